@@ -0,0 +1,108 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+// checkDecrypt reports whether an identity could decrypt a ciphertext
+// with a key without performing the decryption. It checks that the
+// identity's policy, or the key's folder, allows decryption, that the
+// key exists and is neither disabled nor time-locked, and that the
+// ciphertext is well-formed.
+//
+// Clients may only check their own access unless they are the admin.
+// Checks that depend on the client's connection, like geo-fencing,
+// are not evaluated. Neither is the authenticity of the ciphertext,
+// since that requires decrypting it.
+func (s *Server) checkDecrypt(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	var body api.CheckDecryptRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	state := s.state.Load()
+	identity := req.Identity
+	if body.Identity != "" {
+		identity = state.Aliases.Resolve(kes.Identity(body.Identity))
+	}
+	if identity != req.Identity && req.Identity != state.Admin {
+		resp.Fail(http.StatusForbidden, "only the admin can check the access of other identities")
+		return
+	}
+
+	reasons, err := decryptDenials(req.Context(), state, identity, req.Resource, body.Ciphertext, time.Now())
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to read key")
+		return
+	}
+	api.ReplyWith(resp, http.StatusOK, api.CheckDecryptResponse{
+		Allowed: len(reasons) == 0,
+		Reasons: reasons,
+	})
+}
+
+// decryptDenials returns the reasons why the identity could not
+// decrypt the ciphertext with the named key at the given time. It
+// returns no reasons if the decryption would be allowed.
+func decryptDenials(ctx context.Context, state *serverState, identity kes.Identity, name string, ciphertext []byte, now time.Time) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, api.PathKeyDecrypt+name, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var reasons []string
+	if identity != state.Admin {
+		if policy, ok := state.Identities[identity]; !ok {
+			reasons = append(reasons, fmt.Sprintf("identity '%s' has no policy assigned", identity))
+		} else if err := policy.Verify(req); err != nil && !state.Folders.AllowsRequest(req, state.Keys, policy.Name, policy.Policy) {
+			reasons = append(reasons, fmt.Sprintf("policy '%s' does not allow decryption with key '%s'", policy.Name, name))
+		}
+	}
+
+	_, lock, err := state.Keys.GetTimeLock(ctx, name)
+	switch {
+	case errors.Is(err, kes.ErrKeyNotFound):
+		reasons = append(reasons, fmt.Sprintf("key '%s' does not exist", name))
+	case err != nil:
+		return nil, err
+	case !lock.Allows(now):
+		reasons = append(reasons, errTimeLocked(name, lock, now).Error())
+	}
+	if err := state.Lifecycle.Check(req, now); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+	if !crypto.ValidCiphertext(ciphertext) {
+		reasons = append(reasons, "ciphertext is malformed")
+	}
+	return reasons, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestCheckDecrypt(t *testing.T) {
+	t.Parallel()
+
+	appCert, _ := newRenewalCertificate(t, time.Hour)
+	appIdentity := renewalIdentity(appCert.Leaf)
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		Keys: &MemKeyStore{},
+		Policies: map[string]Policy{
+			"my-app": {
+				Allow: map[string]kes.Rule{
+					api.PathKeyCheck + "*":       {},
+					api.PathKeyDecrypt + "app-*": {},
+				},
+				Identities: []kes.Identity{kes.Identity(appIdentity)},
+			},
+		},
+		KeyLifecycle: &KeyLifecycleConfig{
+			Schedules: map[string]*KeySchedule{
+				"app-retired": {Disable: time.Now().Add(-time.Hour)},
+			},
+		},
+	})
+	defer srv.Close()
+
+	admin, app := defaultClient(url), renewalClient(url, appCert)
+	for _, name := range []string{"app-key", "app-retired", "other-key"} {
+		if err := admin.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	ciphertext, err := admin.Encrypt(ctx, "app-key", []byte("Hello World"), nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	check := func(client *kes.Client, name string, body api.CheckDecryptRequest) (api.CheckDecryptResponse, int) {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Failed to encode request: %v", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, url+api.PathKeyCheck+name, bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var result api.CheckDecryptResponse
+		if resp.StatusCode == http.StatusOK {
+			if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return result, resp.StatusCode
+	}
+
+	for i, test := range []struct {
+		Client     *kes.Client
+		Key        string
+		Identity   string
+		Ciphertext []byte
+		Allowed    bool
+		Reasons    int
+	}{
+		{Client: app, Key: "app-key", Ciphertext: ciphertext, Allowed: true},                              // 0
+		{Client: admin, Key: "app-key", Identity: appIdentity, Ciphertext: ciphertext, Allowed: true},     // 1
+		{Client: admin, Key: "app-key", Ciphertext: ciphertext, Allowed: true},                            // 2
+		{Client: app, Key: "other-key", Ciphertext: ciphertext, Reasons: 1},                               // 3
+		{Client: app, Key: "app-missing", Ciphertext: ciphertext, Reasons: 1},                             // 4
+		{Client: app, Key: "app-retired", Ciphertext: ciphertext, Reasons: 1},                             // 5
+		{Client: app, Key: "app-key", Ciphertext: []byte("invalid"), Reasons: 1},                          // 6
+		{Client: admin, Key: "other-key", Identity: "unknown", Ciphertext: []byte("invalid"), Reasons: 2}, // 7
+	} {
+		result, code := check(test.Client, test.Key, api.CheckDecryptRequest{
+			Identity:   test.Identity,
+			Ciphertext: test.Ciphertext,
+		})
+		if code != http.StatusOK {
+			t.Fatalf("Test %d: failed to check decrypt: status code '%d'", i, code)
+		}
+		if result.Allowed != test.Allowed || len(result.Reasons) != test.Reasons {
+			t.Fatalf("Test %d: got allowed '%v' with reasons '%v' - want allowed '%v' with '%d' reasons", i, result.Allowed, result.Reasons, test.Allowed, test.Reasons)
+		}
+	}
+
+	// Only the admin can check the access of other identities.
+	if _, code := check(app, "app-key", api.CheckDecryptRequest{Identity: defaultIdentity, Ciphertext: ciphertext}); code != http.StatusForbidden {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", code, http.StatusForbidden)
+	}
+}
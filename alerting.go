@@ -0,0 +1,521 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/metric"
+)
+
+const (
+	// defaultAlertInterval is the default interval in
+	// which alerting rules are evaluated.
+	defaultAlertInterval = 15 * time.Second
+
+	// defaultAlertSeverity is the default severity of alerts.
+	defaultAlertSeverity = "critical"
+
+	// defaultPagerDutyEndpoint is the default PagerDuty
+	// Events API v2 endpoint.
+	defaultPagerDutyEndpoint = "https://events.pagerduty.com/v2/enqueue"
+)
+
+// Alert states sent to receivers.
+const (
+	alertFiring   = "firing"
+	alertResolved = "resolved"
+)
+
+// alertSeverities are the valid alert severities. They match
+// the severities of the PagerDuty Events API.
+var alertSeverities = []string{"critical", "error", "warning", "info"}
+
+// verifyAlerting returns an error if the alerting
+// configuration is invalid.
+func verifyAlerting(conf *AlertingConfig) error {
+	for name, r := range conf.Receivers {
+		if !validName(name) {
+			return fmt.Errorf("kes: alert receiver name '%s' is empty, too long or contains invalid characters", name)
+		}
+		if r == nil || (r.Webhook == "" && r.Email == nil && r.PagerDuty == nil) {
+			return fmt.Errorf("kes: alert receiver '%s' contains no webhook, email or PagerDuty config", name)
+		}
+		if r.Webhook != "" && !isHTTPURL(r.Webhook) {
+			return fmt.Errorf("kes: alert receiver '%s': webhook '%s' is not a HTTP(S) URL", name, r.Webhook)
+		}
+		if r.Email != nil {
+			if _, _, err := net.SplitHostPort(r.Email.Server); err != nil {
+				return fmt.Errorf("kes: alert receiver '%s': invalid SMTP server '%s'", name, r.Email.Server)
+			}
+			if r.Email.From == "" || len(r.Email.To) == 0 {
+				return fmt.Errorf("kes: alert receiver '%s' contains no email sender or recipients", name)
+			}
+			for _, addr := range append([]string{r.Email.From}, r.Email.To...) {
+				if a, err := mail.ParseAddress(addr); err != nil || a.Address != addr {
+					return fmt.Errorf("kes: alert receiver '%s': invalid email address '%s'", name, addr)
+				}
+			}
+		}
+		if r.PagerDuty != nil {
+			if r.PagerDuty.RoutingKey == "" {
+				return fmt.Errorf("kes: alert receiver '%s' contains no PagerDuty routing key", name)
+			}
+			if r.PagerDuty.Endpoint != "" && !isHTTPURL(r.PagerDuty.Endpoint) {
+				return fmt.Errorf("kes: alert receiver '%s': PagerDuty endpoint '%s' is not a HTTP(S) URL", name, r.PagerDuty.Endpoint)
+			}
+		}
+	}
+
+	names := make(map[string]struct{}, len(conf.Rules))
+	for _, rule := range conf.Rules {
+		if rule == nil {
+			return fmt.Errorf("kes: alert rule is empty")
+		}
+		if !validName(rule.Name) {
+			return fmt.Errorf("kes: alert rule name '%s' is empty, too long or contains invalid characters", rule.Name)
+		}
+		if _, ok := names[rule.Name]; ok {
+			return fmt.Errorf("kes: alert rule '%s' is defined more than once", rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+
+		switch rule.Signal {
+		case AlertBackendUnreachable:
+			if b := rule.Backend; b != "" && b != keyStoreBackend && !strings.HasPrefix(b, "database/") {
+				return fmt.Errorf("kes: alert rule '%s': invalid backend '%s'", rule.Name, b)
+			}
+		case AlertDenyRate:
+			if rule.Threshold > 1 {
+				return fmt.Errorf("kes: alert rule '%s': deny rate threshold '%v' is greater than 1", rule.Name, rule.Threshold)
+			}
+		case AlertMetric:
+			if rule.Metric == "" {
+				return fmt.Errorf("kes: alert rule '%s' contains no metric", rule.Name)
+			}
+		case AlertAudit:
+			if rule.Path == "" {
+				return fmt.Errorf("kes: alert rule '%s' contains no API path", rule.Name)
+			}
+			if _, err := path.Match(rule.Path, ""); err != nil {
+				return fmt.Errorf("kes: alert rule '%s': invalid API path pattern '%s'", rule.Name, rule.Path)
+			}
+		default:
+			return fmt.Errorf("kes: alert rule '%s': unknown signal '%s'", rule.Name, rule.Signal)
+		}
+		if rule.For < 0 {
+			return fmt.Errorf("kes: alert rule '%s': duration '%v' is negative", rule.Name, rule.For)
+		}
+		if rule.Severity != "" && !slices.Contains(alertSeverities, rule.Severity) {
+			return fmt.Errorf("kes: alert rule '%s': invalid severity '%s'", rule.Name, rule.Severity)
+		}
+		for _, name := range rule.Receivers {
+			if _, ok := conf.Receivers[name]; !ok {
+				return fmt.Errorf("kes: alert rule '%s' refers to unknown receiver '%s'", rule.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// isHTTPURL reports whether s is an absolute HTTP(S) URL.
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// alertEngine evaluates alerting rules periodically and
+// notifies receivers once a rule fires or resolves.
+type alertEngine struct {
+	interval  time.Duration
+	source    string
+	receivers map[string]*AlertReceiver
+	rules     []*AlertRule
+
+	requests atomic.Int64   // Requests since the previous evaluation
+	denied   atomic.Int64   // Requests denied since the previous evaluation
+	audits   []atomic.Int64 // Matching audit records per rule since the previous evaluation
+
+	states *alertStates
+}
+
+// alertStates tracks the state of alerting rules by name.
+// It is shared with the alertEngine of a reloaded config
+// such that firing rules are not notified again.
+type alertStates struct {
+	lock  sync.Mutex
+	rules map[string]*alertState
+}
+
+// alertState is the evaluation state of an alerting rule.
+type alertState struct {
+	signal string
+	metric string
+
+	since  time.Time // Since when the value is above the threshold. Zero if it is not.
+	firing bool
+
+	counter float64   // Previous value of a counter metric
+	at      time.Time // Time of the previous counter value. Zero if there is none.
+}
+
+// alert is an alert event and the receivers to notify.
+type alert struct {
+	Event     api.AlertEvent
+	Receivers []string
+}
+
+// newAlertEngine returns a new alertEngine for the given
+// configuration, or nil if conf is nil. Rules that are
+// firing in old, if not nil, remain firing.
+func newAlertEngine(conf *AlertingConfig, old *alertEngine) *alertEngine {
+	if conf == nil {
+		return nil
+	}
+	e := &alertEngine{
+		interval:  conf.Interval,
+		receivers: conf.Receivers,
+		rules:     conf.Rules,
+		audits:    make([]atomic.Int64, len(conf.Rules)),
+	}
+	if e.interval <= 0 {
+		e.interval = defaultAlertInterval
+	}
+	if e.source, _ = os.Hostname(); e.source == "" {
+		e.source = "kes"
+	}
+	if old != nil {
+		e.states = old.states
+	} else {
+		e.states = &alertStates{rules: map[string]*alertState{}}
+	}
+
+	e.states.lock.Lock()
+	defer e.states.lock.Unlock()
+
+	rules := make(map[string]*alertState, len(conf.Rules))
+	for _, rule := range conf.Rules {
+		// A rule keeps its state unless it evaluates another signal.
+		if s, ok := e.states.rules[rule.Name]; ok && s.signal == rule.Signal && s.metric == rule.Metric {
+			rules[rule.Name] = s
+		} else {
+			rules[rule.Name] = &alertState{signal: rule.Signal, metric: rule.Metric}
+		}
+	}
+	e.states.rules = rules
+	return e
+}
+
+// Record records a request that has been denied or allowed.
+func (e *alertEngine) Record(denied bool) {
+	if e == nil {
+		return
+	}
+	e.requests.Add(1)
+	if denied {
+		e.denied.Add(1)
+	}
+}
+
+// Audit records an audit record of the API path with the
+// given response status code.
+func (e *alertEngine) Audit(p string, statusCode int) {
+	if e == nil {
+		return
+	}
+	p = api.VersionPath(p, api.V1)
+	for i, rule := range e.rules {
+		if rule.Signal != AlertAudit || (rule.StatusCode != 0 && rule.StatusCode != statusCode) {
+			continue
+		}
+		if ok, _ := path.Match(rule.Path, p); ok {
+			e.audits[i].Add(1)
+		}
+	}
+}
+
+// Evaluate evaluates all rules and returns an alert for
+// every rule that fires or resolves.
+func (e *alertEngine) Evaluate(now time.Time, health *healthMonitor, metrics *metric.Metrics) []alert {
+	var denyRate float64
+	if requests, denied := e.requests.Swap(0), e.denied.Swap(0); requests > 0 {
+		denyRate = float64(denied) / float64(requests)
+	}
+
+	e.states.lock.Lock()
+	defer e.states.lock.Unlock()
+
+	var alerts []alert
+	for i, rule := range e.rules {
+		state := e.states.rules[rule.Name]
+
+		var value float64
+		switch rule.Signal {
+		case AlertBackendUnreachable:
+			if p, ok := health.Last(cmp.Or(rule.Backend, keyStoreBackend)); ok && p.Err != nil {
+				value = 1
+			}
+		case AlertDenyRate:
+			value = denyRate
+		case AlertMetric:
+			v, counter, ok, err := metrics.Value(rule.Metric, rule.Labels)
+			if err != nil || !ok {
+				// A metric without series, like a counter that has
+				// not been incremented yet, is zero.
+				state.counter, state.at = 0, now
+				break
+			}
+			if !counter {
+				value = v
+				break
+			}
+			if d := now.Sub(state.at).Seconds(); !state.at.IsZero() && d > 0 && v >= state.counter {
+				value = (v - state.counter) / d
+			}
+			state.counter, state.at = v, now
+		case AlertAudit:
+			value = float64(e.audits[i].Swap(0))
+		}
+
+		if value <= rule.Threshold {
+			if state.firing {
+				alerts = append(alerts, e.alert(rule, alertResolved, value, state.since, now))
+			}
+			state.since, state.firing = time.Time{}, false
+			continue
+		}
+		if state.since.IsZero() {
+			state.since = now
+		}
+		if !state.firing && now.Sub(state.since) >= rule.For {
+			state.firing = true
+			alerts = append(alerts, e.alert(rule, alertFiring, value, state.since, now))
+		}
+	}
+	return alerts
+}
+
+// alert returns a new alert for the rule.
+func (e *alertEngine) alert(rule *AlertRule, status string, value float64, since, now time.Time) alert {
+	return alert{
+		Event: api.AlertEvent{
+			Rule:      rule.Name,
+			Status:    status,
+			Severity:  cmp.Or(rule.Severity, defaultAlertSeverity),
+			Signal:    rule.Signal,
+			Value:     value,
+			Threshold: rule.Threshold,
+			Since:     since,
+			Time:      now,
+			Source:    e.source,
+		},
+		Receivers: rule.Receivers,
+	}
+}
+
+// Notify sends the alert to all its receivers concurrently
+// and logs receivers that cannot be notified.
+func (e *alertEngine) Notify(ctx context.Context, log *slog.Logger, a alert) {
+	var wg sync.WaitGroup
+	for _, name := range a.Receivers {
+		r, ok := e.receivers[name]
+		if !ok {
+			continue
+		}
+
+		send := func(channel string, fn func() error) {
+			wg.Go(func() {
+				if err := fn(); err != nil && ctx.Err() == nil {
+					log.WarnContext(ctx, fmt.Sprintf("failed to send alert '%s' to %s receiver '%s': %v", a.Event.Rule, channel, name, err))
+				}
+			})
+		}
+		if r.Webhook != "" {
+			send("webhook", func() error { return sendSinkEvent(ctx, r.Webhook, a.Event) })
+		}
+		if r.Email != nil {
+			send("email", func() error { return sendAlertEmail(ctx, r.Email, a.Event) })
+		}
+		if r.PagerDuty != nil {
+			send("PagerDuty", func() error { return sendPagerDutyEvent(ctx, r.PagerDuty, a.Event) })
+		}
+	}
+	wg.Wait()
+}
+
+// pagerDutyEvent is a PagerDuty Events API v2 event.
+type pagerDutyEvent struct {
+	RoutingKey string            `json:"routing_key"`
+	Action     string            `json:"event_action"` // Either "trigger" or "resolve"
+	DedupKey   string            `json:"dedup_key"`
+	Payload    *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+// pagerDutyPayload is the payload of a PagerDuty trigger event.
+type pagerDutyPayload struct {
+	Summary   string         `json:"summary"`
+	Source    string         `json:"source"`
+	Severity  string         `json:"severity"`
+	Timestamp time.Time      `json:"timestamp"`
+	Component string         `json:"component"`
+	Details   api.AlertEvent `json:"custom_details"`
+}
+
+// sendPagerDutyEvent sends the alert event to the PagerDuty
+// Events API. The alert of a rule triggers and resolves the
+// same PagerDuty incident.
+func sendPagerDutyEvent(ctx context.Context, conf *AlertPagerDutyConfig, e api.AlertEvent) error {
+	event := pagerDutyEvent{
+		RoutingKey: conf.RoutingKey,
+		Action:     "trigger",
+		DedupKey:   e.Source + "/" + e.Rule,
+	}
+	if e.Status == alertResolved {
+		event.Action = "resolve"
+	} else {
+		event.Payload = &pagerDutyPayload{
+			Summary:   fmt.Sprintf("KES alert '%s': %s %v > %v", e.Rule, e.Signal, e.Value, e.Threshold),
+			Source:    e.Source,
+			Severity:  e.Severity,
+			Timestamp: e.Time,
+			Component: "kes",
+			Details:   e,
+		}
+	}
+	return sendSinkEvent(ctx, cmp.Or(conf.Endpoint, defaultPagerDutyEndpoint), event)
+}
+
+// sendAlertEmail sends the alert event as plain text email.
+// The connection is upgraded to TLS if the SMTP server
+// supports STARTTLS.
+func sendAlertEmail(ctx context.Context, conf *AlertEmailConfig, e api.AlertEvent) error {
+	host, _, err := net.SplitHostPort(conf.Server)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sinkTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", conf.Server)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+	if conf.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", conf.Username, conf.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err = client.Mail(conf.From); err != nil {
+		return err
+	}
+	for _, to := range conf.To {
+		if err = client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(alertEmail(conf, e)); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// alertEmail returns the alert event as plain text email message.
+func alertEmail(conf *AlertEmailConfig, e api.AlertEvent) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", conf.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(conf.To, ", "))
+	fmt.Fprintf(&b, "Subject: [KES %s] %s: %s\r\n", strings.ToUpper(e.Status), e.Severity, e.Rule)
+	fmt.Fprintf(&b, "Date: %s\r\n", e.Time.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "Rule:      %s\r\n", e.Rule)
+	fmt.Fprintf(&b, "Status:    %s\r\n", e.Status)
+	fmt.Fprintf(&b, "Severity:  %s\r\n", e.Severity)
+	fmt.Fprintf(&b, "Signal:    %s\r\n", e.Signal)
+	fmt.Fprintf(&b, "Value:     %v\r\n", e.Value)
+	fmt.Fprintf(&b, "Threshold: %v\r\n", e.Threshold)
+	fmt.Fprintf(&b, "Since:     %s\r\n", e.Since.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Source:    %s\r\n", e.Source)
+	return b.Bytes()
+}
+
+// startAlerting evaluates the alerting rules of the current
+// server state periodically until ctx is canceled.
+func (s *Server) startAlerting(ctx context.Context) {
+	go func() {
+		for {
+			interval := defaultAlertInterval
+			if e := s.state.Load().Alerts; e != nil {
+				interval = e.interval
+			}
+
+			timer := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case now := <-timer.C:
+				state := s.state.Load()
+				if state.Alerts == nil {
+					continue
+				}
+
+				for _, a := range state.Alerts.Evaluate(now, &s.health, state.Metrics) {
+					if a.Event.Status == alertFiring {
+						state.Log.WarnContext(ctx, fmt.Sprintf("alert '%s' firing: %s %v > %v", a.Event.Rule, a.Event.Signal, a.Event.Value, a.Event.Threshold))
+					} else {
+						state.Log.InfoContext(ctx, fmt.Sprintf("alert '%s' resolved", a.Event.Rule))
+					}
+					state.Alerts.Notify(ctx, state.Log, a)
+				}
+			}
+		}
+	}()
+}
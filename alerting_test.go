@@ -0,0 +1,341 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/metric"
+)
+
+func TestAlerting(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan api.AlertEvent, 16)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event api.AlertEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		events <- event
+	}))
+	defer webhook.Close()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		Alerting: &AlertingConfig{
+			Interval: 100 * time.Millisecond,
+			Receivers: map[string]*AlertReceiver{
+				"ops": {Webhook: webhook.URL},
+			},
+			Rules: []*AlertRule{
+				{Name: "key-created", Signal: AlertAudit, Path: "/v1/key/create/*", StatusCode: http.StatusOK, Receivers: []string{"ops"}},
+			},
+		},
+	})
+	defer srv.Close()
+
+	if err := defaultClient(url).CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for _, status := range []string{alertFiring, alertResolved} {
+		select {
+		case event := <-events:
+			if event.Rule != "key-created" || event.Status != status || event.Severity != defaultAlertSeverity {
+				t.Fatalf("Alert mismatch: got '%+v' - want status '%s'", event, status)
+			}
+		case <-timeout:
+			t.Fatalf("No '%s' alert has been sent to the webhook", status)
+		}
+	}
+}
+
+func TestAlertEngineEvaluate(t *testing.T) {
+	e := newAlertEngine(&AlertingConfig{
+		Rules: []*AlertRule{
+			{Name: "keystore-unreachable", Signal: AlertBackendUnreachable, For: time.Minute},
+			{Name: "deny-rate", Signal: AlertDenyRate, Threshold: 0.05},
+			{Name: "key-deleted", Signal: AlertAudit, Path: "/v1/key/delete/*"},
+			{Name: "requests", Signal: AlertMetric, Metric: "kes_http_request_success", Threshold: 1},
+		},
+	}, nil)
+
+	var health healthMonitor
+	metrics := metric.New()
+	handler := metrics.Count(api.HandlerFunc(func(resp *api.Response, _ *api.Request) { resp.WriteHeader(http.StatusOK) }))
+	request := func(n int) {
+		for range n {
+			handler.ServeAPI(&api.Response{ResponseWriter: httptest.NewRecorder()}, &api.Request{Request: httptest.NewRequest(http.MethodGet, "/", nil)})
+		}
+	}
+
+	start := time.Now()
+	for i, test := range []struct {
+		Offset time.Duration
+		Setup  func()
+		Alerts []string
+	}{
+		{ // 0: key store unreachable but not long enough, deny rate below threshold
+			Offset: 0,
+			Setup: func() {
+				health.Record(keyStoreBackend, healthProbe{Err: errors.New("connection refused")})
+				for range 20 {
+					e.Record(false)
+				}
+				e.Record(true)
+			},
+		},
+		{ // 1: deny rate above threshold, delete audit record, 2 requests per second
+			Offset: 10 * time.Second,
+			Setup: func() {
+				e.Record(false)
+				e.Record(true)
+				e.Audit("/v1/key/delete/my-key", http.StatusOK)
+				e.Audit("/v1/key/create/my-key", http.StatusOK)
+				request(20)
+			},
+			Alerts: []string{"deny-rate/" + alertFiring, "key-deleted/" + alertFiring, "requests/" + alertFiring},
+		},
+		{ // 2: all rules, except the key store, resolve
+			Offset: 20 * time.Second,
+			Setup:  func() { request(5) },
+			Alerts: []string{"deny-rate/" + alertResolved, "key-deleted/" + alertResolved, "requests/" + alertResolved},
+		},
+		{ // 3: key store unreachable for 1 minute
+			Offset: 60 * time.Second,
+			Alerts: []string{"keystore-unreachable/" + alertFiring},
+		},
+		{ // 4: key store still unreachable
+			Offset: 75 * time.Second,
+		},
+		{ // 5: key store reachable again
+			Offset: 90 * time.Second,
+			Setup:  func() { health.Record(keyStoreBackend, healthProbe{}) },
+			Alerts: []string{"keystore-unreachable/" + alertResolved},
+		},
+	} {
+		if test.Setup != nil {
+			test.Setup()
+		}
+
+		var alerts []string
+		for _, a := range e.Evaluate(start.Add(test.Offset), &health, metrics) {
+			alerts = append(alerts, a.Event.Rule+"/"+a.Event.Status)
+		}
+		if !slices.Equal(alerts, test.Alerts) {
+			t.Fatalf("Test %d: alerts mismatch: got '%v' - want '%v'", i, alerts, test.Alerts)
+		}
+	}
+}
+
+func TestAlertEngineReload(t *testing.T) {
+	conf := &AlertingConfig{
+		Rules: []*AlertRule{
+			{Name: "deny-rate", Signal: AlertDenyRate},
+		},
+	}
+	old := newAlertEngine(conf, nil)
+	old.Record(true)
+	if alerts := old.Evaluate(time.Now(), &healthMonitor{}, nil); len(alerts) != 1 {
+		t.Fatalf("Alerts mismatch: got '%d' - want '%d'", len(alerts), 1)
+	}
+
+	// A rule that keeps firing after a reload is not notified again.
+	e := newAlertEngine(conf, old)
+	e.Record(true)
+	if alerts := e.Evaluate(time.Now(), &healthMonitor{}, nil); len(alerts) != 0 {
+		t.Fatalf("Alerts mismatch: got '%d' - want '%d'", len(alerts), 0)
+	}
+
+	// A rule that evaluates another signal after a reload starts over.
+	e = newAlertEngine(&AlertingConfig{
+		Rules: []*AlertRule{
+			{Name: "deny-rate", Signal: AlertAudit, Path: "/v1/key/delete/*"},
+		},
+	}, e)
+	e.Audit("/v1/key/delete/my-key", http.StatusOK)
+	if alerts := e.Evaluate(time.Now(), &healthMonitor{}, nil); len(alerts) != 1 || alerts[0].Event.Status != alertFiring {
+		t.Fatalf("Alerts mismatch: got '%+v'", alerts)
+	}
+}
+
+func TestAlertNotify(t *testing.T) {
+	t.Parallel()
+
+	pagerDuty := make(chan pagerDutyEvent, 1)
+	pdServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event pagerDutyEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pagerDuty <- event
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer pdServer.Close()
+
+	emails := make(chan string, 1)
+	smtpAddr := startSMTPServer(t, emails)
+
+	e := newAlertEngine(&AlertingConfig{
+		Receivers: map[string]*AlertReceiver{
+			"ops": {
+				Email:     &AlertEmailConfig{Server: smtpAddr, From: "kes@example.com", To: []string{"ops@example.com"}},
+				PagerDuty: &AlertPagerDutyConfig{RoutingKey: "my-routing-key", Endpoint: pdServer.URL},
+			},
+		},
+		Rules: []*AlertRule{
+			{Name: "keystore-unreachable", Signal: AlertBackendUnreachable, Severity: "error", Receivers: []string{"ops"}},
+		},
+	}, nil)
+
+	var health healthMonitor
+	health.Record(keyStoreBackend, healthProbe{Err: errors.New("connection refused")})
+	alerts := e.Evaluate(time.Now(), &health, nil)
+	if len(alerts) != 1 {
+		t.Fatalf("Alerts mismatch: got '%d' - want '%d'", len(alerts), 1)
+	}
+	e.Notify(testContext(t), slog.New(slog.DiscardHandler), alerts[0])
+
+	event := <-pagerDuty
+	if event.RoutingKey != "my-routing-key" || event.Action != "trigger" || event.DedupKey != e.source+"/keystore-unreachable" {
+		t.Fatalf("PagerDuty event mismatch: got '%+v'", event)
+	}
+	if event.Payload == nil || event.Payload.Severity != "error" || event.Payload.Details.Rule != "keystore-unreachable" {
+		t.Fatalf("PagerDuty payload mismatch: got '%+v'", event.Payload)
+	}
+
+	email := <-emails
+	if !strings.Contains(email, "Subject: [KES FIRING] error: keystore-unreachable\r\n") {
+		t.Fatalf("Email mismatch: got '%s'", email)
+	}
+}
+
+func TestVerifyAlerting(t *testing.T) {
+	for i, test := range verifyAlertingTests {
+		err := verifyAlerting(&test.Config)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: verified invalid alerting config", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to verify alerting config: %v", i, err)
+		}
+	}
+}
+
+var verifyAlertingTests = []struct {
+	Config     AlertingConfig
+	ShouldFail bool
+}{
+	{ // 0
+		Config: AlertingConfig{
+			Receivers: map[string]*AlertReceiver{
+				"ops":    {Webhook: "https://alerts.example.com"},
+				"oncall": {PagerDuty: &AlertPagerDutyConfig{RoutingKey: "key"}},
+				"mail":   {Email: &AlertEmailConfig{Server: "smtp.example.com:587", From: "kes@example.com", To: []string{"ops@example.com"}}},
+			},
+			Rules: []*AlertRule{
+				{Name: "keystore", Signal: AlertBackendUnreachable, For: time.Minute, Receivers: []string{"ops", "oncall"}},
+				{Name: "database", Signal: AlertBackendUnreachable, Backend: "database/postgres", Severity: "warning"},
+				{Name: "deny-rate", Signal: AlertDenyRate, Threshold: 0.05, Receivers: []string{"mail"}},
+				{Name: "errors", Signal: AlertMetric, Metric: "kes_http_request_failure"},
+				{Name: "deleted", Signal: AlertAudit, Path: "/v1/key/delete/*"},
+			},
+		},
+	},
+	{Config: AlertingConfig{Rules: []*AlertRule{{Name: "rule", Signal: "unknown"}}}, ShouldFail: true},                                            // 1
+	{Config: AlertingConfig{Rules: []*AlertRule{{Name: "rule", Signal: AlertMetric}}}, ShouldFail: true},                                          // 2
+	{Config: AlertingConfig{Rules: []*AlertRule{{Name: "rule", Signal: AlertAudit, Path: "/v1/key/[delete"}}}, ShouldFail: true},                  // 3
+	{Config: AlertingConfig{Rules: []*AlertRule{{Name: "rule", Signal: AlertDenyRate, Threshold: 2}}}, ShouldFail: true},                          // 4
+	{Config: AlertingConfig{Rules: []*AlertRule{{Name: "rule", Signal: AlertDenyRate, Severity: "fatal"}}}, ShouldFail: true},                     // 5
+	{Config: AlertingConfig{Rules: []*AlertRule{{Name: "rule", Signal: AlertDenyRate, Receivers: []string{"ops"}}}}, ShouldFail: true},            // 6
+	{Config: AlertingConfig{Rules: []*AlertRule{{Name: "rule", Signal: AlertDenyRate}, {Name: "rule", Signal: AlertDenyRate}}}, ShouldFail: true}, // 7
+	{Config: AlertingConfig{Rules: []*AlertRule{{Name: "rule", Signal: AlertBackendUnreachable, Backend: "vault"}}}, ShouldFail: true},            // 8
+	{Config: AlertingConfig{Receivers: map[string]*AlertReceiver{"ops": {}}}, ShouldFail: true},                                                   // 9
+	{Config: AlertingConfig{Receivers: map[string]*AlertReceiver{"ops": {Webhook: "alerts.example.com"}}}, ShouldFail: true},                      // 10
+	{Config: AlertingConfig{Receivers: map[string]*AlertReceiver{"ops": {PagerDuty: &AlertPagerDutyConfig{}}}}, ShouldFail: true},                 // 11
+	{ // 12
+		Config: AlertingConfig{Receivers: map[string]*AlertReceiver{
+			"ops": {Email: &AlertEmailConfig{Server: "smtp.example.com", From: "kes@example.com", To: []string{"ops@example.com"}}},
+		}},
+		ShouldFail: true,
+	},
+	{ // 13
+		Config: AlertingConfig{Receivers: map[string]*AlertReceiver{
+			"ops": {Email: &AlertEmailConfig{Server: "smtp.example.com:587", From: "kes@example.com\r\nBcc: evil@example.com", To: []string{"ops@example.com"}}},
+		}},
+		ShouldFail: true,
+	},
+}
+
+// startSMTPServer starts a minimal SMTP server that accepts
+// any email and sends its content to emails.
+func startSMTPServer(t *testing.T, emails chan<- string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+		reply := func(line string) {
+			w.WriteString(line + "\r\n")
+			w.Flush()
+		}
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 end data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				emails <- data.String()
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String()
+}
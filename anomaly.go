@@ -0,0 +1,267 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kms-go/kes"
+)
+
+const (
+	// defaultAnomalyInterval is the default interval over
+	// which usage features are aggregated.
+	defaultAnomalyInterval = 1 * time.Minute
+
+	// defaultAnomalyMinRequests is the default min. number of
+	// requests before the deny rate of an identity is checked.
+	defaultAnomalyMinRequests = 10
+
+	// maxAnomalyKeys is the max. number of distinct keys tracked
+	// per identity and interval. Once reached, the number of
+	// distinct keys of the identity no longer increases.
+	maxAnomalyKeys = 10_000
+
+	// sinkTimeout is the timeout for sending an event to
+	// a sink, like the anomaly detection sink.
+	sinkTimeout = 10 * time.Second
+)
+
+// Usage features that can raise alerts.
+const (
+	featureRequestRate  = "request_rate"
+	featureDistinctKeys = "distinct_keys"
+	featureDenyRate     = "deny_rate"
+)
+
+// anomalyDetector aggregates usage features per identity and
+// raises alerts once an identity exceeds a threshold.
+type anomalyDetector struct {
+	interval        time.Duration
+	sink            string
+	maxRequestRate  float64
+	maxDistinctKeys int
+	maxDenyRate     float64
+	minRequests     int
+
+	lock       sync.Mutex
+	start      time.Time
+	identities map[kes.Identity]*identityUsage
+}
+
+// identityUsage is the usage of an identity within an interval.
+type identityUsage struct {
+	requests int64
+	denied   int64
+	keys     map[string]struct{}
+}
+
+// newAnomalyDetector returns a new anomaly detector for the
+// given configuration, or nil if conf is nil.
+func newAnomalyDetector(conf *AnomalyDetectionConfig) *anomalyDetector {
+	if conf == nil {
+		return nil
+	}
+	d := &anomalyDetector{
+		interval:        conf.Interval,
+		sink:            conf.Sink,
+		maxRequestRate:  conf.MaxRequestRate,
+		maxDistinctKeys: conf.MaxDistinctKeys,
+		maxDenyRate:     conf.MaxDenyRate,
+		minRequests:     conf.MinRequests,
+		start:           time.Now(),
+		identities:      map[kes.Identity]*identityUsage{},
+	}
+	if d.interval <= 0 {
+		d.interval = defaultAnomalyInterval
+	}
+	if d.minRequests <= 0 {
+		d.minRequests = defaultAnomalyMinRequests
+	}
+	return d
+}
+
+// Record records a request of the identity. The key is the name
+// of the key the request refers to, if any. Denied reports whether
+// the request has been rejected by the identity's policy.
+func (d *anomalyDetector) Record(identity kes.Identity, key string, denied bool) {
+	if d == nil {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	u, ok := d.identities[identity]
+	if !ok {
+		u = &identityUsage{keys: map[string]struct{}{}}
+		d.identities[identity] = u
+	}
+	u.requests++
+	if denied {
+		u.denied++
+	}
+	if key != "" && len(u.keys) < maxAnomalyKeys {
+		u.keys[key] = struct{}{}
+	}
+}
+
+// Flush returns the usage features of all identities, and any
+// alerts, since the last flush and starts a new interval.
+func (d *anomalyDetector) Flush(now time.Time) api.UsageFeaturesEvent {
+	d.lock.Lock()
+	start, identities := d.start, d.identities
+	d.start, d.identities = now, map[kes.Identity]*identityUsage{}
+	d.lock.Unlock()
+
+	event := api.UsageFeaturesEvent{
+		Start:      start,
+		End:        now,
+		Identities: make([]api.UsageFeatures, 0, len(identities)),
+	}
+	seconds := max(now.Sub(start).Seconds(), 1)
+	for identity, u := range identities {
+		f := api.UsageFeatures{
+			Identity:     identity.String(),
+			Requests:     u.requests,
+			RequestRate:  float64(u.requests) / seconds,
+			DistinctKeys: len(u.keys),
+			Denied:       u.denied,
+			DenyRate:     float64(u.denied) / float64(u.requests),
+		}
+		event.Identities = append(event.Identities, f)
+
+		if d.maxRequestRate > 0 && f.RequestRate > d.maxRequestRate {
+			event.Alerts = append(event.Alerts, api.UsageAlert{
+				Identity:  f.Identity,
+				Feature:   featureRequestRate,
+				Value:     f.RequestRate,
+				Threshold: d.maxRequestRate,
+			})
+		}
+		if d.maxDistinctKeys > 0 && f.DistinctKeys > d.maxDistinctKeys {
+			event.Alerts = append(event.Alerts, api.UsageAlert{
+				Identity:  f.Identity,
+				Feature:   featureDistinctKeys,
+				Value:     float64(f.DistinctKeys),
+				Threshold: float64(d.maxDistinctKeys),
+			})
+		}
+		if d.maxDenyRate > 0 && f.Requests >= int64(d.minRequests) && f.DenyRate > d.maxDenyRate {
+			event.Alerts = append(event.Alerts, api.UsageAlert{
+				Identity:  f.Identity,
+				Feature:   featureDenyRate,
+				Value:     f.DenyRate,
+				Threshold: d.maxDenyRate,
+			})
+		}
+	}
+	slices.SortFunc(event.Identities, func(a, b api.UsageFeatures) int { return strings.Compare(a.Identity, b.Identity) })
+	return event
+}
+
+// requestedKey returns the name of the key the request refers to,
+// or the empty string if the request does not refer to a key.
+func requestedKey(req *http.Request) string {
+	_, name := keyOperation(req)
+	return name
+}
+
+// keyOperation returns the key operation, like "encrypt", and the
+// name of the key the request refers to. It returns empty strings
+// if the request does not refer to a key.
+func keyOperation(req *http.Request) (op, name string) {
+	_, path, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/") // Strip the API version
+	path, ok := strings.CutPrefix(path, "key/")
+	if !ok {
+		return "", ""
+	}
+	op, name, _ = strings.Cut(path, "/")
+	if op == "list" {
+		return "", ""
+	}
+	return op, name
+}
+
+// startAnomalyDetector flushes the usage features at the end of
+// every interval until ctx is canceled. It logs all alerts and
+// sends the features to the sink, if any.
+func (s *Server) startAnomalyDetector(ctx context.Context) {
+	go func() {
+		for {
+			interval := defaultAnomalyInterval
+			if d := s.state.Load().Anomalies; d != nil {
+				interval = d.interval
+			}
+
+			timer := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case now := <-timer.C:
+				state := s.state.Load()
+				if state.Anomalies == nil {
+					continue
+				}
+
+				event := state.Anomalies.Flush(now)
+				if p := state.Audit.pseudonyms.Load(); p != nil {
+					for i := range event.Identities {
+						event.Identities[i].Identity = p.Pseudonym(identityPseudonymPrefix, event.Identities[i].Identity)
+					}
+					for i := range event.Alerts {
+						event.Alerts[i].Identity = p.Pseudonym(identityPseudonymPrefix, event.Alerts[i].Identity)
+					}
+				}
+				for _, alert := range event.Alerts {
+					state.Metrics.AnomalyAlert(alert.Feature)
+					state.Log.WarnContext(ctx, fmt.Sprintf("usage anomaly: identity '%s' exceeded %s threshold: %v > %v", alert.Identity, alert.Feature, alert.Value, alert.Threshold))
+				}
+				if state.Anomalies.sink != "" && len(event.Identities) > 0 {
+					if err := sendSinkEvent(ctx, state.Anomalies.sink, event); err != nil && ctx.Err() == nil {
+						state.Log.WarnContext(ctx, fmt.Sprintf("failed to send usage features to '%s': %v", state.Anomalies.sink, err))
+					}
+				}
+			}
+		}
+	}()
+}
+
+// sendSinkEvent sends the event as JSON object to the sink.
+func sendSinkEvent(ctx context.Context, sink string, event any) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sinkTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sink, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(headers.ContentType, headers.ContentTypeJSON)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("sink responded with status '%s'", resp.Status)
+	}
+	return nil
+}
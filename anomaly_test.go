@@ -0,0 +1,147 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+)
+
+func TestAnomalyDetection(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan api.UsageFeaturesEvent, 16)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event api.UsageFeaturesEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		events <- event
+	}))
+	defer sink.Close()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		AnomalyDetection: &AnomalyDetectionConfig{
+			Interval:        100 * time.Millisecond,
+			Sink:            sink.URL,
+			MaxDistinctKeys: 2,
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	for _, name := range []string{"key-1", "key-2", "key-3"} {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if len(event.Alerts) == 0 {
+				continue
+			}
+			alert := event.Alerts[0]
+			if alert.Identity != defaultIdentity || alert.Feature != featureDistinctKeys || alert.Value != 3 {
+				t.Fatalf("Alert mismatch: got '%+v'", alert)
+			}
+			return
+		case <-timeout:
+			t.Fatal("No usage anomaly alert has been sent to the sink")
+		}
+	}
+}
+
+func TestAnomalyDetectorFlush(t *testing.T) {
+	for i, test := range anomalyDetectorTests {
+		d := newAnomalyDetector(&test.Config)
+		d.start = time.Now()
+		for _, r := range test.Requests {
+			d.Record("identity", r.Key, r.Denied)
+		}
+
+		event := d.Flush(d.start.Add(time.Second))
+		features := make([]string, 0, len(event.Alerts))
+		for _, alert := range event.Alerts {
+			features = append(features, alert.Feature)
+		}
+		if !slices.Equal(features, test.Alerts) {
+			t.Fatalf("Test %d: alerts mismatch: got '%v' - want '%v'", i, features, test.Alerts)
+		}
+		if len(d.identities) != 0 {
+			t.Fatalf("Test %d: usage has not been reset after flush", i)
+		}
+	}
+}
+
+func TestRequestedKey(t *testing.T) {
+	for i, test := range requestedKeyTests {
+		req := httptest.NewRequest(http.MethodGet, test.Path, nil)
+		if key := requestedKey(req); key != test.Key {
+			t.Fatalf("Test %d: key mismatch: got '%s' - want '%s'", i, key, test.Key)
+		}
+	}
+}
+
+type anomalyRequest struct {
+	Key    string
+	Denied bool
+}
+
+var anomalyDetectorTests = []struct {
+	Config   AnomalyDetectionConfig
+	Requests []anomalyRequest
+	Alerts   []string
+}{
+	{ // 0
+		Config:   AnomalyDetectionConfig{MaxDistinctKeys: 1},
+		Requests: []anomalyRequest{{Key: "a"}, {Key: "a"}},
+	},
+	{ // 1
+		Config:   AnomalyDetectionConfig{MaxDistinctKeys: 1},
+		Requests: []anomalyRequest{{Key: "a"}, {Key: "b"}},
+		Alerts:   []string{featureDistinctKeys},
+	},
+	{ // 2
+		Config:   AnomalyDetectionConfig{MaxRequestRate: 2},
+		Requests: []anomalyRequest{{}, {}, {}},
+		Alerts:   []string{featureRequestRate},
+	},
+	{ // 3
+		Config:   AnomalyDetectionConfig{MaxDenyRate: 0.5, MinRequests: 4},
+		Requests: []anomalyRequest{{Denied: true}, {Denied: true}, {Denied: true}},
+	},
+	{ // 4
+		Config:   AnomalyDetectionConfig{MaxDenyRate: 0.5, MinRequests: 4},
+		Requests: []anomalyRequest{{Denied: true}, {Denied: true}, {Denied: true}, {}},
+		Alerts:   []string{featureDenyRate},
+	},
+	{ // 5
+		Config:   AnomalyDetectionConfig{MaxRequestRate: 1, MaxDistinctKeys: 1},
+		Requests: []anomalyRequest{{Key: "a"}, {Key: "b"}},
+		Alerts:   []string{featureRequestRate, featureDistinctKeys},
+	},
+}
+
+var requestedKeyTests = []struct {
+	Path string
+	Key  string
+}{
+	{Path: api.PathKeyDecrypt + "my-key", Key: "my-key"}, // 0
+	{Path: "/v2/key/encrypt/my-key", Key: "my-key"},      // 1
+	{Path: api.PathKeyList + "my-*", Key: ""},            // 2
+	{Path: api.PathPolicyDescribe + "my-key", Key: ""},   // 3
+	{Path: api.PathKeyInventory, Key: ""},                // 4
+}
@@ -7,15 +7,25 @@ package kes
 import (
 	"bytes"
 	"crypto/hmac"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"runtime"
 	"slices"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"aead.dev/mem"
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kes/internal/headers"
+	xhttp "github.com/minio/kes/internal/http"
 	"github.com/minio/kms-go/kes"
 )
 
@@ -49,17 +59,33 @@ func TestAPI(t *testing.T) {
 
 	t.Run("v1/metrics", testMetrics)
 	t.Run("v1/api", testListAPIDefaults)
+	t.Run("v1/openapi", testOpenAPI)
+	t.Run("errors", testProblemDetails)
+	t.Run("v2", testAPIVersions)
 	t.Run("v1/status", testStatus)
+	t.Run("v1/batch", testBatch)
+	t.Run("v1/changes", testChanges)
+	t.Run("v1/maintenance", testMaintenance)
+	t.Run("v1/standby", testStandby)
+	t.Run("v1/cache/key", testCacheKey)
 	t.Run("v1/key/create", testCreateKey)
+	t.Run("v1/key/create/idempotent", testIdempotentCreateKey)
 	t.Run("v1/key/delete", testDeleteKey)
 	t.Run("v1/key/import", testImportKey)
 	t.Run("v1/key/describe", testDescribeKey)
 	t.Run("v1/key/generate", testGenerateKey)
 	t.Run("v1/key/hmac", testHMAC)
 	t.Run("v1/key/encrypt", testEncryptDecryptKey) // also tests decryption
+	t.Run("v1/key/seal", testEnvelope)             // also tests opening
+	t.Run("v1/ciphertext/inspect", testInspectCiphertext)
 	t.Run("v1/key/list", testListKeys)
+	t.Run("v1/key/list/filter", testListKeysFilter)
+	t.Run("v1/key/list/page", testListKeysPage)
+	t.Run("v1/key/inventory", testKeyInventory)
 	t.Run("v1/identity/describe", testDescribeIdentity)
 	t.Run("v1/identity/list", testListIdentities)
+	t.Run("v1/job", testJobs)
+	t.Run("v1/lock", testLocks)
 	t.Run("v1/identity/self/describe", testSelfDescribeIdentity)
 	t.Run("v1/policy/describe", testDescribePolicy)
 	t.Run("v1/policy/read", testReadPolicy)
@@ -127,21 +153,49 @@ func testListAPIDefaults(t *testing.T) {
 		MaxBody mem.Size
 		Timeout time.Duration
 	}{
-		"/version":    {Method: http.MethodGet, MaxBody: 0, Timeout: 10 * time.Second},
-		"/v1/ready":   {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
-		"/v1/status":  {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
-		"/v1/metrics": {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
-		"/v1/api":     {Method: http.MethodGet, MaxBody: 0, Timeout: 10 * time.Second},
-
-		"/v1/key/create/":   {Method: http.MethodPut, MaxBody: 0, Timeout: 15 * time.Second},
-		"/v1/key/import/":   {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
-		"/v1/key/describe/": {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
-		"/v1/key/list/":     {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
-		"/v1/key/delete/":   {Method: http.MethodDelete, MaxBody: 0, Timeout: 15 * time.Second},
-		"/v1/key/generate/": {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
-		"/v1/key/encrypt/":  {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
-		"/v1/key/decrypt/":  {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
-		"/v1/key/hmac/":     {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
+		"/version":           {Method: http.MethodGet, MaxBody: 0, Timeout: 10 * time.Second},
+		"/v1/ready":          {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/status":         {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/status/backend": {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/metrics":        {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/api":            {Method: http.MethodGet, MaxBody: 0, Timeout: 10 * time.Second},
+		"/v1/openapi":        {Method: http.MethodGet, MaxBody: 0, Timeout: 10 * time.Second},
+
+		"/v1/changes":         {Method: http.MethodGet, MaxBody: 0, Timeout: 0},
+		"/v1/maintenance":     {Method: http.MethodPut, MaxBody: 1 * mem.KB, Timeout: 15 * time.Second},
+		"/v1/batch":           {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 30 * time.Second},
+		"/v1/selftest":        {Method: http.MethodPut, MaxBody: 0, Timeout: 30 * time.Second},
+		"/v1/cache/sync":      {Method: http.MethodGet, MaxBody: 0, Timeout: 30 * time.Second},
+		"/v1/cache/key/":      {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/standby/promote": {Method: http.MethodPut, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/key/create/":     {Method: http.MethodPut, MaxBody: 16 * mem.KB, Timeout: 15 * time.Second},
+		"/v1/key/import/":     {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
+		"/v1/key/describe/":   {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/key/list/":       {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/key/delete/":     {Method: http.MethodDelete, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/key/generate/":   {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
+		"/v1/key/encrypt/":    {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
+		"/v1/key/decrypt/":    {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
+		"/v1/key/check/":      {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
+		"/v1/key/rewrap/":     {Method: http.MethodPut, MaxBody: 1 * mem.KB, Timeout: 15 * time.Second},
+		"/v1/key/hmac/":       {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
+		"/v1/key/seal/":       {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
+		"/v1/key/open/":       {Method: http.MethodPut, MaxBody: 2 * mem.MB, Timeout: 15 * time.Second},
+		"/v1/key/tokenize/":   {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
+		"/v1/key/detokenize/": {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
+		"/v1/key/reveal/":     {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
+		"/v1/key/stale/":      {Method: http.MethodGet, MaxBody: 0, Timeout: 30 * time.Second},
+		"/v1/key/inventory":   {Method: http.MethodGet, MaxBody: 0, Timeout: 60 * time.Second},
+		"/v1/key/hold/":       {Method: http.MethodPut, MaxBody: 16 * mem.KB, Timeout: 15 * time.Second},
+		"/v1/key/folder/":     {Method: http.MethodPut, MaxBody: 1 * mem.KB, Timeout: 15 * time.Second},
+		"/v1/key/owner/":      {Method: http.MethodPut, MaxBody: 1 * mem.KB, Timeout: 15 * time.Second},
+		"/v1/key/reserve/":    {Method: http.MethodPut, MaxBody: 1 * mem.KB, Timeout: 15 * time.Second},
+		"/v1/key/release/":    {Method: http.MethodPut, MaxBody: 1 * mem.KB, Timeout: 15 * time.Second},
+		"/v1/key/shred/":      {Method: http.MethodDelete, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/key/receipt/":    {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+
+		"/v1/ciphertext/inspect": {Method: http.MethodPut, MaxBody: 4 * mem.MB, Timeout: 15 * time.Second},
+		"/v1/tenant/shred/":      {Method: http.MethodDelete, MaxBody: 0, Timeout: 15 * time.Second},
 
 		"/v1/policy/describe/": {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
 		"/v1/policy/read/":     {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
@@ -149,39 +203,897 @@ func testListAPIDefaults(t *testing.T) {
 
 		"/v1/identity/describe/":     {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
 		"/v1/identity/self/describe": {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/identity/self/renew":    {Method: http.MethodPut, MaxBody: 16 * mem.KB, Timeout: 15 * time.Second},
 		"/v1/identity/list/":         {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/identity/stale/":        {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
 
-		"/v1/log/error": {Method: http.MethodGet, MaxBody: 0, Timeout: 0},
-		"/v1/log/audit": {Method: http.MethodGet, MaxBody: 0, Timeout: 0},
+		"/v1/job/start/":    {Method: http.MethodPut, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/job/describe/": {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/job/list":      {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/job/cancel/":   {Method: http.MethodDelete, MaxBody: 0, Timeout: 15 * time.Second},
+
+		"/v1/lock/acquire/":  {Method: http.MethodPut, MaxBody: 1 * mem.KB, Timeout: 15 * time.Second},
+		"/v1/lock/renew/":    {Method: http.MethodPut, MaxBody: 1 * mem.KB, Timeout: 15 * time.Second},
+		"/v1/lock/release/":  {Method: http.MethodPut, MaxBody: 1 * mem.KB, Timeout: 15 * time.Second},
+		"/v1/lock/describe/": {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+
+		"/v1/db/creds/":  {Method: http.MethodPut, MaxBody: 1 * mem.KB, Timeout: 15 * time.Second},
+		"/v1/db/revoke/": {Method: http.MethodPut, MaxBody: 0, Timeout: 15 * time.Second},
+
+		"/v1/ssh/ca":    {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/ssh/sign/": {Method: http.MethodPut, MaxBody: 16 * mem.KB, Timeout: 15 * time.Second},
+
+		"/v1/pki/ca":        {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/pki/ca/import": {Method: http.MethodPut, MaxBody: 1 * mem.MB, Timeout: 15 * time.Second},
+		"/v1/pki/issue/":    {Method: http.MethodPut, MaxBody: 64 * mem.KB, Timeout: 15 * time.Second},
+		"/v1/pki/revoke/":   {Method: http.MethodPut, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/pki/crl":       {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/pki/ocsp":      {Method: http.MethodPost, MaxBody: 16 * mem.KB, Timeout: 15 * time.Second},
+		"/v1/pki/ocsp/":     {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+
+		"/v1/witness/cosign": {Method: http.MethodPut, MaxBody: 16 * mem.KB, Timeout: 15 * time.Second},
+
+		"/v1/conn/list":   {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/conn/close/": {Method: http.MethodDelete, MaxBody: 0, Timeout: 15 * time.Second},
+
+		"/v1/login/challenge": {Method: http.MethodPost, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/login":           {Method: http.MethodPost, MaxBody: 64 * mem.KB, Timeout: 15 * time.Second},
+
+		"/v1/attestation": {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+
+		"/v1/merkle/root":   {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+		"/v1/merkle/proof/": {Method: http.MethodGet, MaxBody: 0, Timeout: 30 * time.Second},
+
+		"/v1/log/error":            {Method: http.MethodGet, MaxBody: 0, Timeout: 0},
+		"/v1/log/audit":            {Method: http.MethodGet, MaxBody: 0, Timeout: 0},
+		"/v1/log/audit/pseudonym/": {Method: http.MethodGet, MaxBody: 0, Timeout: 15 * time.Second},
+	}
+
+	t.Parallel()
+	ctx := testContext(t)
+	srv, url := startServer(ctx, nil)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	routes, err := client.APIs(ctx)
+	if err != nil {
+		t.Fatalf("Failed fetch server APIs: %v", err)
+	}
+	if len(routes) != len(defaults) {
+		t.Fatalf("Routes mismatch: got len '%d' - want len '%d'", len(routes), len(defaults))
+	}
+	for i := range routes {
+		api, ok := defaults[routes[i].Path]
+		if !ok {
+			t.Fatalf("Route '%s': not found", routes[i].Path)
+		}
+		if routes[i].Method != api.Method {
+			t.Fatalf("Route '%s': method mismatch: got '%s' - want '%s'", routes[i].Path, routes[i].Method, api.Method)
+		}
+		if routes[i].MaxBody != int64(api.MaxBody) {
+			t.Fatalf("Route '%s': max body mismatch: got '%d' - want '%d'", routes[i].Path, routes[i].MaxBody, api.MaxBody)
+		}
+		if routes[i].Timeout != api.Timeout {
+			t.Fatalf("Route '%s': timeout mismatch: got '%v' - want '%v'", routes[i].Path, routes[i].Timeout, api.Timeout)
+		}
+	}
+}
+
+func testBatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, nil)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	batch := func(ops ...api.BatchOperation) (int, api.BatchErrorResponse) {
+		body, err := json.Marshal(api.BatchRequest{Operations: ops})
+		if err != nil {
+			t.Fatalf("Failed to encode batch: %v", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, url+api.PathBatch, bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send batch: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var response api.BatchErrorResponse
+		if resp.StatusCode != http.StatusOK {
+			if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return resp.StatusCode, response
+	}
+
+	status, _ := batch(
+		api.BatchOperation{Op: api.BatchCreateKey, Name: "tenant-key"},
+		api.BatchOperation{Op: api.BatchSetPolicy, Name: "tenant", Allow: []string{"/v1/key/encrypt/tenant-*"}},
+		api.BatchOperation{Op: api.BatchAssignIdentity, Identity: "tenant-identity", Policy: "tenant"},
+	)
+	if status != http.StatusOK {
+		t.Fatalf("Failed to apply batch: got status '%d'", status)
+	}
+	if _, err := client.DescribeKey(ctx, "tenant-key"); err != nil {
+		t.Fatalf("Failed to describe key created by batch: %v", err)
+	}
+	if _, err := client.DescribePolicy(ctx, "tenant"); err != nil {
+		t.Fatalf("Failed to describe policy created by batch: %v", err)
+	}
+	if info, err := client.DescribeIdentity(ctx, "tenant-identity"); err != nil || info.Policy != "tenant" {
+		t.Fatalf("Failed to describe identity assigned by batch: got '%v' - err '%v'", info, err)
+	}
+
+	status, resp := batch(
+		api.BatchOperation{Op: api.BatchCreateKey, Name: "tenant-key-2"},
+		api.BatchOperation{Op: api.BatchCreateKey, Name: "tenant-key"},
+		api.BatchOperation{Op: api.BatchSetPolicy, Name: "tenant-2"},
+	)
+	if status == http.StatusOK {
+		t.Fatal("Batch should have failed: key already exists")
+	}
+	if resp.FailedAt != 1 || resp.Op != api.BatchCreateKey {
+		t.Fatalf("Batch failed at wrong operation: got '%d' (%s) - want '1' (%s)", resp.FailedAt, resp.Op, api.BatchCreateKey)
+	}
+	if _, err := client.DescribeKey(ctx, "tenant-key-2"); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Batch has not been rolled back: key 'tenant-key-2' exists: %v", err)
+	}
+	if _, err := client.DescribePolicy(ctx, "tenant-2"); err == nil {
+		t.Fatal("Batch has not been rolled back: policy 'tenant-2' exists")
+	}
+
+	status, resp = batch(api.BatchOperation{Op: api.BatchAssignIdentity, Identity: "tenant-identity", Policy: "does-not-exist"})
+	if status != http.StatusNotFound || resp.FailedAt != 0 {
+		t.Fatalf("Batch should have failed: got status '%d' - want '%d'", status, http.StatusNotFound)
+	}
+}
+
+func testChanges(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, nil)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	changes := func(query string) (*http.Response, *json.Decoder) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+api.PathChanges+query, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to fetch changes: %v", err)
+		}
+		return resp, json.NewDecoder(resp.Body)
+	}
+
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := client.DeleteKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+
+	resp, decoder := changes("?follow=false")
+	var events []api.ChangeEvent
+	for {
+		var event api.ChangeEvent
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			t.Fatalf("Failed to decode change event: %v", err)
+		}
+		events = append(events, event)
+	}
+	resp.Body.Close()
+
+	if len(events) != 2 {
+		t.Fatalf("Invalid number of change events: got '%d' - want '2'", len(events))
+	}
+	if e := events[0]; e.Object != api.ChangeObjectKey || e.Op != api.ChangeCreate || e.Name != "my-key" {
+		t.Fatalf("Invalid change event: got '%+v'", e)
+	}
+	if e := events[1]; e.Object != api.ChangeObjectKey || e.Op != api.ChangeDelete || e.Name != "my-key" || e.Seq <= events[0].Seq {
+		t.Fatalf("Invalid change event: got '%+v'", e)
+	}
+
+	resp, decoder = changes("?since=" + events[1].Cursor)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to follow changes: got status '%d'", resp.StatusCode)
+	}
+	if err := client.CreateKey(ctx, "my-key-2"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	var event api.ChangeEvent
+	if err := decoder.Decode(&event); err != nil {
+		t.Fatalf("Failed to decode change event: %v", err)
+	}
+	if event.Name != "my-key-2" || event.Seq != events[1].Seq+1 {
+		t.Fatalf("Invalid change event: got '%+v'", event)
+	}
+
+	epoch, _, _ := strings.Cut(events[1].Cursor, ".")
+	resp, _ = changes("?since=" + epoch + ".1000")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGone {
+		t.Fatalf("Invalid status for unavailable position: got '%d' - want '%d'", resp.StatusCode, http.StatusGone)
+	}
+
+	// A cursor of another server, or of this server before a
+	// restart, must not be resumed even though its sequence
+	// number is still available.
+	resp, _ = changes("?since=ANOTHEREPOCH." + strconv.FormatUint(events[0].Seq, 10))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGone {
+		t.Fatalf("Invalid status for cursor of another feed: got '%d' - want '%d'", resp.StatusCode, http.StatusGone)
+	}
+	resp, _ = changes("?since=" + strconv.FormatUint(events[0].Seq, 10))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Invalid status for malformed cursor: got '%d' - want '%d'", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func testMaintenance(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, nil)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	ciphertext, err := client.Encrypt(ctx, "my-key", []byte("Hello World"), nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	setReadOnly := func(readOnly bool) {
+		body, _ := json.Marshal(api.MaintenanceRequest{ReadOnly: readOnly, Reason: "key store migration"})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, url+api.PathMaintenance, bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to set maintenance mode: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Failed to set maintenance mode: got status '%d'", resp.StatusCode)
+		}
+	}
+
+	setReadOnly(true)
+	if err := client.CreateKey(ctx, "my-key-2"); err == nil {
+		t.Fatal("Creating a key in read-only mode should have failed")
+	}
+	if err := client.DeleteKey(ctx, "my-key"); err == nil {
+		t.Fatal("Deleting a key in read-only mode should have failed")
+	}
+	if _, err := client.Decrypt(ctx, "my-key", ciphertext, nil); err != nil {
+		t.Fatalf("Failed to decrypt in read-only mode: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url+api.PathJobStart+"rewrap", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Starting a job in read-only mode should have failed: got status '%d'", resp.StatusCode)
+	}
+
+	setReadOnly(false)
+	if err := client.CreateKey(ctx, "my-key-2"); err != nil {
+		t.Fatalf("Failed to create key after leaving read-only mode: %v", err)
+	}
+}
+
+func testOpenAPI(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, nil)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+api.PathOpenAPI, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to fetch OpenAPI specification: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var doc api.OpenAPIDocument
+	if err = json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("Failed to decode OpenAPI specification: %v", err)
+	}
+	if doc.OpenAPI != api.OpenAPIVersion {
+		t.Fatalf("OpenAPI version mismatch: got '%s' - want '%s'", doc.OpenAPI, api.OpenAPIVersion)
+	}
+
+	routes := srv.state.Load().Routes
+	operations := 0
+	for _, ops := range doc.Paths {
+		operations += len(ops)
+	}
+	if operations != len(routes) {
+		t.Fatalf("OpenAPI specification contains '%d' operations - want '%d'", operations, len(routes))
+	}
+	for _, route := range routes {
+		path := route.Path
+		if strings.HasSuffix(path, "/") {
+			for p := range doc.Paths {
+				if strings.HasPrefix(p, path+"{") {
+					path = p
+				}
+			}
+		}
+		op, ok := doc.Paths[path][strings.ToLower(route.Method)]
+		if !ok {
+			t.Fatalf("OpenAPI specification does not contain '%s %s'", route.Method, route.Path)
+		}
+		if op.Summary == "" {
+			t.Fatalf("OpenAPI operation '%s %s' has no summary", route.Method, route.Path)
+		}
+	}
+
+	var verifyRefs func(*api.Schema)
+	verifyRefs = func(s *api.Schema) {
+		if s == nil {
+			return
+		}
+		if name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/"); ok {
+			if _, ok = doc.Components.Schemas[name]; !ok {
+				t.Fatalf("OpenAPI schema '%s' does not exist", s.Ref)
+			}
+		}
+		verifyRefs(s.Items)
+		verifyRefs(s.AdditionalProperties)
+		for _, p := range s.Properties {
+			verifyRefs(p)
+		}
+	}
+	for _, ops := range doc.Paths {
+		for _, op := range ops {
+			if op.RequestBody != nil {
+				for _, m := range op.RequestBody.Content {
+					verifyRefs(m.Schema)
+				}
+			}
+			for _, r := range op.Responses {
+				for _, m := range r.Content {
+					verifyRefs(m.Schema)
+				}
+			}
+		}
+	}
+	if schema := doc.Components.Schemas["EncryptKeyRequest"]; schema == nil || schema.Properties["plaintext"] == nil || schema.Properties["plaintext"].ContentEncoding != "base64" {
+		t.Fatalf("Invalid OpenAPI schema for 'EncryptKeyRequest': %v", schema)
+	}
+}
+
+func testProblemDetails(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, nil)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	client.HTTPClient.Transport = client.HTTPClient.Transport.(xhttp.LegacyErrors).RoundTripper
+	for i, test := range problemDetailsTests {
+		req, err := http.NewRequestWithContext(ctx, test.Method, url+test.Path, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		if test.Accept != "" {
+			req.Header.Set(headers.Accept, test.Accept)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Test %d: failed to send request: %v", i, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("Test %d: failed to read response: %v", i, err)
+		}
+
+		if resp.StatusCode != test.StatusCode {
+			t.Fatalf("Test %d: status code mismatch: got '%d' - want '%d'", i, resp.StatusCode, test.StatusCode)
+		}
+		if test.Code == "" { // Legacy format
+			if ct := resp.Header.Get(headers.ContentType); ct != headers.ContentTypeJSON {
+				t.Fatalf("Test %d: content type mismatch: got '%s' - want '%s'", i, ct, headers.ContentTypeJSON)
+			}
+			var response struct {
+				Message string `json:"message"`
+			}
+			if err = json.Unmarshal(body, &response); err != nil || response.Message == "" {
+				t.Fatalf("Test %d: invalid legacy error response: %s", i, body)
+			}
+			continue
+		}
+
+		if ct := resp.Header.Get(headers.ContentType); ct != headers.ContentTypeProblemJSON {
+			t.Fatalf("Test %d: content type mismatch: got '%s' - want '%s'", i, ct, headers.ContentTypeProblemJSON)
+		}
+		var problem api.Problem
+		if err = json.Unmarshal(body, &problem); err != nil {
+			t.Fatalf("Test %d: invalid problem details: %v", i, err)
+		}
+		if problem.Code != test.Code {
+			t.Fatalf("Test %d: error code mismatch: got '%s' - want '%s'", i, problem.Code, test.Code)
+		}
+		if problem.Status != test.StatusCode || problem.Type != api.ProblemTypePrefix+test.Code || problem.Title == "" || problem.Detail == "" {
+			t.Fatalf("Test %d: invalid problem details: %s", i, body)
+		}
+	}
+}
+
+var problemDetailsTests = []struct {
+	Method     string
+	Path       string
+	Accept     string
+	StatusCode int
+	Code       string
+}{
+	{ // 0
+		Method:     http.MethodGet,
+		Path:       api.PathKeyDescribe + "does-not-exist",
+		StatusCode: http.StatusNotFound,
+		Code:       api.CodeKeyNotFound,
+	},
+	{ // 1
+		Method:     http.MethodGet,
+		Path:       api.PathKeyDescribe + "does-not-exist",
+		Accept:     "*/*",
+		StatusCode: http.StatusNotFound,
+		Code:       api.CodeKeyNotFound,
+	},
+	{ // 2
+		Method:     http.MethodGet,
+		Path:       api.PathKeyDescribe + "does-not-exist",
+		Accept:     headers.ContentTypeProblemJSON,
+		StatusCode: http.StatusNotFound,
+		Code:       api.CodeKeyNotFound,
+	},
+	{ // 3
+		Method:     http.MethodGet,
+		Path:       api.PathPolicyDescribe + "does-not-exist",
+		Accept:     headers.ContentTypeProblemJSON + ", " + headers.ContentTypeJSON,
+		StatusCode: http.StatusNotFound,
+		Code:       api.CodePolicyNotFound,
+	},
+	{ // 4
+		Method:     http.MethodDelete,
+		Path:       api.PathKeyDescribe + "my-key",
+		Accept:     headers.ContentTypeProblemJSON,
+		StatusCode: http.StatusMethodNotAllowed,
+		Code:       "method_not_allowed",
+	},
+	{ // 5
+		Method:     http.MethodGet,
+		Path:       api.VersionPath(api.PathKeyDescribe, api.V2) + "does-not-exist",
+		StatusCode: http.StatusNotFound,
+		Code:       api.CodeKeyNotFound,
+	},
+	{ // 6
+		Method:     http.MethodGet,
+		Path:       api.PathKeyDescribe + "does-not-exist",
+		Accept:     headers.ContentTypeJSON,
+		StatusCode: http.StatusNotFound,
+	},
+	{ // 7
+		Method:     http.MethodGet,
+		Path:       api.VersionPath(api.PathKeyDescribe, api.V2) + "does-not-exist",
+		Accept:     headers.ContentTypeJSON,
+		StatusCode: http.StatusNotFound,
+		Code:       api.CodeKeyNotFound,
+	},
+	{ // 8
+		Method:     http.MethodGet,
+		Path:       api.PathPolicyDescribe + "does-not-exist",
+		Accept:     headers.ContentTypeJSON,
+		StatusCode: http.StatusNotFound,
+	},
+}
+
+func testAPIVersions(t *testing.T) {
+	t.Parallel()
+
+	deprecation := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	sunset := deprecation.AddDate(1, 0, 0)
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		Routes: map[string]RouteConfig{
+			api.PathKeyDescribe: {Deprecation: deprecation, Sunset: sunset},
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	get := func(path string) *http.Response {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request to '%s': %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Failed to send request to '%s': got status '%d' - want '%d'", path, resp.StatusCode, http.StatusOK)
+		}
+		return resp
+	}
+
+	resp := get(api.PathKeyDescribe + "my-key")
+	if v := resp.Header.Get(headers.Deprecation); v != "@1704067200" {
+		t.Fatalf("Deprecation header mismatch: got '%s' - want '%s'", v, "@1704067200")
+	}
+	if v := resp.Header.Get(headers.Sunset); v != sunset.Format(http.TimeFormat) {
+		t.Fatalf("Sunset header mismatch: got '%s' - want '%s'", v, sunset.Format(http.TimeFormat))
+	}
+	if v, want := resp.Header.Get(headers.Link), "<"+api.VersionPath(api.PathKeyDescribe, api.V2)+`>; rel="successor-version"`; v != want {
+		t.Fatalf("Link header mismatch: got '%s' - want '%s'", v, want)
+	}
+
+	resp = get(api.VersionPath(api.PathKeyDescribe, api.V2) + "my-key")
+	if v := resp.Header.Get(headers.Deprecation); v != "" {
+		t.Fatalf("v2 API is deprecated: got '%s'", v)
+	}
+	if v := resp.Header.Get(headers.Link); v != "" {
+		t.Fatalf("v2 API has a successor: got '%s'", v)
+	}
+
+	if resp = get(api.PathKeyList + "*"); resp.Header.Get(headers.Deprecation) != "" {
+		t.Fatalf("API '%s' is deprecated", api.PathKeyList)
+	}
+}
+
+func testStandby(t *testing.T) {
+	t.Parallel()
+
+	otherKey, err := kes.GenerateAPIKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate API key: %v", err)
+	}
+	otherCert, err := kes.GenerateCertificate(otherKey)
+	if err != nil {
+		t.Fatalf("Failed to generate client certificate: %v", err)
+	}
+
+	ctx := testContext(t)
+	primary, primaryURL := startServer(ctx, &Config{
+		Policies: map[string]Policy{
+			"sync": {
+				Allow:      map[string]kes.Rule{api.PathCacheSync: {}},
+				Identities: []kes.Identity{otherKey.Identity()},
+			},
+		},
+	})
+	defer primary.Close()
+
+	client := defaultClient(primaryURL)
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := client.CreateKey(ctx, "deleted-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	// Only the admin and standby identities can sync the cache,
+	// even if the policy of an identity allows the API.
+	syncReq, err := http.NewRequestWithContext(ctx, http.MethodGet, primaryURL+api.PathCacheSync, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	syncResp, err := renewalClient(primaryURL, otherCert).HTTPClient.Do(syncReq)
+	if err != nil {
+		t.Fatalf("Failed to sync cache: %v", err)
+	}
+	syncResp.Body.Close()
+	if syncResp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Identity without standby permission synced cache: got status '%d' - want '%d'", syncResp.StatusCode, http.StatusForbidden)
+	}
+	ciphertext, err := client.Encrypt(ctx, "my-key", []byte("Hello World"), nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	adminKey, err := kes.ParseAPIKey(defaultAPIKey)
+	if err != nil {
+		t.Fatalf("Failed to parse API key: %v", err)
+	}
+	clientCert, err := kes.GenerateCertificate(adminKey)
+	if err != nil {
+		t.Fatalf("Failed to generate client certificate: %v", err)
+	}
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(defaultServerCertificate().Leaf)
+
+	standby, standbyURL := startServer(ctx, &Config{
+		Standby: &StandbyConfig{
+			Primary:  primaryURL,
+			Interval: 100 * time.Millisecond,
+			TLS: &tls.Config{
+				RootCAs:      rootCAs,
+				Certificates: []tls.Certificate{clientCert},
+			},
+		},
+	})
+	defer standby.Close()
+
+	client = defaultClient(standbyURL)
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, standbyURL+api.PathStatus, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to fetch standby status: %v", err)
+		}
+		var status api.StatusResponse
+		err = json.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("Failed to decode standby status: %v", err)
+		}
+		if !status.Standby {
+			t.Fatal("Server should be a standby")
+		}
+		if !status.StandbySyncedAt.IsZero() {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("Standby did not synchronize with primary")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	if _, err := client.Decrypt(ctx, "my-key", ciphertext, nil); err == nil {
+		t.Fatal("Standby should not serve API requests before being promoted")
+	}
+
+	if err := defaultClient(primaryURL).DeleteKey(ctx, "deleted-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if err := standby.syncStandby(ctx, standby.state.Load().Standby); err != nil {
+		t.Fatalf("Failed to sync with primary: %v", err)
+	}
+	if _, ok := standby.state.Load().Keys.Snapshot()["deleted-key"]; ok {
+		t.Fatal("Standby still caches key deleted at the primary")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, standbyURL+api.PathStandbyPromote, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to promote standby: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to promote standby: got status '%d'", resp.StatusCode)
+	}
+
+	plaintext, err := client.Decrypt(ctx, "my-key", ciphertext, nil)
+	if err != nil {
+		t.Fatalf("Failed to decrypt with promoted standby: %v", err)
+	}
+	if string(plaintext) != "Hello World" {
+		t.Fatalf("Invalid plaintext: got '%s' - want 'Hello World'", plaintext)
+	}
+}
+
+func testCacheKey(t *testing.T) {
+	t.Parallel()
+
+	proxyKey, err := kes.GenerateAPIKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate API key: %v", err)
+	}
+	proxyCert, err := kes.GenerateCertificate(proxyKey)
+	if err != nil {
+		t.Fatalf("Failed to generate client certificate: %v", err)
+	}
+	otherKey, err := kes.GenerateAPIKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate API key: %v", err)
+	}
+	otherCert, err := kes.GenerateCertificate(otherKey)
+	if err != nil {
+		t.Fatalf("Failed to generate client certificate: %v", err)
+	}
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		Policies: map[string]Policy{
+			"proxy": {
+				Allow:      map[string]kes.Rule{api.PathCacheKey + "*": {}},
+				Identities: []kes.Identity{proxyKey.Identity(), otherKey.Identity()},
+			},
+		},
+		StandbyIdentities: []kes.Identity{proxyKey.Identity()},
+	})
+	defer srv.Close()
+
+	if err := defaultClient(url).CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	fetch := func(cert tls.Certificate, name string) (api.SyncKey, int) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+api.PathCacheKey+name, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := renewalClient(url, cert).HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to fetch key: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var key api.SyncKey
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&key); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return key, resp.StatusCode
+	}
+
+	key, code := fetch(proxyCert, "my-key")
+	if code != http.StatusOK {
+		t.Fatalf("Failed to fetch key: got status '%d' - want '%d'", code, http.StatusOK)
+	}
+	if key.Name != "my-key" {
+		t.Fatalf("Invalid key name: got '%s' - want '%s'", key.Name, "my-key")
+	}
+	want, err := srv.state.Load().Keys.Get(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to get key: %v", err)
+	}
+	got, err := crypto.ParseKeyVersion([]byte(key.Key))
+	if err != nil {
+		t.Fatalf("Failed to parse key: %v", err)
+	}
+	if !bytes.Equal(got.Key.Bytes(), want.Key.Bytes()) {
+		t.Fatal("Fetched key does not match the server key")
+	}
+
+	if _, code = fetch(proxyCert, "missing-key"); code != http.StatusNotFound {
+		t.Fatalf("Fetched non-existing key: got status '%d' - want '%d'", code, http.StatusNotFound)
+	}
+
+	// Only the admin and standby identities can fetch keys,
+	// even if the policy of an identity allows the API.
+	if _, code = fetch(otherCert, "my-key"); code != http.StatusForbidden {
+		t.Fatalf("Identity without standby permission fetched key: got status '%d' - want '%d'", code, http.StatusForbidden)
+	}
+}
+
+func testLocks(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, nil)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	send := func(method, path string, body any, v any) int {
+		var r io.Reader
+		if body != nil {
+			b, err := json.Marshal(body)
+			if err != nil {
+				t.Fatalf("Failed to encode request: %v", err)
+			}
+			r = bytes.NewReader(b)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url+path, r)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if v != nil && resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return resp.StatusCode
 	}
 
-	t.Parallel()
-	ctx := testContext(t)
-	srv, url := startServer(ctx, nil)
-	defer srv.Close()
+	var lock api.LockResponse
+	if code := send(http.MethodPut, api.PathLockAcquire+"my-lock", api.AcquireLockRequest{TTL: "1m"}, &lock); code != http.StatusOK {
+		t.Fatalf("Failed to acquire lock: got status '%d'", code)
+	}
+	if lock.Token == "" {
+		t.Fatal("Acquiring a lock returned no token")
+	}
+	if code := send(http.MethodPut, api.PathLockAcquire+"my-lock", api.AcquireLockRequest{}, nil); code != http.StatusConflict {
+		t.Fatalf("Acquiring a held lock should fail with '%d' - got '%d'", http.StatusConflict, code)
+	}
+	if code := send(http.MethodPut, api.PathLockAcquire+"my-lock", api.AcquireLockRequest{TTL: "1ms"}, nil); code != http.StatusBadRequest {
+		t.Fatalf("Acquiring a lock with invalid TTL should fail with '%d' - got '%d'", http.StatusBadRequest, code)
+	}
 
-	client := defaultClient(url)
-	routes, err := client.APIs(ctx)
+	var info api.LockResponse
+	if code := send(http.MethodGet, api.PathLockDescribe+"my-lock", nil, &info); code != http.StatusOK {
+		t.Fatalf("Failed to describe lock: got status '%d'", code)
+	}
+	if info.Token != "" {
+		t.Fatal("Describing a lock must not reveal its token")
+	}
+	if info.Holder != defaultIdentity {
+		t.Fatalf("Invalid lock holder: got '%s' - want '%s'", info.Holder, defaultIdentity)
+	}
+
+	if code := send(http.MethodPut, api.PathLockRenew+"my-lock", api.RenewLockRequest{Token: "invalid"}, nil); code != http.StatusConflict {
+		t.Fatalf("Renewing a lock with invalid token should fail with '%d' - got '%d'", http.StatusConflict, code)
+	}
+	var renewed api.LockResponse
+	if code := send(http.MethodPut, api.PathLockRenew+"my-lock", api.RenewLockRequest{Token: lock.Token, TTL: "5m"}, &renewed); code != http.StatusOK {
+		t.Fatalf("Failed to renew lock: got status '%d'", code)
+	}
+	if !renewed.ExpiresAt.After(lock.ExpiresAt) {
+		t.Fatalf("Renewed lock expires at '%v' - before '%v'", renewed.ExpiresAt, lock.ExpiresAt)
+	}
+
+	names, _, err := client.ListKeys(ctx, "", -1)
 	if err != nil {
-		t.Fatalf("Failed fetch server APIs: %v", err)
+		t.Fatalf("Failed to list keys: %v", err)
 	}
-	if len(routes) != len(defaults) {
-		t.Fatalf("Routes mismatch: got len '%d' - want len '%d'", len(routes), len(defaults))
+	if len(names) != 0 {
+		t.Fatalf("Locks must not be listed as keys: got '%v'", names)
 	}
-	for i := range routes {
-		api, ok := defaults[routes[i].Path]
-		if !ok {
-			t.Fatalf("Route '%s': not found", routes[i].Path)
-		}
-		if routes[i].Method != api.Method {
-			t.Fatalf("Route '%s': method mismatch: got '%s' - want '%s'", routes[i].Path, routes[i].Method, api.Method)
-		}
-		if routes[i].MaxBody != int64(api.MaxBody) {
-			t.Fatalf("Route '%s': max body mismatch: got '%d' - want '%d'", routes[i].Path, routes[i].MaxBody, api.MaxBody)
-		}
-		if routes[i].Timeout != api.Timeout {
-			t.Fatalf("Route '%s': timeout mismatch: got '%v' - want '%v'", routes[i].Path, routes[i].Timeout, api.Timeout)
-		}
+
+	if code := send(http.MethodPut, api.PathLockRelease+"my-lock", api.ReleaseLockRequest{Token: lock.Token}, nil); code != http.StatusOK {
+		t.Fatalf("Failed to release lock: got status '%d'", code)
+	}
+	if code := send(http.MethodGet, api.PathLockDescribe+"my-lock", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("Describing a released lock should fail with '%d' - got '%d'", http.StatusNotFound, code)
+	}
+	if code := send(http.MethodPut, api.PathLockAcquire+"my-lock", api.AcquireLockRequest{}, nil); code != http.StatusOK {
+		t.Fatalf("Failed to acquire released lock: got status '%d'", code)
+	}
+
+	// Locks require a key store that supports conditional writes.
+	srv2, url2 := startServer(ctx, &Config{Keys: nonBulkKeyStore{&MemKeyStore{}}})
+	defer srv2.Close()
+
+	url = url2
+	if code := send(http.MethodPut, api.PathLockAcquire+"my-lock", api.AcquireLockRequest{}, nil); code != http.StatusNotImplemented {
+		t.Fatalf("Acquiring a lock without conditional writes should fail with '%d' - got '%d'", http.StatusNotImplemented, code)
 	}
 }
 
@@ -204,6 +1116,53 @@ func testCreateKey(t *testing.T) {
 	}
 }
 
+func testIdempotentCreateKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, nil)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	send := func(method, path, key string) *http.Response {
+		req, err := http.NewRequestWithContext(ctx, method, url+path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		if key != "" {
+			req.Header.Set(headers.IdempotencyKey, key)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	for i := 0; i < 3; i++ {
+		resp := send(http.MethodPut, api.PathKeyCreate+"my-key", "create-1")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Request %d: failed to create key: got status '%d'", i, resp.StatusCode)
+		}
+		if replayed := resp.Header.Get(headers.IdempotentReplayed) == "true"; replayed != (i > 0) {
+			t.Fatalf("Request %d: got 'replayed=%v' - want 'replayed=%v'", i, replayed, i > 0)
+		}
+	}
+	if resp := send(http.MethodPut, api.PathKeyCreate+"my-key", "create-2"); resp.StatusCode == http.StatusOK {
+		t.Fatal("Creating an existing key with a new idempotency key should have failed")
+	}
+	if resp := send(http.MethodPut, api.PathKeyCreate+"my-key", ""); resp.StatusCode == http.StatusOK {
+		t.Fatal("Creating an existing key without an idempotency key should have failed")
+	}
+
+	for i := 0; i < 2; i++ {
+		if resp := send(http.MethodDelete, api.PathKeyDelete+"my-key", "delete-1"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("Request %d: failed to delete key: got status '%d'", i, resp.StatusCode)
+		}
+	}
+}
+
 func testDeleteKey(t *testing.T) {
 	t.Parallel()
 
@@ -430,6 +1389,137 @@ func testEncryptDecryptKey(t *testing.T) {
 	}
 }
 
+func testEnvelope(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, nil)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	send := func(path string, body, v any) int {
+		b, _ := json.Marshal(body)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, url+path, bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request to '%s': %v", path, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatalf("Failed to decode response of '%s': %v", path, err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	plaintext := make([]byte, mem.KB)
+	associatedData := []byte("bucket=my-bucket")
+
+	var env api.Envelope
+	if code := send(api.PathKeySeal+"my-key", api.SealEnvelopeRequest{Plaintext: plaintext, Context: associatedData}, &env); code != http.StatusOK {
+		t.Fatalf("Failed to seal envelope: got status '%d'", code)
+	}
+	if env.Key != "my-key" || env.KeyVersion.IsZero() || env.Version != 1 {
+		t.Fatalf("Envelope is not self-describing: got key '%s' - version '%v'", env.Key, env.KeyVersion)
+	}
+
+	var opened api.OpenEnvelopeResponse
+	if code := send(api.PathKeyOpen+"my-key", api.OpenEnvelopeRequest{Envelope: env, Context: associatedData}, &opened); code != http.StatusOK {
+		t.Fatalf("Failed to open envelope: got status '%d'", code)
+	}
+	if !bytes.Equal(opened.Plaintext, plaintext) {
+		t.Fatalf("Plaintext mismatch: got %v - want %v", opened.Plaintext, plaintext)
+	}
+
+	if code := send(api.PathKeyOpen+"my-key", api.OpenEnvelopeRequest{Envelope: env}, &opened); code == http.StatusOK {
+		t.Fatal("Opening an envelope with a different context should fail")
+	}
+	if err := client.CreateKey(ctx, "my-key-2"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if code := send(api.PathKeyOpen+"my-key-2", api.OpenEnvelopeRequest{Envelope: env, Context: associatedData}, &opened); code != http.StatusBadRequest {
+		t.Fatalf("Opening an envelope with another key should fail with '%d' - got '%d'", http.StatusBadRequest, code)
+	}
+
+	env.Ciphertext[0] ^= 1
+	if code := send(api.PathKeyOpen+"my-key", api.OpenEnvelopeRequest{Envelope: env, Context: associatedData}, &opened); code == http.StatusOK {
+		t.Fatal("Opening a modified envelope should fail")
+	}
+}
+
+func testInspectCiphertext(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, nil)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	send := func(path string, body, v any) int {
+		b, _ := json.Marshal(body)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, url+path, bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request to '%s': %v", path, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatalf("Failed to decode response of '%s': %v", path, err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	var env api.Envelope
+	if code := send(api.PathKeySeal+"my-key", api.SealEnvelopeRequest{Plaintext: []byte("Hello World")}, &env); code != http.StatusOK {
+		t.Fatalf("Failed to seal envelope: got status '%d'", code)
+	}
+	envelope, _ := json.Marshal(env)
+	ciphertext, err := client.Encrypt(ctx, "my-key", []byte("Hello World"), nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt plaintext: %v", err)
+	}
+
+	var inspected api.InspectCiphertextResponse
+	request := api.InspectCiphertextRequest{Ciphertexts: [][]byte{envelope, ciphertext, []byte("malformed")}}
+	if code := send(api.PathCiphertextInspect, request, &inspected); code != http.StatusOK {
+		t.Fatalf("Failed to inspect ciphertexts: got status '%d'", code)
+	}
+	if n := len(inspected.Ciphertexts); n != 3 {
+		t.Fatalf("Invalid number of inspected ciphertexts: got '%d' - want '%d'", n, 3)
+	}
+	if info := inspected.Ciphertexts[0]; info.Format != "envelope" || info.Key != "my-key" || !info.KeyVersion.Equal(env.KeyVersion) || info.Algorithm != env.Algorithm {
+		t.Fatalf("Invalid envelope info: got '%+v'", info)
+	}
+	if info := inspected.Ciphertexts[1]; info.Format != "raw" || info.Key != "" || info.Error != "" {
+		t.Fatalf("Invalid ciphertext info: got '%+v'", info)
+	}
+	if info := inspected.Ciphertexts[2]; info.Format != "" || info.Error == "" {
+		t.Fatalf("Malformed ciphertext should not be inspected: got '%+v'", info)
+	}
+
+	request.Ciphertexts = make([][]byte, 1001)
+	if code := send(api.PathCiphertextInspect, request, &inspected); code != http.StatusBadRequest {
+		t.Fatalf("Inspecting too many ciphertexts should fail with '%d' - got '%d'", http.StatusBadRequest, code)
+	}
+}
+
 func testListKeys(t *testing.T) {
 	t.Parallel()
 
@@ -462,6 +1552,275 @@ func testListKeys(t *testing.T) {
 	}
 }
 
+func testListKeysFilter(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, nil)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	for _, name := range []string{"my-key-1", "my-key-2", "other-key-1"} {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+
+	for i, test := range listKeysFilterTests {
+		resp, err := client.HTTPClient.Get(url + api.PathKeyList + test.Query)
+		if err != nil {
+			t.Fatalf("Test %d: failed to list keys: %v", i, err)
+		}
+		var response api.ListKeysResponse
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		resp.Body.Close()
+
+		if test.ShouldFail {
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Test %d: got status '%d' - want '%d'", i, resp.StatusCode, http.StatusBadRequest)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: failed to decode response: %v", i, err)
+		}
+		if !slices.Equal(response.Names, test.Names) {
+			t.Errorf("Test %d: got %v - want %v", i, response.Names, test.Names)
+		}
+	}
+}
+
+var listKeysFilterTests = []struct {
+	Query      string
+	Names      []string
+	ShouldFail bool
+}{
+	{Query: "*", Names: []string{"my-key-1", "my-key-2", "other-key-1"}},                                  // 0
+	{Query: "my*", Names: []string{"my-key-1", "my-key-2"}},                                               // 1
+	{Query: "*?pattern=*-1", Names: []string{"my-key-1", "other-key-1"}},                                  // 2
+	{Query: "my*?pattern=*-1", Names: []string{"my-key-1"}},                                               // 3
+	{Query: "*?created_before=2000-01-01T00:00:00Z", Names: []string{}},                                   // 4
+	{Query: "*?created_after=2000-01-01T00:00:00Z&pattern=my-*", Names: []string{"my-key-1", "my-key-2"}}, // 5
+	{Query: "*?pattern=%5B", ShouldFail: true},                                                            // 6
+	{Query: "*?created_after=yesterday", ShouldFail: true},                                                // 7
+	{Query: "*?algorithm=DES", ShouldFail: true},                                                          // 8
+}
+
+func testListKeysPage(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, nil)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	for _, name := range []string{"key-1", "key-2", "key-3", "key-4", "key-5"} {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+
+	list := func(query string) (api.ListKeysResponse, int) {
+		resp, err := client.HTTPClient.Get(url + api.PathKeyList + "*?" + query)
+		if err != nil {
+			t.Fatalf("Failed to list keys: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var response api.ListKeysResponse
+		if resp.StatusCode == http.StatusOK {
+			if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return response, resp.StatusCode
+	}
+
+	var (
+		names  []string
+		cursor string
+	)
+	for i := 0; ; i++ {
+		page, status := list("limit=2&cursor=" + cursor)
+		if status != http.StatusOK {
+			t.Fatalf("Failed to list keys: got status '%d'", status)
+		}
+		names = append(names, page.Names...)
+
+		if i == 0 { // Modify the listing concurrently
+			if err := client.DeleteKey(ctx, "key-3"); err != nil {
+				t.Fatalf("Failed to delete key: %v", err)
+			}
+			if err := client.CreateKey(ctx, "key-0"); err != nil {
+				t.Fatalf("Failed to create key: %v", err)
+			}
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if want := []string{"key-1", "key-2", "key-4", "key-5"}; !slices.Equal(names, want) {
+		t.Fatalf("Pagination mismatch: got %v - want %v", names, want)
+	}
+
+	for i, query := range []string{"limit=0", "limit=-1", "cursor=invalid", "cursor=a2V5LTE"} {
+		if _, status := list(query); status != http.StatusBadRequest {
+			t.Errorf("Test %d: got status '%d' - want '%d'", i, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func testJobs(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	migration, backup := &MemKeyStore{}, &MemKeyStore{}
+	if err := migration.Create(ctx, "key-1", []byte("existing")); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	srv, url := startServer(ctx, &Config{
+		Jobs: &JobConfig{
+			MigrationTarget: migration,
+			BackupTarget:    backup,
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	for _, name := range []string{"key-1", "key-2", "key-3"} {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+
+	send := func(method, path string, v any) int {
+		req, err := http.NewRequestWithContext(ctx, method, url+path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if v != nil && resp.StatusCode < 300 {
+			if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	run := func(kind string) api.JobResponse {
+		var job api.JobResponse
+		if status := send(http.MethodPut, api.PathJobStart+kind, &job); status != http.StatusAccepted {
+			t.Fatalf("Failed to start job '%s': got status '%d'", kind, status)
+		}
+		for job.State == "running" {
+			time.Sleep(10 * time.Millisecond)
+			if status := send(http.MethodGet, api.PathJobDescribe+job.ID, &job); status != http.StatusOK {
+				t.Fatalf("Failed to describe job: got status '%d'", status)
+			}
+		}
+		return job
+	}
+
+	for _, kind := range []string{"scrub", "rewrap", "backup"} {
+		if job := run(kind); job.State != "succeeded" || job.Done != 3 || job.Total != 3 || len(job.Errors) != 0 {
+			t.Fatalf("Job '%s' did not succeed: %+v", kind, job)
+		}
+	}
+	if job := run("migrate"); job.State != "succeeded" || job.Done != 3 || len(job.Errors) != 1 {
+		t.Fatalf("Job 'migrate' should report the existing key: %+v", job)
+	}
+	for _, name := range []string{"key-1", "key-2", "key-3"} {
+		if _, err := backup.Get(ctx, name); err != nil {
+			t.Fatalf("Key '%s' has not been backed up: %v", name, err)
+		}
+		if _, err := migration.Get(ctx, name); err != nil {
+			t.Fatalf("Key '%s' has not been migrated: %v", name, err)
+		}
+	}
+	if v, _ := migration.Get(ctx, "key-1"); string(v) != "existing" {
+		t.Fatalf("Migration overwrote existing key: got '%s'", v)
+	}
+
+	var list api.ListJobsResponse
+	if status := send(http.MethodGet, api.PathJobList, &list); status != http.StatusOK || len(list.Jobs) != 4 {
+		t.Fatalf("Failed to list jobs: got status '%d' and %d jobs", status, len(list.Jobs))
+	}
+	if status := send(http.MethodPut, api.PathJobStart+"unknown", nil); status != http.StatusBadRequest {
+		t.Fatalf("Starting unknown job should have failed: got status '%d'", status)
+	}
+	if status := send(http.MethodDelete, api.PathJobCancel+"unknown", nil); status != http.StatusNotFound {
+		t.Fatalf("Canceling unknown job should have failed: got status '%d'", status)
+	}
+}
+
+func testKeyInventory(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, nil)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	for _, name := range []string{"my-key-2", "my-key-1"} {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	if _, err := client.Encrypt(ctx, "my-key-1", []byte("Hello World"), nil); err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	inventory := func(format string) *http.Response {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+api.PathKeyInventory+"?format="+format, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to fetch key inventory: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Failed to fetch key inventory: got status '%d'", resp.StatusCode)
+		}
+		return resp
+	}
+
+	resp := inventory("json")
+	var report api.KeyInventoryResponse
+	err := json.NewDecoder(resp.Body).Decode(&report)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to decode key inventory: %v", err)
+	}
+	if len(report.Keys) != 2 {
+		t.Fatalf("Invalid key inventory: got '%d' keys - want '2'", len(report.Keys))
+	}
+	if key := report.Keys[0]; key.Name != "my-key-1" || key.LastUsed.IsZero() || key.Owner != defaultIdentity || key.Algorithm == "" {
+		t.Fatalf("Invalid key inventory entry: got '%+v'", key)
+	}
+	if key := report.Keys[1]; key.Name != "my-key-2" || !key.LastUsed.IsZero() {
+		t.Fatalf("Invalid key inventory entry: got '%+v'", key)
+	}
+
+	resp = inventory("csv")
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to decode key inventory: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Invalid key inventory: got '%d' CSV records - want '3'", len(records))
+	}
+	if records[0][0] != "name" || records[1][0] != "my-key-1" || records[2][0] != "my-key-2" {
+		t.Fatalf("Invalid key inventory: got '%v'", records)
+	}
+}
+
 func testDescribeIdentity(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,143 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kes/internal/tpm"
+)
+
+// Min. and max. length of attestation nonces.
+const (
+	minAttestationNonce = 8
+	maxAttestationNonce = 64
+)
+
+// measureBinary returns the SHA-256 hash of the running
+// server binary. It is computed only once per process.
+var measureBinary = sync.OnceValues(func() ([]byte, error) {
+	filename, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+})
+
+// attestation creates TPM quotes over the server's measurement.
+type attestation struct {
+	device string
+	pcrs   []int
+	binary []byte
+	config []byte
+}
+
+// newAttestation returns a new attestation for the given
+// configuration. It returns nil if conf is nil.
+func newAttestation(conf *AttestationConfig) (*attestation, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	pcrs := conf.PCRs
+	if len(pcrs) == 0 {
+		pcrs = DefaultAttestationPCRs
+	}
+	for _, pcr := range pcrs {
+		if pcr < 0 || pcr > tpm.MaxPCR {
+			return nil, fmt.Errorf("kes: invalid attestation config: invalid PCR %d", pcr)
+		}
+	}
+	if len(conf.ConfigDigest) != 0 && len(conf.ConfigDigest) != sha256.Size {
+		return nil, errors.New("kes: invalid attestation config: config digest is not a SHA-256 hash")
+	}
+
+	binary, err := measureBinary()
+	if err != nil {
+		return nil, fmt.Errorf("kes: failed to measure server binary: %v", err)
+	}
+	return &attestation{
+		device: conf.Device,
+		pcrs:   slices.Clone(pcrs),
+		binary: binary,
+		config: slices.Clone(conf.ConfigDigest),
+	}, nil
+}
+
+// attest returns a TPM quote over the server's binary and config
+// measurement and the nonce specified by the "nonce" query parameter.
+func (s *Server) attest(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	if state.Attestation == nil {
+		resp.Fail(http.StatusNotImplemented, "attestation is not enabled")
+		return
+	}
+
+	nonce, err := hex.DecodeString(req.URL.Query().Get("nonce"))
+	if err != nil || len(nonce) < minAttestationNonce || len(nonce) > maxAttestationNonce {
+		resp.Failf(http.StatusBadRequest, "invalid nonce: expected %d to %d hex-encoded bytes", minAttestationNonce, maxAttestationNonce)
+		return
+	}
+
+	att := state.Attestation
+	device, err := tpm.Open(att.device)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to open TPM")
+		return
+	}
+	defer device.Close()
+
+	data := &crypto.Attestation{
+		Nonce:  nonce,
+		Binary: att.binary,
+		Config: att.config,
+	}
+	quote, err := device.Quote(att.pcrs, data.QualifyingData())
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to create TPM quote")
+		return
+	}
+
+	var report []byte
+	if state.Sealing != nil {
+		report, err = state.Sealing.sealer.Report(req.Context(), data.QualifyingData())
+		if err != nil {
+			state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+			resp.Fail(http.StatusBadGateway, "failed to create attestation report")
+			return
+		}
+	}
+
+	api.ReplyWith(resp, http.StatusOK, api.AttestationResponse{
+		Binary:    att.binary,
+		Config:    att.config,
+		PCRs:      quote.PCRs,
+		Quote:     quote.Attest,
+		Signature: quote.Signature,
+		PublicKey: quote.PublicKey,
+		Report:    report,
+	})
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kes/internal/tpm"
+	"github.com/minio/kes/internal/tpm/tpmtest"
+)
+
+func TestAttestation(t *testing.T) {
+	t.Parallel()
+
+	sim, err := tpmtest.NewSimulator()
+	if err != nil {
+		t.Fatalf("Failed to create TPM simulator: %v", err)
+	}
+	sim.Extend(7, []byte("secure boot policy"))
+
+	socket := filepath.Join(t.TempDir(), "tpm.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to listen on unix socket: %v", err)
+	}
+	defer ln.Close()
+	go sim.Serve(ln)
+
+	ctx := testContext(t)
+	configDigest := sha256.Sum256([]byte("config"))
+	srv, url := startServer(ctx, &Config{
+		Attestation: &AttestationConfig{
+			Device:       socket,
+			PCRs:         []int{0, 7},
+			ConfigDigest: configDigest[:],
+		},
+		Sealing: &SealingConfig{Sealer: newFakeSealer("vm-0")},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	attest := func(nonce string) (api.AttestationResponse, int) {
+		resp := sendRequest(ctx, t, &client.HTTPClient, http.MethodGet, url+api.PathAttestation+"?nonce="+nonce, nil, nil)
+		defer resp.Body.Close()
+
+		var attestation api.AttestationResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&attestation); err != nil {
+				t.Fatalf("Failed to decode attestation response: %v", err)
+			}
+		}
+		return attestation, resp.StatusCode
+	}
+
+	for _, nonce := range []string{"", "not-hex", "0011"} {
+		if _, status := attest(nonce); status != http.StatusBadRequest {
+			t.Fatalf("Accepted invalid nonce '%s': got status '%d' - want '%d'", nonce, status, http.StatusBadRequest)
+		}
+	}
+
+	nonce := bytes.Repeat([]byte{0x42}, 32)
+	resp, status := attest(hex.EncodeToString(nonce))
+	if status != http.StatusOK {
+		t.Fatalf("Failed to get attestation: status '%d'", status)
+	}
+	if !bytes.Equal(resp.Config, configDigest[:]) {
+		t.Fatalf("Invalid config digest: got '%x' - want '%x'", resp.Config, configDigest)
+	}
+	binary, _ := measureBinary()
+	if !bytes.Equal(resp.Binary, binary) {
+		t.Fatalf("Invalid binary digest: got '%x' - want '%x'", resp.Binary, binary)
+	}
+
+	quote := &tpm.Quote{
+		Attest:    resp.Quote,
+		Signature: resp.Signature,
+		PublicKey: resp.PublicKey,
+		PCRs:      resp.PCRs,
+	}
+	data := &crypto.Attestation{Nonce: nonce, Binary: resp.Binary, Config: resp.Config}
+	pcrs, err := tpm.Verify(quote, data.QualifyingData())
+	if err != nil {
+		t.Fatalf("Failed to verify quote: %v", err)
+	}
+	if !slices.Equal(pcrs, []int{0, 7}) {
+		t.Fatalf("Invalid quoted PCRs: got '%v' - want '%v'", pcrs, []int{0, 7})
+	}
+
+	if report := append([]byte("vm-0:"), data.QualifyingData()...); !bytes.Equal(resp.Report, report) {
+		t.Fatalf("Invalid attestation report: got '%x' - want '%x'", resp.Report, report)
+	}
+
+	// A quote for another nonce must not verify.
+	data.Nonce = bytes.Repeat([]byte{0x43}, 32)
+	if _, err = tpm.Verify(quote, data.QualifyingData()); err == nil {
+		t.Fatal("Verified quote for another nonce")
+	}
+}
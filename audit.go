@@ -5,10 +5,12 @@
 package kes
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"log/slog"
 	"net/netip"
+	"sync/atomic"
 	"time"
 
 	"github.com/minio/kes/internal/api"
@@ -127,6 +129,9 @@ type auditLogger struct {
 	level slog.Leveler
 
 	out *api.Multicast // clients subscribed to the AuditLog API
+
+	pseudonyms atomic.Pointer[pseudonymizer] // nil if pseudonymization is disabled
+	alerts     atomic.Pointer[alertEngine]   // nil if alerting is disabled
 }
 
 // newAuditLogger returns a new auditLogger passing AuditRecords to h.
@@ -140,9 +145,29 @@ func newAuditLogger(h AuditHandler, level slog.Leveler) *auditLogger {
 	}
 }
 
+// pseudonymize enables audit record pseudonymization if conf is
+// not nil and disables it otherwise. It keeps the pseudonyms seen
+// so far if the pseudonymization key does not change.
+func (a *auditLogger) pseudonymize(ctx context.Context, conf *AuditPseudonymizationConfig, store KeyStore) error {
+	if conf == nil {
+		a.pseudonyms.Store(nil)
+		return nil
+	}
+	p, err := newPseudonymizer(ctx, conf, store)
+	if err != nil {
+		return err
+	}
+	if old := a.pseudonyms.Load(); old == nil || !bytes.Equal(old.key, p.key) {
+		a.pseudonyms.Store(p)
+	}
+	return nil
+}
+
 // Log emits an audit record with the current time, log message,
 // response status code and request information.
 func (a *auditLogger) Log(msg string, statusCode int, req *api.Request) {
+	a.alerts.Load().Audit(req.URL.Path, statusCode)
+
 	const Level = slog.LevelInfo
 	if Level < a.level.Level() {
 		return
@@ -166,6 +191,9 @@ func (a *auditLogger) Log(msg string, statusCode int, req *api.Request) {
 		Level:        Level,
 		Message:      msg,
 	}
+	if p := a.pseudonyms.Load(); p != nil {
+		r = p.Record(r, req.Resource)
+	}
 	if hEnabled {
 		a.h.Handle(req.Context(), r)
 	}
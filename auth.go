@@ -11,7 +11,10 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync/atomic"
+	"time"
+	"unicode"
 
 	"github.com/minio/kes/internal/api"
 	"github.com/minio/kms-go/kes"
@@ -34,12 +37,37 @@ type verifyIdentity atomic.Pointer[serverState]
 // Otherwise, it returns an error.
 func (v *verifyIdentity) Authenticate(req *http.Request) (*api.Request, api.Error) {
 	s := (*atomic.Pointer[serverState])(v).Load()
-	identity, err := identifyRequest(req.TLS)
+	if err := s.checkBanned(req); err != nil {
+		return nil, err
+	}
+	identity, err := s.Auth.Identify(req, s.Admin)
 	if err != nil {
 		s.Log.DebugContext(req.Context(), err.Error(), "req", req)
-		return nil, err
+		return nil, s.authFailed(req, authFailureInvalidIdentity, err)
+	}
+	if s.Standby.IsActive() {
+		switch req.URL.Path {
+		case api.PathStatus, api.PathBackend, api.PathMetrics, api.PathStandbyPromote:
+		default:
+			return nil, api.NewError(http.StatusServiceUnavailable, "server is a standby")
+		}
+	}
+	identity = s.Aliases.Resolve(identity) // Renewed certificates keep the identity of the original one
+	if s.WebAuthn.IsAdmin(identity) {
+		identity = s.Admin // Clients with a WebAuthn session act as admin
 	}
 	if identity == s.Admin {
+		if err := s.Cosigning.Verify(req, identity); err != nil {
+			s.Log.DebugContext(req.Context(), "access denied: "+err.Error(), "req", req)
+			return nil, err
+		}
+		if err := s.Lifecycle.Check(req, time.Now()); err != nil {
+			s.Log.DebugContext(req.Context(), "access denied: "+err.Error(), "req", req)
+			return nil, err
+		}
+		s.Usage.SeeIdentity(identity)
+		s.Anomalies.Record(identity, requestedKey(req), false)
+		s.Alerts.Record(false)
 		return &api.Request{
 			Request:  req,
 			Identity: identity,
@@ -49,13 +77,67 @@ func (v *verifyIdentity) Authenticate(req *http.Request) (*api.Request, api.Erro
 	policy, ok := s.Identities[identity]
 	if !ok {
 		s.Log.DebugContext(req.Context(), "access denied: identity not found", "req", req)
-		return nil, kes.ErrNotAllowed
+		return nil, s.authFailed(req, authFailureUnknownIdentity, kes.ErrNotAllowed)
 	}
-	if err := policy.Verify(req); err != nil {
+	if err := policy.Verify(req); err != nil && !s.Folders.AllowsRequest(req, s.Keys, policy.Name, policy.Policy) {
 		s.Log.DebugContext(req.Context(), fmt.Sprintf("access denied: rejected by policy '%s'", policy.Name), "req", req)
+		s.Anomalies.Record(identity, requestedKey(req), true)
+		s.Alerts.Record(true)
+		return nil, kes.ErrNotAllowed
+	}
+	if !s.GeoFence.Allows(policy.Name, req) {
+		s.Log.DebugContext(req.Context(), fmt.Sprintf("access denied: client location rejected by policy '%s'", policy.Name), "req", req)
+		s.Anomalies.Record(identity, requestedKey(req), true)
+		s.Alerts.Record(true)
 		return nil, kes.ErrNotAllowed
 	}
+	if err := s.Cosigning.Verify(req, identity); err != nil {
+		s.Log.DebugContext(req.Context(), "access denied: "+err.Error(), "req", req)
+		return nil, err
+	}
+	if err := s.Lifecycle.Check(req, time.Now()); err != nil {
+		s.Log.DebugContext(req.Context(), "access denied: "+err.Error(), "req", req)
+		return nil, err
+	}
 
+	s.Usage.SeeIdentity(identity)
+	s.Anomalies.Record(identity, requestedKey(req), false)
+	s.Alerts.Record(false)
+	return &api.Request{
+		Request:  req,
+		Identity: identity,
+	}, nil
+}
+
+// verifyAssignedIdentity authenticates client requests of the admin
+// or any identity with an assigned policy. Unlike verifyIdentity, it
+// does not evaluate the policy. It is used for self-service APIs that
+// only operate on the client's own identity.
+type verifyAssignedIdentity atomic.Pointer[serverState]
+
+func (v *verifyAssignedIdentity) Authenticate(req *http.Request) (*api.Request, api.Error) {
+	s := (*atomic.Pointer[serverState])(v).Load()
+	if err := s.checkBanned(req); err != nil {
+		return nil, err
+	}
+	identity, err := s.Auth.Identify(req, s.Admin)
+	if err != nil {
+		s.Log.DebugContext(req.Context(), err.Error(), "req", req)
+		return nil, s.authFailed(req, authFailureInvalidIdentity, err)
+	}
+	if s.Standby.IsActive() {
+		return nil, api.NewError(http.StatusServiceUnavailable, "server is a standby")
+	}
+
+	identity = s.Aliases.Resolve(identity)
+	if s.WebAuthn.IsAdmin(identity) {
+		identity = s.Admin
+	}
+	if _, ok := s.Identities[identity]; !ok && identity != s.Admin {
+		s.Log.DebugContext(req.Context(), "access denied: identity not found", "req", req)
+		return nil, s.authFailed(req, authFailureUnknownIdentity, kes.ErrNotAllowed)
+	}
+	s.Usage.SeeIdentity(identity)
 	return &api.Request{
 		Request:  req,
 		Identity: identity,
@@ -63,14 +145,15 @@ func (v *verifyIdentity) Authenticate(req *http.Request) (*api.Request, api.Erro
 }
 
 // insecureIdentifyOnly does not authenticate client requests but
-// computes the certificate public key identity, if provided.
-// It does not return an error if the client did not provide a
-// certificate, or an invalid one, during the TLS handshake. In
-// such a case, the identity of the returned request is empty.
-type insecureIdentifyOnly struct{}
-
-func (insecureIdentifyOnly) Authenticate(req *http.Request) (*api.Request, api.Error) {
-	identity, _ := identifyRequest(req.TLS)
+// identifies the client using the authentication chain, if possible.
+// It does not return an error if the client did not provide any, or
+// only invalid, credentials. In such a case, the identity of the
+// returned request is empty.
+type insecureIdentifyOnly atomic.Pointer[serverState]
+
+func (v *insecureIdentifyOnly) Authenticate(req *http.Request) (*api.Request, api.Error) {
+	s := (*atomic.Pointer[serverState])(v).Load()
+	identity, _ := s.Auth.Identify(req, s.Admin)
 	return &api.Request{
 		Request:  req,
 		Identity: identity,
@@ -132,6 +215,34 @@ func validName(s string) bool {
 	return true
 }
 
+// validIdentity reports whether s is a valid identity that can be
+// assigned to a policy.
+//
+// Valid identities are either valid names, e.g. the hex-encoded hash
+// of a certificate public key, or an identity of an Authenticator
+// that starts with its prefix ("jwt:", "k8s:" or "hmac:") followed
+// by any printable characters except whitespace.
+func validIdentity(s string) bool {
+	const MaxLength = 320 // Long enough for an email address and a prefix
+
+	for _, prefix := range []string{jwtIdentityPrefix, k8sIdentityPrefix, hmacIdentityPrefix} {
+		id, ok := strings.CutPrefix(s, prefix)
+		if !ok {
+			continue
+		}
+		if id == "" || len(s) > MaxLength {
+			return false
+		}
+		for _, r := range id {
+			if !unicode.IsPrint(r) || unicode.IsSpace(r) {
+				return false
+			}
+		}
+		return true
+	}
+	return validName(s)
+}
+
 // validPattern reports whether s is a valid pattern for
 // listing {policy|identity|key} names.
 //
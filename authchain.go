@@ -0,0 +1,514 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+	xhttp "github.com/minio/kes/internal/http"
+	"github.com/minio/kms-go/kes"
+)
+
+// ErrNoCredentials is returned by an Authenticator if a request
+// contains no credentials it can verify. The next Authenticator
+// of the chain tries to identify the client instead.
+var ErrNoCredentials = errors.New("kes: request contains no credentials")
+
+// An Authenticator identifies the client that sent a request.
+//
+// Identify returns the identity of the client. It returns
+// ErrNoCredentials if the request contains no credentials
+// the Authenticator understands. Any other error rejects the
+// request. Policies are assigned to the returned identities.
+//
+// Only identities returned by the MTLSAuthenticator can be
+// the admin identity.
+type Authenticator interface {
+	Identify(*http.Request) (kes.Identity, error)
+}
+
+// Identity prefixes of the Authenticators that don't identify
+// clients by their certificate. Each one has its own prefix
+// such that clients of one Authenticator cannot claim the
+// identity of another one.
+const (
+	jwtIdentityPrefix  = "jwt:"
+	k8sIdentityPrefix  = "k8s:"
+	hmacIdentityPrefix = "hmac:"
+)
+
+// authChain is an ordered list of Authenticators. The first
+// Authenticator that finds credentials identifies the client.
+type authChain []Authenticator
+
+// newAuthChain returns an authChain for the given Authenticators.
+// If none are given, clients are identified by their TLS client
+// certificate.
+func newAuthChain(authenticators []Authenticator) authChain {
+	if len(authenticators) == 0 {
+		return authChain{MTLSAuthenticator{}}
+	}
+	return authChain(authenticators)
+}
+
+// Identify returns the identity of the client that sent
+// the request. It returns an error if no Authenticator finds
+// credentials or the credentials are invalid, or if any other
+// than the MTLSAuthenticator returns the admin identity.
+func (c authChain) Identify(req *http.Request, admin kes.Identity) (kes.Identity, api.Error) {
+	if req.TLS == nil {
+		return "", api.NewError(http.StatusBadRequest, "insecure connection: TLS is required")
+	}
+	for _, auth := range c {
+		identity, err := auth.Identify(req)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		if err != nil {
+			if err, ok := api.IsError(err); ok {
+				return "", err
+			}
+			return "", api.NewError(http.StatusUnauthorized, err.Error())
+		}
+		if identity.IsUnknown() {
+			return "", api.NewError(http.StatusUnauthorized, "invalid credentials: identity is empty")
+		}
+		if _, ok := auth.(MTLSAuthenticator); !ok && identity == admin {
+			return "", api.NewError(http.StatusUnauthorized, "invalid credentials: identity is reserved for the admin")
+		}
+		return identity, nil
+	}
+
+	if len(c) == 1 {
+		if _, ok := c[0].(MTLSAuthenticator); ok {
+			return "", api.NewError(http.StatusBadRequest, "tls: client certificate is required")
+		}
+	}
+	return "", api.NewError(http.StatusUnauthorized, "request contains no credentials")
+}
+
+// MTLSAuthenticator identifies clients by the hash of the public
+// key of the certificate they provide during the TLS handshake.
+type MTLSAuthenticator struct{}
+
+// Identify returns the identity of the request's client
+// certificate.
+func (MTLSAuthenticator) Identify(req *http.Request) (kes.Identity, error) {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return "", ErrNoCredentials
+	}
+	identity, err := identifyRequest(req.TLS)
+	if err != nil {
+		return "", err
+	}
+	return identity, nil
+}
+
+// HMACAuthenticator identifies clients by a request signature
+// computed with a secret shared between client and server.
+// The identity of a client is its key ID prefixed with "hmac:".
+//
+// The signature header has the form:
+//
+//	<identity>:<unix-time>:<hex(HMAC-SHA256(key, method "\n" request-uri "\n" unix-time))>
+//
+// The request body is not signed. Hence, the signature only
+// protects against replay of the signature for other requests
+// and TLS protects the request in transit.
+type HMACAuthenticator struct {
+	// Header is the HTTP header containing the signature.
+	// If empty, defaults to "Kes-Signature".
+	Header string
+
+	// Keys are the shared secrets by key ID. The key ID
+	// is the identity in the signature header.
+	Keys map[kes.Identity][]byte
+
+	// MaxSkew is the max. difference between the time of the
+	// signature and the server time. If <= 0, defaults to
+	// 5 minutes.
+	MaxSkew time.Duration
+}
+
+// Identify verifies the request signature and returns the
+// identity of the signer.
+func (a *HMACAuthenticator) Identify(req *http.Request) (kes.Identity, error) {
+	header := a.Header
+	if header == "" {
+		header = headers.KESSignature
+	}
+	maxSkew := a.MaxSkew
+	if maxSkew <= 0 {
+		maxSkew = 5 * time.Minute
+	}
+
+	v := req.Header.Get(header)
+	if v == "" {
+		return "", ErrNoCredentials
+	}
+	id, rest, _ := strings.Cut(v, ":")
+	timestamp, signature, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", errors.New("invalid signature: malformed header")
+	}
+	key, ok := a.Keys[kes.Identity(id)]
+	if !ok {
+		return "", kes.ErrNotAllowed
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", errors.New("invalid signature: malformed timestamp")
+	}
+	if skew := time.Since(time.Unix(sec, 0)); skew > maxSkew || skew < -maxSkew {
+		return "", errors.New("invalid signature: signature expired")
+	}
+	sum, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(sum, signRequest(key, req.Method, req.URL.RequestURI(), timestamp)) {
+		return "", errors.New("invalid signature")
+	}
+	return kes.Identity(hmacIdentityPrefix + id), nil
+}
+
+// signRequest returns the HMAC-SHA256 request signature
+// verified by the HMACAuthenticator.
+func signRequest(key []byte, method, uri, timestamp string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(method + "\n" + uri + "\n" + timestamp))
+	return mac.Sum(nil)
+}
+
+// JWTAuthenticator identifies clients by a JSON Web Token (JWT)
+// sent as bearer token in the Authorization header.
+//
+// Tokens must be signed with one of the public keys using RS256,
+// ES256, ES384 or EdDSA and must not be expired. Tokens issued by
+// another issuer are left to the next Authenticator of the chain.
+// The identity of a client is its claim prefixed with "jwt:".
+type JWTAuthenticator struct {
+	// Issuer is the expected "iss" claim. If empty, tokens of
+	// any issuer are accepted.
+	Issuer string
+
+	// Audience, if set, must be contained in the "aud" claim.
+	Audience string
+
+	// Claim is the claim containing the client identity.
+	// If empty, defaults to "sub".
+	Claim string
+
+	// Keys are the public keys verifying token signatures.
+	Keys []crypto.PublicKey
+
+	// Leeway is the tolerated clock skew when validating the
+	// "exp" and "nbf" claims.
+	Leeway time.Duration
+}
+
+// Identify verifies the bearer token and returns the
+// identity contained in its claim.
+func (a *JWTAuthenticator) Identify(req *http.Request) (kes.Identity, error) {
+	token, ok := bearerToken(req)
+	if !ok {
+		return "", ErrNoCredentials
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrNoCredentials // Not a JWT
+	}
+
+	var (
+		header struct {
+			Alg string `json:"alg"`
+		}
+		claims map[string]any
+	)
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return "", ErrNoCredentials
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return "", ErrNoCredentials
+	}
+	if iss, _ := claims["iss"].(string); a.Issuer != "" && iss != a.Issuer {
+		return "", ErrNoCredentials
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.New("invalid token: malformed signature")
+	}
+	if !a.verify(header.Alg, []byte(parts[0]+"."+parts[1]), signature) {
+		return "", errors.New("invalid token: invalid signature")
+	}
+
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return "", errors.New("invalid token: no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(a.Leeway)) {
+		return "", errors.New("invalid token: token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(a.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return "", errors.New("invalid token: token not valid yet")
+	}
+	if a.Audience != "" && !hasAudience(claims["aud"], a.Audience) {
+		return "", errors.New("invalid token: audience mismatch")
+	}
+
+	claim := a.Claim
+	if claim == "" {
+		claim = "sub"
+	}
+	identity, _ := claims[claim].(string)
+	if identity == "" {
+		return "", fmt.Errorf("invalid token: no '%s' claim", claim)
+	}
+	return kes.Identity(jwtIdentityPrefix + identity), nil
+}
+
+// verify reports whether the signature over msg is valid
+// for the algorithm and one of the public keys.
+func (a *JWTAuthenticator) verify(alg string, msg, signature []byte) bool {
+	for _, key := range a.Keys {
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			if alg != "RS256" {
+				continue
+			}
+			h := sha256.Sum256(msg)
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, h[:], signature) == nil {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			var digest []byte
+			switch {
+			case alg == "ES256" && key.Curve.Params().BitSize == 256:
+				h := sha256.Sum256(msg)
+				digest = h[:]
+			case alg == "ES384" && key.Curve.Params().BitSize == 384:
+				h := sha512.Sum384(msg)
+				digest = h[:]
+			default:
+				continue
+			}
+			size := (key.Curve.Params().BitSize + 7) / 8
+			if len(signature) != 2*size {
+				continue
+			}
+			r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+			if ecdsa.Verify(key, digest, r, s) {
+				return true
+			}
+		case ed25519.PublicKey:
+			if alg == "EdDSA" && ed25519.Verify(key, msg, signature) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeJWTPart(s string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// hasAudience reports whether the "aud" claim, either a
+// string or a list of strings, contains the audience.
+func hasAudience(claim any, audience string) bool {
+	switch aud := claim.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		for _, v := range aud {
+			if s, ok := v.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bearerToken returns the bearer token of the request's
+// Authorization header, if any.
+func bearerToken(req *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(req.Header.Get(headers.Authorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// KubernetesAuthenticator identifies clients by a Kubernetes
+// service account token sent as bearer token in the Authorization
+// header. Tokens are verified by the Kubernetes API server using
+// the TokenReview API. The identity is the username of the token
+// prefixed with "k8s:", e.g. "k8s:system:serviceaccount:<namespace>:<name>".
+//
+// Any bearer token is sent to the Kubernetes API server. Hence,
+// other Authenticators for bearer tokens, like a JWTAuthenticator,
+// should precede the KubernetesAuthenticator in the chain.
+type KubernetesAuthenticator struct {
+	// Endpoint is the URL of the Kubernetes API server.
+	Endpoint string
+
+	// Token is the bearer token of the KES server used to
+	// authenticate TokenReview requests.
+	Token string
+
+	// TLS is the TLS configuration for connecting to the
+	// Kubernetes API server, e.g. containing its CA.
+	TLS *tls.Config
+
+	// Audiences, if set, are the audiences the token must
+	// be valid for.
+	Audiences []string
+
+	// CacheTTL is the duration successful token reviews are
+	// cached. If <= 0, defaults to 1 minute.
+	CacheTTL time.Duration
+
+	once   sync.Once
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]cachedReview
+}
+
+type cachedReview struct {
+	Identity kes.Identity
+	Expiry   time.Time
+}
+
+// maxCachedReviews is the max. number of cached token reviews.
+// Once exceeded, all cached reviews are discarded.
+const maxCachedReviews = 1024
+
+// Identify reviews the bearer token and returns the
+// username of the token.
+func (a *KubernetesAuthenticator) Identify(req *http.Request) (kes.Identity, error) {
+	token, ok := bearerToken(req)
+	if !ok {
+		return "", ErrNoCredentials
+	}
+
+	h := sha256.Sum256([]byte(token))
+	a.mu.Lock()
+	if r, ok := a.cache[h]; ok && time.Now().Before(r.Expiry) {
+		a.mu.Unlock()
+		return r.Identity, nil
+	}
+	a.mu.Unlock()
+
+	identity, err := a.review(req.Context(), token)
+	if err != nil {
+		return "", err
+	}
+
+	ttl := a.CacheTTL
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	a.mu.Lock()
+	if a.cache == nil || len(a.cache) >= maxCachedReviews {
+		a.cache = map[[sha256.Size]byte]cachedReview{}
+	}
+	a.cache[h] = cachedReview{Identity: identity, Expiry: time.Now().Add(ttl)}
+	a.mu.Unlock()
+	return identity, nil
+}
+
+// review sends a TokenReview request for the token to the
+// Kubernetes API server.
+func (a *KubernetesAuthenticator) review(ctx context.Context, token string) (kes.Identity, error) {
+	a.once.Do(func() {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if a.TLS != nil {
+			transport.TLSClientConfig = a.TLS.Clone()
+		}
+		a.client = &http.Client{Transport: transport, Timeout: 10 * time.Second}
+	})
+
+	type TokenReviewSpec struct {
+		Token     string   `json:"token"`
+		Audiences []string `json:"audiences,omitempty"`
+	}
+	body, err := json.Marshal(struct {
+		APIVersion string          `json:"apiVersion"`
+		Kind       string          `json:"kind"`
+		Spec       TokenReviewSpec `json:"spec"`
+	}{
+		APIVersion: "authentication.k8s.io/v1",
+		Kind:       "TokenReview",
+		Spec:       TokenReviewSpec{Token: token, Audiences: a.Audiences},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(a.Endpoint, "/")+"/apis/authentication.k8s.io/v1/tokenreviews", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(headers.ContentType, headers.ContentTypeJSON)
+	req.Header.Set(headers.Authorization, "Bearer "+a.Token)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", api.NewError(http.StatusBadGateway, "failed to review token: Kubernetes API server is unreachable")
+	}
+	defer xhttp.DrainBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", api.NewError(http.StatusBadGateway, fmt.Sprintf("failed to review token: Kubernetes API server responded with '%s'", resp.Status))
+	}
+	var review struct {
+		Status struct {
+			Authenticated bool `json:"authenticated"`
+			User          struct {
+				Username string `json:"username"`
+			} `json:"user"`
+			Error string `json:"error"`
+		} `json:"status"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&review); err != nil {
+		return "", api.NewError(http.StatusBadGateway, "failed to review token: invalid TokenReview response")
+	}
+	if !review.Status.Authenticated {
+		if review.Status.Error != "" {
+			return "", errors.New("invalid token: " + review.Status.Error)
+		}
+		return "", errors.New("invalid token")
+	}
+	if review.Status.User.Username == "" {
+		return "", errors.New("invalid token: no username")
+	}
+	return kes.Identity(k8sIdentityPrefix + review.Status.User.Username), nil
+}
@@ -0,0 +1,400 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestAuthChain(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("my-secret")
+	chain := newAuthChain([]Authenticator{
+		MTLSAuthenticator{},
+		&HMACAuthenticator{Keys: map[kes.Identity][]byte{"my-app": key}},
+	})
+
+	req := newAuthRequest(http.MethodGet, api.PathKeyDescribe+"my-key")
+	if _, err := chain.Identify(req, ""); err == nil || err.Status() != http.StatusUnauthorized {
+		t.Fatalf("Request without credentials has been identified: %v", err)
+	}
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(headers.KESSignature, "my-app:"+now+":"+hex.EncodeToString(signRequest(key, req.Method, req.URL.RequestURI(), now)))
+	if identity, err := chain.Identify(req, ""); err != nil || identity != "hmac:my-app" {
+		t.Fatalf("Failed to identify signed request: got '%s': %v", identity, err)
+	}
+
+	// A signature for another request must be rejected.
+	other := newAuthRequest(http.MethodDelete, api.PathKeyDelete+"my-key")
+	other.Header.Set(headers.KESSignature, req.Header.Get(headers.KESSignature))
+	if _, err := chain.Identify(other, ""); err == nil {
+		t.Fatal("Request with signature of another request has been identified")
+	}
+
+	// The default chain requires a client certificate.
+	if _, err := newAuthChain(nil).Identify(req, ""); err == nil || err.Status() != http.StatusBadRequest {
+		t.Fatalf("Request without client certificate has been identified: %v", err)
+	}
+}
+
+func TestAuthChainAdmin(t *testing.T) {
+	t.Parallel()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	admin := kes.Identity(hex.EncodeToString(make([]byte, sha256.Size)))
+	token := signJWT(t, "EdDSA", map[string]any{"sub": admin.String(), "exp": time.Now().Unix() + 60}, key)
+
+	// A token with the admin identity as subject identifies
+	// a JWT client and not the admin.
+	chain := newAuthChain([]Authenticator{&JWTAuthenticator{Keys: []crypto.PublicKey{key.Public()}}})
+	req := newAuthRequest(http.MethodGet, api.PathKeyDescribe+"my-key")
+	req.Header.Set(headers.Authorization, "Bearer "+token)
+	identity, err := chain.Identify(req, admin)
+	if err != nil {
+		t.Fatalf("Failed to identify request: %v", err)
+	}
+	if identity == admin || identity != jwtIdentityPrefix+admin {
+		t.Fatalf("Invalid identity: got '%s' - want '%s'", identity, jwtIdentityPrefix+admin)
+	}
+
+	// Only the MTLSAuthenticator may return the admin identity.
+	chain = newAuthChain([]Authenticator{staticAuthenticator(admin)})
+	if identity, err := chain.Identify(req, admin); err == nil || err.Status() != http.StatusUnauthorized {
+		t.Fatalf("Request has been identified as admin '%s': %v", identity, err)
+	}
+}
+
+func TestValidIdentity(t *testing.T) {
+	for i, test := range validIdentityTests {
+		if valid := validIdentity(test.Identity); valid != test.Valid {
+			t.Fatalf("Test %d: got '%v' - want '%v' for identity '%s'", i, valid, test.Valid, test.Identity)
+		}
+	}
+}
+
+var validIdentityTests = []struct {
+	Identity string
+	Valid    bool
+}{
+	{Identity: "c84cc9b91ae2399b043da7eca616048d4b4200edf2ff418d8af3835911db945d", Valid: true}, // 0
+	{Identity: "jwt:alice@example.com", Valid: true},                                            // 1
+	{Identity: "k8s:system:serviceaccount:default:my-app", Valid: true},                         // 2
+	{Identity: "hmac:my-app", Valid: true},                                                      // 3
+	{Identity: "jwt:", Valid: false},                                                            // 4
+	{Identity: "jwt:alice smith", Valid: false},                                                 // 5
+	{Identity: "system:serviceaccount:default:my-app", Valid: false},                            // 6
+	{Identity: "oidc:alice", Valid: false},                                                      // 7
+	{Identity: "", Valid: false},                                                                // 8
+}
+
+func TestServerAuthChain(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	key := []byte("my-secret")
+	srv, url := startServer(ctx, &Config{
+		Authenticators: []Authenticator{
+			MTLSAuthenticator{},
+			&HMACAuthenticator{Keys: map[kes.Identity][]byte{"my-app": key}},
+		},
+		Policies: map[string]Policy{
+			"my-policy": {
+				Allow:      map[string]kes.Rule{api.PathKeyDescribe + "*": {}},
+				Identities: []kes.Identity{"hmac:my-app"},
+			},
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(defaultServerCertificate().Leaf)
+	anonymous := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: rootCAs},
+		},
+	}
+	for i, test := range []struct {
+		Path       string
+		StatusCode int
+	}{
+		{Path: api.PathKeyDescribe + "my-key", StatusCode: http.StatusOK}, // 0
+		{Path: api.PathKeyList + "*", StatusCode: http.StatusForbidden},   // 1
+	} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+test.Path, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		now := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(headers.KESSignature, "my-app:"+now+":"+hex.EncodeToString(signRequest(key, req.Method, req.URL.RequestURI(), now)))
+
+		resp, err := anonymous.Do(req)
+		if err != nil {
+			t.Fatalf("Test %d: failed to send request: %v", i, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.StatusCode {
+			t.Fatalf("Test %d: got status '%d' - want '%d'", i, resp.StatusCode, test.StatusCode)
+		}
+	}
+}
+
+func TestHMACAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("my-secret")
+	auth := &HMACAuthenticator{Keys: map[kes.Identity][]byte{"my-app": key}}
+	for i, test := range hmacAuthenticatorTests {
+		req := newAuthRequest(http.MethodGet, api.PathKeyDescribe+"my-key")
+		timestamp := strconv.FormatInt(time.Now().Add(test.Skew).Unix(), 10)
+		signature := hex.EncodeToString(signRequest(key, req.Method, req.URL.RequestURI(), timestamp))
+		if test.Header != "" {
+			req.Header.Set(headers.KESSignature, test.Header)
+		} else {
+			req.Header.Set(headers.KESSignature, test.Identity+":"+timestamp+":"+signature)
+		}
+
+		identity, err := auth.Identify(req)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: request has been identified as '%s'", i, identity)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to identify request: %v", i, err)
+		}
+	}
+}
+
+var hmacAuthenticatorTests = []struct {
+	Identity   string
+	Skew       time.Duration
+	Header     string
+	ShouldFail bool
+}{
+	{Identity: "my-app"},                                     // 0
+	{Identity: "my-app", Skew: -time.Minute},                 // 1
+	{Identity: "my-app", Skew: -time.Hour, ShouldFail: true}, // 2
+	{Identity: "my-app", Skew: time.Hour, ShouldFail: true},  // 3
+	{Identity: "other-app", ShouldFail: true},                // 4
+	{Header: "my-app", ShouldFail: true},                     // 5
+	{Header: "my-app:now:00", ShouldFail: true},              // 6
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	auth := &JWTAuthenticator{
+		Issuer:   "https://issuer.example.com",
+		Audience: "kes",
+		Keys:     []crypto.PublicKey{edKey.Public(), ecKey.Public()},
+	}
+
+	now := time.Now().Unix()
+	for i, test := range jwtAuthenticatorTests {
+		claims := map[string]any{"iss": "https://issuer.example.com", "aud": []string{"kes", "other"}, "sub": "my-app", "exp": now + 60}
+		for k, v := range test.Claims {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+		var token string
+		if test.ES256 {
+			token = signJWT(t, "ES256", claims, ecKey)
+		} else {
+			token = signJWT(t, "EdDSA", claims, edKey)
+		}
+		if test.Tamper {
+			token = token[:len(token)-4] + "AAAA"
+		}
+
+		req := newAuthRequest(http.MethodGet, api.PathKeyDescribe+"my-key")
+		req.Header.Set(headers.Authorization, "Bearer "+token)
+		identity, err := auth.Identify(req)
+		switch {
+		case test.Err != nil:
+			if err != test.Err {
+				t.Fatalf("Test %d: got error '%v' - want '%v'", i, err, test.Err)
+			}
+		case test.ShouldFail:
+			if err == nil {
+				t.Fatalf("Test %d: request has been identified as '%s'", i, identity)
+			}
+		default:
+			if err != nil {
+				t.Fatalf("Test %d: failed to identify request: %v", i, err)
+			}
+			if identity != "jwt:my-app" {
+				t.Fatalf("Test %d: got identity '%s' - want '%s'", i, identity, "jwt:my-app")
+			}
+		}
+	}
+}
+
+var jwtAuthenticatorTests = []struct {
+	Claims     map[string]any
+	ES256      bool
+	Tamper     bool
+	Err        error // Expected sentinel error
+	ShouldFail bool
+}{
+	{},            // 0
+	{ES256: true}, // 1
+	{Claims: map[string]any{"iss": "https://other.example.com"}, Err: ErrNoCredentials}, // 2
+	{Tamper: true, ShouldFail: true}, // 3
+	{Claims: map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}, ShouldFail: true}, // 4
+	{Claims: map[string]any{"exp": nil}, ShouldFail: true},                               // 5
+	{Claims: map[string]any{"nbf": time.Now().Add(time.Hour).Unix()}, ShouldFail: true},  // 6
+	{Claims: map[string]any{"aud": "other"}, ShouldFail: true},                           // 7
+	{Claims: map[string]any{"aud": "kes"}},                                               // 8
+	{Claims: map[string]any{"sub": nil}, ShouldFail: true},                               // 9
+}
+
+func TestKubernetesAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	var reviews int
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/authentication.k8s.io/v1/tokenreviews" || r.Header.Get(headers.Authorization) != "Bearer reviewer-token" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		reviews++
+
+		var review struct {
+			Spec struct {
+				Token string `json:"token"`
+			} `json:"spec"`
+		}
+		json.NewDecoder(r.Body).Decode(&review)
+
+		type User struct {
+			Username string `json:"username"`
+		}
+		type Status struct {
+			Authenticated bool   `json:"authenticated"`
+			User          User   `json:"user"`
+			Error         string `json:"error,omitempty"`
+		}
+		status := Status{Error: "token is invalid"}
+		if review.Spec.Token == "valid-token" {
+			status = Status{Authenticated: true, User: User{Username: "system:serviceaccount:default:my-app"}}
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"status": status})
+	}))
+	defer srv.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(srv.Certificate())
+	auth := &KubernetesAuthenticator{
+		Endpoint: srv.URL,
+		Token:    "reviewer-token",
+		TLS:      &tls.Config{RootCAs: rootCAs},
+	}
+
+	for range 2 {
+		req := newAuthRequest(http.MethodGet, api.PathKeyDescribe+"my-key")
+		req.Header.Set(headers.Authorization, "Bearer valid-token")
+		identity, err := auth.Identify(req)
+		if err != nil {
+			t.Fatalf("Failed to identify request: %v", err)
+		}
+		if identity != "k8s:system:serviceaccount:default:my-app" {
+			t.Fatalf("Invalid identity: got '%s' - want '%s'", identity, "k8s:system:serviceaccount:default:my-app")
+		}
+	}
+	if reviews != 1 {
+		t.Fatalf("Token review has not been cached: got %d reviews - want %d", reviews, 1)
+	}
+
+	req := newAuthRequest(http.MethodGet, api.PathKeyDescribe+"my-key")
+	req.Header.Set(headers.Authorization, "Bearer invalid-token")
+	if identity, err := auth.Identify(req); err == nil {
+		t.Fatalf("Invalid token has been identified as '%s'", identity)
+	}
+
+	req = newAuthRequest(http.MethodGet, api.PathKeyDescribe+"my-key")
+	if _, err := auth.Identify(req); err != ErrNoCredentials {
+		t.Fatalf("Request without token has been reviewed: got '%v' - want '%v'", err, ErrNoCredentials)
+	}
+}
+
+// staticAuthenticator identifies any request as itself.
+type staticAuthenticator kes.Identity
+
+func (a staticAuthenticator) Identify(*http.Request) (kes.Identity, error) {
+	return kes.Identity(a), nil
+}
+
+// newAuthRequest returns a new HTTP request received over
+// a TLS connection without a client certificate.
+func newAuthRequest(method, path string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	req.TLS = &tls.ConnectionState{}
+	return req
+}
+
+// signJWT returns a JWT with the given claims signed with key.
+func signJWT(t *testing.T, alg string, claims map[string]any, key crypto.Signer) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("Failed to encode claims: %v", err)
+	}
+	msg := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch key := key.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(msg))
+	case *ecdsa.PrivateKey:
+		h := sha256.Sum256([]byte(msg))
+		r, s, err := ecdsa.Sign(rand.Reader, key, h[:])
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return msg + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
@@ -0,0 +1,276 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"maps"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kes/internal/fips"
+	"github.com/minio/kms-go/kes"
+)
+
+// maxBatchOperations is the max. number of operations
+// within a single batch request.
+const maxBatchOperations = 32
+
+// batch applies a list of control-plane operations atomically.
+// Either all operations are applied or none.
+//
+// Policy changes and identity assignments are only applied to
+// the in-memory server state of the server handling the batch.
+// They are not persisted and get lost once the server restarts
+// or its configuration or policies get updated, for example by
+// Server.Update or Server.UpdatePolicies. The response reports
+// such non-persistent changes. Created keys are persisted.
+//
+// A batch is applied in two phases. First, all operations are
+// validated and policy changes are applied to a copy of the
+// current policy set. Then, all keys are created. If creating
+// a key fails, all keys created by the batch are deleted again.
+// Finally, the new policy set replaces the current one.
+func (s *Server) batch(resp *api.Response, req *api.Request) {
+	if req.Identity != s.state.Load().Admin {
+		resp.Fail(http.StatusForbidden, "batch API requires the admin identity")
+		return
+	}
+
+	var batch api.BatchRequest
+	if err := api.ReadBody(req, &batch); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid batch request body")
+		return
+	}
+	if len(batch.Operations) == 0 {
+		resp.Fail(http.StatusBadRequest, "batch contains no operations")
+		return
+	}
+	if len(batch.Operations) > maxBatchOperations {
+		resp.Failf(http.StatusBadRequest, "batch contains more than %d operations", maxBatchOperations)
+		return
+	}
+
+	// Batches must not interleave with each other nor with
+	// concurrent configuration updates. Otherwise, policy
+	// changes of one may get lost.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		resp.Fail(http.StatusServiceUnavailable, "server is closed")
+		return
+	}
+
+	state := s.state.Load()
+	policies := maps.Clone(state.Policies)
+	identities := maps.Clone(state.Identities)
+	keys := make(map[int]crypto.KeyVersion)
+	owners := make(map[int]*keyOwner)
+	for i, op := range batch.Operations {
+		var err api.Error
+		switch op.Op {
+		case api.BatchCreateKey, api.BatchImportKey:
+			var key crypto.KeyVersion
+			if key, err = batchKey(op, req.Identity); err == nil {
+				keys[i] = key
+				owners[i], err = parseKeyOwner(op.Owner, op.Contact, state.RequireOwner, req.Identity)
+			}
+		case api.BatchSetPolicy:
+			err = batchSetPolicy(op, req.Identity, policies, identities)
+		case api.BatchAssignIdentity:
+			err = batchAssignIdentity(op, state.Admin, policies, identities)
+		default:
+			err = api.NewError(http.StatusBadRequest, fmt.Sprintf("operation '%s' is not supported", op.Op))
+		}
+		if err != nil {
+			failBatch(resp, i, op, err)
+			return
+		}
+	}
+
+	var created []string
+	for i, op := range batch.Operations {
+		key, ok := keys[i]
+		if !ok {
+			continue
+		}
+		if err := createOwnedKey(req.Context(), state.Keys, op.Name, key, nil, owners[i]); err != nil {
+			s.rollbackBatch(req, state, created)
+
+			if err, ok := api.IsError(err); ok {
+				failBatch(resp, i, op, err)
+				return
+			}
+			state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+			failBatch(resp, i, op, api.NewError(http.StatusBadGateway, "failed to create key"))
+			return
+		}
+		created = append(created, op.Name)
+	}
+
+	newState := state.clone()
+	newState.Policies = policies
+	newState.Identities = identities
+	newState.Aliases.Prune(identities)
+	s.state.Store(newState)
+
+	for _, name := range created {
+		state.Changes.Record(api.ChangeObjectKey, api.ChangeCreate, name, req.Identity)
+	}
+	state.Changes.RecordPolicies(state, s.state.Load(), req.Identity)
+
+	const StatusOK = http.StatusOK
+	for _, op := range batch.Operations {
+		var msg string
+		switch op.Op {
+		case api.BatchCreateKey:
+			msg = fmt.Sprintf("secret key '%s' created", op.Name)
+		case api.BatchImportKey:
+			msg = fmt.Sprintf("secret key '%s' imported", op.Name)
+		case api.BatchSetPolicy:
+			msg = fmt.Sprintf("policy '%s' updated", op.Name)
+		case api.BatchAssignIdentity:
+			msg = fmt.Sprintf("policy '%s' assigned to identity '%s'", op.Policy, op.Identity)
+		}
+		state.Audit.Log(msg, StatusOK, req)
+	}
+	api.ReplyWith(resp, StatusOK, api.BatchResponse{
+		Applied:   len(batch.Operations),
+		Ephemeral: len(created) < len(batch.Operations),
+	})
+}
+
+// rollbackBatch deletes all keys created by a failed batch.
+func (s *Server) rollbackBatch(req *api.Request, state *serverState, created []string) {
+	ctx := context.WithoutCancel(req.Context())
+	for _, name := range created {
+		if err := state.Keys.Delete(ctx, name); err != nil {
+			state.Log.ErrorContext(ctx, fmt.Sprintf("failed to roll back batch: failed to delete key '%s': %v", name, err), "req", req)
+		}
+	}
+}
+
+// failBatch responds with the error of the i-th batch operation.
+func failBatch(resp *api.Response, i int, op api.BatchOperation, err api.Error) {
+	api.ReplyWith(resp, err.Status(), api.BatchErrorResponse{
+		Message:  err.Error(),
+		Code:     api.ErrorCode(err),
+		FailedAt: i,
+		Op:       op.Op,
+	})
+}
+
+// batchKey returns a new key version for a create_key or
+// import_key batch operation.
+func batchKey(op api.BatchOperation, createdBy kes.Identity) (crypto.KeyVersion, api.Error) {
+	if !validName(op.Name) {
+		return crypto.KeyVersion{}, api.NewError(http.StatusBadRequest, fmt.Sprintf("key name '%s' is empty, too long or contains invalid characters", op.Name))
+	}
+
+	var (
+		key crypto.SecretKey
+		err error
+	)
+	if op.Op == api.BatchImportKey {
+		var cipher crypto.SecretKeyType
+		if cipher, err = crypto.ParseSecretKeyType(op.Cipher); err != nil {
+			return crypto.KeyVersion{}, api.NewError(http.StatusNotAcceptable, fmt.Sprintf("algorithm '%s' is not supported", op.Cipher))
+		}
+		if fips.Enabled && cipher != crypto.AES256 {
+			return crypto.KeyVersion{}, api.NewError(http.StatusNotAcceptable, fmt.Sprintf("algorithm '%s' not supported by FIPS 140-2", op.Cipher))
+		}
+		if len(op.Bytes) != crypto.SecretKeySize {
+			return crypto.KeyVersion{}, api.NewError(http.StatusNotAcceptable, fmt.Sprintf("invalid key size for '%s'", op.Cipher))
+		}
+		key, err = crypto.NewSecretKey(cipher, op.Bytes)
+	} else {
+		key, err = crypto.GenerateSecretKey(crypto.DetermineSecretKeyType(), rand.Reader)
+	}
+	if err != nil {
+		return crypto.KeyVersion{}, api.NewError(http.StatusInternalServerError, "failed to create key")
+	}
+
+	hmac, err := crypto.GenerateHMACKey(crypto.SHA256, rand.Reader)
+	if err != nil {
+		return crypto.KeyVersion{}, api.NewError(http.StatusInternalServerError, "failed to create key")
+	}
+	return crypto.KeyVersion{
+		Key:       key,
+		HMACKey:   hmac,
+		CreatedAt: time.Now().UTC(),
+		CreatedBy: createdBy,
+	}, nil
+}
+
+// batchSetPolicy creates or replaces a policy for a set_policy
+// batch operation. Identities assigned to a replaced policy get
+// assigned to the new policy.
+func batchSetPolicy(op api.BatchOperation, createdBy kes.Identity, policies map[string]*kes.Policy, identities map[kes.Identity]identityEntry) api.Error {
+	if !validName(op.Name) {
+		return api.NewError(http.StatusBadRequest, fmt.Sprintf("policy name '%s' is empty, too long or contains invalid characters", op.Name))
+	}
+
+	policy := &kes.Policy{
+		Allow:     make(map[string]kes.Rule, len(op.Allow)),
+		Deny:      make(map[string]kes.Rule, len(op.Deny)),
+		CreatedAt: time.Now().UTC(),
+		CreatedBy: createdBy,
+	}
+	for _, pattern := range op.Allow {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid policy '%s': invalid allow pattern '%s'", op.Name, pattern))
+		}
+		policy.Allow[pattern] = kes.Rule{}
+	}
+	for _, pattern := range op.Deny {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid policy '%s': invalid deny pattern '%s'", op.Name, pattern))
+		}
+		policy.Deny[pattern] = kes.Rule{}
+	}
+
+	policies[op.Name] = policy
+	for id, entry := range identities {
+		if entry.Name == op.Name {
+			identities[id] = identityEntry{Name: op.Name, Policy: policy}
+		}
+	}
+	return nil
+}
+
+// batchAssignIdentity assigns a policy to an identity for an
+// assign_identity batch operation. The identity must not have
+// another policy assigned already.
+func batchAssignIdentity(op api.BatchOperation, admin kes.Identity, policies map[string]*kes.Policy, identities map[kes.Identity]identityEntry) api.Error {
+	id := kes.Identity(op.Identity)
+	if !validIdentity(op.Identity) {
+		return api.NewError(http.StatusBadRequest, fmt.Sprintf("identity '%s' is empty, too long or contains invalid characters", op.Identity))
+	}
+	if id == admin {
+		return api.NewError(http.StatusBadRequest, "cannot assign a policy to the admin identity")
+	}
+
+	policy, ok := policies[op.Policy]
+	if !ok {
+		return api.NewErrorCode(http.StatusNotFound, api.CodePolicyNotFound, fmt.Sprintf("policy '%s' does not exist", op.Policy))
+	}
+	if entry, ok := identities[id]; ok && entry.Name != op.Policy {
+		return api.NewError(http.StatusConflict, fmt.Sprintf("identity '%s' already has the policy '%s'", op.Identity, entry.Name))
+	}
+	identities[id] = identityEntry{Name: op.Policy, Policy: policy}
+	return nil
+}
@@ -0,0 +1,239 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kes/internal/https"
+	"github.com/minio/kms-go/kes"
+)
+
+// maxChanges is the number of change events a changeFeed retains.
+// Clients that fall further behind have to resync with a full list.
+const maxChanges = 10000
+
+// changeFeed is an ordered, in-memory log of changes to keys,
+// policies and identities. Each event has a unique, increasing
+// sequence number such that clients can resume the feed from
+// the last event they have seen.
+//
+// Sequence numbers are only unique per feed. Each server, and each
+// server restart, starts a new feed with a new random epoch. Events
+// carry a cursor that combines the epoch and the sequence number
+// such that a client cannot resume from a cursor of another feed.
+type changeFeed struct {
+	epoch string
+
+	mu     sync.Mutex
+	seq    uint64
+	events []api.ChangeEvent
+	wait   chan struct{} // Closed and replaced on every change
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{
+		epoch: rand.Text(),
+		wait:  make(chan struct{}),
+	}
+}
+
+// Cursor returns the cursor of the event with the given
+// sequence number.
+func (f *changeFeed) Cursor(seq uint64) string {
+	return f.epoch + "." + strconv.FormatUint(seq, 10)
+}
+
+// ParseCursor returns the sequence number of the cursor. It
+// returns false if the cursor belongs to another feed.
+func (f *changeFeed) ParseCursor(cursor string) (uint64, bool, error) {
+	epoch, v, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return 0, false, fmt.Errorf("invalid change feed cursor '%s'", cursor)
+	}
+	seq, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid change feed cursor '%s'", cursor)
+	}
+	return seq, epoch == f.epoch, nil
+}
+
+// Record appends a new change event to the feed and
+// wakes up all clients waiting for new events.
+func (f *changeFeed) Record(object, op, name string, by kes.Identity) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.events) >= maxChanges {
+		f.events = append(f.events[:0], f.events[maxChanges/10:]...)
+	}
+	f.seq++
+	f.events = append(f.events, api.ChangeEvent{
+		Seq:       f.seq,
+		Cursor:    f.Cursor(f.seq),
+		Time:      time.Now().UTC(),
+		Object:    object,
+		Op:        op,
+		Name:      name,
+		Initiator: by.String(),
+	})
+
+	close(f.wait)
+	f.wait = make(chan struct{})
+}
+
+// Since returns all events after the given sequence number and a
+// channel that is closed once more events get recorded. It returns
+// false if events after seq have been discarded already.
+func (f *changeFeed) Since(seq uint64) ([]api.ChangeEvent, <-chan struct{}, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if seq > f.seq {
+		return nil, f.wait, false
+	}
+	if len(f.events) == 0 || seq+1 >= f.events[0].Seq {
+		i := len(f.events) - int(f.seq-seq)
+		if i < 0 {
+			i = 0
+		}
+		events := make([]api.ChangeEvent, len(f.events)-i)
+		copy(events, f.events[i:])
+		return events, f.wait, true
+	}
+	return nil, f.wait, false
+}
+
+// Oldest returns the sequence number preceding
+// the oldest event retained by the feed.
+func (f *changeFeed) Oldest() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.events) == 0 {
+		return f.seq
+	}
+	return f.events[0].Seq - 1
+}
+
+// RecordPolicies records the changes between the old
+// and new policies and identity assignments.
+func (f *changeFeed) RecordPolicies(old, new *serverState, by kes.Identity) {
+	if f == nil {
+		return
+	}
+
+	for name, policy := range new.Policies {
+		switch p, ok := old.Policies[name]; {
+		case !ok:
+			f.Record(api.ChangeObjectPolicy, api.ChangeCreate, name, by)
+		case !maps.Equal(p.Allow, policy.Allow) || !maps.Equal(p.Deny, policy.Deny):
+			f.Record(api.ChangeObjectPolicy, api.ChangeUpdate, name, by)
+		}
+	}
+	for name := range old.Policies {
+		if _, ok := new.Policies[name]; !ok {
+			f.Record(api.ChangeObjectPolicy, api.ChangeDelete, name, by)
+		}
+	}
+
+	for id, entry := range new.Identities {
+		switch e, ok := old.Identities[id]; {
+		case !ok:
+			f.Record(api.ChangeObjectIdentity, api.ChangeCreate, id.String(), by)
+		case e.Name != entry.Name:
+			f.Record(api.ChangeObjectIdentity, api.ChangeUpdate, id.String(), by)
+		}
+	}
+	for id := range old.Identities {
+		if _, ok := new.Identities[id]; !ok {
+			f.Record(api.ChangeObjectIdentity, api.ChangeDelete, id.String(), by)
+		}
+	}
+}
+
+// changes streams change events as JSON lines. By default, it
+// sends all retained events and then waits for new events until
+// the client disconnects. Clients resume the feed by sending the
+// cursor of the last event they have seen.
+//
+// Cursors of another server, or of the same server before a
+// restart, are rejected with 410 Gone. Then, the client has to
+// resync with a full list.
+func (s *Server) changes(resp *api.Response, req *api.Request) {
+	var (
+		since  uint64
+		follow = true
+		err    error
+	)
+	feed := s.state.Load().Changes
+	query := req.URL.Query()
+	if v := query.Get("follow"); v != "" {
+		if follow, err = strconv.ParseBool(v); err != nil {
+			resp.Failr(api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid 'follow' value '%s'", v)))
+			return
+		}
+	}
+	if v := query.Get("since"); v != "" {
+		seq, ok, err := feed.ParseCursor(v)
+		if err != nil {
+			resp.Fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		if !ok {
+			resp.Failf(http.StatusGone, "change feed cursor '%s' belongs to another server or a previous server run: resync with a full list", v)
+			return
+		}
+		since = seq
+	} else {
+		since = feed.Oldest()
+	}
+	events, wait, ok := feed.Since(since)
+	if !ok {
+		resp.Failf(http.StatusGone, "change feed cursor '%s' is no longer available: resync with a full list", feed.Cursor(since))
+		return
+	}
+
+	resp.Header().Set(headers.ContentType, headers.ContentTypeJSONLines)
+	resp.WriteHeader(http.StatusOK)
+
+	w := https.FlushOnWrite(resp.ResponseWriter)
+	http.NewResponseController(w).Flush() // Send the response headers before waiting for events
+	encoder := json.NewEncoder(w)
+	for {
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+			since = event.Seq
+		}
+		if !follow {
+			return
+		}
+
+		select {
+		case <-req.Context().Done():
+			return
+		case <-wait:
+		}
+		if events, wait, ok = feed.Since(since); !ok {
+			return // Client is too slow. It will receive 410 on reconnect.
+		}
+	}
+}
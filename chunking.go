@@ -0,0 +1,303 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/minio/kms-go/kes"
+)
+
+// chunkedHeader is the prefix of all key store entries that
+// refer to chunks instead of containing the value itself.
+var chunkedHeader = []byte("kes\x00chunked\x01")
+
+// chunkPrefix is the prefix of chunk entries at the key store.
+// It is followed by the entry name, the ID of the chunk set and
+// the chunk index. Like locks, chunks never collide with keys.
+const chunkPrefix = "-chunk-"
+
+// maxChunks is the max. number of chunks of one entry.
+const maxChunks = 1024
+
+// A chunkManifest is stored, after the chunkedHeader, as JSON
+// instead of a value that has been split into chunks.
+//
+// Each write of a value creates a new set of chunks with a
+// unique ID. Hence, concurrent writes and reads never mix
+// chunks of different values.
+type chunkManifest struct {
+	ID     string `json:"id"`
+	Chunks int    `json:"chunks"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// chunkNames returns the names of the chunk entries of
+// the entry with the given name.
+func (m *chunkManifest) chunkNames(name string) []string {
+	names := make([]string, 0, m.Chunks)
+	for i := range m.Chunks {
+		names = append(names, chunkPrefix+name+"-"+m.ID+"-"+strconv.Itoa(i))
+	}
+	return names
+}
+
+// chunkedStore is a KeyStore that splits entries larger
+// than the chunk size into multiple entries at the
+// underlying KeyStore.
+type chunkedStore struct {
+	KeyStore
+	size int
+}
+
+// newChunkedStore returns a KeyStore that splits entries into
+// chunks before storing them at store. It returns store if conf
+// is nil.
+func newChunkedStore(store KeyStore, conf *ChunkingConfig) KeyStore {
+	if conf == nil || store == nil {
+		return store
+	}
+	size := conf.Size
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	return &chunkedStore{KeyStore: store, size: size}
+}
+
+// Create creates the entry. If the value is larger than the
+// chunk size, it creates the chunks before the entry itself.
+func (s *chunkedStore) Create(ctx context.Context, name string, value []byte) error {
+	stored, chunks, err := s.split(ctx, name, value)
+	if err != nil {
+		return err
+	}
+	if err = s.KeyStore.Create(ctx, name, stored); err != nil {
+		s.deleteChunks(ctx, chunks)
+		return err
+	}
+	return nil
+}
+
+// CanOverwrite reports whether the underlying KeyStore
+// can replace existing entries.
+func (s *chunkedStore) CanOverwrite() bool {
+	store, ok := s.KeyStore.(OverwriteKeyStore)
+	return ok && store.CanOverwrite()
+}
+
+// Set creates or replaces the entry. Chunks of the replaced
+// value are removed once the entry has been replaced.
+func (s *chunkedStore) Set(ctx context.Context, name string, value []byte) error {
+	old, err := s.KeyStore.Get(ctx, name)
+	if err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+		return err
+	}
+
+	stored, chunks, err := s.split(ctx, name, value)
+	if err != nil {
+		return err
+	}
+	if err = setEntry(ctx, s.KeyStore, name, stored); err != nil {
+		s.deleteChunks(ctx, chunks)
+		return err
+	}
+	s.deleteChunksOf(ctx, name, old)
+	return nil
+}
+
+// CanSwap reports whether the underlying KeyStore
+// supports conditional writes.
+func (s *chunkedStore) CanSwap() bool { return canSwap(s.KeyStore) }
+
+// Swap replaces the entry if its value, joined from its chunks,
+// is equal to old. The entry itself is replaced with a conditional
+// write. Hence, chunks written concurrently are never mixed.
+func (s *chunkedStore) Swap(ctx context.Context, name string, old, value []byte) error {
+	stored, err := s.KeyStore.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	current, err := s.join(ctx, name, stored)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, old) {
+		return kes.ErrKeyExists
+	}
+
+	replacement, chunks, err := s.split(ctx, name, value)
+	if err != nil {
+		return err
+	}
+	if err = swapEntry(ctx, s.KeyStore, name, stored, replacement); err != nil {
+		s.deleteChunks(ctx, chunks)
+		return err
+	}
+	s.deleteChunksOf(ctx, name, stored)
+	return nil
+}
+
+// Delete removes the entry and its chunks.
+func (s *chunkedStore) Delete(ctx context.Context, name string) error {
+	stored, err := s.KeyStore.Get(ctx, name)
+	if err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+		return err
+	}
+	if err = s.KeyStore.Delete(ctx, name); err != nil {
+		return err
+	}
+	s.deleteChunksOf(ctx, name, stored)
+	return nil
+}
+
+// Get returns the value of the entry joined from its chunks.
+func (s *chunkedStore) Get(ctx context.Context, name string) ([]byte, error) {
+	value, err := s.KeyStore.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.join(ctx, name, value)
+}
+
+// GetBulk returns the values of the given entries joined
+// from their chunks.
+func (s *chunkedStore) GetBulk(ctx context.Context, names []string) (map[string][]byte, error) {
+	values, err := getBulk(ctx, s.KeyStore, names)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range values {
+		if values[name], err = s.join(ctx, name, value); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// List returns the first n entry names that start with the
+// given prefix. Chunk entries are not included.
+func (s *chunkedStore) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	names, next, err := s.KeyStore.List(ctx, prefix, n)
+	if err != nil {
+		return nil, "", err
+	}
+	return slices.DeleteFunc(names, func(name string) bool { return strings.HasPrefix(name, chunkPrefix) }), next, nil
+}
+
+// split returns the value as it is if it is not larger than
+// the chunk size. Otherwise, it stores the value as chunks
+// and returns the encoded chunk manifest and the names of
+// the created chunks.
+func (s *chunkedStore) split(ctx context.Context, name string, value []byte) ([]byte, []string, error) {
+	if len(value) <= s.size {
+		return value, nil, nil
+	}
+	if len(value) > s.size*maxChunks {
+		return nil, nil, fmt.Errorf("kes: key store entry '%s' is too large: %d bytes exceed the max. of %d chunks", name, len(value), maxChunks)
+	}
+
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, nil, err
+	}
+	sum := sha256.Sum256(value)
+	m := chunkManifest{
+		ID:     hex.EncodeToString(id[:]),
+		Chunks: (len(value) + s.size - 1) / s.size,
+		Size:   len(value),
+		SHA256: hex.EncodeToString(sum[:]),
+	}
+
+	names := m.chunkNames(name)
+	for i, chunk := range slices.Collect(slices.Chunk(value, s.size)) {
+		if err := s.KeyStore.Create(ctx, names[i], chunk); err != nil {
+			s.deleteChunks(ctx, names[:i])
+			return nil, nil, err
+		}
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		s.deleteChunks(ctx, names)
+		return nil, nil, err
+	}
+	return append(bytes.Clone(chunkedHeader), b...), names, nil
+}
+
+// join returns the value of the entry with the given name.
+// If the value is a chunk manifest, it reads all chunks and
+// verifies the checksum of the joined value. Otherwise, it
+// returns the value as it is.
+func (s *chunkedStore) join(ctx context.Context, name string, value []byte) ([]byte, error) {
+	m, ok, err := parseChunkManifest(name, value)
+	if !ok || err != nil {
+		return value, err
+	}
+
+	names := m.chunkNames(name)
+	chunks, err := getBulk(ctx, s.KeyStore, names)
+	if err != nil {
+		return nil, err
+	}
+	joined := make([]byte, 0, m.Size)
+	for _, chunkName := range names {
+		chunk, ok := chunks[chunkName]
+		if !ok {
+			return nil, fmt.Errorf("kes: key store entry '%s' is corrupted: chunk '%s' not found", name, chunkName)
+		}
+		joined = append(joined, chunk...)
+	}
+
+	sum := sha256.Sum256(joined)
+	if len(joined) != m.Size || hex.EncodeToString(sum[:]) != m.SHA256 {
+		return nil, fmt.Errorf("kes: key store entry '%s' is corrupted: checksum mismatch", name)
+	}
+	return joined, nil
+}
+
+// deleteChunksOf removes the chunks of the entry with the
+// given name, if its stored value is a chunk manifest.
+func (s *chunkedStore) deleteChunksOf(ctx context.Context, name string, stored []byte) {
+	if m, ok, err := parseChunkManifest(name, stored); ok && err == nil {
+		s.deleteChunks(ctx, m.chunkNames(name))
+	}
+}
+
+// deleteChunks removes the chunks with the given names. Chunks
+// that cannot be removed are left behind since they are never
+// read again.
+func (s *chunkedStore) deleteChunks(ctx context.Context, names []string) {
+	for _, name := range names {
+		s.KeyStore.Delete(ctx, name)
+	}
+}
+
+// parseChunkManifest parses the chunk manifest of the entry with
+// the given name. It reports whether the value is a chunk manifest.
+func parseChunkManifest(name string, value []byte) (*chunkManifest, bool, error) {
+	b, ok := bytes.CutPrefix(value, chunkedHeader)
+	if !ok {
+		return nil, false, nil
+	}
+	var m chunkManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, true, fmt.Errorf("kes: invalid chunk manifest of key store entry '%s': %v", name, err)
+	}
+	if m.Chunks <= 0 || m.Chunks > maxChunks || m.Size < 0 {
+		return nil, true, fmt.Errorf("kes: invalid chunk manifest of key store entry '%s': invalid number of chunks", name)
+	}
+	return &m, true, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/minio/kms-go/kes"
+)
+
+func TestChunkedStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	backend := &MemKeyStore{}
+	store := newChunkedStore(backend, &ChunkingConfig{Size: 16})
+
+	value := []byte("my-secret-value-that-is-larger-than-the-chunk-size")
+	if err := store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if err := store.Create(ctx, "my-key", value); !errors.Is(err, kes.ErrKeyExists) {
+		t.Fatalf("Created entry twice: got '%v' - want '%v'", err, kes.ErrKeyExists)
+	}
+	if n := countChunks(ctx, t, backend); n != 4 {
+		t.Fatalf("Invalid number of chunks: got '%d' - want '%d'", n, 4)
+	}
+	if stored, _ := backend.Get(ctx, "my-key"); !bytes.HasPrefix(stored, chunkedHeader) {
+		t.Fatalf("Entry is not chunked: '%s'", stored)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get entry: got '%s' - want '%s': %v", v, value, err)
+	}
+	if names, _, err := store.List(ctx, "", -1); err != nil || !slices.Equal(names, []string{"my-key"}) {
+		t.Fatalf("Failed to list entries: got '%v' - want '%v': %v", names, []string{"my-key"}, err)
+	}
+
+	// Small entries are stored as they are.
+	small := []byte("small-value")
+	if err := store.Create(ctx, "small-key", small); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if v, _ := backend.Get(ctx, "small-key"); !bytes.Equal(v, small) {
+		t.Fatalf("Small entry got chunked: '%s'", v)
+	}
+
+	// Replacing an entry removes the chunks of the previous value.
+	if err := swapEntry(ctx, store, "my-key", small, value); !errors.Is(err, kes.ErrKeyExists) {
+		t.Fatalf("Swapped entry with wrong value: got '%v' - want '%v'", err, kes.ErrKeyExists)
+	}
+	replacement := bytes.Repeat([]byte("replacement"), 4)
+	if err := swapEntry(ctx, store, "my-key", value, replacement); err != nil {
+		t.Fatalf("Failed to swap entry: %v", err)
+	}
+	if n := countChunks(ctx, t, backend); n != 3 {
+		t.Fatalf("Invalid number of chunks: got '%d' - want '%d'", n, 3)
+	}
+	if err := setEntry(ctx, store, "my-key", small); err != nil {
+		t.Fatalf("Failed to set entry: %v", err)
+	}
+	if n := countChunks(ctx, t, backend); n != 0 {
+		t.Fatalf("Invalid number of chunks: got '%d' - want '%d'", n, 0)
+	}
+
+	if err := setEntry(ctx, store, "my-key", value); err != nil {
+		t.Fatalf("Failed to set entry: %v", err)
+	}
+	values, err := getBulk(ctx, store, []string{"my-key", "small-key"})
+	if err != nil {
+		t.Fatalf("Failed to get entries: %v", err)
+	}
+	if !bytes.Equal(values["my-key"], value) || !bytes.Equal(values["small-key"], small) {
+		t.Fatalf("Invalid values: got '%s'", values)
+	}
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete entry: %v", err)
+	}
+	if n := countChunks(ctx, t, backend); n != 0 {
+		t.Fatalf("Invalid number of chunks: got '%d' - want '%d'", n, 0)
+	}
+}
+
+func TestChunkedStoreCorrupted(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	backend := &MemKeyStore{}
+	store := newChunkedStore(backend, &ChunkingConfig{Size: 16})
+
+	value := []byte("my-secret-value-that-is-larger-than-the-chunk-size")
+	if err := store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	names, _, err := backend.List(ctx, chunkPrefix, -1)
+	if err != nil || len(names) == 0 {
+		t.Fatalf("Failed to list chunks: %v", err)
+	}
+
+	if err = backend.Set(ctx, names[0], []byte("modified-chunk-0")); err != nil {
+		t.Fatalf("Failed to modify chunk: %v", err)
+	}
+	if _, err = store.Get(ctx, "my-key"); err == nil {
+		t.Fatal("Joined entry with modified chunk")
+	}
+	if err = backend.Delete(ctx, names[0]); err != nil {
+		t.Fatalf("Failed to delete chunk: %v", err)
+	}
+	if _, err = store.Get(ctx, "my-key"); err == nil {
+		t.Fatal("Joined entry with missing chunk")
+	}
+}
+
+func countChunks(ctx context.Context, t *testing.T, store KeyStore) int {
+	names, _, err := store.List(ctx, "", -1)
+	if err != nil {
+		t.Fatalf("Failed to list entries: %v", err)
+	}
+	var n int
+	for _, name := range names {
+		if strings.HasPrefix(name, chunkPrefix) {
+			n++
+		}
+	}
+	return n
+}
@@ -29,22 +29,35 @@ func complete(cmd string) bool {
 	}
 
 	completion := map[string][]string{
-		cmd:             {"server", "key", "policy", "identity", "log", "status", "metric", "update"},
-		cmd + " server": {"--config", "--addr", "--auth"},
-		cmd + " log":    {"--audit", "--error", "--json", "--insecure"},
-		cmd + " status": {"--short", "--api", "--json", "--color", "--insecure"},
-		cmd + " metric": {"--rate", "--insecure"},
-		cmd + " update": {"--downgrade", "--output", "--os", "--arch", "--minisign-key", "--insecure"},
-
-		cmd + " key":         {"create", "import", "info", "ls", "rm", "encrypt", "decrypt", "dek"},
+		cmd:                  {"server", "key", "policy", "identity", "conn", "report", "job", "lock", "db", "ssh", "pki", "merkle", "login", "maintenance", "promote", "shim", "log", "status", "metric", "update"},
+		cmd + " server":      {"--config", "--addr", "--auth"},
+		cmd + " log":         {"--audit", "--error", "--json", "--insecure"},
+		cmd + " status":      {"--short", "--api", "--json", "--color", "--insecure"},
+		cmd + " metric":      {"--rate", "--insecure"},
+		cmd + " maintenance": {"on", "off", "--reason", "--insecure"},
+		cmd + " promote":     {"--insecure"},
+		cmd + " shim":        {"--addr", "--key-ttl", "--key-uses", "--cache-size", "--insecure"},
+		cmd + " login":       {"--register", "--port", "--insecure"},
+		cmd + " conn":        {"ls", "close"},
+		cmd + " conn ls":     {"--insecure", "--json", "--color"},
+		cmd + " conn close":  {"--insecure"},
+		cmd + " update":      {"--downgrade", "--output", "--os", "--arch", "--minisign-key", "--insecure"},
+
+		cmd + " key":         {"create", "import", "info", "ls", "rm", "encrypt", "decrypt", "dek", "seal", "open", "tokenize", "detokenize", "reveal"},
 		cmd + " key create":  {"--insecure"},
 		cmd + " key import":  {"--insecure"},
 		cmd + " key info":    {"--insecure", "--json", "--color"},
-		cmd + " key ls":      {"--insecure", "--json", "--color"},
+		cmd + " key ls":      {"--insecure", "--json", "--color", "--match", "--created-before", "--created-after", "--algorithm"},
 		cmd + " key rm":      {"--insecure"},
 		cmd + " key encrypt": {"--insecure"},
 		cmd + " key decrypt": {"--insecure"},
 		cmd + " key dek":     {"--insecure"},
+		cmd + " key seal":    {"--context", "--insecure"},
+		cmd + " key open":    {"--context", "--insecure"},
+
+		cmd + " key tokenize":   {"--tweak", "--insecure"},
+		cmd + " key detokenize": {"--tweak", "--insecure"},
+		cmd + " key reveal":     {"--tweak", "--insecure"},
 
 		cmd + " policy":      {"info", "ls", "rm", "show"},
 		cmd + " policy info": {"--insecure", "--json", "--color"},
@@ -58,6 +71,41 @@ func complete(cmd string) bool {
 		cmd + " identity info": {"--insecure", "--json", "--color"},
 		cmd + " identity ls":   {"--insecure", "--json", "--color"},
 		cmd + " identity rm":   {"--insecure"},
+
+		cmd + " report":            {"keys", "identities", "inventory"},
+		cmd + " report keys":       {"--days", "--insecure", "--json", "--color"},
+		cmd + " report identities": {"--days", "--insecure", "--json", "--color"},
+		cmd + " report inventory":  {"--csv", "--json", "--output", "--insecure", "--color"},
+
+		cmd + " job":        {"start", "ls", "info", "cancel"},
+		cmd + " job start":  {"--insecure", "--json"},
+		cmd + " job ls":     {"--insecure", "--json", "--color"},
+		cmd + " job info":   {"--insecure", "--json", "--color"},
+		cmd + " job cancel": {"--insecure"},
+
+		cmd + " lock":         {"acquire", "renew", "release", "info"},
+		cmd + " lock acquire": {"--ttl", "--insecure", "--json"},
+		cmd + " lock renew":   {"--ttl", "--insecure", "--json"},
+		cmd + " lock release": {"--insecure"},
+		cmd + " lock info":    {"--insecure", "--json"},
+
+		cmd + " db":        {"creds", "revoke"},
+		cmd + " db creds":  {"--ttl", "--insecure", "--json"},
+		cmd + " db revoke": {"--insecure"},
+
+		cmd + " ssh":      {"ca", "sign"},
+		cmd + " ssh ca":   {"--insecure"},
+		cmd + " ssh sign": {"--principal", "--ttl", "--output", "--insecure"},
+
+		cmd + " pki":        {"ca", "issue", "revoke", "import"},
+		cmd + " pki ca":     {"--insecure"},
+		cmd + " pki issue":  {"--dns", "--ip", "--ttl", "--csr", "--output", "--insecure"},
+		cmd + " pki revoke": {"--insecure"},
+		cmd + " pki import": {"--insecure"},
+
+		cmd + " merkle":       {"root", "proof"},
+		cmd + " merkle root":  {"--at", "--insecure", "--json"},
+		cmd + " merkle proof": {"--at", "--insecure", "--json"},
 	}
 
 	fields := strings.Fields(line)
@@ -0,0 +1,245 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	tui "github.com/charmbracelet/lipgloss"
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/cli"
+	"github.com/minio/kes/kesconf"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// checkTimeout is the time after which a single
+// startup check gets aborted.
+const checkTimeout = 30 * time.Second
+
+// certExpiryWarning is the remaining validity period below
+// which the server certificate check reports a warning.
+const certExpiryWarning = 30 * 24 * time.Hour
+
+// Results of startup checks.
+const (
+	checkOK      = "ok"
+	checkWarn    = "warn"
+	checkFailed  = "failed"
+	checkSkipped = "skipped"
+)
+
+// checkResult is the result of a single startup check.
+type checkResult struct {
+	Name     string
+	Result   string
+	Detail   string
+	Duration time.Duration
+}
+
+// checkServer performs a dry-run of the server startup. It loads
+// the config file, validates the TLS material, verifies that the
+// server can listen on its address and exercises the keystore by
+// creating, reading and deleting a canary entry.
+//
+// It prints a report of all checks and returns an error if any
+// check failed. The server itself is never started.
+func checkServer(addrFlag, configFlag string, verify *integrity) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var (
+		results []checkResult
+		failed  = map[string]bool{}
+	)
+	// run runs the check f unless one of the checks
+	// it requires failed or has been skipped.
+	run := func(name string, f func(ctx context.Context) (string, string), requires ...string) {
+		for _, r := range requires {
+			if failed[r] {
+				results = append(results, checkResult{Name: name, Result: checkSkipped, Detail: "requires " + r})
+				failed[name] = true
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+		defer cancel()
+
+		start := time.Now()
+		result, detail := f(ctx)
+		results = append(results, checkResult{
+			Name:     name,
+			Result:   result,
+			Detail:   detail,
+			Duration: time.Since(start),
+		})
+		failed[name] = result == checkFailed
+	}
+
+	var (
+		file  *kesconf.File
+		store kes.KeyStore
+	)
+	run("config", func(context.Context) (string, string) {
+		var err error
+		if file, err = verify.ReadConfig(configFlag); err != nil {
+			return checkFailed, err.Error()
+		}
+		if file.KeyStore == nil {
+			return checkFailed, "no keystore specified"
+		}
+		if verify.key != nil {
+			return checkOK, fmt.Sprintf("loaded '%s' with valid signature", configFlag)
+		}
+		return checkOK, fmt.Sprintf("loaded '%s'", configFlag)
+	})
+	run("tls", func(context.Context) (string, string) { return checkTLS(file) }, "config")
+	run("listen", func(context.Context) (string, string) {
+		switch {
+		case addrFlag != "":
+		case file.Addr != "":
+			addrFlag = file.Addr
+		default:
+			addrFlag = "0.0.0.0:7373"
+		}
+		ln, err := net.Listen("tcp", addrFlag)
+		if err != nil {
+			return checkFailed, err.Error()
+		}
+		ln.Close()
+		return checkOK, fmt.Sprintf("address '%s' is available", addrFlag)
+	}, "config")
+	run("keystore", func(ctx context.Context) (string, string) {
+		var err error
+		if store, err = file.KeyStore.Connect(ctx); err != nil {
+			return checkFailed, err.Error()
+		}
+		state, err := store.Status(ctx)
+		if err != nil {
+			return checkFailed, fmt.Sprintf("%v: %v", store, err)
+		}
+		return checkOK, fmt.Sprintf("connected to %v (latency %v)", store, state.Latency.Round(time.Millisecond))
+	}, "config")
+	if store != nil {
+		defer store.Close()
+	}
+	run("canary", func(ctx context.Context) (string, string) { return checkCanary(ctx, store) }, "keystore")
+
+	printCheckReport(results)
+	for _, r := range results {
+		if r.Result == checkFailed {
+			return errors.New("startup check failed")
+		}
+	}
+	return nil
+}
+
+// checkTLS validates the server's TLS certificate, private key and
+// CA certificates and reports how long the certificate is valid.
+func checkTLS(file *kesconf.File) (string, string) {
+	if file.TLS == nil {
+		return checkFailed, "no TLS certificate specified"
+	}
+	conf, err := file.TLSConfig()
+	if err != nil {
+		return checkFailed, err.Error()
+	}
+
+	cert := conf.Certificates[0].Leaf
+	if cert == nil {
+		if cert, err = x509.ParseCertificate(conf.Certificates[0].Certificate[0]); err != nil {
+			return checkFailed, fmt.Sprintf("invalid TLS certificate: %v", err)
+		}
+	}
+
+	now := time.Now()
+	switch {
+	case now.Before(cert.NotBefore):
+		return checkFailed, fmt.Sprintf("certificate '%s' is not valid before %s", cert.Subject.CommonName, cert.NotBefore.Format(time.RFC3339))
+	case now.After(cert.NotAfter):
+		return checkFailed, fmt.Sprintf("certificate '%s' expired at %s", cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+	case cert.NotAfter.Sub(now) < certExpiryWarning:
+		return checkWarn, fmt.Sprintf("certificate '%s' expires in %.f days", cert.Subject.CommonName, cert.NotAfter.Sub(now).Hours()/24)
+	}
+	return checkOK, fmt.Sprintf("certificate '%s' is valid until %s", cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+}
+
+// checkCanary creates, reads and deletes a randomly named canary
+// entry at the keystore. It tries to delete the canary even if
+// reading it fails.
+func checkCanary(ctx context.Context, store kes.KeyStore) (string, string) {
+	var random [16]byte
+	rand.Read(random[:])
+	name, value := "kes-check-"+hex.EncodeToString(random[:8]), random[8:]
+
+	if err := store.Create(ctx, name, value); err != nil {
+		return checkFailed, fmt.Sprintf("failed to create canary '%s': %v", name, err)
+	}
+
+	b, err := store.Get(ctx, name)
+	if err == nil && !bytes.Equal(b, value) {
+		err = errors.New("value mismatch")
+	}
+	if err != nil {
+		if dErr := store.Delete(ctx, name); dErr != nil {
+			return checkFailed, fmt.Sprintf("failed to read canary '%s': %v. Failed to delete canary: %v", name, err, dErr)
+		}
+		return checkFailed, fmt.Sprintf("failed to read canary '%s': %v", name, err)
+	}
+
+	if err = store.Delete(ctx, name); err != nil {
+		return checkFailed, fmt.Sprintf("failed to delete canary '%s': %v", name, err)
+	}
+	if _, err = store.Get(ctx, name); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		return checkFailed, fmt.Sprintf("canary '%s' still exists after deletion: %v", name, err)
+	}
+	return checkOK, fmt.Sprintf("created, read and deleted '%s'", name)
+}
+
+// printCheckReport prints the results of all startup checks.
+func printCheckReport(results []checkResult) {
+	var (
+		faint  = tui.NewStyle().Faint(true)
+		green  = tui.NewStyle().Foreground(tui.Color("#00f700"))
+		yellow = tui.NewStyle().Foreground(tui.Color("#d7af00"))
+		red    = tui.NewStyle().Foreground(tui.Color("#ac0000"))
+	)
+	if !cli.IsTerminal() {
+		faint, green, yellow, red = tui.NewStyle(), tui.NewStyle(), tui.NewStyle(), tui.NewStyle()
+	}
+
+	for _, r := range results {
+		style := faint
+		switch r.Result {
+		case checkOK:
+			style = green
+		case checkWarn:
+			style = yellow
+		case checkFailed:
+			style = red
+		}
+		if r.Result == checkSkipped {
+			fmt.Fprintf(os.Stdout, "%s %-9s %s\n", style.Render(fmt.Sprintf("%-7s", r.Result)), r.Name, faint.Render(r.Detail))
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "%s %-9s %s %s\n",
+			style.Render(fmt.Sprintf("%-7s", r.Result)),
+			r.Name,
+			r.Detail,
+			faint.Render(r.Duration.Round(time.Millisecond).String()),
+		)
+	}
+}
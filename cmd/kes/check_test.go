@@ -0,0 +1,153 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/kesconf"
+)
+
+func TestCheckTLS(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i, test := range []struct {
+		NotBefore time.Time
+		NotAfter  time.Time
+		Result    string
+	}{
+		{ // 0: valid
+			NotBefore: now.Add(-time.Hour),
+			NotAfter:  now.Add(365 * 24 * time.Hour),
+			Result:    checkOK,
+		},
+		{ // 1: expires within the warning period
+			NotBefore: now.Add(-time.Hour),
+			NotAfter:  now.Add(7 * 24 * time.Hour),
+			Result:    checkWarn,
+		},
+		{ // 2: expired
+			NotBefore: now.Add(-48 * time.Hour),
+			NotAfter:  now.Add(-24 * time.Hour),
+			Result:    checkFailed,
+		},
+		{ // 3: not yet valid
+			NotBefore: now.Add(24 * time.Hour),
+			NotAfter:  now.Add(365 * 24 * time.Hour),
+			Result:    checkFailed,
+		},
+	} {
+		keyFile, certFile := writeServerCertificate(t, dir, test.NotBefore, test.NotAfter)
+		file := &kesconf.File{
+			TLS: &kesconf.TLSConfig{PrivateKey: keyFile, Certificate: certFile},
+		}
+		if result, detail := checkTLS(file); result != test.Result {
+			t.Fatalf("Test %d: got result '%s' - want '%s': %s", i, result, test.Result, detail)
+		}
+	}
+
+	if result, _ := checkTLS(&kesconf.File{}); result != checkFailed {
+		t.Fatalf("Checked TLS without certificate: got result '%s' - want '%s'", result, checkFailed)
+	}
+	missing := &kesconf.File{
+		TLS: &kesconf.TLSConfig{
+			PrivateKey:  filepath.Join(dir, "missing.key"),
+			Certificate: filepath.Join(dir, "missing.cert"),
+		},
+	}
+	if result, _ := checkTLS(missing); result != checkFailed {
+		t.Fatalf("Checked TLS with missing certificate: got result '%s' - want '%s'", result, checkFailed)
+	}
+}
+
+func TestCheckCanary(t *testing.T) {
+	ctx := context.Background()
+	for i, test := range []struct {
+		Store  kes.KeyStore
+		Result string
+	}{
+		{Store: &kes.MemKeyStore{}, Result: checkOK},         // 0
+		{Store: &corruptKeyStore{}, Result: checkFailed},     // 1
+		{Store: &undeletableKeyStore{}, Result: checkFailed}, // 2
+	} {
+		if result, detail := checkCanary(ctx, test.Store); result != test.Result {
+			t.Fatalf("Test %d: got result '%s' - want '%s': %s", i, result, test.Result, detail)
+		}
+	}
+
+	// The canary is removed even if reading it fails.
+	store := &corruptKeyStore{}
+	checkCanary(ctx, store)
+	if names, _, _ := store.List(ctx, "", -1); len(names) != 0 {
+		t.Fatalf("Canary has not been removed: '%v'", names)
+	}
+}
+
+// writeServerCertificate writes a new self-signed server
+// certificate, valid from notBefore until notAfter, and its
+// private key to dir. It returns the key and certificate path.
+func writeServerCertificate(t *testing.T, dir string, notBefore, notAfter time.Time) (string, string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		t.Fatalf("Failed to generate serial number: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("Failed to encode private key: %v", err)
+	}
+
+	name := serial.Text(16)
+	keyFile := filepath.Join(dir, name+".key")
+	certFile := filepath.Join(dir, name+".cert")
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}))
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	return keyFile, certFile
+}
+
+// corruptKeyStore is a KeyStore that returns
+// values different from the stored ones.
+type corruptKeyStore struct{ kes.MemKeyStore }
+
+func (s *corruptKeyStore) Get(ctx context.Context, name string) ([]byte, error) {
+	b, err := s.MemKeyStore.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, 0), nil
+}
+
+// undeletableKeyStore is a KeyStore that reports
+// entries as deleted without removing them.
+type undeletableKeyStore struct{ kes.MemKeyStore }
+
+func (s *undeletableKeyStore) Delete(context.Context, string) error { return nil }
@@ -0,0 +1,205 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+
+	tui "github.com/charmbracelet/lipgloss"
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/cli"
+	flag "github.com/spf13/pflag"
+)
+
+const connCmdUsage = `Usage:
+    kes conn <command>
+
+Commands:
+    ls                       List open client connections.
+    close                    Close all connections of an identity.
+
+Options:
+    -h, --help               Print command line options.
+`
+
+func connCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, connCmdUsage) }
+
+	subCmds := commands{
+		"ls":    lsConnCmd,
+		"close": closeConnCmd,
+	}
+
+	if len(args) < 2 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if cmd, ok := subCmds[args[1]]; ok {
+		cmd(args[1:])
+		return
+	}
+
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes conn --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatalf("%q is not a conn command. See 'kes conn --help'", cmd.Arg(0))
+	}
+	cmd.Usage()
+	os.Exit(2)
+}
+
+const lsConnCmdUsage = `Usage:
+    kes conn ls [options]
+
+List the open client connections and the identities that sent
+requests over them. Requires the admin identity.
+
+Options:
+    -k, --insecure           Skip TLS certificate validation.
+        --json               Print connections in JSON format.
+        --color <when>       Specify when to use colored output. The automatic
+                             mode only enables colors if an interactive terminal
+                             is detected - colors are automatically disabled if
+                             the output goes to a pipe.
+                             Possible values: *auto*, never, always.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes conn ls
+`
+
+func lsConnCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, lsConnCmdUsage) }
+
+	var (
+		jsonFlag           bool
+		colorFlag          colorOption
+		insecureSkipVerify bool
+	)
+	cmd.BoolVar(&jsonFlag, "json", false, "Print connections in JSON format")
+	cmd.Var(&colorFlag, "color", "Specify when to use colored output")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes conn ls --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatal("too many arguments. See 'kes conn ls --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var list api.ListConnsResponse
+	if err := send(ctx, client, http.MethodGet, api.PathConnList, nil, &list); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to list connections: %v", err)
+	}
+	if jsonFlag {
+		if err := json.NewEncoder(os.Stdout).Encode(list); err != nil {
+			cli.Fatalf("failed to list connections: %v", err)
+		}
+		return
+	}
+	if len(list.Conns) == 0 {
+		return
+	}
+
+	var (
+		style = tui.NewStyle().Underline(colorFlag.Colorize())
+		faint = tui.NewStyle().Faint(colorFlag.Colorize())
+		buf   = &strings.Builder{}
+	)
+	fmt.Fprintf(buf, "%s %s %s %s\n", style.Render(fmt.Sprintf("%-22s", "Address")), style.Render(fmt.Sprintf("%-10s", "Age")), style.Render(fmt.Sprintf("%-8s", "Requests")), style.Render("Identity"))
+	for _, c := range list.Conns {
+		if len(c.Identities) == 0 {
+			fmt.Fprintf(buf, "%-22s %-10s %-8d %s\n", c.Addr, c.Age, c.Requests, faint.Render("<none>"))
+			continue
+		}
+		for i, id := range c.Identities {
+			if i == 0 {
+				fmt.Fprintf(buf, "%-22s %-10s %-8d %s\n", c.Addr, c.Age, id.Requests, id.Identity)
+			} else {
+				fmt.Fprintf(buf, "%-22s %-10s %-8d %s\n", "", "", id.Requests, id.Identity)
+			}
+		}
+	}
+	fmt.Print(buf)
+}
+
+const closeConnCmdUsage = `Usage:
+    kes conn close [options] <identity>
+
+Close all open connections over which the identity sent requests,
+e.g. during incident response. The client may reconnect unless its
+identity is removed from its policy. Requires the admin identity.
+
+Options:
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes conn close 736bf58626441e3e134a2daf2e6a0441b40e1abc0eac510c0a7f3ad33d4f7e23
+`
+
+func closeConnCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, closeConnCmdUsage) }
+
+	var insecureSkipVerify bool
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes conn close --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no identity specified. See 'kes conn close --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes conn close --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var resp api.CloseConnsResponse
+	if err := send(ctx, client, http.MethodDelete, api.PathConnClose+url.PathEscape(cmd.Arg(0)), nil, &resp); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to close connections: %v", err)
+	}
+	fmt.Printf("Closed %d connections\n", resp.Closed)
+}
@@ -0,0 +1,179 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/cli"
+	flag "github.com/spf13/pflag"
+)
+
+const dbCmdUsage = `Usage:
+    kes db <command>
+
+Commands:
+    creds                    Issue short-lived database credentials.
+    revoke                   Revoke database credentials.
+
+Options:
+    -h, --help               Print command line options.
+`
+
+func dbCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, dbCmdUsage) }
+
+	subCmds := commands{
+		"creds":  credsDBCmd,
+		"revoke": revokeDBCmd,
+	}
+
+	if len(args) < 2 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if cmd, ok := subCmds[args[1]]; ok {
+		cmd(args[1:])
+		return
+	}
+
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes db --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatalf("%q is not a db command. See 'kes db --help'", cmd.Arg(0))
+	}
+	cmd.Usage()
+	os.Exit(2)
+}
+
+const credsDBCmdUsage = `Usage:
+    kes db creds [options] <database>
+
+Issue a new database user. The user gets revoked automatically
+once its lease expires.
+
+Options:
+        --ttl <duration>     Duration until the lease expires.
+    -k, --insecure           Skip TLS certificate validation.
+        --json               Print the credentials in JSON format.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes db creds --ttl 15m my-db
+`
+
+func credsDBCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, credsDBCmdUsage) }
+
+	var (
+		ttl                string
+		jsonFlag           bool
+		insecureSkipVerify bool
+	)
+	cmd.StringVar(&ttl, "ttl", "", "Duration until the lease expires")
+	cmd.BoolVar(&jsonFlag, "json", false, "Print the credentials in JSON format")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes db creds --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no database specified. See 'kes db creds --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes db creds --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var creds api.DBCredentialsResponse
+	if err := send(ctx, client, http.MethodPut, api.PathDBCredentials+url.PathEscape(cmd.Arg(0)), api.DBCredentialsRequest{TTL: ttl}, &creds); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to issue database credentials: %v", err)
+	}
+	if jsonFlag {
+		if err := json.NewEncoder(os.Stdout).Encode(creds); err != nil {
+			cli.Fatalf("failed to issue database credentials: %v", err)
+		}
+		return
+	}
+
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "%-9s %s\n", "Username", creds.Username)
+	fmt.Fprintf(buf, "%-9s %s\n", "Password", creds.Password)
+	fmt.Fprintf(buf, "%-9s %s\n", "Lease", creds.LeaseID)
+	fmt.Fprintf(buf, "%-9s %s\n", "Expires", formatTime(creds.ExpiresAt))
+	fmt.Print(buf)
+}
+
+const revokeDBCmdUsage = `Usage:
+    kes db revoke [options] <lease>
+
+Options:
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes db revoke 5f4dcc3b5aa765d61d8327deb882cf99
+`
+
+func revokeDBCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, revokeDBCmdUsage) }
+
+	var insecureSkipVerify bool
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes db revoke --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no lease specified. See 'kes db revoke --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes db revoke --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+	if err := send(ctx, client, http.MethodPut, api.PathDBRevoke+url.PathEscape(cmd.Arg(0)), nil, nil); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to revoke database credentials: %v", err)
+	}
+}
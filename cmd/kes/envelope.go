@@ -0,0 +1,164 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/cli"
+	flag "github.com/spf13/pflag"
+)
+
+const sealKeyCmdUsage = `Usage:
+    kes key seal [options] <name> [<file>]
+
+Encrypt the file, or standard input, with a new data encryption
+key and print a self-describing envelope in JSON format.
+
+Options:
+        --context <value>    Associated data bound to the envelope.
+                             The same value must be used to open it.
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes key seal my-key secret.txt > secret.json
+`
+
+func sealKeyCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, sealKeyCmdUsage) }
+
+	var (
+		associatedData     string
+		insecureSkipVerify bool
+	)
+	cmd.StringVar(&associatedData, "context", "", "Associated data bound to the envelope")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes key seal --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no key name specified. See 'kes key seal --help'")
+	case cmd.NArg() > 2:
+		cli.Fatal("too many arguments. See 'kes key seal --help'")
+	}
+
+	plaintext, err := readInput(cmd.Arg(1))
+	if err != nil {
+		cli.Fatalf("failed to read plaintext: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancel()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var envelope api.Envelope
+	body := api.SealEnvelopeRequest{
+		Plaintext: plaintext,
+		Context:   []byte(associatedData),
+	}
+	if err = send(ctx, client, http.MethodPut, api.PathKeySeal+url.PathEscape(cmd.Arg(0)), body, &envelope); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to seal envelope: %v", err)
+	}
+	if err = json.NewEncoder(os.Stdout).Encode(envelope); err != nil {
+		cli.Fatalf("failed to seal envelope: %v", err)
+	}
+}
+
+const openKeyCmdUsage = `Usage:
+    kes key open [options] [<file>]
+
+Decrypt an envelope, read from the file or standard input, and
+print the plaintext. The envelope names the key it was sealed with.
+
+Options:
+        --context <value>    Associated data bound to the envelope.
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes key open secret.json > secret.txt
+`
+
+func openKeyCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, openKeyCmdUsage) }
+
+	var (
+		associatedData     string
+		insecureSkipVerify bool
+	)
+	cmd.StringVar(&associatedData, "context", "", "Associated data bound to the envelope")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes key open --help'", err)
+	}
+	if cmd.NArg() > 1 {
+		cli.Fatal("too many arguments. See 'kes key open --help'")
+	}
+
+	b, err := readInput(cmd.Arg(0))
+	if err != nil {
+		cli.Fatalf("failed to read envelope: %v", err)
+	}
+	var envelope api.Envelope
+	if err = json.Unmarshal(b, &envelope); err != nil {
+		cli.Fatalf("invalid envelope: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancel()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var response api.OpenEnvelopeResponse
+	body := api.OpenEnvelopeRequest{
+		Envelope: envelope,
+		Context:  []byte(associatedData),
+	}
+	if err = send(ctx, client, http.MethodPut, api.PathKeyOpen+url.PathEscape(envelope.Key), body, &response); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to open envelope: %v", err)
+	}
+	os.Stdout.Write(response.Plaintext)
+}
+
+// readInput reads the named file or, if name is empty
+// or "-", the standard input.
+func readInput(name string) ([]byte, error) {
+	if name == "" || name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
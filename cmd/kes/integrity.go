@@ -0,0 +1,170 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/minio/kes/kesconf"
+)
+
+// pinnedVerifyKey is a base64-encoded Ed25519 public key that
+// can be pinned when building the binary:
+//
+//	go build -ldflags "-X main.pinnedVerifyKey=<base64>"
+//
+// If set, the server only starts with a config file signed
+// by the corresponding private key.
+var pinnedVerifyKey string
+
+// integrity verifies Ed25519 signatures over the server config
+// file and the server binary. Signatures are computed over the
+// raw file content, for example with:
+//
+//	openssl pkeyutl -sign -rawin -inkey key.pem -in config.yml | base64 > config.yml.sig
+type integrity struct {
+	key       ed25519.PublicKey // If nil, signatures are not verified
+	configSig string            // Path to the config signature. If empty, "<config>.sig"
+}
+
+// newIntegrity returns a new integrity that verifies signatures
+// with the pinned key or the PEM-encoded public key in keyFile.
+//
+// If verifyBinary is true, it verifies the signature of the
+// running binary, stored at "<executable>.sig", and returns an
+// error if the binary has been modified.
+func newIntegrity(keyFile, configSig string, verifyBinary bool) (*integrity, error) {
+	var key ed25519.PublicKey
+	if pinnedVerifyKey != "" {
+		b, err := base64.StdEncoding.DecodeString(pinnedVerifyKey)
+		if err != nil || len(b) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid pinned verification key: not a base64-encoded Ed25519 public key")
+		}
+		key = b
+	}
+	if keyFile != "" {
+		pub, err := readVerifyKey(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key != nil && !key.Equal(pub) {
+			return nil, fmt.Errorf("verification key '%s' does not match the key pinned in the binary", keyFile)
+		}
+		key = pub
+	}
+	if key == nil && (configSig != "" || verifyBinary) {
+		return nil, errors.New("no verification key specified. Use '--verify-key'")
+	}
+
+	v := &integrity{
+		key:       key,
+		configSig: configSig,
+	}
+	if verifyBinary {
+		if err := v.VerifyBinary(); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// ReadConfig reads and parses the config file. If a verification
+// key is present, it returns an error if the config file does not
+// match its signature.
+//
+// The config file is read once such that it cannot be modified
+// between verifying and parsing it.
+func (v *integrity) ReadConfig(filename string) (*kesconf.File, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if v.key != nil {
+		sigFile := v.configSig
+		if sigFile == "" {
+			sigFile = filename + ".sig"
+		}
+		if err = v.verify(b, sigFile); err != nil {
+			return nil, fmt.Errorf("config file '%s' failed integrity check: %v", filename, err)
+		}
+	}
+	return kesconf.ReadFrom(bytes.NewReader(b))
+}
+
+// VerifyBinary returns an error if the running binary does
+// not match its signature stored at "<executable>.sig".
+//
+// It detects a modified binary on the host. However, it cannot
+// detect a binary that has been modified to skip this check.
+func (v *integrity) VerifyBinary() error {
+	filename, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate server binary: %v", err)
+	}
+	if filename, err = filepath.EvalSymlinks(filename); err != nil {
+		return fmt.Errorf("failed to locate server binary: %v", err)
+	}
+	return v.verifyBinary(filename)
+}
+
+// verifyBinary returns an error if the binary at filename does
+// not match its signature stored at "<filename>.sig".
+func (v *integrity) verifyBinary(filename string) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read server binary: %v", err)
+	}
+	if err = v.verify(b, filename+".sig"); err != nil {
+		return fmt.Errorf("server binary '%s' failed integrity check: %v", filename, err)
+	}
+	return nil
+}
+
+// verify verifies the signature of data stored at sigFile.
+// The signature is either raw or base64-encoded.
+func (v *integrity) verify(data []byte, sigFile string) error {
+	sig, err := os.ReadFile(sigFile)
+	if err != nil {
+		return fmt.Errorf("failed to read signature: %v", err)
+	}
+	if len(sig) != ed25519.SignatureSize {
+		if sig, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig))); err != nil {
+			return fmt.Errorf("invalid signature '%s': %v", sigFile, err)
+		}
+	}
+	if !ed25519.Verify(v.key, data, sig) {
+		return fmt.Errorf("signature '%s' does not match", sigFile)
+	}
+	return nil
+}
+
+// readVerifyKey reads a PEM-encoded Ed25519 public key from filename.
+func readVerifyKey(filename string) (ed25519.PublicKey, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("'%s' contains no PEM-encoded public key", filename)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid verification key '%s': %v", filename, err)
+	}
+	key, ok := pub.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid verification key '%s': not an Ed25519 public key", filename)
+	}
+	return key, nil
+}
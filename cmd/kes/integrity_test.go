@@ -0,0 +1,232 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const integrityConfig = `version: v1
+address: 0.0.0.0:7373
+admin:
+  identity: c84cc9b91ae2399b043da7eca616048d4b4200edf2ff418d8af3835911db945d
+tls:
+  key:  ./server.key
+  cert: ./server.cert
+keystore:
+  fs:
+    path: /tmp/keys
+`
+
+func TestIntegrityReadConfig(t *testing.T) {
+	dir := t.TempDir()
+	pub, priv := generateVerifyKey(t)
+	keyFile := writeVerifyKey(t, dir, pub)
+
+	config := filepath.Join(dir, "config.yml")
+	writeFile(t, config, []byte(integrityConfig))
+
+	for i, test := range []struct {
+		Config     []byte // Config file content at the time of reading
+		Signature  []byte // Content of the signature file. If nil, no signature
+		ShouldFail bool
+	}{
+		{ // 0: valid base64-encoded signature
+			Signature: []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(integrityConfig))) + "\n"),
+		},
+		{ // 1: valid raw signature
+			Signature: ed25519.Sign(priv, []byte(integrityConfig)),
+		},
+		{ // 2: tampered config
+			Config:     []byte(integrityConfig + "log:\n  error: off\n"),
+			Signature:  ed25519.Sign(priv, []byte(integrityConfig)),
+			ShouldFail: true,
+		},
+		{ // 3: missing signature
+			ShouldFail: true,
+		},
+		{ // 4: malformed signature
+			Signature:  []byte("not a signature"),
+			ShouldFail: true,
+		},
+	} {
+		os.Remove(config + ".sig")
+		if test.Signature != nil {
+			writeFile(t, config+".sig", test.Signature)
+		}
+		content := []byte(integrityConfig)
+		if test.Config != nil {
+			content = test.Config
+		}
+		writeFile(t, config, content)
+
+		verify, err := newIntegrity(keyFile, "", false)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create integrity: %v", i, err)
+		}
+		_, err = verify.ReadConfig(config)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: read config without valid signature", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to read config: %v", i, err)
+		}
+	}
+}
+
+func TestIntegrityWrongKey(t *testing.T) {
+	dir := t.TempDir()
+	_, priv := generateVerifyKey(t)
+	other, _ := generateVerifyKey(t)
+
+	config := filepath.Join(dir, "config.yml")
+	writeFile(t, config, []byte(integrityConfig))
+	writeFile(t, config+".sig", ed25519.Sign(priv, []byte(integrityConfig)))
+
+	verify, err := newIntegrity(writeVerifyKey(t, dir, other), "", false)
+	if err != nil {
+		t.Fatalf("Failed to create integrity: %v", err)
+	}
+	if _, err = verify.ReadConfig(config); err == nil {
+		t.Fatal("Read config signed by another key")
+	}
+}
+
+func TestIntegrityConfigSignature(t *testing.T) {
+	dir := t.TempDir()
+	pub, priv := generateVerifyKey(t)
+	keyFile := writeVerifyKey(t, dir, pub)
+
+	config := filepath.Join(dir, "config.yml")
+	sigFile := filepath.Join(dir, "signature")
+	writeFile(t, config, []byte(integrityConfig))
+	writeFile(t, sigFile, ed25519.Sign(priv, []byte(integrityConfig)))
+
+	verify, err := newIntegrity(keyFile, sigFile, false)
+	if err != nil {
+		t.Fatalf("Failed to create integrity: %v", err)
+	}
+	if _, err = verify.ReadConfig(config); err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+
+	// A signature file requires a verification key.
+	if _, err = newIntegrity("", sigFile, false); err == nil {
+		t.Fatal("Created integrity with signature file but no verification key")
+	}
+
+	// Without a verification key, signatures are not verified.
+	if verify, err = newIntegrity("", "", false); err != nil {
+		t.Fatalf("Failed to create integrity: %v", err)
+	}
+	os.Remove(sigFile)
+	if _, err = verify.ReadConfig(config); err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+}
+
+func TestIntegrityVerifyBinary(t *testing.T) {
+	dir := t.TempDir()
+	pub, priv := generateVerifyKey(t)
+	verify, err := newIntegrity(writeVerifyKey(t, dir, pub), "", false)
+	if err != nil {
+		t.Fatalf("Failed to create integrity: %v", err)
+	}
+
+	binary := []byte("\x7fELF server binary")
+	filename := filepath.Join(dir, "kes")
+	writeFile(t, filename, binary)
+
+	if err = verify.verifyBinary(filename); err == nil {
+		t.Fatal("Verified binary without signature")
+	}
+
+	writeFile(t, filename+".sig", []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, binary))))
+	if err = verify.verifyBinary(filename); err != nil {
+		t.Fatalf("Failed to verify binary: %v", err)
+	}
+
+	writeFile(t, filename, append(binary, 0x90))
+	if err = verify.verifyBinary(filename); err == nil {
+		t.Fatal("Verified tampered binary")
+	}
+}
+
+func TestIntegrityPinnedKey(t *testing.T) {
+	dir := t.TempDir()
+	pinned, priv := generateVerifyKey(t)
+	other, _ := generateVerifyKey(t)
+
+	defer func(key string) { pinnedVerifyKey = key }(pinnedVerifyKey)
+	pinnedVerifyKey = base64.StdEncoding.EncodeToString(pinned)
+
+	// A key that does not match the pinned key is rejected.
+	if _, err := newIntegrity(writeVerifyKey(t, dir, other), "", false); err == nil {
+		t.Fatal("Created integrity with key not matching the pinned key")
+	}
+	if _, err := newIntegrity(writeVerifyKey(t, dir, pinned), "", false); err != nil {
+		t.Fatalf("Failed to create integrity with pinned key: %v", err)
+	}
+
+	// The pinned key requires a signed config even
+	// without a verification key.
+	verify, err := newIntegrity("", "", false)
+	if err != nil {
+		t.Fatalf("Failed to create integrity: %v", err)
+	}
+	config := filepath.Join(dir, "config.yml")
+	writeFile(t, config, []byte(integrityConfig))
+	if _, err = verify.ReadConfig(config); err == nil {
+		t.Fatal("Read config without signature")
+	}
+	writeFile(t, config+".sig", ed25519.Sign(priv, []byte(integrityConfig)))
+	if _, err = verify.ReadConfig(config); err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+
+	pinnedVerifyKey = "invalid"
+	if _, err = newIntegrity("", "", false); err == nil {
+		t.Fatal("Created integrity with invalid pinned key")
+	}
+}
+
+func generateVerifyKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return pub, priv
+}
+
+// writeVerifyKey writes the PEM-encoded public key to a
+// new file in dir and returns its path.
+func writeVerifyKey(t *testing.T, dir string, key ed25519.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("Failed to encode public key: %v", err)
+	}
+	f, err := os.CreateTemp(dir, "verify-*.pem")
+	if err != nil {
+		t.Fatalf("Failed to create key file: %v", err)
+	}
+	defer f.Close()
+	if err = pem.Encode(f, &pem.Block{Type: "PUBLIC KEY", Bytes: der}); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	return f.Name()
+}
+
+func writeFile(t *testing.T, filename string, data []byte) {
+	if err := os.WriteFile(filename, data, 0o600); err != nil {
+		t.Fatalf("Failed to write '%s': %v", filename, err)
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+
+	tui "github.com/charmbracelet/lipgloss"
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/cli"
+	flag "github.com/spf13/pflag"
+)
+
+const jobCmdUsage = `Usage:
+    kes job <command>
+
+Commands:
+    start                    Start a long-running job.
+    ls                       List jobs.
+    info                     Get information about a job.
+    cancel                   Cancel a running job.
+
+Options:
+    -h, --help               Print command line options.
+`
+
+func jobCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, jobCmdUsage) }
+
+	subCmds := commands{
+		"start":  startJobCmd,
+		"ls":     lsJobCmd,
+		"info":   infoJobCmd,
+		"cancel": cancelJobCmd,
+	}
+
+	if len(args) < 2 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if cmd, ok := subCmds[args[1]]; ok {
+		cmd(args[1:])
+		return
+	}
+
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes job --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatalf("%q is not a job command. See 'kes job --help'", cmd.Arg(0))
+	}
+	cmd.Usage()
+	os.Exit(2)
+}
+
+const startJobCmdUsage = `Usage:
+    kes job start [options] <kind>
+
+Jobs:
+    backup                   Copy all keys to the backup key store.
+    cache-warmup             Load all keys from the key store into the cache.
+    migrate                  Copy all keys to the migration key store.
+    rewrap                   Seal and wrap all keys with the current tenant KEKs
+                             and the latest Vault transit key version.
+    scrub                    Read and verify all keys from the key store.
+    split-repair             Re-split all entries of a split key store and
+                             remove orphaned shares.
+
+Options:
+    -k, --insecure           Skip TLS certificate validation.
+        --json               Print the job in JSON format.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes job start scrub
+`
+
+func startJobCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, startJobCmdUsage) }
+
+	var (
+		jsonFlag           bool
+		insecureSkipVerify bool
+	)
+	cmd.BoolVar(&jsonFlag, "json", false, "Print the job in JSON format")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes job start --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no job kind specified. See 'kes job start --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes job start --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var job api.JobResponse
+	if err := send(ctx, client, http.MethodPut, api.PathJobStart+url.PathEscape(cmd.Arg(0)), nil, &job); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to start job: %v", err)
+	}
+	if jsonFlag {
+		if err := json.NewEncoder(os.Stdout).Encode(job); err != nil {
+			cli.Fatalf("failed to start job: %v", err)
+		}
+		return
+	}
+	fmt.Println(job.ID)
+}
+
+const lsJobCmdUsage = `Usage:
+    kes job ls [options]
+
+Options:
+    -k, --insecure           Skip TLS certificate validation.
+        --json               Print jobs in JSON format.
+        --color <when>       Specify when to use colored output. The automatic
+                             mode only enables colors if an interactive terminal
+                             is detected - colors are automatically disabled if
+                             the output goes to a pipe.
+                             Possible values: *auto*, never, always.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes job ls
+`
+
+func lsJobCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, lsJobCmdUsage) }
+
+	var (
+		jsonFlag           bool
+		colorFlag          colorOption
+		insecureSkipVerify bool
+	)
+	cmd.BoolVar(&jsonFlag, "json", false, "Print jobs in JSON format")
+	cmd.Var(&colorFlag, "color", "Specify when to use colored output")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes job ls --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatal("too many arguments. See 'kes job ls --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var list api.ListJobsResponse
+	if err := send(ctx, client, http.MethodGet, api.PathJobList, nil, &list); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to list jobs: %v", err)
+	}
+	if jsonFlag {
+		if err := json.NewEncoder(os.Stdout).Encode(list.Jobs); err != nil {
+			cli.Fatalf("failed to list jobs: %v", err)
+		}
+		return
+	}
+	if len(list.Jobs) == 0 {
+		return
+	}
+
+	var (
+		style = tui.NewStyle().Underline(colorFlag.Colorize())
+		buf   = &strings.Builder{}
+	)
+	fmt.Fprintf(buf, "%s %s %s %s\n", style.Render(fmt.Sprintf("%-32s", "ID")), style.Render(fmt.Sprintf("%-8s", "Kind")), style.Render(fmt.Sprintf("%-10s", "State")), style.Render("Created"))
+	for _, job := range list.Jobs {
+		fmt.Fprintf(buf, "%-32s %-8s %-10s %s\n", job.ID, job.Kind, job.State, formatTime(job.CreatedAt))
+	}
+	fmt.Print(buf)
+}
+
+const infoJobCmdUsage = `Usage:
+    kes job info [options] <id>
+
+Options:
+    -k, --insecure           Skip TLS certificate validation.
+        --json               Print the job in JSON format.
+        --color <when>       Specify when to use colored output. The automatic
+                             mode only enables colors if an interactive terminal
+                             is detected - colors are automatically disabled if
+                             the output goes to a pipe.
+                             Possible values: *auto*, never, always.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes job info 6d6ab6d1b8a9c3bd0bb26a04f6c6a4d8
+`
+
+func infoJobCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, infoJobCmdUsage) }
+
+	var (
+		jsonFlag           bool
+		colorFlag          colorOption
+		insecureSkipVerify bool
+	)
+	cmd.BoolVar(&jsonFlag, "json", false, "Print the job in JSON format")
+	cmd.Var(&colorFlag, "color", "Specify when to use colored output")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes job info --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no job ID specified. See 'kes job info --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes job info --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var job api.JobResponse
+	if err := send(ctx, client, http.MethodGet, api.PathJobDescribe+url.PathEscape(cmd.Arg(0)), nil, &job); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to describe job: %v", err)
+	}
+	if jsonFlag {
+		if err := json.NewEncoder(os.Stdout).Encode(job); err != nil {
+			cli.Fatalf("failed to describe job: %v", err)
+		}
+		return
+	}
+
+	faint := tui.NewStyle().Faint(colorFlag.Colorize())
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "%-11s %s\n", faint.Render("ID"), job.ID)
+	fmt.Fprintf(buf, "%-11s %s\n", faint.Render("Kind"), job.Kind)
+	fmt.Fprintf(buf, "%-11s %s\n", faint.Render("State"), job.State)
+	fmt.Fprintf(buf, "%-11s %d / %d\n", faint.Render("Progress"), job.Done, job.Total)
+	fmt.Fprintf(buf, "%-11s %s\n", faint.Render("Created"), formatTime(job.CreatedAt))
+	fmt.Fprintf(buf, "%-11s %s\n", faint.Render("Created By"), job.CreatedBy)
+	if !job.FinishedAt.IsZero() {
+		fmt.Fprintf(buf, "%-11s %s\n", faint.Render("Finished"), formatTime(job.FinishedAt))
+	}
+	if job.Error != "" {
+		fmt.Fprintf(buf, "%-11s %s\n", faint.Render("Error"), job.Error)
+	}
+	for _, msg := range job.Errors {
+		fmt.Fprintf(buf, "%-11s %s\n", faint.Render("Issue"), msg)
+	}
+	fmt.Print(buf)
+}
+
+const cancelJobCmdUsage = `Usage:
+    kes job cancel [options] <id>
+
+Options:
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes job cancel 6d6ab6d1b8a9c3bd0bb26a04f6c6a4d8
+`
+
+func cancelJobCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, cancelJobCmdUsage) }
+
+	var insecureSkipVerify bool
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes job cancel --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no job ID specified. See 'kes job cancel --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes job cancel --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+	if err := send(ctx, client, http.MethodDelete, api.PathJobCancel+url.PathEscape(cmd.Arg(0)), nil, nil); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to cancel job: %v", err)
+	}
+}
@@ -11,12 +11,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"slices"
 	"strings"
+	"time"
 
 	tui "github.com/charmbracelet/lipgloss"
+	"github.com/minio/kes/internal/api"
 	"github.com/minio/kes/internal/cli"
 	"github.com/minio/kms-go/kes"
 	flag "github.com/spf13/pflag"
@@ -35,6 +39,11 @@ Commands:
     encrypt                  Encrypt a message.
     decrypt                  Decrypt an encrypted message.
     dek                      Generate a new data encryption key.
+    seal                     Encrypt data into a self-describing envelope.
+    open                     Decrypt an envelope.
+    tokenize                 Replace values with format-preserving tokens.
+    detokenize               Replace tokens with their original values.
+    reveal                   Replace tokens with masked original values.
 
 Options:
     -h, --help               Print command line options.
@@ -54,6 +63,12 @@ func keyCmd(args []string) {
 		"encrypt": encryptKeyCmd,
 		"decrypt": decryptKeyCmd,
 		"dek":     dekCmd,
+		"seal":    sealKeyCmd,
+		"open":    openKeyCmd,
+
+		"tokenize":   tokenizeKeyCmd,
+		"detokenize": detokenizeKeyCmd,
+		"reveal":     revealKeyCmd,
 	}
 
 	if len(args) < 2 {
@@ -274,11 +289,21 @@ Options:
                              Possible values: *auto*, never, always.
     -e, --enclave <name>     Operate within the specified enclave.
 
+        --match <glob>       Only list keys whose name matches the glob pattern.
+        --created-before <t> Only list keys created before the RFC 3339 time t.
+        --created-after <t>  Only list keys created after the RFC 3339 time t.
+        --algorithm <alg>    Only list keys of the given algorithm.
+                             Possible values: AES256, ChaCha20.
+        --folder <path>      Only list keys within the folder or one of its
+                             sub-folders.
+
     -h, --help               Print command line options.
 
 Examples:
     $ kes key ls
     $ kes key ls 'my-key*'
+    $ kes key ls --folder team-a/payments
+    $ kes key ls --match '*-backup' --created-before 2024-01-01T00:00:00Z
 `
 
 func lsKeyCmd(args []string) {
@@ -290,17 +315,32 @@ func lsKeyCmd(args []string) {
 		colorFlag          colorOption
 		insecureSkipVerify bool
 		enclaveName        string
+		matchFlag          string
+		createdBefore      string
+		createdAfter       string
+		algorithm          string
+		folder             string
 	)
 	cmd.BoolVar(&jsonFlag, "json", false, "Print identities in JSON format")
 	cmd.Var(&colorFlag, "color", "Specify when to use colored output")
 	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
 	cmd.StringVarP(&enclaveName, "enclave", "e", "", "Operate within the specified enclave")
+	cmd.StringVar(&matchFlag, "match", "", "Only list keys whose name matches the glob pattern")
+	cmd.StringVar(&createdBefore, "created-before", "", "Only list keys created before the given time")
+	cmd.StringVar(&createdAfter, "created-after", "", "Only list keys created after the given time")
+	cmd.StringVar(&algorithm, "algorithm", "", "Only list keys of the given algorithm")
+	cmd.StringVar(&folder, "folder", "", "Only list keys within the given folder")
 	if err := cmd.Parse(args[1:]); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
 			os.Exit(2)
 		}
 		cli.Fatalf("%v. See 'kes key ls --help'", err)
 	}
+	for _, t := range []string{createdBefore, createdAfter} {
+		if _, err := time.Parse(time.RFC3339, t); t != "" && err != nil {
+			cli.Fatalf("invalid time '%s': expected RFC 3339 format. See 'kes key ls --help'", t)
+		}
+	}
 
 	if cmd.NArg() > 1 {
 		cli.Fatal("too many arguments. See 'kes key ls --help'")
@@ -317,16 +357,37 @@ func lsKeyCmd(args []string) {
 	enclave := newClient(config{
 		InsecureSkipVerify: insecureSkipVerify,
 	})
-	iter := &kes.ListIter[string]{
-		NextFunc: enclave.ListKeys,
-	}
 
 	var names []string
-	for id, err := iter.SeekTo(ctx, prefix); err != io.EOF; id, err = iter.Next(ctx) {
-		if err != nil {
+	if matchFlag != "" || createdBefore != "" || createdAfter != "" || algorithm != "" || folder != "" {
+		query := url.Values{}
+		for k, v := range map[string]string{
+			"pattern":        matchFlag,
+			"created_before": createdBefore,
+			"created_after":  createdAfter,
+			"algorithm":      algorithm,
+			"folder":         folder,
+		} {
+			if v != "" {
+				query.Set(k, v)
+			}
+		}
+
+		var response api.ListKeysResponse
+		if err := send(ctx, enclave, http.MethodGet, api.PathKeyList+url.PathEscape(prefix)+"?"+query.Encode(), nil, &response); err != nil {
 			cli.Fatalf("failed to list keys: %v", err)
 		}
-		names = append(names, id)
+		names = response.Names
+	} else {
+		iter := &kes.ListIter[string]{
+			NextFunc: enclave.ListKeys,
+		}
+		for id, err := iter.SeekTo(ctx, prefix); err != io.EOF; id, err = iter.Next(ctx) {
+			if err != nil {
+				cli.Fatalf("failed to list keys: %v", err)
+			}
+			names = append(names, id)
+		}
 	}
 	slices.Sort(names)
 
@@ -0,0 +1,302 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/cli"
+	flag "github.com/spf13/pflag"
+)
+
+const lockCmdUsage = `Usage:
+    kes lock <command>
+
+Commands:
+    acquire                  Acquire a lock.
+    renew                    Renew a lock.
+    release                  Release a lock.
+    info                     Get information about a lock.
+
+Options:
+    -h, --help               Print command line options.
+`
+
+func lockCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, lockCmdUsage) }
+
+	subCmds := commands{
+		"acquire": acquireLockCmd,
+		"renew":   renewLockCmd,
+		"release": releaseLockCmd,
+		"info":    infoLockCmd,
+	}
+
+	if len(args) < 2 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if cmd, ok := subCmds[args[1]]; ok {
+		cmd(args[1:])
+		return
+	}
+
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes lock --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatalf("%q is not a lock command. See 'kes lock --help'", cmd.Arg(0))
+	}
+	cmd.Usage()
+	os.Exit(2)
+}
+
+const acquireLockCmdUsage = `Usage:
+    kes lock acquire [options] <name>
+
+Acquire a lock for a limited time. On success, the lock token
+is printed. It is required to renew or release the lock.
+
+Options:
+        --ttl <duration>     Duration until the lock expires. (default: 30s)
+    -k, --insecure           Skip TLS certificate validation.
+        --json               Print the lock in JSON format.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes lock acquire --ttl 5m key-rotation
+`
+
+func acquireLockCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, acquireLockCmdUsage) }
+
+	var (
+		ttl                string
+		jsonFlag           bool
+		insecureSkipVerify bool
+	)
+	cmd.StringVar(&ttl, "ttl", "", "Duration until the lock expires")
+	cmd.BoolVar(&jsonFlag, "json", false, "Print the lock in JSON format")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes lock acquire --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no lock name specified. See 'kes lock acquire --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes lock acquire --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var lock api.LockResponse
+	if err := send(ctx, client, http.MethodPut, api.PathLockAcquire+url.PathEscape(cmd.Arg(0)), api.AcquireLockRequest{TTL: ttl}, &lock); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to acquire lock: %v", err)
+	}
+	printLock(lock, jsonFlag)
+}
+
+const renewLockCmdUsage = `Usage:
+    kes lock renew [options] <name> <token>
+
+Options:
+        --ttl <duration>     Duration until the lock expires. (default: 30s)
+    -k, --insecure           Skip TLS certificate validation.
+        --json               Print the lock in JSON format.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes lock renew --ttl 5m key-rotation 2e0c4c0f4f1b9d1d8a7c5a6a0b8e9f31
+`
+
+func renewLockCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, renewLockCmdUsage) }
+
+	var (
+		ttl                string
+		jsonFlag           bool
+		insecureSkipVerify bool
+	)
+	cmd.StringVar(&ttl, "ttl", "", "Duration until the lock expires")
+	cmd.BoolVar(&jsonFlag, "json", false, "Print the lock in JSON format")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes lock renew --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no lock name specified. See 'kes lock renew --help'")
+	case cmd.NArg() == 1:
+		cli.Fatal("no lock token specified. See 'kes lock renew --help'")
+	case cmd.NArg() > 2:
+		cli.Fatal("too many arguments. See 'kes lock renew --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var lock api.LockResponse
+	if err := send(ctx, client, http.MethodPut, api.PathLockRenew+url.PathEscape(cmd.Arg(0)), api.RenewLockRequest{Token: cmd.Arg(1), TTL: ttl}, &lock); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to renew lock: %v", err)
+	}
+	printLock(lock, jsonFlag)
+}
+
+const releaseLockCmdUsage = `Usage:
+    kes lock release [options] <name> <token>
+
+Options:
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes lock release key-rotation 2e0c4c0f4f1b9d1d8a7c5a6a0b8e9f31
+`
+
+func releaseLockCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, releaseLockCmdUsage) }
+
+	var insecureSkipVerify bool
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes lock release --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no lock name specified. See 'kes lock release --help'")
+	case cmd.NArg() == 1:
+		cli.Fatal("no lock token specified. See 'kes lock release --help'")
+	case cmd.NArg() > 2:
+		cli.Fatal("too many arguments. See 'kes lock release --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+	if err := send(ctx, client, http.MethodPut, api.PathLockRelease+url.PathEscape(cmd.Arg(0)), api.ReleaseLockRequest{Token: cmd.Arg(1)}, nil); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to release lock: %v", err)
+	}
+}
+
+const infoLockCmdUsage = `Usage:
+    kes lock info [options] <name>
+
+Options:
+    -k, --insecure           Skip TLS certificate validation.
+        --json               Print the lock in JSON format.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes lock info key-rotation
+`
+
+func infoLockCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, infoLockCmdUsage) }
+
+	var (
+		jsonFlag           bool
+		insecureSkipVerify bool
+	)
+	cmd.BoolVar(&jsonFlag, "json", false, "Print the lock in JSON format")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes lock info --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no lock name specified. See 'kes lock info --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes lock info --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var lock api.LockResponse
+	if err := send(ctx, client, http.MethodGet, api.PathLockDescribe+url.PathEscape(cmd.Arg(0)), nil, &lock); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to describe lock: %v", err)
+	}
+	printLock(lock, jsonFlag)
+}
+
+func printLock(lock api.LockResponse, jsonFlag bool) {
+	if jsonFlag {
+		if err := json.NewEncoder(os.Stdout).Encode(lock); err != nil {
+			cli.Fatal(err)
+		}
+		return
+	}
+
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "%-8s %s\n", "Name", lock.Name)
+	fmt.Fprintf(buf, "%-8s %s\n", "Holder", lock.Holder)
+	fmt.Fprintf(buf, "%-8s %s\n", "Expires", formatTime(lock.ExpiresAt))
+	if lock.Token != "" {
+		fmt.Fprintf(buf, "%-8s %s\n", "Token", lock.Token)
+	}
+	fmt.Print(buf)
+}
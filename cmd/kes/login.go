@@ -0,0 +1,260 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/cli"
+	"github.com/minio/kms-go/kes"
+	flag "github.com/spf13/pflag"
+)
+
+const loginCmdUsage = `Usage:
+    kes login [options]
+
+Start a short-lived admin session with a WebAuthn hardware token,
+e.g. a FIDO2 security key. The WebAuthn ceremony runs in the browser
+at http://localhost. Once the server verified the token, the API key
+printed to stdout acts as admin until the session expires.
+
+With --register, create a new credential on the hardware token and
+print the credential ID and public key for the server config instead.
+
+Options:
+        --register           Register a new WebAuthn credential.
+        --port <port>        Port of the local login page. (default: 7380)
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ export MINIO_KES_API_KEY=$(kes login)
+    $ kes login --register
+`
+
+func loginCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, loginCmdUsage) }
+
+	var (
+		register           bool
+		port               int
+		insecureSkipVerify bool
+	)
+	cmd.BoolVar(&register, "register", false, "Register a new WebAuthn credential")
+	cmd.IntVar(&port, "port", 7380, "Port of the local login page")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes login --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatal("too many arguments. See 'kes login --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	if register {
+		options := map[string]any{
+			"mode":      "register",
+			"rpId":      "localhost",
+			"challenge": randomBytes(32),
+			"userId":    randomBytes(16),
+		}
+		var credential struct {
+			ID        []byte `json:"credential_id"`
+			PublicKey []byte `json:"public_key"` // DER-encoded SubjectPublicKeyInfo
+		}
+		if err := webAuthnCeremony(ctx, port, options, &credential); err != nil {
+			if errors.Is(err, context.Canceled) {
+				os.Exit(1)
+			}
+			cli.Fatalf("failed to register credential: %v", err)
+		}
+		fmt.Printf("Credential ID: %s\n", base64.RawURLEncoding.EncodeToString(credential.ID))
+		fmt.Print(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: credential.PublicKey})))
+		return
+	}
+
+	// The session is bound to a new, ephemeral, API key. Hence, the
+	// admin session ends once the API key is discarded or expires.
+	apiKey, err := kes.GenerateAPIKey(nil)
+	if err != nil {
+		cli.Fatalf("failed to generate API key: %v", err)
+	}
+	client := newClient(config{
+		APIKey:             apiKey.String(),
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var challenge api.LoginChallengeResponse
+	if err = send(ctx, client, http.MethodPost, api.PathLoginChallenge, nil, &challenge); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to request login challenge: %v", err)
+	}
+	options := map[string]any{
+		"mode":             "login",
+		"rpId":             challenge.RPID,
+		"challenge":        challenge.Challenge,
+		"credentials":      challenge.Credentials,
+		"userVerification": challenge.UserVerification,
+	}
+	var assertion api.LoginRequest
+	if err = webAuthnCeremony(ctx, port, options, &assertion); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to sign login challenge: %v", err)
+	}
+
+	var session api.LoginResponse
+	if err = send(ctx, client, http.MethodPost, api.PathLogin, assertion, &session); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to login: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Admin session of '%s' expires at %s\n", session.Identity, session.NotAfter.Local().Format(time.RFC3339))
+	fmt.Println(apiKey.String())
+}
+
+// webAuthnCeremony serves a page at http://localhost:<port> that
+// runs the WebAuthn ceremony described by options in the browser.
+// It decodes the result the page posts back into v.
+func webAuthnCeremony(ctx context.Context, port int, options map[string]any, v any) error {
+	listener, err := net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	result := make(chan error, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, webAuthnPage)
+	})
+	mux.HandleFunc("GET /options", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(options)
+	})
+	mux.HandleFunc("POST /result", func(w http.ResponseWriter, r *http.Request) {
+		var response struct {
+			Error  string          `json:"error"`
+			Result json.RawMessage `json:"result"`
+		}
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&response)
+		switch {
+		case err != nil:
+		case response.Error != "":
+			err = errors.New(response.Error)
+		default:
+			err = json.Unmarshal(response.Result, v)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		select {
+		case result <- err:
+		default:
+		}
+	})
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	fmt.Fprintf(os.Stderr, "Open http://localhost:%d in your browser and use your security key.\n", port)
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case err = <-result:
+		return err
+	}
+}
+
+// randomBytes returns n random bytes. On error, it aborts
+// the program using cli.Fatal.
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		cli.Fatal(err)
+	}
+	return b
+}
+
+// webAuthnPage is the page running the WebAuthn ceremony. It fetches
+// the ceremony options from, and posts the result back to, the CLI.
+// Binary values are exchanged as standard base64, like JSON []byte.
+const webAuthnPage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>KES Login</title></head>
+<body>
+<p id="status">Use your security key to continue.</p>
+<script>
+const decode = s => Uint8Array.from(atob(s), c => c.charCodeAt(0));
+const encode = b => btoa(String.fromCharCode(...new Uint8Array(b)));
+
+async function ceremony(o) {
+  if (o.mode === "register") {
+    const cred = await navigator.credentials.create({publicKey: {
+      rp: {id: o.rpId, name: "KES"},
+      user: {id: decode(o.userId), name: "kes-admin", displayName: "KES admin"},
+      challenge: decode(o.challenge),
+      pubKeyCredParams: [{type: "public-key", alg: -7}, {type: "public-key", alg: -8}, {type: "public-key", alg: -257}],
+      authenticatorSelection: {userVerification: "preferred"},
+      attestation: "none",
+    }});
+    return {credential_id: encode(cred.rawId), public_key: encode(cred.response.getPublicKey())};
+  }
+  const cred = await navigator.credentials.get({publicKey: {
+    rpId: o.rpId,
+    challenge: decode(o.challenge),
+    allowCredentials: (o.credentials || []).map(id => ({type: "public-key", id: decode(id)})),
+    userVerification: o.userVerification ? "required" : "discouraged",
+  }});
+  return {
+    credential_id: encode(cred.rawId),
+    client_data: encode(cred.response.clientDataJSON),
+    authenticator_data: encode(cred.response.authenticatorData),
+    signature: encode(cred.response.signature),
+  };
+}
+
+(async () => {
+  const status = document.getElementById("status");
+  let body;
+  try {
+    const options = await (await fetch("/options")).json();
+    body = {result: await ceremony(options)};
+  } catch (e) {
+    body = {error: String(e)};
+  }
+  const resp = await fetch("/result", {method: "POST", body: JSON.stringify(body)});
+  status.textContent = resp.ok ? "Done. You can close this page." : "Failed: " + await resp.text();
+})();
+</script>
+</body>
+</html>
+`
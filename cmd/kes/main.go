@@ -18,6 +18,7 @@ import (
 
 	tui "github.com/charmbracelet/lipgloss"
 	"github.com/minio/kes/internal/cli"
+	xhttp "github.com/minio/kes/internal/http"
 	"github.com/minio/kes/internal/https"
 	"github.com/minio/kes/internal/sys"
 	"github.com/minio/kms-go/kes"
@@ -37,10 +38,22 @@ Commands:
     key                      Manage cryptographic keys.
     policy                   Manage KES policies.
     identity                 Manage KES identities.
+    conn                     List and close client connections.
+    report                   Report stale keys and unused identities.
+    job                      Manage long-running server jobs.
+    lock                     Manage distributed locks.
+    db                       Issue short-lived database credentials.
+    ssh                      Sign SSH keys with the KES SSH CA.
+    pki                      Issue X.509 certificates with the KES CA.
+    merkle                   Prove key existence to auditors.
+    login                    Start an admin session with a security key.
 
     log                      Print error and audit log events.
     status                   Print server status.
     metric                   Print server metrics.
+    maintenance              Toggle server read-only mode.
+    promote                  Promote a standby server.
+    shim                     Start an S3 SSE-KMS shim.
 
 Options:
     -v, --version            Print version information.
@@ -63,10 +76,23 @@ func main() {
 		"key":      keyCmd,
 		"policy":   policyCmd,
 		"identity": identityCmd,
+		"conn":     connCmd,
+		"report":   reportCmd,
+		"job":      jobCmd,
+		"lock":     lockCmd,
+		"db":       dbCmd,
+		"ssh":      sshCmd,
+		"pki":      pkiCmd,
+		"merkle":   merkleCmd,
+		"login":    loginCmd,
 
 		"log":    logCmd,
 		"status": statusCmd,
 		"metric": metricCmd,
+
+		"maintenance": maintenanceCmd,
+		"promote":     promoteCmd,
+		"shim":        shimCmd,
 	}
 
 	if len(os.Args) < 2 {
@@ -238,6 +264,7 @@ func newClient(conf config) *kes.Client {
 		InsecureSkipVerify: false,
 	})
 	client.Endpoints = endpoints
+	client.HTTPClient.Transport = xhttp.LegacyErrors{RoundTripper: client.HTTPClient.Transport}
 	return client
 }
 
@@ -0,0 +1,88 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/cli"
+	flag "github.com/spf13/pflag"
+)
+
+const maintenanceCmdUsage = `Usage:
+    kes maintenance [options] <on|off>
+
+Put a KES server into read-only mode, or take it out of read-only
+mode. While in read-only mode, the server rejects requests that
+modify keys, like creating or deleting keys. Requests that only
+use keys, like encrypt or decrypt, are still served.
+
+Options:
+        --reason <text>      Reason reported to rejected clients.
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes maintenance on --reason "key store migration"
+    $ kes maintenance off
+`
+
+func maintenanceCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, maintenanceCmdUsage) }
+
+	var (
+		reason             string
+		insecureSkipVerify bool
+	)
+	cmd.StringVar(&reason, "reason", "", "Reason reported to rejected clients")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes maintenance --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no mode specified. See 'kes maintenance --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes maintenance --help'")
+	}
+
+	var readOnly bool
+	switch cmd.Arg(0) {
+	case "on":
+		readOnly = true
+	case "off":
+		readOnly = false
+	default:
+		cli.Fatalf("invalid mode '%s': expected 'on' or 'off'. See 'kes maintenance --help'", cmd.Arg(0))
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+	err := send(ctx, client, http.MethodPut, api.PathMaintenance, api.MaintenanceRequest{
+		ReadOnly: readOnly,
+		Reason:   reason,
+	}, nil)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to change maintenance mode: %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/cli"
+	"github.com/minio/kes/internal/crypto"
+	flag "github.com/spf13/pflag"
+)
+
+const merkleCmdUsage = `Usage:
+    kes merkle <command>
+
+Commands:
+    root                     Print a published Merkle root.
+    proof                    Prove whether a key existed.
+
+Options:
+    -h, --help               Print command line options.
+`
+
+func merkleCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, merkleCmdUsage) }
+
+	subCmds := commands{
+		"root":  rootMerkleCmd,
+		"proof": proofMerkleCmd,
+	}
+
+	if len(args) < 2 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if cmd, ok := subCmds[args[1]]; ok {
+		cmd(args[1:])
+		return
+	}
+
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes merkle --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatalf("%q is not a merkle command. See 'kes merkle --help'", cmd.Arg(0))
+	}
+	cmd.Usage()
+	os.Exit(2)
+}
+
+const rootMerkleCmdUsage = `Usage:
+    kes merkle root [options]
+
+Print the latest Merkle root over all keys. The root is signed
+by the KES server. Auditors should pin its public key.
+
+Options:
+        --at <time>          Print the latest root published at or before
+                             the given RFC 3339 time.
+    -k, --insecure           Skip TLS certificate validation.
+        --json               Print the root in JSON format.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes merkle root
+    $ kes merkle root --at 2024-06-30T23:59:59Z
+`
+
+func rootMerkleCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, rootMerkleCmdUsage) }
+
+	var (
+		at                 string
+		jsonFlag           bool
+		insecureSkipVerify bool
+	)
+	cmd.StringVar(&at, "at", "", "Print the latest root published at or before the given time")
+	cmd.BoolVar(&jsonFlag, "json", false, "Print the root in JSON format")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes merkle root --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatal("too many arguments. See 'kes merkle root --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var root api.MerkleRootResponse
+	if err := send(ctx, client, http.MethodGet, api.PathMerkleRoot+merkleQuery(at), nil, &root); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to fetch Merkle root: %v", err)
+	}
+	if !verifyMerkleRoot(root) {
+		cli.Fatal("invalid Merkle root: signature verification failed")
+	}
+
+	if jsonFlag {
+		if err := json.NewEncoder(os.Stdout).Encode(root); err != nil {
+			cli.Fatal(err)
+		}
+		return
+	}
+	fmt.Print(formatMerkleRoot(root))
+}
+
+const proofMerkleCmdUsage = `Usage:
+    kes merkle proof [options] <name>
+
+Fetch and verify a proof that a key was, or was not, part of the
+latest Merkle root. An exclusion proof reveals the names of at
+most two other keys.
+
+Options:
+        --at <time>          Prove against the latest root published at
+                             or before the given RFC 3339 time.
+    -k, --insecure           Skip TLS certificate validation.
+        --json               Print the proof in JSON format.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes merkle proof my-key
+    $ kes merkle proof --at 2024-06-30T23:59:59Z my-key
+`
+
+func proofMerkleCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, proofMerkleCmdUsage) }
+
+	var (
+		at                 string
+		jsonFlag           bool
+		insecureSkipVerify bool
+	)
+	cmd.StringVar(&at, "at", "", "Prove against the latest root published at or before the given time")
+	cmd.BoolVar(&jsonFlag, "json", false, "Print the proof in JSON format")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes merkle proof --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no key name specified. See 'kes merkle proof --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes merkle proof --help'")
+	}
+	name := cmd.Arg(0)
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var proof api.MerkleProofResponse
+	if err := send(ctx, client, http.MethodGet, api.PathMerkleProof+url.PathEscape(name)+merkleQuery(at), nil, &proof); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to fetch Merkle proof: %v", err)
+	}
+	if err := verifyMerkleProof(name, proof); err != nil {
+		cli.Fatalf("invalid Merkle proof: %v", err)
+	}
+
+	if jsonFlag {
+		if err := json.NewEncoder(os.Stdout).Encode(proof); err != nil {
+			cli.Fatal(err)
+		}
+		return
+	}
+
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "%-10s %s\n", "Key", name)
+	if proof.Included {
+		fmt.Fprintf(buf, "%-10s %s\n", "Included", "yes")
+		fmt.Fprintf(buf, "%-10s %s\n", "Created", formatTime(proof.Leaf.CreatedAt))
+		if proof.Leaf.CreatedBy != "" {
+			fmt.Fprintf(buf, "%-10s %s\n", "Owner", proof.Leaf.CreatedBy)
+		}
+		fmt.Fprintf(buf, "%-10s %d\n", "Index", proof.Leaf.Index)
+	} else {
+		fmt.Fprintf(buf, "%-10s %s\n", "Included", "no")
+	}
+	buf.WriteString(formatMerkleRoot(proof.Root))
+	fmt.Print(buf)
+}
+
+// merkleQuery returns the URL query of Merkle API requests.
+func merkleQuery(at string) string {
+	if at == "" {
+		return ""
+	}
+	if _, err := time.Parse(time.RFC3339, at); err != nil {
+		cli.Fatalf("invalid time '%s': expected RFC 3339 timestamp, e.g. 2024-06-30T23:59:59Z", at)
+	}
+	return "?at=" + url.QueryEscape(at)
+}
+
+func formatMerkleRoot(root api.MerkleRootResponse) string {
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "%-10s %s\n", "Published", formatTime(root.PublishedAt))
+	fmt.Fprintf(buf, "%-10s %d\n", "Size", root.Size)
+	fmt.Fprintf(buf, "%-10s %s\n", "Root", hex.EncodeToString(root.Root))
+	fmt.Fprintf(buf, "%-10s %s\n", "Signer", base64.StdEncoding.EncodeToString(root.PublicKey))
+	return buf.String()
+}
+
+// verifyMerkleRoot reports whether the root has been signed
+// by its public key.
+func verifyMerkleRoot(root api.MerkleRootResponse) bool {
+	if len(root.PublicKey) != ed25519.PublicKeySize {
+		return false
+	}
+	msg := crypto.MerkleCheckpoint(root.Size, root.Root, root.PublishedAt)
+	return ed25519.Verify(root.PublicKey, msg, root.Signature)
+}
+
+// verifyMerkleProof verifies that the proof proves that the named
+// key is, or is not, part of the proof's Merkle root.
+func verifyMerkleProof(name string, proof api.MerkleProofResponse) error {
+	if !verifyMerkleRoot(proof.Root) {
+		return errors.New("signature verification failed")
+	}
+	verify := func(leaf *api.MerkleLeaf) bool {
+		hash := crypto.MerkleLeafHash(crypto.MerkleKeyLeaf(leaf.Name, leaf.CreatedAt, leaf.CreatedBy))
+		return crypto.VerifyMerklePath(leaf.Index, proof.Root.Size, hash, leaf.Path, proof.Root.Root)
+	}
+
+	if proof.Included {
+		if proof.Leaf == nil || proof.Leaf.Name != name || !verify(proof.Leaf) {
+			return errors.New("inclusion proof verification failed")
+		}
+		return nil
+	}
+
+	left, right := proof.Left, proof.Right
+	switch {
+	case left == nil && right == nil:
+		if proof.Root.Size != 0 {
+			return errors.New("exclusion proof contains no leaves")
+		}
+	case left == nil:
+		if right.Index != 0 {
+			return errors.New("exclusion proof does not start at the first leaf")
+		}
+	case right == nil:
+		if left.Index != proof.Root.Size-1 {
+			return errors.New("exclusion proof does not end at the last leaf")
+		}
+	default:
+		if left.Index+1 != right.Index {
+			return errors.New("exclusion proof leaves are not adjacent")
+		}
+	}
+	if left != nil && (left.Name >= name || !verify(left)) {
+		return errors.New("exclusion proof verification failed")
+	}
+	if right != nil && (right.Name <= name || !verify(right)) {
+		return errors.New("exclusion proof verification failed")
+	}
+	return nil
+}
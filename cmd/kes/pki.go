@@ -0,0 +1,323 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/cli"
+	flag "github.com/spf13/pflag"
+)
+
+const pkiCmdUsage = `Usage:
+    kes pki <command>
+
+Commands:
+    ca                       Print the CA certificate chain.
+    issue                    Issue a X.509 certificate.
+    revoke                   Revoke a X.509 certificate.
+    import                   Import an intermediate CA.
+
+Options:
+    -h, --help               Print command line options.
+`
+
+func pkiCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, pkiCmdUsage) }
+
+	subCmds := commands{
+		"ca":     caPKICmd,
+		"issue":  issuePKICmd,
+		"revoke": revokePKICmd,
+		"import": importPKICmd,
+	}
+
+	if len(args) < 2 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if cmd, ok := subCmds[args[1]]; ok {
+		cmd(args[1:])
+		return
+	}
+
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes pki --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatalf("%q is not a pki command. See 'kes pki --help'", cmd.Arg(0))
+	}
+	cmd.Usage()
+	os.Exit(2)
+}
+
+const caPKICmdUsage = `Usage:
+    kes pki ca [options]
+
+Print the PEM-encoded CA certificate chain.
+
+Options:
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes pki ca > ca.crt
+`
+
+func caPKICmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, caPKICmdUsage) }
+
+	var insecureSkipVerify bool
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes pki ca --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatal("too many arguments. See 'kes pki ca --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var ca api.DescribePKICAResponse
+	if err := send(ctx, client, http.MethodGet, api.PathPKICA, nil, &ca); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to fetch CA certificate: %v", err)
+	}
+	fmt.Print(ca.Certificate)
+}
+
+const issuePKICmdUsage = `Usage:
+    kes pki issue [options] <role> <common-name>
+
+Issue a X.509 certificate for the common name. The certificate
+and private key are written to <common-name>.crt and .key.
+
+Options:
+        --dns <name>         Additional DNS subject alternative name.
+                             May be specified multiple times.
+        --ip <address>       IP address subject alternative name.
+                             May be specified multiple times.
+        --ttl <duration>     Validity period of the certificate.
+        --csr <file>         Issue the certificate for the CSR's
+                             public key. No private key is written.
+    -o, --output <prefix>    Write to <prefix>.crt and <prefix>.key.
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes pki issue --ttl 24h mesh api.svc.cluster.local
+    $ kes pki issue --csr api.csr -o api mesh api.svc.cluster.local
+`
+
+func issuePKICmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, issuePKICmdUsage) }
+
+	var (
+		dnsNames           []string
+		ipAddresses        []string
+		ttl                string
+		csrFile            string
+		output             string
+		insecureSkipVerify bool
+	)
+	cmd.StringArrayVar(&dnsNames, "dns", nil, "Additional DNS subject alternative name")
+	cmd.StringArrayVar(&ipAddresses, "ip", nil, "IP address subject alternative name")
+	cmd.StringVar(&ttl, "ttl", "", "Validity period of the certificate")
+	cmd.StringVar(&csrFile, "csr", "", "Issue the certificate for the CSR's public key")
+	cmd.StringVarP(&output, "output", "o", "", "Write to <prefix>.crt and <prefix>.key")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes pki issue --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no PKI role specified. See 'kes pki issue --help'")
+	case cmd.NArg() == 1:
+		cli.Fatal("no common name specified. See 'kes pki issue --help'")
+	case cmd.NArg() > 2:
+		cli.Fatal("too many arguments. See 'kes pki issue --help'")
+	}
+
+	role, commonName := cmd.Arg(0), cmd.Arg(1)
+	if output == "" {
+		output = commonName
+	}
+	body := api.IssueCertificateRequest{
+		CommonName:  commonName,
+		DNSNames:    dnsNames,
+		IPAddresses: ipAddresses,
+		TTL:         ttl,
+	}
+	if csrFile != "" {
+		csr, err := os.ReadFile(csrFile)
+		if err != nil {
+			cli.Fatalf("failed to read CSR: %v", err)
+		}
+		body.CSR = string(csr)
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var cert api.IssueCertificateResponse
+	if err := send(ctx, client, http.MethodPut, api.PathPKIIssue+url.PathEscape(role), body, &cert); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to issue certificate: %v", err)
+	}
+	if err := os.WriteFile(output+".crt", []byte(cert.Certificate+cert.CAChain), 0o644); err != nil {
+		cli.Fatalf("failed to write certificate: %v", err)
+	}
+	if cert.PrivateKey != "" {
+		if err := os.WriteFile(output+".key", []byte(cert.PrivateKey), 0o600); err != nil {
+			cli.Fatalf("failed to write private key: %v", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Certificate %s written to %s.crt. Valid until %s\n", cert.Serial, output, formatTime(cert.ExpiresAt))
+}
+
+const revokePKICmdUsage = `Usage:
+    kes pki revoke [options] <serial>
+
+Revoke the certificate with the given hex-encoded serial number.
+
+Options:
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes pki revoke 5f4dcc3b5aa765d61d8327deb882cf99
+`
+
+func revokePKICmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, revokePKICmdUsage) }
+
+	var insecureSkipVerify bool
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes pki revoke --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no serial number specified. See 'kes pki revoke --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes pki revoke --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+	if err := send(ctx, client, http.MethodPut, api.PathPKIRevoke+url.PathEscape(cmd.Arg(0)), nil, nil); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to revoke certificate: %v", err)
+	}
+}
+
+const importPKICmdUsage = `Usage:
+    kes pki import [options] <certificate> <private-key>
+
+Replace the KES CA with an intermediate CA. The certificate file
+must contain the PEM-encoded CA certificate, optionally followed by
+its issuers. The private key must be a PEM-encoded PKCS #8 key.
+
+Options:
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes pki import intermediate.crt intermediate.key
+`
+
+func importPKICmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, importPKICmdUsage) }
+
+	var insecureSkipVerify bool
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes pki import --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no CA certificate specified. See 'kes pki import --help'")
+	case cmd.NArg() == 1:
+		cli.Fatal("no private key specified. See 'kes pki import --help'")
+	case cmd.NArg() > 2:
+		cli.Fatal("too many arguments. See 'kes pki import --help'")
+	}
+
+	cert, err := os.ReadFile(cmd.Arg(0))
+	if err != nil {
+		cli.Fatalf("failed to read CA certificate: %v", err)
+	}
+	key, err := os.ReadFile(cmd.Arg(1))
+	if err != nil {
+		cli.Fatalf("failed to read private key: %v", err)
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+	body := api.ImportPKICARequest{
+		Certificate: string(cert),
+		PrivateKey:  string(key),
+	}
+	if err = send(ctx, client, http.MethodPut, api.PathPKIImport, body, nil); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to import CA: %v", err)
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	tui "github.com/charmbracelet/lipgloss"
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/cli"
+	flag "github.com/spf13/pflag"
+)
+
+const reportCmdUsage = `Usage:
+    kes report <command>
+
+Commands:
+    keys                     List keys not used within N days.
+    identities               List identities not seen within N days.
+    inventory                Export an inventory of all keys.
+
+Options:
+    -h, --help               Print command line options.
+`
+
+func reportCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, reportCmdUsage) }
+
+	subCmds := commands{
+		"keys":       reportKeysCmd,
+		"identities": reportIdentitiesCmd,
+		"inventory":  reportInventoryCmd,
+	}
+
+	if len(args) < 2 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if cmd, ok := subCmds[args[1]]; ok {
+		cmd(args[1:])
+		return
+	}
+
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes report --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatalf("%q is not a report command. See 'kes report --help'", cmd.Arg(0))
+	}
+	cmd.Usage()
+	os.Exit(2)
+}
+
+const reportKeysCmdUsage = `Usage:
+    kes report keys [options] [<pattern>]
+
+Options:
+    -d, --days <n>           Report keys not used within the last n days.
+                             Defaults to 90.
+    -k, --insecure           Skip TLS certificate validation.
+        --json               Print keys in JSON format.
+        --color <when>       Specify when to use colored output. The automatic
+                             mode only enables colors if an interactive terminal
+                             is detected - colors are automatically disabled if
+                             the output goes to a pipe.
+                             Possible values: *auto*, never, always.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes report keys
+    $ kes report keys --days 30 'my-key*'
+`
+
+func reportKeysCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, reportKeysCmdUsage) }
+
+	var (
+		days               uint
+		jsonFlag           bool
+		colorFlag          colorOption
+		insecureSkipVerify bool
+	)
+	cmd.UintVarP(&days, "days", "d", 90, "Report keys not used within the last n days")
+	cmd.BoolVar(&jsonFlag, "json", false, "Print keys in JSON format")
+	cmd.Var(&colorFlag, "color", "Specify when to use colored output")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes report keys --help'", err)
+	}
+	if cmd.NArg() > 1 {
+		cli.Fatal("too many arguments. See 'kes report keys --help'")
+	}
+	if days == 0 {
+		cli.Fatal("number of days must be greater than 0. See 'kes report keys --help'")
+	}
+
+	pattern := "*"
+	if cmd.NArg() == 1 {
+		pattern = cmd.Arg(0)
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var report api.ListStaleKeysResponse
+	path := api.PathKeyStale + url.PathEscape(pattern) + "?days=" + strconv.FormatUint(uint64(days), 10)
+	if err := send(ctx, client, http.MethodGet, path, nil, &report); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to report stale keys: %v", err)
+	}
+	if jsonFlag {
+		if err := json.NewEncoder(os.Stdout).Encode(report); err != nil {
+			cli.Fatalf("failed to report stale keys: %v", err)
+		}
+		return
+	}
+	if len(report.Keys) == 0 {
+		return
+	}
+
+	var (
+		style = tui.NewStyle().Underline(colorFlag.Colorize())
+		faint = tui.NewStyle().Faint(colorFlag.Colorize())
+		buf   = &strings.Builder{}
+	)
+	fmt.Fprintf(buf, "%s %s %s\n", style.Render(fmt.Sprintf("%-40s", "Key")), style.Render(fmt.Sprintf("%-19s", "Created")), style.Render("Last Used"))
+	for _, key := range report.Keys {
+		fmt.Fprintf(buf, "%-40s %-19s %s\n", key.Name, formatTime(key.CreatedAt), formatTime(key.LastUsed))
+	}
+	fmt.Fprintln(buf, faint.Render("Usage tracked since "+formatTime(report.TrackedSince)))
+	fmt.Print(buf)
+}
+
+const reportIdentitiesCmdUsage = `Usage:
+    kes report identities [options] [<pattern>]
+
+Options:
+    -d, --days <n>           Report identities not seen within the last n days.
+                             Defaults to 90.
+    -k, --insecure           Skip TLS certificate validation.
+        --json               Print identities in JSON format.
+        --color <when>       Specify when to use colored output. The automatic
+                             mode only enables colors if an interactive terminal
+                             is detected - colors are automatically disabled if
+                             the output goes to a pipe.
+                             Possible values: *auto*, never, always.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes report identities
+    $ kes report identities --days 30
+`
+
+func reportIdentitiesCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, reportIdentitiesCmdUsage) }
+
+	var (
+		days               uint
+		jsonFlag           bool
+		colorFlag          colorOption
+		insecureSkipVerify bool
+	)
+	cmd.UintVarP(&days, "days", "d", 90, "Report identities not seen within the last n days")
+	cmd.BoolVar(&jsonFlag, "json", false, "Print identities in JSON format")
+	cmd.Var(&colorFlag, "color", "Specify when to use colored output")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes report identities --help'", err)
+	}
+	if cmd.NArg() > 1 {
+		cli.Fatal("too many arguments. See 'kes report identities --help'")
+	}
+	if days == 0 {
+		cli.Fatal("number of days must be greater than 0. See 'kes report identities --help'")
+	}
+
+	pattern := "*"
+	if cmd.NArg() == 1 {
+		pattern = cmd.Arg(0)
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var report api.ListStaleIdentitiesResponse
+	path := api.PathIdentityStale + url.PathEscape(pattern) + "?days=" + strconv.FormatUint(uint64(days), 10)
+	if err := send(ctx, client, http.MethodGet, path, nil, &report); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to report unused identities: %v", err)
+	}
+	if jsonFlag {
+		if err := json.NewEncoder(os.Stdout).Encode(report); err != nil {
+			cli.Fatalf("failed to report unused identities: %v", err)
+		}
+		return
+	}
+	if len(report.Identities) == 0 {
+		return
+	}
+
+	var (
+		style = tui.NewStyle().Underline(colorFlag.Colorize())
+		faint = tui.NewStyle().Faint(colorFlag.Colorize())
+		buf   = &strings.Builder{}
+	)
+	fmt.Fprintf(buf, "%s %s %s\n", style.Render(fmt.Sprintf("%-64s", "Identity")), style.Render(fmt.Sprintf("%-20s", "Policy")), style.Render("Last Seen"))
+	for _, id := range report.Identities {
+		policy := id.Policy
+		if id.IsAdmin {
+			policy = "<admin>"
+		}
+		fmt.Fprintf(buf, "%-64s %-20s %s\n", id.Identity, policy, formatTime(id.LastSeen))
+	}
+	fmt.Fprintln(buf, faint.Render("Usage tracked since "+formatTime(report.TrackedSince)))
+	fmt.Print(buf)
+}
+
+const reportInventoryCmdUsage = `Usage:
+    kes report inventory [options]
+
+Export an inventory of all keys, including their algorithm, creation,
+last rotation, last usage and owner. Requires the admin identity.
+
+Options:
+        --csv                Print the inventory in CSV format.
+        --json               Print the inventory in JSON format.
+    -o, --output <file>      Write the inventory to the file.
+    -k, --insecure           Skip TLS certificate validation.
+        --color <when>       Specify when to use colored output. The automatic
+                             mode only enables colors if an interactive terminal
+                             is detected - colors are automatically disabled if
+                             the output goes to a pipe.
+                             Possible values: *auto*, never, always.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes report inventory
+    $ kes report inventory --csv -o inventory.csv
+`
+
+func reportInventoryCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, reportInventoryCmdUsage) }
+
+	var (
+		csvFlag            bool
+		jsonFlag           bool
+		output             string
+		colorFlag          colorOption
+		insecureSkipVerify bool
+	)
+	cmd.BoolVar(&csvFlag, "csv", false, "Print the inventory in CSV format")
+	cmd.BoolVar(&jsonFlag, "json", false, "Print the inventory in JSON format")
+	cmd.StringVarP(&output, "output", "o", "", "Write the inventory to the file")
+	cmd.Var(&colorFlag, "color", "Specify when to use colored output")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes report inventory --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatal("too many arguments. See 'kes report inventory --help'")
+	}
+	if csvFlag && jsonFlag {
+		cli.Fatal("'--csv' and '--json' cannot be used together. See 'kes report inventory --help'")
+	}
+	if output != "" && !csvFlag && !jsonFlag {
+		jsonFlag = true
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	if csvFlag || jsonFlag {
+		out := os.Stdout
+		if output != "" {
+			f, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+			if err != nil {
+				cli.Fatalf("failed to export key inventory: %v", err)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		path := api.PathKeyInventory + "?format=json"
+		if csvFlag {
+			path = api.PathKeyInventory + "?format=csv"
+		}
+		if err := send(ctx, client, http.MethodGet, path, nil, out); err != nil {
+			if errors.Is(err, context.Canceled) {
+				os.Exit(1)
+			}
+			cli.Fatalf("failed to export key inventory: %v", err)
+		}
+		if output != "" {
+			if err := out.Close(); err != nil {
+				cli.Fatalf("failed to export key inventory: %v", err)
+			}
+		}
+		return
+	}
+
+	var report api.KeyInventoryResponse
+	if err := send(ctx, client, http.MethodGet, api.PathKeyInventory, nil, &report); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to export key inventory: %v", err)
+	}
+	if len(report.Keys) == 0 {
+		return
+	}
+
+	var (
+		style = tui.NewStyle().Underline(colorFlag.Colorize())
+		faint = tui.NewStyle().Faint(colorFlag.Colorize())
+		buf   = &strings.Builder{}
+	)
+	fmt.Fprintf(buf, "%s %s %s %s %s\n", style.Render(fmt.Sprintf("%-40s", "Key")), style.Render(fmt.Sprintf("%-12s", "Algorithm")), style.Render(fmt.Sprintf("%-19s", "Created")), style.Render(fmt.Sprintf("%-19s", "Last Used")), style.Render("Owner"))
+	for _, key := range report.Keys {
+		fmt.Fprintf(buf, "%-40s %-12s %-19s %-19s %s\n", key.Name, key.Algorithm, formatTime(key.CreatedAt), formatTime(key.LastUsed), key.Owner)
+	}
+	fmt.Fprintln(buf, faint.Render("Usage tracked since "+formatTime(report.TrackedSince)))
+	fmt.Print(buf)
+}
+
+// formatTime returns a human-readable representation of t
+// in the local time zone, or "never" if t is the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format(time.DateTime)
+}
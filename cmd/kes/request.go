@@ -0,0 +1,78 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"aead.dev/mem"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kms-go/kes"
+)
+
+// send sends a request with the given method and path to the first
+// server endpoint of the client. It encodes body, if not nil, as
+// JSON request body and decodes the JSON response body into v, if
+// v is not nil. If v is an io.Writer, send copies the response body
+// to v as is.
+//
+// It is used by commands that invoke server APIs not covered by
+// the kes.Client. If the server responds with an error status
+// code, send returns a kes.Error.
+func send(ctx context.Context, client *kes.Client, method, path string, body, v any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	endpoint := strings.TrimSuffix(client.Endpoints[0], "/")
+	req, err := http.NewRequestWithContext(ctx, method, endpoint+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set(headers.ContentType, headers.ContentTypeJSON)
+	}
+	req.Header.Set(headers.Accept, headers.ContentTypeProblemJSON+", "+headers.ContentTypeJSON)
+
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		const MaxSize = 5 * mem.KB // An error message should not exceed 5 KB.
+
+		var response struct {
+			Message string `json:"message"`
+			Detail  string `json:"detail"` // RFC 7807 problem details
+		}
+		if err := json.NewDecoder(mem.LimitReader(resp.Body, MaxSize)).Decode(&response); err != nil {
+			return kes.NewError(resp.StatusCode, http.StatusText(resp.StatusCode))
+		}
+		if response.Detail != "" {
+			return kes.NewError(resp.StatusCode, response.Detail)
+		}
+		return kes.NewError(resp.StatusCode, response.Message)
+	}
+	if v == nil {
+		return nil
+	}
+	if w, ok := v.(io.Writer); ok {
+		_, err = io.Copy(w, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -31,7 +31,6 @@ import (
 	"github.com/minio/kes"
 	"github.com/minio/kes/internal/cli"
 	"github.com/minio/kes/internal/sys"
-	"github.com/minio/kes/kesconf"
 	kesdk "github.com/minio/kms-go/kes"
 	flag "github.com/spf13/pflag"
 )
@@ -49,6 +48,24 @@ Options:
     --dev                    Start the KES server in development mode. The server
                              uses a volatile in-memory key store.
 
+    --check                  Validate the server setup without starting the server.
+                             Loads the config file, validates the TLS material,
+                             connects to the key store and creates, reads and
+                             deletes a canary entry. Exits with a non-zero exit
+                             code if any check fails.
+
+    --verify-key <file>      Path to a PEM-encoded Ed25519 public key. If set, the
+                             server refuses to start, or reload its config, unless
+                             the config file matches its Ed25519 signature. It must
+                             match the key pinned into the binary, if any.
+
+    --config-signature <file>
+                             Path to the base64-encoded config file signature.
+                             The default is '<config>.sig'.
+
+    --verify-binary          Verify the server binary against its signature stored
+                             at '<executable>.sig' before starting.
+
     -h, --help               Show list of command-line options
 
 
@@ -66,6 +83,13 @@ Examples:
 
   2. Start a new KES server with a confg file on '127.0.0.1:7000'.
      $ kes server --addr :7000 --config ./kes/config.yml
+
+  3. Validate the server setup before deploying a new config file.
+     $ kes server --config ./kes/config.yml --check
+
+  4. Start a new KES server with a signed config file.
+     $ openssl pkeyutl -sign -rawin -inkey sign.key -in ./kes/config.yml | base64 > ./kes/config.yml.sig
+     $ kes server --config ./kes/config.yml --verify-key sign.pub
 `
 
 func serverCmd(args []string) {
@@ -79,6 +103,11 @@ func serverCmd(args []string) {
 		tlsCertFlag  string
 		mtlsAuthFlag string
 		devFlag      bool
+		checkFlag    bool
+
+		verifyKeyFlag    string
+		configSigFlag    string
+		verifyBinaryFlag bool
 	)
 	cmd.StringVar(&addrFlag, "addr", "", "The address of the server")
 	cmd.StringVar(&configFlag, "config", "", "Path to the server configuration file")
@@ -86,6 +115,10 @@ func serverCmd(args []string) {
 	cmd.StringVar(&tlsCertFlag, "cert", "", "Path to the TLS certificate")
 	cmd.StringVar(&mtlsAuthFlag, "auth", "", "Controls how the server handles mTLS authentication")
 	cmd.BoolVar(&devFlag, "dev", false, "Start the KES server in development mode")
+	cmd.BoolVar(&checkFlag, "check", false, "Validate the server setup without starting the server")
+	cmd.StringVar(&verifyKeyFlag, "verify-key", "", "Path to the public key verifying the config signature")
+	cmd.StringVar(&configSigFlag, "config-signature", "", "Path to the config file signature")
+	cmd.BoolVar(&verifyBinaryFlag, "verify-binary", false, "Verify the server binary signature before starting")
 	if err := cmd.Parse(args[1:]); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
 			os.Exit(2)
@@ -108,6 +141,21 @@ func serverCmd(args []string) {
 		cli.Fatal("too many arguments. See 'kes server --help'")
 	}
 
+	verify, err := newIntegrity(verifyKeyFlag, configSigFlag, verifyBinaryFlag)
+	if err != nil {
+		cli.Fatal(err)
+	}
+
+	if checkFlag {
+		if devFlag {
+			cli.Fatal("'--check' flag is not supported in development mode")
+		}
+		if err := checkServer(addrFlag, configFlag, verify); err != nil {
+			cli.Fatal(err)
+		}
+		return
+	}
+
 	if devFlag {
 		if addrFlag == "" {
 			addrFlag = "0.0.0.0:7373"
@@ -122,12 +170,12 @@ func serverCmd(args []string) {
 		return
 	}
 
-	if err := startServer(addrFlag, configFlag); err != nil {
+	if err := startServer(addrFlag, configFlag, verify); err != nil {
 		cli.Fatal(err)
 	}
 }
 
-func startServer(addrFlag, configFlag string) error {
+func startServer(addrFlag, configFlag string, verify *integrity) error {
 	var memLocked bool
 	if runtime.GOOS == "linux" {
 		memLocked = mlockall() == nil
@@ -143,7 +191,7 @@ func startServer(addrFlag, configFlag string) error {
 	// local network interfaces. We may not know the
 	// server addr yet since a user may not specified
 	// one on the command line.
-	rawConfig, err := kesconf.ReadFile(configFlag)
+	rawConfig, err := verify.ReadConfig(configFlag)
 	if err != nil {
 		return err
 	}
@@ -233,7 +281,7 @@ func startServer(addrFlag, configFlag string) error {
 			case <-sighup:
 				fmt.Fprintln(os.Stderr, "SIGHUP signal received. Reloading configuration...")
 
-				file, err := kesconf.ReadFile(configFlag)
+				file, err := verify.ReadConfig(configFlag)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Failed to reload server config: %v\n", err)
 					continue
@@ -275,7 +323,7 @@ func startServer(addrFlag, configFlag string) error {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				file, err := kesconf.ReadFile(configFlag)
+				file, err := verify.ReadConfig(configFlag)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Failed to reload TLS configuration: %v\n", err)
 					continue
@@ -0,0 +1,134 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/minio/kes/internal/cli"
+	"github.com/minio/kes/internal/ssekms"
+	flag "github.com/spf13/pflag"
+)
+
+const shimCmdUsage = `Usage:
+    kes shim [options]
+
+Start an S3 SSE-KMS shim in front of a KES server. The shim serves
+the KES status, generate and decrypt APIs for object storage, like
+MinIO, on a local address.
+
+Instead of asking the KES server for every data encryption key, the
+shim fetches an intermediate key from the KES server and encrypts
+random data encryption keys with it locally. Intermediate keys are
+replaced once they expire or have been used too often.
+
+Data encryption keys generated by the shim can only be decrypted by
+a shim using the same KES server. Keys generated by the KES server
+are passed through.
+
+The shim does not authenticate requests. Hence, it should only listen
+on a loopback address.
+
+Options:
+    --addr <IP:PORT>         The address of the shim. (default: 127.0.0.1:7374)
+    --key-ttl <DURATION>     Duration an intermediate key is used and cached.
+                             (default: 5m)
+    --key-uses <N>           Max. number of data encryption keys encrypted
+                             with one intermediate key. (default: 16777216)
+    --cache-size <N>         Max. number of intermediate keys cached for
+                             decryption. (default: 10000)
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ KES_SERVER=https://kes:7373 KES_API_KEY=kes:v1:... kes shim
+    $ kes shim --addr 127.0.0.1:7374 --key-ttl 1m
+`
+
+func shimCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, shimCmdUsage) }
+
+	var (
+		addr               string
+		keyTTL             time.Duration
+		keyUses            uint64
+		cacheSize          int
+		insecureSkipVerify bool
+	)
+	cmd.StringVar(&addr, "addr", "127.0.0.1:7374", "The address of the shim")
+	cmd.DurationVar(&keyTTL, "key-ttl", 5*time.Minute, "Duration an intermediate key is used and cached")
+	cmd.Uint64Var(&keyUses, "key-uses", 1<<24, "Max. number of data encryption keys encrypted with one intermediate key")
+	cmd.IntVar(&cacheSize, "cache-size", 10000, "Max. number of intermediate keys cached for decryption")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes shim --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatal("too many arguments. See 'kes shim --help'")
+	}
+	if keyTTL <= 0 {
+		cli.Fatalf("invalid key TTL '%v': must be positive. See 'kes shim --help'", keyTTL)
+	}
+	if keyUses == 0 {
+		cli.Fatal("invalid key uses '0': must be positive. See 'kes shim --help'")
+	}
+	if cacheSize <= 0 {
+		cli.Fatalf("invalid cache size '%d': must be positive. See 'kes shim --help'", cacheSize)
+	}
+	if host, _, err := net.SplitHostPort(addr); err != nil {
+		cli.Fatalf("invalid address '%s': %v", addr, err)
+	} else if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		fmt.Fprintf(os.Stderr, "Warning: shim address '%s' is not a loopback address. Requests are not authenticated.\n", addr)
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+	if _, err := client.Status(ctx); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to reach KES server: %v", err)
+	}
+
+	srv := &http.Server{
+		Addr: addr,
+		Handler: ssekms.New(&ssekms.Config{
+			Upstream:  client,
+			KeyTTL:    keyTTL,
+			KeyUses:   keyUses,
+			CacheSize: cacheSize,
+		}),
+		ReadHeaderTimeout: 5 * time.Second,
+		IdleTimeout:       90 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Listening on %s\n", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		cli.Fatalf("failed to start shim: %v", err)
+	}
+}
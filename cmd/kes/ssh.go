@@ -0,0 +1,201 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/cli"
+	flag "github.com/spf13/pflag"
+)
+
+const sshCmdUsage = `Usage:
+    kes ssh <command>
+
+Commands:
+    ca                       Print the SSH CA public key.
+    sign                     Sign a SSH public key.
+
+Options:
+    -h, --help               Print command line options.
+`
+
+func sshCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, sshCmdUsage) }
+
+	subCmds := commands{
+		"ca":   caSSHCmd,
+		"sign": signSSHCmd,
+	}
+
+	if len(args) < 2 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if cmd, ok := subCmds[args[1]]; ok {
+		cmd(args[1:])
+		return
+	}
+
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes ssh --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatalf("%q is not a ssh command. See 'kes ssh --help'", cmd.Arg(0))
+	}
+	cmd.Usage()
+	os.Exit(2)
+}
+
+const caSSHCmdUsage = `Usage:
+    kes ssh ca [options]
+
+Print the SSH CA public key in the authorized_keys format.
+SSH servers trust the CA via the TrustedUserCAKeys option.
+
+Options:
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes ssh ca > /etc/ssh/kes_ca.pub
+`
+
+func caSSHCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, caSSHCmdUsage) }
+
+	var insecureSkipVerify bool
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes ssh ca --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatal("too many arguments. See 'kes ssh ca --help'")
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var ca api.DescribeSSHCAResponse
+	if err := send(ctx, client, http.MethodGet, api.PathSSHCA, nil, &ca); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to fetch SSH CA: %v", err)
+	}
+	fmt.Println(ca.PublicKey)
+}
+
+const signSSHCmdUsage = `Usage:
+    kes ssh sign [options] <role> <public-key>
+
+Sign a SSH public key with the KES SSH CA. The certificate is
+written next to the public key, e.g. id_ed25519-cert.pub, such
+that SSH clients pick it up automatically.
+
+Options:
+    -p, --principal <name>   Principal, i.e. user or host name, of the
+                             certificate. May be specified multiple times.
+        --ttl <duration>     Validity period of the certificate.
+    -o, --output <file>      Write the certificate to the given file.
+                             Use '-' to write to standard output.
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes ssh sign -p ubuntu --ttl 15m bastion ~/.ssh/id_ed25519.pub
+`
+
+func signSSHCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, signSSHCmdUsage) }
+
+	var (
+		principals         []string
+		ttl                string
+		output             string
+		insecureSkipVerify bool
+	)
+	cmd.StringArrayVarP(&principals, "principal", "p", nil, "Principal of the certificate")
+	cmd.StringVar(&ttl, "ttl", "", "Validity period of the certificate")
+	cmd.StringVarP(&output, "output", "o", "", "Write the certificate to the given file")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes ssh sign --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no SSH role specified. See 'kes ssh sign --help'")
+	case cmd.NArg() == 1:
+		cli.Fatal("no SSH public key specified. See 'kes ssh sign --help'")
+	case cmd.NArg() > 2:
+		cli.Fatal("too many arguments. See 'kes ssh sign --help'")
+	}
+	if len(principals) == 0 {
+		cli.Fatal("no principal specified. See 'kes ssh sign --help'")
+	}
+
+	role, keyFile := cmd.Arg(0), cmd.Arg(1)
+	pubKey, err := os.ReadFile(keyFile)
+	if err != nil {
+		cli.Fatalf("failed to read SSH public key: %v", err)
+	}
+	if output == "" {
+		output = strings.TrimSuffix(keyFile, ".pub") + "-cert.pub"
+	}
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var cert api.SignSSHKeyResponse
+	body := api.SignSSHKeyRequest{
+		PublicKey:  string(pubKey),
+		Principals: principals,
+		TTL:        ttl,
+	}
+	if err = send(ctx, client, http.MethodPut, api.PathSSHSign+url.PathEscape(role), body, &cert); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to sign SSH public key: %v", err)
+	}
+	if output == "-" {
+		fmt.Println(cert.Certificate)
+		return
+	}
+	if err = os.WriteFile(output, []byte(cert.Certificate+"\n"), 0o644); err != nil {
+		cli.Fatalf("failed to write SSH certificate: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Certificate written to %s. Valid until %s\n", output, formatTime(cert.ExpiresAt))
+}
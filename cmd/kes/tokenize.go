@@ -0,0 +1,130 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/cli"
+	flag "github.com/spf13/pflag"
+)
+
+const tokenizeKeyCmdUsage = `Usage:
+    kes key tokenize [options] <name> <value>...
+
+Replace values with tokens of the same length and format. The key
+must be configured for tokenization.
+
+Options:
+        --tweak <value>      Tweak, e.g. a merchant ID. The same value
+                             must be used to detokenize. FF3-1 requires
+                             a 7 byte tweak.
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes key tokenize card-numbers 4111-1111-1111-1111
+`
+
+func tokenizeKeyCmd(args []string) { tokenizeCmd(args, "tokenize", api.PathKeyTokenize) }
+
+const detokenizeKeyCmdUsage = `Usage:
+    kes key detokenize [options] <name> <token>...
+
+Replace tokens with their original values.
+
+Options:
+        --tweak <value>      Tweak used when tokenizing the values.
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes key detokenize card-numbers 8473-2093-4410-0216
+`
+
+func detokenizeKeyCmd(args []string) { tokenizeCmd(args, "detokenize", api.PathKeyDetokenize) }
+
+const revealKeyCmdUsage = `Usage:
+    kes key reveal [options] <name> <token>...
+
+Replace tokens with their masked original values. Only the
+characters configured for the key, e.g. the last 4 digits,
+are revealed.
+
+Options:
+        --tweak <value>      Tweak used when tokenizing the values.
+    -k, --insecure           Skip TLS certificate validation.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes key reveal card-numbers 8473-2093-4410-0216
+`
+
+func revealKeyCmd(args []string) { tokenizeCmd(args, "reveal", api.PathKeyReveal) }
+
+func tokenizeCmd(args []string, op, path string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() {
+		switch op {
+		case "tokenize":
+			fmt.Fprint(os.Stderr, tokenizeKeyCmdUsage)
+		case "detokenize":
+			fmt.Fprint(os.Stderr, detokenizeKeyCmdUsage)
+		default:
+			fmt.Fprint(os.Stderr, revealKeyCmdUsage)
+		}
+	}
+
+	var (
+		tweak              string
+		insecureSkipVerify bool
+	)
+	cmd.StringVar(&tweak, "tweak", "", "Tweak used for tokenization")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes key %s --help'", err, op)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatalf("no key name specified. See 'kes key %s --help'", op)
+	case cmd.NArg() == 1:
+		cli.Fatalf("no value specified. See 'kes key %s --help'", op)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancel()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+
+	var response api.TokenizeResponse
+	body := api.TokenizeRequest{
+		Values: cmd.Args()[1:],
+		Tweak:  []byte(tweak),
+	}
+	if err := send(ctx, client, http.MethodPut, path+url.PathEscape(cmd.Arg(0)), body, &response); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to %s values: %v", op, err)
+	}
+	for _, value := range response.Values {
+		fmt.Println(value)
+	}
+}
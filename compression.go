@@ -0,0 +1,166 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/minio/kms-go/kes"
+)
+
+// compressedHeader is the prefix of all key store entries
+// compressed with DEFLATE. The header identifies the compression
+// algorithm such that other algorithms can be added later.
+var compressedHeader = []byte("kes\x00deflate\x01")
+
+// maxDecompressedSize is the max. size of a decompressed
+// key store entry. It protects against decompression bombs.
+const maxDecompressedSize = 16 << 20
+
+// compressedStore is a KeyStore that compresses entries
+// before storing them at the underlying KeyStore.
+type compressedStore struct {
+	KeyStore
+	minSize int
+}
+
+// newCompressedStore returns a KeyStore that compresses entries
+// before storing them at store. It returns store if conf is nil.
+func newCompressedStore(store KeyStore, conf *CompressionConfig) KeyStore {
+	if conf == nil || store == nil {
+		return store
+	}
+	minSize := conf.MinSize
+	if minSize <= 0 {
+		minSize = DefaultCompressionMinSize
+	}
+	return &compressedStore{KeyStore: store, minSize: minSize}
+}
+
+// Create compresses the value before creating the entry.
+func (s *compressedStore) Create(ctx context.Context, name string, value []byte) error {
+	value, err := s.compress(value)
+	if err != nil {
+		return err
+	}
+	return s.KeyStore.Create(ctx, name, value)
+}
+
+// CanOverwrite reports whether the underlying KeyStore
+// can replace existing entries.
+func (s *compressedStore) CanOverwrite() bool {
+	store, ok := s.KeyStore.(OverwriteKeyStore)
+	return ok && store.CanOverwrite()
+}
+
+// Set compresses the value before creating or replacing the entry.
+func (s *compressedStore) Set(ctx context.Context, name string, value []byte) error {
+	value, err := s.compress(value)
+	if err != nil {
+		return err
+	}
+	return setEntry(ctx, s.KeyStore, name, value)
+}
+
+// CanSwap reports whether the underlying KeyStore
+// supports conditional writes.
+func (s *compressedStore) CanSwap() bool { return canSwap(s.KeyStore) }
+
+// Swap replaces the value of the entry if its decompressed value
+// is equal to old. Since entries may have been stored uncompressed,
+// it compares the decompressed values and swaps the stored value it
+// has read.
+func (s *compressedStore) Swap(ctx context.Context, name string, old, value []byte) error {
+	stored, err := s.KeyStore.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	plaintext, err := decompress(name, stored)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(plaintext, old) {
+		return kes.ErrKeyExists
+	}
+	if value, err = s.compress(value); err != nil {
+		return err
+	}
+	return swapEntry(ctx, s.KeyStore, name, stored, value)
+}
+
+// Get returns the decompressed value of the entry.
+func (s *compressedStore) Get(ctx context.Context, name string) ([]byte, error) {
+	value, err := s.KeyStore.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return decompress(name, value)
+}
+
+// GetBulk returns the decompressed values of the given entries.
+func (s *compressedStore) GetBulk(ctx context.Context, names []string) (map[string][]byte, error) {
+	values, err := getBulk(ctx, s.KeyStore, names)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range values {
+		if values[name], err = decompress(name, value); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// compress returns the compressed value prefixed with the
+// compressedHeader. It returns the value as it is if it is
+// smaller than the min. size or does not compress well.
+func (s *compressedStore) compress(value []byte) ([]byte, error) {
+	if len(value) < s.minSize {
+		return value, nil
+	}
+
+	var buf bytes.Buffer
+	buf.Write(compressedHeader)
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(value); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	if buf.Len() >= len(value) {
+		return value, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress returns the decompressed value of the entry with
+// the given name. It returns values without the compressedHeader,
+// e.g. entries created before compression got enabled, as they are.
+func decompress(name string, value []byte) ([]byte, error) {
+	if !bytes.HasPrefix(value, compressedHeader) {
+		return value, nil
+	}
+
+	r := flate.NewReader(bytes.NewReader(value[len(compressedHeader):]))
+	defer r.Close()
+
+	plaintext, err := io.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("kes: failed to decompress key store entry '%s': %v", name, err)
+	}
+	if len(plaintext) > maxDecompressedSize {
+		return nil, fmt.Errorf("kes: failed to decompress key store entry '%s': %v", name, errors.New("entry too large"))
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/minio/kms-go/kes"
+)
+
+func TestCompressedStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	backend := &MemKeyStore{}
+	store := newCompressedStore(backend, &CompressionConfig{MinSize: 64})
+
+	value := bytes.Repeat([]byte(`{"tenant":"my-tenant","labels":["a","b"]}`), 32)
+	if err := store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	compressed, err := backend.Get(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to get compressed entry: %v", err)
+	}
+	if !bytes.HasPrefix(compressed, compressedHeader) || len(compressed) >= len(value) {
+		t.Fatalf("Entry is not compressed: got %d bytes - original %d bytes", len(compressed), len(value))
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get entry: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	// Small entries and entries that do not compress are stored as they are.
+	small := []byte("my-secret-key-value")
+	if err = store.Create(ctx, "small-key", small); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if v, _ := backend.Get(ctx, "small-key"); !bytes.Equal(v, small) {
+		t.Fatalf("Small entry got compressed: '%x'", v)
+	}
+
+	// Uncompressed entries, e.g. created before compression
+	// got enabled, can be read and swapped.
+	if err = backend.Create(ctx, "old-key", value); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if err = swapEntry(ctx, store, "old-key", value, value); err != nil {
+		t.Fatalf("Failed to swap entry: %v", err)
+	}
+	if v, _ := backend.Get(ctx, "old-key"); !bytes.HasPrefix(v, compressedHeader) {
+		t.Fatal("Swapped entry is not compressed")
+	}
+	if err = swapEntry(ctx, store, "old-key", small, value); !errors.Is(err, kes.ErrKeyExists) {
+		t.Fatalf("Swapped entry with wrong value: got '%v' - want '%v'", err, kes.ErrKeyExists)
+	}
+
+	values, err := getBulk(ctx, store, []string{"my-key", "small-key", "old-key"})
+	if err != nil {
+		t.Fatalf("Failed to get entries: %v", err)
+	}
+	for name, want := range map[string][]byte{"my-key": value, "small-key": small, "old-key": value} {
+		if !bytes.Equal(values[name], want) {
+			t.Fatalf("Invalid value of entry '%s': got '%s' - want '%s'", name, values[name], want)
+		}
+	}
+
+	// Corrupted entries are rejected.
+	if err = backend.Create(ctx, "corrupted-key", append(bytes.Clone(compressedHeader), 0xff, 0xff)); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if _, err = store.Get(ctx, "corrupted-key"); err == nil {
+		t.Fatal("Decompressed corrupted entry")
+	}
+}
+
+func TestCompressedStoreDisabled(t *testing.T) {
+	t.Parallel()
+
+	backend := &MemKeyStore{}
+	if store := newCompressedStore(backend, nil); store != KeyStore(backend) {
+		t.Fatalf("Compression enabled without config: got '%T'", store)
+	}
+}
@@ -5,11 +5,22 @@
 package kes
 
 import (
+	"cmp"
+	"context"
+	stdcrypto "crypto"
+	"crypto/ed25519"
 	"crypto/tls"
+	"database/sql"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net/url"
+	"path"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/minio/kes/internal/crypto"
 	"github.com/minio/kms-go/kes"
 )
 
@@ -31,6 +42,11 @@ type Config struct {
 	// at least tls.RequestClientCert.
 	TLS *tls.Config
 
+	// TLSSession controls TLS session resumption. If nil, clients
+	// can resume sessions using session tickets encrypted with
+	// ticket keys that get rotated once a day.
+	TLSSession *TLSSessionConfig
+
 	// Cache specifies how long the KES server caches keys from the
 	// KeyStore. If nil, caching is disabled.
 	Cache *CacheConfig
@@ -45,6 +61,11 @@ type Config struct {
 	// Keys is the KeyStore the KES server fetches keys from.
 	Keys KeyStore
 
+	// KeyStoreTimeout controls how long the KES server waits
+	// for KeyStore operations to complete. If nil, default
+	// timeouts apply.
+	KeyStoreTimeout *KeyStoreTimeoutConfig
+
 	// Routes allows customization of the KES server API routes. It
 	// contains a set of API route paths, for example "/v1/status",
 	// and the corresponding route configuration.
@@ -58,11 +79,501 @@ type Config struct {
 	// controlled by Server.ErrLevel.
 	ErrorLog slog.Handler
 
+	// Standby, if set, starts the server as warm standby of
+	// another KES server. A standby mirrors the key cache and
+	// policies of its primary but does not serve API requests
+	// until it gets promoted.
+	Standby *StandbyConfig
+
+	// StandbyIdentities are the identities of the standby
+	// servers that may mirror the key cache and policies of
+	// this server, and of the caching proxies that may fetch
+	// keys from this server. Apart from the admin, no other
+	// identity can access the cache sync and cache key APIs,
+	// even if its policy allows it, since these APIs expose
+	// plaintext keys.
+	StandbyIdentities []kes.Identity
+
+	// Databases contains the database secrets engines by name.
+	// Each engine issues short-lived database users on request
+	// and revokes them once their lease expires.
+	Databases map[string]*DatabaseConfig
+
+	// SSHRoles contains the SSH certificate roles by name. Each
+	// role controls which principals and validity periods can be
+	// requested when signing SSH keys with the KES SSH CA.
+	SSHRoles map[string]*SSHRoleConfig
+
+	// PKI contains the configuration of the X.509 certificate
+	// engine. Certificates are issued by the KES CA. If nil,
+	// the CA exists but no certificates can be issued.
+	PKI *PKIConfig
+
+	// Tokenization contains the format-preserving encryption
+	// configuration of keys by key name. Only these keys can
+	// be used to tokenize values, like card numbers.
+	Tokenization map[string]*TokenizationConfig
+
+	// Merkle, if set, periodically publishes a signed Merkle
+	// root over the metadata of all keys. Auditors can request
+	// proofs that a key existed, or did not exist, when a root
+	// got published without exporting the key inventory.
+	Merkle *MerkleConfig
+
+	// LoadShedding, if set, limits the number of concurrent
+	// requests. Under load, the server rejects low priority
+	// requests, like bulk operations, first such that latency
+	// sensitive requests, like decryption, are still served.
+	LoadShedding *LoadSheddingConfig
+
+	// GeoIP, if set, is used to locate client IP addresses.
+	// It is required by policies with a geo condition.
+	GeoIP *GeoIPConfig
+
+	// AnomalyDetection, if set, aggregates usage features, like
+	// the request rate or the number of distinct keys used, per
+	// identity and raises alerts once an identity exceeds one of
+	// the thresholds.
+	AnomalyDetection *AnomalyDetectionConfig
+
+	// Alerting, if set, evaluates alerting rules periodically,
+	// like "key store unreachable for 60s", and notifies
+	// receivers, like webhooks, email or PagerDuty, once a rule
+	// fires or resolves.
+	Alerting *AlertingConfig
+
+	// Authenticators is the ordered authentication chain. The first
+	// Authenticator that finds credentials in a request identifies
+	// the client. If empty, clients are identified by their TLS client
+	// certificate. Virtual hosts have their own chain.
+	Authenticators []Authenticator
+
+	// AuthThrottling, if set, delays responses to requests with
+	// unknown or invalid identities progressively and bans source
+	// IP addresses temporarily once they exceed their failure budget.
+	AuthThrottling *AuthThrottlingConfig
+
+	// WebAuthn, if set, enables the Login API. Clients that pass
+	// a WebAuthn ceremony with a registered hardware token become
+	// the admin for a short time. Hence, the admin identity does
+	// not need to be a long-lived certificate.
+	WebAuthn *WebAuthnConfig
+
+	// Folders contains the key folder configurations by folder
+	// path, like "team-a/payments". Folders form a hierarchy by
+	// their path. Grants and tags of a folder are inherited by
+	// all its sub-folders. Keys are moved into folders via the
+	// key folder API.
+	Folders map[string]*FolderConfig
+
+	// KeyLifecycle, if set, transitions keys through the states
+	// "enabled", "decrypt-only" and "disabled" on configured dates
+	// and eventually purges them. Notifications are emitted before
+	// and once each transition happens.
+	KeyLifecycle *KeyLifecycleConfig
+
+	// RequireKeyOwner, if true, rejects requests that create or
+	// import keys without an owner and contact. Predefined keys
+	// are exempt. The owner of existing keys can be (re)assigned
+	// via the key owner API.
+	RequireKeyOwner bool
+
+	// Tenants contains the tenant configurations by tenant
+	// name. The keys of each tenant are wrapped by a tenant
+	// KEK before they are stored at the KeyStore. Deleting
+	// a tenant's KEK crypto-shreds all keys of the tenant.
+	Tenants map[string]*TenantConfig
+
+	// RequestLog, if set, logs API requests, including request
+	// headers and optionally JSON request bodies, for debugging
+	// integrations. Sensitive values, like plaintexts or key
+	// material, are always redacted.
+	RequestLog *RequestLogConfig
+
 	// AuditLog is an optional handler for handling the server's
 	// audit log events. If nil, defaults to a slog.TextHandler
 	// writing to os.Stdout. The server's audit log level is
 	// controlled by Server.AuditLevel.
 	AuditLog AuditHandler
+
+	// WriteBehind, if set, queues audit records for the AuditLog
+	// handler and usage information in a durable local queue and
+	// delivers them in the background. Hence, a slow or unavailable
+	// audit sink or key store does not add latency to requests.
+	// Queued records are replayed once the sink or key store
+	// recovers, and after a restart.
+	//
+	// The queue is opened when the server starts. Changes on
+	// Update are ignored.
+	WriteBehind *WriteBehindConfig
+
+	// AuditPseudonymization, if set, replaces identities and key
+	// names in audit log events with pseudonyms. It applies to
+	// the AuditLog handler and the audit log API.
+	AuditPseudonymization *AuditPseudonymizationConfig
+
+	// Cosigning, if set, requires that high-risk operations, like
+	// crypto-shredding keys, are countersigned by a witness KES
+	// server before they are executed.
+	Cosigning *CosigningConfig
+
+	// Fencing, if set, enables key usage reservations. While an
+	// identity holds the reservation of a key, encrypt and generate
+	// requests of this key fail unless they carry the reservation's
+	// fencing token.
+	Fencing *FencingConfig
+
+	// Witness, if set, enables the Cosign API such that this
+	// server can act as witness for other KES servers.
+	Witness *WitnessConfig
+
+	// Attestation, if set, enables the Attestation API that
+	// returns a TPM quote over the server's binary and config
+	// measurement.
+	Attestation *AttestationConfig
+
+	// Sealing, if set, seals all key store entries to the
+	// measurement of the confidential VM or enclave the
+	// server runs in.
+	Sealing *SealingConfig
+
+	// Compression, if set, compresses key store entries before
+	// they are sealed, wrapped and stored.
+	Compression *CompressionConfig
+
+	// Chunking, if set, splits large key store entries into
+	// multiple entries, e.g. to stay below the value size
+	// limit of a key store.
+	Chunking *ChunkingConfig
+
+	// Dedup, if set, stores identical values of different key
+	// store entries only once.
+	Dedup *DedupConfig
+
+	// Jobs contains the key stores the migrate and backup jobs
+	// copy keys to. If nil, neither job can be started.
+	Jobs *JobConfig
+
+	// VirtualHosts contains the configurations of virtual KES
+	// servers by TLS server name. A client selects a virtual
+	// server via the server name indication (SNI) of its TLS
+	// handshake. Clients without a matching server name are
+	// served by this server.
+	//
+	// Each virtual server has its own TLS config, e.g. CA and
+	// server certificate, admin identity, policies and key store.
+	// Requests are routed by the server name of the connection,
+	// not by the HTTP host header. Hence, clients of one virtual
+	// server cannot access any other virtual server.
+	//
+	// Virtual servers must not have virtual servers themselves.
+	VirtualHosts map[string]*Config
+}
+
+// A Sealer derives keys bound to the measurement of a confidential
+// VM or enclave, like an AMD SEV-SNP VM, and provides attestation
+// reports of it.
+type Sealer interface {
+	// SealingKey returns a 256-bit key bound to the measurement.
+	// The same, unmodified, VM or enclave on the same platform
+	// always derives the same key.
+	SealingKey(ctx context.Context) ([]byte, error)
+
+	// Report returns an attestation report of the platform that
+	// includes the given report data, at most 64 bytes.
+	Report(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// SealingConfig is a structure containing the configuration of
+// confidential computing aware sealing.
+//
+// All key store entries, including the server's own signing and CA
+// keys and tenant KEKs, are encrypted with the Sealer's sealing key
+// before they are stored. Hence, key material leaving the confidential
+// VM or enclave can only be unsealed by the same, unmodified, VM or
+// enclave. The key cache is kept in memory that is encrypted by the
+// CPU and not readable by the hypervisor, e.g. from VM snapshots.
+//
+// If attestation is enabled, the Attestation API includes an
+// attestation report of the Sealer over the same data that is
+// quoted by the TPM.
+type SealingConfig struct {
+	// Sealer derives the sealing key and provides
+	// attestation reports.
+	Sealer Sealer
+
+	// AllowUnsealed controls whether key store entries that have
+	// not been sealed, e.g. created before sealing got enabled,
+	// can still be read. Such entries are sealed once re-created.
+	// It should only be enabled while migrating existing keys.
+	// The rewrap job seals all existing entries at once.
+	AllowUnsealed bool
+}
+
+// CompressionConfig is a structure containing the configuration
+// of key store entry compression.
+//
+// Entries are compressed with DEFLATE and prefixed with a header
+// that identifies the compression algorithm. Entries that do not
+// get smaller are stored uncompressed. Hence, compression mainly
+// reduces the size of large entries, like tenant or server metadata,
+// at key stores that charge by the stored size. Uncompressed entries,
+// e.g. created before compression got enabled, can always be read.
+// The rewrap job compresses all existing entries at once.
+type CompressionConfig struct {
+	// MinSize is the min. size of entries that get compressed.
+	// Smaller entries are stored uncompressed. If <= 0, defaults
+	// to DefaultCompressionMinSize.
+	MinSize int
+}
+
+// DefaultCompressionMinSize is the default min. size of
+// compressed key store entries.
+const DefaultCompressionMinSize = 256
+
+// ChunkingConfig is a structure containing the configuration
+// of key store entry chunking.
+//
+// Entries larger than the chunk size are split into chunks that
+// are stored as separate entries. The entry itself only refers to
+// its chunks and contains a checksum of the entire value. Hence,
+// key stores with a value size limit, like AWS SecretsManager,
+// can store values of any size. Chunking applies to entries after
+// they have been compressed, sealed and wrapped.
+type ChunkingConfig struct {
+	// Size is the max. size of a chunk. Entries that are
+	// not larger are stored as they are. If <= 0, defaults
+	// to DefaultChunkSize.
+	Size int
+}
+
+// DedupConfig is a structure containing the configuration
+// of key store entry deduplication.
+//
+// Identical values of different entries are stored once as shared
+// value, encrypted with a key derived from the value. Each entry
+// only contains a reference to the shared value and its key, and
+// is compressed, sealed and wrapped on its own. Hence, a shared
+// value can only be read through an entry referring to it. Shared
+// values are removed once no entry refers to them anymore.
+//
+// Deduplication requires a key store that supports conditional
+// writes. Otherwise, entries are stored as they are. It applies
+// to entries before they are compressed, sealed and wrapped.
+// Hence, large identical values, e.g. bootstrap secrets split
+// into chunks, are stored and split only once.
+type DedupConfig struct {
+	// MinSize is the min. size of deduplicated entries. Smaller
+	// entries are stored as they are. If <= 0, defaults to
+	// DefaultDedupMinSize.
+	MinSize int
+}
+
+// DefaultDedupMinSize is the default min. size of
+// deduplicated key store entries.
+const DefaultDedupMinSize = 1024
+
+// DefaultChunkSize is the default max. size of key store
+// entry chunks.
+const DefaultChunkSize = 32 << 10
+
+// JobConfig is a structure containing the key stores of jobs
+// that copy all keys to another key store.
+//
+// Both jobs copy key store entries as stored, i.e. still compressed,
+// sealed, wrapped by tenant KEKs or split into chunks. Hence, a server can use the copied
+// keys only with the same sealing and tenant configuration.
+// Tenant KEKs stored at a separate KEK store are not copied.
+type JobConfig struct {
+	// MigrationTarget is the KeyStore the migrate job copies all
+	// keys to, e.g. before the server switches to another key
+	// store. Keys that exist at the MigrationTarget already are
+	// not overwritten but reported as job errors.
+	MigrationTarget KeyStore
+
+	// BackupTarget is the KeyStore the backup job copies all keys
+	// to. Unlike the migrate job, the backup job overwrites keys
+	// that exist at the BackupTarget already.
+	BackupTarget KeyStore
+}
+
+// AttestationConfig is a structure containing the configuration
+// of TPM-based server attestation.
+//
+// Clients request an attestation with a random nonce. The server
+// returns a TPM quote over the configured PCRs whose qualifying
+// data binds the nonce to the SHA-256 hashes of the server binary
+// and config file. A client verifies the quote with the TPM's
+// attestation key and compares the PCR values and digests with
+// known-good values before sending key material to the server.
+//
+// The binary is measured once, when attestation is first enabled.
+// The measurement is reported by the server itself. Hence, only
+// the PCRs, for example of a measured boot or the Linux IMA, are
+// attested by the TPM directly.
+type AttestationConfig struct {
+	// Device is the path of the TPM device or the unix socket
+	// of a software TPM. If empty, defaults to "/dev/tpmrm0".
+	Device string
+
+	// PCRs are the SHA-256 PCRs included in the quote. If
+	// empty, defaults to DefaultAttestationPCRs.
+	PCRs []int
+
+	// ConfigDigest is the SHA-256 hash of the server config
+	// file, if any.
+	ConfigDigest []byte
+}
+
+// DefaultAttestationPCRs are the PCRs that get quoted by default.
+// They contain the measurements of the firmware, boot loader and
+// secure boot policy.
+var DefaultAttestationPCRs = []int{0, 1, 2, 3, 4, 5, 6, 7}
+
+// WebAuthnConfig is a structure containing the configuration of
+// WebAuthn admin sessions.
+//
+// A client starts a session with a new, ephemeral, TLS client
+// certificate. It requests a challenge, signs it with a registered
+// WebAuthn credential, e.g. a FIDO2 security key, and sends the
+// assertion to the Login API. The identity of the ephemeral
+// certificate is then treated as admin until the session expires.
+//
+// Sessions are kept in memory. Within a cluster, a client has to
+// log in to each server separately.
+type WebAuthnConfig struct {
+	// RPID is the WebAuthn relying party ID credentials are
+	// scoped to, e.g. "localhost" when the ceremony runs in
+	// a browser page served by the KES CLI.
+	RPID string
+
+	// Origins are the accepted origins of the WebAuthn ceremony,
+	// e.g. "http://localhost:7380". If empty, any origin whose
+	// host is the RPID is accepted.
+	Origins []string
+
+	// Credentials are the registered admin credentials.
+	Credentials []WebAuthnCredential
+
+	// UserVerification controls whether the authenticator has
+	// to verify the user, e.g. by a PIN or fingerprint, and not
+	// just the user's presence.
+	UserVerification bool
+
+	// SessionTTL is how long an admin session is valid. It must
+	// not exceed MaxWebAuthnSessionTTL. If <= 0, defaults to
+	// DefaultWebAuthnSessionTTL.
+	SessionTTL time.Duration
+}
+
+// WebAuthnCredential is a registered WebAuthn credential.
+type WebAuthnCredential struct {
+	// ID is the credential ID assigned by the authenticator.
+	ID []byte
+
+	// PublicKey is the public key of the credential. It must
+	// be an ECDSA P-256, Ed25519 or RSA public key.
+	PublicKey stdcrypto.PublicKey
+}
+
+// Default and max. lifetime of WebAuthn admin sessions.
+const (
+	DefaultWebAuthnSessionTTL = 15 * time.Minute
+	MaxWebAuthnSessionTTL     = 12 * time.Hour
+)
+
+// CosigningConfig is a structure containing the configuration of
+// operations that require a cosignature of a witness KES server.
+//
+// A witness is a second, independently administered, KES server.
+// Clients obtain a cosignature from the witness via its Cosign API
+// and send it in the "Cosignature" header of the high-risk request.
+// Each cosignature approves a single request of one identity and
+// is only valid for a short time.
+//
+// Cosignatures are required for all identities, including the
+// admin. Each KES server rejects cosignatures it has already seen.
+// However, within a cluster, a cosignature may be used once per
+// server until it expires.
+type CosigningConfig struct {
+	// Witnesses are the Ed25519 public keys of the witness servers.
+	// A cosignature of any of the witnesses is accepted. A witness
+	// returns its public key as part of any cosignature.
+	Witnesses []ed25519.PublicKey
+
+	// Paths are the API path patterns of high-risk operations,
+	// for example "/v1/key/delete/*". If empty, defaults to
+	// DefaultCosignPaths.
+	Paths []string
+}
+
+// DefaultCosignPaths are the API path patterns of operations that
+// require a cosignature by default: crypto-shredding keys or tenants
+// and exporting the key inventory.
+var DefaultCosignPaths = []string{
+	"/v1/key/shred/*",
+	"/v1/tenant/shred/*",
+	"/v1/key/inventory",
+}
+
+// FencingConfig is a structure containing the configuration of key
+// usage reservations.
+//
+// A reservation grants an identity the exclusive right to produce
+// new ciphertexts under a key for a limited time, e.g. to ensure that
+// only one writer of an application encrypts data during a split-brain.
+// The holder sends the reservation token in the "Kes-Fencing-Token"
+// header of encrypt and generate requests. Once the reservation expires
+// or passes to another holder, requests with the old token fail.
+//
+// Reservations are stored at the key store and require conditional
+// writes. Hence, encrypt and generate requests read the reservation
+// from the key store, which increases their latency.
+type FencingConfig struct {
+	// MaxTTL is the max. lease duration of a reservation. It must
+	// not exceed MaxFencingTTL. If <= 0, defaults to
+	// DefaultFencingMaxTTL.
+	MaxTTL time.Duration
+}
+
+// Default and max. lease duration of key reservations.
+const (
+	DefaultFencingMaxTTL = 5 * time.Minute
+	MaxFencingTTL        = 24 * time.Hour
+)
+
+// WitnessConfig is a structure containing the configuration of
+// a witness KES server.
+//
+// A witness cosigns a request if the policy of the client, at the
+// witness, allows the request. Cosignatures are signed with the
+// server's Ed25519 key that also signs Merkle roots.
+type WitnessConfig struct {
+	// Validity is how long a cosignature is valid. It must not
+	// exceed MaxCosignValidity. If <= 0, defaults to
+	// DefaultCosignValidity.
+	Validity time.Duration
+}
+
+// Default and max. validity of cosignatures.
+const (
+	DefaultCosignValidity = 5 * time.Minute
+	MaxCosignValidity     = 1 * time.Hour
+)
+
+// AuditPseudonymizationConfig is a structure containing the
+// audit log pseudonymization configuration.
+//
+// A pseudonym is a keyed hash (HMAC) of an identity or key name.
+// The same name always maps to the same pseudonym such that audit
+// events can still be correlated. Only the admin can resolve
+// pseudonyms via the /v1/log/audit/pseudonym API.
+type AuditPseudonymizationConfig struct {
+	// Key is the HMAC key used to compute pseudonyms. It must be
+	// at least 16 bytes long. If empty, a key is generated on
+	// first use and stored at the KeyStore such that all servers
+	// of a cluster compute the same pseudonyms.
+	Key []byte
 }
 
 // Policy is a KES policy with associated identities.
@@ -74,6 +585,105 @@ type Policy struct {
 	Deny map[string]kes.Rule // Set of deny rules
 
 	Identities []kes.Identity
+
+	// Geo, if set, restricts requests of the policy's identities
+	// to clients located in certain countries or continents. It
+	// requires a GeoIP database.
+	Geo *GeoCondition
+}
+
+// FolderConfig is a structure containing the configuration of
+// a key folder.
+//
+// Folders allow granting access to all keys within a folder, and
+// its sub-folders, without relying on key name conventions in
+// policy patterns. For example, granting the policy "team-a" the
+// "encrypt" operation on the folder "team-a" allows its identities
+// to encrypt with the key "my-key" once it has been moved into the
+// folder "team-a/payments".
+type FolderConfig struct {
+	// Grants maps policy names to the key operations, like
+	// "encrypt" or "decrypt", the identities of the policy
+	// may perform with keys within the folder. The operation
+	// "*" grants all key operations that refer to an existing
+	// key. Deny rules of the policy take precedence over grants.
+	Grants map[string][]string
+
+	// Tags are attached to all keys within the folder. Tags of
+	// a sub-folder override inherited tags with the same name.
+	Tags map[string]string
+}
+
+// GeoCondition is a policy condition that restricts requests by
+// the location of the client IP address.
+//
+// A request the condition applies to is only allowed if the client
+// IP is located in one of the countries or continents. Requests
+// from IP addresses not contained in the GeoIP database are denied.
+type GeoCondition struct {
+	// Paths are the API path patterns the condition applies to,
+	// for example "/v1/key/decrypt/eu-*". If empty, the condition
+	// applies to all requests.
+	Paths []string
+
+	// Countries are ISO 3166-1 alpha-2 country codes, like "DE".
+	Countries []string
+
+	// Continents are continent codes, like "EU" for Europe.
+	Continents []string
+}
+
+// GeoIPConfig is a structure containing the GeoIP configuration
+// used to locate client IP addresses.
+type GeoIPConfig struct {
+	// Database is a MaxMind DB, like GeoLite2-Country or
+	// GeoIP2-City, that maps IP addresses to countries
+	// and continents.
+	Database []byte
+}
+
+// KeyStoreTimeoutConfig is a structure that holds the timeouts
+// of KeyStore operations.
+//
+// Operations that exceed their timeout get aborted and the
+// KES server responds with 504 GatewayTimeout. A zero timeout
+// is replaced by its default. A negative timeout disables the
+// timeout.
+type KeyStoreTimeoutConfig struct {
+	// Get is the timeout for fetching a key from the
+	// KeyStore. Defaults to 10 seconds.
+	Get time.Duration
+
+	// Write is the timeout for creating and deleting
+	// keys at the KeyStore. Defaults to 15 seconds.
+	Write time.Duration
+
+	// List is the timeout for listing keys at the
+	// KeyStore. Defaults to 30 seconds.
+	List time.Duration
+}
+
+// TLSSessionConfig is a structure containing the TLS
+// session resumption configuration of a KES server.
+//
+// Resumed sessions skip the certificate exchange and
+// verification of a full handshake. Hence, all ticket
+// keys get replaced and cached sessions get removed
+// whenever the server's TLS configuration changes.
+type TLSSessionConfig struct {
+	// TicketsDisabled disables session resumption.
+	TicketsDisabled bool
+
+	// TicketKeyRotation is the interval in which a new session
+	// ticket key is generated. Tickets encrypted with one of the
+	// last 8 keys remain valid. Defaults to 24 hours.
+	TicketKeyRotation time.Duration
+
+	// CacheSize is the max. number of sessions cached by the
+	// server. If set, session tickets only contain a session
+	// ID instead of the encrypted session state. If 0, session
+	// state is kept by clients only.
+	CacheSize int
 }
 
 // CacheConfig is a structure containing the KES server
@@ -109,6 +719,516 @@ type CacheConfig struct {
 	ExpiryOffline time.Duration
 }
 
+// StandbyConfig is a structure containing the warm standby
+// configuration of a KES server.
+type StandbyConfig struct {
+	// Primary is the endpoint of the primary KES server,
+	// for example "https://kes-primary:7373".
+	Primary string
+
+	// TLS is the client TLS configuration used to connect to
+	// the primary. The identity of its client certificate must
+	// either be the primary's admin or one of its standby
+	// identities and be allowed to access the primary's
+	// /v1/cache/sync API.
+	TLS *tls.Config
+
+	// Interval is the interval in which the standby mirrors
+	// the primary's key cache and policies. If <= 0, defaults
+	// to DefaultStandbyInterval.
+	Interval time.Duration
+}
+
+// DatabaseConfig is a structure containing the configuration
+// of a database secrets engine.
+//
+// The SQL statements may contain the placeholders {{name}},
+// {{password}} and {{expiration}}. They get replaced with the
+// generated user name, password and lease expiration time.
+type DatabaseConfig struct {
+	// DB is the database connection used to create and
+	// revoke users. It requires privileges to manage users.
+	DB *sql.DB
+
+	// Dialect is the SQL dialect of the database, either
+	// "postgres" or "mysql". It selects the default creation
+	// and revocation statements.
+	Dialect string
+
+	// CreationStatements are executed to create a new user.
+	// They usually grant privileges to the user. If empty,
+	// the dialect's default statements are used.
+	CreationStatements []string
+
+	// RevocationStatements are executed to revoke a user. If
+	// empty, the dialect's default statements are used.
+	RevocationStatements []string
+
+	// DefaultTTL is the lease duration used when clients do
+	// not request one. If <= 0, defaults to 1 hour.
+	DefaultTTL time.Duration
+
+	// MaxTTL is the longest lease duration clients can request.
+	// If <= 0, defaults to 24 hours.
+	MaxTTL time.Duration
+}
+
+// SSH certificate types.
+const (
+	SSHUserCert = "user"
+	SSHHostCert = "host"
+)
+
+// SSHRoleConfig is a structure containing the configuration
+// of a SSH certificate role.
+//
+// All SSH certificates are signed by the KES SSH CA. Its private
+// key is generated on first use and stored at the key store.
+type SSHRoleConfig struct {
+	// CertType is the type of certificates signed with the
+	// role, either SSHUserCert or SSHHostCert. If empty,
+	// defaults to SSHUserCert.
+	CertType string
+
+	// Principals are the principals, i.e. user or host names,
+	// clients may request. Each entry may be a glob pattern,
+	// for example "*.example.com".
+	Principals []string
+
+	// Extensions are the certificate extensions, for example
+	// "permit-pty". If nil, user certificates permit PTYs,
+	// forwarding and user rc files.
+	Extensions map[string]string
+
+	// DefaultTTL is the validity period used when clients do
+	// not request one. If <= 0, defaults to 1 hour.
+	DefaultTTL time.Duration
+
+	// MaxTTL is the longest validity period clients can request.
+	// If <= 0, defaults to 24 hours.
+	MaxTTL time.Duration
+}
+
+// PKIConfig is a structure containing the configuration of
+// the X.509 certificate engine.
+//
+// All certificates are issued by the KES CA. Its private key is
+// generated on first use and stored at the key store. It can be
+// replaced with an intermediate CA signed by an offline root CA.
+type PKIConfig struct {
+	// URL is the endpoint at which clients reach the KES server,
+	// for example "https://kes.example.com:7373". If set, issued
+	// certificates point to the server's CRL and OCSP APIs.
+	URL string
+
+	// Roles contains the certificate roles by name. Each role
+	// controls which names and validity periods can be requested.
+	Roles map[string]*PKIRoleConfig
+
+	// Renewal controls whether and when clients can renew their
+	// own certificate with a new private key. If nil, clients
+	// cannot renew their certificates.
+	Renewal *PKIRenewalConfig
+}
+
+// PKIRenewalConfig is a structure containing the configuration
+// of client certificate renewals.
+//
+// A client submits a CSR for a new private key and receives a
+// certificate issued by the KES CA. The server treats the new
+// certificate as the client's original identity. Hence, clients
+// keep their policy without any change to the server config.
+type PKIRenewalConfig struct {
+	// Window is the period before expiry in which clients can
+	// renew their certificate. If <= 0, defaults to 30 days.
+	Window time.Duration
+
+	// TTL is the validity period of renewed certificates.
+	// If <= 0, defaults to 90 days.
+	TTL time.Duration
+}
+
+// PKIRoleConfig is a structure containing the configuration
+// of a X.509 certificate role.
+type PKIRoleConfig struct {
+	// Domains are the DNS names clients may request. Each
+	// entry may be a glob pattern, for example "*.svc.local".
+	Domains []string
+
+	// AllowIPs controls whether clients may request IP
+	// address subject alternative names.
+	AllowIPs bool
+
+	// ServerAuth and ClientAuth control the extended key usage
+	// of certificates. If neither is set, certificates can be
+	// used for both, TLS server and client authentication.
+	ServerAuth bool
+	ClientAuth bool
+
+	// DefaultTTL is the validity period used when clients do
+	// not request one. If <= 0, defaults to 24 hours.
+	DefaultTTL time.Duration
+
+	// MaxTTL is the longest validity period clients can request.
+	// If <= 0, defaults to 7 days.
+	MaxTTL time.Duration
+}
+
+// Format-preserving encryption modes as specified by
+// NIST SP 800-38G Rev. 1.
+const (
+	FPEModeFF1  = crypto.FF1
+	FPEModeFF31 = crypto.FF31
+)
+
+// TokenizationConfig is a structure containing the format-preserving
+// encryption configuration of a key.
+//
+// Tokens have the same length and format as the original values.
+// Only characters of the alphabet get encrypted. All others, like
+// separators, remain unchanged.
+type TokenizationConfig struct {
+	// Mode is the format-preserving encryption mode, either
+	// FPEModeFF1 or FPEModeFF31. If empty, defaults to FF1.
+	Mode string
+
+	// Alphabet is the set of characters that get encrypted,
+	// for example "0123456789". Its length is the radix.
+	Alphabet string
+
+	// RevealFirst and RevealLast are the number of leading and
+	// trailing alphabet characters revealed by the Reveal API.
+	// All others are replaced with the MaskChar. If both are
+	// zero, the last 4 characters are revealed.
+	RevealFirst int
+	RevealLast  int
+
+	// MaskChar is the character used to mask values. If
+	// empty, defaults to "*".
+	MaskChar string
+}
+
+// MerkleConfig is a structure containing the configuration
+// of published Merkle roots.
+//
+// All roots are signed with an Ed25519 key. It is generated on
+// first use and stored at the key store.
+type MerkleConfig struct {
+	// Interval is the interval in which a new Merkle root
+	// gets published. If <= 0, defaults to DefaultMerkleInterval.
+	Interval time.Duration
+
+	// Retention is how long published roots are kept. Proofs
+	// can only be generated for retained roots. If <= 0, roots
+	// are kept forever.
+	Retention time.Duration
+}
+
+// LoadSheddingConfig is a structure containing the load
+// shedding configuration.
+//
+// Clients set the priority of a request via the "Request-Priority"
+// header to either PriorityLow, PriorityNormal or PriorityHigh.
+// Encrypt, decrypt, generate and HMAC requests have a high priority
+// by default. All others have a normal priority.
+type LoadSheddingConfig struct {
+	// MaxRequests is the max. number of concurrent requests.
+	// Low priority requests get rejected once 50% and normal
+	// priority requests once 80% of MaxRequests are in use.
+	MaxRequests int
+}
+
+// AnomalyDetectionConfig is a structure containing the usage
+// anomaly detection configuration.
+//
+// The server aggregates the requests of each identity over an
+// interval. At the end of each interval, it compares the usage
+// features of each identity to the thresholds, logs an alert for
+// every exceeded threshold and sends all features and alerts to
+// the sink, if any.
+type AnomalyDetectionConfig struct {
+	// Interval is the interval over which usage features are
+	// aggregated. If <= 0, defaults to 1 minute.
+	Interval time.Duration
+
+	// Sink is an optional HTTP endpoint. At the end of every
+	// interval, the server sends the features of all identities
+	// as JSON object to it via a POST request.
+	Sink string
+
+	// MaxRequestRate is the max. number of requests per second
+	// of an identity. If <= 0, the request rate is not checked.
+	MaxRequestRate float64
+
+	// MaxDistinctKeys is the max. number of distinct keys an
+	// identity uses within an interval. If <= 0, the number of
+	// distinct keys is not checked.
+	MaxDistinctKeys int
+
+	// MaxDenyRate is the max. fraction, between 0 and 1, of
+	// requests of an identity rejected by its policy. If <= 0,
+	// the deny rate is not checked.
+	MaxDenyRate float64
+
+	// MinRequests is the min. number of requests an identity has
+	// to send within an interval before its deny rate is checked.
+	// If <= 0, defaults to 10.
+	MinRequests int
+}
+
+// Signals alerting rules can evaluate.
+const (
+	// AlertBackendUnreachable is 1 while the most recent health
+	// probe of a backend, like the key store, failed and 0 otherwise.
+	AlertBackendUnreachable = "backend_unreachable"
+
+	// AlertDenyRate is the fraction, between 0 and 1, of requests
+	// rejected by policies since the previous evaluation.
+	AlertDenyRate = "deny_rate"
+
+	// AlertMetric is the value of a metric exposed by the Metrics
+	// API. For counters, it is the increase per second since the
+	// previous evaluation.
+	AlertMetric = "metric"
+
+	// AlertAudit is the number of audit records that match a path
+	// pattern since the previous evaluation.
+	AlertAudit = "audit"
+)
+
+// AlertingConfig is a structure containing alerting rules and
+// the receivers that are notified about alerts.
+//
+// The server evaluates all rules at every interval. A rule fires
+// once the value of its signal has been above its threshold for
+// the rule's duration and resolves once it no longer is. Receivers
+// are notified when a rule fires and when it resolves.
+type AlertingConfig struct {
+	// Interval is the interval in which rules are evaluated.
+	// If <= 0, defaults to 15 seconds.
+	Interval time.Duration
+
+	// Receivers are the receivers that rules notify, by name.
+	Receivers map[string]*AlertReceiver
+
+	// Rules are the alerting rules.
+	Rules []*AlertRule
+}
+
+// AlertRule is an alerting rule that compares the value of a
+// signal, like AlertDenyRate, to a threshold.
+type AlertRule struct {
+	// Name is the unique name of the rule.
+	Name string
+
+	// Signal is the signal the rule evaluates.
+	Signal string
+
+	// Backend is the backend, either "keystore" or
+	// "database/<name>", of AlertBackendUnreachable rules.
+	// If empty, defaults to "keystore".
+	Backend string
+
+	// Metric is the name of the metric, like "kes_http_request_failure",
+	// of AlertMetric rules. The values of all series of the metric
+	// whose labels contain Labels are summed up.
+	Metric string
+	Labels map[string]string
+
+	// Path is the API path pattern, like "/v1/key/delete/*", of
+	// AlertAudit rules. Patterns refer to "/v1/" API paths, like
+	// policies. If StatusCode is not zero, only audit records with
+	// this response status code are counted.
+	Path       string
+	StatusCode int
+
+	// Threshold is the value the signal has to exceed.
+	Threshold float64
+
+	// For is the duration the signal has to exceed the threshold
+	// before the rule fires. If <= 0, the rule fires once the signal
+	// exceeds the threshold.
+	For time.Duration
+
+	// Severity is the severity of alerts: "critical", "error",
+	// "warning" or "info". If empty, defaults to "critical".
+	Severity string
+
+	// Receivers are the names of the receivers notified about
+	// alerts. If empty, alerts are only logged.
+	Receivers []string
+}
+
+// AlertReceiver is a receiver of alerts. At least one of its
+// notification channels must be set.
+type AlertReceiver struct {
+	// Webhook is an HTTP endpoint that receives alerts as JSON
+	// object via POST requests.
+	Webhook string
+
+	// Email, if set, sends alerts as email.
+	Email *AlertEmailConfig
+
+	// PagerDuty, if set, sends alerts as PagerDuty events.
+	PagerDuty *AlertPagerDutyConfig
+}
+
+// AlertEmailConfig is a structure containing the configuration
+// for sending alerts via SMTP.
+type AlertEmailConfig struct {
+	// Server is the SMTP server address, like "smtp.example.com:587".
+	// The connection is upgraded to TLS if the server supports STARTTLS.
+	Server string
+
+	// From is the sender address.
+	From string
+
+	// To are the recipient addresses.
+	To []string
+
+	// Username and Password, if set, are used to authenticate
+	// to the SMTP server. Authentication requires TLS.
+	Username string
+	Password string
+}
+
+// AlertPagerDutyConfig is a structure containing the configuration
+// for sending alerts to the PagerDuty Events API v2.
+type AlertPagerDutyConfig struct {
+	// RoutingKey is the integration key of the PagerDuty service.
+	RoutingKey string
+
+	// Endpoint is the Events API endpoint. If empty, defaults
+	// to "https://events.pagerduty.com/v2/enqueue".
+	Endpoint string
+}
+
+// AuthThrottlingConfig is a structure containing the configuration
+// for throttling authentication failures.
+//
+// The server counts requests without a valid client certificate or
+// with an unknown identity per source IP address, or per /64 prefix
+// for IPv6 addresses. Each failure delays the response twice as long
+// as the previous one. Once a source exceeds its failure budget, all
+// its requests are rejected until the ban expires.
+type AuthThrottlingConfig struct {
+	// MaxFailures is the max. number of authentication failures
+	// of a source within an interval. If <= 0, defaults to 10.
+	MaxFailures int
+
+	// Interval is the interval over which authentication failures
+	// are counted. If <= 0, defaults to 1 minute.
+	Interval time.Duration
+
+	// Delay is the delay of the response to the first failure.
+	// If <= 0, defaults to 100 milliseconds.
+	Delay time.Duration
+
+	// MaxDelay is the max. delay of a response. If <= 0, defaults
+	// to 5 seconds.
+	MaxDelay time.Duration
+
+	// BanDuration is the time a source is banned once it exceeds
+	// its failure budget. If <= 0, defaults to 15 minutes.
+	BanDuration time.Duration
+}
+
+// KeyLifecycleConfig is a structure containing the scheduled
+// state transitions of keys.
+//
+// A key is "enabled" until its decrypt-only date. Then, it can
+// only be used to decrypt or unseal existing ciphertexts. Once
+// its disable date has passed, it cannot be used for any crypto
+// operation. Finally, the key is deleted on its purge date unless
+// it is under a legal hold. The state of a key is derived from
+// the dates. Hence, all servers agree on it without coordination.
+type KeyLifecycleConfig struct {
+	// Schedules maps key names, or prefix patterns like "app-*",
+	// to their schedules. If multiple patterns match a key name,
+	// an exact match takes precedence over the longest prefix.
+	Schedules map[string]*KeySchedule
+
+	// Notice is the time before a transition at which a notice
+	// is emitted. If <= 0, defaults to 7 days.
+	Notice time.Duration
+
+	// Sink is an optional HTTP endpoint. The server sends every
+	// notice as JSON object to it via a POST request.
+	Sink string
+}
+
+// KeySchedule contains the dates of the state transitions of a
+// key. Dates that are zero are skipped. At least one date must
+// be set and the dates must be in order.
+type KeySchedule struct {
+	// DecryptOnly is the date at which the key becomes decrypt-only.
+	DecryptOnly time.Time
+
+	// Disable is the date at which the key becomes disabled.
+	Disable time.Time
+
+	// Purge is the date at which the key gets deleted.
+	Purge time.Time
+}
+
+// TenantConfig is a structure containing the configuration
+// of a tenant.
+//
+// A tenant owns all keys whose names start with its prefix.
+// If the prefixes of multiple tenants match a key name, the
+// tenant with the longest prefix owns the key.
+//
+// Keys of a tenant are wrapped by the tenant's KEK. The KEK
+// is generated once the first key of the tenant is created.
+// Keys that have been created before the tenant has been
+// configured are not wrapped.
+type TenantConfig struct {
+	// Prefix is the key name prefix of the tenant's keys.
+	Prefix string
+
+	// KEKStore is the KeyStore that stores the tenant's KEK.
+	// If nil, the KEK is stored at the Config.Keys KeyStore.
+	KEKStore KeyStore
+}
+
+// RequestLogConfig is a structure containing the request log
+// configuration.
+type RequestLogConfig struct {
+	// Handler handles request log records. If nil, defaults
+	// to a slog.JSONHandler writing to os.Stderr.
+	Handler slog.Handler
+
+	// SampleRate is the fraction of successful requests that
+	// get logged. Failed requests are always logged. If <= 0
+	// or > 1, all requests are logged.
+	SampleRate float64
+
+	// Body controls whether JSON request bodies are logged.
+	Body bool
+
+	// Redact contains additional names of request headers,
+	// query parameters and JSON fields whose values are
+	// redacted. Names are case-insensitive. Headers, like
+	// Authorization, and fields containing plaintexts,
+	// ciphertexts or key material are always redacted.
+	Redact []string
+}
+
+// WriteBehindConfig is a structure containing the write-behind
+// queue configuration.
+type WriteBehindConfig struct {
+	// Dir is the directory of the queue. It is created if it
+	// does not exist. Servers, including virtual hosts, must
+	// not share a directory.
+	Dir string
+
+	// MaxSize is the max. size of the queued audit records, and
+	// of the queued usage information, in bytes. Once reached,
+	// audit records are passed to the AuditLog handler directly.
+	// If <= 0, defaults to 64 MiB.
+	MaxSize int64
+}
+
 // RouteConfig is a structure holding API route configuration.
 type RouteConfig struct {
 	// Timeout specifies when the API handler times out.
@@ -139,6 +1259,15 @@ type RouteConfig struct {
 	// client auth type has been set  tls.RequireAnyClientCert
 	// or tls.RequireAndVerifyClientCert.
 	InsecureSkipAuth bool
+
+	// Deprecation, if set, marks the v1 API route as deprecated
+	// since the given time. Responses contain a "Deprecation"
+	// header and a "Link" header pointing to the v2 API route.
+	Deprecation time.Time
+
+	// Sunset, if set, is the time after which the v1 API route
+	// may become unavailable. Responses contain a "Sunset" header.
+	Sunset time.Time
 }
 
 // verifyConfig reports whether the c is a valid Config
@@ -154,5 +1283,158 @@ func verifyConfig(c *Config) error {
 	if c.Keys == nil {
 		return errors.New("kes: config contains no key store")
 	}
+	for i, auth := range c.Authenticators {
+		if auth == nil {
+			return fmt.Errorf("kes: authenticator %d is nil", i)
+		}
+	}
+	if c.AuditPseudonymization != nil && len(c.AuditPseudonymization.Key) > 0 && len(c.AuditPseudonymization.Key) < 16 {
+		return errors.New("kes: audit pseudonymization key must be at least 16 bytes long")
+	}
+	if c.TLSSession != nil {
+		if c.TLSSession.TicketKeyRotation < 0 {
+			return errors.New("kes: TLS session ticket key rotation must not be negative")
+		}
+		if c.TLSSession.CacheSize < 0 {
+			return errors.New("kes: TLS session cache size must not be negative")
+		}
+	}
+	if c.Standby != nil {
+		if c.Standby.Primary == "" {
+			return errors.New("kes: standby config contains no primary endpoint")
+		}
+		if c.Standby.TLS == nil {
+			return errors.New("kes: standby config contains no TLS config")
+		}
+	}
+	for name, vhost := range c.VirtualHosts {
+		if name == "" || name != strings.ToLower(name) || strings.HasSuffix(name, ".") {
+			return fmt.Errorf("kes: virtual host name '%s' is not a lower-case server name", name)
+		}
+		if vhost == nil {
+			return fmt.Errorf("kes: virtual host '%s' contains no config", name)
+		}
+		if len(vhost.VirtualHosts) > 0 {
+			return fmt.Errorf("kes: virtual host '%s' contains virtual hosts", name)
+		}
+		if err := verifyConfig(vhost); err != nil {
+			return fmt.Errorf("kes: invalid virtual host '%s': %v", name, strings.TrimPrefix(err.Error(), "kes: "))
+		}
+	}
+	if c.WriteBehind != nil && c.WriteBehind.Dir == "" {
+		return errors.New("kes: write-behind config contains no directory")
+	}
+	if c.LoadShedding != nil && c.LoadShedding.MaxRequests <= 0 {
+		return errors.New("kes: load shedding config contains no max. number of requests")
+	}
+	if c.AnomalyDetection != nil {
+		if c.AnomalyDetection.MaxDenyRate > 1 {
+			return fmt.Errorf("kes: anomaly detection max. deny rate '%v' is greater than 1", c.AnomalyDetection.MaxDenyRate)
+		}
+		if c.AnomalyDetection.Sink != "" {
+			if u, err := url.Parse(c.AnomalyDetection.Sink); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("kes: anomaly detection sink '%s' is not a HTTP(S) URL", c.AnomalyDetection.Sink)
+			}
+		}
+	}
+	if c.KeyLifecycle != nil {
+		if err := verifyKeyLifecycle(c.KeyLifecycle); err != nil {
+			return err
+		}
+	}
+	if c.Alerting != nil {
+		if err := verifyAlerting(c.Alerting); err != nil {
+			return err
+		}
+	}
+	for name, db := range c.Databases {
+		if !validName(name) {
+			return fmt.Errorf("kes: database name '%s' is empty, too long or contains invalid characters", name)
+		}
+		if db == nil || db.DB == nil {
+			return fmt.Errorf("kes: database '%s' contains no database connection", name)
+		}
+		if _, ok := dbDialects[db.Dialect]; !ok && (len(db.CreationStatements) == 0 || len(db.RevocationStatements) == 0) {
+			return fmt.Errorf("kes: database '%s' contains no creation or revocation statements", name)
+		}
+	}
+	for name, role := range c.SSHRoles {
+		if !validName(name) {
+			return fmt.Errorf("kes: SSH role name '%s' is empty, too long or contains invalid characters", name)
+		}
+		if role == nil || len(role.Principals) == 0 {
+			return fmt.Errorf("kes: SSH role '%s' contains no principals", name)
+		}
+		if role.CertType != "" && role.CertType != SSHUserCert && role.CertType != SSHHostCert {
+			return fmt.Errorf("kes: SSH role '%s' contains invalid certificate type '%s'", name, role.CertType)
+		}
+		for _, pattern := range role.Principals {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("kes: SSH role '%s' contains invalid principal '%s'", name, pattern)
+			}
+		}
+	}
+	for name, t := range c.Tokenization {
+		if !validName(name) {
+			return fmt.Errorf("kes: tokenization key name '%s' is empty, too long or contains invalid characters", name)
+		}
+		if t == nil {
+			return fmt.Errorf("kes: tokenization config of key '%s' is empty", name)
+		}
+		if t.Mode != "" && t.Mode != FPEModeFF1 && t.Mode != FPEModeFF31 {
+			return fmt.Errorf("kes: tokenization mode '%s' of key '%s' is not supported", t.Mode, name)
+		}
+		if t.RevealFirst < 0 || t.RevealLast < 0 {
+			return fmt.Errorf("kes: tokenization config of key '%s' must not reveal a negative number of characters", name)
+		}
+		if utf8.RuneCountInString(t.MaskChar) > 1 {
+			return fmt.Errorf("kes: tokenization mask '%s' of key '%s' must be a single character", t.MaskChar, name)
+		}
+		alphabet := []rune(t.Alphabet)
+		if n := len(alphabet); n < 2 || n > 1<<16 {
+			return fmt.Errorf("kes: tokenization alphabet of key '%s' must contain 2 to 65536 characters", name)
+		}
+		seen := make(map[rune]bool, len(alphabet))
+		for _, r := range alphabet {
+			if seen[r] {
+				return fmt.Errorf("kes: tokenization alphabet of key '%s' contains duplicate character '%c'", name, r)
+			}
+			seen[r] = true
+		}
+		if mask := cmp.Or(t.MaskChar, "*"); seen[[]rune(mask)[0]] {
+			return fmt.Errorf("kes: tokenization mask '%s' of key '%s' must not be part of the alphabet", mask, name)
+		}
+	}
+	for name, t := range c.Tenants {
+		if !validName(name) {
+			return fmt.Errorf("kes: tenant name '%s' is empty, too long or contains invalid characters", name)
+		}
+		if t == nil || t.Prefix == "" {
+			return fmt.Errorf("kes: tenant '%s' contains no key name prefix", name)
+		}
+		if !validName(t.Prefix) && !validName(t.Prefix+"_") {
+			return fmt.Errorf("kes: tenant '%s' contains invalid key name prefix '%s'", name, t.Prefix)
+		}
+		for other, o := range c.Tenants {
+			if other != name && o != nil && o.Prefix == t.Prefix {
+				return fmt.Errorf("kes: tenants '%s' and '%s' use the same key name prefix '%s'", name, other, t.Prefix)
+			}
+		}
+	}
+	if c.PKI != nil {
+		for name, role := range c.PKI.Roles {
+			if !validName(name) {
+				return fmt.Errorf("kes: PKI role name '%s' is empty, too long or contains invalid characters", name)
+			}
+			if role == nil || len(role.Domains) == 0 {
+				return fmt.Errorf("kes: PKI role '%s' contains no domains", name)
+			}
+			for _, pattern := range role.Domains {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return fmt.Errorf("kes: PKI role '%s' contains invalid domain '%s'", name, pattern)
+				}
+			}
+		}
+	}
 	return nil
 }
@@ -0,0 +1,229 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+// connContextKey is the context key of a request's *clientConn.
+type connContextKey struct{}
+
+// clientConn is a client connection and the identities
+// that have sent requests over it.
+type clientConn struct {
+	conn        net.Conn
+	connectedAt time.Time
+	closed      atomic.Bool
+
+	mu          sync.Mutex
+	identities  map[kes.Identity]uint64 // Identity -> number of requests
+	requests    uint64
+	lastRequest time.Time
+}
+
+// Close closes the underlying network connection.
+func (c *clientConn) Close() error {
+	c.closed.Store(true)
+	return c.conn.Close()
+}
+
+// seen records a request of the given identity. Requests of
+// unknown identities, e.g. to the version API, are counted
+// but not attributed.
+func (c *clientConn) seen(identity kes.Identity, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests++
+	c.lastRequest = at
+	if identity.IsUnknown() {
+		return
+	}
+	if c.identities == nil {
+		c.identities = map[kes.Identity]uint64{}
+	}
+	c.identities[identity]++
+}
+
+// has reports whether the identity sent requests over c.
+func (c *clientConn) has(identity kes.Identity) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.identities[identity]
+	return ok
+}
+
+// connTracker tracks the open client connections of a server.
+//
+// Connections are accepted by the server that owns the listener
+// but are tracked by the server, or virtual server, that handles
+// their requests. Hence, admins only see the connections of
+// clients of their own server.
+type connTracker struct {
+	open sync.Map // net.Conn -> *clientConn, of the listener owning server
+
+	mu    sync.Mutex
+	conns map[*clientConn]struct{}
+}
+
+// Accept returns a context for the new connection c that
+// carries its *clientConn. It is used as http.Server.ConnContext.
+func (t *connTracker) Accept(ctx context.Context, c net.Conn) context.Context {
+	conn := &clientConn{conn: c, connectedAt: time.Now()}
+	t.open.Store(c, conn)
+	return context.WithValue(ctx, connContextKey{}, conn)
+}
+
+// StateChanged marks closed connections as such. It is used
+// as http.Server.ConnState.
+func (t *connTracker) StateChanged(c net.Conn, state http.ConnState) {
+	if state != http.StateClosed && state != http.StateHijacked {
+		return
+	}
+	if conn, ok := t.open.LoadAndDelete(c); ok {
+		conn.(*clientConn).closed.Store(true)
+	}
+}
+
+// Add starts tracking the connection, if not already tracked,
+// and removes closed connections.
+func (t *connTracker) Add(conn *clientConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.conns[conn]; ok {
+		return
+	}
+	if t.conns == nil {
+		t.conns = map[*clientConn]struct{}{}
+	}
+	for c := range t.conns {
+		if c.closed.Load() {
+			delete(t.conns, c)
+		}
+	}
+	t.conns[conn] = struct{}{}
+}
+
+// List returns all open connections ordered by connection time.
+func (t *connTracker) List() []*clientConn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	conns := make([]*clientConn, 0, len(t.conns))
+	for c := range t.conns {
+		if c.closed.Load() {
+			delete(t.conns, c)
+			continue
+		}
+		conns = append(conns, c)
+	}
+	slices.SortFunc(conns, func(a, b *clientConn) int { return a.connectedAt.Compare(b.connectedAt) })
+	return conns
+}
+
+// Find returns all open connections over which the identity
+// has sent requests.
+func (t *connTracker) Find(identity kes.Identity) []*clientConn {
+	conns := t.List()
+	return slices.DeleteFunc(conns, func(c *clientConn) bool { return !c.has(identity) })
+}
+
+// trackConn returns the route with a handler that attributes the
+// request to the client connection it has been sent over. It runs
+// once the request has been authenticated.
+func (s *Server) trackConn(route api.Route) api.Route {
+	handler := route.Handler
+	route.Handler = api.HandlerFunc(func(resp *api.Response, req *api.Request) {
+		if conn, ok := req.Context().Value(connContextKey{}).(*clientConn); ok {
+			conn.seen(req.Identity, time.Now())
+			s.conns.Add(conn)
+		}
+		handler.ServeAPI(resp, req)
+	})
+	return route
+}
+
+// listConns lists all open client connections. Only the
+// admin may list connections.
+func (s *Server) listConns(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	if req.Identity != state.Admin {
+		resp.Fail(http.StatusForbidden, "listing connections requires the admin identity")
+		return
+	}
+
+	now := time.Now()
+	conns := s.conns.List()
+	list := make([]api.ConnResponse, 0, len(conns))
+	for _, c := range conns {
+		c.mu.Lock()
+		identities := slices.Sorted(maps.Keys(c.identities))
+		info := api.ConnResponse{
+			Addr:        c.conn.RemoteAddr().String(),
+			ConnectedAt: c.connectedAt,
+			Age:         now.Sub(c.connectedAt).Round(time.Second).String(),
+			Requests:    c.requests,
+			LastRequest: c.lastRequest,
+		}
+		for _, id := range identities {
+			info.Identities = append(info.Identities, api.ConnIdentityResponse{
+				Identity: id.String(),
+				Requests: c.identities[id],
+			})
+		}
+		c.mu.Unlock()
+		list = append(list, info)
+	}
+	api.ReplyWith(resp, http.StatusOK, api.ListConnsResponse{Conns: list})
+}
+
+// closeConns closes all open client connections of an identity,
+// e.g. during incident response. Clients may reconnect unless the
+// identity gets removed or its policy gets changed. Only the admin
+// may close connections.
+func (s *Server) closeConns(resp *api.Response, req *api.Request) {
+	identity := kes.Identity(req.Resource)
+	if identity.IsUnknown() {
+		resp.Fail(http.StatusBadRequest, "identity is empty")
+		return
+	}
+
+	state := s.state.Load()
+	if req.Identity != state.Admin {
+		resp.Fail(http.StatusForbidden, "closing connections requires the admin identity")
+		return
+	}
+
+	self, _ := req.Context().Value(connContextKey{}).(*clientConn)
+	conns := s.conns.Find(identity)
+	for _, c := range conns {
+		if c != self {
+			c.Close()
+		}
+	}
+
+	// The connection of this request may be one of the identity's
+	// connections as well. It gets closed once the response is sent.
+	if slices.Contains(conns, self) {
+		resp.Header().Set("Connection", "close")
+	}
+	const StatusOK = http.StatusOK
+	state.Audit.Log(fmt.Sprintf("closed %d connections of identity '%s'", len(conns), identity), StatusOK, req)
+	api.ReplyWith(resp, StatusOK, api.CloseConnsResponse{Closed: len(conns)})
+}
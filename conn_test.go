@@ -0,0 +1,90 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestConns(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	cert, _ := newRenewalCertificate(t, time.Hour)
+	identity := renewalIdentity(cert.Leaf)
+	srv, url := startServer(ctx, &Config{
+		Policies: map[string]Policy{
+			"app": {
+				Allow:      map[string]kes.Rule{api.PathKeyCreate + "*": {}, api.PathConnList: {}},
+				Identities: []kes.Identity{kes.Identity(identity)},
+			},
+		},
+	})
+	defer srv.Close()
+
+	app := renewalClient(url, cert)
+	if err := app.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	admin := &defaultClient(url).HTTPClient
+	listConns := func() []api.ConnResponse {
+		resp := sendRequest(ctx, t, admin, http.MethodGet, url+api.PathConnList, nil, nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Failed to list connections: status '%d'", resp.StatusCode)
+		}
+		var list api.ListConnsResponse
+		if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+			t.Fatalf("Failed to decode connections: %v", err)
+		}
+		return list.Conns
+	}
+	hasIdentity := func(conns []api.ConnResponse) bool {
+		return slices.ContainsFunc(conns, func(c api.ConnResponse) bool {
+			return slices.ContainsFunc(c.Identities, func(id api.ConnIdentityResponse) bool { return id.Identity == identity })
+		})
+	}
+
+	conns := listConns()
+	if !hasIdentity(conns) {
+		t.Fatalf("Connection of identity '%s' not listed: got '%+v'", identity, conns)
+	}
+
+	// Only the admin may list or close connections.
+	resp := sendRequest(ctx, t, &app.HTTPClient, http.MethodGet, url+api.PathConnList, nil, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Listing connections as non-admin: got status '%d' - want '%d'", resp.StatusCode, http.StatusForbidden)
+	}
+
+	resp = sendRequest(ctx, t, admin, http.MethodDelete, url+api.PathConnClose+identity, nil, nil)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to close connections: status '%d'", resp.StatusCode)
+	}
+	var closed api.CloseConnsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&closed); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if closed.Closed != 1 {
+		t.Fatalf("Invalid number of closed connections: got '%d' - want '%d'", closed.Closed, 1)
+	}
+	if conns = listConns(); hasIdentity(conns) {
+		t.Fatalf("Closed connection of identity '%s' still listed: got '%+v'", identity, conns)
+	}
+
+	// Clients may reconnect.
+	if err := app.CreateKey(ctx, "my-key-2"); err != nil {
+		t.Fatalf("Failed to create key after reconnecting: %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kms-go/kes"
+)
+
+// cosignature is a cosignature as sent by clients in the
+// "Cosignature" header. It is encoded as base64 JSON.
+type cosignature struct {
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Identity  string    `json:"identity"`
+	Nonce     []byte    `json:"nonce"`
+	NotAfter  time.Time `json:"not_after"`
+	Signature []byte    `json:"signature"`
+}
+
+// message returns the signed cosignature message.
+func (c *cosignature) message() *crypto.Cosignature {
+	return &crypto.Cosignature{
+		Method:   c.Method,
+		Path:     c.Path,
+		Identity: c.Identity,
+		Nonce:    c.Nonce,
+		NotAfter: c.NotAfter,
+	}
+}
+
+// String returns the header representation of the cosignature.
+func (c *cosignature) String() string {
+	b, _ := json.Marshal(c)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// parseCosignature parses the header representation
+// of a cosignature.
+func parseCosignature(s string) (*cosignature, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c cosignature
+	if err = json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// cosigning verifies that requests of high-risk operations carry
+// a valid cosignature of a witness KES server.
+type cosigning struct {
+	witnesses []ed25519.PublicKey
+	paths     *kes.Policy // Only uses the allow rules
+
+	mu   sync.Mutex
+	seen map[string]time.Time // Nonce -> expiry
+}
+
+// newCosigning returns a new cosigning for the given configuration.
+// It returns nil if conf is nil. Nonces of cosignatures seen by old,
+// if not nil, are rejected by the returned cosigning as well.
+func newCosigning(conf *CosigningConfig, old *cosigning) (*cosigning, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	if len(conf.Witnesses) == 0 {
+		return nil, errors.New("kes: invalid cosigning config: no witness specified")
+	}
+	for i, pub := range conf.Witnesses {
+		if len(pub) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("kes: invalid cosigning config: witness %d: invalid Ed25519 public key", i)
+		}
+	}
+
+	paths := conf.Paths
+	if len(paths) == 0 {
+		paths = DefaultCosignPaths
+	}
+	c := &cosigning{
+		witnesses: slices.Clone(conf.Witnesses),
+		paths:     &kes.Policy{Allow: make(map[string]kes.Rule, len(paths))},
+		seen:      map[string]time.Time{},
+	}
+	for _, pattern := range paths {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("kes: invalid cosigning config: invalid path '%s'", pattern)
+		}
+		c.paths.Allow[pattern] = kes.Rule{}
+	}
+	if old != nil {
+		old.mu.Lock()
+		for nonce, expiry := range old.seen {
+			c.seen[nonce] = expiry
+		}
+		old.mu.Unlock()
+	}
+	return c, nil
+}
+
+// Verify returns an error if the request is a high-risk operation
+// that does not carry a valid cosignature for the given identity.
+// Each cosignature is accepted only once.
+func (c *cosigning) Verify(req *http.Request, identity kes.Identity) api.Error {
+	if c == nil || c.paths.Verify(req) != nil {
+		return nil
+	}
+
+	value := req.Header.Get(headers.Cosignature)
+	if value == "" {
+		return api.NewError(http.StatusForbidden, "operation requires a cosignature of a witness")
+	}
+	cosig, err := parseCosignature(value)
+	if err != nil {
+		return api.NewError(http.StatusBadRequest, "invalid cosignature")
+	}
+
+	now := time.Now()
+	switch {
+	case cosig.Method != req.Method || cosig.Path != req.URL.Path:
+		return api.NewError(http.StatusForbidden, "cosignature does not approve this operation")
+	case cosig.Identity != identity.String():
+		return api.NewError(http.StatusForbidden, "cosignature does not approve this identity")
+	case !now.Before(cosig.NotAfter):
+		return api.NewError(http.StatusForbidden, "cosignature has expired")
+	case cosig.NotAfter.After(now.Add(MaxCosignValidity)):
+		return api.NewError(http.StatusForbidden, "cosignature is valid for too long")
+	}
+
+	msg := cosig.message().Message()
+	if !slices.ContainsFunc(c.witnesses, func(pub ed25519.PublicKey) bool { return ed25519.Verify(pub, msg, cosig.Signature) }) {
+		return api.NewError(http.StatusForbidden, "cosignature is not signed by a witness")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for nonce, expiry := range c.seen {
+		if !now.Before(expiry) {
+			delete(c.seen, nonce)
+		}
+	}
+	nonce := string(cosig.Nonce)
+	if _, ok := c.seen[nonce]; ok {
+		return api.NewError(http.StatusForbidden, "cosignature has already been used")
+	}
+	c.seen[nonce] = cosig.NotAfter
+	return nil
+}
+
+// witness cosigns high-risk operations for other KES servers.
+type witness struct {
+	validity time.Duration
+}
+
+// newWitness returns a new witness for the given configuration.
+// It returns nil if conf is nil.
+func newWitness(conf *WitnessConfig) (*witness, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	if conf.Validity > MaxCosignValidity {
+		return nil, fmt.Errorf("kes: invalid witness config: validity exceeds %v", MaxCosignValidity)
+	}
+	validity := conf.Validity
+	if validity <= 0 {
+		validity = DefaultCosignValidity
+	}
+	return &witness{validity: validity}, nil
+}
+
+// cosign countersigns a high-risk operation for another KES server.
+// The client's policy, at this server, must allow the operation.
+// The admin may cosign any operation.
+//
+// A client may request a cosignature for another identity, for
+// example when an approver cosigns an operation of an operator.
+func (s *Server) cosign(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	if state.Witness == nil {
+		resp.Fail(http.StatusNotImplemented, "server is not a witness")
+		return
+	}
+
+	var body api.CosignRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid cosign request body")
+		return
+	}
+	if body.Method == "" || !strings.HasPrefix(body.Path, "/v1/") {
+		resp.Fail(http.StatusBadRequest, "invalid cosign request: method and API path required")
+		return
+	}
+	if body.Identity == "" {
+		body.Identity = req.Identity.String()
+	}
+
+	if req.Identity != state.Admin {
+		// The policy is evaluated against the operation to cosign.
+		// Hence, the witness's admins decide which operations get
+		// approved, for example "/v1/key/shred/tenant-a-*".
+		policy, ok := state.Identities[req.Identity]
+		if !ok {
+			resp.Failr(kes.ErrNotAllowed)
+			return
+		}
+		op, err := http.NewRequestWithContext(req.Context(), body.Method, body.Path, http.NoBody)
+		if err != nil || policy.Verify(op) != nil {
+			state.Log.DebugContext(req.Context(), fmt.Sprintf("cosign denied: operation rejected by policy '%s'", policy.Name), "req", req)
+			resp.Failr(kes.ErrNotAllowed)
+			return
+		}
+	}
+
+	priv, err := loadMerkleKey(req.Context(), state.Keys.store)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to load signing key")
+		return
+	}
+
+	cosig := &cosignature{
+		Method:   body.Method,
+		Path:     body.Path,
+		Identity: body.Identity,
+		Nonce:    make([]byte, 16),
+		NotAfter: time.Now().Add(state.Witness.validity).UTC(),
+	}
+	if _, err = rand.Read(cosig.Nonce); err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to generate nonce")
+		return
+	}
+	cosig.Signature = ed25519.Sign(priv, cosig.message().Message())
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(fmt.Sprintf("cosigned '%s %s' for identity '%s'", body.Method, body.Path, body.Identity), StatusOK, req)
+	api.ReplyWith(resp, StatusOK, api.CosignResponse{
+		Cosignature: cosig.String(),
+		NotAfter:    cosig.NotAfter,
+		PublicKey:   priv.Public().(ed25519.PublicKey),
+	})
+}
@@ -0,0 +1,121 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestCosigning(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	approverCert, _ := newRenewalCertificate(t, time.Hour)
+	witness, witnessURL := startServer(ctx, &Config{
+		Witness: &WitnessConfig{},
+		Policies: map[string]Policy{
+			"approver": {
+				Allow:      map[string]kes.Rule{api.PathKeyShred + "approved-*": {}},
+				Identities: []kes.Identity{kes.Identity(renewalIdentity(approverCert.Leaf))},
+			},
+		},
+	})
+	defer witness.Close()
+
+	cosign := func(client *http.Client, path string) (api.CosignResponse, int) {
+		body, _ := json.Marshal(api.CosignRequest{
+			Method:   http.MethodDelete,
+			Path:     path,
+			Identity: defaultIdentity,
+		})
+		resp := sendRequest(ctx, t, client, http.MethodPut, witnessURL+api.PathWitnessCosign, body, nil)
+		defer resp.Body.Close()
+
+		var cosig api.CosignResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&cosig); err != nil {
+				t.Fatalf("Failed to decode cosign response: %v", err)
+			}
+		}
+		return cosig, resp.StatusCode
+	}
+
+	approver := &renewalClient(witnessURL, approverCert).HTTPClient
+	if _, status := cosign(approver, api.PathKeyShred+"other-key"); status != http.StatusForbidden {
+		t.Fatalf("Cosigned operation not allowed by policy: got status '%d' - want '%d'", status, http.StatusForbidden)
+	}
+	cosig, status := cosign(approver, api.PathKeyShred+"approved-key")
+	if status != http.StatusOK {
+		t.Fatalf("Failed to cosign operation: status '%d'", status)
+	}
+
+	srv, url := startServer(ctx, &Config{
+		Cosigning: &CosigningConfig{Witnesses: []ed25519.PublicKey{cosig.PublicKey}},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	for _, name := range []string{"approved-key", "other-key"} {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+
+	shred := func(name, cosignature string) int {
+		h := http.Header{}
+		if cosignature != "" {
+			h.Set(headers.Cosignature, cosignature)
+		}
+		resp := sendRequest(ctx, t, &client.HTTPClient, http.MethodDelete, url+api.PathKeyShred+name, nil, h)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := shred("approved-key", ""); status != http.StatusForbidden {
+		t.Fatalf("Shredded key without cosignature: got status '%d' - want '%d'", status, http.StatusForbidden)
+	}
+	if status := shred("other-key", cosig.Cosignature); status != http.StatusForbidden {
+		t.Fatalf("Shredded key with cosignature of another operation: got status '%d' - want '%d'", status, http.StatusForbidden)
+	}
+	if status := shred("approved-key", cosig.Cosignature); status != http.StatusOK {
+		t.Fatalf("Failed to shred key with cosignature: status '%d'", status)
+	}
+
+	if err := client.CreateKey(ctx, "approved-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if status := shred("approved-key", cosig.Cosignature); status != http.StatusForbidden {
+		t.Fatalf("Reused cosignature: got status '%d' - want '%d'", status, http.StatusForbidden)
+	}
+
+	// Operations that are not high-risk don't require a cosignature.
+	if err := client.DeleteKey(ctx, "other-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+}
+
+func sendRequest(ctx context.Context, t *testing.T, client *http.Client, method, url string, body []byte, h http.Header) *http.Response {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	return resp
+}
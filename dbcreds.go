@@ -0,0 +1,346 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+// Default lease durations of database credentials.
+const (
+	defaultDBTTL = 1 * time.Hour
+	maxDBTTL     = 24 * time.Hour
+)
+
+// leasePrefix is the prefix of all database lease entries at the
+// key store. Like lock entries, they cannot collide with keys.
+const leasePrefix = "-dblease-"
+
+// dbRevokeInterval is the interval in which expired database
+// leases get revoked.
+const dbRevokeInterval = 10 * time.Second
+
+// dbDialects contains the default creation and revocation
+// statements of the supported SQL dialects.
+var dbDialects = map[string]struct {
+	Creation   []string
+	Revocation []string
+}{
+	"postgres": {
+		Creation:   []string{`CREATE ROLE "{{name}}" WITH LOGIN PASSWORD '{{password}}' VALID UNTIL '{{expiration}}';`},
+		Revocation: []string{`DROP ROLE IF EXISTS "{{name}}";`},
+	},
+	"mysql": {
+		Creation:   []string{`CREATE USER '{{name}}'@'%' IDENTIFIED BY '{{password}}';`},
+		Revocation: []string{`DROP USER IF EXISTS '{{name}}'@'%';`},
+	},
+}
+
+// dbEngine issues short-lived users for one database.
+type dbEngine struct {
+	db         *sql.DB
+	creation   []string
+	revocation []string
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+}
+
+// newDBEngines returns the database engines for the given
+// database configurations.
+func newDBEngines(conf map[string]*DatabaseConfig) map[string]*dbEngine {
+	engines := make(map[string]*dbEngine, len(conf))
+	for name, c := range conf {
+		e := &dbEngine{
+			db:         c.DB,
+			creation:   c.CreationStatements,
+			revocation: c.RevocationStatements,
+			defaultTTL: c.DefaultTTL,
+			maxTTL:     c.MaxTTL,
+		}
+		if dialect, ok := dbDialects[c.Dialect]; ok {
+			if len(e.creation) == 0 {
+				e.creation = dialect.Creation
+			}
+			if len(e.revocation) == 0 {
+				e.revocation = dialect.Revocation
+			}
+		}
+		if e.defaultTTL <= 0 {
+			e.defaultTTL = defaultDBTTL
+		}
+		if e.maxTTL <= 0 {
+			e.maxTTL = maxDBTTL
+		}
+		engines[name] = e
+	}
+	return engines
+}
+
+// exec executes the statements after replacing the placeholders.
+func (e *dbEngine) exec(ctx context.Context, statements []string, l *dbLease, password string) error {
+	r := strings.NewReplacer(
+		"{{name}}", l.Username,
+		"{{password}}", password,
+		"{{expiration}}", l.ExpiresAt.Format(time.RFC3339),
+	)
+	for _, stmt := range statements {
+		if _, err := e.db.ExecContext(ctx, r.Replace(stmt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A dbLease is a database user issued to an identity. It
+// is stored as JSON at the key store until it gets revoked.
+type dbLease struct {
+	Database  string       `json:"database"`
+	Username  string       `json:"username"`
+	Holder    kes.Identity `json:"holder"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
+
+func readLease(ctx context.Context, store KeyStore, id string) (*dbLease, error) {
+	b, err := store.Get(ctx, leasePrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	var l dbLease
+	if err = json.Unmarshal(b, &l); err != nil {
+		return nil, fmt.Errorf("invalid database lease '%s': %v", id, err)
+	}
+	return &l, nil
+}
+
+// randomString returns a random alphanumeric string of length n.
+func randomString(n int) (string, error) {
+	const Chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = Chars[int(b[i])%len(Chars)]
+	}
+	return string(b), nil
+}
+
+// revokeLease executes the revocation statements of the lease's
+// database and removes the lease from the key store.
+func (s *Server) revokeLease(ctx context.Context, state *serverState, id string, l *dbLease) error {
+	s.leases.Lock(id)
+	defer s.leases.Unlock(id)
+
+	e, ok := state.Databases[l.Database]
+	if !ok {
+		return fmt.Errorf("database '%s' does not exist", l.Database)
+	}
+	if err := e.exec(ctx, e.revocation, l, ""); err != nil {
+		return err
+	}
+	if err := state.Keys.store.Delete(ctx, leasePrefix+id); err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+		return err
+	}
+	return nil
+}
+
+// revokeExpiredLeases revokes all leases that have expired at now.
+func (s *Server) revokeExpiredLeases(ctx context.Context, now time.Time) {
+	state := s.state.Load()
+	if len(state.Databases) == 0 {
+		return
+	}
+
+	names, _, err := state.Keys.store.List(ctx, leasePrefix, -1)
+	if err != nil {
+		state.Log.WarnContext(ctx, fmt.Sprintf("failed to list database leases: %v", err))
+		return
+	}
+	for _, name := range names {
+		id := strings.TrimPrefix(name, leasePrefix)
+		l, err := readLease(ctx, state.Keys.store, id)
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			continue // Revoked in the meantime
+		}
+		if err != nil {
+			state.Log.WarnContext(ctx, fmt.Sprintf("failed to read database lease '%s': %v", id, err))
+			continue
+		}
+		if now.Before(l.ExpiresAt) {
+			continue
+		}
+		if err = s.revokeLease(ctx, state, id, l); err != nil {
+			state.Log.WarnContext(ctx, fmt.Sprintf("failed to revoke database user '%s' of '%s': %v", l.Username, l.Database, err))
+			continue
+		}
+		state.Log.InfoContext(ctx, fmt.Sprintf("database user '%s' of '%s' expired and has been revoked", l.Username, l.Database), "holder", l.Holder)
+	}
+}
+
+// startLeaseRevoker revokes expired database leases
+// in the background until ctx is done.
+func (s *Server) startLeaseRevoker(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(dbRevokeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.revokeExpiredLeases(ctx, now)
+			}
+		}
+	}()
+}
+
+// issueDBCredentials creates a new database user for the requesting
+// identity. The user gets revoked once its lease expires.
+func (s *Server) issueDBCredentials(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	e, ok := state.Databases[req.Resource]
+	if !ok {
+		resp.Failf(http.StatusNotFound, "database '%s' does not exist", req.Resource)
+		return
+	}
+
+	var body api.DBCredentialsRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid database credentials request body")
+		return
+	}
+	ttl := e.defaultTTL
+	if body.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(body.TTL); err != nil || ttl <= 0 {
+			resp.Failf(http.StatusBadRequest, "invalid lease TTL '%s'", body.TTL)
+			return
+		}
+		if ttl > e.maxTTL {
+			resp.Failf(http.StatusBadRequest, "invalid lease TTL '%v': must not exceed %v", ttl, e.maxTTL)
+			return
+		}
+	}
+
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to generate database credentials")
+		return
+	}
+	suffix, err := randomString(16)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to generate database credentials")
+		return
+	}
+	password, err := randomString(32)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to generate database credentials")
+		return
+	}
+
+	leaseID := hex.EncodeToString(id[:])
+	l := &dbLease{
+		Database:  req.Resource,
+		Username:  "kes_" + strings.ToLower(suffix),
+		Holder:    req.Identity,
+		ExpiresAt: time.Now().UTC().Add(ttl).Truncate(time.Second),
+	}
+	b, err := json.Marshal(l)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to generate database credentials")
+		return
+	}
+
+	// Store the lease before creating the user. Otherwise, the user
+	// would never be revoked when storing the lease fails.
+	if err = state.Keys.store.Create(req.Context(), leasePrefix+leaseID, b); err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to store database lease")
+		return
+	}
+	if err = e.exec(req.Context(), e.creation, l, password); err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		if rErr := s.revokeLease(context.WithoutCancel(req.Context()), state, leaseID, l); rErr != nil {
+			state.Log.ErrorContext(req.Context(), rErr.Error(), "req", req)
+		}
+		resp.Failf(http.StatusBadGateway, "failed to create database user for '%s'", req.Resource)
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(
+		fmt.Sprintf("database user '%s' of '%s' issued until %s", l.Username, req.Resource, l.ExpiresAt.Format(time.RFC3339)),
+		StatusOK,
+		req,
+	)
+	api.ReplyWith(resp, StatusOK, api.DBCredentialsResponse{
+		LeaseID:   leaseID,
+		Username:  l.Username,
+		Password:  password,
+		ExpiresAt: l.ExpiresAt,
+	})
+}
+
+// revokeDBCredentials revokes a database user before its lease
+// expires. Only the lease holder or the admin may revoke it.
+func (s *Server) revokeDBCredentials(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+
+	id := req.Resource
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 32 {
+		resp.Failf(http.StatusBadRequest, "invalid lease ID '%s'", id)
+		return
+	}
+	l, err := readLease(req.Context(), state.Keys.store, id)
+	if err != nil {
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			resp.Failf(http.StatusNotFound, "lease '%s' does not exist", id)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to read database lease")
+		return
+	}
+	if req.Identity != l.Holder && req.Identity != state.Admin {
+		resp.Failf(http.StatusForbidden, "lease '%s' is held by another identity", id)
+		return
+	}
+	if err = s.revokeLease(req.Context(), state, id, l); err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Failf(http.StatusBadGateway, "failed to revoke database user '%s'", l.Username)
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(
+		fmt.Sprintf("database user '%s' of '%s' revoked", l.Username, l.Database),
+		StatusOK,
+		req,
+	)
+	resp.Reply(StatusOK)
+}
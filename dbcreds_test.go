@@ -0,0 +1,144 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+)
+
+func TestDBCredentials(t *testing.T) {
+	t.Parallel()
+
+	db, statements := openRecordingDB(t)
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		Databases: map[string]*DatabaseConfig{
+			"my-db": {
+				DB:                 db,
+				Dialect:            "postgres",
+				CreationStatements: []string{`CREATE ROLE "{{name}}" WITH LOGIN PASSWORD '{{password}}';`, `GRANT SELECT ON ALL TABLES IN SCHEMA public TO "{{name}}";`},
+			},
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	issue := func(ttl string) (api.DBCredentialsResponse, int) {
+		body, _ := json.Marshal(api.DBCredentialsRequest{TTL: ttl})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, url+api.PathDBCredentials+"my-db", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to issue database credentials: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var creds api.DBCredentialsResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&creds); err != nil {
+				t.Fatalf("Failed to decode database credentials: %v", err)
+			}
+		}
+		return creds, resp.StatusCode
+	}
+
+	if _, code := issue("48h"); code != http.StatusBadRequest {
+		t.Fatalf("Issuing credentials exceeding the max. TTL should fail with '%d' - got '%d'", http.StatusBadRequest, code)
+	}
+
+	creds, code := issue("")
+	if code != http.StatusOK {
+		t.Fatalf("Failed to issue database credentials: got status '%d'", code)
+	}
+	if stmts := statements(); len(stmts) != 2 || !strings.Contains(stmts[0], creds.Username) || !strings.Contains(stmts[0], creds.Password) {
+		t.Fatalf("Invalid creation statements: got '%v'", stmts)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url+api.PathDBRevoke+creds.LeaseID, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to revoke database credentials: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to revoke database credentials: got status '%d'", resp.StatusCode)
+	}
+	if stmts := statements(); len(stmts) != 3 || stmts[2] != `DROP ROLE IF EXISTS "`+creds.Username+`";` {
+		t.Fatalf("Invalid revocation statements: got '%v'", stmts)
+	}
+
+	creds, code = issue("1h")
+	if code != http.StatusOK {
+		t.Fatalf("Failed to issue database credentials: got status '%d'", code)
+	}
+	srv.revokeExpiredLeases(ctx, time.Now())
+	if stmts := statements(); len(stmts) != 5 {
+		t.Fatalf("Unexpired credentials should not be revoked: got '%v'", stmts)
+	}
+	srv.revokeExpiredLeases(ctx, creds.ExpiresAt)
+	if stmts := statements(); len(stmts) != 6 || !strings.Contains(stmts[5], creds.Username) {
+		t.Fatalf("Expired credentials should be revoked: got '%v'", stmts)
+	}
+}
+
+// openRecordingDB returns a database that records all executed
+// statements instead of executing them.
+func openRecordingDB(t *testing.T) (*sql.DB, func() []string) {
+	c := &recordingConn{}
+	db := sql.OpenDB(c)
+	t.Cleanup(func() { db.Close() })
+
+	return db, func() []string {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return append([]string(nil), c.statements...)
+	}
+}
+
+// recordingConn is a driver.Connector and driver.Conn
+// that records executed statements.
+type recordingConn struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+func (c *recordingConn) Connect(context.Context) (driver.Conn, error) { return c, nil }
+
+func (c *recordingConn) Driver() driver.Driver { return nil }
+
+func (c *recordingConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("recording: prepared statements are not supported")
+}
+
+func (c *recordingConn) Close() error { return nil }
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("recording: transactions are not supported")
+}
+
+func (c *recordingConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.statements = append(c.statements, query)
+	return driver.RowsAffected(0), nil
+}
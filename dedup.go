@@ -0,0 +1,388 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+// dedupHeader is the prefix of all key store entries that refer
+// to a shared value instead of containing the value itself.
+var dedupHeader = []byte("kes\x00dedup\x01")
+
+// dedupPrefix is the prefix of shared values and their reference
+// counts at the key store. It is followed by the content ID and
+// the generation of the shared value. Like chunks, shared values
+// never collide with keys.
+const dedupPrefix = "-dedup-"
+
+// maxDedupAttempts is the max. number of attempts to add or
+// remove a reference to a shared value that is modified
+// concurrently.
+const maxDedupAttempts = 16
+
+// A dedupRef is stored, after the dedupHeader, as JSON instead
+// of a value that is shared with other entries.
+//
+// The shared value is encrypted with a key derived from the value
+// itself. Each entry contains this key. Hence, a shared value can
+// only be read through an entry that refers to it, and each entry
+// is sealed or wrapped by a tenant KEK on its own.
+type dedupRef struct {
+	ID  string `json:"id"`
+	Gen int    `json:"gen"`
+	Key []byte `json:"key"`
+}
+
+// Name returns the name of the reference count entry
+// of the shared value.
+func (r *dedupRef) Name() string { return dedupPrefix + r.ID + "-" + strconv.Itoa(r.Gen) }
+
+// ValueName returns the name of the entry containing
+// the encrypted shared value.
+func (r *dedupRef) ValueName() string { return r.Name() + "-value" }
+
+// dedupCount is the reference count of a shared value. A count
+// of zero marks a shared value that is being deleted. It is
+// never referenced again. Instead, a new generation is created.
+type dedupCount struct {
+	Refs int `json:"refs"`
+}
+
+// dedupStore is a KeyStore that stores identical values of
+// different entries only once at the underlying KeyStore.
+type dedupStore struct {
+	KeyStore
+	minSize int
+}
+
+// newDedupStore returns a KeyStore that stores identical values
+// only once at store. It returns store if conf is nil or store
+// does not support conditional writes, which are required to
+// count the references to shared values.
+func newDedupStore(store KeyStore, conf *DedupConfig) KeyStore {
+	if conf == nil || store == nil || !canSwap(store) {
+		return store
+	}
+	minSize := conf.MinSize
+	if minSize <= 0 {
+		minSize = DefaultDedupMinSize
+	}
+	return &dedupStore{KeyStore: store, minSize: minSize}
+}
+
+// Create creates the entry. If the value is not smaller than the
+// min. size, it adds a reference to the shared value before
+// creating the entry itself.
+func (s *dedupStore) Create(ctx context.Context, name string, value []byte) error {
+	stored, ref, err := s.share(ctx, value)
+	if err != nil {
+		return err
+	}
+	if err = s.KeyStore.Create(ctx, name, stored); err != nil {
+		s.release(ctx, ref)
+		return err
+	}
+	return nil
+}
+
+// CanOverwrite reports whether the underlying KeyStore
+// can replace existing entries.
+func (s *dedupStore) CanOverwrite() bool {
+	store, ok := s.KeyStore.(OverwriteKeyStore)
+	return ok && store.CanOverwrite()
+}
+
+// Set creates or replaces the entry. The reference to the shared
+// value of the replaced entry is removed once it has been replaced.
+func (s *dedupStore) Set(ctx context.Context, name string, value []byte) error {
+	old, err := s.KeyStore.Get(ctx, name)
+	if err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+		return err
+	}
+
+	stored, ref, err := s.share(ctx, value)
+	if err != nil {
+		return err
+	}
+	if err = setEntry(ctx, s.KeyStore, name, stored); err != nil {
+		s.release(ctx, ref)
+		return err
+	}
+	s.releaseOf(ctx, name, old)
+	return nil
+}
+
+// CanSwap reports whether the underlying KeyStore
+// supports conditional writes.
+func (s *dedupStore) CanSwap() bool { return canSwap(s.KeyStore) }
+
+// Swap replaces the entry if its value, resolved from its shared
+// value, is equal to old. The entry itself is replaced with a
+// conditional write.
+func (s *dedupStore) Swap(ctx context.Context, name string, old, value []byte) error {
+	stored, err := s.KeyStore.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	current, err := s.resolve(ctx, name, stored)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, old) {
+		return kes.ErrKeyExists
+	}
+
+	replacement, ref, err := s.share(ctx, value)
+	if err != nil {
+		return err
+	}
+	if err = swapEntry(ctx, s.KeyStore, name, stored, replacement); err != nil {
+		s.release(ctx, ref)
+		return err
+	}
+	s.releaseOf(ctx, name, stored)
+	return nil
+}
+
+// Delete removes the entry and its reference to a shared value.
+func (s *dedupStore) Delete(ctx context.Context, name string) error {
+	stored, err := s.KeyStore.Get(ctx, name)
+	if err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+		return err
+	}
+	if err = s.KeyStore.Delete(ctx, name); err != nil {
+		return err
+	}
+	s.releaseOf(ctx, name, stored)
+	return nil
+}
+
+// Get returns the value of the entry resolved from its
+// shared value.
+func (s *dedupStore) Get(ctx context.Context, name string) ([]byte, error) {
+	value, err := s.KeyStore.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.resolve(ctx, name, value)
+}
+
+// GetBulk returns the values of the given entries resolved
+// from their shared values.
+func (s *dedupStore) GetBulk(ctx context.Context, names []string) (map[string][]byte, error) {
+	values, err := getBulk(ctx, s.KeyStore, names)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range values {
+		if values[name], err = s.resolve(ctx, name, value); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// List returns the first n entry names that start with the
+// given prefix. Shared values are not included.
+func (s *dedupStore) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	names, next, err := s.KeyStore.List(ctx, prefix, n)
+	if err != nil {
+		return nil, "", err
+	}
+	return slices.DeleteFunc(names, func(name string) bool { return strings.HasPrefix(name, dedupPrefix) }), next, nil
+}
+
+// share returns the value as it is if it is smaller than the
+// min. size. Otherwise, it adds a reference to the shared value,
+// creating it if necessary, and returns the encoded reference.
+func (s *dedupStore) share(ctx context.Context, value []byte) ([]byte, *dedupRef, error) {
+	if len(value) < s.minSize {
+		return value, nil, nil
+	}
+
+	key := dedupKey(value)
+	id := sha256.Sum256(append([]byte("kes\x00dedup id\x00"), key...))
+	ref := &dedupRef{ID: hex.EncodeToString(id[:16]), Key: key}
+	for range maxDedupAttempts {
+		count, err := s.count(ctx, ref)
+		switch {
+		case errors.Is(err, kes.ErrKeyNotFound):
+			err = s.KeyStore.Create(ctx, ref.Name(), encodeDedupCount(1))
+		case err != nil:
+			return nil, nil, err
+		case count.Refs == 0: // Being deleted. Try the next generation.
+			ref.Gen++
+			continue
+		default:
+			err = swapEntry(ctx, s.KeyStore, ref.Name(), encodeDedupCount(count.Refs), encodeDedupCount(count.Refs+1))
+		}
+		if errors.Is(err, kes.ErrKeyExists) || errors.Is(err, kes.ErrKeyNotFound) {
+			continue // Modified concurrently
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// The shared value may not exist yet even if it is referenced
+		// already, e.g. when created concurrently. Since its content is
+		// the same, an existing shared value is not replaced.
+		if err = s.createValue(ctx, ref, value); err != nil {
+			s.release(ctx, ref)
+			return nil, nil, err
+		}
+		b, err := json.Marshal(ref)
+		if err != nil {
+			s.release(ctx, ref)
+			return nil, nil, err
+		}
+		return append(bytes.Clone(dedupHeader), b...), ref, nil
+	}
+	return nil, nil, errors.New("kes: failed to deduplicate key store entry: too many concurrent modifications")
+}
+
+// createValue encrypts the value and stores it as the shared
+// value of ref, unless it exists already.
+func (s *dedupStore) createValue(ctx context.Context, ref *dedupRef, value []byte) error {
+	key, err := crypto.NewSecretKey(crypto.AES256, ref.Key)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := key.Encrypt(value, dedupAssociatedData(ref))
+	if err != nil {
+		return err
+	}
+	if err = s.KeyStore.Create(ctx, ref.ValueName(), ciphertext); err != nil && !errors.Is(err, kes.ErrKeyExists) {
+		return err
+	}
+	return nil
+}
+
+// resolve returns the value of the entry with the given name.
+// If the value is a reference, it reads and decrypts the shared
+// value. Otherwise, it returns the value as it is.
+func (s *dedupStore) resolve(ctx context.Context, name string, value []byte) ([]byte, error) {
+	ref, ok, err := parseDedupRef(name, value)
+	if !ok || err != nil {
+		return value, err
+	}
+
+	ciphertext, err := s.KeyStore.Get(ctx, ref.ValueName())
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		return nil, fmt.Errorf("kes: key store entry '%s' is corrupted: shared value '%s' not found", name, ref.ValueName())
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypto.NewSecretKey(crypto.AES256, ref.Key)
+	if err != nil {
+		return nil, fmt.Errorf("kes: invalid reference of key store entry '%s': %v", name, err)
+	}
+	plaintext, err := key.Decrypt(ciphertext, dedupAssociatedData(ref))
+	if err != nil {
+		return nil, fmt.Errorf("kes: key store entry '%s' is corrupted: failed to decrypt shared value: %v", name, err)
+	}
+	return plaintext, nil
+}
+
+// releaseOf removes the reference of the entry with the
+// given name, if its stored value is a reference.
+func (s *dedupStore) releaseOf(ctx context.Context, name string, stored []byte) {
+	if ref, ok, err := parseDedupRef(name, stored); ok && err == nil {
+		s.release(ctx, ref)
+	}
+}
+
+// release removes a reference to the shared value of ref. The
+// last reference marks the shared value as being deleted before
+// removing it. References that cannot be removed are left behind.
+// Then, the shared value is never removed but also never read
+// again once no entry refers to it.
+func (s *dedupStore) release(ctx context.Context, ref *dedupRef) {
+	if ref == nil {
+		return
+	}
+	for range maxDedupAttempts {
+		count, err := s.count(ctx, ref)
+		if err != nil || count.Refs == 0 {
+			return
+		}
+
+		err = swapEntry(ctx, s.KeyStore, ref.Name(), encodeDedupCount(count.Refs), encodeDedupCount(count.Refs-1))
+		if errors.Is(err, kes.ErrKeyExists) {
+			continue // Modified concurrently
+		}
+		if err != nil {
+			return
+		}
+		if count.Refs == 1 {
+			if err = s.KeyStore.Delete(ctx, ref.ValueName()); err == nil || errors.Is(err, kes.ErrKeyNotFound) {
+				s.KeyStore.Delete(ctx, ref.Name())
+			}
+		}
+		return
+	}
+}
+
+// count returns the reference count of the shared value of ref.
+func (s *dedupStore) count(ctx context.Context, ref *dedupRef) (dedupCount, error) {
+	b, err := s.KeyStore.Get(ctx, ref.Name())
+	if err != nil {
+		return dedupCount{}, err
+	}
+	var count dedupCount
+	if err = json.Unmarshal(b, &count); err != nil || count.Refs < 0 {
+		return dedupCount{}, fmt.Errorf("kes: invalid reference count '%s'", ref.Name())
+	}
+	return count, nil
+}
+
+// dedupKey returns the key that encrypts the shared value.
+// It is derived from the value such that identical values
+// are encrypted with the same key.
+func dedupKey(value []byte) []byte {
+	key := sha256.Sum256(append([]byte("kes\x00dedup key\x00"), value...))
+	return key[:]
+}
+
+// dedupAssociatedData binds a shared value to its
+// entry name such that shared values cannot be swapped.
+func dedupAssociatedData(ref *dedupRef) []byte {
+	return append(bytes.Clone(dedupHeader), "name="+ref.ValueName()...)
+}
+
+func encodeDedupCount(refs int) []byte {
+	b, _ := json.Marshal(dedupCount{Refs: refs})
+	return b
+}
+
+// parseDedupRef parses the reference of the entry with the given
+// name. It reports whether the value is a reference.
+func parseDedupRef(name string, value []byte) (*dedupRef, bool, error) {
+	b, ok := bytes.CutPrefix(value, dedupHeader)
+	if !ok {
+		return nil, false, nil
+	}
+	var ref dedupRef
+	if err := json.Unmarshal(b, &ref); err != nil {
+		return nil, true, fmt.Errorf("kes: invalid reference of key store entry '%s': %v", name, err)
+	}
+	if _, err := hex.DecodeString(ref.ID); err != nil || len(ref.ID) != 32 || ref.Gen < 0 || len(ref.Key) != crypto.SecretKeySize {
+		return nil, true, fmt.Errorf("kes: invalid reference of key store entry '%s'", name)
+	}
+	return &ref, true, nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/minio/kms-go/kes"
+)
+
+func TestDedupStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	backend := &MemKeyStore{}
+	store := newDedupStore(backend, &DedupConfig{MinSize: 16})
+
+	value := []byte("my-bootstrap-secret-shared-by-many-keys")
+	for _, name := range []string{"my-key", "my-key-2", "my-key-3"} {
+		if err := store.Create(ctx, name, value); err != nil {
+			t.Fatalf("Failed to create entry '%s': %v", name, err)
+		}
+	}
+	if err := store.Create(ctx, "my-key", value); !errors.Is(err, kes.ErrKeyExists) {
+		t.Fatalf("Created entry twice: got '%v' - want '%v'", err, kes.ErrKeyExists)
+	}
+	if refs := countDedupRefs(ctx, t, backend); !slices.Equal(refs, []int{3}) {
+		t.Fatalf("Invalid references: got '%v' - want '%v'", refs, []int{3})
+	}
+	stored, _ := backend.Get(ctx, "my-key")
+	if !bytes.HasPrefix(stored, dedupHeader) || bytes.Contains(stored, value) {
+		t.Fatalf("Entry is not deduplicated: '%s'", stored)
+	}
+	ref, _, _ := parseDedupRef("my-key", stored)
+	if shared, _ := backend.Get(ctx, ref.ValueName()); bytes.Contains(shared, value) {
+		t.Fatalf("Shared value is not encrypted: '%s'", shared)
+	}
+	if v, err := store.Get(ctx, "my-key-2"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get entry: got '%s' - want '%s': %v", v, value, err)
+	}
+	if names, _, err := store.List(ctx, "", -1); err != nil || !slices.Equal(names, []string{"my-key", "my-key-2", "my-key-3"}) {
+		t.Fatalf("Failed to list entries: got '%v': %v", names, err)
+	}
+
+	// Small entries are stored as they are.
+	small := []byte("small-value")
+	if err := store.Create(ctx, "small-key", small); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if v, _ := backend.Get(ctx, "small-key"); !bytes.Equal(v, small) {
+		t.Fatalf("Small entry got deduplicated: '%s'", v)
+	}
+
+	// Replacing or deleting an entry removes its reference.
+	if err := swapEntry(ctx, store, "my-key", small, value); !errors.Is(err, kes.ErrKeyExists) {
+		t.Fatalf("Swapped entry with wrong value: got '%v' - want '%v'", err, kes.ErrKeyExists)
+	}
+	if err := swapEntry(ctx, store, "my-key", value, value); err != nil {
+		t.Fatalf("Failed to swap entry: %v", err)
+	}
+	if err := setEntry(ctx, store, "my-key", small); err != nil {
+		t.Fatalf("Failed to set entry: %v", err)
+	}
+	if err := store.Delete(ctx, "my-key-2"); err != nil {
+		t.Fatalf("Failed to delete entry: %v", err)
+	}
+	if refs := countDedupRefs(ctx, t, backend); !slices.Equal(refs, []int{1}) {
+		t.Fatalf("Invalid references: got '%v' - want '%v'", refs, []int{1})
+	}
+
+	values, err := getBulk(ctx, store, []string{"my-key", "my-key-3"})
+	if err != nil {
+		t.Fatalf("Failed to get entries: %v", err)
+	}
+	if !bytes.Equal(values["my-key"], small) || !bytes.Equal(values["my-key-3"], value) {
+		t.Fatalf("Invalid values: got '%s'", values)
+	}
+
+	// The shared value is removed with its last reference.
+	if err = store.Delete(ctx, "my-key-3"); err != nil {
+		t.Fatalf("Failed to delete entry: %v", err)
+	}
+	if names, _, _ := backend.List(ctx, dedupPrefix, -1); len(names) != 0 {
+		t.Fatalf("Shared value has not been removed: '%v'", names)
+	}
+}
+
+func TestDedupStoreGeneration(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	backend := &MemKeyStore{}
+	store := newDedupStore(backend, &DedupConfig{MinSize: 16})
+
+	// A shared value that is being deleted is never referenced
+	// again. Instead, a new generation is created.
+	value := []byte("my-bootstrap-secret-shared-by-many-keys")
+	if err := store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	stored, _ := backend.Get(ctx, "my-key")
+	ref, _, _ := parseDedupRef("my-key", stored)
+	if err := backend.Set(ctx, ref.Name(), encodeDedupCount(0)); err != nil {
+		t.Fatalf("Failed to mark shared value as deleted: %v", err)
+	}
+
+	if err := store.Create(ctx, "my-key-2", value); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	stored, _ = backend.Get(ctx, "my-key-2")
+	if ref2, _, _ := parseDedupRef("my-key-2", stored); ref2 == nil || ref2.ID != ref.ID || ref2.Gen != ref.Gen+1 {
+		t.Fatalf("Invalid reference: got '%+v' - want generation '%d'", ref2, ref.Gen+1)
+	}
+	if v, err := store.Get(ctx, "my-key-2"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get entry: got '%s' - want '%s': %v", v, value, err)
+	}
+}
+
+func TestDedupStoreConcurrent(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	backend := &MemKeyStore{}
+	store := newDedupStore(backend, &DedupConfig{MinSize: 16})
+
+	value := []byte("my-bootstrap-secret-shared-by-many-keys")
+	var wg sync.WaitGroup
+	for i := range 8 {
+		name := "my-key-" + string(rune('a'+i))
+		wg.Go(func() {
+			if err := store.Create(ctx, name, value); err != nil {
+				t.Errorf("Failed to create entry '%s': %v", name, err)
+			}
+		})
+	}
+	wg.Wait()
+	if refs := countDedupRefs(ctx, t, backend); !slices.Equal(refs, []int{8}) {
+		t.Fatalf("Invalid references: got '%v' - want '%v'", refs, []int{8})
+	}
+}
+
+func TestDedupStoreUnsupported(t *testing.T) {
+	store := &unconditionalKeyStore{}
+	if s := newDedupStore(store, &DedupConfig{}); s != KeyStore(store) {
+		t.Fatal("Created deduplicating store without conditional writes")
+	}
+}
+
+// countDedupRefs returns the reference counts of
+// all shared values at the key store.
+func countDedupRefs(ctx context.Context, t *testing.T, store KeyStore) []int {
+	names, _, err := store.List(ctx, dedupPrefix, -1)
+	if err != nil {
+		t.Fatalf("Failed to list shared values: %v", err)
+	}
+
+	var refs []int
+	for _, name := range names {
+		if strings.HasSuffix(name, "-value") {
+			continue
+		}
+		b, err := store.Get(ctx, name)
+		if err != nil {
+			t.Fatalf("Failed to read reference count '%s': %v", name, err)
+		}
+		var count dedupCount
+		if err = json.Unmarshal(b, &count); err != nil {
+			t.Fatalf("Failed to parse reference count '%s': %v", name, err)
+		}
+		refs = append(refs, count.Refs)
+	}
+	return refs
+}
+
+// unconditionalKeyStore is a KeyStore that does
+// not support conditional writes.
+type unconditionalKeyStore struct{ KeyStore }
@@ -0,0 +1,174 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/rand"
+	"net/http"
+	"slices"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+)
+
+// envelopeVersion is the current envelope format version.
+const envelopeVersion = 1
+
+// sealEnvelope encrypts a plaintext with a new data encryption
+// key (DEK) and returns a self-describing envelope containing
+// the DEK encrypted with the KES key and the payload ciphertext.
+//
+// The envelope can be stored as-is. Clients do not have to
+// track the key name or DEK separately.
+func (s *Server) sealEnvelope(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	var body api.SealEnvelopeRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	key, err := s.state.Load().Keys.Use(req.Context(), req.Resource)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to read key")
+		return
+	}
+	s.state.Load().Usage.UseKey(req.Resource)
+
+	dek, err := crypto.GenerateSecretKey(crypto.DetermineSecretKeyType(), rand.Reader)
+	if err != nil {
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to generate encryption key")
+		return
+	}
+	encryptedKey, err := key.Key.Encrypt(dek.Bytes(), body.Context)
+	if err != nil {
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to encrypt plaintext")
+		return
+	}
+
+	// The encrypted DEK is the associated data of the payload.
+	// Hence, the payload cannot be combined with another DEK.
+	ciphertext, err := dek.Encrypt(body.Plaintext, encryptedKey)
+	if err != nil {
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to encrypt plaintext")
+		return
+	}
+
+	api.ReplyWith(resp, http.StatusOK, api.Envelope{
+		Version:      envelopeVersion,
+		Key:          req.Resource,
+		KeyVersion:   key.CreatedAt,
+		Algorithm:    dek.Type().String(),
+		EncryptedKey: encryptedKey,
+		Ciphertext:   ciphertext,
+	})
+}
+
+// openEnvelope decrypts an envelope produced by sealEnvelope.
+// The envelope must have been sealed with the key named in
+// the request path, such that policies apply to envelopes
+// like to any other key operation.
+func (s *Server) openEnvelope(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	var body api.OpenEnvelopeRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid request body")
+		return
+	}
+	env := body.Envelope
+	if env.Version != envelopeVersion {
+		resp.Failf(http.StatusBadRequest, "unsupported envelope version '%d'", env.Version)
+		return
+	}
+	if env.Key != req.Resource {
+		resp.Failf(http.StatusBadRequest, "envelope was sealed with key '%s'", env.Key)
+		return
+	}
+	cipher, err := crypto.ParseSecretKeyType(env.Algorithm)
+	if err != nil {
+		resp.Failf(http.StatusBadRequest, "unsupported envelope algorithm '%s'", env.Algorithm)
+		return
+	}
+
+	key, err := s.state.Load().Keys.Use(req.Context(), req.Resource)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to read key")
+		return
+	}
+	s.state.Load().Usage.UseKey(req.Resource)
+	if !env.KeyVersion.IsZero() && !env.KeyVersion.Equal(key.CreatedAt) {
+		resp.Failf(http.StatusConflict, "envelope was sealed with another version of key '%s'", req.Resource)
+		return
+	}
+
+	// Decrypt works in-place but the encrypted DEK is also
+	// the associated data of the payload.
+	plainKey, err := key.Key.Decrypt(slices.Clone(env.EncryptedKey), body.Context)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to decrypt ciphertext")
+		return
+	}
+	dek, err := crypto.NewSecretKey(cipher, plainKey)
+	if err != nil {
+		resp.Fail(http.StatusBadRequest, "invalid envelope: malformed encryption key")
+		return
+	}
+	plaintext, err := dek.Decrypt(env.Ciphertext, env.EncryptedKey)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to decrypt ciphertext")
+		return
+	}
+
+	api.ReplyWith(resp, http.StatusOK, api.OpenEnvelopeResponse{
+		Plaintext: plaintext,
+	})
+}
@@ -0,0 +1,332 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kms-go/kes"
+)
+
+// reservationPrefix is the prefix of key reservation entries at
+// the key store. It is followed by the key name. Like locks, key
+// reservations never collide with keys.
+const reservationPrefix = "-reserve-"
+
+// A reservation grants its holder the exclusive right to encrypt
+// with a key until it expires. It is stored as JSON at the key store.
+//
+// The fence increases whenever the reservation passes to a new
+// holder. Released and expired reservations are kept, such that
+// fences never repeat, even if the key gets deleted.
+type reservation struct {
+	lock
+	Fence uint64 `json:"fence"`
+}
+
+func (r *reservation) response(name string, withToken bool) api.ReserveKeyResponse {
+	resp := api.ReserveKeyResponse{
+		Key:       name,
+		Holder:    r.Holder.String(),
+		Fence:     r.Fence,
+		ExpiresAt: r.ExpiresAt,
+	}
+	if withToken {
+		resp.Token = r.Token
+	}
+	return resp
+}
+
+// fencing enforces key reservations.
+type fencing struct {
+	maxTTL time.Duration
+}
+
+// newFencing returns a new fencing from the given config.
+// It returns nil if conf is nil.
+func newFencing(conf *FencingConfig) (*fencing, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	if conf.MaxTTL > MaxFencingTTL {
+		return nil, fmt.Errorf("kes: invalid fencing config: max TTL exceeds %v", MaxFencingTTL)
+	}
+	maxTTL := conf.MaxTTL
+	if maxTTL <= 0 {
+		maxTTL = DefaultFencingMaxTTL
+	}
+	return &fencing{maxTTL: maxTTL}, nil
+}
+
+// parseTTL parses the lease duration of a reservation request.
+func (f *fencing) parseTTL(s string) (time.Duration, api.Error) {
+	if s == "" {
+		return min(defaultLockTTL, f.maxTTL), nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid reservation TTL '%s'", s))
+	}
+	if ttl < minLockTTL || ttl > f.maxTTL {
+		return 0, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid reservation TTL '%v': must be between %v and %v", ttl, minLockTTL, f.maxTTL))
+	}
+	return ttl, nil
+}
+
+// readReservation reads the reservation of the key with the given
+// name from the key store. It returns the reservation and its encoded
+// value at the key store.
+func readReservation(ctx context.Context, store KeyStore, name string) (*reservation, []byte, error) {
+	b, err := store.Get(ctx, reservationPrefix+name)
+	if err != nil {
+		return nil, nil, err
+	}
+	var r reservation
+	if err = json.Unmarshal(b, &r); err != nil {
+		return nil, nil, fmt.Errorf("invalid reservation of key '%s': %v", name, err)
+	}
+	return &r, b, nil
+}
+
+// writeReservation creates the reservation of the key with the
+// given name at the key store, if old is nil. Otherwise, it replaces
+// the reservation if and only if its encoded value is still equal
+// to old.
+func writeReservation(ctx context.Context, store KeyStore, name string, old []byte, r *reservation) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if old == nil {
+		return store.Create(ctx, reservationPrefix+name, b)
+	}
+	return swapEntry(ctx, store, reservationPrefix+name, old, b)
+}
+
+// checkFence returns an error if the key with the given name is
+// reserved and the request does not carry the reservation's fencing
+// token, or if the request carries a fencing token but the key is not
+// reserved, or no longer reserved with this token. Hence, once a
+// reservation expires or passes to another holder, its previous holder
+// cannot produce new ciphertexts anymore.
+//
+// It returns nil if key reservations are not enabled.
+func (s *Server) checkFence(req *api.Request) error {
+	state := s.state.Load()
+	if state.Fencing == nil {
+		return nil
+	}
+
+	token := req.Header.Get(headers.KESFencingToken)
+	r, _, err := readReservation(req.Context(), state.Keys.store, req.Resource)
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		if token != "" {
+			return api.NewError(http.StatusConflict, fmt.Sprintf("key '%s' is not reserved", req.Resource))
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case r.IsExpired(time.Now()) && token == "":
+		return nil
+	case r.IsExpired(time.Now()):
+		return api.NewError(http.StatusConflict, fmt.Sprintf("reservation of key '%s' has expired", req.Resource))
+	case token == "":
+		return api.NewError(http.StatusConflict, fmt.Sprintf("key '%s' is reserved by '%s'", req.Resource, r.Holder))
+	case r.Holder != req.Identity || !r.Owns(token):
+		return api.NewError(http.StatusConflict, fmt.Sprintf("fencing token of key '%s' is stale", req.Resource))
+	}
+	return nil
+}
+
+// reserveKey reserves a key for the requesting identity or renews
+// its reservation if the request contains the reservation's token.
+//
+// Taking over an expired reservation replaces it with a conditional
+// write. Hence, at most one of multiple servers sharing the same key
+// store can take over a reservation.
+func (s *Server) reserveKey(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+	state := s.state.Load()
+	if state.Fencing == nil {
+		resp.Fail(http.StatusNotImplemented, "key reservations are not enabled")
+		return
+	}
+	if !canSwap(state.Keys.store) {
+		resp.Fail(http.StatusNotImplemented, "key store does not support key reservations")
+		return
+	}
+
+	var body api.ReserveKeyRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid reservation request body")
+		return
+	}
+	ttl, apiErr := state.Fencing.parseTTL(body.TTL)
+	if apiErr != nil {
+		resp.Failr(apiErr)
+		return
+	}
+
+	fail := func(err error, msg string) {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, msg)
+	}
+
+	s.locks.Lock(reservationPrefix + req.Resource)
+	defer s.locks.Unlock(reservationPrefix + req.Resource)
+
+	if _, err := state.Keys.Get(req.Context(), req.Resource); err != nil {
+		fail(err, "failed to read key")
+		return
+	}
+
+	now := time.Now().UTC()
+	held, raw, err := readReservation(req.Context(), state.Keys.store, req.Resource)
+	if err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+		fail(err, "failed to read key reservation")
+		return
+	}
+
+	var r *reservation
+	switch {
+	case body.Token != "":
+		if held == nil || held.Holder != req.Identity || !held.Owns(body.Token) {
+			resp.Failf(http.StatusConflict, "key '%s' is reserved by another identity", req.Resource)
+			return
+		}
+		if held.IsExpired(now) {
+			resp.Failf(http.StatusConflict, "reservation of key '%s' has expired", req.Resource)
+			return
+		}
+		r = &reservation{lock: held.lock, Fence: held.Fence}
+	case held != nil && !held.IsExpired(now):
+		resp.Failf(http.StatusConflict, "key '%s' is reserved by '%s'", req.Resource, held.Holder)
+		return
+	default:
+		var token [16]byte
+		if _, err = rand.Read(token[:]); err != nil {
+			fail(err, "failed to reserve key")
+			return
+		}
+		r = &reservation{
+			lock:  lock{Holder: req.Identity, Token: hex.EncodeToString(token[:])},
+			Fence: 1,
+		}
+		if held != nil {
+			r.Fence = held.Fence + 1
+		}
+	}
+	r.ExpiresAt = now.Add(ttl)
+
+	err = writeReservation(req.Context(), state.Keys.store, req.Resource, raw, r)
+	if errors.Is(err, kes.ErrKeyExists) || errors.Is(err, kes.ErrKeyNotFound) {
+		resp.Failf(http.StatusConflict, "key '%s' is reserved by another identity", req.Resource)
+		return
+	}
+	if err != nil {
+		fail(err, "failed to reserve key")
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(
+		fmt.Sprintf("key '%s' reserved with fence %d until %s", req.Resource, r.Fence, r.ExpiresAt.Format(time.RFC3339)),
+		StatusOK,
+		req,
+	)
+	api.ReplyWith(resp, StatusOK, r.response(req.Resource, true))
+}
+
+// releaseKey releases a key reservation held by the requesting
+// identity. The reservation is kept as expired reservation such
+// that its fence is not reused.
+func (s *Server) releaseKey(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+	state := s.state.Load()
+	if state.Fencing == nil {
+		resp.Fail(http.StatusNotImplemented, "key reservations are not enabled")
+		return
+	}
+
+	var body api.ReleaseKeyRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid reservation request body")
+		return
+	}
+
+	s.locks.Lock(reservationPrefix + req.Resource)
+	defer s.locks.Unlock(reservationPrefix + req.Resource)
+
+	r, raw, err := readReservation(req.Context(), state.Keys.store, req.Resource)
+	if err != nil {
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			resp.Failf(http.StatusNotFound, "key '%s' is not reserved", req.Resource)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to read key reservation")
+		return
+	}
+	if r.Holder != req.Identity || !r.Owns(body.Token) {
+		resp.Failf(http.StatusConflict, "key '%s' is reserved by another identity", req.Resource)
+		return
+	}
+	if r.IsExpired(time.Now()) {
+		resp.Failf(http.StatusConflict, "reservation of key '%s' has expired", req.Resource)
+		return
+	}
+
+	r.ExpiresAt = time.Now().UTC()
+	if err = writeReservation(req.Context(), state.Keys.store, req.Resource, raw, r); err != nil {
+		if errors.Is(err, kes.ErrKeyExists) || errors.Is(err, kes.ErrKeyNotFound) {
+			resp.Failf(http.StatusConflict, "key '%s' is reserved by another identity", req.Resource)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to release key reservation")
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(
+		fmt.Sprintf("reservation of key '%s' with fence %d released", req.Resource, r.Fence),
+		StatusOK,
+		req,
+	)
+	resp.Reply(StatusOK)
+}
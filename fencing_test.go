@@ -0,0 +1,151 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestFencing(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	cert, _ := newRenewalCertificate(t, time.Hour)
+	identity := renewalIdentity(cert.Leaf)
+	srv, url := startServer(ctx, &Config{
+		Fencing: &FencingConfig{MaxTTL: time.Minute},
+		Policies: map[string]Policy{
+			"app": {
+				Allow: map[string]kes.Rule{
+					api.PathKeyEncrypt + "*": {},
+					api.PathKeyReserve + "*": {},
+					api.PathKeyRelease + "*": {},
+				},
+				Identities: []kes.Identity{kes.Identity(identity)},
+			},
+		},
+	})
+	defer srv.Close()
+
+	admin := defaultClient(url)
+	if err := admin.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	app := renewalClient(url, cert)
+
+	send := func(client *http.Client, path string, body any, token string, v any) int {
+		b, _ := json.Marshal(body)
+		h := http.Header{}
+		if token != "" {
+			h.Set(headers.KESFencingToken, token)
+		}
+		method := http.MethodPut
+		if path == api.PathKeyEncrypt {
+			method = http.MethodPost
+		}
+		resp := sendRequest(ctx, t, client, method, url+path+"my-key", b, h)
+		defer resp.Body.Close()
+		if v != nil && resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return resp.StatusCode
+	}
+	encrypt := api.EncryptKeyRequest{Plaintext: []byte("Hello World")}
+
+	var first api.ReserveKeyResponse
+	if code := send(&admin.HTTPClient, api.PathKeyReserve, api.ReserveKeyRequest{TTL: "1s"}, "", &first); code != http.StatusOK {
+		t.Fatalf("Failed to reserve key: got status '%d'", code)
+	}
+	if first.Token == "" || first.Fence != 1 {
+		t.Fatalf("Invalid reservation: got '%+v'", first)
+	}
+	if code := send(&admin.HTTPClient, api.PathKeyReserve, api.ReserveKeyRequest{TTL: "1h"}, "", nil); code != http.StatusBadRequest {
+		t.Fatalf("Reserving a key with TTL above max. TTL should fail with '%d' - got '%d'", http.StatusBadRequest, code)
+	}
+	if code := send(&app.HTTPClient, api.PathKeyReserve, api.ReserveKeyRequest{}, "", nil); code != http.StatusConflict {
+		t.Fatalf("Reserving a reserved key should fail with '%d' - got '%d'", http.StatusConflict, code)
+	}
+	if code := send(&app.HTTPClient, api.PathKeyEncrypt, encrypt, "", nil); code != http.StatusConflict {
+		t.Fatalf("Encrypting with a reserved key should fail with '%d' - got '%d'", http.StatusConflict, code)
+	}
+	if code := send(&admin.HTTPClient, api.PathKeyEncrypt, encrypt, first.Token, nil); code != http.StatusOK {
+		t.Fatalf("Failed to encrypt with fencing token: got status '%d'", code)
+	}
+
+	// Once the reservation expired, another identity can take it
+	// over and the previous holder's token becomes stale.
+	time.Sleep(time.Until(first.ExpiresAt))
+
+	var second api.ReserveKeyResponse
+	if code := send(&app.HTTPClient, api.PathKeyReserve, api.ReserveKeyRequest{TTL: "30s"}, "", &second); code != http.StatusOK {
+		t.Fatalf("Failed to take over expired reservation: got status '%d'", code)
+	}
+	if second.Fence != first.Fence+1 {
+		t.Fatalf("Invalid fence: got '%d' - want '%d'", second.Fence, first.Fence+1)
+	}
+	if code := send(&admin.HTTPClient, api.PathKeyEncrypt, encrypt, first.Token, nil); code != http.StatusConflict {
+		t.Fatalf("Encrypting with a stale fencing token should fail with '%d' - got '%d'", http.StatusConflict, code)
+	}
+	if code := send(&app.HTTPClient, api.PathKeyEncrypt, encrypt, second.Token, nil); code != http.StatusOK {
+		t.Fatalf("Failed to encrypt with fencing token: got status '%d'", code)
+	}
+	if code := send(&app.HTTPClient, api.PathKeyReserve, api.ReserveKeyRequest{Token: second.Token, TTL: "1m"}, "", nil); code != http.StatusOK {
+		t.Fatalf("Failed to renew reservation: got status '%d'", code)
+	}
+
+	if code := send(&app.HTTPClient, api.PathKeyRelease, api.ReleaseKeyRequest{Token: second.Token}, "", nil); code != http.StatusOK {
+		t.Fatalf("Failed to release reservation: got status '%d'", code)
+	}
+	if code := send(&app.HTTPClient, api.PathKeyEncrypt, encrypt, second.Token, nil); code != http.StatusConflict {
+		t.Fatalf("Encrypting with a released fencing token should fail with '%d' - got '%d'", http.StatusConflict, code)
+	}
+	if code := send(&app.HTTPClient, api.PathKeyEncrypt, encrypt, "", nil); code != http.StatusOK {
+		t.Fatalf("Failed to encrypt with released key: got status '%d'", code)
+	}
+
+	var third api.ReserveKeyResponse
+	if code := send(&admin.HTTPClient, api.PathKeyReserve, api.ReserveKeyRequest{}, "", &third); code != http.StatusOK {
+		t.Fatalf("Failed to reserve released key: got status '%d'", code)
+	}
+	if third.Fence != second.Fence+1 {
+		t.Fatalf("Invalid fence: got '%d' - want '%d'", third.Fence, second.Fence+1)
+	}
+}
+
+func TestFencingDisabled(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, nil)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	resp := sendRequest(ctx, t, &client.HTTPClient, http.MethodPut, url+api.PathKeyReserve+"my-key", nil, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("Reserving a key should fail with '%d' - got '%d'", http.StatusNotImplemented, resp.StatusCode)
+	}
+
+	// Fencing tokens are ignored if key reservations are not enabled.
+	h := http.Header{headers.KESFencingToken: []string{"token"}}
+	body, _ := json.Marshal(api.EncryptKeyRequest{Plaintext: []byte("Hello World")})
+	resp = sendRequest(ctx, t, &client.HTTPClient, http.MethodPost, url+api.PathKeyEncrypt+"my-key", body, h)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to encrypt: got status '%d'", resp.StatusCode)
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+// folderPrefix is the prefix of folder entries at the key store.
+// It is followed by the key name. Like legal holds, folder entries
+// never collide with keys.
+const folderPrefix = "-folder-"
+
+const (
+	maxFolderLength = 255 // Max. length of a folder path
+	maxFolderDepth  = 16  // Max. number of folder path segments
+)
+
+// folderOps are the key operations folders can grant. Operations
+// that don't refer to an existing key, like creating or listing
+// keys, and moving keys between folders cannot be granted.
+var folderOps = []string{
+	"describe",
+	"delete",
+	"generate",
+	"encrypt",
+	"decrypt",
+	"hmac",
+	"seal",
+	"open",
+	"tokenize",
+	"detokenize",
+	"reveal",
+	"rewrap",
+}
+
+// keyFolder is the folder of a key as stored at the key store.
+type keyFolder struct {
+	Folder  string       `json:"folder"`
+	MovedAt time.Time    `json:"moved_at"`
+	MovedBy kes.Identity `json:"moved_by"`
+}
+
+// validFolder reports whether s is a valid folder path. A folder
+// path consists of one or more valid names separated by '/', for
+// example "team-a/payments".
+func validFolder(s string) bool {
+	if s == "" || len(s) > maxFolderLength {
+		return false
+	}
+	segments := strings.Split(s, "/")
+	if len(segments) > maxFolderDepth {
+		return false
+	}
+	for _, segment := range segments {
+		if !validName(segment) {
+			return false
+		}
+	}
+	return true
+}
+
+// inFolder reports whether folder is dir itself or one of
+// its sub-folders.
+func inFolder(folder, dir string) bool {
+	return folder == dir || strings.HasPrefix(folder, dir+"/")
+}
+
+// parentFolder returns the parent of the folder. It returns
+// the empty string for top-level folders.
+func parentFolder(folder string) string {
+	if i := strings.LastIndexByte(folder, '/'); i >= 0 {
+		return folder[:i]
+	}
+	return ""
+}
+
+// folderTree contains the grants and tags of key folders.
+// Sub-folders inherit the grants and tags of their parents.
+type folderTree struct {
+	folders map[string]*folderNode // Folder path -> folder
+}
+
+// folderNode contains the grants and tags of a single folder.
+type folderNode struct {
+	grants map[string]map[string]bool // Policy name -> key operations
+	tags   map[string]string
+}
+
+// newFolderTree returns a new folderTree for the given folder
+// configurations. It returns nil if no folder is configured.
+func newFolderTree(folders map[string]*FolderConfig, policies map[string]Policy) (*folderTree, error) {
+	if len(folders) == 0 {
+		return nil, nil
+	}
+
+	t := &folderTree{folders: make(map[string]*folderNode, len(folders))}
+	for path, conf := range folders {
+		if !validFolder(path) {
+			return nil, fmt.Errorf("kes: invalid folder '%s'", path)
+		}
+		if conf == nil {
+			return nil, fmt.Errorf("kes: folder '%s' has no configuration", path)
+		}
+
+		node := &folderNode{
+			grants: make(map[string]map[string]bool, len(conf.Grants)),
+			tags:   maps.Clone(conf.Tags),
+		}
+		for policy, ops := range conf.Grants {
+			if _, ok := policies[policy]; !ok {
+				return nil, fmt.Errorf("kes: folder '%s' grants access to non-existing policy '%s'", path, policy)
+			}
+			granted := make(map[string]bool, len(ops))
+			for _, op := range ops {
+				switch {
+				case op == "*":
+					for _, op := range folderOps {
+						granted[op] = true
+					}
+				case slices.Contains(folderOps, op):
+					granted[op] = true
+				default:
+					return nil, fmt.Errorf("kes: folder '%s' grants invalid key operation '%s' to policy '%s'", path, op, policy)
+				}
+			}
+			node.grants[policy] = granted
+		}
+		for name := range conf.Tags {
+			if name == "" {
+				return nil, fmt.Errorf("kes: folder '%s' contains empty tag name", path)
+			}
+		}
+		t.folders[path] = node
+	}
+	return t, nil
+}
+
+// Allows reports whether the folder, or any of its parents,
+// grants the key operation to the policy.
+func (t *folderTree) Allows(folder, policy, op string) bool {
+	if t == nil {
+		return false
+	}
+	for ; folder != ""; folder = parentFolder(folder) {
+		if node, ok := t.folders[folder]; ok && node.grants[policy][op] {
+			return true
+		}
+	}
+	return false
+}
+
+// Tags returns the tags of keys within the folder, including
+// the tags inherited from its parents. Tags of sub-folders
+// override inherited tags with the same name.
+func (t *folderTree) Tags(folder string) map[string]string {
+	if t == nil {
+		return nil
+	}
+
+	var nodes []*folderNode
+	for ; folder != ""; folder = parentFolder(folder) {
+		if node, ok := t.folders[folder]; ok && len(node.tags) > 0 {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+	tags := make(map[string]string)
+	for _, node := range slices.Backward(nodes) {
+		maps.Copy(tags, node.tags)
+	}
+	return tags
+}
+
+// AllowsRequest reports whether the folder of the key the request
+// refers to grants the requested key operation to the policy with
+// the given name. The deny rules of the policy take precedence over
+// folder grants.
+//
+// It fails closed: if the folder of the key cannot be read, the
+// request is not allowed.
+func (t *folderTree) AllowsRequest(req *http.Request, keys *keyCache, name string, policy *kes.Policy) bool {
+	if t == nil {
+		return false
+	}
+	op, key := keyOperation(req)
+	if key == "" || !validName(key) || !slices.Contains(folderOps, op) {
+		return false
+	}
+	if (&kes.Policy{Allow: policy.Deny}).Verify(req) == nil {
+		return false
+	}
+
+	folder, err := keys.Folder(req.Context(), key)
+	if err != nil || folder == "" {
+		return false
+	}
+	return t.Allows(folder, name, op)
+}
+
+// loadKeyFolder returns the folder of the key with the given
+// name, or the empty string if the key is not in any folder.
+func loadKeyFolder(ctx context.Context, c *keyCache, name string) (string, error) {
+	if isReservedEntry(name) {
+		return "", nil
+	}
+	b, err := c.get(ctx, folderPrefix+name)
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var folder keyFolder
+	if err = json.Unmarshal(b, &folder); err != nil {
+		return "", fmt.Errorf("kes: invalid folder of key '%s': %v", name, err)
+	}
+	return folder.Folder, nil
+}
+
+// moveKey moves the key with the given name into the folder. If
+// folder is empty, the key gets removed from its folder. It returns
+// kes.ErrKeyNotFound if no such key exists.
+func moveKey(ctx context.Context, c *keyCache, name, folder string, identity kes.Identity) error {
+	if _, err := c.Get(ctx, name); err != nil {
+		return err
+	}
+
+	if folder == "" {
+		err := c.withTimeout(ctx, "delete", c.writeTimeout, func(ctx context.Context) error {
+			return c.store.Delete(ctx, folderPrefix+name)
+		})
+		if err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+			return err
+		}
+	} else {
+		b, err := json.Marshal(keyFolder{
+			Folder:  folder,
+			MovedAt: time.Now().UTC(),
+			MovedBy: identity,
+		})
+		if err != nil {
+			return err
+		}
+		err = c.withTimeout(ctx, "create", c.writeTimeout, func(ctx context.Context) error {
+			return setEntry(ctx, c.store, folderPrefix+name, b)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	c.setFolder(name, folder)
+	return nil
+}
+
+func (s *Server) moveKey(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	var body api.MoveKeyRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if body.Folder != "" && !validFolder(body.Folder) {
+		resp.Failf(http.StatusBadRequest, "folder '%s' is too long, too deep or contains invalid characters", body.Folder)
+		return
+	}
+
+	state := s.state.Load()
+	if err := moveKey(req.Context(), state.Keys, req.Resource, body.Folder, req.Identity); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to move key")
+		return
+	}
+
+	state.Changes.Record(api.ChangeObjectKey, api.ChangeUpdate, req.Resource, req.Identity)
+
+	msg := fmt.Sprintf("secret key '%s' moved to folder '%s'", req.Resource, body.Folder)
+	if body.Folder == "" {
+		msg = fmt.Sprintf("secret key '%s' removed from its folder", req.Resource)
+	}
+	const StatusOK = http.StatusOK
+	state.Audit.Log(msg, StatusOK, req)
+	resp.Reply(StatusOK)
+}
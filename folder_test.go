@@ -0,0 +1,208 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestKeyFolders(t *testing.T) {
+	t.Parallel()
+
+	teamCert, _ := newRenewalCertificate(t, time.Hour)
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		Keys: &MemKeyStore{},
+		Policies: map[string]Policy{
+			"team-a": {
+				Allow:      map[string]kes.Rule{},
+				Deny:       map[string]kes.Rule{api.PathKeyDecrypt + "payments-internal": {}},
+				Identities: []kes.Identity{kes.Identity(renewalIdentity(teamCert.Leaf))},
+			},
+		},
+		Folders: map[string]*FolderConfig{
+			"team-a": {
+				Grants: map[string][]string{"team-a": {"encrypt", "decrypt"}},
+				Tags:   map[string]string{"team": "team-a", "tier": "standard"},
+			},
+			"team-a/payments": {
+				Tags: map[string]string{"tier": "pci"},
+			},
+		},
+	})
+	defer srv.Close()
+
+	admin, team := defaultClient(url), renewalClient(url, teamCert)
+	send := func(method, path string, body, out any) int {
+		var b []byte
+		if body != nil {
+			var err error
+			if b, err = json.Marshal(body); err != nil {
+				t.Fatalf("Failed to encode request: %v", err)
+			}
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url+path, bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := admin.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if out != nil && resp.StatusCode == http.StatusOK {
+			if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return resp.StatusCode
+	}
+	move := func(name, folder string) int {
+		return send(http.MethodPut, api.PathKeyFolder+name, api.MoveKeyRequest{Folder: folder}, nil)
+	}
+
+	for _, name := range []string{"payments-key", "payments-internal", "other-key"} {
+		if err := admin.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	if code := move("missing-key", "team-a"); code != http.StatusNotFound {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", code, http.StatusNotFound)
+	}
+	if code := move("payments-key", "team-a//payments"); code != http.StatusBadRequest {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", code, http.StatusBadRequest)
+	}
+	for _, name := range []string{"payments-key", "payments-internal"} {
+		if code := move(name, "team-a/payments"); code != http.StatusOK {
+			t.Fatalf("Failed to move key '%s': status code '%d'", name, code)
+		}
+	}
+
+	// Grants of "team-a" are inherited by "team-a/payments".
+	ciphertext, err := team.Encrypt(ctx, "payments-key", []byte("Hello World"), nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt with key in folder: %v", err)
+	}
+	if _, err = team.Decrypt(ctx, "payments-key", ciphertext, nil); err != nil {
+		t.Fatalf("Failed to decrypt with key in folder: %v", err)
+	}
+	if _, err = team.GenerateKey(ctx, "payments-key", nil); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Generated key without folder grant: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+	if _, err = team.Encrypt(ctx, "other-key", []byte("Hello World"), nil); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Encrypted with key outside folder: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+
+	// Deny rules of the policy take precedence over folder grants.
+	ciphertext, err = team.Encrypt(ctx, "payments-internal", []byte("Hello World"), nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt with key in folder: %v", err)
+	}
+	if _, err = team.Decrypt(ctx, "payments-internal", ciphertext, nil); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Decrypted despite deny rule: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+
+	var info api.DescribeKeyResponse
+	if code := send(http.MethodGet, api.PathKeyDescribe+"payments-key", nil, &info); code != http.StatusOK {
+		t.Fatalf("Failed to describe key: status code '%d'", code)
+	}
+	if info.Folder != "team-a/payments" {
+		t.Fatalf("Invalid folder: got '%s' - want '%s'", info.Folder, "team-a/payments")
+	}
+	if tags := map[string]string{"team": "team-a", "tier": "pci"}; !maps.Equal(info.Tags, tags) {
+		t.Fatalf("Invalid tags: got '%v' - want '%v'", info.Tags, tags)
+	}
+
+	var list api.ListKeysResponse
+	if code := send(http.MethodGet, api.PathKeyList+"*?folder=team-a", nil, &list); code != http.StatusOK {
+		t.Fatalf("Failed to list keys: status code '%d'", code)
+	}
+	if names := []string{"payments-internal", "payments-key"}; !slices.Equal(list.Names, names) {
+		t.Fatalf("Invalid keys in folder: got '%v' - want '%v'", list.Names, names)
+	}
+
+	if code := move("payments-key", ""); code != http.StatusOK {
+		t.Fatalf("Failed to remove key from folder: status code '%d'", code)
+	}
+	if _, err = team.Encrypt(ctx, "payments-key", []byte("Hello World"), nil); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Encrypted with key removed from folder: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
+
+func TestFolderTree(t *testing.T) {
+	policies := map[string]Policy{"my-app": {}, "my-app-ops": {}}
+	tree, err := newFolderTree(map[string]*FolderConfig{
+		"a":     {Grants: map[string][]string{"my-app": {"encrypt"}}, Tags: map[string]string{"k": "a"}},
+		"a/b":   {Grants: map[string][]string{"my-app-ops": {"*"}}},
+		"a/b/c": {Tags: map[string]string{"k": "c", "c": "c"}},
+	}, policies)
+	if err != nil {
+		t.Fatalf("Failed to create folder tree: %v", err)
+	}
+
+	for i, test := range folderTreeAllowsTests {
+		if allowed := tree.Allows(test.Folder, test.Policy, test.Op); allowed != test.Allowed {
+			t.Fatalf("Test %d: got '%v' - want '%v'", i, allowed, test.Allowed)
+		}
+	}
+	if tags := tree.Tags("a/b/c/d"); !maps.Equal(tags, map[string]string{"k": "c", "c": "c"}) {
+		t.Fatalf("Invalid tags: got '%v'", tags)
+	}
+	if tags := tree.Tags("a/b"); !maps.Equal(tags, map[string]string{"k": "a"}) {
+		t.Fatalf("Invalid tags: got '%v'", tags)
+	}
+	if tags := tree.Tags("b"); tags != nil {
+		t.Fatalf("Invalid tags: got '%v' - want no tags", tags)
+	}
+
+	for i, test := range newFolderTreeTests {
+		_, err := newFolderTree(test.Folders, policies)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: created folder tree with invalid config", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to create folder tree: %v", i, err)
+		}
+	}
+}
+
+var folderTreeAllowsTests = []struct {
+	Folder, Policy, Op string
+	Allowed            bool
+}{
+	{Folder: "a", Policy: "my-app", Op: "encrypt", Allowed: true},       // 0
+	{Folder: "a/b/c", Policy: "my-app", Op: "encrypt", Allowed: true},   // 1
+	{Folder: "a/b/c", Policy: "my-app", Op: "decrypt", Allowed: false},  // 2
+	{Folder: "a", Policy: "my-app-ops", Op: "delete", Allowed: false},   // 3
+	{Folder: "a/b", Policy: "my-app-ops", Op: "delete", Allowed: true},  // 4
+	{Folder: "ab", Policy: "my-app", Op: "encrypt", Allowed: false},     // 5
+	{Folder: "a/b", Policy: "my-app-ops", Op: "create", Allowed: false}, // 6
+	{Folder: "b/a", Policy: "my-app", Op: "encrypt", Allowed: false},    // 7
+}
+
+var newFolderTreeTests = []struct {
+	Folders    map[string]*FolderConfig
+	ShouldFail bool
+}{
+	{Folders: map[string]*FolderConfig{"a/b": {Grants: map[string][]string{"my-app": {"encrypt", "decrypt"}}}}},       // 0
+	{Folders: map[string]*FolderConfig{"a//b": {}}, ShouldFail: true},                                                 // 1
+	{Folders: map[string]*FolderConfig{"/a": {}}, ShouldFail: true},                                                   // 2
+	{Folders: map[string]*FolderConfig{"a": nil}, ShouldFail: true},                                                   // 3
+	{Folders: map[string]*FolderConfig{"a": {Grants: map[string][]string{"unknown": {"encrypt"}}}}, ShouldFail: true}, // 4
+	{Folders: map[string]*FolderConfig{"a": {Grants: map[string][]string{"my-app": {"create"}}}}, ShouldFail: true},   // 5
+	{Folders: map[string]*FolderConfig{"a": {Tags: map[string]string{"": "value"}}}, ShouldFail: true},                // 6
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"path"
+	"slices"
+
+	"github.com/minio/kes/internal/geoip"
+	"github.com/minio/kes/internal/https"
+	"github.com/minio/kms-go/kes"
+)
+
+// geoFence restricts requests of identities by the location
+// of the client IP address.
+type geoFence struct {
+	db         *geoip.DB
+	conditions map[string]*geoCondition // Policy name -> geo condition
+}
+
+// geoCondition is the geo condition of a policy.
+type geoCondition struct {
+	conf       GeoCondition
+	paths      *kes.Policy // Only uses the allow rules
+	countries  map[string]bool
+	continents map[string]bool
+}
+
+// parseGeoIP parses the GeoIP database of the configuration.
+// It returns nil if conf is nil.
+func parseGeoIP(conf *GeoIPConfig) (*geoip.DB, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	db, err := geoip.Parse(conf.Database)
+	if err != nil {
+		return nil, fmt.Errorf("kes: invalid GeoIP database: %v", err)
+	}
+	return db, nil
+}
+
+// newGeoFence returns a new geoFence for the geo conditions of
+// the given policies. It returns nil if no policy contains a
+// geo condition.
+func newGeoFence(db *geoip.DB, policies map[string]Policy) (*geoFence, error) {
+	var conditions map[string]*geoCondition
+	for name, policy := range policies {
+		if policy.Geo == nil {
+			continue
+		}
+		if db == nil {
+			return nil, fmt.Errorf("kes: policy '%s' contains a geo condition but no GeoIP database is configured", name)
+		}
+		if len(policy.Geo.Countries) == 0 && len(policy.Geo.Continents) == 0 {
+			return nil, fmt.Errorf("kes: geo condition of policy '%s' contains no countries or continents", name)
+		}
+
+		c := &geoCondition{
+			conf: GeoCondition{
+				Paths:      slices.Clone(policy.Geo.Paths),
+				Countries:  slices.Clone(policy.Geo.Countries),
+				Continents: slices.Clone(policy.Geo.Continents),
+			},
+			countries:  make(map[string]bool, len(policy.Geo.Countries)),
+			continents: make(map[string]bool, len(policy.Geo.Continents)),
+		}
+		if len(policy.Geo.Paths) > 0 {
+			c.paths = &kes.Policy{Allow: make(map[string]kes.Rule, len(policy.Geo.Paths))}
+			for _, pattern := range policy.Geo.Paths {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("kes: geo condition of policy '%s' contains invalid path '%s'", name, pattern)
+				}
+				c.paths.Allow[pattern] = kes.Rule{}
+			}
+		}
+		for _, code := range policy.Geo.Countries {
+			if !validGeoCode(code) {
+				return nil, fmt.Errorf("kes: geo condition of policy '%s' contains invalid country code '%s'", name, code)
+			}
+			c.countries[code] = true
+		}
+		for _, code := range policy.Geo.Continents {
+			if !validGeoCode(code) {
+				return nil, fmt.Errorf("kes: geo condition of policy '%s' contains invalid continent code '%s'", name, code)
+			}
+			c.continents[code] = true
+		}
+
+		if conditions == nil {
+			conditions = make(map[string]*geoCondition, len(policies))
+		}
+		conditions[name] = c
+	}
+	if db == nil && conditions == nil {
+		return nil, nil
+	}
+	return &geoFence{db: db, conditions: conditions}, nil
+}
+
+// DB returns the GeoIP database of the geoFence, if any.
+func (f *geoFence) DB() *geoip.DB {
+	if f == nil {
+		return nil
+	}
+	return f.db
+}
+
+// Condition returns the geo condition of the policy, if any.
+func (f *geoFence) Condition(policy string) (GeoCondition, bool) {
+	if f == nil {
+		return GeoCondition{}, false
+	}
+	c, ok := f.conditions[policy]
+	if !ok {
+		return GeoCondition{}, false
+	}
+	return c.conf, true
+}
+
+// Allows reports whether the request may be sent by an identity
+// with the given policy. Requests the geo condition of the policy
+// does not apply to are always allowed. Requests from clients that
+// cannot be located are denied.
+func (f *geoFence) Allows(policy string, req *http.Request) bool {
+	if f == nil {
+		return true
+	}
+	c, ok := f.conditions[policy]
+	if !ok {
+		return true
+	}
+	if c.paths != nil && c.paths.Verify(req) != nil {
+		return true
+	}
+
+	record, ok, err := f.db.Lookup(clientIP(req))
+	if err != nil || !ok {
+		return false
+	}
+	return c.countries[record.Country] || c.continents[record.Continent]
+}
+
+// clientIP returns the IP address of the client. If the request
+// has been forwarded by a trusted proxy, it returns the forwarded
+// client IP.
+func clientIP(req *http.Request) netip.Addr {
+	if ip := https.ForwardedIPFromContext(req.Context()); ip != nil {
+		addr, _ := netip.AddrFromSlice(ip)
+		return addr.Unmap()
+	}
+	addr, _ := netip.ParseAddrPort(req.RemoteAddr)
+	return addr.Addr().Unmap()
+}
+
+// validGeoCode reports whether code is a two letter upper-case
+// country or continent code, like "DE" or "EU".
+func validGeoCode(code string) bool {
+	return len(code) == 2 && 'A' <= code[0] && code[0] <= 'Z' && 'A' <= code[1] && code[1] <= 'Z'
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/geoip"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestGeoFence(t *testing.T) {
+	t.Parallel()
+
+	db, err := geoip.Build(map[netip.Prefix]geoip.Record{
+		netip.MustParsePrefix("127.0.0.0/8"): {Country: "DE", Continent: "EU"},
+		netip.MustParsePrefix("::1/128"):     {Country: "DE", Continent: "EU"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build GeoIP database: %v", err)
+	}
+
+	euCert, _ := newRenewalCertificate(t, time.Hour)
+	usCert, _ := newRenewalCertificate(t, time.Hour)
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		GeoIP: &GeoIPConfig{Database: db},
+		Policies: map[string]Policy{
+			"eu": {
+				Allow:      map[string]kes.Rule{api.PathKeyCreate + "*": {}},
+				Identities: []kes.Identity{kes.Identity(renewalIdentity(euCert.Leaf))},
+				Geo:        &GeoCondition{Continents: []string{"EU"}},
+			},
+			"us": {
+				Allow:      map[string]kes.Rule{api.PathKeyCreate + "*": {}},
+				Identities: []kes.Identity{kes.Identity(renewalIdentity(usCert.Leaf))},
+				Geo: &GeoCondition{
+					Paths:     []string{api.PathKeyCreate + "us-*"},
+					Countries: []string{"US"},
+				},
+			},
+		},
+	})
+	defer srv.Close()
+
+	if err = renewalClient(url, euCert).CreateKey(ctx, "eu-key"); err != nil {
+		t.Fatalf("Failed to create key from within the EU: %v", err)
+	}
+	if err = renewalClient(url, usCert).CreateKey(ctx, "other-key"); err != nil {
+		t.Fatalf("Failed to create key not covered by the geo condition: %v", err)
+	}
+	if err = renewalClient(url, usCert).CreateKey(ctx, "us-key"); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Created key from outside the US: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
+
+func TestNewGeoFence(t *testing.T) {
+	b, err := geoip.Build(map[netip.Prefix]geoip.Record{
+		netip.MustParsePrefix("10.0.0.0/8"): {Country: "DE", Continent: "EU"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build GeoIP database: %v", err)
+	}
+	db, err := geoip.Parse(b)
+	if err != nil {
+		t.Fatalf("Failed to parse GeoIP database: %v", err)
+	}
+
+	for i, test := range newGeoFenceTests {
+		d := db
+		if test.NoDB {
+			d = nil
+		}
+		_, err := newGeoFence(d, map[string]Policy{"policy": {Geo: &test.Condition}})
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: created geo fence with invalid condition", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to create geo fence: %v", i, err)
+		}
+	}
+}
+
+var newGeoFenceTests = []struct {
+	Condition  GeoCondition
+	NoDB       bool
+	ShouldFail bool
+}{
+	{Condition: GeoCondition{Countries: []string{"DE"}}},                                         // 0
+	{Condition: GeoCondition{Continents: []string{"EU"}, Paths: []string{"/v1/key/decrypt/*"}}},  // 1
+	{Condition: GeoCondition{Countries: []string{"DE"}}, NoDB: true, ShouldFail: true},           // 2
+	{Condition: GeoCondition{}, ShouldFail: true},                                                // 3
+	{Condition: GeoCondition{Countries: []string{"de"}}, ShouldFail: true},                       // 4
+	{Condition: GeoCondition{Countries: []string{"DEU"}}, ShouldFail: true},                      // 5
+	{Condition: GeoCondition{Countries: []string{"DE"}, Paths: []string{"["}}, ShouldFail: true}, // 6
+}
@@ -0,0 +1,303 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+)
+
+const (
+	// healthProbeInterval is the interval in which the server
+	// probes its backends, like the key store.
+	healthProbeInterval = 10 * time.Second
+
+	// healthProbeTimeout is the time after which a health
+	// probe is considered failed.
+	healthProbeTimeout = 5 * time.Second
+
+	// healthWindow is the number of recent probes per backend
+	// the health state is derived from.
+	healthWindow = 30
+
+	// healthDegradedLatency is the average probe latency above
+	// which a backend is considered degraded.
+	healthDegradedLatency = 1 * time.Second
+)
+
+// Health states of a backend.
+const (
+	healthUnknown   = "unknown"   // Not probed yet
+	healthHealthy   = "healthy"   // Recent probes succeeded fast
+	healthDegraded  = "degraded"  // Some recent probes failed or are slow
+	healthUnhealthy = "unhealthy" // The most recent probe failed
+)
+
+// keyStoreBackend is the name of the key store backend.
+const keyStoreBackend = "keystore"
+
+// healthProbe is the result of probing a backend once.
+type healthProbe struct {
+	Time    time.Time
+	Latency time.Duration
+	Err     error
+
+	// Unauthenticated and Unauthorized indicate that the
+	// backend is reachable but rejected the server's
+	// credentials or denied access.
+	Unauthenticated bool
+	Unauthorized    bool
+}
+
+// Unreachable reports whether the probe failed for another
+// reason than rejected credentials or denied access.
+func (p healthProbe) Unreachable() bool {
+	return p.Err != nil && !p.Unauthenticated && !p.Unauthorized
+}
+
+// backendHealth tracks a rolling window of recent probes
+// of a single backend.
+type backendHealth struct {
+	probes [healthWindow]healthProbe
+	n      int // Number of probes within the window
+	next   int // Index of the next probe within the window
+
+	lastErr       error
+	lastErrAt     time.Time
+	lastSuccessAt time.Time
+}
+
+// healthMonitor tracks the health of all server backends.
+// The zero value is ready to use.
+type healthMonitor struct {
+	lock     sync.Mutex
+	backends map[string]*backendHealth
+}
+
+// Record adds the probe p to the rolling window of the
+// given backend.
+func (m *healthMonitor) Record(backend string, p healthProbe) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.backends == nil {
+		m.backends = map[string]*backendHealth{}
+	}
+	b, ok := m.backends[backend]
+	if !ok {
+		b = &backendHealth{}
+		m.backends[backend] = b
+	}
+
+	b.probes[b.next] = p
+	b.next = (b.next + 1) % healthWindow
+	b.n = min(b.n+1, healthWindow)
+	if p.Err != nil {
+		b.lastErr, b.lastErrAt = p.Err, p.Time
+	} else {
+		b.lastSuccessAt = p.Time
+	}
+}
+
+// Last returns the most recent probe of the given backend
+// and reports whether the backend has been probed.
+func (m *healthMonitor) Last(backend string) (healthProbe, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	b, ok := m.backends[backend]
+	if !ok || b.n == 0 {
+		return healthProbe{}, false
+	}
+	return b.probes[(b.next+healthWindow-1)%healthWindow], true
+}
+
+// Retain removes all backends for which keep returns false.
+func (m *healthMonitor) Retain(keep func(backend string) bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	maps.DeleteFunc(m.backends, func(backend string, _ *backendHealth) bool {
+		return !keep(backend)
+	})
+}
+
+// Status returns the health status of all backends
+// sorted by backend name.
+func (m *healthMonitor) Status() []api.BackendStatus {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	status := make([]api.BackendStatus, 0, len(m.backends))
+	for _, name := range slices.Sorted(maps.Keys(m.backends)) {
+		b := m.backends[name]
+
+		s := api.BackendStatus{
+			Name:          name,
+			State:         healthUnknown,
+			Probes:        b.n,
+			LastErrorAt:   b.lastErrAt,
+			LastSuccessAt: b.lastSuccessAt,
+		}
+		if b.lastErr != nil {
+			s.LastError = b.lastErr.Error()
+		}
+		if b.n > 0 {
+			last := b.probes[(b.next+healthWindow-1)%healthWindow]
+			s.Unauthenticated, s.Unauthorized = last.Unauthenticated, last.Unauthorized
+		}
+
+		var total, maxLatency time.Duration
+		for _, p := range b.probes[:b.n] {
+			if p.Err != nil {
+				s.Errors++
+			}
+			total += p.Latency
+			maxLatency = max(maxLatency, p.Latency)
+		}
+		if b.n > 0 {
+			avg := total / time.Duration(b.n)
+			s.LatencyAvg = avg.Milliseconds()
+			s.LatencyMax = maxLatency.Milliseconds()
+
+			switch last := b.probes[(b.next+healthWindow-1)%healthWindow]; {
+			case last.Err != nil:
+				s.State = healthUnhealthy
+			case s.Errors > 0 || avg > healthDegradedLatency:
+				s.State = healthDegraded
+			default:
+				s.State = healthHealthy
+			}
+		}
+		status = append(status, s)
+	}
+	return status
+}
+
+// startHealthProber starts a background goroutine that probes
+// all backends of the current server state periodically until
+// ctx is canceled.
+func (s *Server) startHealthProber(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(healthProbeInterval)
+		defer ticker.Stop()
+
+		for {
+			s.probeBackends(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// probeBackends probes the key store and all database
+// backends concurrently and records the results.
+func (s *Server) probeBackends(ctx context.Context) {
+	state := s.state.Load()
+
+	var wg sync.WaitGroup
+	wg.Go(func() { s.probeKeyStore(ctx, state) })
+	for name, e := range state.Databases {
+		wg.Go(func() {
+			s.probe(ctx, "database/"+name, func(ctx context.Context) (KeyStoreState, error) {
+				return KeyStoreState{}, e.db.PingContext(ctx)
+			})
+		})
+	}
+	wg.Wait()
+
+	s.health.Retain(func(backend string) bool {
+		if backend == keyStoreBackend {
+			return true
+		}
+		name, ok := strings.CutPrefix(backend, "database/")
+		if !ok {
+			return false
+		}
+		_, ok = state.Databases[name]
+		return ok
+	})
+}
+
+// probeKeyStore probes the key store of the given server
+// state and marks its key cache as offline if the key store
+// is not available.
+func (s *Server) probeKeyStore(ctx context.Context, state *serverState) (healthProbe, bool) {
+	p, ok := s.probe(ctx, keyStoreBackend, state.Keys.store.Status)
+	if ok {
+		state.Keys.offline.Store(p.Err != nil)
+	}
+	return p, ok
+}
+
+// probe calls f to probe the given backend and records the
+// result. f may return the latency reported by the backend.
+// Otherwise, the latency is measured. If f fails, the state
+// returned by f tells whether the backend rejected the server's
+// credentials or denied access.
+//
+// It reports whether the probe got recorded. Probes aborted
+// because ctx is canceled are not recorded.
+func (s *Server) probe(ctx context.Context, backend string, f func(context.Context) (KeyStoreState, error)) (healthProbe, bool) {
+	tctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	state, err := f(tctx)
+	latency := state.Latency
+	if latency <= 0 {
+		latency = time.Since(start)
+	}
+	if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		return healthProbe{}, false
+	}
+
+	p := healthProbe{Time: start, Latency: latency, Err: err}
+	if err != nil {
+		p.Unauthenticated, p.Unauthorized = state.Unauthenticated, state.Unauthorized
+	}
+	s.health.Record(backend, p)
+	return p, true
+}
+
+// keyStoreHealth returns the most recent key store probe. If the
+// key store hasn't been probed yet, it probes the key store.
+func (s *Server) keyStoreHealth(ctx context.Context) healthProbe {
+	if p, ok := s.health.Last(keyStoreBackend); ok {
+		return p
+	}
+	p, ok := s.probeKeyStore(ctx, s.state.Load())
+	if !ok {
+		return healthProbe{Time: time.Now(), Err: ctx.Err()}
+	}
+	return p
+}
+
+func (s *Server) backendStatus(resp *api.Response, req *api.Request) {
+	s.keyStoreHealth(req.Context()) // Probe the key store if not done yet
+
+	status := s.health.Status()
+	code := http.StatusOK
+	for _, b := range status {
+		if b.State == healthUnhealthy {
+			code = http.StatusServiceUnavailable
+			break
+		}
+	}
+	api.ReplyWith(resp, code, api.BackendStatusResponse{
+		Backends: status,
+	})
+}
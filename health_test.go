@@ -0,0 +1,180 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/keystore"
+)
+
+func TestHealthMonitor(t *testing.T) {
+	for i, test := range healthMonitorTests {
+		var m healthMonitor
+		for _, err := range test.Probes {
+			m.Record(keyStoreBackend, healthProbe{Time: time.Now(), Latency: test.Latency, Err: err})
+		}
+
+		status := m.Status()
+		if len(status) != 1 {
+			t.Fatalf("Test %d: got %d backends - want 1", i, len(status))
+		}
+		if status[0].State != test.State {
+			t.Fatalf("Test %d: state mismatch: got '%s' - want '%s'", i, status[0].State, test.State)
+		}
+		if status[0].Probes > healthWindow {
+			t.Fatalf("Test %d: window contains %d probes - want at most %d", i, status[0].Probes, healthWindow)
+		}
+	}
+}
+
+func TestBackendStatus(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	store := &unavailableKeyStore{}
+	srv, url := startServer(ctx, &Config{Keys: store})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	status := func() (int, api.BackendStatusResponse) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+api.PathBackend, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var body api.BackendStatusResponse
+		if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp.StatusCode, body
+	}
+
+	for _, ok := srv.health.Last(keyStoreBackend); !ok; _, ok = srv.health.Last(keyStoreBackend) {
+		time.Sleep(5 * time.Millisecond) // Wait for the initial background probe
+	}
+	code, body := status()
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", code, http.StatusServiceUnavailable)
+	}
+	if len(body.Backends) != 1 || body.Backends[0].Name != keyStoreBackend || body.Backends[0].State != healthUnhealthy {
+		t.Fatalf("Key store is not unhealthy: %+v", body.Backends)
+	}
+	if !srv.state.Load().Keys.offline.Load() {
+		t.Fatal("Key cache is not offline")
+	}
+
+	store.available.Store(true)
+	srv.probeBackends(ctx)
+	code, body = status()
+	if code != http.StatusOK {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", code, http.StatusOK)
+	}
+	if len(body.Backends) != 1 || body.Backends[0].State != healthDegraded {
+		t.Fatalf("Key store is not degraded: %+v", body.Backends)
+	}
+	if srv.state.Load().Keys.offline.Load() {
+		t.Fatal("Key cache is still offline")
+	}
+}
+
+func TestBackendStatusCredentials(t *testing.T) {
+	t.Parallel()
+
+	for i, test := range []KeyStoreState{
+		{Latency: time.Millisecond, Unauthenticated: true}, // 0
+		{Latency: time.Millisecond, Unauthorized: true},    // 1
+	} {
+		ctx := testContext(t)
+		srv, url := startServer(ctx, &Config{Keys: &deniedKeyStore{state: test}})
+		defer srv.Close()
+
+		client := defaultClient(url)
+		get := func(path string, v any) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+path, nil)
+			if err != nil {
+				t.Fatalf("Test %d: failed to create request: %v", i, err)
+			}
+			resp, err := client.HTTPClient.Do(req)
+			if err != nil {
+				t.Fatalf("Test %d: failed to send request: %v", i, err)
+			}
+			defer resp.Body.Close()
+			if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatalf("Test %d: failed to decode response: %v", i, err)
+			}
+		}
+
+		var backends api.BackendStatusResponse
+		get(api.PathBackend, &backends)
+		if len(backends.Backends) != 1 {
+			t.Fatalf("Test %d: got %d backends - want 1", i, len(backends.Backends))
+		}
+		if b := backends.Backends[0]; b.State != healthUnhealthy || b.Unauthenticated != test.Unauthenticated || b.Unauthorized != test.Unauthorized {
+			t.Fatalf("Test %d: backend status mismatch: got '%+v' - want '%+v'", i, b, test)
+		}
+
+		var status api.StatusResponse
+		get(api.PathStatus, &status)
+		if status.KeyStoreUnreachable {
+			t.Fatalf("Test %d: key store is unreachable", i)
+		}
+		if status.KeyStoreUnauthenticated != test.Unauthenticated || status.KeyStoreUnauthorized != test.Unauthorized {
+			t.Fatalf("Test %d: status mismatch: got '%+v' - want '%+v'", i, status, test)
+		}
+	}
+}
+
+var errProbe = errors.New("probe failed")
+
+var healthMonitorTests = []struct {
+	Probes  []error
+	Latency time.Duration
+	State   string
+}{
+	{Probes: []error{nil}, Latency: time.Millisecond, State: healthHealthy},                        // 0
+	{Probes: []error{nil}, Latency: 2 * time.Second, State: healthDegraded},                        // 1
+	{Probes: []error{nil, nil, errProbe}, Latency: time.Millisecond, State: healthUnhealthy},       // 2
+	{Probes: []error{errProbe, nil, nil}, Latency: time.Millisecond, State: healthDegraded},        // 3
+	{Probes: append([]error{errProbe}, make([]error, healthWindow)...), State: healthHealthy},      // 4
+	{Probes: []error{context.DeadlineExceeded}, Latency: time.Millisecond, State: healthUnhealthy}, // 5
+}
+
+// unavailableKeyStore is a KeyStore that is not
+// reachable until it becomes available.
+type unavailableKeyStore struct {
+	MemKeyStore
+	available atomic.Bool
+}
+
+func (s *unavailableKeyStore) Status(ctx context.Context) (KeyStoreState, error) {
+	if !s.available.Load() {
+		return KeyStoreState{}, &keystore.ErrUnreachable{Err: errors.New("connection refused")}
+	}
+	return s.MemKeyStore.Status(ctx)
+}
+
+// deniedKeyStore is a KeyStore that is reachable but
+// rejects the server's credentials or denies access.
+type deniedKeyStore struct {
+	MemKeyStore
+	state KeyStoreState
+}
+
+func (s *deniedKeyStore) Status(context.Context) (KeyStoreState, error) {
+	return s.state, errors.New("access denied")
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+// legalHoldPrefix is the prefix of legal hold entries at the
+// key store. It is followed by the key name. Like locks, legal
+// holds never collide with keys.
+const legalHoldPrefix = "-hold-"
+
+// maxLegalHoldReason is the max. length of a legal hold reason.
+const maxLegalHoldReason = 1024
+
+// legalHold is a legal hold on a key as stored at the key store.
+// While a key is under legal hold, it cannot be deleted.
+type legalHold struct {
+	Reason   string       `json:"reason,omitempty"`
+	PlacedAt time.Time    `json:"placed_at"`
+	PlacedBy kes.Identity `json:"placed_by"`
+}
+
+// errLegalHold returns the error returned when deleting
+// the key with the given name while it is under legal hold.
+func errLegalHold(name string) error {
+	return api.NewError(http.StatusConflict, fmt.Sprintf("key '%s' is under legal hold", name))
+}
+
+// loadLegalHold returns the legal hold on the key with the given
+// name. It returns kes.ErrKeyNotFound if the key is not under
+// legal hold.
+func loadLegalHold(ctx context.Context, store KeyStore, name string) (*legalHold, error) {
+	b, err := store.Get(ctx, legalHoldPrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	var hold legalHold
+	if err = json.Unmarshal(b, &hold); err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// checkLegalHold returns an error if the key with the given name is
+// under legal hold. Deletions fail closed: if the legal hold cannot
+// be read, checkLegalHold returns the key store error.
+//
+// Reserved entries, like canary keys, are never under legal hold.
+func checkLegalHold(ctx context.Context, store KeyStore, name string) error {
+	if isReservedEntry(name) {
+		return nil
+	}
+	_, err := store.Get(ctx, legalHoldPrefix+name)
+	if err == nil {
+		return errLegalHold(name)
+	}
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		return nil
+	}
+	return err
+}
+
+// heldKeys returns the names of all keys with the given
+// prefix that are under legal hold.
+func heldKeys(ctx context.Context, store KeyStore, prefix string) ([]string, error) {
+	names, _, err := store.List(ctx, legalHoldPrefix+prefix, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	held := make([]string, 0, len(names))
+	for _, name := range names {
+		if name, ok := strings.CutPrefix(name, legalHoldPrefix); ok && strings.HasPrefix(name, prefix) {
+			held = append(held, name)
+		}
+	}
+	return held, nil
+}
+
+// setLegalHold places or lifts a legal hold on a key. Placing a
+// hold on a key that is already under legal hold returns the
+// existing hold. Lifting the hold of a key that is not under
+// legal hold has no effect.
+func (s *Server) setLegalHold(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+	state := s.state.Load()
+
+	var body api.LegalHoldRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid legal hold request body")
+		return
+	}
+	if len(body.Reason) > maxLegalHoldReason {
+		resp.Failf(http.StatusBadRequest, "legal hold reason exceeds %d bytes", maxLegalHoldReason)
+		return
+	}
+
+	fail := func(err error, msg string) {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, msg)
+	}
+
+	const StatusOK = http.StatusOK
+	if !body.Hold {
+		err := state.Keys.store.Delete(req.Context(), legalHoldPrefix+req.Resource)
+		if err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+			fail(err, "failed to lift legal hold")
+			return
+		}
+		state.Audit.Log(fmt.Sprintf("legal hold on key '%s' lifted", req.Resource), StatusOK, req)
+		api.ReplyWith(resp, StatusOK, api.LegalHoldResponse{
+			Key: req.Resource,
+		})
+		return
+	}
+
+	if _, err := state.Keys.Get(req.Context(), req.Resource); err != nil {
+		fail(err, "failed to read key")
+		return
+	}
+	hold := legalHold{
+		Reason:   body.Reason,
+		PlacedAt: time.Now().UTC(),
+		PlacedBy: req.Identity,
+	}
+	b, err := json.Marshal(hold)
+	if err != nil {
+		fail(err, "failed to encode legal hold")
+		return
+	}
+
+	// Keep an existing hold as it is. Replacing it would leave
+	// a window in which the key is not under legal hold.
+	err = state.Keys.store.Create(req.Context(), legalHoldPrefix+req.Resource, b)
+	if errors.Is(err, kes.ErrKeyExists) {
+		existing, err := loadLegalHold(req.Context(), state.Keys.store, req.Resource)
+		if err != nil {
+			fail(err, "failed to read legal hold")
+			return
+		}
+		api.ReplyWith(resp, StatusOK, api.LegalHoldResponse{
+			Key:      req.Resource,
+			Hold:     true,
+			Reason:   existing.Reason,
+			PlacedAt: existing.PlacedAt,
+			PlacedBy: existing.PlacedBy.String(),
+		})
+		return
+	}
+	if err != nil {
+		fail(err, "failed to place legal hold")
+		return
+	}
+
+	msg := fmt.Sprintf("legal hold on key '%s' placed", req.Resource)
+	if hold.Reason != "" {
+		msg += ": " + hold.Reason
+	}
+	state.Audit.Log(msg, StatusOK, req)
+	api.ReplyWith(resp, StatusOK, api.LegalHoldResponse{
+		Key:      req.Resource,
+		Hold:     true,
+		Reason:   hold.Reason,
+		PlacedAt: hold.PlacedAt,
+		PlacedBy: hold.PlacedBy.String(),
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/minio/kes/internal/api"
+)
+
+func TestLegalHold(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		Keys: &MemKeyStore{},
+		Tenants: map[string]*TenantConfig{
+			"acme": {Prefix: "acme-"},
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	send := func(method, path string, body any) int {
+		var b []byte
+		if body != nil {
+			var err error
+			if b, err = json.Marshal(body); err != nil {
+				t.Fatalf("Failed to encode request: %v", err)
+			}
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url+path, bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := send(http.MethodPut, api.PathKeyHold+"acme-key", api.LegalHoldRequest{Hold: true}); code != http.StatusNotFound {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", code, http.StatusNotFound)
+	}
+	if err := client.CreateKey(ctx, "acme-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if code := send(http.MethodPut, api.PathKeyHold+"acme-key", api.LegalHoldRequest{Hold: true, Reason: "case 42"}); code != http.StatusOK {
+		t.Fatalf("Failed to place legal hold: status code '%d'", code)
+	}
+
+	for i, test := range legalHoldTests {
+		if code := send(test.Method, test.Path, nil); code != http.StatusConflict {
+			t.Fatalf("Test %d: status code mismatch: got '%d' - want '%d'", i, code, http.StatusConflict)
+		}
+	}
+
+	if code := send(http.MethodPut, api.PathKeyHold+"acme-key", api.LegalHoldRequest{Hold: false}); code != http.StatusOK {
+		t.Fatalf("Failed to lift legal hold: status code '%d'", code)
+	}
+	if code := send(http.MethodDelete, api.PathKeyDelete+"acme-key", nil); code != http.StatusOK {
+		t.Fatalf("Failed to delete key: status code '%d'", code)
+	}
+}
+
+var legalHoldTests = []struct {
+	Method string
+	Path   string
+}{
+	{Method: http.MethodDelete, Path: api.PathKeyDelete + "acme-key"}, // 0
+	{Method: http.MethodDelete, Path: api.PathKeyShred + "acme-key"},  // 1
+	{Method: http.MethodDelete, Path: api.PathTenantShred + "acme"},   // 2
+}
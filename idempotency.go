@@ -0,0 +1,214 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/cache"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kms-go/kes"
+)
+
+// Default idempotency cache limits. Retries are expected
+// within minutes. Hence, outcomes are kept for a short time.
+const (
+	idempotencyWindow     = 10 * time.Minute
+	maxIdempotencyEntries = 10000
+)
+
+// idempotencyCache remembers the outcome of mutating requests
+// that carry an Idempotency-Key header. A client that retries
+// a request, for example after a timeout, with the same key
+// receives the first outcome instead of executing the request
+// again.
+//
+// Outcomes are kept for a bounded time window and the number of
+// cached outcomes is limited. Server errors (5xx) are not cached
+// since retrying them may succeed.
+type idempotencyCache struct {
+	window     time.Duration
+	maxEntries int
+
+	barrier cache.Barrier[idempotencyKey]
+
+	mu      sync.Mutex
+	entries map[idempotencyKey]*idempotentResponse
+}
+
+// idempotencyKey identifies a request. Idempotency keys are
+// scoped to the client identity and the API.
+type idempotencyKey struct {
+	Identity kes.Identity
+	Method   string
+	Path     string
+	Key      string
+}
+
+type idempotentResponse struct {
+	Hash        [sha256.Size]byte // Request body checksum
+	Status      int
+	ContentType string
+	Body        []byte
+	Expires     time.Time
+}
+
+// newIdempotencyCache returns a new idempotencyCache that keeps
+// up to maxEntries outcomes for the given time window.
+func newIdempotencyCache(window time.Duration, maxEntries int) *idempotencyCache {
+	return &idempotencyCache{
+		window:     window,
+		maxEntries: maxEntries,
+		entries:    make(map[idempotencyKey]*idempotentResponse),
+	}
+}
+
+// idempotent returns a Handler that wraps h and replays cached
+// outcomes for requests with an Idempotency-Key header.
+//
+// Concurrent requests with the same key are serialized such
+// that only the first one executes h.
+func (s *Server) idempotent(h api.Handler) api.Handler {
+	return api.HandlerFunc(func(resp *api.Response, req *api.Request) {
+		key := req.Header.Get(headers.IdempotencyKey)
+		if key == "" {
+			h.ServeAPI(resp, req)
+			return
+		}
+
+		const MaxLength = 255
+		if len(key) > MaxLength {
+			resp.Failf(http.StatusBadRequest, "idempotency key exceeds %d bytes", MaxLength)
+			return
+		}
+
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			if err, ok := api.IsError(err); ok {
+				resp.Failr(err)
+				return
+			}
+			resp.Fail(http.StatusBadRequest, "failed to read request body")
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.Sum256(body)
+
+		c := s.state.Load().Idempotency
+		id := idempotencyKey{
+			Identity: req.Identity,
+			Method:   req.Method,
+			Path:     req.URL.Path,
+			Key:      key,
+		}
+		c.barrier.Lock(id)
+		defer c.barrier.Unlock(id)
+
+		if cached, ok := c.get(id); ok {
+			if cached.Hash != hash {
+				resp.Fail(http.StatusUnprocessableEntity, "idempotency key has already been used for a different request")
+				return
+			}
+			resp.Header().Set(headers.IdempotentReplayed, "true")
+			if cached.ContentType != "" {
+				resp.Header().Set(headers.ContentType, cached.ContentType)
+			}
+			resp.Header().Set(headers.ContentLength, strconv.Itoa(len(cached.Body)))
+			resp.WriteHeader(cached.Status)
+			resp.Write(cached.Body)
+			return
+		}
+
+		rw := &recordResponseWriter{ResponseWriter: resp.ResponseWriter}
+		resp.ResponseWriter = rw
+		h.ServeAPI(resp, req)
+
+		if rw.status > 0 && rw.status < 500 {
+			c.add(id, &idempotentResponse{
+				Hash:        hash,
+				Status:      rw.status,
+				ContentType: rw.Header().Get(headers.ContentType),
+				Body:        rw.body.Bytes(),
+				Expires:     time.Now().Add(c.window),
+			})
+		}
+	})
+}
+
+// get returns the cached outcome for the key, if any.
+func (c *idempotencyCache) get(key idempotencyKey) (*idempotentResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(r.Expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return r, true
+}
+
+// add caches the outcome for the key. Once the cache is full,
+// it removes all expired outcomes. If the cache is still full
+// afterwards, the outcome is not cached.
+func (c *idempotencyCache) add(key idempotencyKey, r *idempotentResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		now := time.Now()
+		for k, v := range c.entries {
+			if now.After(v.Expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	if len(c.entries) < c.maxEntries {
+		c.entries[key] = r
+	}
+}
+
+// recordResponseWriter is an http.ResponseWriter that records
+// the response status code and body.
+type recordResponseWriter struct {
+	http.ResponseWriter
+
+	status int
+	body   bytes.Buffer
+}
+
+var (
+	_ http.ResponseWriter = (*recordResponseWriter)(nil)
+	_ http.Flusher        = (*recordResponseWriter)(nil)
+)
+
+func (w *recordResponseWriter) WriteHeader(status int) {
+	w.ResponseWriter.WriteHeader(status)
+	w.status = status
+}
+
+func (w *recordResponseWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client.
+//
+// This method will be called by http.ResponseController.
+func (w *recordResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
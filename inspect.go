@@ -0,0 +1,78 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+)
+
+// maxInspectCiphertexts is the max. number of ciphertexts
+// within one InspectCiphertext request.
+const maxInspectCiphertexts = 1000
+
+// inspectCiphertext describes ciphertexts without decrypting them,
+// such that clients can find out which stored ciphertexts depend on
+// which keys before rotating or migrating them.
+//
+// It neither reads any key nor authenticates the ciphertexts. Only
+// envelopes record the key name and version. Ciphertexts produced
+// by the Encrypt and GenerateKey APIs do not refer to any key, such
+// that clients have to track their keys separately.
+func (s *Server) inspectCiphertext(resp *api.Response, req *api.Request) {
+	var body api.InspectCiphertextRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(body.Ciphertexts) > maxInspectCiphertexts {
+		resp.Failf(http.StatusBadRequest, "request contains more than %d ciphertexts", maxInspectCiphertexts)
+		return
+	}
+
+	infos := make([]api.CiphertextInfo, 0, len(body.Ciphertexts))
+	for _, ciphertext := range body.Ciphertexts {
+		infos = append(infos, inspectCiphertext(ciphertext))
+	}
+
+	const StatusOK = http.StatusOK
+	s.state.Load().Audit.Log("ciphertexts inspected", StatusOK, req)
+	api.ReplyWith(resp, StatusOK, api.InspectCiphertextResponse{
+		Ciphertexts: infos,
+	})
+}
+
+// inspectCiphertext describes a single ciphertext, which is either
+// a JSON-encoded envelope or a ciphertext produced by a SecretKey.
+func inspectCiphertext(ciphertext []byte) api.CiphertextInfo {
+	var env api.Envelope
+	if err := json.Unmarshal(ciphertext, &env); err == nil && env.Version == envelopeVersion && env.Key != "" {
+		return api.CiphertextInfo{
+			Format:     "envelope",
+			Key:        env.Key,
+			KeyVersion: env.KeyVersion,
+			Algorithm:  env.Algorithm,
+		}
+	}
+
+	info, err := crypto.InspectCiphertext(ciphertext)
+	if err != nil {
+		return api.CiphertextInfo{Error: "ciphertext is malformed"}
+	}
+	return api.CiphertextInfo{
+		Format:    info.Format,
+		KeyID:     info.KeyID,
+		Algorithm: info.Algorithm,
+	}
+}
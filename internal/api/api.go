@@ -5,6 +5,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"log/slog"
@@ -23,19 +24,53 @@ import (
 const (
 	PathVersion  = "/version"
 	PathStatus   = "/v1/status"
+	PathBackend  = "/v1/status/backend"
 	PathReady    = "/v1/ready"
 	PathMetrics  = "/v1/metrics"
 	PathListAPIs = "/v1/api"
-
-	PathKeyCreate   = "/v1/key/create/"
-	PathKeyImport   = "/v1/key/import/"
-	PathKeyDescribe = "/v1/key/describe/"
-	PathKeyDelete   = "/v1/key/delete/"
-	PathKeyList     = "/v1/key/list/"
-	PathKeyGenerate = "/v1/key/generate/"
-	PathKeyEncrypt  = "/v1/key/encrypt/"
-	PathKeyDecrypt  = "/v1/key/decrypt/"
-	PathKeyHMAC     = "/v1/key/hmac/"
+	PathOpenAPI  = "/v1/openapi"
+	PathBatch    = "/v1/batch"
+	PathChanges  = "/v1/changes"
+
+	PathMaintenance    = "/v1/maintenance"
+	PathSelfTest       = "/v1/selftest"
+	PathCacheSync      = "/v1/cache/sync"
+	PathCacheKey       = "/v1/cache/key/"
+	PathStandbyPromote = "/v1/standby/promote"
+
+	PathKeyCreate     = "/v1/key/create/"
+	PathKeyImport     = "/v1/key/import/"
+	PathKeyDescribe   = "/v1/key/describe/"
+	PathKeyDelete     = "/v1/key/delete/"
+	PathKeyList       = "/v1/key/list/"
+	PathKeyGenerate   = "/v1/key/generate/"
+	PathKeyEncrypt    = "/v1/key/encrypt/"
+	PathKeyDecrypt    = "/v1/key/decrypt/"
+	PathKeyCheck      = "/v1/key/check/"
+	PathKeyRewrap     = "/v1/key/rewrap/"
+	PathKeyHMAC       = "/v1/key/hmac/"
+	PathKeySeal       = "/v1/key/seal/"
+	PathKeyOpen       = "/v1/key/open/"
+	PathKeyTokenize   = "/v1/key/tokenize/"
+	PathKeyDetokenize = "/v1/key/detokenize/"
+	PathKeyReveal     = "/v1/key/reveal/"
+	PathKeyStale      = "/v1/key/stale/"
+	PathKeyInventory  = "/v1/key/inventory"
+	PathKeyShred      = "/v1/key/shred/"
+	PathKeyReceipt    = "/v1/key/receipt/"
+	PathKeyHold       = "/v1/key/hold/"
+	PathKeyFolder     = "/v1/key/folder/"
+	PathKeyOwner      = "/v1/key/owner/"
+	PathKeyReserve    = "/v1/key/reserve/"
+	PathKeyRelease    = "/v1/key/release/"
+
+	// PathKeyVersion is not an API route. Policies refer to it
+	// to allow clients to pin the version of a key.
+	PathKeyVersion = "/v1/key/version/"
+
+	PathTenantShred = "/v1/tenant/shred/"
+
+	PathCiphertextInspect = "/v1/ciphertext/inspect"
 
 	PathPolicyDescribe = "/v1/policy/describe/"
 	PathPolicyRead     = "/v1/policy/read/"
@@ -44,11 +79,77 @@ const (
 	PathIdentityDescribe     = "/v1/identity/describe/"
 	PathIdentityList         = "/v1/identity/list/"
 	PathIdentitySelfDescribe = "/v1/identity/self/describe"
+	PathIdentitySelfRenew    = "/v1/identity/self/renew"
+	PathIdentityStale        = "/v1/identity/stale/"
+
+	PathConnList  = "/v1/conn/list"
+	PathConnClose = "/v1/conn/close/"
+
+	PathJobStart    = "/v1/job/start/"
+	PathJobDescribe = "/v1/job/describe/"
+	PathJobList     = "/v1/job/list"
+	PathJobCancel   = "/v1/job/cancel/"
+
+	PathLockAcquire  = "/v1/lock/acquire/"
+	PathLockRenew    = "/v1/lock/renew/"
+	PathLockRelease  = "/v1/lock/release/"
+	PathLockDescribe = "/v1/lock/describe/"
+
+	PathDBCredentials = "/v1/db/creds/"
+	PathDBRevoke      = "/v1/db/revoke/"
+
+	PathSSHCA   = "/v1/ssh/ca"
+	PathSSHSign = "/v1/ssh/sign/"
+
+	PathPKICA      = "/v1/pki/ca"
+	PathPKIImport  = "/v1/pki/ca/import"
+	PathPKIIssue   = "/v1/pki/issue/"
+	PathPKIRevoke  = "/v1/pki/revoke/"
+	PathPKICRL     = "/v1/pki/crl"
+	PathPKIOCSP    = "/v1/pki/ocsp"
+	PathPKIOCSPGet = "/v1/pki/ocsp/"
+
+	PathWitnessCosign = "/v1/witness/cosign"
+
+	PathLogin          = "/v1/login"
+	PathLoginChallenge = "/v1/login/challenge"
+
+	PathAttestation = "/v1/attestation"
 
-	PathLogError = "/v1/log/error"
-	PathLogAudit = "/v1/log/audit"
+	PathMerkleRoot  = "/v1/merkle/root"
+	PathMerkleProof = "/v1/merkle/proof/"
+
+	PathLogError          = "/v1/log/error"
+	PathLogAudit          = "/v1/log/audit"
+	PathLogAuditPseudonym = "/v1/log/audit/pseudonym/"
+)
+
+// API versions. Each API route is available under a "/v1/" and
+// a "/v2/" path prefix. Both versions share the same handlers
+// and policies always refer to the "/v1/" path. However, the v2
+// API always responds with RFC 7807 problem details on errors
+// and handlers may evolve their v2 response schemas based on
+// the Request.Version.
+const (
+	V1 = 1
+	V2 = 2
+
+	LatestVersion = V2
 )
 
+// VersionPath returns the path of the API route p for the given
+// API version. For example, VersionPath("/v1/status", V2) returns
+// "/v2/status". Paths without an API version prefix, like
+// "/version", are returned unchanged.
+func VersionPath(p string, version int) string {
+	for v := V1; v <= LatestVersion; v++ {
+		if rest, ok := strings.CutPrefix(p, "/v"+strconv.Itoa(v)+"/"); ok {
+			return "/v" + strconv.Itoa(version) + "/" + rest
+		}
+	}
+	return p
+}
+
 // Route represents an API route handling a client request.
 type Route struct {
 	Method  string        // The HTTP method (GET, PUT, DELETE, ...)
@@ -57,6 +158,23 @@ type Route struct {
 	Timeout time.Duration // Timeout after which the request gets aborted
 	Auth    Authenticator // The authentication method for this API route
 	Handler Handler       // The API handler implementing the server-side logic
+
+	// Version is the API version of the route. If > V1, the
+	// route serves requests sent to the VersionPath of Path.
+	// If zero, defaults to V1.
+	Version int
+
+	// Deprecation and Sunset, if set, are sent to clients as
+	// "Deprecation" (RFC 9745) and "Sunset" (RFC 8594) headers.
+	// Successor, if set, is sent as "Link" header pointing to
+	// the route replacing this one.
+	Deprecation time.Time
+	Sunset      time.Time
+	Successor   string
+
+	// Doc documents the API route. It is used to generate
+	// the OpenAPI specification.
+	Doc RouteDoc
 }
 
 // ServeHTTP implements the http.Handler for Route and handles an incoming
@@ -66,17 +184,30 @@ type Route struct {
 //     prefix of the request path.
 //   - Limit the request body to Route.MaxBody.
 //   - Apply Route.Timeout and timeout the request if generating a response
-//     takes longer.
+//     takes longer. Clients may shorten the timeout, but not extend it, by
+//     sending a "Request-Timeout" header, e.g. "Request-Timeout: 250ms".
 //   - Authenticate the request. If Route.Auth.Authenticate returns an error
 //     the error is sent to the client and the route handler is not invoked.
 //   - Handle the request. The Route.Handler.ServeAPI is invoked with the
 //     authenticated request.
 func (ro Route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	version := max(ro.Version, V1)
 	resp := &Response{
 		ResponseWriter: w,
+		Problem:        ProblemDetails(r.Header, version),
 	}
 	received := time.Now()
 
+	if !ro.Deprecation.IsZero() {
+		w.Header().Set(headers.Deprecation, "@"+strconv.FormatInt(ro.Deprecation.Unix(), 10))
+	}
+	if !ro.Sunset.IsZero() {
+		w.Header().Set(headers.Sunset, ro.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if ro.Successor != "" {
+		w.Header().Set(headers.Link, "<"+ro.Successor+`>; rel="successor-version"`)
+	}
+
 	if r.Method != ro.Method {
 		if r.Method != http.MethodPost || ro.Method != http.MethodPut {
 			w.Header().Set(headers.Accept, ro.Method)
@@ -90,6 +221,13 @@ func (ro Route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if len(r.URL.Path) > 0 && r.URL.Path[0] != '/' {
 		r.URL.Path = "/" + r.URL.Path
 	}
+
+	// Requests to newer API versions are handled like v1 requests.
+	// Hence, policies, audit logs, etc. see the v1 path.
+	if version > V1 {
+		r.URL.Path = VersionPath(r.URL.Path, V1)
+		r.URL.RawPath = ""
+	}
 	resource, ok := strings.CutPrefix(r.URL.Path, ro.Path)
 	if !ok {
 		resp.Failf(http.StatusInternalServerError, "routing error: request '%s' handled by route '%s'", r.URL.Path, ro.Path)
@@ -104,9 +242,27 @@ func (ro Route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		r.Body = http.MaxBytesReader(w, r.Body, r.ContentLength)
 	}
 
-	// Set a timeout.
-	if ro.Timeout > 0 {
-		if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(ro.Timeout)); err != nil {
+	// Set a timeout. A client-supplied timeout can only shorten
+	// the route timeout. It also cancels the request context such
+	// that handlers stop talking to the key store once the client
+	// is no longer interested in the response.
+	timeout := ro.Timeout
+	if v := r.Header.Get(headers.RequestTimeout); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			resp.Failf(http.StatusBadRequest, "invalid request timeout '%s'", v)
+			return
+		}
+		if timeout <= 0 || d < timeout {
+			timeout = d
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+	}
+	if timeout > 0 {
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout)); err != nil {
 			if errors.Is(err, http.ErrNotSupported) {
 				Failf(resp, http.StatusInternalServerError, "route '%s' does not support timeouts", ro.Path)
 				return
@@ -124,6 +280,7 @@ func (ro Route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	req.Resource = resource
 	req.Received = received
+	req.Version = version
 	ro.Handler.ServeAPI(resp, req)
 }
 
@@ -187,6 +344,10 @@ type Request struct {
 	Resource string
 
 	Received time.Time
+
+	// Version is the API version requested by the client,
+	// either V1 or V2.
+	Version int
 }
 
 // LogValue returns the requests logging representation.
@@ -209,6 +370,26 @@ func (r *Request) LogValue() slog.Value {
 // Response is an API response.
 type Response struct {
 	http.ResponseWriter
+
+	// Problem controls whether errors are sent as RFC 7807
+	// problem details. Refer to ProblemDetails.
+	Problem bool
+}
+
+// ProblemDetails reports whether errors of a request with the
+// given headers to the given API version are sent as RFC 7807
+// problem details.
+//
+// The v1 API sends problem details unless the client asks for
+// the legacy {"message": "..."} format by sending an "Accept"
+// header that lists "application/json" but not
+// "application/problem+json". The v2 API always sends problem
+// details.
+func ProblemDetails(h http.Header, version int) bool {
+	if version >= V2 {
+		return true
+	}
+	return !headers.AcceptsExplicitly(h, headers.ContentTypeJSON) || headers.AcceptsExplicitly(h, headers.ContentTypeProblemJSON)
 }
 
 var (
@@ -12,17 +12,28 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"aead.dev/mem"
 	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kms-go/kes"
 )
 
 // Failr responds to the client with err. The response
 // status code is set to err.Status. The error encoding
 // format is selected automatically based on the response
 // content type. Handlers should return after calling Failr.
+//
+// If err implements a RetryAfter() time.Duration method,
+// Failr sets the Retry-After header to tell clients when
+// to retry the request.
 func Failr(r *Response, err Error) error {
-	return Fail(r, err.Status(), err.Error())
+	if e, ok := err.(interface{ RetryAfter() time.Duration }); ok {
+		if d := e.RetryAfter(); d > 0 {
+			r.Header().Set(headers.RetryAfter, strconv.Itoa(int((d+time.Second-1)/time.Second)))
+		}
+	}
+	return fail(r, err.Status(), ErrorCode(err), err.Error())
 }
 
 // Failf responds to the client with the given status code
@@ -37,8 +48,37 @@ func Failf(r *Response, code int, format string, a ...any) error {
 // and error message. The message encoding format is selected
 // automatically based on the response content type. Handlers
 // should return after calling Fail.
+//
+// The error code of RFC 7807 problem details is derived from
+// the status code. Handlers that want to send a more specific
+// error code should use Failr with an error created by
+// NewErrorCode.
 func Fail(r *Response, code int, msg string) error {
+	return fail(r, code, statusErrorCode(code), msg)
+}
+
+// fail responds to the client with the given status code, error
+// code and message. Clients receive RFC 7807 problem details
+// unless they asked for the legacy {"message": "..."} format.
+func fail(r *Response, code int, errCode, msg string) error {
 	var buf bytes.Buffer
+	if r.Problem {
+		if err := json.NewEncoder(&buf).Encode(Problem{
+			Type:   ProblemTypePrefix + errCode,
+			Title:  http.StatusText(code),
+			Status: code,
+			Detail: msg,
+			Code:   errCode,
+		}); err != nil {
+			return err
+		}
+		r.Header().Set(headers.ContentType, headers.ContentTypeProblemJSON)
+		r.Header().Set(headers.ContentLength, strconv.Itoa(buf.Len()))
+		r.WriteHeader(code)
+		_, err := r.Write(buf.Bytes())
+		return err
+	}
+
 	buf.WriteString(`{"message":`)
 	if err := json.NewEncoder(&buf).Encode(msg); err != nil {
 		return err
@@ -52,6 +92,98 @@ func Fail(r *Response, code int, msg string) error {
 	return err
 }
 
+// ProblemTypePrefix is the prefix of RFC 7807 problem
+// types. It is followed by the error code.
+const ProblemTypePrefix = "urn:kes:error:"
+
+// Problem is an RFC 7807 problem details error response.
+// The Code is a stable, machine-readable error code. It
+// does not change when the Detail message changes.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code"`
+}
+
+// Error codes of well-known errors. Other errors have
+// generic error codes based on their HTTP status code.
+const (
+	CodeKeyNotFound      = "key_not_found"
+	CodeKeyExists        = "key_exists"
+	CodePolicyNotFound   = "policy_not_found"
+	CodePolicyExists     = "policy_exists"
+	CodeIdentityNotFound = "identity_not_found"
+	CodeIdentityExists   = "identity_exists"
+	CodeNotAllowed       = "not_allowed"
+	CodeDecrypt          = "decryption_failed"
+)
+
+// sdkErrorCodes maps the errors defined by the kes SDK,
+// which handlers and key stores return as is, to their
+// error codes.
+var sdkErrorCodes = map[kes.Error]string{
+	kes.ErrKeyNotFound:      CodeKeyNotFound,
+	kes.ErrKeyExists:        CodeKeyExists,
+	kes.ErrPolicyNotFound:   CodePolicyNotFound,
+	kes.ErrPolicyExists:     CodePolicyExists,
+	kes.ErrIdentityNotFound: CodeIdentityNotFound,
+	kes.ErrIdentityExists:   CodeIdentityExists,
+	kes.ErrNotAllowed:       CodeNotAllowed,
+	kes.ErrDecrypt:          CodeDecrypt,
+}
+
+// statusErrorCodes are the generic error codes of errors
+// by HTTP status code.
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusNotAcceptable:         "not_acceptable",
+	http.StatusConflict:              "conflict",
+	http.StatusGone:                  "gone",
+	http.StatusPreconditionFailed:    "precondition_failed",
+	http.StatusRequestEntityTooLarge: "request_too_large",
+	http.StatusUnprocessableEntity:   "unprocessable",
+	http.StatusTooManyRequests:       "too_many_requests",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusNotImplemented:        "not_implemented",
+	http.StatusBadGateway:            "bad_gateway",
+	http.StatusServiceUnavailable:    "unavailable",
+	http.StatusGatewayTimeout:        "timeout",
+}
+
+// ErrorCode returns the stable error code of err. It is
+// the code err has been created with, if any. Otherwise,
+// ErrorCode falls back to a generic error code based on
+// err's HTTP status code.
+func ErrorCode(err Error) string {
+	if e, ok := err.(interface{ Code() string }); ok && e.Code() != "" {
+		return e.Code()
+	}
+	if e, ok := err.(kes.Error); ok {
+		if errCode, ok := sdkErrorCodes[e]; ok {
+			return errCode
+		}
+	}
+	return statusErrorCode(err.Status())
+}
+
+// statusErrorCode returns the generic error code for
+// the given HTTP status code.
+func statusErrorCode(code int) string {
+	if errCode, ok := statusErrorCodes[code]; ok {
+		return errCode
+	}
+	if code >= 500 {
+		return statusErrorCodes[http.StatusInternalServerError]
+	}
+	return statusErrorCodes[http.StatusBadRequest]
+}
+
 // Error is an API error.
 //
 // Status codes should be within 400 (inclusive) and 600 (exclusive).
@@ -75,6 +207,17 @@ func NewError(code int, msg string) Error {
 	}
 }
 
+// NewErrorCode returns a new Error from the given status
+// code, stable error code and error message. Clients
+// receive errCode as part of RFC 7807 problem details.
+func NewErrorCode(code int, errCode, msg string) Error {
+	return &codeError{
+		code:    code,
+		errCode: errCode,
+		msg:     msg,
+	}
+}
+
 // IsError reports whether any error in err's tree is an
 // Error. It returns the first error that implements Error,
 // if any.
@@ -139,20 +282,27 @@ func readErrorMessage(resp *http.Response, maxSize mem.Size) (string, error) {
 	default:
 		type ErrResponse struct {
 			Message string `json:"error"`
+			Detail  string `json:"detail"` // RFC 7807 problem details
 		}
 		var response ErrResponse
 		if err := json.NewDecoder(body).Decode(&response); err != nil {
 			return "", err
 		}
+		if response.Detail != "" {
+			return response.Detail, nil
+		}
 		return response.Message, nil
 	}
 }
 
 type codeError struct {
-	code int
-	msg  string
+	code    int
+	errCode string
+	msg     string
 }
 
 func (e *codeError) Error() string { return e.msg }
 
 func (e *codeError) Status() int { return e.code }
+
+func (e *codeError) Code() string { return e.errCode }
@@ -0,0 +1,322 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/headers"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification
+// implemented by OpenAPI documents.
+const OpenAPIVersion = "3.1.0"
+
+// RouteDoc documents the parameters, request and response
+// of an API route. It is used to generate the OpenAPI
+// specification of the KES API.
+type RouteDoc struct {
+	// Summary is a short description of the API.
+	Summary string
+
+	// Param is the name of the path parameter of routes
+	// whose Path ends with a '/'. For example, "name" for
+	// the "/v1/key/create/" API. If empty, defaults to
+	// "resource".
+	Param string
+
+	// Query contains the names of all optional query
+	// parameters supported by the API.
+	Query []string
+
+	// Request is the zero value of the JSON request body
+	// type, or nil if the API does not consume a JSON body.
+	Request any
+
+	// Response is the zero value of the response body type,
+	// or nil if the API does not produce a structured body.
+	// For streaming APIs, Response is the type of a single
+	// event.
+	Response any
+
+	// ContentType is the response content type. If empty,
+	// defaults to "application/json" if Response is set.
+	ContentType string
+
+	// Status is the status code of a successful response.
+	// If zero, defaults to 200 OK.
+	Status int
+}
+
+// OpenAPIDocument is an OpenAPI 3.1 document describing the
+// KES API.
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+	Security   []map[string][]string                   `json:"security"`
+}
+
+// OpenAPIInfo contains the OpenAPI document metadata.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIComponents contains the reusable schemas and security
+// schemes of an OpenAPIDocument.
+type OpenAPIComponents struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]map[string]any `json:"securitySchemes"`
+}
+
+// OpenAPIOperation describes a single API operation.
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenAPIBody                `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+// OpenAPIParameter describes a path or query parameter.
+type OpenAPIParameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// OpenAPIBody describes a request body.
+type OpenAPIBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a response.
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType describes the schema of a request or
+// response body with a particular content type.
+type OpenAPIMediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a JSON schema (draft 2020-12) as used by OpenAPI 3.1.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Minimum              *int               `json:"minimum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// OpenAPI returns an OpenAPI document describing the given API
+// routes. Request and response schemas are derived from the
+// RouteDoc of each route.
+func OpenAPI(version string, routes map[string]Route) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info: OpenAPIInfo{
+			Title:   "KES",
+			Version: version,
+			Description: "Each /v1/ API is also served under the /v2/ prefix. " +
+				"Errors are sent as RFC 7807 problem details. v1 clients may " +
+				"request the legacy error format via 'Accept: application/json'.",
+		},
+		Paths: make(map[string]map[string]*OpenAPIOperation, len(routes)),
+		Components: OpenAPIComponents{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]map[string]any{
+				"mTLS": {"type": "mutualTLS"},
+			},
+		},
+		Security: []map[string][]string{{"mTLS": {}}},
+	}
+	schemas := doc.Components.Schemas
+	problem := schemaOf(reflect.TypeOf(Problem{}), schemas)
+	legacyError := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{"message": {Type: "string"}},
+	}
+
+	for _, ro := range routes {
+		path, op := ro.Path, &OpenAPIOperation{
+			OperationID: operationID(ro.Path),
+			Summary:     ro.Doc.Summary,
+			Responses:   map[string]*OpenAPIResponse{},
+		}
+		if strings.HasSuffix(path, "/") {
+			param := ro.Doc.Param
+			if param == "" {
+				param = "resource"
+			}
+			path += "{" + param + "}"
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name:     param,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+		for _, name := range ro.Doc.Query {
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name:   name,
+				In:     "query",
+				Schema: &Schema{Type: "string"},
+			})
+		}
+		if ro.Auth == InsecureSkipVerify {
+			op.Security = []map[string][]string{{}}
+		}
+
+		if ro.Doc.Request != nil {
+			op.RequestBody = &OpenAPIBody{
+				Required: true,
+				Content: map[string]OpenAPIMediaType{
+					headers.ContentTypeJSON: {Schema: schemaOf(reflect.TypeOf(ro.Doc.Request), schemas)},
+				},
+			}
+		}
+
+		status := ro.Doc.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := &OpenAPIResponse{Description: http.StatusText(status)}
+		contentType := ro.Doc.ContentType
+		if contentType == "" && ro.Doc.Response != nil {
+			contentType = headers.ContentTypeJSON
+		}
+		switch {
+		case ro.Doc.Response != nil:
+			success.Content = map[string]OpenAPIMediaType{
+				contentType: {Schema: schemaOf(reflect.TypeOf(ro.Doc.Response), schemas)},
+			}
+		case contentType != "":
+			success.Content = map[string]OpenAPIMediaType{
+				contentType: {Schema: &Schema{Type: "string"}},
+			}
+		}
+		op.Responses[strconv.Itoa(status)] = success
+		op.Responses["default"] = &OpenAPIResponse{
+			Description: "Error",
+			Content: map[string]OpenAPIMediaType{
+				headers.ContentTypeProblemJSON: {Schema: problem},
+				headers.ContentTypeJSON:        {Schema: legacyError},
+			},
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*OpenAPIOperation{}
+		}
+		doc.Paths[path][strings.ToLower(ro.Method)] = op
+	}
+	return doc
+}
+
+// operationID returns the OpenAPI operation ID for the
+// API path. For example, "keyCreate" for "/v1/key/create/".
+func operationID(path string) string {
+	path = strings.TrimPrefix(path, "/v1/")
+	var id strings.Builder
+	for i, s := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '-' || r == '_' }) {
+		if i > 0 {
+			s = strings.ToUpper(s[:1]) + s[1:]
+		}
+		id.WriteString(s)
+	}
+	return id.String()
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// schemaOf returns the JSON schema of values of type t when
+// encoded as JSON. Schemas of named struct types are added to
+// schemas and referenced.
+func schemaOf(t reflect.Type, schemas map[string]*Schema) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		if t != bytesType && t.Name() != "" { // e.g. json.RawMessage
+			return &Schema{}
+		}
+		return &Schema{Type: "string", ContentEncoding: "base64"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		ref := &Schema{Ref: "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref
+		}
+
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		schemas[t.Name()] = schema // Add before recursion to support recursive types
+		structProperties(t, schema.Properties, schemas)
+		return ref
+	default:
+		return &Schema{}
+	}
+}
+
+// structProperties adds the JSON properties of the struct
+// type t to props. Embedded structs are flattened.
+func structProperties(t reflect.Type, props map[string]*Schema, schemas map[string]*Schema) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			structProperties(field.Type, props, schemas)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		props[name] = schemaOf(field.Type, schemas)
+	}
+}
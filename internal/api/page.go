@@ -0,0 +1,105 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A Page selects a range of entries from a lexicographically
+// sorted listing.
+//
+// A page starts right after the entry the cursor points to.
+// Since a cursor refers to an entry name rather than an index,
+// listing all pages neither skips nor duplicates entries when
+// other entries are created or deleted concurrently. Entries
+// created before the cursor, after the listing has passed them,
+// are not part of any subsequent page.
+type Page struct {
+	// Limit is the max. number of entries within the page.
+	// If Limit <= 0, the page contains all remaining entries.
+	Limit int
+
+	// After is the name of the last entry of the previous
+	// page. If empty, the page starts at the first entry.
+	After string
+}
+
+// ParsePage parses the Page from the optional 'limit' and
+// 'cursor' query parameters of the request.
+func ParsePage(req *Request) (Page, Error) {
+	query := req.URL.Query()
+
+	var page Page
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return Page{}, NewError(http.StatusBadRequest, "invalid page limit '"+v+"'")
+		}
+		page.Limit = n
+	}
+	if v := query.Get("cursor"); v != "" {
+		after, ok := decodeCursor(v)
+		if !ok {
+			return Page{}, NewError(http.StatusBadRequest, "invalid cursor '"+v+"'")
+		}
+		page.After = after
+	}
+	return page, nil
+}
+
+// Seek returns the entries of the sorted slice that come
+// after p.After.
+func (p Page) Seek(sorted []string) []string {
+	if p.After == "" {
+		return sorted
+	}
+	i, found := slices.BinarySearch(sorted, p.After)
+	if found {
+		i++
+	}
+	return sorted[i:]
+}
+
+// Cut truncates the entries to at most p.Limit entries.
+// If entries contains more than p.Limit entries, it returns
+// an opaque cursor pointing to the last returned entry.
+// Otherwise, the returned cursor is empty.
+func (p Page) Cut(entries []string) ([]string, string) {
+	if p.Limit <= 0 || len(entries) <= p.Limit {
+		return entries, ""
+	}
+	entries = entries[:p.Limit]
+	return entries, encodeCursor(entries[len(entries)-1])
+}
+
+// IsFull reports whether the entries contain more than
+// p.Limit entries, and therefore, fill the entire page.
+// Handlers may use it to stop collecting entries early.
+func (p Page) IsFull(entries []string) bool {
+	return p.Limit > 0 && len(entries) > p.Limit
+}
+
+// cursorPrefix versions the cursor encoding such that
+// cursors created by different server versions can be
+// told apart.
+const cursorPrefix = "v1:"
+
+func encodeCursor(name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + name))
+}
+
+func decodeCursor(cursor string) (string, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", false
+	}
+	name, ok := strings.CutPrefix(string(b), cursorPrefix)
+	return name, ok && name != ""
+}
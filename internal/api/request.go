@@ -4,10 +4,40 @@
 
 package api
 
+import "time"
+
+// CreateKeyRequest is the request sent by clients when calling the CreateKey API.
+// The request body is optional.
+type CreateKeyRequest struct {
+	TimeLock *KeyTimeLock `json:"time_lock"`         // optional
+	Owner    string       `json:"owner,omitempty"`   // optional, unless required by the server
+	Contact  string       `json:"contact,omitempty"` // optional, unless required by the server
+}
+
+// KeyTimeLock restricts when a key can be used for cryptographic
+// operations. A time-locked key cannot be used before NotBefore
+// and, if windows are specified, only within one of the windows.
+type KeyTimeLock struct {
+	NotBefore time.Time       `json:"not_before,omitempty"`
+	Windows   []KeyTimeWindow `json:"windows,omitempty"`
+}
+
+// KeyTimeWindow is a daily time window, in UTC, during which a
+// time-locked key can be used. Start and End have the form "15:04".
+// If End is before Start, the window ends on the next day. If Days
+// is empty, the window applies to all weekdays.
+type KeyTimeWindow struct {
+	Days  []string `json:"days,omitempty"` // For example: "mon", "tue", ...
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+}
+
 // ImportKeyRequest is the request sent by clients when calling the ImportKey API.
 type ImportKeyRequest struct {
-	Bytes  []byte `json:"key"`
-	Cipher string `json:"cipher"`
+	Bytes   []byte `json:"key"`
+	Cipher  string `json:"cipher"`
+	Owner   string `json:"owner,omitempty"`   // optional, unless required by the server
+	Contact string `json:"contact,omitempty"` // optional, unless required by the server
 }
 
 // EncryptKeyRequest is the request sent by clients when calling the EncryptKey API.
@@ -30,8 +60,181 @@ type DecryptKeyRequest struct {
 	Version    string `json:"version"` // optional
 }
 
+// CheckDecryptRequest is the request sent by clients when calling the CheckDecrypt API.
+type CheckDecryptRequest struct {
+	Identity   string `json:"identity,omitempty"` // optional, defaults to the client identity
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// RewrapKeyRequest is the request sent by clients when calling the RewrapKey API.
+// WrappedKey is a data encryption key wrapped with the KES key KEK using the
+// key wrapping algorithm, either "AES-KW" (RFC 3394) or "AES-KWP" (RFC 5649).
+type RewrapKeyRequest struct {
+	KEK        string `json:"kek"`
+	Algorithm  string `json:"algorithm"`
+	WrappedKey []byte `json:"wrapped_key"`
+	Context    []byte `json:"context"` // optional
+}
+
+// InspectCiphertextRequest is the request sent by clients when calling the InspectCiphertext API.
+// Each ciphertext is either a ciphertext produced by the Encrypt or GenerateKey API or a JSON-encoded Envelope.
+type InspectCiphertextRequest struct {
+	Ciphertexts [][]byte `json:"ciphertexts"`
+}
+
 // HMACRequest is the request sent by clients when calling the HMAC API.
 type HMACRequest struct {
 	Message []byte `json:"message"`
 	Version string `json:"version"` // optional
 }
+
+// Operations supported by the Batch API.
+const (
+	BatchCreateKey      = "create_key"
+	BatchImportKey      = "import_key"
+	BatchSetPolicy      = "set_policy"
+	BatchAssignIdentity = "assign_identity"
+)
+
+// LegalHoldRequest is the request sent by clients when calling the LegalHold API.
+type LegalHoldRequest struct {
+	Hold   bool   `json:"hold"`
+	Reason string `json:"reason"` // optional
+}
+
+// MoveKeyRequest is the request sent by clients when calling the MoveKey API.
+type MoveKeyRequest struct {
+	Folder string `json:"folder"` // Empty to remove the key from its folder
+}
+
+// SetKeyOwnerRequest is the request sent by clients when calling the SetKeyOwner API.
+type SetKeyOwnerRequest struct {
+	Owner   string `json:"owner"`
+	Contact string `json:"contact"`
+}
+
+// SealEnvelopeRequest is the request sent by clients when calling the SealEnvelope API.
+type SealEnvelopeRequest struct {
+	Plaintext []byte `json:"plaintext"`
+	Context   []byte `json:"context"` // optional
+}
+
+// OpenEnvelopeRequest is the request sent by clients when calling the OpenEnvelope API.
+type OpenEnvelopeRequest struct {
+	Envelope Envelope `json:"envelope"`
+	Context  []byte   `json:"context"` // optional
+}
+
+// TokenizeRequest is the request sent by clients when calling the Tokenize, Detokenize or Reveal API.
+type TokenizeRequest struct {
+	Values []string `json:"values"`
+	Tweak  []byte   `json:"tweak"` // optional
+}
+
+// BatchRequest is the request sent by clients when calling the Batch API.
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation is a single operation within a BatchRequest.
+// The fields, apart from Op, that have to be set depend on the
+// operation:
+//   - create_key:      Name, optionally Owner and Contact
+//   - import_key:      Name, Bytes, Cipher, optionally Owner and Contact
+//   - set_policy:      Name, Allow, Deny
+//   - assign_identity: Identity, Policy
+type BatchOperation struct {
+	Op       string   `json:"op"`
+	Name     string   `json:"name,omitempty"`
+	Bytes    []byte   `json:"key,omitempty"`
+	Cipher   string   `json:"cipher,omitempty"`
+	Owner    string   `json:"owner,omitempty"`
+	Contact  string   `json:"contact,omitempty"`
+	Allow    []string `json:"allow,omitempty"`
+	Deny     []string `json:"deny,omitempty"`
+	Identity string   `json:"identity,omitempty"`
+	Policy   string   `json:"policy,omitempty"`
+}
+
+// MaintenanceRequest is the request sent by clients when calling the Maintenance API.
+type MaintenanceRequest struct {
+	ReadOnly bool   `json:"read_only"`
+	Reason   string `json:"reason,omitempty"` // optional
+}
+
+// AcquireLockRequest is the request sent by clients when calling the AcquireLock API.
+type AcquireLockRequest struct {
+	TTL string `json:"ttl,omitempty"` // optional, e.g. "30s"
+}
+
+// RenewLockRequest is the request sent by clients when calling the RenewLock API.
+type RenewLockRequest struct {
+	Token string `json:"token"`
+	TTL   string `json:"ttl,omitempty"` // optional, e.g. "30s"
+}
+
+// ReleaseLockRequest is the request sent by clients when calling the ReleaseLock API.
+type ReleaseLockRequest struct {
+	Token string `json:"token"`
+}
+
+// ReserveKeyRequest is the request sent by clients when calling the ReserveKey API.
+// A request containing the token of the current reservation renews it.
+type ReserveKeyRequest struct {
+	Token string `json:"token,omitempty"` // optional
+	TTL   string `json:"ttl,omitempty"`   // optional, e.g. "30s"
+}
+
+// ReleaseKeyRequest is the request sent by clients when calling the ReleaseKey API.
+type ReleaseKeyRequest struct {
+	Token string `json:"token"`
+}
+
+// DBCredentialsRequest is the request sent by clients when calling the DBCredentials API.
+type DBCredentialsRequest struct {
+	TTL string `json:"ttl,omitempty"` // optional, e.g. "1h"
+}
+
+// SignSSHKeyRequest is the request sent by clients when calling the SignSSHKey API.
+type SignSSHKeyRequest struct {
+	PublicKey  string   `json:"public_key"` // authorized_keys format
+	Principals []string `json:"principals"`
+	TTL        string   `json:"ttl,omitempty"` // optional, e.g. "1h"
+}
+
+// ImportPKICARequest is the request sent by clients when calling the ImportPKICA API.
+type ImportPKICARequest struct {
+	Certificate string `json:"certificate"` // PEM, CA certificate first
+	PrivateKey  string `json:"private_key"` // PEM, PKCS #8
+}
+
+// RenewIdentityRequest is the request sent by clients when calling the RenewIdentity API.
+type RenewIdentityRequest struct {
+	CSR string `json:"csr"` // PEM
+}
+
+// IssueCertificateRequest is the request sent by clients when calling the IssueCertificate API.
+type IssueCertificateRequest struct {
+	CommonName  string   `json:"common_name,omitempty"`
+	DNSNames    []string `json:"dns_names,omitempty"`
+	IPAddresses []string `json:"ip_addresses,omitempty"`
+	TTL         string   `json:"ttl,omitempty"` // optional, e.g. "24h"
+	CSR         string   `json:"csr,omitempty"` // optional, PEM
+}
+
+// LoginRequest is the request sent by clients when calling the Login API.
+// It contains a WebAuthn assertion over the login challenge.
+type LoginRequest struct {
+	CredentialID      []byte `json:"credential_id"`
+	ClientData        []byte `json:"client_data"`        // clientDataJSON
+	AuthenticatorData []byte `json:"authenticator_data"` // authenticatorData
+	Signature         []byte `json:"signature"`
+}
+
+// CosignRequest is the request sent by clients when calling the Cosign API
+// of a witness KES server.
+type CosignRequest struct {
+	Method   string `json:"method"`             // e.g. "DELETE"
+	Path     string `json:"path"`               // e.g. "/v1/key/shred/my-key"
+	Identity string `json:"identity,omitempty"` // optional, defaults to the client identity
+}
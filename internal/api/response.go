@@ -25,8 +25,59 @@ type StatusResponse struct {
 	HeapAlloc  uint64 `json:"mem_heap_used"`
 	StackAlloc uint64 `json:"mem_stack_used"`
 
-	KeyStoreLatency     int64 `json:"keystore_latency,omitempty"` // In microseconds
-	KeyStoreUnreachable bool  `json:"keystore_unreachable,omitempty"`
+	KeyStoreLatency         int64 `json:"keystore_latency,omitempty"` // In microseconds
+	KeyStoreUnreachable     bool  `json:"keystore_unreachable,omitempty"`
+	KeyStoreUnauthenticated bool  `json:"keystore_unauthenticated,omitempty"`
+	KeyStoreUnauthorized    bool  `json:"keystore_unauthorized,omitempty"`
+
+	ReadOnly       bool   `json:"read_only,omitempty"`
+	ReadOnlyReason string `json:"read_only_reason,omitempty"`
+
+	Standby         bool      `json:"standby,omitempty"`
+	StandbySyncedAt time.Time `json:"standby_synced_at,omitzero"`
+}
+
+// BackendStatus describes the health of a backend, like the
+// key store, based on its recent health probes. It is part of
+// a BackendStatus API response.
+type BackendStatus struct {
+	Name  string `json:"name"`
+	State string `json:"state"` // healthy, degraded, unhealthy or unknown
+
+	Probes     int   `json:"probes"`
+	Errors     int   `json:"errors"`
+	LatencyAvg int64 `json:"latency_avg"` // In milliseconds
+	LatencyMax int64 `json:"latency_max"` // In milliseconds
+
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorAt   time.Time `json:"last_error_at,omitzero"`
+	LastSuccessAt time.Time `json:"last_success_at,omitzero"`
+
+	// Unauthenticated and Unauthorized report whether the backend
+	// rejected the server's credentials or denied access during
+	// the most recent probe.
+	Unauthenticated bool `json:"unauthenticated,omitempty"`
+	Unauthorized    bool `json:"unauthorized,omitempty"`
+}
+
+// BackendStatusResponse is the response sent to clients by the BackendStatus API.
+type BackendStatusResponse struct {
+	Backends []BackendStatus `json:"backends"`
+}
+
+// SelfTestStep is the result of a single self-test step. It is
+// part of a SelfTest API response.
+type SelfTestStep struct {
+	Name    string `json:"name"`
+	Latency int64  `json:"latency"` // In microseconds
+	Error   string `json:"error,omitempty"`
+}
+
+// SelfTestResponse is the response sent to clients by the SelfTest API.
+type SelfTestResponse struct {
+	OK      bool           `json:"ok"`
+	Steps   []SelfTestStep `json:"steps"`
+	Latency int64          `json:"latency"` // In microseconds
 }
 
 // DescribeRouteResponse describes a single API route. It is part of
@@ -43,16 +94,186 @@ type ListAPIsResponse []DescribeRouteResponse
 
 // DescribeKeyResponse is the response sent to clients by the DescribeKey API.
 type DescribeKeyResponse struct {
-	Name      string    `json:"name"`
-	Algorithm string    `json:"algorithm,omitempty"`
-	CreatedAt time.Time `json:"created_at,omitempty"`
-	CreatedBy string    `json:"created_by,omitempty"`
+	Name      string       `json:"name"`
+	Algorithm string       `json:"algorithm,omitempty"`
+	CreatedAt time.Time    `json:"created_at,omitempty"`
+	CreatedBy string       `json:"created_by,omitempty"`
+	TimeLock  *KeyTimeLock `json:"time_lock,omitempty"`
+
+	Folder string            `json:"folder,omitempty"`
+	Tags   map[string]string `json:"tags,omitempty"` // Inherited from the folder and its parents
+
+	Owner   string `json:"owner,omitempty"`
+	Contact string `json:"contact,omitempty"`
+
+	State string `json:"state,omitempty"` // Only present if a lifecycle schedule applies to the key
+}
+
+// CheckDecryptResponse is the response sent to clients by the CheckDecrypt API.
+type CheckDecryptResponse struct {
+	Allowed bool     `json:"allowed"`
+	Reasons []string `json:"reasons,omitempty"` // Why a decryption would fail
 }
 
 // ListKeysResponse is the response sent to clients by the ListKeys API.
 type ListKeysResponse struct {
 	Names      []string `json:"names"`
 	ContinueAt string   `json:"continue_at,omitempty"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// StaleKeyResponse describes a key that has not been used within
+// the requested time period. It is part of a ListStaleKeys API response.
+type StaleKeyResponse struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used,omitzero"`
+}
+
+// ListStaleKeysResponse is the response sent to clients by the ListStaleKeys API.
+type ListStaleKeysResponse struct {
+	Keys []StaleKeyResponse `json:"keys"`
+
+	// TrackedSince is the point in time since when usage has been
+	// tracked by any server sharing the key store. Nothing is stale
+	// unless usage has been tracked for the entire requested period.
+	TrackedSince time.Time `json:"tracked_since"`
+}
+
+// KeyInventoryEntry describes a single key. It is part of a
+// KeyInventory API response.
+type KeyInventoryEntry struct {
+	Name        string    `json:"name"`
+	Algorithm   string    `json:"algorithm"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastRotated time.Time `json:"last_rotated"`
+	LastUsed    time.Time `json:"last_used,omitzero"`
+	Owner       string    `json:"owner,omitempty"`   // Assigned owner or, if unowned, the identity that created the key
+	Contact     string    `json:"contact,omitempty"` // Empty if the key is unowned
+}
+
+// KeyInventoryResponse is the response sent to clients by the KeyInventory API.
+type KeyInventoryResponse struct {
+	Keys         []KeyInventoryEntry `json:"keys"`
+	GeneratedAt  time.Time           `json:"generated_at"`
+	TrackedSince time.Time           `json:"tracked_since"`
+}
+
+// MerkleRootResponse is the response sent to clients by the MerkleRoot API.
+//
+// The signature is an Ed25519 signature of the Merkle checkpoint
+// over the tree size, root and publication time.
+type MerkleRootResponse struct {
+	Size        int       `json:"size"`
+	Root        []byte    `json:"root"`
+	PublishedAt time.Time `json:"published_at"`
+	Signature   []byte    `json:"signature"`
+	PublicKey   []byte    `json:"public_key"`
+}
+
+// LoginChallengeResponse is the response sent to clients by the
+// Login Challenge API.
+//
+// The challenge has to be signed by one of the credentials within
+// a WebAuthn ceremony for the relying party ID.
+type LoginChallengeResponse struct {
+	Challenge        []byte    `json:"challenge"`
+	RPID             string    `json:"rp_id"`
+	Credentials      [][]byte  `json:"credentials"`
+	UserVerification bool      `json:"user_verification"`
+	NotAfter         time.Time `json:"not_after"`
+}
+
+// LoginResponse is the response sent to clients by the Login API.
+// The identity is treated as admin until the session expires.
+type LoginResponse struct {
+	Identity string    `json:"identity"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+// CosignResponse is the response sent to clients by the Cosign API
+// of a witness KES server.
+//
+// The cosignature has to be sent in the "Cosignature" header of
+// the approved request.
+type CosignResponse struct {
+	Cosignature string    `json:"cosignature"`
+	NotAfter    time.Time `json:"not_after"`
+	PublicKey   []byte    `json:"public_key"`
+}
+
+// AttestationResponse is the response sent to clients by the
+// Attestation API.
+//
+// The quote is a TPMS_ATTEST structure signed by the server's TPM.
+// Its qualifying data is the SHA-256 hash of the attestation message
+// over the client's nonce and the binary and config digests.
+//
+// If sealing is enabled, the response contains an attestation
+// report of the confidential VM or enclave over the same data.
+type AttestationResponse struct {
+	Binary    []byte         `json:"binary"`
+	Config    []byte         `json:"config,omitempty"`
+	PCRs      map[int][]byte `json:"pcrs"`
+	Quote     []byte         `json:"quote"`
+	Signature []byte         `json:"signature"`
+	PublicKey []byte         `json:"public_key"`
+	Report    []byte         `json:"report,omitempty"`
+}
+
+// MerkleLeaf is the inclusion proof of a key. It is part of
+// a MerkleProof API response.
+type MerkleLeaf struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	Index     int       `json:"index"`
+	Path      [][]byte  `json:"path"`
+}
+
+// MerkleProofResponse is the response sent to clients by the MerkleProof API.
+//
+// If the key is included in the Merkle tree, Leaf contains its
+// inclusion proof. Otherwise, Left and Right contain the inclusion
+// proofs of the adjacent keys, if any, that would surround it.
+type MerkleProofResponse struct {
+	Root     MerkleRootResponse `json:"root"`
+	Included bool               `json:"included"`
+	Leaf     *MerkleLeaf        `json:"leaf,omitempty"`
+	Left     *MerkleLeaf        `json:"left,omitempty"`
+	Right    *MerkleLeaf        `json:"right,omitempty"`
+}
+
+// LegalHoldResponse is the response sent to clients by the LegalHold API.
+type LegalHoldResponse struct {
+	Key      string    `json:"key"`
+	Hold     bool      `json:"hold"`
+	Reason   string    `json:"reason,omitempty"`
+	PlacedAt time.Time `json:"placed_at,omitzero"`
+	PlacedBy string    `json:"placed_by,omitempty"`
+}
+
+// ShredReceipt is a signed receipt of a crypto-shredded key.
+//
+// The signature is an Ed25519 signature of the receipt message.
+// Prev is the hash of the previous receipt, if any, such that
+// all receipts form a hash chain.
+type ShredReceipt struct {
+	Key        string    `json:"key"`
+	KeyID      string    `json:"key_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	ShreddedAt time.Time `json:"shredded_at"`
+	ShreddedBy string    `json:"shredded_by"`
+	Prev       []byte    `json:"prev,omitempty"`
+	Hash       []byte    `json:"hash"`
+	Signature  []byte    `json:"signature"`
+	PublicKey  []byte    `json:"public_key"`
+}
+
+// ShredReceiptsResponse is the response sent to clients by the KeyReceipt API.
+type ShredReceiptsResponse struct {
+	Receipts []ShredReceipt `json:"receipts"`
 }
 
 // EncryptKeyResponse is the response sent to clients by the EncryptKey API.
@@ -73,6 +294,53 @@ type DecryptKeyResponse struct {
 	Plaintext []byte `json:"plaintext"`
 }
 
+// RewrapKeyResponse is the response sent to clients by the RewrapKey API.
+// Like a GenerateKey API ciphertext, it can be decrypted with the DecryptKey API.
+type RewrapKeyResponse struct {
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Envelope is a self-describing ciphertext returned by the
+// SealEnvelope API. It contains the data encryption key (DEK),
+// encrypted with the named KES key, and the payload encrypted
+// with the DEK.
+type Envelope struct {
+	Version      int       `json:"version"`              // Envelope format version
+	Key          string    `json:"key"`                  // Name of the KES key
+	KeyVersion   time.Time `json:"key_version,omitzero"` // Creation time of the KES key
+	Algorithm    string    `json:"algorithm"`            // Payload encryption algorithm, e.g. "AES256"
+	EncryptedKey []byte    `json:"encrypted_key"`        // DEK encrypted with the KES key
+	Ciphertext   []byte    `json:"ciphertext"`           // Payload encrypted with the DEK
+}
+
+// CiphertextInfo describes a ciphertext. It is part of an InspectCiphertext API response.
+// Only envelopes record the name and version of the key. Ciphertexts in the current
+// "raw" format do not refer to any key.
+type CiphertextInfo struct {
+	Format     string    `json:"format,omitempty"`     // "envelope", "raw", "binary" or "json"
+	Key        string    `json:"key,omitempty"`        // Name of the KES key
+	KeyVersion time.Time `json:"key_version,omitzero"` // Creation time of the KES key
+	KeyID      string    `json:"key_id,omitempty"`     // Recorded by legacy "binary" and "json" ciphertexts
+	Algorithm  string    `json:"algorithm,omitempty"`  // Encryption algorithm, e.g. "AES256"
+	Error      string    `json:"error,omitempty"`      // Set if the ciphertext is malformed
+}
+
+// InspectCiphertextResponse is the response sent to clients by the InspectCiphertext API.
+// The i-th entry describes the i-th ciphertext of the request.
+type InspectCiphertextResponse struct {
+	Ciphertexts []CiphertextInfo `json:"ciphertexts"`
+}
+
+// OpenEnvelopeResponse is the response sent to clients by the OpenEnvelope API.
+type OpenEnvelopeResponse struct {
+	Plaintext []byte `json:"plaintext"`
+}
+
+// TokenizeResponse is the response sent to clients by the Tokenize, Detokenize or Reveal API.
+type TokenizeResponse struct {
+	Values []string `json:"values"`
+}
+
 // HMACResponse is the response sent to clients by the HMAC API.
 type HMACResponse struct {
 	Sum     []byte `json:"hmac"`
@@ -99,6 +367,7 @@ type DescribePolicyResponse struct {
 type ListPoliciesResponse struct {
 	Names      []string `json:"names"`
 	ContinueAt string   `json:"continue_at"`
+	NextCursor string   `json:"next_cursor,omitempty"`
 }
 
 // DescribeIdentityResponse is the response sent to clients by the DescribeIdentity API.
@@ -113,6 +382,54 @@ type DescribeIdentityResponse struct {
 type ListIdentitiesResponse struct {
 	Identities []string `json:"identities"`
 	ContinueAt string   `json:"continue_at"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// StaleIdentityResponse describes an identity that has not authenticated
+// within the requested time period. It is part of a ListStaleIdentities
+// API response.
+type StaleIdentityResponse struct {
+	Identity string    `json:"identity"`
+	IsAdmin  bool      `json:"admin,omitempty"`
+	Policy   string    `json:"policy,omitempty"`
+	LastSeen time.Time `json:"last_seen,omitzero"`
+}
+
+// ListStaleIdentitiesResponse is the response sent to clients by the ListStaleIdentities API.
+type ListStaleIdentitiesResponse struct {
+	Identities []StaleIdentityResponse `json:"identities"`
+
+	// TrackedSince is the point in time since when usage has been
+	// tracked by any server sharing the key store. Nothing is stale
+	// unless usage has been tracked for the entire requested period.
+	TrackedSince time.Time `json:"tracked_since"`
+}
+
+// ConnIdentityResponse is the number of requests an identity
+// has sent over a connection.
+type ConnIdentityResponse struct {
+	Identity string `json:"identity"`
+	Requests uint64 `json:"requests"`
+}
+
+// ConnResponse describes an open client connection.
+type ConnResponse struct {
+	Addr        string                 `json:"addr"`
+	Identities  []ConnIdentityResponse `json:"identities,omitempty"`
+	ConnectedAt time.Time              `json:"connected_at"`
+	Age         string                 `json:"age"` // e.g. "1h2m3s"
+	Requests    uint64                 `json:"requests"`
+	LastRequest time.Time              `json:"last_request,omitzero"`
+}
+
+// ListConnsResponse is the response sent to clients by the ListConns API.
+type ListConnsResponse struct {
+	Conns []ConnResponse `json:"connections"`
+}
+
+// CloseConnsResponse is the response sent to clients by the CloseConns API.
+type CloseConnsResponse struct {
+	Closed int `json:"closed"`
 }
 
 // SelfDescribeIdentityResponse is the response sent to clients by the SelfDescribeIdentity API.
@@ -145,7 +462,220 @@ type AuditLogResponse struct {
 	Time       int64 `json:"time"` // In microseconds
 }
 
+// ResolvePseudonymResponse is the response sent to clients by the ResolvePseudonym API.
+type ResolvePseudonymResponse struct {
+	Pseudonym string `json:"pseudonym"`
+	Type      string `json:"type"` // Either "identity" or "key"
+	Name      string `json:"name"`
+}
+
+// UsageFeaturesEvent is sent to the anomaly detection sink at the end of every interval.
+type UsageFeaturesEvent struct {
+	Start      time.Time       `json:"start"`
+	End        time.Time       `json:"end"`
+	Identities []UsageFeatures `json:"identities"`
+	Alerts     []UsageAlert    `json:"alerts,omitempty"`
+}
+
+// UsageFeatures are the aggregated usage features of an identity.
+type UsageFeatures struct {
+	Identity     string  `json:"identity"`
+	Requests     int64   `json:"requests"`
+	RequestRate  float64 `json:"request_rate"` // Requests per second
+	DistinctKeys int     `json:"distinct_keys"`
+	Denied       int64   `json:"denied"`
+	DenyRate     float64 `json:"deny_rate"` // Fraction of denied requests
+}
+
+// UsageAlert describes an identity that exceeded a usage threshold.
+type UsageAlert struct {
+	Identity  string  `json:"identity"`
+	Feature   string  `json:"feature"` // Either "request_rate", "distinct_keys" or "deny_rate"
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+}
+
+// AlertEvent is sent to alert receivers once an alerting rule
+// fires or resolves.
+type AlertEvent struct {
+	Rule      string    `json:"rule"`
+	Status    string    `json:"status"` // Either "firing" or "resolved"
+	Severity  string    `json:"severity"`
+	Signal    string    `json:"signal"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Since     time.Time `json:"since"` // Since when the value has been above the threshold
+	Time      time.Time `json:"time"`
+	Source    string    `json:"source"` // The host name of the server
+}
+
+// KeyTransitionEvent is sent to the key lifecycle sink before and
+// once a key transitions into a new state.
+type KeyTransitionEvent struct {
+	Key      string    `json:"key"`
+	State    string    `json:"state"` // Either "decrypt-only", "disabled" or "purged"
+	At       time.Time `json:"at"`
+	Upcoming bool      `json:"upcoming,omitempty"` // True if the transition has not happened yet
+}
+
 // ErrorLogEvent is sent to clients (as stream of events) when they subscribe to the ErrorLog API.
 type ErrorLogEvent struct {
 	Message string `json:"message"`
 }
+
+// BatchResponse is the response sent to clients by the Batch API
+// once all operations have been applied.
+//
+// Ephemeral reports whether the batch changed policies or identity
+// assignments. Such changes are kept in memory only and get lost
+// once the server restarts or reloads its configuration.
+type BatchResponse struct {
+	Applied   int  `json:"applied"`
+	Ephemeral bool `json:"ephemeral,omitempty"`
+}
+
+// BatchErrorResponse is the response sent to clients by the Batch
+// API when an operation failed. None of the batch operations have
+// been applied.
+type BatchErrorResponse struct {
+	Message  string `json:"message"`
+	Code     string `json:"code"`
+	FailedAt int    `json:"failed_at"`
+	Op       string `json:"op"`
+}
+
+// JobResponse is the response sent to clients by the StartJob
+// and DescribeJob APIs.
+type JobResponse struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	State      string    `json:"state"`
+	CreatedAt  time.Time `json:"created_at"`
+	CreatedBy  string    `json:"created_by"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	Done       int       `json:"done"`
+	Total      int       `json:"total"`
+	Errors     []string  `json:"errors,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ListJobsResponse is the response sent to clients by the ListJobs API.
+type ListJobsResponse struct {
+	Jobs []JobResponse `json:"jobs"`
+}
+
+// LockResponse is the response sent to clients by the AcquireLock,
+// RenewLock and DescribeLock APIs. The token is only sent to the
+// lock holder when acquiring or renewing the lock.
+type LockResponse struct {
+	Name      string    `json:"name"`
+	Holder    string    `json:"holder"`
+	Token     string    `json:"token,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ReserveKeyResponse is the response sent to clients by the ReserveKey API.
+// Clients send the token as fencing token when encrypting with the key.
+type ReserveKeyResponse struct {
+	Key       string    `json:"key"`
+	Holder    string    `json:"holder"`
+	Token     string    `json:"token,omitempty"`
+	Fence     uint64    `json:"fence"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// DBCredentialsResponse is the response sent to clients by the DBCredentials API.
+type DBCredentialsResponse struct {
+	LeaseID   string    `json:"lease_id"`
+	Username  string    `json:"username"`
+	Password  string    `json:"password"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// DescribeSSHCAResponse is the response sent to clients by the DescribeSSHCA API.
+type DescribeSSHCAResponse struct {
+	PublicKey string `json:"public_key"` // authorized_keys format
+}
+
+// SignSSHKeyResponse is the response sent to clients by the SignSSHKey API.
+type SignSSHKeyResponse struct {
+	Certificate string    `json:"certificate"` // authorized_keys format
+	Serial      uint64    `json:"serial"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// DescribePKICAResponse is the response sent to clients by the DescribePKICA API.
+type DescribePKICAResponse struct {
+	Certificate string `json:"certificate"` // PEM, CA certificate first
+}
+
+// IssueCertificateResponse is the response sent to clients by the IssueCertificate API.
+type IssueCertificateResponse struct {
+	Certificate string    `json:"certificate"`           // PEM
+	CAChain     string    `json:"ca_chain"`              // PEM
+	PrivateKey  string    `json:"private_key,omitempty"` // PEM, PKCS #8. Empty if a CSR was sent
+	Serial      string    `json:"serial"`                // hex
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// RenewIdentityResponse is the response sent to clients by the RenewIdentity API.
+type RenewIdentityResponse struct {
+	Identity    string    `json:"identity"`    // Identity of the renewed certificate
+	Certificate string    `json:"certificate"` // PEM
+	CAChain     string    `json:"ca_chain"`    // PEM
+	Serial      string    `json:"serial"`      // hex
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Change feed objects and operations.
+const (
+	ChangeObjectKey      = "key"
+	ChangeObjectPolicy   = "policy"
+	ChangeObjectIdentity = "identity"
+
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// ChangeEvent is a single event sent to clients by the Changes API.
+// Clients resume the feed by sending the Cursor of the last event
+// they have seen. The Seq is only unique per server run.
+type ChangeEvent struct {
+	Seq       uint64    `json:"seq"`
+	Cursor    string    `json:"cursor"`
+	Time      time.Time `json:"time"`
+	Object    string    `json:"object"`
+	Op        string    `json:"op"`
+	Name      string    `json:"name"`
+	Initiator string    `json:"initiator,omitempty"` // Empty for configuration changes
+}
+
+// CacheSyncResponse is the response sent to standby servers by the
+// CacheSync API. It contains the primary's cached keys and policies.
+type CacheSyncResponse struct {
+	Keys     []SyncKey             `json:"keys"`
+	Policies map[string]SyncPolicy `json:"policies"`
+}
+
+// SyncKey is a cached key. It is part of a CacheSync API response.
+type SyncKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key"` // Encoded key version
+}
+
+// SyncPolicy is a policy with its assigned identities. It is part
+// of a CacheSync API response.
+type SyncPolicy struct {
+	Allow      []string       `json:"allow,omitempty"`
+	Deny       []string       `json:"deny,omitempty"`
+	Identities []string       `json:"identities,omitempty"`
+	Geo        *SyncGeoPolicy `json:"geo,omitempty"`
+}
+
+// SyncGeoPolicy is the geo condition of a SyncPolicy.
+type SyncGeoPolicy struct {
+	Paths      []string `json:"paths,omitempty"`
+	Countries  []string `json:"countries,omitempty"`
+	Continents []string `json:"continents,omitempty"`
+}
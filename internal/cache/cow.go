@@ -124,6 +124,38 @@ func (c *Cow[K, V]) Add(key K, value V) bool {
 	return true
 }
 
+// CompareAndSwap replaces the value of an existing
+// entry if and only if f returns true for its current
+// value. It reports whether the entry exists and whether
+// its value has been replaced.
+func (c *Cow[K, V]) CompareAndSwap(key K, value V, f func(V) bool) (found, swapped bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p := c.ptr.Load()
+	if p == nil {
+		return false, false
+	}
+
+	r := *p
+	v, ok := r[key]
+	if !ok {
+		return false, false
+	}
+	if !f(v) {
+		return true, false
+	}
+
+	w := make(map[K]V, len(r))
+	for k, v := range r {
+		w[k] = v
+	}
+	w[key] = value
+
+	c.ptr.Store(&w)
+	return true, true
+}
+
 // Delete removes the given entry and reports
 // whether it was present.
 func (c *Cow[K, V]) Delete(key K) bool {
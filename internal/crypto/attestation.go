@@ -0,0 +1,40 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Attestation is the measurement of a KES server that gets
+// included into a TPM quote. The nonce is chosen by the
+// verifier and ensures that the quote is fresh.
+type Attestation struct {
+	Nonce  []byte // Random nonce chosen by the verifier
+	Binary []byte // SHA-256 hash of the KES server binary
+	Config []byte // SHA-256 hash of the KES server config file
+}
+
+// Message returns the attestation message.
+func (a *Attestation) Message() []byte {
+	var b bytes.Buffer
+	b.WriteString("kes attestation v1\n")
+	b.WriteString(hex.EncodeToString(a.Nonce))
+	b.WriteByte('\n')
+	b.WriteString(hex.EncodeToString(a.Binary))
+	b.WriteByte('\n')
+	b.WriteString(hex.EncodeToString(a.Config))
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+// QualifyingData returns the SHA-256 hash of the attestation
+// message. It is included as qualifying data into the quote.
+func (a *Attestation) QualifyingData() []byte {
+	sum := sha256.Sum256(a.Message())
+	return sum[:]
+}
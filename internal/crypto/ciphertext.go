@@ -161,3 +161,50 @@ func (c *ciphertext) UnmarshalJSON(text []byte) error {
 	c.Bytes = value.Bytes
 	return nil
 }
+
+// ValidCiphertext reports whether b is a well-formed ciphertext,
+// in any format produced by a SecretKey, without decrypting or
+// authenticating it. Hence, it cannot tell whether b has been
+// produced by a particular key.
+func ValidCiphertext(b []byte) bool {
+	const TagSize = 16
+	return len(parseCiphertext(slices.Clone(b))) >= randSize+TagSize
+}
+
+// Ciphertext formats reported by InspectCiphertext.
+const (
+	CiphertextRaw    = "raw"    // Current format. It does not refer to any key.
+	CiphertextBinary = "binary" // Legacy msgp format
+	CiphertextJSON   = "json"   // Legacy JSON format
+)
+
+// CiphertextInfo describes a ciphertext without decrypting it.
+type CiphertextInfo struct {
+	Format    string // One of CiphertextRaw, CiphertextBinary or CiphertextJSON
+	Algorithm string // Empty for raw ciphertexts
+	KeyID     string // Empty for raw ciphertexts and if not recorded
+}
+
+// InspectCiphertext returns information about the ciphertext, like
+// the algorithm and key ID recorded by legacy ciphertext formats. It
+// neither decrypts nor authenticates the ciphertext. It returns
+// kes.ErrDecrypt if b is not a well-formed ciphertext.
+func InspectCiphertext(b []byte) (CiphertextInfo, error) {
+	if len(b) > 0 {
+		var c ciphertext
+		switch b[0] {
+		case 0x95: // msgp first byte
+			if err := c.UnmarshalBinary(b); err == nil {
+				return CiphertextInfo{Format: CiphertextBinary, Algorithm: c.Algorithm.String(), KeyID: c.ID}, nil
+			}
+		case 0x7b: // JSON first byte
+			if err := c.UnmarshalJSON(b); err == nil {
+				return CiphertextInfo{Format: CiphertextJSON, Algorithm: c.Algorithm.String(), KeyID: c.ID}, nil
+			}
+		}
+	}
+	if !ValidCiphertext(b) {
+		return CiphertextInfo{}, kes.ErrDecrypt
+	}
+	return CiphertextInfo{Format: CiphertextRaw}, nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import "testing"
+
+func TestInspectCiphertext(t *testing.T) {
+	t.Parallel()
+
+	for i, test := range inspectCiphertextTests {
+		info, err := InspectCiphertext([]byte(test.Ciphertext))
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: inspected invalid ciphertext successfully", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to inspect ciphertext: %v", i, err)
+		}
+		if info != test.Info {
+			t.Fatalf("Test %d: got '%+v' - want '%+v'", i, info, test.Info)
+		}
+	}
+}
+
+var inspectCiphertextTests = []struct {
+	Ciphertext string
+	Info       CiphertextInfo
+	ShouldFail bool
+}{
+	{ // 0
+		Ciphertext: secretKeyDecryptTests[0].Ciphertext,
+		Info:       CiphertextInfo{Format: CiphertextJSON, Algorithm: "AES256"},
+	},
+	{ // 1
+		Ciphertext: secretKeyDecryptTests[2].Ciphertext,
+		Info:       CiphertextInfo{Format: CiphertextJSON, Algorithm: "ChaCha20", KeyID: "66687aadf862bd776c8fc18b8e9f8e20"},
+	},
+	{ // 2
+		Ciphertext: secretKeyDecryptTests[3].Ciphertext,
+		Info:       CiphertextInfo{Format: CiphertextBinary, Algorithm: "AES256", KeyID: "66687aadf862bd776c8fc18b8e9f8e20"},
+	},
+	{ // 3
+		Ciphertext: string(mustDecodeB64("Vd3YmtnSApj3mYmZh7I5vrq8ZLLLy4J2SdlFMxi+iKXmmxlHs+7YMcRzM0XHLZHEaqVYaEGxiw1+A2PkT9DA8KNmqpkXBHLT")),
+		Info:       CiphertextInfo{Format: CiphertextRaw},
+	},
+	{Ciphertext: "", ShouldFail: true},                       // 4
+	{Ciphertext: `{"aead":"AES-256-GCM"}`, ShouldFail: true}, // 5
+}
@@ -0,0 +1,40 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"time"
+)
+
+// Cosignature is the statement of a witness KES server that
+// it approves a single high-risk operation, like crypto-shredding
+// a key, requested by an identity at another KES server.
+type Cosignature struct {
+	Method   string    // HTTP method of the approved request
+	Path     string    // API path of the approved request
+	Identity string    // Identity that may send the request
+	Nonce    []byte    // Random nonce that makes the statement unique
+	NotAfter time.Time // Point in time when the approval expires
+}
+
+// Message returns the message that gets signed by
+// the witness when approving the operation.
+func (c *Cosignature) Message() []byte {
+	var b bytes.Buffer
+	b.WriteString("kes cosignature v1\n")
+	b.WriteString(c.Method)
+	b.WriteByte('\n')
+	b.WriteString(c.Path)
+	b.WriteByte('\n')
+	b.WriteString(c.Identity)
+	b.WriteByte('\n')
+	b.WriteString(hex.EncodeToString(c.Nonce))
+	b.WriteByte('\n')
+	b.WriteString(c.NotAfter.UTC().Format(time.RFC3339Nano))
+	b.WriteByte('\n')
+	return b.Bytes()
+}
@@ -0,0 +1,300 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"math"
+	"math/big"
+	"slices"
+)
+
+// FPE modes as specified by NIST SP 800-38G Rev. 1.
+const (
+	FF1  = "FF1"
+	FF31 = "FF3-1"
+)
+
+// MinFPEDomain is the min. number of values a numeral
+// string must be able to represent to get encrypted,
+// i.e. radix^len(x) >= MinFPEDomain.
+const MinFPEDomain = 1_000_000
+
+// FPE is a format-preserving cipher. It encrypts a numeral
+// string, i.e. a sequence of numerals in [0, radix), into a
+// numeral string of the same length and radix.
+type FPE struct {
+	mode  string
+	radix *big.Int
+	block cipher.Block
+
+	minLen, maxLen int
+}
+
+// NewFPE returns a new format-preserving cipher for the given
+// mode, either FF1 or FF31, and radix. The key must be 16, 24
+// or 32 bytes long.
+func NewFPE(mode string, key []byte, radix int) (*FPE, error) {
+	if radix < 2 || radix > 1<<16 {
+		return nil, errors.New("crypto: FPE radix must be within [2, 65536]")
+	}
+	minLen := max(2, int(math.Ceil(math.Log(MinFPEDomain)/math.Log(float64(radix)))))
+	maxLen := math.MaxUint32
+
+	switch mode {
+	case FF1:
+	case FF31:
+		// FF3-1 uses the key in reversed byte order.
+		key = slices.Clone(key)
+		slices.Reverse(key)
+		maxLen = 2 * int(math.Floor(96/math.Log2(float64(radix))))
+	default:
+		return nil, errors.New("crypto: FPE mode '" + mode + "' is not supported")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &FPE{
+		mode:   mode,
+		radix:  big.NewInt(int64(radix)),
+		block:  block,
+		minLen: minLen,
+		maxLen: maxLen,
+	}, nil
+}
+
+// MinLen returns the min. length of numeral strings.
+func (f *FPE) MinLen() int { return f.minLen }
+
+// MaxLen returns the max. length of numeral strings.
+func (f *FPE) MaxLen() int { return f.maxLen }
+
+// Encrypt encrypts the numeral string x using the tweak.
+// FF3-1 requires a 7 byte tweak.
+func (f *FPE) Encrypt(x []uint16, tweak []byte) ([]uint16, error) {
+	if err := f.verify(x, tweak); err != nil {
+		return nil, err
+	}
+	if f.mode == FF31 {
+		TL, TR := ff31Tweak(tweak)
+		return f.ff3(x, TL, TR, true), nil
+	}
+	return f.ff1(x, tweak, true), nil
+}
+
+// Decrypt decrypts the numeral string x using the tweak.
+func (f *FPE) Decrypt(x []uint16, tweak []byte) ([]uint16, error) {
+	if err := f.verify(x, tweak); err != nil {
+		return nil, err
+	}
+	if f.mode == FF31 {
+		TL, TR := ff31Tweak(tweak)
+		return f.ff3(x, TL, TR, false), nil
+	}
+	return f.ff1(x, tweak, false), nil
+}
+
+func (f *FPE) verify(x []uint16, tweak []byte) error {
+	if len(x) < f.minLen {
+		return errors.New("crypto: FPE input is too short")
+	}
+	if len(x) > f.maxLen {
+		return errors.New("crypto: FPE input is too long")
+	}
+	for _, d := range x {
+		if int64(d) >= f.radix.Int64() {
+			return errors.New("crypto: FPE input contains an invalid numeral")
+		}
+	}
+	if f.mode == FF31 && len(tweak) != 7 {
+		return errors.New("crypto: FF3-1 tweak must be 7 bytes long")
+	}
+	return nil
+}
+
+// ff1 implements FF1 as specified by NIST SP 800-38G, 6.1.
+func (f *FPE) ff1(x []uint16, tweak []byte, encrypt bool) []uint16 {
+	n, t := len(x), len(tweak)
+	u := n / 2
+	v := n - u
+	A, B := slices.Clone(x[:u]), slices.Clone(x[u:])
+
+	radix := int(f.radix.Int64())
+	b := int(math.Ceil(math.Ceil(float64(v)*math.Log2(float64(radix))) / 8))
+	d := 4*((b+3)/4) + 4
+
+	P := [16]byte{
+		1, 2, 1,
+		byte(radix >> 16), byte(radix >> 8), byte(radix),
+		10, byte(u),
+		byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n),
+		byte(t >> 24), byte(t >> 16), byte(t >> 8), byte(t),
+	}
+	pad := (16 - (t+b+1)%16) % 16
+	Q := make([]byte, t+pad+1+b)
+	copy(Q, tweak)
+
+	// round computes the round function output y for the i-th
+	// round and the numeral string X.
+	round := func(i int, X []uint16) *big.Int {
+		Q[t+pad] = byte(i)
+		num(X, f.radix).FillBytes(Q[t+pad+1:])
+
+		// R = PRF(P || Q), i.e. the CBC-MAC with a zero IV.
+		var R [16]byte
+		f.block.Encrypt(R[:], P[:])
+		for j := 0; j < len(Q); j += 16 {
+			xor(R[:], Q[j:j+16])
+			f.block.Encrypt(R[:], R[:])
+		}
+
+		// S = R || CIPH(R ⊕ [1]^16) || CIPH(R ⊕ [2]^16) ...
+		S := append(make([]byte, 0, d+16), R[:]...)
+		for j := 1; len(S) < d; j++ {
+			block := R
+			block[12] ^= byte(j >> 24)
+			block[13] ^= byte(j >> 16)
+			block[14] ^= byte(j >> 8)
+			block[15] ^= byte(j)
+			f.block.Encrypt(block[:], block[:])
+			S = append(S, block[:]...)
+		}
+		return new(big.Int).SetBytes(S[:d])
+	}
+
+	modU := new(big.Int).Exp(f.radix, big.NewInt(int64(u)), nil)
+	modV := new(big.Int).Exp(f.radix, big.NewInt(int64(v)), nil)
+	if encrypt {
+		for i := range 10 {
+			m, mod := u, modU
+			if i%2 == 1 {
+				m, mod = v, modV
+			}
+			c := new(big.Int).Add(num(A, f.radix), round(i, B))
+			A, B = B, str(c.Mod(c, mod), f.radix, m)
+		}
+	} else {
+		for i := 9; i >= 0; i-- {
+			m, mod := u, modU
+			if i%2 == 1 {
+				m, mod = v, modV
+			}
+			c := new(big.Int).Sub(num(B, f.radix), round(i, A))
+			B, A = A, str(c.Mod(c, mod), f.radix, m)
+		}
+	}
+	return append(A, B...)
+}
+
+// ff31Tweak converts a 56 bit FF3-1 tweak into the left
+// and right halves of a 64 bit FF3 tweak.
+func ff31Tweak(tweak []byte) (TL, TR [4]byte) {
+	TL = [4]byte{tweak[0], tweak[1], tweak[2], tweak[3] & 0xF0}
+	TR = [4]byte{tweak[4], tweak[5], tweak[6], tweak[3] << 4}
+	return TL, TR
+}
+
+// ff3 implements the FF3 rounds as specified by NIST SP
+// 800-38G Rev. 1, 6.2. FF3-1 only differs in the tweak.
+func (f *FPE) ff3(x []uint16, TL, TR [4]byte, encrypt bool) []uint16 {
+	n := len(x)
+	u := (n + 1) / 2
+	v := n - u
+	A, B := slices.Clone(x[:u]), slices.Clone(x[u:])
+
+	// round computes the round function output y for the i-th
+	// round and the numeral string X.
+	round := func(i int, X []uint16) *big.Int {
+		W := TR
+		if i%2 == 1 {
+			W = TL
+		}
+		var P [16]byte
+		copy(P[:4], W[:])
+		P[3] ^= byte(i)
+		numRev(X, f.radix).FillBytes(P[4:])
+
+		slices.Reverse(P[:])
+		f.block.Encrypt(P[:], P[:])
+		slices.Reverse(P[:])
+		return new(big.Int).SetBytes(P[:])
+	}
+
+	modU := new(big.Int).Exp(f.radix, big.NewInt(int64(u)), nil)
+	modV := new(big.Int).Exp(f.radix, big.NewInt(int64(v)), nil)
+	if encrypt {
+		for i := range 8 {
+			m, mod := u, modU
+			if i%2 == 1 {
+				m, mod = v, modV
+			}
+			c := new(big.Int).Add(numRev(A, f.radix), round(i, B))
+			A, B = B, strRev(c.Mod(c, mod), f.radix, m)
+		}
+	} else {
+		for i := 7; i >= 0; i-- {
+			m, mod := u, modU
+			if i%2 == 1 {
+				m, mod = v, modV
+			}
+			c := new(big.Int).Sub(numRev(B, f.radix), round(i, A))
+			B, A = A, strRev(c.Mod(c, mod), f.radix, m)
+		}
+	}
+	return append(A, B...)
+}
+
+// num returns the number represented by the numeral
+// string X, most significant numeral first.
+func num(X []uint16, radix *big.Int) *big.Int {
+	x := new(big.Int)
+	for _, d := range X {
+		x.Mul(x, radix)
+		x.Add(x, big.NewInt(int64(d)))
+	}
+	return x
+}
+
+// numRev returns the number represented by the numeral
+// string X, least significant numeral first.
+func numRev(X []uint16, radix *big.Int) *big.Int {
+	x := new(big.Int)
+	for _, d := range slices.Backward(X) {
+		x.Mul(x, radix)
+		x.Add(x, big.NewInt(int64(d)))
+	}
+	return x
+}
+
+// str returns the numeral string of length m representing
+// x, most significant numeral first.
+func str(x, radix *big.Int, m int) []uint16 {
+	X := strRev(x, radix, m)
+	slices.Reverse(X)
+	return X
+}
+
+// strRev returns the numeral string of length m representing
+// x, least significant numeral first.
+func strRev(x, radix *big.Int, m int) []uint16 {
+	X := make([]uint16, m)
+	x = new(big.Int).Set(x)
+	r := new(big.Int)
+	for i := range X {
+		x.QuoRem(x, radix, r)
+		X[i] = uint16(r.Int64())
+	}
+	return X
+}
+
+// xor XORs src into dst.
+func xor(dst, src []byte) {
+	for i := range src {
+		dst[i] ^= src[i]
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFF1(t *testing.T) {
+	t.Parallel()
+
+	for i, test := range ff1Tests {
+		f, err := NewFPE(FF1, mustDecodeHex(test.Key), test.Radix)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create FF1 cipher: %v", i, err)
+		}
+		ciphertext, err := f.Encrypt(numerals(test.Plaintext, test.Radix), mustDecodeHex(test.Tweak))
+		if err != nil {
+			t.Fatalf("Test %d: failed to encrypt: %v", i, err)
+		}
+		if s := numeralString(ciphertext, test.Radix); s != test.Ciphertext {
+			t.Fatalf("Test %d: ciphertext mismatch: got '%s' - want '%s'", i, s, test.Ciphertext)
+		}
+		plaintext, err := f.Decrypt(ciphertext, mustDecodeHex(test.Tweak))
+		if err != nil {
+			t.Fatalf("Test %d: failed to decrypt: %v", i, err)
+		}
+		if s := numeralString(plaintext, test.Radix); s != test.Plaintext {
+			t.Fatalf("Test %d: plaintext mismatch: got '%s' - want '%s'", i, s, test.Plaintext)
+		}
+	}
+}
+
+func TestFF3(t *testing.T) {
+	t.Parallel()
+
+	for i, test := range ff3Tests {
+		f, err := NewFPE(FF31, mustDecodeHex(test.Key), test.Radix)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create FF3 cipher: %v", i, err)
+		}
+		tweak := mustDecodeHex(test.Tweak)
+		TL, TR := [4]byte(tweak[:4]), [4]byte(tweak[4:])
+
+		ciphertext := f.ff3(numerals(test.Plaintext, test.Radix), TL, TR, true)
+		if s := numeralString(ciphertext, test.Radix); s != test.Ciphertext {
+			t.Fatalf("Test %d: ciphertext mismatch: got '%s' - want '%s'", i, s, test.Ciphertext)
+		}
+		plaintext := f.ff3(ciphertext, TL, TR, false)
+		if s := numeralString(plaintext, test.Radix); s != test.Plaintext {
+			t.Fatalf("Test %d: plaintext mismatch: got '%s' - want '%s'", i, s, test.Plaintext)
+		}
+	}
+}
+
+func TestFF31(t *testing.T) {
+	t.Parallel()
+
+	key := mustDecodeHex("EF4359D8D580AA4F7F036D6F04FC6A94")
+	f, err := NewFPE(FF31, key, 10)
+	if err != nil {
+		t.Fatalf("Failed to create FF3-1 cipher: %v", err)
+	}
+	if _, err = f.Encrypt(numerals("4000001234567899", 10), make([]byte, 8)); err == nil {
+		t.Fatal("Encrypting with a 64 bit tweak should have failed")
+	}
+	if _, err = f.Encrypt(numerals("12345", 10), make([]byte, 7)); err == nil {
+		t.Fatal("Encrypting an input with less than 1M values should have failed")
+	}
+
+	tweak := mustDecodeHex("D8E7920AFA330A")
+	plaintext := numerals("4000001234567899", 10)
+	ciphertext, err := f.Encrypt(plaintext, tweak)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if slices.Equal(ciphertext, plaintext) || len(ciphertext) != len(plaintext) {
+		t.Fatalf("Invalid ciphertext: got '%v'", ciphertext)
+	}
+	decrypted, err := f.Decrypt(ciphertext, tweak)
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	if !slices.Equal(decrypted, plaintext) {
+		t.Fatalf("Plaintext mismatch: got '%v' - want '%v'", decrypted, plaintext)
+	}
+}
+
+// NIST SP 800-38G sample values.
+var ff1Tests = []struct {
+	Key        string
+	Radix      int
+	Tweak      string
+	Plaintext  string
+	Ciphertext string
+}{
+	{ // 0
+		Key:        "2B7E151628AED2A6ABF7158809CF4F3C",
+		Radix:      10,
+		Plaintext:  "0123456789",
+		Ciphertext: "2433477484",
+	},
+	{ // 1
+		Key:        "2B7E151628AED2A6ABF7158809CF4F3C",
+		Radix:      10,
+		Tweak:      "39383736353433323130",
+		Plaintext:  "0123456789",
+		Ciphertext: "6124200773",
+	},
+	{ // 2
+		Key:        "2B7E151628AED2A6ABF7158809CF4F3C",
+		Radix:      36,
+		Tweak:      "3737373770717273373737",
+		Plaintext:  "0123456789abcdefghi",
+		Ciphertext: "a9tv40mll9kdu509eum",
+	},
+	{ // 3
+		Key:        "2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94",
+		Radix:      10,
+		Plaintext:  "0123456789",
+		Ciphertext: "6657667009",
+	},
+	{ // 4
+		Key:        "2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94",
+		Radix:      10,
+		Tweak:      "39383736353433323130",
+		Plaintext:  "0123456789",
+		Ciphertext: "1001623463",
+	},
+	{ // 5
+		Key:        "2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94",
+		Radix:      36,
+		Tweak:      "3737373770717273373737",
+		Plaintext:  "0123456789abcdefghi",
+		Ciphertext: "xs8a0azh2avyalyzuwd",
+	},
+}
+
+// NIST FF3 sample values. FF3-1 uses the same rounds.
+var ff3Tests = []struct {
+	Key        string
+	Radix      int
+	Tweak      string
+	Plaintext  string
+	Ciphertext string
+}{
+	{ // 0
+		Key:        "EF4359D8D580AA4F7F036D6F04FC6A94",
+		Radix:      10,
+		Tweak:      "D8E7920AFA330A73",
+		Plaintext:  "890121234567890000",
+		Ciphertext: "750918814058654607",
+	},
+	{ // 1
+		Key:        "EF4359D8D580AA4F7F036D6F04FC6A94",
+		Radix:      10,
+		Tweak:      "9A768A92F60E12D8",
+		Plaintext:  "890121234567890000",
+		Ciphertext: "018989839189395384",
+	},
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func numerals(s string, radix int) []uint16 {
+	x := make([]uint16, 0, len(s))
+	for _, r := range s {
+		d, err := strconv.ParseUint(string(r), radix, 16)
+		if err != nil {
+			panic(err)
+		}
+		x = append(x, uint16(d))
+	}
+	return x
+}
+
+func numeralString(x []uint16, radix int) string {
+	var sb strings.Builder
+	for _, d := range x {
+		sb.WriteString(strconv.FormatUint(uint64(d), radix))
+	}
+	return sb.String()
+}
@@ -0,0 +1,208 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// KeyWrap defines a key wrapping algorithm used by HSMs and
+// other key management systems to export keys encrypted with
+// a key encryption key (KEK).
+type KeyWrap uint
+
+// Supported key wrapping algorithms.
+const (
+	// AESKeyWrap represents the AES key wrap algorithm
+	// specified in RFC 3394 (CKM_AES_KEY_WRAP).
+	AESKeyWrap KeyWrap = iota + 1
+
+	// AESKeyWrapPad represents the AES key wrap with padding
+	// algorithm specified in RFC 5649 (CKM_AES_KEY_WRAP_KWP).
+	AESKeyWrapPad
+)
+
+// errUnwrap is returned when a wrapped key cannot be unwrapped,
+// either because it is malformed or because it has been wrapped
+// with a different KEK.
+var errUnwrap = errors.New("crypto: failed to unwrap key: invalid KEK or wrapped key")
+
+// ParseKeyWrap parses s as KeyWrap string representation
+// and returns an error if s is not a valid representation.
+func ParseKeyWrap(s string) (KeyWrap, error) {
+	switch s {
+	case "AES-KW":
+		return AESKeyWrap, nil
+	case "AES-KWP":
+		return AESKeyWrapPad, nil
+	default:
+		return 0, fmt.Errorf("crypto: key wrapping algorithm '%s' is not supported", s)
+	}
+}
+
+// String returns the string representation of the KeyWrap.
+func (w KeyWrap) String() string {
+	switch w {
+	case AESKeyWrap:
+		return "AES-KW"
+	case AESKeyWrapPad:
+		return "AES-KWP"
+	default:
+		return "!INVALID:" + strconv.Itoa(int(w))
+	}
+}
+
+// Wrap wraps the key with the AES key encryption key kek.
+func (w KeyWrap) Wrap(kek, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	switch w {
+	case AESKeyWrap:
+		if len(key) < 16 || len(key)%8 != 0 {
+			return nil, fmt.Errorf("crypto: invalid key length '%d' for '%s'", len(key), w)
+		}
+		return wrap(block, kwIV, key), nil
+	case AESKeyWrapPad:
+		if len(key) == 0 {
+			return nil, fmt.Errorf("crypto: invalid key length '%d' for '%s'", len(key), w)
+		}
+		var iv [8]byte
+		copy(iv[:], kwpIV[:])
+		binary.BigEndian.PutUint32(iv[4:], uint32(len(key)))
+
+		padded := make([]byte, (len(key)+7)/8*8)
+		copy(padded, key)
+		if len(padded) == 8 {
+			b := append(iv[:], padded...)
+			block.Encrypt(b, b)
+			return b, nil
+		}
+		return wrap(block, iv, padded), nil
+	default:
+		return nil, fmt.Errorf("crypto: key wrapping algorithm '%s' is not supported", w)
+	}
+}
+
+// Unwrap unwraps the wrapped key with the AES key encryption
+// key kek. It returns an error if the wrapped key is malformed
+// or has been wrapped with a different KEK.
+func (w KeyWrap) Unwrap(kek, wrapped []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < 16 || len(wrapped)%8 != 0 {
+		return nil, errUnwrap
+	}
+
+	switch w {
+	case AESKeyWrap:
+		if len(wrapped) < 24 {
+			return nil, errUnwrap
+		}
+		iv, key := unwrap(block, wrapped)
+		if subtle.ConstantTimeCompare(iv[:], kwIV[:]) != 1 {
+			return nil, errUnwrap
+		}
+		return key, nil
+	case AESKeyWrapPad:
+		var (
+			iv  [8]byte
+			key []byte
+		)
+		if len(wrapped) == 16 {
+			b := make([]byte, 16)
+			block.Decrypt(b, wrapped)
+			copy(iv[:], b[:8])
+			key = b[8:]
+		} else {
+			iv, key = unwrap(block, wrapped)
+		}
+		if subtle.ConstantTimeCompare(iv[:4], kwpIV[:4]) != 1 {
+			return nil, errUnwrap
+		}
+
+		n := int(binary.BigEndian.Uint32(iv[4:]))
+		if n <= len(key)-8 || n > len(key) {
+			return nil, errUnwrap
+		}
+		var padding byte
+		for _, b := range key[n:] {
+			padding |= b
+		}
+		if padding != 0 {
+			return nil, errUnwrap
+		}
+		return key[:n], nil
+	default:
+		return nil, fmt.Errorf("crypto: key wrapping algorithm '%s' is not supported", w)
+	}
+}
+
+var (
+	kwIV  = [8]byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6} // RFC 3394, 2.2.3.1
+	kwpIV = [8]byte{0xA6, 0x59, 0x59, 0xA6}                         // RFC 5649, 3 - followed by the key length
+)
+
+// wrap implements the wrapping process W of RFC 3394, 2.2.1
+// with the initial value iv. The plaintext must consist of
+// at least two 64-bit blocks.
+func wrap(block cipher.Block, iv [8]byte, plaintext []byte) []byte {
+	n := len(plaintext) / 8
+	out := make([]byte, 8+len(plaintext))
+	copy(out[8:], plaintext)
+
+	var b [16]byte
+	a := iv
+	for j := range 6 {
+		for i := 1; i <= n; i++ {
+			copy(b[:8], a[:])
+			copy(b[8:], out[i*8:(i+1)*8])
+			block.Encrypt(b[:], b[:])
+
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(a[:], binary.BigEndian.Uint64(b[:8])^t)
+			copy(out[i*8:], b[8:])
+		}
+	}
+	copy(out[:8], a[:])
+	return out
+}
+
+// unwrap implements the unwrapping process W⁻¹ of RFC 3394,
+// 2.2.2. It returns the initial value, which the caller must
+// verify, and the plaintext.
+func unwrap(block cipher.Block, ciphertext []byte) ([8]byte, []byte) {
+	n := len(ciphertext)/8 - 1
+	out := make([]byte, len(ciphertext)-8)
+	copy(out, ciphertext[8:])
+
+	var (
+		a [8]byte
+		b [16]byte
+	)
+	copy(a[:], ciphertext[:8])
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(a[:])^t)
+			copy(b[8:], out[(i-1)*8:i*8])
+			block.Decrypt(b[:], b[:])
+
+			copy(a[:], b[:8])
+			copy(out[(i-1)*8:], b[8:])
+		}
+	}
+	return a, out
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKeyWrap(t *testing.T) {
+	t.Parallel()
+
+	for i, test := range keyWrapTests {
+		kek, key, wrapped := mustDecodeHex(test.KEK), mustDecodeHex(test.Key), mustDecodeHex(test.Wrapped)
+
+		w, err := test.Algorithm.Wrap(kek, key)
+		if err != nil {
+			t.Fatalf("Test %d: failed to wrap key: %v", i, err)
+		}
+		if !bytes.Equal(w, wrapped) {
+			t.Fatalf("Test %d: wrapped key mismatch: got '%x' - want '%x'", i, w, wrapped)
+		}
+
+		k, err := test.Algorithm.Unwrap(kek, wrapped)
+		if err != nil {
+			t.Fatalf("Test %d: failed to unwrap key: %v", i, err)
+		}
+		if !bytes.Equal(k, key) {
+			t.Fatalf("Test %d: unwrapped key mismatch: got '%x' - want '%x'", i, k, key)
+		}
+
+		wrapped[len(wrapped)-1] ^= 1
+		if _, err = test.Algorithm.Unwrap(kek, wrapped); err == nil {
+			t.Fatalf("Test %d: unwrapped modified key successfully", i)
+		}
+		wrapped[len(wrapped)-1] ^= 1
+
+		kek[0] ^= 1
+		if _, err = test.Algorithm.Unwrap(kek, wrapped); err == nil {
+			t.Fatalf("Test %d: unwrapped key with wrong KEK successfully", i)
+		}
+	}
+}
+
+func TestParseKeyWrap(t *testing.T) {
+	t.Parallel()
+
+	for _, w := range []KeyWrap{AESKeyWrap, AESKeyWrapPad} {
+		v, err := ParseKeyWrap(w.String())
+		if err != nil {
+			t.Fatalf("Failed to parse '%s': %v", w, err)
+		}
+		if v != w {
+			t.Fatalf("Key wrap mismatch: got '%s' - want '%s'", v, w)
+		}
+	}
+	if _, err := ParseKeyWrap("AES-GCM"); err == nil {
+		t.Fatal("Parsed unsupported key wrapping algorithm successfully")
+	}
+}
+
+var keyWrapTests = []struct {
+	Algorithm KeyWrap
+	KEK       string
+	Key       string
+	Wrapped   string
+}{
+	{ // 0 - RFC 3394, 4.1
+		Algorithm: AESKeyWrap,
+		KEK:       "000102030405060708090a0b0c0d0e0f",
+		Key:       "00112233445566778899aabbccddeeff",
+		Wrapped:   "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5",
+	},
+	{ // 1 - RFC 3394, 4.3
+		Algorithm: AESKeyWrap,
+		KEK:       "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		Key:       "00112233445566778899aabbccddeeff",
+		Wrapped:   "64e8c3f9ce0f5ba263e9777905818a2a93c8191e7d6e8ae7",
+	},
+	{ // 2 - RFC 3394, 4.6
+		Algorithm: AESKeyWrap,
+		KEK:       "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		Key:       "00112233445566778899aabbccddeeff000102030405060708090a0b0c0d0e0f",
+		Wrapped:   "28c9f404c4b810f4cbccb35cfb87f8263f5786e2d80ed326cbc7f0e71a99f43bfb988b9b7a02dd21",
+	},
+	{ // 3 - RFC 5649, 6
+		Algorithm: AESKeyWrapPad,
+		KEK:       "5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8",
+		Key:       "c37b7e6492584340bed12207808941155068f738",
+		Wrapped:   "138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a",
+	},
+	{ // 4 - RFC 5649, 6
+		Algorithm: AESKeyWrapPad,
+		KEK:       "5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8",
+		Key:       "466f7250617369",
+		Wrapped:   "afbeb0f07dfbf5419200f2ccb50bb24f",
+	},
+}
@@ -0,0 +1,125 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/bits"
+	"strconv"
+	"time"
+)
+
+// Domain separation prefixes of Merkle tree hashes as
+// specified by RFC 9162.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleKeyLeaf returns the Merkle tree leaf of a key. Its hash
+// commits to the key name and the key's creation metadata.
+func MerkleKeyLeaf(name string, createdAt time.Time, createdBy string) []byte {
+	leaf := make([]byte, 0, len(name)+len(createdBy)+40)
+	leaf = append(leaf, name...)
+	leaf = append(leaf, 0)
+	leaf = createdAt.UTC().AppendFormat(leaf, time.RFC3339Nano)
+	leaf = append(leaf, 0)
+	return append(leaf, createdBy...)
+}
+
+// MerkleCheckpoint returns the message that gets signed when
+// publishing the root of a Merkle tree with size leaves.
+func MerkleCheckpoint(size int, root []byte, publishedAt time.Time) []byte {
+	var b bytes.Buffer
+	b.WriteString("kes merkle root v1\n")
+	b.WriteString(strconv.Itoa(size))
+	b.WriteByte('\n')
+	b.WriteString(hex.EncodeToString(root))
+	b.WriteByte('\n')
+	b.WriteString(publishedAt.UTC().Format(time.RFC3339Nano))
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+// MerkleLeafHash returns the hash of a Merkle tree leaf.
+func MerkleLeafHash(leaf []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+// MerkleRoot returns the root of the Merkle tree over the
+// given leaf hashes. The root of an empty tree is the hash
+// of the empty string.
+func MerkleRoot(hashes [][]byte) []byte {
+	switch len(hashes) {
+	case 0:
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	case 1:
+		return hashes[0]
+	}
+	k := merkleSplit(len(hashes))
+	return merkleNode(MerkleRoot(hashes[:k]), MerkleRoot(hashes[k:]))
+}
+
+// MerklePath returns the inclusion proof of the i-th leaf of
+// the Merkle tree over the given leaf hashes.
+func MerklePath(i int, hashes [][]byte) [][]byte {
+	if len(hashes) <= 1 {
+		return nil
+	}
+	k := merkleSplit(len(hashes))
+	if i < k {
+		return append(MerklePath(i, hashes[:k]), MerkleRoot(hashes[k:]))
+	}
+	return append(MerklePath(i-k, hashes[k:]), MerkleRoot(hashes[:k]))
+}
+
+// VerifyMerklePath reports whether path proves that the leaf
+// hash is the i-th leaf of the Merkle tree with the given size
+// and root.
+func VerifyMerklePath(i, size int, hash []byte, path [][]byte, root []byte) bool {
+	if i < 0 || i >= size {
+		return false
+	}
+
+	fn, sn := uint64(i), uint64(size-1)
+	r := hash
+	for _, p := range path {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			r = merkleNode(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = merkleNode(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && bytes.Equal(r, root)
+}
+
+// merkleNode returns the hash of an inner Merkle tree node.
+func merkleNode(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// merkleSplit returns the largest power of two smaller than n.
+func merkleSplit(n int) int {
+	return 1 << (bits.Len(uint(n-1)) - 1)
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMerkleRoot(t *testing.T) {
+	t.Parallel()
+
+	hashes := make([][]byte, 0, len(merkleLeaves))
+	for _, leaf := range merkleLeaves {
+		hashes = append(hashes, MerkleLeafHash(mustDecodeHex(leaf)))
+	}
+	if root := hex.EncodeToString(MerkleRoot(nil)); root != merkleEmptyRoot {
+		t.Fatalf("Empty root mismatch: got '%s' - want '%s'", root, merkleEmptyRoot)
+	}
+	for i, want := range merkleRoots {
+		if root := hex.EncodeToString(MerkleRoot(hashes[:i+1])); root != want {
+			t.Fatalf("Test %d: root mismatch: got '%s' - want '%s'", i, root, want)
+		}
+	}
+}
+
+func TestMerklePath(t *testing.T) {
+	t.Parallel()
+
+	for size := 1; size <= 33; size++ {
+		hashes := make([][]byte, 0, size)
+		for i := range size {
+			hashes = append(hashes, MerkleLeafHash([]byte(strconv.Itoa(i))))
+		}
+		root := MerkleRoot(hashes)
+
+		for i := range size {
+			path := MerklePath(i, hashes)
+			if !VerifyMerklePath(i, size, hashes[i], path, root) {
+				t.Fatalf("Size %d: failed to verify path of leaf %d", size, i)
+			}
+			if size > 1 && VerifyMerklePath((i+1)%size, size, hashes[i], path, root) {
+				t.Fatalf("Size %d: verified path of leaf %d at wrong index", size, i)
+			}
+			if VerifyMerklePath(i, size, MerkleLeafHash([]byte("x")), path, root) {
+				t.Fatalf("Size %d: verified path of wrong leaf %d", size, i)
+			}
+			if len(path) > 0 && VerifyMerklePath(i, size, hashes[i], path[:len(path)-1], root) {
+				t.Fatalf("Size %d: verified truncated path of leaf %d", size, i)
+			}
+		}
+	}
+}
+
+func TestMerkleCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	root := MerkleRoot(nil)
+	now := time.Now()
+	a := MerkleCheckpoint(1, root, now)
+	b := MerkleCheckpoint(2, root, now)
+	if bytes.Equal(a, b) {
+		t.Fatal("Checkpoints of trees with different sizes are equal")
+	}
+}
+
+// Test vectors from RFC 6962 reference implementation.
+var merkleLeaves = []string{
+	"",
+	"00",
+	"10",
+	"2021",
+	"3031",
+	"40414243",
+	"5051525354555657",
+	"606162636465666768696a6b6c6d6e6f",
+}
+
+const merkleEmptyRoot = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+var merkleRoots = []string{
+	"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+	"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+}
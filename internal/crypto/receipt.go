@@ -0,0 +1,63 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// ShredReceipt contains the evidence that a key has been
+// crypto-shredded. Receipts form a hash chain since each
+// receipt commits to its predecessor.
+type ShredReceipt struct {
+	Key        string    // Name of the shredded key
+	KeyID      string    // Fingerprint of the shredded key version
+	CreatedAt  time.Time // Creation time of the shredded key
+	CreatedBy  string    // Identity that created the shredded key
+	ShreddedAt time.Time // Point in time when the key got shredded
+	ShreddedBy string    // Identity that shredded the key
+	Prev       []byte    // Hash of the previous receipt, if any
+}
+
+// Message returns the message that gets signed when
+// issuing the receipt.
+func (r *ShredReceipt) Message() []byte {
+	var b bytes.Buffer
+	b.WriteString("kes shred receipt v1\n")
+	b.WriteString(r.Key)
+	b.WriteByte('\n')
+	b.WriteString(r.KeyID)
+	b.WriteByte('\n')
+	b.WriteString(r.CreatedAt.UTC().Format(time.RFC3339Nano))
+	b.WriteByte('\n')
+	b.WriteString(r.CreatedBy)
+	b.WriteByte('\n')
+	b.WriteString(r.ShreddedAt.UTC().Format(time.RFC3339Nano))
+	b.WriteByte('\n')
+	b.WriteString(r.ShreddedBy)
+	b.WriteByte('\n')
+	b.WriteString(hex.EncodeToString(r.Prev))
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+// Hash returns the hash of the receipt. The next
+// receipt of the chain refers to it.
+func (r *ShredReceipt) Hash() []byte {
+	h := sha256.Sum256(r.Message())
+	return h[:]
+}
+
+// KeyID returns the fingerprint of an encoded key version.
+// It identifies a key version without revealing it.
+func KeyID(b []byte) string {
+	h := sha256.New()
+	h.Write([]byte("kes key id v1\n"))
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil))
+}
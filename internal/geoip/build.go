@@ -0,0 +1,173 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package geoip
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"net/netip"
+	"slices"
+)
+
+// Build returns a MaxMind DB that maps the given networks to
+// their location. More specific networks take precedence over
+// less specific ones.
+//
+// Build is meant for networks not covered by public databases,
+// like private networks, and for testing.
+func Build(networks map[netip.Prefix]Record) ([]byte, error) {
+	prefixes := slices.SortedFunc(maps.Keys(networks), func(a, b netip.Prefix) int {
+		return cmp.Compare(prefixBits(a), prefixBits(b))
+	})
+
+	// A record is either empty (0), a node (> 0) or data (< 0).
+	// Data records refer to the index of the network - 1.
+	nodes := [][2]int{{}}
+	for i, prefix := range prefixes {
+		if !prefix.IsValid() {
+			return nil, errors.New("geoip: invalid network")
+		}
+		addr, bits := prefixAddr(prefix), prefixBits(prefix)
+		if bits == 0 {
+			return nil, errors.New("geoip: network must not contain all IP addresses")
+		}
+
+		node := 0
+		for j := 0; j < bits-1; j++ {
+			bit := (addr[j/8] >> (7 - j%8)) & 1
+			if next := nodes[node][bit]; next > 0 {
+				node = next
+				continue
+			}
+			// Split empty or data records such that the new
+			// node inherits the data of the less specific network.
+			inherit := nodes[node][bit]
+			nodes = append(nodes, [2]int{inherit, inherit})
+			nodes[node][bit] = len(nodes) - 1
+			node = len(nodes) - 1
+		}
+		bit := (addr[(bits-1)/8] >> (7 - (bits-1)%8)) & 1
+		nodes[node][bit] = -(i + 1)
+	}
+
+	var data []byte
+	offsets := make([]int, len(prefixes))
+	for i, prefix := range prefixes {
+		offsets[i] = len(data)
+
+		var pairs []any
+		if r := networks[prefix]; r.Country != "" {
+			pairs = append(pairs, "country", mapValue("iso_code", r.Country))
+		}
+		if r := networks[prefix]; r.Continent != "" {
+			pairs = append(pairs, "continent", mapValue("code", r.Continent))
+		}
+		data = append(data, mapValue(pairs...)...)
+	}
+
+	nodeCount := len(nodes)
+	tree := make([]byte, 0, nodeCount*8+dataSectionSeparator)
+	for _, node := range nodes {
+		for _, r := range node {
+			var v int
+			switch {
+			case r == 0:
+				v = nodeCount
+			case r > 0:
+				v = r
+			default:
+				v = nodeCount + dataSectionSeparator + offsets[-r-1]
+			}
+			tree = binary.BigEndian.AppendUint32(tree, uint32(v))
+		}
+	}
+	tree = append(tree, make([]byte, dataSectionSeparator)...)
+
+	db := append(tree, data...)
+	db = append(db, metadataMarker...)
+	db = append(db, mapValue(
+		"binary_format_major_version", uintValue(typeUint16, 2),
+		"binary_format_minor_version", uintValue(typeUint16, 0),
+		"database_type", "KES-Custom",
+		"ip_version", uintValue(typeUint16, 6),
+		"node_count", uintValue(typeUint32, uint64(nodeCount)),
+		"record_size", uintValue(typeUint16, 32),
+	)...)
+	return db, nil
+}
+
+// prefixAddr returns the 16 byte address of the prefix. IPv4
+// addresses are mapped into the IPv6 tree as ::a.b.c.d.
+func prefixAddr(prefix netip.Prefix) [16]byte {
+	var addr [16]byte
+	if a := prefix.Addr(); a.Is4() {
+		v4 := a.As4()
+		copy(addr[12:], v4[:])
+	} else {
+		addr = a.As16()
+	}
+	return addr
+}
+
+// prefixBits returns the prefix length within the IPv6 tree.
+func prefixBits(prefix netip.Prefix) int {
+	if prefix.Addr().Is4() {
+		return 96 + prefix.Bits()
+	}
+	return prefix.Bits()
+}
+
+// encoded is an already encoded value.
+type encoded []byte
+
+// mapValue encodes the key-value pairs as map. Values are
+// either strings or already encoded values.
+func mapValue(pairs ...any) encoded {
+	b := appendControl(nil, typeMap, len(pairs)/2)
+	for _, v := range pairs {
+		switch v := v.(type) {
+		case string:
+			b = appendControl(b, typeString, len(v))
+			b = append(b, v...)
+		case encoded:
+			b = append(b, v...)
+		}
+	}
+	return b
+}
+
+// uintValue encodes n as unsigned integer of the given type.
+func uintValue(typ int, n uint64) encoded {
+	v := binary.BigEndian.AppendUint64(nil, n)
+	for len(v) > 0 && v[0] == 0 {
+		v = v[1:]
+	}
+	return append(appendControl(nil, typ, len(v)), v...)
+}
+
+func appendControl(b []byte, typ, size int) []byte {
+	var ctrl byte
+	if typ < 8 {
+		ctrl = byte(typ) << 5
+	}
+	var ext []byte
+	switch {
+	case size < 29:
+		ctrl |= byte(size)
+	case size < 285:
+		ctrl |= 29
+		ext = []byte{byte(size - 29)}
+	default:
+		ctrl |= 30
+		ext = binary.BigEndian.AppendUint16(nil, uint16(size-285))
+	}
+	b = append(b, ctrl)
+	if typ >= 8 {
+		b = append(b, byte(typ-7))
+	}
+	return append(b, ext...)
+}
@@ -0,0 +1,202 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package geoip
+
+import (
+	"encoding/binary"
+	"math"
+	"math/big"
+)
+
+// MaxMind DB data types.
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEnd       = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// maxDecodeDepth limits the nesting of maps, arrays and
+// pointers such that malformed databases cannot cause
+// an infinite recursion.
+const maxDecodeDepth = 32
+
+// decoder decodes values of a MaxMind DB data section.
+type decoder []byte
+
+// decode decodes the value at offset. It returns the value
+// and the offset of the next value.
+//
+// Maps are decoded as map[string]any, arrays as []any, strings
+// as string, unsigned integers as uint64 or *big.Int, signed
+// integers as int32, floating point numbers as float64 and
+// booleans as bool.
+func (d decoder) decode(offset uint, depth int) (any, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, errInvalidDB
+	}
+	b, err := d.bytes(offset, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	ctrl := b[0]
+	offset++
+
+	typ := uint(ctrl >> 5)
+	if typ == typePointer {
+		pointer, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(pointer, depth+1)
+		return v, next, err
+	}
+	if typ == typeExtended {
+		if b, err = d.bytes(offset, 1); err != nil {
+			return nil, 0, err
+		}
+		typ = 7 + uint(b[0])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1F)
+	if typ != typeBool && size >= 29 {
+		n := size - 28
+		if b, err = d.bytes(offset, n); err != nil {
+			return nil, 0, err
+		}
+		switch size {
+		case 29:
+			size = 29 + uint(b[0])
+		case 30:
+			size = 285 + uint(binary.BigEndian.Uint16(b))
+		default:
+			size = 65821 + (uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]))
+		}
+		offset += n
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, min(size, 64))
+		for range size {
+			var k, v any
+			if k, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errInvalidDB
+			}
+			if v, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, min(size, 64))
+		for range size {
+			var v any
+			if v, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeContainer, typeEnd:
+		return nil, offset, nil
+	}
+
+	if b, err = d.bytes(offset, size); err != nil {
+		return nil, 0, err
+	}
+	offset += size
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return b, offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errInvalidDB
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errInvalidDB
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, errInvalidDB
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, offset, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, errInvalidDB
+		}
+		return new(big.Int).SetBytes(b), offset, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, errInvalidDB
+		}
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int32(n), offset, nil
+	default:
+		return nil, 0, errInvalidDB
+	}
+}
+
+// pointer decodes the pointer with the given control byte
+// at offset. It returns the offset the pointer points to
+// and the offset of the next value.
+func (d decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint(ctrl>>3)&0x3 + 1
+	b, err := d.bytes(offset, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	v := uint(ctrl & 0x7)
+	switch n {
+	case 1:
+		return v<<8 | uint(b[0]), offset + n, nil
+	case 2:
+		return (v<<16 | uint(b[0])<<8 | uint(b[1])) + 2048, offset + n, nil
+	case 3:
+		return (v<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336, offset + n, nil
+	default:
+		return uint(binary.BigEndian.Uint32(b)), offset + n, nil
+	}
+}
+
+// bytes returns the n bytes at offset.
+func (d decoder) bytes(offset, n uint) ([]byte, error) {
+	if offset > uint(len(d)) || n > uint(len(d))-offset {
+		return nil, errInvalidDB
+	}
+	return d[offset : offset+n], nil
+}
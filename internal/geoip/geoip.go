@@ -0,0 +1,160 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package geoip implements IP geolocation lookups using
+// MaxMind DB files, like GeoLite2-Country or GeoIP2-City.
+//
+// The MaxMind DB format is specified at:
+// https://maxmind.github.io/MaxMind-DB/
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/netip"
+)
+
+// metadataMarker separates the search tree and data section
+// from the database metadata.
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparator is the number of zero bytes between
+// the search tree and the data section.
+const dataSectionSeparator = 16
+
+var errInvalidDB = errors.New("geoip: invalid database")
+
+// Record is the location of an IP address.
+type Record struct {
+	Country   string // ISO 3166-1 alpha-2 country code, e.g. "DE"
+	Continent string // Continent code, e.g. "EU"
+}
+
+// DB is a MaxMind DB. It is safe for concurrent use.
+type DB struct {
+	tree []byte
+	data []byte
+
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint
+}
+
+// Parse parses b as MaxMind DB. The returned DB refers to b.
+// Hence, b must not be modified afterwards.
+func Parse(b []byte) (*DB, error) {
+	i := bytes.LastIndex(b, metadataMarker)
+	if i < 0 {
+		return nil, errors.New("geoip: invalid database: no metadata found")
+	}
+	v, _, err := decoder(b[i+len(metadataMarker):]).decode(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	metadata, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("geoip: invalid database: invalid metadata")
+	}
+
+	db := &DB{
+		nodeCount:  toUint(metadata["node_count"]),
+		recordSize: toUint(metadata["record_size"]),
+		ipVersion:  toUint(metadata["ip_version"]),
+	}
+	if db.recordSize != 24 && db.recordSize != 28 && db.recordSize != 32 {
+		return nil, errors.New("geoip: invalid database: unsupported record size")
+	}
+	if db.ipVersion != 4 && db.ipVersion != 6 {
+		return nil, errors.New("geoip: invalid database: unsupported IP version")
+	}
+
+	treeSize := db.nodeCount * db.recordSize / 4
+	if treeSize+dataSectionSeparator > uint(i) {
+		return nil, errors.New("geoip: invalid database: search tree exceeds file")
+	}
+	db.tree = b[:treeSize]
+	db.data = b[treeSize+dataSectionSeparator : i]
+
+	// IPv4 addresses are stored in the IPv6 tree as ::a.b.c.d.
+	// Hence, IPv4 lookups start at the node of the 96-bit prefix.
+	if db.ipVersion == 6 {
+		for j := 0; j < 96 && db.ipv4Start < db.nodeCount; j++ {
+			db.ipv4Start = db.record(db.ipv4Start, 0)
+		}
+	}
+	return db, nil
+}
+
+// Lookup returns the location of the IP address. It reports
+// whether the database contains the IP address.
+func (db *DB) Lookup(ip netip.Addr) (Record, bool, error) {
+	ip = ip.Unmap()
+
+	var node uint
+	switch {
+	case ip.Is4() && db.ipVersion == 6:
+		node = db.ipv4Start
+	case ip.Is6() && db.ipVersion == 4:
+		return Record{}, false, nil
+	case !ip.IsValid():
+		return Record{}, false, nil
+	}
+
+	addr := ip.AsSlice()
+	for i := 0; i < len(addr)*8 && node < db.nodeCount; i++ {
+		bit := (addr[i/8] >> (7 - i%8)) & 1
+		node = db.record(node, bit)
+	}
+	switch {
+	case node == db.nodeCount:
+		return Record{}, false, nil
+	case node < db.nodeCount:
+		return Record{}, false, errInvalidDB
+	}
+
+	offset := node - db.nodeCount - dataSectionSeparator
+	v, _, err := decoder(db.data).decode(offset, 0)
+	if err != nil {
+		return Record{}, false, err
+	}
+	m, _ := v.(map[string]any)
+
+	var record Record
+	country, ok := m["country"].(map[string]any)
+	if !ok {
+		country, _ = m["registered_country"].(map[string]any)
+	}
+	record.Country, _ = country["iso_code"].(string)
+	if continent, ok := m["continent"].(map[string]any); ok {
+		record.Continent, _ = continent["code"].(string)
+	}
+	return record, true, nil
+}
+
+// record returns the left (bit = 0) or right (bit = 1)
+// record of the given node.
+func (db *DB) record(node uint, bit byte) uint {
+	switch db.recordSize {
+	case 24:
+		off := node*6 + uint(bit)*3
+		b := db.tree[off : off+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := db.tree[node*7 : node*7+7]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		off := node*8 + uint(bit)*4
+		return uint(binary.BigEndian.Uint32(db.tree[off : off+4]))
+	}
+}
+
+func toUint(v any) uint {
+	n, _ := v.(uint64)
+	return uint(n)
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package geoip
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	b, err := Build(map[netip.Prefix]Record{
+		netip.MustParsePrefix("10.0.0.0/8"):    {Country: "DE", Continent: "EU"},
+		netip.MustParsePrefix("10.1.0.0/16"):   {Country: "FR", Continent: "EU"},
+		netip.MustParsePrefix("2001:db8::/32"): {Country: "US", Continent: "NA"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build database: %v", err)
+	}
+	db, err := Parse(b)
+	if err != nil {
+		t.Fatalf("Failed to parse database: %v", err)
+	}
+
+	for i, test := range lookupTests {
+		record, ok, err := db.Lookup(netip.MustParseAddr(test.IP))
+		if err != nil {
+			t.Fatalf("Test %d: failed to lookup '%s': %v", i, test.IP, err)
+		}
+		if ok != test.Found {
+			t.Fatalf("Test %d: found mismatch: got '%v' - want '%v'", i, ok, test.Found)
+		}
+		if record != test.Record {
+			t.Fatalf("Test %d: record mismatch: got '%+v' - want '%+v'", i, record, test.Record)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for i, test := range parseTests {
+		if _, err := Parse(test); err == nil {
+			t.Fatalf("Test %d: parsed invalid database", i)
+		}
+	}
+}
+
+var lookupTests = []struct {
+	IP     string
+	Record Record
+	Found  bool
+}{
+	{IP: "10.0.0.1", Record: Record{Country: "DE", Continent: "EU"}, Found: true},        // 0
+	{IP: "10.1.2.3", Record: Record{Country: "FR", Continent: "EU"}, Found: true},        // 1
+	{IP: "10.2.0.1", Record: Record{Country: "DE", Continent: "EU"}, Found: true},        // 2
+	{IP: "::ffff:10.0.0.1", Record: Record{Country: "DE", Continent: "EU"}, Found: true}, // 3
+	{IP: "2001:db8::1", Record: Record{Country: "US", Continent: "NA"}, Found: true},     // 4
+	{IP: "192.168.0.1", Found: false},                                                    // 5
+	{IP: "2001:db9::1", Found: false},                                                    // 6
+}
+
+var parseTests = [][]byte{
+	nil,                      // 0
+	[]byte("not a database"), // 1
+	append(append([]byte{}, metadataMarker...), 0xe0), // 2: empty metadata map
+}
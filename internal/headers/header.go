@@ -21,6 +21,41 @@ const (
 	TransferEncoding = "Transfer-Encoding" // RFC 2616
 )
 
+// HTTP headers used for request deadlines and load shedding.
+const (
+	RequestTimeout  = "Request-Timeout"  // Non-standard
+	RequestPriority = "Request-Priority" // Non-standard
+	RetryAfter      = "Retry-After"      // RFC 7231
+)
+
+// HTTP headers used for API versioning.
+const (
+	Deprecation = "Deprecation" // RFC 9745
+	Sunset      = "Sunset"      // RFC 8594
+	Link        = "Link"        // RFC 8288
+)
+
+// HTTP headers used for idempotent requests.
+const (
+	IdempotencyKey     = "Idempotency-Key"     // IETF draft-ietf-httpapi-idempotency-key-header
+	IdempotentReplayed = "Idempotent-Replayed" // Non-standard
+)
+
+// HTTP headers used for cosigned requests.
+const (
+	Cosignature = "Cosignature" // Non-standard
+)
+
+// HTTP headers used for key usage fencing.
+const (
+	KESFencingToken = "Kes-Fencing-Token" // Non-standard
+)
+
+// HTTP headers used for HMAC request authentication.
+const (
+	KESSignature = "Kes-Signature" // Non-standard
+)
+
 // Commonly used HTTP headers for forwarding originating
 // IP addresses of clients connecting through an reverse
 // proxy or load balancer.
@@ -37,6 +72,12 @@ const (
 	ContentTypeJSONLines = "application/x-ndjson"
 	ContentTypeText      = "text/plain"
 	ContentTypeHTML      = "text/html"
+	ContentTypeCSV       = "text/csv"
+
+	ContentTypeProblemJSON = "application/problem+json" // RFC 7807
+
+	ContentTypePKIXCRL      = "application/pkix-crl"      // RFC 5280
+	ContentTypeOCSPResponse = "application/ocsp-response" // RFC 6960
 )
 
 // Accepts reports whether h contains an "Accept" header
@@ -62,3 +103,18 @@ func Accepts(h http.Header, s string) bool {
 		return false
 	})
 }
+
+// AcceptsExplicitly reports whether h contains an "Accept"
+// header that lists s explicitly. Unlike Accepts, it ignores
+// wildcards, like "*/*", and media type parameters.
+func AcceptsExplicitly(h http.Header, s string) bool {
+	for _, v := range h[Accept] {
+		for _, t := range strings.Split(v, ",") {
+			t, _, _ = strings.Cut(t, ";")
+			if strings.TrimSpace(t) == s {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -38,3 +38,25 @@ var acceptsTests = []struct {
 	{http.Header{Accept: []string{"application/*"}}, ContentTypeBinary, true}, // 11
 	{http.Header{Accept: []string{"application/*"}}, ContentTypeJSON, true},   // 12
 }
+
+func TestAcceptsExplicitly(t *testing.T) {
+	for i, test := range acceptsExplicitlyTests {
+		if accept := AcceptsExplicitly(test.Headers, test.ContentType); accept != test.Accept {
+			t.Errorf("Test %d: got '%v' - want '%v' for content type '%s'", i, accept, test.Accept, test.ContentType)
+		}
+	}
+}
+
+var acceptsExplicitlyTests = []struct {
+	Headers     http.Header
+	ContentType string
+	Accept      bool
+}{
+	{http.Header{}, ContentTypeProblemJSON, false},                                                                    // 0
+	{http.Header{Accept: []string{"*/*"}}, ContentTypeProblemJSON, false},                                             // 1
+	{http.Header{Accept: []string{"application/*"}}, ContentTypeProblemJSON, false},                                   // 2
+	{http.Header{Accept: []string{ContentTypeProblemJSON}}, ContentTypeProblemJSON, true},                             // 3
+	{http.Header{Accept: []string{ContentTypeJSON, ContentTypeProblemJSON}}, ContentTypeProblemJSON, true},            // 4
+	{http.Header{Accept: []string{"application/problem+json;q=0.9, application/json"}}, ContentTypeProblemJSON, true}, // 5
+	{http.Header{Accept: []string{"application/json, text/plain"}}, ContentTypeProblemJSON, false},                    // 6
+}
@@ -0,0 +1,31 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/minio/kes/internal/headers"
+)
+
+// LegacyErrors is an http.RoundTripper that asks KES servers
+// for legacy {"message": "..."} error responses instead of
+// RFC 7807 problem details. The kes SDK client only parses
+// the legacy format.
+//
+// Requests that already contain an "Accept" header are sent
+// unchanged.
+type LegacyErrors struct {
+	http.RoundTripper
+}
+
+// RoundTrip sends the request using the underlying RoundTripper.
+func (t LegacyErrors) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get(headers.Accept) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(headers.Accept, headers.ContentTypeJSON)
+	}
+	return t.RoundTripper.RoundTrip(req)
+}
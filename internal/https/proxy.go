@@ -135,7 +135,14 @@ func (p *TLSProxy) Verify(req *http.Request) error {
 	if len(peerCertificates) > 1 {
 		return kes.NewError(http.StatusBadRequest, "too many client certificates are present")
 	}
-	req.TLS.PeerCertificates = peerCertificates
+
+	// The TLS connection state is shared by all requests sent over
+	// the same connection. Hence, modify a copy. Otherwise, the next
+	// request of the proxy would carry the certificate forwarded in
+	// this request and would be treated as sent by the KES client.
+	state := *req.TLS
+	state.PeerCertificates = peerCertificates
+	req.TLS = &state
 
 	identity := identify(req)
 	if identity.IsUnknown() {
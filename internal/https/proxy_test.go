@@ -5,8 +5,12 @@
 package https
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"net/http"
 	"net/url"
+	"os"
 	"testing"
 
 	"github.com/minio/kms-go/kes"
@@ -106,6 +110,49 @@ func TestTLSProxyGetClientCertificate(t *testing.T) {
 	}
 }
 
+func TestTLSProxyVerifySharedState(t *testing.T) {
+	b, err := os.ReadFile("testdata/certificates/single.pem")
+	if err != nil {
+		t.Fatalf("Failed to read proxy certificate: %v", err)
+	}
+	block, _ := pem.Decode(b)
+	proxyCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse proxy certificate: %v", err)
+	}
+
+	// All requests sent over the same connection share
+	// the same TLS connection state.
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{proxyCert}}
+	newRequest := func() *http.Request {
+		req, err := http.NewRequest(http.MethodGet, "https://127.0.0.1:7373/v1/status", nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.TLS = state
+		req.Header.Set("X-Tls-Client-Cert", url.QueryEscape(clientCert))
+		return req
+	}
+
+	proxy := &TLSProxy{CertHeader: "X-Tls-Client-Cert"}
+	req := newRequest()
+	proxyIdentity := identify(req)
+	proxy.Add(proxyIdentity)
+
+	for i := range 2 {
+		req := newRequest()
+		if err := proxy.Verify(req); err != nil {
+			t.Fatalf("Request %d: failed to verify request: %v", i, err)
+		}
+		if identity := identify(req); identity == proxyIdentity || identity.IsUnknown() {
+			t.Fatalf("Request %d: forwarded client certificate has not been applied", i)
+		}
+		if state.PeerCertificates[0] != proxyCert {
+			t.Fatalf("Request %d: TLS connection state has been modified", i)
+		}
+	}
+}
+
 const clientCert = `-----BEGIN CERTIFICATE-----
 MIIBETCBxKADAgECAhEAwNfpyTO85V8w7ecjWU8CdDAFBgMrZXAwDzENMAsGA1UE
 AxMEcm9vdDAeFw0xOTEyMTYyMjQ2NDdaFw0yMDAxMTUyMjQ2NDdaMA8xDTALBgNV
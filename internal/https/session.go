@@ -0,0 +1,136 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package https
+
+import (
+	"container/list"
+	"crypto/rand"
+	"crypto/tls"
+	"sync"
+	"time"
+)
+
+// SessionCache is a server-side TLS session cache with a fixed
+// capacity. Once full, it evicts the least recently used session.
+//
+// Its WrapSession and UnwrapSession methods can be used as the
+// tls.Config functions of the same name. Then, session tickets
+// only contain a random session ID instead of the encrypted
+// session state and resumption no longer depends on session
+// ticket keys.
+type SessionCache struct {
+	size int
+	ttl  time.Duration
+
+	lock     sync.Mutex
+	lru      *list.List // Most recently used first
+	sessions map[string]*list.Element
+}
+
+type cachedSession struct {
+	ID        string
+	State     []byte
+	CreatedAt time.Time
+}
+
+// NewSessionCache returns a new SessionCache that holds at most
+// size sessions. Sessions older than ttl cannot be resumed.
+func NewSessionCache(size int, ttl time.Duration) *SessionCache {
+	return &SessionCache{
+		size:     size,
+		ttl:      ttl,
+		lru:      list.New(),
+		sessions: make(map[string]*list.Element, size),
+	}
+}
+
+// WrapSession adds the session to the cache and returns
+// its ID.
+func (c *SessionCache) WrapSession(_ tls.ConnectionState, ss *tls.SessionState) ([]byte, error) {
+	state, err := ss.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return c.add(state)
+}
+
+// UnwrapSession returns the session with the given ID.
+// It returns no session and no error if no such session
+// exists such that the client performs a full handshake.
+func (c *SessionCache) UnwrapSession(id []byte, _ tls.ConnectionState) (*tls.SessionState, error) {
+	state, ok := c.get(id)
+	if !ok {
+		return nil, nil
+	}
+	ss, err := tls.ParseSessionState(state)
+	if err != nil {
+		return nil, nil
+	}
+	return ss, nil
+}
+
+// Len returns the number of cached sessions.
+func (c *SessionCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.lru.Len()
+}
+
+// Flush removes all sessions from the cache.
+func (c *SessionCache) Flush() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.lru.Init()
+	clear(c.sessions)
+}
+
+func (c *SessionCache) evict(e *list.Element) {
+	delete(c.sessions, e.Value.(*cachedSession).ID)
+	c.lru.Remove(e)
+}
+
+// add adds the encoded session state to the cache and
+// returns its ID.
+func (c *SessionCache) add(state []byte) ([]byte, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for c.lru.Len() > 0 && c.lru.Len() >= c.size {
+		c.evict(c.lru.Back())
+	}
+	c.sessions[string(id[:])] = c.lru.PushFront(&cachedSession{
+		ID:        string(id[:]),
+		State:     state,
+		CreatedAt: time.Now(),
+	})
+	return id[:], nil
+}
+
+// get returns the encoded session state with the given ID
+// and reports whether such a session exists and has not
+// expired.
+func (c *SessionCache) get(id []byte) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, ok := c.sessions[string(id)]
+	if !ok {
+		return nil, false
+	}
+	session := e.Value.(*cachedSession)
+	if time.Since(session.CreatedAt) > c.ttl {
+		c.evict(e)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return session.State, true
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package https
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionCache(t *testing.T) {
+	for i, test := range sessionCacheTests {
+		cache := NewSessionCache(test.Size, test.TTL)
+
+		ids := make([][]byte, 0, test.Sessions)
+		for j := 0; j < test.Sessions; j++ {
+			id, err := cache.add([]byte{byte(j)})
+			if err != nil {
+				t.Fatalf("Test %d: failed to add session: %v", i, err)
+			}
+			ids = append(ids, id)
+		}
+		if n := cache.Len(); n != min(test.Sessions, test.Size) {
+			t.Fatalf("Test %d: cache contains %d sessions - want %d", i, n, min(test.Sessions, test.Size))
+		}
+
+		_, found := cache.get(ids[len(ids)-1])
+		if found != test.Found {
+			t.Fatalf("Test %d: got found '%v' - want '%v'", i, found, test.Found)
+		}
+		if test.Sessions > test.Size {
+			if _, found = cache.get(ids[0]); found {
+				t.Fatalf("Test %d: least recently used session has not been evicted", i)
+			}
+		}
+
+		cache.Flush()
+		if _, found = cache.get(ids[len(ids)-1]); found {
+			t.Fatalf("Test %d: session found after flushing the cache", i)
+		}
+	}
+}
+
+var sessionCacheTests = []struct {
+	Size     int
+	TTL      time.Duration
+	Sessions int
+	Found    bool
+}{
+	{Size: 4, TTL: time.Hour, Sessions: 1, Found: true}, // 0
+	{Size: 4, TTL: time.Hour, Sessions: 8, Found: true}, // 1
+	{Size: 4, TTL: -1, Sessions: 1, Found: false},       // 2
+}
@@ -5,21 +5,34 @@
 package aws
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"maps"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/aws/smithy-go"
 	smithyendpoints "github.com/aws/smithy-go/endpoints"
 	"github.com/minio/kes"
-	xhttp "github.com/minio/kes/internal/http"
 	"github.com/minio/kes/internal/keystore"
 	kesdk "github.com/minio/kms-go/kes"
 )
@@ -39,8 +52,54 @@ type Config struct {
 	// Manager. In general, the address has the
 	// following form:
 	//  secretsmanager.<region>.amazonaws.com
+	//
+	// It may also be a custom endpoint, like a VPC
+	// endpoint or a LocalStack instance, optionally
+	// with a "http://" or "https://" scheme. If empty,
+	// the regional AWS endpoint is used.
 	Addr string
 
+	// PlainHTTP controls whether the SecretsManager is
+	// accessed via plain HTTP instead of HTTPS. It should
+	// only be used for local testing, e.g. with LocalStack.
+	PlainHTTP bool
+
+	// InsecureSkipVerify controls whether the TLS certificate
+	// of the SecretsManager endpoint is verified. It should
+	// only be used for testing, e.g. with self-signed certificates.
+	InsecureSkipVerify bool
+
+	// ProxyURL is the URL of an HTTP(S) proxy used for all AWS
+	// requests, e.g. a TLS-intercepting proxy in an air-gapped
+	// environment. If empty, the proxy is read from the
+	// HTTPS_PROXY and NO_PROXY environment variables.
+	ProxyURL string
+
+	// CAPath is an optional path to a PEM-encoded CA bundle used
+	// to verify the TLS certificates of the AWS endpoints, or of a
+	// TLS-intercepting proxy. If empty, the system root CAs, or the
+	// bundle specified via AWS_CA_BUNDLE, are used.
+	CAPath string
+
+	// DialTimeout is the max. time to wait for a TCP connection
+	// to an AWS endpoint, or the proxy. If zero, defaults to 30s.
+	DialTimeout time.Duration
+
+	// ResponseTimeout is the max. time to wait for the response
+	// headers once a request has been sent. If zero, there is no
+	// timeout besides the request's context.
+	ResponseTimeout time.Duration
+
+	// UseFIPSEndpoint controls whether the FIPS 140 endpoints
+	// of SecretsManager and STS are used, e.g. in GovCloud
+	// regions. It requires an empty Addr.
+	UseFIPSEndpoint bool
+
+	// UseDualStackEndpoint controls whether the dual-stack,
+	// IPv4 and IPv6, endpoints of SecretsManager and STS are
+	// used, e.g. in IPv6-only VPCs. It requires an empty Addr.
+	UseDualStackEndpoint bool
+
 	// Region is the AWS region. Even though the Addr
 	// endpoint contains that information already, this
 	// field is mandatory.
@@ -51,16 +110,162 @@ type Config struct {
 	// values stored at AWS Secrets Manager.
 	KMSKeyID string
 
+	// Prefix is an optional prefix of all secret names. Keys are
+	// stored as "<prefix>/<name>" and the prefix is removed from
+	// listed names. Hence, multiple KES deployments can share one
+	// AWS account and IAM policies can restrict access to secrets
+	// with the prefix. It must not start or end with a '/'.
+	Prefix string
+
+	// Overwrite controls whether Set replaces the value of
+	// existing secrets. If false, Set behaves like Create and
+	// the store only supports create-only semantics.
+	Overwrite bool
+
+	// ReplicaRegions are the regions secrets are replicated to
+	// when created. If empty, secrets are not replicated.
+	ReplicaRegions []ReplicaRegion
+
+	// Binary controls whether values are stored as SecretBinary
+	// instead of SecretString. Values are read from either, such
+	// that existing secrets remain accessible after switching.
+	Binary bool
+
+	// Tags are attached to all secrets created by the Store,
+	// e.g. to comply with tag policies enforced via SCPs.
+	Tags map[string]string
+
+	// Retry controls how requests that fail with a retryable
+	// error, e.g. due to throttling, are retried.
+	Retry RetryConfig
+
 	// Login contains the AWS credentials (access/secret key).
 	Login Credentials
+
+	// RoleARN is the ARN of an IAM role that is assumed via
+	// STS AssumeRole using the Login credentials, or the
+	// default credential chain, as base identity. If empty,
+	// no role is assumed.
+	RoleARN string
+
+	// ExternalID is an optional external ID passed to STS
+	// when assuming the role.
+	ExternalID string
+
+	// SessionName is an optional name of the role session.
+	// If empty, the AWS SDK generates one.
+	SessionName string
+
+	// WebIdentityTokenFile is the path to an OIDC token file,
+	// like the service account token projected into EKS pods
+	// when using IAM Roles for Service Accounts (IRSA).
+	//
+	// If set, the RoleARN is assumed via STS AssumeRoleWithWebIdentity
+	// instead of AssumeRole. The token file is read again whenever the
+	// role credentials are refreshed such that rotated tokens are used.
+	WebIdentityTokenFile string
+}
+
+// ReplicaRegion is an AWS region a secret is replicated to.
+type ReplicaRegion struct {
+	// Region is the AWS region of the replica. It must not
+	// be the region of the primary secret.
+	Region string
+
+	// KMSKeyID is the AWS-KMS key ID used to encrypt the
+	// replica. It must be a key in the replica's region. If
+	// empty, the default AWS-KMS key of the region is used.
+	KMSKeyID string
+}
+
+// RetryConfig is a structure containing options for retrying
+// failed AWS SecretsManager and STS requests.
+type RetryConfig struct {
+	// Mode is the retry mode. Either "standard" or "adaptive".
+	// The adaptive mode additionally limits the request rate
+	// on the client side when requests get throttled. If empty,
+	// the standard mode is used.
+	Mode string
+
+	// MaxAttempts is the max. number of attempts, including
+	// the first one, made per request. If zero, the AWS SDK
+	// default of 3 attempts is used.
+	MaxAttempts int
+
+	// MaxBackoff is the max. time to wait between two attempts.
+	// If zero, the AWS SDK default of 20s is used.
+	MaxBackoff time.Duration
 }
 
+// Supported retry modes.
+const (
+	RetryModeStandard = "standard"
+	RetryModeAdaptive = "adaptive"
+)
+
+// credentialsExpiryWindow is the time before the role credentials
+// expire at which they get refreshed.
+const credentialsExpiryWindow = 5 * time.Minute
+
 // Connect establishes and returns a Conn to a AWS SecretManager
 // using the given config.
 func Connect(ctx context.Context, cfg *Config) (*Store, error) {
+	if err := validateTags(cfg.Tags); err != nil {
+		return nil, err
+	}
+	if err := validateReplicaRegions(cfg); err != nil {
+		return nil, err
+	}
+	if err := validateRetry(cfg.Retry); err != nil {
+		return nil, err
+	}
+	if err := validatePrefix(cfg.Prefix); err != nil {
+		return nil, err
+	}
+	if cfg.RoleARN == "" && (cfg.ExternalID != "" || cfg.SessionName != "") {
+		return nil, errors.New("aws: external ID and session name require a role ARN")
+	}
+	if cfg.WebIdentityTokenFile != "" {
+		if cfg.RoleARN == "" {
+			return nil, errors.New("aws: web identity token file requires a role ARN")
+		}
+		if cfg.ExternalID != "" {
+			return nil, errors.New("aws: external ID is not supported with web identity authentication")
+		}
+		if _, err := stscreds.IdentityTokenFile(cfg.WebIdentityTokenFile).GetIdentityToken(); err != nil {
+			return nil, err
+		}
+	}
+
+	endpoint, err := endpointURL(cfg)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	// Configure AWS SDK v2 with custom options
 	opts := []func(*config.LoadOptions) error{
 		config.WithRegion(cfg.Region),
+		config.WithRetryer(newRetryer(cfg.Retry)),
+	}
+	opts = append(opts, config.WithHTTPClient(httpClient))
+	if cfg.CAPath != "" {
+		// The CA bundle replaces the system root CAs, and any
+		// bundle specified via the AWS_CA_BUNDLE env. variable.
+		caBundle, err := os.ReadFile(cfg.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("aws: failed to read CA certificates: %v", err)
+		}
+		opts = append(opts, config.WithCustomCABundle(bytes.NewReader(caBundle)))
+	}
+	if cfg.UseFIPSEndpoint {
+		opts = append(opts, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+	if cfg.UseDualStackEndpoint {
+		opts = append(opts, config.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
 	}
 
 	// Configure credentials
@@ -87,6 +292,14 @@ func Connect(ctx context.Context, cfg *Config) (*Store, error) {
 		return nil, err
 	}
 
+	// Assume the IAM role, if specified. The temp. role credentials
+	// are cached and refreshed by the SDK before they expire.
+	if provider := roleCredentials(cfg, sts.NewFromConfig(awsCfg)); provider != nil {
+		awsCfg.Credentials = aws.NewCredentialsCache(provider, func(o *aws.CredentialsCacheOptions) {
+			o.ExpiryWindow = credentialsExpiryWindow
+		})
+	}
+
 	// Create Secrets Manager client with additional options if needed
 	var clientOpts []func(*secretsmanager.Options)
 
@@ -94,13 +307,26 @@ func Connect(ctx context.Context, cfg *Config) (*Store, error) {
 	if cfg.Addr != "" {
 		clientOpts = append(clientOpts, func(o *secretsmanager.Options) {
 			o.EndpointResolverV2 = &customEndpointResolver{
-				endpoint: "https://" + cfg.Addr,
+				endpoint: endpoint,
 			}
 		})
 	}
 
+	if cfg.InsecureSkipVerify {
+		insecureClient := httpClient.WithTransportOptions(func(tr *http.Transport) {
+			if tr.TLSClientConfig == nil {
+				tr.TLSClientConfig = &tls.Config{}
+			}
+			tr.TLSClientConfig.InsecureSkipVerify = true
+		})
+		clientOpts = append(clientOpts, func(o *secretsmanager.Options) {
+			o.HTTPClient = insecureClient
+		})
+	}
+
 	c := &Store{
 		config: *cfg,
+		tags:   secretTags(cfg.Tags),
 		client: secretsmanager.NewFromConfig(awsCfg, clientOpts...),
 	}
 
@@ -110,7 +336,210 @@ func Connect(ctx context.Context, cfg *Config) (*Store, error) {
 	return c, nil
 }
 
-// customEndpointResolver implements the EndpointResolverV2 interface for Secrets Manager
+// newHTTPClient returns the HTTP client for all AWS requests as
+// specified by the proxy and timeout options of the config.
+func newHTTPClient(cfg *Config) (*awshttp.BuildableClient, error) {
+	if cfg.DialTimeout < 0 || cfg.ResponseTimeout < 0 {
+		return nil, errors.New("aws: invalid HTTP timeouts: must not be negative")
+	}
+
+	client := awshttp.NewBuildableClient()
+	if cfg.ProxyURL != "" {
+		proxy, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("aws: invalid proxy URL '%s': %v", cfg.ProxyURL, err)
+		}
+		if proxy.Scheme != "http" && proxy.Scheme != "https" || proxy.Host == "" {
+			return nil, fmt.Errorf("aws: invalid proxy URL '%s': must be an 'http' or 'https' URL", cfg.ProxyURL)
+		}
+		client = client.WithTransportOptions(func(tr *http.Transport) { tr.Proxy = http.ProxyURL(proxy) })
+	}
+	if cfg.DialTimeout > 0 {
+		client = client.WithDialerOptions(func(d *net.Dialer) { d.Timeout = cfg.DialTimeout })
+	}
+	if cfg.ResponseTimeout > 0 {
+		client = client.WithTransportOptions(func(tr *http.Transport) { tr.ResponseHeaderTimeout = cfg.ResponseTimeout })
+	}
+	return client, nil
+}
+
+// roleCredentials returns a credentials provider that assumes the
+// configured IAM role via the given STS client, either with the web
+// identity token or with the client's credentials. It returns nil
+// if no role is configured.
+func roleCredentials(cfg *Config, client *sts.Client) aws.CredentialsProvider {
+	switch {
+	case cfg.RoleARN != "" && cfg.WebIdentityTokenFile != "":
+		return stscreds.NewWebIdentityRoleProvider(client, cfg.RoleARN, stscreds.IdentityTokenFile(cfg.WebIdentityTokenFile), func(o *stscreds.WebIdentityRoleOptions) {
+			o.RoleSessionName = cfg.SessionName
+		})
+	case cfg.RoleARN != "":
+		return stscreds.NewAssumeRoleProvider(client, cfg.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			if cfg.ExternalID != "" {
+				o.ExternalID = aws.String(cfg.ExternalID)
+			}
+			o.RoleSessionName = cfg.SessionName
+		})
+	default:
+		return nil
+	}
+}
+
+// validateTags returns an error if tags contains a tag
+// that is not a valid SecretsManager resource tag.
+func validateTags(tags map[string]string) error {
+	const (
+		MaxTags        = 50
+		MaxKeyLength   = 128
+		MaxValueLength = 256
+	)
+	if len(tags) > MaxTags {
+		return fmt.Errorf("aws: invalid tags: more than %d tags specified", MaxTags)
+	}
+	for key, value := range tags {
+		if key == "" || utf8.RuneCountInString(key) > MaxKeyLength {
+			return fmt.Errorf("aws: invalid tag key '%s': must be between 1 and %d characters long", key, MaxKeyLength)
+		}
+		if strings.HasPrefix(strings.ToLower(key), "aws:") {
+			return fmt.Errorf("aws: invalid tag key '%s': the 'aws:' prefix is reserved", key)
+		}
+		if utf8.RuneCountInString(value) > MaxValueLength {
+			return fmt.Errorf("aws: invalid tag value '%s': longer than %d characters", value, MaxValueLength)
+		}
+	}
+	return nil
+}
+
+// validateRetry returns an error if the retry config
+// specifies an unknown mode or negative limits.
+func validateRetry(cfg RetryConfig) error {
+	switch {
+	case cfg.Mode != "" && cfg.Mode != RetryModeStandard && cfg.Mode != RetryModeAdaptive:
+		return fmt.Errorf("aws: invalid retry mode '%s': must be '%s' or '%s'", cfg.Mode, RetryModeStandard, RetryModeAdaptive)
+	case cfg.MaxAttempts < 0:
+		return fmt.Errorf("aws: invalid retry max attempts '%d': must not be negative", cfg.MaxAttempts)
+	case cfg.MaxBackoff < 0:
+		return fmt.Errorf("aws: invalid retry max backoff '%v': must not be negative", cfg.MaxBackoff)
+	}
+	return nil
+}
+
+// validatePrefix returns an error if prefix contains characters
+// not allowed in secret names or starts or ends with a '/'.
+func validatePrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	if strings.HasPrefix(prefix, "/") || strings.HasSuffix(prefix, "/") {
+		return fmt.Errorf("aws: invalid prefix '%s': must not start or end with '/'", prefix)
+	}
+	for _, r := range prefix {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || strings.ContainsRune("/_+=.@-", r)) {
+			return fmt.Errorf("aws: invalid prefix '%s': contains invalid character '%c'", prefix, r)
+		}
+	}
+	return nil
+}
+
+// newRetryer returns a function creating AWS SDK retryers
+// as specified by the retry config.
+func newRetryer(cfg RetryConfig) func() aws.Retryer {
+	standardOptions := func(o *retry.StandardOptions) {
+		if cfg.MaxAttempts > 0 {
+			o.MaxAttempts = cfg.MaxAttempts
+		}
+		if cfg.MaxBackoff > 0 {
+			o.MaxBackoff = cfg.MaxBackoff
+		}
+	}
+	if cfg.Mode == RetryModeAdaptive {
+		return func() aws.Retryer {
+			return retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+				o.StandardOptions = append(o.StandardOptions, standardOptions)
+			})
+		}
+	}
+	return func() aws.Retryer { return retry.NewStandard(standardOptions) }
+}
+
+// validateReplicaRegions returns an error if the replica regions
+// contain duplicates, empty regions or the primary region.
+func validateReplicaRegions(cfg *Config) error {
+	regions := make(map[string]bool, len(cfg.ReplicaRegions))
+	for _, r := range cfg.ReplicaRegions {
+		switch {
+		case r.Region == "":
+			return errors.New("aws: invalid replica region: region is empty")
+		case r.Region == cfg.Region:
+			return fmt.Errorf("aws: invalid replica region '%s': region of the primary secret", r.Region)
+		case regions[r.Region]:
+			return fmt.Errorf("aws: invalid replica region '%s': region specified multiple times", r.Region)
+		}
+		regions[r.Region] = true
+	}
+	return nil
+}
+
+// secretTags returns the tags as SecretsManager tags
+// sorted by key.
+func secretTags(tags map[string]string) []types.Tag {
+	secretTags := make([]types.Tag, 0, len(tags))
+	for _, key := range slices.Sorted(maps.Keys(tags)) {
+		secretTags = append(secretTags, types.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(tags[key]),
+		})
+	}
+	return secretTags
+}
+
+// endpointURL returns the URL of the SecretsManager endpoint.
+// If no address is specified, it returns the regional endpoint.
+func endpointURL(cfg *Config) (string, error) {
+	if cfg.Addr == "" {
+		if cfg.PlainHTTP {
+			return "", errors.New("aws: plain HTTP requires an endpoint")
+		}
+
+		service, domain := "secretsmanager", "amazonaws.com"
+		if cfg.UseFIPSEndpoint {
+			service = "secretsmanager-fips"
+		}
+		if cfg.UseDualStackEndpoint {
+			domain = "api.aws"
+		}
+		return fmt.Sprintf("https://%s.%s.%s", service, cfg.Region, domain), nil
+	}
+	if cfg.UseFIPSEndpoint || cfg.UseDualStackEndpoint {
+		return "", fmt.Errorf("aws: invalid endpoint '%s': FIPS and dual-stack endpoints cannot be combined with a custom endpoint", cfg.Addr)
+	}
+
+	addr := cfg.Addr
+	if !strings.Contains(addr, "://") {
+		if cfg.PlainHTTP {
+			addr = "http://" + addr
+		} else {
+			addr = "https://" + addr
+		}
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", fmt.Errorf("aws: invalid endpoint '%s': %v", cfg.Addr, err)
+	}
+	switch {
+	case u.Scheme != "http" && u.Scheme != "https":
+		return "", fmt.Errorf("aws: invalid endpoint '%s': unsupported scheme '%s'", cfg.Addr, u.Scheme)
+	case u.Host == "":
+		return "", fmt.Errorf("aws: invalid endpoint '%s': no host specified", cfg.Addr)
+	case cfg.PlainHTTP && u.Scheme == "https":
+		return "", fmt.Errorf("aws: invalid endpoint '%s': plain HTTP specified for HTTPS endpoint", cfg.Addr)
+	}
+	return u.String(), nil
+}
+
+// customEndpointResolver implements the EndpointResolverV2 interface for Secrets Manager.
+// It resolves the custom endpoint using the default resolver such that requests are
+// still signed for the SecretsManager service and configured region.
 type customEndpointResolver struct {
 	endpoint string
 }
@@ -118,41 +547,72 @@ type customEndpointResolver struct {
 func (r *customEndpointResolver) ResolveEndpoint(ctx context.Context,
 	params secretsmanager.EndpointParameters,
 ) (smithyendpoints.Endpoint, error) {
+	params.Endpoint = aws.String(r.endpoint)
 	return secretsmanager.NewDefaultEndpointResolverV2().ResolveEndpoint(ctx, params)
 }
 
 // Store is an AWS SecretsManager secret store.
 type Store struct {
 	config Config
+	tags   []types.Tag
 	client *secretsmanager.Client
 }
 
 func (s *Store) String() string { return "AWS SecretsManager: " + s.config.Addr }
 
+// secretName returns the name of the secret storing the
+// key with the given name.
+func (s *Store) secretName(name string) string {
+	if s.config.Prefix == "" {
+		return name
+	}
+	return s.config.Prefix + "/" + name
+}
+
+// keyName returns the name of the key stored at the secret
+// with the given name. It reports whether the secret name
+// starts with the prefix.
+func (s *Store) keyName(secret string) (string, bool) {
+	if s.config.Prefix == "" {
+		return secret, true
+	}
+	return strings.CutPrefix(secret, s.config.Prefix+"/")
+}
+
 // Status returns the current state of the AWS SecretsManager instance.
 // In particular, whether it is reachable and the network latency.
+//
+// It lists at most one secret such that invalid credentials or missing
+// permissions are detected as well. Such failures are reported as
+// unauthenticated or unauthorized state along with an error.
 func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
-	// Build the endpoint URL
-	endpoint := "https://" + s.config.Addr
-	if s.config.Addr == "" {
-		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", s.config.Region)
+	start := time.Now()
+	_, err := s.client.ListSecrets(ctx, &secretsmanager.ListSecretsInput{
+		MaxResults: aws.Int32(1),
+	})
+	latency := time.Since(start)
+	if err == nil {
+		return kes.KeyStoreState{Latency: latency}, nil
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
-	if err != nil {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		var netErr net.Error
+		if errors.As(err, &netErr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+		}
 		return kes.KeyStoreState{}, err
 	}
-
-	start := time.Now()
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+	switch apiErr.ErrorCode() {
+	case "UnrecognizedClientException", "InvalidClientTokenId", "InvalidSignatureException",
+		"SignatureDoesNotMatch", "IncompleteSignature", "MissingAuthenticationToken",
+		"ExpiredTokenException", "ExpiredToken":
+		return kes.KeyStoreState{Latency: latency, Unauthenticated: true}, fmt.Errorf("aws: authentication failed: %v", err)
+	case "AccessDeniedException", "AccessDenied":
+		return kes.KeyStoreState{Latency: latency, Unauthorized: true}, fmt.Errorf("aws: access denied: %v", err)
+	default:
+		return kes.KeyStoreState{Latency: latency}, err
 	}
-	defer xhttp.DrainBody(resp.Body)
-
-	return kes.KeyStoreState{
-		Latency: time.Since(start),
-	}, nil
 }
 
 // Create stores the given key-value pair at the AWS SecretsManager
@@ -164,12 +624,26 @@ func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
 // encrypting secrets at the AWS SecretsManager.
 func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 	createInput := &secretsmanager.CreateSecretInput{
-		Name:         aws.String(name),
-		SecretString: aws.String(string(value)),
+		Name: aws.String(s.secretName(name)),
+	}
+	if s.config.Binary {
+		createInput.SecretBinary = value
+	} else {
+		createInput.SecretString = aws.String(string(value))
 	}
 	if s.config.KMSKeyID != "" {
 		createInput.KmsKeyId = aws.String(s.config.KMSKeyID)
 	}
+	if len(s.tags) > 0 {
+		createInput.Tags = s.tags
+	}
+	for _, r := range s.config.ReplicaRegions {
+		replica := types.ReplicaRegionType{Region: aws.String(r.Region)}
+		if r.KMSKeyID != "" {
+			replica.KmsKeyId = aws.String(r.KMSKeyID)
+		}
+		createInput.AddReplicaRegions = append(createInput.AddReplicaRegions, replica)
+	}
 
 	_, err := s.client.CreateSecret(ctx, createInput)
 	if err != nil {
@@ -187,22 +661,71 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 	return nil
 }
 
-// Set stores the given key-value pair at the AWS SecretsManager
-// if and only if it doesn't exists. If such an entry already exists
-// it returns kes.ErrKeyExists.
+// Set stores the given key-value pair at the AWS SecretsManager.
+//
+// If Config.Overwrite is set, Set replaces the value of an existing
+// secret by storing a new secret version. Otherwise, Set behaves like
+// Create and returns kes.ErrKeyExists if such an entry already exists.
 //
 // If the SecretsManager.KMSKeyID is set AWS will use this key ID to
 // encrypt the values. Otherwise, AWS will use the default key ID for
 // encrypting secrets at the AWS SecretsManager.
 func (s *Store) Set(ctx context.Context, name string, value []byte) error {
-	return s.Create(ctx, name, value)
+	err := s.Create(ctx, name, value)
+	if !s.config.Overwrite || !errors.Is(err, kesdk.ErrKeyExists) {
+		return err
+	}
+
+	// The KMS key ID is only updated via UpdateSecret. Hence, it
+	// is used, instead of PutSecretValue, when a KMS key is set
+	// such that existing secrets get re-encrypted with it.
+	var (
+		secretString *string
+		secretBinary []byte
+	)
+	if s.config.Binary {
+		secretBinary = value
+	} else {
+		secretString = aws.String(string(value))
+	}
+	if s.config.KMSKeyID != "" {
+		_, err = s.client.UpdateSecret(ctx, &secretsmanager.UpdateSecretInput{
+			SecretId:     aws.String(s.secretName(name)),
+			SecretString: secretString,
+			SecretBinary: secretBinary,
+			KmsKeyId:     aws.String(s.config.KMSKeyID),
+		})
+	} else {
+		_, err = s.client.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+			SecretId:     aws.String(s.secretName(name)),
+			SecretString: secretString,
+			SecretBinary: secretBinary,
+		})
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+
+		// The secret has been deleted in the meantime.
+		var rnfe *types.ResourceNotFoundException
+		if errors.As(err, &rnfe) {
+			return s.Create(ctx, name, value)
+		}
+		return fmt.Errorf("aws: failed to update '%s': %v", name, err)
+	}
+	return nil
 }
 
+// CanOverwrite reports whether Set replaces existing
+// entries. It returns the Config.Overwrite value.
+func (s *Store) CanOverwrite() bool { return s.config.Overwrite }
+
 // Get returns the value associated with the given key.
 // If no entry for key exists, it returns kes.ErrKeyNotFound.
 func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 	response, err := s.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
-		SecretId: aws.String(name),
+		SecretId: aws.String(s.secretName(name)),
 	})
 	if err != nil {
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
@@ -249,11 +772,76 @@ func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 	return value, nil
 }
 
+// maxBatchGet is the max. number of secrets that can be fetched
+// with a single BatchGetSecretValue request.
+const maxBatchGet = 20
+
+// GetBulk returns the values of the given secrets. It fetches
+// up to 20 secrets per BatchGetSecretValue request. Secrets that
+// do not exist are not included.
+func (s *Store) GetBulk(ctx context.Context, names []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(names))
+	for batch := range slices.Chunk(names, maxBatchGet) {
+		input := &secretsmanager.BatchGetSecretValueInput{
+			SecretIdList: make([]string, 0, len(batch)),
+		}
+		for _, name := range batch {
+			input.SecretIdList = append(input.SecretIdList, s.secretName(name))
+		}
+		for {
+			response, err := s.client.BatchGetSecretValue(ctx, input)
+			if err != nil {
+				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+					return nil, err
+				}
+				return nil, fmt.Errorf("aws: failed to read secrets: %v", err)
+			}
+			for _, e := range response.Errors {
+				switch code := aws.ToString(e.ErrorCode); code {
+				case "ResourceNotFoundException":
+					continue
+				case "DecryptionFailure", "DecryptionFailureException":
+					return nil, fmt.Errorf("aws: cannot access '%s': %s", aws.ToString(e.SecretId), aws.ToString(e.Message))
+				default:
+					return nil, fmt.Errorf("aws: failed to read '%s': %s: %s", aws.ToString(e.SecretId), code, aws.ToString(e.Message))
+				}
+			}
+			for _, v := range response.SecretValues {
+				name, ok := s.keyName(aws.ToString(v.Name))
+				if !ok {
+					continue
+				}
+				// See Get: only one of "SecretString" or "SecretBinary" is present.
+				if v.SecretString != nil {
+					values[name] = []byte(*v.SecretString)
+				} else {
+					values[name] = v.SecretBinary
+				}
+			}
+			if aws.ToString(response.NextToken) == "" {
+				break
+			}
+			input.NextToken = response.NextToken
+		}
+	}
+	return values, nil
+}
+
 // Delete removes the key-value pair from the AWS SecretsManager, if
 // it exists.
+//
+// SecretsManager does not delete secrets that are replicated to other
+// regions. Hence, if replica regions are configured, Delete removes
+// all replicas of the secret first.
 func (s *Store) Delete(ctx context.Context, name string) error {
+	if len(s.config.ReplicaRegions) > 0 {
+		if err := s.removeReplicas(ctx, name); err != nil {
+			return err
+		}
+	}
+
 	_, err := s.client.DeleteSecret(ctx, &secretsmanager.DeleteSecretInput{
-		SecretId:                   aws.String(name),
+		SecretId:                   aws.String(s.secretName(name)),
 		ForceDeleteWithoutRecovery: aws.Bool(true),
 	})
 	if err != nil {
@@ -279,37 +867,135 @@ func (s *Store) Delete(ctx context.Context, name string) error {
 	return nil
 }
 
-// List returns a new Iterator over the names of
-// all stored keys.
+// removeReplicas removes all replicas of the secret with
+// the given name.
+func (s *Store) removeReplicas(ctx context.Context, name string) error {
+	secret, err := s.client.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(s.secretName(name)),
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+
+		var rnfe *types.ResourceNotFoundException
+		if errors.As(err, &rnfe) {
+			return kesdk.ErrKeyNotFound
+		}
+		return fmt.Errorf("aws: failed to describe '%s': %v", name, err)
+	}
+	if len(secret.ReplicationStatus) == 0 {
+		return nil
+	}
+
+	regions := make([]string, 0, len(secret.ReplicationStatus))
+	for _, r := range secret.ReplicationStatus {
+		regions = append(regions, aws.ToString(r.Region))
+	}
+	if _, err = s.client.RemoveRegionsFromReplication(ctx, &secretsmanager.RemoveRegionsFromReplicationInput{
+		SecretId:             aws.String(s.secretName(name)),
+		RemoveReplicaRegions: regions,
+	}); err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return fmt.Errorf("aws: failed to remove replicas of '%s': %v", name, err)
+	}
+	return nil
+}
+
 // List returns the first n key names, that start with the given
-// prefix, and the next prefix from which the listing should
-// continue.
+// prefix, and a cursor from which the listing should continue.
+//
+// List fetches only as many secrets as needed. The prefix is
+// applied by SecretsManager and the returned cursor contains
+// the SecretsManager continuation token. Passing the cursor as
+// prefix to List continues the listing.
 //
-// It returns all keys with the prefix if n < 0 and less than n
-// names if n is greater than the number of keys with the prefix.
+// If n <= 0, List returns all keys starting with the prefix and
+// no cursor. At the end of the listing or when there are no (more)
+// keys starting with the prefix, the returned cursor is empty.
 //
-// An empty prefix matches any key name. At the end of the listing
-// or when there are no (more) keys starting with the prefix, the
-// returned prefix is empty.
+// If Config.Prefix is set, only secrets with this prefix are listed
+// and the prefix is removed from the returned names.
 func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
-	var names []string
+	const PageSize = 100 // Max. number of results per SecretsManager request
 
-	paginator := secretsmanager.NewListSecretsPaginator(s.client, &secretsmanager.ListSecretsInput{})
+	var token *string
+	if p, t, ok := parseListCursor(prefix); ok {
+		prefix, token = p, &t
+	}
+
+	secretPrefix := s.secretName(prefix)
+	input := &secretsmanager.ListSecretsInput{}
+	if secretPrefix != "" {
+		input.Filters = []types.Filter{{
+			Key:    types.FilterNameStringTypeName,
+			Values: []string{secretPrefix},
+		}}
+	}
+
+	var names []string
+	if n > 0 {
+		names = make([]string, 0, min(n, PageSize))
+	}
+	for {
+		input.NextToken = token
+		input.MaxResults = aws.Int32(PageSize)
+		if n > 0 {
+			input.MaxResults = aws.Int32(int32(min(n-len(names), PageSize)))
+		}
 
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+		page, err := s.client.ListSecrets(ctx, input)
 		if err != nil {
 			return nil, "", err
 		}
-
 		for _, secret := range page.SecretList {
-			if secret.Name != nil {
-				names = append(names, *secret.Name)
+			// The name filter of SecretsManager is not case-sensitive.
+			if secret.Name == nil || !strings.HasPrefix(*secret.Name, secretPrefix) {
+				continue
+			}
+			if name, ok := s.keyName(*secret.Name); ok {
+				names = append(names, name)
 			}
 		}
+
+		token = page.NextToken
+		if token == nil || *token == "" {
+			return names, "", nil
+		}
+		if n > 0 && len(names) >= n {
+			return names, listCursor(prefix, *token), nil
+		}
 	}
+}
+
+// listCursorPrefix is the prefix of list cursors. It contains
+// a NUL byte and therefore never collides with a secret name.
+const listCursorPrefix = "\x00aws-list:"
 
-	return keystore.List(names, prefix, n)
+// listCursor returns a list cursor that continues the listing
+// of the given prefix at the SecretsManager continuation token.
+func listCursor(prefix, token string) string {
+	return listCursorPrefix + base64.RawURLEncoding.EncodeToString([]byte(prefix)) + ":" + token
+}
+
+// parseListCursor parses the list cursor. It reports whether
+// s is a list cursor.
+func parseListCursor(s string) (prefix, token string, ok bool) {
+	s, ok = strings.CutPrefix(s, listCursorPrefix)
+	if !ok {
+		return "", "", false
+	}
+	p, token, ok := strings.Cut(s, ":")
+	if !ok || token == "" {
+		return "", "", false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return "", "", false
+	}
+	return string(b), token, true
 }
 
 // Close closes the Store.
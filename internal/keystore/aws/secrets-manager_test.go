@@ -0,0 +1,1186 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestStoreList(t *testing.T) {
+	secrets := []string{"my-key-0", "my-key-1", "My-Key-2", "my-key-3", "my-key-4", "other-key"}
+
+	var requests int
+	srv := newListSecretsServer(secrets, &requests)
+	defer srv.Close()
+
+	store := newListSecretsStore(srv.URL)
+
+	ctx := context.Background()
+	for i, test := range storeListTests {
+		requests = 0
+
+		var (
+			names  []string
+			cursor = test.Prefix
+		)
+		for {
+			page, next, err := store.List(ctx, cursor, test.N)
+			if err != nil {
+				t.Fatalf("Test %d: failed to list secrets: %v", i, err)
+			}
+			if test.N > 0 && len(page) > test.N {
+				t.Fatalf("Test %d: got %d names - want at most %d", i, len(page), test.N)
+			}
+			names = append(names, page...)
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if !slices.Equal(names, test.Names) {
+			t.Fatalf("Test %d: got '%v' - want '%v'", i, names, test.Names)
+		}
+		if requests != test.Requests {
+			t.Fatalf("Test %d: got %d requests - want %d", i, requests, test.Requests)
+		}
+	}
+}
+
+func TestStoreListAll(t *testing.T) {
+	secrets := make([]string, 0, 250)
+	for i := range cap(secrets) {
+		secrets = append(secrets, "my-key-"+strconv.Itoa(i))
+	}
+
+	var requests int
+	srv := newListSecretsServer(secrets, &requests)
+	defer srv.Close()
+
+	store := newListSecretsStore(srv.URL)
+	names, next, err := store.List(context.Background(), "", -1)
+	if err != nil {
+		t.Fatalf("Failed to list secrets: %v", err)
+	}
+	if next != "" {
+		t.Fatalf("Listing all secrets returned a cursor: got '%s'", next)
+	}
+	if !slices.Equal(names, secrets) {
+		t.Fatalf("Invalid secrets: got %d names - want %d", len(names), len(secrets))
+	}
+	if requests != 3 {
+		t.Fatalf("Invalid number of requests: got %d - want %d", requests, 3)
+	}
+}
+
+// newListSecretsServer returns a server that mocks the SecretsManager
+// ListSecrets API for the given secrets. It counts the received
+// requests.
+func newListSecretsServer(secrets []string, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if target := r.Header.Get("X-Amz-Target"); target != "secretsmanager.ListSecrets" {
+			http.Error(w, "unexpected operation: "+target, http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Filters []struct {
+				Key    string
+				Values []string
+			}
+			MaxResults int
+			NextToken  string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Like SecretsManager, match names case-insensitive.
+		var matches []string
+		for _, name := range secrets {
+			if len(req.Filters) == 0 || strings.HasPrefix(strings.ToLower(name), strings.ToLower(req.Filters[0].Values[0])) {
+				matches = append(matches, name)
+			}
+		}
+		start, _ := strconv.Atoi(req.NextToken)
+		end := min(start+req.MaxResults, len(matches))
+
+		type secret struct{ Name string }
+		var resp struct {
+			SecretList []secret
+			NextToken  string `json:",omitempty"`
+		}
+		for _, name := range matches[start:end] {
+			resp.SecretList = append(resp.SecretList, secret{Name: name})
+		}
+		if end < len(matches) {
+			resp.NextToken = strconv.Itoa(end)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func newListSecretsStore(endpoint string) *Store {
+	return &Store{
+		client: secretsmanager.New(secretsmanager.Options{
+			BaseEndpoint: aws.String(endpoint),
+			Region:       "us-east-1",
+			Credentials:  credentials.NewStaticCredentialsProvider("access-key", "secret-key", ""),
+		}),
+	}
+}
+
+var storeListTests = []struct {
+	Prefix   string
+	N        int
+	Names    []string
+	Requests int
+}{
+	{ // 0
+		Prefix:   "",
+		N:        -1,
+		Names:    []string{"my-key-0", "my-key-1", "My-Key-2", "my-key-3", "my-key-4", "other-key"},
+		Requests: 1,
+	},
+	{ // 1
+		Prefix:   "my-key",
+		N:        2,
+		Names:    []string{"my-key-0", "my-key-1", "my-key-3", "my-key-4"},
+		Requests: 3,
+	},
+	{ // 2
+		Prefix:   "other",
+		N:        10,
+		Names:    []string{"other-key"},
+		Requests: 1,
+	},
+	{ // 3
+		Prefix:   "none",
+		N:        10,
+		Names:    nil,
+		Requests: 1,
+	},
+}
+
+func TestConnectCustomEndpoint(t *testing.T) {
+	for i, test := range connectCustomEndpointTests {
+		mock := newMockSecretsManager()
+		var srv *httptest.Server
+		if test.TLS {
+			srv = httptest.NewTLSServer(mock)
+		} else {
+			srv = httptest.NewServer(mock)
+		}
+		defer srv.Close()
+
+		addr := strings.TrimPrefix(strings.TrimPrefix(srv.URL, "https://"), "http://")
+		if test.Scheme {
+			addr = srv.URL
+		}
+		ctx := context.Background()
+		store, err := Connect(ctx, &Config{
+			Addr:               addr,
+			Region:             "us-east-1",
+			PlainHTTP:          test.PlainHTTP,
+			InsecureSkipVerify: test.InsecureSkipVerify,
+			Login:              Credentials{AccessKey: "access-key", SecretKey: "secret-key"},
+		})
+		if err != nil {
+			t.Fatalf("Test %d: failed to connect: %v", i, err)
+		}
+
+		if err = store.Create(ctx, "my-key", []byte("my-value")); err != nil {
+			t.Fatalf("Test %d: failed to create secret: %v", i, err)
+		}
+		if err = store.Create(ctx, "my-key", []byte("my-value")); !errors.Is(err, kesdk.ErrKeyExists) {
+			t.Fatalf("Test %d: created secret twice: got '%v' - want '%v'", i, err, kesdk.ErrKeyExists)
+		}
+		if value, err := store.Get(ctx, "my-key"); err != nil || string(value) != "my-value" {
+			t.Fatalf("Test %d: failed to get secret: got '%s': %v", i, value, err)
+		}
+		if err = store.Delete(ctx, "my-key"); err != nil {
+			t.Fatalf("Test %d: failed to delete secret: %v", i, err)
+		}
+		if _, err = store.Get(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+			t.Fatalf("Test %d: got deleted secret: got '%v' - want '%v'", i, err, kesdk.ErrKeyNotFound)
+		}
+		if mock.Requests() == 0 {
+			t.Fatalf("Test %d: requests have not been sent to the custom endpoint", i)
+		}
+	}
+}
+
+func TestEndpointURL(t *testing.T) {
+	for i, test := range endpointURLTests {
+		endpoint, err := endpointURL(&test.Config)
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to parse endpoint: %v", i, err)
+		}
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: parsed invalid endpoint", i)
+		}
+		if endpoint != test.Endpoint {
+			t.Fatalf("Test %d: got '%s' - want '%s'", i, endpoint, test.Endpoint)
+		}
+	}
+}
+
+var connectCustomEndpointTests = []struct {
+	TLS                bool
+	Scheme             bool
+	PlainHTTP          bool
+	InsecureSkipVerify bool
+}{
+	{TLS: false, PlainHTTP: true},                       // 0
+	{TLS: false, Scheme: true},                          // 1
+	{TLS: true, InsecureSkipVerify: true},               // 2
+	{TLS: true, Scheme: true, InsecureSkipVerify: true}, // 3
+}
+
+var endpointURLTests = []struct {
+	Config     Config
+	Endpoint   string
+	ShouldFail bool
+}{
+	{ // 0
+		Config:   Config{Region: "us-east-1"},
+		Endpoint: "https://secretsmanager.us-east-1.amazonaws.com",
+	},
+	{ // 1
+		Config:   Config{Addr: "secretsmanager.us-east-1.amazonaws.com", Region: "us-east-1"},
+		Endpoint: "https://secretsmanager.us-east-1.amazonaws.com",
+	},
+	{ // 2
+		Config:   Config{Addr: "localhost:4566", PlainHTTP: true},
+		Endpoint: "http://localhost:4566",
+	},
+	{ // 3
+		Config:   Config{Addr: "http://localhost:4566"},
+		Endpoint: "http://localhost:4566",
+	},
+	{ // 4
+		Config:     Config{Addr: "https://localhost:4566", PlainHTTP: true},
+		ShouldFail: true,
+	},
+	{ // 5
+		Config:     Config{Addr: "ftp://localhost:4566"},
+		ShouldFail: true,
+	},
+	{ // 6
+		Config:     Config{PlainHTTP: true},
+		ShouldFail: true,
+	},
+	{ // 7
+		Config:   Config{Region: "us-gov-west-1", UseFIPSEndpoint: true},
+		Endpoint: "https://secretsmanager-fips.us-gov-west-1.amazonaws.com",
+	},
+	{ // 8
+		Config:   Config{Region: "us-east-1", UseDualStackEndpoint: true},
+		Endpoint: "https://secretsmanager.us-east-1.api.aws",
+	},
+	{ // 9
+		Config:   Config{Region: "us-east-1", UseFIPSEndpoint: true, UseDualStackEndpoint: true},
+		Endpoint: "https://secretsmanager-fips.us-east-1.api.aws",
+	},
+	{ // 10
+		Config:     Config{Addr: "secretsmanager.us-east-1.amazonaws.com", Region: "us-east-1", UseFIPSEndpoint: true},
+		ShouldFail: true,
+	},
+	{ // 11
+		Config:     Config{Addr: "localhost:4566", PlainHTTP: true, UseDualStackEndpoint: true},
+		ShouldFail: true,
+	},
+}
+
+func TestStoreSet(t *testing.T) {
+	for i, test := range storeSetTests {
+		mock := newMockSecretsManager()
+		srv := httptest.NewServer(mock)
+		defer srv.Close()
+
+		ctx := context.Background()
+		store, err := Connect(ctx, &Config{
+			Addr:      srv.URL,
+			Region:    "us-east-1",
+			KMSKeyID:  test.KMSKeyID,
+			Overwrite: test.Overwrite,
+			Login:     Credentials{AccessKey: "access-key", SecretKey: "secret-key"},
+		})
+		if err != nil {
+			t.Fatalf("Test %d: failed to connect: %v", i, err)
+		}
+		if store.CanOverwrite() != test.Overwrite {
+			t.Fatalf("Test %d: got CanOverwrite '%v' - want '%v'", i, store.CanOverwrite(), test.Overwrite)
+		}
+
+		if err = store.Set(ctx, "my-key", []byte("value-0")); err != nil {
+			t.Fatalf("Test %d: failed to set new secret: %v", i, err)
+		}
+		err = store.Set(ctx, "my-key", []byte("value-1"))
+		if test.Overwrite && err != nil {
+			t.Fatalf("Test %d: failed to overwrite secret: %v", i, err)
+		}
+		if !test.Overwrite && !errors.Is(err, kesdk.ErrKeyExists) {
+			t.Fatalf("Test %d: overwrote secret: got '%v' - want '%v'", i, err, kesdk.ErrKeyExists)
+		}
+
+		value, err := store.Get(ctx, "my-key")
+		if err != nil {
+			t.Fatalf("Test %d: failed to get secret: %v", i, err)
+		}
+		if string(value) != test.Value {
+			t.Fatalf("Test %d: got value '%s' - want '%s'", i, value, test.Value)
+		}
+		if !slices.Equal(mock.updates, test.Updates) {
+			t.Fatalf("Test %d: got updates '%v' - want '%v'", i, mock.updates, test.Updates)
+		}
+	}
+}
+
+var storeSetTests = []struct {
+	Overwrite bool
+	KMSKeyID  string
+	Value     string
+	Updates   []string
+}{
+	{ // 0
+		Overwrite: false,
+		Value:     "value-0",
+	},
+	{ // 1
+		Overwrite: true,
+		Value:     "value-1",
+		Updates:   []string{"PutSecretValue"},
+	},
+	{ // 2
+		Overwrite: true,
+		KMSKeyID:  "my-kms-key",
+		Value:     "value-1",
+		Updates:   []string{"UpdateSecret"},
+	},
+}
+
+func TestStoreBinary(t *testing.T) {
+	mock := newMockSecretsManager()
+	mock.secrets["string-key"] = "string-value" // Stored before switching to binary secrets
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, &Config{
+		Addr:      srv.URL,
+		Region:    "us-east-1",
+		Binary:    true,
+		Overwrite: true,
+		Login:     Credentials{AccessKey: "access-key", SecretKey: "secret-key"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	value := []byte{0x00, 0xff, 0xfe, 'k', 'e', 's'} // Not valid UTF-8
+	if err = store.Create(ctx, "binary-key", value); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	if !mock.binary["binary-key"] {
+		t.Fatal("Secret has not been stored as SecretBinary")
+	}
+	if v, err := store.Get(ctx, "binary-key"); err != nil || !slices.Equal(v, value) {
+		t.Fatalf("Failed to get binary secret: got '%x' - want '%x': %v", v, value, err)
+	}
+	if v, err := store.Get(ctx, "string-key"); err != nil || string(v) != "string-value" {
+		t.Fatalf("Failed to get string secret: got '%s' - want '%s': %v", v, "string-value", err)
+	}
+
+	if err = store.Set(ctx, "string-key", value); err != nil {
+		t.Fatalf("Failed to overwrite secret: %v", err)
+	}
+	if !mock.binary["string-key"] {
+		t.Fatal("Secret has not been overwritten with a SecretBinary")
+	}
+
+	values, err := store.GetBulk(ctx, []string{"binary-key", "string-key"})
+	if err != nil {
+		t.Fatalf("Failed to get secrets: %v", err)
+	}
+	if len(values) != 2 {
+		t.Fatalf("Invalid number of secrets: got %d - want %d", len(values), 2)
+	}
+	for name, v := range values {
+		if !slices.Equal(v, value) {
+			t.Fatalf("Invalid value of '%s': got '%x' - want '%x'", name, v, value)
+		}
+	}
+}
+
+func TestStoreGetBulk(t *testing.T) {
+	for i, test := range storeGetBulkTests {
+		mock := newMockSecretsManager()
+		for j := range test.Secrets {
+			mock.secrets[fmt.Sprintf("key-%d", j)] = fmt.Sprintf("value-%d", j)
+		}
+		srv := httptest.NewServer(mock)
+		defer srv.Close()
+
+		ctx := context.Background()
+		store, err := Connect(ctx, &Config{
+			Addr:   srv.URL,
+			Region: "us-east-1",
+			Login:  Credentials{AccessKey: "access-key", SecretKey: "secret-key"},
+		})
+		if err != nil {
+			t.Fatalf("Test %d: failed to connect: %v", i, err)
+		}
+
+		names := make([]string, 0, test.Names)
+		for j := range test.Names {
+			names = append(names, fmt.Sprintf("key-%d", j))
+		}
+		requests := mock.Requests()
+		values, err := store.GetBulk(ctx, names)
+		if err != nil {
+			t.Fatalf("Test %d: failed to get secrets: %v", i, err)
+		}
+		if n := mock.Requests() - requests; n != test.Requests {
+			t.Fatalf("Test %d: got %d requests - want %d", i, n, test.Requests)
+		}
+		if n := min(test.Names, test.Secrets); len(values) != n {
+			t.Fatalf("Test %d: got %d secrets - want %d", i, len(values), n)
+		}
+		for name, value := range values {
+			if want := strings.Replace(name, "key-", "value-", 1); string(value) != want {
+				t.Fatalf("Test %d: got value '%s' for '%s' - want '%s'", i, value, name, want)
+			}
+		}
+	}
+}
+
+var storeGetBulkTests = []struct {
+	Secrets  int // Number of existing secrets
+	Names    int // Number of requested secrets
+	Requests int // Number of expected BatchGetSecretValue requests
+}{
+	{Secrets: 5, Names: 0, Requests: 0},   // 0
+	{Secrets: 5, Names: 5, Requests: 1},   // 1
+	{Secrets: 20, Names: 20, Requests: 1}, // 2
+	{Secrets: 45, Names: 45, Requests: 3}, // 3
+	{Secrets: 10, Names: 25, Requests: 2}, // 4
+	{Secrets: 0, Names: 3, Requests: 1},   // 5
+}
+
+func TestStoreCreateTags(t *testing.T) {
+	mock := newMockSecretsManager()
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, &Config{
+		Addr:   srv.URL,
+		Region: "us-east-1",
+		Tags:   map[string]string{"kes-cluster": "kes-prod-1", "cost-center": "1234", "empty": ""},
+		Login:  Credentials{AccessKey: "access-key", SecretKey: "secret-key"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", []byte("value")); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	want := []string{"cost-center=1234", "empty=", "kes-cluster=kes-prod-1"}
+	if tags := mock.tags["my-key"]; !slices.Equal(tags, want) {
+		t.Fatalf("Invalid tags: got '%v' - want '%v'", tags, want)
+	}
+}
+
+func TestConnectProxy(t *testing.T) {
+	mock := newMockSecretsManager()
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.Host)
+		mock.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	// The endpoint is not resolvable. Hence, requests only
+	// succeed if sent via the proxy.
+	ctx := context.Background()
+	store, err := Connect(ctx, &Config{
+		Addr:      "http://secretsmanager.kes.invalid",
+		Region:    "us-east-1",
+		PlainHTTP: true,
+		ProxyURL:  proxy.URL,
+		Login:     Credentials{AccessKey: "access-key", SecretKey: "secret-key"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect via proxy: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", []byte("my-value")); err != nil {
+		t.Fatalf("Failed to create secret via proxy: %v", err)
+	}
+	if len(proxied) == 0 || proxied[0] != "secretsmanager.kes.invalid" {
+		t.Fatalf("Invalid proxied requests: got '%v'", proxied)
+	}
+}
+
+func TestConnectCAPath(t *testing.T) {
+	srv := httptest.NewTLSServer(newMockSecretsManager())
+	defer srv.Close()
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caPath, ca, 0o600); err != nil {
+		t.Fatalf("Failed to write CA certificate: %v", err)
+	}
+
+	config := &Config{
+		Addr:            srv.URL,
+		Region:          "us-east-1",
+		DialTimeout:     5 * time.Second,
+		ResponseTimeout: 5 * time.Second,
+		Retry:           RetryConfig{MaxAttempts: 1},
+		Login:           Credentials{AccessKey: "access-key", SecretKey: "secret-key"},
+	}
+	if _, err := Connect(context.Background(), config); err == nil {
+		t.Fatal("Connecting to endpoint with untrusted certificate should have failed")
+	}
+	config.CAPath = caPath
+	if _, err := Connect(context.Background(), config); err != nil {
+		t.Fatalf("Failed to connect with CA certificate: %v", err)
+	}
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	for i, test := range newHTTPClientTests {
+		_, err := newHTTPClient(&test.Config)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to create HTTP client: %v", i, err)
+		}
+	}
+}
+
+var newHTTPClientTests = []struct {
+	Config     Config
+	ShouldFail bool
+}{
+	{Config: Config{}}, // 0
+	{Config: Config{ProxyURL: "http://proxy.example.com:3128"}},                       // 1
+	{Config: Config{ProxyURL: "https://proxy.example.com", DialTimeout: time.Second}}, // 2
+	{Config: Config{ProxyURL: "proxy.example.com"}, ShouldFail: true},                 // 3
+	{Config: Config{ProxyURL: "ftp://proxy.example.com"}, ShouldFail: true},           // 4
+	{Config: Config{ResponseTimeout: -time.Second}, ShouldFail: true},                 // 5
+}
+
+func TestStoreStatus(t *testing.T) {
+	mock := newMockSecretsManager()
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, &Config{
+		Addr:   srv.URL,
+		Region: "us-east-1",
+		Login:  Credentials{AccessKey: "access-key", SecretKey: "secret-key"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	for i, test := range storeStatusTests {
+		mock.mu.Lock()
+		mock.denied = test.Error
+		mock.mu.Unlock()
+
+		state, err := store.Status(ctx)
+		if (err != nil) != test.ShouldFail {
+			t.Fatalf("Test %d: got error '%v' - want failure '%v'", i, err, test.ShouldFail)
+		}
+		if state.Unauthenticated != test.Unauthenticated {
+			t.Fatalf("Test %d: unauthenticated mismatch: got '%v' - want '%v'", i, state.Unauthenticated, test.Unauthenticated)
+		}
+		if state.Unauthorized != test.Unauthorized {
+			t.Fatalf("Test %d: unauthorized mismatch: got '%v' - want '%v'", i, state.Unauthorized, test.Unauthorized)
+		}
+		if _, ok := keystore.IsUnreachable(err); ok {
+			t.Fatalf("Test %d: reachable SecretsManager reported as unreachable: %v", i, err)
+		}
+	}
+
+	srv.Close()
+	if _, err = store.Status(ctx); err == nil {
+		t.Fatal("Status of unreachable SecretsManager should have failed")
+	} else if _, ok := keystore.IsUnreachable(err); !ok {
+		t.Fatalf("Unreachable SecretsManager not reported as unreachable: %v", err)
+	}
+}
+
+var storeStatusTests = []struct {
+	Error           string
+	Unauthenticated bool
+	Unauthorized    bool
+	ShouldFail      bool
+}{
+	{Error: ""}, // 0
+	{Error: "UnrecognizedClientException", Unauthenticated: true, ShouldFail: true}, // 1
+	{Error: "InvalidSignatureException", Unauthenticated: true, ShouldFail: true},   // 2
+	{Error: "AccessDeniedException", Unauthorized: true, ShouldFail: true},          // 3
+	{Error: "InvalidParameterException", ShouldFail: true},                          // 4
+}
+
+func TestStoreReplicaRegions(t *testing.T) {
+	mock := newMockSecretsManager()
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, &Config{
+		Addr:   srv.URL,
+		Region: "us-east-1",
+		ReplicaRegions: []ReplicaRegion{
+			{Region: "us-west-2", KMSKeyID: "alias/kes-replica"},
+			{Region: "eu-central-1"},
+		},
+		Login: Credentials{AccessKey: "access-key", SecretKey: "secret-key"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", []byte("value")); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	want := []string{"us-west-2=alias/kes-replica", "eu-central-1="}
+	if replicas := mock.replicas["my-key"]; !slices.Equal(replicas, want) {
+		t.Fatalf("Invalid replicas: got '%v' - want '%v'", replicas, want)
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete replicated secret: %v", err)
+	}
+	if _, ok := mock.secrets["my-key"]; ok {
+		t.Fatal("Secret has not been deleted")
+	}
+	if err = store.Delete(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Deleting a non-existing secret: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+}
+
+func TestStorePrefix(t *testing.T) {
+	mock := newMockSecretsManager()
+	mock.secrets["other/my-key"] = "other-value" // Secret of another deployment
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, &Config{
+		Addr:   srv.URL,
+		Region: "us-east-1",
+		Prefix: "kes/prod",
+		Login:  Credentials{AccessKey: "access-key", SecretKey: "secret-key"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	if err = store.Create(ctx, "my-key", []byte("my-value")); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	if value, ok := mock.secrets["kes/prod/my-key"]; !ok || value != "my-value" {
+		t.Fatalf("Secret not stored with prefix: got '%v'", slices.Collect(maps.Keys(mock.secrets)))
+	}
+	if value, err := store.Get(ctx, "my-key"); err != nil || string(value) != "my-value" {
+		t.Fatalf("Failed to get secret: got '%s': %v", value, err)
+	}
+	if values, err := store.GetBulk(ctx, []string{"my-key"}); err != nil || string(values["my-key"]) != "my-value" {
+		t.Fatalf("Failed to get secrets: got '%v': %v", values, err)
+	}
+	for _, prefix := range []string{"", "my"} {
+		if names, _, err := store.List(ctx, prefix, -1); err != nil || !slices.Equal(names, []string{"my-key"}) {
+			t.Fatalf("Failed to list secrets with prefix '%s': got '%v' - want '%v': %v", prefix, names, []string{"my-key"}, err)
+		}
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete secret: %v", err)
+	}
+	if _, ok := mock.secrets["kes/prod/my-key"]; ok {
+		t.Fatal("Secret has not been deleted")
+	}
+	if _, ok := mock.secrets["other/my-key"]; !ok {
+		t.Fatal("Secret of another deployment has been deleted")
+	}
+}
+
+func TestValidatePrefix(t *testing.T) {
+	for i, test := range validatePrefixTests {
+		err := validatePrefix(test.Prefix)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to validate prefix: %v", i, err)
+		}
+	}
+}
+
+var validatePrefixTests = []struct {
+	Prefix     string
+	ShouldFail bool
+}{
+	{Prefix: ""},                           // 0
+	{Prefix: "kes"},                        // 1
+	{Prefix: "kes/prod/eu-west-1"},         // 2
+	{Prefix: "team_a+b=c.d@e"},             // 3
+	{Prefix: "/kes", ShouldFail: true},     // 4
+	{Prefix: "kes/", ShouldFail: true},     // 5
+	{Prefix: "kes prod", ShouldFail: true}, // 6
+	{Prefix: "kes:prod", ShouldFail: true}, // 7
+}
+
+func TestValidateReplicaRegions(t *testing.T) {
+	for i, test := range validateReplicaRegionsTests {
+		err := validateReplicaRegions(&Config{Region: "us-east-1", ReplicaRegions: test.Replicas})
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to validate replica regions: %v", i, err)
+		}
+	}
+}
+
+var validateReplicaRegionsTests = []struct {
+	Replicas   []ReplicaRegion
+	ShouldFail bool
+}{
+	{Replicas: nil}, // 0
+	{Replicas: []ReplicaRegion{{Region: "us-west-2"}}},                                               // 1
+	{Replicas: []ReplicaRegion{{Region: "us-west-2", KMSKeyID: "alias/key"}, {Region: "eu-west-1"}}}, // 2
+	{Replicas: []ReplicaRegion{{Region: ""}}, ShouldFail: true},                                      // 3
+	{Replicas: []ReplicaRegion{{Region: "us-east-1"}}, ShouldFail: true},                             // 4
+	{Replicas: []ReplicaRegion{{Region: "us-west-2"}, {Region: "us-west-2"}}, ShouldFail: true},      // 5
+}
+
+func TestNewRetryer(t *testing.T) {
+	for i, test := range newRetryerTests {
+		if err := validateRetry(test.Config); err != nil {
+			if !test.ShouldFail {
+				t.Fatalf("Test %d: failed to validate retry config: %v", i, err)
+			}
+			continue
+		}
+		if test.ShouldFail {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+
+		retryer := newRetryer(test.Config)()
+		if n := retryer.MaxAttempts(); n != test.MaxAttempts {
+			t.Fatalf("Test %d: max attempts mismatch: got '%d' - want '%d'", i, n, test.MaxAttempts)
+		}
+		if _, ok := retryer.(*retry.AdaptiveMode); ok != (test.Config.Mode == RetryModeAdaptive) {
+			t.Fatalf("Test %d: retry mode mismatch: got '%T' - want '%s'", i, retryer, test.Config.Mode)
+		}
+	}
+}
+
+var newRetryerTests = []struct {
+	Config      RetryConfig
+	MaxAttempts int
+	ShouldFail  bool
+}{
+	{Config: RetryConfig{}, MaxAttempts: retry.DefaultMaxAttempts},                                            // 0
+	{Config: RetryConfig{Mode: RetryModeStandard, MaxAttempts: 5}, MaxAttempts: 5},                            // 1
+	{Config: RetryConfig{Mode: RetryModeAdaptive, MaxAttempts: 10, MaxBackoff: time.Minute}, MaxAttempts: 10}, // 2
+	{Config: RetryConfig{Mode: RetryModeAdaptive}, MaxAttempts: retry.DefaultMaxAttempts},                     // 3
+	{Config: RetryConfig{Mode: "legacy"}, ShouldFail: true},                                                   // 4
+	{Config: RetryConfig{MaxAttempts: -1}, ShouldFail: true},                                                  // 5
+	{Config: RetryConfig{MaxBackoff: -time.Second}, ShouldFail: true},                                         // 6
+}
+
+func TestValidateTags(t *testing.T) {
+	for i, test := range validateTagsTests {
+		err := validateTags(test.Tags)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to validate tags: %v", i, err)
+		}
+	}
+}
+
+var validateTagsTests = []struct {
+	Tags       map[string]string
+	ShouldFail bool
+}{
+	{Tags: nil}, // 0
+	{Tags: map[string]string{"kes-cluster": "kes-prod-1"}},                         // 1
+	{Tags: map[string]string{"Cost Center": "", "env": "x"}},                       // 2
+	{Tags: map[string]string{"": "value"}, ShouldFail: true},                       // 3
+	{Tags: map[string]string{"aws:created": "kes"}, ShouldFail: true},              // 4
+	{Tags: map[string]string{"AWS:created": "kes"}, ShouldFail: true},              // 5
+	{Tags: map[string]string{strings.Repeat("k", 129): "value"}, ShouldFail: true}, // 6
+	{Tags: map[string]string{"key": strings.Repeat("v", 257)}, ShouldFail: true},   // 7
+	{Tags: map[string]string{strings.Repeat("k", 128): strings.Repeat("v", 256)}},  // 8
+}
+
+func TestConnectRoleConfig(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("my-token"), 0o600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+
+	for i, test := range connectRoleConfigTests {
+		config := test.Config
+		if config.WebIdentityTokenFile == "token" {
+			config.WebIdentityTokenFile = tokenFile
+		}
+		config.Region = "us-east-1"
+
+		_, err := Connect(context.Background(), &config)
+		if err == nil {
+			t.Fatalf("Test %d: connected with invalid role config", i)
+		}
+		if !strings.Contains(err.Error(), test.Err) {
+			t.Fatalf("Test %d: got error '%v' - want error containing '%s'", i, err, test.Err)
+		}
+	}
+}
+
+var connectRoleConfigTests = []struct {
+	Config Config
+	Err    string
+}{
+	{ // 0
+		Config: Config{WebIdentityTokenFile: "token"},
+		Err:    "requires a role ARN",
+	},
+	{ // 1
+		Config: Config{RoleARN: "arn:aws:iam::123456789012:role/kes", WebIdentityTokenFile: "token", ExternalID: "my-id"},
+		Err:    "external ID is not supported",
+	},
+	{ // 2
+		Config: Config{RoleARN: "arn:aws:iam::123456789012:role/kes", WebIdentityTokenFile: "does-not-exist"},
+		Err:    "does-not-exist",
+	},
+	{ // 3
+		Config: Config{ExternalID: "my-id"},
+		Err:    "require a role ARN",
+	},
+	{ // 4
+		Config: Config{SessionName: "kes"},
+		Err:    "require a role ARN",
+	},
+}
+
+func TestRoleCredentials(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("my-token"), 0o600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+
+	for i, test := range roleCredentialsTests {
+		var (
+			mu     sync.Mutex
+			params map[string][]string
+		)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			mu.Lock()
+			params = r.PostForm
+			mu.Unlock()
+
+			action := r.PostForm.Get("Action")
+			w.Header().Set("Content-Type", "text/xml")
+			fmt.Fprintf(w, `<%[1]sResponse><%[1]sResult><Credentials>`+
+				`<AccessKeyId>role-access-key</AccessKeyId><SecretAccessKey>role-secret-key</SecretAccessKey>`+
+				`<SessionToken>role-session-token</SessionToken><Expiration>2100-01-01T00:00:00Z</Expiration>`+
+				`</Credentials></%[1]sResult></%[1]sResponse>`, action)
+		}))
+		defer srv.Close()
+
+		config := test.Config
+		if config.WebIdentityTokenFile == "token" {
+			config.WebIdentityTokenFile = tokenFile
+		}
+		client := sts.New(sts.Options{
+			BaseEndpoint: aws.String(srv.URL),
+			Region:       "us-east-1",
+			Credentials:  credentials.NewStaticCredentialsProvider("access-key", "secret-key", ""),
+		})
+
+		provider := roleCredentials(&config, client)
+		if provider == nil {
+			if test.Action != "" {
+				t.Fatalf("Test %d: no role credentials provider", i)
+			}
+			continue
+		}
+		if test.Action == "" {
+			t.Fatalf("Test %d: role credentials provider for config without role", i)
+		}
+
+		creds, err := provider.Retrieve(context.Background())
+		if err != nil {
+			t.Fatalf("Test %d: failed to retrieve role credentials: %v", i, err)
+		}
+		if creds.AccessKeyID != "role-access-key" || creds.SessionToken != "role-session-token" {
+			t.Fatalf("Test %d: invalid role credentials: got '%s'", i, creds.AccessKeyID)
+		}
+
+		want := map[string]string{
+			"Action":           test.Action,
+			"RoleArn":          config.RoleARN,
+			"ExternalId":       config.ExternalID,
+			"WebIdentityToken": test.Token,
+		}
+		if test.SessionName != "" {
+			want["RoleSessionName"] = test.SessionName
+		}
+		mu.Lock()
+		for key, value := range want {
+			if got := strings.Join(params[key], ","); got != value {
+				t.Fatalf("Test %d: invalid STS parameter '%s': got '%s' - want '%s'", i, key, got, value)
+			}
+		}
+		mu.Unlock()
+	}
+}
+
+var roleCredentialsTests = []struct {
+	Config      Config
+	Action      string // Expected STS action, empty if no role is assumed
+	SessionName string // Expected session name, empty if generated by the SDK
+	Token       string // Expected web identity token
+}{
+	{ // 0
+		Config: Config{},
+	},
+	{ // 1
+		Config: Config{RoleARN: "arn:aws:iam::123456789012:role/kes"},
+		Action: "AssumeRole",
+	},
+	{ // 2
+		Config:      Config{RoleARN: "arn:aws:iam::123456789012:role/kes", ExternalID: "my-id", SessionName: "kes"},
+		Action:      "AssumeRole",
+		SessionName: "kes",
+	},
+	{ // 3
+		Config:      Config{RoleARN: "arn:aws:iam::123456789012:role/kes", WebIdentityTokenFile: "token", SessionName: "kes"},
+		Action:      "AssumeRoleWithWebIdentity",
+		SessionName: "kes",
+		Token:       "my-token",
+	},
+	{ // 4
+		Config: Config{WebIdentityTokenFile: "token"},
+	},
+}
+
+// mockSecretsManager is a minimal in-memory SecretsManager
+// implementing the JSON protocol.
+type mockSecretsManager struct {
+	mu       sync.Mutex
+	secrets  map[string]string
+	binary   map[string]bool // Secrets stored as SecretBinary
+	requests int
+	updates  []string            // Names of the operations that updated secrets
+	tags     map[string][]string // Tags of created secrets as "key=value"
+	replicas map[string][]string // Replicas of secrets as "region=kmskey"
+	denied   string              // Error code returned by ListSecrets, if not empty
+}
+
+func newMockSecretsManager() *mockSecretsManager {
+	return &mockSecretsManager{
+		secrets:  map[string]string{},
+		binary:   map[string]bool{},
+		tags:     map[string][]string{},
+		replicas: map[string][]string{},
+	}
+}
+
+func (m *mockSecretsManager) Requests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests
+}
+
+func (m *mockSecretsManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests++
+
+	var req struct {
+		Name         string
+		SecretId     string
+		SecretString string
+		SecretBinary []byte
+		KmsKeyId     string
+		SecretIdList []string
+		Tags         []struct{ Key, Value string }
+
+		AddReplicaRegions    []struct{ Region, KmsKeyId string }
+		RemoveReplicaRegions []string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fail := func(code string) {
+		w.Header().Set("X-Amzn-ErrorType", code)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"__type": code, "message": code})
+	}
+	switch r.Header.Get("X-Amz-Target") {
+	case "secretsmanager.ListSecrets":
+		if m.denied != "" {
+			fail(m.denied)
+			return
+		}
+		type secret struct{ Name string }
+		var resp struct{ SecretList []secret }
+		for _, name := range slices.Sorted(maps.Keys(m.secrets)) {
+			resp.SecretList = append(resp.SecretList, secret{Name: name})
+		}
+		json.NewEncoder(w).Encode(resp)
+	case "secretsmanager.CreateSecret":
+		if _, ok := m.secrets[req.Name]; ok {
+			fail("ResourceExistsException")
+			return
+		}
+		m.secrets[req.Name], m.binary[req.Name] = req.SecretString, req.SecretBinary != nil
+		if req.SecretBinary != nil {
+			m.secrets[req.Name] = string(req.SecretBinary)
+		}
+		for _, tag := range req.Tags {
+			m.tags[req.Name] = append(m.tags[req.Name], tag.Key+"="+tag.Value)
+		}
+		for _, replica := range req.AddReplicaRegions {
+			m.replicas[req.Name] = append(m.replicas[req.Name], replica.Region+"="+replica.KmsKeyId)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"Name": req.Name})
+	case "secretsmanager.DescribeSecret":
+		if _, ok := m.secrets[req.SecretId]; !ok {
+			fail("ResourceNotFoundException")
+			return
+		}
+		type status struct{ Region, Status string }
+		var resp struct {
+			Name              string
+			ReplicationStatus []status
+		}
+		resp.Name = req.SecretId
+		for _, replica := range m.replicas[req.SecretId] {
+			region, _, _ := strings.Cut(replica, "=")
+			resp.ReplicationStatus = append(resp.ReplicationStatus, status{Region: region, Status: "InSync"})
+		}
+		json.NewEncoder(w).Encode(resp)
+	case "secretsmanager.RemoveRegionsFromReplication":
+		if _, ok := m.secrets[req.SecretId]; !ok {
+			fail("ResourceNotFoundException")
+			return
+		}
+		m.replicas[req.SecretId] = slices.DeleteFunc(m.replicas[req.SecretId], func(replica string) bool {
+			region, _, _ := strings.Cut(replica, "=")
+			return slices.Contains(req.RemoveReplicaRegions, region)
+		})
+		json.NewEncoder(w).Encode(map[string]string{"ARN": req.SecretId})
+	case "secretsmanager.GetSecretValue":
+		value, ok := m.secrets[req.SecretId]
+		if !ok {
+			fail("ResourceNotFoundException")
+			return
+		}
+		if m.binary[req.SecretId] {
+			json.NewEncoder(w).Encode(map[string]any{"Name": req.SecretId, "SecretBinary": []byte(value)})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"Name": req.SecretId, "SecretString": value})
+	case "secretsmanager.DeleteSecret":
+		if _, ok := m.secrets[req.SecretId]; !ok {
+			fail("ResourceNotFoundException")
+			return
+		}
+		if len(m.replicas[req.SecretId]) > 0 { // Like SecretsManager, refuse to delete replicated secrets
+			fail("InvalidRequestException")
+			return
+		}
+		delete(m.secrets, req.SecretId)
+		json.NewEncoder(w).Encode(map[string]string{"Name": req.SecretId})
+	case "secretsmanager.PutSecretValue", "secretsmanager.UpdateSecret":
+		if _, ok := m.secrets[req.SecretId]; !ok {
+			fail("ResourceNotFoundException")
+			return
+		}
+		m.secrets[req.SecretId], m.binary[req.SecretId] = req.SecretString, req.SecretBinary != nil
+		if req.SecretBinary != nil {
+			m.secrets[req.SecretId] = string(req.SecretBinary)
+		}
+		m.updates = append(m.updates, strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "secretsmanager."))
+		json.NewEncoder(w).Encode(map[string]string{"Name": req.SecretId})
+	case "secretsmanager.BatchGetSecretValue":
+		if len(req.SecretIdList) > maxBatchGet {
+			fail("InvalidParameterException")
+			return
+		}
+		type (
+			secretValue struct {
+				Name         string
+				SecretString string `json:",omitempty"`
+				SecretBinary []byte `json:",omitempty"`
+			}
+			apiError struct{ SecretId, ErrorCode, Message string }
+		)
+		var resp struct {
+			SecretValues []secretValue
+			Errors       []apiError
+		}
+		for _, id := range req.SecretIdList {
+			value, ok := m.secrets[id]
+			if !ok {
+				resp.Errors = append(resp.Errors, apiError{SecretId: id, ErrorCode: "ResourceNotFoundException", Message: "secret not found"})
+				continue
+			}
+			if m.binary[id] {
+				resp.SecretValues = append(resp.SecretValues, secretValue{Name: id, SecretBinary: []byte(value)})
+			} else {
+				resp.SecretValues = append(resp.SecretValues, secretValue{Name: id, SecretString: value})
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	default:
+		http.Error(w, "unsupported operation", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package awskms implements a key-value store that
+// stores values on the filesystem encrypted with data
+// keys generated and wrapped by AWS KMS.
+//
+// It wraps a fs store. AWS KMS is the root of trust
+// but no secret is stored at AWS. Hence, there are no
+// per-secret costs, like with the AWS SecretsManager.
+package awskms
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kes/internal/keystore"
+	"github.com/minio/kes/internal/keystore/fs"
+)
+
+// Credentials represents static AWS credentials:
+// access key, secret key and a session token
+type Credentials struct {
+	AccessKey    string // The AWS access key
+	SecretKey    string // The AWS secret key
+	SessionToken string // The AWS session token
+}
+
+// Config is a structure containing configuration
+// options for connecting to AWS KMS.
+type Config struct {
+	// Dir is the directory that contains the
+	// encrypted keys.
+	Dir string
+
+	// Addr is the HTTP address of AWS KMS. It may be
+	// a custom endpoint, like a VPC endpoint, with a
+	// "http://" or "https://" scheme. If empty, the
+	// regional AWS endpoint is used.
+	Addr string
+
+	// Region is the AWS region.
+	Region string
+
+	// KeyID is the ID, ARN or alias of the customer-managed
+	// KMS key that wraps the data keys. It must be a symmetric
+	// encryption key.
+	KeyID string
+
+	// Login contains the AWS credentials (access/secret key).
+	// If empty, the default credential chain is used.
+	Login Credentials
+}
+
+// Connect returns a new Store that reads from and writes to
+// the configured directory, encrypting values with data keys
+// of the configured AWS KMS key.
+//
+// It returns an error if the KMS key is not accessible or
+// not enabled.
+func Connect(ctx context.Context, cfg *Config) (*Store, error) {
+	if cfg.Region == "" {
+		return nil, errors.New("awskms: no region specified")
+	}
+	if cfg.KeyID == "" {
+		return nil, errors.New("awskms: no KMS key specified")
+	}
+	fsStore, err := fs.NewStore(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []func(*config.LoadOptions) error{
+		config.WithRegion(cfg.Region),
+	}
+	if cfg.Login.AccessKey != "" || cfg.Login.SecretKey != "" || cfg.Login.SessionToken != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(
+				cfg.Login.AccessKey,
+				cfg.Login.SecretKey,
+				cfg.Login.SessionToken,
+			),
+		))
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		config:  *cfg,
+		fsStore: fsStore,
+		client: kms.NewFromConfig(awsCfg, func(o *kms.Options) {
+			if cfg.Addr != "" {
+				o.BaseEndpoint = aws.String(cfg.Addr)
+			}
+		}),
+	}
+	if _, err = s.Status(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Store is a connection to a directory on the filesystem
+// using AWS KMS data keys to encrypt the files.
+type Store struct {
+	config  Config
+	client  *kms.Client
+	fsStore *fs.Store
+}
+
+func (s *Store) String() string { return "AWS KMS: " + s.config.KeyID }
+
+// Status returns the current state of the AWS KMS key.
+// In particular, whether it is reachable, enabled and
+// the network latency.
+func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	if _, err := s.fsStore.Status(ctx); err != nil {
+		return kes.KeyStoreState{}, err
+	}
+
+	start := time.Now()
+	key, err := s.client.DescribeKey(ctx, &kms.DescribeKeyInput{
+		KeyId: aws.String(s.config.KeyID),
+	})
+	latency := time.Since(start)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+		}
+		return kes.KeyStoreState{}, fmt.Errorf("awskms: failed to describe key '%s': %v", s.config.KeyID, err)
+	}
+	if state := key.KeyMetadata.KeyState; state != types.KeyStateEnabled {
+		return kes.KeyStoreState{Latency: latency}, fmt.Errorf("awskms: key '%s' is not enabled: %s", s.config.KeyID, state)
+	}
+	return kes.KeyStoreState{Latency: latency}, nil
+}
+
+// Create encrypts the value with a new data key and creates
+// a new file with the given name inside the Store directory
+// if and only if no such file exists.
+//
+// It returns kes.ErrKeyExists if such a file already exists.
+func (s *Store) Create(ctx context.Context, name string, value []byte) error {
+	ciphertext, err := s.encrypt(ctx, name, value)
+	if err != nil {
+		return err
+	}
+	return s.fsStore.Create(ctx, name, ciphertext)
+}
+
+// Get reads the content of the named file within the Store
+// directory and decrypts it with its data key. It returns
+// kes.ErrKeyNotFound if no such file exists.
+//
+// It returns an error if the file content is not authentic,
+// for example, because the file has been modified or renamed.
+func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
+	ciphertext, err := s.fsStore.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.decrypt(ctx, name, ciphertext)
+}
+
+// Delete deletes the named file within the Store directory if
+// and only if it exists. It returns kes.ErrKeyNotFound if
+// no such file exists.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	return s.fsStore.Delete(ctx, name)
+}
+
+// List returns the first n key names, that start with the given
+// prefix, and the next prefix from which the listing should
+// continue.
+//
+// It returns all keys with the prefix if n < 0 and less than n
+// names if n is greater than the number of keys with the prefix.
+//
+// An empty prefix matches any key name. At the end of the listing
+// or when there are no (more) keys starting with the prefix, the
+// returned prefix is empty
+func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	return s.fsStore.List(ctx, prefix, n)
+}
+
+// Close closes the Store.
+func (s *Store) Close() error {
+	return s.fsStore.Close()
+}
+
+// headerMagic identifies values encrypted with an AWS KMS
+// data key. It is followed by the 2 byte length of the
+// wrapped data key and the wrapped data key itself.
+const headerMagic = "kes\x00awskms\x01"
+
+// encrypt generates a new data key, encrypts value with it
+// and returns the ciphertext prefixed with the header that
+// contains the wrapped data key. The key name is bound to
+// the data key via the KMS encryption context and to the
+// ciphertext as associated data.
+func (s *Store) encrypt(ctx context.Context, name string, value []byte) ([]byte, error) {
+	dataKey, err := s.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(s.config.KeyID),
+		KeySpec:           types.DataKeySpecAes256,
+		EncryptionContext: encryptionContext(name),
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("awskms: failed to generate data key for '%s': %v", name, err)
+	}
+	if len(dataKey.CiphertextBlob) > 1<<16-1 {
+		return nil, fmt.Errorf("awskms: failed to generate data key for '%s': wrapped data key is too large", name)
+	}
+
+	key, err := crypto.NewSecretKey(crypto.AES256, dataKey.Plaintext)
+	clear(dataKey.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(headerMagic)+2+len(dataKey.CiphertextBlob))
+	header = append(header, headerMagic...)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(dataKey.CiphertextBlob)))
+	header = append(header, dataKey.CiphertextBlob...)
+
+	ciphertext, err := key.Encrypt(value, associatedData(header, name))
+	if err != nil {
+		return nil, err
+	}
+	return append(header, ciphertext...), nil
+}
+
+// decrypt unwraps the data key of the ciphertext via AWS KMS
+// and decrypts the ciphertext of the named key.
+func (s *Store) decrypt(ctx context.Context, name string, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < len(headerMagic)+2 || string(ciphertext[:len(headerMagic)]) != headerMagic {
+		return nil, fmt.Errorf("awskms: key '%s' is not encrypted with an AWS KMS data key", name)
+	}
+	n := int(binary.BigEndian.Uint16(ciphertext[len(headerMagic):]))
+	headerSize := len(headerMagic) + 2 + n
+	if len(ciphertext) < headerSize {
+		return nil, fmt.Errorf("awskms: key '%s' is not authentic: invalid header", name)
+	}
+	header, ciphertext := ciphertext[:headerSize], ciphertext[headerSize:]
+
+	dataKey, err := s.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:             aws.String(s.config.KeyID),
+		CiphertextBlob:    header[len(headerMagic)+2:],
+		EncryptionContext: encryptionContext(name),
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		var ice *types.InvalidCiphertextException
+		if errors.As(err, &ice) {
+			return nil, fmt.Errorf("awskms: key '%s' is not authentic or has been renamed: %v", name, err)
+		}
+		return nil, fmt.Errorf("awskms: failed to decrypt data key of '%s': %v", name, err)
+	}
+
+	key, err := crypto.NewSecretKey(crypto.AES256, dataKey.Plaintext)
+	clear(dataKey.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+	value, err := key.Decrypt(ciphertext, associatedData(header, name))
+	if err != nil {
+		return nil, fmt.Errorf("awskms: key '%s' is not authentic or has been renamed: %v", name, err)
+	}
+	return value, nil
+}
+
+// encryptionContext returns the KMS encryption context
+// of the data key of the named key.
+func encryptionContext(name string) map[string]string {
+	return map[string]string{"kes:name": name}
+}
+
+// associatedData returns the associated data for
+// encrypting the named key.
+func associatedData(header []byte, name string) []byte {
+	ad := make([]byte, 0, len(header)+5+len(name))
+	ad = append(ad, header...)
+	ad = append(ad, "name="...)
+	return append(ad, name...)
+}
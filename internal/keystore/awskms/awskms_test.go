@@ -0,0 +1,201 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package awskms
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestStore(t *testing.T) {
+	mock := newMockKMS()
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, newConfig(t, srv.URL))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	value := []byte("my-secret-value")
+	if err = store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", value); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Created key twice: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, value, err)
+	}
+	if names, _, err := store.List(ctx, "", -1); err != nil || !slices.Equal(names, []string{"my-key"}) {
+		t.Fatalf("Failed to list keys: got '%v': %v", names, err)
+	}
+
+	ciphertext, err := store.fsStore.Get(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to read key file: %v", err)
+	}
+	if !bytes.HasPrefix(ciphertext, []byte(headerMagic)) || bytes.Contains(ciphertext, value) {
+		t.Fatalf("Key is not encrypted: '%x'", ciphertext)
+	}
+
+	// A renamed key cannot be decrypted since the data key
+	// is bound to the key name.
+	if err = store.fsStore.Create(ctx, "other-key", ciphertext); err != nil {
+		t.Fatalf("Failed to create key file: %v", err)
+	}
+	if _, err = store.Get(ctx, "other-key"); err == nil {
+		t.Fatal("Decrypted renamed key")
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if _, err = store.Get(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Deleted key still exists: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+}
+
+func TestStoreStatus(t *testing.T) {
+	mock := newMockKMS()
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, newConfig(t, srv.URL))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if _, err = store.Status(ctx); err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+
+	mock.setKeyState("Disabled")
+	if _, err = store.Status(ctx); err == nil {
+		t.Fatal("Status of disabled key should have failed")
+	}
+	if _, err = Connect(ctx, newConfig(t, srv.URL)); err == nil {
+		t.Fatal("Connecting with disabled key should have failed")
+	}
+}
+
+func TestConnectConfig(t *testing.T) {
+	ctx := context.Background()
+	for i, config := range []*Config{
+		{Dir: t.TempDir(), KeyID: "alias/kes"},   // 0: no region
+		{Dir: t.TempDir(), Region: "us-east-1"},  // 1: no key ID
+		{Dir: filepath.Join(os.DevNull, "keys")}, // 2: invalid directory
+	} {
+		if _, err := Connect(ctx, config); err == nil {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+	}
+}
+
+func newConfig(t *testing.T, endpoint string) *Config {
+	return &Config{
+		Dir:    t.TempDir(),
+		Addr:   endpoint,
+		Region: "us-east-1",
+		KeyID:  "alias/kes",
+		Login:  Credentials{AccessKey: "access-key", SecretKey: "secret-key"},
+	}
+}
+
+// mockKMS is a minimal in-memory AWS KMS implementing the
+// JSON protocol. Wrapped data keys are references to data
+// keys kept in memory along with their encryption context.
+type mockKMS struct {
+	mu       sync.Mutex
+	state    string
+	dataKeys map[string]mockDataKey
+}
+
+type mockDataKey struct {
+	Plaintext []byte
+	Context   map[string]string
+}
+
+func newMockKMS() *mockKMS {
+	return &mockKMS{
+		state:    "Enabled",
+		dataKeys: map[string]mockDataKey{},
+	}
+}
+
+func (m *mockKMS) setKeyState(state string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = state
+}
+
+func (m *mockKMS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var req struct {
+		KeyId             string
+		KeySpec           string
+		CiphertextBlob    []byte
+		EncryptionContext map[string]string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fail := func(code string) {
+		w.Header().Set("X-Amzn-ErrorType", code)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"__type": code, "message": code})
+	}
+	switch r.Header.Get("X-Amz-Target") {
+	case "TrentService.DescribeKey":
+		json.NewEncoder(w).Encode(map[string]any{
+			"KeyMetadata": map[string]string{"KeyId": req.KeyId, "KeyState": m.state},
+		})
+	case "TrentService.GenerateDataKey":
+		if m.state != "Enabled" {
+			fail("DisabledException")
+			return
+		}
+		key := make([]byte, 32)
+		rand.Read(key)
+		id := strconv.Itoa(len(m.dataKeys))
+		m.dataKeys[id] = mockDataKey{Plaintext: key, Context: req.EncryptionContext}
+		json.NewEncoder(w).Encode(map[string]any{
+			"KeyId":          req.KeyId,
+			"Plaintext":      key,
+			"CiphertextBlob": []byte(id),
+		})
+	case "TrentService.Decrypt":
+		dataKey, ok := m.dataKeys[string(req.CiphertextBlob)]
+		if !ok || !maps.Equal(dataKey.Context, req.EncryptionContext) {
+			fail("InvalidCiphertextException")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"KeyId":     req.KeyId,
+			"Plaintext": bytes.Clone(dataKey.Plaintext),
+		})
+	default:
+		http.Error(w, "unsupported operation", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// WorkloadIdentity is an Azure workload identity, for example
+// of an AKS pod. The workload exchanges a Kubernetes service
+// account token, issued by the cluster's OIDC issuer, for an
+// Entra ID access token. The application, or user-assigned
+// managed identity, must have a federated identity credential
+// matching the token's issuer and subject.
+//
+// On AKS, the workload identity webhook sets the environment
+// variables AZURE_TENANT_ID, AZURE_CLIENT_ID and
+// AZURE_FEDERATED_TOKEN_FILE. They are used if the
+// corresponding fields are empty.
+type WorkloadIdentity struct {
+	TenantID  string // The ID of the Azure tenant
+	ClientID  string // The client ID of the application or managed identity
+	TokenFile string // Path to the Kubernetes service account token
+
+	// Cloud is the Azure cloud of the tenant. If its authority
+	// host is empty, the AZURE_AUTHORITY_HOST environment variable,
+	// set by the AKS workload identity webhook, or the Azure public
+	// cloud is used.
+	Cloud Cloud
+}
+
+// federatedTokenAudience is the audience that service account
+// tokens must have to be exchanged for Entra ID access tokens.
+const federatedTokenAudience = "api://AzureADTokenExchange"
+
+// NewWorkloadIdentityCredential returns a credential for the given
+// workload identity. It verifies that the service account token
+// exists, has not expired and has the audience expected by Entra ID.
+//
+// The returned credential refreshes access tokens before they
+// expire and re-reads the service account token file, which is
+// rotated by the kubelet. Authentication errors caused by a
+// misconfigured federation contain a hint about the likely cause.
+func NewWorkloadIdentityCredential(w WorkloadIdentity) (azcore.TokenCredential, error) {
+	const Hint = "is the pod labeled with 'azure.workload.identity/use: \"true\"' and is its service account annotated with the client ID?"
+	if w.TenantID == "" {
+		if w.TenantID = os.Getenv("AZURE_TENANT_ID"); w.TenantID == "" {
+			return nil, fmt.Errorf("azure: invalid workload identity: no tenant ID specified and AZURE_TENANT_ID is not set: %s", Hint)
+		}
+	}
+	if w.ClientID == "" {
+		if w.ClientID = os.Getenv("AZURE_CLIENT_ID"); w.ClientID == "" {
+			return nil, fmt.Errorf("azure: invalid workload identity: no client ID specified and AZURE_CLIENT_ID is not set: %s", Hint)
+		}
+	}
+	if w.TokenFile == "" {
+		if w.TokenFile = os.Getenv("AZURE_FEDERATED_TOKEN_FILE"); w.TokenFile == "" {
+			return nil, fmt.Errorf("azure: invalid workload identity: no token file specified and AZURE_FEDERATED_TOKEN_FILE is not set: %s", Hint)
+		}
+	}
+	if err := checkFederatedToken(w.TokenFile, time.Now()); err != nil {
+		return nil, err
+	}
+
+	cred, err := azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+		TenantID:      w.TenantID,
+		ClientID:      w.ClientID,
+		TokenFilePath: w.TokenFile,
+		ClientOptions: w.Cloud.ClientOptions(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("azure: invalid workload identity: %v", err)
+	}
+	return &federatedCredential{cred: cred}, nil
+}
+
+// ClientCertificate is an Entra ID application that authenticates
+// with a client certificate instead of a client secret.
+type ClientCertificate struct {
+	TenantID string // The ID of the Azure tenant
+	ClientID string // The client ID of the application
+
+	// File is the path to a PEM or PKCS#12 (pfx) file containing
+	// the certificate and its unencrypted RSA private key. A
+	// PKCS#12 file may be protected by the Password.
+	File     string
+	Password string
+
+	// SendChain controls whether the certificate chain is sent
+	// to Entra ID. It is required for subject name and issuer
+	// authentication.
+	SendChain bool
+
+	// Cloud is the Azure cloud of the tenant. If its authority
+	// host is empty, the AZURE_AUTHORITY_HOST environment variable
+	// or the Azure public cloud is used.
+	Cloud Cloud
+}
+
+// NewClientCertificateCredential returns a credential for the given
+// application client certificate. It returns an error if the
+// certificate file cannot be parsed or the certificate has expired.
+//
+// The returned credential refreshes access tokens before they
+// expire.
+func NewClientCertificateCredential(c ClientCertificate) (azcore.TokenCredential, error) {
+	if c.TenantID == "" {
+		return nil, errors.New("azure: invalid client certificate: no tenant ID specified")
+	}
+	if c.ClientID == "" {
+		return nil, errors.New("azure: invalid client certificate: no client ID specified")
+	}
+	if c.File == "" {
+		return nil, errors.New("azure: invalid client certificate: no certificate file specified")
+	}
+
+	b, err := os.ReadFile(c.File)
+	if err != nil {
+		return nil, fmt.Errorf("azure: invalid client certificate: %v", err)
+	}
+	var password []byte
+	if c.Password != "" {
+		password = []byte(c.Password)
+	}
+	certs, key, err := azidentity.ParseCertificates(b, password)
+	if err != nil {
+		return nil, fmt.Errorf("azure: invalid client certificate '%s': %v", c.File, err)
+	}
+	if now := time.Now(); now.After(certs[0].NotAfter) {
+		return nil, fmt.Errorf("azure: invalid client certificate '%s': certificate expired at %s", c.File, certs[0].NotAfter.UTC().Format(time.RFC3339))
+	}
+
+	cred, err := azidentity.NewClientCertificateCredential(c.TenantID, c.ClientID, certs, key, &azidentity.ClientCertificateCredentialOptions{
+		ClientOptions:        c.Cloud.ClientOptions(),
+		SendCertificateChain: c.SendChain,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("azure: invalid client certificate: %v", err)
+	}
+	return cred, nil
+}
+
+// NewManagedIdentityCredential returns a credential for the given
+// managed identity. If neither a client ID nor a resource ID is
+// specified, it returns a credential for the system-assigned
+// managed identity of the Azure VM, App Service or container.
+//
+// The returned credential refreshes access tokens before they
+// expire.
+func NewManagedIdentityCredential(m ManagedIdentity) (azcore.TokenCredential, error) {
+	if m.ClientID != "" && m.ResourceID != "" {
+		return nil, errors.New("azure: invalid managed identity: client ID and resource ID must not both be specified")
+	}
+
+	var id azidentity.ManagedIDKind
+	switch {
+	case m.ClientID != "":
+		id = azidentity.ClientID(m.ClientID)
+	case m.ResourceID != "":
+		id = azidentity.ResourceID(m.ResourceID)
+	}
+	cred, err := azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+		ID: id,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("azure: invalid managed identity: %v", err)
+	}
+	return cred, nil
+}
+
+// VerifyCredential fetches an access token for the KeyVault at
+// endpoint. It returns an error if cred cannot authenticate, for
+// example due to a misconfigured managed or workload identity.
+// Otherwise, such errors would only surface on the first request.
+func VerifyCredential(ctx context.Context, endpoint string, cred azcore.TokenCredential) error {
+	scope, err := keyVaultScope(endpoint)
+	if err != nil {
+		return err
+	}
+	if _, err = cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{scope}}); err != nil {
+		return fmt.Errorf("azure: failed to authenticate to '%s': %w", endpoint, err)
+	}
+	return nil
+}
+
+// keyVaultScope returns the OAuth2 scope for KeyVault endpoint.
+// For example, "https://vault.azure.net/.default" for the endpoint
+// "https://my-vault.vault.azure.net".
+func keyVaultScope(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("azure: invalid KeyVault endpoint '%s': %v", endpoint, err)
+	}
+	_, domain, ok := strings.Cut(u.Hostname(), ".")
+	if !ok || domain == "" {
+		return "", fmt.Errorf("azure: invalid KeyVault endpoint '%s': expected '<name>.<keyvault domain>'", endpoint)
+	}
+	return "https://" + domain + "/.default", nil
+}
+
+// checkFederatedToken checks that the service account token file
+// contains a JWT that is valid at now and whose audience is the
+// Entra ID token exchange. It does not verify the JWT signature.
+func checkFederatedToken(filename string, now time.Time) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("azure: invalid workload identity: failed to read service account token: %v", err)
+	}
+
+	parts := strings.Split(strings.TrimSpace(string(b)), ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("azure: invalid workload identity: service account token '%s' is not a JWT", filename)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("azure: invalid workload identity: service account token '%s' is not a JWT", filename)
+	}
+
+	var claims struct {
+		Subject  string          `json:"sub"`
+		Audience json.RawMessage `json:"aud"`
+		Expiry   int64           `json:"exp"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return fmt.Errorf("azure: invalid workload identity: service account token '%s' is not a JWT", filename)
+	}
+
+	var audience []string
+	if err = json.Unmarshal(claims.Audience, &audience); err != nil {
+		var aud string
+		if err = json.Unmarshal(claims.Audience, &aud); err != nil {
+			return fmt.Errorf("azure: invalid workload identity: service account token '%s' has no audience", filename)
+		}
+		audience = []string{aud}
+	}
+	if !slices.Contains(audience, federatedTokenAudience) {
+		return fmt.Errorf("azure: invalid workload identity: service account token of '%s' has audience '%s' - expected '%s'", claims.Subject, strings.Join(audience, ","), federatedTokenAudience)
+	}
+	if claims.Expiry > 0 && now.After(time.Unix(claims.Expiry, 0)) {
+		return fmt.Errorf("azure: invalid workload identity: service account token of '%s' expired at %s: is the token file projected and rotated by the kubelet?", claims.Subject, time.Unix(claims.Expiry, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// federatedCredential wraps a workload identity credential and
+// explains token exchange errors caused by misconfigured
+// federated identity credentials.
+type federatedCredential struct {
+	cred azcore.TokenCredential
+}
+
+func (c *federatedCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	token, err := c.cred.GetToken(ctx, opts)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return token, err
+		}
+		if hint := federationHint(err); hint != "" {
+			return token, fmt.Errorf("azure: workload identity authentication failed: %s: %w", hint, err)
+		}
+		return token, fmt.Errorf("azure: workload identity authentication failed: %w", err)
+	}
+	return token, nil
+}
+
+// federationErrors maps Entra ID error codes of failed token
+// exchanges to hints about misconfigured federations.
+var federationErrors = []struct {
+	Code string
+	Hint string
+}{
+	{Code: "AADSTS700211", Hint: "the issuer of the federated credential does not match the cluster's OIDC issuer URL"},
+	{Code: "AADSTS700212", Hint: "the audience of the federated credential is not '" + federatedTokenAudience + "'"},
+	{Code: "AADSTS700213", Hint: "the subject of the federated credential does not match 'system:serviceaccount:<namespace>:<service-account>'"},
+	{Code: "AADSTS70021", Hint: "no federated credential matches the issuer and subject of the service account token"},
+	{Code: "AADSTS700024", Hint: "the service account token has expired or is not yet valid"},
+	{Code: "AADSTS700016", Hint: "the client ID does not exist in the tenant"},
+	{Code: "AADSTS90002", Hint: "the tenant does not exist"},
+}
+
+// federationHint returns a hint for the token exchange error,
+// or the empty string if the error is not caused by a known
+// federation misconfiguration.
+func federationHint(err error) string {
+	msg := err.Error()
+	for _, e := range federationErrors {
+		if strings.Contains(msg, e.Code+":") {
+			return e.Hint
+		}
+	}
+	return ""
+}
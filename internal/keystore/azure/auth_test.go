@@ -0,0 +1,189 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCheckFederatedToken(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	for i, test := range checkFederatedTokenTests {
+		filename := filepath.Join(dir, "token")
+		if err := os.WriteFile(filename, []byte(test.Token), 0o600); err != nil {
+			t.Fatalf("Test %d: failed to write token file: %v", i, err)
+		}
+
+		err := checkFederatedToken(filename, now)
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to check token: %v", i, err)
+		}
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: token check should have failed", i)
+		}
+	}
+
+	if err := checkFederatedToken(filepath.Join(dir, "does-not-exist"), now); err == nil {
+		t.Fatal("Token check of non-existing file should have failed")
+	}
+}
+
+func TestNewWorkloadIdentityCredential(t *testing.T) {
+	t.Setenv("AZURE_TENANT_ID", "")
+	t.Setenv("AZURE_CLIENT_ID", "")
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", "")
+
+	if _, err := NewWorkloadIdentityCredential(WorkloadIdentity{}); err == nil {
+		t.Fatal("Created workload identity credential without tenant ID")
+	}
+
+	filename := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(filename, []byte(jwt(`{"aud":["api://AzureADTokenExchange"],"sub":"system:serviceaccount:kes:kes"}`)), 0o600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	t.Setenv("AZURE_TENANT_ID", "00000000-0000-0000-0000-000000000000")
+	t.Setenv("AZURE_CLIENT_ID", "00000000-0000-0000-0000-000000000001")
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", filename)
+	if _, err := NewWorkloadIdentityCredential(WorkloadIdentity{}); err != nil {
+		t.Fatalf("Failed to create workload identity credential: %v", err)
+	}
+}
+
+func TestNewClientCertificateCredential(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "client.pem")
+	writeCertificate(t, valid, time.Now().Add(time.Hour))
+	expired := filepath.Join(dir, "expired.pem")
+	writeCertificate(t, expired, time.Now().Add(-time.Hour))
+
+	const (
+		TenantID = "00000000-0000-0000-0000-000000000000"
+		ClientID = "00000000-0000-0000-0000-000000000001"
+	)
+	for i, test := range []struct {
+		Cert       ClientCertificate
+		ShouldFail bool
+	}{
+		{Cert: ClientCertificate{TenantID: TenantID, ClientID: ClientID, File: valid}},                                  // 0
+		{Cert: ClientCertificate{TenantID: TenantID, ClientID: ClientID, File: valid, SendChain: true}},                 // 1
+		{Cert: ClientCertificate{ClientID: ClientID, File: valid}, ShouldFail: true},                                    // 2: no tenant ID
+		{Cert: ClientCertificate{TenantID: TenantID, File: valid}, ShouldFail: true},                                    // 3: no client ID
+		{Cert: ClientCertificate{TenantID: TenantID, ClientID: ClientID}, ShouldFail: true},                             // 4: no file
+		{Cert: ClientCertificate{TenantID: TenantID, ClientID: ClientID, File: expired}, ShouldFail: true},              // 5: expired
+		{Cert: ClientCertificate{TenantID: TenantID, ClientID: ClientID, File: dir + "/missing.pem"}, ShouldFail: true}, // 6: missing file
+	} {
+		_, err := NewClientCertificateCredential(test.Cert)
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to create client certificate credential: %v", i, err)
+		}
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: creating client certificate credential should have failed", i)
+		}
+	}
+}
+
+// writeCertificate writes a self-signed certificate, that
+// expires at notAfter, and its private key to filename.
+func writeCertificate(t *testing.T, filename string, notAfter time.Time) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "kes"},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	privKey, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to encode private key: %v", err)
+	}
+
+	b := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+	b = append(b, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privKey})...)
+	if err = os.WriteFile(filename, b, 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+}
+
+func TestKeyVaultScope(t *testing.T) {
+	for i, test := range keyVaultScopeTests {
+		scope, err := keyVaultScope(test.Endpoint)
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to determine scope: %v", i, err)
+		}
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: determining scope should have failed", i)
+		}
+		if scope != test.Scope {
+			t.Fatalf("Test %d: scope mismatch: got '%s' - want '%s'", i, scope, test.Scope)
+		}
+	}
+}
+
+func TestFederationHint(t *testing.T) {
+	err := errors.New("WorkloadIdentityCredential authentication failed. AADSTS70021: No matching federated identity record found for presented assertion.")
+	if hint := federationHint(err); hint != federationErrors[3].Hint {
+		t.Fatalf("Hint mismatch: got '%s' - want '%s'", hint, federationErrors[3].Hint)
+	}
+	err = errors.New("AADSTS700213: No matching federated identity record found for presented assertion subject.")
+	if hint := federationHint(err); hint != federationErrors[2].Hint {
+		t.Fatalf("Hint mismatch: got '%s' - want '%s'", hint, federationErrors[2].Hint)
+	}
+	if hint := federationHint(errors.New("connection refused")); hint != "" {
+		t.Fatalf("Unexpected hint: %s", hint)
+	}
+}
+
+func jwt(claims string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"RS256"}`)) + "." + enc.EncodeToString([]byte(claims)) + ".c2ln"
+}
+
+var checkFederatedTokenTests = []struct {
+	Token      string
+	ShouldFail bool
+}{
+	{Token: jwt(`{"aud":["api://AzureADTokenExchange"],"sub":"system:serviceaccount:kes:kes"}`)},                            // 0
+	{Token: jwt(`{"aud":"api://AzureADTokenExchange","sub":"system:serviceaccount:kes:kes"}`) + "\n"},                       // 1
+	{Token: jwt(`{"aud":["api://AzureADTokenExchange"],"exp":` + unix(time.Now().Add(time.Hour)) + `}`)},                    // 2
+	{Token: jwt(`{"aud":["api://AzureADTokenExchange"],"exp":` + unix(time.Now().Add(-time.Hour)) + `}`), ShouldFail: true}, // 3
+	{Token: jwt(`{"aud":["https://kubernetes.default.svc"],"sub":"system:serviceaccount:kes:kes"}`), ShouldFail: true},      // 4
+	{Token: jwt(`{"sub":"system:serviceaccount:kes:kes"}`), ShouldFail: true},                                               // 5
+	{Token: "not-a-jwt", ShouldFail: true}, // 6
+	{Token: "a.!!!.c", ShouldFail: true},   // 7
+}
+
+var keyVaultScopeTests = []struct {
+	Endpoint   string
+	Scope      string
+	ShouldFail bool
+}{
+	{Endpoint: "https://my-vault.vault.azure.net", Scope: "https://vault.azure.net/.default"},         // 0
+	{Endpoint: "https://my-vault.vault.azure.net/", Scope: "https://vault.azure.net/.default"},        // 1
+	{Endpoint: "https://my-vault.vault.azure.cn:443", Scope: "https://vault.azure.cn/.default"},       // 2
+	{Endpoint: "https://my-hsm.managedhsm.azure.net", Scope: "https://managedhsm.azure.net/.default"}, // 3
+	{Endpoint: "https://localhost", ShouldFail: true},                                                 // 4
+}
+
+func unix(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) }
@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
@@ -145,6 +146,65 @@ func (c *client) PurgeSecret(ctx context.Context, name string) (status, error) {
 	}, nil
 }
 
+// deletedSecret describes a (soft) deleted KeyVault secret.
+type deletedSecret struct {
+	DeletedAt      time.Time // Zero, if unknown
+	ScheduledPurge time.Time // Zero, if unknown
+	RecoveryLevel  string    // For example, "Recoverable+Purgeable"
+}
+
+// Purgeable reports whether the deleted secret can be purged
+// before its scheduled purge date. If purge protection is
+// enabled for the KeyVault, only KeyVault itself can purge
+// the secret once the retention period has passed.
+func (d *deletedSecret) Purgeable() bool {
+	return d.RecoveryLevel == "" || strings.Contains(d.RecoveryLevel, "Purgeable")
+}
+
+// GetDeletedSecret returns the (soft) deleted secret with the
+// given name. It returns a status with an HTTP 404 NotFound
+// status code if no such deleted secret exists.
+func (c *client) GetDeletedSecret(ctx context.Context, name string) (deletedSecret, status, error) {
+	response, err := c.azsecretsClient.GetDeletedSecret(ctx, name, nil)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return deletedSecret{}, status{}, err
+	}
+	if err != nil {
+		stat, err := transportErrToStatus(err)
+		return deletedSecret{}, stat, err
+	}
+
+	var secret deletedSecret
+	if response.DeletedDate != nil {
+		secret.DeletedAt = *response.DeletedDate
+	}
+	if response.ScheduledPurgeDate != nil {
+		secret.ScheduledPurge = *response.ScheduledPurgeDate
+	}
+	if response.Attributes != nil && response.Attributes.RecoveryLevel != nil {
+		secret.RecoveryLevel = *response.Attributes.RecoveryLevel
+	}
+	return secret, status{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+// RecoverSecret recovers the (soft) deleted secret with the
+// given name. It cannot be used to recover a purged secret.
+//
+// KeyVault recovers secrets in the background. Hence, the
+// secret may not be accessible immediately even if KeyVault
+// returns 200 OK.
+func (c *client) RecoverSecret(ctx context.Context, name string) (status, error) {
+	_, err := c.azsecretsClient.RecoverDeletedSecret(ctx, name, nil)
+	if err != nil {
+		return transportErrToStatus(err)
+	}
+	return status{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
 // GetFirstVersion returns the first version of a secret
 // based on its created_at timestamp.
 //
@@ -0,0 +1,132 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+)
+
+// Cloud is an Azure cloud environment, like the Azure public
+// cloud or a sovereign cloud, like Azure US Government.
+//
+// Each cloud has its own Microsoft Entra ID authority host
+// and KeyVault domains.
+type Cloud struct {
+	// Name is the name of the cloud, like "AzureUSGovernment".
+	// It is empty for custom clouds.
+	Name string
+
+	// AuthorityHost is the Microsoft Entra ID authority
+	// host, like "https://login.microsoftonline.us/".
+	// If empty, the credential's default is used.
+	AuthorityHost string
+
+	// KeyVaultDomain and ManagedHSMDomain are the DNS domains
+	// of KeyVault and Managed HSM endpoints. If empty, the
+	// endpoint domain is not checked.
+	KeyVaultDomain   string
+	ManagedHSMDomain string
+}
+
+var (
+	// AzurePublic is the Azure public cloud.
+	AzurePublic = Cloud{
+		Name:             "AzurePublic",
+		AuthorityHost:    cloud.AzurePublic.ActiveDirectoryAuthorityHost,
+		KeyVaultDomain:   "vault.azure.net",
+		ManagedHSMDomain: "managedhsm.azure.net",
+	}
+
+	// AzureUSGovernment is the Azure US Government cloud.
+	AzureUSGovernment = Cloud{
+		Name:             "AzureUSGovernment",
+		AuthorityHost:    cloud.AzureGovernment.ActiveDirectoryAuthorityHost,
+		KeyVaultDomain:   "vault.usgovcloudapi.net",
+		ManagedHSMDomain: "managedhsm.usgovcloudapi.net",
+	}
+
+	// AzureChina is the Azure China cloud operated by 21Vianet.
+	AzureChina = Cloud{
+		Name:             "AzureChina",
+		AuthorityHost:    cloud.AzureChina.ActiveDirectoryAuthorityHost,
+		KeyVaultDomain:   "vault.azure.cn",
+		ManagedHSMDomain: "managedhsm.azure.cn",
+	}
+)
+
+// clouds contains all known Azure clouds.
+var clouds = []Cloud{AzurePublic, AzureUSGovernment, AzureChina}
+
+// LookupCloud returns the cloud with the given name. Names are
+// case-insensitive and may have a "Cloud" suffix. For example,
+// "AzureUSGovernment" and "AzureUSGovernmentCloud" refer to the
+// same cloud.
+//
+// If authorityHost is not empty, it replaces the cloud's authority
+// host. If name is empty, LookupCloud returns a custom cloud with
+// the given authority host.
+func LookupCloud(name, authorityHost string) (Cloud, error) {
+	if authorityHost != "" {
+		u, err := url.Parse(authorityHost)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return Cloud{}, fmt.Errorf("azure: invalid authority host '%s': expected 'https://<host>'", authorityHost)
+		}
+	}
+	if name == "" {
+		return Cloud{AuthorityHost: authorityHost}, nil
+	}
+
+	trimmed := strings.TrimSuffix(strings.ToLower(name), "cloud")
+	for _, c := range clouds {
+		if strings.ToLower(c.Name) == trimmed {
+			if authorityHost != "" {
+				c.AuthorityHost = authorityHost
+			}
+			return c, nil
+		}
+	}
+	return Cloud{}, fmt.Errorf("azure: unknown cloud '%s': expected '%s', '%s' or '%s'", name, AzurePublic.Name, AzureUSGovernment.Name, AzureChina.Name)
+}
+
+// ClientOptions returns the client options for Azure SDK
+// credentials that authenticate to the cloud's authority host.
+//
+// If the cloud has no authority host, the options are empty.
+// Hence, credentials fall back to the AZURE_AUTHORITY_HOST
+// environment variable or the Azure public cloud.
+func (c Cloud) ClientOptions() azcore.ClientOptions {
+	if c.AuthorityHost == "" {
+		return azcore.ClientOptions{}
+	}
+	return azcore.ClientOptions{
+		Cloud: cloud.Configuration{
+			ActiveDirectoryAuthorityHost: c.AuthorityHost,
+		},
+	}
+}
+
+// CheckEndpoint returns an error if the KeyVault or Managed HSM
+// endpoint does not belong to the cloud. Endpoints of custom
+// clouds are not checked.
+func (c Cloud) CheckEndpoint(endpoint string) error {
+	if c.KeyVaultDomain == "" && c.ManagedHSMDomain == "" {
+		return nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("azure: invalid KeyVault endpoint '%s': %v", endpoint, err)
+	}
+	host := strings.ToLower(u.Hostname())
+	if strings.HasSuffix(host, "."+c.KeyVaultDomain) || strings.HasSuffix(host, "."+c.ManagedHSMDomain) {
+		return nil
+	}
+	return fmt.Errorf("azure: KeyVault endpoint '%s' does not belong to the %s cloud: expected '<name>.%s' or '<name>.%s'", endpoint, c.Name, c.KeyVaultDomain, c.ManagedHSMDomain)
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"testing"
+)
+
+func TestLookupCloud(t *testing.T) {
+	for i, test := range lookupCloudTests {
+		c, err := LookupCloud(test.Name, test.AuthorityHost)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: lookup should have failed", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to lookup cloud: %v", i, err)
+		}
+		if err == nil && c != test.Cloud {
+			t.Fatalf("Test %d: got '%+v' - want '%+v'", i, c, test.Cloud)
+		}
+	}
+}
+
+var lookupCloudTests = []struct {
+	Name          string
+	AuthorityHost string
+	Cloud         Cloud
+	ShouldFail    bool
+}{
+	{Name: "", Cloud: Cloud{}},                                                                               // 0
+	{Name: "AzurePublic", Cloud: AzurePublic},                                                                // 1
+	{Name: "AzureUSGovernment", Cloud: AzureUSGovernment},                                                    // 2
+	{Name: "azureusgovernmentcloud", Cloud: AzureUSGovernment},                                               // 3
+	{Name: "AzureChinaCloud", Cloud: AzureChina},                                                             // 4
+	{Name: "AzureGermany", ShouldFail: true},                                                                 // 5
+	{AuthorityHost: "http://login.example.com", ShouldFail: true},                                            // 6
+	{AuthorityHost: "https://login.example.com/", Cloud: Cloud{AuthorityHost: "https://login.example.com/"}}, // 7
+	{ // 8
+		Name:          "AzureUSGovernment",
+		AuthorityHost: "https://login.example.com/",
+		Cloud: Cloud{
+			Name:             AzureUSGovernment.Name,
+			AuthorityHost:    "https://login.example.com/",
+			KeyVaultDomain:   AzureUSGovernment.KeyVaultDomain,
+			ManagedHSMDomain: AzureUSGovernment.ManagedHSMDomain,
+		},
+	},
+}
+
+func TestCloudCheckEndpoint(t *testing.T) {
+	for i, test := range checkEndpointTests {
+		err := test.Cloud.CheckEndpoint(test.Endpoint)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: check should have failed for '%s'", i, test.Endpoint)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: check failed: %v", i, err)
+		}
+	}
+}
+
+var checkEndpointTests = []struct {
+	Cloud      Cloud
+	Endpoint   string
+	ShouldFail bool
+}{
+	{Cloud: AzurePublic, Endpoint: "https://my-vault.vault.azure.net"},                           // 0
+	{Cloud: AzurePublic, Endpoint: "https://my-hsm.managedhsm.azure.net"},                        // 1
+	{Cloud: AzurePublic, Endpoint: "https://my-vault.vault.usgovcloudapi.net", ShouldFail: true}, // 2
+	{Cloud: AzureUSGovernment, Endpoint: "https://my-vault.vault.usgovcloudapi.net"},             // 3
+	{Cloud: AzureUSGovernment, Endpoint: "https://my-vault.vault.azure.net", ShouldFail: true},   // 4
+	{Cloud: AzureChina, Endpoint: "https://MY-VAULT.vault.azure.cn/"},                            // 5
+	{Cloud: Cloud{}, Endpoint: "https://my-vault.vault.example.com"},                             // 6
+}
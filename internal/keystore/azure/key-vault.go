@@ -33,13 +33,26 @@ type Credentials struct {
 //
 // It allows applications running inside Azure to authenticate
 // to Azure services via a managed identity object containing
-// the access credentials.
+// the access credentials. A user-assigned managed identity is
+// identified by either its client ID or its resource ID. If
+// both are empty, the system-assigned managed identity is used.
 type ManagedIdentity struct {
-	ClientID string // The Azure managed identity client ID
+	ClientID   string // The Azure managed identity client ID
+	ResourceID string // The Azure managed identity resource ID
 }
 
 // Store is an Azure KeyVault secret store.
 type Store struct {
+	// RecoverDeleted controls whether Create recovers a (soft)
+	// deleted secret with the same name instead of purging it.
+	// If set, Create returns kes.ErrKeyExists once the deleted
+	// secret has been recovered.
+	//
+	// Recovering deleted secrets is required when the KeyVault
+	// has purge protection enabled since deleted secrets cannot
+	// be purged before the end of the retention period.
+	RecoverDeleted bool
+
 	endpoint string
 	client   client
 }
@@ -82,7 +95,8 @@ func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
 // name exists, and if it does, returns kes.ErrKeyExists.
 //
 // Further, a secret may not exist but may be in a soft delete
-// state. In this case, Create tries to purge the deleted
+// state. In this case, Create either recovers the deleted secret,
+// if RecoverDeleted is set, or tries to purge the deleted
 // secret and then tries to create it. However, KeyVault
 // purges deleted secrets in the background such that
 // an incoming create fails with HTTP 409 Conflict. Therefore,
@@ -90,6 +104,9 @@ func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
 // purging but will eventually give up and fail. However,
 // a subsequent create may succeed once KeyVault has purged
 // the secret completely.
+//
+// If the deleted secret cannot be purged because the KeyVault
+// has purge protection enabled, Create returns a *DeletedError.
 func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 	_, stat, err := s.client.GetSecret(ctx, name, "")
 	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
@@ -114,6 +131,12 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 	if err != nil {
 		return fmt.Errorf("azure: failed to create '%s': %v", name, err)
 	}
+	if stat.StatusCode == http.StatusConflict && stat.ErrorCode == "ObjectIsDeletedButRecoverable" && s.RecoverDeleted {
+		if err = s.Recover(ctx, name); err != nil {
+			return err
+		}
+		return kesdk.ErrKeyExists
+	}
 	if stat.StatusCode == http.StatusConflict && (stat.ErrorCode == "ObjectIsDeletedButRecoverable" || stat.ErrorCode == "ObjectIsBeingDeleted") {
 		stat, err = s.purgeWithRetry(ctx, name, 25)
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
@@ -122,6 +145,9 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 		if err != nil {
 			return fmt.Errorf("azure: failed to create '%s': failed to purge deleted secret: %v", name, err)
 		}
+		if stat.StatusCode == http.StatusForbidden {
+			return s.purgeForbidden(ctx, name, "create", stat)
+		}
 		if stat.StatusCode != http.StatusOK {
 			return fmt.Errorf("azure: failed to create '%s': failed to purge deleted secret: %s (%s)", name, stat.Message, stat.ErrorCode)
 		}
@@ -145,7 +171,7 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 	case stat.StatusCode == http.StatusOK:
 		return nil
 	case stat.StatusCode == http.StatusConflict && stat.ErrorCode == "ObjectIsDeletedButRecoverable":
-		return fmt.Errorf("azure: failed to create '%s': key already exists but is currently marked as deleted. Either restore or purge '%s'", name, name)
+		return &DeletedError{Name: name}
 	case stat.StatusCode == http.StatusForbidden && stat.ErrorCode == "ForbiddenByPolicy":
 		return fmt.Errorf("azure: failed to create '%s': insufficient permissions: %s", name, stat.Message)
 	default:
@@ -190,6 +216,10 @@ func (s *Store) Set(ctx context.Context, name string, value []byte) error {
 // and purge the secret. Further, a subsequent Create operation
 // will also try to purge the secret.
 //
+// If the KeyVault has purge protection enabled, Delete only
+// (soft) deletes the secret. KeyVault purges it at the end of
+// the retention period. Until then, it can be recovered.
+//
 // Since KeyVault only supports two-steps deletes, KES cannot
 // guarantee that a Delete operation has atomic semantics.
 func (s *Store) Delete(ctx context.Context, name string) error {
@@ -232,6 +262,11 @@ func (s *Store) Delete(ctx context.Context, name string) error {
 		return nil
 	case stat.StatusCode == http.StatusConflict && stat.ErrorCode == "ObjectIsBeingDeleted":
 		return nil
+	case stat.StatusCode == http.StatusForbidden:
+		if err = s.purgeForbidden(ctx, name, "delete", stat); errors.As(err, new(*DeletedError)) {
+			return nil // Purge protection is enabled. The secret remains (soft) deleted.
+		}
+		return err
 	default:
 		return fmt.Errorf("azure: failed to delete '%s': failed to purge deleted secret: %s (%s)", name, stat.Message, stat.ErrorCode)
 	}
@@ -314,7 +349,13 @@ func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, strin
 func (s *Store) Close() error { return nil }
 
 // ConnectWithCredentials tries to establish a connection to an Azure KeyVault instance
+//
+// Managed HSM does not support secrets. Use ConnectManagedHSM
+// for Managed HSM endpoints instead.
 func ConnectWithCredentials(endpoint string, cred azcore.TokenCredential) (*Store, error) {
+	if IsManagedHSM(endpoint) {
+		return nil, fmt.Errorf("azure: '%s' is a Managed HSM which does not store secrets: specify a Managed HSM key and a keystore for the wrapped keys", endpoint)
+	}
 	azsecretsClient, err := azsecrets.NewClient(endpoint, cred, &azsecrets.ClientOptions{
 		ClientOptions: azcore.ClientOptions{
 			Retry: policy.RetryOptions{
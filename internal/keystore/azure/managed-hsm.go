@@ -0,0 +1,370 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kes/internal/keystore"
+)
+
+// hsmHeader is the prefix of all entries wrapped by a Managed HSM key.
+var hsmHeader = []byte("kes\x00azurehsm\x01")
+
+// IsManagedHSM reports whether endpoint is an Azure KeyVault
+// Managed HSM endpoint, like "https://my-hsm.managedhsm.azure.net",
+// of the public or a sovereign cloud.
+func IsManagedHSM(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, c := range clouds {
+		if strings.HasSuffix(host, "."+c.ManagedHSMDomain) {
+			return true
+		}
+	}
+	return false
+}
+
+// HSMConfig is a structure containing the Managed HSM configuration.
+//
+// Managed HSM does not store secrets. Instead, entries are encrypted
+// with a random data encryption key that is wrapped with an HSM key
+// and stored at another key store.
+type HSMConfig struct {
+	// Endpoint is the Managed HSM endpoint, like:
+	//   https://<name>.managedhsm.azure.net
+	Endpoint string
+
+	// Key is the name of the HSM key wrapping the data encryption
+	// keys. It must be an AES (oct-HSM) or RSA (RSA-HSM) key that
+	// permits the wrapKey and unwrapKey operations.
+	Key string
+
+	// Credential authenticates KES to the Managed HSM. The
+	// identity must be assigned the "Managed HSM Crypto User"
+	// role, or a custom role with the wrap and unwrap data
+	// actions, for the Key via the HSM's local RBAC.
+	Credential azcore.TokenCredential
+
+	// Store is the key store at which wrapped entries are stored.
+	Store kes.KeyStore
+}
+
+// hsmClient wraps and unwraps keys with a Managed HSM key.
+type hsmClient interface {
+	// GetKey returns the latest version of the key.
+	GetKey(ctx context.Context) (azkeys.KeyBundle, error)
+
+	// WrapKey wraps the plaintext with the latest version of the
+	// key and returns the version used for wrapping.
+	WrapKey(ctx context.Context, alg azkeys.EncryptionAlgorithm, plaintext []byte) (string, []byte, error)
+
+	// UnwrapKey unwraps the ciphertext with the given key version.
+	UnwrapKey(ctx context.Context, version string, alg azkeys.EncryptionAlgorithm, ciphertext []byte) ([]byte, error)
+}
+
+// ConnectManagedHSM connects to an Azure Managed HSM and returns a new
+// HSMStore that wraps all entries before storing them at the config's
+// key store.
+func ConnectManagedHSM(ctx context.Context, config *HSMConfig) (*HSMStore, error) {
+	if !IsManagedHSM(config.Endpoint) {
+		return nil, fmt.Errorf("azure: invalid Managed HSM endpoint '%s': expected 'https://<name>.%s'", config.Endpoint, AzurePublic.ManagedHSMDomain)
+	}
+	if config.Key == "" {
+		return nil, errors.New("azure: no Managed HSM key specified")
+	}
+	if config.Store == nil {
+		return nil, errors.New("azure: no key store specified")
+	}
+
+	// Managed HSM rate limits requests per HSM pool and responds
+	// with 429 Too Many Requests and a Retry-After header. Requests
+	// are only retried a few times with short delays. Otherwise, they
+	// would block until the KES request times out. Instead, clients
+	// are asked to retry later.
+	c, err := azkeys.NewClient(config.Endpoint, config.Credential, &azkeys.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Retry: policy.RetryOptions{
+				MaxRetries:    3,
+				RetryDelay:    250 * time.Millisecond,
+				MaxRetryDelay: 2 * time.Second,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("azure: failed to create keys client: %v", err)
+	}
+	return newHSMStore(ctx, &keysClient{client: c, key: config.Key}, config)
+}
+
+// newHSMStore returns a new HSMStore after checking that the
+// key can be used for wrapping keys.
+func newHSMStore(ctx context.Context, c hsmClient, config *HSMConfig) (*HSMStore, error) {
+	key, err := c.GetKey(ctx)
+	if err != nil {
+		return nil, hsmError("fetch key", config.Key, err)
+	}
+	if key.Attributes != nil && key.Attributes.Enabled != nil && !*key.Attributes.Enabled {
+		return nil, fmt.Errorf("azure: invalid Managed HSM key '%s': key is disabled", config.Key)
+	}
+	if key.Key == nil || key.Key.Kty == nil {
+		return nil, fmt.Errorf("azure: invalid Managed HSM key '%s': unknown key type", config.Key)
+	}
+
+	var alg azkeys.EncryptionAlgorithm
+	switch *key.Key.Kty {
+	case azkeys.KeyTypeOctHSM:
+		alg = azkeys.EncryptionAlgorithmA256KW
+	case azkeys.KeyTypeRSAHSM:
+		alg = azkeys.EncryptionAlgorithmRSAOAEP256
+	default:
+		return nil, fmt.Errorf("azure: invalid Managed HSM key '%s': key type is '%s' - expected '%s' or '%s'", config.Key, *key.Key.Kty, azkeys.KeyTypeOctHSM, azkeys.KeyTypeRSAHSM)
+	}
+	for _, op := range []azkeys.KeyOperation{azkeys.KeyOperationWrapKey, azkeys.KeyOperationUnwrapKey} {
+		if !slices.ContainsFunc(key.Key.KeyOps, func(o *azkeys.KeyOperation) bool { return o != nil && *o == op }) {
+			return nil, fmt.Errorf("azure: invalid Managed HSM key '%s': key does not permit the '%s' operation", config.Key, op)
+		}
+	}
+	return &HSMStore{
+		endpoint: config.Endpoint,
+		key:      config.Key,
+		alg:      alg,
+		client:   c,
+		store:    config.Store,
+	}, nil
+}
+
+// HSMStore is a key store that encrypts all entries with data
+// encryption keys wrapped by an Azure Managed HSM key before
+// storing them at another key store.
+type HSMStore struct {
+	endpoint string
+	key      string
+	alg      azkeys.EncryptionAlgorithm
+	client   hsmClient
+	store    kes.KeyStore
+}
+
+func (s *HSMStore) String() string {
+	return "Azure Managed HSM: " + s.endpoint + " Key=" + s.key + " " + fmt.Sprint(s.store)
+}
+
+// Status returns the current state of the underlying key store.
+// It returns an error if the Managed HSM is not reachable.
+func (s *HSMStore) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	if _, err := s.client.GetKey(ctx); err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return kes.KeyStoreState{}, err
+		}
+		return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+	}
+	return s.store.Status(ctx)
+}
+
+// Create encrypts the value and creates a new entry at the
+// underlying key store if and only if no entry with the
+// given name exists.
+//
+// The value is encrypted with a random data encryption key
+// that is wrapped with the latest version of the HSM key.
+func (s *HSMStore) Create(ctx context.Context, name string, value []byte) error {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return err
+	}
+	key, err := crypto.NewSecretKey(crypto.AES256, dek)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := key.Encrypt(value, hsmAssociatedData(name))
+	if err != nil {
+		return err
+	}
+
+	version, wrappedKey, err := s.client.WrapKey(ctx, s.alg, dek)
+	if err != nil {
+		return hsmError("wrap entry", name, err)
+	}
+
+	wrapped := make([]byte, 0, len(hsmHeader)+6+len(version)+len(s.alg)+len(wrappedKey)+len(ciphertext))
+	wrapped = append(wrapped, hsmHeader...)
+	wrapped = appendField(wrapped, []byte(version))
+	wrapped = appendField(wrapped, []byte(s.alg))
+	wrapped = appendField(wrapped, wrappedKey)
+	wrapped = append(wrapped, ciphertext...)
+	return s.store.Create(ctx, name, wrapped)
+}
+
+// Delete removes the entry from the underlying key store.
+func (s *HSMStore) Delete(ctx context.Context, name string) error {
+	return s.store.Delete(ctx, name)
+}
+
+// Get returns the decrypted value of the entry. The data
+// encryption key is unwrapped with the HSM key version
+// that wrapped it.
+func (s *HSMStore) Get(ctx context.Context, name string) ([]byte, error) {
+	wrapped, err := s.store.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(wrapped, hsmHeader) {
+		return nil, fmt.Errorf("azure: entry '%s' is not wrapped", name)
+	}
+
+	b := wrapped[len(hsmHeader):]
+	version, b, ok := parseField(b)
+	if !ok {
+		return nil, fmt.Errorf("azure: entry '%s' is not wrapped", name)
+	}
+	alg, b, ok := parseField(b)
+	if !ok {
+		return nil, fmt.Errorf("azure: entry '%s' is not wrapped", name)
+	}
+	wrappedKey, ciphertext, ok := parseField(b)
+	if !ok {
+		return nil, fmt.Errorf("azure: entry '%s' is not wrapped", name)
+	}
+
+	dek, err := s.client.UnwrapKey(ctx, string(version), azkeys.EncryptionAlgorithm(alg), wrappedKey)
+	if err != nil {
+		return nil, hsmError("unwrap entry", name, err)
+	}
+	key, err := crypto.NewSecretKey(crypto.AES256, dek)
+	if err != nil {
+		return nil, fmt.Errorf("azure: failed to unwrap entry '%s': %v", name, err)
+	}
+	value, err := key.Decrypt(bytes.Clone(ciphertext), hsmAssociatedData(name))
+	if err != nil {
+		return nil, fmt.Errorf("azure: failed to decrypt entry '%s': %v", name, err)
+	}
+	return value, nil
+}
+
+// List returns the names of all entries of the underlying
+// key store that start with the prefix.
+func (s *HSMStore) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	return s.store.List(ctx, prefix, n)
+}
+
+// Close closes the underlying key store.
+func (s *HSMStore) Close() error { return s.store.Close() }
+
+// hsmAssociatedData binds an encrypted value to its entry
+// name such that entries cannot be swapped.
+func hsmAssociatedData(name string) []byte {
+	return append(bytes.Clone(hsmHeader), "name="+name...)
+}
+
+// appendField appends the 2 byte big endian length of
+// field followed by the field itself to b.
+func appendField(b, field []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(field)))
+	return append(b, field...)
+}
+
+// parseField parses a length-prefixed field from b and
+// returns the field and the remaining bytes.
+func parseField(b []byte) (field, rest []byte, ok bool) {
+	if len(b) < 2 {
+		return nil, nil, false
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return nil, nil, false
+	}
+	return b[2 : 2+n], b[2+n:], true
+}
+
+// hsmError converts a Managed HSM error into an error
+// returned to the caller.
+//
+// Throttled requests are converted into *keystore.ErrThrottled
+// such that clients retry them later. Permission errors contain
+// a hint about the Managed HSM local RBAC since, unlike KeyVault,
+// Managed HSM does not use access policies or Azure RBAC for
+// key operations.
+func hsmError(op, name string, err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	var rerr *azcore.ResponseError
+	if !errors.As(err, &rerr) {
+		return fmt.Errorf("azure: failed to %s '%s': %v", op, name, err)
+	}
+	switch rerr.StatusCode {
+	case http.StatusTooManyRequests:
+		var delay time.Duration
+		if rerr.RawResponse != nil {
+			if sec, err := strconv.Atoi(rerr.RawResponse.Header.Get("Retry-After")); err == nil && sec > 0 {
+				delay = time.Duration(sec) * time.Second
+			}
+		}
+		return &keystore.ErrThrottled{Delay: delay, Err: err}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("azure: failed to %s '%s': insufficient permissions: is the 'Managed HSM Crypto User' role assigned to KES via the HSM's local RBAC? (%s)", op, name, rerr.ErrorCode)
+	default:
+		return fmt.Errorf("azure: failed to %s '%s': %s (%d)", op, name, rerr.ErrorCode, rerr.StatusCode)
+	}
+}
+
+// keysClient implements hsmClient using the KeyVault keys SDK.
+type keysClient struct {
+	client *azkeys.Client
+	key    string
+}
+
+func (c *keysClient) GetKey(ctx context.Context) (azkeys.KeyBundle, error) {
+	resp, err := c.client.GetKey(ctx, c.key, "", nil)
+	if err != nil {
+		return azkeys.KeyBundle{}, err
+	}
+	return resp.KeyBundle, nil
+}
+
+func (c *keysClient) WrapKey(ctx context.Context, alg azkeys.EncryptionAlgorithm, plaintext []byte) (string, []byte, error) {
+	resp, err := c.client.WrapKey(ctx, c.key, "", azkeys.KeyOperationParameters{
+		Algorithm: &alg,
+		Value:     plaintext,
+	}, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	if resp.KID == nil || resp.KID.Version() == "" {
+		return "", nil, errors.New("response contains no key version")
+	}
+	return resp.KID.Version(), resp.Result, nil
+}
+
+func (c *keysClient) UnwrapKey(ctx context.Context, version string, alg azkeys.EncryptionAlgorithm, ciphertext []byte) ([]byte, error) {
+	resp, err := c.client.UnwrapKey(ctx, c.key, version, azkeys.KeyOperationParameters{
+		Algorithm: &alg,
+		Value:     ciphertext,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Result, nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestIsManagedHSM(t *testing.T) {
+	for i, test := range isManagedHSMTests {
+		if ok := IsManagedHSM(test.Endpoint); ok != test.OK {
+			t.Fatalf("Test %d: got '%v' - want '%v' for '%s'", i, ok, test.OK, test.Endpoint)
+		}
+	}
+}
+
+var isManagedHSMTests = []struct {
+	Endpoint string
+	OK       bool
+}{
+	{Endpoint: "https://my-hsm.managedhsm.azure.net", OK: true},
+	{Endpoint: "https://my-hsm.managedhsm.azure.net/", OK: true},
+	{Endpoint: "https://MY-HSM.ManagedHSM.azure.net", OK: true},
+	{Endpoint: "https://my-hsm.managedhsm.usgovcloudapi.net", OK: true},
+	{Endpoint: "https://my-hsm.managedhsm.azure.cn", OK: true},
+	{Endpoint: "https://my-vault.vault.azure.net", OK: false},
+	{Endpoint: "https://managedhsm.azure.net.example.com", OK: false},
+	{Endpoint: "", OK: false},
+}
+
+func TestHSMStore(t *testing.T) {
+	ctx := context.Background()
+	mem := &kes.MemKeyStore{}
+	hsm := newSoftHSM(t)
+	store, err := newHSMStore(ctx, hsm, &HSMConfig{Key: "kes-root", Store: mem})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	value := []byte("my-secret-value")
+	if err = store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", value); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Created key twice: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	// Entries wrapped with an older key version remain readable
+	// after the HSM key has been rotated.
+	hsm.rotate(t)
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key after rotation: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	wrapped, err := mem.Get(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to get wrapped key: %v", err)
+	}
+	if !bytes.HasPrefix(wrapped, hsmHeader) || bytes.Contains(wrapped, value) {
+		t.Fatalf("Key is not wrapped: got '%x'", wrapped)
+	}
+	if err = mem.Create(ctx, "other-key", wrapped); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if _, err = store.Get(ctx, "other-key"); err == nil {
+		t.Fatal("Decrypted entry stored under a different name")
+	}
+	if err = mem.Create(ctx, "truncated-key", wrapped[:len(hsmHeader)+4]); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if _, err = store.Get(ctx, "truncated-key"); err == nil {
+		t.Fatal("Decrypted truncated entry")
+	}
+
+	hsm.err = &azcore.ResponseError{
+		StatusCode:  http.StatusTooManyRequests,
+		RawResponse: &http.Response{Header: http.Header{"Retry-After": []string{"3"}}},
+	}
+	_, err = store.Get(ctx, "my-key")
+	if e, ok := keystore.IsThrottled(err); !ok || e.RetryAfter() != 3*time.Second {
+		t.Fatalf("Throttled request did not fail with ErrThrottled: got '%v'", err)
+	}
+}
+
+func TestNewHSMStore(t *testing.T) {
+	ctx := context.Background()
+	for i, test := range newHSMStoreTests {
+		hsm := newSoftHSM(t)
+		hsm.kty = test.Type
+		hsm.ops = test.Ops
+		hsm.enabled = test.Enabled
+
+		_, err := newHSMStore(ctx, hsm, &HSMConfig{Key: "kes-root", Store: &kes.MemKeyStore{}})
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: creating store should have failed", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to create store: %v", i, err)
+		}
+	}
+}
+
+var newHSMStoreTests = []struct {
+	Type       azkeys.KeyType
+	Ops        []azkeys.KeyOperation
+	Enabled    bool
+	ShouldFail bool
+}{
+	{Type: azkeys.KeyTypeOctHSM, Ops: []azkeys.KeyOperation{azkeys.KeyOperationWrapKey, azkeys.KeyOperationUnwrapKey}, Enabled: true},
+	{Type: azkeys.KeyTypeRSAHSM, Ops: []azkeys.KeyOperation{azkeys.KeyOperationWrapKey, azkeys.KeyOperationUnwrapKey}, Enabled: true},
+	{Type: azkeys.KeyTypeEC, Ops: []azkeys.KeyOperation{azkeys.KeyOperationWrapKey, azkeys.KeyOperationUnwrapKey}, Enabled: true, ShouldFail: true},
+	{Type: azkeys.KeyTypeOctHSM, Ops: []azkeys.KeyOperation{azkeys.KeyOperationWrapKey}, Enabled: true, ShouldFail: true},
+	{Type: azkeys.KeyTypeOctHSM, Ops: []azkeys.KeyOperation{azkeys.KeyOperationWrapKey, azkeys.KeyOperationUnwrapKey}, Enabled: false, ShouldFail: true},
+}
+
+func TestConnectWithCredentialsManagedHSM(t *testing.T) {
+	if _, err := ConnectWithCredentials("https://my-hsm.managedhsm.azure.net", nil); err == nil {
+		t.Fatal("Connected to Managed HSM as secret store")
+	}
+}
+
+// softHSM is an hsmClient that wraps keys with
+// AES-GCM keys held in memory.
+type softHSM struct {
+	kty     azkeys.KeyType
+	ops     []azkeys.KeyOperation
+	enabled bool
+	err     error
+
+	versions map[string]cipher.AEAD
+	latest   string
+}
+
+func newSoftHSM(t *testing.T) *softHSM {
+	h := &softHSM{
+		kty:      azkeys.KeyTypeOctHSM,
+		ops:      []azkeys.KeyOperation{azkeys.KeyOperationWrapKey, azkeys.KeyOperationUnwrapKey},
+		enabled:  true,
+		versions: map[string]cipher.AEAD{},
+	}
+	h.rotate(t)
+	return h
+}
+
+func (h *softHSM) rotate(t *testing.T) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %v", err)
+	}
+	h.latest = strconv.Itoa(len(h.versions))
+	h.versions[h.latest] = aead
+}
+
+func (h *softHSM) GetKey(context.Context) (azkeys.KeyBundle, error) {
+	if h.err != nil {
+		return azkeys.KeyBundle{}, h.err
+	}
+	ops := make([]*azkeys.KeyOperation, 0, len(h.ops))
+	for i := range h.ops {
+		ops = append(ops, &h.ops[i])
+	}
+	return azkeys.KeyBundle{
+		Attributes: &azkeys.KeyAttributes{Enabled: &h.enabled},
+		Key:        &azkeys.JSONWebKey{Kty: &h.kty, KeyOps: ops},
+	}, nil
+}
+
+func (h *softHSM) WrapKey(_ context.Context, _ azkeys.EncryptionAlgorithm, plaintext []byte) (string, []byte, error) {
+	if h.err != nil {
+		return "", nil, h.err
+	}
+	aead := h.versions[h.latest]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return h.latest, aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (h *softHSM) UnwrapKey(_ context.Context, version string, _ azkeys.EncryptionAlgorithm, ciphertext []byte) ([]byte, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+	aead, ok := h.versions[version]
+	if !ok {
+		return nil, errors.New("key version not found")
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("invalid ciphertext")
+	}
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], nil)
+}
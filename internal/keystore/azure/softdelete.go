@@ -0,0 +1,140 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// DeletedError is returned when a key cannot be created since
+// a (soft) deleted KeyVault secret with the same name exists
+// that can neither be purged nor has been recovered.
+//
+// KeyVault keeps deleted secrets for the vault's retention period.
+// If purge protection is enabled, deleted secrets cannot be purged
+// before their scheduled purge date. Such secrets can only be
+// recovered.
+type DeletedError struct {
+	Name            string    // The name of the deleted secret
+	ScheduledPurge  time.Time // The time KeyVault purges the secret. Zero, if unknown
+	PurgeProtection bool      // Whether purge protection prevents purging the secret
+}
+
+func (e *DeletedError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "azure: key '%s' already exists but is currently marked as deleted", e.Name)
+	if !e.PurgeProtection {
+		fmt.Fprintf(&b, ". Either recover or purge '%s'", e.Name)
+		return b.String()
+	}
+
+	b.WriteString(" and cannot be purged since purge protection is enabled")
+	if !e.ScheduledPurge.IsZero() {
+		fmt.Fprintf(&b, ". KeyVault purges '%s' at %s", e.Name, e.ScheduledPurge.UTC().Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, ". Either recover '%s' or use a different key name", e.Name)
+	return b.String()
+}
+
+// Recover recovers the (soft) deleted secret with the given
+// name such that it can be used again. It returns
+// kes.ErrKeyNotFound if no such deleted secret exists.
+//
+// KeyVault recovers secrets in the background. Therefore,
+// Recover waits until the recovered secret is accessible
+// but will eventually give up and fail.
+func (s *Store) Recover(ctx context.Context, name string) error {
+	var (
+		stat status
+		err  error
+	)
+	for i := 0; i < 7; i++ {
+		stat, err = s.client.RecoverSecret(ctx, name)
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("azure: failed to recover '%s': %v", name, err)
+		}
+		if stat.StatusCode == http.StatusConflict && stat.ErrorCode == "ObjectIsBeingDeleted" {
+			time.Sleep(delay + time.Duration(rand.Int63n(jitter.Milliseconds()))*time.Millisecond)
+			continue
+		}
+		break
+	}
+	switch {
+	case stat.StatusCode == http.StatusOK:
+	case stat.StatusCode == http.StatusNotFound:
+		return kesdk.ErrKeyNotFound
+	case stat.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("azure: failed to recover '%s': insufficient permissions: %s (%s)", name, stat.Message, stat.ErrorCode)
+	default:
+		return fmt.Errorf("azure: failed to recover '%s': %s (%s)", name, stat.Message, stat.ErrorCode)
+	}
+
+	for i := 0; i < 7; i++ {
+		_, stat, err = s.client.GetSecret(ctx, name, "")
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("azure: failed to recover '%s': %v", name, err)
+		}
+		if stat.StatusCode != http.StatusNotFound {
+			return nil
+		}
+		time.Sleep(delay + time.Duration(rand.Int63n(jitter.Milliseconds()))*time.Millisecond)
+	}
+	return fmt.Errorf("azure: failed to recover '%s': secret is not accessible yet. Retry later", name)
+}
+
+// Purge permanently deletes the (soft) deleted secret with the
+// given name. It returns a *DeletedError if purge protection is
+// enabled. Purging a secret that does not exist is a no-op.
+//
+// Purge does not delete active secrets. Use Delete instead.
+func (s *Store) Purge(ctx context.Context, name string) error {
+	stat, err := s.purgeWithRetry(ctx, name, 10)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case stat.StatusCode == http.StatusOK:
+		return nil
+	case stat.StatusCode == http.StatusForbidden:
+		return s.purgeForbidden(ctx, name, "purge", stat)
+	default:
+		return fmt.Errorf("azure: failed to purge '%s': %s (%s)", name, stat.Message, stat.ErrorCode)
+	}
+}
+
+// purgeForbidden returns an error explaining why purging the
+// deleted secret with the given name has been rejected with
+// HTTP 403 Forbidden. Either purge protection is enabled, in
+// which case it returns a *DeletedError, or the KeyVault
+// access policy does not grant the 'purge' permission.
+func (s *Store) purgeForbidden(ctx context.Context, name, op string, stat status) error {
+	secret, dstat, err := s.client.GetDeletedSecret(ctx, name)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if err == nil && dstat.StatusCode == http.StatusOK && !secret.Purgeable() {
+		return &DeletedError{
+			Name:            name,
+			ScheduledPurge:  secret.ScheduledPurge,
+			PurgeProtection: true,
+		}
+	}
+	return fmt.Errorf("azure: failed to %s '%s': insufficient permissions to purge deleted secret. Grant the 'purge' secret permission: %s (%s)", op, name, stat.Message, stat.ErrorCode)
+}
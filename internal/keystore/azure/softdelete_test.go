@@ -0,0 +1,80 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeletedError(t *testing.T) {
+	purge := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for i, test := range deletedErrorTests {
+		err := &DeletedError{
+			Name:            "my-key",
+			PurgeProtection: test.PurgeProtection,
+		}
+		if test.Scheduled {
+			err.ScheduledPurge = purge
+		}
+
+		msg := err.Error()
+		for _, s := range test.Contains {
+			if !strings.Contains(msg, s) {
+				t.Fatalf("Test %d: error '%s' does not contain '%s'", i, msg, s)
+			}
+		}
+		for _, s := range test.NotContains {
+			if strings.Contains(msg, s) {
+				t.Fatalf("Test %d: error '%s' contains '%s'", i, msg, s)
+			}
+		}
+	}
+}
+
+func TestDeletedSecretPurgeable(t *testing.T) {
+	for i, test := range deletedSecretPurgeableTests {
+		secret := deletedSecret{RecoveryLevel: test.RecoveryLevel}
+		if purgeable := secret.Purgeable(); purgeable != test.Purgeable {
+			t.Fatalf("Test %d: purgeable mismatch: got '%v' - want '%v'", i, purgeable, test.Purgeable)
+		}
+	}
+}
+
+var deletedErrorTests = []struct {
+	PurgeProtection bool
+	Scheduled       bool
+	Contains        []string
+	NotContains     []string
+}{
+	{ // 0
+		Contains:    []string{"'my-key'", "recover or purge"},
+		NotContains: []string{"purge protection"},
+	},
+	{ // 1
+		PurgeProtection: true,
+		Contains:        []string{"purge protection is enabled", "recover 'my-key'"},
+		NotContains:     []string{"recover or purge", "KeyVault purges"},
+	},
+	{ // 2
+		PurgeProtection: true,
+		Scheduled:       true,
+		Contains:        []string{"purge protection is enabled", "2024-03-01T12:00:00Z"},
+	},
+}
+
+var deletedSecretPurgeableTests = []struct {
+	RecoveryLevel string
+	Purgeable     bool
+}{
+	{RecoveryLevel: "", Purgeable: true},                                             // 0
+	{RecoveryLevel: "Purgeable", Purgeable: true},                                    // 1
+	{RecoveryLevel: "Recoverable+Purgeable", Purgeable: true},                        // 2
+	{RecoveryLevel: "CustomizedRecoverable+Purgeable", Purgeable: true},              // 3
+	{RecoveryLevel: "Recoverable", Purgeable: false},                                 // 4
+	{RecoveryLevel: "Recoverable+ProtectedSubscription", Purgeable: false},           // 5
+	{RecoveryLevel: "CustomizedRecoverable+ProtectedSubscription", Purgeable: false}, // 6
+}
@@ -0,0 +1,424 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package dynamodb implements a key-value store that
+// stores values as items of an AWS DynamoDB table.
+//
+// The table must have a string partition key named
+// "name" and no sort key. Values are stored as binary
+// attribute "value".
+package dynamodb
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// Attribute names of table items.
+const (
+	attrName  = "name"
+	attrValue = "value"
+)
+
+// Credentials represents static AWS credentials:
+// access key, secret key and a session token
+type Credentials struct {
+	AccessKey    string // The AWS access key
+	SecretKey    string // The AWS secret key
+	SessionToken string // The AWS session token
+}
+
+// Client is the subset of the DynamoDB API used by the Store.
+// It is implemented by the DynamoDB client of the AWS SDK and
+// by DynamoDB Accelerator (DAX) clients.
+type Client interface {
+	GetItem(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(context.Context, *dynamodb.DeleteItemInput, ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Scan(context.Context, *dynamodb.ScanInput, ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// Config is a structure containing configuration
+// options for connecting to AWS DynamoDB.
+type Config struct {
+	// Addr is the HTTP address of DynamoDB. It may be a
+	// custom endpoint, like a VPC endpoint or DynamoDB
+	// local, with a "http://" or "https://" scheme. If
+	// empty, the regional AWS endpoint is used.
+	Addr string
+
+	// Region is the AWS region.
+	Region string
+
+	// Table is the name of the DynamoDB table.
+	Table string
+
+	// EventualConsistency controls whether keys are read with
+	// eventually consistent reads. They are cheaper and served
+	// from a DAX cache, if used, but may not reflect recently
+	// created or deleted keys. By default, reads are strongly
+	// consistent.
+	EventualConsistency bool
+
+	// Login contains the AWS credentials (access/secret key).
+	// If empty, the default credential chain is used.
+	Login Credentials
+
+	// Client is an optional DynamoDB client, like a DynamoDB
+	// Accelerator (DAX) client, used instead of a client for
+	// the Addr and Region. DAX serves eventually consistent
+	// reads from its cache. Hence, it should be combined with
+	// EventualConsistency.
+	Client Client
+}
+
+// Connect returns a new Store that stores keys as items of
+// the configured DynamoDB table.
+//
+// It returns an error if the table is not accessible.
+func Connect(ctx context.Context, cfg *Config) (*Store, error) {
+	if cfg.Table == "" {
+		return nil, errors.New("dynamodb: no table specified")
+	}
+
+	client := cfg.Client
+	if client == nil {
+		if cfg.Region == "" {
+			return nil, errors.New("dynamodb: no region specified")
+		}
+
+		opts := []func(*config.LoadOptions) error{
+			config.WithRegion(cfg.Region),
+		}
+		if cfg.Login.AccessKey != "" || cfg.Login.SecretKey != "" || cfg.Login.SessionToken != "" {
+			opts = append(opts, config.WithCredentialsProvider(
+				credentials.NewStaticCredentialsProvider(
+					cfg.Login.AccessKey,
+					cfg.Login.SecretKey,
+					cfg.Login.SessionToken,
+				),
+			))
+		}
+		awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		client = dynamodb.NewFromConfig(awsCfg, func(o *dynamodb.Options) {
+			if cfg.Addr != "" {
+				o.BaseEndpoint = aws.String(cfg.Addr)
+			}
+		})
+	}
+
+	s := &Store{
+		config: *cfg,
+		client: client,
+	}
+	if _, err := s.Status(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Store is an AWS DynamoDB key store.
+type Store struct {
+	config Config
+	client Client
+}
+
+func (s *Store) String() string { return "AWS DynamoDB: " + s.config.Table }
+
+// Status returns the current state of the DynamoDB table.
+// In particular, whether it is reachable and the network
+// latency.
+//
+// It scans at most one item such that a missing table, invalid
+// credentials or missing permissions are detected as well. Such
+// failures are reported as unauthenticated or unauthorized state
+// along with an error.
+func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	start := time.Now()
+	_, err := s.client.Scan(ctx, &dynamodb.ScanInput{
+		TableName:                aws.String(s.config.Table),
+		ProjectionExpression:     aws.String("#n"),
+		ExpressionAttributeNames: map[string]string{"#n": attrName},
+		Limit:                    aws.Int32(1),
+	})
+	latency := time.Since(start)
+	if err == nil {
+		return kes.KeyStoreState{Latency: latency}, nil
+	}
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		if isUnreachable(err) {
+			return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+		}
+		return kes.KeyStoreState{}, err
+	}
+	switch apiErr.ErrorCode() {
+	case "UnrecognizedClientException", "InvalidClientTokenId", "InvalidSignatureException",
+		"SignatureDoesNotMatch", "IncompleteSignature", "MissingAuthenticationToken",
+		"ExpiredTokenException", "ExpiredToken":
+		return kes.KeyStoreState{Latency: latency, Unauthenticated: true}, fmt.Errorf("dynamodb: authentication failed: %v", err)
+	case "AccessDeniedException", "AccessDenied":
+		return kes.KeyStoreState{Latency: latency, Unauthorized: true}, fmt.Errorf("dynamodb: access denied: %v", err)
+	case "ResourceNotFoundException":
+		return kes.KeyStoreState{Latency: latency}, fmt.Errorf("dynamodb: table '%s' does not exist: %v", s.config.Table, err)
+	default:
+		return kes.KeyStoreState{Latency: latency}, err
+	}
+}
+
+// Create stores the given key-value pair as table item if and
+// only if no item with the given name exists. Otherwise, it
+// returns kes.ErrKeyExists.
+//
+// The existence check is a conditional write. Hence, concurrent
+// creates of the same key by multiple KES servers are safe.
+func (s *Store) Create(ctx context.Context, name string, value []byte) error {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.config.Table),
+		Item: map[string]types.AttributeValue{
+			attrName:  &types.AttributeValueMemberS{Value: name},
+			attrValue: &types.AttributeValueMemberB{Value: value},
+		},
+		ConditionExpression:      aws.String("attribute_not_exists(#n)"),
+		ExpressionAttributeNames: map[string]string{"#n": attrName},
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return kesdk.ErrKeyExists
+		}
+		return fmt.Errorf("dynamodb: failed to create '%s': %v", name, err)
+	}
+	return nil
+}
+
+// Get returns the value associated with the given key.
+// If no entry for key exists, it returns kes.ErrKeyNotFound.
+func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
+	response, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.config.Table),
+		Key: map[string]types.AttributeValue{
+			attrName: &types.AttributeValueMemberS{Value: name},
+		},
+		ConsistentRead: aws.Bool(!s.config.EventualConsistency),
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("dynamodb: failed to read '%s': %v", name, err)
+	}
+	if len(response.Item) == 0 {
+		return nil, kesdk.ErrKeyNotFound
+	}
+	value, ok := response.Item[attrValue].(*types.AttributeValueMemberB)
+	if !ok {
+		return nil, fmt.Errorf("dynamodb: failed to read '%s': item has no binary '%s' attribute", name, attrValue)
+	}
+	return value.Value, nil
+}
+
+// Delete removes the item with the given name if it exists.
+// Otherwise, it returns kes.ErrKeyNotFound.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.config.Table),
+		Key: map[string]types.AttributeValue{
+			attrName: &types.AttributeValueMemberS{Value: name},
+		},
+		ConditionExpression:      aws.String("attribute_exists(#n)"),
+		ExpressionAttributeNames: map[string]string{"#n": attrName},
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			return kesdk.ErrKeyNotFound
+		}
+		return fmt.Errorf("dynamodb: failed to delete '%s': %v", name, err)
+	}
+	return nil
+}
+
+// List returns the first n key names, that start with the given
+// prefix, and the next prefix from which the listing should
+// continue.
+//
+// It returns all keys with the prefix if n < 0 and less than n
+// names if n is greater than the number of keys with the prefix.
+//
+// An empty prefix matches any key name. At the end of the listing
+// or when there are no (more) keys starting with the prefix, the
+// returned prefix is empty.
+//
+// Keys are listed in table scan order. If n > 0, the listing
+// continues at the DynamoDB pagination key. Hence, the returned
+// prefix may be a list cursor instead of a key name. If n <= 0
+// and the table contains more than one page of items, all keys
+// are listed with a parallel scan and returned in sorted order.
+func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	const (
+		PageSize        = 1000 // Max. number of items evaluated per Scan request
+		ScanSegments    = 16   // Number of segments of a parallel scan
+		ScanConcurrency = 8    // Max. number of concurrent Scan requests
+	)
+
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(s.config.Table),
+		ProjectionExpression:     aws.String("#n"),
+		ExpressionAttributeNames: map[string]string{"#n": attrName},
+	}
+	if p, last, ok := parseListCursor(prefix); ok {
+		prefix = p
+		input.ExclusiveStartKey = map[string]types.AttributeValue{
+			attrName: &types.AttributeValueMemberS{Value: last},
+		}
+	}
+	if prefix != "" {
+		input.FilterExpression = aws.String("begins_with(#n, :prefix)")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":prefix": &types.AttributeValueMemberS{Value: prefix},
+		}
+	}
+
+	var names []string
+	for {
+		input.Limit = aws.Int32(PageSize)
+		if n > 0 {
+			input.Limit = aws.Int32(int32(min(n-len(names), PageSize)))
+		}
+
+		page, err := s.client.Scan(ctx, input)
+		if err != nil {
+			if isUnreachable(err) {
+				return nil, "", &keystore.ErrUnreachable{Err: err}
+			}
+			return nil, "", fmt.Errorf("dynamodb: failed to list keys: %v", err)
+		}
+		for _, item := range page.Items {
+			if name, ok := item[attrName].(*types.AttributeValueMemberS); ok {
+				names = append(names, name.Value)
+			}
+		}
+
+		last, ok := page.LastEvaluatedKey[attrName].(*types.AttributeValueMemberS)
+		if !ok {
+			return names, "", nil
+		}
+		if n > 0 && len(names) >= n {
+			return names, listCursor(prefix, last.Value), nil
+		}
+
+		// A Scan request evaluates at most PageSize items.
+		// Hence, scan large tables with a parallel scan
+		// when listing all keys.
+		if n <= 0 && input.ExclusiveStartKey == nil {
+			names, err = keystore.ListConcurrent(ctx, ScanSegments, ScanConcurrency, func(ctx context.Context, segment int) ([]string, error) {
+				return s.scanSegment(ctx, input, segment, ScanSegments)
+			})
+			if err != nil {
+				return nil, "", err
+			}
+			return names, "", nil
+		}
+		input.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+}
+
+// scanSegment returns the key names of all items within the
+// given segment of a parallel scan with the given number of
+// segments.
+func (s *Store) scanSegment(ctx context.Context, scan *dynamodb.ScanInput, segment, total int) ([]string, error) {
+	input := *scan
+	input.Segment = aws.Int32(int32(segment))
+	input.TotalSegments = aws.Int32(int32(total))
+
+	var names []string
+	for {
+		page, err := s.client.Scan(ctx, &input)
+		if err != nil {
+			if isUnreachable(err) {
+				return nil, &keystore.ErrUnreachable{Err: err}
+			}
+			return nil, fmt.Errorf("dynamodb: failed to list keys: %v", err)
+		}
+		for _, item := range page.Items {
+			if name, ok := item[attrName].(*types.AttributeValueMemberS); ok {
+				names = append(names, name.Value)
+			}
+		}
+		if len(page.LastEvaluatedKey) == 0 {
+			return names, nil
+		}
+		input.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+}
+
+// Close closes the Store.
+func (s *Store) Close() error { return nil }
+
+// listCursorPrefix is the prefix of list cursors. It contains
+// a NUL byte and therefore never collides with a key name.
+const listCursorPrefix = "\x00dynamodb-list:"
+
+// listCursor returns a list cursor that continues the listing
+// of the given prefix after the item with the given name.
+func listCursor(prefix, last string) string {
+	return listCursorPrefix + base64.RawURLEncoding.EncodeToString([]byte(prefix)) + ":" + base64.RawURLEncoding.EncodeToString([]byte(last))
+}
+
+// parseListCursor parses the list cursor. It reports whether
+// s is a list cursor.
+func parseListCursor(s string) (prefix, last string, ok bool) {
+	s, ok = strings.CutPrefix(s, listCursorPrefix)
+	if !ok {
+		return "", "", false
+	}
+	p, l, ok := strings.Cut(s, ":")
+	if !ok || l == "" {
+		return "", "", false
+	}
+	bp, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return "", "", false
+	}
+	bl, err := base64.RawURLEncoding.DecodeString(l)
+	if err != nil {
+		return "", "", false
+	}
+	return string(bp), string(bl), true
+}
+
+// isUnreachable reports whether err indicates that
+// DynamoDB is not reachable.
+func isUnreachable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,249 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package dynamodb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := Connect(ctx, &Config{Table: "kes", Client: newMockTable()})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	value := []byte("my-secret-value")
+	if err = store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", value); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Created key twice: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, value, err)
+	}
+	if _, err = store.Get(ctx, "missing-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Fetched non-existing key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if err = store.Delete(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Deleted key twice: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+}
+
+func TestStoreList(t *testing.T) {
+	ctx := context.Background()
+	store, err := Connect(ctx, &Config{Table: "kes", Client: newMockTable()})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	for _, name := range []string{"key-0", "key-1", "key-2", "my-key"} {
+		if err = store.Create(ctx, name, []byte("value")); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+
+	if names, next, err := store.List(ctx, "", -1); err != nil || !slices.Equal(names, []string{"key-0", "key-1", "key-2", "my-key"}) || next != "" {
+		t.Fatalf("Failed to list keys: got '%v' and '%s': %v", names, next, err)
+	}
+	if names, _, err := store.List(ctx, "key-", -1); err != nil || !slices.Equal(names, []string{"key-0", "key-1", "key-2"}) {
+		t.Fatalf("Failed to list keys: got '%v': %v", names, err)
+	}
+
+	// Paginate through the keys with the prefix using
+	// the returned list cursors.
+	var (
+		names  []string
+		prefix = "key-"
+	)
+	for {
+		page, next, err := store.List(ctx, prefix, 2)
+		if err != nil {
+			t.Fatalf("Failed to list keys: %v", err)
+		}
+		if len(page) > 2 {
+			t.Fatalf("Invalid page size: got '%d' - want at most '%d'", len(page), 2)
+		}
+		names = append(names, page...)
+		if next == "" {
+			break
+		}
+		prefix = next
+	}
+	if !slices.Equal(names, []string{"key-0", "key-1", "key-2"}) {
+		t.Fatalf("Invalid paginated listing: got '%v'", names)
+	}
+}
+
+func TestStoreListParallel(t *testing.T) {
+	ctx := context.Background()
+	table := newMockTable()
+	store, err := Connect(ctx, &Config{Table: "kes", Client: table})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	// The table contains more items than a single Scan
+	// request evaluates.
+	var want []string
+	for i := range 2500 {
+		name := fmt.Sprintf("key-%04d", i)
+		table.items[name] = []byte("value")
+		want = append(want, name)
+	}
+
+	names, next, err := store.List(ctx, "", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if !slices.Equal(names, want) || next != "" {
+		t.Fatalf("Invalid listing: got %d keys and '%s' - want %d keys", len(names), next, len(want))
+	}
+	if table.segments.Load() == 0 {
+		t.Fatal("Keys have not been listed with a parallel scan")
+	}
+}
+
+func TestParseListCursor(t *testing.T) {
+	prefix, last, ok := parseListCursor(listCursor("my-", "my-key"))
+	if !ok || prefix != "my-" || last != "my-key" {
+		t.Fatalf("Failed to parse list cursor: got '%s', '%s' and '%v'", prefix, last, ok)
+	}
+	for _, s := range []string{"", "my-key", listCursorPrefix, listCursorPrefix + "bXkt:", listCursorPrefix + "!:bXkt"} {
+		if _, _, ok := parseListCursor(s); ok {
+			t.Fatalf("Parsed invalid list cursor '%q'", s)
+		}
+	}
+}
+
+func TestConnectConfig(t *testing.T) {
+	ctx := context.Background()
+	for i, config := range []*Config{
+		{Region: "us-east-1"}, // 0: no table
+		{Table: "kes"},        // 1: no region
+	} {
+		if _, err := Connect(ctx, config); err == nil {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+	}
+}
+
+// mockTable is a minimal in-memory DynamoDB table with
+// a string partition key that scans items in name order.
+type mockTable struct {
+	mu    sync.Mutex
+	items map[string][]byte
+
+	segments atomic.Int64 // Number of Scan requests of a parallel scan
+}
+
+func newMockTable() *mockTable { return &mockTable{items: map[string][]byte{}} }
+
+func (m *mockTable) GetItem(_ context.Context, in *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := in.Key[attrName].(*types.AttributeValueMemberS).Value
+	value, ok := m.items[name]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		attrName:  &types.AttributeValueMemberS{Value: name},
+		attrValue: &types.AttributeValueMemberB{Value: bytes.Clone(value)},
+	}}, nil
+}
+
+func (m *mockTable) PutItem(_ context.Context, in *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := in.Item[attrName].(*types.AttributeValueMemberS).Value
+	if _, ok := m.items[name]; ok && aws.ToString(in.ConditionExpression) == "attribute_not_exists(#n)" {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+	m.items[name] = bytes.Clone(in.Item[attrValue].(*types.AttributeValueMemberB).Value)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *mockTable) DeleteItem(_ context.Context, in *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := in.Key[attrName].(*types.AttributeValueMemberS).Value
+	if _, ok := m.items[name]; !ok && aws.ToString(in.ConditionExpression) == "attribute_exists(#n)" {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+	delete(m.items, name)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (m *mockTable) Scan(_ context.Context, in *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var start, prefix string
+	if key, ok := in.ExclusiveStartKey[attrName].(*types.AttributeValueMemberS); ok {
+		start = key.Value
+	}
+	if p, ok := in.ExpressionAttributeValues[":prefix"].(*types.AttributeValueMemberS); ok {
+		prefix = p.Value
+	}
+
+	// Items are assigned to the segments of a parallel
+	// scan by the hash of their name.
+	inSegment := func(string) bool { return true }
+	if total := aws.ToInt32(in.TotalSegments); total > 0 {
+		m.segments.Add(1)
+		inSegment = func(name string) bool {
+			return int32(crc32.ChecksumIEEE([]byte(name))%uint32(total)) == aws.ToInt32(in.Segment)
+		}
+	}
+
+	names := slices.Sorted(func(yield func(string) bool) {
+		for name := range m.items {
+			if name > start && inSegment(name) && !yield(name) {
+				return
+			}
+		}
+	})
+
+	// Like DynamoDB, the limit applies to the evaluated
+	// items - before the filter expression.
+	var out dynamodb.ScanOutput
+	if limit := int(aws.ToInt32(in.Limit)); limit > 0 && len(names) > limit {
+		names = names[:limit]
+		out.LastEvaluatedKey = map[string]types.AttributeValue{
+			attrName: &types.AttributeValueMemberS{Value: names[limit-1]},
+		}
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			out.Items = append(out.Items, map[string]types.AttributeValue{
+				attrName: &types.AttributeValueMemberS{Value: name},
+			})
+		}
+	}
+	return &out, nil
+}
@@ -10,7 +10,10 @@
 package efs
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
@@ -21,8 +24,37 @@ import (
 	"github.com/minio/kes/internal/crypto"
 	"github.com/minio/kes/internal/fips"
 	"github.com/minio/kes/internal/keystore/fs"
+	kesdk "github.com/minio/kms-go/kes"
 )
 
+// MasterKey identifies a file containing a master key
+// and the cipher used to load it.
+type MasterKey struct {
+	Path   string // Path of the master key file
+	Cipher string // Either AES256 or ChaCha20
+}
+
+// Config is a structure containing the configuration
+// of an encrypted filesystem Store.
+type Config struct {
+	// Dir is the directory that contains the keys.
+	Dir string
+
+	// MasterKey is the master key used to encrypt
+	// keys.
+	MasterKey MasterKey
+
+	// PreviousMasterKeys are master keys that have
+	// been replaced by MasterKey. They are only used
+	// to decrypt keys that have been encrypted before
+	// the master key rotation.
+	//
+	// Open re-encrypts all such keys with MasterKey.
+	// Once Open succeeds, the previous master keys
+	// are no longer required.
+	PreviousMasterKeys []MasterKey
+}
+
 // NewStore returns a new Store that reads
 // from and writes to the given directory,
 // using encryption.
@@ -33,17 +65,52 @@ import (
 // It returns an error if dir exists but is
 // not a directory.
 func NewStore(keyPath string, keyCipher string, dir string) (*Store, error) {
-	fsStore, err := fs.NewStore(dir)
+	return Open(&Config{
+		Dir: dir,
+		MasterKey: MasterKey{
+			Path:   keyPath,
+			Cipher: keyCipher,
+		},
+	})
+}
+
+// Open returns a new Store that reads from and writes
+// to the configured directory, using encryption.
+//
+// If previous master keys are configured, Open rotates
+// the master key by re-encrypting all keys that are
+// encrypted with a previous master key. The Store keeps
+// serving keys encrypted with any of the master keys
+// while a rotation is in progress.
+func Open(config *Config) (*Store, error) {
+	fsStore, err := fs.NewStore(config.Dir)
 	if err != nil {
 		return nil, err
 	}
 
-	key, err := loadMasterKey(keyPath, keyCipher)
+	key, err := loadMasterKey(config.MasterKey.Path, config.MasterKey.Cipher)
 	if err != nil {
 		return nil, err
 	}
+	s := &Store{
+		key:     key,
+		keyID:   keyID(key),
+		fsStore: fsStore,
+	}
+	for _, prev := range config.PreviousMasterKeys {
+		key, err := loadMasterKey(prev.Path, prev.Cipher)
+		if err != nil {
+			return nil, err
+		}
+		s.prevKeys = append(s.prevKeys, key)
+	}
 
-	return &Store{key: key, fsStore: fsStore}, nil
+	if len(s.prevKeys) > 0 {
+		if err = s.Rotate(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
 }
 
 // loadMasterKey reads a secret key from a
@@ -90,8 +157,10 @@ func loadMasterKey(keyPath string, keyCipher string) (crypto.SecretKey, error) {
 // It implements the kms.Store interface and
 // acts as KMS abstraction over a filesystem.
 type Store struct {
-	key     crypto.SecretKey
-	fsStore *fs.Store
+	key      crypto.SecretKey
+	keyID    [keyIDSize]byte
+	prevKeys []crypto.SecretKey
+	fsStore  *fs.Store
 }
 
 func (s *Store) String() string { return "Encrypted Filesystem: " + s.fsStore.Dir() }
@@ -109,33 +178,65 @@ func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
 //
 // It returns kes.ErrKeyExists if such a file already exists.
 func (s *Store) Create(ctx context.Context, name string, value []byte) error {
-	context := fmt.Sprintf("name=%s", name)
-	encryptedValue, err := s.key.Encrypt(value, []byte(context))
+	encryptedValue, err := s.encrypt(name, value)
 	if err != nil {
 		return err
 	}
-
 	return s.fsStore.Create(ctx, name, encryptedValue)
 }
 
 // Get reads the content of the named file within the Conn
 // directory. It returns kes.ErrKeyNotFound if no such file
 // exists.
+//
+// It returns an error if the file content is not authentic,
+// for example, because the file has been modified or renamed.
 func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 	encryptedValue, err := s.fsStore.Get(ctx, name)
 	if err != nil {
 		return nil, err
 	}
 
-	context := fmt.Sprintf("name=%s", name)
-	value, err := s.key.Decrypt(encryptedValue, []byte(context))
+	value, _, err := s.decrypt(name, encryptedValue)
 	if err != nil {
 		return nil, err
 	}
-
 	return value, nil
 }
 
+// Rotate re-encrypts all keys that are not encrypted with
+// the current master key. It returns an error if any key
+// cannot be decrypted with either the current or any of
+// the previous master keys.
+//
+// Rotate can be called while the Store serves requests.
+// Each key is replaced atomically.
+func (s *Store) Rotate(ctx context.Context) error {
+	names, err := s.fsStore.Names(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		err = s.fsStore.Update(ctx, name, func(encryptedValue []byte) ([]byte, error) {
+			value, current, err := s.decrypt(name, bytes.Clone(encryptedValue))
+			if err != nil {
+				return nil, err
+			}
+			if current {
+				return encryptedValue, nil
+			}
+			return s.encrypt(name, value)
+		})
+		if errors.Is(err, kesdk.ErrKeyNotFound) { // Key has been deleted concurrently
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Delete deletes the named file within the Conn directory if
 // and only if it exists. It returns kes.ErrKeyNotFound if
 // no such file exists.
@@ -163,3 +264,96 @@ func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, strin
 func (s *Store) Close() error {
 	return s.fsStore.Close()
 }
+
+const (
+	// headerMagic identifies keys that are encrypted with
+	// an identifiable master key. Keys without the magic
+	// prefix have been encrypted by previous versions.
+	headerMagic = "kes\x00efs\x01"
+
+	keyIDSize  = 8
+	headerSize = len(headerMagic) + keyIDSize
+)
+
+// encrypt encrypts value with the current master key. The
+// returned ciphertext starts with a header that identifies
+// the master key. The header and key name are authenticated
+// as associated data.
+func (s *Store) encrypt(name string, value []byte) ([]byte, error) {
+	header := make([]byte, 0, headerSize)
+	header = append(header, headerMagic...)
+	header = append(header, s.keyID[:]...)
+
+	ciphertext, err := s.key.Encrypt(value, associatedData(header, name))
+	if err != nil {
+		return nil, err
+	}
+	return append(header, ciphertext...), nil
+}
+
+// decrypt decrypts the ciphertext of the named key. It
+// reports whether the ciphertext has been encrypted with
+// the current master key.
+//
+// Ciphertexts without header have been encrypted by
+// previous versions and are decrypted by trying all
+// master keys.
+func (s *Store) decrypt(name string, ciphertext []byte) ([]byte, bool, error) {
+	if len(ciphertext) > headerSize && string(ciphertext[:len(headerMagic)]) == headerMagic {
+		header, ciphertext := ciphertext[:headerSize], ciphertext[headerSize:]
+		id := header[len(headerMagic):]
+
+		for i, key := range s.keys() {
+			if k := keyID(key); !bytes.Equal(k[:], id) {
+				continue
+			}
+			value, err := key.Decrypt(ciphertext, associatedData(header, name))
+			if err != nil {
+				return nil, false, fmt.Errorf("efs: key '%s' is not authentic or has been renamed: %v", name, err)
+			}
+			return value, i == 0, nil
+		}
+		return nil, false, fmt.Errorf("efs: key '%s' is encrypted with an unknown master key '%x'", name, id)
+	}
+
+	var err error
+	for _, key := range s.keys() {
+		// Decrypt decrypts in-place and overwrites the
+		// ciphertext if it is not authentic. Hence, each
+		// key has to decrypt its own copy.
+		var value []byte
+		if value, err = key.Decrypt(bytes.Clone(ciphertext), associatedData(nil, name)); err == nil {
+			return value, false, nil
+		}
+	}
+	return nil, false, fmt.Errorf("efs: key '%s' is not authentic or has been renamed: %v", name, err)
+}
+
+// keys returns the current master key followed
+// by all previous master keys.
+func (s *Store) keys() []crypto.SecretKey {
+	keys := make([]crypto.SecretKey, 0, 1+len(s.prevKeys))
+	keys = append(keys, s.key)
+	return append(keys, s.prevKeys...)
+}
+
+// associatedData returns the associated data for
+// encrypting the named key. Keys encrypted by
+// previous versions have no header.
+func associatedData(header []byte, name string) []byte {
+	ad := make([]byte, 0, len(header)+5+len(name))
+	ad = append(ad, header...)
+	ad = append(ad, "name="...)
+	return append(ad, name...)
+}
+
+// keyID returns an identifier of the master key. It
+// does not reveal any information about the key itself.
+func keyID(key crypto.SecretKey) [keyIDSize]byte {
+	mac := hmac.New(sha256.New, key.Bytes())
+	mac.Write([]byte("kes efs master key id"))
+
+	var id [keyIDSize]byte
+	copy(id[:], mac.Sum(nil))
+	return id
+}
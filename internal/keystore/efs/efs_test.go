@@ -0,0 +1,90 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package efs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStoreRotate(t *testing.T) {
+	ctx := context.Background()
+	dir, keyDir := t.TempDir(), t.TempDir()
+	oldKey := writeMasterKey(t, keyDir, "old-key", "passwordpasswordpasswordpassword")
+	newKey := writeMasterKey(t, keyDir, "new-key", "drowssapdrowssapdrowssapdrowssap")
+
+	store, err := Open(&Config{Dir: dir, MasterKey: oldKey})
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", []byte("my-value")); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	// A ciphertext created by previous versions without header
+	legacy, _ := base64.StdEncoding.DecodeString("Eu4t1j1T8CuLjgxqZoCBXguh6DJ+Jg4oZyhPUE6CNsgeGGZ3UhxQ0Eozh1A0THfsx/EK9rc97V2RTg5U")
+	if err = os.WriteFile(filepath.Join(dir, "test-kek"), legacy, 0o600); err != nil {
+		t.Fatalf("Failed to write legacy key: %v", err)
+	}
+
+	store, err = Open(&Config{Dir: dir, MasterKey: newKey})
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	if _, err = store.Get(ctx, "my-key"); err == nil || !strings.Contains(err.Error(), "unknown master key") {
+		t.Fatalf("Decrypted key encrypted with unknown master key: %v", err)
+	}
+
+	for range 2 { // Rotating again must not modify already rotated keys
+		if _, err = Open(&Config{Dir: dir, MasterKey: newKey, PreviousMasterKeys: []MasterKey{oldKey}}); err != nil {
+			t.Fatalf("Failed to rotate master key: %v", err)
+		}
+	}
+	if store, err = Open(&Config{Dir: dir, MasterKey: newKey}); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	for name, value := range map[string]string{"my-key": "my-value", "test-kek": "my-plaintext-kek"} {
+		v, err := store.Get(ctx, name)
+		if err != nil {
+			t.Fatalf("Failed to get key '%s' after rotation: %v", name, err)
+		}
+		if !bytes.Equal(v, []byte(value)) {
+			t.Fatalf("Value mismatch for '%s': got '%s' - want '%s'", name, v, value)
+		}
+	}
+}
+
+func TestStoreRename(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	key := writeMasterKey(t, t.TempDir(), "key", "passwordpasswordpasswordpassword")
+
+	store, err := Open(&Config{Dir: dir, MasterKey: key})
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", []byte("my-value")); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = os.Rename(filepath.Join(dir, "my-key"), filepath.Join(dir, "other-key")); err != nil {
+		t.Fatalf("Failed to rename key: %v", err)
+	}
+	if _, err = store.Get(ctx, "other-key"); err == nil || !strings.Contains(err.Error(), "has been renamed") {
+		t.Fatalf("Decrypted renamed key: %v", err)
+	}
+}
+
+func writeMasterKey(t *testing.T, dir, name, key string) MasterKey {
+	filename := filepath.Join(dir, name)
+	if err := os.WriteFile(filename, []byte(key), 0o600); err != nil {
+		t.Fatalf("Failed to write master key: %v", err)
+	}
+	return MasterKey{Path: filename, Cipher: "AES256"}
+}
@@ -16,14 +16,18 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"aead.dev/mem"
 	"github.com/minio/kes"
+	"github.com/minio/kes/internal/headers"
 	xhttp "github.com/minio/kes/internal/http"
 	"github.com/minio/kes/internal/keystore"
 	kesdk "github.com/minio/kms-go/kes"
@@ -50,6 +54,10 @@ type Config struct {
 	// Fortanix SDKMS uses groups as collection of (security) objects.
 	// Typically, applications can access some/all objects within groups
 	// the application is assigned to.
+	//
+	// If set, only keys within this group are listed, fetched or
+	// deleted. Keys in other groups the application is assigned to
+	// are treated as if they would not exist.
 	GroupID string
 
 	// APIKey is the application's Fortanix SDKMS API key used to authenticate
@@ -237,15 +245,14 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 	if err != nil {
 		return fmt.Errorf("fortanix: failed to create key '%s': %v", name, err)
 	}
-	req.Header.Set("Authorization", s.config.APIKey.String())
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := s.client.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 			return err
 		}
-		return fmt.Errorf("fortanix: failed to create key '%s': %v", name, err)
+		return fmt.Errorf("fortanix: failed to create key '%s': %w", name, err)
 	}
 	if resp.StatusCode != http.StatusCreated {
 		switch err := parseErrorResponse(resp); {
@@ -290,15 +297,14 @@ func (s *Store) Delete(ctx context.Context, name string) error {
 	if err != nil {
 		return fmt.Errorf("fortanix: failed to delete '%s': %v", name, err)
 	}
-	req.Header.Set("Authorization", s.config.APIKey.String())
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := s.client.Do(req)
+	resp, err := s.do(req)
 	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return err
 	}
 	if err != nil {
-		return fmt.Errorf("fortanix: failed to delete '%s': %v", name, err)
+		return fmt.Errorf("fortanix: failed to delete '%s': %w", name, err)
 	}
 	if resp.StatusCode != http.StatusOK {
 		switch err = parseErrorResponse(resp); {
@@ -312,12 +318,16 @@ func (s *Store) Delete(ctx context.Context, name string) error {
 	}
 
 	type Response struct {
-		KeyID string `json:"kid"`
+		KeyID   string `json:"kid"`
+		GroupID string `json:"group_id"`
 	}
 	var response Response
 	if err := json.NewDecoder(mem.LimitReader(resp.Body, mem.MB)).Decode(&response); err != nil {
 		return fmt.Errorf("fortanix: failed to delete '%s': failed to parse key metadata: %v", name, err)
 	}
+	if s.config.GroupID != "" && response.GroupID != s.config.GroupID {
+		return kesdk.ErrKeyNotFound
+	}
 
 	// Now, we can delete the key using its key ID.
 	url = endpoint(s.config.Endpoint, "/crypto/v1/keys", response.KeyID)
@@ -325,14 +335,13 @@ func (s *Store) Delete(ctx context.Context, name string) error {
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", s.config.APIKey.String())
 
-	resp, err = s.client.Do(req)
+	resp, err = s.do(req)
 	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return err
 	}
 	if err != nil {
-		return fmt.Errorf("fortanix: failed to delete '%s': %v", name, err)
+		return fmt.Errorf("fortanix: failed to delete '%s': %w", name, err)
 	}
 	if resp.StatusCode != http.StatusNoContent {
 		switch err = parseErrorResponse(resp); err {
@@ -364,15 +373,14 @@ func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("fortanix: failed to fetch '%s': %v", name, err)
 	}
-	req.Header.Set("Authorization", s.config.APIKey.String())
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := s.client.Do(req)
+	resp, err := s.do(req)
 	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return nil, err
 	}
 	if err != nil {
-		return nil, fmt.Errorf("fortanix: failed to fetch '%s': %v", name, err)
+		return nil, fmt.Errorf("fortanix: failed to fetch '%s': %w", name, err)
 	}
 	if resp.StatusCode != http.StatusOK {
 		switch err = parseErrorResponse(resp); {
@@ -388,11 +396,15 @@ func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 	type Response struct {
 		Value   string `json:"value"`
 		Enabled bool   `json:"enabled"`
+		GroupID string `json:"group_id"`
 	}
 	var response Response
 	if err := json.NewDecoder(mem.LimitReader(resp.Body, mem.MB)).Decode(&response); err != nil {
 		return nil, fmt.Errorf("fortanix: failed to fetch '%s': failed to parse server response %v", name, err)
 	}
+	if s.config.GroupID != "" && response.GroupID != s.config.GroupID {
+		return nil, kesdk.ErrKeyNotFound
+	}
 	if !response.Enabled {
 		return nil, fmt.Errorf("fortanix: failed to fetch '%s': key has been disabled and cannot be used until enabled again", name)
 	}
@@ -415,50 +427,226 @@ func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 // An empty prefix matches any key name. At the end of the listing
 // or when there are no (more) keys starting with the prefix, the
 // returned prefix is empty.
+//
+// Fortanix DSM returns at most 100 keys per request. If there are
+// more keys, List splits the key names into ranges and lists them
+// concurrently.
 func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
-	var (
-		names []string
-		start = prefix
+	const (
+		Shards      = 16 // Number of key name ranges listed concurrently
+		Concurrency = 4  // Max. number of concurrent list requests
 	)
-	for {
-		reqURL := endpoint(s.config.Endpoint, "/crypto/v1/keys") + "?sort=name:asc&limit=100"
-		if start != "" {
-			reqURL += "&start=" + start
-		}
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
-		if err != nil {
-			return nil, "", fmt.Errorf("fortanix: failed to list keys: %v", err)
-		}
-		req.Header.Set("Authorization", s.config.APIKey.String())
 
-		resp, err := s.client.Do(req)
-		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+	// Most groups contain less than one page of keys.
+	// Hence, only split the listing if there are more.
+	names, err := s.listPage(ctx, prefix)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(names) == listPageSize {
+		ranges := keystore.Ranges(prefix, Shards)
+		names, err = keystore.ListConcurrent(ctx, len(ranges), Concurrency, func(ctx context.Context, i int) ([]string, error) {
+			return s.listRange(ctx, prefix, ranges[i])
+		})
+		if err != nil {
 			return nil, "", err
 		}
+	}
+	return keystore.List(names, prefix, n)
+}
+
+// listPageSize is the max. number of keys returned
+// by one Fortanix DSM list request.
+const listPageSize = 100
+
+// listRange returns all key names within the range r. If the
+// range is unbounded, it starts listing at the prefix.
+func (s *Store) listRange(ctx context.Context, prefix string, r keystore.Range) ([]string, error) {
+	start := prefix
+	if r.After != "" {
+		start = r.After
+	}
+
+	var names []string
+	for {
+		keys, err := s.listPage(ctx, start)
 		if err != nil {
-			return nil, "", fmt.Errorf("fortanix: failed to list keys: %v", err)
+			return nil, err
+		}
+		for _, name := range keys {
+			if len(names) > 0 && names[len(names)-1] == name {
+				continue // Consecutive pages overlap at the start key
+			}
+			if name == r.After {
+				continue // The start key is not within the range
+			}
+			if !r.Contains(name) {
+				return names, nil
+			}
+			names = append(names, name)
+		}
+		if len(keys) < listPageSize || keys[len(keys)-1] == start {
+			return names, nil
 		}
+		start = keys[len(keys)-1]
+	}
+}
 
-		type Response struct {
-			Name string `json:"name"`
+// listPage returns the names of up to listPageSize keys in
+// lexicographic order starting at the given key name.
+func (s *Store) listPage(ctx context.Context, start string) ([]string, error) {
+	query := url.Values{}
+	query.Set("sort", "name:asc")
+	query.Set("limit", strconv.Itoa(listPageSize))
+	if start != "" {
+		query.Set("start", start)
+	}
+	if s.config.GroupID != "" {
+		query.Set("group_id", s.config.GroupID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint(s.config.Endpoint, "/crypto/v1/keys")+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("fortanix: failed to list keys: %v", err)
+	}
+
+	resp, err := s.do(req)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fortanix: failed to list keys: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if err = parseErrorResponse(resp); err == nil {
+			err = fmt.Errorf("%s (%d)", resp.Status, resp.StatusCode)
 		}
-		var keys []Response
-		if err := json.NewDecoder(mem.LimitReader(resp.Body, 10*mem.MB)).Decode(&keys); err != nil {
-			return nil, "", fmt.Errorf("fortanix: failed to list keys: failed to parse server response: %v", err)
+		return nil, fmt.Errorf("fortanix: failed to list keys: %v", err)
+	}
+
+	type Response struct {
+		Name string `json:"name"`
+	}
+	var keys []Response
+	err = json.NewDecoder(mem.LimitReader(resp.Body, 10*mem.MB)).Decode(&keys)
+	xhttp.DrainBody(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("fortanix: failed to list keys: failed to parse server response: %v", err)
+	}
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, k.Name)
+	}
+	return names, nil
+}
+
+// Export returns the values of the keys with the given names,
+// for example to migrate keys to another key store. It exports
+// up to concurrency keys in parallel. If concurrency <= 0, it
+// defaults to 8.
+//
+// If Fortanix DSM throttles requests, Export waits and retries
+// the request. It returns an error if any key cannot be exported.
+func (s *Store) Export(ctx context.Context, names []string, concurrency int) (map[string][]byte, error) {
+	if concurrency <= 0 {
+		concurrency = 8
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		mu     sync.Mutex
+		values = make(map[string][]byte, len(names))
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, concurrency)
+	)
+	for _, name := range names {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
 		}
-		if len(keys) == 0 {
+		if ctx.Err() != nil {
 			break
 		}
-		for _, k := range keys {
-			names = append(names, k.Name)
-		}
+
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+
+			for {
+				value, err := s.Get(ctx, name)
+				if throttled, ok := keystore.IsThrottled(err); ok {
+					select {
+					case <-time.After(throttled.RetryAfter()):
+						continue
+					case <-ctx.Done():
+						return
+					}
+				}
+				if err != nil {
+					cancel(fmt.Errorf("fortanix: failed to export '%s': %w", name, err))
+					return
+				}
+
+				mu.Lock()
+				values[name] = value
+				mu.Unlock()
+				return
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
 	}
-	return keystore.List(names, prefix, n)
+	return values, nil
 }
 
 // Close closes the Store.
 func (s *Store) Close() error { return nil }
 
+// do sends the request authenticated with the API key.
+//
+// If Fortanix DSM rate-limits the request with 429 Too Many
+// Requests, do returns a *keystore.ErrThrottled such that
+// clients retry the request later.
+func (s *Store) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", s.config.APIKey.String())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		delay := retryAfter(resp.Header.Get(headers.RetryAfter), time.Now())
+		return nil, &keystore.ErrThrottled{
+			Delay: delay,
+			Err:   parseErrorResponse(resp),
+		}
+	}
+	return resp, nil
+}
+
+// retryAfter parses the value of a Retry-After header. It
+// is either a number of seconds or an HTTP date. It returns
+// zero if the value is empty or invalid.
+func retryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
 // parseErrorResponse returns an error containing
 // the response status code and response body
 // as error message if the response is an error
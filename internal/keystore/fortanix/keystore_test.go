@@ -0,0 +1,204 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package fortanix
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestStoreGroup(t *testing.T) {
+	srv := newDSM(250)
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	ctx := context.Background()
+	store := &Store{config: Config{Endpoint: server.URL, GroupID: "group-1"}}
+	if _, err := store.Get(ctx, "key-000"); err != nil {
+		t.Fatalf("Failed to fetch key: %v", err)
+	}
+	if _, err := store.Get(ctx, "key-001"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Fetched key of another group: %v", err)
+	}
+	if err := store.Delete(ctx, "key-001"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Deleted key of another group: %v", err)
+	}
+
+	names, _, err := store.List(ctx, "", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if len(names) != 125 {
+		t.Fatalf("Listed %d keys - want 125", len(names))
+	}
+	if !slices.IsSorted(names) || len(slices.Compact(slices.Clone(names))) != len(names) {
+		t.Fatalf("Listed keys are not sorted or contain duplicates: %v", names)
+	}
+}
+
+func TestStoreExport(t *testing.T) {
+	srv := newDSM(250)
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	ctx := context.Background()
+	store := &Store{config: Config{Endpoint: server.URL}}
+	names, _, err := store.List(ctx, "", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if len(names) != 250 {
+		t.Fatalf("Listed %d keys - want 250", len(names))
+	}
+
+	srv.throttle.Store(10)
+	values, err := store.Export(ctx, names, 4)
+	if err != nil {
+		t.Fatalf("Failed to export keys: %v", err)
+	}
+	if len(values) != len(names) {
+		t.Fatalf("Exported %d keys - want %d", len(values), len(names))
+	}
+	for _, name := range names {
+		if string(values[name]) != "value-"+name {
+			t.Fatalf("Value mismatch for '%s': got '%s'", name, values[name])
+		}
+	}
+
+	if _, err = store.Export(ctx, []string{"key-000", "does-not-exist"}, 0); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Exported non-existing key: %v", err)
+	}
+}
+
+func TestStoreThrottled(t *testing.T) {
+	srv := newDSM(1)
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	srv.throttle.Store(1)
+	store := &Store{config: Config{Endpoint: server.URL}}
+	_, err := store.Get(context.Background(), "key-000")
+
+	throttled, ok := keystore.IsThrottled(err)
+	if !ok {
+		t.Fatalf("Expected throttled error: %v", err)
+	}
+	if throttled.Delay != 0 || throttled.RetryAfter() != time.Second {
+		t.Fatalf("Retry delay mismatch: got '%v' - want '%v'", throttled.RetryAfter(), time.Second)
+	}
+	apiErr, ok := api.IsError(err)
+	if !ok || apiErr.Status() != http.StatusServiceUnavailable {
+		t.Fatalf("Throttled error is not a 503 API error: %v", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for i, test := range retryAfterTests {
+		if d := retryAfter(test.Value, now); d != test.Delay {
+			t.Fatalf("Test %d: delay mismatch: got '%v' - want '%v'", i, d, test.Delay)
+		}
+	}
+}
+
+var retryAfterTests = []struct {
+	Value string
+	Delay time.Duration
+}{
+	{Value: "", Delay: 0},                // 0
+	{Value: "5", Delay: 5 * time.Second}, // 1
+	{Value: "-1", Delay: 0},              // 2
+	{Value: "Fri, 01 Mar 2024 12:00:30 GMT", Delay: 30 * time.Second}, // 3
+	{Value: "Fri, 01 Mar 2024 11:00:00 GMT", Delay: 0},                // 4
+	{Value: "soon", Delay: 0},                                         // 5
+}
+
+// dsm is a minimal Fortanix DSM server. Keys with an even
+// index belong to "group-1", all others to "group-2".
+type dsm struct {
+	keys     []string
+	throttle atomic.Int64 // Number of requests to reject with 429
+}
+
+func newDSM(n int) *dsm {
+	d := &dsm{}
+	for i := range n {
+		d.keys = append(d.keys, fmt.Sprintf("key-%03d", i))
+	}
+	return d
+}
+
+func (d *dsm) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if d.throttle.Add(-1) >= 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"message":"too many requests"}`))
+		return
+	}
+	d.throttle.Store(0)
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/crypto/v1/keys/export":
+		var req struct {
+			Name string `json:"name"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		i := slices.Index(d.keys, req.Name)
+		if i < 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"sobject does not exist"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"kid":      strconv.Itoa(i),
+			"value":    base64.StdEncoding.EncodeToString([]byte("value-" + req.Name)),
+			"enabled":  true,
+			"group_id": group(i),
+		})
+	case r.Method == http.MethodGet && r.URL.Path == "/crypto/v1/keys":
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		start, groupID := r.URL.Query().Get("start"), r.URL.Query().Get("group_id")
+
+		type Key struct {
+			Name string `json:"name"`
+		}
+		keys := []Key{}
+		for i, name := range d.keys {
+			if name < start || (groupID != "" && group(i) != groupID) {
+				continue
+			}
+			if len(keys) == limit {
+				break
+			}
+			keys = append(keys, Key{Name: name})
+		}
+		json.NewEncoder(w).Encode(keys)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func group(i int) string {
+	if i%2 == 0 {
+		return "group-1"
+	}
+	return "group-2"
+}
@@ -9,10 +9,13 @@ package fs
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -23,6 +26,79 @@ import (
 	kesdk "github.com/minio/kms-go/kes"
 )
 
+// SyncMode controls when a Store flushes written keys
+// to stable storage.
+type SyncMode uint
+
+const (
+	// SyncAlways flushes key files and their parent directory
+	// before a create or delete returns. A key that has been
+	// created successfully survives a system crash.
+	SyncAlways SyncMode = iota
+
+	// SyncData flushes key files but not their parent directory.
+	// A crash cannot corrupt a key but may lose keys that have
+	// been created or deleted shortly before the crash.
+	SyncData
+
+	// SyncNone never flushes explicitly and leaves it to the
+	// operating system. A crash may lose any key that has been
+	// created recently.
+	SyncNone
+)
+
+// ParseSyncMode parses s as SyncMode. It accepts
+// "always", "data" and "none". An empty string is
+// parsed as SyncAlways.
+func ParseSyncMode(s string) (SyncMode, error) {
+	switch s {
+	case "", "always":
+		return SyncAlways, nil
+	case "data":
+		return SyncData, nil
+	case "none":
+		return SyncNone, nil
+	default:
+		return 0, errors.New("fs: invalid sync mode '" + s + "'")
+	}
+}
+
+// String returns the string representation of the SyncMode.
+func (m SyncMode) String() string {
+	switch m {
+	case SyncAlways:
+		return "always"
+	case SyncData:
+		return "data"
+	case SyncNone:
+		return "none"
+	default:
+		return "invalid"
+	}
+}
+
+// Config is a structure containing the configuration
+// of a filesystem Store.
+type Config struct {
+	// Dir is the directory that contains the keys.
+	Dir string
+
+	// Sync controls when keys are flushed to stable storage.
+	// The zero value is SyncAlways.
+	Sync SyncMode
+
+	// Sharding stores keys within two levels of sub-directories
+	// based on the SHA-256 hash of the key name instead of
+	// storing all keys within Dir. It keeps directories small
+	// when storing many keys.
+	//
+	// Opening an existing, unsharded directory with Sharding
+	// enabled moves all keys into the sharded layout. Once
+	// sharded, the directory can only be opened with Sharding
+	// enabled.
+	Sharding bool
+}
+
 // NewStore returns a new Store that reads
 // from and writes to the given directory.
 //
@@ -31,7 +107,19 @@ import (
 //
 // It returns an error if dir exists but is
 // not a directory.
-func NewStore(dir string) (*Store, error) {
+func NewStore(dir string) (*Store, error) { return Open(&Config{Dir: dir}) }
+
+// Open returns a new Store that reads from and writes
+// to the configured directory.
+//
+// If the directory or any parent directory does not
+// exist, Open creates them all. It returns an error
+// if the directory exists but is not a directory.
+//
+// Open removes any temporary files left over by writes
+// that have been interrupted by a crash.
+func Open(config *Config) (*Store, error) {
+	dir := config.Dir
 	switch file, err := os.Stat(dir); {
 	case errors.Is(err, os.ErrNotExist):
 		if err = os.MkdirAll(dir, 0o755); err != nil {
@@ -44,7 +132,30 @@ func NewStore(dir string) (*Store, error) {
 			return nil, errors.New("fs: '" + dir + "' is not a directory")
 		}
 	}
-	return &Store{dir: dir}, nil
+	if config.Sync > SyncNone {
+		return nil, errors.New("fs: invalid sync mode")
+	}
+
+	s := &Store{
+		dir:      dir,
+		sync:     config.Sync,
+		sharding: config.Sharding,
+	}
+	_, err := os.Stat(filepath.Join(dir, shardMarker))
+	switch {
+	case err == nil && !s.sharding:
+		return nil, errors.New("fs: '" + dir + "' uses sharding but sharding is disabled")
+	case errors.Is(err, os.ErrNotExist) && s.sharding:
+		if err = s.shard(); err != nil {
+			return nil, err
+		}
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+	if err = s.removeTemp(); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
 // Store is a connection to a directory on
@@ -53,8 +164,10 @@ func NewStore(dir string) (*Store, error) {
 // It implements the kms.Store interface and
 // acts as KMS abstraction over a filesystem.
 type Store struct {
-	dir  string
-	lock sync.RWMutex
+	dir      string
+	sync     SyncMode
+	sharding bool
+	lock     sync.RWMutex
 }
 
 func (s *Store) String() string { return "Filesystem: " + s.dir }
@@ -80,6 +193,10 @@ func (s *Store) Status(context.Context) (kes.KeyStoreState, error) {
 // the Conn directory if and only if no such file exists.
 //
 // It returns kes.ErrKeyExists if such a file already exists.
+//
+// Create writes the value to a temporary file first and
+// only then links it to its final name. Hence, a crash
+// never leaves a partially written key behind.
 func (s *Store) Create(_ context.Context, name string, value []byte) error {
 	if err := validName(name); err != nil {
 		return err
@@ -87,12 +204,10 @@ func (s *Store) Create(_ context.Context, name string, value []byte) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	filename := filepath.Join(s.dir, name)
-	switch err := s.create(filename, value); {
+	switch err := s.write(s.path(name), value, false); {
 	case errors.Is(err, os.ErrExist):
 		return kesdk.ErrKeyExists
 	case err != nil:
-		os.Remove(filename)
 		return err
 	}
 	return nil
@@ -102,31 +217,38 @@ func (s *Store) Create(_ context.Context, name string, value []byte) error {
 // directory. It returns kes.ErrKeyNotFound if no such file
 // exists.
 func (s *Store) Get(_ context.Context, name string) ([]byte, error) {
-	const MaxSize = 1 * mem.MiB
-
 	if err := validName(name); err != nil {
 		return nil, err
 	}
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
-	file, err := os.Open(filepath.Join(s.dir, name))
-	if errors.Is(err, os.ErrNotExist) {
-		return nil, kesdk.ErrKeyNotFound
-	}
-	if err != nil {
-		return nil, err
+	return readFile(s.path(name))
+}
+
+// Update replaces the content of the named file within the
+// Conn directory with the value returned by fn. It passes
+// the current content to fn and returns kes.ErrKeyNotFound
+// if no such file exists.
+//
+// Update holds the store lock while calling fn. Hence, no
+// concurrent create or delete can interleave with it.
+func (s *Store) Update(_ context.Context, name string, fn func(value []byte) ([]byte, error)) error {
+	if err := validName(name); err != nil {
+		return err
 	}
-	defer file.Close()
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
-	value, err := io.ReadAll(mem.LimitReader(file, MaxSize))
+	filename := s.path(name)
+	value, err := readFile(filename)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if err = file.Close(); err != nil {
-		return nil, err
+	if value, err = fn(value); err != nil {
+		return err
 	}
-	return value, nil
+	return s.write(filename, value, true)
 }
 
 // Delete deletes the named file within the Conn directory if
@@ -136,16 +258,22 @@ func (s *Store) Delete(_ context.Context, name string) error {
 	if err := validName(name); err != nil {
 		return err
 	}
-	switch err := os.Remove(filepath.Join(s.dir, name)); {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	filename := s.path(name)
+	switch err := os.Remove(filename); {
 	case errors.Is(err, os.ErrNotExist):
 		return kesdk.ErrKeyNotFound
-	default:
+	case err != nil:
 		return err
 	}
+	if s.sync == SyncAlways {
+		return syncDir(filepath.Dir(filename))
+	}
+	return nil
 }
 
-// List returns a new Iterator over the names of
-// all stored keys.
 // List returns the first n key names, that start with the given
 // prefix, and the next prefix from which the listing should
 // continue.
@@ -157,35 +285,78 @@ func (s *Store) Delete(_ context.Context, name string) error {
 // or when there are no (more) keys starting with the prefix, the
 // returned prefix is empty
 func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
-	dir, err := os.Open(s.dir)
+	names, err := s.Names(ctx)
 	if err != nil {
 		return nil, "", err
 	}
-	defer dir.Close()
+	return keystore.List(names, prefix, n)
+}
 
-	names, err := dir.Readdirnames(-1)
-	if err != nil {
-		return nil, "", err
-	}
-	select {
-	case <-ctx.Done():
-		if err := ctx.Err(); err != nil {
-			return nil, "", err
+// Names returns the names of all stored keys
+// in no particular order.
+func (s *Store) Names(ctx context.Context) ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var names []string
+	err := s.walk(ctx, func(_, name string) error {
+		if validName(name) == nil {
+			names = append(names, name)
 		}
-		return nil, "", context.Canceled
-	default:
-		return keystore.List(names, prefix, n)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return names, nil
 }
 
 // Close closes the Store.
 func (s *Store) Close() error { return nil }
 
-func (s *Store) create(filename string, value []byte) error {
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+// shardMarker is the name of the file that marks a
+// directory as sharded. It is not a valid key name.
+const shardMarker = ".sharded"
+
+// tempPrefix is the name prefix of temporary files.
+// It is not a valid key name.
+const tempPrefix = ".tmp-"
+
+// shardPrefix is the name prefix of keys that are
+// moved into the sharded layout. It is not a valid
+// key name.
+const shardPrefix = ".shard-"
+
+// path returns the path of the file that
+// stores the key with the given name.
+func (s *Store) path(name string) string {
+	if !s.sharding {
+		return filepath.Join(s.dir, name)
+	}
+	h := sha256.Sum256([]byte(name))
+	shard := hex.EncodeToString(h[:2])
+	return filepath.Join(s.dir, shard[:2], shard[2:], name)
+}
+
+// write writes value to a temporary file within the
+// directory of filename and moves it to filename.
+//
+// If replace is false, it returns an error wrapping
+// os.ErrExist if filename already exists. Otherwise,
+// it atomically replaces any existing file.
+func (s *Store) write(filename string, value []byte, replace bool) error {
+	dir := filepath.Dir(filename)
+	if s.sharding {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.CreateTemp(dir, tempPrefix)
 	if err != nil {
 		return err
 	}
+	defer os.Remove(file.Name())
 	defer file.Close()
 
 	n, err := file.Write(value)
@@ -195,6 +366,218 @@ func (s *Store) create(filename string, value []byte) error {
 	if n != len(value) {
 		return io.ErrShortWrite
 	}
+	if s.sync != SyncNone {
+		if err = file.Sync(); err != nil {
+			return err
+		}
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	if replace {
+		if err = os.Rename(file.Name(), filename); err != nil {
+			return err
+		}
+		if s.sync == SyncAlways {
+			return syncDir(dir)
+		}
+		return nil
+	}
+
+	// A hard link, unlike a rename, never replaces an existing
+	// file. Fall back to a rename for filesystems that don't
+	// support hard links. The store lock prevents concurrent
+	// creates from replacing each other's files.
+	if err = os.Link(file.Name(), filename); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return err
+		}
+		if _, err = os.Lstat(filename); err == nil {
+			return os.ErrExist
+		}
+		if err = os.Rename(file.Name(), filename); err != nil {
+			return err
+		}
+	}
+	if s.sync == SyncAlways {
+		return syncDir(dir)
+	}
+	return nil
+}
+
+// walk calls fn for every file that may store a key.
+// It passes the file's directory and name to fn.
+func (s *Store) walk(ctx context.Context, fn func(dir, name string) error) error {
+	if !s.sharding {
+		return walkDir(ctx, s.dir, fn)
+	}
+
+	shards, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, shard := range shards {
+		if !shard.IsDir() || !isShard(shard.Name()) {
+			continue
+		}
+		dir := filepath.Join(s.dir, shard.Name())
+		subShards, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, subShard := range subShards {
+			if !subShard.IsDir() || !isShard(subShard.Name()) {
+				continue
+			}
+			if err = walkDir(ctx, filepath.Join(dir, subShard.Name()), fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// shard moves all keys stored directly within the
+// store directory into the sharded layout and marks
+// the directory as sharded.
+//
+// It renames all keys first such that no key named
+// like a shard directory prevents creating it. If
+// interrupted, shard continues with renamed keys
+// when called again.
+func (s *Store) shard() error {
+	var names []string
+	if err := walkDir(context.Background(), s.dir, func(_, name string) error {
+		switch {
+		case validName(name) == nil:
+			if err := os.Rename(filepath.Join(s.dir, name), filepath.Join(s.dir, shardPrefix+name)); err != nil {
+				return err
+			}
+			names = append(names, name)
+		case strings.HasPrefix(name, shardPrefix) && validName(strings.TrimPrefix(name, shardPrefix)) == nil:
+			names = append(names, strings.TrimPrefix(name, shardPrefix))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		filename := s.path(name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(s.dir, shardPrefix+name), filename); err != nil {
+			return err
+		}
+		if s.sync == SyncAlways {
+			if err := syncDir(filepath.Dir(filename)); err != nil {
+				return err
+			}
+		}
+	}
+
+	marker, err := os.OpenFile(filepath.Join(s.dir, shardMarker), os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if err = marker.Close(); err != nil {
+		return err
+	}
+	if s.sync != SyncNone {
+		return syncDir(s.dir)
+	}
+	return nil
+}
+
+// removeTemp removes all temporary files left
+// over by interrupted writes.
+func (s *Store) removeTemp() error {
+	remove := func(dir, name string) error {
+		if !strings.HasPrefix(name, tempPrefix) {
+			return nil
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if s.sharding { // Writes before sharding the directory may have left temporary files behind.
+		if err := walkDir(context.Background(), s.dir, remove); err != nil {
+			return err
+		}
+	}
+	return s.walk(context.Background(), remove)
+}
+
+// readFile reads the content of the named file. It
+// returns kes.ErrKeyNotFound if no such file exists.
+func readFile(filename string) ([]byte, error) {
+	const MaxSize = 1 * mem.MiB
+
+	file, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, kesdk.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	value, err := io.ReadAll(mem.LimitReader(file, MaxSize))
+	if err != nil {
+		return nil, err
+	}
+	if err = file.Close(); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// walkDir calls fn for every regular file within dir.
+func walkDir(ctx context.Context, dir string, fn func(dir, name string) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if err = fn(dir, entry.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isShard reports whether name is the name
+// of a shard directory.
+func isShard(name string) bool {
+	if len(name) != 2 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil && strings.ToLower(name) == name
+}
+
+// syncDir flushes the directory entries of dir to
+// stable storage such that creating, renaming or
+// removing a file within dir survives a crash.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil // Windows does not support syncing directories
+	}
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
 	if err = file.Sync(); err != nil {
 		return err
 	}
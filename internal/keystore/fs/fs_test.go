@@ -4,7 +4,17 @@
 
 package fs
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	kesdk "github.com/minio/kms-go/kes"
+)
 
 var validNameTests = []struct {
 	Name  string
@@ -31,3 +41,116 @@ func TestValidName(t *testing.T) {
 		}
 	}
 }
+
+func TestStore(t *testing.T) {
+	for _, sharding := range []bool{false, true} {
+		ctx := context.Background()
+		store, err := Open(&Config{Dir: t.TempDir(), Sharding: sharding})
+		if err != nil {
+			t.Fatalf("Sharding %v: failed to open store: %v", sharding, err)
+		}
+
+		if err = store.Create(ctx, "my-key", []byte("value")); err != nil {
+			t.Fatalf("Sharding %v: failed to create key: %v", sharding, err)
+		}
+		if err = store.Create(ctx, "my-key", []byte("other-value")); !errors.Is(err, kesdk.ErrKeyExists) {
+			t.Fatalf("Sharding %v: created existing key: %v", sharding, err)
+		}
+		if value, err := store.Get(ctx, "my-key"); err != nil || string(value) != "value" {
+			t.Fatalf("Sharding %v: failed to get key: got '%s' - %v", sharding, value, err)
+		}
+		if names, _, err := store.List(ctx, "", -1); err != nil || !slices.Equal(names, []string{"my-key"}) {
+			t.Fatalf("Sharding %v: failed to list keys: got '%v' - %v", sharding, names, err)
+		}
+		if err = store.Delete(ctx, "my-key"); err != nil {
+			t.Fatalf("Sharding %v: failed to delete key: %v", sharding, err)
+		}
+		if _, err = store.Get(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+			t.Fatalf("Sharding %v: fetched deleted key: %v", sharding, err)
+		}
+	}
+}
+
+func TestStoreSharding(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	var keys []string
+	for i := range 64 {
+		keys = append(keys, fmt.Sprintf("%02x", i)) // Keys named like shard directories
+		keys = append(keys, fmt.Sprintf("key-%d", i))
+	}
+	for _, name := range keys {
+		if err = store.Create(ctx, name, []byte(name)); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	// Simulate an interrupted write
+	if err = os.WriteFile(filepath.Join(dir, tempPrefix+"123"), nil, 0o600); err != nil {
+		t.Fatalf("Failed to create temp. file: %v", err)
+	}
+
+	store, err = Open(&Config{Dir: dir, Sharding: true})
+	if err != nil {
+		t.Fatalf("Failed to shard store: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read store directory: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != shardMarker && (!entry.IsDir() || !isShard(entry.Name())) {
+			t.Fatalf("Unexpected entry '%s' in sharded directory", entry.Name())
+		}
+	}
+
+	names, _, err := store.List(ctx, "", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(names, keys) {
+		t.Fatalf("Listed keys mismatch: got '%v' - want '%v'", names, keys)
+	}
+	for _, name := range keys {
+		if value, err := store.Get(ctx, name); err != nil || string(value) != name {
+			t.Fatalf("Failed to get key '%s': got '%s' - %v", name, value, err)
+		}
+	}
+
+	if _, err = NewStore(dir); err == nil {
+		t.Fatal("Opened sharded directory without sharding")
+	}
+}
+
+func TestParseSyncMode(t *testing.T) {
+	for i, test := range parseSyncModeTests {
+		mode, err := ParseSyncMode(test.String)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to parse sync mode: %v", i, err)
+		}
+		if mode != test.Mode {
+			t.Fatalf("Test %d: sync mode mismatch: got '%v' - want '%v'", i, mode, test.Mode)
+		}
+	}
+}
+
+var parseSyncModeTests = []struct {
+	String     string
+	Mode       SyncMode
+	ShouldFail bool
+}{
+	{String: "", Mode: SyncAlways},       // 0
+	{String: "always", Mode: SyncAlways}, // 1
+	{String: "data", Mode: SyncData},     // 2
+	{String: "none", Mode: SyncNone},     // 3
+	{String: "Always", ShouldFail: true}, // 4
+	{String: "fsync", ShouldFail: true},  // 5
+}
@@ -6,10 +6,18 @@ package gcp
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"maps"
 	"net/url"
+	"os"
+	"path"
+	"slices"
 	"strings"
 	"sync"
+
+	secretmanagerpb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 )
 
 // Credentials represent GCP service account credentials.
@@ -79,10 +87,54 @@ type Config struct {
 	// ProjectID is the ID of the GCP project.
 	ProjectID string
 
+	// Region pins the SecretManager to a region, for example
+	// "europe-west3". If set, secrets are regional secrets
+	// that are stored and processed in the region only. Unless
+	// an Endpoint is specified, the regional endpoint
+	//   secretmanager.<region>.rep.googleapis.com
+	// is used. Regional secrets cannot be replicated.
+	Region string
+
 	// Credentials are the GCP credentials to
 	// access the SecretManager.
 	Credentials Credentials
 
+	// ExternalAccountFile is the path to a workload identity
+	// federation credential configuration file. It allows
+	// workloads running outside GCP, e.g. on AWS, Azure or
+	// Kubernetes, to impersonate a GCP service account
+	// without a service account key. Such files are created
+	// by 'gcloud iam workload-identity-pools create-cred-config'.
+	//
+	// It must not be set when Credentials are specified.
+	ExternalAccountFile string
+
+	// KMSKey is the resource name of a Cloud KMS key (CMEK) used
+	// to encrypt automatically replicated secrets. It must be a
+	// key in the "global" location. For example:
+	//   projects/<project>/locations/global/keyRings/<ring>/cryptoKeys/<key>
+	//
+	// For regional secrets, it must be a key in the Region.
+	//
+	// If empty, secrets are encrypted with Google-managed keys.
+	// KMSKey is ignored if Replicas are specified.
+	KMSKey string
+
+	// Replicas are the locations secrets are replicated to. If
+	// not empty, secrets use a user-managed replication policy
+	// instead of being replicated automatically.
+	Replicas []Replica
+
+	// Labels are attached to all secrets created by the Store.
+	// If not empty, the Store only lists, reads and deletes
+	// secrets with matching labels. Hence, multiple KES clusters
+	// can share one GCP project by using distinct labels.
+	//
+	// Label keys must start with a lowercase letter. Keys and
+	// values may only contain lowercase letters, digits, '_'
+	// and '-' and must not be longer than 63 characters.
+	Labels map[string]string
+
 	// Scopes are GCP OAuth2 scopes for accessing GCP APIs.
 	// If not set, defaults to the GCP default scopes.
 	//
@@ -108,10 +160,21 @@ func (c *Config) Clone() *Config {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 	clone := &Config{
-		Endpoint:    c.Endpoint,
-		ProjectID:   c.ProjectID,
-		Credentials: c.Credentials,
-		ErrorLog:    c.ErrorLog,
+		Endpoint:            c.Endpoint,
+		ProjectID:           c.ProjectID,
+		Region:              c.Region,
+		Credentials:         c.Credentials,
+		ExternalAccountFile: c.ExternalAccountFile,
+		KMSKey:              c.KMSKey,
+		ErrorLog:            c.ErrorLog,
+	}
+	if len(c.Labels) > 0 {
+		clone.Labels = make(map[string]string, len(c.Labels))
+		maps.Copy(clone.Labels, c.Labels)
+	}
+	if len(c.Replicas) > 0 {
+		clone.Replicas = make([]Replica, 0, len(c.Replicas))
+		clone.Replicas = append(clone.Replicas, c.Replicas...)
 	}
 	if len(c.Scopes) > 0 {
 		clone.Scopes = make([]string, 0, len(c.Scopes))
@@ -119,3 +182,261 @@ func (c *Config) Clone() *Config {
 	}
 	return clone
 }
+
+// Replica is a SecretManager replica location of a secret
+// with a user-managed replication policy.
+type Replica struct {
+	// Location is the GCP region of the replica. For
+	// example, "us-east1".
+	Location string
+
+	// KMSKey is the resource name of a Cloud KMS key (CMEK)
+	// used to encrypt the replica. It must be a key in the
+	// replica's location. If empty, the replica is encrypted
+	// with a Google-managed key.
+	KMSKey string
+}
+
+// newSecret returns the SecretManager secret, without a name,
+// created with the given config. Regional secrets are encrypted
+// with the config's KMS key, if any, and not replicated. All
+// other secrets have a replication policy.
+func newSecret(c *Config) (*secretmanagerpb.Secret, error) {
+	if c.Region == "" {
+		replication, err := replication(c)
+		if err != nil {
+			return nil, err
+		}
+		return &secretmanagerpb.Secret{
+			Replication: replication,
+			Labels:      c.Labels,
+		}, nil
+	}
+
+	if !validRegion(c.Region) {
+		return nil, fmt.Errorf("gcp: invalid region '%s'", c.Region)
+	}
+	if len(c.Replicas) > 0 {
+		return nil, fmt.Errorf("gcp: invalid replicas: regional secrets are stored in region '%s' and cannot be replicated", c.Region)
+	}
+	secret := &secretmanagerpb.Secret{
+		Labels: c.Labels,
+	}
+	if c.KMSKey != "" {
+		location, err := kmsKeyLocation(c.KMSKey)
+		if err != nil {
+			return nil, err
+		}
+		if location != c.Region {
+			return nil, fmt.Errorf("gcp: invalid KMS key '%s': key location '%s' does not match region '%s'", c.KMSKey, location, c.Region)
+		}
+		secret.CustomerManagedEncryption = &secretmanagerpb.CustomerManagedEncryption{
+			KmsKeyName: c.KMSKey,
+		}
+	}
+	return secret, nil
+}
+
+// parent returns the resource name of the location secrets
+// are created in. Regional secrets reside within the region:
+//
+//	projects/<project>/locations/<region>
+func parent(c *Config) string {
+	if c.Region != "" {
+		return path.Join("projects", c.ProjectID, "locations", c.Region)
+	}
+	return path.Join("projects", c.ProjectID)
+}
+
+// validRegion reports whether region is a valid GCP region
+// name, like "us-east1", that can be part of an endpoint.
+func validRegion(region string) bool {
+	if region == "" {
+		return false
+	}
+	for _, r := range region {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// replication returns the SecretManager replication policy
+// for secrets created with the given config.
+func replication(c *Config) (*secretmanagerpb.Replication, error) {
+	if len(c.Replicas) == 0 {
+		automatic := &secretmanagerpb.Replication_Automatic{}
+		if c.KMSKey != "" {
+			location, err := kmsKeyLocation(c.KMSKey)
+			if err != nil {
+				return nil, err
+			}
+			if location != "global" {
+				return nil, fmt.Errorf("gcp: invalid KMS key '%s': automatic replication requires a key in the 'global' location", c.KMSKey)
+			}
+			automatic.CustomerManagedEncryption = &secretmanagerpb.CustomerManagedEncryption{
+				KmsKeyName: c.KMSKey,
+			}
+		}
+		return &secretmanagerpb.Replication{
+			Replication: &secretmanagerpb.Replication_Automatic_{
+				Automatic: automatic,
+			},
+		}, nil
+	}
+
+	replicas := make([]*secretmanagerpb.Replication_UserManaged_Replica, 0, len(c.Replicas))
+	locations := make(map[string]bool, len(c.Replicas))
+	for _, r := range c.Replicas {
+		if r.Location == "" {
+			return nil, errors.New("gcp: invalid replica: no location specified")
+		}
+		if locations[r.Location] {
+			return nil, fmt.Errorf("gcp: invalid replica: location '%s' specified more than once", r.Location)
+		}
+		locations[r.Location] = true
+
+		replica := &secretmanagerpb.Replication_UserManaged_Replica{
+			Location: r.Location,
+		}
+		if r.KMSKey != "" {
+			location, err := kmsKeyLocation(r.KMSKey)
+			if err != nil {
+				return nil, err
+			}
+			if location != r.Location {
+				return nil, fmt.Errorf("gcp: invalid KMS key '%s': key location '%s' does not match replica location '%s'", r.KMSKey, location, r.Location)
+			}
+			replica.CustomerManagedEncryption = &secretmanagerpb.CustomerManagedEncryption{
+				KmsKeyName: r.KMSKey,
+			}
+		}
+		replicas = append(replicas, replica)
+	}
+	return &secretmanagerpb.Replication{
+		Replication: &secretmanagerpb.Replication_UserManaged_{
+			UserManaged: &secretmanagerpb.Replication_UserManaged{
+				Replicas: replicas,
+			},
+		},
+	}, nil
+}
+
+// validateLabels returns an error if labels contains a label
+// that is not a valid SecretManager label.
+func validateLabels(labels map[string]string) error {
+	const MaxLabels = 64
+	if len(labels) > MaxLabels {
+		return fmt.Errorf("gcp: invalid labels: more than %d labels specified", MaxLabels)
+	}
+	for key, value := range labels {
+		if key == "" || key[0] < 'a' || key[0] > 'z' || !isLabel(key) {
+			return fmt.Errorf("gcp: invalid label key '%s': must start with a lowercase letter and contain only lowercase letters, digits, '_' and '-'", key)
+		}
+		if !isLabel(value) {
+			return fmt.Errorf("gcp: invalid label value '%s': must contain only lowercase letters, digits, '_' and '-'", value)
+		}
+	}
+	return nil
+}
+
+// isLabel reports whether s is a valid label key or value
+// without checking the first character of label keys.
+func isLabel(s string) bool {
+	const MaxLength = 63
+	if len(s) > MaxLength {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// labelFilter returns a SecretManager list filter that matches
+// secrets with all the given labels. For example:
+//
+//	labels.cluster="kes-1" AND labels.env="prod"
+func labelFilter(labels map[string]string) string {
+	filters := make([]string, 0, len(labels))
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		filters = append(filters, "labels."+key+"=\""+labels[key]+"\"")
+	}
+	return strings.Join(filters, " AND ")
+}
+
+// hasLabels reports whether the secret labels contain all
+// of the given labels.
+func hasLabels(secret, labels map[string]string) bool {
+	for key, value := range labels {
+		if v, ok := secret[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// kmsKeyLocation returns the location of the Cloud KMS key
+// with the given resource name:
+//
+//	projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>
+func kmsKeyLocation(name string) (string, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 8 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "keyRings" || parts[6] != "cryptoKeys" {
+		return "", fmt.Errorf("gcp: invalid KMS key '%s': expected 'projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>'", name)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return "", fmt.Errorf("gcp: invalid KMS key '%s': expected 'projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>'", name)
+		}
+	}
+	return parts[3], nil
+}
+
+// ReadExternalAccount reads and validates the workload identity
+// federation credential configuration file. Credential configurations
+// may reference arbitrary URLs and executables. Hence, only
+// external account configurations that exchange tokens with the GCP
+// security token service are accepted.
+func ReadExternalAccount(filename string) ([]byte, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("gcp: failed to read external account credentials: %v", err)
+	}
+
+	var config struct {
+		Type             string          `json:"type"`
+		Audience         string          `json:"audience"`
+		SubjectTokenType string          `json:"subject_token_type"`
+		TokenURL         string          `json:"token_url"`
+		CredentialSource json.RawMessage `json:"credential_source"`
+		Impersonation    string          `json:"service_account_impersonation_url"`
+	}
+	if err = json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("gcp: invalid external account credentials '%s': %v", filename, err)
+	}
+	if config.Type != "external_account" {
+		return nil, fmt.Errorf("gcp: invalid external account credentials '%s': type is '%s' - expected 'external_account'", filename, config.Type)
+	}
+	if !strings.HasPrefix(config.Audience, "//iam.googleapis.com/") {
+		return nil, fmt.Errorf("gcp: invalid external account credentials '%s': audience '%s' is not a workload identity pool provider", filename, config.Audience)
+	}
+	if config.SubjectTokenType == "" {
+		return nil, fmt.Errorf("gcp: invalid external account credentials '%s': no subject token type specified", filename)
+	}
+	if len(config.CredentialSource) == 0 || string(config.CredentialSource) == "null" {
+		return nil, fmt.Errorf("gcp: invalid external account credentials '%s': no credential source specified", filename)
+	}
+	if u, err := url.Parse(config.TokenURL); err != nil || u.Scheme != "https" || !strings.HasSuffix(u.Hostname(), ".googleapis.com") {
+		return nil, fmt.Errorf("gcp: invalid external account credentials '%s': token URL '%s' is not a GCP security token service", filename, config.TokenURL)
+	}
+	if config.Impersonation != "" {
+		if u, err := url.Parse(config.Impersonation); err != nil || u.Scheme != "https" || !strings.HasSuffix(u.Hostname(), ".googleapis.com") {
+			return nil, fmt.Errorf("gcp: invalid external account credentials '%s': service account impersonation URL '%s' is not a GCP IAM endpoint", filename, config.Impersonation)
+		}
+	}
+	return b, nil
+}
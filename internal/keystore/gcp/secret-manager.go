@@ -40,9 +40,14 @@ func Connect(ctx context.Context, c *Config) (*Store, error) {
 	}
 
 	var options []option.ClientOption
-	if c.Endpoint != "" {
+	switch {
+	case c.Endpoint != "":
 		options = append(options, option.WithEndpoint(c.Endpoint))
-	} else {
+	case c.Region != "" && validRegion(c.Region):
+		host := "secretmanager." + c.Region + ".rep.googleapis.com"
+		options = append(options, option.WithEndpoint(host+":443"))
+		c.Endpoint = "https://" + host
+	default:
 		const DefaultEndpoint = "https://secretmanager.googleapis.com" // From the GCP SDK
 		c.Endpoint = DefaultEndpoint
 	}
@@ -52,6 +57,22 @@ func Connect(ctx context.Context, c *Config) (*Store, error) {
 	// the GCP SDK will pick them up automatically. In this case
 	// the user does not have to provide login credentials at all.
 	empty := Credentials{}
+	if c.Credentials != empty && c.ExternalAccountFile != "" {
+		return nil, errors.New("gcp: service account credentials and external account credentials must not both be specified")
+	}
+	if _, err := newSecret(c); err != nil {
+		return nil, err
+	}
+	if err := validateLabels(c.Labels); err != nil {
+		return nil, err
+	}
+	if c.ExternalAccountFile != "" {
+		credentialsJSON, err := ReadExternalAccount(c.ExternalAccountFile)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, option.WithCredentialsJSON(credentialsJSON))
+	}
 	if c.Credentials != empty {
 		// We do a sanity check here to ensure that the user
 		// actually provided some login credentials. However,
@@ -102,7 +123,12 @@ func Connect(ctx context.Context, c *Config) (*Store, error) {
 	return conn, nil
 }
 
-func (s *Store) String() string { return "GCP SecretManager: Project=" + s.config.ProjectID }
+func (s *Store) String() string {
+	if s.config.Region != "" {
+		return "GCP SecretManager: Project=" + s.config.ProjectID + " Region=" + s.config.Region
+	}
+	return "GCP SecretManager: Project=" + s.config.ProjectID
+}
 
 // Status returns the current state of the GCP SecretManager instance.
 // In particular, whether it is reachable and the network latency.
@@ -131,17 +157,19 @@ func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
 // Creating a secret at the GCP SecretManager requires first creating
 // secret itself and then adding a secret version with some payload
 // data. The payload data contains the actual value.
+//
+// The secret is either replicated automatically or to the configured
+// replica locations, and encrypted with the configured KMS keys, if any.
+// Regional secrets are not replicated but stored in the region only.
 func (s *Store) Create(ctx context.Context, name string, value []byte) error {
-	secret, err := s.client.CreateSecret(ctx, &secretmanagerpb.CreateSecretRequest{
-		Parent:   path.Join("projects", s.config.ProjectID),
+	secret, err := newSecret(s.config)
+	if err != nil {
+		return fmt.Errorf("gcp: failed to create '%s': %v", name, err)
+	}
+	secret, err = s.client.CreateSecret(ctx, &secretmanagerpb.CreateSecretRequest{
+		Parent:   parent(s.config),
 		SecretId: name,
-		Secret: &secretmanagerpb.Secret{
-			Replication: &secretmanagerpb.Replication{
-				Replication: &secretmanagerpb.Replication_Automatic_{
-					Automatic: &secretmanagerpb.Replication_Automatic{},
-				},
-			},
-		},
+		Secret:   secret,
 	})
 	if err != nil {
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
@@ -180,9 +208,20 @@ func (s *Store) Set(ctx context.Context, name string, value []byte) error {
 }
 
 // Get returns the value associated with the given key.
+//
+// If labels are configured, Get returns kes.ErrKeyNotFound
+// if the secret does not have matching labels.
 func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
+	if len(s.config.Labels) > 0 {
+		if _, err := s.getSecret(ctx, name); err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, kesdk.ErrKeyNotFound) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("gcp: failed to read '%s': %v", name, err)
+		}
+	}
 	result, err := s.client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{
-		Name: path.Join("projects", s.config.ProjectID, "secrets", name, "versions", "1"),
+		Name: path.Join(parent(s.config), "secrets", name, "versions", "1"),
 	})
 	if err != nil {
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
@@ -204,9 +243,24 @@ func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 // versions through e.g. the GCP CLI. However, KES does not
 // support multiple secret versions and expects a different
 // mechanism for "key-rotation".
+//
+// If labels are configured, Delete returns kes.ErrKeyNotFound
+// if the secret does not have matching labels.
 func (s *Store) Delete(ctx context.Context, name string) error {
+	var etag string
+	if len(s.config.Labels) > 0 {
+		secret, err := s.getSecret(ctx, name)
+		if err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, kesdk.ErrKeyNotFound) {
+				return err
+			}
+			return fmt.Errorf("gcp: failed to delete '%s': %v", name, err)
+		}
+		etag = secret.Etag // Fail if the secret, and its labels, changed concurrently
+	}
 	err := s.client.DeleteSecret(ctx, &secretmanagerpb.DeleteSecretRequest{
-		Name: path.Join("projects", s.config.ProjectID, "secrets", name),
+		Name: path.Join(parent(s.config), "secrets", name),
+		Etag: etag,
 	})
 	if err != nil {
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
@@ -232,11 +286,13 @@ func (s *Store) Delete(ctx context.Context, name string) error {
 // An empty prefix matches any key name. At the end of the listing
 // or when there are no (more) keys starting with the prefix, the
 // returned prefix is empty.
+//
+// If labels are configured, List only returns the names of
+// secrets with matching labels.
 func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
-	location := path.Join("projects", s.config.ProjectID)
-
 	iter := s.client.ListSecrets(ctx, &secretmanagerpb.ListSecretsRequest{
-		Parent: location,
+		Parent: parent(s.config),
+		Filter: labelFilter(s.config.Labels),
 	})
 
 	var names []string
@@ -249,5 +305,24 @@ func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, strin
 	return keystore.List(names, prefix, n)
 }
 
+// getSecret returns the secret metadata with the given name.
+// It returns kes.ErrKeyNotFound if no such secret exists or
+// the secret does not have matching labels.
+func (s *Store) getSecret(ctx context.Context, name string) (*secretmanagerpb.Secret, error) {
+	secret, err := s.client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{
+		Name: path.Join(parent(s.config), "secrets", name),
+	})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, kesdk.ErrKeyNotFound
+		}
+		return nil, err
+	}
+	if !hasLabels(secret.Labels, s.config.Labels) {
+		return nil, kesdk.ErrKeyNotFound
+	}
+	return secret, nil
+}
+
 // Close closes the Store.
 func (s *Store) Close() error { return nil }
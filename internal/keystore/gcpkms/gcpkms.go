@@ -0,0 +1,298 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package gcpkms implements a key store that wraps all entries
+// with a Google Cloud KMS key before storing them at another key
+// store, like a GCS bucket or the GCP SecretManager.
+//
+// The Cloud KMS key never leaves Cloud KMS. With the HSM protection
+// level, it never leaves the Cloud HSM. Hence, the key material of
+// KES can only be unwrapped by Cloud KMS while KES keeps serving
+// all requests from its key cache.
+package gcpkms
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/keystore"
+	"github.com/minio/kes/internal/keystore/gcp"
+	"google.golang.org/api/option"
+)
+
+// wrappedHeader is the prefix of all entries wrapped by Cloud KMS.
+var wrappedHeader = []byte("kes\x00gcpkms\x01")
+
+// Config is a structure containing the Cloud KMS configuration.
+type Config struct {
+	// Endpoint is the Cloud KMS endpoint. If empty,
+	// the global endpoint of the GCP SDK is used.
+	Endpoint string
+
+	// Key is the resource name of the Cloud KMS key wrapping
+	// all entries. It must be a symmetric encryption key:
+	//   projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>
+	Key string
+
+	// RequireHSM requires the primary version of the Key to
+	// be protected by a Cloud HSM. If the Key uses software
+	// or external protection, Connect fails.
+	RequireHSM bool
+
+	// Credentials are the GCP service account credentials
+	// to access Cloud KMS.
+	Credentials gcp.Credentials
+
+	// ExternalAccountFile is the path to a workload identity
+	// federation credential configuration file. It must not
+	// be set when Credentials are specified.
+	//
+	// If neither Credentials nor ExternalAccountFile are set,
+	// the credentials provided by the environment are used.
+	ExternalAccountFile string
+
+	// Store is the key store at which wrapped entries are stored.
+	Store kes.KeyStore
+}
+
+// client encrypts and decrypts with a Cloud KMS key.
+type client interface {
+	// Encrypt encrypts the plaintext with the latest
+	// version of the key.
+	Encrypt(ctx context.Context, plaintext, associatedData []byte) ([]byte, error)
+
+	// Decrypt decrypts the ciphertext with the key
+	// version that produced it.
+	Decrypt(ctx context.Context, ciphertext, associatedData []byte) ([]byte, error)
+
+	// Describe returns the key's purpose and the protection
+	// level of its primary version.
+	Describe(ctx context.Context) (kmspb.CryptoKey_CryptoKeyPurpose, kmspb.ProtectionLevel, error)
+
+	// Close closes the client connection.
+	Close() error
+}
+
+// Connect connects to Cloud KMS and returns a new Store that
+// wraps all entries before storing them at the config's key
+// store.
+func Connect(ctx context.Context, config *Config) (*Store, error) {
+	if config.Key == "" {
+		return nil, errors.New("gcpkms: no key specified")
+	}
+	if !validKeyName(config.Key) {
+		return nil, fmt.Errorf("gcpkms: invalid key '%s': expected 'projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>'", config.Key)
+	}
+	if config.Store == nil {
+		return nil, errors.New("gcpkms: no key store specified")
+	}
+
+	var options []option.ClientOption
+	if config.Endpoint != "" {
+		options = append(options, option.WithEndpoint(config.Endpoint))
+	}
+	empty := gcp.Credentials{}
+	if config.Credentials != empty && config.ExternalAccountFile != "" {
+		return nil, errors.New("gcpkms: service account credentials and external account credentials must not both be specified")
+	}
+	if config.ExternalAccountFile != "" {
+		credentialsJSON, err := gcp.ReadExternalAccount(config.ExternalAccountFile)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, option.WithCredentialsJSON(credentialsJSON))
+	}
+	if config.Credentials != empty {
+		if config.Credentials.Client == "" {
+			return nil, errors.New("gcpkms: no client email provided")
+		}
+		if config.Credentials.ClientID == "" {
+			return nil, errors.New("gcpkms: no client ID provided")
+		}
+		if config.Credentials.Key == "" {
+			return nil, errors.New("gcpkms: no client private key provided")
+		}
+		if config.Credentials.KeyID == "" {
+			return nil, errors.New("gcpkms: no client private key ID provided")
+		}
+		credentialsJSON, err := config.Credentials.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, option.WithCredentialsJSON(credentialsJSON))
+	}
+
+	c, err := kms.NewKeyManagementClient(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	store, err := newStore(ctx, &kmsClient{client: c, key: config.Key}, config)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// newStore returns a new Store after checking that the key
+// can be used for wrapping entries.
+func newStore(ctx context.Context, c client, config *Config) (*Store, error) {
+	purpose, level, err := c.Describe(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("gcpkms: failed to fetch key '%s': %v", config.Key, err)
+	}
+	if purpose != kmspb.CryptoKey_ENCRYPT_DECRYPT {
+		return nil, fmt.Errorf("gcpkms: invalid key '%s': purpose is '%s' - expected '%s'", config.Key, purpose, kmspb.CryptoKey_ENCRYPT_DECRYPT)
+	}
+	if config.RequireHSM && level != kmspb.ProtectionLevel_HSM {
+		return nil, fmt.Errorf("gcpkms: invalid key '%s': protection level is '%s' - expected '%s'", config.Key, level, kmspb.ProtectionLevel_HSM)
+	}
+	return &Store{client: c, key: config.Key, store: config.Store}, nil
+}
+
+// Store is a key store that wraps all entries with a Cloud KMS
+// key before storing them at another key store.
+type Store struct {
+	client client
+	key    string
+	store  kes.KeyStore
+}
+
+func (s *Store) String() string { return "GCP KMS: Key=" + s.key + " " + fmt.Sprint(s.store) }
+
+// Status returns the current state of the underlying key store.
+// It returns an error if Cloud KMS is not reachable.
+func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	if _, _, err := s.client.Describe(ctx); err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return kes.KeyStoreState{}, err
+		}
+		return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+	}
+	return s.store.Status(ctx)
+}
+
+// Create wraps the value and creates a new entry at the
+// underlying key store if and only if no entry with the
+// given name exists.
+func (s *Store) Create(ctx context.Context, name string, value []byte) error {
+	ciphertext, err := s.client.Encrypt(ctx, value, associatedData(name))
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return fmt.Errorf("gcpkms: failed to wrap entry '%s': %v", name, err)
+	}
+
+	wrapped := make([]byte, 0, len(wrappedHeader)+len(ciphertext))
+	wrapped = append(wrapped, wrappedHeader...)
+	wrapped = append(wrapped, ciphertext...)
+	return s.store.Create(ctx, name, wrapped)
+}
+
+// Delete removes the entry from the underlying key store.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	return s.store.Delete(ctx, name)
+}
+
+// Get returns the unwrapped value of the entry.
+func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
+	wrapped, err := s.store.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(wrapped, wrappedHeader) {
+		return nil, fmt.Errorf("gcpkms: entry '%s' is not wrapped", name)
+	}
+
+	value, err := s.client.Decrypt(ctx, wrapped[len(wrappedHeader):], associatedData(name))
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("gcpkms: failed to unwrap entry '%s': %v", name, err)
+	}
+	return value, nil
+}
+
+// List returns the names of all entries of the underlying
+// key store that start with the prefix.
+func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	return s.store.List(ctx, prefix, n)
+}
+
+// Close closes the Cloud KMS connection and the underlying
+// key store.
+func (s *Store) Close() error {
+	return errors.Join(s.client.Close(), s.store.Close())
+}
+
+// associatedData binds a wrapped value to its entry name
+// such that entries cannot be swapped.
+func associatedData(name string) []byte {
+	return append(bytes.Clone(wrappedHeader), "name="+name...)
+}
+
+// validKeyName reports whether name is the resource name
+// of a Cloud KMS key:
+//
+//	projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>
+func validKeyName(name string) bool {
+	parts := strings.Split(name, "/")
+	if len(parts) != 8 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "keyRings" || parts[6] != "cryptoKeys" {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// kmsClient implements client using the Cloud KMS SDK.
+type kmsClient struct {
+	client *kms.KeyManagementClient
+	key    string
+}
+
+func (c *kmsClient) Encrypt(ctx context.Context, plaintext, associatedData []byte) ([]byte, error) {
+	resp, err := c.client.Encrypt(ctx, &kmspb.EncryptRequest{
+		Name:                        c.key,
+		Plaintext:                   plaintext,
+		AdditionalAuthenticatedData: associatedData,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Ciphertext, nil
+}
+
+func (c *kmsClient) Decrypt(ctx context.Context, ciphertext, associatedData []byte) ([]byte, error) {
+	resp, err := c.client.Decrypt(ctx, &kmspb.DecryptRequest{
+		Name:                        c.key,
+		Ciphertext:                  ciphertext,
+		AdditionalAuthenticatedData: associatedData,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+func (c *kmsClient) Describe(ctx context.Context) (kmspb.CryptoKey_CryptoKeyPurpose, kmspb.ProtectionLevel, error) {
+	key, err := c.client.GetCryptoKey(ctx, &kmspb.GetCryptoKeyRequest{Name: c.key})
+	if err != nil {
+		return 0, 0, err
+	}
+	return key.GetPurpose(), key.GetPrimary().GetProtectionLevel(), nil
+}
+
+func (c *kmsClient) Close() error { return c.client.Close() }
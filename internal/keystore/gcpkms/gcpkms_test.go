@@ -0,0 +1,163 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package gcpkms
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"slices"
+	"testing"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/minio/kes"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+const testKey = "projects/kes/locations/global/keyRings/kes/cryptoKeys/kes-root"
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	mem := &kes.MemKeyStore{}
+	store, err := newStore(ctx, newSoftKMS(t, kmspb.ProtectionLevel_HSM), &Config{Key: testKey, RequireHSM: true, Store: mem})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	value := []byte("my-secret-value")
+	if err = store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", value); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Created key twice: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, value, err)
+	}
+	if _, err = store.Get(ctx, "missing-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Fetched non-existing key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+
+	// The key material must only be stored wrapped.
+	wrapped, err := mem.Get(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to get wrapped key: %v", err)
+	}
+	if !bytes.HasPrefix(wrapped, wrappedHeader) || bytes.Contains(wrapped, value) {
+		t.Fatalf("Key is not wrapped: got '%x'", wrapped)
+	}
+
+	// Wrapped entries are bound to their name.
+	if err = mem.Create(ctx, "other-key", wrapped); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if _, err = store.Get(ctx, "other-key"); err == nil {
+		t.Fatal("Unwrapped entry stored under a different name")
+	}
+	if err = mem.Create(ctx, "plain-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if _, err = store.Get(ctx, "plain-key"); err == nil {
+		t.Fatal("Read entry that is not wrapped")
+	}
+
+	if names, _, err := store.List(ctx, "", -1); err != nil || !slices.Equal(names, []string{"my-key", "other-key", "plain-key"}) {
+		t.Fatalf("Failed to list keys: got '%v': %v", names, err)
+	}
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if err = store.Delete(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Deleted key twice: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	ctx := context.Background()
+	for i, test := range []struct {
+		Purpose    kmspb.CryptoKey_CryptoKeyPurpose
+		Level      kmspb.ProtectionLevel
+		RequireHSM bool
+		ShouldFail bool
+	}{
+		{Purpose: kmspb.CryptoKey_ENCRYPT_DECRYPT, Level: kmspb.ProtectionLevel_HSM, RequireHSM: true},                        // 0
+		{Purpose: kmspb.CryptoKey_ENCRYPT_DECRYPT, Level: kmspb.ProtectionLevel_SOFTWARE},                                     // 1
+		{Purpose: kmspb.CryptoKey_ENCRYPT_DECRYPT, Level: kmspb.ProtectionLevel_SOFTWARE, RequireHSM: true, ShouldFail: true}, // 2
+		{Purpose: kmspb.CryptoKey_ASYMMETRIC_DECRYPT, Level: kmspb.ProtectionLevel_HSM, ShouldFail: true},                     // 3
+	} {
+		kms := newSoftKMS(t, test.Level)
+		kms.purpose = test.Purpose
+
+		_, err := newStore(ctx, kms, &Config{Key: testKey, RequireHSM: test.RequireHSM, Store: &kes.MemKeyStore{}})
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to create store: %v", i, err)
+		}
+	}
+}
+
+func TestConnectConfig(t *testing.T) {
+	ctx := context.Background()
+	for i, config := range []*Config{
+		{Store: &kes.MemKeyStore{}},                         // 0: no key
+		{Key: "kes-root", Store: &kes.MemKeyStore{}},        // 1: invalid key
+		{Key: "projects/kes/locations/global/keyRings/kes"}, // 2: invalid key
+		{Key: testKey}, // 3: no key store
+		{Key: testKey, ExternalAccountFile: "./non-existing"}, // 4: no external account file
+	} {
+		if _, err := Connect(ctx, config); err == nil {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+	}
+}
+
+// softKMS is an in-memory Cloud KMS key.
+type softKMS struct {
+	aead    cipher.AEAD
+	purpose kmspb.CryptoKey_CryptoKeyPurpose
+	level   kmspb.ProtectionLevel
+}
+
+func newSoftKMS(t *testing.T, level kmspb.ProtectionLevel) *softKMS {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("Failed to create AES cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("Failed to create AES-GCM: %v", err)
+	}
+	return &softKMS{aead: aead, purpose: kmspb.CryptoKey_ENCRYPT_DECRYPT, level: level}
+}
+
+func (k *softKMS) Encrypt(_ context.Context, plaintext, associatedData []byte) ([]byte, error) {
+	iv := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(iv, iv, plaintext, associatedData), nil
+}
+
+func (k *softKMS) Decrypt(_ context.Context, ciphertext, associatedData []byte) ([]byte, error) {
+	if len(ciphertext) < k.aead.NonceSize() {
+		return nil, errors.New("invalid ciphertext")
+	}
+	iv := ciphertext[:k.aead.NonceSize()]
+	return k.aead.Open(nil, iv, ciphertext[len(iv):], associatedData)
+}
+
+func (k *softKMS) Describe(context.Context) (kmspb.CryptoKey_CryptoKeyPurpose, kmspb.ProtectionLevel, error) {
+	return k.purpose, k.level, nil
+}
+
+func (k *softKMS) Close() error { return nil }
@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"sync"
 	"time"
@@ -21,9 +22,16 @@ import (
 // authToken is a KeySecure authentication token.
 // It can be used to authenticate API requests.
 type authToken struct {
-	Type   string
-	Value  string
-	Expiry time.Duration
+	Type     string
+	Value    string
+	Expiry   time.Duration
+	IssuedAt time.Time
+}
+
+// Expired reports whether the authentication token
+// has expired at the given point in time.
+func (t *authToken) Expired(now time.Time) bool {
+	return t.Value == "" || now.After(t.IssuedAt.Add(t.Expiry))
 }
 
 // String returns the string representation of
@@ -38,6 +46,8 @@ type client struct {
 
 	lock  sync.Mutex
 	token authToken
+
+	loginLock sync.Mutex // Serializes re-authentication on 401 Unauthorized
 }
 
 // Authenticate tries to obtain a new authentication token
@@ -105,14 +115,75 @@ func (c *client) Authenticate(ctx context.Context, endpoint string, login Creden
 
 	c.lock.Lock()
 	c.token = authToken{
-		Type:   response.Type,
-		Value:  response.Token,
-		Expiry: time.Duration(response.Expiry) * time.Second,
+		Type:     response.Type,
+		Value:    response.Token,
+		Expiry:   time.Duration(response.Expiry) * time.Second,
+		IssuedAt: time.Now(),
 	}
 	c.lock.Unlock()
 	return nil
 }
 
+// Send sends the request to KeySecure authenticated with the
+// client's current authentication token.
+//
+// If the token has expired, e.g. because renewing it failed,
+// or KeySecure rejects it with 401 Unauthorized, e.g. because
+// it has been revoked, Send re-authenticates and retries the
+// request once. Concurrent requests share one re-authentication
+// such that a rejected token does not cause a login storm.
+//
+// If non-nil, the request body must implement io.Seeker.
+func (c *client) Send(ctx context.Context, endpoint string, login Credentials, req *http.Request) (*http.Response, error) {
+	c.lock.Lock()
+	token, expired := c.token.String(), c.token.Expired(time.Now())
+	c.lock.Unlock()
+
+	if expired {
+		if err := c.reauthenticate(ctx, endpoint, login, token); err != nil {
+			return nil, err
+		}
+		token = c.AuthToken()
+	}
+	req.Header.Set("Authorization", token)
+	resp, err := c.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	xhttp.DrainBody(resp.Body)
+
+	if err = c.reauthenticate(ctx, endpoint, login, token); err != nil {
+		return nil, err
+	}
+	if req.Body != nil {
+		seeker, ok := req.Body.(io.Seeker)
+		if !ok {
+			return nil, errors.New("request body does not implement io.Seeker")
+		}
+		if _, err = seeker.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	req.Header.Set("Authorization", c.AuthToken())
+	return c.Do(req)
+}
+
+// reauthenticate obtains a new authentication token unless
+// the client's token differs from the stale token, i.e. another
+// request has already re-authenticated in the meantime.
+func (c *client) reauthenticate(ctx context.Context, endpoint string, login Credentials, stale string) error {
+	c.loginLock.Lock()
+	defer c.loginLock.Unlock()
+
+	if c.AuthToken() != stale {
+		return nil
+	}
+	if err := c.Authenticate(ctx, endpoint, login); err != nil {
+		return fmt.Errorf("failed to re-authenticate: %w", err)
+	}
+	return nil
+}
+
 // RenewAuthToken tries to renew the client's authentication
 // token before it expires. It blocks until <-ctx.Done() completes.
 //
@@ -0,0 +1,125 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package gemalto
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	xhttp "github.com/minio/kes/internal/http"
+)
+
+func TestClientSend(t *testing.T) {
+	srv := &keySecure{}
+	server := httptest.NewServer(srv)
+	defer server.Close()
+
+	ctx := context.Background()
+	c := &client{}
+	if err := c.Authenticate(ctx, server.URL, Credentials{Token: "refresh-token"}); err != nil {
+		t.Fatalf("Failed to authenticate: %v", err)
+	}
+
+	send := func(body string) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/api/v1/vault/secrets", xhttp.RetryReader(bytes.NewReader([]byte(body))))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := c.Send(ctx, server.URL, Credentials{Token: "refresh-token"}, req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Request failed: %s", resp.Status)
+		}
+		if b, _ := io.ReadAll(resp.Body); string(b) != body {
+			t.Fatalf("Request body mismatch: got '%s' - want '%s'", b, body)
+		}
+	}
+
+	send("my-secret")
+	if n := srv.logins.Load(); n != 1 {
+		t.Fatalf("Client authenticated %d times - want 1", n)
+	}
+
+	// Revoking the token must cause exactly one re-authentication,
+	// even when multiple requests get rejected concurrently.
+	srv.Revoke()
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			send(fmt.Sprintf("my-secret-%d", i))
+		}()
+	}
+	wg.Wait()
+	if n := srv.logins.Load(); n != 2 {
+		t.Fatalf("Client authenticated %d times - want 2", n)
+	}
+
+	// An expired token must be renewed before sending a request.
+	c.lock.Lock()
+	c.token.IssuedAt = time.Now().Add(-2 * c.token.Expiry)
+	c.lock.Unlock()
+	send("my-secret")
+	if n := srv.logins.Load(); n != 3 {
+		t.Fatalf("Client authenticated %d times - want 3", n)
+	}
+	if n := srv.rejected.Load(); n > 8 {
+		t.Fatalf("KeySecure rejected %d requests - want at most 8", n)
+	}
+}
+
+// keySecure is a minimal KeySecure server that issues
+// authentication tokens and echos authenticated requests.
+type keySecure struct {
+	lock  sync.Mutex
+	token string
+
+	logins   atomic.Int64
+	rejected atomic.Int64
+}
+
+// Revoke revokes the current authentication token.
+func (s *keySecure) Revoke() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.token = ""
+}
+
+func (s *keySecure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/v1/auth/tokens" {
+		n := s.logins.Add(1)
+		s.lock.Lock()
+		s.token = fmt.Sprintf("token-%d", n)
+		s.lock.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"token_type":"Bearer","jwt":"token-%d","duration":300}`, n)
+		return
+	}
+
+	s.lock.Lock()
+	token := s.token
+	s.lock.Unlock()
+	if token == "" || r.Header.Get("Authorization") != "Bearer "+token {
+		s.rejected.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	io.Copy(w, r.Body)
+}
@@ -56,8 +56,18 @@ type Config struct {
 	// KeySecure instance and obtain a short-lived authentication
 	// token.
 	Login Credentials
+
+	// PoolSize is the max. number of idle connections kept
+	// open to the KeySecure instance. Reusing connections
+	// avoids a TCP and TLS handshake per request. If 0,
+	// defaults to DefaultPoolSize.
+	PoolSize int
 }
 
+// DefaultPoolSize is the default max. number of idle
+// connections to a KeySecure instance.
+const DefaultPoolSize = 32
+
 // Store is a Gemalto KeySecure secret store.
 type Store struct {
 	config Config
@@ -76,6 +86,10 @@ func Connect(ctx context.Context, config *Config) (c *Store, err error) {
 		}
 	}
 
+	poolSize := config.PoolSize
+	if poolSize <= 0 {
+		poolSize = DefaultPoolSize
+	}
 	client := &client{
 		Retry: xhttp.Retry{
 			Client: http.Client{
@@ -91,8 +105,9 @@ func Connect(ctx context.Context, config *Config) (c *Store, err error) {
 						DualStack: true,
 					}).DialContext,
 					ForceAttemptHTTP2:     true,
-					MaxIdleConns:          100,
-					IdleConnTimeout:       30 * time.Second,
+					MaxIdleConns:          poolSize,
+					MaxIdleConnsPerHost:   poolSize,
+					IdleConnTimeout:       90 * time.Second,
 					TLSHandshakeTimeout:   10 * time.Second,
 					ExpectContinueTimeout: 1 * time.Second,
 				},
@@ -159,9 +174,8 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 		return fmt.Errorf("gemalto: failed to create key '%s': %v", name, err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", s.client.AuthToken())
 
-	resp, err := s.client.Do(req)
+	resp, err := s.client.Send(ctx, s.config.Endpoint, s.config.Login, req)
 	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return err
 	}
@@ -202,9 +216,8 @@ func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("gemalto: failed to access key '%s': %v", name, err)
 	}
-	req.Header.Set("Authorization", s.client.AuthToken())
 
-	resp, err := s.client.Do(req)
+	resp, err := s.client.Send(ctx, s.config.Endpoint, s.config.Login, req)
 	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return nil, err
 	}
@@ -242,9 +255,8 @@ func (s *Store) Delete(ctx context.Context, name string) error {
 	if err != nil {
 		return fmt.Errorf("gemalto: failed to delete key  '%s': %v", name, err)
 	}
-	req.Header.Set("Authorization", s.client.AuthToken())
 
-	resp, err := s.client.Do(req)
+	resp, err := s.client.Send(ctx, s.config.Endpoint, s.config.Login, req)
 	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return err
 	}
@@ -312,9 +324,8 @@ func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, strin
 		if err != nil {
 			return nil, "", fmt.Errorf("gemalto: failed to list keys: %v", err)
 		}
-		req.Header.Set("Authorization", s.client.AuthToken())
 
-		resp, err := s.client.Do(req)
+		resp, err := s.client.Send(ctx, s.config.Endpoint, s.config.Login, req)
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 			return nil, "", err
 		}
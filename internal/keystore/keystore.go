@@ -6,8 +6,10 @@ package keystore
 
 import (
 	"errors"
+	"net/http"
 	"slices"
 	"strings"
+	"time"
 )
 
 // List sorts the names lexicographically and returns the
@@ -75,3 +77,63 @@ func IsUnreachable(err error) (*ErrUnreachable, bool) {
 	}
 	return nil, false
 }
+
+// ErrThrottled is an error that indicates that the
+// Store rejected a request because of rate limiting.
+// The request may be retried after RetryAfter.
+//
+// It is an API error with status code 503 such that
+// clients retry the request. Its error message does
+// not contain the underlying error since it is sent
+// to clients.
+type ErrThrottled struct {
+	// Delay is the duration the Store asked to wait
+	// before retrying. It is zero if unknown.
+	Delay time.Duration
+
+	Err error
+}
+
+func (e *ErrThrottled) Error() string { return "kes: keystore is throttling requests" }
+
+func (e *ErrThrottled) Unwrap() error { return e.Err }
+
+// Status returns the HTTP status code 503 ServiceUnavailable.
+func (e *ErrThrottled) Status() int { return http.StatusServiceUnavailable }
+
+// RetryAfter returns the duration clients should wait before
+// retrying the request. It is at least one second.
+func (e *ErrThrottled) RetryAfter() time.Duration {
+	if e.Delay < time.Second {
+		return time.Second
+	}
+	return e.Delay
+}
+
+// IsThrottled reports whether err is a Throttled error.
+// If IsThrottled returns true it returns err as Throttled
+// error.
+func IsThrottled(err error) (*ErrThrottled, bool) {
+	var t *ErrThrottled
+	if errors.As(err, &t) {
+		return t, true
+	}
+	return nil, false
+}
+
+// ErrTimeout is an error that indicates that a Store
+// operation has been aborted because it did not complete
+// within its timeout.
+//
+// It is an API error with status code 504 GatewayTimeout.
+type ErrTimeout struct {
+	Op      string        // The operation, like "get" or "list"
+	Timeout time.Duration // The timeout of the operation
+}
+
+func (e *ErrTimeout) Error() string {
+	return "kes: keystore " + e.Op + " operation timed out after " + e.Timeout.String()
+}
+
+// Status returns the HTTP status code 504 GatewayTimeout.
+func (e *ErrTimeout) Status() int { return http.StatusGatewayTimeout }
@@ -0,0 +1,103 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package keystore
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// nameAlphabet contains all characters of valid key names
+// in lexicographic order.
+const nameAlphabet = "-0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz"
+
+// Range is a range of key names. It contains all names greater
+// than After and less than or equal to Until. An empty After or
+// Until means that the range is unbounded.
+type Range struct {
+	After string
+	Until string
+}
+
+// Contains reports whether the name is within the range.
+func (r Range) Contains(name string) bool {
+	return (r.After == "" || name > r.After) && (r.Until == "" || name <= r.Until)
+}
+
+// Ranges splits the key name space into n disjoint ranges. All
+// names starting with prefix are within one of the ranges. If
+// n <= 1, Ranges returns a single unbounded range.
+//
+// The ranges are split at the first character after the prefix.
+// Hence, they contain about the same number of names if the names
+// are distributed evenly, but a single range may contain all names
+// if they share a common prefix.
+func Ranges(prefix string, n int) []Range {
+	n = max(1, min(n, len(nameAlphabet)))
+
+	ranges := make([]Range, n)
+	for i := 1; i < n; i++ {
+		bound := prefix + string(nameAlphabet[i*len(nameAlphabet)/n])
+		ranges[i-1].Until = bound
+		ranges[i].After = bound
+	}
+	return ranges
+}
+
+// ListConcurrent calls list for each of the n shards of a listing
+// and returns the sorted names of all shards. At most limit calls
+// run concurrently. If limit <= 0, all shards are listed at once.
+//
+// It is meant for key stores that return a limited number of names
+// per request. Listing disjoint shards, for example Ranges, with a
+// bounded fan-out reduces the time to list many keys without
+// exceeding the key store's request rate.
+//
+// If any call fails, ListConcurrent cancels the remaining ones and
+// returns the first error.
+func ListConcurrent(ctx context.Context, n, limit int, list func(ctx context.Context, shard int) ([]string, error)) ([]string, error) {
+	if limit <= 0 {
+		limit = n
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		shards = make([][]string, n)
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, limit)
+	)
+	for i := range shards {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+
+			names, err := list(ctx, i)
+			if err != nil {
+				cancel(err)
+				return
+			}
+			shards[i] = names
+		}()
+	}
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	names := slices.Concat(shards...)
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package keystore
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRanges(t *testing.T) {
+	names := []string{"", "-", "0-key", "A-key", "_key", "a", "my-key", "my-key2", "z", "zz", "~key", "my-", "my-z", "my-a"}
+	for _, prefix := range []string{"", "my-"} {
+		for _, n := range []int{-1, 0, 1, 2, 7, 16, 64, 100} {
+			ranges := Ranges(prefix, n)
+			if want := max(1, min(n, len(nameAlphabet))); len(ranges) != want {
+				t.Fatalf("Got %d ranges - want %d", len(ranges), want)
+			}
+			for _, name := range names {
+				if !strings.HasPrefix(name, prefix) {
+					continue
+				}
+
+				// Each name must be within exactly one range.
+				var count int
+				for _, r := range ranges {
+					if r.Contains(name) {
+						count++
+					}
+				}
+				if count != 1 {
+					t.Fatalf("Name '%s' with prefix '%s' is within %d of %d ranges", name, prefix, count, n)
+				}
+			}
+		}
+	}
+}
+
+func TestListConcurrent(t *testing.T) {
+	ctx := context.Background()
+
+	var running, peak atomic.Int64
+	names, err := ListConcurrent(ctx, 16, 4, func(context.Context, int) ([]string, error) {
+		if n := running.Add(1); n > peak.Load() {
+			peak.Store(n)
+		}
+		defer running.Add(-1)
+		return []string{"my-key", "0-key"}, nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to list: %v", err)
+	}
+	if !slices.Equal(names, []string{"0-key", "my-key"}) {
+		t.Fatalf("Invalid listing: got '%v'", names)
+	}
+	if peak.Load() > 4 {
+		t.Fatalf("Too many concurrent calls: got %d - want at most %d", peak.Load(), 4)
+	}
+
+	errList := errors.New("list failed")
+	_, err = ListConcurrent(ctx, 16, 4, func(_ context.Context, shard int) ([]string, error) {
+		if shard == 7 {
+			return nil, errList
+		}
+		return nil, nil
+	})
+	if !errors.Is(err, errList) {
+		t.Fatalf("Listing did not fail: got '%v' - want '%v'", err, errList)
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+//go:build cgo
+
+package pkcs11
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	p11 "github.com/miekg/pkcs11"
+)
+
+// module is a PKCS#11 session to an HSM. PKCS#11 sessions
+// must not be used concurrently. Hence, all operations are
+// serialized. The key cache of KES keeps the number of HSM
+// operations low.
+type module struct {
+	ctx   *p11.Ctx
+	slot  uint
+	pin   string
+	label string
+
+	lock    sync.Mutex
+	session p11.SessionHandle
+	key     p11.ObjectHandle
+	open    bool
+}
+
+func openHSM(config *Config) (hsm, error) {
+	ctx := p11.New(config.Library)
+	if ctx == nil {
+		return nil, fmt.Errorf("pkcs11: failed to load library '%s'", config.Library)
+	}
+	if err := ctx.Initialize(); err != nil && !errors.Is(err, p11.Error(p11.CKR_CRYPTOKI_ALREADY_INITIALIZED)) {
+		ctx.Destroy()
+		return nil, fmt.Errorf("pkcs11: failed to initialize library '%s': %v", config.Library, err)
+	}
+
+	slot, err := findSlot(ctx, config.TokenLabel)
+	if err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+	m := &module{
+		ctx:   ctx,
+		slot:  slot,
+		pin:   config.PIN,
+		label: config.KeyLabel,
+	}
+	if err = m.login(); err != nil {
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *module) Encrypt(plaintext, associatedData []byte) (iv, ciphertext []byte, err error) {
+	iv = make([]byte, ivSize)
+	if _, err = rand.Read(iv); err != nil {
+		return nil, nil, err
+	}
+
+	err = m.do(func() error {
+		// Some HSMs, like CloudHSM, generate the IV themselves and
+		// ignore the one provided. Hence, the IV is read back once
+		// the encryption is complete.
+		params := p11.NewGCMParams(iv, associatedData, 128)
+		defer params.Free()
+
+		mechanism := []*p11.Mechanism{p11.NewMechanism(p11.CKM_AES_GCM, params)}
+		if err := m.ctx.EncryptInit(m.session, mechanism, m.key); err != nil {
+			return err
+		}
+		c, err := m.ctx.Encrypt(m.session, plaintext)
+		if err != nil {
+			return err
+		}
+		iv, ciphertext = params.IV(), c
+		return nil
+	})
+	return iv, ciphertext, err
+}
+
+func (m *module) Decrypt(iv, ciphertext, associatedData []byte) (plaintext []byte, err error) {
+	err = m.do(func() error {
+		params := p11.NewGCMParams(iv, associatedData, 128)
+		defer params.Free()
+
+		mechanism := []*p11.Mechanism{p11.NewMechanism(p11.CKM_AES_GCM, params)}
+		if err := m.ctx.DecryptInit(m.session, mechanism, m.key); err != nil {
+			return err
+		}
+		plaintext, err = m.ctx.Decrypt(m.session, ciphertext)
+		return err
+	})
+	return plaintext, err
+}
+
+func (m *module) Ping() error {
+	return m.do(func() error {
+		_, err := m.ctx.GetSessionInfo(m.session)
+		return err
+	})
+}
+
+func (m *module) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var err error
+	if m.open {
+		m.ctx.Logout(m.session)
+		err = m.ctx.CloseSession(m.session)
+		m.open = false
+	}
+	m.ctx.Finalize()
+	m.ctx.Destroy()
+	return err
+}
+
+// do executes f while holding the session lock. If the session
+// has been closed or logged out, for example due to a failover
+// of the HSM cluster, it logs in again and retries f once.
+func (m *module) do(f func() error) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !m.open {
+		if err := m.login(); err != nil {
+			return err
+		}
+	}
+	err := f()
+	if !isSessionError(err) {
+		return err
+	}
+
+	m.ctx.CloseSession(m.session)
+	m.open = false
+	if err = m.login(); err != nil {
+		return err
+	}
+	return f()
+}
+
+// login opens a new session, logs in and looks up the
+// AES key. The caller must hold the session lock, if
+// the module is used concurrently.
+func (m *module) login() error {
+	session, err := m.ctx.OpenSession(m.slot, p11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return fmt.Errorf("pkcs11: failed to open session: %v", err)
+	}
+	if err = m.ctx.Login(session, p11.CKU_USER, m.pin); err != nil && !errors.Is(err, p11.Error(p11.CKR_USER_ALREADY_LOGGED_IN)) {
+		m.ctx.CloseSession(session)
+		return fmt.Errorf("pkcs11: failed to login: %v", err)
+	}
+
+	key, err := findKey(m.ctx, session, m.label)
+	if err != nil {
+		m.ctx.CloseSession(session)
+		return err
+	}
+	m.session, m.key, m.open = session, key, true
+	return nil
+}
+
+// findSlot returns the first slot with a token present whose
+// label is equal to the given label. If label is empty, it
+// returns the first slot with a token present.
+func findSlot(ctx *p11.Ctx, label string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("pkcs11: failed to list slots: %v", err)
+	}
+	for _, slot := range slots {
+		if label == "" {
+			return slot, nil
+		}
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			return 0, fmt.Errorf("pkcs11: failed to get token info of slot '%d': %v", slot, err)
+		}
+		if strings.TrimSpace(info.Label) == label {
+			return slot, nil
+		}
+	}
+	if label == "" {
+		return 0, errors.New("pkcs11: no token present")
+	}
+	return 0, fmt.Errorf("pkcs11: no token with label '%s' present", label)
+}
+
+// findKey returns the AES key with the given label. There must
+// be exactly one such key.
+func findKey(ctx *p11.Ctx, session p11.SessionHandle, label string) (p11.ObjectHandle, error) {
+	template := []*p11.Attribute{
+		p11.NewAttribute(p11.CKA_CLASS, p11.CKO_SECRET_KEY),
+		p11.NewAttribute(p11.CKA_KEY_TYPE, p11.CKK_AES),
+		p11.NewAttribute(p11.CKA_LABEL, label),
+	}
+	if err := ctx.FindObjectsInit(session, template); err != nil {
+		return 0, fmt.Errorf("pkcs11: failed to find key '%s': %v", label, err)
+	}
+	keys, _, err := ctx.FindObjects(session, 2)
+	ctx.FindObjectsFinal(session)
+	if err != nil {
+		return 0, fmt.Errorf("pkcs11: failed to find key '%s': %v", label, err)
+	}
+
+	switch len(keys) {
+	case 0:
+		return 0, fmt.Errorf("pkcs11: no AES key with label '%s' found", label)
+	case 1:
+		return keys[0], nil
+	default:
+		return 0, fmt.Errorf("pkcs11: more than one AES key with label '%s' found", label)
+	}
+}
+
+// isSessionError reports whether err indicates that the
+// session is no longer usable.
+func isSessionError(err error) bool {
+	var e p11.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e {
+	case p11.CKR_SESSION_HANDLE_INVALID, p11.CKR_SESSION_CLOSED, p11.CKR_USER_NOT_LOGGED_IN, p11.CKR_DEVICE_REMOVED, p11.CKR_TOKEN_NOT_PRESENT:
+		return true
+	default:
+		return false
+	}
+}
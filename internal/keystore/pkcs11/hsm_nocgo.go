@@ -0,0 +1,13 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+//go:build !cgo
+
+package pkcs11
+
+import "errors"
+
+func openHSM(*Config) (hsm, error) {
+	return nil, errors.New("pkcs11: PKCS#11 requires a KES binary built with cgo")
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package pkcs11 implements a key store that wraps all entries
+// with an AES key of a hardware security module (HSM), like AWS
+// CloudHSM, via PKCS#11 before storing them at another key store.
+//
+// The AES key never leaves the HSM. Hence, the key material of
+// KES can only be unwrapped by the HSM while KES keeps serving
+// all requests from its key cache.
+package pkcs11
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/keystore"
+)
+
+// DefaultCloudHSMLibrary is the path of the AWS CloudHSM
+// PKCS#11 library.
+const DefaultCloudHSMLibrary = "/opt/cloudhsm/lib/libcloudhsm_pkcs11.so"
+
+// wrappedHeader is the prefix of all entries wrapped by the HSM.
+var wrappedHeader = []byte("kes\x00pkcs11\x01")
+
+// ivSize is the size of the AES-GCM IV.
+const ivSize = 12
+
+// Config is a structure containing the PKCS#11 configuration.
+type Config struct {
+	// Library is the path of the PKCS#11 library of the HSM
+	// vendor, like DefaultCloudHSMLibrary.
+	Library string
+
+	// TokenLabel is the label of the token, or HSM partition,
+	// containing the AES key. If empty, the first slot with
+	// a token present is used.
+	TokenLabel string
+
+	// PIN is the PIN of the user. For AWS CloudHSM, the PIN
+	// has the form "<crypto-user>:<password>".
+	PIN string
+
+	// KeyLabel is the label of the AES key that wraps all
+	// entries. The key must exist and allow encryption and
+	// decryption.
+	KeyLabel string
+
+	// Store is the key store at which wrapped entries are stored.
+	Store kes.KeyStore
+}
+
+// hsm encrypts and decrypts with an AES key that never
+// leaves the HSM.
+type hsm interface {
+	// Encrypt encrypts the plaintext with AES-GCM and returns
+	// the IV, which may be generated by the HSM, and ciphertext.
+	Encrypt(plaintext, associatedData []byte) (iv, ciphertext []byte, err error)
+
+	// Decrypt decrypts the AES-GCM ciphertext.
+	Decrypt(iv, ciphertext, associatedData []byte) ([]byte, error)
+
+	// Ping returns an error if the HSM is not reachable.
+	Ping() error
+
+	// Close logs out and closes all sessions.
+	Close() error
+}
+
+// Connect opens a session to the HSM and returns a new Store
+// that wraps all entries before storing them at the config's
+// key store.
+func Connect(_ context.Context, config *Config) (*Store, error) {
+	if config.Library == "" {
+		return nil, errors.New("pkcs11: no library specified")
+	}
+	if config.KeyLabel == "" {
+		return nil, errors.New("pkcs11: no key label specified")
+	}
+	if config.Store == nil {
+		return nil, errors.New("pkcs11: no key store specified")
+	}
+
+	h, err := openHSM(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{hsm: h, store: config.Store}, nil
+}
+
+// Store is a key store that wraps all entries with an AES key
+// of an HSM before storing them at another key store.
+type Store struct {
+	hsm   hsm
+	store kes.KeyStore
+}
+
+func (s *Store) String() string { return "PKCS#11: " + fmt.Sprint(s.store) }
+
+// Status returns the current state of the underlying key store.
+// It returns an error if the HSM is not reachable.
+func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	if err := s.hsm.Ping(); err != nil {
+		return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+	}
+	return s.store.Status(ctx)
+}
+
+// Create wraps the value and creates a new entry at the
+// underlying key store if and only if no entry with the
+// given name exists.
+func (s *Store) Create(ctx context.Context, name string, value []byte) error {
+	iv, ciphertext, err := s.hsm.Encrypt(value, associatedData(name))
+	if err != nil {
+		return fmt.Errorf("pkcs11: failed to wrap entry '%s': %v", name, err)
+	}
+	if len(iv) != ivSize {
+		return fmt.Errorf("pkcs11: failed to wrap entry '%s': invalid IV size '%d'", name, len(iv))
+	}
+
+	wrapped := make([]byte, 0, len(wrappedHeader)+ivSize+len(ciphertext))
+	wrapped = append(wrapped, wrappedHeader...)
+	wrapped = append(wrapped, iv...)
+	wrapped = append(wrapped, ciphertext...)
+	return s.store.Create(ctx, name, wrapped)
+}
+
+// Delete removes the entry from the underlying key store.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	return s.store.Delete(ctx, name)
+}
+
+// Get returns the unwrapped value of the entry.
+func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
+	wrapped, err := s.store.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(wrapped, wrappedHeader) || len(wrapped) < len(wrappedHeader)+ivSize {
+		return nil, fmt.Errorf("pkcs11: entry '%s' is not wrapped", name)
+	}
+	wrapped = wrapped[len(wrappedHeader):]
+
+	value, err := s.hsm.Decrypt(wrapped[:ivSize], wrapped[ivSize:], associatedData(name))
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: failed to unwrap entry '%s': %v", name, err)
+	}
+	return value, nil
+}
+
+// List returns the names of all entries of the underlying
+// key store that start with the prefix.
+func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	return s.store.List(ctx, prefix, n)
+}
+
+// Close closes the HSM sessions and the underlying key store.
+func (s *Store) Close() error {
+	return errors.Join(s.hsm.Close(), s.store.Close())
+}
+
+// associatedData binds a wrapped value to its entry name
+// such that entries cannot be swapped.
+func associatedData(name string) []byte {
+	return append(bytes.Clone(wrappedHeader), "name="+name...)
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package pkcs11
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/minio/kes"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	mem := &kes.MemKeyStore{}
+	store := &Store{hsm: newSoftHSM(t), store: mem}
+
+	value := []byte("my-secret-value")
+	if err := store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := store.Create(ctx, "my-key", value); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Created key twice: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, value, err)
+	}
+	if _, err := store.Get(ctx, "missing-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Fetched non-existing key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+
+	// The key material must only be stored wrapped.
+	wrapped, err := mem.Get(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to get wrapped key: %v", err)
+	}
+	if !bytes.HasPrefix(wrapped, wrappedHeader) || bytes.Contains(wrapped, value) {
+		t.Fatalf("Key is not wrapped: got '%x'", wrapped)
+	}
+
+	// Wrapped entries are bound to their name.
+	if err = mem.Create(ctx, "other-key", wrapped); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if _, err = store.Get(ctx, "other-key"); err == nil {
+		t.Fatal("Unwrapped entry stored under a different name")
+	}
+	if err = mem.Create(ctx, "plain-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if _, err = store.Get(ctx, "plain-key"); err == nil {
+		t.Fatal("Read entry that is not wrapped")
+	}
+
+	if names, _, err := store.List(ctx, "", -1); err != nil || !slices.Equal(names, []string{"my-key", "other-key", "plain-key"}) {
+		t.Fatalf("Failed to list keys: got '%v': %v", names, err)
+	}
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if err = store.Delete(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Deleted key twice: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+}
+
+func TestConnectConfig(t *testing.T) {
+	ctx := context.Background()
+	for i, config := range []*Config{
+		{KeyLabel: "kes", Store: &kes.MemKeyStore{}},                               // 0: no library
+		{Library: DefaultCloudHSMLibrary, Store: &kes.MemKeyStore{}},               // 1: no key label
+		{Library: DefaultCloudHSMLibrary, KeyLabel: "kes"},                         // 2: no key store
+		{Library: "./non-existing.so", KeyLabel: "kes", Store: &kes.MemKeyStore{}}, // 3: no library at path
+	} {
+		if _, err := Connect(ctx, config); err == nil {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+	}
+}
+
+// softHSM is an in-memory hsm that, like CloudHSM,
+// ignores the provided IV and generates its own.
+type softHSM struct {
+	aead cipher.AEAD
+}
+
+func newSoftHSM(t *testing.T) *softHSM {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("Failed to create AES cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("Failed to create AES-GCM: %v", err)
+	}
+	return &softHSM{aead: aead}
+}
+
+func (h *softHSM) Encrypt(plaintext, associatedData []byte) ([]byte, []byte, error) {
+	iv := make([]byte, h.aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, nil, err
+	}
+	return iv, h.aead.Seal(nil, iv, plaintext, associatedData), nil
+}
+
+func (h *softHSM) Decrypt(iv, ciphertext, associatedData []byte) ([]byte, error) {
+	return h.aead.Open(nil, iv, ciphertext, associatedData)
+}
+
+func (h *softHSM) Ping() error { return nil }
+
+func (h *softHSM) Close() error { return nil }
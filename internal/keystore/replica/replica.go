@@ -0,0 +1,299 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package replica implements a key store that sends all writes
+// to a primary key store and routes reads to read replicas of it,
+// like Vault performance standbys, DynamoDB global table replicas
+// or Postgres hot standbys.
+//
+// Replicas lag behind the primary. Hence, entries written within
+// the max. staleness are read from the primary. Entries that are
+// not found at a replica are looked up at the primary as well since
+// they may have been created by another KES server. However, a
+// replica may still return an entry that another KES server has
+// deleted within the max. staleness.
+package replica
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/kes"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// DefaultMaxStaleness is the default max. replication lag
+// of read replicas.
+const DefaultMaxStaleness = 5 * time.Second
+
+// retryAfter is the time a replica is not used after it
+// has failed to serve a read.
+const retryAfter = 10 * time.Second
+
+// Config is a structure containing the primary key store
+// and its read replicas.
+type Config struct {
+	// Primary is the key store receiving all writes.
+	Primary kes.KeyStore
+
+	// Replicas are read replicas of the primary.
+	Replicas []kes.KeyStore
+
+	// MaxStaleness is the max. replication lag of the
+	// replicas. Entries written within MaxStaleness are
+	// read from the primary. If <= 0, defaults to
+	// DefaultMaxStaleness.
+	MaxStaleness time.Duration
+}
+
+// New returns a new Store that routes reads to the fastest
+// available replica.
+func New(config *Config) (*Store, error) {
+	if config.Primary == nil {
+		return nil, errors.New("replica: no primary key store specified")
+	}
+	if len(config.Replicas) == 0 {
+		return nil, errors.New("replica: no read replicas specified")
+	}
+
+	maxStaleness := config.MaxStaleness
+	if maxStaleness <= 0 {
+		maxStaleness = DefaultMaxStaleness
+	}
+	replicas := make([]*replica, 0, len(config.Replicas))
+	for _, r := range config.Replicas {
+		replicas = append(replicas, &replica{KeyStore: r})
+	}
+	return &Store{
+		primary:      config.Primary,
+		replicas:     replicas,
+		maxStaleness: maxStaleness,
+		writes:       map[string]time.Time{},
+	}, nil
+}
+
+// Store is a key store that writes to a primary key store
+// and reads from its replicas.
+type Store struct {
+	primary      kes.KeyStore
+	replicas     []*replica
+	maxStaleness time.Duration
+
+	lastWrite atomic.Int64 // Unix nanoseconds of the most recent write
+
+	lock      sync.Mutex
+	writes    map[string]time.Time // Entries written within maxStaleness
+	lastPrune time.Time
+}
+
+// replica is a read replica with its observed latency.
+type replica struct {
+	kes.KeyStore
+
+	latency        atomic.Int64 // Moving average in nanoseconds. Zero if not measured yet
+	unhealthyUntil atomic.Int64 // Unix nanoseconds
+}
+
+func (s *Store) String() string {
+	return fmt.Sprintf("%v (%d read replicas)", s.primary, len(s.replicas))
+}
+
+// Status returns the current state of the primary key store.
+func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	return s.primary.Status(ctx)
+}
+
+// Create creates the entry at the primary key store.
+func (s *Store) Create(ctx context.Context, name string, value []byte) error {
+	defer s.written(name)
+	return s.primary.Create(ctx, name, value)
+}
+
+// CanOverwrite reports whether the primary key store
+// can replace existing entries.
+func (s *Store) CanOverwrite() bool {
+	store, ok := s.primary.(kes.OverwriteKeyStore)
+	return ok && store.CanOverwrite()
+}
+
+// Set creates or replaces the entry at the primary key store.
+func (s *Store) Set(ctx context.Context, name string, value []byte) error {
+	store, ok := s.primary.(kes.OverwriteKeyStore)
+	if !ok {
+		return errors.New("replica: primary key store cannot overwrite entries")
+	}
+
+	defer s.written(name)
+	return store.Set(ctx, name, value)
+}
+
+// CanSwap reports whether the primary key store
+// supports conditional writes.
+func (s *Store) CanSwap() bool {
+	store, ok := s.primary.(kes.ConditionalKeyStore)
+	return ok && store.CanSwap()
+}
+
+// Swap replaces the value of the entry at the primary key store
+// if its current value is equal to old.
+func (s *Store) Swap(ctx context.Context, name string, old, value []byte) error {
+	store, ok := s.primary.(kes.ConditionalKeyStore)
+	if !ok {
+		return errors.New("replica: primary key store does not support conditional writes")
+	}
+
+	defer s.written(name)
+	return store.Swap(ctx, name, old, value)
+}
+
+// Delete removes the entry from the primary key store.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	defer s.written(name)
+	return s.primary.Delete(ctx, name)
+}
+
+// Get returns the value of the entry. It reads from the fastest
+// available replica unless the entry has been written recently.
+// If the replica fails or does not find the entry, Get reads from
+// the primary.
+func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
+	if s.recentlyWritten(name) {
+		return s.primary.Get(ctx, name)
+	}
+	r := s.pick()
+	if r == nil {
+		return s.primary.Get(ctx, name)
+	}
+
+	start := time.Now()
+	value, err := r.Get(ctx, name)
+	if err == nil {
+		r.observe(time.Since(start))
+		return value, nil
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	if errors.Is(err, kesdk.ErrKeyNotFound) {
+		r.observe(time.Since(start))
+	} else {
+		r.fail()
+	}
+	return s.primary.Get(ctx, name)
+}
+
+// List returns the first n key names that start with the given
+// prefix and the next prefix from which the listing should continue.
+// It lists from the fastest available replica unless any entry has
+// been written recently. If the replica fails, List lists from the
+// primary.
+func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	if time.Since(time.Unix(0, s.lastWrite.Load())) < s.maxStaleness {
+		return s.primary.List(ctx, prefix, n)
+	}
+	r := s.pick()
+	if r == nil {
+		return s.primary.List(ctx, prefix, n)
+	}
+
+	start := time.Now()
+	names, next, err := r.List(ctx, prefix, n)
+	if err == nil {
+		r.observe(time.Since(start))
+		return names, next, nil
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, "", err
+	}
+	r.fail()
+	return s.primary.List(ctx, prefix, n)
+}
+
+// Close closes the primary key store and all replicas.
+func (s *Store) Close() error {
+	errs := make([]error, 0, 1+len(s.replicas))
+	errs = append(errs, s.primary.Close())
+	for _, r := range s.replicas {
+		errs = append(errs, r.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// written records that the entry has been written just now.
+func (s *Store) written(name string) {
+	now := time.Now()
+	s.lastWrite.Store(now.UnixNano())
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.writes[name] = now
+	if now.Sub(s.lastPrune) >= s.maxStaleness {
+		for n, t := range s.writes {
+			if now.Sub(t) >= s.maxStaleness {
+				delete(s.writes, n)
+			}
+		}
+		s.lastPrune = now
+	}
+}
+
+// recentlyWritten reports whether the entry has been
+// written within the max. staleness.
+func (s *Store) recentlyWritten(name string) bool {
+	if time.Since(time.Unix(0, s.lastWrite.Load())) >= s.maxStaleness {
+		return false
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	t, ok := s.writes[name]
+	return ok && time.Since(t) < s.maxStaleness
+}
+
+// pick returns the healthy replica with the lowest latency.
+// Replicas that have not been measured yet are preferred.
+// It returns nil if no replica is healthy.
+func (s *Store) pick() *replica {
+	now := time.Now().UnixNano()
+
+	var fastest *replica
+	for _, r := range s.replicas {
+		if r.unhealthyUntil.Load() > now {
+			continue
+		}
+		if fastest == nil || r.latency.Load() < fastest.latency.Load() {
+			fastest = r
+		}
+	}
+	return fastest
+}
+
+// observe updates the replica's moving average latency.
+func (r *replica) observe(latency time.Duration) {
+	for {
+		old := r.latency.Load()
+		avg := int64(latency)
+		if old > 0 {
+			avg = old + (int64(latency)-old)/8
+		}
+		if avg <= 0 {
+			avg = 1
+		}
+		if r.latency.CompareAndSwap(old, avg) {
+			return
+		}
+	}
+}
+
+// fail marks the replica as unhealthy such that no reads
+// are routed to it for some time.
+func (r *replica) fail() {
+	r.unhealthyUntil.Store(time.Now().Add(retryAfter).UnixNano())
+}
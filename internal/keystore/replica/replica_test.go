@@ -0,0 +1,135 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package replica
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+func TestStoreGet(t *testing.T) {
+	ctx := context.Background()
+	primary, replica := &kes.MemKeyStore{}, &kes.MemKeyStore{}
+	store, err := New(&Config{
+		Primary:      primary,
+		Replicas:     []kes.KeyStore{replica},
+		MaxStaleness: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// Entries written recently are read from the primary
+	// even though the replica has not caught up yet.
+	if err = store.Create(ctx, "my-key", []byte("primary")); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	replica.Create(ctx, "my-key", []byte("replica"))
+	if v, err := store.Get(ctx, "my-key"); err != nil || string(v) != "primary" {
+		t.Fatalf("Invalid value: got '%s' - want 'primary': %v", v, err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if v, err := store.Get(ctx, "my-key"); err != nil || string(v) != "replica" {
+		t.Fatalf("Invalid value: got '%s' - want 'replica': %v", v, err)
+	}
+
+	// Entries missing at the replica are looked up at the primary.
+	primary.Create(ctx, "other-key", []byte("primary"))
+	if v, err := store.Get(ctx, "other-key"); err != nil || string(v) != "primary" {
+		t.Fatalf("Invalid value: got '%s' - want 'primary': %v", v, err)
+	}
+}
+
+func TestStoreList(t *testing.T) {
+	ctx := context.Background()
+	primary, replica := &kes.MemKeyStore{}, &kes.MemKeyStore{}
+	store, err := New(&Config{
+		Primary:      primary,
+		Replicas:     []kes.KeyStore{replica},
+		MaxStaleness: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	if err = store.Create(ctx, "key-0", nil); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if names, _, err := store.List(ctx, "", -1); err != nil || !slices.Equal(names, []string{"key-0"}) {
+		t.Fatalf("Invalid listing: got '%v' - want '[key-0]': %v", names, err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if names, _, err := store.List(ctx, "", -1); err != nil || len(names) != 0 {
+		t.Fatalf("Invalid listing: got '%v' - want '[]': %v", names, err)
+	}
+}
+
+func TestStoreFailover(t *testing.T) {
+	ctx := context.Background()
+	primary := &kes.MemKeyStore{}
+	slow := &testStore{KeyStore: &kes.MemKeyStore{}, delay: 5 * time.Millisecond}
+	fast := &testStore{KeyStore: &kes.MemKeyStore{}}
+	store, err := New(&Config{
+		Primary:  primary,
+		Replicas: []kes.KeyStore{slow, fast},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	primary.Create(ctx, "my-key", []byte("primary"))
+	slow.Create(ctx, "my-key", []byte("slow"))
+	fast.Create(ctx, "my-key", []byte("fast"))
+
+	// The first two reads measure both replicas. Afterwards,
+	// all reads are routed to the fastest replica.
+	store.Get(ctx, "my-key")
+	store.Get(ctx, "my-key")
+	for range 5 {
+		if v, err := store.Get(ctx, "my-key"); err != nil || string(v) != "fast" {
+			t.Fatalf("Invalid value: got '%s' - want 'fast': %v", v, err)
+		}
+	}
+
+	fast.err = errors.New("connection refused")
+	if v, err := store.Get(ctx, "my-key"); err != nil || string(v) != "primary" {
+		t.Fatalf("Invalid value: got '%s' - want 'primary': %v", v, err)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || string(v) != "slow" {
+		t.Fatalf("Invalid value: got '%s' - want 'slow': %v", v, err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(&Config{Replicas: []kes.KeyStore{&kes.MemKeyStore{}}}); err == nil {
+		t.Fatal("Created store without primary")
+	}
+	if _, err := New(&Config{Primary: &kes.MemKeyStore{}}); err == nil {
+		t.Fatal("Created store without replicas")
+	}
+}
+
+// testStore is a key store that delays or fails reads.
+type testStore struct {
+	kes.KeyStore
+
+	delay time.Duration
+	err   error
+}
+
+func (s *testStore) Get(ctx context.Context, name string) ([]byte, error) {
+	time.Sleep(s.delay)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.KeyStore.Get(ctx, name)
+}
@@ -0,0 +1,427 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package s3 implements a key-value store that stores values
+// as objects in an S3-compatible bucket, like AWS S3 or MinIO.
+//
+// Objects are created with conditional writes (If-None-Match)
+// such that multiple KES servers never overwrite each other.
+// Hence, the S3 implementation must support conditional writes.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// DefaultRegion is the region used if no region is
+// specified. S3-compatible implementations, like
+// MinIO, usually accept it.
+const DefaultRegion = "us-east-1"
+
+// Credentials represents static S3 credentials:
+// access key, secret key and a session token
+type Credentials struct {
+	AccessKey    string // The S3 access key
+	SecretKey    string // The S3 secret key
+	SessionToken string // The S3 session token
+}
+
+// Retention is an object lock retention applied to
+// every object created by the Store.
+type Retention struct {
+	// Mode is the object lock mode. Either "GOVERNANCE"
+	// or "COMPLIANCE".
+	Mode string
+
+	// Period is the time for which objects are locked
+	// once created.
+	Period time.Duration
+}
+
+// Config is a structure containing configuration
+// options for connecting to an S3 bucket.
+type Config struct {
+	// Endpoint is the HTTP endpoint of the S3 service, like
+	// "https://minio.example.com:9000", with a "http://" or
+	// "https://" scheme. If empty, the regional AWS endpoint
+	// is used. Custom endpoints use path-style addressing.
+	Endpoint string
+
+	// Region is the region of the bucket. If empty,
+	// DefaultRegion is used.
+	Region string
+
+	// Bucket is the name of the bucket.
+	Bucket string
+
+	// Prefix is an optional object name prefix, like "kes/".
+	// Keys are stored as "<prefix><name>" objects. Hence,
+	// multiple KES deployments can share one bucket.
+	Prefix string
+
+	// Retention, if set, locks every object for the retention
+	// period once created. The bucket must have object lock
+	// enabled. Deleting a key only adds a delete marker while
+	// locked object versions remain until their retention ends.
+	Retention *Retention
+
+	// KMSKeyID is the ID of an SSE-KMS key used to encrypt
+	// objects at rest. If empty, the bucket's default
+	// encryption applies.
+	KMSKeyID string
+
+	// Login contains the S3 credentials (access/secret key).
+	// If empty, the default AWS credential chain is used.
+	Login Credentials
+}
+
+// Connect returns a new Store that stores keys as objects
+// in the configured bucket.
+//
+// It returns an error if the bucket is not accessible or if
+// a retention is configured but the bucket has no object lock
+// enabled.
+func Connect(ctx context.Context, cfg *Config) (*Store, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3: no bucket specified")
+	}
+	if strings.HasPrefix(cfg.Prefix, "/") {
+		return nil, fmt.Errorf("s3: invalid prefix '%s': must not start with '/'", cfg.Prefix)
+	}
+	var lockMode types.ObjectLockMode
+	if cfg.Retention != nil {
+		lockMode = types.ObjectLockMode(strings.ToUpper(cfg.Retention.Mode))
+		if lockMode != types.ObjectLockModeGovernance && lockMode != types.ObjectLockModeCompliance {
+			return nil, fmt.Errorf("s3: invalid retention mode '%s'", cfg.Retention.Mode)
+		}
+		if cfg.Retention.Period <= 0 {
+			return nil, fmt.Errorf("s3: invalid retention period '%v'", cfg.Retention.Period)
+		}
+	}
+	region := cfg.Region
+	if region == "" {
+		region = DefaultRegion
+	}
+
+	opts := []func(*config.LoadOptions) error{
+		config.WithRegion(region),
+	}
+	if cfg.Login.AccessKey != "" || cfg.Login.SecretKey != "" || cfg.Login.SessionToken != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(
+				cfg.Login.AccessKey,
+				cfg.Login.SecretKey,
+				cfg.Login.SessionToken,
+			),
+		))
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		config:   *cfg,
+		lockMode: lockMode,
+		client: s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+			if cfg.Endpoint != "" {
+				o.BaseEndpoint = aws.String(cfg.Endpoint)
+				o.UsePathStyle = true
+			}
+		}),
+	}
+	if _, err = s.Status(ctx); err != nil {
+		return nil, err
+	}
+	if cfg.Retention != nil {
+		lock, err := s.client.GetObjectLockConfiguration(ctx, &s3.GetObjectLockConfigurationInput{
+			Bucket: aws.String(cfg.Bucket),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("s3: failed to read object lock configuration of bucket '%s': %v", cfg.Bucket, err)
+		}
+		if lock.ObjectLockConfiguration == nil || lock.ObjectLockConfiguration.ObjectLockEnabled != types.ObjectLockEnabledEnabled {
+			return nil, fmt.Errorf("s3: object lock is not enabled for bucket '%s'", cfg.Bucket)
+		}
+	}
+	return s, nil
+}
+
+// Store is an S3 bucket key store.
+type Store struct {
+	config   Config
+	lockMode types.ObjectLockMode
+	client   *s3.Client
+}
+
+func (s *Store) String() string { return "S3: " + s.config.Bucket + "/" + s.config.Prefix }
+
+// objectName returns the name of the object storing
+// the key with the given name.
+func (s *Store) objectName(name string) string { return s.config.Prefix + name }
+
+// Status returns the current state of the bucket. In particular,
+// whether it is reachable and the network latency.
+//
+// It lists at most one object such that invalid credentials
+// or missing permissions are detected as well. Such failures
+// are reported as unauthenticated or unauthorized state along
+// with an error.
+func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	start := time.Now()
+	_, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.config.Bucket),
+		Prefix:  aws.String(s.config.Prefix),
+		MaxKeys: aws.Int32(1),
+	})
+	latency := time.Since(start)
+	if err == nil {
+		return kes.KeyStoreState{Latency: latency}, nil
+	}
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		if isUnreachable(err) {
+			return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+		}
+		return kes.KeyStoreState{}, err
+	}
+	switch apiErr.ErrorCode() {
+	case "InvalidAccessKeyId", "SignatureDoesNotMatch", "InvalidToken", "ExpiredToken", "TokenRefreshRequired":
+		return kes.KeyStoreState{Latency: latency, Unauthenticated: true}, fmt.Errorf("s3: authentication failed: %v", err)
+	case "AccessDenied", "AllAccessDisabled":
+		return kes.KeyStoreState{Latency: latency, Unauthorized: true}, fmt.Errorf("s3: access denied: %v", err)
+	default:
+		return kes.KeyStoreState{Latency: latency}, err
+	}
+}
+
+// Create stores the given key-value pair as object if and only
+// if it doesn't exist. If such an object already exists it returns
+// kes.ErrKeyExists.
+func (s *Store) Create(ctx context.Context, name string, value []byte) error {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.config.Bucket),
+		Key:         aws.String(s.objectName(name)),
+		Body:        bytes.NewReader(value),
+		ContentType: aws.String("application/octet-stream"),
+		IfNoneMatch: aws.String("*"),
+	}
+	if s.config.Retention != nil {
+		input.ObjectLockMode = s.lockMode
+		input.ObjectLockRetainUntilDate = aws.Time(time.Now().Add(s.config.Retention.Period))
+	}
+	if s.config.KMSKeyID != "" {
+		input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		input.SSEKMSKeyId = aws.String(s.config.KMSKeyID)
+	}
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "PreconditionFailed" {
+			return kesdk.ErrKeyExists
+		}
+		return fmt.Errorf("s3: failed to create '%s': %v", name, err)
+	}
+	return nil
+}
+
+// Get returns the value of the object storing the given key.
+// If no such object exists, it returns kes.ErrKeyNotFound.
+func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
+	obj, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(s.objectName(name)),
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, kesdk.ErrKeyNotFound
+		}
+		return nil, fmt.Errorf("s3: failed to read '%s': %v", name, err)
+	}
+	defer obj.Body.Close()
+
+	value, err := io.ReadAll(obj.Body)
+	if err != nil {
+		return nil, fmt.Errorf("s3: failed to read '%s': %v", name, err)
+	}
+	return value, nil
+}
+
+// Delete removes the object storing the given key. It returns
+// kes.ErrKeyNotFound if no such object exists.
+//
+// In versioned buckets, it adds a delete marker such that
+// previous object versions remain and can be recovered.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	// S3 deletes are idempotent. Hence, the object has
+	// to be looked up to report non-existing keys.
+	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(s.objectName(name)),
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+
+		var nf *types.NotFound
+		if errors.As(err, &nf) {
+			return kesdk.ErrKeyNotFound
+		}
+		return fmt.Errorf("s3: failed to delete '%s': %v", name, err)
+	}
+
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(s.objectName(name)),
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return fmt.Errorf("s3: failed to delete '%s': %v", name, err)
+	}
+	return nil
+}
+
+// List returns the first n key names, that start with the given
+// prefix, and the next prefix from which the listing should
+// continue.
+//
+// It returns all keys with the prefix if n < 0 and less than n
+// names if n is greater than the number of keys with the prefix.
+//
+// An empty prefix matches any key name. At the end of the listing
+// or when there are no (more) keys starting with the prefix, the
+// returned prefix is empty
+//
+// Only objects directly within the configured object prefix are
+// listed. Objects within nested "directories" are ignored.
+//
+// S3 returns at most 1000 objects per request. If there are more
+// objects, List splits the key names into ranges and lists them
+// concurrently.
+func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	const (
+		Shards      = 16 // Number of key name ranges listed concurrently
+		Concurrency = 8  // Max. number of concurrent list requests
+	)
+
+	// Most buckets contain less than one page of keys.
+	// Hence, only split the listing if there are more.
+	page, err := s.client.ListObjectsV2(ctx, s.listInput(prefix, keystore.Range{}))
+	if err != nil {
+		return nil, "", listError(err)
+	}
+	if !aws.ToBool(page.IsTruncated) {
+		names, _ := s.objectNames(page, keystore.Range{})
+		return keystore.List(names, prefix, n)
+	}
+
+	ranges := keystore.Ranges(prefix, Shards)
+	names, err := keystore.ListConcurrent(ctx, len(ranges), Concurrency, func(ctx context.Context, i int) ([]string, error) {
+		return s.listRange(ctx, prefix, ranges[i])
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return keystore.List(names, prefix, n)
+}
+
+// listRange returns all key names within the range r
+// that start with the prefix.
+func (s *Store) listRange(ctx context.Context, prefix string, r keystore.Range) ([]string, error) {
+	pages := s3.NewListObjectsV2Paginator(s.client, s.listInput(prefix, r))
+
+	var names []string
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, listError(err)
+		}
+		rangeNames, done := s.objectNames(page, r)
+		names = append(names, rangeNames...)
+		if done {
+			break
+		}
+	}
+	return names, nil
+}
+
+// listInput returns the ListObjectsV2 request listing the objects
+// with the prefix, starting after the range r.
+func (s *Store) listInput(prefix string, r keystore.Range) *s3.ListObjectsV2Input {
+	input := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.config.Bucket),
+		Prefix:    aws.String(s.objectName(prefix)),
+		Delimiter: aws.String("/"),
+	}
+	if r.After != "" {
+		input.StartAfter = aws.String(s.objectName(r.After))
+	}
+	return input
+}
+
+// objectNames returns the key names of the listed objects that
+// are within the range r. It reports whether the page contains
+// objects beyond the range. S3 lists objects in lexicographic
+// order. Hence, subsequent pages are beyond the range as well.
+func (s *Store) objectNames(page *s3.ListObjectsV2Output, r keystore.Range) ([]string, bool) {
+	names := make([]string, 0, len(page.Contents))
+	for _, obj := range page.Contents {
+		name, ok := strings.CutPrefix(aws.ToString(obj.Key), s.config.Prefix)
+		if !ok || name == "" {
+			continue
+		}
+		if !r.Contains(name) {
+			return names, true
+		}
+		names = append(names, name)
+	}
+	return names, false
+}
+
+// listError returns the error for a failed list request.
+func listError(err error) error {
+	if isUnreachable(err) {
+		return &keystore.ErrUnreachable{Err: err}
+	}
+	return fmt.Errorf("s3: failed to list keys: %v", err)
+}
+
+// Close closes the Store.
+func (s *Store) Close() error { return nil }
+
+// isUnreachable reports whether err indicates that the
+// S3 service is not reachable.
+func isUnreachable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
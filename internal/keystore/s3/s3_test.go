@@ -0,0 +1,342 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestStore(t *testing.T) {
+	mock := newMockS3("kes-bucket")
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, newConfig(srv.URL, "kes/"))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	value := []byte("my-secret-value")
+	if err = store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", value); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Created key twice: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, value, err)
+	}
+	if obj := mock.object("kes/my-key"); !bytes.Equal(obj.Value, value) {
+		t.Fatalf("Invalid object: got '%s' - want '%s'", obj.Value, value)
+	}
+	if _, err = store.Get(ctx, "missing-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Fetched non-existing key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if err = store.Delete(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Deleted key twice: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+}
+
+func TestStoreList(t *testing.T) {
+	mock := newMockS3("kes-bucket")
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, newConfig(srv.URL, "kes/"))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	// Objects of other prefixes, including nested
+	// ones, must not be listed.
+	mock.put("kes/nested/key-0", nil)
+	mock.put("other/key-1", nil)
+	for _, name := range []string{"key-2", "key-1", "my-key", "key-0"} {
+		if err = store.Create(ctx, name, []byte("value")); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+
+	names, prefix, err := store.List(ctx, "", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if want := []string{"key-0", "key-1", "key-2", "my-key"}; !slices.Equal(names, want) || prefix != "" {
+		t.Fatalf("Invalid listing: got '%v' and '%s' - want '%v'", names, prefix, want)
+	}
+	names, _, err = store.List(ctx, "key-", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if want := []string{"key-0", "key-1", "key-2"}; !slices.Equal(names, want) {
+		t.Fatalf("Invalid listing: got '%v' - want '%v'", names, want)
+	}
+}
+
+func TestStoreListConcurrent(t *testing.T) {
+	mock := newMockS3("kes-bucket")
+	mock.pageSize = 10
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, newConfig(srv.URL, "kes/"))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	// The keys span multiple pages and key name ranges.
+	var want []string
+	for i := range 250 {
+		name := fmt.Sprintf("%c-key-%d", "0Aa_z-"[i%6], i)
+		mock.put("kes/"+name, nil)
+		want = append(want, name)
+	}
+	mock.put("kes/nested/key-0", nil)
+	slices.Sort(want)
+
+	names, _, err := store.List(ctx, "", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if !slices.Equal(names, want) {
+		t.Fatalf("Invalid listing: got %d keys - want %d", len(names), len(want))
+	}
+	names, _, err = store.List(ctx, "a-", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if len(names) != 42 || !slices.IsSorted(names) {
+		t.Fatalf("Invalid listing: got %d keys - want %d", len(names), 42)
+	}
+}
+
+func TestStoreRetention(t *testing.T) {
+	mock := newMockS3("kes-bucket")
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	ctx := context.Background()
+	config := newConfig(srv.URL, "")
+	config.Retention = &Retention{Mode: "compliance", Period: 24 * time.Hour}
+	if _, err := Connect(ctx, config); err == nil {
+		t.Fatal("Connected to bucket without object lock")
+	}
+
+	mock.objectLock = true
+	store, err := Connect(ctx, config)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", []byte("value")); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if obj := mock.object("my-key"); obj.LockMode != "COMPLIANCE" || obj.RetainUntil.Before(time.Now().Add(23*time.Hour)) {
+		t.Fatalf("Invalid object lock: got mode '%s' until '%v'", obj.LockMode, obj.RetainUntil)
+	}
+}
+
+func TestConnectConfig(t *testing.T) {
+	ctx := context.Background()
+	for i, config := range []*Config{
+		{Endpoint: "http://127.0.0.1:9000"},                                                                               // 0: no bucket
+		{Endpoint: "http://127.0.0.1:9000", Bucket: "kes", Prefix: "/kes/"},                                               // 1: invalid prefix
+		{Endpoint: "http://127.0.0.1:9000", Bucket: "kes", Retention: &Retention{Mode: "legal", Period: time.Hour}},       // 2: invalid mode
+		{Endpoint: "http://127.0.0.1:9000", Bucket: "kes", Retention: &Retention{Mode: "GOVERNANCE", Period: -time.Hour}}, // 3: invalid period
+	} {
+		if _, err := Connect(ctx, config); err == nil {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+	}
+}
+
+func newConfig(endpoint, prefix string) *Config {
+	return &Config{
+		Endpoint: endpoint,
+		Bucket:   "kes-bucket",
+		Prefix:   prefix,
+		Login: Credentials{
+			AccessKey: "minioadmin",
+			SecretKey: "minioadmin",
+		},
+	}
+}
+
+// mockObject is an object stored by mockS3.
+type mockObject struct {
+	Value       []byte
+	LockMode    string
+	RetainUntil time.Time
+}
+
+// mockS3 is an in-memory S3 bucket implementing the subset
+// of the S3 API, using path-style addressing, used by the
+// Store.
+type mockS3 struct {
+	bucket     string
+	objectLock bool
+	pageSize   int // Max. number of objects per list response. Defaults to 1000
+
+	lock    sync.Mutex
+	objects map[string]mockObject
+}
+
+func newMockS3(bucket string) *mockS3 {
+	return &mockS3{bucket: bucket, objects: map[string]mockObject{}}
+}
+
+func (m *mockS3) put(name string, value []byte) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.objects[name] = mockObject{Value: value}
+}
+
+func (m *mockS3) object(name string) mockObject {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.objects[name]
+}
+
+func (m *mockS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != m.bucket {
+		writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	query := r.URL.Query()
+	switch {
+	case name == "" && r.Method == http.MethodGet && query.Has("object-lock"):
+		if !m.objectLock {
+			writeError(w, http.StatusNotFound, "ObjectLockConfigurationNotFoundError")
+			return
+		}
+		writeXML(w, struct {
+			XMLName           xml.Name `xml:"ObjectLockConfiguration"`
+			ObjectLockEnabled string
+		}{ObjectLockEnabled: "Enabled"})
+	case name == "" && r.Method == http.MethodGet:
+		type Object struct{ Key string }
+		type CommonPrefix struct{ Prefix string }
+		result := struct {
+			XMLName               xml.Name `xml:"ListBucketResult"`
+			Name                  string
+			Prefix                string
+			IsTruncated           bool
+			NextContinuationToken string
+			Contents              []Object
+			CommonPrefixes        []CommonPrefix
+		}{Name: m.bucket, Prefix: query.Get("prefix")}
+
+		pageSize := m.pageSize
+		if pageSize <= 0 {
+			pageSize = 1000
+		}
+		startAfter := query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			startAfter = token
+		}
+
+		keys := slices.Sorted(maps.Keys(m.objects))
+		delimiter, last := query.Get("delimiter"), ""
+		for _, key := range keys {
+			if key <= startAfter {
+				continue
+			}
+			rest, ok := strings.CutPrefix(key, result.Prefix)
+			if !ok {
+				continue
+			}
+			if len(result.Contents)+len(result.CommonPrefixes) == pageSize {
+				result.IsTruncated = true
+				result.NextContinuationToken = last
+				break
+			}
+			last = key
+			if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+				result.CommonPrefixes = append(result.CommonPrefixes, CommonPrefix{Prefix: result.Prefix + rest[:i+1]})
+				continue
+			}
+			result.Contents = append(result.Contents, Object{Key: key})
+		}
+		writeXML(w, result)
+	case r.Method == http.MethodPut:
+		if r.Header.Get("If-None-Match") == "*" {
+			if _, ok := m.objects[name]; ok {
+				writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+				return
+			}
+		}
+		value, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		obj := mockObject{Value: value, LockMode: r.Header.Get("X-Amz-Object-Lock-Mode")}
+		if v := r.Header.Get("X-Amz-Object-Lock-Retain-Until-Date"); v != "" {
+			if obj.RetainUntil, err = time.Parse(time.RFC3339, v); err != nil {
+				writeError(w, http.StatusBadRequest, "InvalidArgument")
+				return
+			}
+		}
+		m.objects[name] = obj
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := m.objects[name]
+		if !ok {
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Write(obj.Value)
+		}
+	case r.Method == http.MethodDelete:
+		delete(m.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+	}{Code: code})
+}
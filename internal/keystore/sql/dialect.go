@@ -0,0 +1,68 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package sql
+
+import "strings"
+
+// Dialect adapts the Store to a particular SQL database.
+//
+// The Store builds all generic statements using the
+// dialect's placeholders. Only the schema and statements
+// that differ between databases are dialect-specific.
+type Dialect interface {
+	// Name returns the name of the dialect, e.g. "postgres".
+	Name() string
+
+	// Placeholder returns the n-th placeholder of a statement,
+	// starting at 1. For example, "$1" or "?".
+	Placeholder(n int) string
+
+	// Migrations returns the statements that create and
+	// evolve the schema of the given table. The i-th entry
+	// migrates the schema from version i to version i+1.
+	//
+	// The table must have a name and a value column. The
+	// name column must be the primary key and sort names
+	// by their bytes.
+	//
+	// Migrations must only be appended, never modified.
+	Migrations(table string) [][]string
+
+	// InsertIfAbsent returns a statement that inserts a
+	// name and value into the table if no row with this
+	// name exists. It must not fail but affect no rows
+	// if the name exists.
+	InsertIfAbsent(table string) string
+}
+
+var dialects = map[string]Dialect{
+	"postgres": Postgres,
+	"mysql":    MySQL,
+	"sqlite":   SQLite,
+}
+
+// driverDialects maps well-known database/sql
+// driver names to their dialect.
+var driverDialects = map[string]Dialect{
+	"postgres": Postgres,
+	"pgx":      Postgres,
+	"mysql":    MySQL,
+	"sqlite":   SQLite,
+	"sqlite3":  SQLite,
+}
+
+// LookupDialect returns the dialect with the given name.
+// Names are case-insensitive.
+func LookupDialect(name string) (Dialect, bool) {
+	d, ok := dialects[strings.ToLower(name)]
+	return d, ok
+}
+
+// DriverDialect returns the dialect of well-known
+// database/sql drivers, like "pgx" or "sqlite3".
+func DriverDialect(driver string) (Dialect, bool) {
+	d, ok := driverDialects[driver]
+	return d, ok
+}
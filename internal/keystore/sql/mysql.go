@@ -0,0 +1,32 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package sql
+
+// MySQL is the dialect for MySQL and MariaDB.
+var MySQL Dialect = mysql{}
+
+type mysql struct{}
+
+func (mysql) Name() string { return "mysql" }
+
+func (mysql) Placeholder(int) string { return "?" }
+
+func (mysql) Migrations(table string) [][]string {
+	return [][]string{
+		{ // 1
+			`CREATE TABLE IF NOT EXISTS ` + table + ` (
+				name VARCHAR(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL PRIMARY KEY,
+				value BLOB NOT NULL
+			)`,
+		},
+	}
+}
+
+// InsertIfAbsent uses a no-op update instead of INSERT IGNORE
+// since INSERT IGNORE turns any error into a warning. MySQL
+// reports no affected rows for a no-op update.
+func (mysql) InsertIfAbsent(table string) string {
+	return `INSERT INTO ` + table + ` (name, value) VALUES (?, ?) ON DUPLICATE KEY UPDATE name = name`
+}
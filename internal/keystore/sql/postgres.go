@@ -0,0 +1,32 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package sql
+
+import "strconv"
+
+// Postgres is the dialect for PostgreSQL and
+// compatible databases, like CockroachDB.
+var Postgres Dialect = postgres{}
+
+type postgres struct{}
+
+func (postgres) Name() string { return "postgres" }
+
+func (postgres) Placeholder(n int) string { return "$" + strconv.Itoa(n) }
+
+func (postgres) Migrations(table string) [][]string {
+	return [][]string{
+		{ // 1
+			`CREATE TABLE IF NOT EXISTS ` + table + ` (
+				name TEXT COLLATE "C" NOT NULL PRIMARY KEY,
+				value BYTEA NOT NULL
+			)`,
+		},
+	}
+}
+
+func (postgres) InsertIfAbsent(table string) string {
+	return `INSERT INTO ` + table + ` (name, value) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING`
+}
@@ -0,0 +1,29 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package sql
+
+// SQLite is the dialect for SQLite 3.24 or newer.
+var SQLite Dialect = sqlite{}
+
+type sqlite struct{}
+
+func (sqlite) Name() string { return "sqlite" }
+
+func (sqlite) Placeholder(int) string { return "?" }
+
+func (sqlite) Migrations(table string) [][]string {
+	return [][]string{
+		{ // 1
+			`CREATE TABLE IF NOT EXISTS ` + table + ` (
+				name TEXT NOT NULL PRIMARY KEY,
+				value BLOB NOT NULL
+			)`,
+		},
+	}
+}
+
+func (sqlite) InsertIfAbsent(table string) string {
+	return `INSERT INTO ` + table + ` (name, value) VALUES (?, ?) ON CONFLICT (name) DO NOTHING`
+}
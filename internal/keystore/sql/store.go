@@ -0,0 +1,388 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package sql implements a key-value store that stores
+// keys within a table of a SQL database.
+//
+// The Store works with any database/sql driver. Databases
+// differ in their schema definitions and statements. A
+// Dialect adapts the Store to a particular database.
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// DefaultTable is the default name of the table
+// that stores keys.
+const DefaultTable = "kes_keys"
+
+// Config is a structure containing configuration
+// options for connecting to a SQL database.
+type Config struct {
+	// Driver is the database/sql driver name, e.g. "pgx".
+	// The driver must be registered with database/sql,
+	// i.e. compiled into the KES binary.
+	Driver string
+
+	// DSN is the data source name used to connect
+	// to the database.
+	DSN string
+
+	// Dialect is the SQL dialect of the database. If
+	// nil, it is derived from the driver name.
+	Dialect Dialect
+
+	// Table is the name of the table that stores
+	// keys. If empty, defaults to DefaultTable.
+	Table string
+
+	// MaxOpenConns is the maximum number of open
+	// connections to the database. If <= 0, the
+	// number of connections is not limited.
+	MaxOpenConns int
+
+	// ReadOnly controls whether the database is a read-only
+	// replica, like a Postgres hot standby. Connect does not
+	// migrate the schema of a read-only database but checks
+	// that it has been migrated.
+	ReadOnly bool
+}
+
+var validTable = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]{0,62}$`)
+
+// Connect connects to the SQL database and migrates
+// the schema of the key table to the latest version,
+// unless the database is read-only.
+func Connect(ctx context.Context, config *Config) (*Store, error) {
+	if config.Driver == "" {
+		return nil, errors.New("sql: no driver specified")
+	}
+	dialect := config.Dialect
+	if dialect == nil {
+		d, ok := DriverDialect(config.Driver)
+		if !ok {
+			return nil, fmt.Errorf("sql: no dialect specified for driver '%s'", config.Driver)
+		}
+		dialect = d
+	}
+	table := config.Table
+	if table == "" {
+		table = DefaultTable
+	}
+	if !validTable.MatchString(table) {
+		return nil, fmt.Errorf("sql: invalid table name '%s'", table)
+	}
+
+	db, err := dbsql.Open(config.Driver, config.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("sql: failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+
+	s := &Store{
+		db:      db,
+		dialect: dialect,
+		table:   table,
+		stmts:   map[string]*dbsql.Stmt{},
+	}
+	if config.ReadOnly {
+		err = s.checkSchema(ctx)
+	} else {
+		err = s.migrate(ctx)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Store is a connection to a SQL database.
+type Store struct {
+	db      *dbsql.DB
+	dialect Dialect
+	table   string
+
+	lock  sync.Mutex
+	stmts map[string]*dbsql.Stmt // Prepared statements by query
+}
+
+func (s *Store) String() string { return "SQL (" + s.dialect.Name() + "): " + s.table }
+
+// Status returns the current state of the database.
+func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	start := time.Now()
+	if err := s.db.PingContext(ctx); err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return kes.KeyStoreState{}, err
+		}
+		return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+	}
+	return kes.KeyStoreState{
+		Latency: time.Since(start),
+	}, nil
+}
+
+// Create stores the given key-value pair if and only if
+// no entry for the given name exists. It returns
+// kes.ErrKeyExists if such an entry exists.
+func (s *Store) Create(ctx context.Context, name string, value []byte) error {
+	stmt, err := s.stmt(ctx, s.dialect.InsertIfAbsent(s.table))
+	if err != nil {
+		return err
+	}
+	result, err := stmt.ExecContext(ctx, name, value)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("sql: failed to create '%s': %v", name, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("sql: failed to create '%s': %v", name, err)
+	}
+	if n == 0 {
+		return kesdk.ErrKeyExists
+	}
+	return nil
+}
+
+// CanSwap returns true. The database updates rows atomically.
+func (s *Store) CanSwap() bool { return true }
+
+// Swap replaces the value of the entry with the given name if and
+// only if its current value is equal to old. It returns
+// kes.ErrKeyNotFound if no such entry exists and kes.ErrKeyExists
+// if the entry's value is not equal to old.
+func (s *Store) Swap(ctx context.Context, name string, old, value []byte) error {
+	p := s.dialect.Placeholder
+	stmt, err := s.stmt(ctx, "UPDATE "+s.table+" SET value = "+p(1)+" WHERE name = "+p(2)+" AND value = "+p(3))
+	if err != nil {
+		return err
+	}
+	result, err := stmt.ExecContext(ctx, value, name, old)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("sql: failed to update '%s': %v", name, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("sql: failed to update '%s': %v", name, err)
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err = s.Get(ctx, name); err != nil {
+		return err
+	}
+	return kesdk.ErrKeyExists
+}
+
+// Get returns the value associated with the given key.
+// It returns kes.ErrKeyNotFound if no such entry exists.
+func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
+	stmt, err := s.stmt(ctx, "SELECT value FROM "+s.table+" WHERE name = "+s.dialect.Placeholder(1))
+	if err != nil {
+		return nil, err
+	}
+
+	var value []byte
+	err = stmt.QueryRowContext(ctx, name).Scan(&value)
+	if errors.Is(err, dbsql.ErrNoRows) {
+		return nil, kesdk.ErrKeyNotFound
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sql: failed to read '%s': %v", name, err)
+	}
+	return value, nil
+}
+
+// Delete removes the key with the given value, if it exists.
+// It returns kes.ErrKeyNotFound if no such entry exists.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	stmt, err := s.stmt(ctx, "DELETE FROM "+s.table+" WHERE name = "+s.dialect.Placeholder(1))
+	if err != nil {
+		return err
+	}
+	result, err := stmt.ExecContext(ctx, name)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("sql: failed to delete '%s': %v", name, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("sql: failed to delete '%s': %v", name, err)
+	}
+	if n == 0 {
+		return kesdk.ErrKeyNotFound
+	}
+	return nil
+}
+
+// List returns the first n key names, that start with the given
+// prefix, and the next prefix from which the listing should
+// continue.
+//
+// It returns all keys with the prefix if n < 0 and less than n
+// names if n is greater than the number of keys with the prefix.
+//
+// An empty prefix matches any key name. At the end of the listing
+// or when there are no (more) keys starting with the prefix, the
+// returned prefix is empty
+func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	const N = 1024
+
+	limit := N
+	if n > 0 && n < N {
+		limit = n
+	}
+	stmt, err := s.stmt(ctx, "SELECT name FROM "+s.table+" WHERE name >= "+s.dialect.Placeholder(1)+" ORDER BY name LIMIT "+s.dialect.Placeholder(2))
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := stmt.QueryContext(ctx, prefix, limit+1)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, "", err
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("sql: failed to list keys: %v", err)
+	}
+	defer rows.Close()
+
+	names := make([]string, 0, limit+1)
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, "", fmt.Errorf("sql: failed to list keys: %v", err)
+		}
+		if !strings.HasPrefix(name, prefix) {
+			break
+		}
+		names = append(names, name)
+	}
+	if err = rows.Err(); err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, "", err
+		}
+		return nil, "", fmt.Errorf("sql: failed to list keys: %v", err)
+	}
+
+	if len(names) > limit {
+		return names[:limit], names[limit], nil
+	}
+	return names, "", nil
+}
+
+// Close closes all prepared statements and the
+// connection to the database.
+func (s *Store) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for query, stmt := range s.stmts {
+		stmt.Close()
+		delete(s.stmts, query)
+	}
+	return s.db.Close()
+}
+
+// stmt returns a prepared statement for the given query.
+// It prepares each query once and caches the statement.
+func (s *Store) stmt(ctx context.Context, query string) (*dbsql.Stmt, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if stmt, ok := s.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sql: failed to prepare statement: %v", err)
+	}
+	s.stmts[query] = stmt
+	return stmt, nil
+}
+
+// checkSchema checks that the schema of the key table has
+// been migrated to a version supported by the dialect. It
+// does not modify the database.
+func (s *Store) checkSchema(ctx context.Context) error {
+	versions := s.table + "_schema"
+
+	var version dbsql.NullInt64
+	if err := s.db.QueryRowContext(ctx, "SELECT MAX(version) FROM "+versions).Scan(&version); err != nil {
+		return fmt.Errorf("sql: failed to read schema version: %v", err)
+	}
+	migrations := s.dialect.Migrations(s.table)
+	if version.Int64 == 0 {
+		return fmt.Errorf("sql: table '%s' has not been created", s.table)
+	}
+	if int(version.Int64) > len(migrations) {
+		return fmt.Errorf("sql: schema version %d of table '%s' is newer than supported version %d", version.Int64, s.table, len(migrations))
+	}
+	return nil
+}
+
+// migrate applies all migrations of the dialect that
+// have not been applied yet. It records the current
+// schema version in a separate table.
+func (s *Store) migrate(ctx context.Context) error {
+	versions := s.table + "_schema"
+	if _, err := s.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+versions+" (version INTEGER NOT NULL)"); err != nil {
+		return fmt.Errorf("sql: failed to create schema table '%s': %v", versions, err)
+	}
+
+	var version dbsql.NullInt64
+	if err := s.db.QueryRowContext(ctx, "SELECT MAX(version) FROM "+versions).Scan(&version); err != nil {
+		return fmt.Errorf("sql: failed to read schema version: %v", err)
+	}
+
+	migrations := s.dialect.Migrations(s.table)
+	if int(version.Int64) > len(migrations) {
+		return fmt.Errorf("sql: schema version %d of table '%s' is newer than supported version %d", version.Int64, s.table, len(migrations))
+	}
+	for v := int(version.Int64); v < len(migrations); v++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("sql: failed to migrate schema to version %d: %v", v+1, err)
+		}
+		for _, statement := range migrations[v] {
+			if _, err = tx.ExecContext(ctx, statement); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("sql: failed to migrate schema to version %d: %v", v+1, err)
+			}
+		}
+		if _, err = tx.ExecContext(ctx, "INSERT INTO "+versions+" (version) VALUES ("+s.dialect.Placeholder(1)+")", v+1); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("sql: failed to migrate schema to version %d: %v", v+1, err)
+		}
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("sql: failed to migrate schema to version %d: %v", v+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package sql
+
+import (
+	"bytes"
+	"context"
+	dbsql "database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestStore(t *testing.T) {
+	for _, dialect := range []Dialect{Postgres, MySQL, SQLite} {
+		db := newFakeDB()
+		dbsql.Register("fake-"+dialect.Name(), db)
+
+		ctx := context.Background()
+		store, err := Connect(ctx, &Config{Driver: "fake-" + dialect.Name(), Dialect: dialect})
+		if err != nil {
+			t.Fatalf("%s: failed to connect: %v", dialect.Name(), err)
+		}
+		if db.version != len(dialect.Migrations(DefaultTable)) {
+			t.Fatalf("%s: schema version mismatch: got %d - want %d", dialect.Name(), db.version, len(dialect.Migrations(DefaultTable)))
+		}
+
+		if err = store.Create(ctx, "my-key", []byte("my-value")); err != nil {
+			t.Fatalf("%s: failed to create key: %v", dialect.Name(), err)
+		}
+		if err = store.Create(ctx, "my-key", []byte("my-value")); !errors.Is(err, kesdk.ErrKeyExists) {
+			t.Fatalf("%s: created existing key: %v", dialect.Name(), err)
+		}
+		if value, err := store.Get(ctx, "my-key"); err != nil || string(value) != "my-value" {
+			t.Fatalf("%s: failed to get key: got '%s' - %v", dialect.Name(), value, err)
+		}
+		if _, err = store.Get(ctx, "other-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+			t.Fatalf("%s: fetched non-existing key: %v", dialect.Name(), err)
+		}
+		if err = store.Delete(ctx, "my-key"); err != nil {
+			t.Fatalf("%s: failed to delete key: %v", dialect.Name(), err)
+		}
+		if err = store.Delete(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+			t.Fatalf("%s: deleted non-existing key: %v", dialect.Name(), err)
+		}
+		if err = store.Create(ctx, "my-key", []byte("my-value")); err != nil {
+			t.Fatalf("%s: failed to create key: %v", dialect.Name(), err)
+		}
+		if err = store.Swap(ctx, "my-key", []byte("other-value"), []byte("new-value")); !errors.Is(err, kesdk.ErrKeyExists) {
+			t.Fatalf("%s: swapped key with mismatching value: %v", dialect.Name(), err)
+		}
+		if err = store.Swap(ctx, "my-key", []byte("my-value"), []byte("new-value")); err != nil {
+			t.Fatalf("%s: failed to swap key: %v", dialect.Name(), err)
+		}
+		if value, err := store.Get(ctx, "my-key"); err != nil || string(value) != "new-value" {
+			t.Fatalf("%s: failed to get swapped key: got '%s' - %v", dialect.Name(), value, err)
+		}
+		if err = store.Swap(ctx, "other-key", []byte("my-value"), []byte("new-value")); !errors.Is(err, kesdk.ErrKeyNotFound) {
+			t.Fatalf("%s: swapped non-existing key: %v", dialect.Name(), err)
+		}
+		if db.prepared != 3 { // INSERT, SELECT and DELETE
+			t.Fatalf("%s: prepared %d statements - want 3", dialect.Name(), db.prepared)
+		}
+		if err = store.Close(); err != nil {
+			t.Fatalf("%s: failed to close store: %v", dialect.Name(), err)
+		}
+
+		// Reconnecting must not apply migrations again
+		if store, err = Connect(ctx, &Config{Driver: "fake-" + dialect.Name(), Dialect: dialect}); err != nil {
+			t.Fatalf("%s: failed to connect: %v", dialect.Name(), err)
+		}
+		if db.migrations != 1 {
+			t.Fatalf("%s: applied migrations %d times - want 1", dialect.Name(), db.migrations)
+		}
+		store.Close()
+	}
+}
+
+func TestStoreList(t *testing.T) {
+	db := newFakeDB()
+	dbsql.Register("fake-list", db)
+
+	ctx := context.Background()
+	store, err := Connect(ctx, &Config{Driver: "fake-list", Dialect: Postgres})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer store.Close()
+
+	for i := range 1100 {
+		if err = store.Create(ctx, fmt.Sprintf("key-%04d", i), []byte("value")); err != nil {
+			t.Fatalf("Failed to create key: %v", err)
+		}
+	}
+	if err = store.Create(ctx, "other-key", []byte("value")); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	for i, test := range listTests {
+		names, next, err := store.List(ctx, test.Prefix, test.N)
+		if err != nil {
+			t.Fatalf("Test %d: failed to list keys: %v", i, err)
+		}
+		if len(names) != test.Len {
+			t.Fatalf("Test %d: got %d names - want %d", i, len(names), test.Len)
+		}
+		if next != test.Next {
+			t.Fatalf("Test %d: next prefix mismatch: got '%s' - want '%s'", i, next, test.Next)
+		}
+	}
+}
+
+func TestConnectReadOnly(t *testing.T) {
+	db := newFakeDB()
+	dbsql.Register("fake-readonly", db)
+
+	ctx := context.Background()
+	config := &Config{Driver: "fake-readonly", Dialect: Postgres, ReadOnly: true}
+	if _, err := Connect(ctx, config); err == nil {
+		t.Fatal("Connected to read-only database without schema")
+	}
+
+	db.version = len(Postgres.Migrations(DefaultTable))
+	store, err := Connect(ctx, config)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer store.Close()
+	if db.migrations != 0 {
+		t.Fatalf("Migrated read-only database: got %d migrations - want 0", db.migrations)
+	}
+}
+
+func TestConnect(t *testing.T) {
+	for i, test := range connectTests {
+		if _, err := Connect(context.Background(), &test.Config); err == nil {
+			t.Fatalf("Test %d: connect should have failed", i)
+		}
+	}
+}
+
+var listTests = []struct {
+	Prefix string
+	N      int
+	Len    int
+	Next   string
+}{
+	{Prefix: "", N: -1, Len: 1024, Next: "key-1024"},   // 0
+	{Prefix: "key-", N: 10, Len: 10, Next: "key-0010"}, // 1
+	{Prefix: "key-10", N: -1, Len: 100},                // 2
+	{Prefix: "other", N: 1, Len: 1},                    // 3
+	{Prefix: "does-not-exist", N: -1, Len: 0},          // 4
+}
+
+var connectTests = []struct {
+	Config Config
+}{
+	{Config: Config{}},                         // 0
+	{Config: Config{Driver: "unknown-driver"}}, // 1
+	{Config: Config{Driver: "unknown-driver", Dialect: Postgres, Table: "a;b"}}, // 2
+}
+
+// fakeDB is an in-memory database/sql driver that
+// understands the statements issued by the Store.
+type fakeDB struct {
+	lock       sync.Mutex
+	keys       map[string][]byte
+	version    int
+	migrations int
+	prepared   int
+}
+
+func newFakeDB() *fakeDB { return &fakeDB{keys: map[string][]byte{}} }
+
+func (db *fakeDB) Open(string) (driver.Conn, error) { return fakeConn{db}, nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	c.db.lock.Lock()
+	defer c.db.lock.Unlock()
+
+	if strings.HasPrefix(query, "INSERT INTO kes_keys ") || strings.HasPrefix(query, "SELECT value ") ||
+		strings.HasPrefix(query, "SELECT name ") || strings.HasPrefix(query, "DELETE ") {
+		c.db.prepared++
+	}
+	return fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c fakeConn) Close() error { return nil }
+
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error { return nil }
+
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error { return nil }
+
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.lock.Lock()
+	defer s.db.lock.Unlock()
+
+	switch q := s.query; {
+	case strings.HasPrefix(q, "CREATE TABLE IF NOT EXISTS kes_keys_schema"):
+	case strings.HasPrefix(q, "CREATE TABLE IF NOT EXISTS kes_keys"):
+		s.db.migrations++
+	case strings.HasPrefix(q, "INSERT INTO kes_keys_schema"):
+		s.db.version = int(args[0].(int64))
+	case strings.HasPrefix(q, "INSERT INTO kes_keys"):
+		if _, ok := s.db.keys[args[0].(string)]; ok {
+			return driver.RowsAffected(0), nil
+		}
+		s.db.keys[args[0].(string)] = args[1].([]byte)
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(q, "UPDATE kes_keys"):
+		value, ok := s.db.keys[args[1].(string)]
+		if !ok || !bytes.Equal(value, args[2].([]byte)) {
+			return driver.RowsAffected(0), nil
+		}
+		s.db.keys[args[1].(string)] = args[0].([]byte)
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(q, "DELETE FROM kes_keys"):
+		if _, ok := s.db.keys[args[0].(string)]; !ok {
+			return driver.RowsAffected(0), nil
+		}
+		delete(s.db.keys, args[0].(string))
+		return driver.RowsAffected(1), nil
+	default:
+		return nil, errors.New("fake: unsupported statement: " + q)
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.lock.Lock()
+	defer s.db.lock.Unlock()
+
+	switch q := s.query; {
+	case strings.HasPrefix(q, "SELECT MAX(version)"):
+		if s.db.version == 0 {
+			return &fakeRows{column: "max", values: []driver.Value{nil}}, nil
+		}
+		return &fakeRows{column: "max", values: []driver.Value{int64(s.db.version)}}, nil
+	case strings.HasPrefix(q, "SELECT value"):
+		value, ok := s.db.keys[args[0].(string)]
+		if !ok {
+			return &fakeRows{column: "value"}, nil
+		}
+		return &fakeRows{column: "value", values: []driver.Value{value}}, nil
+	case strings.HasPrefix(q, "SELECT name"):
+		var names []string
+		for name := range s.db.keys {
+			if name >= args[0].(string) {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		names = names[:min(len(names), int(args[1].(int64)))]
+
+		rows := &fakeRows{column: "name"}
+		for _, name := range names {
+			rows.values = append(rows.values, name)
+		}
+		return rows, nil
+	default:
+		return nil, errors.New("fake: unsupported query: " + q)
+	}
+}
+
+type fakeRows struct {
+	column string
+	values []driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return []string{r.column} }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
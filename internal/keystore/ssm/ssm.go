@@ -0,0 +1,375 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package ssm implements a key-value store that stores
+// values as SecureString parameters at the AWS Systems
+// Manager (SSM) Parameter Store.
+//
+// Standard parameters are free of charge. Hence, it is
+// a low-cost alternative to the AWS SecretsManager.
+package ssm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// DefaultPath is the parameter path used if no path
+// is specified.
+const DefaultPath = "/kes"
+
+// Credentials represents static AWS credentials:
+// access key, secret key and a session token
+type Credentials struct {
+	AccessKey    string // The AWS access key
+	SecretKey    string // The AWS secret key
+	SessionToken string // The AWS session token
+}
+
+// Config is a structure containing configuration
+// options for connecting to the SSM Parameter Store.
+type Config struct {
+	// Addr is the HTTP address of the SSM Parameter Store.
+	// It may be a custom endpoint, like a VPC endpoint, with
+	// a "http://" or "https://" scheme. If empty, the regional
+	// AWS endpoint is used.
+	Addr string
+
+	// Region is the AWS region.
+	Region string
+
+	// Path is the parameter hierarchy keys are stored in.
+	// Keys are stored as "<path>/<name>" parameters. Hence,
+	// multiple KES deployments can share one AWS account and
+	// IAM policies can restrict access to the hierarchy. It
+	// must start with a '/'. If empty, DefaultPath is used.
+	Path string
+
+	// KMSKeyID is the ID, ARN or alias of the AWS-KMS key
+	// used to encrypt the parameters. If empty, the AWS
+	// managed key "alias/aws/ssm" is used.
+	KMSKeyID string
+
+	// Tier is the parameter tier. Either "Standard",
+	// "Advanced" or "Intelligent-Tiering". Standard
+	// parameters are free but limited to 4 KB. If
+	// empty, the standard tier is used.
+	Tier string
+
+	// Login contains the AWS credentials (access/secret key).
+	// If empty, the default credential chain is used.
+	Login Credentials
+}
+
+// Connect returns a new Store that stores keys as SecureString
+// parameters within the configured parameter path.
+//
+// It returns an error if the SSM Parameter Store is not
+// accessible.
+func Connect(ctx context.Context, cfg *Config) (*Store, error) {
+	if cfg.Region == "" {
+		return nil, errors.New("ssm: no region specified")
+	}
+	path := cfg.Path
+	if path == "" {
+		path = DefaultPath
+	}
+	if err := validatePath(path); err != nil {
+		return nil, err
+	}
+	tier := types.ParameterTierStandard
+	if cfg.Tier != "" {
+		tier = types.ParameterTier(cfg.Tier)
+		if !slices.Contains(tier.Values(), tier) {
+			return nil, fmt.Errorf("ssm: invalid tier '%s'", cfg.Tier)
+		}
+	}
+
+	opts := []func(*config.LoadOptions) error{
+		config.WithRegion(cfg.Region),
+	}
+	if cfg.Login.AccessKey != "" || cfg.Login.SecretKey != "" || cfg.Login.SessionToken != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(
+				cfg.Login.AccessKey,
+				cfg.Login.SecretKey,
+				cfg.Login.SessionToken,
+			),
+		))
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		config: *cfg,
+		path:   strings.TrimSuffix(path, "/"),
+		tier:   tier,
+		client: ssm.NewFromConfig(awsCfg, func(o *ssm.Options) {
+			if cfg.Addr != "" {
+				o.BaseEndpoint = aws.String(cfg.Addr)
+			}
+		}),
+	}
+	if _, err = s.Status(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// validatePath returns an error if path is not a valid
+// SSM parameter hierarchy.
+func validatePath(path string) error {
+	const MaxDepth = 14 // SSM allows 15 levels, including the key name
+
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("ssm: invalid path '%s': must start with '/'", path)
+	}
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return nil
+	}
+	segments := strings.Split(trimmed, "/")
+	if len(segments) > MaxDepth {
+		return fmt.Errorf("ssm: invalid path '%s': more than %d levels", path, MaxDepth)
+	}
+	if first := strings.ToLower(segments[0]); strings.HasPrefix(first, "aws") || strings.HasPrefix(first, "ssm") {
+		return fmt.Errorf("ssm: invalid path '%s': the 'aws' and 'ssm' prefixes are reserved", path)
+	}
+	for _, segment := range segments {
+		if segment == "" {
+			return fmt.Errorf("ssm: invalid path '%s': contains empty level", path)
+		}
+		for _, r := range segment {
+			if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || strings.ContainsRune("_.-", r)) {
+				return fmt.Errorf("ssm: invalid path '%s': contains invalid character '%c'", path, r)
+			}
+		}
+	}
+	return nil
+}
+
+// Store is an SSM Parameter Store key store.
+type Store struct {
+	config Config
+	path   string
+	tier   types.ParameterTier
+	client *ssm.Client
+}
+
+func (s *Store) String() string { return "AWS SSM Parameter Store: " + s.path }
+
+// parameterName returns the name of the parameter storing
+// the key with the given name.
+func (s *Store) parameterName(name string) string { return s.path + "/" + name }
+
+// Status returns the current state of the SSM Parameter Store.
+// In particular, whether it is reachable and the network latency.
+//
+// It describes at most one parameter such that invalid credentials
+// or missing permissions are detected as well. Such failures are
+// reported as unauthenticated or unauthorized state along with an
+// error.
+func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	start := time.Now()
+	_, err := s.client.DescribeParameters(ctx, &ssm.DescribeParametersInput{
+		ParameterFilters: s.pathFilter(),
+		MaxResults:       aws.Int32(1),
+	})
+	latency := time.Since(start)
+	if err == nil {
+		return kes.KeyStoreState{Latency: latency}, nil
+	}
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		if isUnreachable(err) {
+			return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+		}
+		return kes.KeyStoreState{}, err
+	}
+	switch apiErr.ErrorCode() {
+	case "UnrecognizedClientException", "InvalidClientTokenId", "InvalidSignatureException",
+		"SignatureDoesNotMatch", "IncompleteSignature", "MissingAuthenticationToken",
+		"ExpiredTokenException", "ExpiredToken":
+		return kes.KeyStoreState{Latency: latency, Unauthenticated: true}, fmt.Errorf("ssm: authentication failed: %v", err)
+	case "AccessDeniedException", "AccessDenied":
+		return kes.KeyStoreState{Latency: latency, Unauthorized: true}, fmt.Errorf("ssm: access denied: %v", err)
+	default:
+		return kes.KeyStoreState{Latency: latency}, err
+	}
+}
+
+// Create stores the given key-value pair as SecureString parameter
+// if and only if it doesn't exist. If such a parameter already exists
+// it returns kes.ErrKeyExists.
+//
+// Parameter values are strings. Hence, it returns an error if the
+// value is not valid UTF-8.
+func (s *Store) Create(ctx context.Context, name string, value []byte) error {
+	if !utf8.Valid(value) {
+		return fmt.Errorf("ssm: failed to create '%s': value is not valid UTF-8", name)
+	}
+
+	input := &ssm.PutParameterInput{
+		Name:      aws.String(s.parameterName(name)),
+		Value:     aws.String(string(value)),
+		Type:      types.ParameterTypeSecureString,
+		Tier:      s.tier,
+		Overwrite: aws.Bool(false),
+	}
+	if s.config.KMSKeyID != "" {
+		input.KeyId = aws.String(s.config.KMSKeyID)
+	}
+	if _, err := s.client.PutParameter(ctx, input); err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+
+		var pae *types.ParameterAlreadyExists
+		if errors.As(err, &pae) {
+			return kesdk.ErrKeyExists
+		}
+		return fmt.Errorf("ssm: failed to create '%s': %v", name, err)
+	}
+	return nil
+}
+
+// Get returns the decrypted value of the parameter storing the
+// given key. If no such parameter exists, it returns
+// kes.ErrKeyNotFound.
+func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
+	response, err := s.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(s.parameterName(name)),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+
+		var pnf *types.ParameterNotFound
+		if errors.As(err, &pnf) {
+			return nil, kesdk.ErrKeyNotFound
+		}
+		return nil, fmt.Errorf("ssm: failed to read '%s': %v", name, err)
+	}
+	if response.Parameter == nil || response.Parameter.Value == nil {
+		return nil, fmt.Errorf("ssm: failed to read '%s': parameter has no value", name)
+	}
+	return []byte(*response.Parameter.Value), nil
+}
+
+// Delete removes the parameter storing the given key, if it
+// exists. It returns kes.ErrKeyNotFound if no such parameter
+// exists.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	_, err := s.client.DeleteParameter(ctx, &ssm.DeleteParameterInput{
+		Name: aws.String(s.parameterName(name)),
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+
+		var pnf *types.ParameterNotFound
+		if errors.As(err, &pnf) {
+			return kesdk.ErrKeyNotFound
+		}
+		return fmt.Errorf("ssm: failed to delete '%s': %v", name, err)
+	}
+	return nil
+}
+
+// List returns the first n key names, that start with the given
+// prefix, and the next prefix from which the listing should
+// continue.
+//
+// It returns all keys with the prefix if n < 0 and less than n
+// names if n is greater than the number of keys with the prefix.
+//
+// An empty prefix matches any key name. At the end of the listing
+// or when there are no (more) keys starting with the prefix, the
+// returned prefix is empty
+//
+// Only parameters directly within the parameter path are listed.
+// Parameters within nested paths are ignored.
+func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	const PageSize = 50 // Max. number of results per DescribeParameters request
+
+	input := &ssm.DescribeParametersInput{
+		ParameterFilters: s.pathFilter(),
+		MaxResults:       aws.Int32(PageSize),
+	}
+	if prefix != "" {
+		input.ParameterFilters = append(input.ParameterFilters, types.ParameterStringFilter{
+			Key:    aws.String("Name"),
+			Option: aws.String("BeginsWith"),
+			Values: []string{s.parameterName(prefix)},
+		})
+	}
+
+	var names []string
+	for {
+		page, err := s.client.DescribeParameters(ctx, input)
+		if err != nil {
+			if isUnreachable(err) {
+				return nil, "", &keystore.ErrUnreachable{Err: err}
+			}
+			return nil, "", fmt.Errorf("ssm: failed to list keys: %v", err)
+		}
+		for _, p := range page.Parameters {
+			name, ok := strings.CutPrefix(aws.ToString(p.Name), s.path+"/")
+			if ok && name != "" && !strings.Contains(name, "/") {
+				names = append(names, name)
+			}
+		}
+		if aws.ToString(page.NextToken) == "" {
+			break
+		}
+		input.NextToken = page.NextToken
+	}
+	return keystore.List(names, prefix, n)
+}
+
+// Close closes the Store.
+func (s *Store) Close() error { return nil }
+
+// pathFilter returns the filter that restricts parameter
+// descriptions to the parameters directly within the path.
+func (s *Store) pathFilter() []types.ParameterStringFilter {
+	path := s.path
+	if path == "" {
+		path = "/"
+	}
+	return []types.ParameterStringFilter{{
+		Key:    aws.String("Path"),
+		Option: aws.String("OneLevel"),
+		Values: []string{path},
+	}}
+}
+
+// isUnreachable reports whether err indicates that the
+// SSM Parameter Store is not reachable.
+func isUnreachable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
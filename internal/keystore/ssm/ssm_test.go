@@ -0,0 +1,245 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package ssm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestStore(t *testing.T) {
+	mock := newMockSSM()
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, newConfig(srv.URL, "/kes/prod"))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	value := []byte("my-secret-value")
+	if err = store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", value); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Created key twice: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if err = store.Create(ctx, "binary-key", []byte{0xff, 0xfe}); err == nil {
+		t.Fatal("Created key with non-UTF-8 value")
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, value, err)
+	}
+	if p := mock.parameter("/kes/prod/my-key"); p.Type != "SecureString" || p.KeyId != "alias/kes" || p.Tier != "Standard" {
+		t.Fatalf("Invalid parameter: got type '%s', key '%s' and tier '%s'", p.Type, p.KeyId, p.Tier)
+	}
+	if _, err = store.Get(ctx, "missing-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Fetched non-existing key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if err = store.Delete(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Deleted key twice: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+}
+
+func TestStoreList(t *testing.T) {
+	mock := newMockSSM()
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, newConfig(srv.URL, "/kes"))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	// Parameters of other paths, including nested ones,
+	// must not be listed.
+	mock.put("/kes/nested/key-0", "value")
+	mock.put("/other/key-1", "value")
+	for _, name := range []string{"key-2", "key-1", "my-key", "key-0"} {
+		if err = store.Create(ctx, name, []byte("value")); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+
+	if names, _, err := store.List(ctx, "", -1); err != nil || !slices.Equal(names, []string{"key-0", "key-1", "key-2", "my-key"}) {
+		t.Fatalf("Failed to list keys: got '%v': %v", names, err)
+	}
+	if names, _, err := store.List(ctx, "key-", -1); err != nil || !slices.Equal(names, []string{"key-0", "key-1", "key-2"}) {
+		t.Fatalf("Failed to list keys: got '%v': %v", names, err)
+	}
+	if names, next, err := store.List(ctx, "", 2); err != nil || !slices.Equal(names, []string{"key-0", "key-1"}) || next != "key-2" {
+		t.Fatalf("Failed to list keys: got '%v' and '%s': %v", names, next, err)
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	for i, test := range validatePathTests {
+		if err := validatePath(test.Path); err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: path '%s' should be invalid", i, test.Path)
+		} else if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: path '%s' should be valid: %v", i, test.Path, err)
+		}
+	}
+}
+
+var validatePathTests = []struct {
+	Path       string
+	ShouldFail bool
+}{
+	{Path: "/"},                                              // 0
+	{Path: "/kes"},                                           // 1
+	{Path: "/kes/prod/eu-west-1"},                            // 2
+	{Path: "/kes/"},                                          // 3
+	{Path: "kes", ShouldFail: true},                          // 4
+	{Path: "/kes//prod", ShouldFail: true},                   // 5
+	{Path: "/aws/kes", ShouldFail: true},                     // 6
+	{Path: "/SSM-kes", ShouldFail: true},                     // 7
+	{Path: "/kes/prod key", ShouldFail: true},                // 8
+	{Path: "/" + strings.Repeat("a/", 15), ShouldFail: true}, // 9
+}
+
+func TestConnectConfig(t *testing.T) {
+	ctx := context.Background()
+	for i, config := range []*Config{
+		{Path: "/kes"},                                    // 0: no region
+		{Region: "us-east-1", Path: "kes"},                // 1: invalid path
+		{Region: "us-east-1", Path: "/kes", Tier: "Free"}, // 2: invalid tier
+	} {
+		if _, err := Connect(ctx, config); err == nil {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+	}
+}
+
+func newConfig(endpoint, path string) *Config {
+	return &Config{
+		Addr:     endpoint,
+		Region:   "us-east-1",
+		Path:     path,
+		KMSKeyID: "alias/kes",
+		Login:    Credentials{AccessKey: "access-key", SecretKey: "secret-key"},
+	}
+}
+
+// mockSSM is a minimal in-memory SSM Parameter Store
+// implementing the JSON protocol.
+type mockSSM struct {
+	mu         sync.Mutex
+	parameters map[string]mockParameter
+}
+
+type mockParameter struct {
+	Value string
+	Type  string
+	KeyId string
+	Tier  string
+}
+
+func newMockSSM() *mockSSM {
+	return &mockSSM{parameters: map[string]mockParameter{}}
+}
+
+func (m *mockSSM) put(name, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.parameters[name] = mockParameter{Value: value, Type: "SecureString"}
+}
+
+func (m *mockSSM) parameter(name string) mockParameter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.parameters[name]
+}
+
+func (m *mockSSM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var req struct {
+		Name             string
+		Value            string
+		Type             string
+		KeyId            string
+		Tier             string
+		Overwrite        bool
+		WithDecryption   bool
+		ParameterFilters []struct {
+			Key    string
+			Option string
+			Values []string
+		}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fail := func(code string) {
+		w.Header().Set("X-Amzn-ErrorType", code)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"__type": code, "message": code})
+	}
+	switch r.Header.Get("X-Amz-Target") {
+	case "AmazonSSM.PutParameter":
+		if _, ok := m.parameters[req.Name]; ok && !req.Overwrite {
+			fail("ParameterAlreadyExists")
+			return
+		}
+		m.parameters[req.Name] = mockParameter{Value: req.Value, Type: req.Type, KeyId: req.KeyId, Tier: req.Tier}
+		json.NewEncoder(w).Encode(map[string]any{"Version": 1, "Tier": req.Tier})
+	case "AmazonSSM.GetParameter":
+		p, ok := m.parameters[req.Name]
+		if !ok {
+			fail("ParameterNotFound")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"Parameter": map[string]any{"Name": req.Name, "Type": p.Type, "Value": p.Value},
+		})
+	case "AmazonSSM.DeleteParameter":
+		if _, ok := m.parameters[req.Name]; !ok {
+			fail("ParameterNotFound")
+			return
+		}
+		delete(m.parameters, req.Name)
+		w.Write([]byte("{}"))
+	case "AmazonSSM.DescribeParameters":
+		var params []map[string]string
+		for name := range m.parameters {
+			match := true
+			for _, f := range req.ParameterFilters {
+				switch f.Key {
+				case "Path":
+					dir := strings.TrimSuffix(f.Values[0], "/") + "/"
+					match = match && strings.HasPrefix(name, dir) && !strings.Contains(name[len(dir):], "/")
+				case "Name":
+					match = match && strings.HasPrefix(name, f.Values[0])
+				}
+			}
+			if match {
+				params = append(params, map[string]string{"Name": name})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"Parameters": params})
+	default:
+		http.Error(w, "unsupported operation", http.StatusBadRequest)
+	}
+}
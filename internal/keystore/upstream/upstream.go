@@ -0,0 +1,249 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package upstream implements a read-only key store that
+// fetches keys from another, upstream, KES server.
+//
+// It allows a KES server to act as caching proxy in front
+// of a remote KES cluster, for example at an edge site.
+// The proxy enforces its own policies and serves cached
+// keys locally. Only cache misses are forwarded upstream.
+package upstream
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"aead.dev/mem"
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// Config is a structure containing configuration
+// options for connecting to an upstream KES server.
+type Config struct {
+	// Endpoints are the endpoints of the upstream KES
+	// servers, e.g. "https://kes.example.com:7373".
+	// Keys are fetched from the first reachable one.
+	Endpoints []string
+
+	// TLS is the TLS client configuration. It must contain
+	// the client certificate of the proxy. The identity of
+	// the certificate must be a standby identity of the
+	// upstream servers.
+	TLS *tls.Config
+}
+
+// Connect returns a new Store that fetches keys from the
+// upstream KES servers.
+//
+// It returns an error if no upstream server is ready.
+func Connect(ctx context.Context, config *Config) (*Store, error) {
+	if len(config.Endpoints) == 0 {
+		return nil, errors.New("upstream: no endpoint specified")
+	}
+	if config.TLS == nil || (len(config.TLS.Certificates) == 0 && config.TLS.GetClientCertificate == nil) {
+		return nil, errors.New("upstream: no client certificate specified")
+	}
+
+	endpoints := make([]string, 0, len(config.Endpoints))
+	for _, endpoint := range config.Endpoints {
+		endpoints = append(endpoints, strings.TrimSuffix(endpoint, "/"))
+	}
+	tlsConf := config.TLS.Clone()
+	if tlsConf.MinVersion == 0 {
+		tlsConf.MinVersion = tls.VersionTLS12
+	}
+
+	s := &Store{
+		endpoints: endpoints,
+		client: &kesdk.Client{
+			Endpoints: endpoints,
+			HTTPClient: http.Client{
+				Transport: &http.Transport{
+					Proxy:               http.ProxyFromEnvironment,
+					TLSClientConfig:     tlsConf,
+					TLSHandshakeTimeout: 10 * time.Second,
+					ForceAttemptHTTP2:   true,
+				},
+			},
+		},
+	}
+	if _, err := s.Status(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Store is a read-only connection to upstream KES servers.
+type Store struct {
+	endpoints []string
+	client    *kesdk.Client
+}
+
+var errReadOnly = kesdk.NewError(http.StatusNotImplemented, "key store is read-only: keys are managed by the upstream KES server")
+
+func (s *Store) String() string { return "KES: " + strings.Join(s.endpoints, ", ") }
+
+// Status returns the current state of the upstream KES
+// servers. In particular, whether they are reachable, ready
+// and the network latency.
+func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	start := time.Now()
+	_, err := s.client.IsReady(ctx)
+	latency := time.Since(start)
+	if err != nil {
+		if isUnreachable(err) {
+			return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+		}
+		return kes.KeyStoreState{}, fmt.Errorf("upstream: server is not ready: %v", err)
+	}
+	return kes.KeyStoreState{Latency: latency}, nil
+}
+
+// Create returns an error since keys can only be created
+// at the upstream KES servers.
+func (s *Store) Create(context.Context, string, []byte) error { return errReadOnly }
+
+// Delete returns an error since keys can only be deleted
+// at the upstream KES servers.
+func (s *Store) Delete(context.Context, string) error { return errReadOnly }
+
+// Get fetches the named key from the first reachable upstream
+// KES server. It returns kes.ErrKeyNotFound if no such key
+// exists.
+//
+// Reserved entries, like time locks, are never fetched since
+// the upstream servers enforce them before handing out keys.
+func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
+	if strings.HasPrefix(name, "-") {
+		return nil, kesdk.ErrKeyNotFound
+	}
+
+	// Try the next endpoint if an upstream server is unreachable
+	// or fails with a server error, e.g. while it is restarting.
+	var err error
+	for _, endpoint := range s.endpoints {
+		var value []byte
+		if value, err = s.fetch(ctx, endpoint, name); err == nil || !isRetryable(err) {
+			return value, err
+		}
+	}
+	if isUnreachable(err) {
+		return nil, &keystore.ErrUnreachable{Err: err}
+	}
+	return nil, err
+}
+
+// List returns the first n key names, that start with the given
+// prefix, and the next prefix from which the listing should
+// continue.
+//
+// It returns all keys with the prefix if n < 0 and less than n
+// names if n is greater than the number of keys with the prefix.
+//
+// An empty prefix matches any key name. At the end of the listing
+// or when there are no (more) keys starting with the prefix, the
+// returned prefix is empty
+func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	names, next, err := s.client.ListKeys(ctx, prefix, n)
+	if err != nil {
+		if isUnreachable(err) {
+			return nil, "", &keystore.ErrUnreachable{Err: err}
+		}
+		return nil, "", fmt.Errorf("upstream: failed to list keys: %v", err)
+	}
+	if n >= 0 && len(names) > n {
+		return names[:n], names[n], nil
+	}
+	return names, next, nil
+}
+
+// Close closes the Store.
+func (s *Store) Close() error {
+	s.client.HTTPClient.CloseIdleConnections()
+	return nil
+}
+
+// fetch fetches the named key from the given endpoint.
+func (s *Store) fetch(ctx context.Context, endpoint, name string) ([]byte, error) {
+	const MaxResponseSize = 1 * mem.MB
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+api.PathCacheKey+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, kesdk.ErrKeyNotFound
+	default:
+		var response struct {
+			Message string `json:"message"`
+			Detail  string `json:"detail"`
+		}
+		json.NewDecoder(mem.LimitReader(resp.Body, MaxResponseSize)).Decode(&response)
+		if response.Message == "" {
+			response.Message = response.Detail
+		}
+		return nil, &statusError{
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("upstream: failed to fetch key '%s': %s: %s", name, resp.Status, response.Message),
+		}
+	}
+
+	var key api.SyncKey
+	if err = json.NewDecoder(mem.LimitReader(resp.Body, MaxResponseSize)).Decode(&key); err != nil {
+		return nil, fmt.Errorf("upstream: failed to fetch key '%s': %v", name, err)
+	}
+	if key.Name != name {
+		return nil, fmt.Errorf("upstream: failed to fetch key '%s': received key '%s'", name, key.Name)
+	}
+	return []byte(key.Key), nil
+}
+
+// statusError is an error response of an upstream server.
+type statusError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *statusError) Error() string { return e.Err.Error() }
+
+func (e *statusError) Unwrap() error { return e.Err }
+
+// isRetryable reports whether a request, that failed with
+// err, should be sent to the next upstream server. This is
+// the case if the server is unreachable or responded with
+// a server error.
+func isRetryable(err error) bool {
+	if isUnreachable(err) {
+		return true
+	}
+	var sErr *statusError
+	return errors.As(err, &sErr) && sErr.StatusCode >= http.StatusInternalServerError
+}
+
+// isUnreachable reports whether err indicates that
+// the upstream server is not reachable.
+func isUnreachable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,208 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestStore(t *testing.T) {
+	mock := &mockKES{keys: map[string]string{"my-key": "my-key-value", "other-key": "other-key-value"}}
+	srv := httptest.NewTLSServer(mock)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, newConfig(t, srv, srv.URL))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, []byte("my-key-value")) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, "my-key-value", err)
+	}
+	if _, err = store.Get(ctx, "missing-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Fetched non-existing key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if _, err = store.Get(ctx, "-timelock-my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Fetched reserved entry: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if n := mock.fetches.Load(); n != 2 {
+		t.Fatalf("Invalid number of upstream fetches: got '%d' - want '%d'", n, 2)
+	}
+
+	if names, _, err := store.List(ctx, "", -1); err != nil || !slices.Equal(names, []string{"my-key", "other-key"}) {
+		t.Fatalf("Failed to list keys: got '%v': %v", names, err)
+	}
+	if names, next, err := store.List(ctx, "", 1); err != nil || !slices.Equal(names, []string{"my-key"}) || next != "other-key" {
+		t.Fatalf("Failed to list keys: got '%v' and '%s': %v", names, next, err)
+	}
+
+	if err = store.Create(ctx, "new-key", []byte("value")); err == nil {
+		t.Fatal("Created key at read-only key store")
+	}
+	if err = store.Delete(ctx, "my-key"); err == nil {
+		t.Fatal("Deleted key at read-only key store")
+	}
+}
+
+func TestStoreFailover(t *testing.T) {
+	mock := &mockKES{keys: map[string]string{"my-key": "my-key-value"}}
+	srv := httptest.NewTLSServer(mock)
+	defer srv.Close()
+
+	down := httptest.NewTLSServer(mock)
+	down.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, newConfig(t, srv, down.URL, srv.URL))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, []byte("my-key-value")) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, "my-key-value", err)
+	}
+
+	srv.Close()
+	if _, err = store.Get(ctx, "my-key"); err == nil {
+		t.Fatal("Fetched key from unreachable upstream")
+	} else if _, ok := keystore.IsUnreachable(err); !ok {
+		t.Fatalf("Invalid error: got '%v' - want unreachable error", err)
+	}
+}
+
+func TestStoreFailoverServerError(t *testing.T) {
+	mock := &mockKES{keys: map[string]string{"my-key": "my-key-value"}}
+	srv := httptest.NewTLSServer(mock)
+	defer srv.Close()
+
+	failing := &mockKES{status: http.StatusServiceUnavailable}
+	failingSrv := httptest.NewTLSServer(failing)
+	defer failingSrv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, newConfig(t, srv, failingSrv.URL, srv.URL))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, []byte("my-key-value")) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, "my-key-value", err)
+	}
+	if n := failing.fetches.Load(); n != 1 {
+		t.Fatalf("Invalid number of upstream fetches: got '%d' - want '%d'", n, 1)
+	}
+
+	// Client errors are not retried at the next endpoint.
+	failing.status = http.StatusForbidden
+	if _, err = store.Get(ctx, "my-key"); err == nil {
+		t.Fatal("Fetched key despite client error")
+	}
+	if n := mock.fetches.Load(); n != 1 {
+		t.Fatalf("Invalid number of upstream fetches: got '%d' - want '%d'", n, 1)
+	}
+
+	// If all upstream servers fail, the last error is returned.
+	failing.status = http.StatusInternalServerError
+	store, err = Connect(ctx, newConfig(t, failingSrv, failingSrv.URL, failingSrv.URL))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if _, err = store.Get(ctx, "my-key"); err == nil {
+		t.Fatal("Fetched key from failing upstream")
+	} else if _, ok := keystore.IsUnreachable(err); ok {
+		t.Fatalf("Invalid error: got '%v' - want server error", err)
+	}
+}
+
+func TestConnectConfig(t *testing.T) {
+	ctx := context.Background()
+	for i, config := range []*Config{
+		{TLS: &tls.Config{Certificates: []tls.Certificate{{}}}},             // 0: no endpoint
+		{Endpoints: []string{"https://127.0.0.1:7373"}},                     // 1: no TLS config
+		{Endpoints: []string{"https://127.0.0.1:7373"}, TLS: &tls.Config{}}, // 2: no client certificate
+	} {
+		if _, err := Connect(ctx, config); err == nil {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+	}
+}
+
+func newConfig(t *testing.T, srv *httptest.Server, endpoints ...string) *Config {
+	key, err := kesdk.GenerateAPIKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate API key: %v", err)
+	}
+	cert, err := kesdk.GenerateCertificate(key)
+	if err != nil {
+		t.Fatalf("Failed to generate client certificate: %v", err)
+	}
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(srv.Certificate())
+
+	return &Config{
+		Endpoints: endpoints,
+		TLS: &tls.Config{
+			RootCAs:      rootCAs,
+			Certificates: []tls.Certificate{cert},
+		},
+	}
+}
+
+// mockKES is a minimal upstream KES server implementing
+// the readiness, cache key and key listing APIs. If status
+// is set, it fails all key fetches with this status code.
+type mockKES struct {
+	keys    map[string]string
+	status  int
+	fetches atomic.Int64
+}
+
+func (m *mockKES) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == api.PathReady:
+		w.WriteHeader(http.StatusOK)
+	case strings.HasPrefix(r.URL.Path, api.PathCacheKey):
+		m.fetches.Add(1)
+		if m.status != 0 {
+			http.Error(w, http.StatusText(m.status), m.status)
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, api.PathCacheKey)
+		key, ok := m.keys[name]
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "key does not exist"})
+			return
+		}
+		json.NewEncoder(w).Encode(api.SyncKey{Name: name, Key: key})
+	case strings.HasPrefix(r.URL.Path, strings.TrimSuffix(api.PathKeyList, "/")):
+		names := make([]string, 0, len(m.keys))
+		for name := range m.keys {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"names": names})
+	default:
+		http.Error(w, "unsupported operation", http.StatusNotFound)
+	}
+}
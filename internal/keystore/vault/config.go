@@ -200,6 +200,22 @@ type Config struct {
 	// engine. It adds an additional layer of encryption.
 	Transit *Transit
 
+	// DeleteVersionAfter is the duration after which Vault
+	// deletes K/V v2 entries created by KES. It is set as
+	// "delete_version_after" on the entry's metadata. If zero,
+	// the engine's setting applies.
+	//
+	// Ref: https://developer.hashicorp.com/vault/api-docs/secret/kv/kv-v2#create-update-metadata
+	DeleteVersionAfter time.Duration
+
+	// PinnedVersion is the version of K/V v2 entries that
+	// is read, even if an entry has been overwritten.
+	//
+	// If zero, the latest version is read. Since KES creates
+	// each entry exactly once, reading fails if the latest
+	// version is not the first one.
+	PinnedVersion int
+
 	// StatusPingAfter is the duration after which
 	// the KeyStore will check the status of the Vault
 	// server. Particularly, this status information
@@ -234,17 +250,19 @@ func (c *Config) Clone() *Config {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 	return &Config{
-		Endpoint:        c.Endpoint,
-		Engine:          c.Engine,
-		APIVersion:      c.APIVersion,
-		Namespace:       c.Namespace,
-		Prefix:          c.Prefix,
-		AppRole:         c.AppRole.Clone(),
-		K8S:             c.K8S.Clone(),
-		Transit:         c.Transit.Clone(),
-		StatusPingAfter: c.StatusPingAfter,
-		PrivateKey:      c.PrivateKey,
-		Certificate:     c.Certificate,
-		CAPath:          c.CAPath,
+		Endpoint:           c.Endpoint,
+		Engine:             c.Engine,
+		APIVersion:         c.APIVersion,
+		Namespace:          c.Namespace,
+		Prefix:             c.Prefix,
+		AppRole:            c.AppRole.Clone(),
+		K8S:                c.K8S.Clone(),
+		Transit:            c.Transit.Clone(),
+		DeleteVersionAfter: c.DeleteVersionAfter,
+		PinnedVersion:      c.PinnedVersion,
+		StatusPingAfter:    c.StatusPingAfter,
+		PrivateKey:         c.PrivateKey,
+		Certificate:        c.Certificate,
+		CAPath:             c.CAPath,
 	}
 }
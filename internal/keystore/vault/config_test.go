@@ -39,9 +39,11 @@ var cloneConfigTests = []*Config{
 			Engine:  "transit",
 			KeyName: "my-key",
 		},
-		StatusPingAfter: 15 * time.Second,
-		PrivateKey:      "/tmp/kes/vault.key",
-		Certificate:     "/tmp/kes/vault.crt",
-		CAPath:          "/tmp/kes/vautl.ca",
+		DeleteVersionAfter: 720 * time.Hour,
+		PinnedVersion:      1,
+		StatusPingAfter:    15 * time.Second,
+		PrivateKey:         "/tmp/kes/vault.key",
+		Certificate:        "/tmp/kes/vault.crt",
+		CAPath:             "/tmp/kes/vautl.ca",
 	},
 }
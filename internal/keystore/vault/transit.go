@@ -0,0 +1,366 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package vault
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// transitHeader is the prefix of all entries wrapped by a transit key.
+var transitHeader = []byte("kes\x00vaulttransit\x01")
+
+// TransitStore is a key store that encrypts all entries with data
+// encryption keys wrapped by a Vault transit key before storing
+// them at another key store.
+//
+// Vault does not store any entry. Instead, it acts as root of
+// trust: entries cannot be decrypted without the transit key.
+// Once the transit key is rotated, new entries are wrapped with
+// the latest key version while existing entries remain readable
+// as long as Vault permits decryption with their key version.
+// Swapping an entry with its current value rewraps its data
+// encryption key with the latest key version.
+type TransitStore struct {
+	client *client
+	config *Config
+	store  kes.KeyStore
+	stop   context.CancelFunc
+}
+
+// ConnectTransit connects to a Hashicorp Vault server and returns
+// a new TransitStore that wraps all entries with the transit key
+// of the config before storing them at the given key store.
+//
+// The config's K/V engine options are ignored.
+func ConnectTransit(ctx context.Context, c *Config, store kes.KeyStore) (*TransitStore, error) {
+	c = c.Clone()
+	if c.Transit == nil || c.Transit.KeyName == "" {
+		return nil, errors.New("vault: transit key name is empty")
+	}
+	if c.Transit.Engine == "" {
+		c.Transit.Engine = EngineTransit
+	}
+	if store == nil {
+		return nil, errors.New("vault: no key store specified")
+	}
+
+	client, stop, err := connect(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newTransitStore(ctx, client, c, store)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	s.stop = stop
+	return s, nil
+}
+
+// newTransitStore returns a new TransitStore after checking
+// that the transit key can be used for wrapping keys.
+func newTransitStore(ctx context.Context, client *client, c *Config, store kes.KeyStore) (*TransitStore, error) {
+	s := &TransitStore{
+		client: client,
+		config: c,
+		store:  store,
+		stop:   func() {},
+	}
+	key, err := s.readKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"supports_encryption", "supports_decryption"} {
+		if ok, _ := key[op].(bool); !ok {
+			return nil, fmt.Errorf("vault: invalid transit key '%s': key does not support %s", c.Transit.KeyName, strings.TrimPrefix(op, "supports_"))
+		}
+	}
+	return s, nil
+}
+
+func (s *TransitStore) String() string {
+	return "Hashicorp Vault Transit: " + s.config.Endpoint + " Key=" + s.config.Transit.KeyName + " " + fmt.Sprint(s.store)
+}
+
+// Status returns the current state of the underlying key store.
+// It returns an error if the transit key is not accessible.
+func (s *TransitStore) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	if s.client.Sealed() {
+		return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: errSealed}
+	}
+	if _, err := s.readKey(ctx); err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return kes.KeyStoreState{}, err
+		}
+		return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+	}
+	return s.store.Status(ctx)
+}
+
+// Create encrypts the value and creates a new entry at the
+// underlying key store if and only if no entry with the
+// given name exists.
+//
+// The value is encrypted with a random data encryption key
+// that is wrapped with the latest version of the transit key.
+func (s *TransitStore) Create(ctx context.Context, name string, value []byte) error {
+	entry, err := s.wrap(ctx, name, value)
+	if err != nil {
+		return err
+	}
+	return s.store.Create(ctx, name, entry)
+}
+
+// Delete removes the entry from the underlying key store.
+func (s *TransitStore) Delete(ctx context.Context, name string) error {
+	return s.store.Delete(ctx, name)
+}
+
+// Get returns the decrypted value of the entry. The data
+// encryption key is unwrapped by Vault with the transit
+// key version that wrapped it.
+func (s *TransitStore) Get(ctx context.Context, name string) ([]byte, error) {
+	entry, err := s.store.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, ciphertext, err := parseTransitEntry(name, entry)
+	if err != nil {
+		return nil, err
+	}
+	return s.unwrap(ctx, name, wrappedKey, ciphertext)
+}
+
+// List returns the names of all entries of the underlying
+// key store that start with the prefix.
+func (s *TransitStore) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	return s.store.List(ctx, prefix, n)
+}
+
+// CanSwap reports whether the underlying key store
+// supports conditional writes.
+func (s *TransitStore) CanSwap() bool {
+	store, ok := s.store.(kes.ConditionalKeyStore)
+	return ok && store.CanSwap()
+}
+
+// Swap replaces the value of the entry if its decrypted value
+// is equal to old.
+//
+// If value is equal to old, Swap rewraps the data encryption key
+// of the entry with the latest version of the transit key, using
+// Vault's rewrap API, and keeps the encrypted value. Hence, once
+// the transit key has been rotated, swapping all entries with
+// their current values, for example by the rewrap job, ensures
+// that older key versions are no longer needed.
+func (s *TransitStore) Swap(ctx context.Context, name string, old, value []byte) error {
+	store, ok := s.store.(kes.ConditionalKeyStore)
+	if !ok {
+		return errors.New("vault: key store does not support conditional writes")
+	}
+
+	entry, err := s.store.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	wrappedKey, ciphertext, err := parseTransitEntry(name, entry)
+	if err != nil {
+		return err
+	}
+	plaintext, err := s.unwrap(ctx, name, wrappedKey, ciphertext)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(plaintext, old) {
+		return kesdk.ErrKeyExists
+	}
+
+	var swapped []byte
+	if bytes.Equal(old, value) {
+		rewrapped, err := s.transit(ctx, "rewrap", "ciphertext", wrappedKey)
+		if err != nil {
+			return fmt.Errorf("vault: failed to rewrap '%s': %v", name, err)
+		}
+		if !isTransitCiphertext(rewrapped) {
+			return fmt.Errorf("vault: failed to rewrap '%s': invalid vault response", name)
+		}
+		if transitKeyVersion(rewrapped) == transitKeyVersion(wrappedKey) {
+			return nil // Already wrapped with the latest key version
+		}
+		swapped = transitEntry(rewrapped, ciphertext)
+	} else if swapped, err = s.wrap(ctx, name, value); err != nil {
+		return err
+	}
+	return store.Swap(ctx, name, entry, swapped)
+}
+
+// Close stops the Vault authentication renewal and
+// closes the underlying key store.
+func (s *TransitStore) Close() error {
+	s.stop()
+	return s.store.Close()
+}
+
+// wrap encrypts the value with a random data encryption key
+// and returns the entry containing the value and the data
+// encryption key wrapped with the transit key.
+func (s *TransitStore) wrap(ctx context.Context, name string, value []byte) ([]byte, error) {
+	if s.client.Sealed() {
+		return nil, errSealed
+	}
+
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	key, err := crypto.NewSecretKey(crypto.AES256, dek)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := key.Encrypt(value, transitAssociatedData(name))
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := s.transit(ctx, "encrypt", "plaintext", base64.StdEncoding.EncodeToString(dek))
+	if err != nil {
+		return nil, fmt.Errorf("vault: failed to wrap '%s': %v", name, err)
+	}
+	if !isTransitCiphertext(wrappedKey) {
+		return nil, fmt.Errorf("vault: failed to wrap '%s': invalid vault response", name)
+	}
+	return transitEntry(wrappedKey, ciphertext), nil
+}
+
+// unwrap unwraps the data encryption key with the transit key
+// and decrypts the ciphertext.
+func (s *TransitStore) unwrap(ctx context.Context, name, wrappedKey string, ciphertext []byte) ([]byte, error) {
+	if s.client.Sealed() {
+		return nil, errSealed
+	}
+
+	plaintext, err := s.transit(ctx, "decrypt", "ciphertext", wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("vault: failed to unwrap '%s': %v", name, err)
+	}
+	dek, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("vault: failed to unwrap '%s': invalid vault response", name)
+	}
+	key, err := crypto.NewSecretKey(crypto.AES256, dek)
+	if err != nil {
+		return nil, fmt.Errorf("vault: failed to unwrap '%s': %v", name, err)
+	}
+	value, err := key.Decrypt(bytes.Clone(ciphertext), transitAssociatedData(name))
+	if err != nil {
+		return nil, fmt.Errorf("vault: failed to decrypt '%s': %v", name, err)
+	}
+	return value, nil
+}
+
+// transit sends the input to the given transit API of the
+// transit key, like "encrypt", and returns the output.
+//
+// Ref: https://developer.hashicorp.com/vault/api-docs/secret/transit
+func (s *TransitStore) transit(ctx context.Context, api, field, input string) (string, error) {
+	location := path.Join(s.config.Transit.Engine, api, s.config.Transit.KeyName)
+	secret, err := s.client.Logical().WriteWithContext(ctx, location, map[string]any{field: input})
+	if err != nil {
+		return "", err
+	}
+	if secret == nil {
+		return "", errors.New("empty vault response")
+	}
+
+	output := "ciphertext"
+	if api == "decrypt" {
+		output = "plaintext"
+	}
+	v, ok := secret.Data[output].(string)
+	if !ok {
+		return "", fmt.Errorf("no %s in vault response", output)
+	}
+	return v, nil
+}
+
+// readKey returns the properties of the transit key.
+func (s *TransitStore) readKey(ctx context.Context) (map[string]any, error) {
+	location := path.Join(s.config.Transit.Engine, "keys", s.config.Transit.KeyName)
+	secret, err := s.client.Logical().ReadWithContext(ctx, location)
+	if err != nil {
+		return nil, fmt.Errorf("vault: failed to read transit key '%s': %v", s.config.Transit.KeyName, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("vault: transit key '%s' does not exist", s.config.Transit.KeyName)
+	}
+	return secret.Data, nil
+}
+
+// transitEntry returns the entry containing the wrapped
+// data encryption key and the encrypted value.
+func transitEntry(wrappedKey string, ciphertext []byte) []byte {
+	entry := make([]byte, 0, len(transitHeader)+2+len(wrappedKey)+len(ciphertext))
+	entry = append(entry, transitHeader...)
+	entry = binary.BigEndian.AppendUint16(entry, uint16(len(wrappedKey)))
+	entry = append(entry, wrappedKey...)
+	return append(entry, ciphertext...)
+}
+
+// parseTransitEntry parses an entry created by transitEntry.
+func parseTransitEntry(name string, entry []byte) (wrappedKey string, ciphertext []byte, err error) {
+	b, ok := bytes.CutPrefix(entry, transitHeader)
+	if !ok || len(b) < 2 {
+		return "", nil, fmt.Errorf("vault: entry '%s' is not wrapped", name)
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, fmt.Errorf("vault: entry '%s' is not wrapped", name)
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+// transitAssociatedData binds an encrypted value to its entry
+// name such that entries cannot be swapped.
+func transitAssociatedData(name string) []byte {
+	return append(bytes.Clone(transitHeader), "name="+name...)
+}
+
+// isTransitCiphertext reports whether s is a transit ciphertext,
+// like "vault:v1:...", of any key version.
+func isTransitCiphertext(s string) bool {
+	return transitKeyVersion(s) > 0
+}
+
+// transitKeyVersion returns the key version of a transit
+// ciphertext or 0 if s is not a transit ciphertext.
+func transitKeyVersion(s string) int {
+	s, ok := strings.CutPrefix(s, "vault:v")
+	if !ok {
+		return 0
+	}
+	version, _, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0
+	}
+	v, err := strconv.Atoi(version)
+	if err != nil || v <= 0 {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,204 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/minio/kes"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestTransitStore(t *testing.T) {
+	ctx := context.Background()
+	transit := &transitServer{key: "kes-root", version: 1}
+	srv := httptest.NewServer(transit)
+	defer srv.Close()
+
+	mem := &kes.MemKeyStore{}
+	config := &Config{Endpoint: srv.URL, Transit: &Transit{Engine: EngineTransit, KeyName: "kes-root"}}
+	store, err := newTransitStore(ctx, newTestClient(t, srv.URL), config, mem)
+	if err != nil {
+		t.Fatalf("Failed to create transit store: %v", err)
+	}
+	if _, err = newTransitStore(ctx, newTestClient(t, srv.URL), &Config{Transit: &Transit{Engine: EngineTransit, KeyName: "unknown"}}, mem); err == nil {
+		t.Fatal("Created transit store with non-existing transit key")
+	}
+
+	value := []byte("my-secret-value")
+	if err = store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", value); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Created key twice: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	entry, err := mem.Get(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to read wrapped key: %v", err)
+	}
+	if !bytes.HasPrefix(entry, transitHeader) || bytes.Contains(entry, value) {
+		t.Fatalf("Key has not been wrapped: '%s'", entry)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	// An entry cannot be read under another name.
+	if err = mem.Create(ctx, "copied-key", entry); err != nil {
+		t.Fatalf("Failed to copy key: %v", err)
+	}
+	if _, err = store.Get(ctx, "copied-key"); err == nil {
+		t.Fatal("Read key copied to another name")
+	}
+
+	// After rotating the transit key, new entries are wrapped with
+	// the latest key version while existing ones remain readable.
+	transit.Rotate()
+	if err = store.Create(ctx, "new-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if version := wrappedKeyVersion(t, mem, "new-key"); version != 2 {
+		t.Fatalf("Invalid key version: got '%d' - want '%d'", version, 2)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key after rotation: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	// Swapping an entry with its value rewraps it with the latest
+	// key version. Hence, older versions are no longer needed.
+	if err = store.Swap(ctx, "my-key", value, value); err != nil {
+		t.Fatalf("Failed to rewrap key: %v", err)
+	}
+	if version := wrappedKeyVersion(t, mem, "my-key"); version != 2 {
+		t.Fatalf("Invalid key version: got '%d' - want '%d'", version, 2)
+	}
+	if err = store.Swap(ctx, "my-key", value, value); err != nil {
+		t.Fatalf("Failed to rewrap key twice: %v", err)
+	}
+	transit.minDecryption = 2
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get rewrapped key: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	if err = store.Swap(ctx, "my-key", []byte("other-value"), value); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Swapped key with other value: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if err = store.Swap(ctx, "my-key", value, []byte("new-value")); err != nil {
+		t.Fatalf("Failed to swap key: %v", err)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || string(v) != "new-value" {
+		t.Fatalf("Failed to get swapped key: got '%s' - want '%s': %v", v, "new-value", err)
+	}
+}
+
+func TestTransitKeyVersion(t *testing.T) {
+	for i, test := range []struct {
+		Ciphertext string
+		Version    int
+	}{
+		{Ciphertext: "vault:v1:AAAA", Version: 1},    // 0
+		{Ciphertext: "vault:v12:AAAA", Version: 12},  // 1
+		{Ciphertext: "vault:v0:AAAA", Version: 0},    // 2
+		{Ciphertext: "vault:vX:AAAA", Version: 0},    // 3
+		{Ciphertext: "vault:v1", Version: 0},         // 4
+		{Ciphertext: `{"bytes":"AAAA"}`, Version: 0}, // 5
+	} {
+		if v := transitKeyVersion(test.Ciphertext); v != test.Version {
+			t.Fatalf("Test %d: version mismatch: got '%d' - want '%d'", i, v, test.Version)
+		}
+	}
+}
+
+func wrappedKeyVersion(t *testing.T, store kes.KeyStore, name string) int {
+	entry, err := store.Get(context.Background(), name)
+	if err != nil {
+		t.Fatalf("Failed to read wrapped key '%s': %v", name, err)
+	}
+	wrappedKey, _, err := parseTransitEntry(name, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return transitKeyVersion(wrappedKey)
+}
+
+// transitServer is a minimal transit secret engine mounted at
+// EngineTransit with a single key. Its ciphertexts contain the
+// key version and the plaintext.
+type transitServer struct {
+	lock          sync.Mutex
+	key           string
+	version       int // Latest key version
+	minDecryption int // Min. key version for decryption
+}
+
+// Rotate creates a new key version.
+func (s *transitServer) Rotate() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.version++
+}
+
+func (s *transitServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	reply := func(status int, data any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}
+	fail := func(status int, msg string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{"errors": []string{msg}})
+	}
+
+	op, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/transit/"), "/")
+	if name != s.key {
+		fail(http.StatusNotFound, "key not found")
+		return
+	}
+	if op == "keys" && r.Method == http.MethodGet {
+		reply(http.StatusOK, map[string]any{"latest_version": s.version, "supports_encryption": true, "supports_decryption": true})
+		return
+	}
+
+	var body struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		fail(http.StatusBadRequest, err.Error())
+		return
+	}
+	if op == "encrypt" {
+		reply(http.StatusOK, map[string]any{"ciphertext": "vault:v" + strconv.Itoa(s.version) + ":" + body.Plaintext})
+		return
+	}
+
+	version := transitKeyVersion(body.Ciphertext)
+	if version < max(s.minDecryption, 1) || version > s.version {
+		fail(http.StatusBadRequest, "invalid ciphertext: key version not allowed")
+		return
+	}
+	plaintext := body.Ciphertext[strings.LastIndexByte(body.Ciphertext, ':')+1:]
+	switch op {
+	case "decrypt":
+		reply(http.StatusOK, map[string]any{"plaintext": plaintext})
+	case "rewrap":
+		reply(http.StatusOK, map[string]any{"ciphertext": "vault:v" + strconv.Itoa(s.version) + ":" + plaintext})
+	default:
+		fail(http.StatusNotFound, "unsupported path")
+	}
+}
@@ -20,7 +20,6 @@ import (
 	"net/http"
 	"os"
 	"path"
-	"strings"
 	"time"
 
 	"aead.dev/mem"
@@ -48,6 +47,45 @@ func Connect(ctx context.Context, c *Config) (*Store, error) {
 	if c.APIVersion == "" {
 		c.APIVersion = APIv1
 	}
+	if c.Transit != nil {
+		if c.Transit.Engine == "" {
+			c.Transit.Engine = EngineTransit
+		}
+	}
+
+	if c.APIVersion != APIv1 && c.APIVersion != APIv2 {
+		return nil, fmt.Errorf("vault: invalid engine API version '%s'", c.APIVersion)
+	}
+	if c.Transit != nil {
+		if c.Transit.KeyName == "" {
+			return nil, errors.New("vault: transit key name is empty")
+		}
+	}
+	if c.DeleteVersionAfter < 0 {
+		return nil, fmt.Errorf("vault: invalid delete_version_after '%v'", c.DeleteVersionAfter)
+	}
+	if c.PinnedVersion < 0 {
+		return nil, fmt.Errorf("vault: invalid pinned version '%d'", c.PinnedVersion)
+	}
+	if c.APIVersion != APIv2 && (c.DeleteVersionAfter > 0 || c.PinnedVersion > 0) {
+		return nil, errors.New("vault: delete_version_after and pinned versions require the K/V v2 engine")
+	}
+
+	client, stop, err := connect(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{
+		config: c,
+		client: client,
+		stop:   stop,
+	}, nil
+}
+
+// connect returns a new client that is authenticated to the Vault
+// server. It checks the Vault status and renews the authentication
+// token in the background until the returned CancelFunc is called.
+func connect(ctx context.Context, c *Config) (*client, context.CancelFunc, error) {
 	if c.AppRole != nil {
 		if c.AppRole.Engine == "" {
 			c.AppRole.Engine = EngineAppRole
@@ -58,32 +96,19 @@ func Connect(ctx context.Context, c *Config) (*Store, error) {
 			c.K8S.Engine = EngineKubernetes
 		}
 	}
-	if c.Transit != nil {
-		if c.Transit.Engine == "" {
-			c.Transit.Engine = EngineTransit
-		}
-	}
 	if c.StatusPingAfter == 0 {
 		c.StatusPingAfter = 15 * time.Second
 	}
 
 	if c.Endpoint == "" {
-		return nil, fmt.Errorf("vault: endpoint is empty")
-	}
-	if c.APIVersion != APIv1 && c.APIVersion != APIv2 {
-		return nil, fmt.Errorf("vault: invalid engine API version '%s'", c.APIVersion)
+		return nil, nil, fmt.Errorf("vault: endpoint is empty")
 	}
 	if c.AppRole != nil && c.K8S != nil {
 		if (c.AppRole.ID == "" || c.AppRole.Secret == "") && (c.K8S.JWT == "" || c.K8S.Role == "") {
-			return nil, errors.New("vault: no authentication method specified")
+			return nil, nil, errors.New("vault: no authentication method specified")
 		}
 		if (c.AppRole.ID != "" || c.AppRole.Secret != "") && (c.K8S.JWT != "" || c.K8S.Role != "") {
-			return nil, errors.New("vault: more than one authentication method specified: approle and kubernetes configuration is present")
-		}
-	}
-	if c.Transit != nil {
-		if c.Transit.KeyName == "" {
-			return nil, errors.New("vault: transit key name is empty")
+			return nil, nil, errors.New("vault: more than one authentication method specified: approle and kubernetes configuration is present")
 		}
 	}
 
@@ -94,7 +119,7 @@ func Connect(ctx context.Context, c *Config) (*Store, error) {
 	if c.CAPath != "" {
 		stat, err := os.Stat(c.CAPath)
 		if err != nil {
-			return nil, fmt.Errorf("vault: failed to open '%s': %v", c.CAPath, err)
+			return nil, nil, fmt.Errorf("vault: failed to open '%s': %v", c.CAPath, err)
 		}
 		if stat.IsDir() {
 			tlsConfig.CAPath = c.CAPath
@@ -114,7 +139,7 @@ func Connect(ctx context.Context, c *Config) (*Store, error) {
 	}
 	vaultClient, err := vaultapi.NewClient(config)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	client := &client{
 		Client: vaultClient,
@@ -137,22 +162,18 @@ func Connect(ctx context.Context, c *Config) (*Store, error) {
 
 	auth, err := authenticate(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	token, err := auth.TokenID()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	client.SetToken(token)
 
 	ctx, cancel := context.WithCancel(ctx)
 	go client.CheckStatus(ctx, c.StatusPingAfter)
 	go client.RenewToken(ctx, authenticate, auth)
-	return &Store{
-		config: c,
-		client: client,
-		stop:   cancel,
-	}, nil
+	return client, cancel, nil
 }
 
 var errSealed = errors.New("vault: key store is sealed")
@@ -212,48 +233,50 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 		location = path.Join(s.config.Engine, s.config.Prefix, name) // /<engine>/<location>/<name>
 	}
 
-	// Vault will return nil for the secret as well as a nil-error
-	// if the specified entry does not exist.
-	// More specifically the Vault server + client behaves as following:
-	//  - If the entry does not exist (b/c it never existed) the server
-	//    returns 404 and the client returns the tuple (nil, nil).
-	//  - If the entry does not exist (b/c it existed before but has
-	//    been deleted) the server returns 404 but response with a
-	//    "secret". The client will still parse the response body (even
-	//    though 404) and return (nil, nil) if the body is empty or
-	//    the secret contains no data (and no "warnings" or "errors")
-	//
-	// Therefore, we check whether the client returns a nil error
-	// and a non-nil "secret". In this case, the secret key either
-	// already exists or the K/V backend does not understand the
-	// request (K/V v1 vs. K/V v2) and returns a "secret" without
-	// a key entry but an API warning.
-	//
-	// But when the client returns an error it does not mean that
-	// the entry does not exist but that some other error (e.g.
-	// network error) occurred.
-	switch secret, err := s.client.Logical().ReadWithContext(ctx, location); {
-	case err == nil && secret != nil && s.config.APIVersion != APIv2:
-		if _, ok := secret.Data[name]; !ok {
-			return fmt.Errorf("vault: entry exist but failed to read '%s': invalid K/V v1 format", location)
-		}
-		return kesdk.ErrKeyExists
-	case err == nil && secret != nil && s.config.APIVersion == APIv2 && len(secret.Data) > 0:
-		data := secret.Data
-		v, ok := data["data"]
-		if !ok || v == nil {
-			return fmt.Errorf("vault: entry exists but failed to read '%s': invalid K/V v2 format: missing 'data' entry", location)
-		}
-		data, ok = v.(map[string]interface{})
-		if !ok || data == nil {
-			return fmt.Errorf("vault: entry exists but failed to read '%s': invalid K/V v2 format: invalid 'data' entry", location)
-		}
-		if _, ok := data[name]; !ok {
-			return fmt.Errorf("vault: failed to read '%s': entry exists but no secret key is present", location)
-		}
-		return kesdk.ErrKeyExists
-	case err != nil:
-		return fmt.Errorf("vault: failed to create '%s': %v", location, err)
+	if s.config.APIVersion == APIv2 {
+		// K/V v2 keeps all versions of an entry, including deleted
+		// ones, until its metadata is deleted. We must not create
+		// a new version if any version exists. Otherwise, undeleting
+		// an older version would resurrect a deleted key or replace
+		// the key created by KES.
+		//
+		// See: https://developer.hashicorp.com/vault/api-docs/secret/kv/kv-v2#read-secret-metadata
+		switch metadata, err := s.client.KVv2(s.config.Engine).GetMetadata(ctx, path.Join(s.config.Prefix, name)); {
+		case err == nil && (metadata.CurrentVersion > 0 || len(metadata.Versions) > 0):
+			return kesdk.ErrKeyExists
+		case err != nil && !errors.Is(err, vaultapi.ErrSecretNotFound):
+			return fmt.Errorf("vault: failed to create '%s': %v", location, err)
+		}
+	} else {
+		// Vault will return nil for the secret as well as a nil-error
+		// if the specified entry does not exist.
+		// More specifically the Vault server + client behaves as following:
+		//  - If the entry does not exist (b/c it never existed) the server
+		//    returns 404 and the client returns the tuple (nil, nil).
+		//  - If the entry does not exist (b/c it existed before but has
+		//    been deleted) the server returns 404 but response with a
+		//    "secret". The client will still parse the response body (even
+		//    though 404) and return (nil, nil) if the body is empty or
+		//    the secret contains no data (and no "warnings" or "errors")
+		//
+		// Therefore, we check whether the client returns a nil error
+		// and a non-nil "secret". In this case, the secret key either
+		// already exists or the K/V backend does not understand the
+		// request (K/V v1 vs. K/V v2) and returns a "secret" without
+		// a key entry but an API warning.
+		//
+		// But when the client returns an error it does not mean that
+		// the entry does not exist but that some other error (e.g.
+		// network error) occurred.
+		switch secret, err := s.client.Logical().ReadWithContext(ctx, location); {
+		case err == nil && secret != nil:
+			if _, ok := secret.Data[name]; !ok {
+				return fmt.Errorf("vault: entry exist but failed to read '%s': invalid K/V v1 format", location)
+			}
+			return kesdk.ErrKeyExists
+		case err != nil:
+			return fmt.Errorf("vault: failed to create '%s': %v", location, err)
+		}
 	}
 
 	if s.config.Transit != nil {
@@ -288,7 +311,7 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 			return fmt.Errorf("vault: failed to create '%s': failed to encrypt key: no ciphertext in vault response", location)
 		}
 		v, ok := ciphertext.(string)
-		if !ok || !strings.HasPrefix(v, "vault:v1:") {
+		if !ok || !isTransitCiphertext(v) {
 			return fmt.Errorf("vault: failed to create '%s': failed to encrypt key: invalid vault response", location)
 		}
 		value = []byte(v)
@@ -316,6 +339,20 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 		}
 	}
 
+	if s.config.APIVersion == APIv2 && s.config.DeleteVersionAfter > 0 {
+		// We set the metadata before creating the entry such that the
+		// entry never exists without it. Metadata without any version
+		// does not prevent creating the entry with CAS 0.
+		//
+		// See: https://developer.hashicorp.com/vault/api-docs/secret/kv/kv-v2#create-update-metadata
+		metadata := path.Join(s.config.Engine, "metadata", s.config.Prefix, name)
+		if _, err := s.client.Logical().WriteWithContext(ctx, metadata, map[string]interface{}{
+			"delete_version_after": s.config.DeleteVersionAfter.String(),
+		}); err != nil {
+			return fmt.Errorf("vault: failed to create '%s': failed to write metadata: %v", location, err)
+		}
+	}
+
 	// The Vault SDK may not return an error even if it hasn't created
 	// an entry - e.g. in case of some network errors. Therefore, we
 	// implement the specific key creation logic ourself.
@@ -359,7 +396,17 @@ func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 		err      error
 	)
 	if s.config.APIVersion == APIv2 {
-		entry, err = s.client.KVv2(s.config.Engine).GetVersion(ctx, location, 1)
+		// KES creates each K/V v2 entry exactly once. Hence, any
+		// version but the first one has been written by someone
+		// else. Unless a version is pinned, we read the latest
+		// version and fail if the entry has been overwritten
+		// instead of silently ignoring it.
+		kv := s.client.KVv2(s.config.Engine)
+		if s.config.PinnedVersion > 0 {
+			entry, err = kv.GetVersion(ctx, location, s.config.PinnedVersion)
+		} else if entry, err = kv.Get(ctx, location); err == nil && entry.VersionMetadata != nil && entry.VersionMetadata.Version != 1 {
+			return nil, fmt.Errorf("vault: failed to read '%s': entry has been overwritten: latest version is %d", location, entry.VersionMetadata.Version)
+		}
 	} else {
 		entry, err = s.client.KVv1(s.config.Engine).Get(ctx, location)
 	}
@@ -371,6 +418,9 @@ func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 		}
 		return nil, fmt.Errorf("vault: failed to read '%s': %v", location, err)
 	}
+	if entry.Data == nil { // K/V v2 versions that have been deleted or destroyed have no data.
+		return nil, kesdk.ErrKeyNotFound
+	}
 
 	// Verify that we got a well-formed response from Vault
 	v, ok := entry.Data[name]
@@ -383,7 +433,7 @@ func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 	}
 
 	// Handle transit encrypted K/V entries
-	if strings.HasPrefix(value, "vault:v1:") {
+	if isTransitCiphertext(value) {
 		if s.config.Transit == nil {
 			return nil, fmt.Errorf("vault: failed to read '%s': key is encrypted with vault transit key", location)
 		}
@@ -0,0 +1,220 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestStoreKVv2(t *testing.T) {
+	ctx := context.Background()
+	kv := &kvServer{entries: map[string]*kvEntry{}}
+	srv := httptest.NewServer(kv)
+	defer srv.Close()
+
+	store := newTestStore(t, srv.URL, &Config{DeleteVersionAfter: 720 * time.Hour})
+	pinned := newTestStore(t, srv.URL, &Config{PinnedVersion: 1})
+
+	value := []byte("my-secret-value")
+	if err := store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := store.Create(ctx, "my-key", value); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Created key twice: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if d := kv.entries["my-key"].deleteVersionAfter; d != "720h0m0s" {
+		t.Fatalf("Invalid delete_version_after: got '%s' - want '%s'", d, "720h0m0s")
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	// An overwritten entry is not returned unless the
+	// first version is pinned.
+	kv.entries["my-key"].versions = append(kv.entries["my-key"].versions, map[string]any{"my-key": "overwritten"})
+	if _, err := store.Get(ctx, "my-key"); err == nil {
+		t.Fatal("Read overwritten key")
+	}
+	if v, err := pinned.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get pinned version: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	// A deleted version is not found but the entry cannot
+	// be created again until its metadata is deleted.
+	if err := store.Create(ctx, "deleted-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	kv.entries["deleted-key"].versions[0] = nil
+	if _, err := store.Get(ctx, "deleted-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Read deleted key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if err := store.Create(ctx, "deleted-key", value); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Created key with deleted version: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if err := store.Delete(ctx, "deleted-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if err := store.Create(ctx, "deleted-key", value); err != nil {
+		t.Fatalf("Failed to create deleted key: %v", err)
+	}
+}
+
+func newTestStore(t *testing.T, endpoint string, c *Config) *Store {
+	c.Endpoint = endpoint
+	c.Engine = EngineKV
+	c.APIVersion = APIv2
+	return &Store{
+		client: newTestClient(t, endpoint),
+		config: c,
+		stop:   func() {},
+	}
+}
+
+func newTestClient(t *testing.T, endpoint string) *client {
+	config := vaultapi.DefaultConfig()
+	config.Address = endpoint
+	vaultClient, err := vaultapi.NewClient(config)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	vaultClient.SetToken("kes-test-token")
+	return &client{Client: vaultClient}
+}
+
+// kvServer is a minimal K/V v2 secret engine mounted
+// at EngineKV. A deleted version has no data.
+type kvServer struct {
+	lock    sync.Mutex
+	entries map[string]*kvEntry
+}
+
+type kvEntry struct {
+	deleteVersionAfter string
+	versions           []map[string]any
+}
+
+func (s *kvServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	reply := func(status int, data any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}
+	fail := func(status int) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{"errors": []string{}})
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v1/kv/metadata/"):
+		name := strings.TrimPrefix(r.URL.Path, "/v1/kv/metadata/")
+		entry := s.entries[name]
+		switch r.Method {
+		case http.MethodGet:
+			if entry == nil {
+				fail(http.StatusNotFound)
+				return
+			}
+			versions := map[string]any{}
+			for i, data := range entry.versions {
+				versions[strconv.Itoa(i+1)] = map[string]any{"deletion_time": deletionTime(data), "destroyed": false}
+			}
+			reply(http.StatusOK, map[string]any{"current_version": len(entry.versions), "versions": versions})
+		case http.MethodPut, http.MethodPost:
+			var body struct {
+				DeleteVersionAfter string `json:"delete_version_after"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				fail(http.StatusBadRequest)
+				return
+			}
+			if entry == nil {
+				entry = &kvEntry{}
+				s.entries[name] = entry
+			}
+			entry.deleteVersionAfter = body.DeleteVersionAfter
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			delete(s.entries, name)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			fail(http.StatusMethodNotAllowed)
+		}
+	case strings.HasPrefix(r.URL.Path, "/v1/kv/data/"):
+		name := strings.TrimPrefix(r.URL.Path, "/v1/kv/data/")
+		entry := s.entries[name]
+		switch r.Method {
+		case http.MethodGet:
+			if entry == nil || len(entry.versions) == 0 {
+				fail(http.StatusNotFound)
+				return
+			}
+			version := len(entry.versions)
+			if v := r.URL.Query().Get("version"); v != "" {
+				version, _ = strconv.Atoi(v)
+			}
+			if version < 1 || version > len(entry.versions) {
+				fail(http.StatusNotFound)
+				return
+			}
+			data, status := entry.versions[version-1], http.StatusOK
+			if data == nil {
+				status = http.StatusNotFound
+			}
+			reply(status, map[string]any{
+				"data":     data,
+				"metadata": map[string]any{"version": version, "deletion_time": deletionTime(data), "destroyed": false},
+			})
+		case http.MethodPut, http.MethodPost:
+			var body struct {
+				Options struct {
+					CAS *int `json:"cas"`
+				} `json:"options"`
+				Data map[string]any `json:"data"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				fail(http.StatusBadRequest)
+				return
+			}
+			if entry == nil {
+				entry = &kvEntry{}
+				s.entries[name] = entry
+			}
+			if body.Options.CAS != nil && *body.Options.CAS != len(entry.versions) {
+				fail(http.StatusBadRequest)
+				return
+			}
+			entry.versions = append(entry.versions, body.Data)
+			reply(http.StatusOK, map[string]any{"version": len(entry.versions)})
+		default:
+			fail(http.StatusMethodNotAllowed)
+		}
+	default:
+		fail(http.StatusNotFound)
+	}
+}
+
+func deletionTime(data map[string]any) string {
+	if data == nil {
+		return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	}
+	return ""
+}
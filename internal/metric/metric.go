@@ -13,6 +13,7 @@ import (
 	"github.com/minio/kes/internal/api"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/expfmt"
 )
 
@@ -50,6 +51,42 @@ func New() *Metrics {
 			Name:      "request_active",
 			Help:      "Number of active requests that are not finished, yet.",
 		}),
+		requestShed: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kes",
+			Subsystem: "http",
+			Name:      "request_shed",
+			Help:      "Number of requests that have been rejected because the server was overloaded.",
+		}, []string{"priority"}),
+		keyStoreTimeout: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kes",
+			Subsystem: "keystore",
+			Name:      "timeout",
+			Help:      "Number of keystore operations that have been aborted because they exceeded their timeout.",
+		}, []string{"operation"}),
+		tlsHandshake: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kes",
+			Subsystem: "tls",
+			Name:      "handshake",
+			Help:      "Number of completed TLS handshakes. Resumed handshakes skip the certificate exchange.",
+		}, []string{"resumed"}),
+		anomalyAlerts: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kes",
+			Subsystem: "usage",
+			Name:      "anomaly_alerts",
+			Help:      "Number of alerts raised because an identity exceeded a usage threshold.",
+		}, []string{"feature"}),
+		authFailures: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kes",
+			Subsystem: "auth",
+			Name:      "failures",
+			Help:      "Number of requests rejected because of an unknown or invalid identity, or a banned source.",
+		}, []string{"reason"}),
+		authBans: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: "kes",
+			Subsystem: "auth",
+			Name:      "bans",
+			Help:      "Number of source IP addresses banned because they exceeded their authentication failure budget.",
+		}),
 		requestLatency: factory.NewHistogram(prometheus.HistogramOpts{
 			Namespace: "kes",
 			Subsystem: "http",
@@ -130,8 +167,15 @@ type Metrics struct {
 	requestFailed    *prometheus.CounterVec
 	requestErrored   *prometheus.CounterVec
 	requestActive    prometheus.Gauge
+	requestShed      *prometheus.CounterVec
 	requestLatency   prometheus.Histogram
 
+	keyStoreTimeout *prometheus.CounterVec
+	tlsHandshake    *prometheus.CounterVec
+	anomalyAlerts   *prometheus.CounterVec
+	authFailures    *prometheus.CounterVec
+	authBans        prometheus.Counter
+
 	errorLogEvents prometheus.Counter
 	auditLogEvents prometheus.Counter
 
@@ -149,6 +193,65 @@ type Metrics struct {
 // EncodeTo collects all outstanding metrics information
 // about the application and writes it to encoder.
 func (m *Metrics) EncodeTo(encoder expfmt.Encoder) error {
+	m.updateSystem()
+
+	metrics, err := m.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	for _, metric := range metrics {
+		if err := encoder.Encode(metric); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Value returns the sum of all series of the named metric whose
+// labels contain the given labels. For histograms and summaries,
+// it returns the sum of the number of observations.
+//
+// It reports whether the metric is a counter, i.e. a value that
+// only increases, and whether any series of the metric exists.
+func (m *Metrics) Value(name string, labels map[string]string) (value float64, counter, ok bool, err error) {
+	m.updateSystem()
+
+	metrics, err := m.gatherer.Gather()
+	if err != nil {
+		return 0, false, false, err
+	}
+	for _, family := range metrics {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if !hasLabels(metric, labels) {
+				continue
+			}
+			ok = true
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				value += metric.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				value += metric.GetGauge().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				value += float64(metric.GetHistogram().GetSampleCount())
+			case dto.MetricType_SUMMARY:
+				value += float64(metric.GetSummary().GetSampleCount())
+			default:
+				value += metric.GetUntyped().GetValue()
+			}
+		}
+		counter = family.GetType() != dto.MetricType_GAUGE && family.GetType() != dto.MetricType_UNTYPED
+		return value, counter, ok, nil
+	}
+	return 0, false, false, nil
+}
+
+// updateSystem updates the system metrics, like the up time
+// or the heap memory, that are not updated continuously.
+func (m *Metrics) updateSystem() {
 	var memStats runtime.MemStats
 	runtime.ReadMemStats(&memStats)
 
@@ -159,17 +262,23 @@ func (m *Metrics) EncodeTo(encoder expfmt.Encoder) error {
 	m.memHeapUsed.Set(float64(memStats.HeapAlloc))
 	m.memHeapObjects.Set(float64(memStats.HeapObjects))
 	m.memStackUsed.Set(float64(memStats.StackSys))
+}
 
-	metrics, err := m.gatherer.Gather()
-	if err != nil {
-		return err
-	}
-	for _, metric := range metrics {
-		if err := encoder.Encode(metric); err != nil {
-			return err
+// hasLabels reports whether the metric has all the given labels.
+func hasLabels(metric *dto.Metric, labels map[string]string) bool {
+	for name, value := range labels {
+		var found bool
+		for _, pair := range metric.GetLabel() {
+			if pair.GetName() == name && pair.GetValue() == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
 		}
 	}
-	return nil
+	return true
 }
 
 // Count returns a HandlerFunc that wraps h and counts the
@@ -196,6 +305,39 @@ func (m *Metrics) Count(h api.Handler) api.Handler {
 	})
 }
 
+// Shed increments the number of requests with the given
+// priority that have been rejected due to overload.
+func (m *Metrics) Shed(priority string) {
+	m.requestShed.WithLabelValues(priority).Inc()
+}
+
+// KeyStoreTimeout increments the number of keystore operations,
+// like "get" or "list", that exceeded their timeout.
+func (m *Metrics) KeyStoreTimeout(operation string) {
+	m.keyStoreTimeout.WithLabelValues(operation).Inc()
+}
+
+// TLSHandshake increments the number of completed TLS
+// handshakes, either full or resumed handshakes.
+func (m *Metrics) TLSHandshake(resumed bool) {
+	m.tlsHandshake.WithLabelValues(strconv.FormatBool(resumed)).Inc()
+}
+
+// AnomalyAlert increments the number of usage anomaly alerts
+// raised for the given usage feature.
+func (m *Metrics) AnomalyAlert(feature string) {
+	m.anomalyAlerts.WithLabelValues(feature).Inc()
+}
+
+// AuthFailure increments the number of requests rejected
+// for the given reason, like "unknown_identity".
+func (m *Metrics) AuthFailure(reason string) {
+	m.authFailures.WithLabelValues(reason).Inc()
+}
+
+// AuthBan increments the number of banned sources.
+func (m *Metrics) AuthBan() { m.authBans.Inc() }
+
 // Latency returns a HandlerFunc that wraps h and measures the
 // internal request-response latency.
 //
@@ -0,0 +1,369 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package queue implements a durable FIFO queue of records
+// stored in a local directory.
+//
+// Records are appended to segment files. Once all records of
+// a segment have been acknowledged, the segment is removed.
+// Records survive process restarts but are not synced to disk
+// on every push. Hence, records may be lost on power loss or
+// OS crashes.
+//
+// Records are delivered at least once. Records of the oldest
+// segment that have been acknowledged before a restart may be
+// returned again after the restart.
+package queue
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MaxRecordSize is the max. size of a single record.
+const MaxRecordSize = 1 << 20
+
+// segmentSize is the size at which a new segment is started.
+const segmentSize = 4 << 20
+
+// headerSize is the size of a record header. It contains the
+// record size and its CRC-32C checksum.
+const headerSize = 8
+
+// segmentSuffix is the file name suffix of segment files.
+const segmentSuffix = ".seg"
+
+var (
+	// ErrFull is returned by Push when the queue has reached
+	// its max. size.
+	ErrFull = errors.New("queue: queue is full")
+
+	// ErrClosed is returned when the queue has been closed.
+	ErrClosed = errors.New("queue: queue is closed")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Queue is a durable FIFO queue of records.
+//
+// Records may be pushed concurrently. However, records must be
+// consumed by a single goroutine calling Next and Ack.
+type Queue struct {
+	dir     string
+	maxSize int64
+
+	mu       sync.Mutex
+	pushed   chan struct{} // Closed and replaced whenever records are pushed
+	segments []*segment    // Ordered by sequence number. The last one is written
+	w        *os.File      // The last segment
+	r        *os.File      // The first segment, if opened for reading
+	rOff     int64         // Read offset within the first segment
+	next     int64         // Offset of the record after the one returned by Next
+	size     int64         // Total size of all segments
+	len      int           // Number of records not acknowledged
+	closed   bool
+}
+
+type segment struct {
+	seq     uint64
+	size    int64
+	records int
+}
+
+// Open opens the queue in the given directory, creating the
+// directory if it does not exist. Once the segments of the queue
+// reach maxSize bytes, Push fails with ErrFull.
+//
+// Incomplete or corrupted records at the end of a segment, for
+// example due to a crash while writing, are discarded.
+func Open(dir string, maxSize int64) (*Queue, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("queue: invalid max. size '%d'", maxSize)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Queue{
+		dir:     dir,
+		maxSize: maxSize,
+		pushed:  make(chan struct{}),
+	}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), segmentSuffix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		seq, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		q.segments = append(q.segments, &segment{seq: seq})
+	}
+	slices.SortFunc(q.segments, func(a, b *segment) int {
+		switch {
+		case a.seq < b.seq:
+			return -1
+		case a.seq > b.seq:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	for _, s := range q.segments {
+		if err = q.recover(s); err != nil {
+			return nil, err
+		}
+		q.size += s.size
+		q.len += s.records
+	}
+
+	// Remove empty segments, except for the last one, such
+	// that the first segment contains a record unless the
+	// queue is empty.
+	for len(q.segments) > 1 && q.segments[0].size == 0 {
+		if err = os.Remove(q.filename(q.segments[0].seq)); err != nil {
+			return nil, err
+		}
+		q.segments = q.segments[1:]
+	}
+
+	if len(q.segments) == 0 {
+		q.segments = append(q.segments, &segment{seq: 1})
+	}
+	last := q.segments[len(q.segments)-1]
+	if q.w, err = os.OpenFile(q.filename(last.seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Len returns the number of records that have
+// not been acknowledged.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.len
+}
+
+// Push appends the record to the end of the queue.
+//
+// It returns ErrFull if the record would exceed the max.
+// size of the queue.
+func (q *Queue) Push(record []byte) error {
+	if len(record) > MaxRecordSize {
+		return fmt.Errorf("queue: record size '%d' exceeds max. size '%d'", len(record), MaxRecordSize)
+	}
+
+	buf := make([]byte, headerSize, headerSize+len(record))
+	binary.BigEndian.PutUint32(buf[0:], uint32(len(record)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.Checksum(record, crcTable))
+	buf = append(buf, record...)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+	if q.size+int64(len(buf)) > q.maxSize {
+		return ErrFull
+	}
+
+	last := q.segments[len(q.segments)-1]
+	if last.size >= segmentSize {
+		w, err := os.OpenFile(q.filename(last.seq+1), os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+		q.w.Close()
+		q.w = w
+		last = &segment{seq: last.seq + 1}
+		q.segments = append(q.segments, last)
+	}
+	if _, err := q.w.Write(buf); err != nil {
+		// Discard a partially written record such that
+		// subsequent records remain readable.
+		q.w.Truncate(last.size)
+		return err
+	}
+	last.size += int64(len(buf))
+	last.records++
+	q.size += int64(len(buf))
+	q.len++
+
+	close(q.pushed)
+	q.pushed = make(chan struct{})
+	return nil
+}
+
+// Next returns the first record of the queue. It blocks until
+// a record is available, ctx is done or the queue is closed.
+//
+// Next returns the same record until it is acknowledged by Ack.
+func (q *Queue) Next(ctx context.Context) ([]byte, error) {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return nil, ErrClosed
+		}
+
+		first := q.segments[0]
+		if q.rOff < first.size {
+			record, err := q.read(first)
+			q.mu.Unlock()
+			return record, err
+		}
+		pushed := q.pushed
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-pushed:
+		}
+	}
+}
+
+// Ack acknowledges the record returned by Next and removes
+// it from the queue. Segments are removed once all of their
+// records have been acknowledged.
+func (q *Queue) Ack() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+	first := q.segments[0]
+	if q.next <= q.rOff {
+		return errors.New("queue: no record to acknowledge")
+	}
+	q.rOff = q.next
+	q.len--
+	if q.rOff < first.size {
+		return nil
+	}
+
+	// All records of the first segment have been acknowledged.
+	if q.r != nil {
+		q.r.Close()
+		q.r = nil
+	}
+	q.size -= first.size
+	q.rOff, q.next = 0, 0
+	if len(q.segments) == 1 { // Reuse the segment that is currently written
+		first.size, first.records = 0, 0
+		return q.w.Truncate(0)
+	}
+	q.segments = q.segments[1:]
+	return os.Remove(q.filename(first.seq))
+}
+
+// Close closes the queue. Records that have not been
+// acknowledged remain in the queue directory.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	close(q.pushed)
+
+	err := q.w.Close()
+	if q.r != nil {
+		err = errors.Join(err, q.r.Close())
+	}
+	return err
+}
+
+// read reads the record at the read offset of the segment.
+func (q *Queue) read(s *segment) ([]byte, error) {
+	if q.r == nil {
+		r, err := os.Open(q.filename(s.seq))
+		if err != nil {
+			return nil, err
+		}
+		q.r = r
+	}
+
+	var header [headerSize]byte
+	if _, err := q.r.ReadAt(header[:], q.rOff); err != nil {
+		return nil, err
+	}
+	record := make([]byte, binary.BigEndian.Uint32(header[0:]))
+	if _, err := q.r.ReadAt(record, q.rOff+headerSize); err != nil {
+		return nil, err
+	}
+	q.next = q.rOff + headerSize + int64(len(record))
+	return record, nil
+}
+
+// recover scans all records of the segment and truncates
+// the segment at the first invalid record.
+func (q *Queue) recover(s *segment) error {
+	f, err := os.OpenFile(q.filename(s.seq), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var (
+		header [headerSize]byte
+		record []byte
+	)
+	for {
+		if _, err = f.ReadAt(header[:], s.size); err != nil {
+			break
+		}
+		n := binary.BigEndian.Uint32(header[0:])
+		if n > MaxRecordSize {
+			break
+		}
+		record = slices.Grow(record[:0], int(n))[:n]
+		if _, err = f.ReadAt(record, s.size+headerSize); err != nil {
+			break
+		}
+		if crc32.Checksum(record, crcTable) != binary.BigEndian.Uint32(header[4:]) {
+			break
+		}
+		s.size += headerSize + int64(n)
+		s.records++
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Size() > s.size {
+		return f.Truncate(s.size)
+	}
+	return nil
+}
+
+func (q *Queue) filename(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, segmentSuffix))
+}
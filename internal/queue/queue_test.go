@@ -0,0 +1,191 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	q, err := Open(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+	for i := range 10 {
+		if err = q.Push(fmt.Appendf(nil, "record-%d", i)); err != nil {
+			t.Fatalf("Failed to push record %d: %v", i, err)
+		}
+	}
+	for i := range 5 {
+		record, err := q.Next(ctx)
+		if err != nil {
+			t.Fatalf("Failed to read record %d: %v", i, err)
+		}
+		if want := fmt.Appendf(nil, "record-%d", i); !bytes.Equal(record, want) {
+			t.Fatalf("Invalid record %d: got '%s' - want '%s'", i, record, want)
+		}
+		if err = q.Ack(); err != nil {
+			t.Fatalf("Failed to acknowledge record %d: %v", i, err)
+		}
+	}
+	if n := q.Len(); n != 5 {
+		t.Fatalf("Invalid queue length: got '%d' - want '5'", n)
+	}
+	if err = q.Close(); err != nil {
+		t.Fatalf("Failed to close queue: %v", err)
+	}
+
+	// Records survive a restart. Acknowledged records of the
+	// first segment are delivered again.
+	q, err = Open(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+	defer q.Close()
+
+	if n := q.Len(); n != 10 {
+		t.Fatalf("Invalid queue length: got '%d' - want '10'", n)
+	}
+	for i := range 10 {
+		record, err := q.Next(ctx)
+		if err != nil {
+			t.Fatalf("Failed to read record %d: %v", i, err)
+		}
+		if want := fmt.Appendf(nil, "record-%d", i); !bytes.Equal(record, want) {
+			t.Fatalf("Invalid record %d: got '%s' - want '%s'", i, record, want)
+		}
+		if err = q.Ack(); err != nil {
+			t.Fatalf("Failed to acknowledge record %d: %v", i, err)
+		}
+	}
+	if n := q.Len(); n != 0 {
+		t.Fatalf("Invalid queue length: got '%d' - want '0'", n)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err = q.Next(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Read from empty queue: got '%v' - want '%v'", err, context.DeadlineExceeded)
+	}
+}
+
+func TestQueueSegments(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	q, err := Open(dir, 64<<20)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+	defer q.Close()
+
+	record := make([]byte, MaxRecordSize)
+	for i := range 10 {
+		record[0] = byte(i)
+		if err = q.Push(record); err != nil {
+			t.Fatalf("Failed to push record %d: %v", i, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 {
+		t.Fatalf("Invalid number of segments: got '%d' - want '3'", len(entries))
+	}
+	for i := range 10 {
+		record, err := q.Next(ctx)
+		if err != nil {
+			t.Fatalf("Failed to read record %d: %v", i, err)
+		}
+		if record[0] != byte(i) {
+			t.Fatalf("Invalid record %d: got record '%d'", i, record[0])
+		}
+		if err = q.Ack(); err != nil {
+			t.Fatalf("Failed to acknowledge record %d: %v", i, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("Invalid number of segments: got '%d' - want '1'", len(entries))
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	q, err := Open(t.TempDir(), 64)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+	defer q.Close()
+
+	if err = q.Push(make([]byte, 32)); err != nil {
+		t.Fatalf("Failed to push record: %v", err)
+	}
+	if err = q.Push(make([]byte, 32)); !errors.Is(err, ErrFull) {
+		t.Fatalf("Pushed record to full queue: got '%v' - want '%v'", err, ErrFull)
+	}
+
+	if _, err = q.Next(context.Background()); err != nil {
+		t.Fatalf("Failed to read record: %v", err)
+	}
+	if err = q.Ack(); err != nil {
+		t.Fatalf("Failed to acknowledge record: %v", err)
+	}
+	if err = q.Push(make([]byte, 32)); err != nil {
+		t.Fatalf("Failed to push record: %v", err)
+	}
+}
+
+func TestQueueRecover(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+	for i := range 3 {
+		if err = q.Push(fmt.Appendf(nil, "record-%d", i)); err != nil {
+			t.Fatalf("Failed to push record %d: %v", i, err)
+		}
+	}
+	q.Close()
+
+	// Simulate a crash while writing the last record.
+	filename := filepath.Join(dir, fmt.Sprintf("%020d%s", 1, segmentSuffix))
+	stat, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("Failed to stat segment: %v", err)
+	}
+	if err = os.Truncate(filename, stat.Size()-2); err != nil {
+		t.Fatalf("Failed to truncate segment: %v", err)
+	}
+
+	q, err = Open(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+	defer q.Close()
+
+	if n := q.Len(); n != 2 {
+		t.Fatalf("Invalid queue length: got '%d' - want '2'", n)
+	}
+	if err = q.Push([]byte("record-3")); err != nil {
+		t.Fatalf("Failed to push record: %v", err)
+	}
+	for _, want := range []string{"record-0", "record-1", "record-3"} {
+		record, err := q.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to read record: %v", err)
+		}
+		if string(record) != want {
+			t.Fatalf("Invalid record: got '%s' - want '%s'", record, want)
+		}
+		q.Ack()
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package sevsnp implements sealing key derivation and attestation
+// reports for AMD SEV-SNP confidential VMs via the Linux sev-guest
+// driver.
+//
+// A derived key is bound to the chip (VCEK), the launch measurement
+// and the guest policy of the VM. Hence, only the same VM image,
+// launched with the same policy on the same machine, derives the
+// same key. A hypervisor cannot derive or read it.
+package sevsnp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// DefaultDevice is the SEV-SNP guest device on Linux.
+const DefaultDevice = "/dev/sev-guest"
+
+// Guest fields mixed into derived keys.
+const (
+	fieldGuestPolicy = 1 << 0
+	fieldMeasurement = 1 << 3
+)
+
+// Sizes of the firmware messages.
+const (
+	keyResponseSize    = 64
+	reportResponseSize = 4000
+
+	reportDataSize = 64
+	keySize        = 32
+)
+
+// derivedKeyRequest is the SNP_GET_DERIVED_KEY request
+// as defined by the Linux sev-guest driver.
+type derivedKeyRequest struct {
+	RootKeySelect    uint32 // 0 = VCEK
+	_                uint32
+	GuestFieldSelect uint64
+	VMPL             uint32
+	GuestSVN         uint32
+	TCBVersion       uint64
+}
+
+// reportRequest is the SNP_GET_REPORT request as
+// defined by the Linux sev-guest driver.
+type reportRequest struct {
+	UserData [reportDataSize]byte
+	VMPL     uint32
+	_        [28]byte
+}
+
+// Device is a SEV-SNP guest device.
+type Device struct {
+	path string
+}
+
+// Open returns the SEV-SNP guest device at the given path. If
+// path is empty, DefaultDevice is used. It returns an error if
+// the server does not run within a SEV-SNP confidential VM.
+func Open(path string) (*Device, error) {
+	if path == "" {
+		path = DefaultDevice
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("sevsnp: no SEV-SNP guest device: %v", err)
+	}
+	return &Device{path: path}, nil
+}
+
+// String returns a string representation of the device.
+func (d *Device) String() string { return "AMD SEV-SNP: " + d.path }
+
+// SealingKey returns a 256-bit key derived from the chip-unique
+// VCEK, the launch measurement and the guest policy of the VM.
+func (d *Device) SealingKey(context.Context) ([]byte, error) {
+	f, err := os.OpenFile(d.path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	resp, err := getDerivedKey(f, &derivedKeyRequest{
+		GuestFieldSelect: fieldGuestPolicy | fieldMeasurement,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseKeyResponse(resp[:])
+}
+
+// Report returns a SEV-SNP attestation report, signed by the
+// chip's VCEK, that includes data as report data. Data must
+// not be longer than 64 bytes.
+func (d *Device) Report(_ context.Context, data []byte) ([]byte, error) {
+	if len(data) > reportDataSize {
+		return nil, errors.New("sevsnp: report data too large")
+	}
+	req := &reportRequest{}
+	copy(req.UserData[:], data)
+
+	f, err := os.OpenFile(d.path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	resp, err := getReport(f, req)
+	if err != nil {
+		return nil, err
+	}
+	return parseReportResponse(resp[:])
+}
+
+// parseKeyResponse parses a MSG_KEY_RSP firmware message:
+// status (4 bytes), reserved (28 bytes), key (32 bytes).
+func parseKeyResponse(b []byte) ([]byte, error) {
+	if len(b) < keyResponseSize {
+		return nil, errors.New("sevsnp: invalid key response")
+	}
+	if status := binary.LittleEndian.Uint32(b); status != 0 {
+		return nil, fmt.Errorf("sevsnp: failed to derive key: status 0x%x", status)
+	}
+	return slices.Clone(b[32 : 32+keySize]), nil
+}
+
+// parseReportResponse parses a MSG_REPORT_RSP firmware message:
+// status (4 bytes), report size (4 bytes), reserved (24 bytes)
+// and the report.
+func parseReportResponse(b []byte) ([]byte, error) {
+	if len(b) < 32 {
+		return nil, errors.New("sevsnp: invalid report response")
+	}
+	if status := binary.LittleEndian.Uint32(b); status != 0 {
+		return nil, fmt.Errorf("sevsnp: failed to get report: status 0x%x", status)
+	}
+	size := int(binary.LittleEndian.Uint32(b[4:]))
+	if size == 0 || size > len(b)-32 {
+		return nil, errors.New("sevsnp: invalid report response: invalid report size")
+	}
+	return slices.Clone(b[32 : 32+size]), nil
+}
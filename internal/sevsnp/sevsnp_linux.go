@@ -0,0 +1,67 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+//go:build linux && amd64
+
+package sevsnp
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioctl commands of the Linux sev-guest driver:
+// _IOWR('S', nr, struct snp_guest_request_ioctl)
+const (
+	ioctlGetReport     = 0xC0205300
+	ioctlGetDerivedKey = 0xC0205301
+)
+
+// guestRequest is the snp_guest_request_ioctl structure. The
+// request and response are unsafe.Pointers such that the GC
+// tracks them during the ioctl.
+type guestRequest struct {
+	MsgVersion uint8
+	_          [7]byte
+	ReqData    unsafe.Pointer
+	RespData   unsafe.Pointer
+	ExitInfo2  uint64
+}
+
+func getDerivedKey(f *os.File, req *derivedKeyRequest) ([keyResponseSize]byte, error) {
+	var resp [keyResponseSize]byte
+	err := ioctl(f, ioctlGetDerivedKey, unsafe.Pointer(req), unsafe.Pointer(&resp))
+	return resp, err
+}
+
+func getReport(f *os.File, req *reportRequest) ([reportResponseSize]byte, error) {
+	var resp [reportResponseSize]byte
+	err := ioctl(f, ioctlGetReport, unsafe.Pointer(req), unsafe.Pointer(&resp))
+	return resp, err
+}
+
+func ioctl(f *os.File, cmd uintptr, req, resp unsafe.Pointer) error {
+	greq := &guestRequest{
+		MsgVersion: 1,
+		ReqData:    req,
+		RespData:   resp,
+	}
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno unix.Errno
+	if err = conn.Control(func(fd uintptr) {
+		_, _, errno = unix.Syscall(unix.SYS_IOCTL, fd, cmd, uintptr(unsafe.Pointer(greq)))
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return fmt.Errorf("sevsnp: guest request failed: %v (firmware error 0x%x)", errno, uint32(greq.ExitInfo2))
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+//go:build !(linux && amd64)
+
+package sevsnp
+
+import (
+	"errors"
+	"os"
+)
+
+var errUnsupported = errors.New("sevsnp: SEV-SNP is only supported on linux/amd64")
+
+func getDerivedKey(*os.File, *derivedKeyRequest) ([keyResponseSize]byte, error) {
+	return [keyResponseSize]byte{}, errUnsupported
+}
+
+func getReport(*os.File, *reportRequest) ([reportResponseSize]byte, error) {
+	return [reportResponseSize]byte{}, errUnsupported
+}
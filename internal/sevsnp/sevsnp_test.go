@@ -0,0 +1,63 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package sevsnp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unsafe"
+)
+
+func TestRequestSize(t *testing.T) {
+	// The sizes must match the structures of the sev-guest driver.
+	if size := unsafe.Sizeof(derivedKeyRequest{}); size != 32 {
+		t.Fatalf("Invalid derived key request size: got %d - want %d", size, 32)
+	}
+	if size := unsafe.Sizeof(reportRequest{}); size != 96 {
+		t.Fatalf("Invalid report request size: got %d - want %d", size, 96)
+	}
+}
+
+func TestParseKeyResponse(t *testing.T) {
+	resp := make([]byte, keyResponseSize)
+	key := bytes.Repeat([]byte{0x42}, keySize)
+	copy(resp[32:], key)
+
+	k, err := parseKeyResponse(resp)
+	if err != nil {
+		t.Fatalf("Failed to parse key response: %v", err)
+	}
+	if !bytes.Equal(k, key) {
+		t.Fatalf("Invalid key: got '%x' - want '%x'", k, key)
+	}
+
+	binary.LittleEndian.PutUint32(resp, 0x16) // INVALID_PARAM
+	if _, err = parseKeyResponse(resp); err == nil {
+		t.Fatal("Parsed key response with error status")
+	}
+}
+
+func TestParseReportResponse(t *testing.T) {
+	const ReportSize = 1184
+
+	resp := make([]byte, reportResponseSize)
+	report := bytes.Repeat([]byte{0x42}, ReportSize)
+	binary.LittleEndian.PutUint32(resp[4:], ReportSize)
+	copy(resp[32:], report)
+
+	r, err := parseReportResponse(resp)
+	if err != nil {
+		t.Fatalf("Failed to parse report response: %v", err)
+	}
+	if !bytes.Equal(r, report) {
+		t.Fatal("Invalid report")
+	}
+
+	binary.LittleEndian.PutUint32(resp[4:], reportResponseSize)
+	if _, err = parseReportResponse(resp); err == nil {
+		t.Fatal("Parsed report response with invalid report size")
+	}
+}
@@ -0,0 +1,429 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package ssekms implements a shim in front of a KES server that
+// serves the KMS operations required by S3 SSE-KMS - generating and
+// decrypting per-object data encryption keys (DEKs) - at very high
+// request rates.
+//
+// Object storage generates one DEK per object. Instead of asking the
+// KES server for every DEK, the shim fetches an intermediate key per
+// KES key, similar to S3 bucket keys, and uses it to encrypt random
+// DEKs locally. The intermediate key is replaced once it expires or
+// has encrypted too many DEKs. The KES server is only contacted when
+// a new intermediate key is needed or when an intermediate key is not
+// cached for decryption.
+//
+// Shim ciphertexts contain the intermediate key encrypted by the KES
+// server. Hence, they can only be decrypted by a shim, not by the KES
+// server directly. Ciphertexts produced by the KES server are passed
+// through to it.
+package ssekms
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"aead.dev/mem"
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/cache"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+// header is the prefix of all ciphertexts produced by the shim.
+var header = []byte("kes\x00sse\x01")
+
+// errInvalidName is returned when a key name is empty or too long.
+var errInvalidName = kes.NewError(http.StatusBadRequest, "key name is empty or too long")
+
+// Upstream is the KES server the shim forwards requests to.
+// It is implemented by the KES SDK client.
+type Upstream interface {
+	// GenerateKey returns a new DEK encrypted with the named key.
+	GenerateKey(ctx context.Context, name string, context []byte) (kes.DEK, error)
+
+	// Decrypt decrypts the ciphertext with the named key.
+	Decrypt(ctx context.Context, name string, ciphertext, context []byte) ([]byte, error)
+
+	// Status returns the current state of the KES server.
+	Status(ctx context.Context) (kes.State, error)
+}
+
+// Config is a structure containing the shim configuration.
+type Config struct {
+	// Upstream is the KES server generating and decrypting
+	// intermediate keys.
+	Upstream Upstream
+
+	// KeyTTL is the time an intermediate key is used to
+	// encrypt DEKs and cached for decrypting DEKs. If <= 0,
+	// it defaults to 5 minutes.
+	KeyTTL time.Duration
+
+	// KeyUses is the max. number of DEKs encrypted with the
+	// same intermediate key. If 0, it defaults to 2^24.
+	KeyUses uint64
+
+	// CacheSize is the max. number of intermediate keys cached
+	// for decrypting DEKs. If <= 0, it defaults to 10000.
+	CacheSize int
+
+	// ErrorLog logs errors returned by the upstream KES server.
+	// If nil, slog.Default is used.
+	ErrorLog *slog.Logger
+}
+
+// New returns a new Shim for the given config.
+func New(config *Config) *Shim {
+	s := &Shim{
+		upstream: config.Upstream,
+		ttl:      config.KeyTTL,
+		uses:     config.KeyUses,
+		log:      config.ErrorLog,
+	}
+	if s.ttl <= 0 {
+		s.ttl = 5 * time.Minute
+	}
+	if s.uses == 0 {
+		s.uses = 1 << 24
+	}
+	if s.log == nil {
+		s.log = slog.Default()
+	}
+	size := config.CacheSize
+	if size <= 0 {
+		size = 10000
+	}
+	s.encKeys = cache.NewCow[string, *encryptionKey](0)
+	s.decKeys = cache.NewCow[[sha256.Size]byte, decryptionKey](size)
+
+	s.mux = http.NewServeMux()
+	s.mux.Handle(api.PathStatus, api.Route{
+		Method:  http.MethodGet,
+		Path:    api.PathStatus,
+		MaxBody: 0,
+		Timeout: 15 * time.Second,
+		Auth:    api.InsecureSkipVerify,
+		Handler: api.HandlerFunc(s.status),
+	})
+	s.mux.Handle(api.PathKeyGenerate, api.Route{
+		Method:  http.MethodPut,
+		Path:    api.PathKeyGenerate,
+		MaxBody: 1 * mem.MB,
+		Timeout: 15 * time.Second,
+		Auth:    api.InsecureSkipVerify,
+		Handler: api.HandlerFunc(s.generateKey),
+	})
+	s.mux.Handle(api.PathKeyDecrypt, api.Route{
+		Method:  http.MethodPut,
+		Path:    api.PathKeyDecrypt,
+		MaxBody: 1 * mem.MB,
+		Timeout: 15 * time.Second,
+		Auth:    api.InsecureSkipVerify,
+		Handler: api.HandlerFunc(s.decryptKey),
+	})
+	return s
+}
+
+// Shim generates and decrypts DEKs using intermediate keys
+// of an upstream KES server.
+//
+// It implements a subset of the KES API, the status, generate
+// and decrypt APIs, without authentication. Hence, it should
+// only listen on a loopback address.
+type Shim struct {
+	upstream Upstream
+	ttl      time.Duration
+	uses     uint64
+	log      *slog.Logger
+
+	encKeys *cache.Cow[string, *encryptionKey]
+	decKeys *cache.Cow[[sha256.Size]byte, decryptionKey]
+
+	encBarrier cache.Barrier[string]
+	decBarrier cache.Barrier[[sha256.Size]byte]
+
+	mux *http.ServeMux
+}
+
+// encryptionKey is an intermediate key used to encrypt DEKs.
+type encryptionKey struct {
+	key        crypto.SecretKey
+	ciphertext []byte
+	expiresAt  time.Time
+	uses       atomic.Uint64
+}
+
+// decryptionKey is an intermediate key cached for decrypting DEKs.
+type decryptionKey struct {
+	key       crypto.SecretKey
+	expiresAt time.Time
+}
+
+// ServeHTTP serves the shim's subset of the KES API.
+func (s *Shim) ServeHTTP(w http.ResponseWriter, r *http.Request) { s.mux.ServeHTTP(w, r) }
+
+// GenerateKey returns a new DEK encrypted with an intermediate
+// key of the named KES key. The context is bound to the DEK
+// and must be provided when decrypting it.
+func (s *Shim) GenerateKey(ctx context.Context, name string, context []byte) (kes.DEK, error) {
+	if !validName(name) {
+		return kes.DEK{}, errInvalidName
+	}
+	key, err := s.encryptionKey(ctx, name)
+	if err != nil {
+		return kes.DEK{}, err
+	}
+
+	plaintext := make([]byte, 32)
+	if _, err = rand.Read(plaintext); err != nil {
+		return kes.DEK{}, err
+	}
+	sealed, err := key.key.Encrypt(plaintext, associatedData(name, context))
+	if err != nil {
+		return kes.DEK{}, err
+	}
+
+	ciphertext := make([]byte, 0, len(header)+2+len(key.ciphertext)+len(sealed))
+	ciphertext = append(ciphertext, header...)
+	ciphertext = binary.BigEndian.AppendUint16(ciphertext, uint16(len(key.ciphertext)))
+	ciphertext = append(ciphertext, key.ciphertext...)
+	ciphertext = append(ciphertext, sealed...)
+	return kes.DEK{
+		Plaintext:  plaintext,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// Decrypt decrypts the ciphertext of a DEK with the named key.
+// The context must match the one provided when generating the DEK.
+//
+// Ciphertexts not produced by a shim are decrypted by the upstream
+// KES server.
+func (s *Shim) Decrypt(ctx context.Context, name string, ciphertext, context []byte) ([]byte, error) {
+	if !validName(name) {
+		return nil, errInvalidName
+	}
+	if !bytes.HasPrefix(ciphertext, header) {
+		return s.upstream.Decrypt(ctx, name, ciphertext, context)
+	}
+
+	b := ciphertext[len(header):]
+	if len(b) < 2 {
+		return nil, kes.ErrDecrypt
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if b = b[2:]; len(b) <= n {
+		return nil, kes.ErrDecrypt
+	}
+
+	key, err := s.decryptionKey(ctx, name, b[:n])
+	if err != nil {
+		return nil, err
+	}
+	// Decrypt works in place. Hence, we must not pass
+	// the caller's ciphertext.
+	plaintext, err := key.Decrypt(bytes.Clone(b[n:]), associatedData(name, context))
+	if err != nil {
+		return nil, kes.ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// Status returns the current state of the upstream KES server.
+func (s *Shim) Status(ctx context.Context) (kes.State, error) {
+	return s.upstream.Status(ctx)
+}
+
+// encryptionKey returns the current intermediate key of the
+// named key. It fetches a new one from the upstream KES server
+// if there is none or the current one has expired or has been
+// used too often.
+func (s *Shim) encryptionKey(ctx context.Context, name string) (*encryptionKey, error) {
+	if key, ok := s.encKeys.Get(name); ok && key.usable(time.Now(), s.uses) {
+		return key, nil
+	}
+
+	s.encBarrier.Lock(name)
+	defer s.encBarrier.Unlock(name)
+
+	// Another request may have replaced the key
+	// while we were waiting for the barrier.
+	if key, ok := s.encKeys.Get(name); ok && key.usable(time.Now(), s.uses) {
+		return key, nil
+	}
+
+	dek, err := s.upstream.GenerateKey(ctx, name, header)
+	if err != nil {
+		return nil, err
+	}
+	if len(dek.Ciphertext) > 1<<16-1 {
+		return nil, errors.New("ssekms: intermediate key ciphertext is too large")
+	}
+	secret, err := crypto.NewSecretKey(crypto.AES256, dek.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	key := &encryptionKey{
+		key:        secret,
+		ciphertext: dek.Ciphertext,
+		expiresAt:  now.Add(s.ttl),
+	}
+	key.uses.Store(1) // The caller uses the key once
+	s.encKeys.Set(name, key)
+	s.cacheDecryptionKey(cacheKey(name, dek.Ciphertext), decryptionKey{
+		key:       secret,
+		expiresAt: now.Add(s.ttl),
+	})
+	return key, nil
+}
+
+// decryptionKey returns the intermediate key of the named key
+// for the given ciphertext. It asks the upstream KES server to
+// decrypt the ciphertext if the intermediate key is not cached.
+func (s *Shim) decryptionKey(ctx context.Context, name string, ciphertext []byte) (crypto.SecretKey, error) {
+	id := cacheKey(name, ciphertext)
+	if key, ok := s.decKeys.Get(id); ok && time.Now().Before(key.expiresAt) {
+		return key.key, nil
+	}
+
+	s.decBarrier.Lock(id)
+	defer s.decBarrier.Unlock(id)
+
+	if key, ok := s.decKeys.Get(id); ok && time.Now().Before(key.expiresAt) {
+		return key.key, nil
+	}
+
+	plaintext, err := s.upstream.Decrypt(ctx, name, ciphertext, header)
+	if err != nil {
+		return crypto.SecretKey{}, err
+	}
+	secret, err := crypto.NewSecretKey(crypto.AES256, plaintext)
+	if err != nil {
+		return crypto.SecretKey{}, kes.ErrDecrypt
+	}
+	s.cacheDecryptionKey(id, decryptionKey{
+		key:       secret,
+		expiresAt: time.Now().Add(s.ttl),
+	})
+	return secret, nil
+}
+
+// cacheDecryptionKey caches the intermediate key. If the cache
+// is full, it removes all expired keys first. If the cache is
+// still full, the key is not cached.
+func (s *Shim) cacheDecryptionKey(id [sha256.Size]byte, key decryptionKey) {
+	if s.decKeys.Set(id, key) {
+		return
+	}
+
+	now := time.Now()
+	s.decKeys.DeleteFunc(func(_ [sha256.Size]byte, k decryptionKey) bool {
+		return !now.Before(k.expiresAt)
+	})
+	s.decKeys.Set(id, key)
+}
+
+// usable reports whether the intermediate key can encrypt
+// another DEK. It counts the use if so.
+func (k *encryptionKey) usable(now time.Time, maxUses uint64) bool {
+	return now.Before(k.expiresAt) && k.uses.Add(1) <= maxUses
+}
+
+func (s *Shim) status(resp *api.Response, req *api.Request) {
+	state, err := s.upstream.Status(req.Context())
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "KES server is not reachable")
+		return
+	}
+	api.ReplyWith(resp, http.StatusOK, state)
+}
+
+func (s *Shim) generateKey(resp *api.Response, req *api.Request) {
+	var body api.GenerateKeyRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		resp.Fail(http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	dek, err := s.GenerateKey(req.Context(), req.Resource, body.Context)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to generate key")
+		return
+	}
+	api.ReplyWith(resp, http.StatusOK, api.GenerateKeyResponse{
+		Plaintext:  dek.Plaintext,
+		Ciphertext: dek.Ciphertext,
+	})
+}
+
+func (s *Shim) decryptKey(resp *api.Response, req *api.Request) {
+	var body api.DecryptKeyRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		resp.Fail(http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	plaintext, err := s.Decrypt(req.Context(), req.Resource, body.Ciphertext, body.Context)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to decrypt key")
+		return
+	}
+	api.ReplyWith(resp, http.StatusOK, api.DecryptKeyResponse{
+		Plaintext: plaintext,
+	})
+}
+
+// validName reports whether name can be bound to a DEK. The
+// upstream KES server performs the actual name validation.
+func validName(name string) bool { return name != "" && len(name) <= 255 }
+
+// associatedData binds a DEK to the key name and the
+// context provided by the client.
+func associatedData(name string, context []byte) []byte {
+	b := make([]byte, 0, len(header)+2+len(name)+len(context))
+	b = append(b, header...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(name)))
+	b = append(b, name...)
+	return append(b, context...)
+}
+
+// cacheKey returns the cache key of the intermediate key
+// ciphertext of the named key.
+func cacheKey(name string, ciphertext []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(binary.BigEndian.AppendUint16(nil, uint16(len(name))))
+	h.Write([]byte(name))
+	h.Write(ciphertext)
+	return [sha256.Size]byte(h.Sum(nil))
+}
@@ -0,0 +1,195 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package ssekms
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestShim(t *testing.T) {
+	ctx := context.Background()
+	upstream := newFakeKES(t)
+	shim := New(&Config{Upstream: upstream})
+
+	context := []byte("bucket/object")
+	deks := make([]kes.DEK, 0, 100)
+	for range 100 {
+		dek, err := shim.GenerateKey(ctx, "my-key", context)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		deks = append(deks, dek)
+	}
+	for i, dek := range deks {
+		plaintext, err := shim.Decrypt(ctx, "my-key", dek.Ciphertext, context)
+		if err != nil {
+			t.Fatalf("Test %d: failed to decrypt key: %v", i, err)
+		}
+		if !bytes.Equal(plaintext, dek.Plaintext) {
+			t.Fatalf("Test %d: plaintext mismatch: got '%x' - want '%x'", i, plaintext, dek.Plaintext)
+		}
+	}
+	if n := upstream.generated.Load(); n != 1 {
+		t.Fatalf("Invalid number of upstream key generations: got '%d' - want '1'", n)
+	}
+	if n := upstream.decrypted.Load(); n != 0 {
+		t.Fatalf("Invalid number of upstream decryptions: got '%d' - want '0'", n)
+	}
+
+	// A new shim has no cached intermediate keys and has to
+	// ask the upstream KES server once.
+	shim = New(&Config{Upstream: upstream})
+	for i, dek := range deks {
+		if _, err := shim.Decrypt(ctx, "my-key", dek.Ciphertext, context); err != nil {
+			t.Fatalf("Test %d: failed to decrypt key: %v", i, err)
+		}
+	}
+	if n := upstream.decrypted.Load(); n != 1 {
+		t.Fatalf("Invalid number of upstream decryptions: got '%d' - want '1'", n)
+	}
+
+	dek := deks[0]
+	if _, err := shim.Decrypt(ctx, "my-key", dek.Ciphertext, []byte("bucket/other")); !errors.Is(err, kes.ErrDecrypt) {
+		t.Fatalf("Decrypted key with invalid context: got '%v' - want '%v'", err, kes.ErrDecrypt)
+	}
+	if _, err := shim.Decrypt(ctx, "other-key", dek.Ciphertext, context); !errors.Is(err, kes.ErrDecrypt) {
+		t.Fatalf("Decrypted key with invalid key name: got '%v' - want '%v'", err, kes.ErrDecrypt)
+	}
+	if _, err := shim.Decrypt(ctx, "my-key", dek.Ciphertext[:len(header)+1], context); !errors.Is(err, kes.ErrDecrypt) {
+		t.Fatalf("Decrypted truncated ciphertext: got '%v' - want '%v'", err, kes.ErrDecrypt)
+	}
+}
+
+func TestShimRotation(t *testing.T) {
+	ctx := context.Background()
+	upstream := newFakeKES(t)
+
+	shim := New(&Config{Upstream: upstream, KeyUses: 10})
+	for range 25 {
+		if _, err := shim.GenerateKey(ctx, "my-key", nil); err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+	}
+	if n := upstream.generated.Load(); n != 3 {
+		t.Fatalf("Invalid number of upstream key generations: got '%d' - want '3'", n)
+	}
+
+	shim = New(&Config{Upstream: upstream, KeyTTL: time.Nanosecond})
+	dek, err := shim.GenerateKey(ctx, "my-key", nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err = shim.GenerateKey(ctx, "my-key", nil); err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if n := upstream.generated.Load(); n != 5 {
+		t.Fatalf("Invalid number of upstream key generations: got '%d' - want '5'", n)
+	}
+	if _, err = shim.Decrypt(ctx, "my-key", dek.Ciphertext, nil); err != nil {
+		t.Fatalf("Failed to decrypt key with expired intermediate key: %v", err)
+	}
+}
+
+func TestShimPassthrough(t *testing.T) {
+	ctx := context.Background()
+	upstream := newFakeKES(t)
+	shim := New(&Config{Upstream: upstream})
+
+	dek, err := upstream.GenerateKey(ctx, "my-key", []byte("context"))
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	plaintext, err := shim.Decrypt(ctx, "my-key", dek.Ciphertext, []byte("context"))
+	if err != nil {
+		t.Fatalf("Failed to decrypt upstream key: %v", err)
+	}
+	if !bytes.Equal(plaintext, dek.Plaintext) {
+		t.Fatalf("Plaintext mismatch: got '%x' - want '%x'", plaintext, dek.Plaintext)
+	}
+	if n := upstream.decrypted.Load(); n != 1 {
+		t.Fatalf("Invalid number of upstream decryptions: got '%d' - want '1'", n)
+	}
+}
+
+func TestShimAPI(t *testing.T) {
+	ctx := context.Background()
+	upstream := newFakeKES(t)
+	srv := httptest.NewServer(New(&Config{Upstream: upstream}))
+	defer srv.Close()
+
+	client := &kes.Client{
+		Endpoints:  []string{srv.URL},
+		HTTPClient: *srv.Client(),
+	}
+	if _, err := client.Status(ctx); err != nil {
+		t.Fatalf("Failed to fetch status: %v", err)
+	}
+	dek, err := client.GenerateKey(ctx, "my-key", []byte("context"))
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	plaintext, err := client.Decrypt(ctx, "my-key", dek.Ciphertext, []byte("context"))
+	if err != nil {
+		t.Fatalf("Failed to decrypt key: %v", err)
+	}
+	if !bytes.Equal(plaintext, dek.Plaintext) {
+		t.Fatalf("Plaintext mismatch: got '%x' - want '%x'", plaintext, dek.Plaintext)
+	}
+	var kesErr kes.Error
+	if _, err = client.Decrypt(ctx, "my-key", dek.Ciphertext, nil); !errors.As(err, &kesErr) || kesErr.Status() != kes.ErrDecrypt.Status() {
+		t.Fatalf("Decrypted key with invalid context: got '%v' - want '%v'", err, kes.ErrDecrypt)
+	}
+}
+
+// fakeKES is an upstream KES server with a single secret
+// key that counts the requests it receives.
+type fakeKES struct {
+	key crypto.SecretKey
+
+	generated atomic.Int64
+	decrypted atomic.Int64
+}
+
+func newFakeKES(t *testing.T) *fakeKES {
+	key, err := crypto.GenerateSecretKey(crypto.AES256, rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate secret key: %v", err)
+	}
+	return &fakeKES{key: key}
+}
+
+func (f *fakeKES) GenerateKey(_ context.Context, name string, context []byte) (kes.DEK, error) {
+	f.generated.Add(1)
+
+	plaintext := make([]byte, 32)
+	if _, err := rand.Read(plaintext); err != nil {
+		return kes.DEK{}, err
+	}
+	ciphertext, err := f.key.Encrypt(plaintext, append([]byte(name), context...))
+	if err != nil {
+		return kes.DEK{}, err
+	}
+	return kes.DEK{Plaintext: plaintext, Ciphertext: ciphertext}, nil
+}
+
+func (f *fakeKES) Decrypt(_ context.Context, name string, ciphertext, context []byte) ([]byte, error) {
+	f.decrypted.Add(1)
+	return f.key.Decrypt(bytes.Clone(ciphertext), append([]byte(name), context...))
+}
+
+func (f *fakeKES) Status(context.Context) (kes.State, error) {
+	return kes.State{Version: "v0.0.0-dev"}, nil
+}
@@ -0,0 +1,545 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package tpm implements a minimal TPM 2.0 client that produces
+// quotes - PCR values signed by the TPM - and verifies them.
+//
+// Quotes are signed by a restricted ECDSA P-256 attestation key
+// derived from the TPM's endorsement hierarchy. The attestation
+// key is created as primary key such that the same TPM always
+// returns the same key. A restricted key only signs structures
+// generated by the TPM itself. Hence, a valid quote proves that
+// the TPM reported the included PCR values.
+package tpm
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"slices"
+	"sync"
+)
+
+// DefaultDevice is the TPM resource manager device on Linux.
+const DefaultDevice = "/dev/tpmrm0"
+
+// MaxPCR is the largest PCR index.
+const MaxPCR = 23
+
+// TPM 2.0 constants as defined by the TPM 2.0 Library Specification.
+const (
+	tagNoSessions = 0x8001
+	tagSessions   = 0x8002
+
+	ccCreatePrimary = 0x00000131
+	ccQuote         = 0x00000158
+	ccFlushContext  = 0x00000165
+	ccPCRRead       = 0x0000017E
+
+	rhEndorsement = 0x4000000B
+	rsPassword    = 0x40000009
+
+	algECC    = 0x0023
+	algSHA256 = 0x000B
+	algNull   = 0x0010
+	algECDSA  = 0x0018
+	eccP256   = 0x0003
+
+	attestMagic = 0xff544347
+	attestQuote = 0x8018
+
+	// fixedTPM | fixedParent | sensitiveDataOrigin | userWithAuth | restricted | sign
+	akAttributes = 1<<1 | 1<<4 | 1<<5 | 1<<6 | 1<<16 | 1<<18
+)
+
+// maxResponseSize is the max. size of a TPM response.
+const maxResponseSize = 4096
+
+// Quote is a TPM quote over a set of SHA-256 PCRs.
+type Quote struct {
+	// Attest is the TPMS_ATTEST structure generated and
+	// signed by the TPM. It contains the qualifying data
+	// and the digest of the quoted PCR values.
+	Attest []byte
+
+	// Signature is the ASN.1 DER encoded ECDSA signature
+	// of the SHA-256 hash of Attest.
+	Signature []byte
+
+	// PublicKey is the PKIX DER encoded attestation key.
+	PublicKey []byte
+
+	// PCRs are the quoted SHA-256 PCR values by PCR index.
+	PCRs map[int][]byte
+}
+
+// TPM is a TPM 2.0 connection.
+type TPM struct {
+	mu sync.Mutex
+	rw io.ReadWriter
+}
+
+// Open opens the TPM device at the given path. If path is a
+// unix socket, for example of a software TPM (swtpm), Open
+// connects to it instead. If path is empty, DefaultDevice
+// is used.
+func Open(path string) (*TPM, error) {
+	if path == "" {
+		path = DefaultDevice
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if stat.Mode()&os.ModeSocket != 0 {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		return New(conn), nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return New(f), nil
+}
+
+// New returns a TPM that sends commands to and reads
+// responses from rw.
+func New(rw io.ReadWriter) *TPM { return &TPM{rw: rw} }
+
+// Close closes the underlying connection, if it
+// implements io.Closer.
+func (t *TPM) Close() error {
+	if c, ok := t.rw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Quote returns a quote over the given SHA-256 PCRs that includes
+// data as qualifying data. Data must not be longer than 64 bytes.
+func (t *TPM) Quote(pcrs []int, data []byte) (*Quote, error) {
+	if len(pcrs) == 0 {
+		return nil, errors.New("tpm: no PCR selected")
+	}
+	if len(data) > 64 {
+		return nil, errors.New("tpm: qualifying data too large")
+	}
+	selection, err := pcrSelection(pcrs)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	handle, publicKey, err := t.createPrimary()
+	if err != nil {
+		return nil, err
+	}
+	defer t.flushContext(handle)
+
+	// PCRs may be extended between the quote and reading them.
+	// Hence, retry until the PCR values match the quoted digest.
+	const MaxAttempts = 3
+	for range MaxAttempts {
+		attest, signature, err := t.quote(handle, data, selection)
+		if err != nil {
+			return nil, err
+		}
+		values, err := t.pcrRead(pcrs)
+		if err != nil {
+			return nil, err
+		}
+
+		q := &Quote{
+			Attest:    attest,
+			Signature: signature,
+			PublicKey: publicKey,
+			PCRs:      values,
+		}
+		if _, err = Verify(q, data); err == nil {
+			return q, nil
+		}
+	}
+	return nil, errors.New("tpm: PCR values changed while quoting")
+}
+
+// Verify verifies that the quote has been signed by its attestation
+// key, contains data as qualifying data and that the quote's PCR
+// values match the quoted PCR digest. It returns the quoted PCR
+// indices.
+//
+// Verify does not verify that the attestation key belongs to a
+// genuine TPM. The caller has to trust the attestation key, for
+// example by comparing it with a known key of the server's TPM.
+func Verify(q *Quote, data []byte) ([]int, error) {
+	key, err := x509.ParsePKIXPublicKey(q.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("tpm: invalid attestation key: %v", err)
+	}
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("tpm: invalid attestation key: not an ECDSA key")
+	}
+	digest := sha256.Sum256(q.Attest)
+	if !ecdsa.VerifyASN1(pub, digest[:], q.Signature) {
+		return nil, errors.New("tpm: invalid quote signature")
+	}
+
+	r := reader{b: q.Attest}
+	magic, typ := r.u32(), r.u16()
+	r.tpm2b() // qualifiedSigner
+	extraData := r.tpm2b()
+	r.skip(17) // clockInfo
+	r.skip(8)  // firmwareVersion
+	pcrs := r.pcrSelection()
+	pcrDigest := r.tpm2b()
+	if r.err != nil {
+		return nil, fmt.Errorf("tpm: invalid quote: %v", r.err)
+	}
+	if magic != attestMagic || typ != attestQuote {
+		return nil, errors.New("tpm: invalid quote: not a TPM quote")
+	}
+	if subtle.ConstantTimeCompare(extraData, data) != 1 {
+		return nil, errors.New("tpm: quote does not contain the expected data")
+	}
+
+	if len(q.PCRs) != len(pcrs) {
+		return nil, errors.New("tpm: PCR values do not match quoted PCRs")
+	}
+	h := sha256.New()
+	for _, pcr := range pcrs {
+		value, ok := q.PCRs[pcr]
+		if !ok {
+			return nil, errors.New("tpm: PCR values do not match quoted PCRs")
+		}
+		h.Write(value)
+	}
+	if subtle.ConstantTimeCompare(h.Sum(nil), pcrDigest) != 1 {
+		return nil, errors.New("tpm: PCR values do not match quoted PCR digest")
+	}
+	return pcrs, nil
+}
+
+// createPrimary creates the attestation key in the endorsement
+// hierarchy and returns its handle and PKIX encoded public key.
+func (t *TPM) createPrimary() (uint32, []byte, error) {
+	var template bytes.Buffer
+	writeU16(&template, algECC)
+	writeU16(&template, algSHA256)
+	writeU32(&template, akAttributes)
+	writeU16(&template, 0)         // authPolicy
+	writeU16(&template, algNull)   // symmetric
+	writeU16(&template, algECDSA)  // scheme
+	writeU16(&template, algSHA256) // scheme hash
+	writeU16(&template, eccP256)
+	writeU16(&template, algNull) // kdf
+	writeU16(&template, 0)       // unique.x
+	writeU16(&template, 0)       // unique.y
+
+	var params bytes.Buffer
+	writeU16(&params, 4) // inSensitive
+	writeU16(&params, 0) // userAuth
+	writeU16(&params, 0) // data
+	writeU16(&params, uint16(template.Len()))
+	params.Write(template.Bytes())
+	writeU16(&params, 0) // outsideInfo
+	writeU32(&params, 0) // creationPCR
+
+	resp, err := t.run(tagSessions, ccCreatePrimary, []uint32{rhEndorsement}, params.Bytes())
+	if err != nil {
+		return 0, nil, err
+	}
+	r := reader{b: resp}
+	handle := r.u32()
+	r.u32() // parameterSize
+
+	r = reader{b: r.tpm2b(), err: r.err}
+	alg := r.u16()
+	r.u16() // nameAlg
+	r.u32() // objectAttributes
+	r.tpm2b()
+	if sym := r.u16(); sym != algNull {
+		r.skip(4) // keyBits and mode
+	}
+	if scheme := r.u16(); scheme != algNull {
+		r.u16()
+	}
+	curve := r.u16()
+	if kdf := r.u16(); kdf != algNull {
+		r.u16()
+	}
+	x, y := r.tpm2b(), r.tpm2b()
+	if r.err != nil {
+		return 0, nil, fmt.Errorf("tpm: invalid CreatePrimary response: %v", r.err)
+	}
+	if alg != algECC || curve != eccP256 || len(x) > 32 || len(y) > 32 {
+		return 0, nil, errors.New("tpm: invalid CreatePrimary response: not an ECC P-256 key")
+	}
+
+	point := make([]byte, 65)
+	point[0] = 4
+	copy(point[33-len(x):33], x)
+	copy(point[65-len(y):], y)
+	if _, err = ecdh.P256().NewPublicKey(point); err != nil {
+		return 0, nil, fmt.Errorf("tpm: invalid attestation key: %v", err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return handle, publicKey, nil
+}
+
+// quote runs TPM2_Quote and returns the TPMS_ATTEST structure
+// and its ASN.1 encoded signature.
+func (t *TPM) quote(handle uint32, data, selection []byte) ([]byte, []byte, error) {
+	var params bytes.Buffer
+	writeU16(&params, uint16(len(data)))
+	params.Write(data)
+	writeU16(&params, algNull) // use the scheme of the key
+	params.Write(selection)
+
+	resp, err := t.run(tagSessions, ccQuote, []uint32{handle}, params.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	r := reader{b: resp}
+	r.u32() // parameterSize
+	attest := r.tpm2b()
+	alg := r.u16()
+	r.u16() // hash
+	sigR, sigS := r.tpm2b(), r.tpm2b()
+	if r.err != nil {
+		return nil, nil, fmt.Errorf("tpm: invalid Quote response: %v", r.err)
+	}
+	if alg != algECDSA {
+		return nil, nil, errors.New("tpm: invalid Quote response: not an ECDSA signature")
+	}
+	signature, err := asn1.Marshal(struct{ R, S *big.Int }{
+		R: new(big.Int).SetBytes(sigR),
+		S: new(big.Int).SetBytes(sigS),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return slices.Clone(attest), signature, nil
+}
+
+// pcrRead returns the SHA-256 values of the given PCRs.
+func (t *TPM) pcrRead(pcrs []int) (map[int][]byte, error) {
+	values := make(map[int][]byte, len(pcrs))
+	remaining := slices.Clone(pcrs)
+	for len(remaining) > 0 {
+		selection, err := pcrSelection(remaining)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.run(tagNoSessions, ccPCRRead, nil, selection)
+		if err != nil {
+			return nil, err
+		}
+
+		// The TPM may return fewer PCRs than requested.
+		r := reader{b: resp}
+		r.u32() // pcrUpdateCounter
+		selected := r.pcrSelection()
+		n := r.u32()
+		if r.err == nil && int(n) != len(selected) {
+			return nil, errors.New("tpm: invalid PCR_Read response: digest count mismatch")
+		}
+		for _, pcr := range selected {
+			values[pcr] = slices.Clone(r.tpm2b())
+		}
+		if r.err != nil {
+			return nil, fmt.Errorf("tpm: invalid PCR_Read response: %v", r.err)
+		}
+		if len(selected) == 0 {
+			return nil, errors.New("tpm: invalid PCR_Read response: no PCR returned")
+		}
+		remaining = slices.DeleteFunc(remaining, func(pcr int) bool {
+			_, ok := values[pcr]
+			return ok
+		})
+	}
+	return values, nil
+}
+
+// flushContext removes the transient object from the TPM.
+func (t *TPM) flushContext(handle uint32) error {
+	var params bytes.Buffer
+	writeU32(&params, handle)
+	_, err := t.run(tagNoSessions, ccFlushContext, nil, params.Bytes())
+	return err
+}
+
+// run sends a command to the TPM and returns the response
+// following the response header. Commands tagged with sessions
+// are authorized with an empty password for each handle.
+func (t *TPM) run(tag uint16, cc uint32, handles []uint32, params []byte) ([]byte, error) {
+	var cmd bytes.Buffer
+	writeU16(&cmd, tag)
+	writeU32(&cmd, 0) // Size, set below
+	writeU32(&cmd, cc)
+	for _, h := range handles {
+		writeU32(&cmd, h)
+	}
+	if tag == tagSessions {
+		const AuthSize = 9 // handle, empty nonce, attributes, empty HMAC
+		writeU32(&cmd, AuthSize*uint32(len(handles)))
+		for range handles {
+			writeU32(&cmd, rsPassword)
+			writeU16(&cmd, 0)
+			cmd.WriteByte(0)
+			writeU16(&cmd, 0)
+		}
+	}
+	cmd.Write(params)
+	b := cmd.Bytes()
+	binary.BigEndian.PutUint32(b[2:], uint32(len(b)))
+
+	if _, err := t.rw.Write(b); err != nil {
+		return nil, err
+	}
+
+	// A TPM device has to be read in a single read call
+	// while a socket may return partial responses.
+	resp := make([]byte, maxResponseSize)
+	n, err := t.rw.Read(resp)
+	if err != nil {
+		return nil, err
+	}
+	if n < 10 {
+		if _, err = io.ReadFull(t.rw, resp[n:10]); err != nil {
+			return nil, err
+		}
+		n = 10
+	}
+	size := int(binary.BigEndian.Uint32(resp[2:]))
+	if size < 10 || size > maxResponseSize {
+		return nil, fmt.Errorf("tpm: invalid response size %d", size)
+	}
+	if n < size {
+		if _, err = io.ReadFull(t.rw, resp[n:size]); err != nil {
+			return nil, err
+		}
+	}
+	if rc := binary.BigEndian.Uint32(resp[6:]); rc != 0 {
+		return nil, fmt.Errorf("tpm: command 0x%x failed with response code 0x%x", cc, rc)
+	}
+	return resp[10:size], nil
+}
+
+// pcrSelection returns the TPML_PCR_SELECTION of the given
+// SHA-256 PCRs.
+func pcrSelection(pcrs []int) ([]byte, error) {
+	var bitmap [3]byte
+	for _, pcr := range pcrs {
+		if pcr < 0 || pcr > MaxPCR {
+			return nil, fmt.Errorf("tpm: invalid PCR %d", pcr)
+		}
+		bitmap[pcr/8] |= 1 << (pcr % 8)
+	}
+
+	var b bytes.Buffer
+	writeU32(&b, 1)
+	writeU16(&b, algSHA256)
+	b.WriteByte(byte(len(bitmap)))
+	b.Write(bitmap[:])
+	return b.Bytes(), nil
+}
+
+func writeU16(b *bytes.Buffer, v uint16) { b.Write(binary.BigEndian.AppendUint16(nil, v)) }
+
+func writeU32(b *bytes.Buffer, v uint32) { b.Write(binary.BigEndian.AppendUint32(nil, v)) }
+
+// reader decodes TPM structures. Once an error occurred, all
+// subsequent reads return zero values.
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.b) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *reader) skip(n int) { r.bytes(n) }
+
+func (r *reader) u8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) u32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) tpm2b() []byte { return r.bytes(int(r.u16())) }
+
+// pcrSelection decodes a TPML_PCR_SELECTION and returns the
+// selected SHA-256 PCRs in ascending order.
+func (r *reader) pcrSelection() []int {
+	var pcrs []int
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		hash := r.u16()
+		bitmap := r.bytes(int(r.u8()))
+		if hash != algSHA256 {
+			if slices.ContainsFunc(bitmap, func(b byte) bool { return b != 0 }) {
+				r.err = errors.New("unsupported PCR bank")
+			}
+			continue
+		}
+		for j, b := range bitmap {
+			for k := range 8 {
+				if b&(1<<k) != 0 {
+					pcrs = append(pcrs, 8*j+k)
+				}
+			}
+		}
+	}
+	return pcrs
+}
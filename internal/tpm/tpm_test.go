@@ -0,0 +1,105 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package tpm_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"net"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/minio/kes/internal/tpm"
+	"github.com/minio/kes/internal/tpm/tpmtest"
+)
+
+func TestQuote(t *testing.T) {
+	sim, err := tpmtest.NewSimulator()
+	if err != nil {
+		t.Fatalf("Failed to create TPM simulator: %v", err)
+	}
+	for pcr := range tpm.MaxPCR + 1 {
+		sim.Extend(pcr, []byte{byte(pcr)})
+	}
+
+	device := tpm.New(sim.Open())
+	for i, test := range quoteTests {
+		data := sha256.Sum256([]byte(test.Data))
+		quote, err := device.Quote(test.PCRs, data[:])
+		if err != nil {
+			t.Fatalf("Test %d: failed to create quote: %v", i, err)
+		}
+
+		pcrs, err := tpm.Verify(quote, data[:])
+		if err != nil {
+			t.Fatalf("Test %d: failed to verify quote: %v", i, err)
+		}
+		if !slices.Equal(pcrs, test.Quoted) {
+			t.Fatalf("Test %d: got PCRs '%v' - want '%v'", i, pcrs, test.Quoted)
+		}
+
+		other := sha256.Sum256([]byte("other data"))
+		if _, err = tpm.Verify(quote, other[:]); err == nil {
+			t.Fatalf("Test %d: verified quote with other qualifying data", i)
+		}
+		pcr := test.Quoted[0]
+		quote.PCRs[pcr] = bytes.Repeat([]byte{0xff}, sha256.Size)
+		if _, err = tpm.Verify(quote, data[:]); err == nil {
+			t.Fatalf("Test %d: verified quote with modified PCR %d", i, pcr)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	sim, err := tpmtest.NewSimulator()
+	if err != nil {
+		t.Fatalf("Failed to create TPM simulator: %v", err)
+	}
+	socket := filepath.Join(t.TempDir(), "tpm.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to listen on unix socket: %v", err)
+	}
+	defer ln.Close()
+	go sim.Serve(ln)
+
+	device, err := tpm.Open(socket)
+	if err != nil {
+		t.Fatalf("Failed to open TPM: %v", err)
+	}
+	defer device.Close()
+
+	data := []byte("nonce")
+	quote, err := device.Quote([]int{0, 7}, data)
+	if err != nil {
+		t.Fatalf("Failed to create quote: %v", err)
+	}
+	if _, err = tpm.Verify(quote, data); err != nil {
+		t.Fatalf("Failed to verify quote: %v", err)
+	}
+}
+
+var quoteTests = []struct {
+	Data   string
+	PCRs   []int
+	Quoted []int
+}{
+	{ // 0
+		Data:   "my-data",
+		PCRs:   []int{0},
+		Quoted: []int{0},
+	},
+	{ // 1
+		Data:   "my-data",
+		PCRs:   []int{7, 0, 1, 2, 3, 4, 5, 6},
+		Quoted: []int{0, 1, 2, 3, 4, 5, 6, 7},
+	},
+	{ // 2
+		Data:   "",
+		PCRs:   []int{0, 1, 2, 3, 4, 5, 6, 7, 10, 14, 23},
+		Quoted: []int{0, 1, 2, 3, 4, 5, 6, 7, 10, 14, 23},
+	},
+}
@@ -0,0 +1,312 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package tpmtest provides a TPM 2.0 simulator for testing.
+package tpmtest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+)
+
+const (
+	rcSuccess = 0x000
+	rcFailure = 0x101
+	rcCommand = 0x143
+
+	akHandle = 0x80000000
+)
+
+// Simulator simulates the subset of TPM 2.0 commands used by
+// the tpm package: CreatePrimary, Quote, PCR_Read and
+// FlushContext. It returns at most 8 PCR values per PCR_Read
+// command, like many hardware TPMs.
+type Simulator struct {
+	mu   sync.Mutex
+	key  *ecdsa.PrivateKey
+	pcrs [24][sha256.Size]byte
+}
+
+// NewSimulator returns a new Simulator with a random
+// attestation key and all PCRs set to zero.
+func NewSimulator() (*Simulator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Simulator{key: key}, nil
+}
+
+// Extend extends the PCR with the given SHA-256 digest.
+func (s *Simulator) Extend(pcr int, digest []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := sha256.New()
+	h.Write(s.pcrs[pcr][:])
+	h.Write(digest)
+	h.Sum(s.pcrs[pcr][:0])
+}
+
+// Open returns a new connection to the simulator.
+func (s *Simulator) Open() io.ReadWriteCloser { return &conn{sim: s} }
+
+// Serve accepts connections on ln and executes the received
+// TPM commands, like a software TPM listening on a unix socket.
+// It returns when ln is closed.
+func (s *Simulator) Serve(ln net.Listener) error {
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer c.Close()
+
+			header := make([]byte, 10)
+			for {
+				if _, err := io.ReadFull(c, header); err != nil {
+					return
+				}
+				size := int(binary.BigEndian.Uint32(header[2:]))
+				if size < len(header) {
+					return
+				}
+				cmd := make([]byte, size)
+				copy(cmd, header)
+				if _, err := io.ReadFull(c, cmd[len(header):]); err != nil {
+					return
+				}
+				if _, err := c.Write(s.Execute(cmd)); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Execute executes the TPM command and returns the response.
+func (s *Simulator) Execute(cmd []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(cmd) < 10 {
+		return response(rcFailure, nil)
+	}
+	tag := binary.BigEndian.Uint16(cmd)
+	cc := binary.BigEndian.Uint32(cmd[6:])
+	body := cmd[10:]
+
+	switch cc {
+	case 0x131: // CreatePrimary
+		if len(body) < 4 || binary.BigEndian.Uint32(body) != 0x4000000B { // Endorsement hierarchy
+			return response(rcFailure, nil)
+		}
+		if _, ok := skipAuth(tag, body[4:], 1); !ok {
+			return response(rcFailure, nil)
+		}
+		return s.createPrimary()
+	case 0x158: // Quote
+		if len(body) < 4 || binary.BigEndian.Uint32(body) != akHandle {
+			return response(rcFailure, nil)
+		}
+		body, ok := skipAuth(tag, body[4:], 1)
+		if !ok {
+			return response(rcFailure, nil)
+		}
+		return s.quote(body)
+	case 0x17E: // PCR_Read
+		return s.pcrRead(body)
+	case 0x165: // FlushContext
+		return response(rcSuccess, nil)
+	default:
+		return response(rcCommand, nil)
+	}
+}
+
+func (s *Simulator) createPrimary() []byte {
+	var public bytes.Buffer
+	for _, v := range []uint16{0x0023, 0x000B} { // ECC, SHA-256
+		writeU16(&public, v)
+	}
+	writeU32(&public, 1<<1|1<<4|1<<5|1<<6|1<<16|1<<18)
+	for _, v := range []uint16{0, 0x0010, 0x0018, 0x000B, 0x0003, 0x0010} {
+		writeU16(&public, v)
+	}
+	writeTPM2B(&public, s.key.X.FillBytes(make([]byte, 32)))
+	writeTPM2B(&public, s.key.Y.FillBytes(make([]byte, 32)))
+
+	var params bytes.Buffer
+	writeTPM2B(&params, public.Bytes())
+
+	var resp bytes.Buffer
+	writeU32(&resp, akHandle)
+	writeU32(&resp, uint32(params.Len()))
+	resp.Write(params.Bytes())
+	return response(rcSuccess, resp.Bytes())
+}
+
+func (s *Simulator) quote(body []byte) []byte {
+	data, body, ok := readTPM2B(body)
+	if !ok || len(body) < 2 || binary.BigEndian.Uint16(body) != 0x0010 {
+		return response(rcFailure, nil)
+	}
+	selection := body[2:]
+	pcrs, ok := parseSelection(selection)
+	if !ok {
+		return response(rcFailure, nil)
+	}
+
+	h := sha256.New()
+	for _, pcr := range pcrs {
+		h.Write(s.pcrs[pcr][:])
+	}
+
+	var attest bytes.Buffer
+	writeU32(&attest, 0xff544347)
+	writeU16(&attest, 0x8018)
+	writeTPM2B(&attest, nil) // qualifiedSigner
+	writeTPM2B(&attest, data)
+	attest.Write(make([]byte, 17+8)) // clockInfo and firmwareVersion
+	attest.Write(selection)
+	writeTPM2B(&attest, h.Sum(nil))
+
+	digest := sha256.Sum256(attest.Bytes())
+	der, err := ecdsa.SignASN1(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return response(rcFailure, nil)
+	}
+	var sig struct{ R, S *big.Int }
+	if _, err = asn1.Unmarshal(der, &sig); err != nil {
+		return response(rcFailure, nil)
+	}
+
+	var params bytes.Buffer
+	writeTPM2B(&params, attest.Bytes())
+	writeU16(&params, 0x0018) // ECDSA
+	writeU16(&params, 0x000B) // SHA-256
+	writeTPM2B(&params, sig.R.Bytes())
+	writeTPM2B(&params, sig.S.Bytes())
+
+	var resp bytes.Buffer
+	writeU32(&resp, uint32(params.Len()))
+	resp.Write(params.Bytes())
+	return response(rcSuccess, resp.Bytes())
+}
+
+func (s *Simulator) pcrRead(body []byte) []byte {
+	pcrs, ok := parseSelection(body)
+	if !ok {
+		return response(rcFailure, nil)
+	}
+	if len(pcrs) > 8 {
+		pcrs = pcrs[:8]
+	}
+
+	var bitmap [3]byte
+	for _, pcr := range pcrs {
+		bitmap[pcr/8] |= 1 << (pcr % 8)
+	}
+	var resp bytes.Buffer
+	writeU32(&resp, 0) // pcrUpdateCounter
+	writeU32(&resp, 1)
+	writeU16(&resp, 0x000B)
+	resp.WriteByte(byte(len(bitmap)))
+	resp.Write(bitmap[:])
+	writeU32(&resp, uint32(len(pcrs)))
+	for _, pcr := range pcrs {
+		writeTPM2B(&resp, s.pcrs[pcr][:])
+	}
+	return response(rcSuccess, resp.Bytes())
+}
+
+// parseSelection parses a TPML_PCR_SELECTION with
+// a single SHA-256 bank.
+func parseSelection(b []byte) ([]int, bool) {
+	if len(b) < 7 || binary.BigEndian.Uint32(b) != 1 || binary.BigEndian.Uint16(b[4:]) != 0x000B {
+		return nil, false
+	}
+	size := int(b[6])
+	if len(b) < 7+size || size > 3 {
+		return nil, false
+	}
+	var pcrs []int
+	for i, v := range b[7 : 7+size] {
+		for j := range 8 {
+			if v&(1<<j) != 0 {
+				pcrs = append(pcrs, 8*i+j)
+			}
+		}
+	}
+	return pcrs, true
+}
+
+// skipAuth skips the authorization area of a command with
+// n password sessions.
+func skipAuth(tag uint16, b []byte, n int) ([]byte, bool) {
+	if tag != 0x8002 {
+		return nil, false
+	}
+	if len(b) < 4 || int(binary.BigEndian.Uint32(b)) != 9*n || len(b) < 4+9*n {
+		return nil, false
+	}
+	return b[4+9*n:], true
+}
+
+func response(rc uint32, body []byte) []byte {
+	var b bytes.Buffer
+	writeU16(&b, 0x8001) // Response tags are not checked by the tpm package
+	writeU32(&b, uint32(10+len(body)))
+	writeU32(&b, rc)
+	b.Write(body)
+	return b.Bytes()
+}
+
+func readTPM2B(b []byte) ([]byte, []byte, bool) {
+	if len(b) < 2 {
+		return nil, nil, false
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return nil, nil, false
+	}
+	return b[2 : 2+n], b[2+n:], true
+}
+
+func writeTPM2B(b *bytes.Buffer, v []byte) {
+	writeU16(b, uint16(len(v)))
+	b.Write(v)
+}
+
+func writeU16(b *bytes.Buffer, v uint16) { b.Write(binary.BigEndian.AppendUint16(nil, v)) }
+
+func writeU32(b *bytes.Buffer, v uint32) { b.Write(binary.BigEndian.AppendUint32(nil, v)) }
+
+// conn is an in-memory connection to a Simulator.
+type conn struct {
+	sim  *Simulator
+	resp bytes.Buffer
+}
+
+func (c *conn) Write(cmd []byte) (int, error) {
+	c.resp.Write(c.sim.Execute(cmd))
+	return len(cmd), nil
+}
+
+func (c *conn) Read(b []byte) (int, error) { return c.resp.Read(b) }
+
+func (c *conn) Close() error { return nil }
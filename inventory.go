@@ -0,0 +1,133 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kms-go/kes"
+)
+
+// keyInventory returns a report of all keys, including their algorithm,
+// creation and last usage, as JSON or CSV. Clients select CSV either via
+// the "format=csv" query parameter or by accepting "text/csv" responses.
+//
+// KES keys are immutable. A key gets rotated by creating a new key.
+// Hence, a key's last rotation is its creation. The owner of unowned
+// keys is the identity that created them and their contact is empty.
+func (s *Server) keyInventory(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	if req.Identity != state.Admin {
+		resp.Fail(http.StatusForbidden, "key inventory API requires the admin identity")
+		return
+	}
+
+	var asCSV bool
+	switch format := req.URL.Query().Get("format"); format {
+	case "":
+		asCSV = headers.Accepts(req.Header, headers.ContentTypeCSV)
+	case "json":
+	case "csv":
+		asCSV = true
+	default:
+		resp.Failf(http.StatusBadRequest, "invalid inventory format '%s': expected 'json' or 'csv'", format)
+		return
+	}
+
+	names, _, err := state.Keys.List(req.Context(), "", -1)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to list keys")
+		return
+	}
+	slices.Sort(names)
+
+	inventory := api.KeyInventoryResponse{
+		Keys:         make([]api.KeyInventoryEntry, 0, len(names)),
+		GeneratedAt:  time.Now().UTC(),
+		TrackedSince: state.Usage.Since(),
+	}
+	for _, name := range names {
+		key, err := state.Keys.Get(req.Context(), name)
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			continue // The key has been deleted in the meantime
+		}
+		if err != nil {
+			if err, ok := api.IsError(err); ok {
+				resp.Failr(err)
+				return
+			}
+
+			state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+			resp.Fail(http.StatusBadGateway, "failed to read key")
+			return
+		}
+
+		owner, err := loadKeyOwner(req.Context(), state.Keys, name)
+		if err != nil {
+			state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+			resp.Fail(http.StatusBadGateway, "failed to read key owner")
+			return
+		}
+
+		entry := api.KeyInventoryEntry{
+			Name:        name,
+			Algorithm:   key.Key.Type().String(),
+			CreatedAt:   key.CreatedAt,
+			LastRotated: key.CreatedAt,
+			LastUsed:    state.Usage.KeyLastUsed(name),
+			Owner:       key.CreatedBy.String(),
+		}
+		if owner != nil {
+			entry.Owner, entry.Contact = owner.Owner, owner.Contact
+		}
+		inventory.Keys = append(inventory.Keys, entry)
+	}
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log("key inventory exported", StatusOK, req)
+	if !asCSV {
+		api.ReplyWith(resp, StatusOK, inventory)
+		return
+	}
+
+	resp.Header().Set(headers.ContentType, headers.ContentTypeCSV)
+	resp.WriteHeader(StatusOK)
+
+	w := csv.NewWriter(resp)
+	w.Write([]string{"name", "algorithm", "created_at", "last_rotated", "last_used", "owner", "contact"})
+	for _, key := range inventory.Keys {
+		w.Write([]string{
+			key.Name,
+			key.Algorithm,
+			formatInventoryTime(key.CreatedAt),
+			formatInventoryTime(key.LastRotated),
+			formatInventoryTime(key.LastUsed),
+			key.Owner,
+			key.Contact,
+		})
+	}
+	w.Flush()
+}
+
+// formatInventoryTime formats t as RFC 3339 timestamp,
+// or returns the empty string if t is the zero time.
+func formatInventoryTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,507 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+// Job states.
+const (
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+	jobCanceled  = "canceled"
+)
+
+// A jobFunc implements a long-running operation. It should
+// report its progress to the job and return once ctx is done.
+type jobFunc func(ctx context.Context, state *serverState, j *job) error
+
+// jobKinds contains all operations that can be started
+// as job via the API.
+var jobKinds = map[string]jobFunc{
+	"backup":       backupKeys,
+	"cache-warmup": warmCache,
+	"migrate":      migrateKeys,
+	"rewrap":       rewrapKeys,
+	"scrub":        scrubKeys,
+	"split-repair": repairSplitKeys,
+}
+
+// A job is a long-running operation executed in the background.
+// It is not bound to the request that started it. Hence, a job
+// keeps running when the client disconnects.
+type job struct {
+	ID        string
+	Kind      string
+	CreatedAt time.Time
+	CreatedBy kes.Identity
+
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	state      string
+	finishedAt time.Time
+	err        string
+	done       int
+	total      int
+	errors     []string
+}
+
+// SetTotal sets the total number of items the job processes.
+func (j *job) SetTotal(n int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.total = n
+}
+
+// Progress marks one more item as processed.
+func (j *job) Progress() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.done++
+}
+
+// Report records a non-fatal error. Only the first
+// errors are kept to limit the job's memory usage.
+func (j *job) Report(msg string) {
+	const MaxErrors = 100
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.errors) < MaxErrors {
+		j.errors = append(j.errors, msg)
+	}
+}
+
+// IsRunning reports whether the job has not finished yet.
+func (j *job) IsRunning() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state == jobRunning
+}
+
+// Status returns the job's current status.
+func (j *job) Status() api.JobResponse {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return api.JobResponse{
+		ID:         j.ID,
+		Kind:       j.Kind,
+		State:      j.state,
+		CreatedAt:  j.CreatedAt,
+		CreatedBy:  j.CreatedBy.String(),
+		FinishedAt: j.finishedAt,
+		Done:       j.done,
+		Total:      j.total,
+		Errors:     slices.Clone(j.errors),
+		Error:      j.err,
+	}
+}
+
+func (j *job) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.finishedAt = time.Now().UTC()
+	switch {
+	case err == nil:
+		j.state = jobSucceeded
+	case errors.Is(err, context.Canceled):
+		j.state = jobCanceled
+	default:
+		j.state = jobFailed
+		j.err = err.Error()
+	}
+}
+
+// jobManager runs jobs and keeps track of the most
+// recent ones.
+type jobManager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+// Limits for jobs. At most maxRunningJobs can run concurrently.
+// The manager retains up to maxJobs jobs, including finished ones.
+const (
+	maxRunningJobs = 4
+	maxJobs        = 100
+)
+
+// newJobManager returns a new jobManager. Its jobs get
+// canceled once the jobManager is closed.
+func newJobManager() *jobManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobManager{
+		ctx:    ctx,
+		cancel: cancel,
+		jobs:   make(map[string]*job),
+	}
+}
+
+// Start starts a new job that executes f in the background.
+// It returns an error if too many jobs are running already.
+func (m *jobManager) Start(kind string, createdBy kes.Identity, state *serverState, f jobFunc) (*job, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ctx.Err() != nil {
+		return nil, api.NewError(http.StatusServiceUnavailable, "server is closed")
+	}
+
+	var running int
+	for _, j := range m.jobs {
+		if j.IsRunning() {
+			running++
+		}
+	}
+	if running >= maxRunningJobs {
+		return nil, api.NewError(http.StatusTooManyRequests, "too many jobs are running")
+	}
+	m.prune()
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	j := &job{
+		ID:        hex.EncodeToString(id[:]),
+		Kind:      kind,
+		CreatedAt: time.Now().UTC(),
+		CreatedBy: createdBy,
+		cancel:    cancel,
+		state:     jobRunning,
+	}
+	m.jobs[j.ID] = j
+
+	go func() {
+		defer cancel()
+		j.finish(f(ctx, state, j))
+	}()
+	return j, nil
+}
+
+// Get returns the job with the given ID, if any.
+func (m *jobManager) Get(id string) (*job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	return j, ok
+}
+
+// List returns all jobs ordered by creation time.
+func (m *jobManager) List() []*job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]*job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j)
+	}
+	slices.SortFunc(jobs, func(a, b *job) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return jobs
+}
+
+// Close cancels all running jobs.
+func (m *jobManager) Close() {
+	if m != nil {
+		m.cancel()
+	}
+}
+
+// prune removes the oldest finished jobs once the
+// manager keeps track of maxJobs jobs.
+func (m *jobManager) prune() {
+	if len(m.jobs) < maxJobs {
+		return
+	}
+
+	finished := make([]*job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		if !j.IsRunning() {
+			finished = append(finished, j)
+		}
+	}
+	slices.SortFunc(finished, func(a, b *job) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	for _, j := range finished[:min(len(finished), len(m.jobs)-maxJobs+1)] {
+		delete(m.jobs, j.ID)
+	}
+}
+
+// scrubKeys reads every key from the key store, bypassing
+// the cache, and reports keys that cannot be read or parsed.
+func scrubKeys(ctx context.Context, state *serverState, j *job) error {
+	names, _, err := state.Keys.List(ctx, "", -1)
+	if err != nil {
+		return err
+	}
+
+	j.SetTotal(len(names))
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := state.Keys.Verify(ctx, name); err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+			j.Report(fmt.Sprintf("key '%s': %v", name, err))
+		}
+		j.Progress()
+	}
+	return nil
+}
+
+// warmupBatchSize is the number of keys the cache-warmup
+// job fetches from the key store at once.
+const warmupBatchSize = 100
+
+// warmCache loads all keys that are not cached yet from the key
+// store into the cache. It uses bulk reads if the key store
+// supports them.
+func warmCache(ctx context.Context, state *serverState, j *job) error {
+	names, _, err := state.Keys.List(ctx, "", -1)
+	if err != nil {
+		return err
+	}
+
+	j.SetTotal(len(names))
+	for batch := range slices.Chunk(names, warmupBatchSize) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := state.Keys.Warm(ctx, batch); err != nil {
+			j.Report(fmt.Sprintf("keys '%s' - '%s': %v", batch[0], batch[len(batch)-1], err))
+		}
+		for range batch {
+			j.Progress()
+		}
+	}
+	return nil
+}
+
+func (s *Server) startJob(resp *api.Response, req *api.Request) {
+	f, ok := jobKinds[req.Resource]
+	if !ok {
+		resp.Failf(http.StatusBadRequest, "job kind '%s' is not supported", req.Resource)
+		return
+	}
+
+	state := s.state.Load()
+	j, err := state.Jobs.Start(req.Resource, req.Identity, state, f)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to start job")
+		return
+	}
+
+	state.Audit.Log(
+		fmt.Sprintf("job '%s' (%s) started", j.ID, j.Kind),
+		http.StatusAccepted,
+		req,
+	)
+	api.ReplyWith(resp, http.StatusAccepted, j.Status())
+}
+
+func (s *Server) describeJob(resp *api.Response, req *api.Request) {
+	j, ok := s.state.Load().Jobs.Get(req.Resource)
+	if !ok {
+		resp.Failf(http.StatusNotFound, "job '%s' does not exist", req.Resource)
+		return
+	}
+	api.ReplyWith(resp, http.StatusOK, j.Status())
+}
+
+func (s *Server) listJobs(resp *api.Response, req *api.Request) {
+	jobs := s.state.Load().Jobs.List()
+
+	list := api.ListJobsResponse{
+		Jobs: make([]api.JobResponse, 0, len(jobs)),
+	}
+	for _, j := range jobs {
+		list.Jobs = append(list.Jobs, j.Status())
+	}
+	api.ReplyWith(resp, http.StatusOK, list)
+}
+
+func (s *Server) cancelJob(resp *api.Response, req *api.Request) {
+	j, ok := s.state.Load().Jobs.Get(req.Resource)
+	if !ok {
+		resp.Failf(http.StatusNotFound, "job '%s' does not exist", req.Resource)
+		return
+	}
+	j.cancel()
+
+	const StatusOK = http.StatusOK
+	s.state.Load().Audit.Log(
+		fmt.Sprintf("job '%s' (%s) canceled", j.ID, j.Kind),
+		StatusOK,
+		req,
+	)
+	resp.Reply(StatusOK)
+}
+
+// rawKeyStore returns the configured KeyStore without the deduplication,
+// compression, sealing, tenant and chunking wrappers. Its entries are still
+// deduplicated, compressed, sealed, wrapped or split into chunks.
+func rawKeyStore(state *serverState) KeyStore {
+	store := state.Keys.store
+	if s, ok := store.(*dedupStore); ok {
+		store = s.KeyStore
+	}
+	if s, ok := store.(*compressedStore); ok {
+		store = s.KeyStore
+	}
+	if s, ok := store.(*tenantStore); ok {
+		store = s.KeyStore
+	}
+	if s, ok := store.(*sealedStore); ok {
+		store = s.KeyStore
+	}
+	if s, ok := store.(*chunkedStore); ok {
+		store = s.KeyStore
+	}
+	return store
+}
+
+// migrateKeys copies all key store entries to the migration
+// target. Entries that exist at the target already are not
+// overwritten but reported.
+func migrateKeys(ctx context.Context, state *serverState, j *job) error {
+	if state.JobTargets == nil || state.JobTargets.MigrationTarget == nil {
+		return api.NewError(http.StatusBadRequest, "no migration target key store configured")
+	}
+	return copyKeys(ctx, rawKeyStore(state), state.JobTargets.MigrationTarget, j, func(ctx context.Context, dst KeyStore, name string, value []byte) error {
+		return dst.Create(ctx, name, value)
+	})
+}
+
+// backupKeys copies all key store entries to the backup
+// target. It overwrites entries that exist at the target.
+func backupKeys(ctx context.Context, state *serverState, j *job) error {
+	if state.JobTargets == nil || state.JobTargets.BackupTarget == nil {
+		return api.NewError(http.StatusBadRequest, "no backup target key store configured")
+	}
+	return copyKeys(ctx, rawKeyStore(state), state.JobTargets.BackupTarget, j, setEntry)
+}
+
+// copyKeys copies all entries from src to dst using put and
+// reports entries that cannot be copied.
+func copyKeys(ctx context.Context, src, dst KeyStore, j *job, put func(context.Context, KeyStore, string, []byte) error) error {
+	names, _, err := src.List(ctx, "", -1)
+	if err != nil {
+		return err
+	}
+
+	j.SetTotal(len(names))
+	for batch := range slices.Chunk(names, warmupBatchSize) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		values, err := getBulk(ctx, src, batch)
+		if err != nil {
+			j.Report(fmt.Sprintf("keys '%s' - '%s': %v", batch[0], batch[len(batch)-1], err))
+			for range batch {
+				j.Progress()
+			}
+			continue
+		}
+		for _, name := range batch {
+			if value, ok := values[name]; ok { // Entries deleted in the meantime are skipped
+				if err := put(ctx, dst, name, value); err != nil {
+					j.Report(fmt.Sprintf("key '%s': %v", name, err))
+				}
+			}
+			j.Progress()
+		}
+	}
+	return nil
+}
+
+// rewrapKeys re-writes every key store entry such that it gets
+// compressed, sealed and wrapped with the current tenant KEK. It
+// seals entries created before sealing got enabled and wraps entries
+// created before a tenant got configured.
+//
+// Entries are replaced with conditional writes. Hence, rewrapping
+// never overwrites keys modified concurrently.
+func rewrapKeys(ctx context.Context, state *serverState, j *job) error {
+	store := state.Keys.store
+	if !canSwap(store) {
+		return api.NewError(http.StatusNotImplemented, "key store does not support conditional writes")
+	}
+	names, _, err := store.List(ctx, "", -1)
+	if err != nil {
+		return err
+	}
+
+	j.SetTotal(len(names))
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		value, err := store.Get(ctx, name)
+		if err == nil {
+			err = swapEntry(ctx, store, name, value, value)
+		}
+		if err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+			j.Report(fmt.Sprintf("key '%s': %v", name, err))
+		}
+		j.Progress()
+	}
+	return nil
+}
+
+// repairSplitKeys re-splits every entry of a SplitKeyStore with new
+// random shares and removes orphaned shares. It should be run once
+// a backend of the SplitKeyStore has been recovered.
+func repairSplitKeys(ctx context.Context, state *serverState, j *job) error {
+	split, ok := rawKeyStore(state).(*SplitKeyStore)
+	if !ok {
+		return api.NewError(http.StatusBadRequest, "key store is not a split key store")
+	}
+
+	names, err := split.names(ctx)
+	if err != nil {
+		return err
+	}
+
+	j.SetTotal(len(names))
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		resplit, err := split.repair(ctx, name, time.Now())
+		if err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+			j.Report(fmt.Sprintf("entry '%s': %v", name, err))
+		} else if !resplit && err == nil {
+			j.Report(fmt.Sprintf("entry '%s': cannot be reconstructed: missing share", name))
+		}
+		j.Progress()
+	}
+	return nil
+}
@@ -5,7 +5,9 @@
 package kesconf
 
 import (
+	"crypto/ed25519"
 	"crypto/tls"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -14,6 +16,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/minio/kes/internal/keystore/azure"
 	"github.com/minio/kms-go/kes"
 	"gopkg.in/yaml.v3"
 )
@@ -40,14 +43,256 @@ type ymlFile struct {
 				ClientCert env[string] `yaml:"cert"`
 			} `yaml:"header"`
 		} `yaml:"proxy"`
+
+		Session struct {
+			Tickets           env[string]        `yaml:"tickets"`
+			TicketKeyRotation env[time.Duration] `yaml:"ticket_key_rotation"`
+			CacheSize         env[int]           `yaml:"cache_size"`
+		} `yaml:"session"`
 	} `yaml:"tls"`
 
 	Policies map[string]struct {
 		Allow      []string            `yaml:"allow"`
 		Deny       []string            `yaml:"deny"`
 		Identities []env[kes.Identity] `yaml:"identities"`
+		Geo        *struct {
+			Paths      []string `yaml:"paths"`
+			Countries  []string `yaml:"countries"`
+			Continents []string `yaml:"continents"`
+		} `yaml:"geo"`
 	} `yaml:"policy"`
 
+	GeoIP struct {
+		Database env[string] `yaml:"database"`
+	} `yaml:"geoip"`
+
+	Databases map[string]struct {
+		Driver     env[string]        `yaml:"driver"`
+		DSN        env[string]        `yaml:"dsn"`
+		Dialect    env[string]        `yaml:"dialect"`
+		Creation   []string           `yaml:"creation"`
+		Revocation []string           `yaml:"revocation"`
+		TTL        env[time.Duration] `yaml:"ttl"`
+		MaxTTL     env[time.Duration] `yaml:"max_ttl"`
+	} `yaml:"database"`
+
+	SSH map[string]struct {
+		Type       env[string]        `yaml:"type"`
+		Principals []string           `yaml:"principals"`
+		Extensions map[string]string  `yaml:"extensions"`
+		TTL        env[time.Duration] `yaml:"ttl"`
+		MaxTTL     env[time.Duration] `yaml:"max_ttl"`
+	} `yaml:"ssh"`
+
+	PKI struct {
+		URL   env[string] `yaml:"url"`
+		Roles map[string]struct {
+			Domains  []string           `yaml:"domains"`
+			AllowIPs env[bool]          `yaml:"allow_ip"`
+			Server   env[bool]          `yaml:"server"`
+			Client   env[bool]          `yaml:"client"`
+			TTL      env[time.Duration] `yaml:"ttl"`
+			MaxTTL   env[time.Duration] `yaml:"max_ttl"`
+		} `yaml:"roles"`
+		Renewal struct {
+			Enabled env[bool]          `yaml:"enabled"`
+			Window  env[time.Duration] `yaml:"window"`
+			TTL     env[time.Duration] `yaml:"ttl"`
+		} `yaml:"renewal"`
+	} `yaml:"pki"`
+
+	Tokenization map[string]struct {
+		Mode     env[string] `yaml:"mode"`
+		Alphabet env[string] `yaml:"alphabet"`
+		Reveal   struct {
+			First env[int]    `yaml:"first"`
+			Last  env[int]    `yaml:"last"`
+			Mask  env[string] `yaml:"mask"`
+		} `yaml:"reveal"`
+	} `yaml:"tokenization"`
+
+	Folders map[string]struct {
+		Grants map[string][]string    `yaml:"grants"`
+		Tags   map[string]env[string] `yaml:"tags"`
+	} `yaml:"folders"`
+
+	KeyLifecycle struct {
+		Notice env[time.Duration] `yaml:"notice"`
+		Sink   env[string]        `yaml:"sink"`
+		Keys   map[string]struct {
+			DecryptOnly env[time.Time] `yaml:"decrypt_only"`
+			Disable     env[time.Time] `yaml:"disable"`
+			Purge       env[time.Time] `yaml:"purge"`
+		} `yaml:"keys"`
+	} `yaml:"key_lifecycle"`
+
+	KeyOwnership struct {
+		Required env[bool] `yaml:"required"`
+	} `yaml:"key_ownership"`
+
+	Tenants map[string]struct {
+		Prefix   env[string]  `yaml:"prefix"`
+		KeyStore *ymlKeyStore `yaml:"keystore"`
+	} `yaml:"tenant"`
+
+	LoadShedding struct {
+		MaxRequests env[int] `yaml:"max_requests"`
+	} `yaml:"load_shedding"`
+
+	AnomalyDetection struct {
+		Enabled   env[bool]          `yaml:"enabled"`
+		Interval  env[time.Duration] `yaml:"interval"`
+		Sink      env[string]        `yaml:"sink"`
+		Threshold struct {
+			RequestRate  env[float64] `yaml:"request_rate"`
+			DistinctKeys env[int]     `yaml:"distinct_keys"`
+			DenyRate     env[float64] `yaml:"deny_rate"`
+			MinRequests  env[int]     `yaml:"min_requests"`
+		} `yaml:"threshold"`
+	} `yaml:"anomaly_detection"`
+
+	Alerting struct {
+		Interval  env[time.Duration] `yaml:"interval"`
+		Receivers map[string]struct {
+			Webhook env[string] `yaml:"webhook"`
+			Email   *struct {
+				Server   env[string]   `yaml:"server"`
+				From     env[string]   `yaml:"from"`
+				To       []env[string] `yaml:"to"`
+				Username env[string]   `yaml:"username"`
+				Password env[string]   `yaml:"password"`
+			} `yaml:"email"`
+			PagerDuty *struct {
+				RoutingKey env[string] `yaml:"routing_key"`
+				Endpoint   env[string] `yaml:"endpoint"`
+			} `yaml:"pagerduty"`
+		} `yaml:"receivers"`
+		Rules []struct {
+			Name      env[string]        `yaml:"name"`
+			Signal    env[string]        `yaml:"signal"`
+			Backend   env[string]        `yaml:"backend"`
+			Metric    env[string]        `yaml:"metric"`
+			Labels    map[string]string  `yaml:"labels"`
+			Path      env[string]        `yaml:"path"`
+			Status    env[int]           `yaml:"status"`
+			Threshold env[float64]       `yaml:"threshold"`
+			For       env[time.Duration] `yaml:"for"`
+			Severity  env[string]        `yaml:"severity"`
+			Receivers []string           `yaml:"receivers"`
+		} `yaml:"rules"`
+	} `yaml:"alerting"`
+
+	Authentication []struct {
+		MTLS *struct{} `yaml:"mtls"`
+		JWT  *struct {
+			Issuer     env[string]        `yaml:"issuer"`
+			Audience   env[string]        `yaml:"audience"`
+			Claim      env[string]        `yaml:"claim"`
+			PublicKeys []env[string]      `yaml:"public_keys"`
+			Leeway     env[time.Duration] `yaml:"leeway"`
+		} `yaml:"jwt"`
+		Kubernetes *struct {
+			Endpoint  env[string]        `yaml:"endpoint"`
+			TokenFile env[string]        `yaml:"token_file"`
+			CAPath    env[string]        `yaml:"ca"`
+			Audiences []env[string]      `yaml:"audiences"`
+			CacheTTL  env[time.Duration] `yaml:"cache_ttl"`
+		} `yaml:"kubernetes"`
+		HMAC *struct {
+			Header  env[string]            `yaml:"header"`
+			MaxSkew env[time.Duration]     `yaml:"max_skew"`
+			Keys    map[string]env[string] `yaml:"keys"`
+		} `yaml:"hmac"`
+	} `yaml:"authentication"`
+
+	AuthThrottling struct {
+		Enabled     env[bool]          `yaml:"enabled"`
+		MaxFailures env[int]           `yaml:"max_failures"`
+		Interval    env[time.Duration] `yaml:"interval"`
+		Delay       env[time.Duration] `yaml:"delay"`
+		MaxDelay    env[time.Duration] `yaml:"max_delay"`
+		Ban         env[time.Duration] `yaml:"ban"`
+	} `yaml:"auth_throttling"`
+
+	WebAuthn struct {
+		RPID             env[string]        `yaml:"rp_id"`
+		Origins          []env[string]      `yaml:"origins"`
+		UserVerification env[bool]          `yaml:"user_verification"`
+		SessionTTL       env[time.Duration] `yaml:"session_ttl"`
+		Credentials      []struct {
+			ID        env[string] `yaml:"id"`
+			PublicKey env[string] `yaml:"public_key"`
+		} `yaml:"credentials"`
+	} `yaml:"webauthn"`
+
+	Merkle struct {
+		Interval  env[time.Duration] `yaml:"interval"`
+		Retention env[time.Duration] `yaml:"retention"`
+	} `yaml:"merkle"`
+
+	Cosigning struct {
+		Witnesses []env[string] `yaml:"witnesses"`
+		Paths     []string      `yaml:"paths"`
+	} `yaml:"cosigning"`
+
+	Witness struct {
+		Enabled  env[bool]          `yaml:"enabled"`
+		Validity env[time.Duration] `yaml:"validity"`
+	} `yaml:"witness"`
+
+	Fencing struct {
+		Enabled env[bool]          `yaml:"enabled"`
+		MaxTTL  env[time.Duration] `yaml:"max_ttl"`
+	} `yaml:"fencing"`
+
+	Attestation struct {
+		Enabled env[bool]   `yaml:"enabled"`
+		Device  env[string] `yaml:"device"`
+		PCRs    []int       `yaml:"pcrs"`
+	} `yaml:"attestation"`
+
+	Sealing struct {
+		SEVSNP *struct {
+			Device env[string] `yaml:"device"`
+		} `yaml:"sev_snp"`
+		AllowUnsealed env[bool] `yaml:"allow_unsealed"`
+	} `yaml:"sealing"`
+
+	Compression struct {
+		Enabled env[bool] `yaml:"enabled"`
+		MinSize env[int]  `yaml:"min_size"`
+	} `yaml:"compression"`
+
+	Chunking struct {
+		Enabled env[bool] `yaml:"enabled"`
+		Size    env[int]  `yaml:"size"`
+	} `yaml:"chunking"`
+
+	Deduplication struct {
+		Enabled env[bool] `yaml:"enabled"`
+		MinSize env[int]  `yaml:"min_size"`
+	} `yaml:"deduplication"`
+
+	WriteBehind struct {
+		Path    env[string] `yaml:"path"`
+		MaxSize env[int]    `yaml:"max_size"`
+	} `yaml:"write_behind"`
+
+	Jobs struct {
+		Migrate struct {
+			KeyStore *ymlKeyStore `yaml:"keystore"`
+		} `yaml:"migrate"`
+		Backup struct {
+			KeyStore *ymlKeyStore `yaml:"keystore"`
+		} `yaml:"backup"`
+	} `yaml:"jobs"`
+
+	Standby struct {
+		Primary    env[string]         `yaml:"primary"`
+		Interval   env[time.Duration]  `yaml:"interval"`
+		Identities []env[kes.Identity] `yaml:"identities"`
+	} `yaml:"standby"`
+
 	Cache struct {
 		Expiry struct {
 			Any     env[time.Duration] `yaml:"any"`
@@ -60,160 +305,377 @@ type ymlFile struct {
 		Paths map[string]struct {
 			InsecureSkipAuth env[bool]          `yaml:"skip_auth"`
 			Timeout          env[time.Duration] `yaml:"timeout"`
+			Deprecated       env[time.Time]     `yaml:"deprecated"`
+			Sunset           env[time.Time]     `yaml:"sunset"`
 		} `yaml:",inline"`
 	} `yaml:"api"`
 
 	Log struct {
 		Error env[string] `yaml:"error"`
 		Audit env[string] `yaml:"audit"`
+
+		Request struct {
+			Enabled    env[bool]    `yaml:"enabled"`
+			SampleRate env[float64] `yaml:"sample_rate"`
+			Body       env[bool]    `yaml:"body"`
+			Redact     []string     `yaml:"redact"`
+		} `yaml:"request"`
+
+		Pseudonymize struct {
+			Enabled env[bool]   `yaml:"enabled"`
+			Key     env[string] `yaml:"key"`
+		} `yaml:"pseudonymize"`
 	} `yaml:"log"`
 
 	Keys []struct {
 		Name env[string] `yaml:"name"`
 	} `yaml:"keys"`
 
-	KeyStore struct {
-		FS *struct {
-			Path env[string] `yaml:"path"`
-		}
-		EncryptedFS *struct {
-			MasterKeyPath   env[string] `yaml:"masterKeyPath"`
-			MasterKeyCipher env[string] `yaml:"masterKeyCipher"`
-			Path            env[string] `yaml:"path"`
-		} `yaml:"encryptedfs"`
-		KES *struct {
-			Endpoint []env[string] `yaml:"endpoint"`
-			Enclave  env[string]   `yaml:"enclave"`
-			TLS      struct {
-				Certificate env[string] `yaml:"cert"`
-				PrivateKey  env[string] `yaml:"key"`
-				CAPath      env[string] `yaml:"ca"`
+	KeyStore ymlKeyStore `yaml:"keystore"`
+
+	VirtualHosts map[string]*ymlFile `yaml:"virtual_hosts"`
+}
+
+// ymlKeyStore is the YAML representation of a keystore config.
+type ymlKeyStore struct {
+	FS *struct {
+		Path     env[string] `yaml:"path"`
+		FSync    env[string] `yaml:"fsync"`
+		Sharding env[bool]   `yaml:"sharding"`
+	}
+	EncryptedFS *struct {
+		MasterKeyPath   env[string] `yaml:"masterKeyPath"`
+		MasterKeyCipher env[string] `yaml:"masterKeyCipher"`
+		Path            env[string] `yaml:"path"`
+
+		PreviousMasterKeys []struct {
+			Path   env[string] `yaml:"path"`
+			Cipher env[string] `yaml:"cipher"`
+		} `yaml:"previousMasterKeys"`
+	} `yaml:"encryptedfs"`
+	KES *struct {
+		Endpoint []env[string] `yaml:"endpoint"`
+		Enclave  env[string]   `yaml:"enclave"`
+		TLS      struct {
+			Certificate env[string] `yaml:"cert"`
+			PrivateKey  env[string] `yaml:"key"`
+			CAPath      env[string] `yaml:"ca"`
+		} `yaml:"tls"`
+	} `yaml:"kes"`
+
+	Vault *struct {
+		Endpoint   env[string] `yaml:"endpoint"`
+		Engine     env[string] `yaml:"engine"`
+		APIVersion env[string] `yaml:"version"`
+		Namespace  env[string] `yaml:"namespace"`
+		Prefix     env[string] `yaml:"prefix"`
+
+		DeleteVersionAfter env[time.Duration] `yaml:"delete_version_after"`
+		PinnedVersion      env[int]           `yaml:"pinned_version"`
+
+		Transit *struct {
+			Engine   env[string]  `yaml:"engine"`
+			KeyName  env[string]  `yaml:"key"`
+			KeyStore *ymlKeyStore `yaml:"keystore"`
+		}
+
+		AppRole *struct {
+			Engine    env[string] `yaml:"engine"`
+			Namespace env[string] `yaml:"namespace"`
+			ID        env[string] `yaml:"id"`
+			Secret    env[string] `yaml:"secret"`
+		} `yaml:"approle"`
+
+		Kubernetes *struct {
+			Engine    env[string] `yaml:"engine"`
+			Namespace env[string] `yaml:"namespace"`
+			Role      env[string] `yaml:"role"`
+			JWT       env[string] `yaml:"jwt"` // Can be either a JWT or a path to a file containing a JWT
+		} `yaml:"kubernetes"`
+
+		TLS struct {
+			PrivateKey  env[string] `yaml:"key"`
+			Certificate env[string] `yaml:"cert"`
+			CAPath      env[string] `yaml:"ca"`
+		} `yaml:"tls"`
+
+		Status struct {
+			Ping env[time.Duration] `yaml:"ping"`
+		} `yaml:"status"`
+
+		ReadReplicas *struct {
+			Endpoints    []env[string]      `yaml:"endpoints"`
+			MaxStaleness env[time.Duration] `yaml:"max_staleness"`
+		} `yaml:"read_replicas"`
+	} `yaml:"vault"`
+
+	Fortanix *struct {
+		SDKMS *struct {
+			Endpoint env[string] `yaml:"endpoint"`
+			GroupID  env[string] `yaml:"group_id"`
+
+			Login struct {
+				APIKey env[string] `yaml:"key"`
+			} `yaml:"credentials"`
+
+			TLS struct {
+				CAPath env[string] `yaml:"ca"`
 			} `yaml:"tls"`
-		} `yaml:"kes"`
-
-		Vault *struct {
-			Endpoint   env[string] `yaml:"endpoint"`
-			Engine     env[string] `yaml:"engine"`
-			APIVersion env[string] `yaml:"version"`
-			Namespace  env[string] `yaml:"namespace"`
-			Prefix     env[string] `yaml:"prefix"`
-
-			Transit *struct {
-				Engine  env[string] `yaml:"engine"`
-				KeyName env[string] `yaml:"key"`
-			}
-
-			AppRole *struct {
-				Engine    env[string] `yaml:"engine"`
-				Namespace env[string] `yaml:"namespace"`
-				ID        env[string] `yaml:"id"`
-				Secret    env[string] `yaml:"secret"`
-			} `yaml:"approle"`
-
-			Kubernetes *struct {
-				Engine    env[string] `yaml:"engine"`
-				Namespace env[string] `yaml:"namespace"`
-				Role      env[string] `yaml:"role"`
-				JWT       env[string] `yaml:"jwt"` // Can be either a JWT or a path to a file containing a JWT
-			} `yaml:"kubernetes"`
+		} `yaml:"sdkms"`
+	} `yaml:"fortanix"`
+
+	Gemalto *struct {
+		KeySecure *struct {
+			Endpoint env[string] `yaml:"endpoint"`
+
+			Login struct {
+				Token  env[string]        `yaml:"token"`
+				Domain env[string]        `yaml:"domain"`
+				Retry  env[time.Duration] `yaml:"retry"`
+			} `yaml:"credentials"`
 
 			TLS struct {
-				PrivateKey  env[string] `yaml:"key"`
-				Certificate env[string] `yaml:"cert"`
-				CAPath      env[string] `yaml:"ca"`
+				CAPath env[string] `yaml:"ca"`
 			} `yaml:"tls"`
 
-			Status struct {
-				Ping env[time.Duration] `yaml:"ping"`
-			} `yaml:"status"`
-		} `yaml:"vault"`
-
-		Fortanix *struct {
-			SDKMS *struct {
-				Endpoint env[string] `yaml:"endpoint"`
-				GroupID  env[string] `yaml:"group_id"`
-
-				Login struct {
-					APIKey env[string] `yaml:"key"`
-				} `yaml:"credentials"`
-
-				TLS struct {
-					CAPath env[string] `yaml:"ca"`
-				} `yaml:"tls"`
-			} `yaml:"sdkms"`
-		} `yaml:"fortanix"`
-
-		Gemalto *struct {
-			KeySecure *struct {
-				Endpoint env[string] `yaml:"endpoint"`
-
-				Login struct {
-					Token  env[string] `yaml:"token"`
-					Domain env[string] `yaml:"domain"`
-				} `yaml:"credentials"`
-
-				TLS struct {
-					CAPath env[string] `yaml:"ca"`
-				} `yaml:"tls"`
-			} `yaml:"keysecure"`
-		} `yaml:"gemalto"`
-
-		GCP *struct {
-			SecretManager *struct {
-				ProjectID   env[string]   `yaml:"project_id"`
-				Endpoint    env[string]   `yaml:"endpoint"`
-				Scopes      []env[string] `yaml:"scopes"`
-				Credentials struct {
-					Client   env[string] `yaml:"client_email"`
-					ClientID env[string] `yaml:"client_id"`
-					KeyID    env[string] `yaml:"private_key_id"`
-					Key      env[string] `yaml:"private_key"`
-				} `yaml:"credentials"`
-			} `yaml:"secretmanager"`
-		} `yaml:"gcp"`
-
-		AWS *struct {
-			SecretsManager *struct {
-				Endpoint env[string] `yaml:"endpoint"`
-				Region   env[string] `yaml:"region"`
-				KmsKey   env[string] ` yaml:"kmskey"`
-
-				Login struct {
-					AccessKey    env[string] `yaml:"accesskey"`
-					SecretKey    env[string] `yaml:"secretkey"`
-					SessionToken env[string] `yaml:"token"`
-				} `yaml:"credentials"`
-			} `yaml:"secretsmanager"`
-		} `yaml:"aws"`
-
-		Azure *struct {
-			KeyVault *struct {
-				Endpoint    env[string] `yaml:"endpoint"`
-				Credentials *struct {
-					TenantID env[string] `yaml:"tenant_id"`
-					ClientID env[string] `yaml:"client_id"`
-					Secret   env[string] `yaml:"client_secret"`
-				} `yaml:"credentials"`
-				ManagedIdentity *struct {
-					ClientID env[string] `yaml:"client_id"`
-				} `yaml:"managed_identity"`
-			} `yaml:"keyvault"`
-		} `yaml:"azure"`
-		Entrust *struct {
-			KeyControl *struct {
-				Endpoint env[string] `yaml:"endpoint"`
-				VaultID  env[string] `yaml:"vault_id"`
-				BoxID    env[string] `yaml:"box_id"`
-				Login    *struct {
-					Username env[string] `yaml:"username"`
-					Password env[string] `yaml:"password"`
-				} `yaml:"credentials"`
-				TLS struct {
-					CAPath env[string] `yaml:"ca"`
-				} `yaml:"tls"`
-			} `yaml:"keycontrol"`
-		} `yaml:"entrust"`
-	} `yaml:"keystore"`
+			PoolSize env[int] `yaml:"pool_size"`
+		} `yaml:"keysecure"`
+	} `yaml:"gemalto"`
+
+	GCP *struct {
+		SecretManager *struct {
+			ProjectID   env[string]   `yaml:"project_id"`
+			Region      env[string]   `yaml:"region"`
+			Endpoint    env[string]   `yaml:"endpoint"`
+			Scopes      []env[string] `yaml:"scopes"`
+			Credentials struct {
+				Client   env[string] `yaml:"client_email"`
+				ClientID env[string] `yaml:"client_id"`
+				KeyID    env[string] `yaml:"private_key_id"`
+				Key      env[string] `yaml:"private_key"`
+			} `yaml:"credentials"`
+			WorkloadIdentity *struct {
+				CredentialsFile env[string] `yaml:"credentials_file"`
+			} `yaml:"workload_identity"`
+			Labels      map[string]env[string] `yaml:"labels"`
+			Replication *struct {
+				KMSKey   env[string] `yaml:"kms_key"`
+				Replicas []struct {
+					Location env[string] `yaml:"location"`
+					KMSKey   env[string] `yaml:"kms_key"`
+				} `yaml:"replicas"`
+			} `yaml:"replication"`
+		} `yaml:"secretmanager"`
+
+		KMS *struct {
+			Endpoint    env[string] `yaml:"endpoint"`
+			Key         env[string] `yaml:"key"`
+			RequireHSM  env[bool]   `yaml:"require_hsm"`
+			Credentials struct {
+				Client   env[string] `yaml:"client_email"`
+				ClientID env[string] `yaml:"client_id"`
+				KeyID    env[string] `yaml:"private_key_id"`
+				Key      env[string] `yaml:"private_key"`
+			} `yaml:"credentials"`
+			WorkloadIdentity *struct {
+				CredentialsFile env[string] `yaml:"credentials_file"`
+			} `yaml:"workload_identity"`
+			KeyStore *ymlKeyStore `yaml:"keystore"`
+		} `yaml:"kms"`
+	} `yaml:"gcp"`
+
+	AWS *struct {
+		SecretsManager *struct {
+			Endpoint env[string] `yaml:"endpoint"`
+			Region   env[string] `yaml:"region"`
+			KmsKey   env[string] ` yaml:"kmskey"`
+			Prefix   env[string] `yaml:"prefix"`
+
+			Overwrite env[bool]              `yaml:"overwrite"`
+			Binary    env[bool]              `yaml:"binary"`
+			Tags      map[string]env[string] `yaml:"tags"`
+
+			Replicas []struct {
+				Region env[string] `yaml:"region"`
+				KmsKey env[string] `yaml:"kmskey"`
+			} `yaml:"replicas"`
+
+			PlainHTTP          env[bool] `yaml:"plain_http"`
+			InsecureSkipVerify env[bool] `yaml:"insecure_skip_verify"`
+
+			Proxy env[string] `yaml:"proxy"`
+			TLS   struct {
+				CAPath env[string] `yaml:"ca"`
+			} `yaml:"tls"`
+			Timeout struct {
+				Dial     env[time.Duration] `yaml:"dial"`
+				Response env[time.Duration] `yaml:"response"`
+			} `yaml:"timeout"`
+
+			UseFIPSEndpoint      env[bool] `yaml:"use_fips_endpoint"`
+			UseDualStackEndpoint env[bool] `yaml:"use_dualstack_endpoint"`
+
+			Retry struct {
+				Mode        env[string]        `yaml:"mode"`
+				MaxAttempts env[int]           `yaml:"max_attempts"`
+				MaxBackoff  env[time.Duration] `yaml:"max_backoff"`
+			} `yaml:"retry"`
+
+			Login struct {
+				AccessKey    env[string] `yaml:"accesskey"`
+				SecretKey    env[string] `yaml:"secretkey"`
+				SessionToken env[string] `yaml:"token"`
+			} `yaml:"credentials"`
+
+			AssumeRole struct {
+				RoleARN     env[string] `yaml:"arn"`
+				ExternalID  env[string] `yaml:"external_id"`
+				SessionName env[string] `yaml:"session_name"`
+				TokenFile   env[string] `yaml:"web_identity_token_file"`
+			} `yaml:"assume_role"`
+		} `yaml:"secretsmanager"`
+
+		KMS *struct {
+			Path     env[string] `yaml:"path"`
+			Endpoint env[string] `yaml:"endpoint"`
+			Region   env[string] `yaml:"region"`
+			Key      env[string] `yaml:"key"`
+
+			Login struct {
+				AccessKey    env[string] `yaml:"accesskey"`
+				SecretKey    env[string] `yaml:"secretkey"`
+				SessionToken env[string] `yaml:"token"`
+			} `yaml:"credentials"`
+		} `yaml:"kms"`
+
+		SSM *struct {
+			Endpoint env[string] `yaml:"endpoint"`
+			Region   env[string] `yaml:"region"`
+			Path     env[string] `yaml:"path"`
+			KMSKey   env[string] `yaml:"kmskey"`
+			Tier     env[string] `yaml:"tier"`
+
+			Login struct {
+				AccessKey    env[string] `yaml:"accesskey"`
+				SecretKey    env[string] `yaml:"secretkey"`
+				SessionToken env[string] `yaml:"token"`
+			} `yaml:"credentials"`
+		} `yaml:"ssm"`
+
+		DynamoDB *struct {
+			Endpoint            env[string] `yaml:"endpoint"`
+			Region              env[string] `yaml:"region"`
+			Table               env[string] `yaml:"table"`
+			EventualConsistency env[bool]   `yaml:"eventual_consistency"`
+
+			Login struct {
+				AccessKey    env[string] `yaml:"accesskey"`
+				SecretKey    env[string] `yaml:"secretkey"`
+				SessionToken env[string] `yaml:"token"`
+			} `yaml:"credentials"`
+
+			ReadReplicas *struct {
+				Regions      []env[string]      `yaml:"regions"`
+				MaxStaleness env[time.Duration] `yaml:"max_staleness"`
+			} `yaml:"read_replicas"`
+		} `yaml:"dynamodb"`
+
+		CloudHSM *struct {
+			Library  env[string]  `yaml:"library"`
+			Token    env[string]  `yaml:"token"`
+			PIN      env[string]  `yaml:"pin"`
+			Key      env[string]  `yaml:"key"`
+			KeyStore *ymlKeyStore `yaml:"keystore"`
+		} `yaml:"cloudhsm"`
+	} `yaml:"aws"`
+
+	Azure *struct {
+		KeyVault *struct {
+			Endpoint      env[string] `yaml:"endpoint"`
+			Cloud         env[string] `yaml:"cloud"`
+			AuthorityHost env[string] `yaml:"authority_host"`
+			Credentials   *struct {
+				TenantID env[string] `yaml:"tenant_id"`
+				ClientID env[string] `yaml:"client_id"`
+				Secret   env[string] `yaml:"client_secret"`
+			} `yaml:"credentials"`
+			ClientCertificate *struct {
+				TenantID  env[string] `yaml:"tenant_id"`
+				ClientID  env[string] `yaml:"client_id"`
+				File      env[string] `yaml:"file"`
+				Password  env[string] `yaml:"password"`
+				SendChain env[bool]   `yaml:"send_chain"`
+			} `yaml:"client_certificate"`
+			ManagedIdentity *struct {
+				ClientID   env[string] `yaml:"client_id"`
+				ResourceID env[string] `yaml:"resource_id"`
+			} `yaml:"managed_identity"`
+			WorkloadIdentity *struct {
+				TenantID  env[string] `yaml:"tenant_id"`
+				ClientID  env[string] `yaml:"client_id"`
+				TokenFile env[string] `yaml:"token_file"`
+			} `yaml:"workload_identity"`
+			SoftDelete *struct {
+				Recover env[bool] `yaml:"recover"`
+			} `yaml:"soft_delete"`
+			ManagedHSM *struct {
+				Key      env[string]  `yaml:"key"`
+				KeyStore *ymlKeyStore `yaml:"keystore"`
+			} `yaml:"managed_hsm"`
+		} `yaml:"keyvault"`
+	} `yaml:"azure"`
+	Entrust *struct {
+		KeyControl *struct {
+			Endpoint env[string] `yaml:"endpoint"`
+			VaultID  env[string] `yaml:"vault_id"`
+			BoxID    env[string] `yaml:"box_id"`
+			Login    *struct {
+				Username env[string] `yaml:"username"`
+				Password env[string] `yaml:"password"`
+			} `yaml:"credentials"`
+			TLS struct {
+				CAPath env[string] `yaml:"ca"`
+			} `yaml:"tls"`
+		} `yaml:"keycontrol"`
+	} `yaml:"entrust"`
+	Timeout struct {
+		Get   env[time.Duration] `yaml:"get"`
+		Write env[time.Duration] `yaml:"write"`
+		List  env[time.Duration] `yaml:"list"`
+	} `yaml:"timeout"`
+	SQL *struct {
+		Driver       env[string] `yaml:"driver"`
+		DSN          env[string] `yaml:"dsn"`
+		Dialect      env[string] `yaml:"dialect"`
+		Table        env[string] `yaml:"table"`
+		MaxOpenConns env[int]    `yaml:"max_open_conns"`
+
+		ReadReplicas *struct {
+			DSNs         []env[string]      `yaml:"dsns"`
+			MaxStaleness env[time.Duration] `yaml:"max_staleness"`
+		} `yaml:"read_replicas"`
+	} `yaml:"sql"`
+	S3 *struct {
+		Endpoint  env[string] `yaml:"endpoint"`
+		Region    env[string] `yaml:"region"`
+		Bucket    env[string] `yaml:"bucket"`
+		Prefix    env[string] `yaml:"prefix"`
+		KMSKey    env[string] `yaml:"kmskey"`
+		Retention *struct {
+			Mode   env[string]        `yaml:"mode"`
+			Period env[time.Duration] `yaml:"period"`
+		} `yaml:"retention"`
+
+		Login struct {
+			AccessKey    env[string] `yaml:"accesskey"`
+			SecretKey    env[string] `yaml:"secretkey"`
+			SessionToken env[string] `yaml:"token"`
+		} `yaml:"credentials"`
+	} `yaml:"s3"`
+	Split *struct {
+		Primary   *ymlKeyStore `yaml:"primary"`
+		Secondary *ymlKeyStore `yaml:"secondary"`
+	} `yaml:"split"`
 }
 
 func findVersion(root *yaml.Node) (string, error) {
@@ -267,6 +729,16 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 		clientAuth = tls.RequireAndVerifyClientCert
 	}
 
+	if v := strings.ToLower(y.TLS.Session.Tickets.Value); v != "" && v != "on" && v != "off" {
+		return nil, fmt.Errorf("kesconf: invalid tls session config: invalid tickets '%s'", y.TLS.Session.Tickets.Value)
+	}
+	if y.TLS.Session.TicketKeyRotation.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid tls session config: invalid ticket key rotation '%v'", y.TLS.Session.TicketKeyRotation.Value)
+	}
+	if y.TLS.Session.CacheSize.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid tls session config: invalid cache size '%d'", y.TLS.Session.CacheSize.Value)
+	}
+
 	for _, proxy := range y.TLS.Proxy.Identities {
 		if proxy.Value == y.Admin.Identity.Value {
 			return nil, fmt.Errorf("kesconf: invalid tls proxy: identity '%s' is already admin", proxy.Value)
@@ -274,6 +746,9 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 	}
 
 	for name, policy := range y.Policies {
+		if policy.Geo != nil && y.GeoIP.Database.Value == "" {
+			return nil, fmt.Errorf("kesconf: invalid policy '%s': geo condition requires a GeoIP database", name)
+		}
 		for _, identity := range policy.Identities {
 			if identity.Value == y.Admin.Identity.Value {
 				return nil, fmt.Errorf("kesconf: invalid policy '%s': identity '%s' is already admin", name, identity.Value)
@@ -304,11 +779,62 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 	if err != nil {
 		return nil, err
 	}
+	var pseudonymKey []byte
+	if y.Log.Pseudonymize.Key.Value != "" {
+		pseudonymKey, err = base64.StdEncoding.DecodeString(y.Log.Pseudonymize.Key.Value)
+		if err != nil {
+			return nil, fmt.Errorf("kesconf: invalid log pseudonymization key: %v", err)
+		}
+		if len(pseudonymKey) < 16 {
+			return nil, errors.New("kesconf: invalid log pseudonymization key: key must be at least 16 bytes long")
+		}
+	}
+
+	var witnesses []ed25519.PublicKey
+	for i, witness := range y.Cosigning.Witnesses {
+		pub, err := base64.StdEncoding.DecodeString(witness.Value)
+		if err != nil {
+			return nil, fmt.Errorf("kesconf: invalid cosigning witness %d: %v", i, err)
+		}
+		if len(pub) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("kesconf: invalid cosigning witness %d: not an Ed25519 public key", i)
+		}
+		witnesses = append(witnesses, pub)
+	}
+	if len(y.Cosigning.Paths) > 0 && len(witnesses) == 0 {
+		return nil, errors.New("kesconf: invalid cosigning config: no witnesses specified")
+	}
+	if y.Witness.Validity.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid witness validity '%v'", y.Witness.Validity.Value)
+	}
+	if y.Fencing.MaxTTL.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid fencing max TTL '%v'", y.Fencing.MaxTTL.Value)
+	}
+	if y.Compression.MinSize.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid compression min. size '%d'", y.Compression.MinSize.Value)
+	}
+	if y.Chunking.Size.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid chunk size '%d'", y.Chunking.Size.Value)
+	}
+	if y.Deduplication.MinSize.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid deduplication min. size '%d'", y.Deduplication.MinSize.Value)
+	}
+	if y.WriteBehind.MaxSize.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid write-behind max. size '%d'", y.WriteBehind.MaxSize.Value)
+	}
+	for _, pcr := range y.Attestation.PCRs {
+		if pcr < 0 || pcr > 23 {
+			return nil, fmt.Errorf("kesconf: invalid attestation PCR '%d'", pcr)
+		}
+	}
 
 	for path, api := range y.API.Paths {
 		if api.Timeout.Value < 0 {
 			return nil, fmt.Errorf("kesconf: invalid timeout '%d' for API '%s'", api.Timeout.Value, path)
 		}
+		if !api.Sunset.Value.IsZero() && api.Sunset.Value.Before(api.Deprecated.Value) {
+			return nil, fmt.Errorf("kesconf: invalid sunset for API '%s': sunset is before deprecation", path)
+		}
 
 		// If mTLS authentication is disabled for at least one API,
 		// we must no longer require that a client sends a certificate.
@@ -325,6 +851,198 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 		}
 	}
 
+	for name, db := range y.Databases {
+		if db.Driver.Value == "" {
+			return nil, fmt.Errorf("kesconf: invalid database '%s': no driver specified", name)
+		}
+		if db.DSN.Value == "" {
+			return nil, fmt.Errorf("kesconf: invalid database '%s': no DSN specified", name)
+		}
+		if db.TTL.Value < 0 || db.MaxTTL.Value < 0 {
+			return nil, fmt.Errorf("kesconf: invalid database '%s': TTL must not be negative", name)
+		}
+		if db.MaxTTL.Value > 0 && db.TTL.Value > db.MaxTTL.Value {
+			return nil, fmt.Errorf("kesconf: invalid database '%s': TTL '%v' exceeds max. TTL '%v'", name, db.TTL.Value, db.MaxTTL.Value)
+		}
+	}
+
+	for name, role := range y.SSH {
+		if role.Type.Value != "" && role.Type.Value != "user" && role.Type.Value != "host" {
+			return nil, fmt.Errorf("kesconf: invalid SSH role '%s': invalid type '%s'", name, role.Type.Value)
+		}
+		if len(role.Principals) == 0 {
+			return nil, fmt.Errorf("kesconf: invalid SSH role '%s': no principals specified", name)
+		}
+		if role.TTL.Value < 0 || role.MaxTTL.Value < 0 {
+			return nil, fmt.Errorf("kesconf: invalid SSH role '%s': TTL must not be negative", name)
+		}
+		if role.MaxTTL.Value > 0 && role.TTL.Value > role.MaxTTL.Value {
+			return nil, fmt.Errorf("kesconf: invalid SSH role '%s': TTL '%v' exceeds max. TTL '%v'", name, role.TTL.Value, role.MaxTTL.Value)
+		}
+	}
+
+	for name, role := range y.PKI.Roles {
+		if len(role.Domains) == 0 {
+			return nil, fmt.Errorf("kesconf: invalid PKI role '%s': no domains specified", name)
+		}
+		if role.TTL.Value < 0 || role.MaxTTL.Value < 0 {
+			return nil, fmt.Errorf("kesconf: invalid PKI role '%s': TTL must not be negative", name)
+		}
+		if role.MaxTTL.Value > 0 && role.TTL.Value > role.MaxTTL.Value {
+			return nil, fmt.Errorf("kesconf: invalid PKI role '%s': TTL '%v' exceeds max. TTL '%v'", name, role.TTL.Value, role.MaxTTL.Value)
+		}
+	}
+
+	if y.PKI.Renewal.Window.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid PKI renewal window '%v': window must not be negative", y.PKI.Renewal.Window.Value)
+	}
+	if y.PKI.Renewal.TTL.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid PKI renewal TTL '%v': TTL must not be negative", y.PKI.Renewal.TTL.Value)
+	}
+	if y.PKI.Renewal.Window.Value > 0 && y.PKI.Renewal.TTL.Value > 0 && y.PKI.Renewal.Window.Value >= y.PKI.Renewal.TTL.Value {
+		return nil, fmt.Errorf("kesconf: invalid PKI renewal window '%v': window must be shorter than TTL '%v'", y.PKI.Renewal.Window.Value, y.PKI.Renewal.TTL.Value)
+	}
+
+	for name, t := range y.Tokenization {
+		if t.Mode.Value != "" && t.Mode.Value != "FF1" && t.Mode.Value != "FF3-1" {
+			return nil, fmt.Errorf("kesconf: invalid tokenization config of key '%s': invalid mode '%s'", name, t.Mode.Value)
+		}
+		if t.Alphabet.Value == "" {
+			return nil, fmt.Errorf("kesconf: invalid tokenization config of key '%s': no alphabet specified", name)
+		}
+		if t.Reveal.First.Value < 0 || t.Reveal.Last.Value < 0 {
+			return nil, fmt.Errorf("kesconf: invalid tokenization config of key '%s': reveal must not be negative", name)
+		}
+	}
+
+	for name, t := range y.Tenants {
+		if t.Prefix.Value == "" {
+			return nil, fmt.Errorf("kesconf: invalid tenant '%s': no prefix specified", name)
+		}
+	}
+
+	if r := y.Log.Request.SampleRate.Value; r < 0 || r > 1 {
+		return nil, fmt.Errorf("kesconf: invalid request log sample rate '%v': must be between 0 and 1", r)
+	}
+	if y.LoadShedding.MaxRequests.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid load shedding max. requests '%d'", y.LoadShedding.MaxRequests.Value)
+	}
+	if y.KeyLifecycle.Notice.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid key lifecycle notice '%v'", y.KeyLifecycle.Notice.Value)
+	}
+	if y.AnomalyDetection.Interval.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid anomaly detection interval '%v'", y.AnomalyDetection.Interval.Value)
+	}
+	if r := y.AnomalyDetection.Threshold.DenyRate.Value; r < 0 || r > 1 {
+		return nil, fmt.Errorf("kesconf: invalid anomaly detection deny rate '%v': must be between 0 and 1", r)
+	}
+	if y.AnomalyDetection.Threshold.RequestRate.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid anomaly detection request rate '%v'", y.AnomalyDetection.Threshold.RequestRate.Value)
+	}
+	if y.AnomalyDetection.Threshold.DistinctKeys.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid anomaly detection distinct keys '%d'", y.AnomalyDetection.Threshold.DistinctKeys.Value)
+	}
+	if y.AnomalyDetection.Threshold.MinRequests.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid anomaly detection min. requests '%d'", y.AnomalyDetection.Threshold.MinRequests.Value)
+	}
+	if y.Alerting.Interval.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid alerting interval '%v'", y.Alerting.Interval.Value)
+	}
+	for i, rule := range y.Alerting.Rules {
+		if rule.Name.Value == "" {
+			return nil, fmt.Errorf("kesconf: invalid alert rule %d: no name specified", i)
+		}
+		if rule.Signal.Value == "" {
+			return nil, fmt.Errorf("kesconf: invalid alert rule '%s': no signal specified", rule.Name.Value)
+		}
+		if rule.For.Value < 0 {
+			return nil, fmt.Errorf("kesconf: invalid alert rule '%s': invalid duration '%v'", rule.Name.Value, rule.For.Value)
+		}
+		for _, name := range rule.Receivers {
+			if _, ok := y.Alerting.Receivers[name]; !ok {
+				return nil, fmt.Errorf("kesconf: invalid alert rule '%s': receiver '%s' does not exist", rule.Name.Value, name)
+			}
+		}
+	}
+	for i, auth := range y.Authentication {
+		var n int
+		for _, ok := range []bool{auth.MTLS != nil, auth.JWT != nil, auth.Kubernetes != nil, auth.HMAC != nil} {
+			if ok {
+				n++
+			}
+		}
+		if n != 1 {
+			return nil, fmt.Errorf("kesconf: invalid authentication config: authenticator %d must specify exactly one of 'mtls', 'jwt', 'kubernetes' or 'hmac'", i)
+		}
+		if auth.JWT != nil && len(auth.JWT.PublicKeys) == 0 {
+			return nil, fmt.Errorf("kesconf: invalid JWT authenticator %d: no public keys specified", i)
+		}
+		if auth.Kubernetes != nil && (auth.Kubernetes.Endpoint.Value == "" || auth.Kubernetes.TokenFile.Value == "") {
+			return nil, fmt.Errorf("kesconf: invalid Kubernetes authenticator %d: endpoint and token file are required", i)
+		}
+		if auth.HMAC != nil {
+			if len(auth.HMAC.Keys) == 0 {
+				return nil, fmt.Errorf("kesconf: invalid HMAC authenticator %d: no keys specified", i)
+			}
+			for id, key := range auth.HMAC.Keys {
+				if len(key.Value) < 16 {
+					return nil, fmt.Errorf("kesconf: invalid HMAC authenticator %d: key of '%s' is shorter than 16 bytes", i, id)
+				}
+			}
+		}
+	}
+	if y.AuthThrottling.MaxFailures.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid auth throttling max. failures '%d'", y.AuthThrottling.MaxFailures.Value)
+	}
+	if y.AuthThrottling.Interval.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid auth throttling interval '%v'", y.AuthThrottling.Interval.Value)
+	}
+	if y.AuthThrottling.Delay.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid auth throttling delay '%v'", y.AuthThrottling.Delay.Value)
+	}
+	if y.AuthThrottling.MaxDelay.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid auth throttling max. delay '%v'", y.AuthThrottling.MaxDelay.Value)
+	}
+	if y.AuthThrottling.Ban.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid auth throttling ban '%v'", y.AuthThrottling.Ban.Value)
+	}
+	var credentials []WebAuthnCredential
+	for i, cred := range y.WebAuthn.Credentials {
+		id, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(cred.ID.Value, "="))
+		if err != nil || len(id) == 0 {
+			return nil, fmt.Errorf("kesconf: invalid WebAuthn credential %d: invalid credential ID", i)
+		}
+		if cred.PublicKey.Value == "" {
+			return nil, fmt.Errorf("kesconf: invalid WebAuthn credential %d: no public key specified", i)
+		}
+		credentials = append(credentials, WebAuthnCredential{ID: id, PublicKey: cred.PublicKey.Value})
+	}
+	if len(credentials) > 0 && y.WebAuthn.RPID.Value == "" {
+		return nil, errors.New("kesconf: invalid WebAuthn config: no relying party ID specified")
+	}
+	if y.WebAuthn.RPID.Value != "" && len(credentials) == 0 {
+		return nil, errors.New("kesconf: invalid WebAuthn config: no credentials specified")
+	}
+	if y.WebAuthn.SessionTTL.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid WebAuthn session TTL '%v'", y.WebAuthn.SessionTTL.Value)
+	}
+	if y.Merkle.Interval.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid merkle interval '%v'", y.Merkle.Interval.Value)
+	}
+	if y.Merkle.Retention.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid merkle retention '%v'", y.Merkle.Retention.Value)
+	}
+	if y.Merkle.Retention.Value > 0 && y.Merkle.Interval.Value == 0 {
+		return nil, errors.New("kesconf: invalid merkle config: no interval specified")
+	}
+
+	if y.Standby.Interval.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid standby interval '%v'", y.Standby.Interval.Value)
+	}
+	if y.Standby.Interval.Value > 0 && y.Standby.Primary.Value == "" {
+		return nil, errors.New("kesconf: invalid standby config: no primary specified")
+	}
+
 	if len(y.Keys) > 0 {
 		names := make(map[string]struct{}, len(y.Keys))
 		for _, key := range y.Keys {
@@ -335,7 +1053,7 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 		}
 	}
 
-	keystore, err := ymlToKeyStore(y)
+	keystore, err := ymlToKeyStore(&y.KeyStore)
 	if err != nil {
 		return nil, err
 	}
@@ -350,6 +1068,10 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 			ClientAuth:        clientAuth,
 			CAPath:            y.TLS.CAPath.Value,
 			ForwardCertHeader: y.TLS.Proxy.Header.ClientCert.Value,
+
+			SessionTicketsDisabled:   strings.ToLower(y.TLS.Session.Tickets.Value) == "off",
+			SessionTicketKeyRotation: y.TLS.Session.TicketKeyRotation.Value,
+			SessionCacheSize:         y.TLS.Session.CacheSize.Value,
 		},
 		Cache: &CacheConfig{
 			Expiry:        y.Cache.Expiry.Any.Value,
@@ -361,6 +1083,336 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 			AuditLevel: auditLevel,
 		},
 		KeyStore: keystore,
+		KeyStoreTimeout: &KeyStoreTimeoutConfig{
+			Get:   y.KeyStore.Timeout.Get.Value,
+			Write: y.KeyStore.Timeout.Write.Value,
+			List:  y.KeyStore.Timeout.List.Value,
+		},
+	}
+	if c.KeyStoreTimeout.List == 0 {
+		switch keystore.(type) {
+		case *AWSSecretsManagerKeyStore, *GCPSecretManagerKeyStore, *AzureKeyVaultKeyStore:
+			// Cloud secret managers list secrets page by page
+			// and limit the request rate. Listing many keys
+			// takes significantly longer than fetching a key.
+			c.KeyStoreTimeout.List = defaultCloudListTimeout
+		}
+	}
+	if len(y.Databases) > 0 {
+		c.Databases = make(map[string]DatabaseConfig, len(y.Databases))
+		for name, db := range y.Databases {
+			dialect := db.Dialect.Value
+			if dialect == "" {
+				dialect = db.Driver.Value
+			}
+			c.Databases[name] = DatabaseConfig{
+				Driver:               db.Driver.Value,
+				DSN:                  db.DSN.Value,
+				Dialect:              dialect,
+				CreationStatements:   db.Creation,
+				RevocationStatements: db.Revocation,
+				DefaultTTL:           db.TTL.Value,
+				MaxTTL:               db.MaxTTL.Value,
+			}
+		}
+	}
+	if len(y.SSH) > 0 {
+		c.SSHRoles = make(map[string]SSHRoleConfig, len(y.SSH))
+		for name, role := range y.SSH {
+			c.SSHRoles[name] = SSHRoleConfig{
+				CertType:   role.Type.Value,
+				Principals: role.Principals,
+				Extensions: role.Extensions,
+				DefaultTTL: role.TTL.Value,
+				MaxTTL:     role.MaxTTL.Value,
+			}
+		}
+	}
+	if y.PKI.URL.Value != "" || len(y.PKI.Roles) > 0 || y.PKI.Renewal.Enabled.Value {
+		c.PKI = &PKIConfig{
+			URL:   y.PKI.URL.Value,
+			Roles: make(map[string]PKIRoleConfig, len(y.PKI.Roles)),
+		}
+		for name, role := range y.PKI.Roles {
+			c.PKI.Roles[name] = PKIRoleConfig{
+				Domains:    role.Domains,
+				AllowIPs:   role.AllowIPs.Value,
+				ServerAuth: role.Server.Value,
+				ClientAuth: role.Client.Value,
+				DefaultTTL: role.TTL.Value,
+				MaxTTL:     role.MaxTTL.Value,
+			}
+		}
+		if y.PKI.Renewal.Enabled.Value {
+			c.PKI.Renewal = &PKIRenewalConfig{
+				Window: y.PKI.Renewal.Window.Value,
+				TTL:    y.PKI.Renewal.TTL.Value,
+			}
+		}
+	}
+	if len(y.Tokenization) > 0 {
+		c.Tokenization = make(map[string]TokenizationConfig, len(y.Tokenization))
+		for name, t := range y.Tokenization {
+			c.Tokenization[name] = TokenizationConfig{
+				Mode:        t.Mode.Value,
+				Alphabet:    t.Alphabet.Value,
+				RevealFirst: t.Reveal.First.Value,
+				RevealLast:  t.Reveal.Last.Value,
+				MaskChar:    t.Reveal.Mask.Value,
+			}
+		}
+	}
+	if len(y.Folders) > 0 {
+		c.Folders = make(map[string]FolderConfig, len(y.Folders))
+		for path, f := range y.Folders {
+			folder := FolderConfig{Grants: f.Grants}
+			if len(f.Tags) > 0 {
+				folder.Tags = make(map[string]string, len(f.Tags))
+				for k, v := range f.Tags {
+					folder.Tags[k] = v.Value
+				}
+			}
+			c.Folders[path] = folder
+		}
+	}
+	if len(y.KeyLifecycle.Keys) > 0 {
+		c.KeyLifecycle = &KeyLifecycleConfig{
+			Notice:    y.KeyLifecycle.Notice.Value,
+			Sink:      y.KeyLifecycle.Sink.Value,
+			Schedules: make(map[string]KeySchedule, len(y.KeyLifecycle.Keys)),
+		}
+		for pattern, k := range y.KeyLifecycle.Keys {
+			c.KeyLifecycle.Schedules[pattern] = KeySchedule{
+				DecryptOnly: k.DecryptOnly.Value,
+				Disable:     k.Disable.Value,
+				Purge:       k.Purge.Value,
+			}
+		}
+	}
+	c.RequireKeyOwner = y.KeyOwnership.Required.Value
+	if len(y.Tenants) > 0 {
+		c.Tenants = make(map[string]TenantConfig, len(y.Tenants))
+		for name, t := range y.Tenants {
+			tenant := TenantConfig{Prefix: t.Prefix.Value}
+			if t.KeyStore != nil {
+				if tenant.KeyStore, err = ymlToKeyStore(t.KeyStore); err != nil {
+					return nil, fmt.Errorf("kesconf: invalid tenant '%s': %v", name, err)
+				}
+			}
+			c.Tenants[name] = tenant
+		}
+	}
+	if y.Log.Pseudonymize.Enabled.Value {
+		c.Log.Pseudonymization = &AuditPseudonymizationConfig{
+			Key: pseudonymKey,
+		}
+	}
+	if y.Log.Request.Enabled.Value {
+		c.Log.Request = &RequestLogConfig{
+			SampleRate: y.Log.Request.SampleRate.Value,
+			Body:       y.Log.Request.Body.Value,
+			Redact:     y.Log.Request.Redact,
+		}
+	}
+	if y.LoadShedding.MaxRequests.Value > 0 {
+		c.LoadShedding = &LoadSheddingConfig{
+			MaxRequests: y.LoadShedding.MaxRequests.Value,
+		}
+	}
+	if y.GeoIP.Database.Value != "" {
+		c.GeoIP = &GeoIPConfig{
+			Database: y.GeoIP.Database.Value,
+		}
+	}
+	if y.AnomalyDetection.Enabled.Value {
+		c.AnomalyDetection = &AnomalyDetectionConfig{
+			Interval:        y.AnomalyDetection.Interval.Value,
+			Sink:            y.AnomalyDetection.Sink.Value,
+			MaxRequestRate:  y.AnomalyDetection.Threshold.RequestRate.Value,
+			MaxDistinctKeys: y.AnomalyDetection.Threshold.DistinctKeys.Value,
+			MaxDenyRate:     y.AnomalyDetection.Threshold.DenyRate.Value,
+			MinRequests:     y.AnomalyDetection.Threshold.MinRequests.Value,
+		}
+	}
+	if len(y.Alerting.Rules) > 0 {
+		c.Alerting = &AlertingConfig{
+			Interval:  y.Alerting.Interval.Value,
+			Receivers: make(map[string]AlertReceiver, len(y.Alerting.Receivers)),
+		}
+		for name, r := range y.Alerting.Receivers {
+			receiver := AlertReceiver{Webhook: r.Webhook.Value}
+			if r.Email != nil {
+				receiver.Email = &AlertEmailConfig{
+					Server:   r.Email.Server.Value,
+					From:     r.Email.From.Value,
+					Username: r.Email.Username.Value,
+					Password: r.Email.Password.Value,
+				}
+				for _, to := range r.Email.To {
+					receiver.Email.To = append(receiver.Email.To, to.Value)
+				}
+			}
+			if r.PagerDuty != nil {
+				receiver.PagerDuty = &AlertPagerDutyConfig{
+					RoutingKey: r.PagerDuty.RoutingKey.Value,
+					Endpoint:   r.PagerDuty.Endpoint.Value,
+				}
+			}
+			c.Alerting.Receivers[name] = receiver
+		}
+		for _, rule := range y.Alerting.Rules {
+			c.Alerting.Rules = append(c.Alerting.Rules, AlertRule{
+				Name:       rule.Name.Value,
+				Signal:     rule.Signal.Value,
+				Backend:    rule.Backend.Value,
+				Metric:     rule.Metric.Value,
+				Labels:     rule.Labels,
+				Path:       rule.Path.Value,
+				StatusCode: rule.Status.Value,
+				Threshold:  rule.Threshold.Value,
+				For:        rule.For.Value,
+				Severity:   rule.Severity.Value,
+				Receivers:  rule.Receivers,
+			})
+		}
+	}
+	for _, auth := range y.Authentication {
+		switch {
+		case auth.MTLS != nil:
+			c.Authentication = append(c.Authentication, AuthenticatorConfig{MTLS: true})
+		case auth.JWT != nil:
+			jwt := &JWTAuthConfig{
+				Issuer:   auth.JWT.Issuer.Value,
+				Audience: auth.JWT.Audience.Value,
+				Claim:    auth.JWT.Claim.Value,
+				Leeway:   auth.JWT.Leeway.Value,
+			}
+			for _, key := range auth.JWT.PublicKeys {
+				jwt.PublicKeys = append(jwt.PublicKeys, key.Value)
+			}
+			c.Authentication = append(c.Authentication, AuthenticatorConfig{JWT: jwt})
+		case auth.Kubernetes != nil:
+			k8s := &KubernetesAuthConfig{
+				Endpoint:  auth.Kubernetes.Endpoint.Value,
+				TokenFile: auth.Kubernetes.TokenFile.Value,
+				CAPath:    auth.Kubernetes.CAPath.Value,
+				CacheTTL:  auth.Kubernetes.CacheTTL.Value,
+			}
+			for _, aud := range auth.Kubernetes.Audiences {
+				k8s.Audiences = append(k8s.Audiences, aud.Value)
+			}
+			c.Authentication = append(c.Authentication, AuthenticatorConfig{Kubernetes: k8s})
+		case auth.HMAC != nil:
+			hmac := &HMACAuthConfig{
+				Header:  auth.HMAC.Header.Value,
+				MaxSkew: auth.HMAC.MaxSkew.Value,
+				Keys:    make(map[kes.Identity]string, len(auth.HMAC.Keys)),
+			}
+			for id, key := range auth.HMAC.Keys {
+				hmac.Keys[kes.Identity(id)] = key.Value
+			}
+			c.Authentication = append(c.Authentication, AuthenticatorConfig{HMAC: hmac})
+		}
+	}
+	if y.AuthThrottling.Enabled.Value {
+		c.AuthThrottling = &AuthThrottlingConfig{
+			MaxFailures: y.AuthThrottling.MaxFailures.Value,
+			Interval:    y.AuthThrottling.Interval.Value,
+			Delay:       y.AuthThrottling.Delay.Value,
+			MaxDelay:    y.AuthThrottling.MaxDelay.Value,
+			BanDuration: y.AuthThrottling.Ban.Value,
+		}
+	}
+	if len(credentials) > 0 {
+		c.WebAuthn = &WebAuthnConfig{
+			RPID:             y.WebAuthn.RPID.Value,
+			UserVerification: y.WebAuthn.UserVerification.Value,
+			SessionTTL:       y.WebAuthn.SessionTTL.Value,
+			Credentials:      credentials,
+		}
+		for _, origin := range y.WebAuthn.Origins {
+			c.WebAuthn.Origins = append(c.WebAuthn.Origins, origin.Value)
+		}
+	}
+	if y.Merkle.Interval.Value > 0 {
+		c.Merkle = &MerkleConfig{
+			Interval:  y.Merkle.Interval.Value,
+			Retention: y.Merkle.Retention.Value,
+		}
+	}
+	if len(witnesses) > 0 {
+		c.Cosigning = &CosigningConfig{
+			Witnesses: witnesses,
+			Paths:     y.Cosigning.Paths,
+		}
+	}
+	if y.Witness.Enabled.Value {
+		c.Witness = &WitnessConfig{
+			Validity: y.Witness.Validity.Value,
+		}
+	}
+	if y.Fencing.Enabled.Value {
+		c.Fencing = &FencingConfig{
+			MaxTTL: y.Fencing.MaxTTL.Value,
+		}
+	}
+	if y.Sealing.SEVSNP != nil {
+		c.Sealing = &SealingConfig{
+			SEVSNPDevice:  y.Sealing.SEVSNP.Device.Value,
+			AllowUnsealed: y.Sealing.AllowUnsealed.Value,
+		}
+	}
+	if y.Compression.Enabled.Value {
+		c.Compression = &CompressionConfig{
+			MinSize: y.Compression.MinSize.Value,
+		}
+	}
+	if y.Chunking.Enabled.Value {
+		c.Chunking = &ChunkingConfig{
+			Size: y.Chunking.Size.Value,
+		}
+	}
+	if y.Deduplication.Enabled.Value {
+		c.Dedup = &DedupConfig{
+			MinSize: y.Deduplication.MinSize.Value,
+		}
+	}
+	if y.WriteBehind.Path.Value != "" {
+		c.WriteBehind = &WriteBehindConfig{
+			Path:    y.WriteBehind.Path.Value,
+			MaxSize: int64(y.WriteBehind.MaxSize.Value),
+		}
+	}
+	if y.Attestation.Enabled.Value {
+		c.Attestation = &AttestationConfig{
+			Device: y.Attestation.Device.Value,
+			PCRs:   y.Attestation.PCRs,
+		}
+	}
+	if y.Standby.Primary.Value != "" {
+		c.Standby = &StandbyConfig{
+			Primary:  y.Standby.Primary.Value,
+			Interval: y.Standby.Interval.Value,
+		}
+	}
+	for _, id := range y.Standby.Identities {
+		if id.Value.IsUnknown() {
+			continue
+		}
+		c.StandbyIdentities = append(c.StandbyIdentities, id.Value)
+	}
+	if y.Jobs.Migrate.KeyStore != nil || y.Jobs.Backup.KeyStore != nil {
+		c.Jobs = &JobConfig{}
+		if y.Jobs.Migrate.KeyStore != nil {
+			if c.Jobs.MigrationTarget, err = ymlToKeyStore(y.Jobs.Migrate.KeyStore); err != nil {
+				return nil, fmt.Errorf("kesconf: invalid migrate job: %v", err)
+			}
+		}
+		if y.Jobs.Backup.KeyStore != nil {
+			if c.Jobs.BackupTarget, err = ymlToKeyStore(y.Jobs.Backup.KeyStore); err != nil {
+				return nil, fmt.Errorf("kesconf: invalid backup job: %v", err)
+			}
+		}
 	}
 	if len(y.TLS.Proxy.Identities) > 0 {
 		c.TLS.Proxies = make([]kes.Identity, 0, len(y.TLS.Proxy.Identities))
@@ -375,11 +1427,19 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 			for _, id := range policy.Identities {
 				identities = append(identities, id.Value)
 			}
-			c.Policies[name] = Policy{
+			p := Policy{
 				Allow:      policy.Allow,
 				Deny:       policy.Deny,
 				Identities: identities,
 			}
+			if policy.Geo != nil {
+				p.Geo = &GeoCondition{
+					Paths:      policy.Geo.Paths,
+					Countries:  policy.Geo.Countries,
+					Continents: policy.Geo.Continents,
+				}
+			}
+			c.Policies[name] = p
 		}
 	}
 	if len(y.API.Paths) > 0 {
@@ -388,6 +1448,8 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 			paths[path] = APIPathConfig{
 				InsecureSkipAuth: api.InsecureSkipAuth.Value,
 				Timeout:          api.Timeout.Value,
+				Deprecated:       api.Deprecated.Value,
+				Sunset:           api.Sunset.Value,
 			}
 		}
 		c.API = &APIConfig{
@@ -405,281 +1467,810 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 			c.Keys = append(c.Keys, Key{Name: key.Name.Value})
 		}
 	}
+	if len(y.VirtualHosts) > 0 {
+		c.VirtualHosts = make(map[string]*File, len(y.VirtualHosts))
+		for name, vhost := range y.VirtualHosts {
+			serverName := strings.ToLower(name)
+			if _, ok := c.VirtualHosts[serverName]; ok {
+				return nil, fmt.Errorf("kesconf: invalid virtual host '%s': defined multiple times", name)
+			}
+			if vhost == nil {
+				return nil, fmt.Errorf("kesconf: invalid virtual host '%s': no config specified", name)
+			}
+			if vhost.Addr.Value != "" {
+				return nil, fmt.Errorf("kesconf: invalid virtual host '%s': address is not supported", name)
+			}
+			if len(vhost.VirtualHosts) > 0 {
+				return nil, fmt.Errorf("kesconf: invalid virtual host '%s': virtual hosts are not supported", name)
+			}
+			file, err := ymlToServerConfig(vhost)
+			if err != nil {
+				return nil, fmt.Errorf("kesconf: invalid virtual host '%s': %v", name, strings.TrimPrefix(err.Error(), "kesconf: "))
+			}
+			c.VirtualHosts[serverName] = file
+		}
+	}
 	return c, nil
 }
 
-func ymlToKeyStore(y *ymlFile) (KeyStore, error) {
+// ymlToReadReplicas returns the read replicas of a keystore, like
+// endpoints, regions or DSNs. It returns an error if no replica is
+// specified, a replica is empty or the max. staleness is negative.
+func ymlToReadReplicas(replicas []env[string], maxStaleness time.Duration) ([]string, error) {
+	if len(replicas) == 0 {
+		return nil, errors.New("no read replicas specified")
+	}
+	if maxStaleness < 0 {
+		return nil, errors.New("read replica max_staleness must not be negative")
+	}
+	values := make([]string, 0, len(replicas))
+	for _, replica := range replicas {
+		if replica.Value == "" {
+			return nil, errors.New("empty read replica specified")
+		}
+		values = append(values, replica.Value)
+	}
+	return values, nil
+}
+
+func ymlToKeyStore(y *ymlKeyStore) (KeyStore, error) {
 	var keystore KeyStore
 
 	// FS Keystore
-	if y.KeyStore.FS != nil {
-		if y.KeyStore.FS.Path.Value == "" {
+	if y.FS != nil {
+		if y.FS.Path.Value == "" {
 			return nil, errors.New("kesconf: invalid fs keystore: no path specified")
 		}
+		switch y.FS.FSync.Value {
+		case "", "always", "data", "none":
+		default:
+			return nil, fmt.Errorf("kesconf: invalid fs keystore: invalid fsync mode '%s'", y.FS.FSync.Value)
+		}
 		keystore = &FSKeyStore{
-			Path: y.KeyStore.FS.Path.Value,
+			Path:     y.FS.Path.Value,
+			Sync:     y.FS.FSync.Value,
+			Sharding: y.FS.Sharding.Value,
 		}
 	}
 
 	// Encrypted FS Keystore
-	if y.KeyStore.EncryptedFS != nil {
+	if y.EncryptedFS != nil {
 		// Ensure only one keystore type is configured
 		if keystore != nil {
 			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
 		}
-		if y.KeyStore.EncryptedFS.MasterKeyPath.Value == "" {
+		if y.EncryptedFS.MasterKeyPath.Value == "" {
 			return nil, errors.New("kesconf: invalid encryptedfs keystore: no master key path specified")
 		}
-		if y.KeyStore.EncryptedFS.MasterKeyCipher.Value == "" {
+		if y.EncryptedFS.MasterKeyCipher.Value == "" {
 			return nil, errors.New("kesconf: invalid encryptedfs keystore: no master key cipher specified")
 		}
-		if y.KeyStore.EncryptedFS.Path.Value == "" {
+		if y.EncryptedFS.Path.Value == "" {
 			return nil, errors.New("kesconf: invalid encryptedfs keystore: no path specified")
 		}
+		var prevKeys []EncryptedFSMasterKey
+		for i, key := range y.EncryptedFS.PreviousMasterKeys {
+			if key.Path.Value == "" {
+				return nil, fmt.Errorf("kesconf: invalid encryptedfs keystore: no path specified for previous master key %d", i)
+			}
+			if key.Cipher.Value == "" {
+				return nil, fmt.Errorf("kesconf: invalid encryptedfs keystore: no cipher specified for previous master key %d", i)
+			}
+			prevKeys = append(prevKeys, EncryptedFSMasterKey{
+				Path:   key.Path.Value,
+				Cipher: key.Cipher.Value,
+			})
+		}
 		keystore = &EncryptedFSKeyStore{
-			MasterKeyPath:   y.KeyStore.EncryptedFS.MasterKeyPath.Value,
-			MasterKeyCipher: y.KeyStore.EncryptedFS.MasterKeyCipher.Value,
-			Path:            y.KeyStore.EncryptedFS.Path.Value,
+			MasterKeyPath:      y.EncryptedFS.MasterKeyPath.Value,
+			MasterKeyCipher:    y.EncryptedFS.MasterKeyCipher.Value,
+			Path:               y.EncryptedFS.Path.Value,
+			PreviousMasterKeys: prevKeys,
+		}
+	}
+
+	// Upstream KES Keystore
+	if y.KES != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if len(y.KES.Endpoint) == 0 {
+			return nil, errors.New("kesconf: invalid kes keystore: no endpoint specified")
+		}
+		if y.KES.Enclave.Value != "" {
+			return nil, errors.New("kesconf: invalid kes keystore: enclaves are not supported")
+		}
+		if y.KES.TLS.PrivateKey.Value == "" {
+			return nil, errors.New("kesconf: invalid kes keystore: invalid tls config: no TLS private key provided")
+		}
+		if y.KES.TLS.Certificate.Value == "" {
+			return nil, errors.New("kesconf: invalid kes keystore: invalid tls config: no TLS certificate provided")
+		}
+		endpoints := make([]string, 0, len(y.KES.Endpoint))
+		for _, endpoint := range y.KES.Endpoint {
+			if endpoint.Value == "" {
+				return nil, errors.New("kesconf: invalid kes keystore: empty endpoint")
+			}
+			endpoints = append(endpoints, endpoint.Value)
+		}
+		keystore = &KESKeyStore{
+			Endpoints:   endpoints,
+			PrivateKey:  y.KES.TLS.PrivateKey.Value,
+			Certificate: y.KES.TLS.Certificate.Value,
+			CAPath:      y.KES.TLS.CAPath.Value,
 		}
 	}
 
 	// Hashicorp Vault Keystore
-	if y.KeyStore.Vault != nil {
+	if y.Vault != nil {
 		if keystore != nil {
 			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
 		}
-		if y.KeyStore.Vault.Endpoint.Value == "" {
+		if y.Vault.Endpoint.Value == "" {
 			return nil, errors.New("kesconf: invalid vault keystore: no endpoint specified")
 		}
-		if y.KeyStore.Vault.AppRole == nil && y.KeyStore.Vault.Kubernetes == nil {
+		if y.Vault.AppRole == nil && y.Vault.Kubernetes == nil {
 			return nil, errors.New("kesconf: invalid vault keystore: no authentication method specified")
 		}
-		if y.KeyStore.Vault.AppRole != nil && y.KeyStore.Vault.Kubernetes != nil {
+		if y.Vault.AppRole != nil && y.Vault.Kubernetes != nil {
 			return nil, errors.New("kesconf: invalid vault keystore: more than one authentication method specified")
 		}
-		if y.KeyStore.Vault.AppRole != nil {
-			if y.KeyStore.Vault.AppRole.ID.Value == "" {
+		if y.Vault.AppRole != nil {
+			if y.Vault.AppRole.ID.Value == "" {
 				return nil, errors.New("kesconf: invalid vault keystore: invalid approle config: no approle ID specified")
 			}
-			if y.KeyStore.Vault.AppRole.Secret.Value == "" {
+			if y.Vault.AppRole.Secret.Value == "" {
 				return nil, errors.New("kesconf: invalid vault keystore: invalid approle config: no approle secret specified")
 			}
 		}
-		if y.KeyStore.Vault.Kubernetes != nil {
-			if y.KeyStore.Vault.Kubernetes.JWT.Value == "" {
+		if y.Vault.Kubernetes != nil {
+			if y.Vault.Kubernetes.JWT.Value == "" {
 				return nil, errors.New("kesconf: invalid vault keystore: invalid kubernetes config: no JWT specified")
 			}
 
 			// If the passed JWT value contains a path separator we assume it's a file.
 			// We always check for '/' and the OS-specific one make cover cases where
 			// a path is specified using '/' but the underlying OS is e.g. windows.
-			if jwt := y.KeyStore.Vault.Kubernetes.JWT.Value; strings.ContainsRune(jwt, '/') || strings.ContainsRune(jwt, os.PathSeparator) {
-				_, err := os.ReadFile(y.KeyStore.Vault.Kubernetes.JWT.Value)
+			if jwt := y.Vault.Kubernetes.JWT.Value; strings.ContainsRune(jwt, '/') || strings.ContainsRune(jwt, os.PathSeparator) {
+				_, err := os.ReadFile(y.Vault.Kubernetes.JWT.Value)
 				if err != nil {
-					return nil, fmt.Errorf("kesconf: failed to read vault kubernetes JWT from '%s': %v", y.KeyStore.Vault.Kubernetes.JWT.Value, err)
+					return nil, fmt.Errorf("kesconf: failed to read vault kubernetes JWT from '%s': %v", y.Vault.Kubernetes.JWT.Value, err)
 				}
 				// postpone resolving the JWT until actually logging in
 			}
 		}
-		if y.KeyStore.Vault.Transit != nil {
-			if y.KeyStore.Vault.Transit.KeyName.Value == "" {
+		if y.Vault.Transit != nil {
+			if y.Vault.Transit.KeyName.Value == "" {
 				return nil, errors.New("kesconf: invalid vault keystore: invalid transit config: no key name specified")
 			}
+			if y.Vault.Transit.KeyStore != nil && (y.Vault.DeleteVersionAfter.Value > 0 || y.Vault.PinnedVersion.Value > 0 || y.Vault.ReadReplicas != nil) {
+				return nil, errors.New("kesconf: invalid vault keystore: invalid transit config: delete_version_after, pinned_version and read_replicas cannot be used with a transit keystore")
+			}
 		}
 
-		if y.KeyStore.Vault.TLS.PrivateKey.Value != "" && y.KeyStore.Vault.TLS.Certificate.Value == "" {
+		if y.Vault.DeleteVersionAfter.Value < 0 {
+			return nil, errors.New("kesconf: invalid vault keystore: delete_version_after is negative")
+		}
+		if y.Vault.PinnedVersion.Value < 0 {
+			return nil, errors.New("kesconf: invalid vault keystore: pinned_version is negative")
+		}
+		if y.Vault.APIVersion.Value != "v2" && (y.Vault.DeleteVersionAfter.Value > 0 || y.Vault.PinnedVersion.Value > 0) {
+			return nil, errors.New("kesconf: invalid vault keystore: delete_version_after and pinned_version require K/V engine version 'v2'")
+		}
+		if y.Vault.TLS.PrivateKey.Value != "" && y.Vault.TLS.Certificate.Value == "" {
 			return nil, errors.New("kesconf: invalid vault keystore: invalid tls config: no TLS certificate provided")
 		}
-		if y.KeyStore.Vault.TLS.PrivateKey.Value == "" && y.KeyStore.Vault.TLS.Certificate.Value != "" {
+		if y.Vault.TLS.PrivateKey.Value == "" && y.Vault.TLS.Certificate.Value != "" {
 			return nil, errors.New("kesconf: invalid vault keystore: invalid tls config: no TLS private key provided")
 		}
 		s := &VaultKeyStore{
-			Endpoint:    y.KeyStore.Vault.Endpoint.Value,
-			Namespace:   y.KeyStore.Vault.Namespace.Value,
-			APIVersion:  y.KeyStore.Vault.APIVersion.Value,
-			Engine:      y.KeyStore.Vault.Engine.Value,
-			Prefix:      y.KeyStore.Vault.Prefix.Value,
-			PrivateKey:  y.KeyStore.Vault.TLS.PrivateKey.Value,
-			Certificate: y.KeyStore.Vault.TLS.Certificate.Value,
-			CAPath:      y.KeyStore.Vault.TLS.CAPath.Value,
-			StatusPing:  y.KeyStore.Vault.Status.Ping.Value,
-		}
-		if y.KeyStore.Vault.AppRole != nil {
+			Endpoint:    y.Vault.Endpoint.Value,
+			Namespace:   y.Vault.Namespace.Value,
+			APIVersion:  y.Vault.APIVersion.Value,
+			Engine:      y.Vault.Engine.Value,
+			Prefix:      y.Vault.Prefix.Value,
+			PrivateKey:  y.Vault.TLS.PrivateKey.Value,
+			Certificate: y.Vault.TLS.Certificate.Value,
+			CAPath:      y.Vault.TLS.CAPath.Value,
+			StatusPing:  y.Vault.Status.Ping.Value,
+
+			DeleteVersionAfter: y.Vault.DeleteVersionAfter.Value,
+			PinnedVersion:      y.Vault.PinnedVersion.Value,
+		}
+		if y.Vault.AppRole != nil {
 			s.AppRole = &VaultAppRoleAuth{
-				Engine:    y.KeyStore.Vault.AppRole.Engine.Value,
-				Namespace: y.KeyStore.Vault.AppRole.Namespace.Value,
-				ID:        y.KeyStore.Vault.AppRole.ID.Value,
-				Secret:    y.KeyStore.Vault.AppRole.Secret.Value,
+				Engine:    y.Vault.AppRole.Engine.Value,
+				Namespace: y.Vault.AppRole.Namespace.Value,
+				ID:        y.Vault.AppRole.ID.Value,
+				Secret:    y.Vault.AppRole.Secret.Value,
 			}
 		}
-		if y.KeyStore.Vault.Kubernetes != nil {
+		if y.Vault.Kubernetes != nil {
 			s.Kubernetes = &VaultKubernetesAuth{
-				Engine:    y.KeyStore.Vault.Kubernetes.Engine.Value,
-				Namespace: y.KeyStore.Vault.Kubernetes.Namespace.Value,
-				JWT:       y.KeyStore.Vault.Kubernetes.JWT.Value,
-				Role:      y.KeyStore.Vault.Kubernetes.Role.Value,
+				Engine:    y.Vault.Kubernetes.Engine.Value,
+				Namespace: y.Vault.Kubernetes.Namespace.Value,
+				JWT:       y.Vault.Kubernetes.JWT.Value,
+				Role:      y.Vault.Kubernetes.Role.Value,
 			}
 		}
-		if y.KeyStore.Vault.Transit != nil {
+		if y.Vault.Transit != nil {
 			s.Transit = &VaultTransit{
-				Engine:  y.KeyStore.Vault.Transit.Engine.Value,
-				KeyName: y.KeyStore.Vault.Transit.KeyName.Value,
+				Engine:  y.Vault.Transit.Engine.Value,
+				KeyName: y.Vault.Transit.KeyName.Value,
+			}
+			if y.Vault.Transit.KeyStore != nil {
+				store, err := ymlToKeyStore(y.Vault.Transit.KeyStore)
+				if err != nil {
+					return nil, fmt.Errorf("kesconf: invalid vault keystore: invalid transit config: %v", strings.TrimPrefix(err.Error(), "kesconf: "))
+				}
+				s.Transit.KeyStore = store
 			}
 		}
+		if y.Vault.ReadReplicas != nil {
+			endpoints, err := ymlToReadReplicas(y.Vault.ReadReplicas.Endpoints, y.Vault.ReadReplicas.MaxStaleness.Value)
+			if err != nil {
+				return nil, fmt.Errorf("kesconf: invalid vault keystore: %v", err)
+			}
+			s.ReadEndpoints = endpoints
+			s.MaxStaleness = y.Vault.ReadReplicas.MaxStaleness.Value
+		}
 		keystore = s
 	}
 
 	// Fortanix SDKMS
-	if y.KeyStore.Fortanix != nil && y.KeyStore.Fortanix.SDKMS != nil {
+	if y.Fortanix != nil && y.Fortanix.SDKMS != nil {
 		if keystore != nil {
 			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
 		}
-		if y.KeyStore.Fortanix.SDKMS.Endpoint.Value == "" {
+		if y.Fortanix.SDKMS.Endpoint.Value == "" {
 			return nil, errors.New("kesconf: invalid fortanix SDKMS keystore: no endpoint specified")
 		}
-		if y.KeyStore.Fortanix.SDKMS.Login.APIKey.Value == "" {
+		if y.Fortanix.SDKMS.Login.APIKey.Value == "" {
 			return nil, errors.New("kesconf: invalid fortanix SDKMS keystore: no API key specified")
 		}
 		keystore = &FortanixKeyStore{
-			Endpoint: y.KeyStore.Fortanix.SDKMS.Endpoint.Value,
-			GroupID:  y.KeyStore.Fortanix.SDKMS.GroupID.Value,
-			APIKey:   y.KeyStore.Fortanix.SDKMS.Login.APIKey.Value,
-			CAPath:   y.KeyStore.Fortanix.SDKMS.TLS.CAPath.Value,
+			Endpoint: y.Fortanix.SDKMS.Endpoint.Value,
+			GroupID:  y.Fortanix.SDKMS.GroupID.Value,
+			APIKey:   y.Fortanix.SDKMS.Login.APIKey.Value,
+			CAPath:   y.Fortanix.SDKMS.TLS.CAPath.Value,
 		}
 	}
 
 	// Thales CipherTrust / Gemalto KeySecure
-	if y.KeyStore.Gemalto != nil && y.KeyStore.Gemalto.KeySecure != nil {
+	if y.Gemalto != nil && y.Gemalto.KeySecure != nil {
 		if keystore != nil {
 			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
 		}
-		if y.KeyStore.Gemalto.KeySecure.Endpoint.Value == "" {
+		if y.Gemalto.KeySecure.Endpoint.Value == "" {
 			return nil, errors.New("kesconf: invalid gemalto keysecure keystore: no endpoint specified")
 		}
-		if y.KeyStore.Gemalto.KeySecure.Login.Token.Value == "" {
+		if y.Gemalto.KeySecure.Login.Token.Value == "" {
 			return nil, errors.New("kesconf: invalid gemalto keysecure keystore: no token specified")
 		}
+		if y.Gemalto.KeySecure.PoolSize.Value < 0 {
+			return nil, fmt.Errorf("kesconf: invalid gemalto keysecure keystore: invalid pool size '%d'", y.Gemalto.KeySecure.PoolSize.Value)
+		}
 		keystore = &KeySecureKeyStore{
-			Endpoint: y.KeyStore.Gemalto.KeySecure.Endpoint.Value,
-			Token:    y.KeyStore.Gemalto.KeySecure.Login.Token.Value,
-			Domain:   y.KeyStore.Gemalto.KeySecure.Login.Domain.Value,
-			CAPath:   y.KeyStore.Gemalto.KeySecure.TLS.CAPath.Value,
+			Endpoint: y.Gemalto.KeySecure.Endpoint.Value,
+			Token:    y.Gemalto.KeySecure.Login.Token.Value,
+			Domain:   y.Gemalto.KeySecure.Login.Domain.Value,
+			Retry:    y.Gemalto.KeySecure.Login.Retry.Value,
+			CAPath:   y.Gemalto.KeySecure.TLS.CAPath.Value,
+			PoolSize: y.Gemalto.KeySecure.PoolSize.Value,
 		}
 	}
 
 	// GCP SecretManager
-	if y.KeyStore.GCP != nil && y.KeyStore.GCP.SecretManager != nil {
+	if y.GCP != nil && y.GCP.SecretManager != nil {
 		if keystore != nil {
 			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
 		}
-		if y.KeyStore.GCP.SecretManager.ProjectID.Value == "" {
+		if y.GCP.SecretManager.ProjectID.Value == "" {
 			return nil, errors.New("kesconf: invalid GCP secretmanager keystore: no project ID specified")
 		}
 		var scopes []string
-		if len(y.KeyStore.GCP.SecretManager.Scopes) > 0 {
+		if len(y.GCP.SecretManager.Scopes) > 0 {
 			scopes = make([]string, 0, len(scopes))
-			for _, scope := range y.KeyStore.GCP.SecretManager.Scopes {
+			for _, scope := range y.GCP.SecretManager.Scopes {
 				scopes = append(scopes, scope.Value)
 			}
 		}
-		keystore = &GCPSecretManagerKeyStore{
-			ProjectID:   y.KeyStore.GCP.SecretManager.ProjectID.Value,
-			Endpoint:    y.KeyStore.GCP.SecretManager.Endpoint.Value,
-			ClientEmail: y.KeyStore.GCP.SecretManager.Credentials.Client.Value,
-			ClientID:    y.KeyStore.GCP.SecretManager.Credentials.ClientID.Value,
-			KeyID:       y.KeyStore.GCP.SecretManager.Credentials.KeyID.Value,
-			Key:         y.KeyStore.GCP.SecretManager.Credentials.Key.Value,
+		s := &GCPSecretManagerKeyStore{
+			ProjectID:   y.GCP.SecretManager.ProjectID.Value,
+			Region:      y.GCP.SecretManager.Region.Value,
+			Endpoint:    y.GCP.SecretManager.Endpoint.Value,
+			ClientEmail: y.GCP.SecretManager.Credentials.Client.Value,
+			ClientID:    y.GCP.SecretManager.Credentials.ClientID.Value,
+			KeyID:       y.GCP.SecretManager.Credentials.KeyID.Value,
+			Key:         y.GCP.SecretManager.Credentials.Key.Value,
 			Scopes:      scopes,
 		}
+		if y.GCP.SecretManager.WorkloadIdentity != nil {
+			if y.GCP.SecretManager.WorkloadIdentity.CredentialsFile.Value == "" {
+				return nil, errors.New("kesconf: invalid GCP secretmanager keystore: no workload identity credentials file specified")
+			}
+			if s.ClientEmail != "" || s.ClientID != "" || s.KeyID != "" || s.Key != "" {
+				return nil, errors.New("kesconf: invalid GCP secretmanager keystore: service account credentials and workload identity specified")
+			}
+			s.CredentialsFile = y.GCP.SecretManager.WorkloadIdentity.CredentialsFile.Value
+		}
+		if len(y.GCP.SecretManager.Labels) > 0 {
+			s.Labels = make(map[string]string, len(y.GCP.SecretManager.Labels))
+			for key, value := range y.GCP.SecretManager.Labels {
+				s.Labels[key] = value.Value
+			}
+		}
+		if y.GCP.SecretManager.Replication != nil {
+			s.KMSKey = y.GCP.SecretManager.Replication.KMSKey.Value
+			for _, r := range y.GCP.SecretManager.Replication.Replicas {
+				if r.Location.Value == "" {
+					return nil, errors.New("kesconf: invalid GCP secretmanager keystore: replica without location specified")
+				}
+				s.Replicas = append(s.Replicas, GCPReplica{
+					Location: r.Location.Value,
+					KMSKey:   r.KMSKey.Value,
+				})
+			}
+			if s.KMSKey != "" && len(s.Replicas) > 0 {
+				return nil, errors.New("kesconf: invalid GCP secretmanager keystore: replication KMS key and replicas specified. Specify a KMS key per replica instead")
+			}
+			if s.Region != "" && len(s.Replicas) > 0 {
+				return nil, errors.New("kesconf: invalid GCP secretmanager keystore: region and replicas specified. Regional secrets cannot be replicated")
+			}
+		}
+		keystore = s
+	}
+
+	// GCP KMS
+	if y.GCP != nil && y.GCP.KMS != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if y.GCP.KMS.Key.Value == "" {
+			return nil, errors.New("kesconf: invalid GCP kms keystore: no key specified")
+		}
+		if y.GCP.KMS.KeyStore == nil {
+			return nil, errors.New("kesconf: invalid GCP kms keystore: no keystore specified")
+		}
+		store, err := ymlToKeyStore(y.GCP.KMS.KeyStore)
+		if err != nil {
+			return nil, fmt.Errorf("kesconf: invalid GCP kms keystore: %v", strings.TrimPrefix(err.Error(), "kesconf: "))
+		}
+		s := &GCPKMSKeyStore{
+			Endpoint:     y.GCP.KMS.Endpoint.Value,
+			Key:          y.GCP.KMS.Key.Value,
+			RequireHSM:   y.GCP.KMS.RequireHSM.Value,
+			ClientEmail:  y.GCP.KMS.Credentials.Client.Value,
+			ClientID:     y.GCP.KMS.Credentials.ClientID.Value,
+			PrivateKeyID: y.GCP.KMS.Credentials.KeyID.Value,
+			PrivateKey:   y.GCP.KMS.Credentials.Key.Value,
+			KeyStore:     store,
+		}
+		if y.GCP.KMS.WorkloadIdentity != nil {
+			if y.GCP.KMS.WorkloadIdentity.CredentialsFile.Value == "" {
+				return nil, errors.New("kesconf: invalid GCP kms keystore: no workload identity credentials file specified")
+			}
+			if s.ClientEmail != "" || s.ClientID != "" || s.PrivateKeyID != "" || s.PrivateKey != "" {
+				return nil, errors.New("kesconf: invalid GCP kms keystore: service account credentials and workload identity specified")
+			}
+			s.CredentialsFile = y.GCP.KMS.WorkloadIdentity.CredentialsFile.Value
+		}
+		keystore = s
 	}
 
 	// AWS SecretsManager
-	if y.KeyStore.AWS != nil && y.KeyStore.AWS.SecretsManager != nil {
+	if y.AWS != nil && y.AWS.SecretsManager != nil {
 		if keystore != nil {
 			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
 		}
-		if y.KeyStore.AWS.SecretsManager.Endpoint.Value == "" {
+		variant := y.AWS.SecretsManager.UseFIPSEndpoint.Value || y.AWS.SecretsManager.UseDualStackEndpoint.Value
+		if y.AWS.SecretsManager.Endpoint.Value == "" && !variant {
 			return nil, errors.New("kesconf: invalid AWS secretsmanager keystore: no endpoint specified")
 		}
-		if y.KeyStore.AWS.SecretsManager.Region.Value == "" {
+		if y.AWS.SecretsManager.Endpoint.Value != "" && variant {
+			return nil, errors.New("kesconf: invalid AWS secretsmanager keystore: FIPS and dual-stack endpoints cannot be combined with an endpoint")
+		}
+		if y.AWS.SecretsManager.Region.Value == "" {
 			return nil, errors.New("kesconf: invalid AWS secretsmanager keystore: no region specified")
 		}
-		keystore = &AWSSecretsManagerKeyStore{
-			Endpoint:     y.KeyStore.AWS.SecretsManager.Endpoint.Value,
-			Region:       y.KeyStore.AWS.SecretsManager.Region.Value,
-			KMSKey:       y.KeyStore.AWS.SecretsManager.KmsKey.Value,
-			AccessKey:    y.KeyStore.AWS.SecretsManager.Login.AccessKey.Value,
-			SecretKey:    y.KeyStore.AWS.SecretsManager.Login.SecretKey.Value,
-			SessionToken: y.KeyStore.AWS.SecretsManager.Login.SessionToken.Value,
+		if role := y.AWS.SecretsManager.AssumeRole; role.RoleARN.Value == "" && (role.ExternalID.Value != "" || role.SessionName.Value != "" || role.TokenFile.Value != "") {
+			return nil, errors.New("kesconf: invalid AWS secretsmanager keystore: no role ARN specified")
+		}
+		if role := y.AWS.SecretsManager.AssumeRole; role.TokenFile.Value != "" && role.ExternalID.Value != "" {
+			return nil, errors.New("kesconf: invalid AWS secretsmanager keystore: external ID is not supported with web identity token file")
+		}
+		if retry := y.AWS.SecretsManager.Retry; retry.Mode.Value != "" && retry.Mode.Value != "standard" && retry.Mode.Value != "adaptive" {
+			return nil, fmt.Errorf("kesconf: invalid AWS secretsmanager keystore: invalid retry mode '%s'", retry.Mode.Value)
+		}
+		if timeout := y.AWS.SecretsManager.Timeout; timeout.Dial.Value < 0 || timeout.Response.Value < 0 {
+			return nil, errors.New("kesconf: invalid AWS secretsmanager keystore: timeouts must not be negative")
+		}
+		if retry := y.AWS.SecretsManager.Retry; retry.MaxAttempts.Value < 0 || retry.MaxBackoff.Value < 0 {
+			return nil, errors.New("kesconf: invalid AWS secretsmanager keystore: retry max attempts and max backoff must not be negative")
+		}
+		s := &AWSSecretsManagerKeyStore{
+			Endpoint:             y.AWS.SecretsManager.Endpoint.Value,
+			Region:               y.AWS.SecretsManager.Region.Value,
+			KMSKey:               y.AWS.SecretsManager.KmsKey.Value,
+			Prefix:               y.AWS.SecretsManager.Prefix.Value,
+			Overwrite:            y.AWS.SecretsManager.Overwrite.Value,
+			Binary:               y.AWS.SecretsManager.Binary.Value,
+			PlainHTTP:            y.AWS.SecretsManager.PlainHTTP.Value,
+			InsecureSkipVerify:   y.AWS.SecretsManager.InsecureSkipVerify.Value,
+			Proxy:                y.AWS.SecretsManager.Proxy.Value,
+			CAPath:               y.AWS.SecretsManager.TLS.CAPath.Value,
+			DialTimeout:          y.AWS.SecretsManager.Timeout.Dial.Value,
+			ResponseTimeout:      y.AWS.SecretsManager.Timeout.Response.Value,
+			UseFIPSEndpoint:      y.AWS.SecretsManager.UseFIPSEndpoint.Value,
+			UseDualStackEndpoint: y.AWS.SecretsManager.UseDualStackEndpoint.Value,
+			RetryMode:            y.AWS.SecretsManager.Retry.Mode.Value,
+			RetryMaxAttempts:     y.AWS.SecretsManager.Retry.MaxAttempts.Value,
+			RetryMaxBackoff:      y.AWS.SecretsManager.Retry.MaxBackoff.Value,
+			AccessKey:            y.AWS.SecretsManager.Login.AccessKey.Value,
+			SecretKey:            y.AWS.SecretsManager.Login.SecretKey.Value,
+			SessionToken:         y.AWS.SecretsManager.Login.SessionToken.Value,
+			RoleARN:              y.AWS.SecretsManager.AssumeRole.RoleARN.Value,
+			ExternalID:           y.AWS.SecretsManager.AssumeRole.ExternalID.Value,
+			SessionName:          y.AWS.SecretsManager.AssumeRole.SessionName.Value,
+			WebIdentityTokenFile: y.AWS.SecretsManager.AssumeRole.TokenFile.Value,
+		}
+		if len(y.AWS.SecretsManager.Tags) > 0 {
+			s.Tags = make(map[string]string, len(y.AWS.SecretsManager.Tags))
+			for key, value := range y.AWS.SecretsManager.Tags {
+				s.Tags[key] = value.Value
+			}
+		}
+		for _, r := range y.AWS.SecretsManager.Replicas {
+			if r.Region.Value == "" {
+				return nil, errors.New("kesconf: invalid AWS secretsmanager keystore: replica contains no region")
+			}
+			s.Replicas = append(s.Replicas, AWSReplica{
+				Region: r.Region.Value,
+				KMSKey: r.KmsKey.Value,
+			})
+		}
+		keystore = s
+	}
+
+	// AWS KMS
+	if y.AWS != nil && y.AWS.KMS != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if y.AWS.KMS.Path.Value == "" {
+			return nil, errors.New("kesconf: invalid AWS kms keystore: no path specified")
+		}
+		if y.AWS.KMS.Region.Value == "" {
+			return nil, errors.New("kesconf: invalid AWS kms keystore: no region specified")
+		}
+		if y.AWS.KMS.Key.Value == "" {
+			return nil, errors.New("kesconf: invalid AWS kms keystore: no key specified")
+		}
+		keystore = &AWSKMSKeyStore{
+			Path:         y.AWS.KMS.Path.Value,
+			Endpoint:     y.AWS.KMS.Endpoint.Value,
+			Region:       y.AWS.KMS.Region.Value,
+			KeyID:        y.AWS.KMS.Key.Value,
+			AccessKey:    y.AWS.KMS.Login.AccessKey.Value,
+			SecretKey:    y.AWS.KMS.Login.SecretKey.Value,
+			SessionToken: y.AWS.KMS.Login.SessionToken.Value,
+		}
+	}
+
+	// AWS SSM Parameter Store
+	if y.AWS != nil && y.AWS.SSM != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if y.AWS.SSM.Region.Value == "" {
+			return nil, errors.New("kesconf: invalid AWS ssm keystore: no region specified")
+		}
+		if path := y.AWS.SSM.Path.Value; path != "" && !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("kesconf: invalid AWS ssm keystore: path '%s' does not start with '/'", path)
+		}
+		keystore = &AWSSSMKeyStore{
+			Endpoint:     y.AWS.SSM.Endpoint.Value,
+			Region:       y.AWS.SSM.Region.Value,
+			Path:         y.AWS.SSM.Path.Value,
+			KMSKey:       y.AWS.SSM.KMSKey.Value,
+			Tier:         y.AWS.SSM.Tier.Value,
+			AccessKey:    y.AWS.SSM.Login.AccessKey.Value,
+			SecretKey:    y.AWS.SSM.Login.SecretKey.Value,
+			SessionToken: y.AWS.SSM.Login.SessionToken.Value,
+		}
+	}
+
+	// AWS DynamoDB
+	if y.AWS != nil && y.AWS.DynamoDB != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if y.AWS.DynamoDB.Region.Value == "" {
+			return nil, errors.New("kesconf: invalid AWS dynamodb keystore: no region specified")
+		}
+		if y.AWS.DynamoDB.Table.Value == "" {
+			return nil, errors.New("kesconf: invalid AWS dynamodb keystore: no table specified")
+		}
+		s := &AWSDynamoDBKeyStore{
+			Endpoint:            y.AWS.DynamoDB.Endpoint.Value,
+			Region:              y.AWS.DynamoDB.Region.Value,
+			Table:               y.AWS.DynamoDB.Table.Value,
+			EventualConsistency: y.AWS.DynamoDB.EventualConsistency.Value,
+			AccessKey:           y.AWS.DynamoDB.Login.AccessKey.Value,
+			SecretKey:           y.AWS.DynamoDB.Login.SecretKey.Value,
+			SessionToken:        y.AWS.DynamoDB.Login.SessionToken.Value,
+		}
+		if y.AWS.DynamoDB.ReadReplicas != nil {
+			regions, err := ymlToReadReplicas(y.AWS.DynamoDB.ReadReplicas.Regions, y.AWS.DynamoDB.ReadReplicas.MaxStaleness.Value)
+			if err != nil {
+				return nil, fmt.Errorf("kesconf: invalid AWS dynamodb keystore: %v", err)
+			}
+			s.ReadRegions = regions
+			s.MaxStaleness = y.AWS.DynamoDB.ReadReplicas.MaxStaleness.Value
+		}
+		keystore = s
+	}
+
+	// AWS CloudHSM
+	if y.AWS != nil && y.AWS.CloudHSM != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if y.AWS.CloudHSM.Key.Value == "" {
+			return nil, errors.New("kesconf: invalid AWS cloudhsm keystore: no key label specified")
+		}
+		if y.AWS.CloudHSM.KeyStore == nil {
+			return nil, errors.New("kesconf: invalid AWS cloudhsm keystore: no keystore specified")
+		}
+		store, err := ymlToKeyStore(y.AWS.CloudHSM.KeyStore)
+		if err != nil {
+			return nil, fmt.Errorf("kesconf: invalid AWS cloudhsm keystore: %v", strings.TrimPrefix(err.Error(), "kesconf: "))
+		}
+		keystore = &AWSCloudHSMKeyStore{
+			Library:    y.AWS.CloudHSM.Library.Value,
+			TokenLabel: y.AWS.CloudHSM.Token.Value,
+			PIN:        y.AWS.CloudHSM.PIN.Value,
+			KeyLabel:   y.AWS.CloudHSM.Key.Value,
+			KeyStore:   store,
 		}
 	}
 
 	// Azure KeyVault
-	if y.KeyStore.Azure != nil && y.KeyStore.Azure.KeyVault != nil {
+	if y.Azure != nil && y.Azure.KeyVault != nil {
 		if keystore != nil {
 			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
 		}
-		if y.KeyStore.Azure.KeyVault.Endpoint.Value == "" {
+		if y.Azure.KeyVault.Endpoint.Value == "" {
 			return nil, errors.New("kesconf: invalid Azure keyvault keystore: no endpoint specified")
 		}
-		if y.KeyStore.Azure.KeyVault.Credentials != nil && y.KeyStore.Azure.KeyVault.ManagedIdentity != nil {
+		var methods int
+		for _, ok := range []bool{
+			y.Azure.KeyVault.Credentials != nil,
+			y.Azure.KeyVault.ClientCertificate != nil,
+			y.Azure.KeyVault.ManagedIdentity != nil,
+			y.Azure.KeyVault.WorkloadIdentity != nil,
+		} {
+			if ok {
+				methods++
+			}
+		}
+		if methods > 1 {
 			return nil, errors.New("kesconf: invalid Azure keyvault keystore: more than one authentication method specified")
 		}
-		if y.KeyStore.Azure.KeyVault.Credentials != nil {
-			if y.KeyStore.Azure.KeyVault.Credentials.TenantID.Value == "" {
+		if y.Azure.KeyVault.Credentials != nil {
+			if y.Azure.KeyVault.Credentials.TenantID.Value == "" {
 				return nil, errors.New("kesconf: invalid Azure keyvault keystore: no tenant ID specified")
 			}
-			if y.KeyStore.Azure.KeyVault.Credentials.ClientID.Value == "" {
+			if y.Azure.KeyVault.Credentials.ClientID.Value == "" {
 				return nil, errors.New("kesconf: invalid Azure keyvault keystore: no client ID specified")
 			}
-			if y.KeyStore.Azure.KeyVault.Credentials.Secret.Value == "" {
+			if y.Azure.KeyVault.Credentials.Secret.Value == "" {
 				return nil, errors.New("kesconf: invalid Azure keyvault keystore: no client secret specified")
 			}
 		}
-		if y.KeyStore.Azure.KeyVault.ManagedIdentity != nil {
-			if y.KeyStore.Azure.KeyVault.ManagedIdentity.ClientID.Value == "" {
-				return nil, errors.New("kesconf: invalid Azure keyvault keystore: no client ID specified")
+		if y.Azure.KeyVault.ClientCertificate != nil {
+			if y.Azure.KeyVault.ClientCertificate.TenantID.Value == "" {
+				return nil, errors.New("kesconf: invalid Azure keyvault keystore: no client certificate tenant ID specified")
+			}
+			if y.Azure.KeyVault.ClientCertificate.ClientID.Value == "" {
+				return nil, errors.New("kesconf: invalid Azure keyvault keystore: no client certificate client ID specified")
+			}
+			if y.Azure.KeyVault.ClientCertificate.File.Value == "" {
+				return nil, errors.New("kesconf: invalid Azure keyvault keystore: no client certificate file specified")
 			}
 		}
-		s := &AzureKeyVaultKeyStore{
-			Endpoint: y.KeyStore.Azure.KeyVault.Endpoint.Value,
+		if y.Azure.KeyVault.ManagedIdentity != nil {
+			if y.Azure.KeyVault.ManagedIdentity.ClientID.Value != "" && y.Azure.KeyVault.ManagedIdentity.ResourceID.Value != "" {
+				return nil, errors.New("kesconf: invalid Azure keyvault keystore: managed identity client ID and resource ID specified")
+			}
+		}
+		cloud, err := azure.LookupCloud(y.Azure.KeyVault.Cloud.Value, y.Azure.KeyVault.AuthorityHost.Value)
+		if err != nil {
+			return nil, fmt.Errorf("kesconf: invalid Azure keyvault keystore: %v", strings.TrimPrefix(err.Error(), "azure: "))
 		}
-		if y.KeyStore.Azure.KeyVault.Credentials != nil {
-			s.TenantID = y.KeyStore.Azure.KeyVault.Credentials.TenantID.Value
-			s.ClientID = y.KeyStore.Azure.KeyVault.Credentials.ClientID.Value
-			s.ClientSecret = y.KeyStore.Azure.KeyVault.Credentials.Secret.Value
+		if err = cloud.CheckEndpoint(y.Azure.KeyVault.Endpoint.Value); err != nil {
+			return nil, fmt.Errorf("kesconf: invalid Azure keyvault keystore: %v", strings.TrimPrefix(err.Error(), "azure: "))
 		}
-		if y.KeyStore.Azure.KeyVault.ManagedIdentity != nil {
-			s.ManagedIdentityClientID = y.KeyStore.Azure.KeyVault.ManagedIdentity.ClientID.Value
+		s := &AzureKeyVaultKeyStore{
+			Endpoint:      y.Azure.KeyVault.Endpoint.Value,
+			Cloud:         y.Azure.KeyVault.Cloud.Value,
+			AuthorityHost: y.Azure.KeyVault.AuthorityHost.Value,
+		}
+		if y.Azure.KeyVault.Credentials != nil {
+			s.TenantID = y.Azure.KeyVault.Credentials.TenantID.Value
+			s.ClientID = y.Azure.KeyVault.Credentials.ClientID.Value
+			s.ClientSecret = y.Azure.KeyVault.Credentials.Secret.Value
+		}
+		if y.Azure.KeyVault.ClientCertificate != nil {
+			s.TenantID = y.Azure.KeyVault.ClientCertificate.TenantID.Value
+			s.ClientID = y.Azure.KeyVault.ClientCertificate.ClientID.Value
+			s.ClientCertificateFile = y.Azure.KeyVault.ClientCertificate.File.Value
+			s.ClientCertificatePassword = y.Azure.KeyVault.ClientCertificate.Password.Value
+			s.ClientCertificateSendChain = y.Azure.KeyVault.ClientCertificate.SendChain.Value
+		}
+		if y.Azure.KeyVault.ManagedIdentity != nil {
+			s.ManagedIdentity = true
+			s.ManagedIdentityClientID = y.Azure.KeyVault.ManagedIdentity.ClientID.Value
+			s.ManagedIdentityResourceID = y.Azure.KeyVault.ManagedIdentity.ResourceID.Value
+		}
+		if y.Azure.KeyVault.WorkloadIdentity != nil {
+			s.WorkloadIdentity = true
+			s.WorkloadIdentityTenantID = y.Azure.KeyVault.WorkloadIdentity.TenantID.Value
+			s.WorkloadIdentityClientID = y.Azure.KeyVault.WorkloadIdentity.ClientID.Value
+			s.WorkloadIdentityTokenFile = y.Azure.KeyVault.WorkloadIdentity.TokenFile.Value
+		}
+		if y.Azure.KeyVault.SoftDelete != nil {
+			s.RecoverDeleted = y.Azure.KeyVault.SoftDelete.Recover.Value
+		}
+		if azure.IsManagedHSM(s.Endpoint) && y.Azure.KeyVault.ManagedHSM == nil {
+			return nil, errors.New("kesconf: invalid Azure keyvault keystore: endpoint is a Managed HSM but no managed_hsm key specified")
+		}
+		if hsm := y.Azure.KeyVault.ManagedHSM; hsm != nil {
+			if !azure.IsManagedHSM(s.Endpoint) {
+				return nil, fmt.Errorf("kesconf: invalid Azure keyvault keystore: endpoint '%s' is not a Managed HSM", s.Endpoint)
+			}
+			if y.Azure.KeyVault.SoftDelete != nil {
+				return nil, errors.New("kesconf: invalid Azure keyvault keystore: soft_delete and managed_hsm specified")
+			}
+			if hsm.Key.Value == "" {
+				return nil, errors.New("kesconf: invalid Azure keyvault keystore: no Managed HSM key specified")
+			}
+			if hsm.KeyStore == nil {
+				return nil, errors.New("kesconf: invalid Azure keyvault keystore: no Managed HSM keystore specified")
+			}
+			store, err := ymlToKeyStore(hsm.KeyStore)
+			if err != nil {
+				return nil, fmt.Errorf("kesconf: invalid Azure keyvault keystore: %v", strings.TrimPrefix(err.Error(), "kesconf: "))
+			}
+			s.HSMKey = hsm.Key.Value
+			s.HSMKeyStore = store
 		}
 		keystore = s
 	}
-	if y.KeyStore.Entrust != nil && y.KeyStore.Entrust.KeyControl != nil {
+	if y.Entrust != nil && y.Entrust.KeyControl != nil {
 		if keystore != nil {
 			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
 		}
-		if y.KeyStore.Entrust.KeyControl.Endpoint.Value == "" {
+		if y.Entrust.KeyControl.Endpoint.Value == "" {
 			return nil, errors.New("kesconf: invalid Entrust KeyControl keystore: no endpoint specified")
 		}
-		if y.KeyStore.Entrust.KeyControl.VaultID.Value == "" {
+		if y.Entrust.KeyControl.VaultID.Value == "" {
 			return nil, errors.New("kesconf: invalid Entrust KeyControl keystore: no vault ID specified")
 		}
-		if y.KeyStore.Entrust.KeyControl.BoxID.Value == "" {
+		if y.Entrust.KeyControl.BoxID.Value == "" {
 			return nil, errors.New("kesconf: invalid Entrust KeyControl keystore: no box ID specified")
 		}
-		if y.KeyStore.Entrust.KeyControl.Login.Username.Value == "" {
+		if y.Entrust.KeyControl.Login.Username.Value == "" {
 			return nil, errors.New("kesconf: invalid Entrust KeyControl keystore: no username specified")
 		}
-		if y.KeyStore.Entrust.KeyControl.Login.Password.Value == "" {
+		if y.Entrust.KeyControl.Login.Password.Value == "" {
 			return nil, errors.New("kesconf: invalid Entrust KeyControl keystore: no password specified")
 		}
 		keystore = &EntrustKeyControlKeyStore{
-			Endpoint: y.KeyStore.Entrust.KeyControl.Endpoint.Value,
-			VaultID:  y.KeyStore.Entrust.KeyControl.VaultID.Value,
-			BoxID:    y.KeyStore.Entrust.KeyControl.BoxID.Value,
-			Username: y.KeyStore.Entrust.KeyControl.Login.Username.Value,
-			Password: y.KeyStore.Entrust.KeyControl.Login.Password.Value,
-			CAPath:   y.KeyStore.Entrust.KeyControl.TLS.CAPath.Value,
+			Endpoint: y.Entrust.KeyControl.Endpoint.Value,
+			VaultID:  y.Entrust.KeyControl.VaultID.Value,
+			BoxID:    y.Entrust.KeyControl.BoxID.Value,
+			Username: y.Entrust.KeyControl.Login.Username.Value,
+			Password: y.Entrust.KeyControl.Login.Password.Value,
+			CAPath:   y.Entrust.KeyControl.TLS.CAPath.Value,
+		}
+	}
+
+	if y.SQL != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if y.SQL.Driver.Value == "" {
+			return nil, errors.New("kesconf: invalid SQL keystore: no driver specified")
+		}
+		if y.SQL.DSN.Value == "" {
+			return nil, errors.New("kesconf: invalid SQL keystore: no DSN specified")
+		}
+		switch strings.ToLower(y.SQL.Dialect.Value) {
+		case "", "postgres", "mysql", "sqlite":
+		default:
+			return nil, fmt.Errorf("kesconf: invalid SQL keystore: unknown dialect '%s'", y.SQL.Dialect.Value)
+		}
+		if y.SQL.MaxOpenConns.Value < 0 {
+			return nil, errors.New("kesconf: invalid SQL keystore: max_open_conns must not be negative")
+		}
+		s := &SQLKeyStore{
+			Driver:       y.SQL.Driver.Value,
+			DSN:          y.SQL.DSN.Value,
+			Dialect:      y.SQL.Dialect.Value,
+			Table:        y.SQL.Table.Value,
+			MaxOpenConns: y.SQL.MaxOpenConns.Value,
+		}
+		if y.SQL.ReadReplicas != nil {
+			dsns, err := ymlToReadReplicas(y.SQL.ReadReplicas.DSNs, y.SQL.ReadReplicas.MaxStaleness.Value)
+			if err != nil {
+				return nil, fmt.Errorf("kesconf: invalid SQL keystore: %v", err)
+			}
+			s.ReadDSNs = dsns
+			s.MaxStaleness = y.SQL.ReadReplicas.MaxStaleness.Value
+		}
+		keystore = s
+	}
+
+	if y.S3 != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if y.S3.Bucket.Value == "" {
+			return nil, errors.New("kesconf: invalid S3 keystore: no bucket specified")
+		}
+		if strings.HasPrefix(y.S3.Prefix.Value, "/") {
+			return nil, fmt.Errorf("kesconf: invalid S3 keystore: prefix '%s' starts with '/'", y.S3.Prefix.Value)
+		}
+		s3 := &S3KeyStore{
+			Endpoint:     y.S3.Endpoint.Value,
+			Region:       y.S3.Region.Value,
+			Bucket:       y.S3.Bucket.Value,
+			Prefix:       y.S3.Prefix.Value,
+			KMSKey:       y.S3.KMSKey.Value,
+			AccessKey:    y.S3.Login.AccessKey.Value,
+			SecretKey:    y.S3.Login.SecretKey.Value,
+			SessionToken: y.S3.Login.SessionToken.Value,
+		}
+		if y.S3.Retention != nil {
+			switch strings.ToUpper(y.S3.Retention.Mode.Value) {
+			case "GOVERNANCE", "COMPLIANCE":
+			default:
+				return nil, fmt.Errorf("kesconf: invalid S3 keystore: invalid retention mode '%s'", y.S3.Retention.Mode.Value)
+			}
+			if y.S3.Retention.Period.Value <= 0 {
+				return nil, fmt.Errorf("kesconf: invalid S3 keystore: invalid retention period '%v'", y.S3.Retention.Period.Value)
+			}
+			s3.RetentionMode = strings.ToUpper(y.S3.Retention.Mode.Value)
+			s3.RetentionPeriod = y.S3.Retention.Period.Value
+		}
+		keystore = s3
+	}
+
+	if y.Split != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if y.Split.Primary == nil {
+			return nil, errors.New("kesconf: invalid split keystore: no primary keystore specified")
+		}
+		if y.Split.Secondary == nil {
+			return nil, errors.New("kesconf: invalid split keystore: no secondary keystore specified")
+		}
+		if y.Split.Primary.Split != nil || y.Split.Secondary.Split != nil {
+			return nil, errors.New("kesconf: invalid split keystore: split keystores cannot be nested")
+		}
+		primary, err := ymlToKeyStore(y.Split.Primary)
+		if err != nil {
+			return nil, fmt.Errorf("kesconf: invalid split keystore: primary: %v", err)
+		}
+		secondary, err := ymlToKeyStore(y.Split.Secondary)
+		if err != nil {
+			return nil, fmt.Errorf("kesconf: invalid split keystore: secondary: %v", err)
+		}
+		keystore = &SplitKeyStore{
+			Primary:   primary,
+			Secondary: secondary,
 		}
 	}
 
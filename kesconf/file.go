@@ -5,13 +5,20 @@
 package kesconf
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"slices"
 	"time"
@@ -21,14 +28,24 @@ import (
 	"github.com/minio/kes"
 	"github.com/minio/kes/internal/https"
 	"github.com/minio/kes/internal/keystore/aws"
+	"github.com/minio/kes/internal/keystore/awskms"
 	"github.com/minio/kes/internal/keystore/azure"
+	"github.com/minio/kes/internal/keystore/dynamodb"
 	"github.com/minio/kes/internal/keystore/efs"
 	"github.com/minio/kes/internal/keystore/entrust"
 	"github.com/minio/kes/internal/keystore/fortanix"
 	"github.com/minio/kes/internal/keystore/fs"
 	"github.com/minio/kes/internal/keystore/gcp"
+	"github.com/minio/kes/internal/keystore/gcpkms"
 	"github.com/minio/kes/internal/keystore/gemalto"
+	"github.com/minio/kes/internal/keystore/pkcs11"
+	"github.com/minio/kes/internal/keystore/replica"
+	"github.com/minio/kes/internal/keystore/s3"
+	sqlstore "github.com/minio/kes/internal/keystore/sql"
+	"github.com/minio/kes/internal/keystore/ssm"
+	"github.com/minio/kes/internal/keystore/upstream"
 	"github.com/minio/kes/internal/keystore/vault"
+	"github.com/minio/kes/internal/sevsnp"
 	kesdk "github.com/minio/kms-go/kes"
 	yaml "gopkg.in/yaml.v3"
 )
@@ -52,8 +69,12 @@ func ReadFile(filename string) (*File, error) {
 // ReadFrom parses and returns a new KES server configuration file
 // from r.
 func ReadFrom(r io.Reader) (*File, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
 	var node yaml.Node
-	if err := yaml.NewDecoder(r).Decode(&node); err != nil {
+	if err := yaml.NewDecoder(bytes.NewReader(raw)).Decode(&node); err != nil {
 		return nil, err
 	}
 
@@ -70,7 +91,15 @@ func ReadFrom(r io.Reader) (*File, error) {
 	if err := node.Decode(&y); err != nil {
 		return nil, err
 	}
-	return ymlToServerConfig(&y)
+	file, err := ymlToServerConfig(&y)
+	if err != nil {
+		return nil, err
+	}
+	if file.Attestation != nil {
+		digest := sha256.Sum256(raw)
+		file.Attestation.ConfigDigest = digest[:]
+	}
+	return file, nil
 }
 
 // File is a structure that holds the content of a KES server
@@ -99,12 +128,132 @@ type File struct {
 	// Cache contains the KES server cache configuration.
 	Cache *CacheConfig
 
+	// KeyStoreTimeout contains the timeouts of keystore
+	// operations.
+	KeyStoreTimeout *KeyStoreTimeoutConfig
+
+	// Standby, if set, runs the KES server as warm standby
+	// of another KES server.
+	Standby *StandbyConfig
+
+	// StandbyIdentities are the identities of the standby
+	// servers that may mirror this KES server and of the
+	// caching proxies that may fetch keys from it.
+	StandbyIdentities []kes.Identity
+
+	// Databases contains the database secrets engines
+	// issuing short-lived database users.
+	Databases map[string]DatabaseConfig
+
+	// SSHRoles contains the SSH certificate roles of the
+	// KES SSH CA.
+	SSHRoles map[string]SSHRoleConfig
+
+	// PKI contains the configuration of the X.509
+	// certificate engine.
+	PKI *PKIConfig
+
+	// Tokenization contains the format-preserving encryption
+	// configuration of keys by key name.
+	Tokenization map[string]TokenizationConfig
+
+	// Folders contains the grants and tags of key folders
+	// by folder path, e.g. "team-a/payments". Sub-folders
+	// inherit the grants and tags of their parents.
+	Folders map[string]FolderConfig
+
+	// KeyLifecycle, if set, transitions keys between states
+	// on configured dates and purges them eventually.
+	KeyLifecycle *KeyLifecycleConfig
+
+	// RequireKeyOwner, if true, requires an owner and contact
+	// for every key created or imported via the API.
+	RequireKeyOwner bool
+
+	// Tenants contains the tenant configuration by tenant name.
+	// Keys of a tenant are wrapped with a per-tenant KEK.
+	Tenants map[string]TenantConfig
+
+	// LoadShedding, if set, limits the number of concurrent
+	// requests and rejects low priority requests first.
+	LoadShedding *LoadSheddingConfig
+
+	// Merkle, if set, periodically publishes signed Merkle
+	// roots over the metadata of all keys.
+	Merkle *MerkleConfig
+
+	// AnomalyDetection, if set, aggregates usage features per
+	// identity and raises alerts once a threshold is exceeded.
+	AnomalyDetection *AnomalyDetectionConfig
+
+	// Alerting, if set, evaluates alerting rules and notifies
+	// receivers once a rule fires or resolves.
+	Alerting *AlertingConfig
+
+	// Authentication is the ordered authentication chain. If
+	// empty, clients are identified by their TLS certificate.
+	Authentication []AuthenticatorConfig
+
+	// AuthThrottling, if set, delays responses to requests with
+	// unknown or invalid identities and bans source IP addresses
+	// with too many authentication failures temporarily.
+	AuthThrottling *AuthThrottlingConfig
+
+	// WebAuthn, if set, enables admin sessions for clients
+	// that log in with a registered WebAuthn credential.
+	WebAuthn *WebAuthnConfig
+
+	// Cosigning, if set, requires that high-risk operations are
+	// countersigned by a witness KES server.
+	Cosigning *CosigningConfig
+
+	// Witness, if set, enables cosigning high-risk operations
+	// for other KES servers.
+	Witness *WitnessConfig
+
+	// Fencing, if set, enables key usage reservations.
+	Fencing *FencingConfig
+
+	// Attestation, if set, enables the TPM-based attestation
+	// of the KES server.
+	Attestation *AttestationConfig
+
+	// Sealing, if set, seals all key store entries to the
+	// measurement of the confidential VM.
+	Sealing *SealingConfig
+
+	// Compression, if set, compresses key store entries
+	// before they are stored.
+	Compression *CompressionConfig
+
+	// Chunking, if set, splits large key store entries
+	// into multiple entries.
+	Chunking *ChunkingConfig
+
+	// Dedup, if set, stores identical values of key
+	// store entries only once.
+	Dedup *DedupConfig
+
+	// WriteBehind, if set, queues audit records and usage
+	// information in a durable local queue such that an
+	// unavailable audit sink or keystore does not add
+	// latency to requests.
+	WriteBehind *WriteBehindConfig
+
+	// Jobs, if set, contains the keystores the migrate
+	// and backup jobs copy keys to.
+	Jobs *JobConfig
+
 	// Log contains the KES server logging configuration.
 	Log *LogConfig
 
 	// API contains the KES server API configuration.
 	API *APIConfig
 
+	// GeoIP, if set, is used to locate client IP addresses.
+	// It is required by policies with a geo condition.
+	GeoIP *GeoIPConfig
+
 	// Policies contains the KES server policy definitions
 	// and statical identity assignments.
 	Policies map[string]Policy
@@ -117,6 +266,11 @@ type File struct {
 	// The KeyStore manages the keys used by the KES server for
 	// encryption and decryption.
 	KeyStore KeyStore
+
+	// VirtualHosts contains the configurations of virtual KES
+	// servers by TLS server name. Each virtual server has its
+	// own TLS, admin, policy and keystore configuration.
+	VirtualHosts map[string]*File
 }
 
 // TLSConfig returns a new TLS configuration as specified by
@@ -171,6 +325,14 @@ func (f *File) Config(ctx context.Context) (*kes.Config, error) {
 			return nil, err
 		}
 		conf.TLS = tlsConf
+
+		if f.TLS.SessionTicketsDisabled || f.TLS.SessionTicketKeyRotation > 0 || f.TLS.SessionCacheSize > 0 {
+			conf.TLSSession = &kes.TLSSessionConfig{
+				TicketsDisabled:   f.TLS.SessionTicketsDisabled,
+				TicketKeyRotation: f.TLS.SessionTicketKeyRotation,
+				CacheSize:         f.TLS.SessionCacheSize,
+			}
+		}
 	}
 
 	if f.Cache != nil {
@@ -181,12 +343,338 @@ func (f *File) Config(ctx context.Context) (*kes.Config, error) {
 		}
 	}
 
+	if f.KeyStoreTimeout != nil {
+		conf.KeyStoreTimeout = &kes.KeyStoreTimeoutConfig{
+			Get:   f.KeyStoreTimeout.Get,
+			Write: f.KeyStoreTimeout.Write,
+			List:  f.KeyStoreTimeout.List,
+		}
+	}
+
+	conf.StandbyIdentities = f.StandbyIdentities
+	if f.Standby != nil {
+		if conf.TLS == nil {
+			return nil, errors.New("kesconf: invalid standby config: no TLS configuration")
+		}
+		conf.Standby = &kes.StandbyConfig{
+			Primary:  f.Standby.Primary,
+			Interval: f.Standby.Interval,
+			TLS: &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: conf.TLS.Certificates,
+				RootCAs:      conf.TLS.RootCAs,
+			},
+		}
+	}
+
+	if len(f.Databases) > 0 {
+		conf.Databases = make(map[string]*kes.DatabaseConfig, len(f.Databases))
+		for name, db := range f.Databases {
+			handle, err := sql.Open(db.Driver, db.DSN)
+			if err != nil {
+				return nil, fmt.Errorf("kesconf: failed to open database '%s': %v", name, err)
+			}
+			conf.Databases[name] = &kes.DatabaseConfig{
+				DB:                   handle,
+				Dialect:              db.Dialect,
+				CreationStatements:   db.CreationStatements,
+				RevocationStatements: db.RevocationStatements,
+				DefaultTTL:           db.DefaultTTL,
+				MaxTTL:               db.MaxTTL,
+			}
+		}
+	}
+
+	if len(f.SSHRoles) > 0 {
+		conf.SSHRoles = make(map[string]*kes.SSHRoleConfig, len(f.SSHRoles))
+		for name, role := range f.SSHRoles {
+			conf.SSHRoles[name] = &kes.SSHRoleConfig{
+				CertType:   role.CertType,
+				Principals: role.Principals,
+				Extensions: role.Extensions,
+				DefaultTTL: role.DefaultTTL,
+				MaxTTL:     role.MaxTTL,
+			}
+		}
+		if f.PKI.Renewal != nil {
+			conf.PKI.Renewal = &kes.PKIRenewalConfig{
+				Window: f.PKI.Renewal.Window,
+				TTL:    f.PKI.Renewal.TTL,
+			}
+		}
+	}
+
+	if f.PKI != nil {
+		conf.PKI = &kes.PKIConfig{
+			URL:   f.PKI.URL,
+			Roles: make(map[string]*kes.PKIRoleConfig, len(f.PKI.Roles)),
+		}
+		for name, role := range f.PKI.Roles {
+			conf.PKI.Roles[name] = &kes.PKIRoleConfig{
+				Domains:    role.Domains,
+				AllowIPs:   role.AllowIPs,
+				ServerAuth: role.ServerAuth,
+				ClientAuth: role.ClientAuth,
+				DefaultTTL: role.DefaultTTL,
+				MaxTTL:     role.MaxTTL,
+			}
+		}
+	}
+
+	if len(f.Tokenization) > 0 {
+		conf.Tokenization = make(map[string]*kes.TokenizationConfig, len(f.Tokenization))
+		for name, t := range f.Tokenization {
+			conf.Tokenization[name] = &kes.TokenizationConfig{
+				Mode:        t.Mode,
+				Alphabet:    t.Alphabet,
+				RevealFirst: t.RevealFirst,
+				RevealLast:  t.RevealLast,
+				MaskChar:    t.MaskChar,
+			}
+		}
+	}
+
+	if len(f.Folders) > 0 {
+		conf.Folders = make(map[string]*kes.FolderConfig, len(f.Folders))
+		for path, folder := range f.Folders {
+			conf.Folders[path] = &kes.FolderConfig{
+				Grants: maps.Clone(folder.Grants),
+				Tags:   maps.Clone(folder.Tags),
+			}
+		}
+	}
+
+	if f.KeyLifecycle != nil {
+		conf.KeyLifecycle = &kes.KeyLifecycleConfig{
+			Notice: f.KeyLifecycle.Notice,
+			Sink:   f.KeyLifecycle.Sink,
+		}
+		if len(f.KeyLifecycle.Schedules) > 0 {
+			conf.KeyLifecycle.Schedules = make(map[string]*kes.KeySchedule, len(f.KeyLifecycle.Schedules))
+			for pattern, s := range f.KeyLifecycle.Schedules {
+				conf.KeyLifecycle.Schedules[pattern] = &kes.KeySchedule{
+					DecryptOnly: s.DecryptOnly,
+					Disable:     s.Disable,
+					Purge:       s.Purge,
+				}
+			}
+		}
+	}
+
+	conf.RequireKeyOwner = f.RequireKeyOwner
+
+	if len(f.Tenants) > 0 {
+		conf.Tenants = make(map[string]*kes.TenantConfig, len(f.Tenants))
+		for name, t := range f.Tenants {
+			tenant := &kes.TenantConfig{Prefix: t.Prefix}
+			if t.KeyStore != nil {
+				kekStore, err := t.KeyStore.Connect(ctx)
+				if err != nil {
+					return nil, err
+				}
+				tenant.KEKStore = kekStore
+			}
+			conf.Tenants[name] = tenant
+		}
+	}
+
+	if f.Log != nil && f.Log.Pseudonymization != nil {
+		conf.AuditPseudonymization = &kes.AuditPseudonymizationConfig{
+			Key: slices.Clone(f.Log.Pseudonymization.Key),
+		}
+	}
+	if f.Log != nil && f.Log.Request != nil {
+		conf.RequestLog = &kes.RequestLogConfig{
+			SampleRate: f.Log.Request.SampleRate,
+			Body:       f.Log.Request.Body,
+			Redact:     slices.Clone(f.Log.Request.Redact),
+		}
+	}
+	if f.LoadShedding != nil {
+		conf.LoadShedding = &kes.LoadSheddingConfig{
+			MaxRequests: f.LoadShedding.MaxRequests,
+		}
+	}
+	if f.GeoIP != nil {
+		db, err := os.ReadFile(f.GeoIP.Database)
+		if err != nil {
+			return nil, err
+		}
+		conf.GeoIP = &kes.GeoIPConfig{
+			Database: db,
+		}
+	}
+	if f.AnomalyDetection != nil {
+		conf.AnomalyDetection = &kes.AnomalyDetectionConfig{
+			Interval:        f.AnomalyDetection.Interval,
+			Sink:            f.AnomalyDetection.Sink,
+			MaxRequestRate:  f.AnomalyDetection.MaxRequestRate,
+			MaxDistinctKeys: f.AnomalyDetection.MaxDistinctKeys,
+			MaxDenyRate:     f.AnomalyDetection.MaxDenyRate,
+			MinRequests:     f.AnomalyDetection.MinRequests,
+		}
+	}
+	if f.Alerting != nil {
+		conf.Alerting = &kes.AlertingConfig{
+			Interval:  f.Alerting.Interval,
+			Receivers: make(map[string]*kes.AlertReceiver, len(f.Alerting.Receivers)),
+		}
+		for name, r := range f.Alerting.Receivers {
+			receiver := &kes.AlertReceiver{Webhook: r.Webhook}
+			if r.Email != nil {
+				receiver.Email = &kes.AlertEmailConfig{
+					Server:   r.Email.Server,
+					From:     r.Email.From,
+					To:       slices.Clone(r.Email.To),
+					Username: r.Email.Username,
+					Password: r.Email.Password,
+				}
+			}
+			if r.PagerDuty != nil {
+				receiver.PagerDuty = &kes.AlertPagerDutyConfig{
+					RoutingKey: r.PagerDuty.RoutingKey,
+					Endpoint:   r.PagerDuty.Endpoint,
+				}
+			}
+			conf.Alerting.Receivers[name] = receiver
+		}
+		for _, rule := range f.Alerting.Rules {
+			conf.Alerting.Rules = append(conf.Alerting.Rules, &kes.AlertRule{
+				Name:       rule.Name,
+				Signal:     rule.Signal,
+				Backend:    rule.Backend,
+				Metric:     rule.Metric,
+				Labels:     maps.Clone(rule.Labels),
+				Path:       rule.Path,
+				StatusCode: rule.StatusCode,
+				Threshold:  rule.Threshold,
+				For:        rule.For,
+				Severity:   rule.Severity,
+				Receivers:  slices.Clone(rule.Receivers),
+			})
+		}
+	}
+	for _, auth := range f.Authentication {
+		authenticator, err := auth.Authenticator()
+		if err != nil {
+			return nil, err
+		}
+		conf.Authenticators = append(conf.Authenticators, authenticator)
+	}
+	if f.AuthThrottling != nil {
+		conf.AuthThrottling = &kes.AuthThrottlingConfig{
+			MaxFailures: f.AuthThrottling.MaxFailures,
+			Interval:    f.AuthThrottling.Interval,
+			Delay:       f.AuthThrottling.Delay,
+			MaxDelay:    f.AuthThrottling.MaxDelay,
+			BanDuration: f.AuthThrottling.BanDuration,
+		}
+	}
+	if f.WebAuthn != nil {
+		conf.WebAuthn = &kes.WebAuthnConfig{
+			RPID:             f.WebAuthn.RPID,
+			Origins:          slices.Clone(f.WebAuthn.Origins),
+			UserVerification: f.WebAuthn.UserVerification,
+			SessionTTL:       f.WebAuthn.SessionTTL,
+		}
+		for _, cred := range f.WebAuthn.Credentials {
+			key, err := publicKeyFromFile(cred.PublicKey)
+			if err != nil {
+				return nil, fmt.Errorf("kesconf: invalid WebAuthn credential: %v", err)
+			}
+			conf.WebAuthn.Credentials = append(conf.WebAuthn.Credentials, kes.WebAuthnCredential{
+				ID:        slices.Clone(cred.ID),
+				PublicKey: key,
+			})
+		}
+	}
+	if f.Merkle != nil {
+		conf.Merkle = &kes.MerkleConfig{
+			Interval:  f.Merkle.Interval,
+			Retention: f.Merkle.Retention,
+		}
+	}
+
+	if f.Cosigning != nil {
+		conf.Cosigning = &kes.CosigningConfig{
+			Witnesses: slices.Clone(f.Cosigning.Witnesses),
+			Paths:     slices.Clone(f.Cosigning.Paths),
+		}
+	}
+	if f.Witness != nil {
+		conf.Witness = &kes.WitnessConfig{
+			Validity: f.Witness.Validity,
+		}
+	}
+	if f.Fencing != nil {
+		conf.Fencing = &kes.FencingConfig{
+			MaxTTL: f.Fencing.MaxTTL,
+		}
+	}
+	if f.Sealing != nil {
+		device, err := sevsnp.Open(f.Sealing.SEVSNPDevice)
+		if err != nil {
+			return nil, err
+		}
+		conf.Sealing = &kes.SealingConfig{
+			Sealer:        device,
+			AllowUnsealed: f.Sealing.AllowUnsealed,
+		}
+	}
+	if f.Compression != nil {
+		conf.Compression = &kes.CompressionConfig{
+			MinSize: f.Compression.MinSize,
+		}
+	}
+	if f.Chunking != nil {
+		conf.Chunking = &kes.ChunkingConfig{
+			Size: f.Chunking.Size,
+		}
+	}
+	if f.Dedup != nil {
+		conf.Dedup = &kes.DedupConfig{
+			MinSize: f.Dedup.MinSize,
+		}
+	}
+	if f.WriteBehind != nil {
+		conf.WriteBehind = &kes.WriteBehindConfig{
+			Dir:     f.WriteBehind.Path,
+			MaxSize: f.WriteBehind.MaxSize,
+		}
+	}
+	if f.Jobs != nil {
+		conf.Jobs = &kes.JobConfig{}
+		if f.Jobs.MigrationTarget != nil {
+			store, err := f.Jobs.MigrationTarget.Connect(ctx)
+			if err != nil {
+				return nil, err
+			}
+			conf.Jobs.MigrationTarget = store
+		}
+		if f.Jobs.BackupTarget != nil {
+			store, err := f.Jobs.BackupTarget.Connect(ctx)
+			if err != nil {
+				return nil, err
+			}
+			conf.Jobs.BackupTarget = store
+		}
+	}
+	if f.Attestation != nil {
+		conf.Attestation = &kes.AttestationConfig{
+			Device:       f.Attestation.Device,
+			PCRs:         slices.Clone(f.Attestation.PCRs),
+			ConfigDigest: slices.Clone(f.Attestation.ConfigDigest),
+		}
+	}
+
 	if f.API != nil && len(f.API.Paths) > 0 {
 		conf.Routes = make(map[string]kes.RouteConfig, len(f.API.Paths))
 		for path, config := range f.API.Paths {
 			conf.Routes[path] = kes.RouteConfig{
 				Timeout:          config.Timeout,
 				InsecureSkipAuth: config.InsecureSkipAuth,
+				Deprecation:      config.Deprecated,
+				Sunset:           config.Sunset,
 			}
 		}
 	}
@@ -206,6 +694,13 @@ func (f *File) Config(ctx context.Context) (*kes.Config, error) {
 			for _, pattern := range policy.Deny {
 				p.Deny[pattern] = struct{}{}
 			}
+			if policy.Geo != nil {
+				p.Geo = &kes.GeoCondition{
+					Paths:      slices.Clone(policy.Geo.Paths),
+					Countries:  slices.Clone(policy.Geo.Countries),
+					Continents: slices.Clone(policy.Geo.Continents),
+				}
+			}
 			policies[name] = p
 		}
 		conf.Policies = policies
@@ -226,6 +721,17 @@ func (f *File) Config(ctx context.Context) (*kes.Config, error) {
 		}
 		conf.Keys = keystore
 	}
+
+	if len(f.VirtualHosts) > 0 {
+		conf.VirtualHosts = make(map[string]*kes.Config, len(f.VirtualHosts))
+		for name, vhost := range f.VirtualHosts {
+			vconf, err := vhost.Config(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("kesconf: virtual host '%s': %v", name, err)
+			}
+			conf.VirtualHosts[name] = vconf
+		}
+	}
 	return conf, nil
 }
 
@@ -268,6 +774,37 @@ type TLSConfig struct {
 	// TLS / HTTPS proxy to forward the actual client certificate
 	// to KES.
 	ForwardCertHeader string
+
+	// SessionTicketsDisabled disables TLS session resumption.
+	SessionTicketsDisabled bool
+
+	// SessionTicketKeyRotation is the interval in which the KES
+	// server generates a new session ticket key. If 0, defaults
+	// to 24 hours.
+	SessionTicketKeyRotation time.Duration
+
+	// SessionCacheSize is the max. number of TLS sessions cached
+	// by the KES server. If 0, clients keep the session state
+	// within encrypted session tickets.
+	SessionCacheSize int
+}
+
+// defaultCloudListTimeout is the default timeout for listing
+// keys at cloud secret managers, like AWS SecretsManager.
+const defaultCloudListTimeout = 2 * time.Minute
+
+// KeyStoreTimeoutConfig is a structure that holds the timeouts
+// of keystore operations. A zero timeout is replaced by its
+// default. A negative timeout disables the timeout.
+type KeyStoreTimeoutConfig struct {
+	// Get is the timeout for fetching a key.
+	Get time.Duration
+
+	// Write is the timeout for creating and deleting keys.
+	Write time.Duration
+
+	// List is the timeout for listing keys.
+	List time.Duration
 }
 
 // CacheConfig is a structure that holds the Cache configuration
@@ -297,134 +834,927 @@ type CacheConfig struct {
 	ExpiryOffline time.Duration
 }
 
-// LogConfig is a structure that holds the logging configuration
-// for a KES server.
-type LogConfig struct {
-	// Error determines whether the KES server logs error events to STDERR.
-	// It does not en/disable error logging in general.
-	ErrLevel slog.Level
-
-	// Audit determines whether the KES server logs audit events to STDOUT.
-	// It does not en/disable audit logging in general.
-	AuditLevel slog.Level
+// StandbyConfig is a structure that holds the warm standby
+// configuration for a KES server.
+type StandbyConfig struct {
+	// Primary is the endpoint of the KES server the standby
+	// mirrors, e.g. "https://kes-primary:7373".
+	//
+	// The standby authenticates to the primary with its own
+	// TLS certificate. Hence, its identity must be allowed
+	// to access the primary's cache sync API.
+	Primary string
+
+	// Interval is the time period in which the standby
+	// synchronizes with the primary. If zero, the default
+	// interval is used.
+	Interval time.Duration
 }
 
-// APIConfig is a structure that holds the API configuration
-// for a KES server.
-type APIConfig struct {
-	// Paths contains a set of API paths and there
-	// API configuration.
-	Paths map[string]APIPathConfig
+// LoadSheddingConfig is a structure that holds the load
+// shedding configuration.
+type LoadSheddingConfig struct {
+	// MaxRequests is the max. number of concurrent requests.
+	MaxRequests int
 }
 
-// APIPathConfig is a structure that holds the API configuration
-// for one particular KES API.
-type APIPathConfig struct {
-	// Timeout is the duration after which the API response
-	// with a HTTP timeout error response. If Timeout is
-	// zero the API default is used.
-	Timeout time.Duration
+// MerkleConfig is a structure that holds the configuration
+// of published Merkle roots.
+type MerkleConfig struct {
+	// Interval is the time period in which a new Merkle
+	// root gets published.
+	Interval time.Duration
 
-	// InsecureSkipAuth controls whether the API verifies
-	// client identities. If InsecureSkipAuth is true,
-	// the API accepts requests from arbitrary identities.
-	// In this mode, the API can be used by anyone who can
-	// communicate to the KES server over HTTPS.
-	// This should only be set for testing or in certain
-	// cases for APIs that don't expose sensitive information,
-	// like metrics.
-	InsecureSkipAuth bool
+	// Retention is how long published Merkle roots are
+	// kept. If zero, roots are kept forever.
+	Retention time.Duration
 }
 
-// Policy is a structure defining a KES policy.
-//
-// Any request issued by a KES identity is validated
-// by the associated allow and deny patterns. A
-// request is accepted if and only if no deny pattern
-// and at least one allow pattern matches the request.
-type Policy struct {
-	// Allow is the list of API path patterns
-	// that are explicitly allowed.
-	Allow []string
+// CosigningConfig is a structure that holds the configuration
+// of operations that require a cosignature of a witness.
+type CosigningConfig struct {
+	// Witnesses are the Ed25519 public keys of the witness
+	// KES servers.
+	Witnesses []ed25519.PublicKey
 
-	// Deny is the list of API path patterns
-	// that are explicitly denied.
-	Deny []string
+	// Paths are the API path patterns of high-risk operations.
+	// If empty, defaults to kes.DefaultCosignPaths.
+	Paths []string
+}
 
-	// Identities is a list of KES identities
-	// that are assigned to this policy.
-	//
-	// It must not contain the admin or any
-	// TLS proxy identity.
-	Identities []kes.Identity
+// WitnessConfig is a structure that holds the witness
+// configuration.
+type WitnessConfig struct {
+	// Validity is how long a cosignature is valid. If zero,
+	// defaults to kes.DefaultCosignValidity.
+	Validity time.Duration
 }
 
-// Key is a structure defining a cryptographic key
-// that the KES server will create or ensure exists
-// before startup.
-type Key struct {
-	// Name is the name of the cryptographic key.
-	Name string
+// FencingConfig is a structure that holds the key usage
+// reservation configuration.
+type FencingConfig struct {
+	// MaxTTL is the max. lease duration of a reservation. If
+	// zero, defaults to kes.DefaultFencingMaxTTL.
+	MaxTTL time.Duration
 }
 
-// KeyStore is a KES keystore configuration.
-//
-// Concrete instances implement Connect to return
-// a connection to a concrete keystore.
-type KeyStore interface {
-	// Connect establishes and returns a new connection
-	// to the keystore.
-	Connect(ctx context.Context) (kes.KeyStore, error)
+// CompressionConfig is a structure that holds the key store
+// entry compression configuration.
+type CompressionConfig struct {
+	// MinSize is the min. size of compressed entries. If
+	// zero, defaults to kes.DefaultCompressionMinSize.
+	MinSize int
 }
 
-// FSKeyStore is a structure containing the configuration
-// for a simple filesystem keystore.
-//
-// A FSKeyStore should only be used when testing a KES server.
-type FSKeyStore struct {
-	// Path is the path to the directory that
-	// contains the keys.
-	//
-	// If the directory does not exist, it
-	// will be created.
-	Path string
+// ChunkingConfig is a structure that holds the key store
+// entry chunking configuration.
+type ChunkingConfig struct {
+	// Size is the max. size of a chunk. If zero, defaults
+	// to kes.DefaultChunkSize.
+	Size int
 }
 
-// Connect returns a kv.Store that stores key-value pairs in a path on the filesystem.
-func (s *FSKeyStore) Connect(context.Context) (kes.KeyStore, error) {
-	return fs.NewStore(s.Path)
+// DedupConfig is a structure that holds the key store
+// entry deduplication configuration.
+type DedupConfig struct {
+	// MinSize is the min. size of deduplicated entries. If
+	// zero, defaults to kes.DefaultDedupMinSize.
+	MinSize int
 }
 
-// EncryptedFSKeyStore is a structure containing the configuration
-// for a simple filesystem keystore.
-//
-// An EncryptedFSKeyStore should only be used when testing a KES server.
-type EncryptedFSKeyStore struct {
-	// MasterKeyPath is the path of the file containing the master key.
-	MasterKeyPath string
-	// MasterKeyCipher is the cipher to load the master key.
-	MasterKeyCipher string
-	// Path is the path to the directory that
-	// contains the keys.
-	//
-	// If the directory does not exist, it
-	// will be created.
+// WriteBehindConfig is a structure that holds the write-behind
+// queue configuration.
+type WriteBehindConfig struct {
+	// Path is the directory of the queue. It is created
+	// if it does not exist.
 	Path string
+
+	// MaxSize is the max. size of the queue in bytes. If
+	// zero, defaults to 64 MiB.
+	MaxSize int64
 }
 
-// Connect returns a kes.KeyStore that stores encrypted key-value pairs in a path on the filesystem.
-func (s *EncryptedFSKeyStore) Connect(context.Context) (kes.KeyStore, error) {
-	return efs.NewStore(s.MasterKeyPath, s.MasterKeyCipher, s.Path)
+// AttestationConfig is a structure that holds the TPM-based
+// attestation configuration.
+type AttestationConfig struct {
+	// Device is the path of the TPM device or the unix socket
+	// of a software TPM. If empty, defaults to "/dev/tpmrm0".
+	Device string
+
+	// PCRs are the SHA-256 PCRs included in TPM quotes. If
+	// empty, defaults to kes.DefaultAttestationPCRs.
+	PCRs []int
+
+	// ConfigDigest is the SHA-256 hash of the config file.
+	// It is set by ReadFrom and covers the raw file content,
+	// not the values of referenced environment variables.
+	ConfigDigest []byte
 }
 
-// VaultKeyStore is a structure containing the configuration
-// for Hashicorp Vault.
-type VaultKeyStore struct {
-	// Endpoint is the Hashicorp Vault endpoint.
-	Endpoint string
+// SealingConfig is a structure that holds the confidential
+// computing sealing configuration.
+type SealingConfig struct {
+	// SEVSNPDevice is the path of the AMD SEV-SNP guest device.
+	// If empty, defaults to "/dev/sev-guest".
+	SEVSNPDevice string
 
-	// Namespace is an optional Hashicorp Vault namespace.
-	// An empty namespace means no particular namespace
+	// AllowUnsealed controls whether key store entries that
+	// have not been sealed can still be read.
+	AllowUnsealed bool
+}
+
+// AnomalyDetectionConfig is a structure that holds the usage
+// anomaly detection configuration.
+type AnomalyDetectionConfig struct {
+	// Interval is the interval over which usage features
+	// are aggregated.
+	Interval time.Duration
+
+	// Sink is an optional HTTP endpoint that receives the
+	// usage features at the end of every interval.
+	Sink string
+
+	// MaxRequestRate is the max. number of requests per
+	// second of an identity.
+	MaxRequestRate float64
+
+	// MaxDistinctKeys is the max. number of distinct keys
+	// an identity uses within an interval.
+	MaxDistinctKeys int
+
+	// MaxDenyRate is the max. fraction of requests of an
+	// identity rejected by its policy.
+	MaxDenyRate float64
+
+	// MinRequests is the min. number of requests before the
+	// deny rate of an identity is checked.
+	MinRequests int
+}
+
+// AlertingConfig is a structure that holds the alerting
+// rules and the receivers that are notified about alerts.
+type AlertingConfig struct {
+	// Interval is the interval in which rules are evaluated.
+	Interval time.Duration
+
+	// Receivers are the receivers that rules notify, by name.
+	Receivers map[string]AlertReceiver
+
+	// Rules are the alerting rules.
+	Rules []AlertRule
+}
+
+// AlertRule is a structure that holds an alerting rule.
+type AlertRule struct {
+	// Name is the unique name of the rule.
+	Name string
+
+	// Signal is the signal the rule evaluates, like
+	// "backend_unreachable", "deny_rate", "metric"
+	// or "audit".
+	Signal string
+
+	// Backend is the backend of "backend_unreachable"
+	// rules, like "keystore".
+	Backend string
+
+	// Metric and Labels select the metric series of
+	// "metric" rules.
+	Metric string
+	Labels map[string]string
+
+	// Path and StatusCode select the audit records
+	// of "audit" rules.
+	Path       string
+	StatusCode int
+
+	// Threshold is the value the signal has to exceed.
+	Threshold float64
+
+	// For is the duration the signal has to exceed
+	// the threshold before the rule fires.
+	For time.Duration
+
+	// Severity is the severity of alerts.
+	Severity string
+
+	// Receivers are the names of the receivers
+	// notified about alerts.
+	Receivers []string
+}
+
+// AlertReceiver is a structure that holds the notification
+// channels of an alert receiver.
+type AlertReceiver struct {
+	// Webhook is an HTTP endpoint that receives alerts.
+	Webhook string
+
+	// Email, if set, sends alerts as email.
+	Email *AlertEmailConfig
+
+	// PagerDuty, if set, sends alerts to PagerDuty.
+	PagerDuty *AlertPagerDutyConfig
+}
+
+// AlertEmailConfig is a structure that holds the SMTP
+// configuration of an alert receiver.
+type AlertEmailConfig struct {
+	// Server is the SMTP server address.
+	Server string
+
+	// From is the sender address.
+	From string
+
+	// To are the recipient addresses.
+	To []string
+
+	// Username and Password are used to authenticate
+	// to the SMTP server.
+	Username string
+	Password string
+}
+
+// AlertPagerDutyConfig is a structure that holds the
+// PagerDuty configuration of an alert receiver.
+type AlertPagerDutyConfig struct {
+	// RoutingKey is the integration key of the PagerDuty service.
+	RoutingKey string
+
+	// Endpoint is the PagerDuty Events API endpoint.
+	Endpoint string
+}
+
+// AuthenticatorConfig is a structure that holds the configuration
+// of one authenticator of the authentication chain. Exactly one
+// authenticator must be specified.
+type AuthenticatorConfig struct {
+	// MTLS identifies clients by their TLS client certificate.
+	MTLS bool
+
+	// JWT identifies clients by a JSON Web Token.
+	JWT *JWTAuthConfig
+
+	// Kubernetes identifies clients by a Kubernetes service
+	// account token.
+	Kubernetes *KubernetesAuthConfig
+
+	// HMAC identifies clients by a request signature.
+	HMAC *HMACAuthConfig
+}
+
+// JWTAuthConfig is a structure that holds the configuration
+// of a JWT authenticator.
+type JWTAuthConfig struct {
+	// Issuer is the expected token issuer.
+	Issuer string
+
+	// Audience, if set, must be an audience of the token.
+	Audience string
+
+	// Claim is the claim containing the client identity.
+	// If empty, defaults to "sub".
+	Claim string
+
+	// PublicKeys are paths to PEM-encoded public keys
+	// verifying token signatures.
+	PublicKeys []string
+
+	// Leeway is the tolerated clock skew.
+	Leeway time.Duration
+}
+
+// KubernetesAuthConfig is a structure that holds the configuration
+// of a Kubernetes service account token authenticator.
+type KubernetesAuthConfig struct {
+	// Endpoint is the URL of the Kubernetes API server.
+	Endpoint string
+
+	// TokenFile is the path to the token of the KES server
+	// used to review client tokens.
+	TokenFile string
+
+	// CAPath is an optional path to the CA certificate(s)
+	// of the Kubernetes API server.
+	CAPath string
+
+	// Audiences, if set, are the audiences client tokens
+	// must be valid for.
+	Audiences []string
+
+	// CacheTTL is the duration token reviews are cached.
+	CacheTTL time.Duration
+}
+
+// HMACAuthConfig is a structure that holds the configuration
+// of an HMAC request signature authenticator.
+type HMACAuthConfig struct {
+	// Header is the HTTP header containing the signature.
+	Header string
+
+	// MaxSkew is the max. age of a signature.
+	MaxSkew time.Duration
+
+	// Keys are the shared secrets by identity.
+	Keys map[kes.Identity]string
+}
+
+// Authenticator returns the kes.Authenticator specified by the
+// AuthenticatorConfig. It reads all referenced files.
+func (c *AuthenticatorConfig) Authenticator() (kes.Authenticator, error) {
+	switch {
+	case c.MTLS:
+		return kes.MTLSAuthenticator{}, nil
+	case c.JWT != nil:
+		auth := &kes.JWTAuthenticator{
+			Issuer:   c.JWT.Issuer,
+			Audience: c.JWT.Audience,
+			Claim:    c.JWT.Claim,
+			Leeway:   c.JWT.Leeway,
+		}
+		for _, filename := range c.JWT.PublicKeys {
+			key, err := publicKeyFromFile(filename)
+			if err != nil {
+				return nil, fmt.Errorf("kesconf: failed to read JWT public key: %v", err)
+			}
+			auth.Keys = append(auth.Keys, key)
+		}
+		return auth, nil
+	case c.Kubernetes != nil:
+		token, err := os.ReadFile(c.Kubernetes.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("kesconf: failed to read Kubernetes token: %v", err)
+		}
+		auth := &kes.KubernetesAuthenticator{
+			Endpoint:  c.Kubernetes.Endpoint,
+			Token:     string(bytes.TrimSpace(token)),
+			Audiences: c.Kubernetes.Audiences,
+			CacheTTL:  c.Kubernetes.CacheTTL,
+		}
+		if c.Kubernetes.CAPath != "" {
+			rootCAs, err := https.CertPoolFromFile(c.Kubernetes.CAPath)
+			if err != nil {
+				return nil, fmt.Errorf("kesconf: failed to read Kubernetes CA certificates: %v", err)
+			}
+			auth.TLS = &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: rootCAs}
+		}
+		return auth, nil
+	case c.HMAC != nil:
+		auth := &kes.HMACAuthenticator{
+			Header:  c.HMAC.Header,
+			MaxSkew: c.HMAC.MaxSkew,
+			Keys:    make(map[kes.Identity][]byte, len(c.HMAC.Keys)),
+		}
+		for id, key := range c.HMAC.Keys {
+			auth.Keys[id] = []byte(key)
+		}
+		return auth, nil
+	default:
+		return nil, errors.New("kesconf: invalid authenticator config: no authenticator specified")
+	}
+}
+
+// publicKeyFromFile reads a PEM-encoded PKIX public key.
+func publicKeyFromFile(filename string) (crypto.PublicKey, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("'%s' contains no PEM-encoded public key", filename)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// WebAuthnConfig is a structure that holds the configuration
+// of WebAuthn admin sessions.
+type WebAuthnConfig struct {
+	// RPID is the WebAuthn relying party ID.
+	RPID string
+
+	// Origins are the accepted origins of the WebAuthn ceremony.
+	// If empty, any origin whose host is the RPID is accepted.
+	Origins []string
+
+	// UserVerification controls whether the authenticator
+	// has to verify the user, e.g. by a PIN.
+	UserVerification bool
+
+	// SessionTTL is how long an admin session is valid. If
+	// <= 0, defaults to kes.DefaultWebAuthnSessionTTL.
+	SessionTTL time.Duration
+
+	// Credentials are the registered admin credentials.
+	Credentials []WebAuthnCredential
+}
+
+// WebAuthnCredential is a registered WebAuthn credential.
+type WebAuthnCredential struct {
+	// ID is the credential ID.
+	ID []byte
+
+	// PublicKey is the path to the PEM-encoded public
+	// key of the credential.
+	PublicKey string
+}
+
+// AuthThrottlingConfig is a structure that holds the
+// configuration for throttling authentication failures.
+type AuthThrottlingConfig struct {
+	// MaxFailures is the max. number of authentication
+	// failures of a source IP address within an interval.
+	MaxFailures int
+
+	// Interval is the interval over which authentication
+	// failures are counted.
+	Interval time.Duration
+
+	// Delay is the delay of the response to the first
+	// authentication failure. Every further failure doubles it.
+	Delay time.Duration
+
+	// MaxDelay is the max. delay of a response.
+	MaxDelay time.Duration
+
+	// BanDuration is the time a source IP address is banned
+	// once it exceeds its failure budget.
+	BanDuration time.Duration
+}
+
+// DatabaseConfig is a structure that holds the configuration
+// of a database secrets engine.
+//
+// The KES server opens the database with the given driver. The
+// driver must be registered with database/sql, i.e. compiled
+// into the KES binary.
+type DatabaseConfig struct {
+	// Driver is the database/sql driver name, e.g. "postgres".
+	Driver string
+
+	// DSN is the data source name used to connect to the database.
+	DSN string
+
+	// Dialect is the SQL dialect, either "postgres" or "mysql".
+	// It selects the default creation and revocation statements.
+	Dialect string
+
+	// CreationStatements are executed to create a new user.
+	CreationStatements []string
+
+	// RevocationStatements are executed to revoke a user.
+	RevocationStatements []string
+
+	// DefaultTTL is the default lease duration of database users.
+	DefaultTTL time.Duration
+
+	// MaxTTL is the longest lease duration clients can request.
+	MaxTTL time.Duration
+}
+
+// SSHRoleConfig is a structure that holds the configuration
+// of a SSH certificate role.
+type SSHRoleConfig struct {
+	// CertType is the certificate type, either "user" or "host".
+	CertType string
+
+	// Principals are the principals clients may request. Each
+	// entry may be a glob pattern.
+	Principals []string
+
+	// Extensions are the certificate extensions.
+	Extensions map[string]string
+
+	// DefaultTTL is the default validity period of certificates.
+	DefaultTTL time.Duration
+
+	// MaxTTL is the longest validity period clients can request.
+	MaxTTL time.Duration
+}
+
+// PKIConfig is a structure that holds the configuration
+// of the X.509 certificate engine.
+type PKIConfig struct {
+	// URL is the endpoint at which clients reach the KES server.
+	// Issued certificates point to its CRL and OCSP APIs.
+	URL string
+
+	// Roles contains the certificate roles by name.
+	Roles map[string]PKIRoleConfig
+
+	// Renewal controls whether clients can renew their own
+	// certificate. If nil, renewal is disabled.
+	Renewal *PKIRenewalConfig
+}
+
+// PKIRenewalConfig is a structure that holds the configuration
+// of client certificate renewals.
+type PKIRenewalConfig struct {
+	// Window is the period before expiry in which clients
+	// can renew their certificate.
+	Window time.Duration
+
+	// TTL is the validity period of renewed certificates.
+	TTL time.Duration
+}
+
+// PKIRoleConfig is a structure that holds the configuration
+// of a X.509 certificate role.
+type PKIRoleConfig struct {
+	// Domains are the DNS names clients may request. Each
+	// entry may be a glob pattern.
+	Domains []string
+
+	// AllowIPs controls whether clients may request IP addresses.
+	AllowIPs bool
+
+	// ServerAuth and ClientAuth control the extended key usage.
+	ServerAuth bool
+	ClientAuth bool
+
+	// DefaultTTL is the default validity period of certificates.
+	DefaultTTL time.Duration
+
+	// MaxTTL is the longest validity period clients can request.
+	MaxTTL time.Duration
+}
+
+// TokenizationConfig is a structure that holds the format-preserving
+// encryption configuration of a key.
+type TokenizationConfig struct {
+	// Mode is the FPE mode, either "FF1" or "FF3-1".
+	Mode string
+
+	// Alphabet is the set of characters that get encrypted.
+	Alphabet string
+
+	// RevealFirst and RevealLast are the number of leading and
+	// trailing characters revealed by the Reveal API.
+	RevealFirst int
+	RevealLast  int
+
+	// MaskChar is the character used to mask values.
+	MaskChar string
+}
+
+// FolderConfig is a structure that holds the grants
+// and tags of a key folder.
+type FolderConfig struct {
+	// Grants maps policy names to the key operations
+	// the policy's identities may perform with keys
+	// within the folder.
+	Grants map[string][]string
+
+	// Tags are attached to all keys within the folder.
+	Tags map[string]string
+}
+
+// KeyLifecycleConfig is a structure that holds the
+// scheduled state transitions of keys.
+type KeyLifecycleConfig struct {
+	// Schedules maps key names, or prefix patterns like
+	// "app-*", to their schedules.
+	Schedules map[string]KeySchedule
+
+	// Notice is the time before a transition at which
+	// a notice is emitted.
+	Notice time.Duration
+
+	// Sink is an optional HTTP endpoint that receives
+	// every notice.
+	Sink string
+}
+
+// KeySchedule is a structure that holds the dates of
+// the state transitions of a key.
+type KeySchedule struct {
+	// DecryptOnly is the date at which the key can only
+	// be used to decrypt existing ciphertexts.
+	DecryptOnly time.Time
+
+	// Disable is the date at which the key can no longer
+	// be used for any crypto operation.
+	Disable time.Time
+
+	// Purge is the date at which the key gets deleted.
+	Purge time.Time
+}
+
+// TenantConfig is a structure that holds the configuration
+// of a tenant.
+type TenantConfig struct {
+	// Prefix is the key name prefix that identifies the
+	// tenant's keys.
+	Prefix string
+
+	// KeyStore is an optional keystore that stores the tenant's
+	// KEK. If nil, the KEK is stored at the server's keystore.
+	KeyStore KeyStore
+}
+
+// JobConfig is a structure that holds the keystores of
+// jobs that copy all keys to another keystore.
+type JobConfig struct {
+	// MigrationTarget is the keystore the migrate job
+	// copies all keys to.
+	MigrationTarget KeyStore
+
+	// BackupTarget is the keystore the backup job
+	// copies all keys to.
+	BackupTarget KeyStore
+}
+
+// LogConfig is a structure that holds the logging configuration
+// for a KES server.
+type LogConfig struct {
+	// Error determines whether the KES server logs error events to STDERR.
+	// It does not en/disable error logging in general.
+	ErrLevel slog.Level
+
+	// Audit determines whether the KES server logs audit events to STDOUT.
+	// It does not en/disable audit logging in general.
+	AuditLevel slog.Level
+
+	// Request, if set, enables the request log. Requests are
+	// logged to STDERR.
+	Request *RequestLogConfig
+
+	// Pseudonymization, if set, replaces identities and key
+	// names in audit events with pseudonyms.
+	Pseudonymization *AuditPseudonymizationConfig
+}
+
+// AuditPseudonymizationConfig is a structure that holds the
+// audit log pseudonymization configuration.
+type AuditPseudonymizationConfig struct {
+	// Key is the HMAC key used to compute pseudonyms. If empty,
+	// the key is generated and stored at the keystore.
+	Key []byte
+}
+
+// RequestLogConfig is a structure that holds the request log
+// configuration.
+type RequestLogConfig struct {
+	// SampleRate is the fraction of successful requests that
+	// get logged. If zero, all requests are logged.
+	SampleRate float64
+
+	// Body controls whether JSON request bodies are logged.
+	Body bool
+
+	// Redact contains additional names of request headers,
+	// query parameters and JSON fields that get redacted.
+	Redact []string
+}
+
+// APIConfig is a structure that holds the API configuration
+// for a KES server.
+type APIConfig struct {
+	// Paths contains a set of API paths and there
+	// API configuration.
+	Paths map[string]APIPathConfig
+}
+
+// APIPathConfig is a structure that holds the API configuration
+// for one particular KES API.
+type APIPathConfig struct {
+	// Timeout is the duration after which the API response
+	// with a HTTP timeout error response. If Timeout is
+	// zero the API default is used.
+	Timeout time.Duration
+
+	// InsecureSkipAuth controls whether the API verifies
+	// client identities. If InsecureSkipAuth is true,
+	// the API accepts requests from arbitrary identities.
+	// In this mode, the API can be used by anyone who can
+	// communicate to the KES server over HTTPS.
+	// This should only be set for testing or in certain
+	// cases for APIs that don't expose sensitive information,
+	// like metrics.
+	InsecureSkipAuth bool
+
+	// Deprecated, if set, marks the v1 API as deprecated since
+	// the given time. Clients are pointed to the v2 API.
+	Deprecated time.Time
+
+	// Sunset, if set, is the time after which the v1 API may
+	// become unavailable.
+	Sunset time.Time
+}
+
+// Policy is a structure defining a KES policy.
+//
+// Any request issued by a KES identity is validated
+// by the associated allow and deny patterns. A
+// request is accepted if and only if no deny pattern
+// and at least one allow pattern matches the request.
+type Policy struct {
+	// Allow is the list of API path patterns
+	// that are explicitly allowed.
+	Allow []string
+
+	// Deny is the list of API path patterns
+	// that are explicitly denied.
+	Deny []string
+
+	// Identities is a list of KES identities
+	// that are assigned to this policy.
+	//
+	// It must not contain the admin or any
+	// TLS proxy identity.
+	Identities []kes.Identity
+
+	// Geo, if set, restricts requests of the
+	// assigned identities by client location.
+	Geo *GeoCondition
+}
+
+// GeoCondition is a policy condition that restricts
+// requests by the location of the client IP address.
+type GeoCondition struct {
+	// Paths is the list of API path patterns the
+	// condition applies to. If empty, it applies
+	// to all requests.
+	Paths []string
+
+	// Countries is the list of ISO 3166-1 alpha-2
+	// country codes, like "DE", clients may be in.
+	Countries []string
+
+	// Continents is the list of continent codes,
+	// like "EU", clients may be in.
+	Continents []string
+}
+
+// GeoIPConfig is a structure that holds the GeoIP
+// configuration.
+type GeoIPConfig struct {
+	// Database is the path to a MaxMind DB file,
+	// like GeoLite2-Country.mmdb.
+	Database string
+}
+
+// Key is a structure defining a cryptographic key
+// that the KES server will create or ensure exists
+// before startup.
+type Key struct {
+	// Name is the name of the cryptographic key.
+	Name string
+}
+
+// KeyStore is a KES keystore configuration.
+//
+// Concrete instances implement Connect to return
+// a connection to a concrete keystore.
+type KeyStore interface {
+	// Connect establishes and returns a new connection
+	// to the keystore.
+	Connect(ctx context.Context) (kes.KeyStore, error)
+}
+
+// FSKeyStore is a structure containing the configuration
+// for a simple filesystem keystore.
+//
+// A FSKeyStore should only be used when testing a KES server.
+type FSKeyStore struct {
+	// Path is the path to the directory that
+	// contains the keys.
+	//
+	// If the directory does not exist, it
+	// will be created.
+	Path string
+
+	// Sync controls when keys are flushed to stable
+	// storage. Either "always", "data" or "none".
+	// If empty, defaults to "always".
+	Sync string
+
+	// Sharding stores keys in two levels of
+	// sub-directories based on the hash of
+	// the key name.
+	Sharding bool
+}
+
+// Connect returns a kv.Store that stores key-value pairs in a path on the filesystem.
+func (s *FSKeyStore) Connect(context.Context) (kes.KeyStore, error) {
+	mode, err := fs.ParseSyncMode(s.Sync)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Open(&fs.Config{
+		Dir:      s.Path,
+		Sync:     mode,
+		Sharding: s.Sharding,
+	})
+}
+
+// EncryptedFSKeyStore is a structure containing the configuration
+// for a simple filesystem keystore.
+//
+// An EncryptedFSKeyStore should only be used when testing a KES server.
+type EncryptedFSKeyStore struct {
+	// MasterKeyPath is the path of the file containing the master key.
+	MasterKeyPath string
+	// MasterKeyCipher is the cipher to load the master key.
+	MasterKeyCipher string
+	// Path is the path to the directory that
+	// contains the keys.
+	//
+	// If the directory does not exist, it
+	// will be created.
+	Path string
+	// PreviousMasterKeys are master keys replaced
+	// by the current master key. Keys encrypted
+	// with any of them get re-encrypted with the
+	// current master key on startup.
+	PreviousMasterKeys []EncryptedFSMasterKey
+}
+
+// EncryptedFSMasterKey is a master key file of
+// an EncryptedFSKeyStore.
+type EncryptedFSMasterKey struct {
+	// Path is the path of the file containing the master key.
+	Path string
+	// Cipher is the cipher to load the master key.
+	Cipher string
+}
+
+// Connect returns a kes.KeyStore that stores encrypted key-value pairs in a path on the filesystem.
+func (s *EncryptedFSKeyStore) Connect(context.Context) (kes.KeyStore, error) {
+	config := &efs.Config{
+		Dir: s.Path,
+		MasterKey: efs.MasterKey{
+			Path:   s.MasterKeyPath,
+			Cipher: s.MasterKeyCipher,
+		},
+	}
+	for _, key := range s.PreviousMasterKeys {
+		config.PreviousMasterKeys = append(config.PreviousMasterKeys, efs.MasterKey{
+			Path:   key.Path,
+			Cipher: key.Cipher,
+		})
+	}
+	return efs.Open(config)
+}
+
+// KESKeyStore is a structure containing the configuration
+// for fetching keys from upstream KES servers.
+//
+// With a KESKeyStore, the KES server acts as caching proxy in
+// front of the upstream servers. It enforces its own policies
+// and serves cached keys locally. The cache expiry bounds how
+// long keys remain usable after they have been deleted at the
+// upstream servers.
+type KESKeyStore struct {
+	// Endpoints are the endpoints of the upstream KES servers.
+	Endpoints []string
+
+	// PrivateKey is the path to the private key of the TLS
+	// client certificate used to authenticate to the upstream
+	// servers. Its identity must be a standby identity of the
+	// upstream servers.
+	PrivateKey string
+
+	// Certificate is the path to the TLS client certificate
+	// used to authenticate to the upstream servers.
+	Certificate string
+
+	// CAPath is an optional path to the root CA certificate(s)
+	// for verifying the TLS certificates of the upstream
+	// servers. If empty, the OS default root CA set is used.
+	CAPath string
+}
+
+// Connect returns a kes.KeyStore that fetches keys from the
+// upstream KES servers.
+func (s *KESKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	certificate, err := https.CertificateFromFile(s.Certificate, s.PrivateKey, "")
+	if err != nil {
+		return nil, fmt.Errorf("kesconf: failed to load kes keystore client certificate: %v", err)
+	}
+	var rootCAs *x509.CertPool
+	if s.CAPath != "" {
+		if rootCAs, err = https.CertPoolFromFile(s.CAPath); err != nil {
+			return nil, fmt.Errorf("kesconf: failed to load kes keystore CA certificates: %v", err)
+		}
+	}
+	return upstream.Connect(ctx, &upstream.Config{
+		Endpoints: s.Endpoints,
+		TLS: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{certificate},
+			RootCAs:      rootCAs,
+		},
+	})
+}
+
+// VaultKeyStore is a structure containing the configuration
+// for Hashicorp Vault.
+type VaultKeyStore struct {
+	// Endpoint is the Hashicorp Vault endpoint.
+	Endpoint string
+
+	// Namespace is an optional Hashicorp Vault namespace.
+	// An empty namespace means no particular namespace
 	// is used.
 	Namespace string
 
@@ -443,6 +1773,17 @@ type VaultKeyStore struct {
 	// level.
 	Prefix string
 
+	// DeleteVersionAfter is the duration after which Vault
+	// deletes K/V v2 entries. If zero, the engine's setting
+	// applies. Requires APIVersion "v2".
+	DeleteVersionAfter time.Duration
+
+	// PinnedVersion is the version of K/V v2 entries that is
+	// read, even if an entry has been overwritten. If zero,
+	// the latest version is read and reading fails if an
+	// entry has been overwritten. Requires APIVersion "v2".
+	PinnedVersion int
+
 	// AppRole contains the Vault AppRole authentication
 	// method credentials.
 	AppRole *VaultAppRoleAuth
@@ -457,7 +1798,8 @@ type VaultKeyStore struct {
 	//
 	// This is an optional and additional layer of encryption.
 	// Since Vault manages and encrypts K/V values in any case,
-	// using the transit engine is usually not necessary.
+	// using the transit engine is usually not necessary, unless
+	// keys are stored at the transit KeyStore instead of Vault.
 	Transit *VaultTransit
 
 	// PrivateKey is an optional path to a
@@ -486,6 +1828,16 @@ type VaultKeyStore struct {
 	// is checked.
 	// If not set, defaults to 10s.
 	StatusPing time.Duration
+
+	// ReadEndpoints are optional endpoints of Vault performance
+	// standbys. If set, reads are routed to the fastest standby
+	// while writes are sent to Endpoint.
+	ReadEndpoints []string
+
+	// MaxStaleness is the max. replication lag of the read
+	// endpoints. Keys written within MaxStaleness are read
+	// from Endpoint. If zero, defaults to 5s.
+	MaxStaleness time.Duration
 }
 
 // VaultAppRoleAuth is a structure containing the configuration
@@ -556,6 +1908,12 @@ type VaultTransit struct {
 
 	// KeyName is the name of the key used for en/decryption.
 	KeyName string
+
+	// KeyStore, if set, is the keystore at which keys are
+	// stored. Keys are wrapped with the transit key before
+	// they are stored. Hence, Vault does not store any key
+	// but acts as root of trust. The K/V engine is not used.
+	KeyStore KeyStore
 }
 
 // Connect returns a kv.Store that stores key-value pairs on a Hashicorp Vault server.
@@ -576,6 +1934,9 @@ func (s *VaultKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
 		Certificate:     s.Certificate,
 		CAPath:          s.CAPath,
 		StatusPingAfter: s.StatusPing,
+
+		DeleteVersionAfter: s.DeleteVersionAfter,
+		PinnedVersion:      s.PinnedVersion,
 	}
 	if s.AppRole != nil {
 		c.AppRole = &vault.AppRole{
@@ -599,7 +1960,29 @@ func (s *VaultKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
 			KeyName: s.Transit.KeyName,
 		}
 	}
-	return vault.Connect(ctx, c)
+
+	if s.Transit != nil && s.Transit.KeyStore != nil {
+		store, err := s.Transit.KeyStore.Connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		transit, err := vault.ConnectTransit(ctx, c, store)
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+		return transit, nil
+	}
+
+	store, err := vault.Connect(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return connectReplicas(store, s.ReadEndpoints, s.MaxStaleness, func(endpoint string) (kes.KeyStore, error) {
+		c := c.Clone()
+		c.Endpoint = endpoint
+		return vault.Connect(ctx, c)
+	})
 }
 
 // FortanixKeyStore is a structure containing the
@@ -609,6 +1992,8 @@ type FortanixKeyStore struct {
 	Endpoint string
 
 	// GroupID is the ID of the access control group.
+	// If set, new keys are created within this group
+	// and keys in other groups are not accessible.
 	GroupID string
 
 	// APIKey is the API key for authenticating to
@@ -657,6 +2042,16 @@ type KeySecureKeyStore struct {
 	// If empty, the OS default root CA set is
 	// used.
 	CAPath string
+
+	// Retry is the time to wait before retrying to
+	// renew the authentication token after a failed
+	// attempt. If 0, a reasonable default is used.
+	Retry time.Duration
+
+	// PoolSize is the max. number of idle connections
+	// kept open to the KeySecure server. If 0, a
+	// reasonable default is used.
+	PoolSize int
 }
 
 // Connect returns a kv.Store that stores key-value pairs on a Gemalto KeySecure instance.
@@ -667,7 +2062,9 @@ func (s *KeySecureKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
 		Login: gemalto.Credentials{
 			Token:  s.Token,
 			Domain: s.Domain,
+			Retry:  s.Retry,
 		},
+		PoolSize: s.PoolSize,
 	})
 }
 
@@ -677,9 +2074,16 @@ type GCPSecretManagerKeyStore struct {
 	// ProjectID is the GCP project ID.
 	ProjectID string
 
+	// Region pins the SecretManager to a region, like
+	// "europe-west3". Secrets are then stored as regional
+	// secrets and not replicated.
+	Region string
+
 	// Endpoint is the GCP project ID. If empty,
 	// defaults to:
 	//   secretmanager.googleapis.com:443
+	// or, if a Region is set, to:
+	//   secretmanager.<region>.rep.googleapis.com:443
 	Endpoint string
 
 	// Scopes are GCP OAuth2 scopes for accessing
@@ -706,21 +2110,145 @@ type GCPSecretManagerKeyStore struct {
 	// service account used to access the
 	// SecretManager.
 	Key string
+
+	// CredentialsFile is the path to a workload identity
+	// federation (external account) credential configuration
+	// file. It must not be set when service account credentials
+	// are specified.
+	CredentialsFile string
+
+	// KMSKey is the Cloud KMS key (CMEK) used to encrypt
+	// automatically replicated secrets. It must be a key
+	// in the "global" location.
+	KMSKey string
+
+	// Replicas are the locations secrets are replicated to.
+	// If empty, secrets are replicated automatically.
+	Replicas []GCPReplica
+
+	// Labels are attached to all created secrets. If not
+	// empty, only secrets with matching labels are listed,
+	// read or deleted. Distinct labels allow multiple KES
+	// clusters to share one GCP project.
+	Labels map[string]string
 }
 
-// Connect returns a kv.Store that stores key-value pairs on GCP SecretManager.
-func (s *GCPSecretManagerKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
-	return gcp.Connect(ctx, &gcp.Config{
-		Endpoint:  s.Endpoint,
-		ProjectID: s.ProjectID,
-		Scopes:    s.Scopes,
+// GCPReplica is a GCP SecretManager replica location
+// of a secret with a user-managed replication policy.
+type GCPReplica struct {
+	// Location is the GCP region of the replica,
+	// for example "us-east1".
+	Location string
+
+	// KMSKey is the Cloud KMS key (CMEK) used to
+	// encrypt the replica. It must be a key in the
+	// replica's location. If empty, a Google-managed
+	// key is used.
+	KMSKey string
+}
+
+// Connect returns a kv.Store that stores key-value pairs on GCP SecretManager.
+func (s *GCPSecretManagerKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	var replicas []gcp.Replica
+	for _, r := range s.Replicas {
+		replicas = append(replicas, gcp.Replica{
+			Location: r.Location,
+			KMSKey:   r.KMSKey,
+		})
+	}
+	return gcp.Connect(ctx, &gcp.Config{
+		Endpoint:  s.Endpoint,
+		ProjectID: s.ProjectID,
+		Region:    s.Region,
+		Scopes:    s.Scopes,
+		Credentials: gcp.Credentials{
+			ClientID: s.ClientID,
+			Client:   s.ClientEmail,
+			KeyID:    s.KeyID,
+			Key:      s.Key,
+		},
+		ExternalAccountFile: s.CredentialsFile,
+		KMSKey:              s.KMSKey,
+		Replicas:            replicas,
+		Labels:              s.Labels,
+	})
+}
+
+// GCPKMSKeyStore is a structure containing the
+// configuration for wrapping keys with GCP Cloud KMS.
+//
+// Keys are wrapped with a Cloud KMS key and stored at
+// another keystore, like a GCS bucket.
+type GCPKMSKeyStore struct {
+	// Endpoint is the Cloud KMS endpoint. If empty,
+	// defaults to:
+	//   cloudkms.googleapis.com:443
+	Endpoint string
+
+	// Key is the resource name of the Cloud KMS key
+	// wrapping all keys:
+	//   projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>
+	Key string
+
+	// RequireHSM requires the Cloud KMS key to be
+	// protected by a Cloud HSM.
+	RequireHSM bool
+
+	// ClientEmail is the Client email of the
+	// GCP service account used to access Cloud KMS.
+	ClientEmail string
+
+	// ClientID is the Client ID of the GCP
+	// service account used to access Cloud KMS.
+	ClientID string
+
+	// PrivateKeyID is the private key ID of the GCP
+	// service account used to access Cloud KMS.
+	PrivateKeyID string
+
+	// PrivateKey is the private key of the GCP
+	// service account used to access Cloud KMS.
+	PrivateKey string
+
+	// CredentialsFile is the path to a workload identity
+	// federation (external account) credential configuration
+	// file. It must not be set when service account credentials
+	// are specified.
+	CredentialsFile string
+
+	// KeyStore is the keystore at which wrapped keys are stored.
+	KeyStore KeyStore
+}
+
+// Connect returns a kes.KeyStore that wraps all keys with a
+// Cloud KMS key before storing them at the nested keystore.
+func (s *GCPKMSKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	if s.KeyStore == nil {
+		return nil, errors.New("kesconf: invalid GCP KMS keystore: no keystore specified")
+	}
+
+	store, err := s.KeyStore.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	kms, err := gcpkms.Connect(ctx, &gcpkms.Config{
+		Endpoint:   s.Endpoint,
+		Key:        s.Key,
+		RequireHSM: s.RequireHSM,
 		Credentials: gcp.Credentials{
 			ClientID: s.ClientID,
 			Client:   s.ClientEmail,
-			KeyID:    s.KeyID,
-			Key:      s.Key,
+			KeyID:    s.PrivateKeyID,
+			Key:      s.PrivateKey,
 		},
+		ExternalAccountFile: s.CredentialsFile,
+		Store:               store,
 	})
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	return kms, nil
 }
 
 // AWSSecretsManagerKeyStore is a structure containing the
@@ -741,6 +2269,73 @@ type AWSSecretsManagerKeyStore struct {
 	// If empty, the default AWS KMS key is used.
 	KMSKey string
 
+	// Prefix is an optional prefix of all secret names. Keys
+	// are stored as "<prefix>/<name>".
+	Prefix string
+
+	// Overwrite controls whether existing secrets may be
+	// replaced. If false, secrets are only created, never
+	// updated.
+	Overwrite bool
+
+	// Binary controls whether values are stored as SecretBinary
+	// instead of SecretString. Either is read.
+	Binary bool
+
+	// Tags are attached to all created secrets.
+	Tags map[string]string
+
+	// Replicas are the regions secrets are replicated to.
+	Replicas []AWSReplica
+
+	// PlainHTTP controls whether the endpoint is accessed
+	// via plain HTTP instead of HTTPS, e.g. for LocalStack.
+	PlainHTTP bool
+
+	// InsecureSkipVerify controls whether the TLS certificate
+	// of the endpoint is verified. It should only be used for
+	// testing.
+	InsecureSkipVerify bool
+
+	// Proxy is an optional URL of an HTTP(S) proxy used for
+	// all AWS requests.
+	Proxy string
+
+	// CAPath is an optional path to a PEM-encoded CA bundle
+	// used to verify the TLS certificates of AWS, or of a
+	// TLS-intercepting proxy.
+	CAPath string
+
+	// DialTimeout is the max. time to wait for a connection
+	// to AWS. If zero, the AWS SDK default is used.
+	DialTimeout time.Duration
+
+	// ResponseTimeout is the max. time to wait for a response.
+	// If zero, there is no timeout.
+	ResponseTimeout time.Duration
+
+	// UseFIPSEndpoint controls whether the FIPS endpoints of
+	// SecretsManager and STS are used. It requires an empty
+	// Endpoint.
+	UseFIPSEndpoint bool
+
+	// UseDualStackEndpoint controls whether the dual-stack
+	// endpoints of SecretsManager and STS are used. It requires
+	// an empty Endpoint.
+	UseDualStackEndpoint bool
+
+	// RetryMode is the retry mode, either "standard" or
+	// "adaptive". If empty, the standard mode is used.
+	RetryMode string
+
+	// RetryMaxAttempts is the max. number of attempts per
+	// request. If zero, the AWS SDK default is used.
+	RetryMaxAttempts int
+
+	// RetryMaxBackoff is the max. time to wait between
+	// two attempts. If zero, the AWS SDK default is used.
+	RetryMaxBackoff time.Duration
+
 	// AccessKey is the access key for authenticating to AWS.
 	AccessKey string
 
@@ -750,20 +2345,299 @@ type AWSSecretsManagerKeyStore struct {
 	// SessionToken is an optional session token for authenticating
 	// to AWS.
 	SessionToken string
+
+	// RoleARN is the ARN of an optional IAM role assumed
+	// via STS AssumeRole. The access key, or the default
+	// AWS credential chain, is used as base identity.
+	RoleARN string
+
+	// ExternalID is an optional external ID passed to
+	// STS when assuming the role.
+	ExternalID string
+
+	// SessionName is an optional name of the role session.
+	SessionName string
+
+	// WebIdentityTokenFile is an optional path to a web identity token,
+	// e.g. the projected service account token of an EKS pod
+	// using IAM Roles for Service Accounts (IRSA). If set,
+	// the role is assumed via AssumeRoleWithWebIdentity.
+	WebIdentityTokenFile string
+}
+
+// AWSReplica is an AWS region secrets are replicated to.
+type AWSReplica struct {
+	// Region is the AWS region of the replica.
+	Region string
+
+	// KMSKey is the AWS-KMS key ID used to encrypt the
+	// replica. If empty, the default AWS-KMS key is used.
+	KMSKey string
 }
 
 // Connect returns a kv.Store that stores key-value pairs on AWS SecretsManager.
 func (s *AWSSecretsManagerKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	var replicas []aws.ReplicaRegion
+	for _, r := range s.Replicas {
+		replicas = append(replicas, aws.ReplicaRegion{
+			Region:   r.Region,
+			KMSKeyID: r.KMSKey,
+		})
+	}
 	return aws.Connect(ctx, &aws.Config{
+		Addr:      s.Endpoint,
+		Region:    s.Region,
+		KMSKeyID:  s.KMSKey,
+		Prefix:    s.Prefix,
+		Overwrite: s.Overwrite,
+		Binary:    s.Binary,
+		Tags:      s.Tags,
+
+		ReplicaRegions: replicas,
+		Login: aws.Credentials{
+			AccessKey:    s.AccessKey,
+			SecretKey:    s.SecretKey,
+			SessionToken: s.SessionToken,
+		},
+		RoleARN:              s.RoleARN,
+		ExternalID:           s.ExternalID,
+		SessionName:          s.SessionName,
+		WebIdentityTokenFile: s.WebIdentityTokenFile,
+		PlainHTTP:            s.PlainHTTP,
+		InsecureSkipVerify:   s.InsecureSkipVerify,
+		ProxyURL:             s.Proxy,
+		CAPath:               s.CAPath,
+		DialTimeout:          s.DialTimeout,
+		ResponseTimeout:      s.ResponseTimeout,
+		UseFIPSEndpoint:      s.UseFIPSEndpoint,
+		UseDualStackEndpoint: s.UseDualStackEndpoint,
+		Retry: aws.RetryConfig{
+			Mode:        s.RetryMode,
+			MaxAttempts: s.RetryMaxAttempts,
+			MaxBackoff:  s.RetryMaxBackoff,
+		},
+	})
+}
+
+// AWSKMSKeyStore is a structure containing the configuration
+// for a filesystem keystore encrypted with AWS KMS data keys.
+type AWSKMSKeyStore struct {
+	// Path is the path to the directory that
+	// contains the encrypted keys.
+	//
+	// If the directory does not exist, it
+	// will be created.
+	Path string
+
+	// Endpoint is an optional AWS KMS endpoint, like
+	// a VPC endpoint. If empty, the regional endpoint
+	// is used.
+	Endpoint string
+
+	// Region is the AWS region of the KMS key.
+	Region string
+
+	// KeyID is the ID, ARN or alias of the customer-managed
+	// KMS key that wraps the data keys.
+	KeyID string
+
+	// AccessKey is the access key for authenticating to AWS.
+	AccessKey string
+
+	// SecretKey is the secret key for authenticating to AWS.
+	SecretKey string
+
+	// SessionToken is an optional session token for authenticating
+	// to AWS.
+	SessionToken string
+}
+
+// Connect returns a kes.KeyStore that stores key-value pairs in a path
+// on the filesystem encrypted with AWS KMS data keys.
+func (s *AWSKMSKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	return awskms.Connect(ctx, &awskms.Config{
+		Dir:    s.Path,
+		Addr:   s.Endpoint,
+		Region: s.Region,
+		KeyID:  s.KeyID,
+		Login: awskms.Credentials{
+			AccessKey:    s.AccessKey,
+			SecretKey:    s.SecretKey,
+			SessionToken: s.SessionToken,
+		},
+	})
+}
+
+// AWSSSMKeyStore is a structure containing the configuration
+// for the AWS SSM Parameter Store.
+type AWSSSMKeyStore struct {
+	// Endpoint is an optional SSM endpoint, like a VPC
+	// endpoint. If empty, the regional endpoint is used.
+	Endpoint string
+
+	// Region is the AWS region of the Parameter Store.
+	Region string
+
+	// Path is the parameter hierarchy, e.g. "/kes/prod",
+	// keys are stored in. If empty, "/kes" is used.
+	Path string
+
+	// KMSKey is the AWS-KMS key ID used to encrypt the
+	// parameters. If empty, the AWS managed key is used.
+	KMSKey string
+
+	// Tier is the parameter tier. If empty, the free
+	// standard tier is used.
+	Tier string
+
+	// AccessKey is the access key for authenticating to AWS.
+	AccessKey string
+
+	// SecretKey is the secret key for authenticating to AWS.
+	SecretKey string
+
+	// SessionToken is an optional session token for authenticating
+	// to AWS.
+	SessionToken string
+}
+
+// Connect returns a kes.KeyStore that stores key-value pairs as
+// SecureString parameters at the AWS SSM Parameter Store.
+func (s *AWSSSMKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	return ssm.Connect(ctx, &ssm.Config{
 		Addr:     s.Endpoint,
 		Region:   s.Region,
+		Path:     s.Path,
 		KMSKeyID: s.KMSKey,
-		Login: aws.Credentials{
+		Tier:     s.Tier,
+		Login: ssm.Credentials{
+			AccessKey:    s.AccessKey,
+			SecretKey:    s.SecretKey,
+			SessionToken: s.SessionToken,
+		},
+	})
+}
+
+// AWSDynamoDBKeyStore is a structure containing the
+// configuration for AWS DynamoDB.
+type AWSDynamoDBKeyStore struct {
+	// Endpoint is an optional DynamoDB endpoint, like a VPC
+	// endpoint. If empty, the regional endpoint is used.
+	Endpoint string
+
+	// Region is the AWS region of the DynamoDB table.
+	Region string
+
+	// Table is the name of the DynamoDB table.
+	Table string
+
+	// EventualConsistency controls whether keys are read
+	// with eventually consistent reads.
+	EventualConsistency bool
+
+	// AccessKey is the access key for authenticating to AWS.
+	AccessKey string
+
+	// SecretKey is the secret key for authenticating to AWS.
+	SecretKey string
+
+	// SessionToken is an optional session token for authenticating
+	// to AWS.
+	SessionToken string
+
+	// ReadRegions are optional regions of DynamoDB global
+	// table replicas. If set, reads are routed to the fastest
+	// replica while writes are sent to Region.
+	ReadRegions []string
+
+	// MaxStaleness is the max. replication lag of the read
+	// regions. Keys written within MaxStaleness are read
+	// from Region. If zero, defaults to 5s.
+	MaxStaleness time.Duration
+}
+
+// Connect returns a kes.KeyStore that stores key-value pairs as
+// items of an AWS DynamoDB table.
+func (s *AWSDynamoDBKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	config := &dynamodb.Config{
+		Addr:                s.Endpoint,
+		Region:              s.Region,
+		Table:               s.Table,
+		EventualConsistency: s.EventualConsistency,
+		Login: dynamodb.Credentials{
 			AccessKey:    s.AccessKey,
 			SecretKey:    s.SecretKey,
 			SessionToken: s.SessionToken,
 		},
+	}
+	store, err := dynamodb.Connect(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return connectReplicas(store, s.ReadRegions, s.MaxStaleness, func(region string) (kes.KeyStore, error) {
+		// Replicas use their regional endpoint. A custom
+		// endpoint, like a VPC endpoint, is region-specific.
+		config := *config
+		config.Addr = ""
+		config.Region = region
+		return dynamodb.Connect(ctx, &config)
+	})
+}
+
+// AWSCloudHSMKeyStore is a structure containing the
+// configuration for wrapping keys with AWS CloudHSM.
+//
+// Keys are wrapped with an AES key of the CloudHSM cluster
+// via PKCS#11 and stored at another keystore.
+type AWSCloudHSMKeyStore struct {
+	// Library is the path of the CloudHSM PKCS#11 library.
+	// If empty, the default path of the CloudHSM client
+	// installation is used.
+	Library string
+
+	// TokenLabel is an optional label of the token containing
+	// the AES key. If empty, the first token is used.
+	TokenLabel string
+
+	// PIN is the PIN of the crypto user in the form
+	// "<crypto-user>:<password>".
+	PIN string
+
+	// KeyLabel is the label of the AES key wrapping all keys.
+	KeyLabel string
+
+	// KeyStore is the keystore at which wrapped keys are stored.
+	KeyStore KeyStore
+}
+
+// Connect returns a kes.KeyStore that wraps all keys with an
+// AES key of the CloudHSM cluster before storing them at the
+// nested keystore.
+func (s *AWSCloudHSMKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	if s.KeyStore == nil {
+		return nil, errors.New("kesconf: invalid AWS CloudHSM keystore: no keystore specified")
+	}
+	library := s.Library
+	if library == "" {
+		library = pkcs11.DefaultCloudHSMLibrary
+	}
+
+	store, err := s.KeyStore.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hsm, err := pkcs11.Connect(ctx, &pkcs11.Config{
+		Library:    library,
+		TokenLabel: s.TokenLabel,
+		PIN:        s.PIN,
+		KeyLabel:   s.KeyLabel,
+		Store:      store,
 	})
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	return hsm, nil
 }
 
 // AzureKeyVaultKeyStore is a structure containing the
@@ -772,6 +2646,19 @@ type AzureKeyVaultKeyStore struct {
 	// Endpoint is the Azure KeyVault endpoint.
 	Endpoint string
 
+	// Cloud is the Azure cloud of the KeyVault, either
+	// "AzurePublic", "AzureUSGovernment" or "AzureChina".
+	// If empty, the KeyVault endpoint is not checked and
+	// credentials authenticate to the AuthorityHost.
+	Cloud string
+
+	// AuthorityHost is the Microsoft Entra ID authority host,
+	// like "https://login.microsoftonline.us/". It overrides
+	// the Cloud's authority host. If both are empty, the
+	// AZURE_AUTHORITY_HOST environment variable or the
+	// Azure public cloud is used.
+	AuthorityHost string
+
 	// TenantID is the ID of the Azure KeyVault tenant.
 	TenantID string
 
@@ -783,32 +2670,169 @@ type AzureKeyVaultKeyStore struct {
 	// Azure KeyVault.
 	ClientSecret string
 
+	// ClientCertificateFile is the path to a PEM or PKCS#12
+	// file containing a client certificate and its RSA private
+	// key. If set, the client with the TenantID and ClientID
+	// authenticates with the certificate instead of a secret.
+	ClientCertificateFile string
+
+	// ClientCertificatePassword is the optional password
+	// of the PKCS#12 ClientCertificateFile.
+	ClientCertificatePassword string
+
+	// ClientCertificateSendChain controls whether the client
+	// certificate chain is sent to Microsoft Entra ID. It is
+	// required for subject name and issuer authentication.
+	ClientCertificateSendChain bool
+
+	// ManagedIdentity controls whether the KeyVault is accessed
+	// with an Azure managed identity. If neither a client ID nor
+	// a resource ID is specified, the system-assigned managed
+	// identity is used.
+	ManagedIdentity bool
+
 	// ManagedIdentityClientID is the client ID of the
 	// Azure managed identity that access the KeyVault.
+	// Setting it implies ManagedIdentity.
 	ManagedIdentityClientID string
+
+	// ManagedIdentityResourceID is the resource ID of
+	// the user-assigned Azure managed identity that
+	// access the KeyVault.
+	ManagedIdentityResourceID string
+
+	// WorkloadIdentity controls whether the KeyVault is accessed
+	// with an Azure workload identity, for example of an AKS pod,
+	// using a federated Kubernetes service account token.
+	WorkloadIdentity bool
+
+	// WorkloadIdentityTenantID, WorkloadIdentityClientID and
+	// WorkloadIdentityTokenFile default to the AZURE_TENANT_ID,
+	// AZURE_CLIENT_ID and AZURE_FEDERATED_TOKEN_FILE environment
+	// variables set by the AKS workload identity webhook.
+	WorkloadIdentityTenantID  string
+	WorkloadIdentityClientID  string
+	WorkloadIdentityTokenFile string
+
+	// RecoverDeleted controls whether creating a key recovers
+	// a (soft) deleted secret with the same name instead of
+	// purging it. It is required to re-create keys when the
+	// KeyVault has purge protection enabled.
+	RecoverDeleted bool
+
+	// HSMKey is the name of the Managed HSM key wrapping
+	// all keys. It must be set if, and only if, the Endpoint
+	// is a Managed HSM, like:
+	//   https://<name>.managedhsm.azure.net
+	//
+	// Managed HSM does not store secrets. Hence, keys are
+	// wrapped with the HSM key and stored at the HSMKeyStore.
+	HSMKey string
+
+	// HSMKeyStore is the keystore at which keys wrapped
+	// by the Managed HSM are stored.
+	HSMKeyStore KeyStore
 }
 
 // Connect returns a kv.Store that stores key-value pairs on Azure KeyVault.
-func (s *AzureKeyVaultKeyStore) Connect(_ context.Context) (kes.KeyStore, error) {
-	if (s.TenantID != "" || s.ClientID != "" || s.ClientSecret != "") && s.ManagedIdentityClientID != "" {
+func (s *AzureKeyVaultKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	var (
+		certificate = s.ClientCertificateFile != ""
+		secret      = s.ClientSecret != "" || (!certificate && (s.TenantID != "" || s.ClientID != ""))
+		managed     = s.ManagedIdentity || s.ManagedIdentityClientID != "" || s.ManagedIdentityResourceID != ""
+		workload    = s.WorkloadIdentity
+	)
+	var methods int
+	for _, ok := range []bool{secret, certificate, managed, workload} {
+		if ok {
+			methods++
+		}
+	}
+	if methods > 1 {
 		return nil, errors.New("edge: failed to connect to Azure KeyVault: more than one authentication method specified")
 	}
+	cloud, err := azure.LookupCloud(s.Cloud, s.AuthorityHost)
+	if err != nil {
+		return nil, err
+	}
+	if err = cloud.CheckEndpoint(s.Endpoint); err != nil {
+		return nil, err
+	}
+
+	// Managed identities fetch access tokens from the instance
+	// metadata service. Hence, they do not use an authority host.
 	var cred azcore.TokenCredential
-	var err error
 	switch {
-	case s.TenantID != "" || s.ClientID != "" || s.ClientSecret != "":
-		cred, err = azidentity.NewClientSecretCredential(s.TenantID, s.ClientID, s.ClientSecret, nil)
-	case s.ManagedIdentityClientID != "":
-		cred, err = azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
-			ID: azidentity.ClientID(s.ManagedIdentityClientID),
+	case secret:
+		cred, err = azidentity.NewClientSecretCredential(s.TenantID, s.ClientID, s.ClientSecret, &azidentity.ClientSecretCredentialOptions{
+			ClientOptions: cloud.ClientOptions(),
+		})
+	case certificate:
+		cred, err = azure.NewClientCertificateCredential(azure.ClientCertificate{
+			TenantID:  s.TenantID,
+			ClientID:  s.ClientID,
+			File:      s.ClientCertificateFile,
+			Password:  s.ClientCertificatePassword,
+			SendChain: s.ClientCertificateSendChain,
+			Cloud:     cloud,
+		})
+	case managed:
+		cred, err = azure.NewManagedIdentityCredential(azure.ManagedIdentity{
+			ClientID:   s.ManagedIdentityClientID,
+			ResourceID: s.ManagedIdentityResourceID,
+		})
+	case workload:
+		cred, err = azure.NewWorkloadIdentityCredential(azure.WorkloadIdentity{
+			TenantID:  s.WorkloadIdentityTenantID,
+			ClientID:  s.WorkloadIdentityClientID,
+			TokenFile: s.WorkloadIdentityTokenFile,
+			Cloud:     cloud,
 		})
 	default:
-		cred, err = azidentity.NewDefaultAzureCredential(nil)
+		cred, err = azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+			ClientOptions: cloud.ClientOptions(),
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("azure: failed to create Azure credential: %v", err)
+	}
+
+	// Managed and workload identities as well as client
+	// certificates are configured outside of KES. Hence, verify
+	// them early to report misconfigured identities, federations
+	// or certificate registrations on startup.
+	if managed || workload || certificate {
+		if err = azure.VerifyCredential(ctx, s.Endpoint, cred); err != nil {
+			return nil, err
+		}
+	}
+	if azure.IsManagedHSM(s.Endpoint) {
+		if s.HSMKeyStore == nil {
+			return nil, errors.New("kesconf: invalid Azure Managed HSM keystore: no keystore specified")
+		}
+		store, err := s.HSMKeyStore.Connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		hsm, err := azure.ConnectManagedHSM(ctx, &azure.HSMConfig{
+			Endpoint:   s.Endpoint,
+			Key:        s.HSMKey,
+			Credential: cred,
+			Store:      store,
+		})
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+		return hsm, nil
 	}
+
+	store, err := azure.ConnectWithCredentials(s.Endpoint, cred)
 	if err != nil {
-		return nil, fmt.Errorf("azure: failed to create default Azure credential: %v", err)
+		return nil, err
 	}
-	return azure.ConnectWithCredentials(s.Endpoint, cred)
+	store.RecoverDeleted = s.RecoverDeleted
+	return store, nil
 }
 
 // EntrustKeyControlKeyStore is a structure containing the
@@ -860,3 +2884,205 @@ func (s *EntrustKeyControlKeyStore) Connect(ctx context.Context) (kes.KeyStore,
 		},
 	})
 }
+
+// SQLKeyStore is a structure containing the configuration
+// for a SQL database keystore.
+//
+// The KES server opens the database with the given driver.
+// The driver must be registered with database/sql, i.e.
+// compiled into the KES binary.
+type SQLKeyStore struct {
+	// Driver is the database/sql driver name, e.g. "pgx".
+	Driver string
+
+	// DSN is the data source name used to connect to the database.
+	DSN string
+
+	// Dialect is the SQL dialect, either "postgres", "mysql"
+	// or "sqlite". If empty, it is derived from the driver.
+	Dialect string
+
+	// Table is the name of the table that stores the keys.
+	// If empty, defaults to "kes_keys".
+	Table string
+
+	// MaxOpenConns limits the number of open connections.
+	// If zero, the number of connections is not limited.
+	MaxOpenConns int
+
+	// ReadDSNs are optional data source names of read-only
+	// database replicas, like Postgres hot standbys. If set,
+	// reads are routed to the fastest replica while writes
+	// are sent to DSN.
+	ReadDSNs []string
+
+	// MaxStaleness is the max. replication lag of the read
+	// replicas. Keys written within MaxStaleness are read
+	// from DSN. If zero, defaults to 5s.
+	MaxStaleness time.Duration
+}
+
+// Connect returns a kes.KeyStore that stores key-value pairs in a SQL database.
+func (s *SQLKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	var dialect sqlstore.Dialect
+	if s.Dialect != "" {
+		d, ok := sqlstore.LookupDialect(s.Dialect)
+		if !ok {
+			return nil, fmt.Errorf("kesconf: unknown SQL dialect '%s'", s.Dialect)
+		}
+		dialect = d
+	}
+	config := &sqlstore.Config{
+		Driver:       s.Driver,
+		DSN:          s.DSN,
+		Dialect:      dialect,
+		Table:        s.Table,
+		MaxOpenConns: s.MaxOpenConns,
+	}
+	store, err := sqlstore.Connect(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return connectReplicas(store, s.ReadDSNs, s.MaxStaleness, func(dsn string) (kes.KeyStore, error) {
+		config := *config
+		config.DSN = dsn
+		config.ReadOnly = true
+		return sqlstore.Connect(ctx, &config)
+	})
+}
+
+// connectReplicas connects to the read replicas of the primary
+// and returns a key store that routes reads to them. It returns
+// the primary if there are no replicas. On error, it closes the
+// primary and all replicas connected so far.
+func connectReplicas(primary kes.KeyStore, replicas []string, maxStaleness time.Duration, connect func(string) (kes.KeyStore, error)) (kes.KeyStore, error) {
+	if len(replicas) == 0 {
+		return primary, nil
+	}
+
+	stores := make([]kes.KeyStore, 0, len(replicas))
+	closeAll := func() {
+		primary.Close()
+		for _, store := range stores {
+			store.Close()
+		}
+	}
+	for _, name := range replicas {
+		store, err := connect(name)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		stores = append(stores, store)
+	}
+	store, err := replica.New(&replica.Config{
+		Primary:      primary,
+		Replicas:     stores,
+		MaxStaleness: maxStaleness,
+	})
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	return store, nil
+}
+
+// S3KeyStore is a structure containing the configuration
+// for an S3-compatible bucket, like AWS S3 or MinIO.
+type S3KeyStore struct {
+	// Endpoint is an optional S3 endpoint, like a MinIO
+	// server. If empty, the regional AWS endpoint is used.
+	Endpoint string
+
+	// Region is the region of the bucket. If empty,
+	// "us-east-1" is used.
+	Region string
+
+	// Bucket is the name of the bucket.
+	Bucket string
+
+	// Prefix is an optional object name prefix, like "kes/".
+	Prefix string
+
+	// KMSKey is an optional SSE-KMS key used to encrypt
+	// objects at rest.
+	KMSKey string
+
+	// RetentionMode is the object lock mode applied to new
+	// objects. Either "GOVERNANCE", "COMPLIANCE" or empty.
+	RetentionMode string
+
+	// RetentionPeriod is the time for which new objects
+	// are locked, if a RetentionMode is set.
+	RetentionPeriod time.Duration
+
+	// AccessKey is the access key for authenticating to S3.
+	AccessKey string
+
+	// SecretKey is the secret key for authenticating to S3.
+	SecretKey string
+
+	// SessionToken is an optional session token for
+	// authenticating to S3.
+	SessionToken string
+}
+
+// Connect returns a kes.KeyStore that stores key-value pairs
+// as objects in an S3 bucket.
+func (s *S3KeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	config := &s3.Config{
+		Endpoint: s.Endpoint,
+		Region:   s.Region,
+		Bucket:   s.Bucket,
+		Prefix:   s.Prefix,
+		KMSKeyID: s.KMSKey,
+		Login: s3.Credentials{
+			AccessKey:    s.AccessKey,
+			SecretKey:    s.SecretKey,
+			SessionToken: s.SessionToken,
+		},
+	}
+	if s.RetentionMode != "" {
+		config.Retention = &s3.Retention{
+			Mode:   s.RetentionMode,
+			Period: s.RetentionPeriod,
+		}
+	}
+	return s3.Connect(ctx, config)
+}
+
+// SplitKeyStore is a structure containing the configuration
+// for a keystore that splits every key into two shares stored
+// at two different keystores.
+//
+// Neither keystore alone reveals anything about the keys.
+// However, both keystores must be available to read or
+// write keys.
+type SplitKeyStore struct {
+	// Primary is the keystore that stores the random shares.
+	Primary KeyStore
+
+	// Secondary is the keystore that stores the key XOR
+	// the random shares. It should be a different kind of
+	// keystore, or at least be operated independently, than
+	// the primary keystore.
+	Secondary KeyStore
+}
+
+// Connect returns a kes.KeyStore that splits keys into shares stored
+// at the primary and secondary keystore.
+func (s *SplitKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	primary, err := s.Primary.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	secondary, err := s.Secondary.Connect(ctx)
+	if err != nil {
+		primary.Close()
+		return nil, err
+	}
+	return &kes.SplitKeyStore{
+		Primary:   primary,
+		Secondary: secondary,
+	}, nil
+}
@@ -5,8 +5,11 @@
 package kes
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"slices"
 	"strings"
@@ -16,6 +19,7 @@ import (
 	"github.com/minio/kes/internal/cache"
 	"github.com/minio/kes/internal/crypto"
 	"github.com/minio/kes/internal/keystore"
+	"github.com/minio/kes/internal/metric"
 	"github.com/minio/kms-go/kes"
 )
 
@@ -61,10 +65,119 @@ type KeyStore interface {
 	List(ctx context.Context, prefix string, n int) ([]string, string, error)
 }
 
+// An OverwriteKeyStore is a KeyStore that can replace the value of
+// existing entries. Overwriting may depend on the KeyStore's config.
+// Hence, the server only calls Set if CanOverwrite reports true.
+// Otherwise, it replaces entries by deleting and re-creating them.
+type OverwriteKeyStore interface {
+	KeyStore
+
+	// CanOverwrite reports whether Set replaces existing entries.
+	CanOverwrite() bool
+
+	// Set creates a new entry with the given name or replaces
+	// the value of an existing one.
+	Set(ctx context.Context, name string, value []byte) error
+}
+
+// setEntry creates or replaces the entry with the given name. It
+// uses the KeyStore's upsert semantics, if supported. Otherwise,
+// it deletes and re-creates the entry.
+func setEntry(ctx context.Context, store KeyStore, name string, value []byte) error {
+	if s, ok := store.(OverwriteKeyStore); ok && s.CanOverwrite() {
+		return s.Set(ctx, name, value)
+	}
+	if err := store.Delete(ctx, name); err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+		return err
+	}
+	return store.Create(ctx, name, value)
+}
+
+// A ConditionalKeyStore is a KeyStore that can atomically replace
+// the value of an entry if it has not been modified concurrently,
+// even by other servers sharing the KeyStore. Conditional writes
+// may depend on the KeyStore's config. Hence, the server only calls
+// Swap if CanSwap reports true.
+type ConditionalKeyStore interface {
+	KeyStore
+
+	// CanSwap reports whether Swap is supported.
+	CanSwap() bool
+
+	// Swap replaces the value of the entry with the given name
+	// if and only if its current value is equal to old. It returns
+	// kes.ErrKeyNotFound if no such entry exists and kes.ErrKeyExists
+	// if the entry exists but its value is not equal to old.
+	Swap(ctx context.Context, name string, old, value []byte) error
+}
+
+// errSwapUnsupported is returned by swapEntry if the KeyStore
+// does not support conditional writes.
+var errSwapUnsupported = errors.New("kes: key store does not support conditional writes")
+
+// canSwap reports whether the KeyStore supports conditional writes.
+func canSwap(store KeyStore) bool {
+	s, ok := store.(ConditionalKeyStore)
+	return ok && s.CanSwap()
+}
+
+// swapEntry replaces the value of the entry with the given name if
+// and only if its current value is equal to old. It returns
+// errSwapUnsupported if the KeyStore does not support conditional
+// writes.
+func swapEntry(ctx context.Context, store KeyStore, name string, old, value []byte) error {
+	if !canSwap(store) {
+		return errSwapUnsupported
+	}
+	return store.(ConditionalKeyStore).Swap(ctx, name, old, value)
+}
+
+// A BulkKeyStore is a KeyStore that can fetch multiple entries
+// with fewer round trips than one Get call per entry.
+type BulkKeyStore interface {
+	KeyStore
+
+	// GetBulk returns the values of the given entries by name.
+	// Entries that do not exist are not included.
+	GetBulk(ctx context.Context, names []string) (map[string][]byte, error)
+}
+
+// getBulk returns the values of the given entries by name. It uses
+// the KeyStore's bulk reads, if supported. Otherwise, it fetches one
+// entry after another. Entries that do not exist are not included.
+func getBulk(ctx context.Context, store KeyStore, names []string) (map[string][]byte, error) {
+	if s, ok := store.(BulkKeyStore); ok {
+		return s.GetBulk(ctx, names)
+	}
+
+	values := make(map[string][]byte, len(names))
+	for _, name := range names {
+		value, err := store.Get(ctx, name)
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
 // KeyStoreState is a structure containing information about
 // the current state of a KeyStore.
 type KeyStoreState struct {
 	Latency time.Duration
+
+	// Unauthenticated indicates that the KeyStore is reachable
+	// but rejected the server's credentials, e.g. because they
+	// are invalid or expired.
+	Unauthenticated bool
+
+	// Unauthorized indicates that the KeyStore accepted the
+	// server's credentials but denied access, e.g. due to a
+	// missing permission.
+	Unauthorized bool
 }
 
 // MemKeyStore is a volatile KeyStore that stores key-value pairs in
@@ -75,7 +188,10 @@ type MemKeyStore struct {
 	keys cache.Cow[string, []byte]
 }
 
-var _ KeyStore = (*MemKeyStore)(nil) // compiler check
+var ( // compiler checks
+	_ OverwriteKeyStore   = (*MemKeyStore)(nil)
+	_ ConditionalKeyStore = (*MemKeyStore)(nil)
+)
 
 func (ks *MemKeyStore) String() string { return "In Memory" }
 
@@ -97,6 +213,36 @@ func (ks *MemKeyStore) Create(_ context.Context, name string, value []byte) erro
 	return nil
 }
 
+// CanOverwrite returns true. The MemKeyStore always
+// supports replacing existing entries.
+func (ks *MemKeyStore) CanOverwrite() bool { return true }
+
+// Set creates a new entry with the given name or replaces
+// the value of an existing one.
+func (ks *MemKeyStore) Set(_ context.Context, name string, value []byte) error {
+	ks.keys.Set(name, slices.Clone(value))
+	return nil
+}
+
+// CanSwap returns true. The MemKeyStore always
+// supports conditional writes.
+func (ks *MemKeyStore) CanSwap() bool { return true }
+
+// Swap replaces the value of the entry with the given name
+// if and only if its current value is equal to old.
+func (ks *MemKeyStore) Swap(_ context.Context, name string, old, value []byte) error {
+	found, swapped := ks.keys.CompareAndSwap(name, slices.Clone(value), func(v []byte) bool {
+		return bytes.Equal(v, old)
+	})
+	if !found {
+		return kes.ErrKeyNotFound
+	}
+	if !swapped {
+		return kes.ErrKeyExists
+	}
+	return nil
+}
+
 // Delete removes the entry. It may return either no error or
 // kes.ErrKeyNotFound if no such entry exists.
 func (ks *MemKeyStore) Delete(_ context.Context, name string) error {
@@ -163,6 +309,13 @@ func (ks *MemKeyStore) List(_ context.Context, prefix string, n int) ([]string,
 // interface.
 func (ks *MemKeyStore) Close() error { return nil }
 
+// Default timeouts of KeyStore operations.
+const (
+	defaultGetTimeout   = 10 * time.Second
+	defaultWriteTimeout = 15 * time.Second
+	defaultListTimeout  = 30 * time.Second
+)
+
 // newCache returns a new keyCache wrapping the KeyStore.
 // It caches keys in memory and evicts cache entries based
 // on the CacheConfig.
@@ -170,11 +323,30 @@ func (ks *MemKeyStore) Close() error { return nil }
 // Close the keyCache to release to the stop background
 // garbage collector evicting cache entries and release
 // associated resources.
-func newCache(store KeyStore, conf *CacheConfig) *keyCache {
+func newCache(store KeyStore, conf *CacheConfig, timeouts *KeyStoreTimeoutConfig, metrics *metric.Metrics) *keyCache {
 	ctx, stop := context.WithCancel(context.Background())
 	c := &keyCache{
-		store: store,
-		stop:  stop,
+		store:        store,
+		getTimeout:   defaultGetTimeout,
+		writeTimeout: defaultWriteTimeout,
+		listTimeout:  defaultListTimeout,
+		metrics:      metrics,
+		stop:         stop,
+	}
+	if timeouts != nil {
+		c.getTimeout = cmp.Or(timeouts.Get, defaultGetTimeout)
+		c.writeTimeout = cmp.Or(timeouts.Write, defaultWriteTimeout)
+		c.listTimeout = cmp.Or(timeouts.List, defaultListTimeout)
+	}
+
+	// Tenant KEKs may be shredded by other servers sharing the
+	// key store. Hence, the cache validates them periodically
+	// and evicts all keys of shredded tenants.
+	if tenants, ok := store.(*tenantStore); ok {
+		tenants.Evict = func(prefix string) {
+			c.cache.DeleteFunc(func(name string, _ *cacheEntry) bool { return strings.HasPrefix(name, prefix) })
+		}
+		go c.gc(ctx, tenantKEKValidity, func() { tenants.Validate(ctx) })
 	}
 
 	expiryOffline := conf.ExpiryOffline
@@ -205,14 +377,6 @@ func newCache(store KeyStore, conf *CacheConfig) *keyCache {
 			c.cache.DeleteAll()
 		}
 	})
-	go c.gc(ctx, 10*time.Second, func() {
-		_, err := c.store.Status(ctx)
-		if err != nil && !errors.Is(err, context.Canceled) {
-			c.offline.Store(true)
-		} else {
-			c.offline.Store(false)
-		}
-	})
 	return c
 }
 
@@ -234,8 +398,16 @@ type keyCache struct {
 	// all others to wait until the first is done.
 	barrier cache.Barrier[string]
 
+	// Timeouts of KeyStore operations. Operations
+	// without timeout, if <= 0.
+	getTimeout   time.Duration
+	writeTimeout time.Duration
+	listTimeout  time.Duration
+	metrics      *metric.Metrics // Counts timed out operations, if not nil
+
 	// Controls whether we treat the cache as offline
-	// cache (with different GC config).
+	// cache (with different GC config). It is updated
+	// by the server's key store health probes.
 	offline atomic.Bool
 	stop    func() // Stops the GC
 }
@@ -244,6 +416,18 @@ type keyCache struct {
 type cacheEntry struct {
 	Key  crypto.KeyVersion
 	Used atomic.Bool
+
+	// Lock is the time lock of the key, if any. It is only
+	// valid if HasLock is true. Entries added via Set, e.g.
+	// by a standby, don't load the time lock eagerly.
+	Lock    *timeLock
+	HasLock bool
+
+	// Folder is the folder of the key, if any. It is only
+	// valid if HasFolder is true. Like time locks, folders
+	// are loaded lazily.
+	Folder    string
+	HasFolder bool
 }
 
 // Status returns the current state of the underlying KeyStore.
@@ -265,7 +449,10 @@ func (c *keyCache) Create(ctx context.Context, name string, key crypto.KeyVersio
 		return err
 	}
 
-	if err = c.store.Create(ctx, name, b); err != nil {
+	err = c.withTimeout(ctx, "create", c.writeTimeout, func(ctx context.Context) error {
+		return c.store.Create(ctx, name, b)
+	})
+	if err != nil {
 		if errors.Is(err, kes.ErrKeyExists) {
 			return kes.ErrKeyExists
 		}
@@ -275,16 +462,39 @@ func (c *keyCache) Create(ctx context.Context, name string, key crypto.KeyVersio
 
 // Delete deletes the key from the key store and removes it from the
 // cache. It may return either no error or kes.ErrKeyNotFound if no
-// such entry exists.
+// such entry exists. It returns an error if the key is under legal
+// hold.
 func (c *keyCache) Delete(ctx context.Context, name string) error {
-	if err := c.store.Delete(ctx, name); err != nil {
-		if errors.Is(err, kes.ErrKeyNotFound) {
+	err := c.withTimeout(ctx, "delete", c.writeTimeout, func(ctx context.Context) error {
+		if err := checkLegalHold(ctx, c.store, name); err != nil {
 			return err
 		}
+		return c.store.Delete(ctx, name)
+	})
+	if err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
 		return err
 	}
 	c.cache.Delete(name)
-	return nil
+
+	// Remove the time lock, folder and owner, if any, such that
+	// they do not apply to a new key with the same name. They are
+	// also removed if the key does not exist anymore, e.g. when a
+	// previous delete failed to remove them.
+	if !isReservedEntry(name) {
+		var errs []error
+		for _, prefix := range []string{timeLockPrefix, folderPrefix, ownerPrefix} {
+			err := c.withTimeout(ctx, "delete", c.writeTimeout, func(ctx context.Context) error {
+				return c.store.Delete(ctx, prefix+name)
+			})
+			if err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("kes: failed to remove metadata of key '%s': %w", name, errors.Join(errs...))
+		}
+	}
+	return err
 }
 
 // Get returns the key from the cache. If it key is not in the cache,
@@ -296,9 +506,87 @@ func (c *keyCache) Delete(ctx context.Context, name string) error {
 // concurrent Get calls for the same key, that is not in the cache, are
 // serialized.
 func (c *keyCache) Get(ctx context.Context, name string) (crypto.KeyVersion, error) {
+	entry, err := c.entry(ctx, name)
+	if err != nil {
+		return crypto.KeyVersion{}, err
+	}
+	return entry.Key, nil
+}
+
+// Use returns the key like Get, but fails if the key is time-locked
+// at the moment. It should be used when performing cryptographic
+// operations with the key.
+func (c *keyCache) Use(ctx context.Context, name string) (crypto.KeyVersion, error) {
+	key, lock, err := c.GetTimeLock(ctx, name)
+	if err != nil {
+		return crypto.KeyVersion{}, err // Fail closed if the time lock cannot be read
+	}
+	if now := time.Now(); !lock.Allows(now) {
+		return crypto.KeyVersion{}, errTimeLocked(name, lock, now)
+	}
+	return key, nil
+}
+
+// GetTimeLock returns the key and its time lock, if any.
+func (c *keyCache) GetTimeLock(ctx context.Context, name string) (crypto.KeyVersion, *timeLock, error) {
+	entry, err := c.entry(ctx, name)
+	if err != nil {
+		return crypto.KeyVersion{}, nil, err
+	}
+	if entry.HasLock {
+		return entry.Key, entry.Lock, nil
+	}
+
+	lock, err := loadTimeLock(ctx, c, name)
+	if err != nil {
+		return crypto.KeyVersion{}, nil, err
+	}
+	e := &cacheEntry{Key: entry.Key, Lock: lock, HasLock: true, Folder: entry.Folder, HasFolder: entry.HasFolder}
+	e.Used.Store(true)
+	c.cache.Set(name, e)
+	return entry.Key, lock, nil
+}
+
+// Folder returns the folder of the key, or the empty string
+// if the key is not in any folder.
+func (c *keyCache) Folder(ctx context.Context, name string) (string, error) {
+	entry, err := c.entry(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if entry.HasFolder {
+		return entry.Folder, nil
+	}
+
+	folder, err := loadKeyFolder(ctx, c, name)
+	if err != nil {
+		return "", err
+	}
+	e := &cacheEntry{Key: entry.Key, Lock: entry.Lock, HasLock: entry.HasLock, Folder: folder, HasFolder: true}
+	e.Used.Store(true)
+	c.cache.Set(name, e)
+	return folder, nil
+}
+
+// setFolder updates the folder of the cached key, if present,
+// without storing it at the underlying KeyStore.
+func (c *keyCache) setFolder(name, folder string) {
+	entry, ok := c.cache.Get(name)
+	if !ok {
+		return
+	}
+	e := &cacheEntry{Key: entry.Key, Lock: entry.Lock, HasLock: entry.HasLock, Folder: folder, HasFolder: true}
+	e.Used.Store(true)
+	c.cache.Set(name, e)
+}
+
+// entry returns the cache entry of the key. If the key is not in
+// the cache, it fetches the key and its time lock from the key
+// store.
+func (c *keyCache) entry(ctx context.Context, name string) (*cacheEntry, error) {
 	if entry, ok := c.cache.Get(name); ok {
 		entry.Used.Store(true)
-		return entry.Key, nil
+		return entry, nil
 	}
 
 	// Since the key is not in the cache, we want to fetch it, once.
@@ -312,28 +600,128 @@ func (c *keyCache) Get(ctx context.Context, name string) (crypto.KeyVersion, err
 	// while we were blocked by the barrier.
 	if entry, ok := c.cache.Get(name); ok {
 		entry.Used.Store(true)
-		return entry.Key, nil
+		return entry, nil
 	}
 
-	b, err := c.store.Get(ctx, name)
+	b, err := c.get(ctx, name)
 	if err != nil {
 		if errors.Is(err, kes.ErrKeyNotFound) {
-			return crypto.KeyVersion{}, kes.ErrKeyNotFound
+			return nil, kes.ErrKeyNotFound
 		}
-		return crypto.KeyVersion{}, err
+		return nil, err
 	}
 
 	k, err := crypto.ParseKeyVersion(b)
 	if err != nil {
-		return crypto.KeyVersion{}, err
+		return nil, err
+	}
+	lock, err := loadTimeLock(ctx, c, name)
+	if err != nil {
+		return nil, err
 	}
 
 	entry := &cacheEntry{
-		Key: k,
+		Key:     k,
+		Lock:    lock,
+		HasLock: true,
 	}
 	entry.Used.Store(true)
 	c.cache.Set(name, entry)
-	return entry.Key, nil
+	return entry, nil
+}
+
+// Warm fetches the given keys, and their time locks, that are not
+// cached yet from the key store and adds them to the cache. It uses
+// bulk reads if the key store supports them. Keys that do not exist
+// are skipped. Warm returns the number of keys added to the cache.
+func (c *keyCache) Warm(ctx context.Context, names []string) (int, error) {
+	names = slices.DeleteFunc(slices.Clone(names), func(name string) bool {
+		_, ok := c.cache.Get(name)
+		return ok || isReservedEntry(name)
+	})
+	if len(names) == 0 {
+		return 0, nil
+	}
+
+	entries := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		entries = append(entries, name, timeLockPrefix+name)
+	}
+	var values map[string][]byte
+	err := c.withTimeout(ctx, "get", c.getTimeout, func(ctx context.Context) (err error) {
+		values, err = getBulk(ctx, c.store, entries)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, name := range names {
+		b, ok := values[name]
+		if !ok {
+			continue
+		}
+		key, err := crypto.ParseKeyVersion(b)
+		if err != nil {
+			return n, fmt.Errorf("kes: invalid key '%s': %v", name, err)
+		}
+		var lock *timeLock
+		if b, ok := values[timeLockPrefix+name]; ok {
+			if lock, err = parseTimeLockEntry(b); err != nil {
+				return n, fmt.Errorf("kes: invalid time lock of key '%s': %v", name, err)
+			}
+		}
+
+		entry := &cacheEntry{
+			Key:     key,
+			Lock:    lock,
+			HasLock: true,
+		}
+		entry.Used.Store(true)
+		if c.cache.Add(name, entry) { // Don't replace entries fetched in the meantime
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Set adds the key to the cache, or replaces an existing
+// cache entry, without storing it at the underlying KeyStore.
+func (c *keyCache) Set(name string, key crypto.KeyVersion) {
+	entry := &cacheEntry{
+		Key: key,
+	}
+	entry.Used.Store(true)
+	c.cache.Set(name, entry)
+}
+
+// Evict removes the key from the cache, if present, without
+// deleting it from the underlying KeyStore.
+func (c *keyCache) Evict(name string) { c.cache.Delete(name) }
+
+// Snapshot returns all keys that are currently cached.
+func (c *keyCache) Snapshot() map[string]crypto.KeyVersion {
+	names := c.cache.Keys()
+	keys := make(map[string]crypto.KeyVersion, len(names))
+	for _, name := range names {
+		if entry, ok := c.cache.Get(name); ok {
+			keys[name] = entry.Key
+		}
+	}
+	return keys
+}
+
+// Verify reads the key with the given name from the underlying
+// KeyStore, bypassing the cache, and checks that it can be parsed.
+// It does not modify the cache.
+func (c *keyCache) Verify(ctx context.Context, name string) error {
+	b, err := c.get(ctx, name)
+	if err != nil {
+		return err
+	}
+	_, err = crypto.ParseKeyVersion(b)
+	return err
 }
 
 // List returns the first n key names, that start with the given prefix,
@@ -345,8 +733,55 @@ func (c *keyCache) Get(ctx context.Context, name string) (crypto.KeyVersion, err
 // An empty prefix matches any key name. At the end of the listing
 // or when there are no (more) keys starting with the prefix, the
 // returned prefix is empty.
+//
+// Lock and lease entries stored at the key store are not included.
 func (c *keyCache) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
-	return c.store.List(ctx, prefix, n)
+	var (
+		names []string
+		next  string
+	)
+	err := c.withTimeout(ctx, "list", c.listTimeout, func(ctx context.Context) (err error) {
+		names, next, err = c.store.List(ctx, prefix, n)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return slices.DeleteFunc(names, isReservedEntry), next, nil
+}
+
+// get fetches the named key from the underlying KeyStore.
+func (c *keyCache) get(ctx context.Context, name string) (b []byte, err error) {
+	err = c.withTimeout(ctx, "get", c.getTimeout, func(ctx context.Context) error {
+		b, err = c.store.Get(ctx, name)
+		return err
+	})
+	return b, err
+}
+
+// errKeyStoreTimeout is the cause of contexts canceled
+// because a KeyStore operation exceeded its timeout.
+var errKeyStoreTimeout = errors.New("kes: keystore operation timed out")
+
+// withTimeout calls f with a context that gets canceled once
+// the timeout expires. If f fails because of the timeout, it
+// returns a *keystore.ErrTimeout for the operation op.
+func (c *keyCache) withTimeout(ctx context.Context, op string, timeout time.Duration, f func(context.Context) error) error {
+	if timeout <= 0 {
+		return f(ctx)
+	}
+
+	tctx, cancel := context.WithTimeoutCause(ctx, timeout, errKeyStoreTimeout)
+	defer cancel()
+
+	err := f(tctx)
+	if err != nil && ctx.Err() == nil && errors.Is(context.Cause(tctx), errKeyStoreTimeout) {
+		if c.metrics != nil {
+			c.metrics.KeyStoreTimeout(op)
+		}
+		return &keystore.ErrTimeout{Op: op, Timeout: timeout}
+	}
+	return err
 }
 
 // Close stops the cache's background garbage collector and
@@ -0,0 +1,366 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kes/internal/keystore"
+	"github.com/minio/kes/internal/metric"
+	"github.com/minio/kms-go/kes"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestKeyStoreTimeout(t *testing.T) {
+	t.Parallel()
+
+	metrics := metric.New()
+	store := &slowKeyStore{}
+	cache := newCache(store, &CacheConfig{}, &KeyStoreTimeoutConfig{
+		Get:   10 * time.Millisecond,
+		Write: -1,
+	}, metrics)
+	defer cache.Close()
+
+	ctx := testContext(t)
+	_, err := cache.Get(ctx, "my-key")
+
+	var timeout *keystore.ErrTimeout
+	if !errors.As(err, &timeout) {
+		t.Fatalf("Expected timeout error: %v", err)
+	}
+	if timeout.Op != "get" || timeout.Status() != http.StatusGatewayTimeout {
+		t.Fatalf("Timeout error mismatch: got op '%s' and status %d", timeout.Op, timeout.Status())
+	}
+
+	// A negative timeout disables the timeout. Hence, the
+	// operation only fails once the request is canceled.
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err = cache.Delete(cctx, "my-key"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context deadline exceeded: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err = metrics.EncodeTo(expfmt.NewEncoder(&buf, expfmt.NewFormat(expfmt.TypeTextPlain))); err != nil {
+		t.Fatalf("Failed to encode metrics: %v", err)
+	}
+	if !strings.Contains(buf.String(), `kes_keystore_timeout{operation="get"} 1`) {
+		t.Fatalf("Timeout metric not incremented:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), `kes_keystore_timeout{operation="delete"}`) {
+		t.Fatalf("Canceled request counted as timeout:\n%s", buf.String())
+	}
+}
+
+func TestSetEntry(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	for i, test := range setEntryTests {
+		store := &upsertKeyStore{overwrite: test.Overwrite}
+		var s KeyStore = store
+		if test.Tenant {
+			s = newTenantStore(store, map[string]*TenantConfig{"acme": {Prefix: "my-"}})
+		}
+
+		for _, value := range []string{"value-0", "value-1"} {
+			if err := setEntry(ctx, s, "my-key", []byte(value)); err != nil {
+				t.Fatalf("Test %d: failed to set entry: %v", i, err)
+			}
+		}
+		if value, err := s.Get(ctx, "my-key"); err != nil || string(value) != "value-1" {
+			t.Fatalf("Test %d: failed to get entry: got '%s' - want '%s': %v", i, value, "value-1", err)
+		}
+		if store.sets != test.Sets {
+			t.Fatalf("Test %d: got %d calls to Set - want %d", i, store.sets, test.Sets)
+		}
+	}
+}
+
+var setEntryTests = []struct {
+	Overwrite bool
+	Tenant    bool
+	Sets      int
+}{
+	{Overwrite: false, Sets: 0},               // 0
+	{Overwrite: true, Sets: 2},                // 1
+	{Overwrite: false, Tenant: true, Sets: 0}, // 2
+	{Overwrite: true, Tenant: true, Sets: 2},  // 3
+}
+
+func TestSwapEntry(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	for i, test := range swapEntryTests {
+		var s KeyStore = &MemKeyStore{}
+		if !test.CanSwap {
+			s = nonBulkKeyStore{s} // Hides the Swap method
+		}
+		if test.Tenant {
+			s = newTenantStore(s, map[string]*TenantConfig{"acme": {Prefix: "my-"}})
+		}
+		if err := s.Create(ctx, "my-key", []byte("value-0")); err != nil {
+			t.Fatalf("Test %d: failed to create entry: %v", i, err)
+		}
+
+		err := swapEntry(ctx, s, "my-key", []byte("value-0"), []byte("value-1"))
+		if !test.CanSwap {
+			if !errors.Is(err, errSwapUnsupported) {
+				t.Fatalf("Test %d: swapped entry: got '%v' - want '%v'", i, err, errSwapUnsupported)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: failed to swap entry: %v", i, err)
+		}
+		if err = swapEntry(ctx, s, "my-key", []byte("value-0"), []byte("value-2")); !errors.Is(err, kes.ErrKeyExists) {
+			t.Fatalf("Test %d: swapped modified entry: got '%v' - want '%v'", i, err, kes.ErrKeyExists)
+		}
+		if err = swapEntry(ctx, s, "my-other-key", []byte("value-0"), []byte("value-2")); !errors.Is(err, kes.ErrKeyNotFound) {
+			t.Fatalf("Test %d: swapped non-existing entry: got '%v' - want '%v'", i, err, kes.ErrKeyNotFound)
+		}
+		if value, err := s.Get(ctx, "my-key"); err != nil || string(value) != "value-1" {
+			t.Fatalf("Test %d: failed to get entry: got '%s' - want '%s': %v", i, value, "value-1", err)
+		}
+	}
+}
+
+var swapEntryTests = []struct {
+	CanSwap bool
+	Tenant  bool
+}{
+	{CanSwap: true},                // 0
+	{CanSwap: false},               // 1
+	{CanSwap: true, Tenant: true},  // 2
+	{CanSwap: false, Tenant: true}, // 3
+}
+
+func TestKeyCacheWarm(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	for i, test := range keyCacheWarmTests {
+		store := &bulkKeyStore{bulk: test.Bulk}
+		cache := newCache(store, &CacheConfig{}, &KeyStoreTimeoutConfig{}, metric.New())
+		defer cache.Close()
+
+		var names []string
+		for j := range test.Keys {
+			name := fmt.Sprintf("key-%d", j)
+			if err := cache.Create(ctx, name, generateTestKey(t)); err != nil {
+				t.Fatalf("Test %d: failed to create key '%s': %v", i, name, err)
+			}
+			names = append(names, name)
+		}
+
+		cache = newCache(store, &CacheConfig{}, &KeyStoreTimeoutConfig{}, metric.New())
+		defer cache.Close()
+		if test.Cached > 0 {
+			if _, err := cache.Get(ctx, names[0]); err != nil {
+				t.Fatalf("Test %d: failed to get key '%s': %v", i, names[0], err)
+			}
+		}
+
+		store.gets, store.bulks = 0, 0
+		n, err := cache.Warm(ctx, append(names, "missing-key"))
+		if err != nil {
+			t.Fatalf("Test %d: failed to warm cache: %v", i, err)
+		}
+		if want := test.Keys - test.Cached; n != want {
+			t.Fatalf("Test %d: got %d warmed keys - want %d", i, n, want)
+		}
+		if store.bulks != test.Bulks {
+			t.Fatalf("Test %d: got %d bulk reads - want %d", i, store.bulks, test.Bulks)
+		}
+
+		gets := store.gets
+		for _, name := range names {
+			if _, err := cache.Get(ctx, name); err != nil {
+				t.Fatalf("Test %d: failed to get key '%s': %v", i, name, err)
+			}
+		}
+		if store.gets != gets {
+			t.Fatalf("Test %d: warmed keys have been fetched again from the key store", i)
+		}
+	}
+}
+
+var keyCacheWarmTests = []struct {
+	Keys   int
+	Cached int
+	Bulk   bool
+	Bulks  int
+}{
+	{Keys: 0, Bulk: true, Bulks: 1},             // 0
+	{Keys: 5, Bulk: true, Bulks: 1},             // 1
+	{Keys: 5, Cached: 1, Bulk: true, Bulks: 1},  // 2
+	{Keys: 5, Bulk: false, Bulks: 0},            // 3
+	{Keys: 5, Cached: 1, Bulk: false, Bulks: 0}, // 4
+}
+
+func TestKeyCacheDelete(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	store := &failingDeleteKeyStore{}
+	cache := newCache(store, &CacheConfig{}, &KeyStoreTimeoutConfig{}, metric.New())
+	defer cache.Close()
+
+	if err := cache.Create(ctx, "my-key", generateTestKey(t)); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	for _, prefix := range []string{timeLockPrefix, folderPrefix, ownerPrefix} {
+		if err := store.Create(ctx, prefix+"my-key", []byte("{}")); err != nil {
+			t.Fatalf("Failed to create metadata '%s': %v", prefix+"my-key", err)
+		}
+	}
+
+	// A failure to remove the metadata is reported.
+	store.fail = ownerPrefix
+	if err := cache.Delete(ctx, "my-key"); err == nil {
+		t.Fatal("Deleted key without removing its owner")
+	}
+	if _, err := store.Get(ctx, "my-key"); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Key has not been deleted: %v", err)
+	}
+
+	// Deleting the key again removes the remaining metadata.
+	store.fail = ""
+	if err := cache.Delete(ctx, "my-key"); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Deleted key twice: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+	for _, prefix := range []string{timeLockPrefix, folderPrefix, ownerPrefix} {
+		if _, err := store.Get(ctx, prefix+"my-key"); !errors.Is(err, kes.ErrKeyNotFound) {
+			t.Fatalf("Metadata '%s' has not been removed: %v", prefix+"my-key", err)
+		}
+	}
+}
+
+func TestGetBulk(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	for _, bulk := range []bool{false, true} {
+		store := &bulkKeyStore{bulk: bulk}
+		var s KeyStore = newTenantStore(store, map[string]*TenantConfig{"acme": {Prefix: "my-"}})
+		for _, name := range []string{"my-key", "other-key"} {
+			if err := s.Create(ctx, name, []byte("value:"+name)); err != nil {
+				t.Fatalf("Failed to create entry '%s': %v", name, err)
+			}
+		}
+
+		values, err := getBulk(ctx, s, []string{"my-key", "other-key", "missing-key"})
+		if err != nil {
+			t.Fatalf("Failed to get entries: %v", err)
+		}
+		if len(values) != 2 {
+			t.Fatalf("Got %d entries - want 2", len(values))
+		}
+		for name, value := range values {
+			if string(value) != "value:"+name {
+				t.Fatalf("Got value '%s' for entry '%s' - want '%s'", value, name, "value:"+name)
+			}
+		}
+		if want := map[bool]int{true: 1}[bulk]; store.bulks != want {
+			t.Fatalf("Got %d bulk reads - want %d", store.bulks, want)
+		}
+	}
+}
+
+// failingDeleteKeyStore is a KeyStore that fails to
+// delete entries starting with a given prefix.
+type failingDeleteKeyStore struct {
+	MemKeyStore
+	fail string
+}
+
+func (s *failingDeleteKeyStore) Delete(ctx context.Context, name string) error {
+	if s.fail != "" && strings.HasPrefix(name, s.fail) {
+		return errors.New("delete failed")
+	}
+	return s.MemKeyStore.Delete(ctx, name)
+}
+
+// bulkKeyStore is a KeyStore that counts the calls
+// to Get and GetBulk. It only supports bulk reads if
+// enabled.
+type bulkKeyStore struct {
+	MemKeyStore
+	bulk  bool
+	gets  int
+	bulks int
+}
+
+func (s *bulkKeyStore) Get(ctx context.Context, name string) ([]byte, error) {
+	s.gets++
+	return s.MemKeyStore.Get(ctx, name)
+}
+
+func (s *bulkKeyStore) GetBulk(ctx context.Context, names []string) (map[string][]byte, error) {
+	if !s.bulk {
+		return getBulk(ctx, nonBulkKeyStore{s}, names)
+	}
+	s.bulks++
+	return getBulk(ctx, &s.MemKeyStore, names)
+}
+
+// nonBulkKeyStore hides all optional methods, like GetBulk
+// or Swap, of a KeyStore.
+type nonBulkKeyStore struct{ KeyStore }
+
+// generateTestKey returns a new random key.
+func generateTestKey(t *testing.T) crypto.KeyVersion {
+	key, err := crypto.GenerateSecretKey(crypto.AES256, rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	hmac, err := crypto.GenerateHMACKey(crypto.SHA256, rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate HMAC key: %v", err)
+	}
+	return crypto.KeyVersion{Key: key, HMACKey: hmac, CreatedAt: time.Now().UTC()}
+}
+
+// upsertKeyStore is a KeyStore that counts the calls to Set
+// and only supports overwriting entries if enabled.
+type upsertKeyStore struct {
+	MemKeyStore
+	overwrite bool
+	sets      int
+}
+
+func (s *upsertKeyStore) CanOverwrite() bool { return s.overwrite }
+
+func (s *upsertKeyStore) Set(ctx context.Context, name string, value []byte) error {
+	s.sets++
+	return s.MemKeyStore.Set(ctx, name, value)
+}
+
+// slowKeyStore is a KeyStore whose operations block
+// until the context is canceled.
+type slowKeyStore struct {
+	MemKeyStore
+}
+
+func (s *slowKeyStore) Delete(ctx context.Context, _ string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *slowKeyStore) Get(ctx context.Context, _ string) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
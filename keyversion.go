@@ -0,0 +1,64 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+// keyVersion returns the version of the key as RFC 3339 timestamp.
+//
+// KES keys are immutable. Hence, a key has exactly one version, the
+// time it has been created. A key that is deleted and created again
+// with the same name has a new version. Keys without a creation time
+// have no version.
+func keyVersion(key crypto.KeyVersion) string {
+	if key.CreatedAt.IsZero() {
+		return ""
+	}
+	return key.CreatedAt.UTC().Format(time.RFC3339Nano)
+}
+
+// checkKeyVersion returns an error if version is not empty and not
+// the version of the key. Clients pin a key version to ensure that
+// data is processed with exactly this key, for example when replaying
+// historical data, instead of a key that has been created again with
+// the same name.
+//
+// Clients must be allowed to access the PathKeyVersion API path of
+// the key in addition to the API itself.
+func checkKeyVersion(state *serverState, req *api.Request, key crypto.KeyVersion, version string) api.Error {
+	if version == "" {
+		return nil
+	}
+	if req.Identity != state.Admin {
+		r, err := http.NewRequestWithContext(req.Context(), http.MethodPut, api.PathKeyVersion+req.Resource, http.NoBody)
+		if err != nil {
+			return api.NewError(http.StatusBadRequest, fmt.Sprintf("key name '%s' is invalid", req.Resource))
+		}
+		policy, ok := state.Identities[req.Identity]
+		if !ok {
+			return kes.ErrNotAllowed
+		}
+		if err := policy.Verify(r); err != nil && !state.Folders.AllowsRequest(r, state.Keys, policy.Name, policy.Policy) {
+			return kes.ErrNotAllowed
+		}
+	}
+
+	pinned, err := time.Parse(time.RFC3339Nano, version)
+	if err != nil {
+		return api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid key version '%s'", version))
+	}
+	if !pinned.Equal(key.CreatedAt) || key.CreatedAt.IsZero() {
+		return api.NewError(http.StatusConflict, fmt.Sprintf("key '%s' does not have version '%s'", req.Resource, version))
+	}
+	return nil
+}
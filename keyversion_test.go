@@ -0,0 +1,121 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestKeyVersion(t *testing.T) {
+	t.Parallel()
+
+	appCert, _ := newRenewalCertificate(t, time.Hour)
+	appIdentity := renewalIdentity(appCert.Leaf)
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		Keys: &MemKeyStore{},
+		Policies: map[string]Policy{
+			"my-app": {
+				Allow: map[string]kes.Rule{
+					api.PathKeyEncrypt + "app-*":        {},
+					api.PathKeyDecrypt + "app-*":        {},
+					api.PathKeyGenerate + "app-*":       {},
+					api.PathKeyHMAC + "app-*":           {},
+					api.PathKeyVersion + "app-pipeline": {},
+				},
+				Identities: []kes.Identity{kes.Identity(appIdentity)},
+			},
+		},
+	})
+	defer srv.Close()
+
+	admin, app := defaultClient(url), renewalClient(url, appCert)
+	for _, name := range []string{"app-pipeline", "app-key"} {
+		if err := admin.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+
+	send := func(client *kes.Client, path string, body, result any) int {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Failed to encode request: %v", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, url+path, bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK && result != nil {
+			if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	var enc api.EncryptKeyResponse
+	if code := send(app, api.PathKeyEncrypt+"app-pipeline", api.EncryptKeyRequest{Plaintext: []byte("Hello World")}, &enc); code != http.StatusOK {
+		t.Fatalf("Failed to encrypt: status code '%d'", code)
+	}
+	info, err := admin.DescribeKey(ctx, "app-pipeline")
+	if err != nil {
+		t.Fatalf("Failed to describe key: %v", err)
+	}
+	if version := info.CreatedAt.UTC().Format(time.RFC3339Nano); enc.Version != version {
+		t.Fatalf("Key version mismatch: got '%s' - want '%s'", enc.Version, version)
+	}
+
+	var dec api.DecryptKeyResponse
+	if code := send(app, api.PathKeyDecrypt+"app-pipeline", api.DecryptKeyRequest{Ciphertext: enc.Ciphertext, Version: enc.Version}, &dec); code != http.StatusOK {
+		t.Fatalf("Failed to decrypt with pinned version: status code '%d'", code)
+	}
+	if !bytes.Equal(dec.Plaintext, []byte("Hello World")) {
+		t.Fatalf("Plaintext mismatch: got '%s' - want '%s'", dec.Plaintext, "Hello World")
+	}
+
+	for i, test := range []struct {
+		Client *kes.Client
+		Path   string
+		Body   any
+		Code   int
+	}{
+		{Client: app, Path: api.PathKeyGenerate + "app-pipeline", Body: api.GenerateKeyRequest{Version: enc.Version}, Code: http.StatusOK},                                            // 0
+		{Client: app, Path: api.PathKeyHMAC + "app-pipeline", Body: api.HMACRequest{Message: []byte("msg"), Version: enc.Version}, Code: http.StatusOK},                               // 1
+		{Client: app, Path: api.PathKeyDecrypt + "app-pipeline", Body: api.DecryptKeyRequest{Ciphertext: enc.Ciphertext, Version: "2006-01-02T15:04:05Z"}, Code: http.StatusConflict}, // 2: other version
+		{Client: app, Path: api.PathKeyDecrypt + "app-pipeline", Body: api.DecryptKeyRequest{Ciphertext: enc.Ciphertext, Version: "v1"}, Code: http.StatusBadRequest},                 // 3: invalid version
+		{Client: app, Path: api.PathKeyEncrypt + "app-key", Body: api.EncryptKeyRequest{Plaintext: []byte("msg"), Version: enc.Version}, Code: http.StatusForbidden},                  // 4: not allowed to pin version
+		{Client: app, Path: api.PathKeyEncrypt + "app-key", Body: api.EncryptKeyRequest{Plaintext: []byte("msg")}, Code: http.StatusOK},                                               // 5: no version
+		{Client: admin, Path: api.PathKeyEncrypt + "app-key", Body: api.EncryptKeyRequest{Plaintext: []byte("msg"), Version: enc.Version}, Code: http.StatusConflict},                 // 6: version of other key
+	} {
+		if code := send(test.Client, test.Path, test.Body, nil); code != test.Code {
+			t.Fatalf("Test %d: status code mismatch: got '%d' - want '%d'", i, code, test.Code)
+		}
+	}
+
+	// A key created again with the same name has a new version.
+	if err = admin.DeleteKey(ctx, "app-pipeline"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if err = admin.CreateKey(ctx, "app-pipeline"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if code := send(app, api.PathKeyDecrypt+"app-pipeline", api.DecryptKeyRequest{Ciphertext: enc.Ciphertext, Version: enc.Version}, nil); code != http.StatusConflict {
+		t.Fatalf("Decrypted with version of deleted key: status code '%d'", code)
+	}
+}
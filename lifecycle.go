@@ -0,0 +1,340 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+// Key lifecycle states.
+const (
+	keyStateEnabled     = "enabled"
+	keyStateDecryptOnly = "decrypt-only"
+	keyStateDisabled    = "disabled"
+	keyStatePurged      = "purged"
+)
+
+const (
+	// defaultLifecycleNotice is the default time before a
+	// transition at which a notice is emitted.
+	defaultLifecycleNotice = 7 * 24 * time.Hour
+
+	// keyLifecycleInterval is the interval at which the server
+	// emits notices and purges keys.
+	keyLifecycleInterval = 5 * time.Minute
+)
+
+// decryptOnlyOps are the key operations rejected once
+// a key is decrypt-only.
+var decryptOnlyOps = []string{"generate", "encrypt", "hmac", "seal", "tokenize", "rewrap"}
+
+// disabledOps are the key operations rejected once a key
+// is disabled.
+var disabledOps = []string{"generate", "encrypt", "decrypt", "hmac", "seal", "open", "tokenize", "detokenize", "reveal", "rewrap"}
+
+// keyLifecycle computes the states of keys from their schedules
+// and emits notices before and once keys transition.
+type keyLifecycle struct {
+	notice   time.Duration
+	sink     string
+	exact    map[string]KeySchedule
+	prefixes []prefixSchedule // Sorted by prefix length, longest first
+
+	notices *lifecycleNotices
+}
+
+// prefixSchedule is the schedule of all keys with a prefix.
+type prefixSchedule struct {
+	prefix   string
+	schedule KeySchedule
+}
+
+// lifecycleNotices tracks the notices already emitted such
+// that each notice is emitted only once.
+type lifecycleNotices struct {
+	lock sync.Mutex
+	sent map[string]struct{}
+}
+
+// keyTransition is a state transition of a key schedule.
+type keyTransition struct {
+	State string
+	At    time.Time
+}
+
+// verifyKeyLifecycle reports whether conf is a valid key
+// lifecycle configuration.
+func verifyKeyLifecycle(conf *KeyLifecycleConfig) error {
+	for pattern, schedule := range conf.Schedules {
+		if pattern == "" || !validPattern(pattern) {
+			return fmt.Errorf("kes: key lifecycle pattern '%s' is empty, too long or is invalid", pattern)
+		}
+		if schedule == nil {
+			return fmt.Errorf("kes: key lifecycle schedule of '%s' is empty", pattern)
+		}
+		transitions := schedule.transitions()
+		if len(transitions) == 0 {
+			return fmt.Errorf("kes: key lifecycle schedule of '%s' contains no dates", pattern)
+		}
+		for i := 1; i < len(transitions); i++ {
+			if !transitions[i-1].At.Before(transitions[i].At) {
+				return fmt.Errorf("kes: key lifecycle schedule of '%s': %s date is not before %s date", pattern, transitions[i-1].State, transitions[i].State)
+			}
+		}
+	}
+	if conf.Sink != "" {
+		if u, err := url.Parse(conf.Sink); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("kes: key lifecycle sink '%s' is not a HTTP(S) URL", conf.Sink)
+		}
+	}
+	return nil
+}
+
+// newKeyLifecycle returns a new keyLifecycle for the given
+// configuration, or nil if conf is nil. Notices already
+// emitted by old, if not nil, are not emitted again.
+func newKeyLifecycle(conf *KeyLifecycleConfig, old *keyLifecycle) *keyLifecycle {
+	if conf == nil {
+		return nil
+	}
+	l := &keyLifecycle{
+		notice: conf.Notice,
+		sink:   conf.Sink,
+		exact:  map[string]KeySchedule{},
+	}
+	if l.notice <= 0 {
+		l.notice = defaultLifecycleNotice
+	}
+	if old != nil {
+		l.notices = old.notices
+	} else {
+		l.notices = &lifecycleNotices{sent: map[string]struct{}{}}
+	}
+
+	for pattern, schedule := range conf.Schedules {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			l.prefixes = append(l.prefixes, prefixSchedule{prefix: prefix, schedule: *schedule})
+		} else {
+			l.exact[pattern] = *schedule
+		}
+	}
+	slices.SortFunc(l.prefixes, func(a, b prefixSchedule) int { return len(b.prefix) - len(a.prefix) })
+	return l
+}
+
+// Schedule returns the schedule of the named key, if any.
+func (l *keyLifecycle) Schedule(name string) (KeySchedule, bool) {
+	if l == nil {
+		return KeySchedule{}, false
+	}
+	if s, ok := l.exact[name]; ok {
+		return s, true
+	}
+	for _, p := range l.prefixes {
+		if strings.HasPrefix(name, p.prefix) {
+			return p.schedule, true
+		}
+	}
+	return KeySchedule{}, false
+}
+
+// State returns the state of the named key at the given time.
+// Keys past their purge date that have not been deleted yet,
+// for example due to a legal hold, are disabled. It returns
+// false if no schedule applies to the key.
+func (l *keyLifecycle) State(name string, now time.Time) (string, bool) {
+	s, ok := l.Schedule(name)
+	if !ok {
+		return "", false
+	}
+	if state := s.stateAt(now); state != keyStatePurged {
+		return state, true
+	}
+	return keyStateDisabled, true
+}
+
+// Check returns an error if the request performs a key operation
+// that the current state of the key does not permit.
+func (l *keyLifecycle) Check(req *http.Request, now time.Time) api.Error {
+	if l == nil {
+		return nil
+	}
+	op, name := keyOperation(req)
+	if name == "" {
+		return nil
+	}
+	switch state, _ := l.State(name, now); state {
+	case keyStateDecryptOnly:
+		if slices.Contains(decryptOnlyOps, op) {
+			return api.NewError(http.StatusForbidden, fmt.Sprintf("key '%s' is decrypt-only", name))
+		}
+	case keyStateDisabled:
+		if slices.Contains(disabledOps, op) {
+			return api.NewError(http.StatusForbidden, fmt.Sprintf("key '%s' is disabled", name))
+		}
+	}
+	return nil
+}
+
+// Due returns the notices for the named key due at the given
+// time that have not been emitted yet. A notice is due once
+// now is within the notice period before a transition. Once
+// the transition happened, another notice is due. Notices
+// for transitions older than the notice period are skipped.
+func (l *keyLifecycle) Due(name string, now time.Time) []api.KeyTransitionEvent {
+	s, ok := l.Schedule(name)
+	if !ok {
+		return nil
+	}
+
+	l.notices.lock.Lock()
+	defer l.notices.lock.Unlock()
+
+	var events []api.KeyTransitionEvent
+	for _, t := range s.transitions() {
+		if now.Before(t.At.Add(-l.notice)) || !now.Before(t.At.Add(l.notice)) {
+			continue
+		}
+		event := api.KeyTransitionEvent{
+			Key:      name,
+			State:    t.State,
+			At:       t.At,
+			Upcoming: now.Before(t.At),
+		}
+		if _, ok := l.notices.sent[noticeID(event)]; !ok {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Sent marks the notice as emitted.
+func (l *keyLifecycle) Sent(event api.KeyTransitionEvent) {
+	l.notices.lock.Lock()
+	defer l.notices.lock.Unlock()
+
+	l.notices.sent[noticeID(event)] = struct{}{}
+}
+
+// noticeID returns the ID of the notice used to
+// emit each notice only once.
+func noticeID(event api.KeyTransitionEvent) string {
+	return fmt.Sprintf("%s/%s/%d/%t", event.Key, event.State, event.At.Unix(), event.Upcoming)
+}
+
+// transitions returns the transitions of the schedule in order.
+func (s *KeySchedule) transitions() []keyTransition {
+	var transitions []keyTransition
+	if !s.DecryptOnly.IsZero() {
+		transitions = append(transitions, keyTransition{State: keyStateDecryptOnly, At: s.DecryptOnly})
+	}
+	if !s.Disable.IsZero() {
+		transitions = append(transitions, keyTransition{State: keyStateDisabled, At: s.Disable})
+	}
+	if !s.Purge.IsZero() {
+		transitions = append(transitions, keyTransition{State: keyStatePurged, At: s.Purge})
+	}
+	return transitions
+}
+
+// stateAt returns the state of keys with this schedule at the
+// given time.
+func (s *KeySchedule) stateAt(now time.Time) string {
+	state := keyStateEnabled
+	for _, t := range s.transitions() {
+		if now.Before(t.At) {
+			break
+		}
+		state = t.State
+	}
+	return state
+}
+
+// startKeyLifecycle emits key lifecycle notices and purges keys
+// in the background until ctx is done.
+func (s *Server) startKeyLifecycle(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(keyLifecycleInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.runKeyLifecycle(ctx, now)
+			}
+		}
+	}()
+}
+
+// runKeyLifecycle emits all notices due at the given time and
+// purges all keys past their purge date. Keys under a legal hold
+// are not purged. Notices that cannot be sent to the sink are
+// retried on the next run.
+func (s *Server) runKeyLifecycle(ctx context.Context, now time.Time) {
+	state := s.state.Load()
+	if state.Lifecycle == nil || state.Standby.IsActive() {
+		return
+	}
+	if readOnly, _ := s.IsReadOnly(); readOnly {
+		return
+	}
+
+	names, _, err := state.Keys.List(ctx, "", -1)
+	if err != nil {
+		state.Log.WarnContext(ctx, fmt.Sprintf("key lifecycle: failed to list keys: %v", err))
+		return
+	}
+	for _, name := range names {
+		schedule, ok := state.Lifecycle.Schedule(name)
+		if !ok {
+			continue
+		}
+
+		purged := false
+		if schedule.stateAt(now) == keyStatePurged {
+			switch err = state.Keys.Delete(ctx, name); {
+			case err == nil:
+				state.Changes.Record(api.ChangeObjectKey, api.ChangeDelete, name, "")
+				purged = true
+			case errors.Is(err, kes.ErrKeyNotFound):
+				purged = true
+			default:
+				state.Log.WarnContext(ctx, fmt.Sprintf("key lifecycle: failed to purge key '%s': %v", name, err))
+			}
+		}
+		for _, event := range state.Lifecycle.Due(name, now) {
+			if event.State == keyStatePurged && !event.Upcoming && !purged {
+				continue
+			}
+			if event.Upcoming {
+				state.Log.WarnContext(ctx, fmt.Sprintf("key lifecycle: key '%s' becomes %s at %s", name, event.State, event.At.Format(time.RFC3339)))
+			} else {
+				state.Log.WarnContext(ctx, fmt.Sprintf("key lifecycle: key '%s' became %s at %s", name, event.State, event.At.Format(time.RFC3339)))
+			}
+			if state.Lifecycle.sink != "" {
+				if err := sendSinkEvent(ctx, state.Lifecycle.sink, event); err != nil {
+					if ctx.Err() == nil {
+						state.Log.WarnContext(ctx, fmt.Sprintf("failed to send key lifecycle notice to '%s': %v", state.Lifecycle.sink, err))
+					}
+					continue
+				}
+			}
+			state.Lifecycle.Sent(event)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestKeyLifecycle(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan api.KeyTransitionEvent, 16)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event api.KeyTransitionEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		events <- event
+	}))
+	defer sink.Close()
+
+	now := time.Now()
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		KeyLifecycle: &KeyLifecycleConfig{
+			Notice: 24 * time.Hour,
+			Sink:   sink.URL,
+			Schedules: map[string]*KeySchedule{
+				"old-key":     {DecryptOnly: now.Add(-time.Hour), Disable: now.Add(time.Hour)},
+				"retired-key": {Disable: now.Add(-time.Hour), Purge: now.Add(30 * 24 * time.Hour)},
+				"app-*":       {DecryptOnly: now.Add(12 * time.Hour)},
+			},
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	for _, name := range []string{"old-key", "retired-key", "app-key"} {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+
+	if _, err := client.Encrypt(ctx, "app-key", []byte("Hello World"), nil); err != nil {
+		t.Fatalf("Failed to encrypt with enabled key: %v", err)
+	}
+	if _, err := client.Encrypt(ctx, "old-key", []byte("Hello World"), nil); !isForbidden(err) {
+		t.Fatalf("Encrypted with decrypt-only key: got '%v' - want status '%d'", err, http.StatusForbidden)
+	}
+	if _, err := client.Decrypt(ctx, "old-key", []byte("invalid ciphertext"), nil); err == nil || isForbidden(err) {
+		t.Fatalf("Decrypt with decrypt-only key has been rejected: %v", err)
+	}
+	if _, err := client.Decrypt(ctx, "retired-key", []byte("invalid ciphertext"), nil); !isForbidden(err) {
+		t.Fatalf("Decrypted with disabled key: got '%v' - want status '%d'", err, http.StatusForbidden)
+	}
+	info, err := client.DescribeKey(ctx, "retired-key")
+	if err != nil {
+		t.Fatalf("Failed to describe disabled key: %v", err)
+	}
+	if info.Name != "retired-key" {
+		t.Fatalf("Invalid key name: got '%s' - want '%s'", info.Name, "retired-key")
+	}
+
+	// Each notice is sent only once.
+	srv.runKeyLifecycle(ctx, now)
+	srv.runKeyLifecycle(ctx, now)
+	want := []string{"app-key/decrypt-only/upcoming", "old-key/decrypt-only", "old-key/disabled/upcoming", "retired-key/disabled"}
+	if got := receiveNotices(events); !slices.Equal(got, want) {
+		t.Fatalf("Notices mismatch: got '%v' - want '%v'", got, want)
+	}
+
+	srv.runKeyLifecycle(ctx, now.Add(30*24*time.Hour+time.Minute))
+	if got, want := receiveNotices(events), []string{"retired-key/purged"}; !slices.Equal(got, want) {
+		t.Fatalf("Notices mismatch: got '%v' - want '%v'", got, want)
+	}
+	if _, err = client.DescribeKey(ctx, "retired-key"); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Purged key still exists: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+}
+
+func TestKeyScheduleState(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	l := newKeyLifecycle(&KeyLifecycleConfig{
+		Schedules: map[string]*KeySchedule{
+			"my-key": {DecryptOnly: now.Add(-time.Hour)},
+			"my-*":   {Disable: now.Add(-time.Hour)},
+			"my-a*":  {DecryptOnly: now.Add(-time.Hour), Disable: now.Add(time.Hour)},
+			"old-*":  {Purge: now.Add(-time.Hour)},
+		},
+	}, nil)
+
+	for i, test := range keyScheduleStateTests {
+		state, ok := l.State(test.Name, now)
+		if ok != (test.State != "") || state != test.State {
+			t.Fatalf("Test %d: state mismatch: got '%s' - want '%s'", i, state, test.State)
+		}
+	}
+}
+
+func TestVerifyKeyLifecycle(t *testing.T) {
+	for i, test := range verifyKeyLifecycleTests {
+		err := verifyKeyLifecycle(&test.Config)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: verified invalid key lifecycle config", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to verify key lifecycle config: %v", i, err)
+		}
+	}
+}
+
+var keyScheduleStateTests = []struct {
+	Name  string
+	State string
+}{
+	{Name: "my-key", State: keyStateDecryptOnly},         // 0
+	{Name: "my-key2", State: keyStateDisabled},           // 1
+	{Name: "my-app", State: keyStateDecryptOnly},         // 2
+	{Name: "old-key", State: keyStateDisabled},           // 3
+	{Name: "other-key", State: ""},                       // 4
+	{Name: "my-", State: keyStateDisabled},               // 5
+	{Name: "my-a", State: keyStateDecryptOnly},           // 6
+	{Name: "mykey", State: ""},                           // 7
+	{Name: "old-", State: keyStateDisabled},              // 8
+	{Name: "old", State: ""},                             // 9
+	{Name: "my-application", State: keyStateDecryptOnly}, // 10
+}
+
+var verifyKeyLifecycleTests = []struct {
+	Config     KeyLifecycleConfig
+	ShouldFail bool
+}{
+	{Config: KeyLifecycleConfig{Schedules: map[string]*KeySchedule{"my-key": {Disable: time.Now()}}}},                          // 0
+	{Config: KeyLifecycleConfig{Schedules: map[string]*KeySchedule{"my-*": {Purge: time.Now()}}, Sink: "https://example.com"}}, // 1
+	{Config: KeyLifecycleConfig{Schedules: map[string]*KeySchedule{"my-key": {}}}, ShouldFail: true},                           // 2
+	{Config: KeyLifecycleConfig{Schedules: map[string]*KeySchedule{"my-key": nil}}, ShouldFail: true},                          // 3
+	{Config: KeyLifecycleConfig{Schedules: map[string]*KeySchedule{"": {Purge: time.Now()}}}, ShouldFail: true},                // 4
+	{Config: KeyLifecycleConfig{Schedules: map[string]*KeySchedule{"my*key": {Purge: time.Now()}}}, ShouldFail: true},          // 5
+	{ // 6
+		Config:     KeyLifecycleConfig{Schedules: map[string]*KeySchedule{"my-key": {Disable: time.Now(), Purge: time.Now().Add(-time.Hour)}}},
+		ShouldFail: true,
+	},
+	{Config: KeyLifecycleConfig{Sink: "ftp://example.com"}, ShouldFail: true}, // 7
+}
+
+// receiveNotices returns all notices received by the sink
+// formatted as "<key>/<state>[/upcoming]" in sorted order.
+func receiveNotices(events <-chan api.KeyTransitionEvent) []string {
+	var notices []string
+	for {
+		select {
+		case event := <-events:
+			notice := event.Key + "/" + event.State
+			if event.Upcoming {
+				notice += "/upcoming"
+			}
+			notices = append(notices, notice)
+		default:
+			slices.Sort(notices)
+			return notices
+		}
+	}
+}
+
+// isForbidden reports whether err is a KES error with
+// status code 403.
+func isForbidden(err error) bool {
+	var e kes.Error
+	return errors.As(err, &e) && e.Status() == http.StatusForbidden
+}
@@ -0,0 +1,355 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+// Limits for lock leases.
+const (
+	defaultLockTTL = 30 * time.Second
+	minLockTTL     = 1 * time.Second
+	maxLockTTL     = 1 * time.Hour
+)
+
+// lockPrefix is the prefix of all lock entries at the key store.
+// It cannot be part of a valid key name since key names must not
+// start with a '-'. Hence, locks never collide with keys.
+const lockPrefix = "-lock-"
+
+// isReservedEntry reports whether the key store entry with
+// the given name is used internally, like a lock, and is not
+// a key.
+func isReservedEntry(name string) bool { return strings.HasPrefix(name, "-") }
+
+// A lock is a lease on a name held by an identity until it expires.
+// It is stored as JSON at the key store.
+type lock struct {
+	Holder    kes.Identity `json:"holder"`
+	Token     string       `json:"token"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
+
+// IsExpired reports whether the lock has expired at time now.
+func (l *lock) IsExpired(now time.Time) bool { return !now.Before(l.ExpiresAt) }
+
+// Owns reports whether the token matches the lock's token.
+func (l *lock) Owns(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(l.Token), []byte(token)) == 1
+}
+
+func (l *lock) response(name string, withToken bool) api.LockResponse {
+	resp := api.LockResponse{
+		Name:      name,
+		Holder:    l.Holder.String(),
+		ExpiresAt: l.ExpiresAt,
+	}
+	if withToken {
+		resp.Token = l.Token
+	}
+	return resp
+}
+
+// readLock reads the lock with the given name from the key store.
+// It returns the lock and its encoded value at the key store.
+func readLock(ctx context.Context, store KeyStore, name string) (*lock, []byte, error) {
+	b, err := store.Get(ctx, lockPrefix+name)
+	if err != nil {
+		return nil, nil, err
+	}
+	var l lock
+	if err = json.Unmarshal(b, &l); err != nil {
+		return nil, nil, fmt.Errorf("invalid lock '%s': %v", name, err)
+	}
+	return &l, b, nil
+}
+
+// writeLock creates the lock with the given name at the key store.
+// It returns kes.ErrKeyExists if such a lock exists already.
+func writeLock(ctx context.Context, store KeyStore, name string, l *lock) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return store.Create(ctx, lockPrefix+name, b)
+}
+
+// swapLock replaces the lock with the given name at the key store
+// if and only if its encoded value is still equal to old. It returns
+// kes.ErrKeyExists or kes.ErrKeyNotFound if the lock has been modified
+// or removed in the meantime.
+func swapLock(ctx context.Context, store KeyStore, name string, old []byte, l *lock) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return swapEntry(ctx, store, lockPrefix+name, old, b)
+}
+
+// parseLockTTL parses the lease duration of a lock request.
+func parseLockTTL(s string) (time.Duration, api.Error) {
+	if s == "" {
+		return defaultLockTTL, nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid lock TTL '%s'", s))
+	}
+	if ttl < minLockTTL || ttl > maxLockTTL {
+		return 0, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid lock TTL '%v': must be between %v and %v", ttl, minLockTTL, maxLockTTL))
+	}
+	return ttl, nil
+}
+
+// acquireLock acquires the named lock for the requesting identity.
+//
+// Taking over an expired lock replaces it with a conditional write.
+// Hence, at most one of multiple servers sharing the same key store
+// can take over a lock. Locks are not supported if the key store
+// cannot perform conditional writes.
+func (s *Server) acquireLock(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "invalid lock name '%s'", req.Resource)
+		return
+	}
+	if !canSwap(s.state.Load().Keys.store) {
+		resp.Fail(http.StatusNotImplemented, "key store does not support distributed locks")
+		return
+	}
+
+	var body api.AcquireLockRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid lock request body")
+		return
+	}
+	ttl, apiErr := parseLockTTL(body.TTL)
+	if apiErr != nil {
+		resp.Failr(apiErr)
+		return
+	}
+
+	var token [16]byte
+	if _, err := rand.Read(token[:]); err != nil {
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to acquire lock")
+		return
+	}
+
+	s.locks.Lock(req.Resource)
+	defer s.locks.Unlock(req.Resource)
+
+	state := s.state.Load()
+	store := state.Keys.store
+
+	now := time.Now().UTC()
+	l := &lock{
+		Holder:    req.Identity,
+		Token:     hex.EncodeToString(token[:]),
+		ExpiresAt: now.Add(ttl),
+	}
+	err := writeLock(req.Context(), store, req.Resource, l)
+	if errors.Is(err, kes.ErrKeyExists) {
+		var (
+			held *lock
+			raw  []byte
+		)
+		if held, raw, err = readLock(req.Context(), store, req.Resource); err == nil {
+			if !held.IsExpired(now) {
+				resp.Failf(http.StatusConflict, "lock '%s' is held by '%s'", req.Resource, held.Holder)
+				return
+			}
+			err = swapLock(req.Context(), store, req.Resource, raw, l)
+		}
+		if errors.Is(err, kes.ErrKeyExists) || errors.Is(err, kes.ErrKeyNotFound) {
+			resp.Failf(http.StatusConflict, "lock '%s' is held by another identity", req.Resource)
+			return
+		}
+	}
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Failf(http.StatusBadGateway, "failed to acquire lock '%s'", req.Resource)
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(
+		fmt.Sprintf("lock '%s' acquired until %s", req.Resource, l.ExpiresAt.Format(time.RFC3339)),
+		StatusOK,
+		req,
+	)
+	api.ReplyWith(resp, StatusOK, l.response(req.Resource, true))
+}
+
+// renewLock extends the lease of a lock held by the requesting identity.
+func (s *Server) renewLock(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "invalid lock name '%s'", req.Resource)
+		return
+	}
+
+	var body api.RenewLockRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid lock request body")
+		return
+	}
+	ttl, apiErr := parseLockTTL(body.TTL)
+	if apiErr != nil {
+		resp.Failr(apiErr)
+		return
+	}
+
+	s.locks.Lock(req.Resource)
+	defer s.locks.Unlock(req.Resource)
+
+	state := s.state.Load()
+	store := state.Keys.store
+	if !canSwap(store) {
+		resp.Fail(http.StatusNotImplemented, "key store does not support distributed locks")
+		return
+	}
+
+	l, raw, ok := s.heldLock(resp, req, body.Token)
+	if !ok {
+		return
+	}
+	l.ExpiresAt = time.Now().UTC().Add(ttl)
+
+	if err := swapLock(req.Context(), store, req.Resource, raw, l); err != nil {
+		if errors.Is(err, kes.ErrKeyExists) || errors.Is(err, kes.ErrKeyNotFound) {
+			resp.Failf(http.StatusConflict, "lock '%s' is held by another identity", req.Resource)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Failf(http.StatusBadGateway, "failed to renew lock '%s'", req.Resource)
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(
+		fmt.Sprintf("lock '%s' renewed until %s", req.Resource, l.ExpiresAt.Format(time.RFC3339)),
+		StatusOK,
+		req,
+	)
+	api.ReplyWith(resp, StatusOK, l.response(req.Resource, true))
+}
+
+// releaseLock releases a lock held by the requesting identity.
+func (s *Server) releaseLock(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "invalid lock name '%s'", req.Resource)
+		return
+	}
+
+	var body api.ReleaseLockRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid lock request body")
+		return
+	}
+
+	s.locks.Lock(req.Resource)
+	defer s.locks.Unlock(req.Resource)
+
+	state := s.state.Load()
+	if _, _, ok := s.heldLock(resp, req, body.Token); !ok {
+		return
+	}
+	if err := state.Keys.store.Delete(req.Context(), lockPrefix+req.Resource); err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Failf(http.StatusBadGateway, "failed to release lock '%s'", req.Resource)
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(
+		fmt.Sprintf("lock '%s' released", req.Resource),
+		StatusOK,
+		req,
+	)
+	resp.Reply(StatusOK)
+}
+
+// describeLock returns information about a lock
+// without revealing its token.
+func (s *Server) describeLock(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "invalid lock name '%s'", req.Resource)
+		return
+	}
+
+	state := s.state.Load()
+	l, _, err := readLock(req.Context(), state.Keys.store, req.Resource)
+	if err == nil && l.IsExpired(time.Now()) {
+		err = kes.ErrKeyNotFound
+	}
+	if err != nil {
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			resp.Failf(http.StatusNotFound, "lock '%s' is not held", req.Resource)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Failf(http.StatusBadGateway, "failed to read lock '%s'", req.Resource)
+		return
+	}
+	api.ReplyWith(resp, http.StatusOK, l.response(req.Resource, false))
+}
+
+// heldLock returns the lock, and its encoded value at the key
+// store, if it is held by the requesting identity with the given
+// token. Otherwise, it sends an error response and returns false.
+func (s *Server) heldLock(resp *api.Response, req *api.Request, token string) (*lock, []byte, bool) {
+	state := s.state.Load()
+
+	l, raw, err := readLock(req.Context(), state.Keys.store, req.Resource)
+	if err != nil {
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			resp.Failf(http.StatusNotFound, "lock '%s' is not held", req.Resource)
+			return nil, nil, false
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Failf(http.StatusBadGateway, "failed to read lock '%s'", req.Resource)
+		return nil, nil, false
+	}
+	if l.Holder != req.Identity || !l.Owns(token) {
+		resp.Failf(http.StatusConflict, "lock '%s' is held by another identity", req.Resource)
+		return nil, nil, false
+	}
+	if l.IsExpired(time.Now()) {
+		resp.Failf(http.StatusConflict, "lock '%s' has expired", req.Resource)
+		return nil, nil, false
+	}
+	return l, raw, true
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"net/http"
+
+	"github.com/minio/kes/internal/api"
+)
+
+// SetReadOnly puts the server into or takes it out of read-only
+// mode. While in read-only mode, the server rejects requests that
+// would modify keys, for example during key store maintenance.
+// Requests that only read keys, like decrypt, are still served.
+//
+// The reason is reported to clients whose requests get rejected.
+// It is ignored when readOnly is false.
+func (s *Server) SetReadOnly(readOnly bool, reason string) {
+	if !readOnly {
+		s.readOnly.Store(nil)
+		return
+	}
+	s.readOnly.Store(&reason)
+}
+
+// IsReadOnly reports whether the server is in read-only mode
+// and, if so, why. The reason may be empty.
+func (s *Server) IsReadOnly() (bool, string) {
+	if reason := s.readOnly.Load(); reason != nil {
+		return true, *reason
+	}
+	return false, ""
+}
+
+// writable returns a Handler that wraps h and rejects
+// requests while the server is in read-only mode.
+func (s *Server) writable(h api.Handler) api.Handler {
+	return api.HandlerFunc(func(resp *api.Response, req *api.Request) {
+		if readOnly, reason := s.IsReadOnly(); readOnly {
+			if reason == "" {
+				resp.Fail(http.StatusServiceUnavailable, "server is in read-only mode")
+				return
+			}
+			resp.Failf(http.StatusServiceUnavailable, "server is in read-only mode: %s", reason)
+			return
+		}
+		h.ServeAPI(resp, req)
+	})
+}
+
+func (s *Server) setMaintenance(resp *api.Response, req *api.Request) {
+	if req.Identity != s.state.Load().Admin {
+		resp.Fail(http.StatusForbidden, "maintenance API requires the admin identity")
+		return
+	}
+
+	var mode api.MaintenanceRequest
+	if err := api.ReadBody(req, &mode); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid maintenance request body")
+		return
+	}
+	s.SetReadOnly(mode.ReadOnly, mode.Reason)
+
+	msg := "server is writable"
+	if mode.ReadOnly {
+		msg = "server is read-only"
+	}
+	const StatusOK = http.StatusOK
+	s.state.Load().Audit.Log(msg, StatusOK, req)
+	resp.Reply(StatusOK)
+}
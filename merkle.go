@@ -0,0 +1,399 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+// DefaultMerkleInterval is the default interval in which
+// a new Merkle root over all keys gets published.
+const DefaultMerkleInterval = 1 * time.Hour
+
+// merkleKeyEntry is the key store entry of the private key
+// used to sign Merkle roots. Like lock entries, it cannot
+// collide with keys.
+const merkleKeyEntry = "-merklekey"
+
+// merkleRootPrefix is the prefix of all published Merkle roots
+// at the key store. The prefix is followed by the zero-padded
+// publication time in seconds such that roots sort by time.
+const merkleRootPrefix = "-merkle-"
+
+// merkleCheckInterval is the interval in which the server
+// checks whether a new Merkle root has to be published.
+const merkleCheckInterval = 1 * time.Minute
+
+// merklePublisher controls how often Merkle roots get
+// published and how long they are retained.
+type merklePublisher struct {
+	interval  time.Duration
+	retention time.Duration
+}
+
+// newMerklePublisher returns a new Merkle publisher for
+// the given configuration, or nil if conf is nil.
+func newMerklePublisher(conf *MerkleConfig) *merklePublisher {
+	if conf == nil {
+		return nil
+	}
+	p := &merklePublisher{
+		interval:  conf.Interval,
+		retention: conf.Retention,
+	}
+	if p.interval <= 0 {
+		p.interval = DefaultMerkleInterval
+	}
+	return p
+}
+
+// merkleSnapshot is a published Merkle root. It contains the
+// leaves of the tree, sorted by key name, such that proofs can
+// be generated after keys have been deleted.
+type merkleSnapshot struct {
+	PublishedAt time.Time    `json:"published_at"`
+	Root        []byte       `json:"root"`
+	Signature   []byte       `json:"signature"`
+	Leaves      []merkleLeaf `json:"leaves"`
+}
+
+// merkleLeaf is the key metadata committed to by a Merkle leaf.
+type merkleLeaf struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by,omitempty"`
+}
+
+// hashes returns the leaf hashes of the snapshot's Merkle tree.
+func (s *merkleSnapshot) hashes() [][]byte {
+	hashes := make([][]byte, 0, len(s.Leaves))
+	for _, l := range s.Leaves {
+		hashes = append(hashes, crypto.MerkleLeafHash(crypto.MerkleKeyLeaf(l.Name, l.CreatedAt, l.CreatedBy)))
+	}
+	return hashes
+}
+
+// proof returns the inclusion proof of the i-th leaf.
+func (s *merkleSnapshot) proof(i int, hashes [][]byte) *api.MerkleLeaf {
+	return &api.MerkleLeaf{
+		Name:      s.Leaves[i].Name,
+		CreatedAt: s.Leaves[i].CreatedAt,
+		CreatedBy: s.Leaves[i].CreatedBy,
+		Index:     i,
+		Path:      crypto.MerklePath(i, hashes),
+	}
+}
+
+// merkleRootEntry returns the key store entry of the Merkle
+// root published at t.
+func merkleRootEntry(t time.Time) string {
+	return fmt.Sprintf("%s%020d", merkleRootPrefix, t.Unix())
+}
+
+// loadMerkleKey returns the Merkle root signing key stored at the
+// key store. If no key exists yet, it generates a new Ed25519 key.
+func loadMerkleKey(ctx context.Context, store KeyStore) (ed25519.PrivateKey, error) {
+	b, err := store.Get(ctx, merkleKeyEntry)
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		var priv ed25519.PrivateKey
+		if _, priv, err = ed25519.GenerateKey(rand.Reader); err != nil {
+			return nil, err
+		}
+		var der []byte
+		if der, err = x509.MarshalPKCS8PrivateKey(priv); err != nil {
+			return nil, err
+		}
+		b = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+		// Another KES server may have created the key in
+		// the meantime. Then, use the existing key.
+		if err = store.Create(ctx, merkleKeyEntry, b); errors.Is(err, kes.ErrKeyExists) {
+			b, err = store.Get(ctx, merkleKeyEntry)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("kes: invalid Merkle signing key: not a PEM-encoded private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("kes: invalid Merkle signing key: not an Ed25519 key")
+	}
+	return priv, nil
+}
+
+// listMerkleRoots returns the key store entries of all published
+// Merkle roots, sorted from oldest to newest.
+func listMerkleRoots(ctx context.Context, store KeyStore) ([]string, error) {
+	names, _, err := store.List(ctx, merkleRootPrefix, -1)
+	if err != nil {
+		return nil, err
+	}
+	names = slices.DeleteFunc(names, func(name string) bool { return !strings.HasPrefix(name, merkleRootPrefix) })
+	slices.Sort(names)
+	return names, nil
+}
+
+// loadMerkleSnapshot returns the latest Merkle root published at
+// or before t. It returns kes.ErrKeyNotFound if no such root exists.
+func loadMerkleSnapshot(ctx context.Context, store KeyStore, t time.Time) (*merkleSnapshot, error) {
+	names, err := listMerkleRoots(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	i, found := slices.BinarySearch(names, merkleRootEntry(t))
+	if found {
+		i++
+	}
+	if i == 0 {
+		return nil, kes.ErrKeyNotFound
+	}
+
+	b, err := store.Get(ctx, names[i-1])
+	if err != nil {
+		return nil, err
+	}
+	var snapshot merkleSnapshot
+	if err = json.Unmarshal(b, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// publishMerkleRoot computes the Merkle root over the metadata of
+// all keys, signs it and stores it at the key store. Roots older
+// than the retention period are removed, except the latest one.
+func (s *Server) publishMerkleRoot(ctx context.Context, now time.Time) error {
+	state := s.state.Load()
+	if state.Merkle == nil {
+		return nil
+	}
+	now = now.UTC().Truncate(time.Second)
+
+	names, _, err := state.Keys.List(ctx, "", -1)
+	if err != nil {
+		return err
+	}
+	slices.Sort(names)
+
+	snapshot := &merkleSnapshot{
+		PublishedAt: now,
+		Leaves:      make([]merkleLeaf, 0, len(names)),
+	}
+	for _, name := range names {
+		key, err := state.Keys.Get(ctx, name)
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			continue // The key has been deleted in the meantime
+		}
+		if err != nil {
+			return err
+		}
+		snapshot.Leaves = append(snapshot.Leaves, merkleLeaf{
+			Name:      name,
+			CreatedAt: key.CreatedAt,
+			CreatedBy: key.CreatedBy.String(),
+		})
+	}
+
+	priv, err := loadMerkleKey(ctx, state.Keys.store)
+	if err != nil {
+		return err
+	}
+	snapshot.Root = crypto.MerkleRoot(snapshot.hashes())
+	snapshot.Signature = ed25519.Sign(priv, crypto.MerkleCheckpoint(len(snapshot.Leaves), snapshot.Root, now))
+
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	// Another KES server may have published a root at the same time.
+	if err = state.Keys.store.Create(ctx, merkleRootEntry(now), b); err != nil && !errors.Is(err, kes.ErrKeyExists) {
+		return err
+	}
+
+	if state.Merkle.retention > 0 {
+		names, err := listMerkleRoots(ctx, state.Keys.store)
+		if err != nil {
+			return err
+		}
+		expired := merkleRootEntry(now.Add(-state.Merkle.retention))
+		for _, name := range names[:max(len(names)-1, 0)] {
+			if name >= expired {
+				break
+			}
+			if err = state.Keys.store.Delete(ctx, name); err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// startMerklePublisher publishes Merkle roots in the background
+// until ctx is done. A new root is published once the latest one
+// is older than the publication interval.
+func (s *Server) startMerklePublisher(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(merkleCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				state := s.state.Load()
+				if state.Merkle == nil || state.Standby.IsActive() {
+					continue
+				}
+				if readOnly, _ := s.IsReadOnly(); readOnly {
+					continue
+				}
+
+				names, err := listMerkleRoots(ctx, state.Keys.store)
+				if err != nil {
+					state.Log.WarnContext(ctx, fmt.Sprintf("failed to list Merkle roots: %v", err))
+					continue
+				}
+				if len(names) > 0 && names[len(names)-1] > merkleRootEntry(now.Add(-state.Merkle.interval)) {
+					continue
+				}
+				if err = s.publishMerkleRoot(ctx, now); err != nil {
+					state.Log.WarnContext(ctx, fmt.Sprintf("failed to publish Merkle root: %v", err))
+				}
+			}
+		}
+	}()
+}
+
+// parseMerkleTime parses the optional "at" query parameter of
+// Merkle API requests. If not present, it returns the current time.
+func parseMerkleTime(req *api.Request) (time.Time, error) {
+	v := req.URL.Query().Get("at")
+	if v == "" {
+		return time.Now(), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// merkleRoot returns the latest Merkle root published at or
+// before the time specified by the "at" query parameter.
+func (s *Server) merkleRoot(resp *api.Response, req *api.Request) {
+	at, err := parseMerkleTime(req)
+	if err != nil {
+		resp.Failf(http.StatusBadRequest, "invalid time '%s': expected RFC 3339 timestamp", req.URL.Query().Get("at"))
+		return
+	}
+	snapshot, pub, ok := s.loadMerkleRoot(resp, req, at)
+	if !ok {
+		return
+	}
+	api.ReplyWith(resp, http.StatusOK, api.MerkleRootResponse{
+		Size:        len(snapshot.Leaves),
+		Root:        snapshot.Root,
+		PublishedAt: snapshot.PublishedAt,
+		Signature:   snapshot.Signature,
+		PublicKey:   pub,
+	})
+}
+
+// merkleProof returns a proof that a key was, or was not, part of
+// the latest Merkle root published at or before the time specified
+// by the "at" query parameter.
+//
+// An inclusion proof contains the key's Merkle leaf and path. An
+// exclusion proof contains the inclusion proofs of the adjacent
+// leaves that would surround the key since leaves are sorted by
+// name. Hence, it reveals at most two other key names.
+func (s *Server) merkleProof(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+
+	at, err := parseMerkleTime(req)
+	if err != nil {
+		resp.Failf(http.StatusBadRequest, "invalid time '%s': expected RFC 3339 timestamp", req.URL.Query().Get("at"))
+		return
+	}
+	snapshot, pub, ok := s.loadMerkleRoot(resp, req, at)
+	if !ok {
+		return
+	}
+
+	hashes := snapshot.hashes()
+	proof := api.MerkleProofResponse{
+		Root: api.MerkleRootResponse{
+			Size:        len(snapshot.Leaves),
+			Root:        snapshot.Root,
+			PublishedAt: snapshot.PublishedAt,
+			Signature:   snapshot.Signature,
+			PublicKey:   pub,
+		},
+	}
+	i, found := slices.BinarySearchFunc(snapshot.Leaves, req.Resource, func(l merkleLeaf, name string) int {
+		return strings.Compare(l.Name, name)
+	})
+	if found {
+		proof.Included = true
+		proof.Leaf = snapshot.proof(i, hashes)
+	} else {
+		if i > 0 {
+			proof.Left = snapshot.proof(i-1, hashes)
+		}
+		if i < len(snapshot.Leaves) {
+			proof.Right = snapshot.proof(i, hashes)
+		}
+	}
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log("merkle proof generated", StatusOK, req)
+	api.ReplyWith(resp, StatusOK, proof)
+}
+
+// loadMerkleRoot loads the latest Merkle root published at or before
+// at and the public key of the signing key. If it fails, it sends an
+// error response to the client and returns false.
+func (s *Server) loadMerkleRoot(resp *api.Response, req *api.Request, at time.Time) (*merkleSnapshot, []byte, bool) {
+	state := s.state.Load()
+
+	snapshot, err := loadMerkleSnapshot(req.Context(), state.Keys.store, at)
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		resp.Failf(http.StatusNotFound, "no Merkle root published at or before %s", at.UTC().Format(time.RFC3339))
+		return nil, nil, false
+	}
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to load Merkle root")
+		return nil, nil, false
+	}
+	priv, err := loadMerkleKey(req.Context(), state.Keys.store)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to load Merkle signing key")
+		return nil, nil, false
+	}
+	return snapshot, priv.Public().(ed25519.PublicKey), true
+}
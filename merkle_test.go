@@ -0,0 +1,137 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+)
+
+func TestMerkleProof(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		Merkle: &MerkleConfig{},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	fetch := func(path string, v any) int {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request to '%s': %v", path, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatalf("Failed to decode response of '%s': %v", path, err)
+			}
+		}
+		return resp.StatusCode
+	}
+	verify := func(root api.MerkleRootResponse, leaf *api.MerkleLeaf) bool {
+		hash := crypto.MerkleLeafHash(crypto.MerkleKeyLeaf(leaf.Name, leaf.CreatedAt, leaf.CreatedBy))
+		return crypto.VerifyMerklePath(leaf.Index, root.Size, hash, leaf.Path, root.Root)
+	}
+
+	if code := fetch(api.PathMerkleRoot, new(api.MerkleRootResponse)); code != http.StatusNotFound {
+		t.Fatalf("Fetched Merkle root before publication: got status '%d' - want '%d'", code, http.StatusNotFound)
+	}
+
+	for _, name := range []string{"key-a", "key-c", "key-e", "key-g", "key-i"} {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	first := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	if err := srv.publishMerkleRoot(ctx, first); err != nil {
+		t.Fatalf("Failed to publish Merkle root: %v", err)
+	}
+	if err := client.CreateKey(ctx, "key-d"); err != nil {
+		t.Fatalf("Failed to create key '%s': %v", "key-d", err)
+	}
+	if err := srv.publishMerkleRoot(ctx, time.Now()); err != nil {
+		t.Fatalf("Failed to publish Merkle root: %v", err)
+	}
+
+	var root api.MerkleRootResponse
+	if code := fetch(api.PathMerkleRoot, &root); code != http.StatusOK {
+		t.Fatalf("Failed to fetch Merkle root: got status '%d'", code)
+	}
+	if root.Size != 6 {
+		t.Fatalf("Merkle root size mismatch: got '%d' - want '%d'", root.Size, 6)
+	}
+	if !ed25519.Verify(root.PublicKey, crypto.MerkleCheckpoint(root.Size, root.Root, root.PublishedAt), root.Signature) {
+		t.Fatal("Failed to verify Merkle root signature")
+	}
+
+	for i, test := range merkleProofTests {
+		path := api.PathMerkleProof + test.Key
+		if test.Before {
+			path += "?at=" + first.Add(time.Minute).Format(time.RFC3339)
+		}
+
+		var proof api.MerkleProofResponse
+		if code := fetch(path, &proof); code != http.StatusOK {
+			t.Fatalf("Test %d: failed to fetch Merkle proof: got status '%d'", i, code)
+		}
+		if test.Before && !proof.Root.PublishedAt.Equal(first) {
+			t.Fatalf("Test %d: Merkle root mismatch: got root published at '%v' - want '%v'", i, proof.Root.PublishedAt, first)
+		}
+		if !ed25519.Verify(proof.Root.PublicKey, crypto.MerkleCheckpoint(proof.Root.Size, proof.Root.Root, proof.Root.PublishedAt), proof.Root.Signature) {
+			t.Fatalf("Test %d: failed to verify Merkle root signature", i)
+		}
+		if proof.Included != test.Included {
+			t.Fatalf("Test %d: inclusion mismatch: got '%v' - want '%v'", i, proof.Included, test.Included)
+		}
+		if test.Included {
+			if proof.Leaf == nil || proof.Leaf.Name != test.Key || !verify(proof.Root, proof.Leaf) {
+				t.Fatalf("Test %d: failed to verify inclusion proof", i)
+			}
+			continue
+		}
+		if proof.Left != nil && (proof.Left.Name >= test.Key || !verify(proof.Root, proof.Left)) {
+			t.Fatalf("Test %d: failed to verify left exclusion proof", i)
+		}
+		if proof.Right != nil && (proof.Right.Name <= test.Key || !verify(proof.Root, proof.Right)) {
+			t.Fatalf("Test %d: failed to verify right exclusion proof", i)
+		}
+		if proof.Left != nil && proof.Right != nil && proof.Left.Index+1 != proof.Right.Index {
+			t.Fatalf("Test %d: exclusion proof leaves are not adjacent", i)
+		}
+	}
+
+	path := api.PathMerkleProof + "key-a?at=" + first.Add(-time.Minute).Format(time.RFC3339)
+	if code := fetch(path, new(api.MerkleProofResponse)); code != http.StatusNotFound {
+		t.Fatalf("Fetched Merkle proof before publication: got status '%d' - want '%d'", code, http.StatusNotFound)
+	}
+}
+
+var merkleProofTests = []struct {
+	Key      string
+	Before   bool
+	Included bool
+}{
+	{Key: "key-a", Included: true},
+	{Key: "key-d", Included: true},
+	{Key: "key-i", Included: true},
+	{Key: "key-d", Before: true, Included: false},
+	{Key: "key-e", Before: true, Included: true},
+	{Key: "key-0", Included: false},
+	{Key: "key-b", Included: false},
+	{Key: "key-z", Included: false},
+}
@@ -0,0 +1,184 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+// ownerPrefix is the prefix of owner entries at the key store.
+// It is followed by the key name. Like folders, owner entries
+// never collide with keys.
+const ownerPrefix = "-owner-"
+
+// maxOwnerLength is the max. length of a key owner or contact.
+const maxOwnerLength = 256
+
+// keyOwner is the owner of a key as stored at the key store.
+type keyOwner struct {
+	Owner      string       `json:"owner"`
+	Contact    string       `json:"contact"`
+	AssignedAt time.Time    `json:"assigned_at"`
+	AssignedBy kes.Identity `json:"assigned_by"`
+}
+
+// validOwner reports whether s is a valid key owner or contact.
+// It must not be empty, be at most maxOwnerLength bytes long and
+// not contain any control characters.
+func validOwner(s string) bool {
+	if s == "" || len(s) > maxOwnerLength || !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseKeyOwner returns the owner of a new key assigned by the
+// given identity. It returns nil if neither owner nor contact is
+// specified and no owner is required. Owner and contact must be
+// specified together.
+func parseKeyOwner(owner, contact string, required bool, identity kes.Identity) (*keyOwner, api.Error) {
+	if owner == "" && contact == "" {
+		if required {
+			return nil, api.NewError(http.StatusBadRequest, "key owner and contact are required")
+		}
+		return nil, nil
+	}
+	if !validOwner(owner) {
+		return nil, api.NewError(http.StatusBadRequest, fmt.Sprintf("key owner '%s' is empty, too long or contains invalid characters", owner))
+	}
+	if !validOwner(contact) {
+		return nil, api.NewError(http.StatusBadRequest, fmt.Sprintf("key contact '%s' is empty, too long or contains invalid characters", contact))
+	}
+	return &keyOwner{
+		Owner:      owner,
+		Contact:    contact,
+		AssignedAt: time.Now().UTC(),
+		AssignedBy: identity,
+	}, nil
+}
+
+// loadKeyOwner returns the owner of the key with the given
+// name, or nil if no owner has been assigned to the key.
+func loadKeyOwner(ctx context.Context, c *keyCache, name string) (*keyOwner, error) {
+	if isReservedEntry(name) {
+		return nil, nil
+	}
+	b, err := c.get(ctx, ownerPrefix+name)
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var owner keyOwner
+	if err = json.Unmarshal(b, &owner); err != nil {
+		return nil, fmt.Errorf("kes: invalid owner of key '%s': %v", name, err)
+	}
+	return &owner, nil
+}
+
+// storeKeyOwner stores the owner of the key with the given name,
+// replacing any previous owner. It does not check whether the key
+// exists.
+func storeKeyOwner(ctx context.Context, c *keyCache, name string, owner *keyOwner) error {
+	b, err := json.Marshal(owner)
+	if err != nil {
+		return err
+	}
+	return c.withTimeout(ctx, "create", c.writeTimeout, func(ctx context.Context) error {
+		return setEntry(ctx, c.store, ownerPrefix+name, b)
+	})
+}
+
+// assignKeyOwner assigns the owner to the key with the given name.
+// It returns kes.ErrKeyNotFound if no such key exists.
+func assignKeyOwner(ctx context.Context, c *keyCache, name string, owner *keyOwner) error {
+	if _, err := c.Get(ctx, name); err != nil {
+		return err
+	}
+	return storeKeyOwner(ctx, c, name, owner)
+}
+
+// createOwnedKey creates a new key, optionally time-locked, and
+// assigns the owner, if not nil, to it. If the owner cannot be
+// stored, the key is deleted again such that no unowned key is
+// left behind.
+func createOwnedKey(ctx context.Context, c *keyCache, name string, key crypto.KeyVersion, lock *timeLock, owner *keyOwner) error {
+	var err error
+	if lock != nil {
+		err = createTimeLockedKey(ctx, c, name, key, lock)
+	} else {
+		err = c.Create(ctx, name, key)
+	}
+	if err != nil || owner == nil {
+		return err
+	}
+
+	if err = storeKeyOwner(ctx, c, name, owner); err != nil {
+		if dErr := c.Delete(context.WithoutCancel(ctx), name); dErr != nil {
+			return fmt.Errorf("kes: failed to assign owner of key '%s': %v: failed to delete key: %v", name, err, dErr)
+		}
+		return fmt.Errorf("kes: failed to assign owner of key '%s': %v", name, err)
+	}
+	return nil
+}
+
+func (s *Server) setKeyOwner(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	var body api.SetKeyOwnerRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid request body")
+		return
+	}
+	owner, apiErr := parseKeyOwner(body.Owner, body.Contact, true, req.Identity)
+	if apiErr != nil {
+		resp.Failr(apiErr)
+		return
+	}
+
+	state := s.state.Load()
+	if err := assignKeyOwner(req.Context(), state.Keys, req.Resource, owner); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to assign key owner")
+		return
+	}
+
+	state.Changes.Record(api.ChangeObjectKey, api.ChangeUpdate, req.Resource, req.Identity)
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(fmt.Sprintf("secret key '%s' assigned to owner '%s'", req.Resource, owner.Owner), StatusOK, req)
+	resp.Reply(StatusOK)
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/minio/kes/internal/api"
+)
+
+func TestKeyOwner(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		Keys:            &MemKeyStore{},
+		RequireKeyOwner: true,
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	send := func(method, path string, body, out any) int {
+		var b []byte
+		if body != nil {
+			var err error
+			if b, err = json.Marshal(body); err != nil {
+				t.Fatalf("Failed to encode request: %v", err)
+			}
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url+path, bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if out != nil && resp.StatusCode == http.StatusOK {
+			if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	if err := client.CreateKey(ctx, "unowned-key"); err == nil {
+		t.Fatal("Created key without owner")
+	}
+	if code := send(http.MethodPost, api.PathKeyCreate+"my-key", api.CreateKeyRequest{Owner: "team-a"}, nil); code != http.StatusBadRequest {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", code, http.StatusBadRequest)
+	}
+	if code := send(http.MethodPost, api.PathKeyCreate+"my-key", api.CreateKeyRequest{Owner: "team-a", Contact: "team-a@example.com"}, nil); code != http.StatusOK {
+		t.Fatalf("Failed to create key: status code '%d'", code)
+	}
+	if code := send(http.MethodPost, api.PathKeyImport+"other-key", api.ImportKeyRequest{
+		Bytes:   make([]byte, 32),
+		Cipher:  "AES256",
+		Owner:   "team-b",
+		Contact: "team-b@example.com",
+	}, nil); code != http.StatusOK {
+		t.Fatalf("Failed to import key: status code '%d'", code)
+	}
+
+	var info api.DescribeKeyResponse
+	if code := send(http.MethodGet, api.PathKeyDescribe+"my-key", nil, &info); code != http.StatusOK {
+		t.Fatalf("Failed to describe key: status code '%d'", code)
+	}
+	if info.Owner != "team-a" || info.Contact != "team-a@example.com" {
+		t.Fatalf("Invalid owner: got '%s' '%s' - want '%s' '%s'", info.Owner, info.Contact, "team-a", "team-a@example.com")
+	}
+
+	setOwner := func(name, owner, contact string) int {
+		return send(http.MethodPut, api.PathKeyOwner+name, api.SetKeyOwnerRequest{Owner: owner, Contact: contact}, nil)
+	}
+	if code := setOwner("missing-key", "team-a", "team-a@example.com"); code != http.StatusNotFound {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", code, http.StatusNotFound)
+	}
+	if code := setOwner("my-key", "team-c", ""); code != http.StatusBadRequest {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", code, http.StatusBadRequest)
+	}
+	if code := setOwner("my-key", strings.Repeat("a", maxOwnerLength+1), "team-c@example.com"); code != http.StatusBadRequest {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", code, http.StatusBadRequest)
+	}
+	if code := setOwner("my-key", "team-b", "team-b@example.com"); code != http.StatusOK {
+		t.Fatalf("Failed to reassign key owner: status code '%d'", code)
+	}
+
+	var list api.ListKeysResponse
+	if code := send(http.MethodGet, api.PathKeyList+"*?owner=team-b", nil, &list); code != http.StatusOK {
+		t.Fatalf("Failed to list keys: status code '%d'", code)
+	}
+	if names := []string{"my-key", "other-key"}; !slices.Equal(list.Names, names) {
+		t.Fatalf("Invalid keys of owner: got '%v' - want '%v'", list.Names, names)
+	}
+
+	// Deleting a key removes its owner such that a new
+	// key with the same name is unowned.
+	if err := client.DeleteKey(ctx, "other-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	state := srv.state.Load().clone()
+	state.RequireOwner = false
+	srv.state.Store(state)
+	if err := client.CreateKey(ctx, "other-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if code := send(http.MethodGet, api.PathKeyList+"*?unowned=true", nil, &list); code != http.StatusOK {
+		t.Fatalf("Failed to list keys: status code '%d'", code)
+	}
+	if names := []string{"other-key"}; !slices.Equal(list.Names, names) {
+		t.Fatalf("Invalid unowned keys: got '%v' - want '%v'", list.Names, names)
+	}
+	if code := send(http.MethodGet, api.PathKeyList+"*?owner=team-b&unowned=true", nil, &list); code != http.StatusBadRequest {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", code, http.StatusBadRequest)
+	}
+}
+
+func TestValidOwner(t *testing.T) {
+	for i, test := range validOwnerTests {
+		if valid := validOwner(test.Owner); valid != test.Valid {
+			t.Fatalf("Test %d: got '%v' - want '%v'", i, valid, test.Valid)
+		}
+	}
+}
+
+var validOwnerTests = []struct {
+	Owner string
+	Valid bool
+}{
+	{Owner: "team-a", Valid: true},                               // 0
+	{Owner: "Jane Doe <jane@example.com>", Valid: true},          // 1
+	{Owner: "", Valid: false},                                    // 2
+	{Owner: "team\na", Valid: false},                             // 3
+	{Owner: strings.Repeat("a", maxOwnerLength), Valid: true},    // 4
+	{Owner: strings.Repeat("a", maxOwnerLength+1), Valid: false}, // 5
+	{Owner: "\xff", Valid: false},                                // 6
+}
@@ -0,0 +1,683 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kms-go/kes"
+	"golang.org/x/crypto/ocsp"
+)
+
+// Default validity periods of X.509 certificates.
+const (
+	defaultPKITTL = 24 * time.Hour
+	maxPKITTL     = 7 * 24 * time.Hour
+)
+
+// Key store entries of the PKI engine. Like lock entries,
+// they cannot collide with keys.
+const (
+	pkiCAEntry       = "-pkica"
+	pkiRevokedPrefix = "-pkirevoked-"
+)
+
+// pkiCRLValidity is the time period after which clients
+// should fetch a new CRL or OCSP response.
+const pkiCRLValidity = 1 * time.Hour
+
+// pkiEngine issues X.509 certificates from the KES CA.
+type pkiEngine struct {
+	url    string
+	roles  map[string]*pkiRole
+	maxTTL time.Duration // The longest validity period of any role
+
+	renewal *pkiRenewal // Client certificate renewal, if enabled
+}
+
+// pkiRole controls which X.509 certificates can be issued.
+type pkiRole struct {
+	domains     []string
+	allowIPs    bool
+	extKeyUsage []x509.ExtKeyUsage
+	defaultTTL  time.Duration
+	maxTTL      time.Duration
+}
+
+// newPKIEngine returns a PKI engine for the given configuration.
+func newPKIEngine(conf *PKIConfig) *pkiEngine {
+	e := &pkiEngine{
+		roles:  map[string]*pkiRole{},
+		maxTTL: maxPKITTL,
+	}
+	if conf == nil {
+		return e
+	}
+
+	e.url = strings.TrimSuffix(conf.URL, "/")
+	e.renewal = newPKIRenewal(conf.Renewal)
+	for name, c := range conf.Roles {
+		r := &pkiRole{
+			domains:    c.Domains,
+			allowIPs:   c.AllowIPs,
+			defaultTTL: c.DefaultTTL,
+			maxTTL:     c.MaxTTL,
+		}
+		if c.ServerAuth || !c.ClientAuth {
+			r.extKeyUsage = append(r.extKeyUsage, x509.ExtKeyUsageServerAuth)
+		}
+		if c.ClientAuth || !c.ServerAuth {
+			r.extKeyUsage = append(r.extKeyUsage, x509.ExtKeyUsageClientAuth)
+		}
+		if r.defaultTTL <= 0 {
+			r.defaultTTL = defaultPKITTL
+		}
+		if r.maxTTL <= 0 {
+			r.maxTTL = maxPKITTL
+		}
+		e.maxTTL = max(e.maxTTL, r.maxTTL)
+		e.roles[name] = r
+	}
+	return e
+}
+
+// allowed reports whether the role allows issuing
+// certificates for the given domain name.
+func (r *pkiRole) allowed(domain string) bool {
+	for _, pattern := range r.domains {
+		if ok, _ := path.Match(pattern, domain); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// pkiCA is the CA certificate chain and private key as stored
+// at the key store.
+type pkiCA struct {
+	Certificate string `json:"certificate"` // PEM, CA certificate first
+	PrivateKey  string `json:"private_key"` // PEM, PKCS #8
+}
+
+// A pkiIssuer signs X.509 certificates and CRLs.
+type pkiIssuer struct {
+	Cert  *x509.Certificate
+	Chain []byte // PEM encoded CA certificate chain
+	Key   crypto.Signer
+}
+
+// parsePKICA parses and verifies a PEM encoded CA certificate
+// chain and private key.
+func parsePKICA(certPEM, keyPEM []byte) (*pkiIssuer, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM-encoded CA certificate found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if !cert.BasicConstraintsValid || !cert.IsCA {
+		return nil, errors.New("certificate is not a CA certificate")
+	}
+	if cert.KeyUsage&x509.KeyUsageCertSign == 0 || cert.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return nil, errors.New("CA certificate must permit certificate and CRL signing")
+	}
+
+	block, _ = pem.Decode(keyPEM)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("no PEM-encoded PKCS #8 private key found")
+	}
+	priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot sign")
+	}
+	if pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(cert.PublicKey) {
+		return nil, errors.New("private key does not match CA certificate")
+	}
+	return &pkiIssuer{
+		Cert:  cert,
+		Chain: certPEM,
+		Key:   key,
+	}, nil
+}
+
+// loadPKICA returns the CA stored at the key store. If no CA
+// exists yet, it generates a new self-signed ECDSA P-256 CA.
+func loadPKICA(ctx context.Context, store KeyStore) (*pkiIssuer, error) {
+	b, err := store.Get(ctx, pkiCAEntry)
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		var ca pkiCA
+		if ca, err = generatePKICA(); err != nil {
+			return nil, err
+		}
+		if b, err = json.Marshal(ca); err != nil {
+			return nil, err
+		}
+
+		// Another request or KES server may have created the
+		// CA in the meantime. Then, use the existing CA.
+		if err = store.Create(ctx, pkiCAEntry, b); errors.Is(err, kes.ErrKeyExists) {
+			b, err = store.Get(ctx, pkiCAEntry)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ca pkiCA
+	if err = json.Unmarshal(b, &ca); err != nil {
+		return nil, fmt.Errorf("invalid PKI CA: %v", err)
+	}
+	return parsePKICA([]byte(ca.Certificate), []byte(ca.PrivateKey))
+}
+
+// generatePKICA generates a new self-signed CA.
+func generatePKICA() (pkiCA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return pkiCA{}, err
+	}
+	serial, err := pkiSerial()
+	if err != nil {
+		return pkiCA{}, err
+	}
+
+	now := time.Now().UTC()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "KES PKI CA"},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return pkiCA{}, err
+	}
+	privKey, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return pkiCA{}, err
+	}
+	return pkiCA{
+		Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})),
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privKey})),
+	}, nil
+}
+
+// pkiSerial returns a random, positive 128 bit serial number.
+func pkiSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// pkiRevocation is a revoked certificate as stored at the
+// key store.
+type pkiRevocation struct {
+	RevokedAt time.Time `json:"revoked_at"`
+	ExpiresAt time.Time `json:"expires_at"` // Certificate is expired after ExpiresAt
+}
+
+// revocations returns all revoked certificates that have
+// not expired at now by their serial number.
+func revocations(ctx context.Context, store KeyStore, now time.Time) (map[string]pkiRevocation, error) {
+	names, _, err := store.List(ctx, pkiRevokedPrefix, -1)
+	if err != nil {
+		return nil, err
+	}
+	revoked := make(map[string]pkiRevocation, len(names))
+	for _, name := range names {
+		b, err := store.Get(ctx, name)
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var r pkiRevocation
+		if err = json.Unmarshal(b, &r); err != nil {
+			return nil, fmt.Errorf("invalid certificate revocation '%s': %v", name, err)
+		}
+		if now.Before(r.ExpiresAt) {
+			revoked[strings.TrimPrefix(name, pkiRevokedPrefix)] = r
+		}
+	}
+	return revoked, nil
+}
+
+// describePKICA returns the PEM encoded CA certificate chain.
+func (s *Server) describePKICA(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+
+	ca, err := loadPKICA(req.Context(), state.Keys.store)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to load PKI CA")
+		return
+	}
+	api.ReplyWith(resp, http.StatusOK, api.DescribePKICAResponse{
+		Certificate: string(ca.Chain),
+	})
+}
+
+// importPKICA replaces the CA, usually with an intermediate CA
+// signed by an offline root CA. Only the admin may import a CA.
+func (s *Server) importPKICA(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	if req.Identity != state.Admin {
+		resp.Fail(http.StatusForbidden, "importing a PKI CA requires the admin identity")
+		return
+	}
+
+	var body api.ImportPKICARequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid PKI CA request body")
+		return
+	}
+	ca, err := parsePKICA([]byte(body.Certificate), []byte(body.PrivateKey))
+	if err != nil {
+		resp.Failf(http.StatusBadRequest, "invalid PKI CA: %v", err)
+		return
+	}
+	b, err := json.Marshal(pkiCA{
+		Certificate: body.Certificate,
+		PrivateKey:  body.PrivateKey,
+	})
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to import PKI CA")
+		return
+	}
+
+	if err = state.Keys.store.Delete(req.Context(), pkiCAEntry); err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to import PKI CA")
+		return
+	}
+	if err = state.Keys.store.Create(req.Context(), pkiCAEntry, b); err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to import PKI CA")
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(fmt.Sprintf("PKI CA '%s' imported", ca.Cert.Subject), StatusOK, req)
+	resp.Reply(StatusOK)
+}
+
+// issueCertificate issues a X.509 certificate. The requested
+// names and validity period must be allowed by the PKI role.
+//
+// If the request contains a CSR, the certificate is issued for
+// the CSR's public key. Otherwise, a new private key is generated
+// and returned to the client.
+func (s *Server) issueCertificate(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	role, ok := state.PKI.roles[req.Resource]
+	if !ok {
+		resp.Failf(http.StatusNotFound, "PKI role '%s' does not exist", req.Resource)
+		return
+	}
+
+	var body api.IssueCertificateRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid certificate request body")
+		return
+	}
+
+	var csr *x509.CertificateRequest
+	if body.CSR != "" {
+		block, _ := pem.Decode([]byte(body.CSR))
+		if block == nil || block.Type != "CERTIFICATE REQUEST" {
+			resp.Fail(http.StatusBadRequest, "invalid CSR: no PEM-encoded certificate request found")
+			return
+		}
+		var err error
+		if csr, err = x509.ParseCertificateRequest(block.Bytes); err != nil {
+			resp.Failf(http.StatusBadRequest, "invalid CSR: %v", err)
+			return
+		}
+		if err = csr.CheckSignature(); err != nil {
+			resp.Failf(http.StatusBadRequest, "invalid CSR: %v", err)
+			return
+		}
+		if body.CommonName == "" && len(body.DNSNames) == 0 && len(body.IPAddresses) == 0 {
+			body.CommonName = csr.Subject.CommonName
+			body.DNSNames = csr.DNSNames
+			for _, ip := range csr.IPAddresses {
+				body.IPAddresses = append(body.IPAddresses, ip.String())
+			}
+		}
+	}
+
+	if body.CommonName == "" && len(body.DNSNames) == 0 && len(body.IPAddresses) == 0 {
+		resp.Fail(http.StatusBadRequest, "no common name or subject alternative names specified")
+		return
+	}
+	dnsNames := slices.Clone(body.DNSNames)
+	if body.CommonName != "" && net.ParseIP(body.CommonName) == nil && !slices.Contains(dnsNames, body.CommonName) {
+		dnsNames = append(dnsNames, body.CommonName)
+	}
+	for _, name := range dnsNames {
+		if !role.allowed(name) {
+			resp.Failf(http.StatusForbidden, "PKI role '%s' does not allow domain '%s'", req.Resource, name)
+			return
+		}
+	}
+	ipAddresses := make([]net.IP, 0, len(body.IPAddresses))
+	for _, addr := range body.IPAddresses {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			resp.Failf(http.StatusBadRequest, "invalid IP address '%s'", addr)
+			return
+		}
+		if !role.allowIPs {
+			resp.Failf(http.StatusForbidden, "PKI role '%s' does not allow IP addresses", req.Resource)
+			return
+		}
+		ipAddresses = append(ipAddresses, ip)
+	}
+	ttl := role.defaultTTL
+	if body.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(body.TTL); err != nil || ttl <= 0 {
+			resp.Failf(http.StatusBadRequest, "invalid certificate TTL '%s'", body.TTL)
+			return
+		}
+		if ttl > role.maxTTL {
+			resp.Failf(http.StatusBadRequest, "invalid certificate TTL '%v': must not exceed %v", ttl, role.maxTTL)
+			return
+		}
+	}
+
+	ca, err := loadPKICA(req.Context(), state.Keys.store)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to load PKI CA")
+		return
+	}
+
+	var (
+		pub     crypto.PublicKey
+		privPEM []byte
+	)
+	if csr != nil {
+		pub = csr.PublicKey
+	} else {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+			resp.Fail(http.StatusInternalServerError, "failed to issue certificate")
+			return
+		}
+		privKey, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+			resp.Fail(http.StatusInternalServerError, "failed to issue certificate")
+			return
+		}
+		pub, privPEM = key.Public(), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privKey})
+	}
+	serial, err := pkiSerial()
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to issue certificate")
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	notAfter := now.Add(ttl)
+	if notAfter.After(ca.Cert.NotAfter) {
+		notAfter = ca.Cert.NotAfter
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: body.CommonName},
+		DNSNames:     dnsNames,
+		IPAddresses:  ipAddresses,
+		NotBefore:    now.Add(-1 * time.Minute),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  role.extKeyUsage,
+	}
+	if state.PKI.url != "" {
+		template.OCSPServer = []string{state.PKI.url + api.PathPKIOCSP}
+		template.CRLDistributionPoints = []string{state.PKI.url + api.PathPKICRL}
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, pub, ca.Key)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to issue certificate")
+		return
+	}
+
+	serialHex := hex.EncodeToString(serial.Bytes())
+	const StatusOK = http.StatusOK
+	state.Audit.Log(
+		fmt.Sprintf("certificate '%s' issued with role '%s' for '%s'", serialHex, req.Resource, strings.Join(dnsNames, ",")),
+		StatusOK,
+		req,
+	)
+	api.ReplyWith(resp, StatusOK, api.IssueCertificateResponse{
+		Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})),
+		CAChain:     string(ca.Chain),
+		PrivateKey:  string(privPEM),
+		Serial:      serialHex,
+		ExpiresAt:   notAfter,
+	})
+}
+
+// revokeCertificate revokes the certificate with the given
+// serial number. Revoked certificates are published via the
+// CRL and OCSP APIs until they expire.
+func (s *Server) revokeCertificate(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+
+	serial, ok := new(big.Int).SetString(req.Resource, 16)
+	if !ok || serial.Sign() <= 0 {
+		resp.Failf(http.StatusBadRequest, "invalid certificate serial number '%s'", req.Resource)
+		return
+	}
+	serialHex := hex.EncodeToString(serial.Bytes())
+
+	// Certificates are not stored. Hence, the revocation is kept
+	// as long as any certificate may be valid.
+	now := time.Now().UTC().Truncate(time.Second)
+	b, err := json.Marshal(pkiRevocation{
+		RevokedAt: now,
+		ExpiresAt: now.Add(state.PKI.maxTTL),
+	})
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to revoke certificate")
+		return
+	}
+	if err = state.Keys.store.Create(req.Context(), pkiRevokedPrefix+serialHex, b); err != nil && !errors.Is(err, kes.ErrKeyExists) {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to revoke certificate")
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(fmt.Sprintf("certificate '%s' revoked", serialHex), StatusOK, req)
+	resp.Reply(StatusOK)
+}
+
+// certificateRevocationList returns a DER encoded CRL
+// signed by the CA.
+func (s *Server) certificateRevocationList(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+
+	ca, err := loadPKICA(req.Context(), state.Keys.store)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to load PKI CA")
+		return
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	revoked, err := revocations(req.Context(), state.Keys.store, now)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to list revoked certificates")
+		return
+	}
+
+	entries := make([]x509.RevocationListEntry, 0, len(revoked))
+	for serial, r := range revoked {
+		n, _ := new(big.Int).SetString(serial, 16)
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   n,
+			RevocationTime: r.RevokedAt,
+		})
+	}
+	slices.SortFunc(entries, func(a, b x509.RevocationListEntry) int { return a.SerialNumber.Cmp(b.SerialNumber) })
+
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(now.Unix()),
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(pkiCRLValidity),
+		RevokedCertificateEntries: entries,
+	}, ca.Cert, ca.Key)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to create CRL")
+		return
+	}
+
+	resp.Header().Set(headers.ContentType, headers.ContentTypePKIXCRL)
+	resp.WriteHeader(http.StatusOK)
+	resp.Write(crl)
+}
+
+// ocspResponder answers OCSP requests (RFC 6960) for
+// certificates issued by the CA.
+func (s *Server) ocspResponder(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+
+	replyOCSP := func(b []byte) {
+		resp.Header().Set(headers.ContentType, headers.ContentTypeOCSPResponse)
+		resp.WriteHeader(http.StatusOK)
+		resp.Write(b)
+	}
+
+	// OCSP clients either POST the DER-encoded request or send
+	// a GET request with the base64-encoded request appended to
+	// the URL path (RFC 6960, Appendix A.1).
+	var body []byte
+	switch req.Method {
+	case http.MethodGet:
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(req.Resource); err != nil {
+			replyOCSP(ocsp.MalformedRequestErrorResponse)
+			return
+		}
+	default:
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			if err, ok := api.IsError(err); ok {
+				resp.Failr(err)
+				return
+			}
+			replyOCSP(ocsp.MalformedRequestErrorResponse)
+			return
+		}
+	}
+	ocspReq, err := ocsp.ParseRequest(body)
+	if err != nil {
+		replyOCSP(ocsp.MalformedRequestErrorResponse)
+		return
+	}
+
+	ca, err := loadPKICA(req.Context(), state.Keys.store)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		replyOCSP(ocsp.InternalErrorErrorResponse)
+		return
+	}
+	if !ocspReq.HashAlgorithm.Available() || !bytes.Equal(issuerKeyHash(ca.Cert, ocspReq.HashAlgorithm), ocspReq.IssuerKeyHash) {
+		replyOCSP(ocsp.UnauthorizedErrorResponse)
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	revoked, err := revocations(req.Context(), state.Keys.store, now)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		replyOCSP(ocsp.InternalErrorErrorResponse)
+		return
+	}
+	template := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: ocspReq.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(pkiCRLValidity),
+	}
+	if r, ok := revoked[hex.EncodeToString(ocspReq.SerialNumber.Bytes())]; ok {
+		template.Status = ocsp.Revoked
+		template.RevokedAt = r.RevokedAt
+		template.RevocationReason = ocsp.Unspecified
+	}
+	b, err := ocsp.CreateResponse(ca.Cert, ca.Cert, template, ca.Key)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		replyOCSP(ocsp.InternalErrorErrorResponse)
+		return
+	}
+	replyOCSP(b)
+}
+
+// issuerKeyHash returns the hash of the certificate's public
+// key as used by OCSP to identify the issuer.
+func issuerKeyHash(cert *x509.Certificate, h crypto.Hash) []byte {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil
+	}
+	hash := h.New()
+	hash.Write(spki.PublicKey.RightAlign())
+	return hash.Sum(nil)
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/minio/kes/internal/api"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestPKI(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		PKI: &PKIConfig{
+			URL: "https://kes.example.com:7373",
+			Roles: map[string]*PKIRoleConfig{
+				"mesh": {
+					Domains:    []string{"*.svc.local"},
+					ServerAuth: true,
+				},
+			},
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	do := func(method, path string, body any) *http.Response {
+		var r io.Reader
+		switch body := body.(type) {
+		case nil:
+		case []byte:
+			r = bytes.NewReader(body)
+		default:
+			b, _ := json.Marshal(body)
+			r = bytes.NewReader(b)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url+path, r)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request to '%s': %v", path, err)
+		}
+		return resp
+	}
+
+	resp := do(http.MethodPut, api.PathPKIIssue+"mesh", api.IssueCertificateRequest{CommonName: "db.example.com"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Issuing a certificate for a disallowed domain should fail with '%d' - got '%d'", http.StatusForbidden, resp.StatusCode)
+	}
+	resp = do(http.MethodPut, api.PathPKIIssue+"mesh", api.IssueCertificateRequest{CommonName: "db.svc.local", IPAddresses: []string{"10.0.0.1"}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Issuing a certificate with IP addresses should fail with '%d' - got '%d'", http.StatusForbidden, resp.StatusCode)
+	}
+
+	resp = do(http.MethodPut, api.PathPKIIssue+"mesh", api.IssueCertificateRequest{CommonName: "db.svc.local", TTL: "1h"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to issue certificate: got status '%d'", resp.StatusCode)
+	}
+	var issued api.IssueCertificateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&issued); err != nil {
+		t.Fatalf("Failed to decode certificate: %v", err)
+	}
+	resp.Body.Close()
+	if issued.PrivateKey == "" {
+		t.Fatal("Issued certificate contains no private key")
+	}
+
+	block, _ := pem.Decode([]byte(issued.Certificate))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	block, _ = pem.Decode([]byte(issued.CAChain))
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse CA certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	if _, err = cert.Verify(x509.VerifyOptions{DNSName: "db.svc.local", Roots: roots}); err != nil {
+		t.Fatalf("Issued certificate is not valid: %v", err)
+	}
+	if len(cert.OCSPServer) != 1 || cert.OCSPServer[0] != "https://kes.example.com:7373"+api.PathPKIOCSP {
+		t.Fatalf("Invalid OCSP server: got '%v'", cert.OCSPServer)
+	}
+
+	resp = do(http.MethodPut, api.PathPKIRevoke+issued.Serial, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to revoke certificate: got status '%d'", resp.StatusCode)
+	}
+
+	// Relying parties fetch the CRL and query OCSP without
+	// a client certificate.
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(defaultServerCertificate().Leaf)
+	client.HTTPClient = http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: rootCAs},
+		},
+	}
+
+	resp = do(http.MethodGet, api.PathPKICRL, nil)
+	der, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatalf("Failed to parse CRL: %v", err)
+	}
+	if err = crl.CheckSignatureFrom(caCert); err != nil {
+		t.Fatalf("CRL is not signed by CA: %v", err)
+	}
+	if len(crl.RevokedCertificateEntries) != 1 || crl.RevokedCertificateEntries[0].SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		t.Fatalf("CRL does not contain revoked certificate: got '%v'", crl.RevokedCertificateEntries)
+	}
+
+	ocspReq, err := ocsp.CreateRequest(cert, caCert, nil)
+	if err != nil {
+		t.Fatalf("Failed to create OCSP request: %v", err)
+	}
+	resp = do(http.MethodPost, api.PathPKIOCSP, ocspReq)
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	ocspResp, err := ocsp.ParseResponseForCert(b, cert, caCert)
+	if err != nil {
+		t.Fatalf("Failed to parse OCSP response: %v", err)
+	}
+	if ocspResp.Status != ocsp.Revoked {
+		t.Fatalf("OCSP response should report the certificate as revoked: got status '%d'", ocspResp.Status)
+	}
+
+	resp = do(http.MethodGet, api.PathPKIOCSPGet+base64.StdEncoding.EncodeToString(ocspReq), nil)
+	b, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if ocspResp, err = ocsp.ParseResponseForCert(b, cert, caCert); err != nil {
+		t.Fatalf("Failed to parse OCSP response: %v", err)
+	}
+	if ocspResp.Status != ocsp.Revoked {
+		t.Fatalf("OCSP response should report the certificate as revoked: got status '%d'", ocspResp.Status)
+	}
+
+	resp = do(http.MethodPut, api.PathPKIOCSP, ocspReq)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("OCSP requests must be sent via POST or GET: got status '%d'", resp.StatusCode)
+	}
+}
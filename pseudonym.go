@@ -0,0 +1,225 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+// auditPseudonymKeyEntry is the name of the audit pseudonymization
+// key at the key store. Like locks, it never collides with keys.
+const auditPseudonymKeyEntry = "-auditpseudonymkey"
+
+// maxPseudonyms is the max. number of pseudonyms a server remembers.
+// Pseudonyms not remembered can still be resolved as long as the
+// identity or key exists.
+const maxPseudonyms = 100_000
+
+// Pseudonym prefixes.
+const (
+	identityPseudonymPrefix = "id-"
+	keyPseudonymPrefix      = "key-"
+)
+
+// pseudonymizer replaces identities and key names in audit records
+// with pseudonyms. A pseudonym is a keyed hash of the name. Hence,
+// the same name always maps to the same pseudonym, and events can
+// be correlated, but only the admin can resolve pseudonyms.
+type pseudonymizer struct {
+	key []byte
+
+	lock  sync.Mutex
+	names map[string]string // pseudonym -> name
+}
+
+// newPseudonymizer returns a new pseudonymizer for the given
+// configuration. If conf contains no key, it uses the key stored
+// at the key store and generates one if none exists.
+func newPseudonymizer(ctx context.Context, conf *AuditPseudonymizationConfig, store KeyStore) (*pseudonymizer, error) {
+	key := conf.Key
+	if len(key) == 0 {
+		var err error
+		if key, err = loadAuditPseudonymKey(ctx, store); err != nil {
+			return nil, err
+		}
+	}
+	return &pseudonymizer{
+		key:   key,
+		names: map[string]string{},
+	}, nil
+}
+
+// loadAuditPseudonymKey returns the audit pseudonymization key
+// stored at the key store. It generates and stores a new key
+// if none exists.
+func loadAuditPseudonymKey(ctx context.Context, store KeyStore) ([]byte, error) {
+	key, err := store.Get(ctx, auditPseudonymKeyEntry)
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		key = make([]byte, 32)
+		if _, err = rand.Read(key); err != nil {
+			return nil, err
+		}
+
+		// Another KES server may have created the key in
+		// the meantime. Then, use the existing key.
+		if err = store.Create(ctx, auditPseudonymKeyEntry, key); errors.Is(err, kes.ErrKeyExists) {
+			key, err = store.Get(ctx, auditPseudonymKeyEntry)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) < 16 {
+		return nil, errors.New("kes: invalid audit pseudonymization key: key is too short")
+	}
+	return key, nil
+}
+
+// Pseudonym returns the pseudonym of name. The prefix
+// distinguishes identity from key pseudonyms.
+func (p *pseudonymizer) Pseudonym(prefix, name string) string {
+	pseudonym := p.pseudonym(prefix, name)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.names[pseudonym]; ok || len(p.names) < maxPseudonyms {
+		p.names[pseudonym] = name
+	}
+	return pseudonym
+}
+
+// Record returns a copy of r with the identity and, if it is a
+// key or identity, the resource replaced by its pseudonym. The
+// resource and identity are also replaced within the message
+// if they are quoted.
+func (p *pseudonymizer) Record(r AuditRecord, resource string) AuditRecord {
+	if !r.Identity.IsUnknown() {
+		pseudonym := p.Pseudonym(identityPseudonymPrefix, r.Identity.String())
+		r.Message = strings.ReplaceAll(r.Message, "'"+r.Identity.String()+"'", "'"+pseudonym+"'")
+		r.Identity = kes.Identity(pseudonym)
+	}
+	if resource == "" || !strings.HasSuffix(r.Path, resource) {
+		return r
+	}
+
+	var prefix string
+	switch path := r.Path[min(len(r.Path), len("/v1/")):]; {
+	case strings.HasPrefix(path, "key/"), strings.HasPrefix(path, "merkle/proof/"):
+		prefix = keyPseudonymPrefix
+	case strings.HasPrefix(path, "identity/"):
+		prefix = identityPseudonymPrefix
+	default:
+		return r
+	}
+	pseudonym := p.Pseudonym(prefix, resource)
+	r.Path = strings.TrimSuffix(r.Path, resource) + pseudonym
+	r.Message = strings.ReplaceAll(r.Message, "'"+resource+"'", "'"+pseudonym+"'")
+	return r
+}
+
+// Resolve returns the name of the given pseudonym. It reports
+// whether the pseudonym has been seen by the server.
+func (p *pseudonymizer) Resolve(pseudonym string) (string, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	name, ok := p.names[pseudonym]
+	return name, ok
+}
+
+func (p *pseudonymizer) pseudonym(prefix, name string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(prefix))
+	mac.Write([]byte(name))
+	return prefix + hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// resolvePseudonym returns the identity or key name of a pseudonym
+// in the audit log. Only the admin can resolve pseudonyms.
+//
+// Pseudonyms the server has not seen are resolved by comparing
+// them to the pseudonyms of all identities or keys. Hence, the
+// pseudonyms of deleted keys may not be resolved.
+func (s *Server) resolvePseudonym(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	if req.Identity != state.Admin {
+		resp.Fail(http.StatusForbidden, "resolving audit log pseudonyms requires the admin identity")
+		return
+	}
+	p := state.Audit.pseudonyms.Load()
+	if p == nil {
+		resp.Fail(http.StatusNotFound, "audit log pseudonymization is not enabled")
+		return
+	}
+
+	pseudonym := req.Resource
+	reply := func(kind, name string) {
+		api.ReplyWith(resp, http.StatusOK, api.ResolvePseudonymResponse{
+			Pseudonym: pseudonym,
+			Type:      kind,
+			Name:      name,
+		})
+	}
+
+	var kind string
+	switch {
+	case strings.HasPrefix(pseudonym, identityPseudonymPrefix):
+		kind = "identity"
+	case strings.HasPrefix(pseudonym, keyPseudonymPrefix):
+		kind = "key"
+	default:
+		resp.Failf(http.StatusBadRequest, "invalid pseudonym '%s'", pseudonym)
+		return
+	}
+	if name, ok := p.Resolve(pseudonym); ok {
+		reply(kind, name)
+		return
+	}
+
+	if kind == "identity" {
+		if p.pseudonym(identityPseudonymPrefix, state.Admin.String()) == pseudonym {
+			reply(kind, state.Admin.String())
+			return
+		}
+		for identity := range state.Identities {
+			if p.pseudonym(identityPseudonymPrefix, identity.String()) == pseudonym {
+				reply(kind, identity.String())
+				return
+			}
+		}
+		resp.Failf(http.StatusNotFound, "pseudonym '%s' not found", pseudonym)
+		return
+	}
+
+	names, _, err := state.Keys.List(req.Context(), "", -1)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to list keys")
+		return
+	}
+	for _, name := range names {
+		if p.pseudonym(keyPseudonymPrefix, name) == pseudonym {
+			reply(kind, name)
+			return
+		}
+	}
+	resp.Failf(http.StatusNotFound, "pseudonym '%s' not found", pseudonym)
+}
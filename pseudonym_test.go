@@ -0,0 +1,135 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/minio/kes/internal/api"
+)
+
+func TestAuditPseudonymization(t *testing.T) {
+	t.Parallel()
+
+	audit := &recordAudit{}
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		AuditLog:              audit,
+		AuditPseudonymization: &AuditPseudonymizationConfig{},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	if err := client.CreateKey(ctx, "secret-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	records := audit.Records()
+	if len(records) != 1 {
+		t.Fatalf("Got %d audit records - want 1", len(records))
+	}
+	r := records[0]
+	if strings.Contains(r.Path, "secret-key") || strings.Contains(r.Message, "secret-key") {
+		t.Fatalf("Audit record contains key name: path '%s' - message '%s'", r.Path, r.Message)
+	}
+	if strings.Contains(r.Message, defaultIdentity) || r.Identity == defaultIdentity {
+		t.Fatalf("Audit record contains identity '%s'", defaultIdentity)
+	}
+
+	for i, test := range auditPseudonymTests {
+		pseudonym := test.Pseudonym
+		switch pseudonym {
+		case "<key>":
+			pseudonym = strings.TrimPrefix(r.Path, api.PathKeyCreate)
+		case "<identity>":
+			pseudonym = r.Identity.String()
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+api.PathLogAuditPseudonym+pseudonym, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Test %d: failed to send request: %v", i, err)
+		}
+		if resp.StatusCode != test.StatusCode {
+			resp.Body.Close()
+			t.Fatalf("Test %d: status code mismatch: got '%d' - want '%d'", i, resp.StatusCode, test.StatusCode)
+		}
+		if test.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			continue
+		}
+
+		var body api.ResolvePseudonymResponse
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("Test %d: failed to decode response: %v", i, err)
+		}
+		if body.Name != test.Name {
+			t.Fatalf("Test %d: name mismatch: got '%s' - want '%s'", i, body.Name, test.Name)
+		}
+	}
+}
+
+func TestPseudonymizerResolve(t *testing.T) {
+	p := &pseudonymizer{key: make([]byte, 32), names: map[string]string{}}
+
+	a, b := p.pseudonym(keyPseudonymPrefix, "my-key"), p.pseudonym(identityPseudonymPrefix, "my-key")
+	if a == b {
+		t.Fatal("Key and identity pseudonyms of the same name are equal")
+	}
+	if _, ok := p.Resolve(a); ok {
+		t.Fatal("Resolved pseudonym that has not been seen")
+	}
+	if p.Pseudonym(keyPseudonymPrefix, "my-key") != a {
+		t.Fatal("Pseudonym is not deterministic")
+	}
+	if name, ok := p.Resolve(a); !ok || name != "my-key" {
+		t.Fatalf("Failed to resolve pseudonym: got '%s' - want '%s'", name, "my-key")
+	}
+}
+
+var auditPseudonymTests = []struct {
+	Pseudonym  string
+	StatusCode int
+	Name       string
+}{
+	{Pseudonym: "<key>", StatusCode: http.StatusOK, Name: "secret-key"},                  // 0
+	{Pseudonym: "<identity>", StatusCode: http.StatusOK, Name: defaultIdentity},          // 1
+	{Pseudonym: "key-00000000000000000000000000000000", StatusCode: http.StatusNotFound}, // 2
+	{Pseudonym: "secret-key", StatusCode: http.StatusBadRequest},                         // 3
+}
+
+// recordAudit is an AuditHandler that keeps all audit records.
+type recordAudit struct {
+	lock    sync.Mutex
+	records []AuditRecord
+}
+
+func (*recordAudit) Enabled(context.Context, slog.Level) bool { return true }
+
+func (a *recordAudit) Handle(_ context.Context, r AuditRecord) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.records = append(a.records, r)
+	return nil
+}
+
+func (a *recordAudit) Records() []AuditRecord {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return append([]AuditRecord(nil), a.records...)
+}
@@ -0,0 +1,358 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+// Default identity renewal limits.
+const (
+	defaultRenewalWindow = 30 * 24 * time.Hour
+	defaultRenewalTTL    = 90 * 24 * time.Hour
+)
+
+// identityAliasPrefix is the prefix of identity alias entries at
+// the key store. It is followed by the identity of the renewed
+// certificate. Like locks, aliases never collide with keys.
+const identityAliasPrefix = "-identityalias-"
+
+// identityAliasInterval is the interval in which the server
+// reloads identity aliases created by other servers.
+const identityAliasInterval = 1 * time.Minute
+
+// pkiRenewal controls how clients can renew their certificates.
+type pkiRenewal struct {
+	window time.Duration
+	ttl    time.Duration
+}
+
+// newPKIRenewal returns the renewal limits for the given
+// configuration, or nil if conf is nil.
+func newPKIRenewal(conf *PKIRenewalConfig) *pkiRenewal {
+	if conf == nil {
+		return nil
+	}
+	r := &pkiRenewal{
+		window: conf.Window,
+		ttl:    conf.TTL,
+	}
+	if r.window <= 0 {
+		r.window = defaultRenewalWindow
+	}
+	if r.ttl <= 0 {
+		r.ttl = defaultRenewalTTL
+	}
+	return r
+}
+
+// identityAlias maps the identity of a renewed certificate to
+// the identity of the original certificate.
+type identityAlias struct {
+	Identity  kes.Identity `json:"identity"`
+	Serial    string       `json:"serial"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
+
+// identityAliases maps the identities of renewed certificates to
+// the identities of the certificates they replace. Hence, a client
+// keeps its identity, and policy, when renewing its certificate
+// with a new private key.
+type identityAliases struct {
+	lock    sync.RWMutex
+	aliases map[kes.Identity]identityAlias
+}
+
+// Resolve returns the identity the given identity is an alias
+// of. It returns identity itself if it is no or an expired alias.
+func (a *identityAliases) Resolve(identity kes.Identity) kes.Identity {
+	if a == nil {
+		return identity
+	}
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	if alias, ok := a.aliases[identity]; ok && time.Now().Before(alias.ExpiresAt) {
+		return alias.Identity
+	}
+	return identity
+}
+
+// Set adds the alias for the given identity.
+func (a *identityAliases) Set(identity kes.Identity, alias identityAlias) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.aliases == nil {
+		a.aliases = map[kes.Identity]identityAlias{}
+	}
+	a.aliases[identity] = alias
+}
+
+// Prune removes all aliases of identities that have no policy
+// assigned anymore. Otherwise, a renewed certificate would regain
+// access once its original identity gets a policy assigned again.
+func (a *identityAliases) Prune(identities map[kes.Identity]identityEntry) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for identity, alias := range a.aliases {
+		if _, ok := identities[alias.Identity]; !ok {
+			delete(a.aliases, identity)
+		}
+	}
+}
+
+// Replace replaces all aliases.
+func (a *identityAliases) Replace(aliases map[kes.Identity]identityAlias) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.aliases = aliases
+}
+
+// loadIdentityAliases returns all identity aliases stored at the
+// key store. It removes expired aliases and aliases of identities
+// without a policy from the key store.
+func loadIdentityAliases(ctx context.Context, store KeyStore, identities map[kes.Identity]identityEntry) (map[kes.Identity]identityAlias, error) {
+	names, _, err := store.List(ctx, identityAliasPrefix, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	aliases := make(map[kes.Identity]identityAlias, len(names))
+	for _, name := range names {
+		identity, ok := strings.CutPrefix(name, identityAliasPrefix)
+		if !ok {
+			continue
+		}
+		b, err := store.Get(ctx, name)
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var alias identityAlias
+		if err = json.Unmarshal(b, &alias); err != nil {
+			return nil, fmt.Errorf("kes: invalid identity alias '%s': %v", identity, err)
+		}
+		if _, ok := identities[alias.Identity]; !ok || !now.Before(alias.ExpiresAt) {
+			if err = store.Delete(ctx, name); err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+				return nil, err
+			}
+			continue
+		}
+		aliases[kes.Identity(identity)] = alias
+	}
+	return aliases, nil
+}
+
+// startIdentityAliasLoader loads the identity aliases from the
+// key store periodically until ctx is canceled. Hence, a client
+// can use its renewed certificate with all servers of a cluster.
+func (s *Server) startIdentityAliasLoader(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(identityAliasInterval)
+		defer ticker.Stop()
+
+		for {
+			if state := s.state.Load(); state.PKI.renewal != nil {
+				aliases, err := loadIdentityAliases(ctx, state.Keys.store, state.Identities)
+				if err == nil {
+					state.Aliases.Replace(aliases)
+				} else if ctx.Err() == nil {
+					state.Log.WarnContext(ctx, fmt.Sprintf("failed to load identity aliases: %v", err))
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// renewIdentity issues a new client certificate, signed by the KES
+// PKI CA, for the public key of a CSR. Requests of the new certificate
+// are treated as requests of the client's identity.
+//
+// A client can renew its certificate once it expires within the
+// renewal window only. The admin identity cannot be renewed.
+func (s *Server) renewIdentity(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	renewal := state.PKI.renewal
+	if renewal == nil {
+		resp.Fail(http.StatusNotFound, "identity renewal is not enabled")
+		return
+	}
+	if req.Identity == state.Admin {
+		resp.Fail(http.StatusForbidden, "the admin identity cannot be renewed")
+		return
+	}
+
+	var cert *x509.Certificate
+	for _, c := range req.TLS.PeerCertificates {
+		if !c.IsCA {
+			cert = c
+			break
+		}
+	}
+	if cert == nil {
+		resp.Fail(http.StatusBadRequest, "tls: client certificate is required")
+		return
+	}
+	if renewableAt := cert.NotAfter.Add(-renewal.window); time.Now().Before(renewableAt) {
+		resp.Failf(http.StatusConflict, "certificate cannot be renewed before %s", renewableAt.UTC().Format(time.RFC3339))
+		return
+	}
+
+	var body api.RenewIdentityRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid renewal request body")
+		return
+	}
+	block, _ := pem.Decode([]byte(body.CSR))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		resp.Fail(http.StatusBadRequest, "invalid CSR: no PEM-encoded certificate request found")
+		return
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		resp.Failf(http.StatusBadRequest, "invalid CSR: %v", err)
+		return
+	}
+	if err = csr.CheckSignature(); err != nil {
+		resp.Failf(http.StatusBadRequest, "invalid CSR: %v", err)
+		return
+	}
+	switch pub := csr.PublicKey.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			resp.Fail(http.StatusBadRequest, "invalid CSR: RSA keys must be at least 2048 bits")
+			return
+		}
+	default:
+		resp.Fail(http.StatusBadRequest, "invalid CSR: unsupported public key type")
+		return
+	}
+
+	h := sha256.Sum256(csr.RawSubjectPublicKeyInfo)
+	renewed := kes.Identity(hex.EncodeToString(h[:]))
+	if renewed == req.Identity || state.Aliases.Resolve(renewed) != renewed {
+		resp.Fail(http.StatusBadRequest, "invalid CSR: public key has already been used")
+		return
+	}
+	if _, ok := state.Identities[renewed]; ok || renewed == state.Admin {
+		resp.Fail(http.StatusBadRequest, "invalid CSR: public key has already been used")
+		return
+	}
+
+	ca, err := loadPKICA(req.Context(), state.Keys.store)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to load PKI CA")
+		return
+	}
+	serial, err := pkiSerial()
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to renew certificate")
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	notAfter := now.Add(renewal.ttl)
+	if notAfter.After(ca.Cert.NotAfter) {
+		notAfter = ca.Cert.NotAfter
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cert.Subject.CommonName},
+		NotBefore:    now.Add(-1 * time.Minute),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if state.PKI.url != "" {
+		template.OCSPServer = []string{state.PKI.url + api.PathPKIOCSP}
+		template.CRLDistributionPoints = []string{state.PKI.url + api.PathPKICRL}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, csr.PublicKey, ca.Key)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to renew certificate")
+		return
+	}
+
+	alias := identityAlias{
+		Identity:  req.Identity,
+		Serial:    hex.EncodeToString(serial.Bytes()),
+		ExpiresAt: notAfter,
+	}
+	b, err := json.Marshal(alias)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to renew certificate")
+		return
+	}
+	if err = state.Keys.store.Create(req.Context(), identityAliasPrefix+renewed.String(), b); err != nil {
+		if errors.Is(err, kes.ErrKeyExists) {
+			resp.Fail(http.StatusBadRequest, "invalid CSR: public key has already been used")
+			return
+		}
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to store identity alias")
+		return
+	}
+	state.Aliases.Set(renewed, alias)
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(
+		fmt.Sprintf("identity '%s' renewed: certificate '%s' issued for identity '%s'", req.Identity, alias.Serial, renewed),
+		StatusOK,
+		req,
+	)
+	api.ReplyWith(resp, StatusOK, api.RenewIdentityResponse{
+		Identity:    renewed.String(),
+		Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		CAChain:     string(ca.Chain),
+		Serial:      alias.Serial,
+		ExpiresAt:   notAfter,
+	})
+}
@@ -0,0 +1,220 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	xhttp "github.com/minio/kes/internal/http"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestRenewIdentity(t *testing.T) {
+	t.Parallel()
+
+	cert, key := newRenewalCertificate(t, 10*24*time.Hour)
+	identity := kes.Identity(renewalIdentity(cert.Leaf))
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		Policies: map[string]Policy{
+			"app": {
+				Allow:      map[string]kes.Rule{api.PathKeyCreate + "*": {}},
+				Identities: []kes.Identity{identity},
+			},
+		},
+		PKI: &PKIConfig{
+			Renewal: &PKIRenewalConfig{Window: 30 * 24 * time.Hour, TTL: 90 * 24 * time.Hour},
+		},
+	})
+	defer srv.Close()
+
+	renew := func(client *kes.Client, csrKey ed25519.PrivateKey) *http.Response {
+		csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject: pkix.Name{CommonName: "app"},
+		}, csrKey)
+		if err != nil {
+			t.Fatalf("Failed to create CSR: %v", err)
+		}
+		body, _ := json.Marshal(api.RenewIdentityRequest{
+			CSR: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})),
+		})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, url+api.PathIdentitySelfRenew, bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		return resp
+	}
+
+	_, newKey, _ := ed25519.GenerateKey(rand.Reader)
+	if resp := renew(defaultClient(url), newKey); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Renewing the admin identity should fail with '%d' - got '%d'", http.StatusForbidden, resp.StatusCode)
+	}
+
+	client := renewalClient(url, cert)
+	if resp := renew(client, key); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Renewing with the same private key should fail with '%d' - got '%d'", http.StatusBadRequest, resp.StatusCode)
+	}
+	resp := renew(client, newKey)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to renew certificate: got status '%d'", resp.StatusCode)
+	}
+	var renewed api.RenewIdentityResponse
+	if err := json.NewDecoder(resp.Body).Decode(&renewed); err != nil {
+		t.Fatalf("Failed to decode renewal response: %v", err)
+	}
+	resp.Body.Close()
+
+	block, _ := pem.Decode([]byte(renewed.Certificate))
+	if block == nil {
+		t.Fatal("Renewed certificate is not PEM-encoded")
+	}
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse renewed certificate: %v", err)
+	}
+	if id := renewalIdentity(leaf); id != renewed.Identity {
+		t.Fatalf("Identity mismatch: got '%s' - want '%s'", renewed.Identity, id)
+	}
+	if leaf.Subject.CommonName != cert.Leaf.Subject.CommonName {
+		t.Fatalf("Common name mismatch: got '%s' - want '%s'", leaf.Subject.CommonName, cert.Leaf.Subject.CommonName)
+	}
+
+	client = renewalClient(url, tls.Certificate{
+		Certificate: [][]byte{block.Bytes},
+		PrivateKey:  newKey,
+		Leaf:        leaf,
+	})
+	if err = client.CreateKey(ctx, "app-key"); err != nil {
+		t.Fatalf("Renewed certificate does not keep the policy of the original identity: %v", err)
+	}
+	_, nextKey, _ := ed25519.GenerateKey(rand.Reader)
+	if resp = renew(client, nextKey); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Renewing before the renewal window should fail with '%d' - got '%d'", http.StatusConflict, resp.StatusCode)
+	}
+
+	unknown, _ := newRenewalCertificate(t, 10*24*time.Hour)
+	if resp = renew(renewalClient(url, unknown), nextKey); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Renewing an unknown identity should fail with '%d' - got '%d'", http.StatusForbidden, resp.StatusCode)
+	}
+
+	// Once the original identity loses its policy, the renewed
+	// certificate must not regain access when the policy is
+	// assigned again.
+	policy := Policy{
+		Allow:      map[string]kes.Rule{api.PathKeyCreate + "*": {}},
+		Identities: []kes.Identity{identity},
+	}
+	if err = srv.UpdatePolicies(map[string]Policy{}); err != nil {
+		t.Fatalf("Failed to update policies: %v", err)
+	}
+	if err = srv.UpdatePolicies(map[string]Policy{"app": policy}); err != nil {
+		t.Fatalf("Failed to update policies: %v", err)
+	}
+	if err = client.CreateKey(ctx, "app-key-2"); err == nil {
+		t.Fatal("Renewed certificate regained access after its identity lost its policy")
+	}
+}
+
+func TestLoadIdentityAliases(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	store := &MemKeyStore{}
+	identities := map[kes.Identity]identityEntry{
+		"my-app": {Name: "app", Policy: &kes.Policy{}},
+	}
+	for renewed, alias := range map[string]identityAlias{
+		"renewed":         {Identity: "my-app", ExpiresAt: time.Now().Add(time.Hour)},
+		"renewed-expired": {Identity: "my-app", ExpiresAt: time.Now().Add(-time.Hour)},
+		"renewed-removed": {Identity: "other-app", ExpiresAt: time.Now().Add(time.Hour)},
+	} {
+		b, _ := json.Marshal(alias)
+		if err := store.Create(ctx, identityAliasPrefix+renewed, b); err != nil {
+			t.Fatalf("Failed to create identity alias: %v", err)
+		}
+	}
+
+	aliases, err := loadIdentityAliases(ctx, store, identities)
+	if err != nil {
+		t.Fatalf("Failed to load identity aliases: %v", err)
+	}
+	if len(aliases) != 1 || aliases["renewed"].Identity != "my-app" {
+		t.Fatalf("Invalid identity aliases: got '%v'", aliases)
+	}
+	if names, _, _ := store.List(ctx, identityAliasPrefix, -1); len(names) != 1 || names[0] != identityAliasPrefix+"renewed" {
+		t.Fatalf("Invalid identity aliases at key store: got '%v'", names)
+	}
+
+	var a identityAliases
+	a.Replace(aliases)
+	if id := a.Resolve("renewed"); id != "my-app" {
+		t.Fatalf("Failed to resolve identity alias: got '%s' - want '%s'", id, "my-app")
+	}
+	a.Prune(map[kes.Identity]identityEntry{})
+	if id := a.Resolve("renewed"); id != "renewed" {
+		t.Fatalf("Resolved identity alias of identity without policy: got '%s'", id)
+	}
+}
+
+func newRenewalCertificate(t *testing.T, ttl time.Duration) (tls.Certificate, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "app"},
+		NotBefore:    time.Now().Add(-1 * time.Minute),
+		NotAfter:     time.Now().Add(ttl),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv, Leaf: leaf}, priv
+}
+
+func renewalClient(endpoint string, cert tls.Certificate) *kes.Client {
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(defaultServerCertificate().Leaf)
+
+	client := kes.NewClientWithConfig(endpoint, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    rootCAs,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &cert, nil
+		},
+	})
+	client.HTTPClient.Transport = xhttp.LegacyErrors{RoundTripper: client.HTTPClient.Transport}
+	return client
+}
+
+func renewalIdentity(cert *x509.Certificate) string {
+	identity, _ := identifyRequest(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}})
+	return identity.String()
+}
@@ -0,0 +1,298 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/headers"
+)
+
+// redacted replaces the values of sensitive request
+// headers, query parameters and JSON fields.
+const redacted = "[REDACTED]"
+
+// maxRequestLogBody is the max. size of a request body
+// captured by the request log. Larger bodies are not
+// logged.
+const maxRequestLogBody = 64 * 1024
+
+// sensitiveFields are the names of request headers, query
+// parameters and JSON fields that are always redacted. They
+// contain plaintexts, ciphertexts, key material, credentials
+// or values that have to be tokenized.
+var sensitiveFields = []string{
+	"authorization",
+	"proxy-authorization",
+	"cookie",
+
+	"plaintext",
+	"ciphertext",
+	"key",
+	"encrypted_key",
+	"context",
+	"message",
+	"values",
+	"tweak",
+	"token",
+	"private_key",
+	"password",
+	"secret",
+}
+
+// requestLogger logs API requests and responses for debugging
+// purposes. Unlike the audit log, it logs request headers and,
+// optionally, request bodies - with sensitive values redacted.
+type requestLogger struct {
+	handler    slog.Handler
+	sampleRate float64
+	body       bool
+	redact     map[string]bool
+}
+
+// newRequestLogger returns a new request logger for the given
+// configuration, or nil if conf is nil.
+func newRequestLogger(conf *RequestLogConfig) *requestLogger {
+	if conf == nil {
+		return nil
+	}
+
+	l := &requestLogger{
+		handler:    conf.Handler,
+		sampleRate: conf.SampleRate,
+		body:       conf.Body,
+		redact:     make(map[string]bool, len(sensitiveFields)+len(conf.Redact)),
+	}
+	if l.handler == nil {
+		l.handler = slog.NewJSONHandler(os.Stderr, nil)
+	}
+	if l.sampleRate <= 0 || l.sampleRate > 1 {
+		l.sampleRate = 1
+	}
+	for _, name := range slices.Concat(sensitiveFields, conf.Redact) {
+		l.redact[strings.ToLower(name)] = true
+	}
+	return l
+}
+
+// logRequest returns an http.Handler that wraps h and logs
+// each request, if the server has a request log.
+//
+// Requests are sampled based on the configured sample rate.
+// However, failed requests are always logged, if necessary
+// without their request body.
+func (s *Server) logRequest(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := s.state.Load().RequestLog
+		if log == nil || !log.handler.Enabled(r.Context(), slog.LevelInfo) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		sampled := log.sampleRate >= 1 || rand.Float64() < log.sampleRate
+		var body *bodyRecorder
+		if sampled && log.body && r.Body != nil {
+			body = &bodyRecorder{ReadCloser: r.Body}
+			r.Body = body
+		}
+
+		// Handlers may modify the request, e.g. rewrite the
+		// URL path of v2 API requests. Hence, we capture the
+		// request as received.
+		method, path, query := r.Method, r.URL.Path, r.URL.Query()
+		rw := &statusResponseWriter{ResponseWriter: w}
+		start := time.Now()
+		h.ServeHTTP(rw, r)
+
+		if !sampled && rw.Status() < 400 {
+			return
+		}
+		log.log(r.Context(), r, rw.Status(), time.Since(start), method, path, query, body)
+	})
+}
+
+// log passes a request log record to the logger's handler.
+func (l *requestLogger) log(ctx context.Context, r *http.Request, status int, elapsed time.Duration, method, path string, query map[string][]string, body *bodyRecorder) {
+	identity, _ := identifyRequest(r.TLS)
+	remoteIP, _ := netip.ParseAddrPort(r.RemoteAddr)
+
+	req := []slog.Attr{
+		slog.String("method", method),
+		slog.String("path", path),
+		slog.String("ip", remoteIP.Addr().String()),
+		slog.String("identity", identity.String()),
+	}
+	if len(query) > 0 {
+		attrs := make([]slog.Attr, 0, len(query))
+		for name, values := range query {
+			attrs = append(attrs, slog.String(name, l.redactValue(name, strings.Join(values, ","))))
+		}
+		req = append(req, slog.Attr{Key: "query", Value: slog.GroupValue(attrs...)})
+	}
+	if len(r.Header) > 0 {
+		attrs := make([]slog.Attr, 0, len(r.Header))
+		for name, values := range r.Header {
+			attrs = append(attrs, slog.String(name, l.redactValue(name, strings.Join(values, ","))))
+		}
+		req = append(req, slog.Attr{Key: "header", Value: slog.GroupValue(attrs...)})
+	}
+	if body != nil {
+		if v, ok := l.redactBody(r.Header, body); ok {
+			req = append(req, slog.String("body", v))
+		}
+	}
+
+	rec := slog.NewRecord(time.Now(), slog.LevelInfo, "request", 0)
+	rec.AddAttrs(
+		slog.Attr{Key: "req", Value: slog.GroupValue(req...)},
+		slog.Attr{Key: "res", Value: slog.GroupValue(
+			slog.Int("code", status),
+			slog.Duration("time", elapsed),
+		)},
+	)
+	l.handler.Handle(ctx, rec)
+}
+
+// redactValue returns the value of the named header or
+// query parameter, or a placeholder if it is sensitive.
+func (l *requestLogger) redactValue(name, value string) string {
+	if l.redact[strings.ToLower(name)] {
+		return redacted
+	}
+	return value
+}
+
+// redactBody returns the recorded request body as JSON with
+// all sensitive fields redacted. It returns false if the body
+// is empty, too large or not a JSON object or array. Request
+// bodies that are not JSON are never logged.
+func (l *requestLogger) redactBody(h http.Header, body *bodyRecorder) (string, bool) {
+	if body.overflow || body.buf.Len() == 0 {
+		return "", false
+	}
+	if ct := h.Get(headers.ContentType); ct != "" && !strings.HasPrefix(ct, headers.ContentTypeJSON) {
+		return "", false
+	}
+
+	var v any
+	if err := json.Unmarshal(body.buf.Bytes(), &v); err != nil {
+		return "", false
+	}
+	switch v.(type) {
+	case map[string]any, []any:
+	default:
+		return "", false
+	}
+
+	b, err := json.Marshal(l.redactJSON(v))
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// redactJSON replaces the values of all sensitive fields
+// within the JSON value v, including nested objects.
+func (l *requestLogger) redactJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for name, value := range v {
+			if l.redact[strings.ToLower(name)] {
+				v[name] = redacted
+			} else {
+				v[name] = l.redactJSON(value)
+			}
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = l.redactJSON(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// bodyRecorder is an io.ReadCloser that records up to
+// maxRequestLogBody bytes read from the request body.
+type bodyRecorder struct {
+	io.ReadCloser
+
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (b *bodyRecorder) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && !b.overflow {
+		if b.buf.Len()+n > maxRequestLogBody {
+			b.overflow = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+// statusResponseWriter is an http.ResponseWriter that
+// records the response status code.
+type statusResponseWriter struct {
+	http.ResponseWriter
+
+	status int
+}
+
+var ( // compiler checks
+	_ http.ResponseWriter = (*statusResponseWriter)(nil)
+	_ http.Flusher        = (*statusResponseWriter)(nil)
+)
+
+// Unwrap returns the underlying ResponseWriter.
+//
+// This method is mainly used in the context of ResponseController.
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client.
+func (w *statusResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Status returns the response status code. If no status
+// code has been sent, it returns 200 OK.
+func (w *statusResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
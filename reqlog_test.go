@@ -0,0 +1,129 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+)
+
+func TestRequestLog(t *testing.T) {
+	t.Parallel()
+
+	records := &requestLogRecorder{}
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		RequestLog: &RequestLogConfig{
+			Handler: slog.NewJSONHandler(records, nil),
+			Body:    true,
+			Redact:  []string{"X-Custom-Secret"},
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	plaintext := []byte("my-secret-plaintext")
+	body := `{"plaintext":"` + base64.StdEncoding.EncodeToString(plaintext) + `","version":"v1"}`
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url+api.PathKeyEncrypt+"my-key", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("X-Custom-Secret", "my-custom-secret")
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	resp.Body.Close()
+
+	log := records.Wait(t, api.PathKeyEncrypt+"my-key")
+	for _, secret := range []string{base64.StdEncoding.EncodeToString(plaintext), "my-custom-secret"} {
+		if strings.Contains(log, secret) {
+			t.Fatalf("Request log contains secret '%s': %s", secret, log)
+		}
+	}
+	for _, s := range []string{`\"plaintext\":\"[REDACTED]\"`, `\"version\":\"v1\"`, `"X-Custom-Secret":"[REDACTED]"`} {
+		if !strings.Contains(log, s) {
+			t.Fatalf("Request log does not contain '%s': %s", s, log)
+		}
+	}
+}
+
+func TestRequestLogSampling(t *testing.T) {
+	t.Parallel()
+
+	records := &requestLogRecorder{}
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		RequestLog: &RequestLogConfig{
+			Handler:    slog.NewJSONHandler(records, nil),
+			SampleRate: 1e-12,
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if _, err := client.DescribeKey(ctx, "does-not-exist"); err == nil {
+		t.Fatal("Described non-existing key")
+	}
+
+	records.Wait(t, api.PathKeyDescribe+"does-not-exist")
+	if log := records.String(); strings.Contains(log, api.PathKeyCreate) {
+		t.Fatalf("Request log contains unsampled successful request: %s", log)
+	}
+}
+
+// requestLogRecorder records request log output.
+type requestLogRecorder struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (r *requestLogRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.Write(p)
+}
+
+func (r *requestLogRecorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.String()
+}
+
+// Wait waits until a request for path has been logged and
+// returns the corresponding log line.
+func (r *requestLogRecorder) Wait(t *testing.T, path string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for {
+		for _, line := range strings.Split(r.String(), "\n") {
+			if strings.Contains(line, `"path":"`+path+`"`) {
+				return line
+			}
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Request log does not contain a request for '%s': %s", path, r.String())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+// rewrapKey imports a data encryption key (DEK) that has been wrapped
+// by an external HSM or key management system with a shared key
+// encryption key (KEK). The shared KEK must have been imported as an
+// AES-256 KES key before.
+//
+// The DEK is unwrapped with the shared KEK, which verifies its
+// integrity, and encrypted with the key named in the request path.
+// The response contains the ciphertext, as returned by the GenerateKey
+// API, but never the DEK itself. Hence, data encrypted before KES
+// existed can be decrypted with DEKs managed by KES.
+//
+// Clients must be allowed to decrypt with the shared KEK in addition
+// to re-wrapping with the named key.
+func (s *Server) rewrapKey(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	var body api.RewrapKeyRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !validName(body.KEK) {
+		resp.Failf(http.StatusBadRequest, "KEK name '%s' is empty, too long or contains invalid characters", body.KEK)
+		return
+	}
+	algorithm, err := crypto.ParseKeyWrap(body.Algorithm)
+	if err != nil {
+		resp.Failf(http.StatusBadRequest, "key wrapping algorithm '%s' is not supported", body.Algorithm)
+		return
+	}
+	if err = s.checkFence(req); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to read key reservation")
+		return
+	}
+
+	state := s.state.Load()
+	if err := checkUnwrap(state, req, body.KEK); err != nil {
+		resp.Failr(err)
+		return
+	}
+	kek, err := state.Keys.Use(req.Context(), body.KEK)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to read key")
+		return
+	}
+	state.Usage.UseKey(body.KEK)
+	if kek.Key.Type() != crypto.AES256 {
+		resp.Failf(http.StatusBadRequest, "KEK '%s' is not an AES256 key", body.KEK)
+		return
+	}
+
+	dek, err := algorithm.Unwrap(kek.Key.Bytes(), body.WrappedKey)
+	if err != nil {
+		resp.Failf(http.StatusBadRequest, "failed to unwrap key with KEK '%s'", body.KEK)
+		return
+	}
+	defer clear(dek)
+
+	switch len(dek) {
+	case 16, 24, 32:
+	default:
+		resp.Failf(http.StatusBadRequest, "invalid key length '%d': must be 16, 24 or 32 bytes", len(dek))
+		return
+	}
+
+	key, err := state.Keys.Use(req.Context(), req.Resource)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to read key")
+		return
+	}
+	state.Usage.UseKey(req.Resource)
+
+	ciphertext, err := key.Key.Encrypt(dek, body.Context)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to encrypt key")
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(fmt.Sprintf("key wrapped with '%s' rewrapped with secret key '%s'", body.KEK, req.Resource), StatusOK, req)
+	api.ReplyWith(resp, StatusOK, api.RewrapKeyResponse{
+		Ciphertext: ciphertext,
+	})
+}
+
+// checkUnwrap returns an error if the request's identity is not
+// allowed to unwrap keys with the named KEK. Unwrapping requires
+// the same permissions as decrypting with the KEK.
+func checkUnwrap(state *serverState, req *api.Request, kek string) api.Error {
+	r, err := http.NewRequestWithContext(req.Context(), http.MethodPut, api.PathKeyDecrypt+kek, http.NoBody)
+	if err != nil {
+		return api.NewError(http.StatusBadRequest, fmt.Sprintf("KEK name '%s' is invalid", kek))
+	}
+	if req.Identity != state.Admin {
+		policy, ok := state.Identities[req.Identity]
+		if !ok {
+			return kes.ErrNotAllowed
+		}
+		if err := policy.Verify(r); err != nil && !state.Folders.AllowsRequest(r, state.Keys, policy.Name, policy.Policy) {
+			return kes.ErrNotAllowed
+		}
+	}
+	return state.Lifecycle.Check(r, time.Now())
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestRewrapKey(t *testing.T) {
+	t.Parallel()
+
+	appCert, _ := newRenewalCertificate(t, time.Hour)
+	appIdentity := renewalIdentity(appCert.Leaf)
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		Keys: &MemKeyStore{},
+		Policies: map[string]Policy{
+			"my-app": {
+				Allow: map[string]kes.Rule{
+					api.PathKeyRewrap + "app-*":       {},
+					api.PathKeyDecrypt + "shared-kek": {},
+				},
+				Identities: []kes.Identity{kes.Identity(appIdentity)},
+			},
+		},
+	})
+	defer srv.Close()
+
+	sharedKEK, otherKEK := make([]byte, 32), make([]byte, 32)
+	rand.Read(sharedKEK)
+	rand.Read(otherKEK)
+
+	admin, app := defaultClient(url), renewalClient(url, appCert)
+	for name, kek := range map[string][]byte{"shared-kek": sharedKEK, "other-kek": otherKEK} {
+		if err := admin.ImportKey(ctx, name, &kes.ImportKeyRequest{Key: kek, Cipher: kes.AES256}); err != nil {
+			t.Fatalf("Failed to import key '%s': %v", name, err)
+		}
+	}
+	if err := admin.CreateKey(ctx, "app-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	rewrap := func(client *kes.Client, name string, body api.RewrapKeyRequest) ([]byte, int) {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Failed to encode request: %v", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, url+api.PathKeyRewrap+name, bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var result api.RewrapKeyResponse
+		if resp.StatusCode == http.StatusOK {
+			if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return result.Ciphertext, resp.StatusCode
+	}
+
+	dek := make([]byte, 32)
+	rand.Read(dek)
+	wrapped, err := crypto.AESKeyWrapPad.Wrap(sharedKEK, dek)
+	if err != nil {
+		t.Fatalf("Failed to wrap key: %v", err)
+	}
+	associatedData := []byte("bucket=my-bucket")
+
+	ciphertext, code := rewrap(app, "app-key", api.RewrapKeyRequest{KEK: "shared-kek", Algorithm: "AES-KWP", WrappedKey: wrapped, Context: associatedData})
+	if code != http.StatusOK {
+		t.Fatalf("Failed to rewrap key: status code '%d'", code)
+	}
+	if bytes.Contains(ciphertext, dek) {
+		t.Fatal("Rewrapped key contains the plaintext key")
+	}
+	plaintext, err := admin.Decrypt(ctx, "app-key", ciphertext, associatedData)
+	if err != nil {
+		t.Fatalf("Failed to decrypt rewrapped key: %v", err)
+	}
+	if !bytes.Equal(plaintext, dek) {
+		t.Fatalf("Key mismatch: got '%x' - want '%x'", plaintext, dek)
+	}
+
+	tampered := bytes.Clone(wrapped)
+	tampered[0] ^= 1
+	for i, test := range []struct {
+		Client  *kes.Client
+		Key     string
+		Request api.RewrapKeyRequest
+		Code    int
+	}{
+		{Client: app, Key: "app-key", Request: api.RewrapKeyRequest{KEK: "other-kek", Algorithm: "AES-KWP", WrappedKey: wrapped}, Code: http.StatusForbidden},    // 0: not allowed to use KEK
+		{Client: admin, Key: "app-key", Request: api.RewrapKeyRequest{KEK: "other-kek", Algorithm: "AES-KWP", WrappedKey: wrapped}, Code: http.StatusBadRequest}, // 1: wrong KEK
+		{Client: app, Key: "app-key", Request: api.RewrapKeyRequest{KEK: "shared-kek", Algorithm: "AES-KWP", WrappedKey: tampered}, Code: http.StatusBadRequest}, // 2: modified wrapped key
+		{Client: app, Key: "app-key", Request: api.RewrapKeyRequest{KEK: "shared-kek", Algorithm: "AES-KW", WrappedKey: wrapped}, Code: http.StatusBadRequest},   // 3: wrong algorithm
+		{Client: app, Key: "app-key", Request: api.RewrapKeyRequest{KEK: "shared-kek", Algorithm: "AES-GCM", WrappedKey: wrapped}, Code: http.StatusBadRequest},  // 4: unsupported algorithm
+		{Client: app, Key: "app-key", Request: api.RewrapKeyRequest{KEK: "app-kek", Algorithm: "AES-KWP", WrappedKey: wrapped}, Code: http.StatusForbidden},      // 5: not allowed to use KEK
+		{Client: admin, Key: "app-key", Request: api.RewrapKeyRequest{KEK: "missing-kek", Algorithm: "AES-KWP", WrappedKey: wrapped}, Code: http.StatusNotFound}, // 6: missing KEK
+		{Client: app, Key: "other-key", Request: api.RewrapKeyRequest{KEK: "shared-kek", Algorithm: "AES-KWP", WrappedKey: wrapped}, Code: http.StatusForbidden}, // 7: not allowed to use key
+	} {
+		if _, code := rewrap(test.Client, test.Key, test.Request); code != test.Code {
+			t.Fatalf("Test %d: status code mismatch: got '%d' - want '%d'", i, code, test.Code)
+		}
+	}
+}
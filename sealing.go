@@ -0,0 +1,195 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+// sealedHeader is the prefix of all key store entries sealed
+// to the measurement of the confidential VM or enclave.
+var sealedHeader = []byte("kes\x00sealed\x01")
+
+// sealing seals key store entries with a key derived from
+// the measurement of the confidential VM or enclave.
+type sealing struct {
+	sealer        Sealer
+	key           crypto.SecretKey
+	allowUnsealed bool
+}
+
+// newSealing derives the sealing key of the given configuration's
+// Sealer. It returns nil if conf is nil.
+func newSealing(ctx context.Context, conf *SealingConfig) (*sealing, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	if conf.Sealer == nil {
+		return nil, errors.New("kes: invalid sealing config: no sealer specified")
+	}
+
+	b, err := conf.Sealer.SealingKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("kes: failed to derive sealing key: %v", err)
+	}
+	if len(b) != 32 {
+		return nil, errors.New("kes: failed to derive sealing key: sealing key is not 256 bits long")
+	}
+	key, err := crypto.NewSecretKey(crypto.AES256, b)
+	if err != nil {
+		return nil, err
+	}
+	return &sealing{
+		sealer:        conf.Sealer,
+		key:           key,
+		allowUnsealed: conf.AllowUnsealed,
+	}, nil
+}
+
+// KeyStore returns a KeyStore that seals all entries before
+// storing them at store. It returns store if s is nil.
+func (s *sealing) KeyStore(store KeyStore) KeyStore {
+	if s == nil || store == nil {
+		return store
+	}
+	return &sealedStore{KeyStore: store, sealing: s}
+}
+
+// Tenants returns a copy of the tenant configuration whose
+// KEK stores seal all entries. It returns conf if s is nil.
+func (s *sealing) Tenants(conf map[string]*TenantConfig) map[string]*TenantConfig {
+	if s == nil || len(conf) == 0 {
+		return conf
+	}
+
+	tenants := maps.Clone(conf)
+	for name, c := range tenants {
+		if c.KEKStore != nil {
+			tenants[name] = &TenantConfig{
+				Prefix:   c.Prefix,
+				KEKStore: s.KeyStore(c.KEKStore),
+			}
+		}
+	}
+	return tenants
+}
+
+// sealedStore is a KeyStore that seals entries to the
+// measurement of the confidential VM or enclave.
+type sealedStore struct {
+	KeyStore
+	sealing *sealing
+}
+
+// Create seals the value before creating the entry.
+func (s *sealedStore) Create(ctx context.Context, name string, value []byte) error {
+	value, err := s.seal(name, value)
+	if err != nil {
+		return err
+	}
+	return s.KeyStore.Create(ctx, name, value)
+}
+
+// CanOverwrite reports whether the underlying KeyStore
+// can replace existing entries.
+func (s *sealedStore) CanOverwrite() bool {
+	store, ok := s.KeyStore.(OverwriteKeyStore)
+	return ok && store.CanOverwrite()
+}
+
+// Set seals the value before creating or replacing the entry.
+func (s *sealedStore) Set(ctx context.Context, name string, value []byte) error {
+	value, err := s.seal(name, value)
+	if err != nil {
+		return err
+	}
+	return setEntry(ctx, s.KeyStore, name, value)
+}
+
+// CanSwap reports whether the underlying KeyStore
+// supports conditional writes.
+func (s *sealedStore) CanSwap() bool { return canSwap(s.KeyStore) }
+
+// Swap replaces the value of the entry if its unsealed value is
+// equal to old. Since sealing is not deterministic, it compares
+// the unsealed values and swaps the sealed value it has read.
+func (s *sealedStore) Swap(ctx context.Context, name string, old, value []byte) error {
+	sealed, err := s.KeyStore.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	plaintext, err := s.unseal(name, bytes.Clone(sealed)) // Decryption may modify the ciphertext in place
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(plaintext, old) {
+		return kes.ErrKeyExists
+	}
+	if value, err = s.seal(name, value); err != nil {
+		return err
+	}
+	return swapEntry(ctx, s.KeyStore, name, sealed, value)
+}
+
+// Get returns the unsealed value of the entry. It returns an
+// error if the entry is not sealed, unless unsealed entries
+// are allowed.
+func (s *sealedStore) Get(ctx context.Context, name string) ([]byte, error) {
+	value, err := s.KeyStore.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.unseal(name, value)
+}
+
+// GetBulk returns the unsealed values of the given entries.
+func (s *sealedStore) GetBulk(ctx context.Context, names []string) (map[string][]byte, error) {
+	values, err := getBulk(ctx, s.KeyStore, names)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range values {
+		if values[name], err = s.unseal(name, value); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (s *sealedStore) unseal(name string, value []byte) ([]byte, error) {
+	if !bytes.HasPrefix(value, sealedHeader) {
+		if s.sealing.allowUnsealed {
+			return value, nil
+		}
+		return nil, fmt.Errorf("kes: key store entry '%s' is not sealed", name)
+	}
+
+	plaintext, err := s.sealing.key.Decrypt(value[len(sealedHeader):], sealedAssociatedData(name))
+	if err != nil {
+		return nil, fmt.Errorf("kes: failed to unseal key store entry '%s': %v", name, err)
+	}
+	return plaintext, nil
+}
+
+func (s *sealedStore) seal(name string, value []byte) ([]byte, error) {
+	ciphertext, err := s.sealing.key.Encrypt(value, sealedAssociatedData(name))
+	if err != nil {
+		return nil, err
+	}
+	return append(bytes.Clone(sealedHeader), ciphertext...), nil
+}
+
+// sealedAssociatedData binds a sealed value to its entry
+// name such that entries cannot be swapped.
+func sealedAssociatedData(name string) []byte {
+	return append(bytes.Clone(sealedHeader), "name="+name...)
+}
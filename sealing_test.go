@@ -0,0 +1,144 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/minio/kms-go/kes"
+)
+
+func TestSealedStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	sealing, err := newSealing(ctx, &SealingConfig{Sealer: newFakeSealer("vm-0")})
+	if err != nil {
+		t.Fatalf("Failed to derive sealing key: %v", err)
+	}
+
+	backend := &MemKeyStore{}
+	store := sealing.KeyStore(backend)
+
+	value := []byte("my-secret-key-value")
+	if err = store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", value); !errors.Is(err, kes.ErrKeyExists) {
+		t.Fatalf("Created entry twice: got '%v' - want '%v'", err, kes.ErrKeyExists)
+	}
+	sealed, err := backend.Get(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to get sealed entry: %v", err)
+	}
+	if !bytes.HasPrefix(sealed, sealedHeader) || bytes.Contains(sealed, value) {
+		t.Fatalf("Entry is not sealed: '%x'", sealed)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get entry: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	// Sealed entries cannot be swapped.
+	if err = backend.Create(ctx, "other-key", sealed); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if _, err = store.Get(ctx, "other-key"); err == nil {
+		t.Fatal("Unsealed entry stored under another name")
+	}
+
+	// Another VM, with a different measurement, cannot unseal entries.
+	other, err := newSealing(ctx, &SealingConfig{Sealer: newFakeSealer("vm-1")})
+	if err != nil {
+		t.Fatalf("Failed to derive sealing key: %v", err)
+	}
+	if _, err = other.KeyStore(backend).Get(ctx, "my-key"); err == nil {
+		t.Fatal("Unsealed entry with sealing key of another VM")
+	}
+
+	if err = backend.Create(ctx, "unsealed-key", value); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if _, err = store.Get(ctx, "unsealed-key"); err == nil {
+		t.Fatal("Got unsealed entry")
+	}
+	migration, err := newSealing(ctx, &SealingConfig{Sealer: newFakeSealer("vm-0"), AllowUnsealed: true})
+	if err != nil {
+		t.Fatalf("Failed to derive sealing key: %v", err)
+	}
+	if v, err := migration.KeyStore(backend).Get(ctx, "unsealed-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get unsealed entry: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	if err = setEntry(ctx, store, "my-key", []byte("new-value")); err != nil {
+		t.Fatalf("Failed to overwrite entry: %v", err)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || string(v) != "new-value" {
+		t.Fatalf("Failed to get overwritten entry: got '%s' - want '%s': %v", v, "new-value", err)
+	}
+}
+
+func TestSealedTenants(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	sealing, err := newSealing(ctx, &SealingConfig{Sealer: newFakeSealer("vm-0")})
+	if err != nil {
+		t.Fatalf("Failed to derive sealing key: %v", err)
+	}
+
+	var (
+		backend  = &MemKeyStore{}
+		kekStore = &MemKeyStore{}
+	)
+	store := newTenantStore(sealing.KeyStore(backend), sealing.Tenants(map[string]*TenantConfig{
+		"acme":    {Prefix: "acme-"},
+		"acme-eu": {Prefix: "acme-eu-", KEKStore: kekStore},
+	}))
+	for _, name := range []string{"acme-key", "acme-eu-key"} {
+		if err = store.Create(ctx, name, []byte("value")); err != nil {
+			t.Fatalf("Failed to create '%s': %v", name, err)
+		}
+	}
+
+	for _, entry := range []struct {
+		Store KeyStore
+		Name  string
+	}{
+		{Store: backend, Name: "acme-key"},
+		{Store: backend, Name: "acme-eu-key"},
+		{Store: backend, Name: tenantKEKPrefix + "acme"},
+		{Store: kekStore, Name: tenantKEKPrefix + "acme-eu"},
+	} {
+		value, err := entry.Store.Get(ctx, entry.Name)
+		if err != nil {
+			t.Fatalf("Failed to get '%s': %v", entry.Name, err)
+		}
+		if !bytes.HasPrefix(value, sealedHeader) {
+			t.Fatalf("Entry '%s' is not sealed", entry.Name)
+		}
+	}
+}
+
+// fakeSealer is a Sealer that derives its sealing key
+// from a fake measurement.
+type fakeSealer struct {
+	measurement string
+}
+
+func newFakeSealer(measurement string) *fakeSealer { return &fakeSealer{measurement: measurement} }
+
+func (s *fakeSealer) SealingKey(context.Context) ([]byte, error) {
+	key := sha256.Sum256([]byte("sealing key: " + s.measurement))
+	return key[:], nil
+}
+
+func (s *fakeSealer) Report(_ context.Context, data []byte) ([]byte, error) {
+	return slices.Concat([]byte(s.measurement+":"), data), nil
+}
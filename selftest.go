@@ -0,0 +1,140 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+)
+
+// canaryPrefix is the prefix of canary keys created by the
+// self-test. Like locks, canary keys cannot collide with keys.
+// Each self-test uses its own canary key such that concurrent
+// self-tests, even of different servers, do not interfere.
+const canaryPrefix = "-canary-"
+
+// Self-test steps in the order they are performed.
+const (
+	selfTestCreate  = "create"
+	selfTestRead    = "read"
+	selfTestEncrypt = "encrypt"
+	selfTestDecrypt = "decrypt"
+	selfTestDelete  = "delete"
+)
+
+// selfTest performs a create, read, encrypt, decrypt and delete
+// cycle with a new canary key. It returns the result of each step
+// and stops at the first failed step. However, it always tries to
+// delete the canary key once it has been created.
+func (s *Server) selfTest(ctx context.Context, state *serverState) []api.SelfTestStep {
+	var random [8]byte
+	rand.Read(random[:])
+	name := canaryPrefix + hex.EncodeToString(random[:])
+
+	var steps []api.SelfTestStep
+	run := func(step string, f func() error) bool {
+		start := time.Now()
+		err := f()
+
+		result := api.SelfTestStep{
+			Name:    step,
+			Latency: time.Since(start).Microseconds(),
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		steps = append(steps, result)
+		return err == nil
+	}
+
+	ok := run(selfTestCreate, func() error {
+		key, err := crypto.GenerateSecretKey(crypto.DetermineSecretKeyType(), rand.Reader)
+		if err != nil {
+			return err
+		}
+		hmac, err := crypto.GenerateHMACKey(crypto.SHA256, rand.Reader)
+		if err != nil {
+			return err
+		}
+		return state.Keys.Create(ctx, name, crypto.KeyVersion{
+			Key:       key,
+			HMACKey:   hmac,
+			CreatedAt: time.Now().UTC(),
+			CreatedBy: state.Admin,
+		})
+	})
+	if !ok {
+		return steps
+	}
+
+	var (
+		key        crypto.KeyVersion
+		ciphertext []byte
+		plaintext  = random[:]
+	)
+	ok = run(selfTestRead, func() (err error) {
+		key, err = state.Keys.Get(ctx, name)
+		return err
+	})
+	ok = ok && run(selfTestEncrypt, func() (err error) {
+		ciphertext, err = key.Key.Encrypt(plaintext, []byte(name))
+		return err
+	})
+	_ = ok && run(selfTestDecrypt, func() error {
+		p, err := key.Key.Decrypt(ciphertext, []byte(name))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(p, plaintext) {
+			return errors.New("decrypted plaintext does not match")
+		}
+		return nil
+	})
+
+	// Use a separate context such that the canary key gets
+	// deleted even if the client canceled the request.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), defaultWriteTimeout)
+	defer cancel()
+	run(selfTestDelete, func() error { return state.Keys.Delete(ctx, name) })
+	return steps
+}
+
+func (s *Server) runSelfTest(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	if req.Identity != state.Admin {
+		resp.Fail(http.StatusForbidden, "self-test API requires the admin identity")
+		return
+	}
+
+	start := time.Now()
+	steps := s.selfTest(req.Context(), state)
+	latency := time.Since(start)
+
+	var (
+		code = http.StatusOK
+		msg  = "self-test succeeded"
+	)
+	for _, step := range steps {
+		if step.Error != "" {
+			state.Log.WarnContext(req.Context(), "self-test failed at step '"+step.Name+"': "+step.Error, "req", req)
+			code, msg = http.StatusBadGateway, "self-test failed"
+			break
+		}
+	}
+	state.Audit.Log(msg, code, req)
+	api.ReplyWith(resp, code, api.SelfTestResponse{
+		OK:      code == http.StatusOK,
+		Steps:   steps,
+		Latency: latency.Microseconds(),
+	})
+}
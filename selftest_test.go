@@ -0,0 +1,93 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/minio/kes/internal/api"
+)
+
+func TestSelfTest(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	store := &brokenGetKeyStore{}
+	srv, url := startServer(ctx, &Config{Keys: store})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	selfTest := func() (int, api.SelfTestResponse) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, url+api.PathSelfTest, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var body api.SelfTestResponse
+		if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp.StatusCode, body
+	}
+	stepNames := func(steps []api.SelfTestStep) []string {
+		names := make([]string, 0, len(steps))
+		for _, step := range steps {
+			names = append(names, step.Name)
+		}
+		return names
+	}
+
+	code, body := selfTest()
+	if code != http.StatusOK || !body.OK {
+		t.Fatalf("Self-test failed: %d: %+v", code, body)
+	}
+	if names := stepNames(body.Steps); !slices.Equal(names, []string{"create", "read", "encrypt", "decrypt", "delete"}) {
+		t.Fatalf("Self-test steps mismatch: got %v", names)
+	}
+
+	store.broken = true
+	code, body = selfTest()
+	if code != http.StatusBadGateway || body.OK {
+		t.Fatalf("Self-test succeeded with broken key store: %d: %+v", code, body)
+	}
+	if names := stepNames(body.Steps); !slices.Equal(names, []string{"create", "read", "delete"}) {
+		t.Fatalf("Self-test steps mismatch: got %v", names)
+	}
+	if body.Steps[1].Error == "" || body.Steps[2].Error != "" {
+		t.Fatalf("Self-test step errors mismatch: %+v", body.Steps)
+	}
+
+	names, _, err := store.List(ctx, canaryPrefix, -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if len(names) != 0 {
+		t.Fatalf("Canary keys have not been deleted: %v", names)
+	}
+}
+
+// brokenGetKeyStore is a KeyStore that fails
+// to fetch keys once it is broken.
+type brokenGetKeyStore struct {
+	MemKeyStore
+	broken bool
+}
+
+func (s *brokenGetKeyStore) Get(ctx context.Context, name string) ([]byte, error) {
+	if s.broken {
+		return nil, errors.New("disk I/O error")
+	}
+	return s.MemKeyStore.Get(ctx, name)
+}
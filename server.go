@@ -15,14 +15,17 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path"
 	"runtime"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/cache"
 	"github.com/minio/kes/internal/crypto"
 	"github.com/minio/kes/internal/fips"
 	"github.com/minio/kes/internal/headers"
@@ -80,9 +83,21 @@ type Server struct {
 	// Defaults to slog.LevelInfo.
 	AuditLevel slog.LevelVar
 
-	tls     atomic.Pointer[tls.Config]
-	state   atomic.Pointer[serverState]
-	handler atomic.Pointer[http.ServeMux]
+	tls      atomic.Pointer[tls.Config]
+	state    atomic.Pointer[serverState]
+	handler  atomic.Pointer[http.ServeMux]
+	vhosts   atomic.Pointer[virtualHosts]
+	readOnly atomic.Pointer[string] // Reason for read-only mode, or nil
+	inFlight atomic.Int64           // Number of requests subject to load shedding
+	locks    cache.Barrier[string]  // Serializes lock API calls per lock
+	leases   cache.Barrier[string]  // Serializes revocations per database lease
+	health   healthMonitor          // Tracks the health of backends, like the key store
+	shreds   sync.Mutex             // Serializes crypto-shred receipts
+	sessions tlsSessions            // Manages TLS session ticket keys and cached sessions
+	conns    connTracker            // Tracks open client connections
+	stop     context.CancelFunc     // Stops background tasks, like revoking database leases
+
+	writeBehind *writeBehind // Queues audit records and usage information. Nil if disabled
 
 	mu              sync.Mutex
 	srv             *http.Server
@@ -123,19 +138,10 @@ func (s *Server) UpdateAdmin(admin kes.Identity) error {
 	}
 
 	old := s.state.Load()
-	s.state.Store(&serverState{
-		Addr:       old.Addr,
-		StartTime:  old.StartTime,
-		Admin:      admin,
-		Keys:       old.Keys,
-		Policies:   old.Policies,
-		Identities: old.Identities,
-		Metrics:    old.Metrics,
-		Routes:     old.Routes,
-		LogHandler: old.LogHandler,
-		Log:        old.Log,
-		Audit:      old.Audit,
-	})
+	state := old.clone()
+	state.Admin = admin
+	addIdentities(state, time.Now())
+	s.state.Store(state)
 	return nil
 }
 
@@ -161,6 +167,10 @@ func (s *Server) UpdateTLS(conf *tls.Config) error {
 		return errors.New("kes: server not started")
 	}
 
+	conf = conf.Clone()
+	s.sessions.Reset() // Sessions established with the previous TLS config must not be resumed
+	s.sessions.Apply(conf, s.tlsHandshake)
+
 	s.tls.Store(conf)
 	return nil
 }
@@ -186,19 +196,18 @@ func (s *Server) UpdatePolicies(policies map[string]Policy) error {
 	}
 
 	old := s.state.Load()
-	s.state.Store(&serverState{
-		Addr:       old.Addr,
-		StartTime:  old.StartTime,
-		Admin:      old.Admin,
-		Keys:       old.Keys,
-		Policies:   policySet,
-		Identities: identitySet,
-		Metrics:    old.Metrics,
-		Routes:     old.Routes,
-		LogHandler: old.LogHandler,
-		Log:        old.Log,
-		Audit:      old.Audit,
-	})
+	geoFence, err := newGeoFence(old.GeoFence.DB(), policies)
+	if err != nil {
+		return err
+	}
+	state := old.clone()
+	state.Policies = policySet
+	state.Identities = identitySet
+	state.GeoFence = geoFence
+	addIdentities(state, time.Now())
+	state.Aliases.Prune(state.Identities)
+	s.state.Store(state)
+	old.Changes.RecordPolicies(old, state, "")
 	return nil
 }
 
@@ -219,6 +228,34 @@ func (s *Server) Update(conf *Config) (io.Closer, error) {
 	if err != nil {
 		return nil, err
 	}
+	geoDB, err := parseGeoIP(conf.GeoIP)
+	if err != nil {
+		return nil, err
+	}
+	geoFence, err := newGeoFence(geoDB, conf.Policies)
+	if err != nil {
+		return nil, err
+	}
+	witness, err := newWitness(conf.Witness)
+	if err != nil {
+		return nil, err
+	}
+	fencing, err := newFencing(conf.Fencing)
+	if err != nil {
+		return nil, err
+	}
+	attestation, err := newAttestation(conf.Attestation)
+	if err != nil {
+		return nil, err
+	}
+	sealing, err := newSealing(context.Background(), conf.Sealing)
+	if err != nil {
+		return nil, err
+	}
+	folders, err := newFolderTree(conf.Folders, conf.Policies)
+	if err != nil {
+		return nil, err
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -231,19 +268,44 @@ func (s *Server) Update(conf *Config) (io.Closer, error) {
 	}
 
 	old := s.state.Load()
-	state := &serverState{
-		Addr:       old.Addr,
-		StartTime:  old.StartTime,
-		Admin:      conf.Admin,
-		Keys:       newCache(conf.Keys, conf.Cache),
-		Policies:   policySet,
-		Identities: identitySet,
-		Metrics:    old.Metrics,
-
-		LogHandler: old.LogHandler,
-		Log:        old.Log,
-		Audit:      old.Audit,
+	cosigning, err := newCosigning(conf.Cosigning, old.Cosigning)
+	if err != nil {
+		return nil, err
 	}
+	webAuthn, err := newWebAuthn(conf.WebAuthn, old.WebAuthn)
+	if err != nil {
+		return nil, err
+	}
+	state := old.clone()
+	state.Admin = conf.Admin
+	state.Keys = newCache(newDedupStore(newCompressedStore(newTenantStore(sealing.KeyStore(newChunkedStore(conf.Keys, conf.Chunking)), sealing.Tenants(conf.Tenants)), conf.Compression), conf.Dedup), conf.Cache, conf.KeyStoreTimeout, old.Metrics)
+	state.Policies = policySet
+	state.Identities = identitySet
+	state.Standbys = slices.Clone(conf.StandbyIdentities)
+	state.JobTargets = conf.Jobs
+	addIdentities(state, time.Now())
+	state.Aliases.Prune(state.Identities)
+	state.Databases = newDBEngines(conf.Databases)
+	state.SSHRoles = newSSHRoles(conf.SSHRoles)
+	state.PKI = newPKIEngine(conf.PKI)
+	state.GeoFence = geoFence
+	state.Cosigning = cosigning
+	state.WebAuthn = webAuthn
+	state.Witness = witness
+	state.Fencing = fencing
+	state.Attestation = attestation
+	state.Sealing = sealing
+	state.Folders = folders
+	state.Lifecycle = newKeyLifecycle(conf.KeyLifecycle, old.Lifecycle)
+	state.RequireOwner = conf.RequireKeyOwner
+	state.Tokenization = newTokenizers(conf.Tokenization)
+	state.Merkle = newMerklePublisher(conf.Merkle)
+	state.LoadShedding = newLoadShedder(conf.LoadShedding)
+	state.Anomalies = newAnomalyDetector(conf.AnomalyDetection)
+	state.Alerts = newAlertEngine(conf.Alerting, old.Alerts)
+	state.Auth = newAuthChain(conf.Authenticators)
+	state.AuthThrottle = newAuthThrottle(conf.AuthThrottling)
+	state.RequestLog = newRequestLogger(conf.RequestLog)
 
 	err = createPredefinedKeys(context.Background(), conf, state)
 	if err != nil {
@@ -259,17 +321,35 @@ func (s *Server) Update(conf *Config) (io.Closer, error) {
 		state.Log = slog.New(state.LogHandler)
 	}
 	if conf.AuditLog != nil {
-		state.Audit.h = conf.AuditLog
+		if s.writeBehind != nil {
+			state.Audit.h = s.writeBehind.AuditHandler(conf.AuditLog)
+		} else {
+			state.Audit.h = conf.AuditLog
+		}
+	}
+	if err = state.Audit.pseudonymize(context.Background(), conf.AuditPseudonymization, state.Keys.store); err != nil {
+		return nil, err
 	}
 
 	mux, routes := initRoutes(s, conf.Routes, state.Metrics)
 	state.Routes = routes
 
-	s.tls.Store(conf.TLS.Clone())
+	vhosts, err := s.updateVirtualHosts(old.Addr, conf.VirtualHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConf := conf.TLS.Clone()
+	s.sessions.Configure(conf.TLSSession)
+	s.sessions.Apply(tlsConf, s.tlsHandshake)
+
+	s.tls.Store(tlsConf)
+	state.Audit.alerts.Store(state.Alerts)
 	s.state.Store(state)
 	s.handler.Store(mux)
+	state.Changes.RecordPolicies(old, state, "")
 
-	return old.Keys, nil
+	return closers{old.Keys, vhosts}, nil
 }
 
 // ListenAndStart listens on the TCP network address addr and
@@ -336,7 +416,15 @@ func (s *Server) Close() error {
 	}
 	s.closed = true
 
+	if state := s.state.Load(); state != nil {
+		state.Jobs.Close()
+		state.Standby.Promote() // Stop synchronizing with the primary
+	}
+	if s.stop != nil {
+		s.stop()
+	}
 	if s.srv == nil {
+		s.closeWriteBehind()
 		if state := s.state.Load(); state != nil && state.Keys != nil {
 			s.cErr = state.Keys.Close()
 		}
@@ -359,12 +447,31 @@ func (s *Server) Close() error {
 	if errors.Is(s.cErr, context.Canceled) || errors.Is(s.cErr, context.DeadlineExceeded) {
 		s.cErr = s.srv.Close()
 	}
+	s.closeWriteBehind()
 	if err := s.state.Load().Keys.Close(); s.cErr == nil {
 		s.cErr = err
 	}
+	if vhosts := s.vhosts.Load(); vhosts != nil { // Virtual servers don't have their own connections
+		if err := vhosts.Close(); s.cErr == nil {
+			s.cErr = err
+		}
+	}
 	return s.cErr
 }
 
+// closeWriteBehind queues the server's current usage information,
+// such that it is stored once the server restarts, and closes the
+// write-behind queues.
+func (s *Server) closeWriteBehind() {
+	if s.writeBehind == nil {
+		return
+	}
+	if state := s.state.Load(); state != nil && !state.Standby.IsActive() {
+		s.writeBehind.QueueUsage(state.Usage.Snapshot())
+	}
+	s.writeBehind.Close()
+}
+
 func (s *Server) serve(ctx context.Context, ln net.Listener, conf *Config) error {
 	listener, err := s.listen(ctx, ln, conf)
 	if err != nil {
@@ -387,34 +494,121 @@ func (s *Server) serve(ctx context.Context, ln net.Listener, conf *Config) error
 }
 
 func (s *Server) listen(ctx context.Context, ln net.Listener, conf *Config) (net.Listener, error) {
-	policySet, identitySet, err := initPolicies(conf.Policies)
+	vhosts, err := s.startVirtualHosts(ctx, ln.Addr(), conf.VirtualHosts)
 	if err != nil {
 		return nil, err
 	}
+	if err = s.start(ctx, ln.Addr(), conf); err != nil {
+		vhosts.Close()
+		return nil, err
+	}
+	s.vhosts.Store(&vhosts)
+
+	return tls.NewListener(ln, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			return s.virtualHostByName(hello.ServerName).tls.Load(), nil
+		},
+	}), nil
+}
+
+// start initializes the server state from the given config and
+// starts all background tasks. It does not accept connections.
+func (s *Server) start(ctx context.Context, addr net.Addr, conf *Config) error {
+	policySet, identitySet, err := initPolicies(conf.Policies)
+	if err != nil {
+		return err
+	}
+	geoDB, err := parseGeoIP(conf.GeoIP)
+	if err != nil {
+		return err
+	}
+	geoFence, err := newGeoFence(geoDB, conf.Policies)
+	if err != nil {
+		return err
+	}
+	witness, err := newWitness(conf.Witness)
+	if err != nil {
+		return err
+	}
+	fencing, err := newFencing(conf.Fencing)
+	if err != nil {
+		return err
+	}
+	attestation, err := newAttestation(conf.Attestation)
+	if err != nil {
+		return err
+	}
+	sealing, err := newSealing(ctx, conf.Sealing)
+	if err != nil {
+		return err
+	}
+	folders, err := newFolderTree(conf.Folders, conf.Policies)
+	if err != nil {
+		return err
+	}
+	cosigning, err := newCosigning(conf.Cosigning, nil)
+	if err != nil {
+		return err
+	}
+	webAuthn, err := newWebAuthn(conf.WebAuthn, nil)
+	if err != nil {
+		return err
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if s.closed {
-		return nil, errors.New("kes: server is closed")
+		return errors.New("kes: server is closed")
 	}
 	if s.started {
-		return nil, errors.New("kes: server already started")
+		return errors.New("kes: server already started")
 	}
 
+	startTime := time.Now()
+	metrics := metric.New()
 	state := &serverState{
-		Addr:       ln.Addr(),
-		StartTime:  time.Now(),
-		Admin:      conf.Admin,
-		Keys:       newCache(conf.Keys, conf.Cache),
-		Policies:   policySet,
-		Identities: identitySet,
-		Metrics:    metric.New(),
+		Addr:         addr,
+		StartTime:    startTime,
+		Admin:        conf.Admin,
+		Keys:         newCache(newDedupStore(newCompressedStore(newTenantStore(sealing.KeyStore(newChunkedStore(conf.Keys, conf.Chunking)), sealing.Tenants(conf.Tenants)), conf.Compression), conf.Dedup), conf.Cache, conf.KeyStoreTimeout, metrics),
+		Policies:     policySet,
+		Identities:   identitySet,
+		Metrics:      metrics,
+		Usage:        newUsageTracker(startTime),
+		Idempotency:  newIdempotencyCache(idempotencyWindow, maxIdempotencyEntries),
+		Jobs:         newJobManager(),
+		JobTargets:   conf.Jobs,
+		Standbys:     slices.Clone(conf.StandbyIdentities),
+		Changes:      newChangeFeed(),
+		Databases:    newDBEngines(conf.Databases),
+		SSHRoles:     newSSHRoles(conf.SSHRoles),
+		PKI:          newPKIEngine(conf.PKI),
+		Aliases:      &identityAliases{},
+		GeoFence:     geoFence,
+		Cosigning:    cosigning,
+		WebAuthn:     webAuthn,
+		Witness:      witness,
+		Fencing:      fencing,
+		Attestation:  attestation,
+		Sealing:      sealing,
+		Folders:      folders,
+		Lifecycle:    newKeyLifecycle(conf.KeyLifecycle, nil),
+		RequireOwner: conf.RequireKeyOwner,
+		Tokenization: newTokenizers(conf.Tokenization),
+		Merkle:       newMerklePublisher(conf.Merkle),
+		LoadShedding: newLoadShedder(conf.LoadShedding),
+		Anomalies:    newAnomalyDetector(conf.AnomalyDetection),
+		Alerts:       newAlertEngine(conf.Alerting, nil),
+		Auth:         newAuthChain(conf.Authenticators),
+		AuthThrottle: newAuthThrottle(conf.AuthThrottling),
+		RequestLog:   newRequestLogger(conf.RequestLog),
 	}
 
 	err = createPredefinedKeys(ctx, conf, state)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if conf.ErrorLog == nil {
@@ -429,25 +623,54 @@ func (s *Server) listen(ctx context.Context, ln net.Listener, conf *Config) (net
 	}
 	state.Log = slog.New(state.LogHandler)
 
-	if conf.AuditLog == nil {
+	if _, err = loadUsage(ctx, state); err != nil {
+		state.Log.WarnContext(ctx, fmt.Sprintf("failed to load usage information: %v", err))
+	}
+	addIdentities(state, startTime)
+
+	writeBehind, err := newWriteBehind(conf.WriteBehind)
+	if err != nil {
+		return err
+	}
+	switch {
+	case conf.AuditLog == nil:
 		handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: &s.AuditLevel})
 		state.Audit = newAuditLogger(&AuditLogHandler{Handler: handler}, &s.AuditLevel)
-	} else {
+	case writeBehind != nil:
+		state.Audit = newAuditLogger(writeBehind.AuditHandler(conf.AuditLog), &s.AuditLevel)
+	default:
 		state.Audit = newAuditLogger(conf.AuditLog, &s.AuditLevel)
 	}
+	if err = state.Audit.pseudonymize(ctx, conf.AuditPseudonymization, state.Keys.store); err != nil {
+		if writeBehind != nil {
+			writeBehind.Close()
+		}
+		return err
+	}
+	state.Audit.alerts.Store(state.Alerts)
 
 	mux, routes := initRoutes(s, conf.Routes, state.Metrics)
 	state.Routes = routes
 
-	s.tls.Store(conf.TLS.Clone())
+	if conf.Standby != nil {
+		state.Standby = newStandby(conf.Standby)
+	}
+
+	tlsConf := conf.TLS.Clone()
+	s.sessions.Configure(conf.TLSSession)
+	s.sessions.Apply(tlsConf, s.tlsHandshake)
+
+	s.tls.Store(tlsConf)
 	s.state.Store(state)
 	s.handler.Store(mux)
 
 	s.srv = &http.Server{
 		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			s.handler.Load().ServeHTTP(w, r)
+			s.virtualHost(r.TLS).handler.Load().ServeHTTP(w, r)
 		}),
 
+		ConnContext:       s.conns.Accept,
+		ConnState:         s.conns.StateChanged,
 		ReadHeaderTimeout: 5 * time.Second,
 		WriteTimeout:      0 * time.Second, // explicitly set no write timeout - api.Route uses http.ResponseController
 		IdleTimeout:       90 * time.Second,
@@ -455,13 +678,28 @@ func (s *Server) listen(ctx context.Context, ln net.Listener, conf *Config) (net
 		ErrorLog:          slog.NewLogLogger(s.state.Load().LogHandler, slog.LevelInfo), // TODO: wrap
 	}
 	s.started = true
+	s.writeBehind = writeBehind
 
-	return tls.NewListener(ln, &tls.Config{
-		MinVersion: tls.VersionTLS12,
-		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
-			return s.tls.Load(), nil
-		},
-	}), nil
+	if state.Standby != nil {
+		s.startStandby(state.Standby)
+	}
+
+	bgCtx, stop := context.WithCancel(context.Background())
+	s.stop = stop
+	s.startLeaseRevoker(bgCtx)
+	s.startMerklePublisher(bgCtx)
+	s.startUsageFlusher(bgCtx)
+	s.startHealthProber(bgCtx)
+	s.startTicketKeyRotation(bgCtx)
+	s.startIdentityAliasLoader(bgCtx)
+	s.startAnomalyDetector(bgCtx)
+	s.startAlerting(bgCtx)
+	s.startKeyLifecycle(bgCtx)
+	if writeBehind != nil {
+		s.startWriteBehind(bgCtx, writeBehind)
+	}
+
+	return nil
 }
 
 func createPredefinedKeys(ctx context.Context, conf *Config, state *serverState) error {
@@ -505,8 +743,12 @@ func (s *Server) version(resp *api.Response, req *api.Request) {
 }
 
 func (s *Server) ready(resp *api.Response, req *api.Request) {
-	_, err := s.state.Load().Keys.Status(req.Context())
-	if _, ok := keystore.IsUnreachable(err); ok {
+	if s.state.Load().Standby.IsActive() {
+		resp.Fail(http.StatusServiceUnavailable, "server is a standby")
+		return
+	}
+	err := s.keyStoreHealth(req.Context()).Err
+	if _, ok := keystore.IsUnreachable(err); ok || errors.Is(err, context.DeadlineExceeded) {
 		s.state.Load().Log.WarnContext(req.Context(), err.Error(), "req", req)
 		resp.Fail(http.StatusGatewayTimeout, "key store is not reachable")
 		return
@@ -527,14 +769,10 @@ func (s *Server) status(resp *api.Response, req *api.Request) {
 		return
 	}
 
-	var (
-		latency     time.Duration
-		unreachable = true
-	)
-	state, err := s.state.Load().Keys.Status(req.Context())
-	if err == nil {
-		unreachable = false
-		latency = state.Latency.Round(time.Millisecond)
+	var latency time.Duration
+	probe := s.keyStoreHealth(req.Context())
+	if !probe.Unreachable() {
+		latency = probe.Latency.Round(time.Millisecond)
 
 		if latency == 0 { // Make sure we actually send a latency even if the key store respond time is < 1ms.
 			latency = 1 * time.Millisecond
@@ -543,6 +781,7 @@ func (s *Server) status(resp *api.Response, req *api.Request) {
 
 	var memStats runtime.MemStats
 	runtime.ReadMemStats(&memStats)
+	readOnly, reason := s.IsReadOnly()
 
 	api.ReplyWith(resp, http.StatusOK, api.StatusResponse{
 		Version: info.Version,
@@ -555,8 +794,16 @@ func (s *Server) status(resp *api.Response, req *api.Request) {
 		HeapAlloc:  memStats.HeapAlloc,
 		StackAlloc: memStats.StackSys,
 
-		KeyStoreLatency:     latency.Milliseconds(),
-		KeyStoreUnreachable: unreachable,
+		KeyStoreLatency:         latency.Milliseconds(),
+		KeyStoreUnreachable:     probe.Unreachable(),
+		KeyStoreUnauthenticated: probe.Unauthenticated,
+		KeyStoreUnauthorized:    probe.Unauthorized,
+
+		ReadOnly:       readOnly,
+		ReadOnlyReason: reason,
+
+		Standby:         s.state.Load().Standby.IsActive(),
+		StandbySyncedAt: s.state.Load().Standby.LastSync(),
 	})
 }
 
@@ -583,12 +830,49 @@ func (s *Server) listAPIs(resp *api.Response, _ *api.Request) {
 	api.ReplyWith(resp, http.StatusOK, responses)
 }
 
+func (s *Server) openAPI(resp *api.Response, req *api.Request) {
+	info, err := sys.ReadBinaryInfo()
+	if err != nil {
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to read server version")
+		return
+	}
+	api.ReplyWith(resp, http.StatusOK, api.OpenAPI(info.Version, s.state.Load().Routes))
+}
+
 func (s *Server) createKey(resp *api.Response, req *api.Request) {
 	if !validName(req.Resource) {
 		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
 		return
 	}
 
+	var body api.CreateKeyRequest
+	if req.ContentLength > 0 {
+		if err := api.ReadBody(req, &body); err != nil {
+			if err, ok := api.IsError(err); ok {
+				resp.Failr(err)
+				return
+			}
+
+			s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+			resp.Fail(http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+	var lock *timeLock
+	if body.TimeLock != nil {
+		var apiErr api.Error
+		if lock, apiErr = parseTimeLock(*body.TimeLock); apiErr != nil {
+			resp.Failr(apiErr)
+			return
+		}
+	}
+	owner, apiErr := parseKeyOwner(body.Owner, body.Contact, s.state.Load().RequireOwner, req.Identity)
+	if apiErr != nil {
+		resp.Failr(apiErr)
+		return
+	}
+
 	cipher := crypto.DetermineSecretKeyType()
 
 	key, err := crypto.GenerateSecretKey(cipher, rand.Reader)
@@ -604,12 +888,13 @@ func (s *Server) createKey(resp *api.Response, req *api.Request) {
 		return
 	}
 
-	if err = s.state.Load().Keys.Create(req.Context(), req.Resource, crypto.KeyVersion{
+	version := crypto.KeyVersion{
 		Key:       key,
 		HMACKey:   hmac,
 		CreatedAt: time.Now().UTC(),
 		CreatedBy: req.Identity,
-	}); err != nil {
+	}
+	if err = createOwnedKey(req.Context(), s.state.Load().Keys, req.Resource, version, lock, owner); err != nil {
 		if err, ok := api.IsError(err); ok {
 			resp.Failr(err)
 			return
@@ -620,12 +905,14 @@ func (s *Server) createKey(resp *api.Response, req *api.Request) {
 		return
 	}
 
+	s.state.Load().Changes.Record(api.ChangeObjectKey, api.ChangeCreate, req.Resource, req.Identity)
+
+	msg := fmt.Sprintf("secret key '%s' created", req.Resource)
+	if lock != nil {
+		msg = fmt.Sprintf("time-locked secret key '%s' created", req.Resource)
+	}
 	const StatusOK = http.StatusOK
-	s.state.Load().Audit.Log(
-		fmt.Sprintf("secret key '%s' created", req.Resource),
-		StatusOK,
-		req,
-	)
+	s.state.Load().Audit.Log(msg, StatusOK, req)
 	resp.Reply(StatusOK)
 }
 
@@ -646,6 +933,11 @@ func (s *Server) importKey(resp *api.Response, req *api.Request) {
 		resp.Fail(http.StatusBadRequest, "invalid import key request body")
 		return
 	}
+	owner, apiErr := parseKeyOwner(imp.Owner, imp.Contact, s.state.Load().RequireOwner, req.Identity)
+	if apiErr != nil {
+		resp.Failr(apiErr)
+		return
+	}
 
 	var cipher crypto.SecretKeyType
 	switch imp.Cipher {
@@ -679,12 +971,12 @@ func (s *Server) importKey(resp *api.Response, req *api.Request) {
 		resp.Fail(http.StatusInternalServerError, "failed to create key")
 		return
 	}
-	if err = s.state.Load().Keys.Create(req.Context(), req.Resource, crypto.KeyVersion{
+	if err = createOwnedKey(req.Context(), s.state.Load().Keys, req.Resource, crypto.KeyVersion{
 		Key:       key,
 		HMACKey:   hmac,
 		CreatedAt: time.Now().UTC(),
 		CreatedBy: req.Identity,
-	}); err != nil {
+	}, nil, owner); err != nil {
 		if err, ok := api.IsError(err); ok {
 			resp.Failr(err)
 			return
@@ -695,6 +987,8 @@ func (s *Server) importKey(resp *api.Response, req *api.Request) {
 		return
 	}
 
+	s.state.Load().Changes.Record(api.ChangeObjectKey, api.ChangeCreate, req.Resource, req.Identity)
+
 	const StatusOK = http.StatusOK
 	s.state.Load().Audit.Log(
 		fmt.Sprintf("secret key '%s' created", req.Resource),
@@ -710,7 +1004,7 @@ func (s *Server) describeKey(resp *api.Response, req *api.Request) {
 		return
 	}
 
-	key, err := s.state.Load().Keys.Get(req.Context(), req.Resource)
+	key, lock, err := s.state.Load().Keys.GetTimeLock(req.Context(), req.Resource)
 	if err != nil {
 		if err, ok := api.IsError(err); ok {
 			resp.Failr(err)
@@ -721,13 +1015,42 @@ func (s *Server) describeKey(resp *api.Response, req *api.Request) {
 		resp.Fail(http.StatusBadGateway, "failed to read key")
 		return
 	}
+	folder, err := s.state.Load().Keys.Folder(req.Context(), req.Resource)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
 
-	api.ReplyWith(resp, http.StatusOK, api.DescribeKeyResponse{
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to read key folder")
+		return
+	}
+	owner, err := loadKeyOwner(req.Context(), s.state.Load().Keys, req.Resource)
+	if err != nil {
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to read key owner")
+		return
+	}
+
+	info := api.DescribeKeyResponse{
 		Name:      req.Resource,
 		Algorithm: key.Key.Type().String(),
 		CreatedAt: key.CreatedAt,
 		CreatedBy: key.CreatedBy.String(),
-	})
+		Folder:    folder,
+		Tags:      s.state.Load().Folders.Tags(folder),
+	}
+	if state, ok := s.state.Load().Lifecycle.State(req.Resource, time.Now()); ok {
+		info.State = state
+	}
+	if owner != nil {
+		info.Owner, info.Contact = owner.Owner, owner.Contact
+	}
+	if lock != nil {
+		info.TimeLock = &lock.conf
+	}
+	api.ReplyWith(resp, http.StatusOK, info)
 }
 
 func (s *Server) listKeys(resp *api.Response, req *api.Request) {
@@ -736,25 +1059,107 @@ func (s *Server) listKeys(resp *api.Response, req *api.Request) {
 		return
 	}
 
-	prefix := req.Resource
-	if prefix == "*" {
-		prefix = ""
+	filter, apiErr := parseKeyFilter(req)
+	if apiErr != nil {
+		resp.Failr(apiErr)
+		return
+	}
+	page, apiErr := api.ParsePage(req)
+	if apiErr != nil {
+		resp.Failr(apiErr)
+		return
 	}
 
-	names, prefix, err := s.state.Load().Keys.List(req.Context(), prefix, -1)
+	prefix := strings.TrimSuffix(req.Resource, "*")
+
+	state := s.state.Load()
+	names, prefix, err := state.Keys.List(req.Context(), prefix, -1)
 	if err != nil {
 		if err, ok := api.IsError(err); ok {
 			resp.Failr(err)
 			return
 		}
 
-		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
 		resp.Fail(http.StatusBadGateway, "failed to list keys")
+		return
+	}
+	slices.Sort(names)
+	names = page.Seek(names)
+
+	if !filter.IsEmpty() {
+		matches := make([]string, 0, len(names))
+		for _, name := range names {
+			if page.IsFull(matches) {
+				break
+			}
+			if !filter.MatchName(name) {
+				continue
+			}
+			if filter.NeedsKey() {
+				key, err := state.Keys.Get(req.Context(), name)
+				if errors.Is(err, kes.ErrKeyNotFound) {
+					continue // The key has been deleted in the meantime
+				}
+				if err != nil {
+					if err, ok := api.IsError(err); ok {
+						resp.Failr(err)
+						return
+					}
+
+					state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+					resp.Fail(http.StatusBadGateway, "failed to read key")
+					return
+				}
+				if !filter.MatchKey(key) {
+					continue
+				}
+			}
+			if filter.Folder != "" {
+				folder, err := state.Keys.Folder(req.Context(), name)
+				if errors.Is(err, kes.ErrKeyNotFound) {
+					continue // The key has been deleted in the meantime
+				}
+				if err != nil {
+					if err, ok := api.IsError(err); ok {
+						resp.Failr(err)
+						return
+					}
+
+					state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+					resp.Fail(http.StatusBadGateway, "failed to read key folder")
+					return
+				}
+				if !inFolder(folder, filter.Folder) {
+					continue
+				}
+			}
+			if filter.NeedsOwner() {
+				owner, err := loadKeyOwner(req.Context(), state.Keys, name)
+				if err != nil {
+					if err, ok := api.IsError(err); ok {
+						resp.Failr(err)
+						return
+					}
+
+					state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+					resp.Fail(http.StatusBadGateway, "failed to read key owner")
+					return
+				}
+				if !filter.MatchOwner(owner) {
+					continue
+				}
+			}
+			matches = append(matches, name)
+		}
+		names = matches
 	}
 
+	names, cursor := page.Cut(names)
 	api.ReplyWith(resp, http.StatusOK, api.ListKeysResponse{
 		Names:      names,
 		ContinueAt: prefix,
+		NextCursor: cursor,
 	})
 }
 
@@ -775,6 +1180,9 @@ func (s *Server) deleteKey(resp *api.Response, req *api.Request) {
 		return
 	}
 
+	s.state.Load().Usage.ForgetKey(req.Resource)
+	s.state.Load().Changes.Record(api.ChangeObjectKey, api.ChangeDelete, req.Resource, req.Identity)
+
 	const StatusOK = http.StatusOK
 	s.state.Load().Audit.Log(
 		fmt.Sprintf("secret key '%s' deleted", req.Resource),
@@ -801,8 +1209,18 @@ func (s *Server) encryptKey(resp *api.Response, req *api.Request) {
 		resp.Fail(http.StatusBadRequest, "invalid request body")
 		return
 	}
+	if err := s.checkFence(req); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to read key reservation")
+		return
+	}
 
-	key, err := s.state.Load().Keys.Get(req.Context(), req.Resource)
+	key, err := s.state.Load().Keys.Use(req.Context(), req.Resource)
 	if err != nil {
 		if err, ok := api.IsError(err); ok {
 			resp.Failr(err)
@@ -813,6 +1231,11 @@ func (s *Server) encryptKey(resp *api.Response, req *api.Request) {
 		resp.Fail(http.StatusBadGateway, "failed to read key")
 		return
 	}
+	if err := checkKeyVersion(s.state.Load(), req, key, enc.Version); err != nil {
+		resp.Failr(err)
+		return
+	}
+	s.state.Load().Usage.UseKey(req.Resource)
 	ciphertext, err := key.Key.Encrypt(enc.Plaintext, enc.Context)
 	if err != nil {
 		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
@@ -822,6 +1245,7 @@ func (s *Server) encryptKey(resp *api.Response, req *api.Request) {
 
 	api.ReplyWith(resp, http.StatusOK, api.EncryptKeyResponse{
 		Ciphertext: ciphertext,
+		Version:    keyVersion(key),
 	})
 }
 
@@ -844,8 +1268,18 @@ func (s *Server) generateKey(resp *api.Response, req *api.Request) {
 			return
 		}
 	}
+	if err := s.checkFence(req); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
 
-	key, err := s.state.Load().Keys.Get(req.Context(), req.Resource)
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to read key reservation")
+		return
+	}
+
+	key, err := s.state.Load().Keys.Use(req.Context(), req.Resource)
 	if err != nil {
 		if err, ok := api.IsError(err); ok {
 			resp.Failr(err)
@@ -856,6 +1290,11 @@ func (s *Server) generateKey(resp *api.Response, req *api.Request) {
 		resp.Fail(http.StatusBadGateway, "failed to read key")
 		return
 	}
+	if err := checkKeyVersion(s.state.Load(), req, key, gen.Version); err != nil {
+		resp.Failr(err)
+		return
+	}
+	s.state.Load().Usage.UseKey(req.Resource)
 
 	dataKey := make([]byte, 32)
 	if _, err = rand.Read(dataKey); err != nil {
@@ -873,6 +1312,7 @@ func (s *Server) generateKey(resp *api.Response, req *api.Request) {
 	api.ReplyWith(resp, http.StatusOK, api.GenerateKeyResponse{
 		Plaintext:  dataKey,
 		Ciphertext: ciphertext,
+		Version:    keyVersion(key),
 	})
 }
 
@@ -894,7 +1334,7 @@ func (s *Server) decryptKey(resp *api.Response, req *api.Request) {
 		return
 	}
 
-	key, err := s.state.Load().Keys.Get(req.Context(), req.Resource)
+	key, err := s.state.Load().Keys.Use(req.Context(), req.Resource)
 	if err != nil {
 		if err, ok := api.IsError(err); ok {
 			resp.Failr(err)
@@ -905,6 +1345,11 @@ func (s *Server) decryptKey(resp *api.Response, req *api.Request) {
 		resp.Fail(http.StatusBadGateway, "failed to read key")
 		return
 	}
+	if err := checkKeyVersion(s.state.Load(), req, key, enc.Version); err != nil {
+		resp.Failr(err)
+		return
+	}
+	s.state.Load().Usage.UseKey(req.Resource)
 	plaintext, err := key.Key.Decrypt(enc.Ciphertext, enc.Context)
 	if err != nil {
 		if err, ok := api.IsError(err); ok {
@@ -940,7 +1385,7 @@ func (s *Server) hmacKey(resp *api.Response, req *api.Request) {
 		return
 	}
 
-	key, err := s.state.Load().Keys.Get(req.Context(), req.Resource)
+	key, err := s.state.Load().Keys.Use(req.Context(), req.Resource)
 	if err != nil {
 		if err, ok := api.IsError(err); ok {
 			resp.Failr(err)
@@ -951,13 +1396,83 @@ func (s *Server) hmacKey(resp *api.Response, req *api.Request) {
 		resp.Fail(http.StatusBadGateway, "failed to read key")
 		return
 	}
+	if err := checkKeyVersion(s.state.Load(), req, key, body.Version); err != nil {
+		resp.Failr(err)
+		return
+	}
+	s.state.Load().Usage.UseKey(req.Resource)
 	if !key.HasHMACKey() {
 		resp.Fail(http.StatusConflict, "key does not support HMAC")
 		return
 	}
 
 	api.ReplyWith(resp, http.StatusOK, api.HMACResponse{
-		Sum: key.HMACKey.Sum(body.Message),
+		Sum:     key.HMACKey.Sum(body.Message),
+		Version: keyVersion(key),
+	})
+}
+
+func (s *Server) listStaleKeys(resp *api.Response, req *api.Request) {
+	if !validPattern(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "listing pattern '%s' is empty, too long or is invalid", req.Resource)
+		return
+	}
+	cutoff, apiErr := staleCutoff(req)
+	if apiErr != nil {
+		resp.Failr(apiErr)
+		return
+	}
+
+	state := s.state.Load()
+	if req.Identity != state.Admin {
+		resp.Fail(http.StatusForbidden, "stale key report requires the admin identity")
+		return
+	}
+	prefix := strings.TrimSuffix(req.Resource, "*")
+
+	names, _, err := state.Keys.List(req.Context(), prefix, -1)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to list keys")
+		return
+	}
+
+	since := state.Usage.Since()
+	keys := make([]api.StaleKeyResponse, 0, len(names))
+	for _, name := range names {
+		key, err := state.Keys.Get(req.Context(), name)
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			continue // The key has been deleted in the meantime
+		}
+		if err != nil {
+			if err, ok := api.IsError(err); ok {
+				resp.Failr(err)
+				return
+			}
+
+			state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+			resp.Fail(http.StatusBadGateway, "failed to read key")
+			return
+		}
+
+		lastUsed := state.Usage.KeyLastUsed(name)
+		if isStale(key.CreatedAt, lastUsed, since, cutoff) {
+			keys = append(keys, api.StaleKeyResponse{
+				Name:      name,
+				CreatedAt: key.CreatedAt,
+				LastUsed:  lastUsed,
+			})
+		}
+	}
+
+	api.ReplyWith(resp, http.StatusOK, api.ListStaleKeysResponse{
+		Keys:         keys,
+		TrackedSince: since,
 	})
 }
 
@@ -1016,6 +1531,12 @@ func (s *Server) listPolicies(resp *api.Response, req *api.Request) {
 		return
 	}
 
+	page, err := api.ParsePage(req)
+	if err != nil {
+		resp.Failr(err)
+		return
+	}
+
 	policies := s.state.Load().Policies
 	var names []string
 	if req.Resource == "" || req.Resource == "*" { // fast path
@@ -1038,13 +1559,15 @@ func (s *Server) listPolicies(resp *api.Response, req *api.Request) {
 	}
 	slices.Sort(names)
 
+	names, cursor := page.Cut(page.Seek(names))
 	api.ReplyWith(resp, http.StatusOK, api.ListPoliciesResponse{
-		Names: names,
+		Names:      names,
+		NextCursor: cursor,
 	})
 }
 
 func (s *Server) describeIdentity(resp *api.Response, req *api.Request) {
-	if !validName(req.Resource) {
+	if !validIdentity(req.Resource) {
 		resp.Failf(http.StatusBadRequest, "identity '%s' is empty, too long or contains invalid characters", req.Resource)
 		return
 	}
@@ -1077,6 +1600,12 @@ func (s *Server) listIdentities(resp *api.Response, req *api.Request) {
 		return
 	}
 
+	page, err := api.ParsePage(req)
+	if err != nil {
+		resp.Failr(err)
+		return
+	}
+
 	state := s.state.Load()
 	var ids []string
 	if req.Resource == "" || req.Resource == "*" { // fast path
@@ -1104,8 +1633,10 @@ func (s *Server) listIdentities(resp *api.Response, req *api.Request) {
 
 	slices.Sort(ids)
 
+	ids, cursor := page.Cut(page.Seek(ids))
 	api.ReplyWith(resp, http.StatusOK, api.ListIdentitiesResponse{
 		Identities: ids,
+		NextCursor: cursor,
 	})
 }
 
@@ -1149,6 +1680,202 @@ func (s *Server) selfDescribeIdentity(resp *api.Response, req *api.Request) {
 	})
 }
 
+func (s *Server) listStaleIdentities(resp *api.Response, req *api.Request) {
+	if !validPattern(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "listing pattern '%s' is empty, too long or is invalid", req.Resource)
+		return
+	}
+	cutoff, err := staleCutoff(req)
+	if err != nil {
+		resp.Failr(err)
+		return
+	}
+
+	state := s.state.Load()
+	if req.Identity != state.Admin {
+		resp.Fail(http.StatusForbidden, "stale identity report requires the admin identity")
+		return
+	}
+	prefix := strings.TrimSuffix(req.Resource, "*")
+
+	since := state.Usage.Since()
+	ids := make([]api.StaleIdentityResponse, 0, 1+len(state.Identities))
+	if !state.Admin.IsUnknown() && strings.HasPrefix(state.Admin.String(), prefix) {
+		lastSeen := state.Usage.IdentityLastSeen(state.Admin)
+		if isStale(identityAdded(state, state.Admin), lastSeen, since, cutoff) {
+			ids = append(ids, api.StaleIdentityResponse{
+				Identity: state.Admin.String(),
+				IsAdmin:  true,
+				LastSeen: lastSeen,
+			})
+		}
+	}
+	for id, info := range state.Identities {
+		if !strings.HasPrefix(id.String(), prefix) {
+			continue
+		}
+		lastSeen := state.Usage.IdentityLastSeen(id)
+		if isStale(identityAdded(state, id), lastSeen, since, cutoff) {
+			ids = append(ids, api.StaleIdentityResponse{
+				Identity: id.String(),
+				Policy:   info.Name,
+				LastSeen: lastSeen,
+			})
+		}
+	}
+	slices.SortFunc(ids, func(a, b api.StaleIdentityResponse) int { return strings.Compare(a.Identity, b.Identity) })
+
+	api.ReplyWith(resp, http.StatusOK, api.ListStaleIdentitiesResponse{
+		Identities:   ids,
+		TrackedSince: since,
+	})
+}
+
+// identityAdded returns the point in time when the identity
+// has been added to the server configuration. It falls back to
+// the server's start time if not known.
+func identityAdded(state *serverState, id kes.Identity) time.Time {
+	if t := state.Usage.IdentityAdded(id); !t.IsZero() {
+		return t
+	}
+	return state.StartTime
+}
+
+// staleCutoff returns the point in time before which keys or
+// identities are considered stale. It is computed from the
+// optional 'days' query parameter and defaults to 90 days.
+func staleCutoff(req *api.Request) (time.Time, api.Error) {
+	const DefaultDays = 90
+
+	days := DefaultDays
+	if v := req.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return time.Time{}, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid number of days '%s'", v))
+		}
+		days = n
+	}
+	return time.Now().AddDate(0, 0, -days), nil
+}
+
+// keyFilter selects keys based on their name, age and algorithm.
+// The zero value matches all keys.
+type keyFilter struct {
+	Pattern       string // Glob pattern, as in path.Match, the key name must match
+	CreatedBefore time.Time
+	CreatedAfter  time.Time
+	Algorithm     crypto.SecretKeyType
+	Folder        string // Folder, or parent folder, the key must be in
+	Owner         string // Owner the key must be assigned to
+	Unowned       bool   // Whether the key must not have an owner
+}
+
+// parseKeyFilter parses a keyFilter from the optional 'pattern',
+// 'created_before', 'created_after', 'algorithm', 'folder', 'owner'
+// and 'unowned' query parameters. Points in time must be RFC 3339
+// timestamps.
+func parseKeyFilter(req *api.Request) (keyFilter, api.Error) {
+	query := req.URL.Query()
+
+	var filter keyFilter
+	if v := query.Get("pattern"); v != "" {
+		if _, err := path.Match(v, ""); err != nil {
+			return keyFilter{}, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid pattern '%s'", v))
+		}
+		filter.Pattern = v
+	}
+	if v := query.Get("created_before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return keyFilter{}, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid created_before timestamp '%s'", v))
+		}
+		filter.CreatedBefore = t
+	}
+	if v := query.Get("created_after"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return keyFilter{}, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid created_after timestamp '%s'", v))
+		}
+		filter.CreatedAfter = t
+	}
+	if v := query.Get("algorithm"); v != "" {
+		algorithm, err := crypto.ParseSecretKeyType(v)
+		if err != nil {
+			return keyFilter{}, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid algorithm '%s'", v))
+		}
+		filter.Algorithm = algorithm
+	}
+	if v := query.Get("folder"); v != "" {
+		if !validFolder(v) {
+			return keyFilter{}, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid folder '%s'", v))
+		}
+		filter.Folder = v
+	}
+	if v := query.Get("owner"); v != "" {
+		if !validOwner(v) {
+			return keyFilter{}, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid owner '%s'", v))
+		}
+		filter.Owner = v
+	}
+	if v := query.Get("unowned"); v != "" {
+		unowned, err := strconv.ParseBool(v)
+		if err != nil {
+			return keyFilter{}, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid unowned value '%s'", v))
+		}
+		filter.Unowned = unowned
+	}
+	if filter.Owner != "" && filter.Unowned {
+		return keyFilter{}, api.NewError(http.StatusBadRequest, "'owner' and 'unowned' are mutually exclusive")
+	}
+	return filter, nil
+}
+
+// IsEmpty reports whether the filter matches all keys.
+func (f *keyFilter) IsEmpty() bool {
+	return f.Pattern == "" && f.Folder == "" && !f.NeedsKey() && !f.NeedsOwner()
+}
+
+// NeedsOwner reports whether the filter has to inspect
+// the owner of the key to decide whether it matches.
+func (f *keyFilter) NeedsOwner() bool { return f.Owner != "" || f.Unowned }
+
+// MatchOwner reports whether the owner, which is nil for
+// unowned keys, matches the filter's owner constraints.
+func (f *keyFilter) MatchOwner(owner *keyOwner) bool {
+	if f.Unowned {
+		return owner == nil
+	}
+	return f.Owner == "" || (owner != nil && owner.Owner == f.Owner)
+}
+
+// NeedsKey reports whether the filter has to inspect the
+// key itself, and not just its name, to decide whether it
+// matches.
+func (f *keyFilter) NeedsKey() bool {
+	return !f.CreatedBefore.IsZero() || !f.CreatedAfter.IsZero() || f.Algorithm != 0
+}
+
+// MatchName reports whether the key name matches the filter's pattern.
+func (f *keyFilter) MatchName(name string) bool {
+	if f.Pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(f.Pattern, name) // The pattern has been validated by parseKeyFilter
+	return ok
+}
+
+// MatchKey reports whether the key matches the filter's
+// creation time and algorithm constraints.
+func (f *keyFilter) MatchKey(key crypto.KeyVersion) bool {
+	if !f.CreatedBefore.IsZero() && !key.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && !key.CreatedAt.After(f.CreatedAfter) {
+		return false
+	}
+	return f.Algorithm == 0 || key.Key.Type() == f.Algorithm
+}
+
 func (s *Server) logError(resp *api.Response, req *api.Request) {
 	resp.Header().Set(headers.ContentType, headers.ContentTypeJSONLines)
 	resp.WriteHeader(http.StatusOK)
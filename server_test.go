@@ -15,6 +15,7 @@ import (
 	"testing"
 	"time"
 
+	xhttp "github.com/minio/kes/internal/http"
 	"github.com/minio/kms-go/kes"
 )
 
@@ -124,13 +125,15 @@ func defaultClient(endpoint string) *kes.Client {
 	rootCAs := x509.NewCertPool()
 	rootCAs.AddCert(defaultServerCertificate().Leaf)
 
-	return kes.NewClientWithConfig(endpoint, &tls.Config{
+	client := kes.NewClientWithConfig(endpoint, &tls.Config{
 		MinVersion: tls.VersionTLS12,
 		RootCAs:    rootCAs,
 		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
 			return &clientCert, nil
 		},
 	})
+	client.HTTPClient.Transport = xhttp.LegacyErrors{RoundTripper: client.HTTPClient.Transport}
+	return client
 }
 
 func newLocalListener() net.Listener {
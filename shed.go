@@ -0,0 +1,121 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"net/http"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+)
+
+// Request priorities. Clients set the priority of a request via
+// the "Request-Priority" header. Under load, the server sheds low
+// priority requests first.
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+// highPriorityRoutes are the API routes whose requests have a high
+// priority unless the client specifies otherwise. Usually, clients
+// wait for these latency-sensitive cryptographic operations before
+// they can proceed.
+var highPriorityRoutes = map[string]bool{
+	api.PathKeyEncrypt:  true,
+	api.PathKeyDecrypt:  true,
+	api.PathKeyGenerate: true,
+	api.PathKeyHMAC:     true,
+}
+
+// loadShedder limits the number of concurrent requests.
+type loadShedder struct {
+	maxRequests int64
+}
+
+// newLoadShedder returns a new load shedder for the given
+// configuration, or nil if conf is nil.
+func newLoadShedder(conf *LoadSheddingConfig) *loadShedder {
+	if conf == nil || conf.MaxRequests <= 0 {
+		return nil
+	}
+	return &loadShedder{maxRequests: int64(conf.MaxRequests)}
+}
+
+// limit returns the number of concurrent requests at which
+// requests with the given priority get shed. Low priority
+// requests get shed at 50% and normal priority requests at
+// 80% of the max. number of requests. Hence, some capacity
+// is left for high priority requests under load.
+func (l *loadShedder) limit(priority string) int64 {
+	switch priority {
+	case PriorityLow:
+		return max(l.maxRequests/2, 1)
+	case PriorityNormal:
+		return max(l.maxRequests*4/5, 1)
+	default:
+		return l.maxRequests
+	}
+}
+
+// shed returns the route with a handler that rejects requests
+// once the server handles too many concurrent requests of the
+// same or a higher priority.
+//
+// The priority is derived once the request has been authenticated.
+// Hence, unauthenticated clients cannot claim capacity reserved for
+// high priority requests. Requests to routes that do not verify the
+// client identity always have the route's default priority.
+//
+// Streaming routes, i.e. routes without a timeout, and health
+// probes are never shed. Otherwise, long-lived connections would
+// use up capacity and orchestrators would restart an overloaded
+// but healthy server.
+func (s *Server) shed(route api.Route) api.Route {
+	switch route.Path {
+	case api.PathVersion, api.PathReady, api.PathStatus, api.PathBackend, api.PathMetrics:
+		return route
+	}
+	if route.Timeout <= 0 {
+		return route
+	}
+
+	defaultPriority := PriorityNormal
+	if highPriorityRoutes[route.Path] {
+		defaultPriority = PriorityHigh
+	}
+	handler := route.Handler
+	route.Handler = api.HandlerFunc(func(resp *api.Response, req *api.Request) {
+		state := s.state.Load()
+		if state.LoadShedding == nil {
+			handler.ServeAPI(resp, req)
+			return
+		}
+
+		priority := defaultPriority
+		if v := req.Header.Get(headers.RequestPriority); v != "" && !req.Identity.IsUnknown() {
+			switch v {
+			case PriorityLow, PriorityNormal, PriorityHigh:
+				priority = v
+			default:
+				resp.Failf(http.StatusBadRequest, "invalid request priority '%s': expected '%s', '%s' or '%s'", v, PriorityLow, PriorityNormal, PriorityHigh)
+				return
+			}
+		}
+
+		active := s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+
+		if active > state.LoadShedding.limit(priority) {
+			state.Metrics.Shed(priority)
+			resp.Header().Set(headers.RetryAfter, "1")
+			resp.Failf(http.StatusServiceUnavailable, "server is overloaded: %s priority request rejected", priority)
+			return
+		}
+		handler.ServeAPI(resp, req)
+	})
+	return route
+}
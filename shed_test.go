@@ -0,0 +1,163 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+)
+
+func TestLoadShedding(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		LoadShedding: &LoadSheddingConfig{MaxRequests: 10},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	// Simulate concurrent requests that are still in progress.
+	for i, test := range loadSheddingTests {
+		srv.inFlight.Store(test.InFlight)
+
+		req, err := http.NewRequestWithContext(ctx, test.Method, url+test.Path, strings.NewReader(test.Body))
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		for k, v := range test.Headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Test %d: failed to send request: %v", i, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.StatusCode {
+			t.Fatalf("Test %d: status code mismatch: got '%d' - want '%d'", i, resp.StatusCode, test.StatusCode)
+		}
+		if resp.StatusCode == http.StatusServiceUnavailable && resp.Header.Get(headers.RetryAfter) == "" {
+			t.Fatalf("Test %d: shed response contains no '%s' header", i, headers.RetryAfter)
+		}
+	}
+
+	// Requests are only shed once authenticated. Hence, unauthenticated
+	// requests get rejected even if the server is overloaded.
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(defaultServerCertificate().Leaf)
+	anonymous := http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: rootCAs},
+		},
+	}
+	srv.inFlight.Store(10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+api.PathKeyDescribe+"my-key", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set(headers.RequestPriority, PriorityHigh)
+	resp, err := anonymous.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Unauthenticated request has not been rejected: got status '%d' - want '%d'", resp.StatusCode, http.StatusBadRequest)
+	}
+	srv.inFlight.Store(0)
+}
+
+var loadSheddingTests = []struct {
+	Method     string
+	Path       string
+	Body       string
+	Headers    map[string]string
+	InFlight   int64
+	StatusCode int
+}{
+	{ // 0
+		Method:     http.MethodGet,
+		Path:       api.PathKeyDescribe + "my-key",
+		InFlight:   4,
+		Headers:    map[string]string{headers.RequestPriority: PriorityLow},
+		StatusCode: http.StatusOK,
+	},
+	{ // 1
+		Method:     http.MethodGet,
+		Path:       api.PathKeyDescribe + "my-key",
+		InFlight:   5,
+		Headers:    map[string]string{headers.RequestPriority: PriorityLow},
+		StatusCode: http.StatusServiceUnavailable,
+	},
+	{ // 2
+		Method:     http.MethodGet,
+		Path:       api.PathKeyDescribe + "my-key",
+		InFlight:   7,
+		StatusCode: http.StatusOK,
+	},
+	{ // 3
+		Method:     http.MethodGet,
+		Path:       api.PathKeyDescribe + "my-key",
+		InFlight:   8,
+		StatusCode: http.StatusServiceUnavailable,
+	},
+	{ // 4
+		Method:     http.MethodPut,
+		Path:       api.PathKeyEncrypt + "my-key",
+		Body:       `{"plaintext":"SGVsbG8gV29ybGQ="}`,
+		InFlight:   9,
+		StatusCode: http.StatusOK,
+	},
+	{ // 5
+		Method:     http.MethodPut,
+		Path:       api.PathKeyEncrypt + "my-key",
+		Body:       `{"plaintext":"SGVsbG8gV29ybGQ="}`,
+		InFlight:   9,
+		Headers:    map[string]string{headers.RequestPriority: PriorityLow},
+		StatusCode: http.StatusServiceUnavailable,
+	},
+	{ // 6
+		Method:     http.MethodPut,
+		Path:       api.PathKeyEncrypt + "my-key",
+		Body:       `{"plaintext":"SGVsbG8gV29ybGQ="}`,
+		InFlight:   10,
+		StatusCode: http.StatusServiceUnavailable,
+	},
+	{ // 7
+		Method:     http.MethodGet,
+		Path:       api.PathStatus,
+		InFlight:   10,
+		StatusCode: http.StatusOK,
+	},
+	{ // 8
+		Method:     http.MethodGet,
+		Path:       api.PathKeyDescribe + "my-key",
+		Headers:    map[string]string{headers.RequestPriority: "urgent"},
+		StatusCode: http.StatusBadRequest,
+	},
+	{ // 9
+		Method:     http.MethodGet,
+		Path:       api.PathKeyDescribe + "my-key",
+		Headers:    map[string]string{headers.RequestTimeout: "5s"},
+		StatusCode: http.StatusOK,
+	},
+	{ // 10
+		Method:     http.MethodGet,
+		Path:       api.PathKeyDescribe + "my-key",
+		Headers:    map[string]string{headers.RequestTimeout: "soon"},
+		StatusCode: http.StatusBadRequest,
+	},
+}
@@ -0,0 +1,260 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+// shredReceiptPrefix is the prefix of all crypto-shred receipts at
+// the key store. It is followed by the zero-padded shred time in
+// nanoseconds and the key name such that receipts sort by time.
+const shredReceiptPrefix = "-shred-"
+
+// shredReceipt is a signed crypto-shred receipt as stored
+// at the key store.
+type shredReceipt struct {
+	Key        string    `json:"key"`
+	KeyID      string    `json:"key_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	ShreddedAt time.Time `json:"shredded_at"`
+	ShreddedBy string    `json:"shredded_by"`
+	Prev       []byte    `json:"prev,omitempty"`
+	Signature  []byte    `json:"signature"`
+}
+
+// message returns the signed receipt message.
+func (r *shredReceipt) message() *crypto.ShredReceipt {
+	return &crypto.ShredReceipt{
+		Key:        r.Key,
+		KeyID:      r.KeyID,
+		CreatedAt:  r.CreatedAt,
+		CreatedBy:  r.CreatedBy,
+		ShreddedAt: r.ShreddedAt,
+		ShreddedBy: r.ShreddedBy,
+		Prev:       r.Prev,
+	}
+}
+
+// response converts the receipt into its API representation.
+func (r *shredReceipt) response(pub ed25519.PublicKey) api.ShredReceipt {
+	return api.ShredReceipt{
+		Key:        r.Key,
+		KeyID:      r.KeyID,
+		CreatedAt:  r.CreatedAt,
+		CreatedBy:  r.CreatedBy,
+		ShreddedAt: r.ShreddedAt,
+		ShreddedBy: r.ShreddedBy,
+		Prev:       r.Prev,
+		Hash:       r.message().Hash(),
+		Signature:  r.Signature,
+		PublicKey:  pub,
+	}
+}
+
+// shredReceiptEntry returns the key store entry of the receipt
+// of the key with the given name shredded at t.
+func shredReceiptEntry(name string, t time.Time) string {
+	return fmt.Sprintf("%s%020d-%s", shredReceiptPrefix, t.UnixNano(), name)
+}
+
+// listShredReceipts returns the key store entries of all
+// crypto-shred receipts, sorted from oldest to newest.
+func listShredReceipts(ctx context.Context, store KeyStore) ([]string, error) {
+	names, _, err := store.List(ctx, shredReceiptPrefix, -1)
+	if err != nil {
+		return nil, err
+	}
+	names = slices.DeleteFunc(names, func(name string) bool { return !strings.HasPrefix(name, shredReceiptPrefix) })
+	slices.Sort(names)
+	return names, nil
+}
+
+// loadShredReceipt reads the receipt stored at the given entry.
+func loadShredReceipt(ctx context.Context, store KeyStore, entry string) (*shredReceipt, error) {
+	b, err := store.Get(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+	var r shredReceipt
+	if err = json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// shredKey deletes a key and records a signed receipt. The receipt
+// commits to the key's fingerprint and creation metadata, the shred
+// time and the identity that shredded the key. It also commits to
+// the previous receipt such that receipts cannot be removed from
+// the chain unnoticed.
+//
+// Receipts are signed with the Merkle root signing key. Hence,
+// auditors have to trust a single public key only. The receipt is
+// stored before the key gets deleted such that there is no deleted
+// key without receipt.
+func (s *Server) shredKey(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+	state := s.state.Load()
+
+	fail := func(err error, msg string) {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, msg)
+	}
+
+	s.shreds.Lock()
+	defer s.shreds.Unlock()
+
+	key, err := state.Keys.Get(req.Context(), req.Resource)
+	if err != nil {
+		fail(err, "failed to read key")
+		return
+	}
+	b, err := crypto.EncodeKeyVersion(key)
+	if err != nil {
+		fail(err, "failed to encode key")
+		return
+	}
+	priv, err := loadMerkleKey(req.Context(), state.Keys.store)
+	if err != nil {
+		fail(err, "failed to load receipt signing key")
+		return
+	}
+
+	receipt := &shredReceipt{
+		Key:        req.Resource,
+		KeyID:      crypto.KeyID(b),
+		CreatedAt:  key.CreatedAt,
+		CreatedBy:  key.CreatedBy.String(),
+		ShreddedAt: time.Now().UTC(),
+		ShreddedBy: req.Identity.String(),
+	}
+	entries, err := listShredReceipts(req.Context(), state.Keys.store)
+	if err != nil {
+		fail(err, "failed to list receipts")
+		return
+	}
+	if len(entries) > 0 {
+		prev, err := loadShredReceipt(req.Context(), state.Keys.store, entries[len(entries)-1])
+		if err != nil {
+			fail(err, "failed to read previous receipt")
+			return
+		}
+		receipt.Prev = prev.message().Hash()
+	}
+	receipt.Signature = ed25519.Sign(priv, receipt.message().Message())
+
+	entry := shredReceiptEntry(req.Resource, receipt.ShreddedAt)
+	b, err = json.Marshal(receipt)
+	if err != nil {
+		fail(err, "failed to encode receipt")
+		return
+	}
+	if err = state.Keys.store.Create(req.Context(), entry, b); err != nil {
+		fail(err, "failed to store receipt")
+		return
+	}
+
+	if err = state.Keys.Delete(req.Context(), req.Resource); err != nil {
+		// The key has not been shredded. Hence, remove the receipt again.
+		if dErr := state.Keys.store.Delete(context.WithoutCancel(req.Context()), entry); dErr != nil {
+			state.Log.ErrorContext(req.Context(), fmt.Sprintf("failed to remove receipt '%s': %v", entry, dErr), "req", req)
+		}
+		fail(err, "failed to delete key")
+		return
+	}
+
+	state.Usage.ForgetKey(req.Resource)
+	state.Changes.Record(api.ChangeObjectKey, api.ChangeDelete, req.Resource, req.Identity)
+
+	response := receipt.response(priv.Public().(ed25519.PublicKey))
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(
+		fmt.Sprintf("secret key '%s' crypto-shredded: receipt %x", req.Resource, response.Hash),
+		StatusOK,
+		req,
+	)
+	api.ReplyWith(resp, StatusOK, response)
+}
+
+// shredReceipts returns all crypto-shred receipts of the
+// key with the given name, sorted from oldest to newest.
+func (s *Server) shredReceipts(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+	state := s.state.Load()
+
+	fail := func(err error, msg string) {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, msg)
+	}
+
+	entries, err := listShredReceipts(req.Context(), state.Keys.store)
+	if err != nil {
+		fail(err, "failed to list receipts")
+		return
+	}
+
+	var receipts []api.ShredReceipt
+	var pub ed25519.PublicKey
+	for _, entry := range entries {
+		// Entries have the form: <prefix><time>-<name>
+		if len(entry) <= len(shredReceiptPrefix)+21 || entry[len(shredReceiptPrefix)+21:] != req.Resource {
+			continue
+		}
+		if pub == nil {
+			priv, err := loadMerkleKey(req.Context(), state.Keys.store)
+			if err != nil {
+				fail(err, "failed to load receipt signing key")
+				return
+			}
+			pub = priv.Public().(ed25519.PublicKey)
+		}
+
+		receipt, err := loadShredReceipt(req.Context(), state.Keys.store, entry)
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			continue // The receipt has been removed in the meantime
+		}
+		if err != nil {
+			fail(err, "failed to read receipt")
+			return
+		}
+		receipts = append(receipts, receipt.response(pub))
+	}
+	if len(receipts) == 0 {
+		resp.Failf(http.StatusNotFound, "no receipt for key '%s' found", req.Resource)
+		return
+	}
+	api.ReplyWith(resp, http.StatusOK, api.ShredReceiptsResponse{
+		Receipts: receipts,
+	})
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestShredKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{Keys: &MemKeyStore{}})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	send := func(method, path string, v any) int {
+		req, err := http.NewRequestWithContext(ctx, method, url+path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return resp.StatusCode
+	}
+	verify := func(r api.ShredReceipt) {
+		msg := &crypto.ShredReceipt{
+			Key:        r.Key,
+			KeyID:      r.KeyID,
+			CreatedAt:  r.CreatedAt,
+			CreatedBy:  r.CreatedBy,
+			ShreddedAt: r.ShreddedAt,
+			ShreddedBy: r.ShreddedBy,
+			Prev:       r.Prev,
+		}
+		if !ed25519.Verify(r.PublicKey, msg.Message(), r.Signature) {
+			t.Fatalf("Invalid signature of receipt of key '%s'", r.Key)
+		}
+		if !bytes.Equal(msg.Hash(), r.Hash) {
+			t.Fatalf("Receipt hash mismatch of key '%s'", r.Key)
+		}
+	}
+
+	var receipts []api.ShredReceipt
+	for _, name := range []string{"my-key", "my-key-2"} {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+
+		var receipt api.ShredReceipt
+		if code := send(http.MethodDelete, api.PathKeyShred+name, &receipt); code != http.StatusOK {
+			t.Fatalf("Failed to shred key '%s': status code '%d'", name, code)
+		}
+		verify(receipt)
+		if receipt.Key != name || receipt.ShreddedBy != srv.state.Load().Admin.String() {
+			t.Fatalf("Invalid receipt of key '%s': %+v", name, receipt)
+		}
+		if _, err := client.DescribeKey(ctx, name); !errors.Is(err, kes.ErrKeyNotFound) {
+			t.Fatalf("Key '%s' has not been shredded: %v", name, err)
+		}
+		receipts = append(receipts, receipt)
+	}
+	if receipts[0].Prev != nil {
+		t.Fatalf("First receipt refers to a previous receipt: %x", receipts[0].Prev)
+	}
+	if !bytes.Equal(receipts[1].Prev, receipts[0].Hash) {
+		t.Fatalf("Receipt chain is broken: got '%x' - want '%x'", receipts[1].Prev, receipts[0].Hash)
+	}
+
+	var resp api.ShredReceiptsResponse
+	if code := send(http.MethodGet, api.PathKeyReceipt+"my-key", &resp); code != http.StatusOK {
+		t.Fatalf("Failed to get receipts: status code '%d'", code)
+	}
+	if len(resp.Receipts) != 1 || !bytes.Equal(resp.Receipts[0].Hash, receipts[0].Hash) {
+		t.Fatalf("Receipts mismatch: got %+v", resp.Receipts)
+	}
+	verify(resp.Receipts[0])
+
+	if code := send(http.MethodGet, api.PathKeyReceipt+"my-key-3", nil); code != http.StatusNotFound {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", code, http.StatusNotFound)
+	}
+	if code := send(http.MethodDelete, api.PathKeyShred+"my-key", nil); code != http.StatusNotFound {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,385 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kms-go/kes"
+)
+
+// splitStagePrefix is the prefix of shares staged by a re-split.
+// It is followed by the entry name. Staged shares are stored at
+// the backends of a SplitKeyStore and never returned by List.
+const splitStagePrefix = "-split-"
+
+// splitOrphanAge is the min. age of an orphaned share, i.e. a share
+// without a counterpart, before it is removed by a repair. Younger
+// shares may belong to an entry that is being created.
+const splitOrphanAge = 1 * time.Hour
+
+// splitShareVersion is the version of the share encoding:
+//
+//	version (1 byte) | split ID (16 bytes) | created at (8 bytes) | share
+const splitShareVersion = 1
+
+const splitShareHeaderSize = 1 + 16 + 8
+
+// SplitKeyStore is a KeyStore that splits every entry into two shares
+// stored at two different backends. The shares are XOR secret shares:
+// one share is random and the other one is the value XOR the random
+// share. Hence, a single backend reveals nothing about the entries.
+//
+// Get fetches both shares and reconstructs the value transparently.
+// If one backend is not available, no entry can be read or written.
+//
+// After a backend has been recovered, e.g. restored from a backup,
+// the "split-repair" job re-splits all entries with new random shares
+// and removes orphaned shares left over by incomplete operations.
+type SplitKeyStore struct {
+	Primary   KeyStore // Stores the random shares
+	Secondary KeyStore // Stores the value XOR the random shares
+}
+
+var _ KeyStore = (*SplitKeyStore)(nil) // compiler check
+
+func (s *SplitKeyStore) String() string {
+	return fmt.Sprintf("Split: %v | %v", s.Primary, s.Secondary)
+}
+
+// Status returns the state of the SplitKeyStore. The latency is the
+// max. latency of both backends. It returns an error if any backend
+// is not available.
+func (s *SplitKeyStore) Status(ctx context.Context) (KeyStoreState, error) {
+	primary, err := s.Primary.Status(ctx)
+	if err != nil {
+		return primary, err
+	}
+	secondary, err := s.Secondary.Status(ctx)
+	if err != nil {
+		return secondary, err
+	}
+	return KeyStoreState{Latency: max(primary.Latency, secondary.Latency)}, nil
+}
+
+// Create splits the value into two shares and stores them at the
+// backends if and only if no such entry exists. Otherwise, Create
+// returns kes.ErrKeyExists.
+func (s *SplitKeyStore) Create(ctx context.Context, name string, value []byte) error {
+	primary, secondary, err := splitValue(value, time.Now())
+	if err != nil {
+		return err
+	}
+	if err = s.Primary.Create(ctx, name, primary); err != nil {
+		return err
+	}
+	if err = s.Secondary.Create(ctx, name, secondary); err != nil {
+		// Remove the primary share such that the entry can be created
+		// again. If this fails, the orphaned share is removed by the
+		// next repair.
+		s.Primary.Delete(ctx, name)
+		return err
+	}
+	return nil
+}
+
+// Delete removes the shares of the entry, including any staged
+// shares, from both backends. It may return either no error or
+// kes.ErrKeyNotFound if no such entry exists.
+func (s *SplitKeyStore) Delete(ctx context.Context, name string) error {
+	found := false
+	for _, store := range []KeyStore{s.Primary, s.Secondary} {
+		for _, n := range []string{name, splitStagePrefix + name} {
+			err := store.Delete(ctx, n)
+			if err == nil {
+				found = true
+				continue
+			}
+			if !errors.Is(err, kes.ErrKeyNotFound) {
+				return err
+			}
+		}
+	}
+	if !found {
+		return kes.ErrKeyNotFound
+	}
+	return nil
+}
+
+// Get fetches the shares of the entry from both backends and returns
+// the reconstructed value. It returns kes.ErrKeyNotFound if no such
+// entry exists.
+func (s *SplitKeyStore) Get(ctx context.Context, name string) ([]byte, error) {
+	value, err := s.get(ctx, name)
+	if errors.Is(err, errSplitInconsistent) {
+		// The shares may have been replaced by a concurrent re-split
+		// while reading them. Hence, read them once more.
+		value, err = s.get(ctx, name)
+	}
+	return value, err
+}
+
+// List returns the first n entry names, that start with the given
+// prefix, and the next prefix from which the listing should continue.
+// It lists the entries of the primary backend but never returns names
+// of staged shares.
+func (s *SplitKeyStore) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	names, next, err := s.Primary.List(ctx, prefix, n)
+	if err != nil {
+		return nil, "", err
+	}
+	filtered := names[:0]
+	for _, name := range names {
+		if !strings.HasPrefix(name, splitStagePrefix) {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered, next, nil
+}
+
+// Close closes both backends.
+func (s *SplitKeyStore) Close() error {
+	return errors.Join(s.Primary.Close(), s.Secondary.Close())
+}
+
+var errSplitInconsistent = errors.New("kes: split key store: inconsistent shares")
+
+// splitShares contains the shares of an entry fetched
+// from one backend.
+type splitShares struct {
+	Share  []byte // The share of the entry, if any
+	Staged []byte // The staged share of the entry, if any
+}
+
+// get reads the shares of the entry, and any staged shares, and
+// reconstructs the value from the first pair of matching shares.
+func (s *SplitKeyStore) get(ctx context.Context, name string) ([]byte, error) {
+	primary, secondary, err := s.shares(ctx, name, false)
+	if err != nil {
+		return nil, err
+	}
+	if primary.Share == nil && secondary.Share == nil {
+		return nil, kes.ErrKeyNotFound
+	}
+	if value, ok := joinShares(primary.Share, secondary.Share); ok {
+		return value, nil
+	}
+
+	// The shares don't match. Either a re-split has been interrupted
+	// or the entry is being created or deleted. Hence, try the staged
+	// shares of an ongoing or interrupted re-split.
+	if primary, secondary, err = s.shares(ctx, name, true); err != nil {
+		return nil, err
+	}
+	for _, p := range [][]byte{primary.Share, primary.Staged} {
+		for _, q := range [][]byte{secondary.Share, secondary.Staged} {
+			if value, ok := joinShares(p, q); ok {
+				return value, nil
+			}
+		}
+	}
+	if primary.Share == nil || secondary.Share == nil {
+		return nil, kes.ErrKeyNotFound // The entry is either being created or deleted
+	}
+	return nil, errSplitInconsistent
+}
+
+// shares fetches the shares of the entry from both backends
+// concurrently. If staged is true, it also fetches staged shares.
+func (s *SplitKeyStore) shares(ctx context.Context, name string, staged bool) (primary, secondary splitShares, err error) {
+	fetch := func(store KeyStore, shares *splitShares) error {
+		var err error
+		if shares.Share, err = store.Get(ctx, name); err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+			return err
+		}
+		if !staged {
+			return nil
+		}
+		if shares.Staged, err = store.Get(ctx, splitStagePrefix+name); err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+			return err
+		}
+		return nil
+	}
+
+	var (
+		wg     sync.WaitGroup
+		errP   error
+		errS   error
+		shareP splitShares
+		shareS splitShares
+	)
+	wg.Add(2)
+	go func() { defer wg.Done(); errP = fetch(s.Primary, &shareP) }()
+	go func() { defer wg.Done(); errS = fetch(s.Secondary, &shareS) }()
+	wg.Wait()
+	if err = errors.Join(errP, errS); err != nil {
+		return splitShares{}, splitShares{}, err
+	}
+	return shareP, shareS, nil
+}
+
+// resplit replaces the shares of the entry with new random shares.
+// The new shares are staged first such that the value can be
+// reconstructed at any point, even if the re-split is interrupted.
+func (s *SplitKeyStore) resplit(ctx context.Context, name string, value []byte) error {
+	primary, secondary, err := splitValue(value, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, stage := range []struct {
+		Store KeyStore
+		Share []byte
+	}{
+		{Store: s.Primary, Share: primary},
+		{Store: s.Secondary, Share: secondary},
+	} {
+		if err = setEntry(ctx, stage.Store, splitStagePrefix+name, stage.Share); err != nil {
+			return err
+		}
+	}
+	for _, replace := range []struct {
+		Store KeyStore
+		Share []byte
+	}{
+		{Store: s.Primary, Share: primary},
+		{Store: s.Secondary, Share: secondary},
+	} {
+		// If the share does not exist, the entry has been deleted
+		// concurrently. Then, it must not be created again.
+		if err = replace.Store.Delete(ctx, name); errors.Is(err, kes.ErrKeyNotFound) {
+			s.Primary.Delete(ctx, splitStagePrefix+name)
+			s.Secondary.Delete(ctx, splitStagePrefix+name)
+			return kes.ErrKeyNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err = replace.Store.Create(ctx, name, replace.Share); err != nil {
+			return err
+		}
+	}
+	for _, store := range []KeyStore{s.Primary, s.Secondary} {
+		if err = store.Delete(ctx, splitStagePrefix+name); err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// repair re-splits the entry, or removes its shares if they are
+// orphaned, i.e. the value cannot be reconstructed from them and
+// they are older than splitOrphanAge. It reports whether the entry
+// has been re-split.
+func (s *SplitKeyStore) repair(ctx context.Context, name string, now time.Time) (bool, error) {
+	value, err := s.Get(ctx, name)
+	if err == nil {
+		return true, s.resplit(ctx, name, value)
+	}
+	if !errors.Is(err, kes.ErrKeyNotFound) {
+		return false, err
+	}
+
+	primary, secondary, err := s.shares(ctx, name, true)
+	if err != nil {
+		return false, err
+	}
+	for _, orphan := range []struct {
+		Store KeyStore
+		Name  string
+		Share []byte
+	}{
+		{Store: s.Primary, Name: name, Share: primary.Share},
+		{Store: s.Primary, Name: splitStagePrefix + name, Share: primary.Staged},
+		{Store: s.Secondary, Name: name, Share: secondary.Share},
+		{Store: s.Secondary, Name: splitStagePrefix + name, Share: secondary.Staged},
+	} {
+		if orphan.Share == nil || now.Sub(shareCreatedAt(orphan.Share)) < splitOrphanAge {
+			continue
+		}
+		if err = orphan.Store.Delete(ctx, orphan.Name); err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// names returns the names of all entries with at least
+// one share, or staged share, at any backend.
+func (s *SplitKeyStore) names(ctx context.Context) ([]string, error) {
+	seen := map[string]bool{}
+	var names []string
+	for _, store := range []KeyStore{s.Primary, s.Secondary} {
+		list, _, err := store.List(ctx, "", -1)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range list {
+			name = strings.TrimPrefix(name, splitStagePrefix)
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// splitValue splits the value into a random primary share and a
+// secondary share that is the value XOR the primary share.
+func splitValue(value []byte, now time.Time) (primary, secondary []byte, err error) {
+	var header [splitShareHeaderSize]byte
+	header[0] = splitShareVersion
+	if _, err = rand.Read(header[1:17]); err != nil {
+		return nil, nil, err
+	}
+	binary.BigEndian.PutUint64(header[17:], uint64(now.Unix()))
+
+	primary = make([]byte, splitShareHeaderSize+len(value))
+	secondary = make([]byte, splitShareHeaderSize+len(value))
+	copy(primary, header[:])
+	copy(secondary, header[:])
+	if _, err = rand.Read(primary[splitShareHeaderSize:]); err != nil {
+		return nil, nil, err
+	}
+	for i, b := range value {
+		secondary[splitShareHeaderSize+i] = b ^ primary[splitShareHeaderSize+i]
+	}
+	return primary, secondary, nil
+}
+
+// joinShares reconstructs the value from the primary and secondary
+// share. It reports whether both shares belong to the same split.
+func joinShares(primary, secondary []byte) ([]byte, bool) {
+	if len(primary) < splitShareHeaderSize || len(primary) != len(secondary) {
+		return nil, false
+	}
+	if primary[0] != splitShareVersion || !bytes.Equal(primary[:17], secondary[:17]) {
+		return nil, false
+	}
+
+	value := make([]byte, len(primary)-splitShareHeaderSize)
+	for i := range value {
+		value[i] = primary[splitShareHeaderSize+i] ^ secondary[splitShareHeaderSize+i]
+	}
+	return value, true
+}
+
+// shareCreatedAt returns the time at which the share has
+// been created. It returns the zero time for invalid shares.
+func shareCreatedAt(share []byte) time.Time {
+	if len(share) < splitShareHeaderSize || share[0] != splitShareVersion {
+		return time.Time{}
+	}
+	return time.Unix(int64(binary.BigEndian.Uint64(share[17:25])), 0)
+}
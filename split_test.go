@@ -0,0 +1,142 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/minio/kms-go/kes"
+)
+
+func TestSplitKeyStore(t *testing.T) {
+	ctx := context.Background()
+	store := &SplitKeyStore{Primary: &MemKeyStore{}, Secondary: &MemKeyStore{}}
+
+	value := []byte("my-secret-key-value")
+	if err := store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if err := store.Create(ctx, "my-key", value); !errors.Is(err, kes.ErrKeyExists) {
+		t.Fatalf("Created entry twice: got '%v' - want '%v'", err, kes.ErrKeyExists)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get entry: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	for _, backend := range []KeyStore{store.Primary, store.Secondary} {
+		share, err := backend.Get(ctx, "my-key")
+		if err != nil {
+			t.Fatalf("Failed to get share: %v", err)
+		}
+		if bytes.Contains(share, value) {
+			t.Fatalf("Share contains the entry value: '%x'", share)
+		}
+	}
+
+	names, _, err := store.List(ctx, "", -1)
+	if err != nil || len(names) != 1 || names[0] != "my-key" {
+		t.Fatalf("Failed to list entries: got '%v': %v", names, err)
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete entry: %v", err)
+	}
+	if _, err = store.Get(ctx, "my-key"); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Got deleted entry: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+	if err = store.Delete(ctx, "my-key"); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Deleted entry twice: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+}
+
+func TestSplitKeyStoreRepair(t *testing.T) {
+	ctx := context.Background()
+	store := &SplitKeyStore{Primary: &MemKeyStore{}, Secondary: &MemKeyStore{}}
+
+	value := []byte("my-secret-key-value")
+	if err := store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	oldShare, _ := store.Primary.Get(ctx, "my-key")
+
+	// Simulate a re-split interrupted after replacing the primary share.
+	primary, secondary, err := splitValue(value, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to split value: %v", err)
+	}
+	store.Primary.Create(ctx, splitStagePrefix+"my-key", primary)
+	store.Secondary.Create(ctx, splitStagePrefix+"my-key", secondary)
+	store.Primary.Delete(ctx, "my-key")
+	store.Primary.Create(ctx, "my-key", primary)
+
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get entry after interrupted re-split: got '%s' - want '%s': %v", v, value, err)
+	}
+	names, _, err := store.List(ctx, "", -1)
+	if err != nil || len(names) != 1 {
+		t.Fatalf("Listed staged shares: got '%v': %v", names, err)
+	}
+
+	if resplit, err := store.repair(ctx, "my-key", time.Now()); err != nil || !resplit {
+		t.Fatalf("Failed to repair entry: %v", err)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get repaired entry: got '%s' - want '%s': %v", v, value, err)
+	}
+	if share, _ := store.Primary.Get(ctx, "my-key"); bytes.Equal(share, oldShare) || bytes.Equal(share, primary) {
+		t.Fatal("Repair did not re-split the entry")
+	}
+	for _, backend := range []KeyStore{store.Primary, store.Secondary} {
+		if _, err := backend.Get(ctx, splitStagePrefix+"my-key"); !errors.Is(err, kes.ErrKeyNotFound) {
+			t.Fatalf("Staged share has not been removed: %v", err)
+		}
+	}
+}
+
+func TestSplitKeyStoreOrphans(t *testing.T) {
+	ctx := context.Background()
+	store := &SplitKeyStore{Primary: &MemKeyStore{}, Secondary: &MemKeyStore{}}
+
+	now := time.Now()
+	for _, orphan := range []struct {
+		Name      string
+		CreatedAt time.Time
+	}{
+		{Name: "old", CreatedAt: now.Add(-2 * splitOrphanAge)},
+		{Name: "new", CreatedAt: now},
+	} {
+		primary, _, err := splitValue([]byte("value"), orphan.CreatedAt)
+		if err != nil {
+			t.Fatalf("Failed to split value: %v", err)
+		}
+		if err = store.Primary.Create(ctx, orphan.Name, primary); err != nil {
+			t.Fatalf("Failed to create share: %v", err)
+		}
+	}
+
+	if _, err := store.Get(ctx, "old"); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Got entry from a single share: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+
+	names, err := store.names(ctx)
+	if err != nil || len(names) != 2 {
+		t.Fatalf("Failed to list entry names: got '%v': %v", names, err)
+	}
+	for _, name := range names {
+		if resplit, err := store.repair(ctx, name, now); err != nil || resplit {
+			t.Fatalf("Failed to repair orphaned share '%s': %v", name, err)
+		}
+	}
+	if _, err := store.Primary.Get(ctx, "old"); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Old orphaned share has not been removed: %v", err)
+	}
+	if _, err := store.Primary.Get(ctx, "new"); err != nil {
+		t.Fatalf("New orphaned share has been removed: %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+	"golang.org/x/crypto/ssh"
+)
+
+// Default validity periods of SSH certificates.
+const (
+	defaultSSHTTL = 1 * time.Hour
+	maxSSHTTL     = 24 * time.Hour
+)
+
+// sshCAEntry is the key store entry of the SSH CA private key.
+// Like lock entries, it cannot collide with keys.
+const sshCAEntry = "-sshca"
+
+// sshClockSkew is subtracted from the start of the validity
+// period of SSH certificates to tolerate clock differences
+// between KES and SSH servers.
+const sshClockSkew = 1 * time.Minute
+
+// sshDefaultExtensions are the extensions of SSH user
+// certificates if a role does not specify any.
+var sshDefaultExtensions = map[string]string{
+	"permit-X11-forwarding":   "",
+	"permit-agent-forwarding": "",
+	"permit-port-forwarding":  "",
+	"permit-pty":              "",
+	"permit-user-rc":          "",
+}
+
+// sshRole controls which SSH certificates can be signed.
+type sshRole struct {
+	certType   uint32
+	principals []string
+	extensions map[string]string
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+}
+
+// newSSHRoles returns the SSH roles for the given role configurations.
+func newSSHRoles(conf map[string]*SSHRoleConfig) map[string]*sshRole {
+	roles := make(map[string]*sshRole, len(conf))
+	for name, c := range conf {
+		r := &sshRole{
+			certType:   ssh.UserCert,
+			principals: c.Principals,
+			extensions: c.Extensions,
+			defaultTTL: c.DefaultTTL,
+			maxTTL:     c.MaxTTL,
+		}
+		if c.CertType == SSHHostCert {
+			r.certType = ssh.HostCert
+		}
+		if r.certType == ssh.UserCert && r.extensions == nil {
+			r.extensions = sshDefaultExtensions
+		}
+		if r.defaultTTL <= 0 {
+			r.defaultTTL = defaultSSHTTL
+		}
+		if r.maxTTL <= 0 {
+			r.maxTTL = maxSSHTTL
+		}
+		roles[name] = r
+	}
+	return roles
+}
+
+// allowed reports whether the role allows signing
+// certificates for the given principal.
+func (r *sshRole) allowed(principal string) bool {
+	for _, pattern := range r.principals {
+		if ok, _ := path.Match(pattern, principal); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadSSHCA returns the SSH CA signer stored at the key store.
+// If no CA exists yet, it generates a new Ed25519 CA key.
+func loadSSHCA(ctx context.Context, store KeyStore) (ssh.Signer, error) {
+	b, err := store.Get(ctx, sshCAEntry)
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		var priv ed25519.PrivateKey
+		if _, priv, err = ed25519.GenerateKey(rand.Reader); err != nil {
+			return nil, err
+		}
+		var block *pem.Block
+		if block, err = ssh.MarshalPrivateKey(priv, "kes ssh ca"); err != nil {
+			return nil, err
+		}
+		b = pem.EncodeToMemory(block)
+
+		// Another request or KES server may have created the
+		// CA in the meantime. Then, use the existing CA key.
+		if err = store.Create(ctx, sshCAEntry, b); errors.Is(err, kes.ErrKeyExists) {
+			b, err = store.Get(ctx, sshCAEntry)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKey(b)
+}
+
+// describeSSHCA returns the public key of the SSH CA in
+// the authorized_keys format. SSH servers trust it via
+// the TrustedUserCAKeys option and SSH clients via a
+// @cert-authority entry in their known_hosts file.
+func (s *Server) describeSSHCA(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+
+	ca, err := loadSSHCA(req.Context(), state.Keys.store)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to load SSH CA")
+		return
+	}
+	api.ReplyWith(resp, http.StatusOK, api.DescribeSSHCAResponse{
+		PublicKey: strings.TrimSpace(string(ssh.MarshalAuthorizedKey(ca.PublicKey()))),
+	})
+}
+
+// signSSHKey signs a SSH public key with the SSH CA. The
+// requested principals and validity period must be allowed
+// by the SSH role.
+func (s *Server) signSSHKey(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	role, ok := state.SSHRoles[req.Resource]
+	if !ok {
+		resp.Failf(http.StatusNotFound, "SSH role '%s' does not exist", req.Resource)
+		return
+	}
+
+	var body api.SignSSHKeyRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid SSH sign request body")
+		return
+	}
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(body.PublicKey))
+	if err != nil {
+		resp.Fail(http.StatusBadRequest, "invalid SSH public key")
+		return
+	}
+	if _, ok := pubKey.(*ssh.Certificate); ok {
+		resp.Fail(http.StatusBadRequest, "invalid SSH public key: key is a certificate")
+		return
+	}
+	if len(body.Principals) == 0 {
+		resp.Fail(http.StatusBadRequest, "no SSH principals specified")
+		return
+	}
+	for _, principal := range body.Principals {
+		if !role.allowed(principal) {
+			resp.Failf(http.StatusForbidden, "SSH role '%s' does not allow principal '%s'", req.Resource, principal)
+			return
+		}
+	}
+	ttl := role.defaultTTL
+	if body.TTL != "" {
+		if ttl, err = time.ParseDuration(body.TTL); err != nil || ttl <= 0 {
+			resp.Failf(http.StatusBadRequest, "invalid certificate TTL '%s'", body.TTL)
+			return
+		}
+		if ttl > role.maxTTL {
+			resp.Failf(http.StatusBadRequest, "invalid certificate TTL '%v': must not exceed %v", ttl, role.maxTTL)
+			return
+		}
+	}
+
+	ca, err := loadSSHCA(req.Context(), state.Keys.store)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to load SSH CA")
+		return
+	}
+	var serial [8]byte
+	if _, err = rand.Read(serial[:]); err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to sign SSH key")
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	cert := &ssh.Certificate{
+		Key:             pubKey,
+		Serial:          binary.BigEndian.Uint64(serial[:]),
+		CertType:        role.certType,
+		KeyId:           fmt.Sprintf("%s:%s", req.Resource, req.Identity),
+		ValidPrincipals: body.Principals,
+		ValidAfter:      uint64(now.Add(-sshClockSkew).Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: role.extensions,
+		},
+	}
+	if err = cert.SignCert(rand.Reader, ca); err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to sign SSH key")
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(
+		fmt.Sprintf("SSH certificate '%d' signed with role '%s' for principals '%s'", cert.Serial, req.Resource, strings.Join(body.Principals, ",")),
+		StatusOK,
+		req,
+	)
+	api.ReplyWith(resp, StatusOK, api.SignSSHKeyResponse{
+		Certificate: strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert))),
+		Serial:      cert.Serial,
+		ExpiresAt:   now.Add(ttl),
+	})
+}
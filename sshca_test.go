@@ -0,0 +1,120 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/minio/kes/internal/api"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSHCA(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		SSHRoles: map[string]*SSHRoleConfig{
+			"ops": {
+				Principals: []string{"ubuntu", "ops-*"},
+			},
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+api.PathSSHCA, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to fetch SSH CA: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to fetch SSH CA: got status '%d'", resp.StatusCode)
+	}
+	var ca api.DescribeSSHCAResponse
+	if err = json.NewDecoder(resp.Body).Decode(&ca); err != nil {
+		t.Fatalf("Failed to decode SSH CA: %v", err)
+	}
+	resp.Body.Close()
+	caKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(ca.PublicKey))
+	if err != nil {
+		t.Fatalf("Failed to parse SSH CA public key: %v", err)
+	}
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate SSH key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("Failed to generate SSH key: %v", err)
+	}
+	sign := func(ttl string, principals ...string) (api.SignSSHKeyResponse, int) {
+		body, _ := json.Marshal(api.SignSSHKeyRequest{
+			PublicKey:  string(ssh.MarshalAuthorizedKey(sshPub)),
+			Principals: principals,
+			TTL:        ttl,
+		})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, url+api.PathSSHSign+"ops", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to sign SSH key: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var cert api.SignSSHKeyResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&cert); err != nil {
+				t.Fatalf("Failed to decode SSH certificate: %v", err)
+			}
+		}
+		return cert, resp.StatusCode
+	}
+
+	if _, code := sign("", "root"); code != http.StatusForbidden {
+		t.Fatalf("Signing a disallowed principal should fail with '%d' - got '%d'", http.StatusForbidden, code)
+	}
+	if _, code := sign("48h", "ubuntu"); code != http.StatusBadRequest {
+		t.Fatalf("Signing with a TTL exceeding the max. TTL should fail with '%d' - got '%d'", http.StatusBadRequest, code)
+	}
+
+	signed, code := sign("15m", "ubuntu", "ops-alice")
+	if code != http.StatusOK {
+		t.Fatalf("Failed to sign SSH key: got status '%d'", code)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signed.Certificate))
+	if err != nil {
+		t.Fatalf("Failed to parse SSH certificate: %v", err)
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		t.Fatalf("Invalid SSH certificate: got '%T'", key)
+	}
+	checker := ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), caKey.Marshal())
+		},
+	}
+	if err = checker.CheckCert("ops-alice", cert); err != nil {
+		t.Fatalf("SSH certificate is not valid: %v", err)
+	}
+	if cert.CertType != ssh.UserCert || cert.Serial != signed.Serial {
+		t.Fatalf("Invalid SSH certificate: type '%d' - serial '%d'", cert.CertType, cert.Serial)
+	}
+	if _, ok := cert.Permissions.Extensions["permit-pty"]; !ok {
+		t.Fatal("SSH user certificate should permit PTYs")
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"aead.dev/mem"
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+// DefaultStandbyInterval is the default interval in which
+// a standby server synchronizes with its primary.
+const DefaultStandbyInterval = 5 * time.Second
+
+// standby is the warm standby role of a server. A standby
+// mirrors the key cache and policies of its primary but does
+// not serve API requests until it gets promoted.
+type standby struct {
+	primary  string
+	client   *http.Client
+	interval time.Duration
+
+	promoted atomic.Bool
+	lastSync atomic.Int64 // Unix nanoseconds of last successful sync
+	cancel   context.CancelFunc
+}
+
+func newStandby(conf *StandbyConfig) *standby {
+	interval := conf.Interval
+	if interval <= 0 {
+		interval = DefaultStandbyInterval
+	}
+	tlsConf := conf.TLS.Clone()
+	if tlsConf.MinVersion == 0 {
+		tlsConf.MinVersion = tls.VersionTLS12
+	}
+	return &standby{
+		primary:  strings.TrimSuffix(conf.Primary, "/"),
+		interval: interval,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				TLSClientConfig:     tlsConf,
+				TLSHandshakeTimeout: 10 * time.Second,
+				ForceAttemptHTTP2:   true,
+			},
+			Timeout: 30 * time.Second,
+		},
+		cancel: func() {},
+	}
+}
+
+// IsActive reports whether the server acts as standby,
+// i.e. has not been promoted yet. It returns false if sb
+// is nil.
+func (sb *standby) IsActive() bool { return sb != nil && !sb.promoted.Load() }
+
+// LastSync returns the point in time of the last successful
+// synchronization with the primary, or the zero time.
+func (sb *standby) LastSync() time.Time {
+	if sb == nil {
+		return time.Time{}
+	}
+	if t := sb.lastSync.Load(); t > 0 {
+		return time.Unix(0, t)
+	}
+	return time.Time{}
+}
+
+// Promote stops the synchronization with the primary. It
+// reports whether sb has been promoted by this call.
+func (sb *standby) Promote() bool {
+	if sb == nil || !sb.promoted.CompareAndSwap(false, true) {
+		return false
+	}
+	sb.cancel()
+	return true
+}
+
+// Promote promotes a standby server to a regular server that
+// serves API requests. It returns an error if the server is not
+// a standby or has been promoted already.
+func (s *Server) Promote() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errors.New("kes: server is closed")
+	}
+	if !s.started {
+		return errors.New("kes: server not started")
+	}
+	if !s.state.Load().Standby.Promote() {
+		return errors.New("kes: server is not a standby")
+	}
+	return nil
+}
+
+// startStandby starts synchronizing the server with the primary
+// in the background until sb gets promoted or the server closed.
+func (s *Server) startStandby(sb *standby) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sb.cancel = cancel
+
+	go func() {
+		ticker := time.NewTicker(sb.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.syncStandby(ctx, sb); err != nil && ctx.Err() == nil {
+				s.state.Load().Log.WarnContext(ctx, fmt.Sprintf("standby: failed to sync with primary '%s': %v", sb.primary, err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// syncStandby fetches the key cache and policies from the
+// primary and applies them to the server.
+func (s *Server) syncStandby(ctx context.Context, sb *standby) error {
+	const MaxResponseSize = 64 * mem.MB
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sb.primary+api.PathCacheSync, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := sb.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("primary responded with '%s'", resp.Status)
+	}
+
+	var sync api.CacheSyncResponse
+	if err := json.NewDecoder(mem.LimitReader(resp.Body, MaxResponseSize)).Decode(&sync); err != nil {
+		return err
+	}
+
+	keys := make(map[string]crypto.KeyVersion, len(sync.Keys))
+	for _, key := range sync.Keys {
+		k, err := crypto.ParseKeyVersion([]byte(key.Key))
+		if err != nil {
+			return fmt.Errorf("invalid key '%s': %v", key.Name, err)
+		}
+		keys[key.Name] = k
+	}
+	policies := make(map[string]Policy, len(sync.Policies))
+	for name, policy := range sync.Policies {
+		p := Policy{
+			Allow:      make(map[string]kes.Rule, len(policy.Allow)),
+			Deny:       make(map[string]kes.Rule, len(policy.Deny)),
+			Identities: make([]kes.Identity, 0, len(policy.Identities)),
+		}
+		for _, pattern := range policy.Allow {
+			p.Allow[pattern] = kes.Rule{}
+		}
+		for _, pattern := range policy.Deny {
+			p.Deny[pattern] = kes.Rule{}
+		}
+		for _, id := range policy.Identities {
+			p.Identities = append(p.Identities, kes.Identity(id))
+		}
+		if policy.Geo != nil {
+			p.Geo = &GeoCondition{
+				Paths:      policy.Geo.Paths,
+				Countries:  policy.Geo.Countries,
+				Continents: policy.Geo.Continents,
+			}
+		}
+		policies[name] = p
+	}
+
+	if !sb.IsActive() { // Don't overwrite policies once promoted
+		return nil
+	}
+	if err := s.UpdatePolicies(policies); err != nil {
+		return err
+	}
+
+	// The primary removes deleted keys from its cache. Hence,
+	// any cached key the primary does not have anymore must not
+	// remain usable once the standby gets promoted.
+	cache := s.state.Load().Keys
+	for name := range cache.Snapshot() {
+		if _, ok := keys[name]; !ok {
+			cache.Evict(name)
+		}
+	}
+	for name, key := range keys {
+		cache.Set(name, key)
+	}
+	sb.lastSync.Store(time.Now().UnixNano())
+	return nil
+}
+
+// syncCache returns the key cache and policies of the server
+// such that a standby can mirror them.
+//
+// The response contains all cached keys in plaintext. Hence,
+// only the admin and the standby identities can sync the cache.
+func (s *Server) syncCache(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	if req.Identity != state.Admin && !slices.Contains(state.Standbys, req.Identity) {
+		resp.Fail(http.StatusForbidden, "cache sync API requires the admin or a standby identity")
+		return
+	}
+
+	var sync api.CacheSyncResponse
+	for name, key := range state.Keys.Snapshot() {
+		b, err := crypto.EncodeKeyVersion(key)
+		if err != nil {
+			state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+			resp.Fail(http.StatusInternalServerError, "failed to encode key")
+			return
+		}
+		sync.Keys = append(sync.Keys, api.SyncKey{
+			Name: name,
+			Key:  string(b),
+		})
+	}
+
+	sync.Policies = make(map[string]api.SyncPolicy, len(state.Policies))
+	for name, policy := range state.Policies {
+		p := api.SyncPolicy{
+			Allow: make([]string, 0, len(policy.Allow)),
+			Deny:  make([]string, 0, len(policy.Deny)),
+		}
+		for pattern := range policy.Allow {
+			p.Allow = append(p.Allow, pattern)
+		}
+		for pattern := range policy.Deny {
+			p.Deny = append(p.Deny, pattern)
+		}
+		if geo, ok := state.GeoFence.Condition(name); ok {
+			p.Geo = &api.SyncGeoPolicy{
+				Paths:      geo.Paths,
+				Countries:  geo.Countries,
+				Continents: geo.Continents,
+			}
+		}
+		sync.Policies[name] = p
+	}
+	for id, entry := range state.Identities {
+		p := sync.Policies[entry.Name]
+		p.Identities = append(p.Identities, id.String())
+		sync.Policies[entry.Name] = p
+	}
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(
+		fmt.Sprintf("cache synced: %d keys", len(sync.Keys)),
+		StatusOK,
+		req,
+	)
+	api.ReplyWith(resp, StatusOK, sync)
+}
+
+// fetchKey returns the named key such that a KES server,
+// acting as caching proxy in front of this server, can
+// serve it.
+//
+// Like the cache sync API, the response contains the key
+// in plaintext. Hence, only the admin and the standby
+// identities can fetch keys. Time-locked and disabled keys
+// cannot be fetched.
+func (s *Server) fetchKey(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	if req.Identity != state.Admin && !slices.Contains(state.Standbys, req.Identity) {
+		resp.Fail(http.StatusForbidden, "cache key API requires the admin or a standby identity")
+		return
+	}
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+	if keyState, _ := state.Lifecycle.State(req.Resource, time.Now()); keyState == keyStateDisabled {
+		resp.Failf(http.StatusForbidden, "key '%s' is disabled", req.Resource)
+		return
+	}
+
+	key, err := state.Keys.Use(req.Context(), req.Resource)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to read key")
+		return
+	}
+	b, err := crypto.EncodeKeyVersion(key)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to encode key")
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(
+		fmt.Sprintf("cache key fetched: '%s'", req.Resource),
+		StatusOK,
+		req,
+	)
+	api.ReplyWith(resp, StatusOK, api.SyncKey{
+		Name: req.Resource,
+		Key:  string(b),
+	})
+}
+
+func (s *Server) promote(resp *api.Response, req *api.Request) {
+	if req.Identity != s.state.Load().Admin {
+		resp.Fail(http.StatusForbidden, "promote API requires the admin identity")
+		return
+	}
+	if err := s.Promote(); err != nil {
+		resp.Fail(http.StatusConflict, "server is not a standby")
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	s.state.Load().Audit.Log("standby promoted", StatusOK, req)
+	resp.Reply(StatusOK)
+}
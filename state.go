@@ -14,6 +14,7 @@ import (
 
 	"aead.dev/mem"
 	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
 	"github.com/minio/kes/internal/metric"
 	"github.com/minio/kms-go/kes"
 )
@@ -29,12 +30,52 @@ type serverState struct {
 
 	Metrics *metric.Metrics
 	Routes  map[string]api.Route
+	Usage   *usageTracker
+
+	Idempotency *idempotencyCache
+	Jobs        *jobManager
+	JobTargets  *JobConfig
+	Standby     *standby
+	Standbys    []kes.Identity // Identities allowed to sync the key cache
+	Changes     *changeFeed
+	Databases   map[string]*dbEngine
+	SSHRoles    map[string]*sshRole
+	PKI         *pkiEngine
+	Aliases     *identityAliases
+	GeoFence    *geoFence
+	Cosigning   *cosigning
+	WebAuthn    *webAuthn
+	Witness     *witness
+	Fencing     *fencing
+	Attestation *attestation
+	Sealing     *sealing
+	Folders     *folderTree
+	Lifecycle   *keyLifecycle
+
+	RequireOwner bool // Whether new keys must have an owner and contact
+
+	Tokenization map[string]*tokenizer
+	Merkle       *merklePublisher
+	LoadShedding *loadShedder
+	Anomalies    *anomalyDetector
+	Alerts       *alertEngine
+	Auth         authChain
+	AuthThrottle *authThrottle
+	RequestLog   *requestLogger
 
 	LogHandler *logHandler
 	Log        *slog.Logger
 	Audit      *auditLogger
 }
 
+// clone returns a shallow copy of the server state. Callers
+// replace the fields that change and store the copy as new
+// server state.
+func (s *serverState) clone() *serverState {
+	c := *s
+	return &c
+}
+
 type identityEntry struct {
 	Name string
 	*kes.Policy
@@ -49,6 +90,10 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 10 * time.Second,
 			Auth:    api.InsecureSkipVerify,
 			Handler: api.HandlerFunc(s.version),
+			Doc: api.RouteDoc{
+				Summary:  "Get the server version",
+				Response: api.VersionResponse{},
+			},
 		},
 		api.PathReady: {
 			Method:  http.MethodGet,
@@ -57,6 +102,9 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: api.HandlerFunc(s.ready),
+			Doc: api.RouteDoc{
+				Summary: "Check whether the server is ready",
+			},
 		},
 		api.PathStatus: {
 			Method:  http.MethodGet,
@@ -65,6 +113,22 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: api.HandlerFunc(s.status),
+			Doc: api.RouteDoc{
+				Summary:  "Get the server status",
+				Response: api.StatusResponse{},
+			},
+		},
+		api.PathBackend: {
+			Method:  http.MethodGet,
+			Path:    api.PathBackend,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: api.HandlerFunc(s.backendStatus),
+			Doc: api.RouteDoc{
+				Summary:  "Get the health of the server backends",
+				Response: api.BackendStatusResponse{},
+			},
 		},
 		api.PathMetrics: {
 			Method:  http.MethodGet,
@@ -73,6 +137,10 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: api.HandlerFunc(s.metrics),
+			Doc: api.RouteDoc{
+				Summary:     "Get the server metrics in the Prometheus text format",
+				ContentType: headers.ContentTypeText,
+			},
 		},
 		api.PathListAPIs: {
 			Method:  http.MethodGet,
@@ -81,15 +149,127 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 10 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: api.HandlerFunc(s.listAPIs),
+			Doc: api.RouteDoc{
+				Summary:  "List all APIs",
+				Response: api.ListAPIsResponse{},
+			},
+		},
+		api.PathOpenAPI: {
+			Method:  http.MethodGet,
+			Path:    api.PathOpenAPI,
+			MaxBody: 0,
+			Timeout: 10 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: api.HandlerFunc(s.openAPI),
+			Doc: api.RouteDoc{
+				Summary:     "Get the OpenAPI specification",
+				ContentType: headers.ContentTypeJSON,
+			},
+		},
+
+		api.PathBatch: {
+			Method:  http.MethodPut,
+			Path:    api.PathBatch,
+			MaxBody: 1 * mem.MB,
+			Timeout: 30 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(s.idempotent(api.HandlerFunc(s.batch))))),
+			Doc: api.RouteDoc{
+				Summary:  "Apply a batch of operations atomically",
+				Request:  api.BatchRequest{},
+				Response: api.BatchResponse{},
+			},
+		},
+
+		api.PathChanges: {
+			Method:  http.MethodGet,
+			Path:    api.PathChanges,
+			MaxBody: 0,
+			Timeout: 0, // No timeout
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Count(api.HandlerFunc(s.changes)),
+			Doc: api.RouteDoc{
+				Summary:     "Stream key and policy changes",
+				Query:       []string{"since", "follow"},
+				Response:    api.ChangeEvent{},
+				ContentType: headers.ContentTypeJSONLines,
+			},
+		},
+		api.PathMaintenance: {
+			Method:  http.MethodPut,
+			Path:    api.PathMaintenance,
+			MaxBody: 1 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.setMaintenance))),
+			Doc: api.RouteDoc{
+				Summary: "Enable or disable the maintenance mode",
+				Request: api.MaintenanceRequest{},
+			},
+		},
+
+		api.PathSelfTest: {
+			Method:  http.MethodPut,
+			Path:    api.PathSelfTest,
+			MaxBody: 0,
+			Timeout: 30 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.runSelfTest)))),
+			Doc: api.RouteDoc{
+				Summary:  "Create, use and delete a canary key",
+				Response: api.SelfTestResponse{},
+			},
+		},
+
+		api.PathCacheSync: {
+			Method:  http.MethodGet,
+			Path:    api.PathCacheSync,
+			MaxBody: 0,
+			Timeout: 30 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.syncCache))),
+			Doc: api.RouteDoc{
+				Summary:  "Get all keys and policies for standby replication",
+				Response: api.CacheSyncResponse{},
+			},
+		},
+		api.PathCacheKey: {
+			Method:  http.MethodGet,
+			Path:    api.PathCacheKey,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.fetchKey))),
+			Doc: api.RouteDoc{
+				Summary:  "Get a key for a caching proxy",
+				Param:    "name",
+				Response: api.SyncKey{},
+			},
+		},
+		api.PathStandbyPromote: {
+			Method:  http.MethodPut,
+			Path:    api.PathStandbyPromote,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.promote))),
+			Doc: api.RouteDoc{
+				Summary: "Promote a standby server to a primary",
+			},
 		},
 
 		api.PathKeyCreate: {
 			Method:  http.MethodPut,
 			Path:    api.PathKeyCreate,
-			MaxBody: 0,
+			MaxBody: 16 * mem.KB,
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
-			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.createKey))),
+			Handler: metrics.Latency(metrics.Count(s.writable(s.idempotent(api.HandlerFunc(s.createKey))))),
+			Doc: api.RouteDoc{
+				Summary: "Create a key",
+				Param:   "name",
+				Request: api.CreateKeyRequest{},
+			},
 		},
 		api.PathKeyImport: {
 			Method:  http.MethodPut,
@@ -97,7 +277,12 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			MaxBody: 1 * mem.MB,
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
-			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.importKey))),
+			Handler: metrics.Latency(metrics.Count(s.writable(s.idempotent(api.HandlerFunc(s.importKey))))),
+			Doc: api.RouteDoc{
+				Summary: "Import a key",
+				Param:   "name",
+				Request: api.ImportKeyRequest{},
+			},
 		},
 		api.PathKeyDescribe: {
 			Method:  http.MethodGet,
@@ -106,6 +291,11 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.describeKey))),
+			Doc: api.RouteDoc{
+				Summary:  "Describe a key",
+				Param:    "name",
+				Response: api.DescribeKeyResponse{},
+			},
 		},
 		api.PathKeyList: {
 			Method:  http.MethodGet,
@@ -114,6 +304,12 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.listKeys))),
+			Doc: api.RouteDoc{
+				Summary:  "List keys",
+				Param:    "pattern",
+				Query:    []string{"pattern", "created_before", "created_after", "algorithm", "folder", "owner", "unowned", "limit", "cursor"},
+				Response: api.ListKeysResponse{},
+			},
 		},
 		api.PathKeyDelete: {
 			Method:  http.MethodDelete,
@@ -121,7 +317,104 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			MaxBody: 0,
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
-			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.deleteKey))),
+			Handler: metrics.Latency(metrics.Count(s.writable(s.idempotent(api.HandlerFunc(s.deleteKey))))),
+			Doc: api.RouteDoc{
+				Summary: "Delete a key",
+				Param:   "name",
+			},
+		},
+		api.PathKeyHold: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyHold,
+			MaxBody: 16 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.setLegalHold)))),
+			Doc: api.RouteDoc{
+				Summary:  "Place or lift a legal hold on a key",
+				Param:    "name",
+				Request:  api.LegalHoldRequest{},
+				Response: api.LegalHoldResponse{},
+			},
+		},
+		api.PathKeyFolder: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyFolder,
+			MaxBody: 1 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.moveKey)))),
+			Doc: api.RouteDoc{
+				Summary: "Move a key into a folder",
+				Param:   "name",
+				Request: api.MoveKeyRequest{},
+			},
+		},
+		api.PathKeyOwner: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyOwner,
+			MaxBody: 1 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.setKeyOwner)))),
+			Doc: api.RouteDoc{
+				Summary: "Assign an owner and contact to a key",
+				Param:   "name",
+				Request: api.SetKeyOwnerRequest{},
+			},
+		},
+		api.PathKeyReserve: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyReserve,
+			MaxBody: 1 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.reserveKey)))),
+			Doc: api.RouteDoc{
+				Summary:  "Reserve the exclusive right to encrypt with a key",
+				Param:    "name",
+				Request:  api.ReserveKeyRequest{},
+				Response: api.ReserveKeyResponse{},
+			},
+		},
+		api.PathKeyRelease: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyRelease,
+			MaxBody: 1 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.releaseKey)))),
+			Doc: api.RouteDoc{
+				Summary: "Release a key reservation",
+				Param:   "name",
+				Request: api.ReleaseKeyRequest{},
+			},
+		},
+		api.PathKeyShred: {
+			Method:  http.MethodDelete,
+			Path:    api.PathKeyShred,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.shredKey)))),
+			Doc: api.RouteDoc{
+				Summary:  "Crypto-shred a key and issue a signed destruction receipt",
+				Param:    "name",
+				Response: api.ShredReceipt{},
+			},
+		},
+		api.PathKeyReceipt: {
+			Method:  http.MethodGet,
+			Path:    api.PathKeyReceipt,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.shredReceipts))),
+			Doc: api.RouteDoc{
+				Summary:  "Get the destruction receipts of a crypto-shredded key",
+				Param:    "name",
+				Response: api.ShredReceiptsResponse{},
+			},
 		},
 		api.PathKeyEncrypt: {
 			Method:  http.MethodPut,
@@ -130,6 +423,12 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.encryptKey))),
+			Doc: api.RouteDoc{
+				Summary:  "Encrypt a plaintext",
+				Param:    "name",
+				Request:  api.EncryptKeyRequest{},
+				Response: api.EncryptKeyResponse{},
+			},
 		},
 		api.PathKeyGenerate: {
 			Method:  http.MethodPut,
@@ -138,6 +437,12 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.generateKey))),
+			Doc: api.RouteDoc{
+				Summary:  "Generate a data encryption key",
+				Param:    "name",
+				Request:  api.GenerateKeyRequest{},
+				Response: api.GenerateKeyResponse{},
+			},
 		},
 		api.PathKeyDecrypt: {
 			Method:  http.MethodPut,
@@ -146,6 +451,40 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.decryptKey))),
+			Doc: api.RouteDoc{
+				Summary:  "Decrypt a ciphertext",
+				Param:    "name",
+				Request:  api.DecryptKeyRequest{},
+				Response: api.DecryptKeyResponse{},
+			},
+		},
+		api.PathKeyCheck: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyCheck,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.checkDecrypt))),
+			Doc: api.RouteDoc{
+				Summary:  "Check whether an identity could decrypt a ciphertext",
+				Param:    "name",
+				Request:  api.CheckDecryptRequest{},
+				Response: api.CheckDecryptResponse{},
+			},
+		},
+		api.PathKeyRewrap: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyRewrap,
+			MaxBody: 1 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.rewrapKey))),
+			Doc: api.RouteDoc{
+				Summary:  "Import an externally wrapped data encryption key",
+				Param:    "name",
+				Request:  api.RewrapKeyRequest{},
+				Response: api.RewrapKeyResponse{},
+			},
 		},
 		api.PathKeyHMAC: {
 			Method:  http.MethodPut,
@@ -154,6 +493,136 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.hmacKey))),
+			Doc: api.RouteDoc{
+				Summary:  "Compute a HMAC of a message",
+				Param:    "name",
+				Request:  api.HMACRequest{},
+				Response: api.HMACResponse{},
+			},
+		},
+		api.PathKeySeal: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeySeal,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.sealEnvelope))),
+			Doc: api.RouteDoc{
+				Summary:  "Seal a plaintext into an envelope",
+				Param:    "name",
+				Request:  api.SealEnvelopeRequest{},
+				Response: api.Envelope{},
+			},
+		},
+		api.PathKeyOpen: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyOpen,
+			MaxBody: 2 * mem.MB, // Envelopes are larger than their plaintext
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.openEnvelope))),
+			Doc: api.RouteDoc{
+				Summary:  "Open an envelope",
+				Param:    "name",
+				Request:  api.OpenEnvelopeRequest{},
+				Response: api.OpenEnvelopeResponse{},
+			},
+		},
+		api.PathKeyTokenize: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyTokenize,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.tokenize))),
+			Doc: api.RouteDoc{
+				Summary:  "Tokenize values",
+				Param:    "name",
+				Request:  api.TokenizeRequest{},
+				Response: api.TokenizeResponse{},
+			},
+		},
+		api.PathKeyDetokenize: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyDetokenize,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.detokenize))),
+			Doc: api.RouteDoc{
+				Summary:  "Detokenize tokens",
+				Param:    "name",
+				Request:  api.TokenizeRequest{},
+				Response: api.TokenizeResponse{},
+			},
+		},
+		api.PathKeyReveal: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyReveal,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.reveal))),
+			Doc: api.RouteDoc{
+				Summary:  "Detokenize tokens into masked values",
+				Param:    "name",
+				Request:  api.TokenizeRequest{},
+				Response: api.TokenizeResponse{},
+			},
+		},
+		api.PathKeyStale: {
+			Method:  http.MethodGet,
+			Path:    api.PathKeyStale,
+			MaxBody: 0,
+			Timeout: 30 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.listStaleKeys))),
+			Doc: api.RouteDoc{
+				Summary:  "List keys not used recently",
+				Param:    "pattern",
+				Query:    []string{"days"},
+				Response: api.ListStaleKeysResponse{},
+			},
+		},
+		api.PathKeyInventory: {
+			Method:  http.MethodGet,
+			Path:    api.PathKeyInventory,
+			MaxBody: 0,
+			Timeout: 60 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.keyInventory))),
+			Doc: api.RouteDoc{
+				Summary:  "Get an inventory of all keys",
+				Query:    []string{"format"},
+				Response: api.KeyInventoryResponse{},
+			},
+		},
+
+		api.PathCiphertextInspect: {
+			Method:  http.MethodPut,
+			Path:    api.PathCiphertextInspect,
+			MaxBody: 4 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.inspectCiphertext))),
+			Doc: api.RouteDoc{
+				Summary:  "Inspect which keys ciphertexts depend on",
+				Request:  api.InspectCiphertextRequest{},
+				Response: api.InspectCiphertextResponse{},
+			},
+		},
+
+		api.PathTenantShred: {
+			Method:  http.MethodDelete,
+			Path:    api.PathTenantShred,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.shredTenant)))),
+			Doc: api.RouteDoc{
+				Summary: "Delete the KEK of a tenant and crypto-shred its keys",
+				Param:   "name",
+			},
 		},
 
 		api.PathPolicyDescribe: {
@@ -163,6 +632,11 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.describePolicy))),
+			Doc: api.RouteDoc{
+				Summary:  "Describe a policy",
+				Param:    "name",
+				Response: api.DescribePolicyResponse{},
+			},
 		},
 		api.PathPolicyRead: {
 			Method:  http.MethodGet,
@@ -171,6 +645,11 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.readPolicy))),
+			Doc: api.RouteDoc{
+				Summary:  "Read a policy",
+				Param:    "name",
+				Response: api.ReadPolicyResponse{},
+			},
 		},
 		api.PathPolicyList: {
 			Method:  http.MethodGet,
@@ -179,6 +658,12 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.listPolicies))),
+			Doc: api.RouteDoc{
+				Summary:  "List policies",
+				Param:    "pattern",
+				Query:    []string{"limit", "cursor"},
+				Response: api.ListPoliciesResponse{},
+			},
 		},
 
 		api.PathIdentityDescribe: {
@@ -188,6 +673,11 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.describeIdentity))),
+			Doc: api.RouteDoc{
+				Summary:  "Describe an identity",
+				Param:    "identity",
+				Response: api.DescribeIdentityResponse{},
+			},
 		},
 		api.PathIdentityList: {
 			Method:  http.MethodGet,
@@ -196,14 +686,406 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.listIdentities))),
+			Doc: api.RouteDoc{
+				Summary:  "List identities",
+				Param:    "pattern",
+				Query:    []string{"limit", "cursor"},
+				Response: api.ListIdentitiesResponse{},
+			},
 		},
 		api.PathIdentitySelfDescribe: {
 			Method:  http.MethodGet,
 			Path:    api.PathIdentitySelfDescribe,
 			MaxBody: 0,
 			Timeout: 15 * time.Second,
-			Auth:    insecureIdentifyOnly{}, // Anyone can use the self-describe API as long as a client cert is provided
+			Auth:    (*insecureIdentifyOnly)(&s.state), // Anyone can use the self-describe API as long as the client provides credentials
 			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.selfDescribeIdentity))),
+			Doc: api.RouteDoc{
+				Summary:  "Describe the identity of the client",
+				Response: api.SelfDescribeIdentityResponse{},
+			},
+		},
+		api.PathIdentitySelfRenew: {
+			Method:  http.MethodPut,
+			Path:    api.PathIdentitySelfRenew,
+			MaxBody: 16 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyAssignedIdentity)(&s.state), // Any client can renew its own certificate, regardless of its policy
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.renewIdentity)))),
+			Doc: api.RouteDoc{
+				Summary:  "Renew the client certificate with a new private key",
+				Request:  api.RenewIdentityRequest{},
+				Response: api.RenewIdentityResponse{},
+			},
+		},
+		api.PathIdentityStale: {
+			Method:  http.MethodGet,
+			Path:    api.PathIdentityStale,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.listStaleIdentities))),
+			Doc: api.RouteDoc{
+				Summary:  "List identities not used recently",
+				Param:    "pattern",
+				Query:    []string{"days"},
+				Response: api.ListStaleIdentitiesResponse{},
+			},
+		},
+
+		api.PathConnList: {
+			Method:  http.MethodGet,
+			Path:    api.PathConnList,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.listConns))),
+			Doc: api.RouteDoc{
+				Summary:  "List open client connections",
+				Response: api.ListConnsResponse{},
+			},
+		},
+		api.PathConnClose: {
+			Method:  http.MethodDelete,
+			Path:    api.PathConnClose,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.closeConns))),
+			Doc: api.RouteDoc{
+				Summary:  "Close all open connections of an identity",
+				Param:    "identity",
+				Response: api.CloseConnsResponse{},
+			},
+		},
+
+		api.PathJobStart: {
+			Method:  http.MethodPut,
+			Path:    api.PathJobStart,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.startJob)))),
+			Doc: api.RouteDoc{
+				Summary:  "Start a background job",
+				Param:    "kind",
+				Response: api.JobResponse{},
+				Status:   http.StatusAccepted,
+			},
+		},
+		api.PathJobDescribe: {
+			Method:  http.MethodGet,
+			Path:    api.PathJobDescribe,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.describeJob))),
+			Doc: api.RouteDoc{
+				Summary:  "Describe a background job",
+				Param:    "id",
+				Response: api.JobResponse{},
+			},
+		},
+		api.PathJobList: {
+			Method:  http.MethodGet,
+			Path:    api.PathJobList,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.listJobs))),
+			Doc: api.RouteDoc{
+				Summary:  "List background jobs",
+				Response: api.ListJobsResponse{},
+			},
+		},
+		api.PathJobCancel: {
+			Method:  http.MethodDelete,
+			Path:    api.PathJobCancel,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.cancelJob))),
+			Doc: api.RouteDoc{
+				Summary: "Cancel a background job",
+				Param:   "id",
+			},
+		},
+
+		api.PathLockAcquire: {
+			Method:  http.MethodPut,
+			Path:    api.PathLockAcquire,
+			MaxBody: 1 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.acquireLock)))),
+			Doc: api.RouteDoc{
+				Summary:  "Acquire a lock",
+				Param:    "name",
+				Request:  api.AcquireLockRequest{},
+				Response: api.LockResponse{},
+			},
+		},
+		api.PathLockRenew: {
+			Method:  http.MethodPut,
+			Path:    api.PathLockRenew,
+			MaxBody: 1 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.renewLock)))),
+			Doc: api.RouteDoc{
+				Summary:  "Renew a lock",
+				Param:    "name",
+				Request:  api.RenewLockRequest{},
+				Response: api.LockResponse{},
+			},
+		},
+		api.PathLockRelease: {
+			Method:  http.MethodPut,
+			Path:    api.PathLockRelease,
+			MaxBody: 1 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.releaseLock)))),
+			Doc: api.RouteDoc{
+				Summary:  "Release a lock",
+				Param:    "name",
+				Request:  api.ReleaseLockRequest{},
+				Response: api.LockResponse{},
+			},
+		},
+		api.PathLockDescribe: {
+			Method:  http.MethodGet,
+			Path:    api.PathLockDescribe,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.describeLock))),
+			Doc: api.RouteDoc{
+				Summary:  "Describe a lock",
+				Param:    "name",
+				Response: api.LockResponse{},
+			},
+		},
+
+		api.PathDBCredentials: {
+			Method:  http.MethodPut,
+			Path:    api.PathDBCredentials,
+			MaxBody: 1 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.issueDBCredentials)))),
+			Doc: api.RouteDoc{
+				Summary:  "Issue database credentials",
+				Param:    "database",
+				Request:  api.DBCredentialsRequest{},
+				Response: api.DBCredentialsResponse{},
+			},
+		},
+		api.PathDBRevoke: {
+			Method:  http.MethodPut,
+			Path:    api.PathDBRevoke,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.revokeDBCredentials)))),
+			Doc: api.RouteDoc{
+				Summary: "Revoke database credentials",
+				Param:   "lease",
+			},
+		},
+
+		api.PathSSHCA: {
+			Method:  http.MethodGet,
+			Path:    api.PathSSHCA,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.describeSSHCA))),
+			Doc: api.RouteDoc{
+				Summary:  "Get the SSH CA public key",
+				Response: api.DescribeSSHCAResponse{},
+			},
+		},
+		api.PathSSHSign: {
+			Method:  http.MethodPut,
+			Path:    api.PathSSHSign,
+			MaxBody: 16 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.signSSHKey))),
+			Doc: api.RouteDoc{
+				Summary:  "Sign a SSH public key",
+				Param:    "role",
+				Request:  api.SignSSHKeyRequest{},
+				Response: api.SignSSHKeyResponse{},
+			},
+		},
+
+		api.PathPKICA: {
+			Method:  http.MethodGet,
+			Path:    api.PathPKICA,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.describePKICA))),
+			Doc: api.RouteDoc{
+				Summary:  "Get the PKI CA certificate",
+				Response: api.DescribePKICAResponse{},
+			},
+		},
+		api.PathPKIImport: {
+			Method:  http.MethodPut,
+			Path:    api.PathPKIImport,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.importPKICA)))),
+			Doc: api.RouteDoc{
+				Summary: "Import a PKI CA",
+				Request: api.ImportPKICARequest{},
+			},
+		},
+		api.PathPKIIssue: {
+			Method:  http.MethodPut,
+			Path:    api.PathPKIIssue,
+			MaxBody: 64 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.issueCertificate)))),
+			Doc: api.RouteDoc{
+				Summary:  "Issue a X.509 certificate",
+				Param:    "role",
+				Request:  api.IssueCertificateRequest{},
+				Response: api.IssueCertificateResponse{},
+			},
+		},
+		api.PathPKIRevoke: {
+			Method:  http.MethodPut,
+			Path:    api.PathPKIRevoke,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.writable(api.HandlerFunc(s.revokeCertificate)))),
+			Doc: api.RouteDoc{
+				Summary: "Revoke a X.509 certificate",
+				Param:   "serial",
+			},
+		},
+		api.PathPKICRL: {
+			Method:  http.MethodGet,
+			Path:    api.PathPKICRL,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    api.InsecureSkipVerify, // Relying parties fetch the CRL without a client certificate
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.certificateRevocationList))),
+			Doc: api.RouteDoc{
+				Summary:     "Get the certificate revocation list",
+				ContentType: headers.ContentTypePKIXCRL,
+			},
+		},
+		api.PathPKIOCSP: {
+			Method:  http.MethodPost,
+			Path:    api.PathPKIOCSP,
+			MaxBody: 16 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    api.InsecureSkipVerify, // Relying parties query OCSP without a client certificate
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.ocspResponder))),
+			Doc: api.RouteDoc{
+				Summary:     "Get the OCSP status of a certificate",
+				ContentType: headers.ContentTypeOCSPResponse,
+			},
+		},
+		api.PathPKIOCSPGet: {
+			Method:  http.MethodGet,
+			Path:    api.PathPKIOCSPGet,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    api.InsecureSkipVerify, // Relying parties query OCSP without a client certificate
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.ocspResponder))),
+			Doc: api.RouteDoc{
+				Summary:     "Get the OCSP status of a certificate",
+				Param:       "request",
+				ContentType: headers.ContentTypeOCSPResponse,
+			},
+		},
+
+		api.PathWitnessCosign: {
+			Method:  http.MethodPut,
+			Path:    api.PathWitnessCosign,
+			MaxBody: 16 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyAssignedIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.cosign))),
+			Doc: api.RouteDoc{
+				Summary:  "Cosign a high-risk operation of another KES server as witness",
+				Request:  api.CosignRequest{},
+				Response: api.CosignResponse{},
+			},
+		},
+		api.PathLoginChallenge: {
+			Method:  http.MethodPost,
+			Path:    api.PathLoginChallenge,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*insecureIdentifyOnly)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.loginChallenge))),
+			Doc: api.RouteDoc{
+				Summary:  "Request a WebAuthn challenge for an admin session",
+				Response: api.LoginChallengeResponse{},
+			},
+		},
+		api.PathLogin: {
+			Method:  http.MethodPost,
+			Path:    api.PathLogin,
+			MaxBody: 64 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*insecureIdentifyOnly)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.login))),
+			Doc: api.RouteDoc{
+				Summary:  "Start an admin session with a WebAuthn assertion",
+				Request:  api.LoginRequest{},
+				Response: api.LoginResponse{},
+			},
+		},
+		api.PathAttestation: {
+			Method:  http.MethodGet,
+			Path:    api.PathAttestation,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.attest))),
+			Doc: api.RouteDoc{
+				Summary:  "Get a TPM quote over the server's binary and config measurement",
+				Query:    []string{"nonce"},
+				Response: api.AttestationResponse{},
+			},
+		},
+		api.PathMerkleRoot: {
+			Method:  http.MethodGet,
+			Path:    api.PathMerkleRoot,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.merkleRoot))),
+			Doc: api.RouteDoc{
+				Summary:  "Get a published Merkle root over all keys",
+				Query:    []string{"at"},
+				Response: api.MerkleRootResponse{},
+			},
+		},
+		api.PathMerkleProof: {
+			Method:  http.MethodGet,
+			Path:    api.PathMerkleProof,
+			MaxBody: 0,
+			Timeout: 30 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.merkleProof))),
+			Doc: api.RouteDoc{
+				Summary:  "Prove whether a key is part of a Merkle root",
+				Param:    "name",
+				Query:    []string{"at"},
+				Response: api.MerkleProofResponse{},
+			},
 		},
 
 		api.PathLogError: {
@@ -213,6 +1095,11 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 0, // No timeout
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.ErrorEventCounter(api.HandlerFunc(s.logError)),
+			Doc: api.RouteDoc{
+				Summary:     "Stream the error log",
+				Response:    api.ErrorLogEvent{},
+				ContentType: headers.ContentTypeJSONLines,
+			},
 		},
 		api.PathLogAudit: {
 			Method:  http.MethodGet,
@@ -221,6 +1108,24 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Timeout: 0, // No timeout
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.AuditEventCounter(api.HandlerFunc(s.logAudit)),
+			Doc: api.RouteDoc{
+				Summary:     "Stream the audit log",
+				Response:    api.AuditLogEvent{},
+				ContentType: headers.ContentTypeJSONLines,
+			},
+		},
+		api.PathLogAuditPseudonym: {
+			Method:  http.MethodGet,
+			Path:    api.PathLogAuditPseudonym,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.resolvePseudonym))),
+			Doc: api.RouteDoc{
+				Summary:  "Resolve an audit log pseudonym",
+				Param:    "pseudonym",
+				Response: api.ResolvePseudonymResponse{},
+			},
 		},
 	}
 
@@ -230,17 +1135,33 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			continue
 		}
 		if conf.InsecureSkipAuth {
-			route.Auth = insecureIdentifyOnly{}
+			route.Auth = (*insecureIdentifyOnly)(&s.state)
 		}
 		if conf.Timeout > 0 {
 			route.Timeout = conf.Timeout
 		}
+		if !conf.Deprecation.IsZero() || !conf.Sunset.IsZero() {
+			route.Deprecation = conf.Deprecation
+			route.Sunset = conf.Sunset
+			route.Successor = api.VersionPath(path, api.V2)
+		}
 		routes[path] = route
 	}
 
 	mux := http.NewServeMux()
 	for path, route := range routes {
-		mux.Handle(path, route)
+		route = s.trackConn(route)
+		mux.Handle(path, s.logRequest(s.shed(route)))
+
+		// Each v1 API is also served under the v2 prefix. The v2
+		// route shares the v1 route's handler, auth and timeout,
+		// but is never deprecated. It is not listed in routes
+		// since clients and policies use the v1 path.
+		if v2 := api.VersionPath(path, api.V2); v2 != path {
+			route.Version = api.V2
+			route.Deprecation, route.Sunset, route.Successor = time.Time{}, time.Time{}, ""
+			mux.Handle(v2, s.logRequest(s.shed(route)))
+		}
 	}
 	return mux, routes
 }
@@ -259,7 +1180,7 @@ func initPolicies(policies map[string]Policy) (map[string]*kes.Policy, map[kes.I
 
 		policySet[name] = p
 		for _, id := range policy.Identities {
-			if !validName(id.String()) {
+			if !validIdentity(id.String()) {
 				return nil, nil, fmt.Errorf("kes: identity '%s' is empty, too long or contains invalid characters", id)
 			}
 			if _, ok := identitySet[id]; ok {
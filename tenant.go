@@ -0,0 +1,465 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+// tenantKEKPrefix is the prefix of tenant KEK entries at the key
+// store. It is followed by the tenant name. Like locks, KEKs never
+// collide with keys, even when stored at the same key store.
+const tenantKEKPrefix = "-tenantkek-"
+
+// tenantHeader is the prefix of all key store entries wrapped
+// by a tenant KEK. Entries without it have been created before
+// the tenant was configured and are not wrapped.
+var tenantHeader = []byte("kes\x00tenant\x01")
+
+// errTenantShredded is returned when the KEK of a tenant
+// has been deleted. Entries of the tenant can no longer be
+// decrypted.
+var errTenantShredded = errors.New("kes: tenant KEK has been shredded")
+
+// tenantKEKValidity is the time period after which a cached
+// tenant KEK is validated against the KEK store again. Hence,
+// servers sharing a KEK store notice a KEK shredded by another
+// server within this period.
+const tenantKEKValidity = 30 * time.Second
+
+// tenant is a set of keys, identified by a common name prefix,
+// that are wrapped by the same KEK.
+type tenant struct {
+	Name   string
+	Prefix string
+
+	kekStore KeyStore // Stores the KEK. May be the tenantStore's KeyStore
+
+	lock      sync.Mutex
+	kek       *crypto.SecretKey // Cached KEK, nil if not fetched yet
+	kekValue  []byte            // Encoded KEK as stored at the KEK store
+	validated time.Time         // Point in time the cached KEK has been validated
+}
+
+// KEK returns the tenant's KEK. It fetches the KEK from the KEK store
+// and validates a cached KEK once it is older than tenantKEKValidity.
+// If the KEK does not exist and create is true, it generates and
+// stores a new KEK. Otherwise, it returns errTenantShredded.
+func (t *tenant) KEK(ctx context.Context, create bool) (crypto.SecretKey, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.kek != nil && time.Since(t.validated) < tenantKEKValidity {
+		return *t.kek, nil
+	}
+
+	name := tenantKEKPrefix + t.Name
+	b, err := t.kekStore.Get(ctx, name)
+	if errors.Is(err, kes.ErrKeyNotFound) && create {
+		b, err = t.createKEK(ctx, name)
+	}
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		t.forget()
+		return crypto.SecretKey{}, errTenantShredded
+	}
+	if err != nil {
+		return crypto.SecretKey{}, err
+	}
+
+	kek, err := crypto.ParseKeyVersion(b)
+	if err != nil {
+		return crypto.SecretKey{}, fmt.Errorf("kes: invalid KEK of tenant '%s': %v", t.Name, err)
+	}
+	t.kek, t.kekValue, t.validated = &kek.Key, b, time.Now()
+	return kek.Key, nil
+}
+
+// Validate checks whether the cached KEK, if any, still exists
+// at the KEK store. It removes the cached KEK and reports true if
+// the KEK has been shredded or replaced in the meantime.
+func (t *tenant) Validate(ctx context.Context) (bool, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.kek == nil {
+		return false, nil
+	}
+	b, err := t.kekStore.Get(ctx, tenantKEKPrefix+t.Name)
+	if err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+		return false, err
+	}
+	if err != nil || !bytes.Equal(b, t.kekValue) {
+		t.forget()
+		return true, nil
+	}
+	t.validated = time.Now()
+	return false, nil
+}
+
+// Forget removes the cached KEK, if any.
+func (t *tenant) Forget() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.forget()
+}
+
+func (t *tenant) forget() { t.kek, t.kekValue, t.validated = nil, nil, time.Time{} }
+
+// createKEK generates a new KEK and stores it at the KEK store
+// under the given name. If another server has created the KEK
+// concurrently, it returns the existing KEK.
+func (t *tenant) createKEK(ctx context.Context, name string) ([]byte, error) {
+	key, err := crypto.GenerateSecretKey(crypto.DetermineSecretKeyType(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	hmac, err := crypto.GenerateHMACKey(crypto.SHA256, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	b, err := crypto.EncodeKeyVersion(crypto.KeyVersion{
+		Key:       key,
+		HMACKey:   hmac,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = t.kekStore.Create(ctx, name, b); errors.Is(err, kes.ErrKeyExists) {
+		return t.kekStore.Get(ctx, name)
+	}
+	return b, err
+}
+
+// tenantStore is a KeyStore that wraps the entries of each tenant
+// with the tenant's KEK before storing them at the underlying
+// KeyStore. All other entries are stored as they are.
+//
+// Deleting a tenant's KEK crypto-shreds all entries of the tenant.
+type tenantStore struct {
+	KeyStore
+	tenants []*tenant // Sorted by prefix length, longest first
+
+	// Evict, if set, is called with the tenant's prefix once
+	// the tenant's KEK has been shredded by another server.
+	Evict func(prefix string)
+}
+
+// newTenantStore returns a KeyStore that wraps the entries of the
+// given tenants with tenant KEKs before storing them at store. It
+// returns store if there are no tenants.
+func newTenantStore(store KeyStore, conf map[string]*TenantConfig) KeyStore {
+	if len(conf) == 0 {
+		return store
+	}
+
+	s := &tenantStore{
+		KeyStore: store,
+		tenants:  make([]*tenant, 0, len(conf)),
+	}
+	for name, c := range conf {
+		t := &tenant{
+			Name:     name,
+			Prefix:   c.Prefix,
+			kekStore: c.KEKStore,
+		}
+		if t.kekStore == nil {
+			t.kekStore = store
+		}
+		s.tenants = append(s.tenants, t)
+	}
+	slices.SortFunc(s.tenants, func(a, b *tenant) int { return len(b.Prefix) - len(a.Prefix) })
+	return s
+}
+
+// Tenant returns the tenant with the given name, if any.
+func (s *tenantStore) Tenant(name string) (*tenant, bool) {
+	for _, t := range s.tenants {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// Create wraps the value with the KEK of the tenant owning the
+// entry, if any, before creating the entry.
+func (s *tenantStore) Create(ctx context.Context, name string, value []byte) error {
+	value, err := s.wrap(ctx, name, value)
+	if err != nil {
+		return err
+	}
+	return s.KeyStore.Create(ctx, name, value)
+}
+
+// CanOverwrite reports whether the underlying KeyStore
+// can replace existing entries.
+func (s *tenantStore) CanOverwrite() bool {
+	store, ok := s.KeyStore.(OverwriteKeyStore)
+	return ok && store.CanOverwrite()
+}
+
+// Set wraps the value with the KEK of the tenant owning the
+// entry, if any, before creating or replacing the entry.
+func (s *tenantStore) Set(ctx context.Context, name string, value []byte) error {
+	value, err := s.wrap(ctx, name, value)
+	if err != nil {
+		return err
+	}
+	return setEntry(ctx, s.KeyStore, name, value)
+}
+
+// CanSwap reports whether the underlying KeyStore
+// supports conditional writes.
+func (s *tenantStore) CanSwap() bool { return canSwap(s.KeyStore) }
+
+// Swap replaces the value of the entry if its unwrapped value is
+// equal to old. Since wrapping is not deterministic, it compares
+// the unwrapped values and swaps the wrapped value it has read.
+func (s *tenantStore) Swap(ctx context.Context, name string, old, value []byte) error {
+	wrapped, err := s.KeyStore.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	plaintext, err := s.unwrap(ctx, name, bytes.Clone(wrapped)) // Decryption may modify the ciphertext in place
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(plaintext, old) {
+		return kes.ErrKeyExists
+	}
+	if value, err = s.wrap(ctx, name, value); err != nil {
+		return err
+	}
+	return swapEntry(ctx, s.KeyStore, name, wrapped, value)
+}
+
+// Validate validates the cached KEKs of all tenants against the
+// KEK stores. It evicts the keys of tenants whose KEK has been
+// shredded or replaced.
+func (s *tenantStore) Validate(ctx context.Context) error {
+	var errs []error
+	for _, t := range s.tenants {
+		if err := s.validate(ctx, t); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *tenantStore) validate(ctx context.Context, t *tenant) error {
+	changed, err := t.Validate(ctx)
+	if err != nil {
+		return err
+	}
+	if changed && s.Evict != nil {
+		s.Evict(t.Prefix)
+	}
+	return nil
+}
+
+// wrap encrypts the value with the KEK of the tenant owning
+// the entry. It returns the value as is if no tenant owns
+// the entry.
+//
+// It validates the cached KEK before wrapping the value.
+// Otherwise, the value might get wrapped with a KEK that
+// has been shredded by another server.
+func (s *tenantStore) wrap(ctx context.Context, name string, value []byte) ([]byte, error) {
+	t, ok := s.lookup(name)
+	if !ok {
+		return value, nil
+	}
+
+	if err := s.validate(ctx, t); err != nil {
+		return nil, err
+	}
+	kek, err := t.KEK(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := kek.Encrypt(value, tenantAssociatedData(name))
+	if err != nil {
+		return nil, err
+	}
+	return append(bytes.Clone(tenantHeader), ciphertext...), nil
+}
+
+// Get returns the value of the entry and unwraps it with the KEK of
+// the tenant owning the entry, if any. It returns kes.ErrKeyNotFound
+// if the tenant's KEK has been shredded.
+func (s *tenantStore) Get(ctx context.Context, name string) ([]byte, error) {
+	value, err := s.KeyStore.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.unwrap(ctx, name, value)
+}
+
+// GetBulk returns the values of the given entries unwrapped with
+// the KEKs of the tenants owning them. Entries of shredded tenants
+// are not included.
+func (s *tenantStore) GetBulk(ctx context.Context, names []string) (map[string][]byte, error) {
+	values, err := getBulk(ctx, s.KeyStore, names)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range values {
+		plaintext, err := s.unwrap(ctx, name, value)
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			delete(values, name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[name] = plaintext
+	}
+	return values, nil
+}
+
+// unwrap decrypts the value with the KEK of the tenant owning
+// the entry. It returns the value as is if no tenant owns the
+// entry or the entry has not been wrapped.
+func (s *tenantStore) unwrap(ctx context.Context, name string, value []byte) ([]byte, error) {
+	t, ok := s.lookup(name)
+	if !ok || !bytes.HasPrefix(value, tenantHeader) {
+		return value, nil
+	}
+
+	kek, err := t.KEK(ctx, false)
+	if errors.Is(err, errTenantShredded) {
+		return nil, kes.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := value[len(tenantHeader):]
+	plaintext, err := kek.Decrypt(bytes.Clone(ciphertext), tenantAssociatedData(name))
+	if err == nil {
+		return plaintext, nil
+	}
+
+	// The entry may have been wrapped with a KEK created by another
+	// server after the cached KEK has been shredded.
+	if err = s.validate(ctx, t); err != nil {
+		return nil, err
+	}
+	if kek, err = t.KEK(ctx, false); errors.Is(err, errTenantShredded) {
+		return nil, kes.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if plaintext, err = kek.Decrypt(ciphertext, tenantAssociatedData(name)); err != nil {
+		return nil, fmt.Errorf("kes: failed to unwrap key '%s' of tenant '%s': %v", name, t.Name, err)
+	}
+	return plaintext, nil
+}
+
+// Close closes the underlying KeyStore and all tenant
+// KEK stores.
+func (s *tenantStore) Close() error {
+	err := s.KeyStore.Close()
+	for _, t := range s.tenants {
+		if t.kekStore == s.KeyStore {
+			continue
+		}
+		if cErr := t.kekStore.Close(); err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+func (s *tenantStore) String() string { return fmt.Sprint(s.KeyStore) }
+
+// lookup returns the tenant owning the entry with the
+// given name, if any.
+func (s *tenantStore) lookup(name string) (*tenant, bool) {
+	for _, t := range s.tenants {
+		if strings.HasPrefix(name, t.Prefix) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// tenantAssociatedData returns the associated data that binds
+// a wrapped entry to its name.
+func tenantAssociatedData(name string) []byte {
+	return append(bytes.Clone(tenantHeader), "name="+name...)
+}
+
+func (s *Server) shredTenant(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	if req.Identity != state.Admin {
+		resp.Fail(http.StatusForbidden, "tenant shred API requires the admin identity")
+		return
+	}
+
+	store, ok := state.Keys.store.(*tenantStore)
+	if !ok {
+		resp.Failf(http.StatusNotFound, "tenant '%s' does not exist", req.Resource)
+		return
+	}
+	t, ok := store.Tenant(req.Resource)
+	if !ok {
+		resp.Failf(http.StatusNotFound, "tenant '%s' does not exist", req.Resource)
+		return
+	}
+
+	held, err := heldKeys(req.Context(), store.KeyStore, t.Prefix)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to list legal holds")
+		return
+	}
+	if len(held) > 0 {
+		resp.Failf(http.StatusConflict, "tenant '%s' has %d keys under legal hold", t.Name, len(held))
+		return
+	}
+
+	if err := t.kekStore.Delete(req.Context(), tenantKEKPrefix+t.Name); err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to delete tenant KEK")
+		return
+	}
+	t.Forget()
+	state.Keys.cache.DeleteFunc(func(name string, _ *cacheEntry) bool { return strings.HasPrefix(name, t.Prefix) })
+
+	const StatusOK = http.StatusOK
+	state.Audit.Log(
+		fmt.Sprintf("tenant '%s' shredded", t.Name),
+		StatusOK,
+		req,
+	)
+	resp.Reply(StatusOK)
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestTenantStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	var (
+		store    = &MemKeyStore{}
+		kekStore = &MemKeyStore{}
+	)
+	s := newTenantStore(store, map[string]*TenantConfig{
+		"acme":    {Prefix: "acme-"},
+		"acme-eu": {Prefix: "acme-eu-", KEKStore: kekStore},
+	})
+
+	for i, test := range tenantStoreTests {
+		if err := s.Create(ctx, test.Name, test.Value); err != nil {
+			t.Fatalf("Test %d: failed to create '%s': %v", i, test.Name, err)
+		}
+		stored, err := store.Get(ctx, test.Name)
+		if err != nil {
+			t.Fatalf("Test %d: failed to fetch '%s' from key store: %v", i, test.Name, err)
+		}
+		if wrapped := bytes.HasPrefix(stored, tenantHeader); wrapped != test.Wrapped {
+			t.Fatalf("Test %d: wrapped mismatch: got '%v' - want '%v'", i, wrapped, test.Wrapped)
+		}
+
+		value, err := s.Get(ctx, test.Name)
+		if err != nil {
+			t.Fatalf("Test %d: failed to get '%s': %v", i, test.Name, err)
+		}
+		if !bytes.Equal(value, test.Value) {
+			t.Fatalf("Test %d: value mismatch: got '%s' - want '%s'", i, value, test.Value)
+		}
+	}
+
+	if _, err := store.Get(ctx, tenantKEKPrefix+"acme"); err != nil {
+		t.Fatalf("KEK of tenant 'acme' is not stored at the key store: %v", err)
+	}
+	if _, err := kekStore.Get(ctx, tenantKEKPrefix+"acme-eu"); err != nil {
+		t.Fatalf("KEK of tenant 'acme-eu' is not stored at the KEK store: %v", err)
+	}
+	if _, err := store.Get(ctx, tenantKEKPrefix+"acme-eu"); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("KEK of tenant 'acme-eu' is stored at the key store: %v", err)
+	}
+
+	// Entries created before the tenant got configured are not wrapped.
+	if err := store.Create(ctx, "acme-legacy", []byte("legacy")); err != nil {
+		t.Fatalf("Failed to create legacy entry: %v", err)
+	}
+	if value, err := s.Get(ctx, "acme-legacy"); err != nil || string(value) != "legacy" {
+		t.Fatalf("Failed to get legacy entry: got '%s' - %v", value, err)
+	}
+}
+
+func TestTenantStoreShreddedByOtherServer(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	store := &MemKeyStore{}
+	conf := map[string]*TenantConfig{"acme": {Prefix: "acme-"}}
+
+	// Two servers sharing the same key store.
+	s1 := newTenantStore(store, conf).(*tenantStore)
+	s2 := newTenantStore(store, conf).(*tenantStore)
+
+	var evicted []string
+	s2.Evict = func(prefix string) { evicted = append(evicted, prefix) }
+
+	if err := s1.Create(ctx, "acme-key-0", []byte("value-0")); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if _, err := s2.Get(ctx, "acme-key-0"); err != nil {
+		t.Fatalf("Failed to get entry: %v", err)
+	}
+
+	// The first server shreds the tenant.
+	t1, _ := s1.Tenant("acme")
+	if err := store.Delete(ctx, tenantKEKPrefix+"acme"); err != nil {
+		t.Fatalf("Failed to delete KEK: %v", err)
+	}
+	t1.Forget()
+
+	if err := s2.Validate(ctx); err != nil {
+		t.Fatalf("Failed to validate KEKs: %v", err)
+	}
+	if len(evicted) != 1 || evicted[0] != "acme-" {
+		t.Fatalf("Keys of shredded tenant not evicted: got '%v'", evicted)
+	}
+	if _, err := s2.Get(ctx, "acme-key-0"); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Got entry of shredded tenant: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+
+	// The first server creates a new KEK while the second one has
+	// not noticed yet. The second must not use the old KEK.
+	if err := s1.Create(ctx, "acme-key-1", []byte("value-1")); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if value, err := s2.Get(ctx, "acme-key-1"); err != nil || string(value) != "value-1" {
+		t.Fatalf("Failed to get entry wrapped with new KEK: got '%s' - %v", value, err)
+	}
+	if err := s2.Create(ctx, "acme-key-2", []byte("value-2")); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if value, err := s1.Get(ctx, "acme-key-2"); err != nil || string(value) != "value-2" {
+		t.Fatalf("Failed to get entry created by other server: got '%s' - %v", value, err)
+	}
+}
+
+func TestShredTenant(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	store := &MemKeyStore{}
+	srv, url := startServer(ctx, &Config{
+		Keys: store,
+		Tenants: map[string]*TenantConfig{
+			"acme": {Prefix: "acme-"},
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	if err := client.CreateKey(ctx, "acme-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := client.CreateKey(ctx, "other-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	shred := func(name string) int {
+		req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url+api.PathTenantShred+name, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := shred("unknown"); code != http.StatusNotFound {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", code, http.StatusNotFound)
+	}
+	if code := shred("acme"); code != http.StatusOK {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", code, http.StatusOK)
+	}
+
+	if _, err := store.Get(ctx, tenantKEKPrefix+"acme"); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Tenant KEK has not been deleted: %v", err)
+	}
+	if _, err := client.DescribeKey(ctx, "acme-key"); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Key of shredded tenant is still available: %v", err)
+	}
+	if _, err := client.DescribeKey(ctx, "other-key"); err != nil {
+		t.Fatalf("Failed to describe key of no tenant: %v", err)
+	}
+}
+
+var tenantStoreTests = []struct {
+	Name    string
+	Value   []byte
+	Wrapped bool
+}{
+	{Name: "acme-key", Value: []byte("acme"), Wrapped: true},       // 0
+	{Name: "acme-eu-key", Value: []byte("acme-eu"), Wrapped: true}, // 1
+	{Name: "other-key", Value: []byte("other"), Wrapped: false},    // 2
+}
@@ -0,0 +1,256 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+)
+
+const (
+	// defaultAuthMaxFailures is the default max. number of
+	// authentication failures of a source within an interval.
+	defaultAuthMaxFailures = 10
+
+	// defaultAuthInterval is the default interval over which
+	// authentication failures are counted.
+	defaultAuthInterval = 1 * time.Minute
+
+	// defaultAuthDelay is the default delay of the response
+	// to the first authentication failure.
+	defaultAuthDelay = 100 * time.Millisecond
+
+	// defaultAuthMaxDelay is the default max. delay of a response.
+	defaultAuthMaxDelay = 5 * time.Second
+
+	// defaultAuthBanDuration is the default time a source is
+	// banned once it exceeds its failure budget.
+	defaultAuthBanDuration = 15 * time.Minute
+
+	// maxAuthSources is the max. number of tracked sources. Once
+	// reached, expired sources are removed and, if there are none,
+	// the oldest sources.
+	maxAuthSources = 100_000
+)
+
+// Reasons for authentication failures.
+const (
+	authFailureInvalidIdentity = "invalid_identity"
+	authFailureUnknownIdentity = "unknown_identity"
+	authFailureBanned          = "banned"
+)
+
+// authThrottle tracks authentication failures per source IP
+// address, or per /64 prefix for IPv6 addresses, delays the
+// responses to failed requests progressively and bans sources
+// that exceed their failure budget.
+type authThrottle struct {
+	maxFailures int
+	interval    time.Duration
+	delay       time.Duration
+	maxDelay    time.Duration
+	banDuration time.Duration
+	maxSources  int
+
+	lock    sync.Mutex
+	sources map[netip.Prefix]*authSource
+}
+
+// authSource are the authentication failures of a source.
+type authSource struct {
+	start       time.Time // Start of the current interval
+	failures    int
+	bannedUntil time.Time
+}
+
+// newAuthThrottle returns a new authThrottle for the given
+// configuration, or nil if conf is nil.
+func newAuthThrottle(conf *AuthThrottlingConfig) *authThrottle {
+	if conf == nil {
+		return nil
+	}
+	t := &authThrottle{
+		maxFailures: conf.MaxFailures,
+		interval:    conf.Interval,
+		delay:       conf.Delay,
+		maxDelay:    conf.MaxDelay,
+		banDuration: conf.BanDuration,
+		maxSources:  maxAuthSources,
+		sources:     map[netip.Prefix]*authSource{},
+	}
+	if t.maxFailures <= 0 {
+		t.maxFailures = defaultAuthMaxFailures
+	}
+	if t.interval <= 0 {
+		t.interval = defaultAuthInterval
+	}
+	if t.delay <= 0 {
+		t.delay = defaultAuthDelay
+	}
+	if t.maxDelay <= 0 {
+		t.maxDelay = defaultAuthMaxDelay
+	}
+	if t.banDuration <= 0 {
+		t.banDuration = defaultAuthBanDuration
+	}
+	return t
+}
+
+// Banned reports whether the source with the given IP address
+// is banned and, if so, for how long.
+func (t *authThrottle) Banned(addr netip.Addr, now time.Time) (time.Duration, bool) {
+	if t == nil {
+		return 0, false
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	s, ok := t.sources[authSourcePrefix(addr)]
+	if !ok || !now.Before(s.bannedUntil) {
+		return 0, false
+	}
+	return s.bannedUntil.Sub(now), true
+}
+
+// Fail records an authentication failure of the source with the
+// given IP address. It returns how long the response should be
+// delayed and whether the source has been banned by this failure.
+func (t *authThrottle) Fail(addr netip.Addr, now time.Time) (time.Duration, bool) {
+	if t == nil {
+		return 0, false
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	prefix := authSourcePrefix(addr)
+	s, ok := t.sources[prefix]
+	if !ok {
+		if len(t.sources) >= t.maxSources {
+			t.prune(now)
+		}
+		if len(t.sources) >= t.maxSources {
+			t.evict(now)
+		}
+		s = &authSource{start: now}
+		t.sources[prefix] = s
+	}
+	if now.Sub(s.start) > t.interval {
+		s.start, s.failures = now, 0
+	}
+	s.failures++
+
+	delay := t.maxDelay
+	if s.failures <= 32 { // Avoid overflows of the shift
+		delay = min(t.delay<<(s.failures-1), t.maxDelay)
+	}
+	if s.failures > t.maxFailures {
+		s.start, s.failures = now, 0
+		s.bannedUntil = now.Add(t.banDuration)
+		return delay, true
+	}
+	return delay, false
+}
+
+// prune removes all sources that are neither banned nor
+// have failed within the current interval.
+func (t *authThrottle) prune(now time.Time) {
+	for prefix, s := range t.sources {
+		if now.Sub(s.start) > t.interval && !now.Before(s.bannedUntil) {
+			delete(t.sources, prefix)
+		}
+	}
+}
+
+// evict removes the oldest tenth of all tracked sources, such
+// that sources failing within their interval cannot grow the
+// map without bounds. Banned sources are only removed if there
+// are not enough sources that are not banned.
+func (t *authThrottle) evict(now time.Time) {
+	type entry struct {
+		prefix netip.Prefix
+		source *authSource
+	}
+	entries := make([]entry, 0, len(t.sources))
+	for prefix, s := range t.sources {
+		entries = append(entries, entry{prefix: prefix, source: s})
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		aBanned, bBanned := now.Before(a.source.bannedUntil), now.Before(b.source.bannedUntil)
+		switch {
+		case !aBanned && bBanned:
+			return -1
+		case aBanned && !bBanned:
+			return 1
+		}
+		return a.source.start.Compare(b.source.start)
+	})
+
+	n := max(len(entries)-t.maxSources+1, t.maxSources/10)
+	for _, e := range entries[:min(n, len(entries))] {
+		delete(t.sources, e.prefix)
+	}
+}
+
+// authSourcePrefix returns the prefix identifying the source
+// with the given IP address. Clients usually get an entire /64
+// IPv6 prefix assigned. Hence, IPv6 sources are tracked per
+// /64 prefix.
+func authSourcePrefix(addr netip.Addr) netip.Prefix {
+	addr = addr.Unmap()
+	if addr.Is6() {
+		prefix, _ := addr.Prefix(64)
+		return prefix
+	}
+	prefix, _ := addr.Prefix(addr.BitLen())
+	return prefix
+}
+
+// checkBanned returns an error if the source of the request is
+// banned because of too many authentication failures.
+func (s *serverState) checkBanned(req *http.Request) api.Error {
+	retryAfter, banned := s.AuthThrottle.Banned(clientIP(req), time.Now())
+	if !banned {
+		return nil
+	}
+	s.Metrics.AuthFailure(authFailureBanned)
+	return api.NewError(http.StatusTooManyRequests, "too many authentication failures: retry after "+retryAfter.Round(time.Second).String())
+}
+
+// authFailed records an authentication failure of the request's
+// source for the given reason and delays the response, if
+// throttling is enabled. It returns err.
+func (s *serverState) authFailed(req *http.Request, reason string, err api.Error) api.Error {
+	s.Metrics.AuthFailure(reason)
+	if s.AuthThrottle == nil {
+		return err
+	}
+
+	addr := clientIP(req)
+	delay, banned := s.AuthThrottle.Fail(addr, time.Now())
+	if banned {
+		s.Metrics.AuthBan()
+		s.Log.WarnContext(req.Context(), "banned source: too many authentication failures", "ip", addr.String(), "duration", s.AuthThrottle.banDuration)
+	}
+	sleep(req.Context(), delay)
+	return err
+}
+
+// sleep waits for the given duration or until
+// ctx is canceled.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestAuthThrottle(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, test := range authThrottleTests {
+		throttle := newAuthThrottle(&AuthThrottlingConfig{
+			MaxFailures: 3,
+			Interval:    time.Minute,
+			Delay:       100 * time.Millisecond,
+			MaxDelay:    300 * time.Millisecond,
+			BanDuration: 10 * time.Minute,
+		})
+		for j, f := range test.Failures {
+			addr := netip.MustParseAddr(f.Addr)
+			delay, banned := throttle.Fail(addr, start.Add(f.At))
+			if delay != f.Delay {
+				t.Fatalf("Test %d: failure %d: got delay '%v' - want '%v'", i, j, delay, f.Delay)
+			}
+			if banned != f.Banned {
+				t.Fatalf("Test %d: failure %d: got banned '%v' - want '%v'", i, j, banned, f.Banned)
+			}
+		}
+
+		addr := netip.MustParseAddr(test.Addr)
+		if _, banned := throttle.Banned(addr, start.Add(test.At)); banned != test.Banned {
+			t.Fatalf("Test %d: got banned '%v' - want '%v'", i, banned, test.Banned)
+		}
+	}
+}
+
+func TestAuthThrottleMaxSources(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	throttle := newAuthThrottle(&AuthThrottlingConfig{
+		MaxFailures: 1,
+		Interval:    time.Hour,
+		BanDuration: time.Hour,
+	})
+	throttle.maxSources = 100
+
+	// Ban the first source. It must survive the eviction
+	// of sources that are not banned.
+	banned := netip.MustParseAddr("10.0.0.1")
+	throttle.Fail(banned, start)
+	if _, ok := throttle.Fail(banned, start); !ok {
+		t.Fatal("Source has not been banned")
+	}
+
+	// All sources fail within their interval, such that
+	// none of them expires.
+	for i := range 1000 {
+		addr := netip.AddrFrom4([4]byte{10, 1, byte(i >> 8), byte(i)})
+		throttle.Fail(addr, start.Add(time.Duration(i)*time.Millisecond))
+
+		if n := len(throttle.sources); n > throttle.maxSources {
+			t.Fatalf("Failure %d: got %d sources - want at most %d", i, n, throttle.maxSources)
+		}
+	}
+	if _, ok := throttle.Banned(banned, start.Add(time.Second)); !ok {
+		t.Fatal("Banned source has been evicted")
+	}
+
+	// The most recent source is still tracked.
+	last := netip.AddrFrom4([4]byte{10, 1, 3, 231})
+	if _, ok := throttle.sources[authSourcePrefix(last)]; !ok {
+		t.Fatal("Most recent source has been evicted")
+	}
+}
+
+func TestAuthThrottling(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		Admin: kes.Identity(strings.Repeat("a", 64)), // The test client's identity is unknown
+		AuthThrottling: &AuthThrottlingConfig{
+			MaxFailures: 2,
+			Delay:       time.Millisecond,
+			MaxDelay:    time.Millisecond,
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	for i, want := range []int{http.StatusForbidden, http.StatusForbidden, http.StatusForbidden, http.StatusTooManyRequests} {
+		resp := sendRequest(ctx, t, &client.HTTPClient, http.MethodGet, url+api.PathKeyDescribe+"my-key", nil, nil)
+		resp.Body.Close()
+
+		if resp.StatusCode != want {
+			t.Fatalf("Request %d: got status '%d' - want '%d'", i, resp.StatusCode, want)
+		}
+	}
+}
+
+type authFailure struct {
+	Addr   string
+	At     time.Duration
+	Delay  time.Duration
+	Banned bool
+}
+
+var authThrottleTests = []struct {
+	Failures []authFailure
+	Addr     string
+	At       time.Duration
+	Banned   bool
+}{
+	{ // 0
+		Failures: []authFailure{
+			{Addr: "10.1.1.1", Delay: 100 * time.Millisecond},
+			{Addr: "10.1.1.1", At: time.Second, Delay: 200 * time.Millisecond},
+			{Addr: "10.1.1.1", At: 2 * time.Second, Delay: 300 * time.Millisecond},
+		},
+		Addr: "10.1.1.1",
+		At:   3 * time.Second,
+	},
+	{ // 1
+		Failures: []authFailure{
+			{Addr: "10.1.1.1", Delay: 100 * time.Millisecond},
+			{Addr: "10.1.1.1", At: time.Second, Delay: 200 * time.Millisecond},
+			{Addr: "10.1.1.1", At: 2 * time.Second, Delay: 300 * time.Millisecond},
+			{Addr: "10.1.1.1", At: 3 * time.Second, Delay: 300 * time.Millisecond, Banned: true},
+		},
+		Addr:   "10.1.1.1",
+		At:     4 * time.Second,
+		Banned: true,
+	},
+	{ // 2
+		Failures: []authFailure{
+			{Addr: "10.1.1.1", Delay: 100 * time.Millisecond},
+			{Addr: "10.1.1.1", At: time.Second, Delay: 200 * time.Millisecond},
+			{Addr: "10.1.1.1", At: 2 * time.Second, Delay: 300 * time.Millisecond},
+			{Addr: "10.1.1.1", At: 3 * time.Second, Delay: 300 * time.Millisecond, Banned: true},
+		},
+		Addr: "10.1.1.1",
+		At:   11 * time.Minute,
+	},
+	{ // 3
+		Failures: []authFailure{
+			{Addr: "10.1.1.1", Delay: 100 * time.Millisecond},
+			{Addr: "10.1.1.1", At: time.Second, Delay: 200 * time.Millisecond},
+			{Addr: "10.1.1.1", At: 2 * time.Second, Delay: 300 * time.Millisecond},
+			{Addr: "10.1.1.1", At: 3 * time.Second, Delay: 300 * time.Millisecond, Banned: true},
+		},
+		Addr: "10.1.1.2",
+		At:   4 * time.Second,
+	},
+	{ // 4
+		Failures: []authFailure{
+			{Addr: "10.1.1.1", Delay: 100 * time.Millisecond},
+			{Addr: "10.1.1.1", At: time.Second, Delay: 200 * time.Millisecond},
+			{Addr: "10.1.1.1", At: 2 * time.Second, Delay: 300 * time.Millisecond},
+			{Addr: "10.1.1.1", At: 2 * time.Minute, Delay: 100 * time.Millisecond},
+		},
+		Addr: "10.1.1.1",
+		At:   2 * time.Minute,
+	},
+	{ // 5
+		Failures: []authFailure{
+			{Addr: "2001:db8::1", Delay: 100 * time.Millisecond},
+			{Addr: "2001:db8::2", At: time.Second, Delay: 200 * time.Millisecond},
+			{Addr: "2001:db8::3", At: 2 * time.Second, Delay: 300 * time.Millisecond},
+			{Addr: "2001:db8::4", At: 3 * time.Second, Delay: 300 * time.Millisecond, Banned: true},
+		},
+		Addr:   "2001:db8::ffff",
+		At:     4 * time.Second,
+		Banned: true,
+	},
+	{ // 6
+		Failures: []authFailure{
+			{Addr: "::ffff:10.1.1.1", Delay: 100 * time.Millisecond},
+			{Addr: "10.1.1.1", At: time.Second, Delay: 200 * time.Millisecond},
+			{Addr: "::ffff:10.1.1.1", At: 2 * time.Second, Delay: 300 * time.Millisecond},
+			{Addr: "10.1.1.1", At: 3 * time.Second, Delay: 300 * time.Millisecond, Banned: true},
+		},
+		Addr:   "::ffff:10.1.1.1",
+		At:     4 * time.Second,
+		Banned: true,
+	},
+}
@@ -0,0 +1,217 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+// timeLockPrefix is the prefix of time lock entries at the key
+// store. It is followed by the key name. Like legal holds, time
+// locks never collide with keys.
+const timeLockPrefix = "-timelock-"
+
+// maxTimeWindows is the max. number of windows of a time lock.
+const maxTimeWindows = 32
+
+// timeLock restricts when a key can be used for cryptographic
+// operations. It is stored at the key store as JSON-encoded
+// api.KeyTimeLock.
+type timeLock struct {
+	conf      api.KeyTimeLock
+	notBefore time.Time
+	windows   []timeWindow
+}
+
+// timeWindow is a daily time window in UTC.
+type timeWindow struct {
+	days       [7]bool // Indexed by time.Weekday
+	start, end int     // Minutes since midnight
+}
+
+// weekdays maps weekday names to time.Weekday.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseTimeLock parses and validates the time lock.
+func parseTimeLock(conf api.KeyTimeLock) (*timeLock, api.Error) {
+	if conf.NotBefore.IsZero() && len(conf.Windows) == 0 {
+		return nil, api.NewError(http.StatusBadRequest, "invalid time lock: no start time or windows specified")
+	}
+	if len(conf.Windows) > maxTimeWindows {
+		return nil, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid time lock: more than %d windows specified", maxTimeWindows))
+	}
+
+	conf.NotBefore = conf.NotBefore.UTC()
+	lock := &timeLock{
+		conf:      conf,
+		notBefore: conf.NotBefore,
+		windows:   make([]timeWindow, 0, len(conf.Windows)),
+	}
+	for i, w := range conf.Windows {
+		start, err := time.Parse("15:04", w.Start)
+		if err != nil {
+			return nil, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid time lock: window %d: invalid start time '%s'", i, w.Start))
+		}
+		end, err := time.Parse("15:04", w.End)
+		if err != nil {
+			return nil, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid time lock: window %d: invalid end time '%s'", i, w.End))
+		}
+
+		window := timeWindow{
+			start: start.Hour()*60 + start.Minute(),
+			end:   end.Hour()*60 + end.Minute(),
+		}
+		if window.start == window.end {
+			return nil, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid time lock: window %d: start and end time are equal", i))
+		}
+		for _, day := range w.Days {
+			d, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return nil, api.NewError(http.StatusBadRequest, fmt.Sprintf("invalid time lock: window %d: invalid day '%s'", i, day))
+			}
+			window.days[d] = true
+		}
+		if len(w.Days) == 0 {
+			window.days = [7]bool{true, true, true, true, true, true, true}
+		}
+		lock.windows = append(lock.windows, window)
+	}
+	return lock, nil
+}
+
+// Allows reports whether a key with this time lock can be used
+// at the given time.
+func (l *timeLock) Allows(now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	now = now.UTC()
+	if now.Before(l.notBefore) {
+		return false
+	}
+	if len(l.windows) == 0 {
+		return true
+	}
+	for _, w := range l.windows {
+		if w.contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether t, in UTC, is within the window.
+func (w *timeWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.days[t.Weekday()] && w.start <= minute && minute < w.end
+	}
+
+	// The window ends on the next day. Hence, t is either
+	// in the part of the window that starts today or in
+	// the part of the window that started yesterday.
+	yesterday := (t.Weekday() + 6) % 7
+	return (w.days[t.Weekday()] && minute >= w.start) || (w.days[yesterday] && minute < w.end)
+}
+
+// errTimeLocked returns the error returned when using the
+// key with the given name while it is time-locked.
+func errTimeLocked(name string, lock *timeLock, now time.Time) error {
+	if now.Before(lock.notBefore) {
+		return api.NewError(http.StatusForbidden, fmt.Sprintf("key '%s' is time-locked until %s", name, lock.notBefore.Format(time.RFC3339)))
+	}
+	return api.NewError(http.StatusForbidden, fmt.Sprintf("key '%s' is time-locked: outside of its usage windows", name))
+}
+
+// loadTimeLock returns the time lock of the key with the given
+// name, or nil if the key is not time-locked.
+func loadTimeLock(ctx context.Context, c *keyCache, name string) (*timeLock, error) {
+	if isReservedEntry(name) {
+		return nil, nil
+	}
+	b, err := c.get(ctx, timeLockPrefix+name)
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseTimeLockEntry(b)
+}
+
+// parseTimeLockEntry parses a time lock stored at the key store.
+func parseTimeLockEntry(b []byte) (*timeLock, error) {
+	var conf api.KeyTimeLock
+	if err := json.Unmarshal(b, &conf); err != nil {
+		return nil, err
+	}
+	lock, apiErr := parseTimeLock(conf)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return lock, nil
+}
+
+// createTimeLockedKey creates a new key with the given name and
+// time lock. The time lock is stored before the key such that
+// the key never exists without its time lock.
+//
+// A time lock of a key that does not exist, e.g. left over by
+// an interrupted creation, is replaced.
+func createTimeLockedKey(ctx context.Context, c *keyCache, name string, key crypto.KeyVersion, lock *timeLock) error {
+	b, err := json.Marshal(lock.conf)
+	if err != nil {
+		return err
+	}
+
+	err = c.withTimeout(ctx, "create", c.writeTimeout, func(ctx context.Context) error {
+		err := c.store.Create(ctx, timeLockPrefix+name, b)
+		if !errors.Is(err, kes.ErrKeyExists) {
+			return err
+		}
+		if _, err = c.store.Get(ctx, name); err == nil {
+			return kes.ErrKeyExists
+		}
+		if !errors.Is(err, kes.ErrKeyNotFound) {
+			return err
+		}
+		return setEntry(ctx, c.store, timeLockPrefix+name, b)
+	})
+	if err != nil {
+		if errors.Is(err, kes.ErrKeyExists) {
+			return kes.ErrKeyExists
+		}
+		return err
+	}
+
+	if err = c.Create(ctx, name, key); errors.Is(err, kes.ErrKeyExists) {
+		// The key has been created, without time lock, in the
+		// meantime. Hence, the time lock must be removed. On any
+		// other error, the key may have been created. Then, the
+		// time lock must be kept.
+		c.withTimeout(ctx, "delete", c.writeTimeout, func(ctx context.Context) error {
+			return c.store.Delete(ctx, timeLockPrefix+name)
+		})
+	}
+	return err
+}
@@ -0,0 +1,167 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+)
+
+func TestTimeLockedKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, nil)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	send := func(method, path string, body any) *http.Response {
+		var b []byte
+		if body != nil {
+			var err error
+			if b, err = json.Marshal(body); err != nil {
+				t.Fatalf("Failed to encode request: %v", err)
+			}
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url+path, bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		return resp
+	}
+
+	notBefore := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	resp := send(http.MethodPut, api.PathKeyCreate+"embargo", api.CreateKeyRequest{
+		TimeLock: &api.KeyTimeLock{NotBefore: notBefore},
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to create time-locked key: status code '%d'", resp.StatusCode)
+	}
+
+	if _, err := client.Encrypt(ctx, "embargo", []byte("Hello World"), nil); err == nil {
+		t.Fatal("Encrypted with time-locked key")
+	}
+	if _, err := client.GenerateKey(ctx, "embargo", nil); err == nil {
+		t.Fatal("Generated data key with time-locked key")
+	}
+
+	resp = send(http.MethodGet, api.PathKeyDescribe+"embargo", nil)
+	var info api.DescribeKeyResponse
+	err := json.NewDecoder(resp.Body).Decode(&info)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to describe key: %v", err)
+	}
+	if info.TimeLock == nil || !info.TimeLock.NotBefore.Equal(notBefore) {
+		t.Fatalf("Time lock mismatch: got '%+v' - want not before '%v'", info.TimeLock, notBefore)
+	}
+
+	// Once deleted, the time lock must not apply to a new key.
+	resp = send(http.MethodDelete, api.PathKeyDelete+"embargo", nil)
+	resp.Body.Close()
+	if err := client.CreateKey(ctx, "embargo"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if _, err := client.Encrypt(ctx, "embargo", []byte("Hello World"), nil); err != nil {
+		t.Fatalf("Failed to encrypt with key: %v", err)
+	}
+
+	resp = send(http.MethodPut, api.PathKeyCreate+"invalid", api.CreateKeyRequest{
+		TimeLock: &api.KeyTimeLock{},
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestTimeLockAllows(t *testing.T) {
+	for i, test := range timeLockTests {
+		lock, err := parseTimeLock(test.Lock)
+		if err != nil {
+			t.Fatalf("Test %d: failed to parse time lock: %v", i, err)
+		}
+		now, _ := time.Parse(time.RFC3339, test.Now)
+		if allowed := lock.Allows(now); allowed != test.Allowed {
+			t.Fatalf("Test %d: got '%v' - want '%v'", i, allowed, test.Allowed)
+		}
+	}
+}
+
+func TestParseTimeLock(t *testing.T) {
+	for i, test := range invalidTimeLockTests {
+		if _, err := parseTimeLock(test); err == nil {
+			t.Fatalf("Test %d: parsed invalid time lock", i)
+		}
+	}
+}
+
+var timeLockTests = []struct {
+	Lock    api.KeyTimeLock
+	Now     string
+	Allowed bool
+}{
+	{ // 0
+		Lock:    api.KeyTimeLock{NotBefore: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		Now:     "2024-05-31T23:59:59Z",
+		Allowed: false,
+	},
+	{ // 1
+		Lock:    api.KeyTimeLock{NotBefore: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		Now:     "2024-06-01T00:00:00Z",
+		Allowed: true,
+	},
+	{ // 2
+		Lock:    api.KeyTimeLock{Windows: []api.KeyTimeWindow{{Start: "09:00", End: "17:00"}}},
+		Now:     "2024-06-03T12:00:00+02:00",
+		Allowed: true,
+	},
+	{ // 3
+		Lock:    api.KeyTimeLock{Windows: []api.KeyTimeWindow{{Start: "09:00", End: "17:00"}}},
+		Now:     "2024-06-03T17:00:00Z",
+		Allowed: false,
+	},
+	{ // 4: 2024-06-03 is a Monday
+		Lock:    api.KeyTimeLock{Windows: []api.KeyTimeWindow{{Days: []string{"sat", "sun"}, Start: "09:00", End: "17:00"}}},
+		Now:     "2024-06-03T12:00:00Z",
+		Allowed: false,
+	},
+	{ // 5: window from Sunday 22:00 until Monday 02:00
+		Lock:    api.KeyTimeLock{Windows: []api.KeyTimeWindow{{Days: []string{"Sun"}, Start: "22:00", End: "02:00"}}},
+		Now:     "2024-06-03T01:00:00Z",
+		Allowed: true,
+	},
+	{ // 6
+		Lock:    api.KeyTimeLock{Windows: []api.KeyTimeWindow{{Days: []string{"sun"}, Start: "22:00", End: "02:00"}}},
+		Now:     "2024-06-03T22:30:00Z",
+		Allowed: false,
+	},
+	{ // 7
+		Lock: api.KeyTimeLock{
+			NotBefore: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+			Windows:   []api.KeyTimeWindow{{Start: "09:00", End: "17:00"}},
+		},
+		Now:     "2024-06-03T12:00:00Z",
+		Allowed: false,
+	},
+}
+
+var invalidTimeLockTests = []api.KeyTimeLock{
+	{}, // 0
+	{Windows: []api.KeyTimeWindow{{Start: "9", End: "17:00"}}},                               // 1
+	{Windows: []api.KeyTimeWindow{{Start: "09:00", End: "24:00"}}},                           // 2
+	{Windows: []api.KeyTimeWindow{{Start: "09:00", End: "09:00"}}},                           // 3
+	{Windows: []api.KeyTimeWindow{{Days: []string{"monday"}, Start: "09:00", End: "17:00"}}}, // 4
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/https"
+)
+
+const (
+	// defaultTicketKeyRotation is the default interval in
+	// which a new session ticket key is generated.
+	defaultTicketKeyRotation = 24 * time.Hour
+
+	// maxTicketKeys is the number of session ticket keys
+	// kept. Tickets encrypted with older keys are rejected.
+	maxTicketKeys = 8
+
+	// ticketKeyCheckInterval is the interval in which the
+	// server checks whether a new ticket key is due.
+	ticketKeyCheckInterval = 1 * time.Minute
+)
+
+// tlsSessions manages the session ticket keys and the server-side
+// session cache, if any, of a server. The zero value enables
+// session tickets with default ticket key rotation.
+type tlsSessions struct {
+	lock      sync.Mutex
+	disabled  bool
+	rotation  time.Duration
+	cache     *https.SessionCache
+	keys      [][32]byte // Newest key first
+	rotatedAt time.Time
+}
+
+// Configure applies the given session configuration and
+// replaces all ticket keys and cached sessions.
+func (t *tlsSessions) Configure(conf *TLSSessionConfig) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.disabled, t.rotation, t.cache = false, defaultTicketKeyRotation, nil
+	if conf != nil {
+		t.disabled = conf.TicketsDisabled
+		if conf.TicketKeyRotation > 0 {
+			t.rotation = conf.TicketKeyRotation
+		}
+		if conf.CacheSize > 0 {
+			t.cache = https.NewSessionCache(conf.CacheSize, maxTicketKeys*t.rotation)
+		}
+	}
+	t.reset()
+}
+
+// Reset replaces all ticket keys and removes all cached
+// sessions. Hence, no session established before can be
+// resumed.
+func (t *tlsSessions) Reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.reset()
+}
+
+// Rotate generates a new ticket key if the newest key is older
+// than the rotation interval. It returns the current ticket keys
+// and reports whether a new key has been generated.
+func (t *tlsSessions) Rotate(now time.Time) ([][32]byte, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if len(t.keys) > 0 && now.Sub(t.rotatedAt) < t.rotation {
+		return slices.Clone(t.keys), false
+	}
+	var key [32]byte
+	rand.Read(key[:])
+
+	t.keys = slices.Insert(t.keys, 0, key)
+	t.keys = t.keys[:min(len(t.keys), maxTicketKeys)]
+	t.rotatedAt = now
+	return slices.Clone(t.keys), true
+}
+
+// Apply configures conf to use the current ticket keys and
+// session cache. It calls handshake for every completed
+// handshake, including resumptions.
+func (t *tlsSessions) Apply(conf *tls.Config, handshake func(resumed bool)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if verify := conf.VerifyConnection; handshake != nil {
+		conf.VerifyConnection = func(cs tls.ConnectionState) error {
+			if verify != nil {
+				if err := verify(cs); err != nil {
+					return err
+				}
+			}
+			handshake(cs.DidResume)
+			return nil
+		}
+	}
+	if t.disabled {
+		conf.SessionTicketsDisabled = true
+		return
+	}
+	if len(t.keys) == 0 {
+		t.reset()
+	}
+	conf.SetSessionTicketKeys(slices.Clone(t.keys))
+	if t.cache != nil && conf.WrapSession == nil && conf.UnwrapSession == nil {
+		conf.WrapSession = t.cache.WrapSession
+		conf.UnwrapSession = t.cache.UnwrapSession
+	}
+}
+
+func (t *tlsSessions) reset() {
+	var key [32]byte
+	rand.Read(key[:])
+
+	t.keys = [][32]byte{key}
+	t.rotatedAt = time.Now()
+	if t.cache != nil {
+		t.cache.Flush()
+	}
+}
+
+// startTicketKeyRotation generates new session ticket keys
+// periodically until ctx is canceled.
+func (s *Server) startTicketKeyRotation(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(ticketKeyCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.mu.Lock()
+				if keys, ok := s.sessions.Rotate(now); ok {
+					if conf := s.tls.Load(); conf != nil && !conf.SessionTicketsDisabled {
+						conf.SetSessionTicketKeys(keys)
+					}
+				}
+				s.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// tlsHandshake records a completed TLS handshake.
+func (s *Server) tlsHandshake(resumed bool) {
+	if state := s.state.Load(); state != nil {
+		state.Metrics.TLSHandshake(resumed)
+	}
+}
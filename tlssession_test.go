@@ -0,0 +1,101 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	xhttp "github.com/minio/kes/internal/http"
+)
+
+func TestTLSSessionResumption(t *testing.T) {
+	t.Parallel()
+
+	for i, test := range tlsSessionResumptionTests {
+		ctx := testContext(t)
+		srv, url := startServer(ctx, &Config{TLSSession: test.Config})
+		defer srv.Close()
+
+		client := defaultClient(url)
+		conf := client.HTTPClient.Transport.(xhttp.LegacyErrors).RoundTripper.(*http.Transport).TLSClientConfig.Clone()
+		conf.ClientSessionCache = tls.NewLRUClientSessionCache(8)
+
+		// A new connection per request, such that each request
+		// performs either a full or a resumed handshake.
+		httpClient := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig:   conf,
+				DisableKeepAlives: true,
+			},
+		}
+		send := func() bool {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+api.PathIdentitySelfDescribe, nil)
+			if err != nil {
+				t.Fatalf("Test %d: failed to create request: %v", i, err)
+			}
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				t.Fatalf("Test %d: failed to send request: %v", i, err)
+			}
+			defer resp.Body.Close()
+
+			var body api.SelfDescribeIdentityResponse
+			if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("Test %d: failed to decode response: %v", i, err)
+			}
+			if body.Identity != defaultIdentity {
+				t.Fatalf("Test %d: identity mismatch: got '%s' - want '%s'", i, body.Identity, defaultIdentity)
+			}
+			return resp.TLS.DidResume
+		}
+
+		if send() {
+			t.Fatalf("Test %d: initial handshake has been resumed", i)
+		}
+		if resumed := send(); resumed != test.Resumed {
+			t.Fatalf("Test %d: resumption mismatch: got '%v' - want '%v'", i, resumed, test.Resumed)
+		}
+
+		if err := srv.UpdateTLS(srv.tls.Load()); err != nil {
+			t.Fatalf("Test %d: failed to update TLS config: %v", i, err)
+		}
+		if send() {
+			t.Fatalf("Test %d: session established before TLS config update has been resumed", i)
+		}
+	}
+}
+
+func TestTLSSessionsRotate(t *testing.T) {
+	var s tlsSessions
+	s.Configure(&TLSSessionConfig{TicketKeyRotation: time.Hour})
+
+	now := time.Now()
+	if _, ok := s.Rotate(now); ok {
+		t.Fatal("Ticket key rotated before rotation interval elapsed")
+	}
+	for i := 1; i <= 2*maxTicketKeys; i++ {
+		keys, ok := s.Rotate(now.Add(time.Duration(i) * time.Hour))
+		if !ok {
+			t.Fatalf("Ticket key not rotated after %d intervals", i)
+		}
+		if n := min(i+1, maxTicketKeys); len(keys) != n {
+			t.Fatalf("Got %d ticket keys after %d rotations - want %d", len(keys), i, n)
+		}
+	}
+}
+
+var tlsSessionResumptionTests = []struct {
+	Config  *TLSSessionConfig
+	Resumed bool
+}{
+	{Config: nil, Resumed: true},                                       // 0
+	{Config: &TLSSessionConfig{CacheSize: 16}, Resumed: true},          // 1
+	{Config: &TLSSessionConfig{TicketsDisabled: true}, Resumed: false}, // 2
+}
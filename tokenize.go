@@ -0,0 +1,214 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"net/http"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+)
+
+// tokenizer holds the format-preserving encryption
+// configuration of a key.
+type tokenizer struct {
+	mode     string
+	alphabet []rune
+	numerals map[rune]uint16
+
+	revealFirst, revealLast int
+	maskChar                rune
+}
+
+// newTokenizers returns the tokenizers for the given
+// tokenization configurations.
+func newTokenizers(conf map[string]*TokenizationConfig) map[string]*tokenizer {
+	tokenizers := make(map[string]*tokenizer, len(conf))
+	for name, c := range conf {
+		t := &tokenizer{
+			mode:     c.Mode,
+			alphabet: []rune(c.Alphabet),
+			numerals: make(map[rune]uint16, len(c.Alphabet)),
+
+			revealFirst: c.RevealFirst,
+			revealLast:  c.RevealLast,
+			maskChar:    '*',
+		}
+		if t.mode == "" {
+			t.mode = FPEModeFF1
+		}
+		if t.revealFirst == 0 && t.revealLast == 0 {
+			t.revealLast = 4
+		}
+		if c.MaskChar != "" {
+			t.maskChar = []rune(c.MaskChar)[0]
+		}
+		for i, r := range t.alphabet {
+			t.numerals[r] = uint16(i)
+		}
+		tokenizers[name] = t
+	}
+	return tokenizers
+}
+
+// transform encrypts, or decrypts, the characters of value
+// that are part of the alphabet. All other characters, like
+// separators, remain unchanged.
+func (t *tokenizer) transform(f *crypto.FPE, value string, tweak []byte, encrypt bool) (string, error) {
+	runes := []rune(value)
+	positions := make([]int, 0, len(runes))
+	numerals := make([]uint16, 0, len(runes))
+	for i, r := range runes {
+		if n, ok := t.numerals[r]; ok {
+			positions = append(positions, i)
+			numerals = append(numerals, n)
+		}
+	}
+
+	var err error
+	if encrypt {
+		numerals, err = f.Encrypt(numerals, tweak)
+	} else {
+		numerals, err = f.Decrypt(numerals, tweak)
+	}
+	if err != nil {
+		return "", err
+	}
+	for i, pos := range positions {
+		runes[pos] = t.alphabet[numerals[i]]
+	}
+	return string(runes), nil
+}
+
+// mask replaces all characters of value that are part of the
+// alphabet with the mask character - except for the first and
+// last ones that get revealed. If revealing them would reveal
+// the entire value, all characters are masked.
+func (t *tokenizer) mask(value string) string {
+	runes := []rune(value)
+
+	var n int
+	for _, r := range runes {
+		if _, ok := t.numerals[r]; ok {
+			n++
+		}
+	}
+	revealFirst, revealLast := t.revealFirst, t.revealLast
+	if revealFirst+revealLast >= n {
+		revealFirst, revealLast = 0, 0
+	}
+
+	var i int
+	for j, r := range runes {
+		if _, ok := t.numerals[r]; !ok {
+			continue
+		}
+		if i >= revealFirst && i < n-revealLast {
+			runes[j] = t.maskChar
+		}
+		i++
+	}
+	return string(runes)
+}
+
+// Tokenization operations.
+const (
+	opTokenize = iota
+	opDetokenize
+	opReveal
+)
+
+// tokenize replaces values with tokens of the same format and length.
+func (s *Server) tokenize(resp *api.Response, req *api.Request) {
+	s.tokenizeValues(resp, req, opTokenize)
+}
+
+// detokenize replaces tokens with their original values.
+func (s *Server) detokenize(resp *api.Response, req *api.Request) {
+	s.tokenizeValues(resp, req, opDetokenize)
+}
+
+// reveal replaces tokens with their masked original values. Only
+// some characters, e.g. the last four digits of a card number, are
+// revealed. It is a separate API such that policies can allow
+// partial reveals while restricting full detokenization.
+func (s *Server) reveal(resp *api.Response, req *api.Request) {
+	s.tokenizeValues(resp, req, opReveal)
+}
+
+func (s *Server) tokenizeValues(resp *api.Response, req *api.Request, op int) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	t, ok := s.state.Load().Tokenization[req.Resource]
+	if !ok {
+		resp.Failf(http.StatusConflict, "key '%s' does not support tokenization", req.Resource)
+		return
+	}
+
+	var body api.TokenizeRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid request body")
+		return
+	}
+	tweak := body.Tweak
+	if t.mode == FPEModeFF31 && len(tweak) == 0 {
+		tweak = make([]byte, 7)
+	}
+	if t.mode == FPEModeFF31 && len(tweak) != 7 {
+		resp.Fail(http.StatusBadRequest, "invalid tweak: FF3-1 tweak must be 7 bytes long")
+		return
+	}
+
+	key, err := s.state.Load().Keys.Use(req.Context(), req.Resource)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadGateway, "failed to read key")
+		return
+	}
+	s.state.Load().Usage.UseKey(req.Resource)
+
+	// The FPE key is derived from the secret key such that
+	// the secret key is not used with different ciphers.
+	mac := hmac.New(sha256.New, key.Key.Bytes())
+	mac.Write([]byte("kes:fpe:" + t.mode))
+	f, err := crypto.NewFPE(t.mode, mac.Sum(nil), len(t.alphabet))
+	if err != nil {
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to tokenize values")
+		return
+	}
+
+	values := make([]string, 0, len(body.Values))
+	for i, value := range body.Values {
+		v, err := t.transform(f, value, tweak, op == opTokenize)
+		if err != nil {
+			resp.Failf(http.StatusBadRequest, "invalid value at index %d: must contain %d to %d characters of the key's alphabet", i, f.MinLen(), f.MaxLen())
+			return
+		}
+		if op == opReveal {
+			v = t.mask(v)
+		}
+		values = append(values, v)
+	}
+	api.ReplyWith(resp, http.StatusOK, api.TokenizeResponse{
+		Values: values,
+	})
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/minio/kes/internal/api"
+)
+
+func TestTokenize(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		Tokenization: map[string]*TokenizationConfig{
+			"cards": {Alphabet: "0123456789"},
+			"ids":   {Mode: FPEModeFF31, Alphabet: "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ", RevealFirst: 2, RevealLast: 1, MaskChar: "#"},
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	for _, name := range []string{"cards", "ids", "my-key"} {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	send := func(path string, body api.TokenizeRequest) ([]string, int) {
+		b, _ := json.Marshal(body)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, url+path, bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		resp, err := client.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request to '%s': %v", path, err)
+		}
+		defer resp.Body.Close()
+
+		var response api.TokenizeResponse
+		if resp.StatusCode == http.StatusOK {
+			if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response of '%s': %v", path, err)
+			}
+		}
+		return response.Values, resp.StatusCode
+	}
+
+	for i, test := range tokenizeTests {
+		tokens, code := send(api.PathKeyTokenize+test.Key, api.TokenizeRequest{Values: test.Values, Tweak: test.Tweak})
+		if test.ShouldFail {
+			if code == http.StatusOK {
+				t.Fatalf("Test %d: tokenization should have failed", i)
+			}
+			continue
+		}
+		if code != http.StatusOK {
+			t.Fatalf("Test %d: failed to tokenize values: got status '%d'", i, code)
+		}
+		for j, token := range tokens {
+			value := test.Values[j]
+			if token == value || len(token) != len(value) {
+				t.Fatalf("Test %d: token '%s' does not preserve the format of '%s'", i, token, value)
+			}
+			for k := range value {
+				if !strings.ContainsRune(test.Alphabet, rune(value[k])) && token[k] != value[k] {
+					t.Fatalf("Test %d: token '%s' does not preserve separators of '%s'", i, token, value)
+				}
+			}
+		}
+
+		values, code := send(api.PathKeyDetokenize+test.Key, api.TokenizeRequest{Values: tokens, Tweak: test.Tweak})
+		if code != http.StatusOK {
+			t.Fatalf("Test %d: failed to detokenize values: got status '%d'", i, code)
+		}
+		if !slices.Equal(values, test.Values) {
+			t.Fatalf("Test %d: values mismatch: got '%v' - want '%v'", i, values, test.Values)
+		}
+
+		masked, code := send(api.PathKeyReveal+test.Key, api.TokenizeRequest{Values: tokens, Tweak: test.Tweak})
+		if code != http.StatusOK {
+			t.Fatalf("Test %d: failed to reveal values: got status '%d'", i, code)
+		}
+		if !slices.Equal(masked, test.Masked) {
+			t.Fatalf("Test %d: masked values mismatch: got '%v' - want '%v'", i, masked, test.Masked)
+		}
+	}
+}
+
+var tokenizeTests = []struct {
+	Key        string
+	Alphabet   string
+	Values     []string
+	Tweak      []byte
+	Masked     []string
+	ShouldFail bool
+}{
+	{Key: "cards", Alphabet: "0123456789", Values: []string{"4111-1111-1111-1111", "5500 0000 0000 0004"}, Masked: []string{"****-****-****-1111", "**** **** **** 0004"}}, // 0
+	{Key: "cards", Alphabet: "0123456789", Values: []string{"4111111111111111"}, Tweak: []byte("merchant-42"), Masked: []string{"************1111"}},                       // 1
+	{Key: "ids", Alphabet: "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ", Values: []string{"AB-123456-C"}, Tweak: []byte("1234567"), Masked: []string{"AB-######-C"}},             // 2
+	{Key: "cards", Values: []string{"4111-1"}, ShouldFail: true},                                   // 3
+	{Key: "ids", Values: []string{"AB123456C"}, Tweak: []byte("too long tweak"), ShouldFail: true}, // 4
+	{Key: "my-key", Values: []string{"4111111111111111"}, ShouldFail: true},                        // 5
+	{Key: "cards", Values: []string{"no digits"}, ShouldFail: true},                                // 6
+}
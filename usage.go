@@ -0,0 +1,298 @@
+// Copyright 2023 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/kes/internal/cache"
+	"github.com/minio/kms-go/kes"
+)
+
+// usageEntry is the key store entry that contains the usage
+// information of all servers sharing the key store.
+const usageEntry = "-usage"
+
+// usageFlushInterval is the interval at which a server merges
+// its usage information with the usageEntry. Hence, a key used
+// at one server is reported as unused by other servers for at
+// most twice the interval.
+const usageFlushInterval = 5 * time.Minute
+
+// usageTracker records when keys have been used and when
+// identities have been authenticated successfully.
+//
+// It only keeps track of events since it has been created or,
+// once merged with the usage information of the key store,
+// since the first server sharing the key store started to track
+// usage. Hence, the absence of an event does not imply that a
+// key or identity has never been used before.
+//
+// Recording an event for a known key or identity does not
+// allocate. New keys and identities are added to a copy-on-write
+// cache. Therefore, the number of entries is bounded by the
+// number of existing keys and identities.
+type usageTracker struct {
+	since      atomic.Int64 // Unix nanoseconds
+	keys       cache.Cow[string, *atomic.Int64]
+	identities cache.Cow[kes.Identity, *atomic.Int64]
+	added      cache.Cow[kes.Identity, *atomic.Int64] // When an identity got assigned a policy
+}
+
+// newUsageTracker returns a new usageTracker that starts
+// tracking usage events at the given point in time.
+func newUsageTracker(since time.Time) *usageTracker {
+	u := &usageTracker{}
+	u.since.Store(since.UnixNano())
+	return u
+}
+
+// Since returns the point in time since when usage
+// events have been tracked.
+func (u *usageTracker) Since() time.Time { return time.Unix(0, u.since.Load()) }
+
+// UseKey records that the key with the given name has been
+// used at the current point in time.
+func (u *usageTracker) UseKey(name string) { record(&u.keys, name) }
+
+// SeeIdentity records that the identity has been authenticated
+// at the current point in time.
+func (u *usageTracker) SeeIdentity(id kes.Identity) { record(&u.identities, id) }
+
+// KeyLastUsed returns the point in time when the key with
+// the given name has been used last. It returns the zero
+// time.Time if the key has not been used since u.Since.
+func (u *usageTracker) KeyLastUsed(name string) time.Time { return lastSeen(&u.keys, name) }
+
+// IdentityLastSeen returns the point in time when the identity
+// has been authenticated last. It returns the zero time.Time if
+// the identity has not been seen since u.Since.
+func (u *usageTracker) IdentityLastSeen(id kes.Identity) time.Time {
+	return lastSeen(&u.identities, id)
+}
+
+// ForgetKey removes all usage information about the key with
+// the given name. For example, once the key has been deleted.
+func (u *usageTracker) ForgetKey(name string) { u.keys.Delete(name) }
+
+// AddIdentity records that the identity has been assigned a
+// policy at the given point in time unless an earlier point in
+// time has been recorded before.
+func (u *usageTracker) AddIdentity(id kes.Identity, at time.Time) {
+	merge(&u.added, id, at.UnixNano())
+}
+
+// IdentityAdded returns the point in time when the identity
+// has been assigned a policy. It returns the zero time.Time
+// if not known.
+func (u *usageTracker) IdentityAdded(id kes.Identity) time.Time {
+	if t, ok := u.added.Get(id); ok {
+		return time.Unix(0, t.Load())
+	}
+	return time.Time{}
+}
+
+// addIdentities records the admin and all identities with
+// a policy of the server state as added at the given point
+// in time unless they have been added before.
+func addIdentities(state *serverState, at time.Time) {
+	if !state.Admin.IsUnknown() {
+		state.Usage.AddIdentity(state.Admin, at)
+	}
+	for id := range state.Identities {
+		state.Usage.AddIdentity(id, at)
+	}
+}
+
+// usageSnapshot is the usage information stored at the
+// usageEntry. Points in time are Unix nanoseconds.
+type usageSnapshot struct {
+	Since      int64                  `json:"since"`
+	Keys       map[string]int64       `json:"keys"`
+	Identities map[kes.Identity]int64 `json:"identities"`
+	Added      map[kes.Identity]int64 `json:"added"`
+}
+
+// Snapshot returns the usage information of the tracker.
+func (u *usageTracker) Snapshot() *usageSnapshot {
+	return &usageSnapshot{
+		Since:      u.since.Load(),
+		Keys:       snapshot(&u.keys),
+		Identities: snapshot(&u.identities),
+		Added:      snapshot(&u.added),
+	}
+}
+
+// Merge merges the usage information of the snapshot into
+// the tracker. It keeps the earliest point in time since when
+// usage has been tracked, the earliest point in time when
+// identities have been added and the most recent usage events.
+func (u *usageTracker) Merge(s *usageSnapshot) {
+	for since := u.since.Load(); s.Since > 0 && s.Since < since; since = u.since.Load() {
+		if u.since.CompareAndSwap(since, s.Since) {
+			break
+		}
+	}
+	for name, t := range s.Keys {
+		merge(&u.keys, name, t)
+	}
+	for id, t := range s.Identities {
+		merge(&u.identities, id, t)
+	}
+	for id, t := range s.Added {
+		if v, ok := u.added.Get(id); ok {
+			for old := v.Load(); t < old && !v.CompareAndSwap(old, t); old = v.Load() {
+			}
+			continue
+		}
+		merge(&u.added, id, t)
+	}
+}
+
+func record[K comparable](c *cache.Cow[K, *atomic.Int64], key K) {
+	now := time.Now().UnixNano()
+	if t, ok := c.Get(key); ok {
+		t.Store(now)
+		return
+	}
+
+	t := new(atomic.Int64)
+	t.Store(now)
+	if !c.Add(key, t) { // Another request may have added the key concurrently
+		if t, ok := c.Get(key); ok {
+			t.Store(now)
+		}
+	}
+}
+
+// merge records the point in time t for the key unless a more
+// recent point in time has been recorded before.
+func merge[K comparable](c *cache.Cow[K, *atomic.Int64], key K, t int64) {
+	if v, ok := c.Get(key); ok {
+		for old := v.Load(); t > old && !v.CompareAndSwap(old, t); old = v.Load() {
+		}
+		return
+	}
+
+	v := new(atomic.Int64)
+	v.Store(t)
+	if !c.Add(key, v) { // Another request may have added the key concurrently
+		merge(c, key, t)
+	}
+}
+
+func snapshot[K comparable](c *cache.Cow[K, *atomic.Int64]) map[K]int64 {
+	keys := c.Keys()
+	m := make(map[K]int64, len(keys))
+	for _, key := range keys {
+		if t, ok := c.Get(key); ok {
+			m[key] = t.Load()
+		}
+	}
+	return m
+}
+
+func lastSeen[K comparable](c *cache.Cow[K, *atomic.Int64], key K) time.Time {
+	if t, ok := c.Get(key); ok {
+		return time.Unix(0, t.Load())
+	}
+	return time.Time{}
+}
+
+// isStale reports whether an entry, created at the given point in
+// time and used last at lastUsed, has not been used since the cutoff.
+//
+// Entries are never considered stale when usage has not been tracked
+// for long enough - i.e. since is after the cutoff.
+func isStale(createdAt, lastUsed, since, cutoff time.Time) bool {
+	return createdAt.Before(cutoff) && lastUsed.Before(cutoff) && since.Before(cutoff)
+}
+
+// loadUsage merges the usage information stored at the key
+// store into the server's usage tracker. It returns the raw
+// usageEntry, or nil if it does not exist.
+func loadUsage(ctx context.Context, state *serverState) ([]byte, error) {
+	value, err := state.Keys.store.Get(ctx, usageEntry)
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot usageSnapshot
+	if err = json.Unmarshal(value, &snapshot); err != nil {
+		return nil, fmt.Errorf("kes: invalid usage entry: %v", err)
+	}
+	state.Usage.Merge(&snapshot)
+	return value, nil
+}
+
+// flushUsage merges the server's usage information with the
+// usage information stored at the key store and stores the
+// result. It uses conditional writes, if supported, such that
+// concurrent flushes of other servers are not lost.
+func flushUsage(ctx context.Context, state *serverState) error {
+	old, err := loadUsage(ctx, state)
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(state.Usage.Snapshot())
+	if err != nil {
+		return err
+	}
+
+	store := state.Keys.store
+	switch {
+	case old == nil:
+		return store.Create(ctx, usageEntry, value)
+	case canSwap(store):
+		return swapEntry(ctx, store, usageEntry, old, value)
+	default:
+		return setEntry(ctx, store, usageEntry, value)
+	}
+}
+
+// startUsageFlusher flushes the server's usage information
+// to the key store in the background until ctx is done.
+func (s *Server) startUsageFlusher(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(usageFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				state := s.state.Load()
+				if state.Standby.IsActive() {
+					continue
+				}
+				if readOnly, _ := s.IsReadOnly(); readOnly {
+					continue
+				}
+
+				// A conflicting flush of another server is retried
+				// with the next tick. Otherwise, the usage information
+				// is queued, if enabled, and stored once the key store
+				// recovers.
+				err := flushUsage(ctx, state)
+				if err != nil && s.writeBehind != nil && s.writeBehind.usage.Len() == 0 {
+					s.writeBehind.QueueUsage(state.Usage.Snapshot())
+				}
+				if err != nil && !errors.Is(err, kes.ErrKeyExists) {
+					state.Log.WarnContext(ctx, fmt.Sprintf("failed to store usage information: %v", err))
+				}
+			}
+		}
+	}()
+}
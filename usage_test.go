@@ -0,0 +1,138 @@
+// Copyright 2023 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/metric"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestIsStale(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	cutoff := now.Add(-24 * time.Hour)
+	for i, test := range isStaleTests {
+		createdAt := now.Add(test.CreatedAt)
+		since := now.Add(test.Since)
+
+		var lastUsed time.Time
+		if test.LastUsed != 0 {
+			lastUsed = now.Add(test.LastUsed)
+		}
+		if stale := isStale(createdAt, lastUsed, since, cutoff); stale != test.Stale {
+			t.Errorf("Test %d: got 'stale=%v' - want 'stale=%v'", i, stale, test.Stale)
+		}
+	}
+}
+
+func TestUsageTracker(t *testing.T) {
+	t.Parallel()
+
+	u := newUsageTracker(time.Now())
+	if last := u.KeyLastUsed("my-key"); !last.IsZero() {
+		t.Fatalf("key has been used before: got '%v'", last)
+	}
+
+	u.UseKey("my-key")
+	if last := u.KeyLastUsed("my-key"); last.IsZero() || last.Before(u.Since()) {
+		t.Fatalf("invalid last usage: got '%v' - want time after '%v'", last, u.Since())
+	}
+
+	u.ForgetKey("my-key")
+	if last := u.KeyLastUsed("my-key"); !last.IsZero() {
+		t.Fatalf("key usage has not been removed: got '%v'", last)
+	}
+}
+
+func TestUsageTrackerMerge(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	u := newUsageTracker(now)
+	u.UseKey("my-key")
+	u.AddIdentity("my-identity", now)
+
+	other := newUsageTracker(now.Add(-time.Hour))
+	other.AddIdentity("my-identity", now.Add(-time.Hour))
+	other.Merge(u.Snapshot())
+
+	if since := other.Since(); !since.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("invalid tracking start: got '%v' - want '%v'", since, now.Add(-time.Hour))
+	}
+	if last := other.KeyLastUsed("my-key"); !last.Equal(u.KeyLastUsed("my-key")) {
+		t.Fatalf("invalid last usage: got '%v' - want '%v'", last, u.KeyLastUsed("my-key"))
+	}
+	if added := other.IdentityAdded("my-identity"); !added.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("invalid identity addition: got '%v' - want '%v'", added, now.Add(-time.Hour))
+	}
+
+	u.Merge(other.Snapshot())
+	if since := u.Since(); !since.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("invalid tracking start: got '%v' - want '%v'", since, now.Add(-time.Hour))
+	}
+	if added := u.IdentityAdded("my-identity"); !added.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("invalid identity addition: got '%v' - want '%v'", added, now.Add(-time.Hour))
+	}
+}
+
+func TestFlushUsage(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	store := &MemKeyStore{}
+	start := time.Now().AddDate(0, 0, -100)
+
+	state := &serverState{
+		Admin: kes.Identity("admin"),
+		Keys:  newCache(store, &CacheConfig{}, &KeyStoreTimeoutConfig{}, metric.New()),
+		Usage: newUsageTracker(start),
+	}
+	defer state.Keys.Close()
+
+	addIdentities(state, start)
+	state.Usage.UseKey("my-key")
+	for range 2 {
+		if err := flushUsage(ctx, state); err != nil {
+			t.Fatalf("failed to flush usage: %v", err)
+		}
+	}
+
+	// A restarted server must not lose usage information.
+	restarted := &serverState{
+		Keys:  newCache(store, &CacheConfig{}, &KeyStoreTimeoutConfig{}, metric.New()),
+		Usage: newUsageTracker(time.Now()),
+	}
+	defer restarted.Keys.Close()
+
+	if _, err := loadUsage(ctx, restarted); err != nil {
+		t.Fatalf("failed to load usage: %v", err)
+	}
+	if since := restarted.Usage.Since(); !since.Equal(start) {
+		t.Fatalf("invalid tracking start: got '%v' - want '%v'", since, start)
+	}
+	if last := restarted.Usage.KeyLastUsed("my-key"); !last.Equal(state.Usage.KeyLastUsed("my-key")) {
+		t.Fatalf("invalid last usage: got '%v' - want '%v'", last, state.Usage.KeyLastUsed("my-key"))
+	}
+	if added := restarted.Usage.IdentityAdded("admin"); !added.Equal(start) {
+		t.Fatalf("invalid identity addition: got '%v' - want '%v'", added, start)
+	}
+}
+
+var isStaleTests = []struct {
+	CreatedAt time.Duration
+	LastUsed  time.Duration // 0 means never used
+	Since     time.Duration
+	Stale     bool
+}{
+	{CreatedAt: -48 * time.Hour, Since: -48 * time.Hour, Stale: true},                            // 0
+	{CreatedAt: -48 * time.Hour, LastUsed: -36 * time.Hour, Since: -48 * time.Hour, Stale: true}, // 1
+	{CreatedAt: -48 * time.Hour, LastUsed: -1 * time.Hour, Since: -48 * time.Hour},               // 2
+	{CreatedAt: -1 * time.Hour, Since: -48 * time.Hour},                                          // 3
+	{CreatedAt: -48 * time.Hour, Since: -1 * time.Hour},                                          // 4
+}
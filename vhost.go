@@ -0,0 +1,139 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"strings"
+)
+
+// virtualHosts are virtual servers by TLS server name.
+//
+// A virtual server has its own state, e.g. TLS config, policies
+// and key store, but does not accept connections itself. Instead,
+// the server accepting a connection routes all its requests to the
+// virtual server matching the TLS server name of the connection.
+type virtualHosts map[string]*Server
+
+// Close closes all virtual servers. It returns the first
+// error encountered, if any.
+func (v virtualHosts) Close() error {
+	var err error
+	for _, vhost := range v {
+		if cErr := vhost.Close(); err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// virtualHost returns the virtual server handling requests received
+// over the TLS connection. It returns s if no virtual server matches
+// the server name of the connection.
+//
+// Requests are routed by the TLS server name, not the HTTP host header.
+// Otherwise, a client could send requests to another virtual server over
+// a connection authenticated by its own virtual server, e.g. due to HTTP/2
+// connection coalescing.
+func (s *Server) virtualHost(state *tls.ConnectionState) *Server {
+	if state == nil {
+		return s
+	}
+	return s.virtualHostByName(state.ServerName)
+}
+
+// virtualHostByName returns the virtual server with the given TLS
+// server name, or s if there is no such virtual server.
+func (s *Server) virtualHostByName(serverName string) *Server {
+	if serverName == "" {
+		return s
+	}
+	if vhosts := s.vhosts.Load(); vhosts != nil {
+		if vhost, ok := (*vhosts)[strings.ToLower(serverName)]; ok {
+			return vhost
+		}
+	}
+	return s
+}
+
+// startVirtualHosts starts a virtual server for each config. If
+// one virtual server cannot be started, it closes all others.
+func (s *Server) startVirtualHosts(ctx context.Context, addr net.Addr, confs map[string]*Config) (virtualHosts, error) {
+	vhosts := make(virtualHosts, len(confs))
+	for name, conf := range confs {
+		vhost := &Server{ShutdownTimeout: s.ShutdownTimeout}
+		vhost.ErrLevel.Set(s.ErrLevel.Level())
+		vhost.AuditLevel.Set(s.AuditLevel.Level())
+		if err := vhost.start(ctx, addr, conf); err != nil {
+			vhosts.Close()
+			return nil, err
+		}
+		vhosts[name] = vhost
+	}
+	return vhosts, nil
+}
+
+// updateVirtualHosts updates the virtual servers with the given
+// configs. It starts virtual servers that don't exist yet. The
+// returned io.Closer releases the resources of the previous
+// configs and closes the virtual servers without a config.
+func (s *Server) updateVirtualHosts(addr net.Addr, confs map[string]*Config) (io.Closer, error) {
+	var old virtualHosts
+	if vhosts := s.vhosts.Load(); vhosts != nil {
+		old = *vhosts
+	}
+
+	var (
+		closers closers
+		added   = map[string]*Config{}
+		vhosts  = make(virtualHosts, len(confs))
+	)
+	for name, conf := range confs {
+		vhost, ok := old[name]
+		if !ok {
+			added[name] = conf
+			continue
+		}
+		closer, err := vhost.Update(conf)
+		if err != nil {
+			return nil, err
+		}
+		closers = append(closers, closer)
+		vhosts[name] = vhost
+	}
+	started, err := s.startVirtualHosts(context.Background(), addr, added)
+	if err != nil {
+		return nil, err
+	}
+	for name, vhost := range started {
+		vhosts[name] = vhost
+	}
+	for name, vhost := range old {
+		if _, ok := vhosts[name]; !ok {
+			closers = append(closers, vhost)
+		}
+	}
+
+	s.vhosts.Store(&vhosts)
+	return closers, nil
+}
+
+// closers is a list of io.Closer.
+type closers []io.Closer
+
+// Close closes all io.Closers. It returns the first
+// error encountered, if any.
+func (c closers) Close() error {
+	var err error
+	for _, closer := range c {
+		if cErr := closer.Close(); err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	xhttp "github.com/minio/kes/internal/http"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestVirtualHosts(t *testing.T) {
+	t.Parallel()
+
+	const ServerName = "tenant-a.example.com"
+	cert := newVirtualHostCertificate(t, ServerName)
+
+	ctx := testContext(t)
+	tenantKeys := &MemKeyStore{}
+	srv, url := startServer(ctx, &Config{
+		Admin: kes.Identity(strings.Repeat("a", 64)), // The test client is not the admin of the server
+		VirtualHosts: map[string]*Config{
+			ServerName: {
+				Admin: defaultIdentity,
+				TLS: &tls.Config{
+					MinVersion:   tls.VersionTLS12,
+					ClientAuth:   tls.RequestClientCert,
+					Certificates: []tls.Certificate{cert},
+				},
+				Cache:    &CacheConfig{Expiry: 5 * time.Minute},
+				Keys:     tenantKeys,
+				ErrorLog: discardLog{},
+				AuditLog: discardAudit{},
+			},
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	if err := client.CreateKey(ctx, "my-key"); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Created key at server: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+
+	tenantClient := virtualHostClient(t, url, ServerName, cert)
+	if err := tenantClient.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key at virtual host: %v", err)
+	}
+	if _, err := tenantKeys.Get(ctx, "my-key"); err != nil {
+		t.Fatalf("Key has not been created at the key store of the virtual host: %v", err)
+	}
+	if _, err := tenantClient.DescribeKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to describe key at virtual host: %v", err)
+	}
+
+	// Clients connecting with an unknown server name are served
+	// by the server itself.
+	otherClient := virtualHostClient(t, url, "localhost", defaultServerCertificate())
+	if err := otherClient.CreateKey(ctx, "my-key"); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Created key with unknown server name: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
+
+// virtualHostClient returns a client with the default identity
+// that connects to the given server name.
+func virtualHostClient(t *testing.T, endpoint, serverName string, cert tls.Certificate) *kes.Client {
+	adminKey, err := kes.ParseAPIKey(defaultAPIKey)
+	if err != nil {
+		t.Fatalf("Failed to parse API key: %v", err)
+	}
+	clientCert, err := kes.GenerateCertificate(adminKey)
+	if err != nil {
+		t.Fatalf("Failed to generate client certificate: %v", err)
+	}
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(cert.Leaf)
+	client := kes.NewClientWithConfig(endpoint, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		RootCAs:    rootCAs,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &clientCert, nil
+		},
+	})
+	client.HTTPClient.Transport = xhttp.LegacyErrors{RoundTripper: client.HTTPClient.Transport}
+	return client
+}
+
+func newVirtualHostCertificate(t *testing.T, serverName string) tls.Certificate {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: serverName},
+		DNSNames:     []string{serverName},
+		NotBefore:    time.Now().Add(-1 * time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv, Leaf: leaf}
+}
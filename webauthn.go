@@ -0,0 +1,343 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+// webAuthnChallengeTTL is how long a login challenge is valid.
+const webAuthnChallengeTTL = 2 * time.Minute
+
+// Flags of the WebAuthn authenticator data.
+const (
+	webAuthnUserPresent  = 1 << 0
+	webAuthnUserVerified = 1 << 2
+)
+
+// webAuthnChallenge is a login challenge issued to an identity.
+type webAuthnChallenge struct {
+	Challenge []byte
+	NotAfter  time.Time
+}
+
+// webAuthn verifies WebAuthn assertions of registered credentials
+// and tracks the admin sessions of successfully logged in clients.
+type webAuthn struct {
+	rpID             string
+	origins          []string
+	credentials      []WebAuthnCredential
+	userVerification bool
+	ttl              time.Duration
+
+	mu         sync.Mutex
+	challenges map[kes.Identity]webAuthnChallenge
+	sessions   map[kes.Identity]time.Time // Identity -> expiry
+	counters   map[string]uint32          // Credential ID -> signature counter
+}
+
+// newWebAuthn returns a new webAuthn for the given configuration.
+// It returns nil if conf is nil. Sessions and signature counters
+// of old, if not nil, are kept.
+func newWebAuthn(conf *WebAuthnConfig, old *webAuthn) (*webAuthn, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	if conf.RPID == "" {
+		return nil, errors.New("kes: invalid WebAuthn config: no relying party ID specified")
+	}
+	if len(conf.Credentials) == 0 {
+		return nil, errors.New("kes: invalid WebAuthn config: no credentials specified")
+	}
+	for i, cred := range conf.Credentials {
+		if len(cred.ID) == 0 {
+			return nil, fmt.Errorf("kes: invalid WebAuthn config: credential %d: no ID specified", i)
+		}
+		switch key := cred.PublicKey.(type) {
+		case *ecdsa.PublicKey:
+			if key.Curve != elliptic.P256() {
+				return nil, fmt.Errorf("kes: invalid WebAuthn config: credential %d: ECDSA key is not a P-256 key", i)
+			}
+		case ed25519.PublicKey, *rsa.PublicKey:
+		default:
+			return nil, fmt.Errorf("kes: invalid WebAuthn config: credential %d: unsupported public key type %T", i, cred.PublicKey)
+		}
+	}
+	for _, origin := range conf.Origins {
+		if _, err := url.Parse(origin); err != nil {
+			return nil, fmt.Errorf("kes: invalid WebAuthn config: invalid origin '%s'", origin)
+		}
+	}
+	if conf.SessionTTL > MaxWebAuthnSessionTTL {
+		return nil, fmt.Errorf("kes: invalid WebAuthn config: session TTL exceeds %v", MaxWebAuthnSessionTTL)
+	}
+
+	ttl := conf.SessionTTL
+	if ttl <= 0 {
+		ttl = DefaultWebAuthnSessionTTL
+	}
+	w := &webAuthn{
+		rpID:             conf.RPID,
+		origins:          slices.Clone(conf.Origins),
+		credentials:      slices.Clone(conf.Credentials),
+		userVerification: conf.UserVerification,
+		ttl:              ttl,
+		challenges:       map[kes.Identity]webAuthnChallenge{},
+		sessions:         map[kes.Identity]time.Time{},
+		counters:         map[string]uint32{},
+	}
+	if old != nil {
+		old.mu.Lock()
+		maxExpiry := time.Now().Add(ttl) // Sessions must not outlive a reduced TTL
+		for identity, expiry := range old.sessions {
+			if expiry.After(maxExpiry) {
+				expiry = maxExpiry
+			}
+			w.sessions[identity] = expiry
+		}
+		for id, counter := range old.counters {
+			w.counters[id] = counter
+		}
+		old.mu.Unlock()
+	}
+	return w, nil
+}
+
+// IsAdmin reports whether the identity has a valid admin session.
+func (w *webAuthn) IsAdmin(identity kes.Identity) bool {
+	if w == nil || identity.IsUnknown() {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	expiry, ok := w.sessions[identity]
+	return ok && time.Now().Before(expiry)
+}
+
+// Challenge returns a new login challenge for the identity. It
+// replaces any previous challenge of the identity.
+func (w *webAuthn) Challenge(identity kes.Identity) (webAuthnChallenge, error) {
+	challenge := webAuthnChallenge{
+		Challenge: make([]byte, 32),
+		NotAfter:  time.Now().Add(webAuthnChallengeTTL),
+	}
+	if _, err := rand.Read(challenge.Challenge); err != nil {
+		return webAuthnChallenge{}, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	for id, c := range w.challenges {
+		if !now.Before(c.NotAfter) {
+			delete(w.challenges, id)
+		}
+	}
+	w.challenges[identity] = challenge
+	return challenge, nil
+}
+
+// Login verifies the WebAuthn assertion over the identity's login
+// challenge and starts an admin session for the identity. Each
+// challenge can only be used once.
+func (w *webAuthn) Login(identity kes.Identity, assertion *api.LoginRequest) (time.Time, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	challenge, ok := w.challenges[identity]
+	delete(w.challenges, identity)
+	if !ok || !now.Before(challenge.NotAfter) {
+		return time.Time{}, errors.New("no valid login challenge")
+	}
+
+	i := slices.IndexFunc(w.credentials, func(c WebAuthnCredential) bool { return bytes.Equal(c.ID, assertion.CredentialID) })
+	if i < 0 {
+		return time.Time{}, errors.New("credential is not registered")
+	}
+	credential := w.credentials[i]
+
+	var clientData struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	if err := json.Unmarshal(assertion.ClientData, &clientData); err != nil {
+		return time.Time{}, errors.New("invalid client data")
+	}
+	if clientData.Type != "webauthn.get" {
+		return time.Time{}, errors.New("client data is not an assertion")
+	}
+	if clientData.Challenge != base64.RawURLEncoding.EncodeToString(challenge.Challenge) {
+		return time.Time{}, errors.New("assertion does not sign the login challenge")
+	}
+	if !w.allowsOrigin(clientData.Origin) {
+		return time.Time{}, fmt.Errorf("origin '%s' is not allowed", clientData.Origin)
+	}
+
+	authData := assertion.AuthenticatorData
+	if len(authData) < 37 {
+		return time.Time{}, errors.New("invalid authenticator data")
+	}
+	if rpIDHash := sha256.Sum256([]byte(w.rpID)); !bytes.Equal(authData[:32], rpIDHash[:]) {
+		return time.Time{}, errors.New("assertion is not scoped to the relying party")
+	}
+	if flags := authData[32]; flags&webAuthnUserPresent == 0 {
+		return time.Time{}, errors.New("user is not present")
+	} else if w.userVerification && flags&webAuthnUserVerified == 0 {
+		return time.Time{}, errors.New("user is not verified")
+	}
+
+	clientDataHash := sha256.Sum256(assertion.ClientData)
+	msg := append(slices.Clip(authData), clientDataHash[:]...)
+	if !verifyWebAuthnSignature(credential.PublicKey, msg, assertion.Signature) {
+		return time.Time{}, errors.New("invalid assertion signature")
+	}
+
+	// Authenticators that support signature counters increment
+	// the counter on every assertion. A counter that does not
+	// increase indicates a cloned authenticator.
+	id := string(credential.ID)
+	if counter := binary.BigEndian.Uint32(authData[33:37]); counter != 0 || w.counters[id] != 0 {
+		if counter <= w.counters[id] {
+			return time.Time{}, errors.New("signature counter did not increase: authenticator may be cloned")
+		}
+		w.counters[id] = counter
+	}
+
+	expiry := now.Add(w.ttl)
+	for id, exp := range w.sessions {
+		if !now.Before(exp) {
+			delete(w.sessions, id)
+		}
+	}
+	w.sessions[identity] = expiry
+	return expiry, nil
+}
+
+// allowsOrigin reports whether the WebAuthn ceremony may
+// run at the given origin.
+func (w *webAuthn) allowsOrigin(origin string) bool {
+	if len(w.origins) > 0 {
+		return slices.Contains(w.origins, origin)
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Hostname() != w.rpID {
+		return false
+	}
+	return u.Scheme == "https" || (u.Scheme == "http" && u.Hostname() == "localhost")
+}
+
+// verifyWebAuthnSignature verifies a WebAuthn assertion signature
+// using the COSE algorithm that corresponds to the public key:
+// ES256, EdDSA or RS256.
+func verifyWebAuthnSignature(key crypto.PublicKey, msg, signature []byte) bool {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(msg)
+		return ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, msg, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(msg)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	default:
+		return false
+	}
+}
+
+// loginChallenge returns a WebAuthn login challenge for the
+// client identity. Clients log in with an ephemeral TLS client
+// certificate that becomes admin once the login succeeds.
+func (s *Server) loginChallenge(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	if state.WebAuthn == nil {
+		resp.Fail(http.StatusNotImplemented, "WebAuthn login is not enabled")
+		return
+	}
+	if req.Identity.IsUnknown() {
+		resp.Fail(http.StatusBadRequest, "tls: client certificate is required")
+		return
+	}
+
+	challenge, err := state.WebAuthn.Challenge(req.Identity)
+	if err != nil {
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusInternalServerError, "failed to generate challenge")
+		return
+	}
+	credentials := make([][]byte, 0, len(state.WebAuthn.credentials))
+	for _, c := range state.WebAuthn.credentials {
+		credentials = append(credentials, c.ID)
+	}
+	api.ReplyWith(resp, http.StatusOK, api.LoginChallengeResponse{
+		Challenge:        challenge.Challenge,
+		RPID:             state.WebAuthn.rpID,
+		Credentials:      credentials,
+		UserVerification: state.WebAuthn.userVerification,
+		NotAfter:         challenge.NotAfter,
+	})
+}
+
+// login verifies a WebAuthn assertion over the client's login
+// challenge and starts an admin session for the client identity.
+func (s *Server) login(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	if state.WebAuthn == nil {
+		resp.Fail(http.StatusNotImplemented, "WebAuthn login is not enabled")
+		return
+	}
+	if req.Identity.IsUnknown() {
+		resp.Fail(http.StatusBadRequest, "tls: client certificate is required")
+		return
+	}
+
+	var body api.LoginRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		state.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		resp.Fail(http.StatusBadRequest, "invalid login request body")
+		return
+	}
+
+	notAfter, err := state.WebAuthn.Login(req.Identity, &body)
+	if err != nil {
+		state.Log.WarnContext(req.Context(), "WebAuthn login failed: "+err.Error(), "req", req)
+		resp.Failr(state.authFailed(req.Request, authFailureInvalidIdentity, api.NewError(http.StatusUnauthorized, "login failed: "+err.Error())))
+		return
+	}
+	const StatusOK = http.StatusOK
+	state.Audit.Log(fmt.Sprintf("started WebAuthn admin session for identity '%s'", req.Identity), StatusOK, req)
+	api.ReplyWith(resp, StatusOK, api.LoginResponse{
+		Identity: req.Identity.String(),
+		NotAfter: notAfter,
+	})
+}
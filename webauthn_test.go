@@ -0,0 +1,169 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestWebAuthnLogin(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate credential key: %v", err)
+	}
+	credentialID := []byte("security-key")
+
+	srv, url := startServer(ctx, &Config{
+		WebAuthn: &WebAuthnConfig{
+			RPID:        "localhost",
+			Credentials: []WebAuthnCredential{{ID: credentialID, PublicKey: &key.PublicKey}},
+		},
+	})
+	defer srv.Close()
+
+	cert, _ := newRenewalCertificate(t, time.Hour)
+	client := renewalClient(url, cert)
+	if err = client.CreateKey(ctx, "my-key"); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Creating key without admin session: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+
+	challenge := func() api.LoginChallengeResponse {
+		resp := sendRequest(ctx, t, &client.HTTPClient, http.MethodPost, url+api.PathLoginChallenge, nil, nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Failed to request login challenge: status '%d'", resp.StatusCode)
+		}
+		var challenge api.LoginChallengeResponse
+		if err := json.NewDecoder(resp.Body).Decode(&challenge); err != nil {
+			t.Fatalf("Failed to decode login challenge: %v", err)
+		}
+		return challenge
+	}
+	login := func(assertion api.LoginRequest) int {
+		body, _ := json.Marshal(assertion)
+		resp := sendRequest(ctx, t, &client.HTTPClient, http.MethodPost, url+api.PathLogin, body, nil)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	c := challenge()
+	if c.RPID != "localhost" || len(c.Credentials) != 1 {
+		t.Fatalf("Invalid login challenge: got '%+v'", c)
+	}
+	if status := login(signWebAuthnAssertion(t, key, credentialID, c.Challenge, "https://evil.example.com", 1)); status != http.StatusUnauthorized {
+		t.Fatalf("Login from invalid origin: got status '%d' - want '%d'", status, http.StatusUnauthorized)
+	}
+
+	// The failed login consumed the challenge.
+	assertion := signWebAuthnAssertion(t, key, credentialID, c.Challenge, "http://localhost:7380", 2)
+	if status := login(assertion); status != http.StatusUnauthorized {
+		t.Fatalf("Login with used challenge: got status '%d' - want '%d'", status, http.StatusUnauthorized)
+	}
+
+	c = challenge()
+	if status := login(signWebAuthnAssertion(t, key, credentialID, c.Challenge, "http://localhost:7380", 2)); status != http.StatusOK {
+		t.Fatalf("Failed to login: status '%d'", status)
+	}
+	if err = client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key with admin session: %v", err)
+	}
+
+	// A signature counter that does not increase indicates a cloned authenticator.
+	c = challenge()
+	if status := login(signWebAuthnAssertion(t, key, credentialID, c.Challenge, "http://localhost:7380", 2)); status != http.StatusUnauthorized {
+		t.Fatalf("Login with replayed signature counter: got status '%d' - want '%d'", status, http.StatusUnauthorized)
+	}
+}
+
+func TestNewWebAuthn(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate credential key: %v", err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate credential key: %v", err)
+	}
+
+	for i, test := range []struct {
+		Config     WebAuthnConfig
+		ShouldFail bool
+	}{
+		{ // 0
+			Config: WebAuthnConfig{RPID: "localhost", Credentials: []WebAuthnCredential{{ID: []byte("id"), PublicKey: &key.PublicKey}}},
+		},
+		{ // 1
+			Config:     WebAuthnConfig{Credentials: []WebAuthnCredential{{ID: []byte("id"), PublicKey: &key.PublicKey}}},
+			ShouldFail: true,
+		},
+		{ // 2
+			Config:     WebAuthnConfig{RPID: "localhost"},
+			ShouldFail: true,
+		},
+		{ // 3
+			Config:     WebAuthnConfig{RPID: "localhost", Credentials: []WebAuthnCredential{{PublicKey: &key.PublicKey}}},
+			ShouldFail: true,
+		},
+		{ // 4
+			Config:     WebAuthnConfig{RPID: "localhost", Credentials: []WebAuthnCredential{{ID: []byte("id"), PublicKey: &p384.PublicKey}}},
+			ShouldFail: true,
+		},
+		{ // 5
+			Config:     WebAuthnConfig{RPID: "localhost", Credentials: []WebAuthnCredential{{ID: []byte("id"), PublicKey: &key.PublicKey}}, SessionTTL: 24 * time.Hour},
+			ShouldFail: true,
+		},
+	} {
+		_, err := newWebAuthn(&test.Config, nil)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: should have failed but succeeded", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to create WebAuthn: %v", i, err)
+		}
+	}
+}
+
+// signWebAuthnAssertion returns a WebAuthn assertion over the
+// challenge as produced by an authenticator for the "localhost"
+// relying party.
+func signWebAuthnAssertion(t *testing.T, key *ecdsa.PrivateKey, credentialID, challenge []byte, origin string, counter uint32) api.LoginRequest {
+	clientData, _ := json.Marshal(map[string]string{
+		"type":      "webauthn.get",
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    origin,
+	})
+	rpIDHash := sha256.Sum256([]byte("localhost"))
+	authData := append(rpIDHash[:], webAuthnUserPresent)
+	authData = binary.BigEndian.AppendUint32(authData, counter)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign assertion: %v", err)
+	}
+	return api.LoginRequest{
+		CredentialID:      credentialID,
+		ClientData:        clientData,
+		AuthenticatorData: authData,
+		Signature:         signature,
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/kes/internal/queue"
+	"github.com/minio/kms-go/kes"
+)
+
+// defaultWriteBehindSize is the default max. size of each
+// write-behind queue.
+const defaultWriteBehindSize = 64 << 20
+
+const (
+	// minReplayDelay and maxReplayDelay are the bounds of the
+	// delay between two failed deliveries of a queued record.
+	minReplayDelay = 1 * time.Second
+	maxReplayDelay = 1 * time.Minute
+)
+
+// writeBehind queues audit records and usage information in
+// durable local queues and delivers them in the background.
+//
+// Hence, a slow or unavailable audit handler or key store does
+// not add latency to client requests. Queued records are replayed
+// once the audit handler or key store recovers, and after a
+// restart.
+type writeBehind struct {
+	audit *queue.Queue
+	usage *queue.Queue
+
+	handler atomic.Pointer[AuditHandler] // The AuditHandler records are delivered to
+}
+
+// newWriteBehind opens the write-behind queues in the config's
+// directory, or returns nil if conf is nil.
+func newWriteBehind(conf *WriteBehindConfig) (*writeBehind, error) {
+	if conf == nil {
+		return nil, nil
+	}
+
+	maxSize := conf.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultWriteBehindSize
+	}
+	audit, err := queue.Open(filepath.Join(conf.Dir, "audit"), maxSize)
+	if err != nil {
+		return nil, fmt.Errorf("kes: failed to open write-behind queue: %v", err)
+	}
+	usage, err := queue.Open(filepath.Join(conf.Dir, "usage"), maxSize)
+	if err != nil {
+		audit.Close()
+		return nil, fmt.Errorf("kes: failed to open write-behind queue: %v", err)
+	}
+	return &writeBehind{
+		audit: audit,
+		usage: usage,
+	}, nil
+}
+
+// AuditHandler returns an AuditHandler that queues audit records
+// before passing them to h.
+func (w *writeBehind) AuditHandler(h AuditHandler) AuditHandler {
+	w.handler.Store(&h)
+	return &queuedAuditHandler{w: w}
+}
+
+// QueueUsage queues the usage snapshot such that it is stored
+// at the key store once the key store is available.
+func (w *writeBehind) QueueUsage(snapshot *usageSnapshot) error {
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return w.usage.Push(b)
+}
+
+// Close closes the write-behind queues. Queued records
+// are replayed once the queues are opened again.
+func (w *writeBehind) Close() error {
+	return errors.Join(w.audit.Close(), w.usage.Close())
+}
+
+// queuedAuditHandler is an AuditHandler that pushes audit
+// records onto the write-behind audit queue.
+type queuedAuditHandler struct {
+	w *writeBehind
+}
+
+func (q *queuedAuditHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return (*q.w.handler.Load()).Enabled(ctx, level)
+}
+
+// Handle queues the audit record. If the queue is full, it passes
+// the record to the underlying AuditHandler directly such that no
+// record is lost.
+func (q *queuedAuditHandler) Handle(ctx context.Context, r AuditRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err = q.w.audit.Push(b); err != nil {
+		return (*q.w.handler.Load()).Handle(ctx, r)
+	}
+	return nil
+}
+
+// startWriteBehind delivers queued audit records and usage
+// information in the background until ctx is done.
+func (s *Server) startWriteBehind(ctx context.Context, w *writeBehind) {
+	go replay(ctx, s, "audit records", w.audit, func(ctx context.Context, b []byte) error {
+		var r AuditRecord
+		if err := json.Unmarshal(b, &r); err != nil {
+			s.state.Load().Log.WarnContext(ctx, fmt.Sprintf("discarding invalid queued audit record: %v", err))
+			return nil
+		}
+		return (*w.handler.Load()).Handle(ctx, r)
+	})
+	go replay(ctx, s, "usage information", w.usage, func(ctx context.Context, b []byte) error {
+		var snapshot usageSnapshot
+		if err := json.Unmarshal(b, &snapshot); err != nil {
+			s.state.Load().Log.WarnContext(ctx, fmt.Sprintf("discarding invalid queued usage information: %v", err))
+			return nil
+		}
+
+		state := s.state.Load()
+		state.Usage.Merge(&snapshot)
+		if state.Standby.IsActive() {
+			return nil // The usage information is stored once the standby gets promoted
+		}
+		if readOnly, _ := s.IsReadOnly(); readOnly {
+			return errors.New("server is read-only")
+		}
+		return flushUsage(ctx, state)
+	})
+}
+
+// replay delivers the records of the queue in order until ctx
+// is done or the queue is closed. It retries failed deliveries
+// with an exponential backoff and logs once per outage.
+func replay(ctx context.Context, s *Server, name string, q *queue.Queue, deliver func(context.Context, []byte) error) {
+	delay, failing := minReplayDelay, false
+	for {
+		record, err := q.Next(ctx)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) && !errors.Is(err, queue.ErrClosed) {
+				s.state.Load().Log.ErrorContext(ctx, fmt.Sprintf("failed to read queued %s: %v", name, err))
+			}
+			return
+		}
+
+		if err = deliver(ctx, record); err != nil && !errors.Is(err, kes.ErrKeyExists) {
+			if ctx.Err() != nil {
+				return
+			}
+			if !failing {
+				s.state.Load().Log.WarnContext(ctx, fmt.Sprintf("failed to deliver queued %s: %v: retrying %d queued records in the background", name, err, q.Len()))
+				failing = true
+			}
+		}
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay = min(2*delay, maxReplayDelay)
+			continue
+		}
+
+		if failing {
+			s.state.Load().Log.InfoContext(ctx, fmt.Sprintf("delivering queued %s: %d records remaining", name, q.Len()-1))
+			delay, failing = minReplayDelay, false
+		}
+		if err = q.Ack(); err != nil {
+			if !errors.Is(err, queue.ErrClosed) {
+				s.state.Load().Log.ErrorContext(ctx, fmt.Sprintf("failed to remove queued %s: %v", name, err))
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/kms-go/kes"
+)
+
+func TestWriteBehindAudit(t *testing.T) {
+	t.Parallel()
+
+	audit := &unavailableAudit{}
+	audit.down.Store(true)
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		AuditLog:    audit,
+		WriteBehind: &WriteBehindConfig{Dir: t.TempDir()},
+	})
+	defer srv.Close()
+
+	// Requests succeed while the audit sink is unavailable.
+	client := defaultClient(url)
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if n := len(audit.Records()); n != 0 {
+		t.Fatalf("Got %d audit records - want 0", n)
+	}
+
+	// Queued records are delivered once the sink recovers.
+	audit.down.Store(false)
+	for deadline := time.Now().Add(5 * time.Second); len(audit.Records()) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("Queued audit record has not been delivered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if r := audit.Records()[0]; r.Message != "secret key 'my-key' created" {
+		t.Fatalf("Invalid audit record: got '%s' - want '%s'", r.Message, "secret key 'my-key' created")
+	}
+}
+
+func TestWriteBehindRestart(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	audit := &unavailableAudit{}
+	audit.down.Store(true)
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		AuditLog:    audit,
+		WriteBehind: &WriteBehindConfig{Dir: dir},
+	})
+	if err := defaultClient(url).CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	srv.Close()
+
+	// Queued audit records and the usage information of the
+	// first server are replayed by the restarted server.
+	audit.down.Store(false)
+	keys := &MemKeyStore{}
+	srv, _ = startServer(ctx, &Config{
+		Keys:        keys,
+		AuditLog:    audit,
+		WriteBehind: &WriteBehindConfig{Dir: dir},
+	})
+	defer srv.Close()
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		_, err := keys.Get(ctx, usageEntry)
+		if err == nil && len(audit.Records()) > 0 {
+			break
+		}
+		if err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+			t.Fatalf("Failed to fetch usage information: %v", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Queued records have not been replayed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if last := srv.state.Load().Usage.IdentityLastSeen(defaultIdentity); last.IsZero() {
+		t.Fatal("Usage information of the first server has not been replayed")
+	}
+}
+
+// unavailableAudit is an audit sink that
+// fails while it is down.
+type unavailableAudit struct {
+	recordAudit
+
+	down atomic.Bool
+}
+
+func (a *unavailableAudit) Handle(ctx context.Context, r AuditRecord) error {
+	if a.down.Load() {
+		return errors.New("audit sink is unavailable")
+	}
+	return a.recordAudit.Handle(ctx, r)
+}